@@ -0,0 +1,112 @@
+// Команда apidiff проверяет совместимость публичного API стабильных пакетов.
+//
+// Для каждого пакета описание текущего API (см. internal/apidiff)
+// сравнивается с зафиксированным в файле api.txt каталога пакета. Команда
+// завершается с кодом 1, если объявление удалено или изменено либо в
+// существующий интерфейс добавлен метод. Совместимые добавления выводятся
+// с подсказкой обновить api.txt.
+//
+// Проверка стабильных пакетов (из корня репозитория):
+//
+//	go run ./cmd/apidiff
+//
+// Обновление api.txt после совместимого добавления:
+//
+//	go run ./cmd/apidiff -update
+//
+// Тест TestAPICompatibility пакета выполняет ту же проверку в go test.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/arzzra/soft_phone/internal/apidiff"
+)
+
+// stablePackages - каталоги пакетов с гарантиями совместимости
+var stablePackages = []string{"pkg/api/v1"}
+
+// surfaceFile - имя файла описания API в каталоге пакета
+const surfaceFile = "api.txt"
+
+func main() {
+	update := flag.Bool("update", false, "перезаписать api.txt текущим API")
+	force := flag.Bool("force", false, "разрешить -update при несовместимых изменениях")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Использование: apidiff [-update [-force]] [каталог пакета...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = stablePackages
+	}
+
+	failed := false
+	for _, dir := range dirs {
+		ok, err := check(os.Stdout, dir, *update, *force)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apidiff: %v\n", err)
+			os.Exit(2)
+		}
+		failed = failed || !ok
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// check сравнивает API пакета с api.txt и при update перезаписывает файл.
+// false - найдены несовместимые изменения, которые не были записаны
+func check(w io.Writer, dir string, update, force bool) (bool, error) {
+	current, err := apidiff.Load(dir)
+	if err != nil {
+		return false, err
+	}
+	path := filepath.Join(dir, surfaceFile)
+	recorded, err := apidiff.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	report := apidiff.Compare(recorded, current)
+	if !report.Changed() {
+		fmt.Fprintf(w, "%s: API не изменился\n", dir)
+		return true, nil
+	}
+	fmt.Fprintf(w, "%s:\n%s", dir, report)
+
+	switch {
+	case !report.Compatible() && !(update && force):
+		fmt.Fprintf(w, "%s: несовместимые изменения API, вынесите их в новую major версию пакета\n", dir)
+		return false, nil
+	case !update:
+		fmt.Fprintf(w, "%s: совместимые добавления, обновите %s: go run ./cmd/apidiff -update\n", dir, path)
+		return true, nil
+	}
+
+	if err := writeSurface(path, current); err != nil {
+		return false, err
+	}
+	fmt.Fprintf(w, "%s: %s обновлен\n", dir, path)
+	return true, nil
+}
+
+// writeSurface записывает описание API в файл
+func writeSurface(path string, surface apidiff.Surface) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := surface.Write(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSource записывает исходный код пакета в каталог
+func writeSource(t *testing.T, dir, source string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "api.go"), []byte(source), 0o644); err != nil {
+		t.Fatalf("Не удалось записать исходный код: %v", err)
+	}
+}
+
+// TestCheckUpdate проверяет обновление api.txt и отказ при несовместимом
+// изменении
+func TestCheckUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "package api\n\nfunc Start(name string) error { return nil }\n")
+
+	var out bytes.Buffer
+	ok, err := check(&out, dir, false, false)
+	if err != nil || !ok {
+		t.Fatalf("Новый пакет должен быть совместим: %v, %v", ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, surfaceFile)); !os.IsNotExist(err) {
+		t.Fatal("Без -update api.txt не должен создаваться")
+	}
+
+	if ok, err := check(&out, dir, true, false); err != nil || !ok {
+		t.Fatalf("Ошибка -update: %v, %v", ok, err)
+	}
+	out.Reset()
+	if ok, _ := check(&out, dir, false, false); !ok || !strings.Contains(out.String(), "API не изменился") {
+		t.Errorf("API должен совпасть с api.txt:\n%s", out.String())
+	}
+
+	// Изменение сигнатуры не записывается без -force
+	writeSource(t, dir, "package api\n\nfunc Start(name string, port int) error { return nil }\n")
+	out.Reset()
+	if ok, _ := check(&out, dir, true, false); ok {
+		t.Errorf("Изменение сигнатуры должно быть несовместимым:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "- func Start(string) error") {
+		t.Errorf("В отчете нет удаленной сигнатуры:\n%s", out.String())
+	}
+	if ok, err := check(&out, dir, true, true); err != nil || !ok {
+		t.Fatalf("Ошибка -update -force: %v, %v", ok, err)
+	}
+	if ok, _ := check(&out, dir, false, false); !ok {
+		t.Error("После -force api.txt должен совпадать с API")
+	}
+}
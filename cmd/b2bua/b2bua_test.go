@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/emiago/sipgo/sip"
+	pionrtp "github.com/pion/rtp"
+)
+
+// testSDP возвращает SDP с аудио потоком PCMU на адресе addr
+func testSDP(addr *net.UDPAddr) string {
+	return fmt.Sprintf("v=0\r\no=- 1 1 IN IP4 %[1]s\r\ns=-\r\nc=IN IP4 %[1]s\r\nt=0 0\r\n"+
+		"m=audio %[2]d RTP/AVP 0 101\r\na=rtpmap:0 PCMU/8000\r\na=rtpmap:101 telephone-event/8000\r\n"+
+		"m=video 5000 RTP/AVP 96\r\n", addr.IP, addr.Port)
+}
+
+// TestHeaderRules проверяет разбор и применение правил заголовков
+func TestHeaderRules(t *testing.T) {
+	for _, value := range []string{"NoColon", ": value", "Bad Name: value"} {
+		if _, err := parseHeaderRule(value); err == nil {
+			t.Errorf("Ожидалась ошибка для %q", value)
+		}
+	}
+
+	identity, err := parseHeaderRule("P-Asserted-Identity: <sip:{from}@trunk.example.com>")
+	if err != nil {
+		t.Fatalf("Ошибка разбора правила: %v", err)
+	}
+	account, _ := parseHeaderRule("X-Account: {to}/{callid}")
+
+	req := sip.NewRequest(sip.INVITE, sip.Uri{User: "200", Host: "b2bua"})
+	req.AppendHeader(&sip.FromHeader{Address: sip.Uri{User: "100", Host: "a.example.com"}, Params: sip.NewParams()})
+	req.AppendHeader(&sip.ToHeader{Address: sip.Uri{User: "200", Host: "b2bua"}, Params: sip.NewParams()})
+	callID := sip.CallIDHeader("call-1")
+	req.AppendHeader(&callID)
+	req.AppendHeader(sip.NewHeader("Subject", "Тест"))
+	req.AppendHeader(sip.NewHeader("X-Account", "исходный"))
+	req.AppendHeader(sip.NewHeader("X-Internal", "секрет"))
+
+	rules := headerRules{Copy: []string{"Subject", "X-Account"}, Set: []headerRule{identity, account}}
+	out := sip.NewRequest(sip.INVITE, sip.Uri{Host: "b"})
+	for _, opt := range rules.apply(req) {
+		opt(out)
+	}
+
+	expected := map[string]string{
+		"Subject":             "Тест",
+		"X-Account":           "200/call-1",
+		"P-Asserted-Identity": "<sip:100@trunk.example.com>",
+	}
+	for name, value := range expected {
+		headers := out.GetHeaders(name)
+		if len(headers) != 1 || headers[0].Value() != value {
+			t.Errorf("Заголовок %s: ожидалось %q, получено %v", name, value, headers)
+		}
+	}
+	if out.GetHeader("X-Internal") != nil {
+		t.Error("Не указанные заголовки не должны копироваться")
+	}
+
+	if got := expand("sip:{to}@{from.host}", req); got != "sip:200@a.example.com" {
+		t.Errorf("Неверная подстановка: %s", got)
+	}
+}
+
+// TestRelaySDP проверяет замену медиа адреса в SDP
+func TestRelaySDP(t *testing.T) {
+	body := testSDP(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4000})
+
+	out, remote, err := relaySDP([]byte(body), "192.168.1.10", 30000)
+	if err != nil {
+		t.Fatalf("Ошибка замены адреса: %v", err)
+	}
+	if remote != "10.0.0.1:4000" {
+		t.Errorf("Неверный исходный адрес: %s", remote)
+	}
+	text := string(out)
+	for _, line := range []string{"c=IN IP4 192.168.1.10", "m=audio 30000 RTP/AVP 0 101", "a=rtpmap:101 telephone-event/8000", "m=video 0 "} {
+		if !strings.Contains(text, line) {
+			t.Errorf("SDP не содержит %q:\n%s", line, text)
+		}
+	}
+	if strings.Contains(text, "10.0.0.1") {
+		t.Errorf("Исходный адрес остался в SDP:\n%s", text)
+	}
+
+	if _, _, err := relaySDP([]byte("v=0\r\no=- 1 1 IN IP4 1.1.1.1\r\ns=-\r\nt=0 0\r\n"), "127.0.0.1", 1); err == nil {
+		t.Error("Ожидалась ошибка для SDP без аудио")
+	}
+}
+
+// TestParseFlags проверяет разбор параметров командной строки
+func TestParseFlags(t *testing.T) {
+	opts, err := parseFlags([]string{
+		"-sip-host", "10.0.0.2", "-target", "sip:{to}@10.0.0.5:5060",
+		"-copy", "Subject, X-Account", "-header", "X-Trunk: a", "-header", "X-From: {from}",
+	})
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if opts.rtpHost != "10.0.0.2" {
+		t.Errorf("Адрес RTP по умолчанию должен совпадать с SIP: %s", opts.rtpHost)
+	}
+	if len(opts.headers.Copy) != 2 || opts.headers.Copy[1] != "X-Account" || len(opts.headers.Set) != 2 {
+		t.Errorf("Неверные правила заголовков: %+v", opts.headers)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"-target", "not a uri"},
+		{"-target", "sip:b@host", "-header", "broken"},
+		{"-target", "sip:b@host", "-setup-timeout", "0s"},
+	} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("Ожидалась ошибка для %v", args)
+		}
+	}
+}
+
+// TestB2BUACall проверяет вызов через B2BUA целиком: перенос заголовков,
+// ретрансляцию RTP в обе стороны, передачу BYE и запись CDR
+func TestB2BUACall(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newUA := func(port int) *dialog.UACUAS {
+		ua, err := dialog.NewUACUAS(dialog.Config{
+			Contact:          "test",
+			TransportConfigs: []dialog.TransportConfig{{Type: dialog.TransportUDP, Host: "127.0.0.1", Port: port}},
+		})
+		if err != nil {
+			t.Fatalf("Ошибка создания агента: %v", err)
+		}
+		go func() { _ = ua.ListenTransports(ctx) }()
+		t.Cleanup(func() { _ = ua.Stop() })
+		return ua
+	}
+
+	caller, callee := newUA(15201), newUA(15204)
+	inbound, outbound := newUA(15202), newUA(15203)
+
+	var cdr bytes.Buffer
+	b := &b2bua{
+		ua:           outbound,
+		target:       "sip:{to}@127.0.0.1:15204",
+		rtpHost:      "127.0.0.1",
+		headers:      headerRules{Copy: []string{"Subject"}, Set: []headerRule{{Name: "X-Caller", Value: "{from}"}}},
+		setupTimeout: 5 * time.Second,
+		cdr:          newCDRWriter(&cdr),
+		ctx:          ctx,
+	}
+	inbound.OnIncomingCall(b.handleIncomingCall)
+	time.Sleep(100 * time.Millisecond)
+
+	mediaA, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	defer mediaA.Close()
+	mediaB, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	defer mediaB.Close()
+
+	incoming := make(chan *sip.Request, 1)
+	relayB := make(chan string, 1)
+	calleeDialog := make(chan dialog.IDialog, 1)
+	callee.OnIncomingCall(func(d dialog.IDialog, tx dialog.IServerTX) {
+		calleeDialog <- d
+		incoming <- tx.Request()
+		_, remote, err := relaySDP(tx.Body().Content(), "127.0.0.1", 1)
+		if err != nil {
+			t.Errorf("Ошибка разбора SDP исходящего плеча: %v", err)
+		}
+		relayB <- remote
+		_ = tx.Provisional(sip.StatusRinging, "Ringing")
+		_ = tx.Accept(dialog.ResponseWithSDP(testSDP(mediaB.LocalAddr().(*net.UDPAddr))))
+	})
+
+	d, err := caller.NewDialog(ctx)
+	if err != nil {
+		t.Fatalf("Ошибка создания диалога: %v", err)
+	}
+	tx, err := d.Start(ctx, "sip:200@127.0.0.1:15202",
+		dialog.WithSDP(testSDP(mediaA.LocalAddr().(*net.UDPAddr))),
+		dialog.WithHeaderString("Subject", "B2BUA"))
+	if err != nil {
+		t.Fatalf("Ошибка отправки INVITE: %v", err)
+	}
+
+	waitCtx, cancelWait := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelWait()
+	resp, err := tx.WaitFinal(waitCtx)
+	if err != nil || resp.StatusCode != sip.StatusOK {
+		t.Fatalf("Вызов не установлен: %v %v", resp, err)
+	}
+
+	req := <-incoming
+	if req.Recipient.User != "200" {
+		t.Errorf("Неверное назначение исходящего плеча: %s", req.Recipient.String())
+	}
+	if h := req.GetHeader("Subject"); h == nil || h.Value() != "B2BUA" {
+		t.Errorf("Subject не перенесен: %v", h)
+	}
+	if h := req.GetHeader("X-Caller"); h == nil || h.Value() != "test" {
+		t.Errorf("X-Caller не добавлен: %v", h)
+	}
+
+	// Медиа ретранслируется в обе стороны через адреса релея из SDP
+	_, relayA, err := relaySDP(resp.Body(), "127.0.0.1", 1)
+	if err != nil {
+		t.Fatalf("Ошибка разбора SDP ответа: %v", err)
+	}
+	exchange := func(from *net.UDPConn, to string, receiver *net.UDPConn, seq uint16) {
+		t.Helper()
+		addr, _ := net.ResolveUDPAddr("udp", to)
+		packet := &pionrtp.Packet{
+			Header:  pionrtp.Header{Version: 2, PayloadType: 0, SequenceNumber: seq, Timestamp: uint32(seq) * 160, SSRC: 1234},
+			Payload: bytes.Repeat([]byte{0xff}, 160),
+		}
+		data, _ := packet.Marshal()
+		if _, err := from.WriteToUDP(data, addr); err != nil {
+			t.Fatalf("Ошибка отправки RTP: %v", err)
+		}
+
+		buf := make([]byte, 1500)
+		_ = receiver.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := receiver.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("RTP не ретранслирован: %v", err)
+		}
+		var received pionrtp.Packet
+		if err := received.Unmarshal(buf[:n]); err != nil {
+			t.Fatalf("Ошибка разбора RTP: %v", err)
+		}
+		if received.SSRC == packet.SSRC || len(received.Payload) != 160 {
+			t.Errorf("Поток должен идти от SSRC релея: %d", received.SSRC)
+		}
+	}
+	exchange(mediaA, relayA, mediaB, 1)
+	exchange(mediaB, <-relayB, mediaA, 1)
+
+	// BYE входящего плеча завершает исходящее, после чего записывается CDR
+	if err := d.Terminate(); err != nil {
+		t.Fatalf("Ошибка отправки BYE: %v", err)
+	}
+	if err := (<-calleeDialog).WaitEnded(waitCtx); err != nil {
+		t.Fatalf("Исходящее плечо не завершено: %v", err)
+	}
+
+	b.wait()
+
+	var record CDR
+	if err := json.Unmarshal(cdr.Bytes(), &record); err != nil {
+		t.Fatalf("Ошибка разбора CDR %q: %v", cdr.String(), err)
+	}
+	if record.StatusCode != sip.StatusOK || record.AnsweredAt == nil || record.ReleasedBy != "A" {
+		t.Errorf("Неверный CDR: %+v", record)
+	}
+	if record.PDD <= 0 || record.SetupB < record.PDD {
+		t.Errorf("Неверные PDD %.3f и время установления %.3f исходящего плеча", record.PDD, record.SetupB)
+	}
+	if record.CallIDA != string(d.CallID()) || record.CallIDB == "" || record.CallIDB == record.CallIDA {
+		t.Errorf("Неверные Call-ID в CDR: %s / %s", record.CallIDA, record.CallIDB)
+	}
+	if record.Release == nil || record.Release.Category != dialog.NormalClearingCause().Category {
+		t.Errorf("CDR не содержит причину завершения: %+v", record.Release)
+	}
+	if record.MediaToA.Packets != 1 || record.MediaToB.Packets != 1 {
+		t.Errorf("Неверная статистика медиа: A %+v, B %+v", record.MediaToA, record.MediaToB)
+	}
+}
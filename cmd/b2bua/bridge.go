@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/emiago/sipgo/sip"
+)
+
+// releasedByB2BUA - значение CDR.ReleasedBy, когда вызов завершен самим B2BUA
+const releasedByB2BUA = "b2bua"
+
+// b2bua связывает входящие вызовы с исходящими: каждое входящее плечо (A)
+// порождает исходящее плечо (B) к Target, медиа ретранслируется relayPair,
+// а по завершении обоих плеч записывается CDR.
+type b2bua struct {
+	ua           *dialog.UACUAS
+	target       string // Шаблон URI назначения, см. headerRules
+	rtpHost      string // Адрес RTP сокетов релея и SDP обоих плеч
+	headers      headerRules
+	setupTimeout time.Duration
+	cdr          *cdrWriter
+
+	ctx   context.Context // Время работы B2BUA, ограничивает ожидание завершения вызовов
+	calls sync.WaitGroup  // Вызовы, для которых еще не записан CDR
+}
+
+// call - состояние одного вызова через B2BUA
+type call struct {
+	a     dialog.IDialog
+	b     dialog.IDialog
+	relay *relayPair
+
+	mu         sync.Mutex
+	record     CDR
+	releasedBy string
+}
+
+// markReleased фиксирует плечо, первым завершившее вызов
+func (c *call) markReleased(by string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.releasedBy == "" {
+		c.releasedBy = by
+	}
+}
+
+// handleIncomingCall обрабатывает INVITE входящего плеча. Обработчик
+// блокирует до финального ответа входящему плечу, дальнейшее сопровождение
+// вызова выполняется в отдельной горутине.
+func (b *b2bua) handleIncomingCall(a dialog.IDialog, tx dialog.IServerTX) {
+	req := tx.Request()
+	c := &call{a: a}
+	c.record = CDR{
+		CallIDA:   string(a.CallID()),
+		From:      req.From().Address.String(),
+		To:        req.To().Address.String(),
+		Target:    expand(b.target, req),
+		StartedAt: time.Now(),
+	}
+
+	b.calls.Add(1)
+	status, reason, err := b.connect(c, req, tx)
+	if err != nil {
+		c.record.Error = err.Error()
+		c.markReleased(releasedByB2BUA)
+		slog.Warn("b2bua: вызов не установлен",
+			slog.String("call_id", c.record.CallIDA),
+			slog.Int("code", status),
+			slog.String("error", err.Error()))
+		if status != 0 {
+			_ = tx.Reject(status, reason)
+		}
+	}
+	c.record.StatusCode = status
+
+	go b.finish(c)
+}
+
+// connect создает исходящее плечо и ждет его финального ответа. Возвращает
+// код и причину ответа входящему плечу; 0 - ответ уже отправлен или входящее
+// плечо отменено.
+func (b *b2bua) connect(c *call, req *sip.Request, tx dialog.IServerTX) (int, string, error) {
+	var offer []byte
+	if body := tx.Body(); body != nil {
+		offer = body.Content()
+	}
+	if len(offer) == 0 {
+		return 488, "Not Acceptable Here", fmt.Errorf("INVITE без SDP offer не поддерживается")
+	}
+
+	relay, err := newRelayPair(b.rtpHost)
+	if err != nil {
+		return sip.StatusInternalServerError, "Server Internal Error", err
+	}
+	c.relay = relay
+
+	offerB, remoteA, err := relaySDP(offer, b.rtpHost, relay.localPort(legB))
+	if err != nil {
+		return 488, "Not Acceptable Here", err
+	}
+	if err := relay.setRemote(legA, remoteA); err != nil {
+		return 488, "Not Acceptable Here", err
+	}
+
+	bDialog, err := b.ua.NewDialog(context.Background())
+	if err != nil {
+		return sip.StatusServiceUnavailable, "Service Unavailable", fmt.Errorf("создание исходящего плеча: %w", err)
+	}
+	c.b = bDialog
+	c.record.CallIDB = string(bDialog.CallID())
+
+	opts := append(b.headers.apply(req), dialog.WithSDP(string(offerB)))
+	txB, err := bDialog.Start(context.Background(), c.record.Target, opts...)
+	if err != nil {
+		c.b = nil
+		return sip.StatusServiceUnavailable, "Service Unavailable", fmt.Errorf("отправка INVITE: %w", err)
+	}
+
+	// Отмена входящего плеча до ответа отменяет исходящее
+	a := c.a
+	a.OnStateChange(func(state dialog.DialogState) {
+		if state == dialog.Terminating || state == dialog.Ended {
+			if bDialog.State() == dialog.Calling {
+				c.markReleased(legA.String())
+				_ = txB.Cancel()
+			}
+		}
+	})
+
+	go b.relayProvisional(txB, tx)
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.setupTimeout)
+	defer cancel()
+	resp, err := txB.WaitFinal(ctx)
+	c.record.PDD, c.record.SetupB = setupMilliseconds(bDialog.SetupTimes())
+	if err != nil {
+		_ = txB.Cancel()
+		return sip.StatusRequestTimeout, "Request Timeout", fmt.Errorf("нет ответа от %s: %w", c.record.Target, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		c.markReleased(legB.String())
+		if a.State() != dialog.Ringing {
+			return 0, "", nil
+		}
+		return resp.StatusCode, resp.Reason, fmt.Errorf("исходящее плечо отклонено: %d %s", resp.StatusCode, resp.Reason)
+	}
+
+	answered := time.Now()
+	c.record.AnsweredAt = &answered
+	c.record.Setup = float64(answered.Sub(c.record.StartedAt)) / float64(time.Millisecond)
+
+	answerA, remoteB, err := relaySDP(resp.Body(), b.rtpHost, relay.localPort(legA))
+	if err == nil {
+		err = relay.setRemote(legB, remoteB)
+	}
+	if err != nil {
+		_ = bDialog.Terminate()
+		return 488, "Not Acceptable Here", fmt.Errorf("SDP answer исходящего плеча: %w", err)
+	}
+
+	// Завершение одного плеча завершает другое с той же причиной
+	a.OnRelease(func(cause dialog.ReleaseCause) {
+		c.markReleased(legA.String())
+		if bDialog.State() == dialog.InCall {
+			_ = bDialog.TerminateWithCause(cause)
+		}
+	})
+	bDialog.OnRelease(func(cause dialog.ReleaseCause) {
+		c.markReleased(legB.String())
+		if a.State() == dialog.InCall {
+			_ = a.TerminateWithCause(cause)
+		}
+	})
+
+	relay.start()
+	if err := tx.Accept(dialog.ResponseWithSDP(string(answerA))); err != nil {
+		c.markReleased(legA.String())
+		_ = bDialog.Terminate()
+		return 0, "", fmt.Errorf("ответ входящему плечу: %w", err)
+	}
+	return sip.StatusOK, "OK", nil
+}
+
+// setupMilliseconds возвращает PDD и время установления исходящего плеча в
+// миллисекундах, 0 - значение не определено
+func setupMilliseconds(times dialog.CallSetupTimes) (pdd, setup float64) {
+	if d, ok := times.PDD(); ok {
+		pdd = float64(d) / float64(time.Millisecond)
+	}
+	if d, ok := times.SetupTime(); ok {
+		setup = float64(d) / float64(time.Millisecond)
+	}
+	return pdd, setup
+}
+
+// relayProvisional передает предварительные ответы исходящего плеча во
+// входящее. Ответы передаются без тела: раннее медиа не ретранслируется.
+func (b *b2bua) relayProvisional(txB dialog.IClientTX, tx dialog.IServerTX) {
+	for resp := range txB.Responses() {
+		if resp == nil || resp.StatusCode == sip.StatusTrying || resp.StatusCode >= 200 {
+			continue
+		}
+		_ = tx.Provisional(resp.StatusCode, resp.Reason)
+	}
+}
+
+// finish ждет завершения обоих плеч, останавливает релей и записывает CDR
+func (b *b2bua) finish(c *call) {
+	defer b.calls.Done()
+
+	// Исходящее плечо завершается вслед за входящим (BYE или CANCEL), его
+	// ожидание ограничено, чтобы неответившая сторона не задерживала CDR
+	_ = c.a.WaitEnded(b.ctx)
+	if c.b != nil {
+		ctx, cancel := context.WithTimeout(b.ctx, b.setupTimeout)
+		_ = c.b.WaitEnded(ctx)
+		cancel()
+	}
+
+	record := c.record
+	record.EndedAt = time.Now()
+	if c.relay != nil {
+		c.relay.stop()
+		record.MediaToA = c.relay.statistics(legA)
+		record.MediaToB = c.relay.statistics(legB)
+	}
+	if record.AnsweredAt != nil {
+		record.Duration = record.EndedAt.Sub(*record.AnsweredAt).Seconds()
+	}
+
+	c.mu.Lock()
+	record.ReleasedBy = c.releasedBy
+	c.mu.Unlock()
+	switch record.ReleasedBy {
+	case legB.String():
+		if c.b != nil {
+			record.Release = c.b.ReleaseCause()
+		}
+	default:
+		record.Release = c.a.ReleaseCause()
+	}
+
+	if err := b.cdr.write(&record); err != nil {
+		slog.Error("b2bua: ошибка записи CDR", slog.String("error", err.Error()))
+	}
+}
+
+// wait ждет записи CDR всех вызовов
+func (b *b2bua) wait() {
+	b.calls.Wait()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+)
+
+// CDR - запись о вызове через B2BUA, формируется после завершения обоих плеч
+type CDR struct {
+	CallIDA string `json:"call_id_a"`           // Call-ID входящего плеча
+	CallIDB string `json:"call_id_b,omitempty"` // Call-ID исходящего плеча
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Target  string `json:"target"` // URI назначения исходящего плеча
+
+	StartedAt  time.Time  `json:"started_at"`            // Получение INVITE
+	AnsweredAt *time.Time `json:"answered_at,omitempty"` // 2xx от исходящего плеча
+	EndedAt    time.Time  `json:"ended_at"`
+
+	StatusCode int                  `json:"status_code"`          // Финальный ответ входящему плечу
+	Setup      float64              `json:"setup_ms,omitempty"`   // От INVITE до ответа
+	PDD        float64              `json:"pdd_ms,omitempty"`     // От INVITE исходящего плеча до первого 18x или 2xx
+	SetupB     float64              `json:"setup_b_ms,omitempty"` // От INVITE исходящего плеча до 2xx
+	Duration   float64              `json:"duration_s"`           // Длительность разговора
+	ReleasedBy string               `json:"released_by"`          // Плечо, завершившее вызов: A, B или b2bua
+	Release    *dialog.ReleaseCause `json:"release,omitempty"`
+	Error      string               `json:"error,omitempty"`
+
+	// Ретранслированные пакеты по направлению отправки
+	MediaToA relayStatistics `json:"media_to_a"`
+	MediaToB relayStatistics `json:"media_to_b"`
+}
+
+// cdrWriter записывает CDR в формате JSON Lines: по объекту на строку,
+// что удобно для tail -f и загрузки в системы биллинга
+type cdrWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func newCDRWriter(w io.Writer) *cdrWriter {
+	return &cdrWriter{encoder: json.NewEncoder(w)}
+}
+
+// write записывает одну запись
+func (w *cdrWriter) write(record *CDR) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.encoder.Encode(record)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/emiago/sipgo/sip"
+)
+
+// headerRules описывает переписывание заголовков при переносе вызова из
+// входящего плеча в исходящее.
+//
+// Исходящий INVITE создается заново, поэтому заголовки входящего плеча по
+// умолчанию не переносятся: Copy перечисляет заголовки, которые копируются
+// как есть, Set задает заголовки со значениями-шаблонами. Заголовок из Set
+// заменяет скопированный с тем же именем.
+//
+// В значениях Set и в URI назначения подставляются поля входящего вызова:
+// {from} и {to} - user part адресов From и To, {from.host}, {to.host},
+// {callid} - Call-ID входящего плеча.
+type headerRules struct {
+	Copy []string
+	Set  []headerRule
+}
+
+// headerRule - заголовок с шаблоном значения
+type headerRule struct {
+	Name  string
+	Value string
+}
+
+// parseHeaderRule разбирает правило вида "Name: value"
+func parseHeaderRule(value string) (headerRule, error) {
+	name, template, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return headerRule{}, fmt.Errorf("неверное правило заголовка %q, ожидается \"Name: value\"", value)
+	}
+	return headerRule{Name: name, Value: strings.TrimSpace(template)}, nil
+}
+
+// expand подставляет поля входящего запроса в шаблон
+func expand(template string, req *sip.Request) string {
+	replacements := make([]string, 0, 10)
+	if from := req.From(); from != nil {
+		replacements = append(replacements, "{from}", from.Address.User, "{from.host}", from.Address.Host)
+	}
+	if to := req.To(); to != nil {
+		replacements = append(replacements, "{to}", to.Address.User, "{to.host}", to.Address.Host)
+	}
+	if callID := req.CallID(); callID != nil {
+		replacements = append(replacements, "{callid}", callID.Value())
+	}
+	return strings.NewReplacer(replacements...).Replace(template)
+}
+
+// apply возвращает опции исходящего INVITE для входящего запроса req
+func (r headerRules) apply(req *sip.Request) []dialog.RequestOpt {
+	overridden := make(map[string]bool, len(r.Set))
+	for _, rule := range r.Set {
+		overridden[strings.ToLower(rule.Name)] = true
+	}
+
+	var opts []dialog.RequestOpt
+	for _, name := range r.Copy {
+		if overridden[strings.ToLower(name)] {
+			continue
+		}
+		for _, header := range req.GetHeaders(name) {
+			opts = append(opts, dialog.WithHeaderString(header.Name(), header.Value()))
+		}
+	}
+	for _, rule := range r.Set {
+		opts = append(opts, dialog.WithHeaderString(rule.Name, expand(rule.Value, req)))
+	}
+	return opts
+}
+
+// stringList - повторяемый флаг командной строки
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,243 @@
+// Команда b2bua - эталонный back-to-back user agent на основе стека.
+//
+// Принимает вызовы на входящем транке (плечо A), для каждого создает
+// исходящий вызов к заданному назначению (плечо B) и связывает их:
+// предварительные и финальные ответы передаются из B в A, завершение одного
+// плеча завершает другое с той же причиной (заголовок Reason), медиа
+// ретранслируется через собственные RTP сокеты без перекодирования.
+// Заголовки входящего INVITE переносятся в исходящий по правилам -copy и
+// -header, по завершении вызова записывается CDR в формате JSON Lines.
+//
+// Команда одновременно является примером интеграции пакетов dialog и rtp:
+// блокирующие WaitFinal и WaitEnded, TerminateWithCause, ReleaseCause и
+// rtp.StreamRewriter.
+//
+// Примеры:
+//
+//	b2bua -target sip:{to}@10.0.0.5:5060
+//	b2bua -sip-host 192.168.1.10 -target sip:{to}@pbx.local -copy X-Account,Subject \
+//	    -header "P-Asserted-Identity: <sip:{from}@trunk.example.com>" -cdr cdr.jsonl -metrics :9090
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/config"
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/emiago/sipgo/sip"
+)
+
+// options содержит параметры командной строки
+type options struct {
+	configPath   string
+	sipHost      string
+	sipPort      int
+	outPort      int
+	rtpHost      string
+	target       string
+	headers      headerRules
+	setupTimeout time.Duration
+	cdrPath      string
+	metricsAddr  string
+	verbose      bool
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "b2bua: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	opts, err := parseFlags(os.Args[1:])
+	if err != nil {
+		return err
+	}
+
+	level := slog.LevelInfo
+	if opts.verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	stack, err := loadStack(opts.configPath)
+	if err != nil {
+		return err
+	}
+
+	var cdrOut io.Writer = os.Stdout
+	if opts.cdrPath != "" {
+		file, err := os.OpenFile(opts.cdrPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("открытие файла CDR: %w", err)
+		}
+		defer file.Close()
+		cdrOut = file
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	inbound, outbound, err := startUAs(ctx, stack, opts)
+	if err != nil {
+		return err
+	}
+
+	b := &b2bua{
+		ua:           outbound,
+		target:       opts.target,
+		rtpHost:      opts.rtpHost,
+		headers:      opts.headers,
+		setupTimeout: opts.setupTimeout,
+		cdr:          newCDRWriter(cdrOut),
+		ctx:          ctx,
+	}
+	inbound.OnIncomingCall(b.handleIncomingCall)
+
+	if opts.metricsAddr != "" {
+		stopMetrics := serveMetrics(opts.metricsAddr, outbound)
+		defer stopMetrics()
+	}
+
+	slog.Info("b2bua: запущен",
+		slog.String("trunk", fmt.Sprintf("%s:%d", opts.sipHost, opts.sipPort)),
+		slog.String("target", opts.target))
+
+	<-ctx.Done()
+	_ = inbound.Stop()
+	_ = outbound.Stop()
+	b.wait()
+	return nil
+}
+
+// parseFlags разбирает аргументы командной строки
+func parseFlags(args []string) (*options, error) {
+	fs := flag.NewFlagSet("b2bua", flag.ContinueOnError)
+
+	opts := &options{}
+	var copyHeaders string
+	var setHeaders stringList
+
+	fs.StringVar(&opts.configPath, "config", "", "файл конфигурации стека (YAML или JSON)")
+	fs.StringVar(&opts.sipHost, "sip-host", "127.0.0.1", "локальный адрес SIP")
+	fs.IntVar(&opts.sipPort, "sip-port", 5060, "порт входящего транка")
+	fs.IntVar(&opts.outPort, "out-port", 5062, "локальный порт исходящих вызовов")
+	fs.StringVar(&opts.rtpHost, "rtp-host", "", "адрес RTP релея (по умолчанию -sip-host)")
+	fs.StringVar(&opts.target, "target", "", "URI назначения, например sip:{to}@10.0.0.5:5060")
+	fs.StringVar(&copyHeaders, "copy", "", "заголовки входящего INVITE, копируемые в исходящий, через запятую")
+	fs.Var(&setHeaders, "header", "заголовок исходящего INVITE \"Name: value\" с подстановками {from}, {to}, {callid} (повторяемый)")
+	fs.DurationVar(&opts.setupTimeout, "setup-timeout", 60*time.Second, "таймаут ожидания ответа исходящего плеча")
+	fs.StringVar(&opts.cdrPath, "cdr", "", "файл CDR в формате JSON Lines (по умолчанию stdout)")
+	fs.StringVar(&opts.metricsAddr, "metrics", "", "адрес HTTP сервера метрик Prometheus, например :9090 (по умолчанию отключен)")
+	fs.BoolVar(&opts.verbose, "v", false, "подробное логирование")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if opts.target == "" {
+		return nil, fmt.Errorf("не задан URI назначения (-target)")
+	}
+	var probe sip.Uri
+	if err := sip.ParseUri(strings.NewReplacer("{", "", "}", "").Replace(opts.target), &probe); err != nil {
+		return nil, fmt.Errorf("неверный URI назначения %q: %w", opts.target, err)
+	}
+	if opts.rtpHost == "" {
+		opts.rtpHost = opts.sipHost
+	}
+	if opts.setupTimeout <= 0 {
+		return nil, fmt.Errorf("таймаут установления должен быть положительным")
+	}
+
+	for _, name := range strings.Split(copyHeaders, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.headers.Copy = append(opts.headers.Copy, name)
+		}
+	}
+	for _, value := range setHeaders {
+		rule, err := parseHeaderRule(value)
+		if err != nil {
+			return nil, err
+		}
+		opts.headers.Set = append(opts.headers.Set, rule)
+	}
+
+	return opts, nil
+}
+
+// loadStack загружает конфигурацию стека из файла или значения по умолчанию
+// с переопределениями из переменных окружения
+func loadStack(path string) (*config.Config, error) {
+	var stack *config.Config
+	if path != "" {
+		loaded, err := config.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		stack = loaded
+	} else {
+		stack = config.Default()
+		if err := stack.ApplyEnv(config.DefaultEnvPrefix); err != nil {
+			return nil, err
+		}
+	}
+
+	if stack.Dialog.Contact == "" {
+		stack.Dialog.Contact = "b2bua"
+	}
+	return stack, nil
+}
+
+// startUAs запускает SIP агентов входящего транка и исходящих вызовов.
+// Исходящий агент не принимает вызовы: входящие INVITE на нем отклоняются.
+func startUAs(ctx context.Context, stack *config.Config, opts *options) (*dialog.UACUAS, *dialog.UACUAS, error) {
+	inboundStack := *stack
+	inboundStack.Dialog.Transports = []config.TransportConfig{
+		{Type: string(dialog.TransportUDP), Host: opts.sipHost, Port: opts.sipPort},
+	}
+	inbound, err := startUA(ctx, &inboundStack)
+	if err != nil {
+		return nil, nil, fmt.Errorf("запуск входящего транка: %w", err)
+	}
+
+	outboundStack := *stack
+	outboundStack.Dialog.Transports = []config.TransportConfig{
+		{Type: string(dialog.TransportUDP), Host: opts.sipHost, Port: opts.outPort},
+	}
+	outbound, err := startUA(ctx, &outboundStack)
+	if err != nil {
+		_ = inbound.Stop()
+		return nil, nil, fmt.Errorf("запуск исходящего агента: %w", err)
+	}
+	outbound.OnIncomingCall(func(_ dialog.IDialog, tx dialog.IServerTX) {
+		_ = tx.Reject(sip.StatusForbidden, "Forbidden")
+	})
+
+	return inbound, outbound, nil
+}
+
+// startUA создает SIP агента и запускает прослушивание транспортов
+func startUA(ctx context.Context, stack *config.Config) (*dialog.UACUAS, error) {
+	ua, err := dialog.NewUACUAS(stack.ToDialogConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := ua.ListenTransports(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("b2bua: ошибка транспорта", slog.String("error", err.Error()))
+		}
+	}()
+
+	return ua, nil
+}
@@ -0,0 +1,26 @@
+//go:build !small
+
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// serveMetrics запускает HTTP сервер метрик Prometheus. Гистограммы PDD и
+// времени установления исходящих вызовов отдаются сборщиком метрик rtp
+// вместе с метриками RTP. Возвращает функцию остановки сервера
+func serveMetrics(addr string, outbound *dialog.UACUAS) func() {
+	collector := rtp.NewMetricsCollector(rtp.MetricsConfig{HTTPEnabled: true})
+	collector.AddPrometheusSource(outbound.CallSetupMetrics())
+	go func() {
+		if err := collector.StartHTTPServer(addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("b2bua: ошибка сервера метрик", slog.String("error", err.Error()))
+		}
+	}()
+	return func() { _ = collector.StopHTTPServer() }
+}
@@ -0,0 +1,16 @@
+//go:build small
+
+package main
+
+import (
+	"log/slog"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+)
+
+// serveMetrics в минимальной сборке ничего не запускает: сборщик метрик rtp
+// исключен тегом small
+func serveMetrics(addr string, _ *dialog.UACUAS) func() {
+	slog.Warn("b2bua: метрики недоступны в сборке с тегом small", slog.String("addr", addr))
+	return func() {}
+}
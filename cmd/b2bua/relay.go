@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// legID обозначает плечо вызова
+type legID int
+
+const (
+	legA legID = iota // Входящее плечо (транк вызывающей стороны)
+	legB              // Исходящее плечо (направление назначения)
+)
+
+// String возвращает имя плеча для логов и CDR
+func (l legID) String() string {
+	if l == legA {
+		return "A"
+	}
+	return "B"
+}
+
+// relayStatistics содержит счетчики ретрансляции одного направления
+type relayStatistics struct {
+	Packets uint64 `json:"packets"` // Ретранслированные пакеты
+	Dropped uint64 `json:"dropped"` // Отброшенные пакеты: до ответа и при переписывании
+	Errors  uint64 `json:"errors"`  // Ошибки отправки
+}
+
+// relayLeg - RTP сокет одного плеча и переписывание потока, отправляемого в него
+type relayLeg struct {
+	transport *rtp.UDPTransport
+	rewriter  *rtp.StreamRewriter
+
+	latched atomic.Bool // Удаленный адрес взят из первого входящего пакета
+	packets atomic.Uint64
+	dropped atomic.Uint64
+	errors  atomic.Uint64
+}
+
+// relayPair ретранслирует RTP между двумя плечами вызова.
+//
+// Каждое плечо получает свой UDP сокет, адрес которого подставляется в SDP
+// этого плеча. Пакеты, принятые на одном плече, переписываются
+// rtp.StreamRewriter (свой SSRC и непрерывная нумерация при смене источника)
+// и отправляются в другое. Удаленный адрес плеча берется из SDP и
+// переключается на источник первого входящего пакета (symmetric RTP), чтобы
+// медиа проходило через NAT.
+type relayPair struct {
+	legs [2]*relayLeg
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// newRelayPair открывает RTP сокеты обоих плеч на адресе host
+func newRelayPair(host string) (*relayPair, error) {
+	pair := &relayPair{}
+	for i := range pair.legs {
+		config := rtp.DefaultTransportConfig()
+		config.LocalAddr = net.JoinHostPort(host, "0")
+		transport, err := rtp.NewUDPTransport(config)
+		if err != nil {
+			pair.close()
+			return nil, fmt.Errorf("открытие RTP сокета плеча %s: %w", legID(i), err)
+		}
+		pair.legs[i] = &relayLeg{
+			transport: transport,
+			rewriter:  rtp.NewStreamRewriter(rtp.RewriterConfig{RandomizeBases: true}),
+		}
+	}
+	return pair, nil
+}
+
+// localPort возвращает локальный RTP порт плеча для SDP
+func (p *relayPair) localPort(leg legID) int {
+	return p.legs[leg].transport.LocalAddr().(*net.UDPAddr).Port
+}
+
+// setRemote задает удаленный адрес плеча из SDP
+func (p *relayPair) setRemote(leg legID, addr string) error {
+	return p.legs[leg].transport.SetRemoteAddr(addr)
+}
+
+// start запускает ретрансляцию в обоих направлениях
+func (p *relayPair) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	p.wg.Add(2)
+	go p.forward(ctx, legA, legB)
+	go p.forward(ctx, legB, legA)
+}
+
+// forward принимает пакеты плеча from и отправляет их в плечо to
+func (p *relayPair) forward(ctx context.Context, from, to legID) {
+	defer p.wg.Done()
+
+	in, out := p.legs[from], p.legs[to]
+	for {
+		packet, addr, err := in.transport.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil || !in.transport.IsActive() {
+				return
+			}
+			// Таймауты чтения и мусорные датаграммы пропускаются
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				slog.Debug("b2bua: ошибка приема RTP", slog.String("leg", from.String()), slog.String("error", err.Error()))
+			}
+			continue
+		}
+
+		if in.latched.CompareAndSwap(false, true) {
+			if remote := in.transport.RemoteAddr(); remote == nil || remote.String() != addr.String() {
+				_ = in.transport.SetRemoteAddr(addr.String())
+			}
+		}
+
+		// До ответа удаленный адрес исходящего плеча еще неизвестен
+		if out.transport.RemoteAddr() == nil {
+			out.dropped.Add(1)
+			continue
+		}
+		rewritten := out.rewriter.Rewrite(packet)
+		if rewritten == nil {
+			out.dropped.Add(1)
+			continue
+		}
+		if err := out.transport.Send(rewritten); err != nil {
+			out.errors.Add(1)
+			continue
+		}
+		out.packets.Add(1)
+	}
+}
+
+// statistics возвращает счетчики пакетов, отправленных в плечо
+func (p *relayPair) statistics(leg legID) relayStatistics {
+	l := p.legs[leg]
+	return relayStatistics{
+		Packets: l.packets.Load(),
+		Dropped: l.dropped.Load(),
+		Errors:  l.errors.Load(),
+	}
+}
+
+// stop останавливает ретрансляцию и закрывает сокеты. Повторный вызов безопасен
+func (p *relayPair) stop() {
+	p.once.Do(func() {
+		if p.cancel != nil {
+			p.cancel()
+		}
+		p.close()
+		p.wg.Wait()
+	})
+}
+
+// close закрывает открытые сокеты плеч
+func (p *relayPair) close() {
+	for _, leg := range p.legs {
+		if leg != nil {
+			_ = leg.transport.Close()
+		}
+	}
+}
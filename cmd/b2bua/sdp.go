@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/pion/sdp/v3"
+)
+
+// relaySDP заменяет медиа адрес в SDP одного плеча адресом релея.
+//
+// Кодеки и атрибуты передаются без изменений: релей не перекодирует, поэтому
+// оба плеча согласуют форматы напрямую, а payload types совпадают. Возвращает
+// новое тело и исходный медиа адрес (host:port) первого аудио потока.
+func relaySDP(body []byte, host string, port int) ([]byte, string, error) {
+	var desc sdp.SessionDescription
+	if err := desc.Unmarshal(body); err != nil {
+		return nil, "", fmt.Errorf("разбор SDP: %w", err)
+	}
+
+	var audio *sdp.MediaDescription
+	for _, media := range desc.MediaDescriptions {
+		if media.MediaName.Media == "audio" && audio == nil {
+			audio = media
+			continue
+		}
+		// Остальные потоки отклоняются: релей передает только один аудио поток
+		media.MediaName.Port = sdp.RangedPort{Value: 0}
+	}
+	if audio == nil {
+		return nil, "", fmt.Errorf("SDP не содержит аудио поток")
+	}
+
+	connection := audio.ConnectionInformation
+	if connection == nil {
+		connection = desc.ConnectionInformation
+	}
+	if connection == nil || connection.Address == nil {
+		return nil, "", fmt.Errorf("SDP не содержит адрес соединения")
+	}
+	remote := net.JoinHostPort(connection.Address.Address, strconv.Itoa(audio.MediaName.Port.Value))
+
+	addressType := "IP4"
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		addressType = "IP6"
+	}
+	local := &sdp.ConnectionInformation{
+		NetworkType: "IN",
+		AddressType: addressType,
+		Address:     &sdp.Address{Address: host},
+	}
+	desc.Origin.UnicastAddress = host
+	desc.ConnectionInformation = local
+	audio.ConnectionInformation = nil
+	audio.MediaName.Port = sdp.RangedPort{Value: port}
+
+	out, err := desc.Marshal()
+	if err != nil {
+		return nil, "", fmt.Errorf("сериализация SDP: %w", err)
+	}
+	return out, remote, nil
+}
@@ -0,0 +1,224 @@
+// Команда benchcmp сравнивает два прогона бенчмарков и сообщает о регрессиях.
+//
+// Принимает вывод `go test -bench -benchmem` базового и нового прогона. При
+// нескольких запусках одного бенчмарка (-count) используется медиана. Команда
+// завершается с кодом 1, если время или количество аллокаций на операцию
+// выросли больше заданного порога.
+//
+// Бенчмарки горячих путей стека и базовые результаты (baseline.txt):
+//
+//	go test -run '^$' -bench 'BuilderCreation|SDPOfferAnswer' -benchmem -benchtime 2000x -count 5 ./pkg/media_sdp/functional_test > new.txt
+//	go test -run '^$' -bench 'SessionRoundTrip' -benchmem -count 5 ./pkg/rtp >> new.txt
+//	go test -run '^$' -bench 'JitterBufferPushPop|DTMFGeneration' -benchmem -count 5 ./pkg/media >> new.txt
+//	go run ./cmd/benchcmp -threshold 15 cmd/benchcmp/baseline.txt new.txt
+//
+// Абсолютные значения зависят от машины, поэтому базовый прогон для проверки
+// регрессий следует снимать на той же машине, что и новый.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// result содержит медианные метрики одного бенчмарка
+type result struct {
+	NsPerOp     float64
+	BytesPerOp  float64
+	AllocsPerOp float64
+	HasMem      bool
+}
+
+func main() {
+	threshold := flag.Float64("threshold", 10, "допустимый рост ns/op, %")
+	allocThreshold := flag.Float64("alloc-threshold", 5, "допустимый рост allocs/op, %")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Использование: benchcmp [флаги] old.txt new.txt")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	oldResults, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "benchcmp: %v\n", err)
+		os.Exit(2)
+	}
+	newResults, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "benchcmp: %v\n", err)
+		os.Exit(2)
+	}
+
+	regressions := compare(os.Stdout, oldResults, newResults, *threshold, *allocThreshold)
+	if len(regressions) > 0 {
+		fmt.Fprintf(os.Stderr, "\nРегрессии производительности: %s\n", strings.Join(regressions, ", "))
+		os.Exit(1)
+	}
+}
+
+// parseFile читает вывод go test -bench из файла
+func parseFile(path string) (map[string]result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	results, err := parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
+
+// parse разбирает строки вида
+// "BenchmarkName-8  1000  1234 ns/op  56 B/op  3 allocs/op"
+// и возвращает медианы метрик по каждому бенчмарку
+func parse(r io.Reader) (map[string]result, error) {
+	samples := make(map[string][]result)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+
+		var sample result
+		hasNs := false
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("некорректное значение %q в строке %q", fields[i], scanner.Text())
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				sample.NsPerOp = value
+				hasNs = true
+			case "B/op":
+				sample.BytesPerOp = value
+				sample.HasMem = true
+			case "allocs/op":
+				sample.AllocsPerOp = value
+				sample.HasMem = true
+			}
+		}
+		if !hasNs {
+			continue
+		}
+
+		name := trimProcs(fields[0])
+		samples[name] = append(samples[name], sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]result, len(samples))
+	for name, list := range samples {
+		results[name] = result{
+			NsPerOp:     median(list, func(r result) float64 { return r.NsPerOp }),
+			BytesPerOp:  median(list, func(r result) float64 { return r.BytesPerOp }),
+			AllocsPerOp: median(list, func(r result) float64 { return r.AllocsPerOp }),
+			HasMem:      list[0].HasMem,
+		}
+	}
+	return results, nil
+}
+
+// trimProcs убирает суффикс GOMAXPROCS из имени бенчмарка ("BenchmarkX-8" -> "BenchmarkX")
+func trimProcs(name string) string {
+	if i := strings.LastIndex(name, "-"); i > 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// median возвращает медиану метрики по запускам
+func median(list []result, metric func(result) float64) float64 {
+	values := make([]float64, len(list))
+	for i, r := range list {
+		values[i] = metric(r)
+	}
+	sort.Float64s(values)
+
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// delta возвращает изменение в процентах
+func delta(oldValue, newValue float64) float64 {
+	if oldValue == 0 {
+		if newValue == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (newValue - oldValue) / oldValue * 100
+}
+
+// compare выводит таблицу сравнения и возвращает имена бенчмарков с регрессией
+func compare(w io.Writer, oldResults, newResults map[string]result, threshold, allocThreshold float64) []string {
+	var names, added []string
+	for name := range newResults {
+		if _, ok := oldResults[name]; ok {
+			names = append(names, name)
+		} else {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(added)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "benchmark\told ns/op\tnew ns/op\tdelta\told allocs\tnew allocs\tdelta\t")
+
+	var regressions []string
+	for _, name := range names {
+		oldResult, newResult := oldResults[name], newResults[name]
+
+		nsDelta := delta(oldResult.NsPerOp, newResult.NsPerOp)
+		allocDelta := delta(oldResult.AllocsPerOp, newResult.AllocsPerOp)
+
+		mark := ""
+		regressed := nsDelta > threshold
+		if oldResult.HasMem && newResult.HasMem && allocDelta > allocThreshold {
+			regressed = true
+		}
+		if regressed {
+			mark = " !"
+			regressions = append(regressions, name)
+		}
+
+		fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%+.1f%%\t%.0f\t%.0f\t%+.1f%%%s\t\n",
+			name, oldResult.NsPerOp, newResult.NsPerOp, nsDelta,
+			oldResult.AllocsPerOp, newResult.AllocsPerOp, allocDelta, mark)
+	}
+	tw.Flush()
+
+	for _, name := range added {
+		fmt.Fprintf(w, "новый бенчмарк без базового результата: %s\n", name)
+	}
+
+	return regressions
+}
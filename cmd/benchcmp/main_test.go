@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const oldRun = `goos: linux
+goarch: amd64
+pkg: github.com/arzzra/soft_phone/pkg/rtp
+BenchmarkSessionRoundTrip-8   	  500000	      2000 ns/op	     392 B/op	       4 allocs/op
+BenchmarkSessionRoundTrip-8   	  500000	      2100 ns/op	     392 B/op	       4 allocs/op
+BenchmarkSessionRoundTrip-8   	  500000	      9000 ns/op	     392 B/op	       4 allocs/op
+BenchmarkDTMFGeneration/GeneratePackets-8   	 1000000	       670 ns/op	     936 B/op	       9 allocs/op
+BenchmarkNoMem-8   	 1000000	       100 ns/op
+PASS
+ok  	github.com/arzzra/soft_phone/pkg/rtp	3.1s
+`
+
+// TestParseMedian проверяет разбор вывода go test и медиану по запускам
+func TestParseMedian(t *testing.T) {
+	results, err := parse(strings.NewReader(oldRun))
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Ожидалось 3 бенчмарка, получено %d: %v", len(results), results)
+	}
+
+	roundTrip := results["BenchmarkSessionRoundTrip"]
+	if roundTrip.NsPerOp != 2100 || roundTrip.AllocsPerOp != 4 || !roundTrip.HasMem {
+		t.Errorf("Неверная медиана: %+v", roundTrip)
+	}
+	if _, ok := results["BenchmarkDTMFGeneration/GeneratePackets"]; !ok {
+		t.Error("Суффикс GOMAXPROCS должен удаляться из имени подбенчмарка")
+	}
+	if results["BenchmarkNoMem"].HasMem {
+		t.Error("Бенчмарк без -benchmem не должен иметь метрик памяти")
+	}
+}
+
+// TestCompareThresholds проверяет обнаружение регрессий по времени и аллокациям
+func TestCompareThresholds(t *testing.T) {
+	oldResults, _ := parse(strings.NewReader(oldRun))
+	newResults, _ := parse(strings.NewReader(`
+BenchmarkSessionRoundTrip-8   	  500000	      2200 ns/op	     392 B/op	       4 allocs/op
+BenchmarkDTMFGeneration/GeneratePackets-8   	 1000000	       600 ns/op	     999 B/op	      10 allocs/op
+BenchmarkNoMem-8   	 1000000	       150 ns/op
+BenchmarkNew-8   	 1000000	       150 ns/op
+`))
+
+	var out bytes.Buffer
+	regressions := compare(&out, oldResults, newResults, 10, 0)
+
+	if strings.Join(regressions, ",") != "BenchmarkDTMFGeneration/GeneratePackets,BenchmarkNoMem" {
+		t.Errorf("Неверный список регрессий: %v\n%s", regressions, out.String())
+	}
+	if !strings.Contains(out.String(), "новый бенчмарк без базового результата: BenchmarkNew") {
+		t.Errorf("Новый бенчмарк должен быть отмечен:\n%s", out.String())
+	}
+
+	if regressions := compare(&out, oldResults, newResults, 60, 20); len(regressions) != 0 {
+		t.Errorf("С увеличенными порогами регрессий быть не должно: %v", regressions)
+	}
+}
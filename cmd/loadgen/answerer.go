@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/config"
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/emiago/sipgo/sip"
+	"github.com/pion/sdp/v3"
+)
+
+// answerer - встроенный отвечающий агент для цели "loopback".
+// Принимает все входящие вызовы, отвечает 180 Ringing и 200 OK с SDP answer
+// и, если включено медиа, отправляет тон в обратном направлении.
+type answerer struct {
+	stack     *config.Config
+	ringDelay time.Duration
+	media     bool
+	ptime     time.Duration
+	scheduler *media.Scheduler // Общий планировщик медиа сессий (опционально)
+	seq       atomic.Int64
+}
+
+// handleIncomingCall обрабатывает входящий INVITE
+func (a *answerer) handleIncomingCall(d dialog.IDialog, tx dialog.IServerTX) {
+	sessionID := fmt.Sprintf("loadgen-answer-%d", a.seq.Add(1))
+
+	// Тело берется из транзакции: Request() возвращает копию запроса без тела
+	var body []byte
+	if b := tx.Body(); b != nil {
+		body = b.Content()
+	}
+
+	var offer sdp.SessionDescription
+	if err := offer.Unmarshal(body); err != nil {
+		a.reject(tx, sip.StatusBadRequest, "Bad SDP", err)
+		return
+	}
+
+	handlerConfig := a.stack.ToHandlerConfig(sessionID)
+	if callID := tx.Request().CallID(); callID != nil {
+		handlerConfig.MediaConfig.CallID = callID.Value()
+	}
+	handlerConfig.MediaConfig.Scheduler = a.scheduler
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		a.reject(tx, sip.StatusInternalServerError, "Server Internal Error", err)
+		return
+	}
+
+	if err := handler.ProcessOffer(&offer); err != nil {
+		_ = handler.Close()
+		a.reject(tx, 488, "Not Acceptable Here", err)
+		return
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		_ = handler.Close()
+		a.reject(tx, 488, "Not Acceptable Here", err)
+		return
+	}
+	answerBody, err := answer.Marshal()
+	if err != nil {
+		_ = handler.Close()
+		a.reject(tx, sip.StatusInternalServerError, "Server Internal Error", err)
+		return
+	}
+
+	done := make(chan struct{})
+	d.OnRelease(func(dialog.ReleaseCause) {
+		close(done)
+		_ = handler.Close()
+	})
+
+	if err := tx.Provisional(sip.StatusRinging, "Ringing"); err != nil {
+		slog.Debug("loadgen: не удалось отправить 180 Ringing", slog.String("error", err.Error()))
+	}
+	if a.ringDelay > 0 {
+		time.Sleep(a.ringDelay)
+	}
+
+	if err := tx.Accept(dialog.ResponseWithSDP(string(answerBody))); err != nil {
+		slog.Warn("loadgen: не удалось принять вызов", slog.String("error", err.Error()))
+		_ = handler.Close()
+		return
+	}
+	if err := handler.Start(); err != nil {
+		slog.Warn("loadgen: не удалось запустить медиа", slog.String("error", err.Error()))
+		return
+	}
+
+	if a.media {
+		go a.sendTone(handler, done)
+	}
+}
+
+// sendTone отправляет тон удаленной стороне до завершения вызова
+func (a *answerer) sendTone(handler media_sdp.SDPMediaHandler, done <-chan struct{}) {
+	session := handler.GetMediaSession()
+	codec := testsignal.Codec(session.GetPayloadType())
+	generator := testsignal.NewGenerator(testsignal.Sine(1000, 0.2), codec, a.ptime)
+
+	ticker := time.NewTicker(a.ptime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = session.SendAudioRaw(generator.NextFrame())
+		case <-done:
+			return
+		}
+	}
+}
+
+// reject отклоняет вызов и логирует причину
+func (a *answerer) reject(tx dialog.IServerTX, code int, reason string, err error) {
+	slog.Warn("loadgen: вызов отклонен",
+		slog.Int("code", code),
+		slog.String("error", err.Error()))
+	_ = tx.Reject(code, reason)
+}
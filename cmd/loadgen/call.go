@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/config"
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/emiago/sipgo/sip"
+	"github.com/pion/sdp/v3"
+)
+
+// dtmfDigits - цифры, из которых выбираются отправляемые DTMF
+var dtmfDigits = []media.DTMFDigit{
+	media.DTMF0, media.DTMF1, media.DTMF2, media.DTMF3, media.DTMF4,
+	media.DTMF5, media.DTMF6, media.DTMF7, media.DTMF8, media.DTMF9,
+	media.DTMFStar, media.DTMFPound,
+}
+
+// dtmfDuration - длительность одной DTMF цифры
+const dtmfDuration = 100 * time.Millisecond
+
+// caller выполняет исходящие вызовы профиля нагрузки
+type caller struct {
+	ua        *dialog.UACUAS
+	stack     *config.Config
+	profile   *Profile
+	target    string
+	scheduler *media.Scheduler // Общий планировщик медиа сессий (опционально)
+}
+
+// call выполняет один вызов: INVITE, разговор с медиа и DTMF, BYE.
+// Отмена stop прерывает разговор досрочно, вызов при этом корректно завершается.
+func (c *caller) call(stop context.Context, index int, rnd *rand.Rand) (result CallResult) {
+	codec := c.profile.pickCodec(rnd)
+	hold := c.profile.pickHoldTime(rnd)
+
+	result = CallResult{
+		Index:     index,
+		Codec:     codec.Name,
+		StartedAt: time.Now(),
+	}
+
+	d, err := c.ua.NewDialog(context.Background())
+	if err != nil {
+		result.Error = fmt.Sprintf("создание диалога: %v", err)
+		return result
+	}
+	result.CallID = string(d.CallID())
+
+	builder, offer, err := c.newOffer(index, result.CallID, codec)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer func() { _ = builder.Close() }()
+
+	released := make(chan dialog.ReleaseCause, 1)
+	d.OnRelease(func(cause dialog.ReleaseCause) {
+		select {
+		case released <- cause:
+		default:
+		}
+	})
+	defer func() {
+		if cause := d.ReleaseCause(); cause != nil {
+			result.Release = string(cause.Category)
+		}
+	}()
+
+	tx, err := d.Start(context.Background(), c.target, dialog.WithSDP(offer))
+	if err != nil {
+		result.Error = fmt.Sprintf("отправка INVITE: %v", err)
+		return result
+	}
+	answer, err := c.waitAnswer(tx, &result)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	var answerSDP sdp.SessionDescription
+	if err := answerSDP.Unmarshal(answer); err != nil {
+		result.Error = fmt.Sprintf("разбор SDP answer: %v", err)
+		_ = d.Terminate()
+		return result
+	}
+	if err := builder.ProcessAnswer(&answerSDP); err != nil {
+		result.Error = fmt.Sprintf("обработка SDP answer: %v", err)
+		_ = d.Terminate()
+		return result
+	}
+	if err := builder.Start(); err != nil {
+		result.Error = fmt.Sprintf("запуск медиа: %v", err)
+		_ = d.Terminate()
+		return result
+	}
+	result.Success = true
+
+	session := builder.GetMediaSession()
+	remoteHangup := c.talk(stop, session, codec, hold, uint64(index), rnd, released, &result)
+
+	stats := session.GetStatistics()
+	result.PacketsSent = stats.AudioPacketsSent
+	result.PacketsReceived = stats.AudioPacketsReceived
+
+	if remoteHangup {
+		return result
+	}
+
+	byeSent := time.Now()
+	if err := d.Terminate(); err != nil {
+		result.Error = fmt.Sprintf("отправка BYE: %v", err)
+		return result
+	}
+	select {
+	case <-released:
+		result.Teardown = time.Since(byeSent)
+	case <-time.After(c.profile.TeardownTimeout):
+		result.Error = "таймаут ожидания ответа на BYE"
+	}
+
+	return result
+}
+
+// newOffer создает медиа сессию вызова и SDP offer для выбранного кодека
+func (c *caller) newOffer(index int, callID string, codec codecWeight) (media_sdp.SDPMediaBuilder, string, error) {
+	builderConfig := c.stack.ToBuilderConfig(fmt.Sprintf("loadgen-%d", index))
+	builderConfig.MediaConfig.CallID = callID
+	builderConfig.MediaConfig.Scheduler = c.scheduler
+	builderConfig.PayloadType = codec.payloadType()
+	builderConfig.ClockRate = testsignal.SampleRate
+	builderConfig.Ptime = c.profile.Ptime
+	builderConfig.MediaConfig.PayloadType = media.PayloadType(codec.Codec)
+	builderConfig.MediaConfig.Ptime = c.profile.Ptime
+	if c.profile.DTMFRate > 0 {
+		builderConfig.DTMFEnabled = true
+		builderConfig.MediaConfig.DTMFEnabled = true
+	}
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("создание медиа сессии: %w", err)
+	}
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		_ = builder.Close()
+		return nil, "", fmt.Errorf("создание SDP offer: %w", err)
+	}
+
+	body, err := offer.Marshal()
+	if err != nil {
+		_ = builder.Close()
+		return nil, "", fmt.Errorf("сериализация SDP offer: %w", err)
+	}
+
+	return builder, string(body), nil
+}
+
+// waitAnswer ожидает финальный ответ на INVITE, фиксируя PDD и время установления.
+// Возвращает тело 2xx ответа.
+func (c *caller) waitAnswer(tx dialog.IClientTX, result *CallResult) ([]byte, error) {
+	timeout := time.NewTimer(c.profile.SetupTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case resp, ok := <-tx.Responses():
+			if !ok {
+				return nil, fmt.Errorf("транзакция INVITE завершена без финального ответа")
+			}
+			if resp == nil {
+				continue
+			}
+
+			elapsed := time.Since(result.StartedAt)
+			switch {
+			case resp.StatusCode < 200:
+				if resp.StatusCode >= 180 && result.PDD == 0 {
+					result.PDD = elapsed
+				}
+			case resp.StatusCode < 300:
+				result.StatusCode = resp.StatusCode
+				result.Setup = elapsed
+				if result.PDD == 0 {
+					result.PDD = elapsed
+				}
+				if len(resp.Body()) == 0 {
+					return nil, fmt.Errorf("200 OK без SDP answer")
+				}
+				return resp.Body(), nil
+			default:
+				result.StatusCode = resp.StatusCode
+				return nil, fmt.Errorf("вызов отклонен: %d %s", resp.StatusCode, resp.Reason)
+			}
+
+		case <-timeout.C:
+			_ = tx.Cancel()
+			result.StatusCode = sip.StatusRequestTimeout
+			return nil, fmt.Errorf("таймаут установления вызова")
+		}
+	}
+}
+
+// talk имитирует разговор: отправляет аудио кадры каждые ptime и DTMF цифры
+// с заданной частотой. Возвращает true, если вызов завершила удаленная сторона.
+func (c *caller) talk(stop context.Context, session *media.MediaSession, codec codecWeight, hold time.Duration,
+	seed uint64, rnd *rand.Rand, released <-chan dialog.ReleaseCause, result *CallResult) bool {
+	holdTimer := time.NewTimer(hold)
+	defer holdTimer.Stop()
+
+	var frames <-chan time.Time
+	var generator *testsignal.Generator
+	if c.profile.Media {
+		signal, _ := newSignal(c.profile.Signal, seed)
+		generator = testsignal.NewGenerator(signal, codec.Codec, c.profile.Ptime)
+		ticker := time.NewTicker(c.profile.Ptime)
+		defer ticker.Stop()
+		frames = ticker.C
+	}
+
+	var dtmf <-chan time.Time
+	if c.profile.DTMFRate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / c.profile.DTMFRate))
+		defer ticker.Stop()
+		dtmf = ticker.C
+	}
+
+	for {
+		select {
+		case <-frames:
+			_ = session.SendAudioRaw(generator.NextFrame())
+		case <-dtmf:
+			digit := dtmfDigits[rnd.Intn(len(dtmfDigits))]
+			if err := session.SendDTMF(digit, dtmfDuration); err == nil {
+				result.DTMFSent++
+			}
+		case <-released:
+			result.Hold = time.Since(result.StartedAt) - result.Setup
+			return true
+		case <-holdTimer.C:
+			result.Hold = hold
+			return false
+		case <-stop.Done():
+			result.Hold = time.Since(result.StartedAt) - result.Setup
+			return false
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+)
+
+// TestParseCodecMix проверяет разбор смеси кодеков
+func TestParseCodecMix(t *testing.T) {
+	mix, err := parseCodecMix("pcmu:70, PCMA:30")
+	if err != nil {
+		t.Fatalf("Ошибка разбора смеси кодеков: %v", err)
+	}
+	if len(mix) != 2 || mix[0].Codec != testsignal.PCMU || mix[0].Weight != 70 ||
+		mix[1].Codec != testsignal.PCMA || mix[1].Weight != 30 {
+		t.Errorf("Неверная смесь кодеков: %+v", mix)
+	}
+
+	mix, err = parseCodecMix("PCMA")
+	if err != nil || len(mix) != 1 || mix[0].Weight != 1 {
+		t.Errorf("Вес по умолчанию должен быть 1: %+v, %v", mix, err)
+	}
+
+	for _, value := range []string{"", "G729:10", "PCMU:0", "PCMU:x", "PCMU,PCMU"} {
+		if _, err := parseCodecMix(value); err == nil {
+			t.Errorf("Ожидалась ошибка для %q", value)
+		}
+	}
+}
+
+// TestPickCodecWeights проверяет распределение кодеков согласно весам
+func TestPickCodecWeights(t *testing.T) {
+	mix, _ := parseCodecMix("PCMU:80,PCMA:20")
+	profile := &Profile{Codecs: mix}
+	rnd := rand.New(rand.NewSource(1))
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[profile.pickCodec(rnd).Name]++
+	}
+	if counts["PCMU"] < 7500 || counts["PCMU"] > 8500 {
+		t.Errorf("Доля PCMU вне ожидаемого диапазона: %v", counts)
+	}
+
+	profile.HoldTime = time.Second
+	profile.HoldJitter = 200 * time.Millisecond
+	for i := 0; i < 1000; i++ {
+		hold := profile.pickHoldTime(rnd)
+		if hold < 800*time.Millisecond || hold > 1200*time.Millisecond {
+			t.Fatalf("Длительность разговора вне диапазона: %v", hold)
+		}
+	}
+}
+
+// TestProfileValidate проверяет проверку профиля нагрузки
+func TestProfileValidate(t *testing.T) {
+	valid := func() *Profile {
+		mix, _ := parseCodecMix("PCMU")
+		return &Profile{
+			Target: targetLoopback, Calls: 1, Rate: 1, Concurrency: 1,
+			HoldTime: time.Second, Codecs: mix, Ptime: 20 * time.Millisecond, Signal: "tone",
+		}
+	}
+
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Корректный профиль не прошел проверку: %v", err)
+	}
+
+	for name, modify := range map[string]func(p *Profile){
+		"цель":           func(p *Profile) { p.Target = "example.com" },
+		"без лимитов":    func(p *Profile) { p.Calls = 0 },
+		"интенсивность":  func(p *Profile) { p.Rate = 0 },
+		"конкурентность": func(p *Profile) { p.Concurrency = 0 },
+		"отклонение":     func(p *Profile) { p.HoldJitter = 2 * time.Second },
+		"сигнал":         func(p *Profile) { p.Signal = "music" },
+		"dtmf":           func(p *Profile) { p.DTMFRate = -1 },
+	} {
+		p := valid()
+		modify(p)
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: ожидалась ошибка проверки", name)
+		}
+	}
+}
+
+// TestLatencyStats проверяет вычисление перцентилей
+func TestLatencyStats(t *testing.T) {
+	values := make([]time.Duration, 0, 100)
+	for i := 100; i >= 1; i-- {
+		values = append(values, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := latencyStats(values)
+	if stats.Count != 100 || stats.Min != 1 || stats.Max != 100 {
+		t.Errorf("Неверные count/min/max: %+v", stats)
+	}
+	if stats.P50 != 50 || stats.P90 != 90 || stats.P95 != 95 || stats.P99 != 99 {
+		t.Errorf("Неверные перцентили: %+v", stats)
+	}
+	if stats.Mean != 50.5 {
+		t.Errorf("Ожидалось среднее 50.5, получено %v", stats.Mean)
+	}
+
+	if empty := latencyStats(nil); empty.Count != 0 || empty.P99 != 0 {
+		t.Errorf("Пустая выборка должна давать нулевую статистику: %+v", empty)
+	}
+}
+
+// TestReportWriters проверяет сводку и форматы отчета
+func TestReportWriters(t *testing.T) {
+	results := []CallResult{
+		{Index: 0, Codec: "PCMU", Success: true, StatusCode: 200, PDD: 10 * time.Millisecond,
+			Setup: 20 * time.Millisecond, Teardown: 5 * time.Millisecond, PacketsSent: 50},
+		{Index: 1, Codec: "PCMA", StatusCode: 486, Error: "вызов отклонен: 486 Busy Here"},
+	}
+
+	summary := summarize("sip:test@127.0.0.1", time.Now(), 2*time.Second, 2, results)
+	if summary.Succeeded != 1 || summary.Failed != 1 || summary.ASR != 50 || summary.CallRate != 1 {
+		t.Errorf("Неверная сводка: %+v", summary)
+	}
+	if summary.StatusCodes[486] != 1 || summary.Codecs["PCMA"] != 1 || summary.Setup.Count != 1 {
+		t.Errorf("Неверная статистика кодов и кодеков: %+v", summary)
+	}
+
+	var text bytes.Buffer
+	if err := writeText(&text, summary); err != nil || !bytes.Contains(text.Bytes(), []byte("486: 1")) {
+		t.Errorf("Неверный текстовый отчет (%v):\n%s", err, text.String())
+	}
+
+	var jsonOut bytes.Buffer
+	if err := writeJSON(&jsonOut, summary, results); err != nil {
+		t.Fatalf("Ошибка JSON отчета: %v", err)
+	}
+	var decoded jsonReport
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatalf("Некорректный JSON отчет: %v", err)
+	}
+	if decoded.Summary.Attempted != 2 || len(decoded.Calls) != 2 || decoded.Summary.Setup.P50 != 20 {
+		t.Errorf("Неверное содержимое JSON отчета: %+v", decoded.Summary)
+	}
+
+	var csvOut bytes.Buffer
+	if err := writeCSV(&csvOut, results); err != nil {
+		t.Fatalf("Ошибка CSV отчета: %v", err)
+	}
+	records, err := csv.NewReader(&csvOut).ReadAll()
+	if err != nil {
+		t.Fatalf("Некорректный CSV отчет: %v", err)
+	}
+	if len(records) != 3 || len(records[1]) != len(csvHeader) || records[1][9] != "20.000" {
+		t.Errorf("Неверное содержимое CSV отчета: %v", records)
+	}
+}
+
+// TestRunLoadConcurrencyLimit проверяет ограничение одновременных вызовов и порядок результатов
+func TestRunLoadConcurrencyLimit(t *testing.T) {
+	profile := &Profile{Calls: 20, Rate: 1000, Concurrency: 3}
+
+	var active, peak atomic.Int64
+	run := runLoad(context.Background(), profile, func(stop context.Context, index int, rnd *rand.Rand) CallResult {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			current := peak.Load()
+			if n <= current || peak.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return CallResult{Index: index, Success: true}
+	})
+
+	if len(run.Results) != 20 {
+		t.Fatalf("Ожидалось 20 результатов, получено %d", len(run.Results))
+	}
+	for i, r := range run.Results {
+		if r.Index != i {
+			t.Fatalf("Результаты должны быть упорядочены по индексу: %d на позиции %d", r.Index, i)
+		}
+	}
+	if peak.Load() > 3 || run.MaxConcurrent > 3 {
+		t.Errorf("Превышен предел одновременных вызовов: %d/%d", peak.Load(), run.MaxConcurrent)
+	}
+}
+
+// TestRunLoadStop проверяет остановку нагрузки по длительности и отмене контекста
+func TestRunLoadStop(t *testing.T) {
+	profile := &Profile{Duration: 100 * time.Millisecond, Rate: 50, Concurrency: 10}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := time.Now()
+	run := runLoad(ctx, profile, func(stop context.Context, index int, rnd *rand.Rand) CallResult {
+		if index == 2 {
+			cancel()
+		}
+		<-stop.Done()
+		return CallResult{Index: index}
+	})
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Нагрузка должна остановиться по отмене контекста, прошло %v", elapsed)
+	}
+	if len(run.Results) < 3 || len(run.Results) > 6 {
+		t.Errorf("Неожиданное количество вызовов: %d", len(run.Results))
+	}
+}
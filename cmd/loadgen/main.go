@@ -0,0 +1,256 @@
+// Команда loadgen - генератор SIP нагрузки для оценки производительности стека.
+//
+// Генерирует вызовы с заданной интенсивностью и длительностью разговора,
+// смесью кодеков G.711 и частотой DTMF, измеряет задержки установления
+// и завершения вызовов и выводит отчет с перцентилями в текстовом виде,
+// JSON или CSV для планирования емкости.
+//
+// Целью нагрузки может быть реальная SIP точка (-target sip:user@host:port)
+// или встроенный отвечающий агент в том же процессе (-target loopback).
+//
+// Примеры:
+//
+//	loadgen -calls 1000 -rate 20 -concurrency 200 -hold 30s
+//	loadgen -target sip:ivr@10.0.0.5:5060 -duration 10m -rate 5 -dtmf-rate 0.5
+//	loadgen -codecs PCMU:70,PCMA:30 -format csv -output calls.csv
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/config"
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/profiling/pprofhttp"
+)
+
+// options содержит параметры командной строки, не относящиеся к профилю нагрузки
+type options struct {
+	configPath string
+	sipHost    string
+	sipPort    int
+	answerPort int
+	ringDelay  time.Duration
+	format     string
+	output     string
+	verbose    bool
+	pprofAddr  string
+	scheduler  bool
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "loadgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	profile, opts, err := parseFlags(os.Args[1:])
+	if err != nil {
+		return err
+	}
+
+	level := slog.LevelWarn
+	if opts.verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	stack, err := loadStack(opts.configPath)
+	if err != nil {
+		return err
+	}
+	// RTP слушает на адресе SIP, если адрес в конфигурации не задан явно
+	if host, _, err := net.SplitHostPort(stack.SDP.LocalAddr); err != nil || host == "" {
+		stack.SDP.LocalAddr = net.JoinHostPort(opts.sipHost, "0")
+	}
+
+	if opts.pprofAddr != "" {
+		go func() {
+			if err := pprofhttp.ListenAndServe(opts.pprofAddr); err != nil {
+				slog.Error("loadgen: ошибка сервера pprof", slog.String("error", err.Error()))
+			}
+		}()
+	}
+
+	// Общий планировщик обслуживает периодическую работу медиа сессий обеих
+	// сторон вместо отдельных горутин каждой сессии
+	var scheduler *media.Scheduler
+	if opts.scheduler {
+		scheduler = media.NewScheduler(media.DefaultSchedulerConfig())
+		defer scheduler.Stop()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	listenCtx, stopListen := context.WithCancel(context.Background())
+	defer stopListen()
+
+	target := profile.Target
+	if target == targetLoopback {
+		target = fmt.Sprintf("sip:loadgen@%s:%d", opts.sipHost, opts.answerPort)
+
+		answerStack := *stack
+		answerStack.Dialog.Transports = []config.TransportConfig{
+			{Type: string(dialog.TransportUDP), Host: opts.sipHost, Port: opts.answerPort},
+		}
+		answerUA, err := startUA(listenCtx, &answerStack)
+		if err != nil {
+			return fmt.Errorf("запуск отвечающего агента: %w", err)
+		}
+		defer func() { _ = answerUA.Stop() }()
+
+		ans := &answerer{stack: &answerStack, ringDelay: opts.ringDelay, media: profile.Media, ptime: profile.Ptime, scheduler: scheduler}
+		answerUA.OnIncomingCall(ans.handleIncomingCall)
+	}
+
+	callerStack := *stack
+	callerStack.Dialog.Transports = []config.TransportConfig{
+		{Type: string(dialog.TransportUDP), Host: opts.sipHost, Port: opts.sipPort},
+	}
+	ua, err := startUA(listenCtx, &callerStack)
+	if err != nil {
+		return fmt.Errorf("запуск SIP агента: %w", err)
+	}
+	defer func() { _ = ua.Stop() }()
+
+	// Даем транспортам время начать прослушивание
+	time.Sleep(100 * time.Millisecond)
+
+	c := &caller{ua: ua, stack: &callerStack, profile: profile, target: target, scheduler: scheduler}
+	run := runLoad(ctx, profile, c.call)
+
+	summary := summarize(target, run.StartedAt, run.Elapsed, run.MaxConcurrent, run.Results)
+	return writeReport(opts, summary, run.Results)
+}
+
+// parseFlags разбирает аргументы командной строки в профиль нагрузки и параметры запуска
+func parseFlags(args []string) (*Profile, *options, error) {
+	fs := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+
+	profile := &Profile{}
+	opts := &options{}
+	var codecs string
+
+	fs.StringVar(&profile.Target, "target", targetLoopback, "SIP URI вызываемой стороны или \"loopback\"")
+	fs.IntVar(&profile.Calls, "calls", 100, "общее количество вызовов (0 - без ограничения)")
+	fs.DurationVar(&profile.Duration, "duration", 0, "длительность теста (0 - до завершения всех вызовов)")
+	fs.Float64Var(&profile.Rate, "rate", 5, "интенсивность вызовов в секунду")
+	fs.IntVar(&profile.Concurrency, "concurrency", 50, "максимум одновременных вызовов")
+	fs.DurationVar(&profile.HoldTime, "hold", 5*time.Second, "длительность разговора")
+	fs.DurationVar(&profile.HoldJitter, "hold-jitter", 0, "случайное отклонение длительности разговора (±)")
+	fs.StringVar(&codecs, "codecs", "PCMU:50,PCMA:50", "смесь кодеков с весами, например PCMU:70,PCMA:30")
+	fs.DurationVar(&profile.Ptime, "ptime", 20*time.Millisecond, "длительность RTP пакета")
+	fs.BoolVar(&profile.Media, "media", true, "отправлять RTP во время разговора")
+	fs.StringVar(&profile.Signal, "signal", "speech", "тестовый сигнал: tone, noise, speech, silence")
+	fs.Float64Var(&profile.DTMFRate, "dtmf-rate", 0, "DTMF цифр в секунду на вызов")
+	fs.DurationVar(&profile.SetupTimeout, "setup-timeout", 10*time.Second, "таймаут ожидания ответа на INVITE")
+	fs.DurationVar(&profile.TeardownTimeout, "teardown-timeout", 5*time.Second, "таймаут ожидания ответа на BYE")
+	fs.Int64Var(&profile.Seed, "seed", time.Now().UnixNano(), "seed генератора случайных чисел")
+
+	fs.StringVar(&opts.configPath, "config", "", "файл конфигурации стека (YAML или JSON)")
+	fs.StringVar(&opts.sipHost, "sip-host", "127.0.0.1", "локальный адрес SIP")
+	fs.IntVar(&opts.sipPort, "sip-port", 5070, "локальный порт SIP")
+	fs.IntVar(&opts.answerPort, "answer-port", 5080, "порт встроенного отвечающего агента (loopback)")
+	fs.DurationVar(&opts.ringDelay, "ring", 0, "задержка ответа встроенного агента после 180 Ringing")
+	fs.StringVar(&opts.format, "format", "text", "формат отчета: text, json, csv")
+	fs.StringVar(&opts.output, "output", "", "файл отчета (по умолчанию stdout)")
+	fs.BoolVar(&opts.verbose, "v", false, "подробное логирование")
+	fs.StringVar(&opts.pprofAddr, "pprof", "", "адрес HTTP сервера pprof/expvar, например 127.0.0.1:6060")
+	fs.BoolVar(&opts.scheduler, "shared-scheduler", false, "обслуживать медиа сессии общим планировщиком вместо горутин каждой сессии")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	mix, err := parseCodecMix(codecs)
+	if err != nil {
+		return nil, nil, err
+	}
+	profile.Codecs = mix
+
+	if err := profile.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	switch opts.format {
+	case "text", "json", "csv":
+	default:
+		return nil, nil, fmt.Errorf("неизвестный формат отчета %q (text, json, csv)", opts.format)
+	}
+
+	return profile, opts, nil
+}
+
+// loadStack загружает конфигурацию стека из файла или значения по умолчанию
+// с переопределениями из переменных окружения
+func loadStack(path string) (*config.Config, error) {
+	var stack *config.Config
+	if path != "" {
+		loaded, err := config.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		stack = loaded
+	} else {
+		stack = config.Default()
+		if err := stack.ApplyEnv(config.DefaultEnvPrefix); err != nil {
+			return nil, err
+		}
+	}
+
+	if stack.Dialog.Contact == "" {
+		stack.Dialog.Contact = "loadgen"
+	}
+	return stack, nil
+}
+
+// startUA создает SIP агента и запускает прослушивание транспортов
+func startUA(ctx context.Context, stack *config.Config) (*dialog.UACUAS, error) {
+	ua, err := dialog.NewUACUAS(stack.ToDialogConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := ua.ListenTransports(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("loadgen: ошибка транспорта", slog.String("error", err.Error()))
+		}
+	}()
+
+	return ua, nil
+}
+
+// writeReport выводит отчет в выбранном формате
+func writeReport(opts *options, summary Summary, results []CallResult) error {
+	var w io.Writer = os.Stdout
+	if opts.output != "" {
+		file, err := os.Create(opts.output)
+		if err != nil {
+			return fmt.Errorf("создание файла отчета: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch strings.ToLower(opts.format) {
+	case "json":
+		return writeJSON(w, summary, results)
+	case "csv":
+		return writeCSV(w, results)
+	default:
+		return writeText(w, summary)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// targetLoopback - цель нагрузки, при которой вызовы принимает встроенный
+// отвечающий агент в том же процессе
+const targetLoopback = "loopback"
+
+// codecWeight задает долю вызовов с кодеком в смеси кодеков
+type codecWeight struct {
+	Name   string
+	Codec  testsignal.Codec
+	Weight int
+}
+
+// payloadType возвращает RTP payload type кодека
+func (c codecWeight) payloadType() rtp.PayloadType {
+	return rtp.PayloadType(c.Codec)
+}
+
+// Profile описывает профиль нагрузки
+type Profile struct {
+	Target      string        // SIP URI вызываемой стороны или "loopback"
+	Calls       int           // Общее количество вызовов (0 - без ограничения)
+	Duration    time.Duration // Длительность теста (0 - до завершения Calls вызовов)
+	Rate        float64       // Вызовов в секунду
+	Concurrency int           // Максимум одновременных вызовов
+
+	HoldTime   time.Duration // Средняя длительность разговора
+	HoldJitter time.Duration // Случайное отклонение длительности разговора (±)
+
+	Codecs   []codecWeight // Смесь кодеков
+	Ptime    time.Duration // Длительность RTP пакета
+	Media    bool          // Отправлять RTP во время разговора
+	Signal   string        // Тип тестового сигнала: tone, noise, speech, silence
+	DTMFRate float64       // DTMF цифр в секунду на вызов (0 - без DTMF)
+
+	SetupTimeout    time.Duration // Таймаут ожидания ответа на INVITE
+	TeardownTimeout time.Duration // Таймаут ожидания ответа на BYE
+	Seed            int64         // Seed генератора случайных чисел
+}
+
+// Validate проверяет профиль нагрузки
+func (p *Profile) Validate() error {
+	if p.Target == "" {
+		return fmt.Errorf("не указана цель нагрузки")
+	}
+	if p.Target != targetLoopback && !strings.HasPrefix(p.Target, "sip:") && !strings.HasPrefix(p.Target, "sips:") {
+		return fmt.Errorf("цель нагрузки должна быть SIP URI или %q: %s", targetLoopback, p.Target)
+	}
+	if p.Calls <= 0 && p.Duration <= 0 {
+		return fmt.Errorf("необходимо задать количество вызовов или длительность теста")
+	}
+	if p.Rate <= 0 {
+		return fmt.Errorf("интенсивность вызовов должна быть больше 0")
+	}
+	if p.Concurrency <= 0 {
+		return fmt.Errorf("максимум одновременных вызовов должен быть больше 0")
+	}
+	if p.HoldTime < 0 || p.HoldJitter < 0 || p.HoldJitter > p.HoldTime {
+		return fmt.Errorf("отклонение длительности разговора должно быть в пределах [0, hold]")
+	}
+	if len(p.Codecs) == 0 {
+		return fmt.Errorf("смесь кодеков пуста")
+	}
+	if p.Ptime <= 0 {
+		return fmt.Errorf("ptime должен быть больше 0")
+	}
+	if _, err := newSignal(p.Signal, 0); err != nil {
+		return err
+	}
+	if p.DTMFRate < 0 {
+		return fmt.Errorf("частота DTMF не может быть отрицательной")
+	}
+	return nil
+}
+
+// pickCodec выбирает кодек вызова согласно весам смеси
+func (p *Profile) pickCodec(rnd *rand.Rand) codecWeight {
+	total := 0
+	for _, c := range p.Codecs {
+		total += c.Weight
+	}
+	n := rnd.Intn(total)
+	for _, c := range p.Codecs {
+		if n < c.Weight {
+			return c
+		}
+		n -= c.Weight
+	}
+	return p.Codecs[len(p.Codecs)-1]
+}
+
+// pickHoldTime выбирает длительность разговора с учетом отклонения
+func (p *Profile) pickHoldTime(rnd *rand.Rand) time.Duration {
+	if p.HoldJitter == 0 {
+		return p.HoldTime
+	}
+	offset := time.Duration(rnd.Int63n(int64(2*p.HoldJitter)+1)) - p.HoldJitter
+	return p.HoldTime + offset
+}
+
+// parseCodecMix разбирает смесь кодеков вида "PCMU:70,PCMA:30".
+// Вес можно не указывать, по умолчанию он равен 1.
+func parseCodecMix(value string) ([]codecWeight, error) {
+	var result []codecWeight
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, weightStr, hasWeight := strings.Cut(item, ":")
+		name = strings.ToUpper(strings.TrimSpace(name))
+
+		weight := 1
+		if hasWeight {
+			w, err := strconv.Atoi(strings.TrimSpace(weightStr))
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("некорректный вес кодека %s: %q", name, weightStr)
+			}
+			weight = w
+		}
+
+		var codec testsignal.Codec
+		switch name {
+		case "PCMU":
+			codec = testsignal.PCMU
+		case "PCMA":
+			codec = testsignal.PCMA
+		default:
+			return nil, fmt.Errorf("неподдерживаемый кодек %q (поддерживаются PCMU и PCMA)", name)
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("кодек %s указан несколько раз", name)
+		}
+		seen[name] = true
+
+		result = append(result, codecWeight{Name: name, Codec: codec, Weight: weight})
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("смесь кодеков пуста")
+	}
+	return result, nil
+}
+
+// newSignal создает тестовый сигнал по имени
+func newSignal(name string, seed uint64) (testsignal.Signal, error) {
+	switch name {
+	case "tone":
+		return testsignal.Sine(440, 0.3), nil
+	case "noise":
+		return testsignal.WhiteNoise(0.1, seed), nil
+	case "speech":
+		return testsignal.Speech(0.5, seed), nil
+	case "silence":
+		return testsignal.Silence(), nil
+	default:
+		return nil, fmt.Errorf("неизвестный тип сигнала %q (tone, noise, speech, silence)", name)
+	}
+}
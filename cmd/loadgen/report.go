@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// CallResult содержит результат одного вызова
+type CallResult struct {
+	Index     int       `json:"index"`
+	CallID    string    `json:"call_id"`
+	Codec     string    `json:"codec"`
+	StartedAt time.Time `json:"started_at"`
+
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error,omitempty"`
+	Release    string `json:"release,omitempty"` // Категория причины завершения
+
+	PDD      time.Duration `json:"pdd_ns"`      // До первого 18x
+	Setup    time.Duration `json:"setup_ns"`    // До 200 OK
+	Hold     time.Duration `json:"hold_ns"`     // Фактическая длительность разговора
+	Teardown time.Duration `json:"teardown_ns"` // От BYE до 200 OK
+
+	PacketsSent     uint64 `json:"packets_sent"`
+	PacketsReceived uint64 `json:"packets_received"`
+	DTMFSent        int    `json:"dtmf_sent"`
+}
+
+// LatencyStats содержит распределение задержки в миллисекундах
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min_ms"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// Summary содержит сводные результаты нагрузки
+type Summary struct {
+	Target    string        `json:"target"`
+	StartedAt time.Time     `json:"started_at"`
+	Elapsed   time.Duration `json:"elapsed_ns"`
+
+	Attempted     int     `json:"attempted"`
+	Succeeded     int     `json:"succeeded"`
+	Failed        int     `json:"failed"`
+	ASR           float64 `json:"asr_percent"` // Доля успешных вызовов
+	CallRate      float64 `json:"call_rate"`   // Фактическая интенсивность вызовов в секунду
+	MaxConcurrent int     `json:"max_concurrent"`
+
+	PDD      LatencyStats `json:"pdd"`
+	Setup    LatencyStats `json:"setup"`
+	Teardown LatencyStats `json:"teardown"`
+
+	StatusCodes map[int]int    `json:"status_codes"`
+	Errors      map[string]int `json:"errors,omitempty"`
+	Codecs      map[string]int `json:"codecs"`
+
+	PacketsSent     uint64 `json:"packets_sent"`
+	PacketsReceived uint64 `json:"packets_received"`
+	DTMFSent        int    `json:"dtmf_sent"`
+}
+
+// summarize формирует сводку по результатам вызовов
+func summarize(target string, startedAt time.Time, elapsed time.Duration, maxConcurrent int, results []CallResult) Summary {
+	summary := Summary{
+		Target:        target,
+		StartedAt:     startedAt,
+		Elapsed:       elapsed,
+		Attempted:     len(results),
+		MaxConcurrent: maxConcurrent,
+		StatusCodes:   make(map[int]int),
+		Errors:        make(map[string]int),
+		Codecs:        make(map[string]int),
+	}
+
+	var pdd, setup, teardown []time.Duration
+	for _, r := range results {
+		if r.Success {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		if r.StatusCode != 0 {
+			summary.StatusCodes[r.StatusCode]++
+		}
+		if r.Error != "" {
+			summary.Errors[r.Error]++
+		}
+		summary.Codecs[r.Codec]++
+		summary.PacketsSent += r.PacketsSent
+		summary.PacketsReceived += r.PacketsReceived
+		summary.DTMFSent += r.DTMFSent
+
+		if r.PDD > 0 {
+			pdd = append(pdd, r.PDD)
+		}
+		if r.Success {
+			setup = append(setup, r.Setup)
+		}
+		if r.Teardown > 0 {
+			teardown = append(teardown, r.Teardown)
+		}
+	}
+
+	if summary.Attempted > 0 {
+		summary.ASR = float64(summary.Succeeded) * 100 / float64(summary.Attempted)
+	}
+	if elapsed > 0 {
+		summary.CallRate = float64(summary.Attempted) / elapsed.Seconds()
+	}
+
+	summary.PDD = latencyStats(pdd)
+	summary.Setup = latencyStats(setup)
+	summary.Teardown = latencyStats(teardown)
+
+	return summary
+}
+
+// latencyStats вычисляет распределение задержек
+func latencyStats(values []time.Duration) LatencyStats {
+	if len(values) == 0 {
+		return LatencyStats{}
+	}
+
+	sorted := make([]time.Duration, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, v := range sorted {
+		total += v
+	}
+
+	return LatencyStats{
+		Count: len(sorted),
+		Min:   milliseconds(sorted[0]),
+		Mean:  milliseconds(total / time.Duration(len(sorted))),
+		P50:   milliseconds(percentile(sorted, 50)),
+		P90:   milliseconds(percentile(sorted, 90)),
+		P95:   milliseconds(percentile(sorted, 95)),
+		P99:   milliseconds(percentile(sorted, 99)),
+		Max:   milliseconds(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile возвращает перцентиль p отсортированных значений (метод nearest-rank)
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// milliseconds переводит длительность в миллисекунды
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// writeText выводит сводку в читаемом виде
+func writeText(w io.Writer, s Summary) error {
+	_, err := fmt.Fprintf(w, `Цель:                  %s
+Длительность теста:    %v
+Вызовов:               %d (успешно %d, ошибок %d, ASR %.1f%%)
+Интенсивность:         %.2f выз/с, максимум одновременно %d
+RTP пакетов:           отправлено %d, получено %d
+DTMF цифр отправлено:  %d
+
+Задержки, мс      count      min     mean      p50      p90      p95      p99      max
+`, s.Target, s.Elapsed.Round(time.Millisecond), s.Attempted, s.Succeeded, s.Failed, s.ASR,
+		s.CallRate, s.MaxConcurrent, s.PacketsSent, s.PacketsReceived, s.DTMFSent)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range []struct {
+		name  string
+		stats LatencyStats
+	}{
+		{"PDD (18x)", s.PDD},
+		{"Setup (200)", s.Setup},
+		{"Teardown", s.Teardown},
+	} {
+		st := row.stats
+		if _, err := fmt.Fprintf(w, "%-14s %8d %8.1f %8.1f %8.1f %8.1f %8.1f %8.1f %8.1f\n",
+			row.name, st.Count, st.Min, st.Mean, st.P50, st.P90, st.P95, st.P99, st.Max); err != nil {
+			return err
+		}
+	}
+
+	if len(s.StatusCodes) > 0 {
+		if _, err := fmt.Fprintln(w, "\nКоды ответов:"); err != nil {
+			return err
+		}
+		codes := make([]int, 0, len(s.StatusCodes))
+		for code := range s.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			if _, err := fmt.Fprintf(w, "  %d: %d\n", code, s.StatusCodes[code]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(s.Errors) > 0 {
+		if _, err := fmt.Fprintln(w, "\nОшибки:"); err != nil {
+			return err
+		}
+		for msg, count := range s.Errors {
+			if _, err := fmt.Fprintf(w, "  %d x %s\n", count, msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonReport - формат JSON отчета
+type jsonReport struct {
+	Summary Summary      `json:"summary"`
+	Calls   []CallResult `json:"calls"`
+}
+
+// writeJSON выводит сводку и результаты вызовов в JSON
+func writeJSON(w io.Writer, s Summary, results []CallResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(jsonReport{Summary: s, Calls: results})
+}
+
+// csvHeader - заголовок CSV отчета
+var csvHeader = []string{
+	"index", "call_id", "codec", "started_at", "success", "status_code", "release", "error",
+	"pdd_ms", "setup_ms", "hold_ms", "teardown_ms", "packets_sent", "packets_received", "dtmf_sent",
+}
+
+// writeCSV выводит результаты вызовов в CSV, по строке на вызов
+func writeCSV(w io.Writer, results []CallResult) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	formatMs := func(d time.Duration) string {
+		return strconv.FormatFloat(milliseconds(d), 'f', 3, 64)
+	}
+
+	for _, r := range results {
+		record := []string{
+			strconv.Itoa(r.Index),
+			r.CallID,
+			r.Codec,
+			r.StartedAt.Format(time.RFC3339Nano),
+			strconv.FormatBool(r.Success),
+			strconv.Itoa(r.StatusCode),
+			r.Release,
+			r.Error,
+			formatMs(r.PDD),
+			formatMs(r.Setup),
+			formatMs(r.Hold),
+			formatMs(r.Teardown),
+			strconv.FormatUint(r.PacketsSent, 10),
+			strconv.FormatUint(r.PacketsReceived, 10),
+			strconv.Itoa(r.DTMFSent),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
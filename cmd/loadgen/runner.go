@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// callFunc выполняет один вызов профиля нагрузки
+type callFunc func(stop context.Context, index int, rnd *rand.Rand) CallResult
+
+// runResult содержит результаты прогона нагрузки
+type runResult struct {
+	Results       []CallResult
+	StartedAt     time.Time
+	Elapsed       time.Duration
+	MaxConcurrent int
+}
+
+// runLoad запускает вызовы с интенсивностью profile.Rate, не превышая
+// profile.Concurrency одновременных вызовов. Новые вызовы перестают запускаться
+// после profile.Calls вызовов, по истечении profile.Duration или при отмене ctx.
+// При отмене ctx активные вызовы завершаются досрочно.
+func runLoad(ctx context.Context, profile *Profile, call callFunc) runResult {
+	run := runResult{StartedAt: time.Now()}
+
+	var deadline <-chan time.Time
+	if profile.Duration > 0 {
+		timer := time.NewTimer(profile.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / profile.Rate))
+	defer ticker.Stop()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		active  atomic.Int64
+		maxSeen atomic.Int64
+	)
+	slots := make(chan struct{}, profile.Concurrency)
+
+	start := func(index int) {
+		n := active.Add(1)
+		for {
+			current := maxSeen.Load()
+			if n <= current || maxSeen.CompareAndSwap(current, n) {
+				break
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				active.Add(-1)
+				<-slots
+			}()
+
+			rnd := rand.New(rand.NewSource(profile.Seed + int64(index)))
+			result := call(ctx, index, rnd)
+
+			mu.Lock()
+			run.Results = append(run.Results, result)
+			mu.Unlock()
+		}()
+	}
+
+loop:
+	for index := 0; profile.Calls <= 0 || index < profile.Calls; index++ {
+		// Первый вызов запускается сразу, последующие - по тикеру
+		if index > 0 {
+			select {
+			case <-ticker.C:
+			case <-deadline:
+				break loop
+			case <-ctx.Done():
+				break loop
+			}
+		}
+
+		// Ожидание свободного слота при достижении предела одновременных вызовов
+		select {
+		case slots <- struct{}{}:
+		case <-deadline:
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+
+		start(index)
+	}
+
+	wg.Wait()
+
+	run.Elapsed = time.Since(run.StartedAt)
+	run.MaxConcurrent = int(maxSeen.Load())
+	sort.Slice(run.Results, func(i, j int) bool {
+		return run.Results[i].Index < run.Results[j].Index
+	})
+
+	return run
+}
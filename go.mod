@@ -8,12 +8,14 @@ require (
 	github.com/emiago/sipgo v0.33.0
 	github.com/looplab/fsm v1.0.3
 	github.com/pion/dtls/v2 v2.2.12
+	github.com/pion/rtcp v1.2.15
 	github.com/pion/rtp v1.8.19
 	github.com/pion/sdp/v3 v3.0.14
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/sync v0.16.0
 	golang.org/x/sys v0.33.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -27,7 +29,6 @@ require (
 	github.com/kr/text v0.2.0 // indirect
 	github.com/pion/logging v0.2.4 // indirect
 	github.com/pion/randutil v0.1.0 // indirect
-	github.com/pion/rtcp v1.2.15 // indirect
 	github.com/pion/srtp v1.5.2 // indirect
 	github.com/pion/transport v0.10.1 // indirect
 	github.com/pion/transport/v2 v2.2.10 // indirect
@@ -35,5 +36,4 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/crypto v0.39.0 // indirect
 	golang.org/x/net v0.41.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,459 @@
+// Package apidiff описывает публичный API Go пакета и проверяет совместимость
+// его изменений.
+//
+// Описание API (surface) - отсортированный список строк, по одной на каждое
+// экспортируемое объявление: функцию, метод, тип, поле структуры, метод
+// интерфейса, константу и переменную. Имена параметров в описание не входят,
+// поэтому их переименование совместимо. Описание строится по исходному коду
+// без проверки типов и хранится рядом с пакетом (api.txt), чтобы изменения
+// API были видны на ревью.
+//
+// Правила совместимости (Compare):
+//   - удаление или изменение строки несовместимо;
+//   - новый метод существующего интерфейса несовместим: ломает реализации
+//     интерфейса вне пакета;
+//   - остальные добавления совместимы.
+package apidiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Header - первая строка файла описания API
+const Header = "# Публичный API пакета. Сгенерировано: go run ./cmd/apidiff -update"
+
+// Surface - описание публичного API пакета, отсортированные строки
+type Surface []string
+
+// Load строит описание API пакета в каталоге dir. Тестовые файлы и файлы
+// с ограничениями сборки (//go:build) не учитываются: стабильный API не
+// должен зависеть от тегов сборки. Методы, продвинутые из встроенных
+// неэкспортируемых типов, не видны без проверки типов и не учитываются
+func Load(dir string) (Surface, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var lines []string
+	packageName := ""
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("разбор %s: %w", name, err)
+		}
+		if packageName != "" && file.Name.Name != packageName {
+			return nil, fmt.Errorf("в каталоге %s несколько пакетов: %s и %s", dir, packageName, file.Name.Name)
+		}
+		packageName = file.Name.Name
+		if hasBuildConstraint(file) {
+			continue
+		}
+		lines = append(lines, fileSurface(fset, file)...)
+	}
+	if packageName == "" {
+		return nil, fmt.Errorf("в каталоге %s нет Go файлов", dir)
+	}
+	sort.Strings(lines)
+	return Surface(lines), nil
+}
+
+// hasBuildConstraint проверяет наличие //go:build до объявления пакета
+func hasBuildConstraint(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, "//go:build") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fileSurface возвращает строки API для экспортируемых объявлений файла
+func fileSurface(fset *token.FileSet, file *ast.File) []string {
+	var lines []string
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if line, ok := funcLine(fset, decl); ok {
+				lines = append(lines, line)
+			}
+		case *ast.GenDecl:
+			lines = append(lines, genDeclLines(fset, decl)...)
+		}
+	}
+	return lines
+}
+
+// funcLine описывает функцию или метод экспортируемого типа
+func funcLine(fset *token.FileSet, decl *ast.FuncDecl) (string, bool) {
+	if !decl.Name.IsExported() {
+		return "", false
+	}
+	signature := decl.Name.Name + signatureText(fset, decl.Type)
+	if decl.Recv == nil {
+		return "func " + signature, true
+	}
+
+	recv := decl.Recv.List[0].Type
+	base := recv
+	if star, ok := base.(*ast.StarExpr); ok {
+		base = star.X
+	}
+	// Параметры типа получателя (T[K]) не влияют на имя типа
+	switch index := base.(type) {
+	case *ast.IndexExpr:
+		base = index.X
+	case *ast.IndexListExpr:
+		base = index.X
+	}
+	ident, ok := base.(*ast.Ident)
+	if !ok || !ident.IsExported() {
+		return "", false
+	}
+	return fmt.Sprintf("method (%s) %s", exprText(fset, recv), signature), true
+}
+
+// genDeclLines описывает экспортируемые типы, константы и переменные
+func genDeclLines(fset *token.FileSet, decl *ast.GenDecl) []string {
+	var lines []string
+	// Константы группы без типа наследуют тип предыдущей спецификации (iota)
+	var groupType ast.Expr
+	for _, spec := range decl.Specs {
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			if spec.Name.IsExported() {
+				lines = append(lines, typeLines(fset, spec)...)
+			}
+		case *ast.ValueSpec:
+			kind := "var"
+			if decl.Tok == token.CONST {
+				kind = "const"
+				if spec.Type != nil || len(spec.Values) > 0 {
+					groupType = spec.Type
+				}
+			}
+			valueType := spec.Type
+			if kind == "const" {
+				valueType = groupType
+			}
+			for _, name := range spec.Names {
+				if !name.IsExported() {
+					continue
+				}
+				line := kind + " " + name.Name
+				if valueType != nil {
+					line += " " + exprText(fset, valueType)
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// typeLines описывает тип, экспортируемые поля структуры и методы интерфейса
+func typeLines(fset *token.FileSet, spec *ast.TypeSpec) []string {
+	name := spec.Name.Name
+	if spec.TypeParams != nil {
+		name += "[" + fieldListText(fset, spec.TypeParams) + "]"
+	}
+	if spec.Assign.IsValid() {
+		return []string{fmt.Sprintf("type %s = %s", name, exprText(fset, spec.Type))}
+	}
+
+	switch typ := spec.Type.(type) {
+	case *ast.StructType:
+		lines := []string{fmt.Sprintf("type %s struct", name)}
+		for _, field := range typ.Fields.List {
+			fieldType := exprText(fset, field.Type)
+			if len(field.Names) == 0 {
+				// Встроенное поле: имя - имя типа без пакета и указателя
+				if embedded := embeddedName(field.Type); ast.IsExported(embedded) {
+					lines = append(lines, fmt.Sprintf("field %s.%s embedded %s", spec.Name.Name, embedded, fieldType))
+				}
+				continue
+			}
+			for _, fieldName := range field.Names {
+				if fieldName.IsExported() {
+					lines = append(lines, fmt.Sprintf("field %s.%s %s", spec.Name.Name, fieldName.Name, fieldType))
+				}
+			}
+		}
+		return lines
+	case *ast.InterfaceType:
+		lines := []string{fmt.Sprintf("type %s interface", name)}
+		for _, method := range typ.Methods.List {
+			if len(method.Names) == 0 {
+				lines = append(lines, fmt.Sprintf("interface %s embedded %s", spec.Name.Name, exprText(fset, method.Type)))
+				continue
+			}
+			funcType, ok := method.Type.(*ast.FuncType)
+			if !ok {
+				continue
+			}
+			for _, methodName := range method.Names {
+				lines = append(lines, fmt.Sprintf("interface %s.%s%s", spec.Name.Name, methodName.Name, signatureText(fset, funcType)))
+			}
+		}
+		return lines
+	default:
+		return []string{fmt.Sprintf("type %s %s", name, exprText(fset, spec.Type))}
+	}
+}
+
+// embeddedName возвращает имя встроенного поля
+func embeddedName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.Ident:
+		return expr.Name
+	case *ast.IndexExpr:
+		return embeddedName(expr.X)
+	case *ast.IndexListExpr:
+		return embeddedName(expr.X)
+	}
+	return ""
+}
+
+// signatureText возвращает параметры и результаты функции без имен
+func signatureText(fset *token.FileSet, funcType *ast.FuncType) string {
+	var b strings.Builder
+	if funcType.TypeParams != nil {
+		b.WriteString("[" + fieldListText(fset, funcType.TypeParams) + "]")
+	}
+	b.WriteString("(" + typeListText(fset, funcType.Params) + ")")
+
+	results := typeListText(fset, funcType.Results)
+	switch {
+	case results == "":
+	case funcType.Results.NumFields() == 1:
+		b.WriteString(" " + results)
+	default:
+		b.WriteString(" (" + results + ")")
+	}
+	return b.String()
+}
+
+// typeListText перечисляет типы полей, повторяя тип для каждого имени
+func typeListText(fset *token.FileSet, fields *ast.FieldList) string {
+	if fields == nil {
+		return ""
+	}
+	var types []string
+	for _, field := range fields.List {
+		text := exprText(fset, field.Type)
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			types = append(types, text)
+		}
+	}
+	return strings.Join(types, ", ")
+}
+
+// fieldListText перечисляет параметры типа с именами и ограничениями:
+// имена параметров типа видны в объявлении
+func fieldListText(fset *token.FileSet, fields *ast.FieldList) string {
+	var params []string
+	for _, field := range fields.List {
+		names := make([]string, len(field.Names))
+		for i, name := range field.Names {
+			names[i] = name.Name
+		}
+		params = append(params, strings.Join(names, ", ")+" "+exprText(fset, field.Type))
+	}
+	return strings.Join(params, ", ")
+}
+
+// exprText печатает выражение типа в одну строку. Имена параметров
+// функциональных типов (поля-callback) отбрасываются
+func exprText(fset *token.FileSet, expr ast.Expr) string {
+	if funcType, ok := expr.(*ast.FuncType); ok {
+		return "func" + signatureText(fset, funcType)
+	}
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, stripNames(expr))
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// stripNames убирает имена параметров вложенных функциональных типов
+func stripNames(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.FuncType:
+		return &ast.FuncType{Params: unnamed(expr.Params), Results: unnamed(expr.Results)}
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: stripNames(expr.X)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: expr.Len, Elt: stripNames(expr.Elt)}
+	case *ast.MapType:
+		return &ast.MapType{Key: stripNames(expr.Key), Value: stripNames(expr.Value)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: expr.Dir, Value: stripNames(expr.Value)}
+	}
+	return expr
+}
+
+// unnamed возвращает список полей без имен
+func unnamed(fields *ast.FieldList) *ast.FieldList {
+	if fields == nil {
+		return nil
+	}
+	result := &ast.FieldList{}
+	for _, field := range fields.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			result.List = append(result.List, &ast.Field{Type: stripNames(field.Type)})
+		}
+	}
+	return result
+}
+
+// Write записывает описание API с заголовком
+func (s Surface) Write(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, Header); err != nil {
+		return err
+	}
+	for _, line := range s {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read читает описание API. Пустые строки и комментарии (#) пропускаются
+func Read(r io.Reader) (Surface, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(lines)
+	return Surface(lines), nil
+}
+
+// ReadFile читает описание API из файла
+func ReadFile(path string) (Surface, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return Read(file)
+}
+
+// Report - результат сравнения двух описаний API
+type Report struct {
+	// Removed - удаленные или измененные объявления старого API
+	Removed []string
+	// Breaking - добавления, ломающие совместимость: новые методы
+	// существующих интерфейсов
+	Breaking []string
+	// Added - совместимые добавления
+	Added []string
+}
+
+// Compatible возвращает true, если новый API совместим со старым
+func (r Report) Compatible() bool {
+	return len(r.Removed) == 0 && len(r.Breaking) == 0
+}
+
+// Changed возвращает true, если API изменился
+func (r Report) Changed() bool {
+	return len(r.Removed)+len(r.Breaking)+len(r.Added) > 0
+}
+
+// String перечисляет изменения: "-" удалено, "!" несовместимо добавлено,
+// "+" совместимо добавлено
+func (r Report) String() string {
+	var b strings.Builder
+	for _, line := range r.Removed {
+		b.WriteString("- " + line + "\n")
+	}
+	for _, line := range r.Breaking {
+		b.WriteString("! " + line + "\n")
+	}
+	for _, line := range r.Added {
+		b.WriteString("+ " + line + "\n")
+	}
+	return b.String()
+}
+
+// Compare сравнивает старое и новое описания API
+func Compare(old, new Surface) Report {
+	oldLines := make(map[string]bool, len(old))
+	oldInterfaces := make(map[string]bool)
+	for _, line := range old {
+		oldLines[line] = true
+		if name, ok := strings.CutPrefix(line, "type "); ok && strings.HasSuffix(name, " interface") {
+			oldInterfaces[interfaceName(strings.TrimSuffix(name, " interface"))] = true
+		}
+	}
+	newLines := make(map[string]bool, len(new))
+	for _, line := range new {
+		newLines[line] = true
+	}
+
+	var report Report
+	for _, line := range old {
+		if !newLines[line] {
+			report.Removed = append(report.Removed, line)
+		}
+	}
+	for _, line := range new {
+		if oldLines[line] {
+			continue
+		}
+		if method, ok := strings.CutPrefix(line, "interface "); ok && oldInterfaces[interfaceName(method)] {
+			report.Breaking = append(report.Breaking, line)
+			continue
+		}
+		report.Added = append(report.Added, line)
+	}
+	return report
+}
+
+// interfaceName возвращает имя интерфейса из "Name[T any]", "Name.Method(...)"
+// или "Name embedded X"
+func interfaceName(s string) string {
+	if i := strings.IndexAny(s, "[. "); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,218 @@
+package apidiff
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writePackage создает пакет из одного файла во временном каталоге
+func writePackage(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatalf("Не удалось записать %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// TestLoadSurface проверяет описание всех видов экспортируемых объявлений
+func TestLoadSurface(t *testing.T) {
+	dir := writePackage(t, map[string]string{
+		"api.go": `package api
+
+import "time"
+
+// Mode - режим
+type Mode int
+
+const (
+	ModeA Mode = iota
+	ModeB
+	modeHidden
+)
+
+const Version = "1"
+
+var ErrClosed error
+
+type Config struct {
+	Name    string
+	Timeout time.Duration
+	OnEvent func(name string, at time.Time) error
+	time.Location
+	hidden int
+}
+
+type Source interface {
+	Read(buf []byte) (n int, err error)
+	Close() error
+}
+
+type Alias = Config
+
+type Set[K comparable] map[K]bool
+
+func New(config Config, extra ...string) (*Config, error) { return nil, nil }
+
+func (c *Config) Start() error { return nil }
+
+func (c Config) String() string { return "" }
+
+func (s Set[K]) Has(key K) bool { return s[key] }
+
+func (c *Config) stop() {}
+
+func helper() {}
+
+type hidden struct{}
+
+func (hidden) Exported() {}
+`,
+		"api_test.go": `package api
+
+func TestOnly() {}
+`,
+		"api_small.go": `//go:build small
+
+package api
+
+func SmallOnly() {}
+`,
+	})
+
+	surface, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Ошибка Load: %v", err)
+	}
+	expected := Surface{
+		"const ModeA Mode",
+		"const ModeB Mode",
+		"const Version",
+		"field Config.Location embedded time.Location",
+		"field Config.Name string",
+		"field Config.OnEvent func(string, time.Time) error",
+		"field Config.Timeout time.Duration",
+		"func New(Config, ...string) (*Config, error)",
+		"interface Source.Close() error",
+		"interface Source.Read([]byte) (int, error)",
+		"method (*Config) Start() error",
+		"method (Config) String() string",
+		"method (Set[K]) Has(K) bool",
+		"type Alias = Config",
+		"type Config struct",
+		"type Mode int",
+		"type Set[K comparable] map[K]bool",
+		"type Source interface",
+		"var ErrClosed error",
+	}
+	if !reflect.DeepEqual(surface, expected) {
+		t.Errorf("Неверное описание API:\n%s", strings.Join(surface, "\n"))
+	}
+}
+
+// TestSurfaceRoundTrip проверяет запись и чтение файла описания
+func TestSurfaceRoundTrip(t *testing.T) {
+	surface := Surface{"func A()", "type B struct"}
+	var buf bytes.Buffer
+	if err := surface.Write(&buf); err != nil {
+		t.Fatalf("Ошибка Write: %v", err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Ошибка Read: %v", err)
+	}
+	if !reflect.DeepEqual(read, surface) {
+		t.Errorf("Описание изменилось после записи: %v", read)
+	}
+}
+
+// TestCompare проверяет правила совместимости
+func TestCompare(t *testing.T) {
+	old := Surface{
+		"func New(Config) (*Call, error)",
+		"interface Source.Read([]byte) (int, error)",
+		"type Config struct",
+		"type Source interface",
+	}
+
+	tests := []struct {
+		name       string
+		new        Surface
+		compatible bool
+		report     Report
+	}{
+		{
+			name:       "без изменений",
+			new:        old,
+			compatible: true,
+		},
+		{
+			name: "новое поле и функция",
+			new: Surface{
+				"field Config.Name string",
+				"func New(Config) (*Call, error)",
+				"func Parse(string) error",
+				"interface Source.Read([]byte) (int, error)",
+				"type Config struct",
+				"type Source interface",
+			},
+			compatible: true,
+			report:     Report{Added: []string{"field Config.Name string", "func Parse(string) error"}},
+		},
+		{
+			name: "изменение сигнатуры",
+			new: Surface{
+				"func New(Config, string) (*Call, error)",
+				"interface Source.Read([]byte) (int, error)",
+				"type Config struct",
+				"type Source interface",
+			},
+			report: Report{
+				Removed: []string{"func New(Config) (*Call, error)"},
+				Added:   []string{"func New(Config, string) (*Call, error)"},
+			},
+		},
+		{
+			name: "новый метод интерфейса",
+			new: Surface{
+				"func New(Config) (*Call, error)",
+				"interface Source.Close() error",
+				"interface Source.Read([]byte) (int, error)",
+				"type Config struct",
+				"type Source interface",
+			},
+			report: Report{Breaking: []string{"interface Source.Close() error"}},
+		},
+		{
+			name: "новый интерфейс",
+			new: Surface{
+				"func New(Config) (*Call, error)",
+				"interface Sink.Write([]byte) (int, error)",
+				"interface Source.Read([]byte) (int, error)",
+				"type Config struct",
+				"type Sink interface",
+				"type Source interface",
+			},
+			compatible: true,
+			report:     Report{Added: []string{"interface Sink.Write([]byte) (int, error)", "type Sink interface"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Compare(old, tt.new)
+			if report.Compatible() != tt.compatible {
+				t.Errorf("Compatible() = %v, ожидалось %v:\n%s", report.Compatible(), tt.compatible, report)
+			}
+			if !reflect.DeepEqual(report, tt.report) {
+				t.Errorf("Неверный отчет:\n%s", report)
+			}
+		})
+	}
+}
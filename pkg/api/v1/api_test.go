@@ -0,0 +1,29 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/arzzra/soft_phone/internal/apidiff"
+)
+
+// TestAPICompatibility сравнивает публичный API пакета с зафиксированным
+// в api.txt. Удаление и изменение объявлений нарушают гарантии v1;
+// совместимые добавления требуют обновить api.txt
+func TestAPICompatibility(t *testing.T) {
+	current, err := apidiff.Load(".")
+	if err != nil {
+		t.Fatalf("Не удалось получить API пакета: %v", err)
+	}
+	recorded, err := apidiff.ReadFile("api.txt")
+	if err != nil {
+		t.Fatalf("Не удалось прочитать api.txt: %v", err)
+	}
+
+	report := apidiff.Compare(recorded, current)
+	if !report.Compatible() {
+		t.Fatalf("Несовместимое изменение API v1, вынесите его в новую major версию:\n%s", report)
+	}
+	if report.Changed() {
+		t.Errorf("API v1 расширен, обновите api.txt командой go run ./cmd/apidiff -update:\n%s", report)
+	}
+}
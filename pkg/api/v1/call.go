@@ -0,0 +1,293 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/pion/sdp/v3"
+)
+
+var (
+	// ErrInvalidConfig - некорректные параметры вызова
+	ErrInvalidConfig = errors.New("некорректная конфигурация вызова")
+	// ErrInvalidSDP - SDP не удалось разобрать
+	ErrInvalidSDP = errors.New("некорректный SDP")
+	// ErrNegotiation - offer и answer несовместимы: нет общего кодека,
+	// недопустимое направление, конфликт повторного answer
+	ErrNegotiation = errors.New("ошибка согласования медиа")
+	// ErrTransport - не удалось открыть RTP/RTCP сокеты
+	ErrTransport = errors.New("ошибка медиа транспорта")
+	// ErrMedia - ошибка медиа сессии: запуск, остановка, отправка
+	ErrMedia = errors.New("ошибка медиа сессии")
+	// ErrState - метод недоступен в текущем состоянии или для направления вызова
+	ErrState = errors.New("недопустимое состояние вызова")
+)
+
+// wrapError относит ошибку внутренних пакетов к одной из ошибок Err*
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var target error
+	switch {
+	case media_sdp.IsSDPError(err, media_sdp.ErrorCodeInvalidConfig):
+		target = ErrInvalidConfig
+	case media_sdp.IsSDPError(err, media_sdp.ErrorCodeSDPParsing):
+		target = ErrInvalidSDP
+	case media_sdp.IsSDPError(err, media_sdp.ErrorCodeIncompatibleCodec),
+		media_sdp.IsSDPError(err, media_sdp.ErrorCodeInvalidDirection),
+		media_sdp.IsSDPError(err, media_sdp.ErrorCodeAnswerConflict):
+		target = ErrNegotiation
+	case media_sdp.IsSDPError(err, media_sdp.ErrorCodeTransportCreation):
+		target = ErrTransport
+	default:
+		target = ErrMedia
+	}
+	return fmt.Errorf("%w: %w", target, err)
+}
+
+// parseSDP разбирает тело SDP
+func parseSDP(body []byte) (*sdp.SessionDescription, error) {
+	desc := &sdp.SessionDescription{}
+	if err := desc.Unmarshal(body); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSDP, err)
+	}
+	return desc, nil
+}
+
+// marshalSDP сериализует SDP
+func marshalSDP(desc *sdp.SessionDescription, err error) ([]byte, error) {
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	body, err := desc.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSDP, err)
+	}
+	return body, nil
+}
+
+// Negotiated - параметры медиа, согласованные в offer/answer
+type Negotiated struct {
+	Codec     Codec
+	Direction Direction
+	OnHold    bool // Удаленная сторона поставила вызов на удержание (c=0.0.0.0)
+
+	LocalRTPAddr   string
+	RemoteRTPAddr  string
+	LocalRTCPAddr  string // Пусто, если RTCP отключен
+	RemoteRTCPAddr string
+
+	DTMF      bool // DTMF (RFC 4733) согласован обеими сторонами
+	Encrypted bool // Медиа шифруется (DTLS-SRTP или SAVP)
+}
+
+// Call - медиа часть одного вызова. Исходящий вызов создается
+// NewOutgoingCall, входящий - NewIncomingCall. Методы безопасны для
+// вызова из разных горутин
+type Call struct {
+	mu      sync.Mutex
+	builder media_sdp.SDPMediaBuilder // Исходящий вызов
+	handler media_sdp.SDPMediaHandler // Входящий вызов
+	started bool
+	closed  bool
+}
+
+// NewOutgoingCall создает исходящий вызов. Offer возвращает SDP offer для
+// INVITE, SetAnswer применяет answer из ответа 200 OK
+func NewOutgoingCall(config Config) (*Call, error) {
+	builderConfig, err := config.builderConfig()
+	if err != nil {
+		return nil, err
+	}
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &Call{builder: builder}, nil
+}
+
+// NewIncomingCall создает входящий вызов по SDP offer из INVITE. Answer
+// возвращает SDP answer для ответа 200 OK
+func NewIncomingCall(config Config, offer []byte) (*Call, error) {
+	offerSDP, err := parseSDP(offer)
+	if err != nil {
+		return nil, err
+	}
+	handler, err := media_sdp.NewSDPMediaHandler(config.handlerConfig())
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if err := handler.ProcessOffer(offerSDP); err != nil {
+		_ = handler.Close()
+		return nil, wrapError(err)
+	}
+	return &Call{handler: handler}, nil
+}
+
+// Offer создает SDP offer исходящего вызова
+func (c *Call) Offer() ([]byte, error) {
+	if err := c.check(c.builder != nil, "Offer доступен только исходящему вызову"); err != nil {
+		return nil, err
+	}
+	return marshalSDP(c.builder.CreateOffer())
+}
+
+// SetAnswer применяет SDP answer исходящего вызова. Повтор того же answer
+// (повтор 200 OK) ничего не делает
+func (c *Call) SetAnswer(answer []byte) error {
+	if err := c.check(c.builder != nil, "SetAnswer доступен только исходящему вызову"); err != nil {
+		return err
+	}
+	answerSDP, err := parseSDP(answer)
+	if err != nil {
+		return err
+	}
+	return wrapError(c.builder.ProcessAnswer(answerSDP))
+}
+
+// Answer создает SDP answer входящего вызова
+func (c *Call) Answer() ([]byte, error) {
+	if err := c.check(c.handler != nil, "Answer доступен только входящему вызову"); err != nil {
+		return nil, err
+	}
+	return marshalSDP(c.handler.CreateAnswer())
+}
+
+// Start запускает прием и отправку медиа. Вызывается после SetAnswer
+// (исходящий вызов) или Answer (входящий)
+func (c *Call) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return fmt.Errorf("%w: вызов завершен", ErrState)
+	}
+	if c.started {
+		return fmt.Errorf("%w: вызов уже запущен", ErrState)
+	}
+	if _, ok := c.negotiated(); !ok {
+		return fmt.Errorf("%w: медиа еще не согласовано", ErrState)
+	}
+	var err error
+	if c.builder != nil {
+		err = c.builder.Start()
+	} else {
+		err = c.handler.Start()
+	}
+	if err != nil {
+		return wrapError(err)
+	}
+	c.started = true
+	return nil
+}
+
+// Close завершает вызов и освобождает сокеты. Повторные вызовы ничего не
+// делают
+func (c *Call) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.builder != nil {
+		return wrapError(c.builder.Close())
+	}
+	return wrapError(c.handler.Close())
+}
+
+// SendAudio отправляет аудио кадр длительностью ptime в формате
+// согласованного кодека
+func (c *Call) SendAudio(frame []byte) error {
+	session, err := c.activeSession()
+	if err != nil {
+		return err
+	}
+	if err := session.SendAudio(frame); err != nil {
+		return fmt.Errorf("%w: %w", ErrMedia, err)
+	}
+	return nil
+}
+
+// SendDTMF отправляет DTMF цифры ("0"-"9", "*", "#", "A"-"D") по RFC 4733.
+// Цифры передаются друг за другом, каждая длительностью duration
+func (c *Call) SendDTMF(digits string, duration time.Duration) error {
+	session, err := c.activeSession()
+	if err != nil {
+		return err
+	}
+	parsed, err := media.ParseDTMFString(digits)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	for _, digit := range parsed {
+		if err := session.SendDTMF(digit, duration); err != nil {
+			return fmt.Errorf("%w: %w", ErrMedia, err)
+		}
+	}
+	return nil
+}
+
+// Negotiated возвращает параметры медиа, согласованные в offer/answer.
+// false - согласование еще не завершено
+func (c *Call) Negotiated() (Negotiated, bool) {
+	negotiated, ok := c.negotiated()
+	if !ok {
+		return Negotiated{}, false
+	}
+	return Negotiated{
+		Codec: Codec{
+			Name:        negotiated.CodecName,
+			PayloadType: uint8(negotiated.PayloadType),
+			ClockRate:   negotiated.ClockRate,
+			Channels:    negotiated.Channels,
+			Ptime:       negotiated.Ptime,
+		},
+		Direction:      directionOf(negotiated.Direction),
+		OnHold:         negotiated.OnHold,
+		LocalRTPAddr:   negotiated.LocalRTPAddr,
+		RemoteRTPAddr:  negotiated.RemoteRTPAddr,
+		LocalRTCPAddr:  negotiated.LocalRTCPAddr,
+		RemoteRTCPAddr: negotiated.RemoteRTCPAddr,
+		DTMF:           negotiated.DTMFEnabled,
+		Encrypted:      negotiated.Encrypted,
+	}, true
+}
+
+// negotiated возвращает согласованные параметры внутреннего типа
+func (c *Call) negotiated() (media_sdp.NegotiatedMedia, bool) {
+	if c.builder != nil {
+		return c.builder.GetNegotiatedMedia()
+	}
+	return c.handler.GetNegotiatedMedia()
+}
+
+// check возвращает ErrState для завершенного вызова или недоступного метода
+func (c *Call) check(allowed bool, message string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return fmt.Errorf("%w: вызов завершен", ErrState)
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s", ErrState, message)
+	}
+	return nil
+}
+
+// activeSession возвращает медиа сессию запущенного вызова
+func (c *Call) activeSession() (*media.MediaSession, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || !c.started {
+		return nil, fmt.Errorf("%w: вызов не запущен", ErrState)
+	}
+	if c.builder != nil {
+		return c.builder.GetMediaSession(), nil
+	}
+	return c.handler.GetMediaSession(), nil
+}
@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCallOfferAnswer проверяет исходящий и входящий вызовы: согласование,
+// передачу аудио и DTMF, завершение
+func TestCallOfferAnswer(t *testing.T) {
+	var mu sync.Mutex
+	audioReceived := 0
+	digits := ""
+	done := make(chan struct{})
+
+	caller, err := NewOutgoingCall(Config{SessionID: "v1-caller", LocalAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Не удалось создать исходящий вызов: %v", err)
+	}
+	defer func() { _ = caller.Close() }()
+
+	offer, err := caller.Offer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+
+	callee, err := NewIncomingCall(Config{
+		SessionID: "v1-callee",
+		LocalAddr: "127.0.0.1:0",
+		Codecs:    []Codec{{Name: "PCMA", PayloadType: 8, ClockRate: 8000}},
+		OnAudio: func(frame []byte, payloadType uint8) {
+			mu.Lock()
+			defer mu.Unlock()
+			if payloadType == 8 {
+				audioReceived++
+			}
+		},
+		OnDTMF: func(digit string, _ time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			digits += digit
+			if digits == "1#" {
+				close(done)
+			}
+		},
+	}, offer)
+	if err != nil {
+		t.Fatalf("Не удалось создать входящий вызов: %v", err)
+	}
+	defer func() { _ = callee.Close() }()
+
+	answer, err := callee.Answer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if err := caller.SetAnswer(answer); err != nil {
+		t.Fatalf("Не удалось применить answer: %v", err)
+	}
+
+	negotiated, ok := caller.Negotiated()
+	if !ok {
+		t.Fatal("Медиа должно быть согласовано после answer")
+	}
+	if negotiated.Codec.Name != "PCMA" || negotiated.Direction != DirectionSendRecv || !negotiated.DTMF {
+		t.Errorf("Неверные согласованные параметры: %+v", negotiated)
+	}
+	calleeNegotiated, _ := callee.Negotiated()
+	if negotiated.RemoteRTPAddr != calleeNegotiated.LocalRTPAddr {
+		t.Errorf("Адрес answer %s не применен: %s", calleeNegotiated.LocalRTPAddr, negotiated.RemoteRTPAddr)
+	}
+
+	if err := callee.Start(); err != nil {
+		t.Fatalf("Не удалось запустить входящий вызов: %v", err)
+	}
+	if err := caller.Start(); err != nil {
+		t.Fatalf("Не удалось запустить исходящий вызов: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := caller.SendAudio(make([]byte, 160)); err != nil {
+			t.Fatalf("Не удалось отправить аудио: %v", err)
+		}
+	}
+	if err := caller.SendDTMF("1#", 60*time.Millisecond); err != nil {
+		t.Fatalf("Не удалось отправить DTMF: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		mu.Lock()
+		t.Fatalf("DTMF не получен, получено %q", digits)
+	}
+	mu.Lock()
+	if audioReceived == 0 {
+		t.Error("Аудио не получено")
+	}
+	mu.Unlock()
+
+	if err := caller.Close(); err != nil {
+		t.Errorf("Ошибка Close: %v", err)
+	}
+	if err := caller.Close(); err != nil {
+		t.Errorf("Повторный Close должен быть идемпотентным: %v", err)
+	}
+	if err := caller.SendAudio(make([]byte, 160)); !errors.Is(err, ErrState) {
+		t.Errorf("Ожидалась ErrState после Close, получено %v", err)
+	}
+}
+
+// TestCallErrors проверяет классификацию ошибок
+func TestCallErrors(t *testing.T) {
+	if _, err := NewOutgoingCall(Config{Direction: "sideways"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Ожидалась ErrInvalidConfig, получено %v", err)
+	}
+	if _, err := NewIncomingCall(Config{}, []byte("не SDP")); !errors.Is(err, ErrInvalidSDP) {
+		t.Errorf("Ожидалась ErrInvalidSDP, получено %v", err)
+	}
+
+	caller, err := NewOutgoingCall(Config{LocalAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Не удалось создать вызов: %v", err)
+	}
+	defer func() { _ = caller.Close() }()
+
+	if _, err := caller.Answer(); !errors.Is(err, ErrState) {
+		t.Errorf("Answer исходящего вызова: ожидалась ErrState, получено %v", err)
+	}
+	if err := caller.Start(); !errors.Is(err, ErrState) {
+		t.Errorf("Start до answer: ожидалась ErrState, получено %v", err)
+	}
+
+	offer, err := caller.Offer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	_, err = NewIncomingCall(Config{
+		LocalAddr: "127.0.0.1:0",
+		Codecs:    []Codec{{Name: "G722", PayloadType: 9, ClockRate: 8000}},
+	}, offer)
+	if !errors.Is(err, ErrNegotiation) {
+		t.Errorf("Нет общего кодека: ожидалась ErrNegotiation, получено %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// Direction - направление медиа потока (атрибут SDP, RFC 4566)
+type Direction string
+
+const (
+	DirectionSendRecv Direction = "sendrecv"
+	DirectionSendOnly Direction = "sendonly"
+	DirectionRecvOnly Direction = "recvonly"
+	DirectionInactive Direction = "inactive"
+)
+
+// Codec - аудио кодек вызова
+type Codec struct {
+	Name        string // Имя кодека в rtpmap, например "PCMU"
+	PayloadType uint8
+	ClockRate   uint32
+	Channels    uint8         // 0 - один канал
+	Ptime       time.Duration // 0 - 20 мс
+}
+
+// Config - параметры вызова. Нулевые значения полей означают значения
+// по умолчанию
+type Config struct {
+	// SessionID - идентификатор вызова в журналах и ошибках, обычно Call-ID.
+	// Пусто - "v1-call"
+	SessionID string
+
+	// LocalAddr - локальный адрес RTP сокета. Пусто - ":0" (любой порт)
+	LocalAddr string
+
+	// Codecs - кодеки в порядке предпочтения. Пусто - PCMU и PCMA.
+	// Исходящий вызов предлагает все кодеки, входящий выбирает первый
+	// кодек offer из этого списка
+	Codecs []Codec
+
+	// Direction - направление медиа исходящего вызова. Пусто -
+	// DirectionSendRecv. Входящий вызов отвечает направлением,
+	// соответствующим offer
+	Direction Direction
+
+	// DisableDTMF отключает DTMF (RFC 4733, telephone-event)
+	DisableDTMF bool
+
+	// OnAudio вызывается для каждого полученного аудио кадра
+	OnAudio func(frame []byte, payloadType uint8)
+
+	// OnDTMF вызывается для каждой полученной DTMF цифры ("0"-"9", "*", "#", "A"-"D")
+	OnDTMF func(digit string, duration time.Duration)
+
+	// OnHoldChanged вызывается, когда удаленная сторона ставит вызов на
+	// удержание (c=0.0.0.0) или снимает с него
+	OnHoldChanged func(onHold bool)
+
+	// OnRemoteAddressChanged вызывается при смене удаленного медиа адреса
+	// повторным согласованием
+	OnRemoteAddressChanged func(rtpAddr, rtcpAddr string)
+}
+
+// defaultCodecs - кодеки по умолчанию
+var defaultCodecs = []Codec{
+	{Name: "PCMU", PayloadType: uint8(rtp.PayloadTypePCMU), ClockRate: 8000},
+	{Name: "PCMA", PayloadType: uint8(rtp.PayloadTypePCMA), ClockRate: 8000},
+}
+
+// sessionID возвращает идентификатор вызова с учетом значения по умолчанию
+func (c Config) sessionID() string {
+	if c.SessionID == "" {
+		return "v1-call"
+	}
+	return c.SessionID
+}
+
+// codecs возвращает кодеки с заполненными значениями по умолчанию
+func (c Config) codecs() []media_sdp.CodecInfo {
+	codecs := c.Codecs
+	if len(codecs) == 0 {
+		codecs = defaultCodecs
+	}
+	result := make([]media_sdp.CodecInfo, len(codecs))
+	for i, codec := range codecs {
+		info := media_sdp.CodecInfo{
+			PayloadType: rtp.PayloadType(codec.PayloadType),
+			Name:        codec.Name,
+			ClockRate:   codec.ClockRate,
+			Channels:    codec.Channels,
+			Ptime:       codec.Ptime,
+		}
+		if info.Channels == 0 {
+			info.Channels = 1
+		}
+		if info.Ptime == 0 {
+			info.Ptime = 20 * time.Millisecond
+		}
+		result[i] = info
+	}
+	return result
+}
+
+// direction преобразует направление во внутренний тип
+func (c Config) direction() (media.Direction, error) {
+	switch c.Direction {
+	case "", DirectionSendRecv:
+		return media.DirectionSendRecv, nil
+	case DirectionSendOnly:
+		return media.DirectionSendOnly, nil
+	case DirectionRecvOnly:
+		return media.DirectionRecvOnly, nil
+	case DirectionInactive:
+		return media.DirectionInactive, nil
+	}
+	return 0, fmt.Errorf("%w: неизвестное направление %q", ErrInvalidConfig, c.Direction)
+}
+
+// directionOf преобразует внутреннее направление
+func directionOf(direction media.Direction) Direction {
+	return Direction(direction.String())
+}
+
+// mediaConfig возвращает конфигурацию медиа сессии с callback вызова
+func (c Config) mediaConfig() media.Config {
+	config := media.DefaultMediaSessionConfig()
+	if c.OnAudio != nil {
+		config.OnAudioReceived = func(frame []byte, payloadType media.PayloadType, _ time.Duration, _ string) {
+			c.OnAudio(frame, uint8(payloadType))
+		}
+	}
+	if c.OnDTMF != nil {
+		config.OnDTMFReceived = func(event media.DTMFEvent, _ string) {
+			c.OnDTMF(event.Digit.String(), event.Duration)
+		}
+	}
+	return config
+}
+
+// onRemoteAddressChanged адаптирует callback смены адреса
+func (c Config) onRemoteAddressChanged() func(media_sdp.RemoteMediaAddressChange) {
+	if c.OnRemoteAddressChanged == nil {
+		return nil
+	}
+	return func(change media_sdp.RemoteMediaAddressChange) {
+		c.OnRemoteAddressChanged(change.RTPAddr, change.RTCPAddr)
+	}
+}
+
+// builderConfig возвращает конфигурацию исходящего вызова
+func (c Config) builderConfig() (media_sdp.BuilderConfig, error) {
+	direction, err := c.direction()
+	if err != nil {
+		return media_sdp.BuilderConfig{}, err
+	}
+	codecs := c.codecs()
+
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = c.sessionID()
+	config.PayloadType = codecs[0].PayloadType
+	config.CodecName = codecs[0].Name
+	config.ClockRate = codecs[0].ClockRate
+	config.Channels = codecs[0].Channels
+	config.Ptime = codecs[0].Ptime
+	config.AlternativeCodecs = codecs[1:]
+	config.Direction = direction
+	config.DTMFEnabled = !c.DisableDTMF
+	config.MediaConfig = c.mediaConfig()
+	config.OnHoldChanged = c.OnHoldChanged
+	config.OnRemoteMediaAddressChanged = c.onRemoteAddressChanged()
+	if c.LocalAddr != "" {
+		config.Transport.LocalAddr = c.LocalAddr
+	}
+	return config, nil
+}
+
+// handlerConfig возвращает конфигурацию входящего вызова
+func (c Config) handlerConfig() media_sdp.HandlerConfig {
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = c.sessionID()
+	config.SupportedCodecs = c.codecs()
+	config.DTMFEnabled = !c.DisableDTMF
+	config.MediaConfig = c.mediaConfig()
+	config.OnHoldChanged = c.OnHoldChanged
+	config.OnRemoteMediaAddressChanged = c.onRemoteAddressChanged()
+	if c.LocalAddr != "" {
+		config.Transport.LocalAddr = c.LocalAddr
+	}
+	return config
+}
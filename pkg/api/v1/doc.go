@@ -0,0 +1,50 @@
+// Package v1 - стабильный публичный API медиа части софтфона: SDP offer/answer,
+// запуск RTP, отправка и прием аудио и DTMF.
+//
+// Пакеты pkg/media_sdp, pkg/media и pkg/rtp развиваются вместе с приложениями
+// репозитория, и их типы меняются без предупреждения. Приложениям вне
+// репозитория следует использовать этот пакет: он скрывает внутренние типы
+// за небольшим набором собственных и сохраняет совместимость.
+//
+// # Гарантии совместимости
+//
+// В пределах v1:
+//   - экспортируемые функции, типы, методы, поля, константы и переменные
+//     не удаляются и не переименовываются, их сигнатуры не меняются;
+//   - новые поля конфигурации имеют нулевое значение, сохраняющее прежнее
+//     поведение, поэтому код, заполняющий Config по именам полей, собирается
+//     и работает без изменений;
+//   - ошибки сравниваются через errors.Is с переменными Err*; текст ошибок
+//     в гарантии не входит;
+//   - устаревшие объявления помечаются "Deprecated:" и удаляются только
+//     в следующей major версии пакета.
+//
+// Добавление функций, методов, полей и констант совместимо. Несовместимые
+// изменения выполняются только в новом пакете (v2), который существует
+// рядом с v1 на время перехода.
+//
+// Публичный API пакета зафиксирован в api.txt. Тест TestAPICompatibility
+// сравнивает с ним исходный код и не проходит при удалении или изменении
+// объявлений. После совместимого добавления файл обновляется командой:
+//
+//	go run ./cmd/apidiff -update
+//
+// # Использование
+//
+// Исходящий вызов:
+//
+//	call, err := v1.NewOutgoingCall(v1.Config{SessionID: callID})
+//	offer, err := call.Offer()
+//	// ... INVITE с offer, 200 OK с answer
+//	err = call.SetAnswer(answer)
+//	err = call.Start()
+//	defer call.Close()
+//
+// Входящий вызов:
+//
+//	call, err := v1.NewIncomingCall(v1.Config{SessionID: callID}, offer)
+//	answer, err := call.Answer()
+//	// ... 200 OK с answer
+//	err = call.Start()
+//	defer call.Close()
+package v1
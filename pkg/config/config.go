@@ -0,0 +1,419 @@
+// Package config загружает конфигурацию стека софтфона из YAML/JSON файлов.
+//
+// Файл конфигурации описывает SIP часть (dialog), параметры медиа сессий (media)
+// и параметры SDP/RTP транспорта (sdp). Значения из файла накладываются на
+// значения по умолчанию, затем переопределяются переменными окружения и
+// проверяются. Готовые структуры пакетов стека получаются методами To*Config.
+//
+// Пример файла:
+//
+//	dialog:
+//	  display_name: Alice
+//	  contact: alice
+//	  user_agent: SoftPhone/1.0
+//	  transports:
+//	    - type: UDP
+//	      host: 0.0.0.0
+//	      port: 5060
+//	media:
+//	  codec: PCMA
+//	  ptime: 20ms
+//	  jitter_enabled: true
+//	sdp:
+//	  local_addr: ":10000"
+//	  codecs: [PCMA, PCMU]
+//
+// Пример использования:
+//
+//	cfg, err := config.Load("softphone.yaml")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	ua, err := dialog.NewUACUAS(cfg.ToDialogConfig())
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// Config содержит конфигурацию всего стека
+type Config struct {
+	Dialog DialogConfig `json:"dialog" yaml:"dialog" env:"DIALOG"`
+	Media  MediaConfig  `json:"media" yaml:"media" env:"MEDIA"`
+	SDP    SDPConfig    `json:"sdp" yaml:"sdp" env:"SDP"`
+}
+
+// DialogConfig описывает конфигурацию SIP менеджера диалогов (dialog.Config)
+type DialogConfig struct {
+	Contact     string `json:"contact" yaml:"contact" env:"CONTACT"`
+	DisplayName string `json:"display_name" yaml:"display_name" env:"DISPLAY_NAME"`
+	UserAgent   string `json:"user_agent" yaml:"user_agent" env:"USER_AGENT"`
+	TestMode    bool   `json:"test_mode" yaml:"test_mode" env:"TEST_MODE"`
+
+	// CompactHeaders - отправлять SIP заголовки в компактной форме
+	CompactHeaders bool `json:"compact_headers" yaml:"compact_headers" env:"COMPACT_HEADERS"`
+
+	// SendReasonHeader - добавлять заголовок Reason в отправляемые BYE и CANCEL
+	SendReasonHeader bool `json:"send_reason_header" yaml:"send_reason_header" env:"SEND_REASON_HEADER"`
+
+	// Features - включенные возможности (refer, update, prack, timer, replaces).
+	// Если не указаны, используется dialog.DefaultFeatures()
+	Features []string `json:"features" yaml:"features" env:"FEATURES"`
+
+	// CallLimit - лимит длительности разговора для всех вызовов
+	CallLimit CallLimitConfig `json:"call_limit" yaml:"call_limit" env:"CALL_LIMIT"`
+	// TenantCallLimits - лимиты длительности по tenant вызова
+	TenantCallLimits map[string]CallLimitConfig `json:"tenant_call_limits" yaml:"tenant_call_limits"`
+
+	Transports []TransportConfig `json:"transports" yaml:"transports"`
+	Endpoints  []EndpointConfig  `json:"endpoints" yaml:"endpoints"`
+}
+
+// CallLimitConfig описывает лимит длительности вызова (dialog.CallLimit)
+type CallLimitConfig struct {
+	MaxDuration   Duration `json:"max_duration" yaml:"max_duration" env:"MAX_DURATION"`
+	WarningBefore Duration `json:"warning_before" yaml:"warning_before" env:"WARNING_BEFORE"`
+}
+
+// toDialogCallLimit преобразует описание лимита в dialog.CallLimit
+func (l CallLimitConfig) toDialogCallLimit() dialog.CallLimit {
+	return dialog.CallLimit{
+		MaxDuration:   time.Duration(l.MaxDuration),
+		WarningBefore: time.Duration(l.WarningBefore),
+	}
+}
+
+// TransportConfig описывает SIP транспорт (dialog.TransportConfig)
+type TransportConfig struct {
+	Type            string `json:"type" yaml:"type"`
+	Host            string `json:"host" yaml:"host"`
+	Port            int    `json:"port" yaml:"port"`
+	WSPath          string `json:"ws_path" yaml:"ws_path"`
+	KeepAlive       bool   `json:"keep_alive" yaml:"keep_alive"`
+	KeepAlivePeriod int    `json:"keep_alive_period" yaml:"keep_alive_period"`
+}
+
+// EndpointConfig описывает удаленную SIP точку подключения (dialog.Endpoint)
+type EndpointConfig struct {
+	Name      string          `json:"name" yaml:"name"`
+	Host      string          `json:"host" yaml:"host"`
+	Port      int             `json:"port" yaml:"port"`
+	Transport TransportConfig `json:"transport" yaml:"transport"`
+	Priority  uint16          `json:"priority" yaml:"priority"`
+	Weight    uint16          `json:"weight" yaml:"weight"`
+}
+
+// MediaConfig описывает параметры медиа сессии (media.Config)
+type MediaConfig struct {
+	Direction string   `json:"direction" yaml:"direction" env:"DIRECTION"`
+	Codec     string   `json:"codec" yaml:"codec" env:"CODEC"`
+	Ptime     Duration `json:"ptime" yaml:"ptime" env:"PTIME"`
+
+	JitterEnabled    bool     `json:"jitter_enabled" yaml:"jitter_enabled" env:"JITTER_ENABLED"`
+	JitterBufferSize int      `json:"jitter_buffer_size" yaml:"jitter_buffer_size" env:"JITTER_BUFFER_SIZE"`
+	JitterDelay      Duration `json:"jitter_delay" yaml:"jitter_delay" env:"JITTER_DELAY"`
+
+	DTMFEnabled     bool  `json:"dtmf_enabled" yaml:"dtmf_enabled" env:"DTMF_ENABLED"`
+	DTMFPayloadType uint8 `json:"dtmf_payload_type" yaml:"dtmf_payload_type" env:"DTMF_PAYLOAD_TYPE"`
+
+	RTCPEnabled  bool     `json:"rtcp_enabled" yaml:"rtcp_enabled" env:"RTCP_ENABLED"`
+	RTCPInterval Duration `json:"rtcp_interval" yaml:"rtcp_interval" env:"RTCP_INTERVAL"`
+
+	// ResourceLimits - бюджет ресурсов tenant без собственных лимитов
+	ResourceLimits TenantLimitsConfig `json:"resource_limits" yaml:"resource_limits" env:"RESOURCE_LIMITS"`
+	// TenantLimits - бюджеты ресурсов по tenant вызова
+	TenantLimits map[string]TenantLimitsConfig `json:"tenant_limits" yaml:"tenant_limits"`
+}
+
+// TenantLimitsConfig описывает бюджет ресурсов tenant (media.TenantLimits).
+// 0 - без ограничения
+type TenantLimitsConfig struct {
+	MaxGoroutines    int `json:"max_goroutines" yaml:"max_goroutines" env:"MAX_GOROUTINES"`
+	MaxBufferedAudio int `json:"max_buffered_audio" yaml:"max_buffered_audio" env:"MAX_BUFFERED_AUDIO"`
+	MaxRecordings    int `json:"max_recordings" yaml:"max_recordings" env:"MAX_RECORDINGS"`
+}
+
+// toMediaTenantLimits преобразует описание бюджета в media.TenantLimits
+func (l TenantLimitsConfig) toMediaTenantLimits() media.TenantLimits {
+	return media.TenantLimits{
+		MaxGoroutines:    l.MaxGoroutines,
+		MaxBufferedAudio: l.MaxBufferedAudio,
+		MaxRecordings:    l.MaxRecordings,
+	}
+}
+
+// SDPConfig описывает параметры SDP и RTP транспорта (media_sdp.BuilderConfig/HandlerConfig)
+type SDPConfig struct {
+	SessionName string   `json:"session_name" yaml:"session_name" env:"SESSION_NAME"`
+	LocalAddr   string   `json:"local_addr" yaml:"local_addr" env:"LOCAL_ADDR"`
+	BufferSize  int      `json:"buffer_size" yaml:"buffer_size" env:"BUFFER_SIZE"`
+	RTCPEnabled bool     `json:"rtcp_enabled" yaml:"rtcp_enabled" env:"RTCP_ENABLED"`
+	RTCPMux     bool     `json:"rtcp_mux" yaml:"rtcp_mux" env:"RTCP_MUX"`
+	Codecs      []string `json:"codecs" yaml:"codecs" env:"CODECS"`
+}
+
+// Duration - time.Duration, который записывается в файле строкой ("20ms", "5s")
+type Duration time.Duration
+
+// UnmarshalText разбирает длительность в формате time.ParseDuration.
+// Используется и JSON, и YAML декодером.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(strings.TrimSpace(string(text)))
+	if err != nil {
+		return fmt.Errorf("некорректная длительность %q: %w", string(text), err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText записывает длительность в формате time.Duration.String
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// codecInfo описывает кодек, доступный для выбора в конфигурации
+type codecInfo struct {
+	payloadType rtp.PayloadType
+	name        string
+	clockRate   uint32
+}
+
+// supportedCodecs содержит кодеки, которые можно указать в конфигурации по имени
+var supportedCodecs = map[string]codecInfo{
+	"PCMU": {rtp.PayloadTypePCMU, "PCMU", 8000},
+	"PCMA": {rtp.PayloadTypePCMA, "PCMA", 8000},
+	"G722": {rtp.PayloadTypeG722, "G722", 8000},
+	"GSM":  {rtp.PayloadTypeGSM, "GSM", 8000},
+	"G728": {rtp.PayloadTypeG728, "G728", 8000},
+	"G729": {rtp.PayloadTypeG729, "G729", 8000},
+}
+
+// lookupCodec ищет кодек по имени без учета регистра
+func lookupCodec(name string) (codecInfo, bool) {
+	codec, ok := supportedCodecs[strings.ToUpper(strings.TrimSpace(name))]
+	return codec, ok
+}
+
+// parseDirection преобразует SDP атрибут направления в media.Direction
+func parseDirection(value string) (media.Direction, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "sendrecv":
+		return media.DirectionSendRecv, true
+	case "sendonly":
+		return media.DirectionSendOnly, true
+	case "recvonly":
+		return media.DirectionRecvOnly, true
+	case "inactive":
+		return media.DirectionInactive, true
+	default:
+		return media.DirectionSendRecv, false
+	}
+}
+
+// Default возвращает конфигурацию со значениями по умолчанию пакетов стека
+func Default() *Config {
+	mediaDefaults := media.DefaultMediaSessionConfig()
+	builderDefaults := media_sdp.DefaultBuilderConfig()
+
+	return &Config{
+		Dialog: DialogConfig{
+			UserAgent: builderDefaults.UserAgent,
+			Transports: []TransportConfig{
+				{Type: string(dialog.TransportUDP), Host: "0.0.0.0", Port: 5060},
+			},
+		},
+		Media: MediaConfig{
+			Direction:        mediaDefaults.Direction.String(),
+			Codec:            "PCMU",
+			Ptime:            Duration(mediaDefaults.Ptime),
+			JitterEnabled:    mediaDefaults.JitterEnabled,
+			JitterBufferSize: mediaDefaults.JitterBufferSize,
+			JitterDelay:      Duration(mediaDefaults.JitterDelay),
+			DTMFEnabled:      mediaDefaults.DTMFEnabled,
+			DTMFPayloadType:  mediaDefaults.DTMFPayloadType,
+			RTCPEnabled:      mediaDefaults.RTCPEnabled,
+			RTCPInterval:     Duration(mediaDefaults.RTCPInterval),
+		},
+		SDP: SDPConfig{
+			SessionName: builderDefaults.SessionName,
+			LocalAddr:   builderDefaults.Transport.LocalAddr,
+			BufferSize:  builderDefaults.Transport.BufferSize,
+			RTCPEnabled: builderDefaults.Transport.RTCPEnabled,
+			Codecs:      []string{"PCMU", "PCMA", "G722"},
+		},
+	}
+}
+
+// toDialogTransport преобразует описание транспорта в dialog.TransportConfig
+func (t TransportConfig) toDialogTransport() dialog.TransportConfig {
+	return dialog.TransportConfig{
+		Type:            dialog.TransportType(strings.ToUpper(t.Type)),
+		Host:            t.Host,
+		Port:            t.Port,
+		WSPath:          t.WSPath,
+		KeepAlive:       t.KeepAlive,
+		KeepAlivePeriod: t.KeepAlivePeriod,
+	}
+}
+
+// ToDialogConfig возвращает конфигурацию для dialog.NewUACUAS
+func (c *Config) ToDialogConfig() dialog.Config {
+	result := dialog.Config{
+		Contact:     c.Dialog.Contact,
+		DisplayName: c.Dialog.DisplayName,
+		UserAgent:   c.Dialog.UserAgent,
+		TestMode:    c.Dialog.TestMode,
+
+		CompactHeaders:   c.Dialog.CompactHeaders,
+		SendReasonHeader: c.Dialog.SendReasonHeader,
+		CallLimit:        c.Dialog.CallLimit.toDialogCallLimit(),
+	}
+
+	if len(c.Dialog.TenantCallLimits) > 0 {
+		result.TenantCallLimits = make(map[string]dialog.CallLimit, len(c.Dialog.TenantCallLimits))
+		for tenant, limit := range c.Dialog.TenantCallLimits {
+			result.TenantCallLimits[tenant] = limit.toDialogCallLimit()
+		}
+	}
+
+	if c.Dialog.Features != nil {
+		result.Features = make([]dialog.Feature, 0, len(c.Dialog.Features))
+		for _, f := range c.Dialog.Features {
+			result.Features = append(result.Features, dialog.Feature(strings.ToLower(strings.TrimSpace(f))))
+		}
+	}
+
+	for _, transport := range c.Dialog.Transports {
+		result.TransportConfigs = append(result.TransportConfigs, transport.toDialogTransport())
+	}
+
+	// Endpoint содержит атомарные поля, поэтому заполняем элементы среза на месте
+	result.Endpoints = make([]dialog.Endpoint, len(c.Dialog.Endpoints))
+	for i, ep := range c.Dialog.Endpoints {
+		result.Endpoints[i].Name = ep.Name
+		result.Endpoints[i].Host = ep.Host
+		result.Endpoints[i].Port = ep.Port
+		result.Endpoints[i].Transport = ep.Transport.toDialogTransport()
+		result.Endpoints[i].Priority = ep.Priority
+		result.Endpoints[i].Weight = ep.Weight
+	}
+
+	return result
+}
+
+// ToMediaConfig возвращает конфигурацию медиа сессии с указанным идентификатором
+func (c *Config) ToMediaConfig(sessionID string) media.Config {
+	result := media.DefaultMediaSessionConfig()
+	result.SessionID = sessionID
+
+	if direction, ok := parseDirection(c.Media.Direction); ok {
+		result.Direction = direction
+	}
+	if codec, ok := lookupCodec(c.Media.Codec); ok {
+		result.PayloadType = media.PayloadType(codec.payloadType)
+	}
+
+	result.Ptime = time.Duration(c.Media.Ptime)
+	result.JitterEnabled = c.Media.JitterEnabled
+	result.JitterBufferSize = c.Media.JitterBufferSize
+	result.JitterDelay = time.Duration(c.Media.JitterDelay)
+	result.DTMFEnabled = c.Media.DTMFEnabled
+	result.DTMFPayloadType = c.Media.DTMFPayloadType
+	result.RTCPEnabled = c.Media.RTCPEnabled
+	result.RTCPInterval = time.Duration(c.Media.RTCPInterval)
+
+	return result
+}
+
+// ToTenantResources возвращает общий для процесса учет ресурсов по tenant
+// для media.Config.Resources. nil - лимиты не заданы
+func (c *Config) ToTenantResources() *media.TenantResources {
+	if c.Media.ResourceLimits == (TenantLimitsConfig{}) && len(c.Media.TenantLimits) == 0 {
+		return nil
+	}
+
+	limits := make(map[string]media.TenantLimits, len(c.Media.TenantLimits))
+	for tenant, limit := range c.Media.TenantLimits {
+		limits[tenant] = limit.toMediaTenantLimits()
+	}
+	return media.NewTenantResources(c.Media.ResourceLimits.toMediaTenantLimits(), limits)
+}
+
+// toSDPTransport возвращает конфигурацию RTP транспорта для media_sdp
+func (c *Config) toSDPTransport() media_sdp.TransportConfig {
+	muxMode := rtp.RTCPMuxNone
+	if c.SDP.RTCPMux {
+		muxMode = rtp.RTCPMuxDemux
+	}
+
+	return media_sdp.TransportConfig{
+		Type:        media_sdp.TransportTypeUDP,
+		LocalAddr:   c.SDP.LocalAddr,
+		BufferSize:  c.SDP.BufferSize,
+		RTCPEnabled: c.SDP.RTCPEnabled,
+		RTCPMuxMode: muxMode,
+	}
+}
+
+// ToBuilderConfig возвращает конфигурацию для создания SDP offer
+func (c *Config) ToBuilderConfig(sessionID string) media_sdp.BuilderConfig {
+	result := media_sdp.DefaultBuilderConfig()
+	mediaConfig := c.ToMediaConfig(sessionID)
+
+	result.SessionID = sessionID
+	result.SessionName = c.SDP.SessionName
+	result.UserAgent = c.Dialog.UserAgent
+	result.PayloadType = rtp.PayloadType(mediaConfig.PayloadType)
+	result.Ptime = mediaConfig.Ptime
+	result.Direction = mediaConfig.Direction
+	result.Transport = c.toSDPTransport()
+	result.MediaConfig = mediaConfig
+	result.DTMFEnabled = mediaConfig.DTMFEnabled
+	result.DTMFPayloadType = mediaConfig.DTMFPayloadType
+
+	if codec, ok := lookupCodec(c.Media.Codec); ok {
+		result.ClockRate = codec.clockRate
+	}
+
+	return result
+}
+
+// ToHandlerConfig возвращает конфигурацию для обработки SDP offer
+func (c *Config) ToHandlerConfig(sessionID string) media_sdp.HandlerConfig {
+	result := media_sdp.DefaultHandlerConfig()
+	mediaConfig := c.ToMediaConfig(sessionID)
+
+	result.SessionID = sessionID
+	result.SessionName = c.SDP.SessionName
+	result.UserAgent = c.Dialog.UserAgent
+	result.Transport = c.toSDPTransport()
+	result.MediaConfig = mediaConfig
+	result.DTMFEnabled = mediaConfig.DTMFEnabled
+	result.DTMFPayloadType = mediaConfig.DTMFPayloadType
+
+	if len(c.SDP.Codecs) > 0 {
+		result.SupportedCodecs = nil
+		for _, name := range c.SDP.Codecs {
+			codec, ok := lookupCodec(name)
+			if !ok {
+				continue
+			}
+			result.SupportedCodecs = append(result.SupportedCodecs, media_sdp.CodecInfo{
+				PayloadType: codec.payloadType,
+				Name:        codec.name,
+				ClockRate:   codec.clockRate,
+				Channels:    1,
+				Ptime:       mediaConfig.Ptime,
+			})
+		}
+	}
+
+	return result
+}
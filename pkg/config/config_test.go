@@ -0,0 +1,213 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+const testYAML = `
+dialog:
+  display_name: Alice
+  contact: alice
+  features: [update, timer]
+  call_limit:
+    max_duration: 2h
+  tenant_call_limits:
+    prepaid:
+      max_duration: 10m
+      warning_before: 30s
+  transports:
+    - type: udp
+      host: 127.0.0.1
+      port: 5070
+  endpoints:
+    - name: main
+      host: sip.example.com
+      port: 5060
+      transport:
+        type: UDP
+media:
+  codec: pcma
+  ptime: 30ms
+  jitter_enabled: true
+  jitter_delay: 80ms
+  resource_limits:
+    max_goroutines: 1000
+  tenant_limits:
+    trial:
+      max_goroutines: 20
+      max_recordings: 1
+sdp:
+  local_addr: ":10000"
+  codecs: [PCMA, PCMU]
+`
+
+func TestParseYAML(t *testing.T) {
+	cfg, err := Parse([]byte(testYAML), FormatYAML)
+	if err != nil {
+		t.Fatalf("Ошибка разбора YAML: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Конфигурация должна быть корректной: %v", err)
+	}
+
+	dialogConfig := cfg.ToDialogConfig()
+	if dialogConfig.DisplayName != "Alice" || dialogConfig.Contact != "alice" {
+		t.Errorf("Неверные параметры пользователя: %+v", dialogConfig)
+	}
+	if dialogConfig.UserAgent == "" {
+		t.Error("UserAgent по умолчанию должен сохраниться")
+	}
+	if len(dialogConfig.TransportConfigs) != 1 || dialogConfig.TransportConfigs[0].Type != dialog.TransportUDP ||
+		dialogConfig.TransportConfigs[0].Port != 5070 {
+		t.Errorf("Неверные транспорты: %+v", dialogConfig.TransportConfigs)
+	}
+	if len(dialogConfig.Features) != 2 || dialogConfig.Features[1] != dialog.FeatureTimer {
+		t.Errorf("Неверный список возможностей: %v", dialogConfig.Features)
+	}
+	if len(dialogConfig.Endpoints) != 1 || dialogConfig.Endpoints[0].Host != "sip.example.com" {
+		t.Errorf("Неверные endpoints")
+	}
+	if dialogConfig.CallLimit.MaxDuration != 2*time.Hour ||
+		dialogConfig.TenantCallLimits["prepaid"] != (dialog.CallLimit{MaxDuration: 10 * time.Minute, WarningBefore: 30 * time.Second}) {
+		t.Errorf("Неверные лимиты длительности: %+v %+v", dialogConfig.CallLimit, dialogConfig.TenantCallLimits)
+	}
+
+	mediaConfig := cfg.ToMediaConfig("call-1")
+	if mediaConfig.SessionID != "call-1" || mediaConfig.PayloadType != media.PayloadTypePCMA {
+		t.Errorf("Неверная медиа конфигурация: %+v", mediaConfig)
+	}
+	if mediaConfig.Ptime != 30*time.Millisecond || mediaConfig.JitterDelay != 80*time.Millisecond {
+		t.Errorf("Неверные длительности: ptime=%v jitter=%v", mediaConfig.Ptime, mediaConfig.JitterDelay)
+	}
+	if !mediaConfig.JitterEnabled || !mediaConfig.DTMFEnabled {
+		t.Error("Флаги медиа конфигурации разобраны неверно")
+	}
+
+	resources := cfg.ToTenantResources()
+	if resources == nil {
+		t.Fatal("Ожидался учет ресурсов tenant")
+	}
+	if limits := resources.Limits("trial"); limits != (media.TenantLimits{MaxGoroutines: 20, MaxRecordings: 1}) {
+		t.Errorf("Неверные лимиты tenant: %+v", limits)
+	}
+	if limits := resources.Limits("other"); limits.MaxGoroutines != 1000 {
+		t.Errorf("Неверные лимиты по умолчанию: %+v", limits)
+	}
+
+	handlerConfig := cfg.ToHandlerConfig("call-1")
+	if err := handlerConfig.Validate(); err != nil {
+		t.Fatalf("Конфигурация handler должна быть корректной: %v", err)
+	}
+	if len(handlerConfig.SupportedCodecs) != 2 || handlerConfig.SupportedCodecs[0].PayloadType != rtp.PayloadTypePCMA {
+		t.Errorf("Неверный список кодеков: %+v", handlerConfig.SupportedCodecs)
+	}
+
+	builderConfig := cfg.ToBuilderConfig("call-1")
+	if err := builderConfig.Validate(); err != nil {
+		t.Fatalf("Конфигурация builder должна быть корректной: %v", err)
+	}
+	if builderConfig.Transport.LocalAddr != ":10000" || builderConfig.PayloadType != rtp.PayloadTypePCMA {
+		t.Errorf("Неверная конфигурация builder: %+v", builderConfig)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	data := `{"media": {"codec": "G722", "ptime": "20ms"}, "sdp": {"rtcp_mux": true}}`
+
+	cfg, err := Parse([]byte(data), FormatJSON)
+	if err != nil {
+		t.Fatalf("Ошибка разбора JSON: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Конфигурация должна быть корректной: %v", err)
+	}
+	if cfg.ToMediaConfig("s").PayloadType != media.PayloadTypeG722 {
+		t.Error("Кодек G722 не применен")
+	}
+	if !cfg.SDP.RTCPMux {
+		t.Error("rtcp_mux не применен")
+	}
+
+	if _, err := Parse([]byte(`{"media": {"unknown": 1}}`), FormatJSON); err == nil {
+		t.Error("Неизвестное поле должно приводить к ошибке")
+	}
+	if _, err := Parse([]byte(`{"media": {"ptime": "20"}}`), FormatJSON); err == nil {
+		t.Error("Длительность без единиц измерения должна приводить к ошибке")
+	}
+}
+
+func TestLoadWithEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "softphone.yml")
+	if err := os.WriteFile(path, []byte(testYAML), 0o600); err != nil {
+		t.Fatalf("Ошибка записи файла: %v", err)
+	}
+
+	t.Setenv("SOFTPHONE_DIALOG_USER_AGENT", "Test/2.0")
+	t.Setenv("SOFTPHONE_MEDIA_PTIME", "20ms")
+	t.Setenv("SOFTPHONE_MEDIA_JITTER_ENABLED", "false")
+	t.Setenv("SOFTPHONE_SDP_CODECS", "PCMU, G722")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Ошибка загрузки конфигурации: %v", err)
+	}
+
+	if cfg.Dialog.UserAgent != "Test/2.0" {
+		t.Errorf("UserAgent не переопределен: %s", cfg.Dialog.UserAgent)
+	}
+	if time.Duration(cfg.Media.Ptime) != 20*time.Millisecond || cfg.Media.JitterEnabled {
+		t.Errorf("Медиа параметры не переопределены: %+v", cfg.Media)
+	}
+	if strings.Join(cfg.SDP.Codecs, ",") != "PCMU,G722" {
+		t.Errorf("Кодеки не переопределены: %v", cfg.SDP.Codecs)
+	}
+
+	t.Setenv("SOFTPHONE_MEDIA_JITTER_BUFFER_SIZE", "много")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "SOFTPHONE_MEDIA_JITTER_BUFFER_SIZE") {
+		t.Errorf("Ошибка должна указывать на переменную окружения: %v", err)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	cfg := Default()
+	cfg.Dialog.Transports[0].Type = "SCTP"
+	cfg.Media.Codec = "OPUS"
+	cfg.Media.Ptime = Duration(100 * time.Millisecond)
+	cfg.SDP.Codecs = []string{"PCMU", "pcmu"}
+	cfg.Dialog.Features = []string{"video"}
+	cfg.Dialog.CallLimit = CallLimitConfig{MaxDuration: Duration(time.Minute), WarningBefore: Duration(time.Minute)}
+	cfg.Media.TenantLimits = map[string]TenantLimitsConfig{"trial": {MaxRecordings: -1}}
+
+	err := cfg.Validate()
+	errs, ok := AsValidationErrors(err)
+	if !ok {
+		t.Fatalf("Ожидались ValidationErrors, получено %v", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{"dialog.transports[0]", "media.codec", "media.ptime", "sdp.codecs[1]", "dialog.features[0]",
+		"dialog.call_limit.warning_before", "media.tenant_limits[trial]"} {
+		if !fields[field] {
+			t.Errorf("Ожидалась ошибка для поля %s, получено: %v", field, err)
+		}
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Конфигурация по умолчанию должна быть корректной: %v", err)
+	}
+
+	if _, err := Load("softphone.toml"); err == nil {
+		t.Error("Неизвестное расширение файла должно приводить к ошибке")
+	}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnvPrefix префикс переменных окружения для переопределения конфигурации.
+// Имя переменной строится из тегов env вложенных полей: SOFTPHONE_MEDIA_PTIME=30ms
+const DefaultEnvPrefix = "SOFTPHONE"
+
+// Format определяет формат файла конфигурации
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// Load загружает конфигурацию из файла с переопределением переменными окружения
+// с префиксом DefaultEnvPrefix и проверяет результат.
+// Формат определяется по расширению файла (.yaml, .yml, .json).
+func Load(path string) (*Config, error) {
+	format, err := formatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла конфигурации %s: %w", path, err)
+	}
+
+	cfg, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора файла конфигурации %s: %w", path, err)
+	}
+
+	if err := cfg.ApplyEnv(DefaultEnvPrefix); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Parse разбирает конфигурацию из данных в указанном формате.
+// Отсутствующие в данных поля сохраняют значения по умолчанию, неизвестные поля
+// считаются ошибкой. Переменные окружения и валидация не применяются.
+func Parse(data []byte, format Format) (*Config, error) {
+	cfg := Default()
+
+	switch format {
+	case FormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("некорректный YAML: %w", err)
+		}
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("некорректный JSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат конфигурации: %s", format)
+	}
+
+	return cfg, nil
+}
+
+// formatFromPath определяет формат по расширению файла
+func formatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("неизвестное расширение файла конфигурации: %s", path)
+	}
+}
+
+// ApplyEnv переопределяет поля конфигурации значениями переменных окружения.
+//
+// Имя переменной складывается из префикса и тегов env по пути к полю через '_',
+// например SOFTPHONE_DIALOG_USER_AGENT или SOFTPHONE_SDP_CODECS=PCMA,PCMU.
+// Списки строк задаются через запятую. Поля без тега env не переопределяются.
+func (c *Config) ApplyEnv(prefix string) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), prefix)
+}
+
+// applyEnv рекурсивно обходит структуру и применяет переменные окружения
+func applyEnv(value reflect.Value, prefix string) error {
+	valueType := value.Type()
+
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag := field.Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+
+		name := tag
+		if prefix != "" {
+			name = prefix + "_" + tag
+		}
+
+		fieldValue := value.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(fieldValue, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err := setFromString(fieldValue, raw); err != nil {
+			return &ValidationError{Field: name, Message: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// setFromString устанавливает значение поля из строки переменной окружения
+func setFromString(value reflect.Value, raw string) error {
+	if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("ожидалось логическое значение, получено %q", raw)
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("ожидалось целое число, получено %q", raw)
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("ожидалось неотрицательное целое число, получено %q", raw)
+		}
+		value.SetUint(parsed)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("тип %s не поддерживается в переменных окружения", value.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("тип %s не поддерживается в переменных окружения", value.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+)
+
+// ValidationError описывает ошибку в конкретном поле конфигурации
+type ValidationError struct {
+	Field   string // Путь к полю, например "media.ptime" или "dialog.transports[0].port"
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors содержит все ошибки, найденные при проверке конфигурации
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "некорректная конфигурация: " + strings.Join(messages, "; ")
+}
+
+// validator собирает ошибки проверки, чтобы сообщить обо всех сразу
+type validator struct {
+	errors ValidationErrors
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errors = append(v.errors, &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate проверяет конфигурацию и возвращает ValidationErrors со всеми найденными ошибками
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	c.validateDialog(v)
+	c.validateMedia(v)
+	c.validateSDP(v)
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+func (c *Config) validateDialog(v *validator) {
+	if len(c.Dialog.Transports) == 0 {
+		v.add("dialog.transports", "должен быть указан хотя бы один транспорт")
+	}
+
+	for i, transport := range c.Dialog.Transports {
+		field := fmt.Sprintf("dialog.transports[%d]", i)
+		dialogTransport := transport.toDialogTransport()
+		if err := dialogTransport.Validate(); err != nil {
+			v.add(field, "%v", err)
+		}
+	}
+
+	for i, f := range c.Dialog.Features {
+		switch dialog.Feature(strings.ToLower(strings.TrimSpace(f))) {
+		case dialog.FeatureRefer, dialog.FeatureUpdate, dialog.FeaturePrack, dialog.FeatureTimer, dialog.FeatureReplaces:
+		default:
+			v.add(fmt.Sprintf("dialog.features[%d]", i), "неизвестная возможность %q", f)
+		}
+	}
+
+	validateCallLimit(v, "dialog.call_limit", c.Dialog.CallLimit)
+	for tenant, limit := range c.Dialog.TenantCallLimits {
+		validateCallLimit(v, fmt.Sprintf("dialog.tenant_call_limits[%s]", tenant), limit)
+	}
+
+	names := make(map[string]bool)
+	for i, ep := range c.Dialog.Endpoints {
+		field := fmt.Sprintf("dialog.endpoints[%d]", i)
+		endpoint := dialog.Endpoint{
+			Name:      ep.Name,
+			Host:      ep.Host,
+			Port:      ep.Port,
+			Transport: ep.Transport.toDialogTransport(),
+		}
+		if err := endpoint.Validate(); err != nil {
+			v.add(field, "%v", err)
+		}
+		if ep.Name != "" && names[ep.Name] {
+			v.add(field+".name", "дублирующееся имя endpoint'а: %s", ep.Name)
+		}
+		names[ep.Name] = true
+	}
+}
+
+// validateCallLimit проверяет лимит длительности вызова
+func validateCallLimit(v *validator, field string, limit CallLimitConfig) {
+	if limit.MaxDuration < 0 || limit.WarningBefore < 0 {
+		v.add(field, "длительности не могут быть отрицательными")
+		return
+	}
+	if limit.WarningBefore > 0 && limit.WarningBefore >= limit.MaxDuration {
+		v.add(field+".warning_before", "предупреждение должно быть меньше max_duration (%s)", time.Duration(limit.MaxDuration))
+	}
+}
+
+func (c *Config) validateMedia(v *validator) {
+	if _, ok := parseDirection(c.Media.Direction); !ok {
+		v.add("media.direction", "неизвестное направление %q (допустимо: sendrecv, sendonly, recvonly, inactive)", c.Media.Direction)
+	}
+
+	if _, ok := lookupCodec(c.Media.Codec); !ok {
+		v.add("media.codec", "неподдерживаемый кодек %q", c.Media.Codec)
+	}
+
+	ptime := time.Duration(c.Media.Ptime)
+	if ptime < 10*time.Millisecond || ptime > 40*time.Millisecond {
+		v.add("media.ptime", "должен быть от 10ms до 40ms, получено %v", ptime)
+	}
+
+	if c.Media.JitterEnabled {
+		if c.Media.JitterBufferSize <= 0 {
+			v.add("media.jitter_buffer_size", "должен быть больше 0, получено %d", c.Media.JitterBufferSize)
+		}
+		if c.Media.JitterDelay < 0 {
+			v.add("media.jitter_delay", "не может быть отрицательной")
+		}
+	}
+
+	if c.Media.DTMFEnabled && (c.Media.DTMFPayloadType < 96 || c.Media.DTMFPayloadType > 127) {
+		v.add("media.dtmf_payload_type", "должен быть в динамическом диапазоне 96-127, получено %d", c.Media.DTMFPayloadType)
+	}
+
+	if c.Media.RTCPEnabled && c.Media.RTCPInterval <= 0 {
+		v.add("media.rtcp_interval", "должен быть больше 0 при включенном RTCP")
+	}
+
+	validateTenantLimits(v, "media.resource_limits", c.Media.ResourceLimits)
+	for tenant, limits := range c.Media.TenantLimits {
+		validateTenantLimits(v, fmt.Sprintf("media.tenant_limits[%s]", tenant), limits)
+	}
+}
+
+// validateTenantLimits проверяет бюджет ресурсов tenant
+func validateTenantLimits(v *validator, field string, limits TenantLimitsConfig) {
+	if limits.MaxGoroutines < 0 || limits.MaxBufferedAudio < 0 || limits.MaxRecordings < 0 {
+		v.add(field, "лимиты не могут быть отрицательными")
+	}
+}
+
+func (c *Config) validateSDP(v *validator) {
+	if c.SDP.LocalAddr == "" {
+		v.add("sdp.local_addr", "не может быть пустым")
+	}
+
+	if c.SDP.BufferSize <= 0 {
+		v.add("sdp.buffer_size", "должен быть больше 0, получено %d", c.SDP.BufferSize)
+	}
+
+	if len(c.SDP.Codecs) == 0 {
+		v.add("sdp.codecs", "должен быть указан хотя бы один кодек")
+	}
+
+	seen := make(map[string]bool)
+	for i, name := range c.SDP.Codecs {
+		codec, ok := lookupCodec(name)
+		if !ok {
+			v.add(fmt.Sprintf("sdp.codecs[%d]", i), "неподдерживаемый кодек %q", name)
+			continue
+		}
+		if seen[codec.name] {
+			v.add(fmt.Sprintf("sdp.codecs[%d]", i), "кодек %s указан повторно", codec.name)
+		}
+		seen[codec.name] = true
+	}
+}
+
+// AsValidationErrors извлекает ValidationErrors из ошибки, если они в ней есть
+func AsValidationErrors(err error) (ValidationErrors, bool) {
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		return errs, true
+	}
+	return nil, false
+}
@@ -1,11 +1,15 @@
 package dialog
 
+import "net/textproto"
+
 // Body представляет тело SIP сообщения.
 // Может содержать SDP, XML или другие данные в зависимости от типа контента.
 // Наиболее часто используется для передачи SDP (Session Description Protocol).
+// Составное тело (multipart/mixed) разбирается на части через Parts и Part.
 type Body struct {
 	contentType string
 	content     []byte
+	headers     textproto.MIMEHeader // Заголовки части составного тела
 }
 
 // ContentType возвращает тип содержимого (MIME type).
@@ -0,0 +1,232 @@
+package dialog
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// CallLimit ограничивает длительность разговора (например, для предоплаченных
+// вызовов). Отсчет идет от ответа на вызов (переход в InCall).
+//
+// Предупреждающий тон воспроизводится приложением по событию CallLimitWarning:
+//
+//	d.OnCallLimit(func(event dialog.CallLimitEvent) {
+//	    if event.Stage == dialog.CallLimitWarning {
+//	        gen := testsignal.NewGenerator(testsignal.WarningTone(0.3), testsignal.PCMU, 20*time.Millisecond)
+//	        for _, frame := range gen.Frames(60) { // 1.2 с - три сигнала
+//	            _ = mediaSession.SendAudioRaw(frame)
+//	        }
+//	    }
+//	})
+type CallLimit struct {
+	// MaxDuration - максимальная длительность разговора, 0 - без ограничения
+	MaxDuration time.Duration
+	// WarningBefore - за сколько до окончания лимита отправить событие
+	// CallLimitWarning (для воспроизведения предупреждающего тона), 0 - без
+	// предупреждения
+	WarningBefore time.Duration
+}
+
+// CallLimitStage - этап контроля длительности вызова
+type CallLimitStage int
+
+const (
+	// CallLimitWarning - до окончания лимита осталось WarningBefore
+	CallLimitWarning CallLimitStage = iota
+	// CallLimitReached - лимит исчерпан, вызов завершается BYE с
+	// причиной CallLimitCause
+	CallLimitReached
+)
+
+// String возвращает название этапа
+func (s CallLimitStage) String() string {
+	switch s {
+	case CallLimitWarning:
+		return "warning"
+	case CallLimitReached:
+		return "reached"
+	default:
+		return "unknown"
+	}
+}
+
+// CallLimitEvent описывает событие контроля длительности вызова
+type CallLimitEvent struct {
+	Stage      CallLimitStage
+	Limit      CallLimit
+	AnsweredAt time.Time     // Время ответа на вызов
+	Remaining  time.Duration // Оставшееся время разговора
+}
+
+// callLimiter планирует события лимита длительности вызова
+type callLimiter struct {
+	mu         sync.Mutex
+	limit      CallLimit
+	explicit   bool   // Лимит задан для вызова через SetCallLimit
+	tenant     string // Tenant вызова для Config.TenantCallLimits
+	answeredAt time.Time
+	warned     bool
+	stopped    bool
+	generation uint64 // Отменяет события таймеров предыдущего планирования
+	warnTimer  *time.Timer
+	endTimer   *time.Timer
+}
+
+// stopTimers останавливает таймеры лимита. Вызывается под mu.
+func (l *callLimiter) stopTimers() {
+	l.generation++
+	if l.warnTimer != nil {
+		l.warnTimer.Stop()
+		l.warnTimer = nil
+	}
+	if l.endTimer != nil {
+		l.endTimer.Stop()
+		l.endTimer = nil
+	}
+}
+
+// SetCallLimit задает лимит длительности для этого вызова вместо лимита
+// tenant и Config.CallLimit. Во время разговора лимит пересчитывается от
+// времени ответа, что позволяет продлить вызов после пополнения баланса.
+// Метод потокобезопасен.
+func (s *Dialog) SetCallLimit(limit CallLimit) {
+	s.callLimit.mu.Lock()
+	s.callLimit.limit = limit
+	s.callLimit.explicit = true
+	s.callLimit.warned = false
+	s.callLimit.mu.Unlock()
+
+	s.scheduleCallLimit()
+}
+
+// SetTenant задает tenant вызова. Если для вызова не задан собственный
+// лимит, применяется Config.TenantCallLimits[tenant].
+// Метод потокобезопасен.
+func (s *Dialog) SetTenant(tenant string) {
+	s.callLimit.mu.Lock()
+	s.callLimit.tenant = tenant
+	s.callLimit.mu.Unlock()
+
+	s.scheduleCallLimit()
+}
+
+// CallLimit возвращает действующий лимит длительности вызова
+func (s *Dialog) CallLimit() CallLimit {
+	s.callLimit.mu.Lock()
+	defer s.callLimit.mu.Unlock()
+	return s.effectiveCallLimit()
+}
+
+// OnCallLimit устанавливает обработчик событий лимита длительности вызова.
+// Обработчик вызывается из отдельной горутины таймера.
+// Метод потокобезопасен.
+func (s *Dialog) OnCallLimit(handler func(event CallLimitEvent)) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.callLimitHandler = handler
+}
+
+// effectiveCallLimit выбирает лимит вызова: собственный, tenant или
+// Config.CallLimit. Вызывается под callLimit.mu.
+func (s *Dialog) effectiveCallLimit() CallLimit {
+	if s.callLimit.explicit {
+		return s.callLimit.limit
+	}
+	if s.uu == nil {
+		return CallLimit{}
+	}
+	if limit, ok := s.uu.config.TenantCallLimits[s.callLimit.tenant]; ok && s.callLimit.tenant != "" {
+		return limit
+	}
+	return s.uu.config.CallLimit
+}
+
+// startCallLimit начинает отсчет лимита при ответе на вызов
+func (s *Dialog) startCallLimit() {
+	s.callLimit.mu.Lock()
+	if s.callLimit.answeredAt.IsZero() {
+		s.callLimit.answeredAt = time.Now()
+	}
+	s.callLimit.mu.Unlock()
+
+	s.scheduleCallLimit()
+}
+
+// stopCallLimit отменяет события лимита при завершении вызова
+func (s *Dialog) stopCallLimit() {
+	s.callLimit.mu.Lock()
+	defer s.callLimit.mu.Unlock()
+	s.callLimit.stopped = true
+	s.callLimit.stopTimers()
+}
+
+// scheduleCallLimit (пере)планирует события лимита от времени ответа
+func (s *Dialog) scheduleCallLimit() {
+	s.callLimit.mu.Lock()
+	defer s.callLimit.mu.Unlock()
+
+	s.callLimit.stopTimers()
+	if s.callLimit.answeredAt.IsZero() || s.callLimit.stopped {
+		return
+	}
+	limit := s.effectiveCallLimit()
+	if limit.MaxDuration <= 0 {
+		return
+	}
+
+	generation := s.callLimit.generation
+	remaining := limit.MaxDuration - time.Since(s.callLimit.answeredAt)
+	if limit.WarningBefore > 0 && !s.callLimit.warned && remaining > 0 {
+		s.callLimit.warnTimer = time.AfterFunc(max(remaining-limit.WarningBefore, 0), func() {
+			s.fireCallLimit(generation, CallLimitWarning)
+		})
+	}
+	s.callLimit.endTimer = time.AfterFunc(max(remaining, 0), func() {
+		s.fireCallLimit(generation, CallLimitReached)
+	})
+}
+
+// fireCallLimit отправляет событие лимита и по исчерпании лимита завершает вызов
+func (s *Dialog) fireCallLimit(generation uint64, stage CallLimitStage) {
+	s.callLimit.mu.Lock()
+	if generation != s.callLimit.generation || s.callLimit.stopped {
+		s.callLimit.mu.Unlock()
+		return
+	}
+	limit := s.effectiveCallLimit()
+	event := CallLimitEvent{
+		Stage:      stage,
+		Limit:      limit,
+		AnsweredAt: s.callLimit.answeredAt,
+		Remaining:  max(limit.MaxDuration-time.Since(s.callLimit.answeredAt), 0),
+	}
+	switch stage {
+	case CallLimitWarning:
+		s.callLimit.warned = true
+	case CallLimitReached:
+		s.callLimit.stopped = true
+		event.Remaining = 0
+	}
+	s.callLimit.mu.Unlock()
+
+	slog.Info("Лимит длительности вызова",
+		slog.String("dialogID", s.ID()),
+		slog.String("stage", stage.String()),
+		slog.Duration("remaining", event.Remaining))
+
+	s.handlersMu.Lock()
+	handler := s.callLimitHandler
+	s.handlersMu.Unlock()
+	if handler != nil {
+		handler(event)
+	}
+
+	if stage == CallLimitReached && s.State() == InCall {
+		if err := s.TerminateWithCause(CallLimitCause()); err != nil {
+			slog.Error("Не удалось завершить вызов по лимиту длительности",
+				slog.String("dialogID", s.ID()),
+				slog.String("error", err.Error()))
+		}
+	}
+}
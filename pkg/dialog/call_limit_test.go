@@ -0,0 +1,105 @@
+package dialog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallLimitSelection(t *testing.T) {
+	d := &Dialog{uu: &UACUAS{config: Config{
+		CallLimit:        CallLimit{MaxDuration: time.Hour},
+		TenantCallLimits: map[string]CallLimit{"prepaid": {MaxDuration: time.Minute, WarningBefore: 10 * time.Second}},
+	}}}
+
+	assert.Equal(t, time.Hour, d.CallLimit().MaxDuration, "Лимит по умолчанию из Config")
+
+	d.SetTenant("prepaid")
+	assert.Equal(t, time.Minute, d.CallLimit().MaxDuration, "Лимит tenant")
+
+	d.SetTenant("unknown")
+	assert.Equal(t, time.Hour, d.CallLimit().MaxDuration, "Tenant без лимита")
+
+	d.SetCallLimit(CallLimit{MaxDuration: 5 * time.Minute})
+	d.SetTenant("prepaid")
+	assert.Equal(t, 5*time.Minute, d.CallLimit().MaxDuration, "Лимит вызова приоритетнее лимита tenant")
+}
+
+func TestCallLimitEvents(t *testing.T) {
+	d := &Dialog{}
+	events := make(chan CallLimitEvent, 4)
+	d.OnCallLimit(func(event CallLimitEvent) { events <- event })
+
+	// До ответа лимит не отсчитывается
+	d.SetCallLimit(CallLimit{MaxDuration: 150 * time.Millisecond, WarningBefore: 100 * time.Millisecond})
+	select {
+	case <-events:
+		t.Fatal("События до ответа на вызов не ожидались")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	d.startCallLimit()
+
+	var warning, reached CallLimitEvent
+	for _, target := range []*CallLimitEvent{&warning, &reached} {
+		select {
+		case *target = <-events:
+		case <-time.After(time.Second):
+			t.Fatal("Таймаут ожидания события лимита")
+		}
+	}
+
+	assert.Equal(t, CallLimitWarning, warning.Stage)
+	assert.InDelta(t, float64(100*time.Millisecond), float64(warning.Remaining), float64(40*time.Millisecond))
+	assert.Equal(t, CallLimitReached, reached.Stage)
+	assert.Zero(t, reached.Remaining)
+	assert.False(t, warning.AnsweredAt.IsZero())
+
+	// После исчерпания лимита новые события не отправляются
+	d.SetCallLimit(CallLimit{MaxDuration: time.Millisecond})
+	select {
+	case event := <-events:
+		t.Fatalf("Неожиданное событие %s", event.Stage)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCallLimitExtend(t *testing.T) {
+	d := &Dialog{}
+	events := make(chan CallLimitEvent, 4)
+	d.OnCallLimit(func(event CallLimitEvent) { events <- event })
+
+	d.SetCallLimit(CallLimit{MaxDuration: 100 * time.Millisecond})
+	d.startCallLimit()
+
+	// Продление во время разговора отменяет запланированное завершение
+	d.SetCallLimit(CallLimit{MaxDuration: 300 * time.Millisecond})
+	select {
+	case <-events:
+		t.Fatal("Лимит продлен, событие не ожидалось")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	select {
+	case event := <-events:
+		assert.Equal(t, CallLimitReached, event.Stage)
+	case <-time.After(time.Second):
+		t.Fatal("Таймаут ожидания исчерпания лимита")
+	}
+
+	// Завершение вызова отменяет события
+	stopped := &Dialog{}
+	stopped.OnCallLimit(func(event CallLimitEvent) { events <- event })
+	stopped.SetCallLimit(CallLimit{MaxDuration: 50 * time.Millisecond})
+	stopped.startCallLimit()
+	stopped.stopCallLimit()
+	select {
+	case <-events:
+		t.Fatal("События после завершения вызова не ожидались")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.Equal(t, CategoryCallLimit, CallLimitCause().Category)
+}
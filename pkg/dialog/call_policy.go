@@ -0,0 +1,274 @@
+package dialog
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyAction - решение политики для входящего вызова
+type PolicyAction string
+
+const (
+	// PolicyAccept - вызов передается приложению (OnIncomingCall)
+	PolicyAccept PolicyAction = "accept"
+	// PolicyReject - вызов отклоняется ответом Status
+	PolicyReject PolicyAction = "reject"
+	// PolicyForward - вызов перенаправляется ответом 302 на Target
+	PolicyForward PolicyAction = "forward"
+)
+
+// PolicyRule - правило политики входящих вызовов. Правила проверяются по
+// порядку, применяется первое правило, условие When которого выполнено.
+// Синтаксис условий описан в call_policy_expr.go.
+type PolicyRule struct {
+	// Name - имя правила для логов и PolicyDecision
+	Name string `yaml:"name" json:"name"`
+	// When - условие правила. Пустое условие выполняется всегда
+	When string `yaml:"when" json:"when"`
+	// Action - действие: accept, reject или forward
+	Action PolicyAction `yaml:"action" json:"action"`
+	// Status и Reason - код и фраза ответа для reject (по умолчанию 403)
+	Status int    `yaml:"status" json:"status"`
+	Reason string `yaml:"reason" json:"reason"`
+	// Target - адрес перенаправления для forward
+	Target string `yaml:"target" json:"target"`
+	// Profile - имя медиа профиля вызова, выбирает приложение
+	// (например, media_sdp.ProfileSelector)
+	Profile string `yaml:"profile" json:"profile"`
+}
+
+// PolicySet - набор правил политики. Загружается из YAML или JSON:
+//
+//	timezone: Europe/Moscow
+//	rules:
+//	  - name: block-anonymous
+//	    when: from.user == "anonymous" || !has_header("From")
+//	    action: reject
+//	    status: 403
+//	  - name: after-hours
+//	    when: hour < 9 || hour >= 18 || weekday in ["sat", "sun"]
+//	    action: forward
+//	    target: sip:voicemail@pbx.example.com
+//	  - name: vip
+//	    when: header("X-Priority") == "high"
+//	    action: accept
+//	    profile: hd-voice
+type PolicySet struct {
+	// Timezone - часовой пояс для hour, minute, weekday и time.
+	// Пустое значение - локальное время
+	Timezone string       `yaml:"timezone" json:"timezone"`
+	Rules    []PolicyRule `yaml:"rules" json:"rules"`
+}
+
+// PolicyDecision - результат проверки входящего вызова
+type PolicyDecision struct {
+	Rule    string // Имя сработавшего правила, пусто если ни одно не сработало
+	Action  PolicyAction
+	Status  int
+	Reason  string
+	Target  sip.Uri
+	Profile string
+}
+
+// compiledPolicy - проверенный набор правил
+type compiledPolicy struct {
+	location *time.Location
+	rules    []compiledRule
+}
+
+type compiledRule struct {
+	PolicyRule
+	when   policyExpr
+	target sip.Uri
+}
+
+// CallPolicy принимает решения по входящим вызовам на основе правил,
+// заданных данными. Правила можно заменить во время работы (Load, LoadFile)
+// без перекомпиляции: новые правила проверяются целиком и применяются
+// атомарно, при ошибке продолжают действовать прежние.
+//
+// Пример использования:
+//
+//	policy, err := dialog.LoadCallPolicy("policy.yaml")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	ua, err := dialog.NewUACUAS(dialog.Config{CallPolicy: policy})
+//	// по SIGHUP:
+//	if err := policy.LoadFile("policy.yaml"); err != nil {
+//	    log.Printf("политика не обновлена: %v", err)
+//	}
+type CallPolicy struct {
+	current atomic.Pointer[compiledPolicy]
+}
+
+// NewCallPolicy создает политику из набора правил
+func NewCallPolicy(set PolicySet) (*CallPolicy, error) {
+	p := &CallPolicy{}
+	if err := p.Load(set); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadCallPolicy создает политику из файла YAML или JSON
+func LoadCallPolicy(path string) (*CallPolicy, error) {
+	p := &CallPolicy{}
+	if err := p.LoadFile(path); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParsePolicySet разбирает набор правил в формате YAML или JSON
+func ParsePolicySet(data []byte) (PolicySet, error) {
+	var set PolicySet
+	if err := yaml.Unmarshal(data, &set); err != nil {
+		return set, errors.Wrap(err, "failed to parse call policy")
+	}
+	return set, nil
+}
+
+// LoadFile заменяет правила правилами из файла YAML или JSON
+func (p *CallPolicy) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read call policy")
+	}
+	set, err := ParsePolicySet(data)
+	if err != nil {
+		return err
+	}
+	return p.Load(set)
+}
+
+// Load проверяет набор правил и атомарно заменяет им текущий
+func (p *CallPolicy) Load(set PolicySet) error {
+	compiled := &compiledPolicy{location: time.Local}
+	if set.Timezone != "" {
+		location, err := time.LoadLocation(set.Timezone)
+		if err != nil {
+			return errors.Wrap(err, "invalid call policy timezone")
+		}
+		compiled.location = location
+	}
+
+	for i, rule := range set.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		c, err := compilePolicyRule(rule)
+		if err != nil {
+			return fmt.Errorf("call policy rule %s: %w", name, err)
+		}
+		compiled.rules = append(compiled.rules, c)
+	}
+
+	p.current.Store(compiled)
+	return nil
+}
+
+// compilePolicyRule проверяет правило и разбирает его условие
+func compilePolicyRule(rule PolicyRule) (compiledRule, error) {
+	c := compiledRule{PolicyRule: rule}
+	if rule.When != "" {
+		when, err := compilePolicyExpr(rule.When)
+		if err != nil {
+			return c, fmt.Errorf("invalid condition: %w", err)
+		}
+		c.when = when
+	}
+
+	switch rule.Action {
+	case PolicyAccept:
+	case PolicyReject:
+		if c.Status == 0 {
+			c.Status = sip.StatusForbidden
+		}
+		if c.Status < 400 || c.Status > 699 {
+			return c, fmt.Errorf("reject status %d is not a final error response", c.Status)
+		}
+	case PolicyForward:
+		if err := sip.ParseUri(rule.Target, &c.target); err != nil || rule.Target == "" {
+			return c, fmt.Errorf("invalid forward target %q", rule.Target)
+		}
+	default:
+		return c, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	return c, nil
+}
+
+// Evaluate проверяет входящий INVITE в момент now. Если ни одно правило не
+// сработало, вызов принимается
+func (p *CallPolicy) Evaluate(req *sip.Request, now time.Time) PolicyDecision {
+	compiled := p.current.Load()
+	if compiled == nil {
+		return PolicyDecision{Action: PolicyAccept}
+	}
+
+	in := &policyInput{req: req, now: now.In(compiled.location)}
+	for _, rule := range compiled.rules {
+		if rule.when != nil && !rule.when.eval(in).(bool) {
+			continue
+		}
+		return PolicyDecision{
+			Rule:    rule.Name,
+			Action:  rule.Action,
+			Status:  rule.Status,
+			Reason:  rule.Reason,
+			Target:  rule.target,
+			Profile: rule.Profile,
+		}
+	}
+	return PolicyDecision{Action: PolicyAccept}
+}
+
+// applyCallPolicy проверяет новый входящий INVITE политикой Config.CallPolicy.
+// Отклоненный или перенаправленный вызов получает ответ и возвращается
+// false; для принятого вызова возвращается решение для диалога.
+func (u *UACUAS) applyCallPolicy(req *sip.Request, tx sip.ServerTransaction) (*PolicyDecision, bool) {
+	if u.config.CallPolicy == nil {
+		return nil, true
+	}
+
+	decision := u.config.CallPolicy.Evaluate(req, time.Now())
+	var resp *sip.Response
+	switch decision.Action {
+	case PolicyAccept:
+		return &decision, true
+	case PolicyReject:
+		resp = sip.NewResponseFromRequest(req, decision.Status, decision.Reason, nil)
+	case PolicyForward:
+		resp = sip.NewResponseFromRequest(req, sip.StatusMovedTemporarily, "Moved Temporarily", nil)
+		resp.AppendHeader(&sip.ContactHeader{Address: decision.Target})
+	}
+
+	slog.Debug("Входящий вызов обработан политикой",
+		slog.String("rule", decision.Rule),
+		slog.String("action", string(decision.Action)),
+		slog.Int("status", resp.StatusCode))
+	if err := tx.Respond(resp); err != nil {
+		slog.Error("Не удалось отправить ответ по политике входящих вызовов",
+			slog.Any("error", err),
+			slog.String("rule", decision.Rule))
+	}
+	return nil, false
+}
+
+// PolicyDecision возвращает решение политики входящих вызовов, принявшее
+// этот вызов, или nil, если политика не настроена или вызов исходящий
+func (s *Dialog) PolicyDecision() *PolicyDecision {
+	if s.policyDecision == nil {
+		return nil
+	}
+	decision := *s.policyDecision
+	return &decision
+}
@@ -0,0 +1,564 @@
+package dialog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// Язык выражений политики входящих вызовов.
+//
+// Выражение - логическое условие над атрибутами входящего INVITE:
+//
+//	from.user == "anonymous"
+//	to.user startsWith "8800" && header("X-Priority") == "high"
+//	hour < 9 || hour >= 18 || weekday in ["sat", "sun"]
+//	from.host matches "^10\\.0\\." && !has_header("P-Asserted-Identity")
+//
+// Переменные (строки, кроме hour и minute):
+//
+//	from, from.user, from.host, from.display - адрес и имя из From
+//	to, to.user, to.host                     - адрес из To
+//	ruri, ruri.user, ruri.host               - Request-URI
+//	call_id, source, transport               - Call-ID, адрес отправителя, транспорт
+//	hour, minute                             - время суток (числа)
+//	weekday                                  - день недели: mon, tue, ..., sun
+//	time                                     - время суток "15:04"
+//
+// Функции: header("Имя") возвращает значение заголовка или пустую строку,
+// has_header("Имя") проверяет наличие заголовка.
+//
+// Операторы по убыванию приоритета: !; == != < <= > >= matches contains
+// startsWith endsWith in; &&; ||. Строки сравниваются лексикографически,
+// поэтому time >= "09:00" работает как ожидается. Типы проверяются при
+// разборе выражения.
+
+// policyType - тип значения выражения
+type policyType int
+
+const (
+	policyString policyType = iota
+	policyInt
+	policyBool
+)
+
+// String возвращает название типа для сообщений об ошибках
+func (t policyType) String() string {
+	switch t {
+	case policyString:
+		return "string"
+	case policyInt:
+		return "int"
+	default:
+		return "bool"
+	}
+}
+
+// policyInput - атрибуты вызова, на которых вычисляется выражение
+type policyInput struct {
+	req *sip.Request
+	now time.Time
+}
+
+// policyExpr - узел разобранного выражения
+type policyExpr interface {
+	typ() policyType
+	eval(in *policyInput) any
+}
+
+// policyVariable описывает переменную выражения
+type policyVariable struct {
+	typ policyType
+	get func(in *policyInput) any
+}
+
+// policyWeekdays - имена дней недели для переменной weekday
+var policyWeekdays = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// policyVariables - переменные выражений
+var policyVariables = map[string]policyVariable{
+	"from":         {policyString, func(in *policyInput) any { return fromHeaderURI(in.req.From()).String() }},
+	"from.user":    {policyString, func(in *policyInput) any { return fromHeaderURI(in.req.From()).User }},
+	"from.host":    {policyString, func(in *policyInput) any { return fromHeaderURI(in.req.From()).Host }},
+	"from.display": {policyString, func(in *policyInput) any { return fromDisplay(in.req.From()) }},
+	"to":           {policyString, func(in *policyInput) any { return toHeaderURI(in.req.To()).String() }},
+	"to.user":      {policyString, func(in *policyInput) any { return toHeaderURI(in.req.To()).User }},
+	"to.host":      {policyString, func(in *policyInput) any { return toHeaderURI(in.req.To()).Host }},
+	"ruri":         {policyString, func(in *policyInput) any { return in.req.Recipient.String() }},
+	"ruri.user":    {policyString, func(in *policyInput) any { return in.req.Recipient.User }},
+	"ruri.host":    {policyString, func(in *policyInput) any { return in.req.Recipient.Host }},
+	"call_id":      {policyString, func(in *policyInput) any { return headerValue(in.req, "Call-ID") }},
+	"source":       {policyString, func(in *policyInput) any { return in.req.Source() }},
+	"transport":    {policyString, func(in *policyInput) any { return in.req.Transport() }},
+	"hour":         {policyInt, func(in *policyInput) any { return in.now.Hour() }},
+	"minute":       {policyInt, func(in *policyInput) any { return in.now.Minute() }},
+	"weekday":      {policyString, func(in *policyInput) any { return policyWeekdays[in.now.Weekday()] }},
+	"time":         {policyString, func(in *policyInput) any { return in.now.Format("15:04") }},
+}
+
+func fromHeaderURI(h *sip.FromHeader) *sip.Uri {
+	if h == nil {
+		return &sip.Uri{}
+	}
+	return &h.Address
+}
+
+func toHeaderURI(h *sip.ToHeader) *sip.Uri {
+	if h == nil {
+		return &sip.Uri{}
+	}
+	return &h.Address
+}
+
+func fromDisplay(h *sip.FromHeader) string {
+	if h == nil {
+		return ""
+	}
+	return h.DisplayName
+}
+
+// headerValue возвращает значение заголовка или пустую строку
+func headerValue(req *sip.Request, name string) string {
+	if h := req.GetHeader(name); h != nil {
+		return h.Value()
+	}
+	return ""
+}
+
+// Узлы выражения
+
+type literalExpr struct {
+	t     policyType
+	value any
+}
+
+func (e *literalExpr) typ() policyType       { return e.t }
+func (e *literalExpr) eval(*policyInput) any { return e.value }
+
+type variableExpr struct {
+	v policyVariable
+}
+
+func (e *variableExpr) typ() policyType          { return e.v.typ }
+func (e *variableExpr) eval(in *policyInput) any { return e.v.get(in) }
+
+type headerExpr struct {
+	name   string
+	result policyType // policyString для header(), policyBool для has_header()
+}
+
+func (e *headerExpr) typ() policyType { return e.result }
+
+func (e *headerExpr) eval(in *policyInput) any {
+	if e.result == policyBool {
+		return in.req.GetHeader(e.name) != nil
+	}
+	return headerValue(in.req, e.name)
+}
+
+type notExpr struct {
+	x policyExpr
+}
+
+func (e *notExpr) typ() policyType          { return policyBool }
+func (e *notExpr) eval(in *policyInput) any { return !e.x.eval(in).(bool) }
+
+type binaryExpr struct {
+	op   string
+	l, r policyExpr
+}
+
+func (e *binaryExpr) typ() policyType { return policyBool }
+
+func (e *binaryExpr) eval(in *policyInput) any {
+	switch e.op {
+	case "&&":
+		return e.l.eval(in).(bool) && e.r.eval(in).(bool)
+	case "||":
+		return e.l.eval(in).(bool) || e.r.eval(in).(bool)
+	}
+
+	l, r := e.l.eval(in), e.r.eval(in)
+	switch e.op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case "contains":
+		return strings.Contains(l.(string), r.(string))
+	case "startsWith":
+		return strings.HasPrefix(l.(string), r.(string))
+	case "endsWith":
+		return strings.HasSuffix(l.(string), r.(string))
+	}
+
+	var cmp int
+	if e.l.typ() == policyInt {
+		cmp = l.(int) - r.(int)
+	} else {
+		cmp = strings.Compare(l.(string), r.(string))
+	}
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+type inExpr struct {
+	x    policyExpr
+	list []any
+}
+
+func (e *inExpr) typ() policyType { return policyBool }
+
+func (e *inExpr) eval(in *policyInput) any {
+	value := e.x.eval(in)
+	for _, item := range e.list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+type matchExpr struct {
+	x  policyExpr
+	re *regexp.Regexp
+}
+
+func (e *matchExpr) typ() policyType          { return policyBool }
+func (e *matchExpr) eval(in *policyInput) any { return e.re.MatchString(e.x.eval(in).(string)) }
+
+// Лексический анализ
+
+type policyTokenKind int
+
+const (
+	tokenEOF policyTokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type policyToken struct {
+	kind  policyTokenKind
+	text  string
+	value any
+	pos   int
+}
+
+// policyOperators - операторы из символов, двухсимвольные проверяются первыми
+var policyOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ","}
+
+// policyComparisons - операторы сравнения
+var policyComparisons = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+func tokenizePolicy(src string) ([]policyToken, error) {
+	var tokens []policyToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			value, n, err := unquotePolicyString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("position %d: %w", i, err)
+			}
+			tokens = append(tokens, policyToken{kind: tokenString, text: src[i : i+n], value: value, pos: i})
+			i += n
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+				i++
+			}
+			number, err := strconv.Atoi(src[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("position %d: %w", start, err)
+			}
+			tokens = append(tokens, policyToken{kind: tokenNumber, text: src[start:i], value: number, pos: start})
+		case isPolicyIdentChar(c) && c != '.':
+			start := i
+			for i < len(src) && isPolicyIdentChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, policyToken{kind: tokenIdent, text: src[start:i], pos: start})
+		default:
+			op := ""
+			for _, candidate := range policyOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("position %d: unexpected character %q", i, c)
+			}
+			tokens = append(tokens, policyToken{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, policyToken{kind: tokenEOF, pos: len(src)}), nil
+}
+
+func isPolicyIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// unquotePolicyString разбирает строку в одинарных или двойных кавычках.
+// Обратная косая черта экранирует следующий символ
+func unquotePolicyString(src string) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			b.WriteByte(src[i])
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// Синтаксический анализ
+
+type policyParser struct {
+	tokens []policyToken
+	pos    int
+}
+
+// compilePolicyExpr разбирает выражение и проверяет, что оно логическое
+func compilePolicyExpr(src string) (policyExpr, error) {
+	tokens, err := tokenizePolicy(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &policyParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("position %d: unexpected %q", tok.pos, tok.text)
+	}
+	if expr.typ() != policyBool {
+		return nil, fmt.Errorf("expression must be bool, got %s", expr.typ())
+	}
+	return expr, nil
+}
+
+func (p *policyParser) peek() policyToken {
+	return p.tokens[p.pos]
+}
+
+func (p *policyParser) next() policyToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *policyParser) expect(op string) error {
+	if tok := p.next(); tok.kind != tokenOp || tok.text != op {
+		return fmt.Errorf("position %d: expected %q", tok.pos, op)
+	}
+	return nil
+}
+
+func (p *policyParser) parseOr() (policyExpr, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+func (p *policyParser) parseAnd() (policyExpr, error) {
+	return p.parseLogical("&&", p.parseUnary)
+}
+
+func (p *policyParser) parseLogical(op string, operand func() (policyExpr, error)) (policyExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.kind == tokenOp && tok.text == op; tok = p.peek() {
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != policyBool || right.typ() != policyBool {
+			return nil, fmt.Errorf("position %d: %s requires bool operands", tok.pos, op)
+		}
+		left = &binaryExpr{op: op, l: left, r: right}
+	}
+	return left, nil
+}
+
+func (p *policyParser) parseUnary() (policyExpr, error) {
+	if tok := p.peek(); tok.kind == tokenOp && tok.text == "!" {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != policyBool {
+			return nil, fmt.Errorf("position %d: ! requires bool operand", tok.pos)
+		}
+		return &notExpr{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *policyParser) parseComparison() (policyExpr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenOp && policyComparisons[tok.text]:
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != right.typ() {
+			return nil, fmt.Errorf("position %d: cannot compare %s with %s", tok.pos, left.typ(), right.typ())
+		}
+		if left.typ() == policyBool && tok.text != "==" && tok.text != "!=" {
+			return nil, fmt.Errorf("position %d: %s is not defined for bool", tok.pos, tok.text)
+		}
+		return &binaryExpr{op: tok.text, l: left, r: right}, nil
+
+	case tok.kind == tokenIdent && (tok.text == "contains" || tok.text == "startsWith" || tok.text == "endsWith"):
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if left.typ() != policyString || right.typ() != policyString {
+			return nil, fmt.Errorf("position %d: %s requires string operands", tok.pos, tok.text)
+		}
+		return &binaryExpr{op: tok.text, l: left, r: right}, nil
+
+	case tok.kind == tokenIdent && tok.text == "matches":
+		p.next()
+		pattern := p.next()
+		if pattern.kind != tokenString {
+			return nil, fmt.Errorf("position %d: matches requires a string literal pattern", pattern.pos)
+		}
+		if left.typ() != policyString {
+			return nil, fmt.Errorf("position %d: matches requires string operand", tok.pos)
+		}
+		re, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("position %d: %w", pattern.pos, err)
+		}
+		return &matchExpr{x: left, re: re}, nil
+
+	case tok.kind == tokenIdent && tok.text == "in":
+		p.next()
+		list, err := p.parseList(left.typ())
+		if err != nil {
+			return nil, err
+		}
+		return &inExpr{x: left, list: list}, nil
+	}
+
+	return left, nil
+}
+
+// parseList разбирает список литералов типа t: ["a", "b"]
+func (p *policyParser) parseList(t policyType) ([]any, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var list []any
+	for {
+		item, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		literal, ok := item.(*literalExpr)
+		if !ok || literal.t != t {
+			return nil, fmt.Errorf("position %d: list items must be %s literals", p.tokens[p.pos-1].pos, t)
+		}
+		list = append(list, literal.value)
+
+		tok := p.next()
+		if tok.kind == tokenOp && tok.text == "]" {
+			return list, nil
+		}
+		if tok.kind != tokenOp || tok.text != "," {
+			return nil, fmt.Errorf("position %d: expected \",\" or \"]\"", tok.pos)
+		}
+	}
+}
+
+func (p *policyParser) parsePrimary() (policyExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return &literalExpr{t: policyString, value: tok.value}, nil
+	case tokenNumber:
+		return &literalExpr{t: policyInt, value: tok.value}, nil
+	case tokenOp:
+		if tok.text != "(" {
+			break
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalExpr{t: policyBool, value: tok.text == "true"}, nil
+		case "header", "has_header":
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			name := p.next()
+			if name.kind != tokenString {
+				return nil, fmt.Errorf("position %d: %s requires a header name string", name.pos, tok.text)
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			result := policyString
+			if tok.text == "has_header" {
+				result = policyBool
+			}
+			return &headerExpr{name: name.value.(string), result: result}, nil
+		}
+		if v, ok := policyVariables[tok.text]; ok {
+			return &variableExpr{v: v}, nil
+		}
+		return nil, fmt.Errorf("position %d: unknown identifier %q", tok.pos, tok.text)
+	case tokenEOF:
+		return nil, fmt.Errorf("position %d: unexpected end of expression", tok.pos)
+	}
+	return nil, fmt.Errorf("position %d: unexpected %q", tok.pos, tok.text)
+}
@@ -0,0 +1,167 @@
+package dialog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// policyTime - среда, 14:30 UTC
+var policyTime = time.Date(2025, time.March, 12, 14, 30, 0, 0, time.UTC)
+
+func TestCompilePolicyExpr(t *testing.T) {
+	req := newTestRequest(sip.INVITE)
+	req.AppendHeader(sip.NewHeader("X-Priority", "high"))
+	in := &policyInput{req: req, now: policyTime}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`from.user == "alice"`, true},
+		{`to.user != "bob"`, false},
+		{`from.user == "alice" && ruri.user == "bob"`, true},
+		{`from.user == "eve" || header("X-Priority") == "high"`, true},
+		{`!has_header("X-Priority")`, false},
+		{`has_header("x-priority")`, true},
+		{`hour >= 9 && hour < 18`, true},
+		{`weekday in ["sat", "sun"]`, false},
+		{`time >= "14:00" && time < "15:00"`, true},
+		{`from matches "^sip:ali.*@127\\.0\\.0\\.1$"`, true},
+		{`from.host startsWith "127." && to.user endsWith "ob"`, true},
+		{`call_id contains "capabilities"`, true},
+		{`transport == "UDP"`, true},
+		{`(hour < 9 || hour > 17) && from.user == "alice"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := compilePolicyExpr(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.eval(in))
+		})
+	}
+
+	invalid := []string{
+		``,
+		`from.user`,
+		`unknown == "x"`,
+		`hour == "9"`,
+		`from.user < 10`,
+		`from matches "("`,
+		`header(from) == "x"`,
+		`from.user == "alice" &&`,
+		`from.user == "alice`,
+		`hour in [9, "10"]`,
+	}
+	for _, src := range invalid {
+		_, err := compilePolicyExpr(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func TestCallPolicyEvaluate(t *testing.T) {
+	policy, err := NewCallPolicy(PolicySet{
+		Timezone: "UTC",
+		Rules: []PolicyRule{
+			{Name: "anonymous", When: `from.user == "anonymous"`, Action: PolicyReject},
+			{Name: "after-hours", When: `hour < 9 || hour >= 18`, Action: PolicyForward, Target: "sip:voicemail@pbx.example.com"},
+			{Name: "vip", When: `header("X-Priority") == "high"`, Action: PolicyAccept, Profile: "hd-voice"},
+			{Name: "busy", When: `to.user == "bob"`, Action: PolicyReject, Status: sip.StatusBusyHere, Reason: "Busy"},
+		},
+	})
+	require.NoError(t, err)
+
+	req := newTestRequest(sip.INVITE)
+	decision := policy.Evaluate(req, policyTime)
+	assert.Equal(t, "busy", decision.Rule)
+	assert.Equal(t, PolicyReject, decision.Action)
+	assert.Equal(t, sip.StatusBusyHere, decision.Status)
+
+	req.AppendHeader(sip.NewHeader("X-Priority", "high"))
+	decision = policy.Evaluate(req, policyTime)
+	assert.Equal(t, "vip", decision.Rule)
+	assert.Equal(t, "hd-voice", decision.Profile)
+
+	decision = policy.Evaluate(req, policyTime.Add(5*time.Hour))
+	assert.Equal(t, PolicyForward, decision.Action)
+	assert.Equal(t, "voicemail", decision.Target.User)
+
+	req.From().Address.User = "anonymous"
+	decision = policy.Evaluate(req, policyTime)
+	assert.Equal(t, PolicyReject, decision.Action)
+	assert.Equal(t, sip.StatusForbidden, decision.Status, "Код отклонения по умолчанию")
+
+	// Ни одно правило не сработало
+	empty, err := NewCallPolicy(PolicySet{})
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDecision{Action: PolicyAccept}, empty.Evaluate(req, policyTime))
+}
+
+func TestCallPolicyReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+rules:
+  - name: reject-all
+    action: reject
+    status: 480
+`), 0o600))
+
+	policy, err := LoadCallPolicy(path)
+	require.NoError(t, err)
+	req := newTestRequest(sip.INVITE)
+	assert.Equal(t, PolicyReject, policy.Evaluate(req, policyTime).Action)
+
+	// Некорректные правила не заменяют действующие
+	invalid := []PolicySet{
+		{Rules: []PolicyRule{{Action: "drop"}}},
+		{Rules: []PolicyRule{{Action: PolicyForward}}},
+		{Rules: []PolicyRule{{Action: PolicyReject, Status: 200}}},
+		{Rules: []PolicyRule{{Action: PolicyAccept, When: `hour ==`}}},
+		{Timezone: "Mars/Olympus", Rules: []PolicyRule{{Action: PolicyAccept}}},
+	}
+	for _, set := range invalid {
+		assert.Error(t, policy.Load(set))
+	}
+	assert.Equal(t, "reject-all", policy.Evaluate(req, policyTime).Rule)
+
+	// JSON является подмножеством YAML
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"name": "open", "action": "accept"}]}`), 0o600))
+	require.NoError(t, policy.LoadFile(path))
+	assert.Equal(t, "open", policy.Evaluate(req, policyTime).Rule)
+}
+
+func TestCallPolicyHandleInvite(t *testing.T) {
+	policy, err := NewCallPolicy(PolicySet{Rules: []PolicyRule{
+		{When: `from.user == "spam"`, Action: PolicyReject, Status: sip.StatusGlobalDecline},
+		{When: `to.user == "bob"`, Action: PolicyForward, Target: "sip:carol@127.0.0.1"},
+	}})
+	require.NoError(t, err)
+	u, err := NewUACUAS(Config{TestMode: true, CallPolicy: policy, TransportConfigs: []TransportConfig{
+		{Type: TransportUDP, Host: "127.0.0.1", Port: 35970},
+	}})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	req := newTestRequest(sip.INVITE)
+	req.From().Address.User = "spam"
+	tx := newRecordingServerTX()
+	u.handleInvite(req, tx)
+	require.Len(t, tx.responses, 1)
+	assert.Equal(t, sip.StatusGlobalDecline, tx.responses[0].StatusCode)
+
+	req = newTestRequest(sip.INVITE)
+	tx = newRecordingServerTX()
+	u.handleInvite(req, tx)
+	require.Len(t, tx.responses, 1)
+	assert.Equal(t, sip.StatusMovedTemporarily, tx.responses[0].StatusCode)
+	contact := tx.responses[0].Contact()
+	require.NotNil(t, contact)
+	assert.Equal(t, "carol", contact.Address.User)
+	_, created := u.dialogs.GetWithTX(GetBranchID(req))
+	assert.False(t, created, "Диалог не создается")
+}
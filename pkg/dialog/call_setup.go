@@ -0,0 +1,214 @@
+package dialog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCallSetupBuckets - границы корзин гистограмм установления вызова, в секундах
+var DefaultCallSetupBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30}
+
+// CallSetupTimes - моменты установления исходящего вызова
+type CallSetupTimes struct {
+	InviteSent       time.Time // Отправка первого INVITE
+	FirstProvisional time.Time // Первый ответ 18x
+	Answered         time.Time // 2xx на INVITE
+}
+
+// PDD возвращает post-dial delay: время от отправки INVITE до первого 18x
+// или, если вызов ответил без 18x, до 2xx. false - вызов еще не получил
+// таких ответов.
+func (t CallSetupTimes) PDD() (time.Duration, bool) {
+	switch {
+	case t.InviteSent.IsZero():
+		return 0, false
+	case !t.FirstProvisional.IsZero():
+		return t.FirstProvisional.Sub(t.InviteSent), true
+	case !t.Answered.IsZero():
+		return t.Answered.Sub(t.InviteSent), true
+	}
+	return 0, false
+}
+
+// SetupTime возвращает время от отправки INVITE до 2xx
+func (t CallSetupTimes) SetupTime() (time.Duration, bool) {
+	if t.InviteSent.IsZero() || t.Answered.IsZero() {
+		return 0, false
+	}
+	return t.Answered.Sub(t.InviteSent), true
+}
+
+// SetupTimes возвращает моменты установления исходящего вызова. Для
+// входящего вызова все значения нулевые. Метод потокобезопасен.
+func (s *Dialog) SetupTimes() CallSetupTimes {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+	return s.setupTimes
+}
+
+// markInviteSent фиксирует отправку первого INVITE. Повторы INVITE после
+// 3xx и 488 не сдвигают начало отсчета.
+func (s *Dialog) markInviteSent(at time.Time) {
+	s.setupMu.Lock()
+	defer s.setupMu.Unlock()
+	if s.setupTimes.InviteSent.IsZero() {
+		s.setupTimes.InviteSent = at
+	}
+}
+
+// markSetupResponse фиксирует 18x или 2xx на исходящий INVITE и учитывает
+// PDD и время установления в CallSetupMetrics
+func (s *Dialog) markSetupResponse(statusCode int, at time.Time) {
+	answered := statusCode >= 200 && statusCode <= 299
+	if !answered && (statusCode < 180 || statusCode > 189) {
+		return
+	}
+
+	s.setupMu.Lock()
+	times := &s.setupTimes
+	if times.InviteSent.IsZero() || !times.Answered.IsZero() {
+		s.setupMu.Unlock()
+		return
+	}
+	_, hadPDD := times.PDD()
+	if answered {
+		times.Answered = at
+	} else if times.FirstProvisional.IsZero() {
+		times.FirstProvisional = at
+	}
+	snapshot := *times
+	s.setupMu.Unlock()
+
+	metrics := s.uu.CallSetupMetrics()
+	if metrics == nil {
+		return
+	}
+	if pdd, ok := snapshot.PDD(); ok && !hadPDD {
+		metrics.PDD.Observe(pdd)
+	}
+	if setup, ok := snapshot.SetupTime(); ok && answered {
+		metrics.Setup.Observe(setup)
+	}
+}
+
+// LatencyHistogram - гистограмма задержек с накопительными корзинами
+// в формате Prometheus
+type LatencyHistogram struct {
+	mu      sync.Mutex
+	buckets []float64 // Верхние границы корзин в секундах, по возрастанию
+	counts  []uint64  // Число наблюдений по корзинам, последняя - +Inf
+	count   uint64
+	sum     float64
+}
+
+// NewLatencyHistogram создает гистограмму с границами корзин в секундах.
+// Пустой buckets - DefaultCallSetupBuckets.
+func NewLatencyHistogram(buckets []float64) *LatencyHistogram {
+	if len(buckets) == 0 {
+		buckets = DefaultCallSetupBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &LatencyHistogram{
+		buckets: sorted,
+		counts:  make([]uint64, len(sorted)+1),
+	}
+}
+
+// Observe добавляет наблюдение
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	index := sort.SearchFloat64s(h.buckets, seconds)
+	h.counts[index]++
+	h.count++
+	h.sum += seconds
+}
+
+// HistogramSnapshot - состояние гистограммы
+type HistogramSnapshot struct {
+	Buckets []float64 // Верхние границы корзин в секундах
+	// Cumulative - число наблюдений не больше границы соответствующей корзины
+	Cumulative []uint64
+	Count      uint64
+	Sum        float64 // Сумма наблюдений в секундах
+}
+
+// Snapshot возвращает копию состояния гистограммы
+func (h *LatencyHistogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snapshot := HistogramSnapshot{
+		Buckets:    append([]float64(nil), h.buckets...),
+		Cumulative: make([]uint64, len(h.buckets)),
+		Count:      h.count,
+		Sum:        h.sum,
+	}
+	var total uint64
+	for i := range h.buckets {
+		total += h.counts[i]
+		snapshot.Cumulative[i] = total
+	}
+	return snapshot
+}
+
+// Mean возвращает среднее значение наблюдений
+func (s HistogramSnapshot) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return time.Duration(s.Sum / float64(s.Count) * float64(time.Second))
+}
+
+// writePrometheus записывает гистограмму в текстовом формате Prometheus
+func (s HistogramSnapshot) writePrometheus(w io.Writer, name, help string) error {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&out, "# TYPE %s histogram\n", name)
+	for i, bound := range s.Buckets {
+		fmt.Fprintf(&out, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), s.Cumulative[i])
+	}
+	fmt.Fprintf(&out, "%s_bucket{le=\"+Inf\"} %d\n", name, s.Count)
+	fmt.Fprintf(&out, "%s_sum %s\n", name, strconv.FormatFloat(s.Sum, 'f', -1, 64))
+	fmt.Fprintf(&out, "%s_count %d\n", name, s.Count)
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// CallSetupMetrics - гистограммы установления исходящих вызовов UACUAS:
+// PDD (до первого 18x) и время до ответа 2xx
+type CallSetupMetrics struct {
+	PDD   *LatencyHistogram
+	Setup *LatencyHistogram
+}
+
+// NewCallSetupMetrics создает гистограммы с границами корзин buckets (секунды)
+func NewCallSetupMetrics(buckets []float64) *CallSetupMetrics {
+	return &CallSetupMetrics{
+		PDD:   NewLatencyHistogram(buckets),
+		Setup: NewLatencyHistogram(buckets),
+	}
+}
+
+// WritePrometheus записывает гистограммы sip_call_pdd_seconds и
+// sip_call_setup_seconds в текстовом формате Prometheus. Подходит для
+// rtp.MetricsCollector.AddPrometheusSource.
+func (m *CallSetupMetrics) WritePrometheus(w io.Writer) error {
+	if err := m.PDD.Snapshot().writePrometheus(w, "sip_call_pdd_seconds",
+		"Post-dial delay from INVITE to first 18x or 2xx"); err != nil {
+		return err
+	}
+	return m.Setup.Snapshot().writePrometheus(w, "sip_call_setup_seconds",
+		"Call setup time from INVITE to 2xx")
+}
+
+// CallSetupMetrics возвращает гистограммы установления исходящих вызовов
+func (u *UACUAS) CallSetupMetrics() *CallSetupMetrics {
+	return u.setupMetrics
+}
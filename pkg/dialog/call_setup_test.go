@@ -0,0 +1,81 @@
+package dialog
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialogSetupTimes(t *testing.T) {
+	metrics := NewCallSetupMetrics([]float64{0.5, 1, 2})
+	d := &Dialog{uu: &UACUAS{setupMetrics: metrics}}
+	start := time.Now()
+
+	// Ответы до отправки INVITE не учитываются
+	d.markSetupResponse(180, start)
+	_, ok := d.SetupTimes().PDD()
+	assert.False(t, ok)
+
+	d.markInviteSent(start)
+	d.markSetupResponse(100, start.Add(100*time.Millisecond))
+	d.markSetupResponse(183, start.Add(800*time.Millisecond))
+	d.markSetupResponse(180, start.Add(900*time.Millisecond))
+	// Повтор INVITE после 3xx не сдвигает начало отсчета
+	d.markInviteSent(start.Add(time.Second))
+	d.markSetupResponse(200, start.Add(1500*time.Millisecond))
+	d.markSetupResponse(200, start.Add(3*time.Second))
+
+	times := d.SetupTimes()
+	pdd, ok := times.PDD()
+	require.True(t, ok)
+	assert.Equal(t, 800*time.Millisecond, pdd)
+	setup, ok := times.SetupTime()
+	require.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, setup)
+
+	pddSnapshot := metrics.PDD.Snapshot()
+	assert.EqualValues(t, 1, pddSnapshot.Count)
+	assert.Equal(t, []uint64{0, 1, 1}, pddSnapshot.Cumulative)
+	setupSnapshot := metrics.Setup.Snapshot()
+	assert.EqualValues(t, 1, setupSnapshot.Count)
+	assert.Equal(t, 1500*time.Millisecond, setupSnapshot.Mean())
+
+	// Ответ 2xx без 18x определяет и PDD
+	answered := &Dialog{uu: &UACUAS{setupMetrics: metrics}}
+	answered.markInviteSent(start)
+	answered.markSetupResponse(200, start.Add(3*time.Second))
+	pdd, ok = answered.SetupTimes().PDD()
+	require.True(t, ok)
+	assert.Equal(t, 3*time.Second, pdd)
+	assert.Equal(t, []uint64{0, 1, 1}, metrics.PDD.Snapshot().Cumulative, "3 с попадает только в +Inf")
+	assert.EqualValues(t, 2, metrics.PDD.Snapshot().Count)
+}
+
+func TestCallSetupMetricsPrometheus(t *testing.T) {
+	metrics := NewCallSetupMetrics(nil)
+	metrics.PDD.Observe(250 * time.Millisecond)
+	metrics.PDD.Observe(4 * time.Second)
+	metrics.Setup.Observe(45 * time.Second)
+
+	var out strings.Builder
+	require.NoError(t, metrics.WritePrometheus(&out))
+	text := out.String()
+
+	for _, line := range []string{
+		"# TYPE sip_call_pdd_seconds histogram",
+		`sip_call_pdd_seconds_bucket{le="0.1"} 0`,
+		`sip_call_pdd_seconds_bucket{le="0.25"} 1`,
+		`sip_call_pdd_seconds_bucket{le="5"} 2`,
+		`sip_call_pdd_seconds_bucket{le="+Inf"} 2`,
+		"sip_call_pdd_seconds_sum 4.25",
+		"sip_call_pdd_seconds_count 2",
+		`sip_call_setup_seconds_bucket{le="30"} 0`,
+		`sip_call_setup_seconds_bucket{le="+Inf"} 1`,
+		"sip_call_setup_seconds_count 1",
+	} {
+		assert.Contains(t, text, line+"\n")
+	}
+}
@@ -0,0 +1,58 @@
+package dialog
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCancelMatchesInviteBranch проверяет, что CANCEL без тега To находит
+// входящий вызов по ветке Via отменяемого INVITE (RFC 3261 Section 9.1)
+func TestCancelMatchesInviteBranch(t *testing.T) {
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15116}},
+	})
+	require.NoError(t, err)
+
+	incoming := make(chan IDialog, 1)
+	u.OnIncomingCall(func(d IDialog, tx IServerTX) { incoming <- d })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { _ = u.ListenTransports(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		_ = u.Stop()
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	alice := newRegistrarPeer(t, "alice")
+	server := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 15116}
+	target := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: 15116}
+
+	invite := alice.newRequest(sip.INVITE, alice.uri, target, alice.uri, "cancel-1", "z9hG4bK-cancel1")
+	alice.send(invite, server)
+	var d IDialog
+	select {
+	case d = <-incoming:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Входящий вызов не получен")
+	}
+
+	// CANCEL в другой ветке не относится к вызову
+	stray := alice.newRequest(sip.CANCEL, alice.uri, target, alice.uri, "cancel-1", "z9hG4bK-other")
+	alice.send(stray, server)
+	alice.read(matchResponse(sip.CANCEL, sip.StatusCallTransactionDoesNotExists))
+	assert.NotEqual(t, Ended, d.State())
+
+	cancelReq := alice.newRequest(sip.CANCEL, alice.uri, target, alice.uri, "cancel-1", "z9hG4bK-cancel1")
+	alice.send(cancelReq, server)
+	alice.read(matchResponse(sip.CANCEL, sip.StatusOK))
+	alice.read(matchResponse(sip.INVITE, sip.StatusRequestTerminated))
+	require.Eventually(t, func() bool { return d.State() == Ended }, 2*time.Second, 5*time.Millisecond)
+}
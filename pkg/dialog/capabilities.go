@@ -0,0 +1,192 @@
+package dialog
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// Feature определяет опциональную возможность SIP стека.
+// Набор включенных возможностей определяет содержимое заголовков Allow и Supported
+// и то, какие входящие запросы будут обработаны.
+type Feature string
+
+const (
+	// FeatureRefer - перевод вызова через REFER (RFC 3515)
+	FeatureRefer Feature = "refer"
+	// FeatureUpdate - обновление параметров сессии через UPDATE (RFC 3311)
+	FeatureUpdate Feature = "update"
+	// FeaturePrack - надежные предварительные ответы, метод PRACK и тег 100rel (RFC 3262)
+	FeaturePrack Feature = "prack"
+	// FeatureTimer - таймеры сессии, тег timer (RFC 4028)
+	FeatureTimer Feature = "timer"
+	// FeatureReplaces - заголовок Replaces для attended transfer (RFC 3891)
+	FeatureReplaces Feature = "replaces"
+	// FeatureSharedLine - подписки SUBSCRIBE на состояние общей линии и захват
+	// appearance (RFC 7463). Включается UACUAS.SetSharedLine
+	FeatureSharedLine Feature = "shared-line"
+)
+
+// featureMethods связывает возможности с методами, которые они разрешают
+var featureMethods = map[Feature]sip.RequestMethod{
+	FeatureRefer:      sip.REFER,
+	FeatureUpdate:     sip.UPDATE,
+	FeaturePrack:      sip.PRACK,
+	FeatureSharedLine: sip.SUBSCRIBE,
+}
+
+// featureOptionTags связывает возможности с option tag для заголовков Supported/Require
+var featureOptionTags = map[Feature]string{
+	FeaturePrack:    "100rel",
+	FeatureTimer:    "timer",
+	FeatureReplaces: "replaces",
+}
+
+// baseMethods - методы, которые обрабатываются всегда, независимо от набора возможностей
+var baseMethods = []sip.RequestMethod{
+	sip.INVITE, sip.ACK, sip.CANCEL, sip.BYE, sip.OPTIONS, sip.NOTIFY, sip.REGISTER, sip.INFO,
+}
+
+// DefaultFeatures возвращает набор возможностей, реализованных стеком по умолчанию
+func DefaultFeatures() []Feature {
+	return []Feature{FeatureRefer, FeatureUpdate, FeatureReplaces}
+}
+
+// Capabilities хранит набор включенных возможностей UACUAS.
+// Используется для формирования заголовков Allow и Supported и для проверки
+// входящих запросов (405 Method Not Allowed, 420 Bad Extension).
+//
+// Возможности можно включать и выключать во время работы. Методы потокобезопасны.
+type Capabilities struct {
+	mu       sync.RWMutex
+	features map[Feature]bool
+}
+
+// NewCapabilities создает набор возможностей из списка
+func NewCapabilities(features ...Feature) *Capabilities {
+	c := &Capabilities{features: make(map[Feature]bool)}
+	for _, f := range features {
+		c.features[f] = true
+	}
+	return c
+}
+
+// Enable включает возможность
+func (c *Capabilities) Enable(f Feature) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.features[f] = true
+}
+
+// Disable выключает возможность
+func (c *Capabilities) Disable(f Feature) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.features, f)
+}
+
+// Has проверяет, включена ли возможность
+func (c *Capabilities) Has(f Feature) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.features[f]
+}
+
+// Methods возвращает список разрешенных методов в порядке: базовые методы,
+// затем методы включенных возможностей в алфавитном порядке
+func (c *Capabilities) Methods() []sip.RequestMethod {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	methods := append([]sip.RequestMethod(nil), baseMethods...)
+
+	var extra []sip.RequestMethod
+	for f, method := range featureMethods {
+		if c.features[f] {
+			extra = append(extra, method)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i] < extra[j] })
+
+	return append(methods, extra...)
+}
+
+// OptionTags возвращает отсортированный список option tags включенных возможностей
+func (c *Capabilities) OptionTags() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var tags []string
+	for f, tag := range featureOptionTags {
+		if c.features[f] {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// MethodAllowed проверяет, разрешен ли метод текущим набором возможностей
+func (c *Capabilities) MethodAllowed(method sip.RequestMethod) bool {
+	for _, m := range c.Methods() {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Unsupported возвращает option tags из заголовков Require запроса,
+// которые не поддерживаются текущим набором возможностей
+func (c *Capabilities) Unsupported(req *sip.Request) []string {
+	supported := make(map[string]bool)
+	for _, tag := range c.OptionTags() {
+		supported[tag] = true
+	}
+
+	var unsupported []string
+	for _, header := range req.GetHeaders("Require") {
+		for _, tag := range strings.Split(header.Value(), ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" && !supported[tag] {
+				unsupported = append(unsupported, tag)
+			}
+		}
+	}
+	return unsupported
+}
+
+// AllowHeader создает заголовок Allow из разрешенных методов
+func (c *Capabilities) AllowHeader() sip.Header {
+	methods := c.Methods()
+	names := make([]string, len(methods))
+	for i, m := range methods {
+		names[i] = m.String()
+	}
+	return sip.NewHeader("Allow", strings.Join(names, ", "))
+}
+
+// SupportedHeader создает заголовок Supported из option tags включенных возможностей.
+// Возвращает nil, если ни одна возможность с option tag не включена.
+func (c *Capabilities) SupportedHeader() sip.Header {
+	tags := c.OptionTags()
+	if len(tags) == 0 {
+		return nil
+	}
+	return sip.NewHeader("Supported", strings.Join(tags, ", "))
+}
+
+// appendCapabilityHeaders добавляет в сообщение заголовки Allow и Supported,
+// если они не были установлены явно
+func (c *Capabilities) appendCapabilityHeaders(msg sip.Message) {
+	if len(msg.GetHeaders("Allow")) == 0 {
+		msg.AppendHeader(c.AllowHeader())
+	}
+	if len(msg.GetHeaders("Supported")) == 0 {
+		if supported := c.SupportedHeader(); supported != nil {
+			msg.AppendHeader(supported)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package dialog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingServerTX - серверная транзакция, которая запоминает отправленные ответы
+type recordingServerTX struct {
+	responses []*sip.Response
+	done      chan struct{}
+}
+
+func newRecordingServerTX() *recordingServerTX {
+	return &recordingServerTX{done: make(chan struct{})}
+}
+
+func (r *recordingServerTX) Terminate()                           {}
+func (r *recordingServerTX) OnTerminate(f sip.FnTxTerminate) bool { return true }
+func (r *recordingServerTX) Done() <-chan struct{}                { return r.done }
+func (r *recordingServerTX) Err() error                           { return nil }
+func (r *recordingServerTX) Acks() <-chan *sip.Request            { return nil }
+func (r *recordingServerTX) OnCancel(f sip.FnTxCancel) bool       { return true }
+func (r *recordingServerTX) Respond(res *sip.Response) error {
+	r.responses = append(r.responses, res)
+	return nil
+}
+
+func newTestRequest(method sip.RequestMethod) *sip.Request {
+	req := sip.NewRequest(method, sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1"})
+	req.AppendHeader(&sip.FromHeader{Address: sip.Uri{Scheme: "sip", User: "alice", Host: "127.0.0.1"},
+		Params: sip.NewParams().Add("tag", "from-tag")})
+	req.AppendHeader(&sip.ToHeader{Address: sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1"}, Params: sip.NewParams()})
+	callID := sip.CallIDHeader("capabilities-test")
+	req.AppendHeader(&callID)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: method})
+	req.AppendHeader(&sip.ViaHeader{ProtocolName: "SIP", ProtocolVersion: "2.0", Transport: "UDP",
+		Host: "127.0.0.1", Port: 5060, Params: sip.NewParams().Add("branch", sip.GenerateBranch())})
+	return req
+}
+
+func TestCapabilitiesHeaders(t *testing.T) {
+	caps := NewCapabilities(DefaultFeatures()...)
+
+	assert.Equal(t, "INVITE, ACK, CANCEL, BYE, OPTIONS, NOTIFY, REGISTER, INFO, REFER, UPDATE",
+		caps.AllowHeader().Value())
+	assert.Equal(t, "replaces", caps.SupportedHeader().Value())
+
+	caps.Enable(FeaturePrack)
+	caps.Enable(FeatureTimer)
+	caps.Disable(FeatureRefer)
+	assert.True(t, caps.MethodAllowed(sip.PRACK))
+	assert.False(t, caps.MethodAllowed(sip.REFER))
+	assert.Equal(t, "100rel, replaces, timer", caps.SupportedHeader().Value())
+
+	empty := NewCapabilities()
+	assert.Nil(t, empty.SupportedHeader(), "Без option tags заголовок Supported не нужен")
+	assert.True(t, empty.MethodAllowed(sip.INVITE), "Базовые методы разрешены всегда")
+}
+
+func TestCapabilitiesGating(t *testing.T) {
+	uacuas, err := NewUACUAS(Config{
+		UserAgent: "TestUA/1.0",
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 15090},
+		},
+		Features: []Feature{FeatureReplaces},
+	})
+	require.NoError(t, err)
+	defer uacuas.Stop()
+
+	t.Run("405 для выключенного метода", func(t *testing.T) {
+		tx := newRecordingServerTX()
+		handled := false
+		uacuas.withCapabilities(func(*sip.Request, sip.ServerTransaction) { handled = true })(newTestRequest(sip.UPDATE), tx)
+
+		assert.False(t, handled)
+		require.Len(t, tx.responses, 1)
+		assert.Equal(t, sip.StatusMethodNotAllowed, tx.responses[0].StatusCode)
+		allow := tx.responses[0].GetHeader("Allow")
+		require.NotNil(t, allow)
+		assert.NotContains(t, allow.Value(), "UPDATE")
+	})
+
+	t.Run("420 для неподдерживаемого расширения", func(t *testing.T) {
+		req := newTestRequest(sip.INVITE)
+		req.AppendHeader(sip.NewHeader("Require", "replaces, 100rel"))
+		tx := newRecordingServerTX()
+		handled := false
+		uacuas.withCapabilities(func(*sip.Request, sip.ServerTransaction) { handled = true })(req, tx)
+
+		assert.False(t, handled)
+		require.Len(t, tx.responses, 1)
+		assert.Equal(t, sip.StatusBadExtension, tx.responses[0].StatusCode)
+		unsupported := tx.responses[0].GetHeader("Unsupported")
+		require.NotNil(t, unsupported)
+		assert.Equal(t, "100rel", unsupported.Value())
+	})
+
+	t.Run("Включение возможности во время работы", func(t *testing.T) {
+		uacuas.Capabilities().Enable(FeatureUpdate)
+		defer uacuas.Capabilities().Disable(FeatureUpdate)
+
+		handled := false
+		uacuas.withCapabilities(func(*sip.Request, sip.ServerTransaction) { handled = true })(newTestRequest(sip.UPDATE), newRecordingServerTX())
+		assert.True(t, handled)
+	})
+
+	t.Run("OPTIONS возвращает возможности", func(t *testing.T) {
+		tx := newRecordingServerTX()
+		uacuas.handleOptions(newTestRequest(sip.OPTIONS), tx)
+
+		require.Len(t, tx.responses, 1)
+		require.NotNil(t, tx.responses[0].GetHeader("Allow"))
+		supported := tx.responses[0].GetHeader("Supported")
+		require.NotNil(t, supported)
+		assert.Equal(t, "replaces", supported.Value())
+	})
+}
+
+func TestInviteCarriesCapabilities(t *testing.T) {
+	uacuas, err := NewUACUAS(Config{
+		UserAgent: "TestUA/1.0",
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 15091},
+		},
+	})
+	require.NoError(t, err)
+	defer uacuas.Stop()
+
+	d, err := uacuas.NewDialog(context.Background())
+	require.NoError(t, err)
+	d.remoteTarget = sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1"}
+
+	invite := d.makeRequest(sip.INVITE)
+	require.NotNil(t, invite.GetHeader("Allow"))
+	assert.Contains(t, invite.GetHeader("Allow").Value(), "REFER")
+	require.NotNil(t, invite.GetHeader("Supported"))
+
+	bye := d.makeRequest(sip.BYE)
+	assert.Nil(t, bye.GetHeader("Allow"), "Allow добавляется только в INVITE")
+
+	// Явно указанный заголовок заменяет автоматический
+	WithAllow("INVITE", "ACK")(invite)
+	assert.Len(t, invite.GetHeaders("Allow"), 1)
+	assert.Equal(t, "INVITE, ACK", invite.GetHeader("Allow").Value())
+}
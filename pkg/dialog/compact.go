@@ -0,0 +1,120 @@
+package dialog
+
+import (
+	"io"
+	"strings"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// compactHeaderNames содержит компактные формы имен заголовков (RFC 3261 Section 7.3.3,
+// RFC 3515, RFC 3892, RFC 3265, RFC 3841). Ключ - имя заголовка в нижнем регистре.
+var compactHeaderNames = map[string]string{
+	"call-id":          "i",
+	"contact":          "m",
+	"content-encoding": "e",
+	"content-length":   "l",
+	"content-type":     "c",
+	"from":             "f",
+	"subject":          "s",
+	"supported":        "k",
+	"to":               "t",
+	"via":              "v",
+	"refer-to":         "r",
+	"referred-by":      "b",
+	"event":            "o",
+	"allow-events":     "u",
+	"accept-contact":   "a",
+}
+
+// fullHeaderNames содержит полные имена заголовков для компактных форм
+var fullHeaderNames = map[string]string{
+	"i": "Call-ID",
+	"m": "Contact",
+	"e": "Content-Encoding",
+	"l": "Content-Length",
+	"c": "Content-Type",
+	"f": "From",
+	"s": "Subject",
+	"k": "Supported",
+	"t": "To",
+	"v": "Via",
+	"r": "Refer-To",
+	"b": "Referred-By",
+	"o": "Event",
+	"u": "Allow-Events",
+	"a": "Accept-Contact",
+}
+
+// compactHeader сериализует заголовок в компактной форме.
+// Name() возвращает полное имя, поэтому поиск заголовков по имени и быстрые ссылки
+// sipgo (From(), Via() и т.д.) продолжают работать.
+type compactHeader struct {
+	sip.Header
+	compactName string
+}
+
+func (h *compactHeader) String() string {
+	var buffer strings.Builder
+	h.StringWrite(&buffer)
+	return buffer.String()
+}
+
+func (h *compactHeader) StringWrite(buffer io.StringWriter) {
+	buffer.WriteString(h.compactName)
+	buffer.WriteString(": ")
+	buffer.WriteString(h.Value())
+}
+
+// messageHeaders возвращает срез заголовков сообщения для изменения на месте
+func messageHeaders(msg sip.Message) []sip.Header {
+	switch m := msg.(type) {
+	case *sip.Request:
+		return m.Headers()
+	case *sip.Response:
+		return m.Headers()
+	default:
+		return nil
+	}
+}
+
+// compactMessageHeaders переводит заголовки сообщения в компактную форму.
+// Заголовки заменяются на месте, чтобы не нарушить быстрые ссылки sipgo.
+// Via, добавляемый транспортным уровнем при отправке, остается в полной форме.
+func compactMessageHeaders(msg sip.Message) {
+	headers := messageHeaders(msg)
+	for i, h := range headers {
+		if _, ok := h.(*compactHeader); ok {
+			continue
+		}
+		if compact, ok := compactHeaderNames[strings.ToLower(h.Name())]; ok {
+			headers[i] = &compactHeader{Header: h, compactName: compact}
+		}
+	}
+}
+
+// expandCompactHeaders заменяет заголовки в компактной форме, которые парсер sipgo
+// оставил нераспознанными (например k, s, o, r), на заголовки с полными именами.
+// Основные заголовки (f, t, v, i, m, l, c) sipgo разбирает самостоятельно.
+func expandCompactHeaders(msg sip.Message) {
+	headers := messageHeaders(msg)
+	for i, h := range headers {
+		name := h.Name()
+		if len(name) != 1 {
+			continue
+		}
+		if full, ok := fullHeaderNames[strings.ToLower(name)]; ok {
+			headers[i] = sip.NewHeader(full, h.Value())
+		}
+	}
+}
+
+// prepareOutgoing применяет к исходящему сообщению настройки сериализации UACUAS
+func (u *UACUAS) prepareOutgoing(msg sip.Message) {
+	if u != nil && u.config.BodyCompression != nil {
+		u.config.BodyCompression.compressBody(msg, u.compressionPeers.accepts(msg.Destination()))
+	}
+	if u != nil && u.config.CompactHeaders {
+		compactMessageHeaders(msg)
+	}
+}
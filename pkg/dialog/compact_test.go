@@ -0,0 +1,104 @@
+package dialog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandCompactHeaders(t *testing.T) {
+	raw := "INVITE sip:bob@127.0.0.1 SIP/2.0\r\n" +
+		"v: SIP/2.0/UDP 127.0.0.1:5070;branch=z9hG4bK-compact\r\n" +
+		"f: <sip:alice@127.0.0.1>;tag=abc\r\n" +
+		"t: <sip:bob@127.0.0.1>\r\n" +
+		"i: compact-call-id\r\n" +
+		"CSeq: 1 INVITE\r\n" +
+		"m: <sip:alice@127.0.0.1:5070>\r\n" +
+		"k: replaces, timer\r\n" +
+		"s: Проверка\r\n" +
+		"c: application/sdp\r\n" +
+		"l: 0\r\n" +
+		"\r\n"
+
+	msg, err := sip.ParseMessage([]byte(raw))
+	require.NoError(t, err)
+	req, ok := msg.(*sip.Request)
+	require.True(t, ok)
+
+	expandCompactHeaders(req)
+
+	// Основные заголовки sipgo разбирает сам
+	require.NotNil(t, req.From())
+	require.NotNil(t, req.To())
+	require.NotNil(t, req.CallID())
+	assert.Equal(t, "compact-call-id", req.CallID().Value())
+	require.NotNil(t, req.Contact())
+
+	// Остальные компактные формы разворачиваются в полные имена
+	supported := req.GetHeader("Supported")
+	require.NotNil(t, supported)
+	assert.Equal(t, "replaces, timer", supported.Value())
+	require.NotNil(t, req.GetHeader("Subject"))
+	assert.Nil(t, req.GetHeader("k"))
+}
+
+func TestCompactMessageHeaders(t *testing.T) {
+	req := newTestRequest(sip.INVITE)
+	req.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Scheme: "sip", User: "alice", Host: "127.0.0.1"}})
+	req.AppendHeader(sip.NewHeader("Supported", "replaces"))
+	contentType := sip.ContentTypeHeader("application/sdp")
+	req.AppendHeader(&contentType)
+	req.SetBody([]byte("v=0\r\n"))
+
+	fullSize := len(req.String())
+	compactMessageHeaders(req)
+	compacted := req.String()
+
+	assert.Less(t, len(compacted), fullSize, "Компактная форма должна быть короче")
+	for _, line := range []string{"\r\nf: ", "\r\nt: ", "\r\ni: ", "\r\nm: ", "\r\nk: replaces", "\r\nc: application/sdp"} {
+		assert.Contains(t, compacted, line)
+	}
+	assert.NotContains(t, compacted, "\r\nFrom: ")
+
+	// Быстрые ссылки и поиск по полному имени продолжают работать
+	require.NotNil(t, req.From())
+	require.NotNil(t, req.CallID())
+	require.NotNil(t, req.GetHeader("Supported"))
+
+	// Сериализованное сообщение разбирается обратно
+	parsed, err := sip.ParseMessage([]byte(compacted))
+	require.NoError(t, err)
+	expandCompactHeaders(parsed)
+	parsedReq := parsed.(*sip.Request)
+	assert.Equal(t, req.CallID().Value(), parsedReq.CallID().Value())
+	assert.Equal(t, "replaces", parsedReq.GetHeader("Supported").Value())
+
+	// Повторное применение не оборачивает заголовки дважды
+	compactMessageHeaders(req)
+	assert.Equal(t, compacted, req.String())
+	assert.False(t, strings.Contains(req.String(), "\r\nf: f: "))
+}
+
+func TestCompactHeadersOption(t *testing.T) {
+	uacuas, err := NewUACUAS(Config{
+		UserAgent:      "TestUA/1.0",
+		CompactHeaders: true,
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 15092},
+		},
+	})
+	require.NoError(t, err)
+	defer uacuas.Stop()
+
+	req := newTestRequest(sip.OPTIONS)
+	uacuas.prepareOutgoing(req)
+	assert.Contains(t, req.String(), "\r\nf: ")
+
+	var disabled *UACUAS
+	req = newTestRequest(sip.OPTIONS)
+	disabled.prepareOutgoing(req)
+	assert.Contains(t, req.String(), "\r\nFrom: ")
+}
@@ -0,0 +1,243 @@
+package dialog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	// ContentEncodingGzip - значение Content-Encoding для тел, сжатых gzip
+	ContentEncodingGzip = "gzip"
+
+	// DefaultCompressionMinSize - минимальный размер тела для сжатия по умолчанию.
+	// Короткие тела после gzip обычно не уменьшаются
+	DefaultCompressionMinSize = 256
+	// DefaultMaxDecompressedSize - ограничение размера распакованного тела по умолчанию
+	DefaultMaxDecompressedSize = 64 * 1024
+)
+
+// BodyCompressionConfig - сжатие тел SIP сообщений (SDP и др.) для каналов
+// с ограниченной пропускной способностью между собственными узлами.
+//
+// Сжатие применяется только к доверенным узлам из Peers: запросы объявляют
+// Accept-Encoding: gzip, исходящие тела сжимаются gzip с заголовком
+// Content-Encoding после того, как узел сам объявил Accept-Encoding: gzip,
+// а входящие сжатые тела от этих узлов распаковываются до передачи диалогу.
+// Запросы со сжатым телом от остальных узлов отклоняются ответом 415,
+// с телом, которое не удалось распаковать, - ответом 400.
+type BodyCompressionConfig struct {
+	// Peers - доверенные узлы в виде "host" (любой порт) или "host:port"
+	Peers []string
+	// MinSize - минимальный размер тела для сжатия (по умолчанию DefaultCompressionMinSize)
+	MinSize int
+	// MaxDecompressedSize - максимальный размер распакованного тела
+	// (по умолчанию DefaultMaxDecompressedSize). Защищает от gzip-бомб
+	MaxDecompressedSize int
+}
+
+// errUntrustedEncoding - сжатое тело от узла вне BodyCompressionConfig.Peers
+var errUntrustedEncoding = errors.New("compressed body from untrusted peer")
+
+// trusted проверяет, входит ли адрес host:port в список доверенных узлов
+func (c *BodyCompressionConfig) trusted(addr string) bool {
+	if addr == "" {
+		return false
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+	for _, peer := range c.Peers {
+		peerHost, peerPort, err := net.SplitHostPort(peer)
+		if err != nil {
+			peerHost, peerPort = peer, ""
+		}
+		if !strings.EqualFold(peerHost, host) {
+			continue
+		}
+		if peerPort == "" || peerPort == port {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *BodyCompressionConfig) minSize() int {
+	if c.MinSize > 0 {
+		return c.MinSize
+	}
+	return DefaultCompressionMinSize
+}
+
+func (c *BodyCompressionConfig) maxDecompressedSize() int {
+	if c.MaxDecompressedSize > 0 {
+		return c.MaxDecompressedSize
+	}
+	return DefaultMaxDecompressedSize
+}
+
+// hasContentEncoding проверяет, указано ли кодирование encoding в заголовках
+// Content-Encoding сообщения
+func hasContentEncoding(msg sip.Message, encoding string) bool {
+	return hasEncoding(msg, "Content-Encoding", encoding)
+}
+
+// hasEncoding проверяет, указано ли кодирование encoding в заголовках name
+func hasEncoding(msg sip.Message, name, encoding string) bool {
+	for _, h := range msg.GetHeaders(name) {
+		for _, token := range strings.Split(h.Value(), ",") {
+			if strings.EqualFold(strings.TrimSpace(token), encoding) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compressionPeers - узлы (host), объявившие Accept-Encoding: gzip
+type compressionPeers struct {
+	mu    sync.RWMutex
+	hosts map[string]struct{}
+}
+
+func (p *compressionPeers) accepts(addr string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.hosts[addrHost(addr)]
+	return ok
+}
+
+func (p *compressionPeers) add(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hosts == nil {
+		p.hosts = make(map[string]struct{})
+	}
+	p.hosts[addrHost(addr)] = struct{}{}
+}
+
+// addrHost возвращает host адреса host:port. Узел отправляет запросы по TCP
+// с другого порта, чем принимает, поэтому поддержка gzip запоминается по host
+func addrHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(addr)
+}
+
+// compressBody сжимает тело исходящего сообщения для доверенного узла.
+// peerAccepts - узел объявил Accept-Encoding: gzip, без этого тело
+// отправляется несжатым (RFC 3261 Section 20.2)
+func (c *BodyCompressionConfig) compressBody(msg sip.Message, peerAccepts bool) {
+	if !c.trusted(msg.Destination()) {
+		return
+	}
+	// Объявляем узлу, что принимаем сжатые тела
+	if req, ok := msg.(*sip.Request); ok && len(req.GetHeaders("Accept-Encoding")) == 0 {
+		req.AppendHeader(sip.NewHeader("Accept-Encoding", ContentEncodingGzip))
+	}
+
+	body := msg.Body()
+	if !peerAccepts || len(body) < c.minSize() || len(msg.GetHeaders("Content-Encoding")) > 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return
+	}
+	if err := zw.Close(); err != nil {
+		return
+	}
+	if buf.Len() >= len(body) {
+		return
+	}
+
+	msg.SetBody(buf.Bytes())
+	msg.AppendHeader(sip.NewHeader("Content-Encoding", ContentEncodingGzip))
+}
+
+// decompressBody распаковывает сжатое тело входящего сообщения от доверенного узла
+func (c *BodyCompressionConfig) decompressBody(msg sip.Message) error {
+	if !hasContentEncoding(msg, ContentEncodingGzip) {
+		return nil
+	}
+	if !c.trusted(msg.Source()) {
+		return fmt.Errorf("%w %s", errUntrustedEncoding, msg.Source())
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(msg.Body()))
+	if err != nil {
+		return fmt.Errorf("invalid gzip body: %w", err)
+	}
+	limit := c.maxDecompressedSize()
+	body, err := io.ReadAll(io.LimitReader(zr, int64(limit)+1))
+	if err != nil {
+		return fmt.Errorf("invalid gzip body: %w", err)
+	}
+	if len(body) > limit {
+		return fmt.Errorf("decompressed body exceeds %d bytes", limit)
+	}
+
+	if m, ok := msg.(interface{ RemoveHeader(name string) bool }); ok {
+		m.RemoveHeader("Content-Encoding")
+	}
+	msg.SetBody(body)
+	return nil
+}
+
+// prepareIncoming приводит входящее сообщение к виду, ожидаемому диалогом:
+// раскрывает компактные заголовки, запоминает узлы, принимающие gzip, и
+// распаковывает сжатое тело. При ошибке распаковки сообщение не должно
+// обрабатываться дальше
+func (u *UACUAS) prepareIncoming(msg sip.Message) error {
+	expandCompactHeaders(msg)
+	if u == nil || u.config.BodyCompression == nil {
+		return nil
+	}
+	cfg := u.config.BodyCompression
+	if cfg.trusted(msg.Source()) && hasEncoding(msg, "Accept-Encoding", ContentEncodingGzip) {
+		u.compressionPeers.add(msg.Source())
+	}
+	if err := cfg.decompressBody(msg); err != nil {
+		slog.Warn("Не удалось распаковать тело SIP сообщения",
+			slog.Any("error", err),
+			slog.String("source", msg.Source()))
+		return err
+	}
+	return nil
+}
+
+// rejectUndecodableBody отвечает на запрос, тело которого не удалось
+// распаковать: 415 с Accept-Encoding, если кодирование от узла не
+// принимается, иначе 400 (RFC 3261 Section 21.4.13)
+func (u *UACUAS) rejectUndecodableBody(req *sip.Request, tx sip.ServerTransaction, err error) {
+	// На ACK ответ не отправляется
+	if req.Method == sip.ACK || tx == nil {
+		return
+	}
+
+	var resp *sip.Response
+	if errors.Is(err, errUntrustedEncoding) {
+		resp = sip.NewResponseFromRequest(req, sip.StatusUnsupportedMediaType, "Unsupported Media Type", nil)
+		resp.AppendHeader(sip.NewHeader("Accept-Encoding", "identity"))
+	} else {
+		resp = sip.NewResponseFromRequest(req, sip.StatusBadRequest, "Bad Request", nil)
+	}
+	if err := tx.Respond(resp); err != nil {
+		slog.Error("Ошибка отправки ответа на сжатое тело",
+			slog.Any("error", err),
+			slog.Int("status", resp.StatusCode),
+			slog.String("Method", req.Method.String()))
+	}
+}
@@ -0,0 +1,196 @@
+package dialog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBodyRequest(body []byte) *sip.Request {
+	req := newTestRequest(sip.INVITE)
+	req.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+	req.SetBody(body)
+	return req
+}
+
+func testSDPBody() []byte {
+	return []byte("v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=-\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\n" +
+		"m=audio 10000 RTP/AVP 0 8 101\r\n" + strings.Repeat("a=rtpmap:0 PCMU/8000\r\n", 20))
+}
+
+func TestBodyCompressionTrustedPeers(t *testing.T) {
+	cfg := &BodyCompressionConfig{Peers: []string{"10.0.0.1", "10.0.0.2:5070"}}
+
+	assert.True(t, cfg.trusted("10.0.0.1:5060"))
+	assert.True(t, cfg.trusted("10.0.0.2:5070"))
+	assert.False(t, cfg.trusted("10.0.0.2:5060"))
+	assert.False(t, cfg.trusted("127.0.0.1:5060"))
+	assert.False(t, cfg.trusted(""))
+}
+
+func TestBodyCompressionRoundTrip(t *testing.T) {
+	cfg := &BodyCompressionConfig{Peers: []string{"127.0.0.1"}}
+	sdp := testSDPBody()
+
+	req := newBodyRequest(sdp)
+	cfg.compressBody(req, true)
+	assert.True(t, hasContentEncoding(req, ContentEncodingGzip))
+	assert.Equal(t, "gzip", req.GetHeader("Accept-Encoding").Value())
+	assert.Less(t, len(req.Body()), len(sdp))
+	assert.Equal(t, len(req.Body()), int(*req.ContentLength()))
+
+	// Разбор сжатого сообщения, как при приеме из сети
+	parsed, err := sip.ParseMessage([]byte(req.String()))
+	require.NoError(t, err)
+	parsed.SetSource("127.0.0.1:5060")
+	require.NoError(t, cfg.decompressBody(parsed))
+	assert.Equal(t, sdp, parsed.Body())
+	assert.Empty(t, parsed.GetHeaders("Content-Encoding"))
+	assert.Equal(t, len(sdp), int(*parsed.ContentLength()))
+}
+
+func TestBodyCompressionSkipped(t *testing.T) {
+	cfg := &BodyCompressionConfig{Peers: []string{"10.0.0.1"}}
+
+	// Недоверенный узел
+	req := newBodyRequest(testSDPBody())
+	cfg.compressBody(req, true)
+	assert.Empty(t, req.GetHeaders("Content-Encoding"))
+	assert.Empty(t, req.GetHeaders("Accept-Encoding"))
+
+	// Короткое тело
+	cfg.Peers = []string{"127.0.0.1"}
+	req = newBodyRequest([]byte("v=0\r\n"))
+	cfg.compressBody(req, true)
+	assert.Empty(t, req.GetHeaders("Content-Encoding"))
+	assert.Equal(t, "v=0\r\n", string(req.Body()))
+
+	// Узел не объявил Accept-Encoding: gzip - тело не сжимается,
+	// но запрос объявляет поддержку gzip
+	sdp := testSDPBody()
+	req = newBodyRequest(sdp)
+	cfg.compressBody(req, false)
+	assert.Empty(t, req.GetHeaders("Content-Encoding"))
+	assert.Equal(t, sdp, req.Body())
+	assert.Equal(t, "gzip", req.GetHeader("Accept-Encoding").Value())
+
+	// Сжатое тело от недоверенного узла не распаковывается
+	req = newBodyRequest(testSDPBody())
+	cfg.compressBody(req, true)
+	compressed := req.Body()
+	req.SetSource("10.0.0.9:5060")
+	assert.ErrorIs(t, cfg.decompressBody(req), errUntrustedEncoding)
+	assert.Equal(t, compressed, req.Body())
+}
+
+func TestBodyCompressionLimit(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(make([]byte, 4096))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	req := newBodyRequest(buf.Bytes())
+	req.AppendHeader(sip.NewHeader("Content-Encoding", ContentEncodingGzip))
+	req.SetSource("127.0.0.1:5060")
+
+	cfg := &BodyCompressionConfig{Peers: []string{"127.0.0.1"}, MaxDecompressedSize: 1024}
+	assert.Error(t, cfg.decompressBody(req))
+	assert.True(t, hasContentEncoding(req, ContentEncodingGzip))
+}
+
+func TestBodyCompressionOption(t *testing.T) {
+	uacuas, err := NewUACUAS(Config{
+		CompactHeaders:  true,
+		BodyCompression: &BodyCompressionConfig{Peers: []string{"127.0.0.1"}},
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 15094},
+		},
+	})
+	require.NoError(t, err)
+	defer uacuas.Stop()
+
+	sdp := testSDPBody()
+	req := newBodyRequest(sdp)
+	uacuas.prepareOutgoing(req)
+	assert.NotContains(t, req.String(), "\r\ne: gzip\r\n", "Узел еще не объявил Accept-Encoding: gzip")
+
+	// Запрос узла с Accept-Encoding: gzip разрешает сжатие
+	offer := newTestRequest(sip.OPTIONS)
+	offer.AppendHeader(sip.NewHeader("Accept-Encoding", "gzip"))
+	offer.SetSource("127.0.0.1:40000")
+	require.NoError(t, uacuas.prepareIncoming(offer))
+
+	req = newBodyRequest(sdp)
+	uacuas.prepareOutgoing(req)
+	assert.Contains(t, req.String(), "\r\ne: gzip\r\n")
+
+	parsed, err := sip.ParseMessage([]byte(req.String()))
+	require.NoError(t, err)
+	parsed.SetSource("127.0.0.1:5060")
+	require.NoError(t, uacuas.prepareIncoming(parsed))
+	assert.Equal(t, sdp, parsed.Body())
+}
+
+// TestBodyCompressionReject проверяет, что запрос со сжатым телом, которое не
+// удалось распаковать, отклоняется и не передается обработчику
+func TestBodyCompressionReject(t *testing.T) {
+	uacuas, err := NewUACUAS(Config{
+		BodyCompression: &BodyCompressionConfig{Peers: []string{"127.0.0.1"}},
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 15094},
+		},
+	})
+	require.NoError(t, err)
+	defer uacuas.Stop()
+
+	gzipRequest := func(body []byte, source string) *sip.Request {
+		req := newBodyRequest(body)
+		req.AppendHeader(sip.NewHeader("Content-Encoding", ContentEncodingGzip))
+		req.SetSource(source)
+		return req
+	}
+
+	t.Run("400 для поврежденного тела", func(t *testing.T) {
+		tx := newRecordingServerTX()
+		handled := false
+		uacuas.withCapabilities(func(*sip.Request, sip.ServerTransaction) { handled = true })(
+			gzipRequest([]byte("not gzip"), "127.0.0.1:5060"), tx)
+
+		assert.False(t, handled)
+		require.Len(t, tx.responses, 1)
+		assert.Equal(t, sip.StatusBadRequest, tx.responses[0].StatusCode)
+	})
+
+	t.Run("415 для недоверенного узла", func(t *testing.T) {
+		req := newBodyRequest(testSDPBody())
+		(&BodyCompressionConfig{Peers: []string{"127.0.0.1"}}).compressBody(req, true)
+		req.SetSource("10.0.0.9:5060")
+		tx := newRecordingServerTX()
+		handled := false
+		uacuas.withCapabilities(func(*sip.Request, sip.ServerTransaction) { handled = true })(req, tx)
+
+		assert.False(t, handled)
+		require.Len(t, tx.responses, 1)
+		assert.Equal(t, sip.StatusUnsupportedMediaType, tx.responses[0].StatusCode)
+		accept := tx.responses[0].GetHeader("Accept-Encoding")
+		require.NotNil(t, accept)
+		assert.Equal(t, "identity", accept.Value())
+	})
+
+	t.Run("ACK без ответа", func(t *testing.T) {
+		req := gzipRequest([]byte("not gzip"), "127.0.0.1:5060")
+		req.Method = sip.ACK
+		tx := newRecordingServerTX()
+		handled := false
+		uacuas.withCapabilities(func(*sip.Request, sip.ServerTransaction) { handled = true })(req, tx)
+
+		assert.False(t, handled)
+		assert.Empty(t, tx.responses)
+	})
+}
@@ -0,0 +1,100 @@
+package dialog
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withMethod меняет метод запроса SendRequest. CSeq с этим методом
+// добавляется при отправке
+func withMethod(method sip.RequestMethod) RequestOpt {
+	return func(msg sip.Message) {
+		msg.(*sip.Request).Method = method
+	}
+}
+
+// TestConcurrentCSeqOrdering отправляет запросы разных методов из многих
+// горутин и проверяет, что на проводе CSeq строго возрастает
+func TestConcurrentCSeqOrdering(t *testing.T) {
+	remote, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer remote.Close()
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15097}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	d.remoteTarget = sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: remote.LocalAddr().(*net.UDPAddr).Port}
+	initial := d.LocalSeq()
+
+	const total = 40
+	methods := []sip.RequestMethod{sip.INFO, sip.UPDATE, sip.INVITE, sip.BYE}
+
+	// Ошибки отправки собираются в канал: assert из горутин после выхода
+	// из теста приводит к панике. Перед выходом горутины завершаются
+	errs := make(chan error, total)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(method sip.RequestMethod) {
+			defer wg.Done()
+			_, err := d.SendRequest(ctx, withMethod(method))
+			errs <- err
+		}(methods[i%len(methods)])
+	}
+
+	var received []uint32
+	seen := make(map[uint32]bool)
+	buf := make([]byte, 4096)
+	require.NoError(t, remote.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for len(received) < total {
+		n, from, err := remote.ReadFromUDP(buf)
+		require.NoError(t, err, "получено %d из %d запросов", len(received), total)
+		msg, err := sip.ParseMessage(buf[:n])
+		require.NoError(t, err)
+		req, ok := msg.(*sip.Request)
+		if !ok || seen[req.CSeq().SeqNo] {
+			continue // ретрансмиссия
+		}
+		seen[req.CSeq().SeqNo] = true
+		if isSerializedMethod(req.Method) {
+			// INVITE и UPDATE ждут в очереди диалога финального ответа на предыдущий
+			resp := sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil)
+			_, err = remote.WriteToUDP([]byte(resp.String()), from)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, req.Method, req.CSeq().MethodName)
+		received = append(received, req.CSeq().SeqNo)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	for i, seq := range received {
+		assert.Equal(t, initial+uint32(i)+1, seq, "CSeq на проводе должен возрастать без пропусков")
+	}
+	assert.Equal(t, initial+total, d.LocalSeq())
+}
@@ -53,12 +53,12 @@ package dialog
 import (
 	"context"
 	"fmt"
+	"github.com/arzzra/soft_phone/pkg/random"
 	"github.com/emiago/sipgo"
 	"github.com/emiago/sipgo/sip"
 	"github.com/looplab/fsm"
 	"github.com/pkg/errors"
 	"log/slog"
-	"math/rand"
 	"net"
 	"strings"
 	"sync"
@@ -121,6 +121,7 @@ type StateTransitionReason struct {
 	StatusCode   int               // Код ответа (если применимо)
 	StatusReason string            // Фраза причины ответа
 	Details      string            // Дополнительный контекст
+	Cause        *ReleaseCause     // Причина завершения для переходов в Terminating и Ended
 }
 
 // Dialog представляет SIP диалог между двумя user agents.
@@ -162,6 +163,11 @@ type Dialog struct {
 	remoteCSeq atomic.Uint32
 	//Local
 	localCSeq atomic.Uint32
+	// cseqMu удерживается от назначения локального CSeq до передачи запроса
+	// транспорту, чтобы запросы из разных горутин уходили в порядке CSeq
+	cseqMu sync.Mutex
+	// requests - очередь re-INVITE, UPDATE и REFER
+	requests requestQueue
 
 	callID sip.CallIDHeader
 
@@ -171,6 +177,9 @@ type Dialog struct {
 	from *sip.FromHeader
 	to   *sip.ToHeader
 
+	// uriMu защищает идентификацию диалога: id, remoteTag, адреса, target,
+	// route set и тела SDP, которые меняются ответами и запросами из других
+	// горутин
 	uriMu        sync.Mutex
 	remoteTarget sip.Uri
 	localTarget  sip.Uri
@@ -191,15 +200,65 @@ type Dialog struct {
 	activityMu   sync.Mutex
 
 	// Обработчики событий
-	stateChangeHandler func(DialogState)
-	bodyHandler        func(*Body)
-	requestHandler     func(IServerTX)
-	terminateHandler   func()
-	handlersMu         sync.Mutex
-
-	// Нужно хранить первую транзакцию
+	stateChangeHandler   func(DialogState)
+	bodyHandler          func(*Body)
+	requestHandler       func(IServerTX)
+	terminateHandler     func()
+	releaseHandler       func(ReleaseCause)
+	forkedHandler        func([]EarlyDialog)
+	redirectHandler      func(*Redirect)
+	notAcceptableHandler func(*NotAcceptable)
+	targetHandler        func(previous, current sip.Uri)
+	callLimitHandler     func(CallLimitEvent)
+	handlersMu           sync.Mutex
+
+	// Нужно хранить первую транзакцию. Заменяется при повторе INVITE
+	// (488, 3xx), поэтому читается и пишется под uriMu
 	firstTX *TX
 
+	// Ветки разветвленного исходящего INVITE
+	forks forkTracker
+
+	// Ответы 3xx на исходящий INVITE
+	redirects  []*Redirect
+	redirectMu sync.Mutex
+
+	// Количество повторов INVITE с новым offer по ответам 488
+	reoffers atomic.Int32
+
+	// Лимит длительности вызова
+	callLimit callLimiter
+
+	// Диалог, замененный этим вызовом (входящий INVITE с Replaces)
+	replaces *Dialog
+
+	// Решение политики входящих вызовов (Config.CallPolicy)
+	policyDecision *PolicyDecision
+	// Результат проверки Identity входящего вызова (Config.Identity)
+	identity *IdentityVerification
+	// Результат проверки медиа пути перед ответом (Config.MediaProbe)
+	mediaProbe atomic.Pointer[MediaProbeResult]
+
+	// Слот парковки вызова
+	parkLot  *ParkLot
+	parkSlot string
+	parkMu   sync.Mutex
+
+	// Appearance общей линии вызова
+	line      *SharedLine
+	lineIndex int
+	lineMu    sync.Mutex
+
+	// Причина завершения вызова
+	releaseCause *ReleaseCause
+	releaseMu    sync.Mutex
+
+	// Моменты установления исходящего вызова (PDD)
+	setupTimes CallSetupTimes
+	setupMu    sync.Mutex
+	// Таймеры ожидания 18x и финального ответа на исходящий INVITE
+	setupTimer setupTimer
+
 	// Транзакция re-INVITE для обновления параметров сессии
 	reInviteTX *TX
 	reInviteMu sync.Mutex
@@ -207,17 +266,26 @@ type Dialog struct {
 	// История переходов состояний
 	transitionHistory []StateTransitionReason
 	transitionMu      sync.RWMutex
+
+	// Закрывается при переходе в Ended (WaitEnded)
+	ended      chan struct{}
+	endedOnce  sync.Once
+	endedClose sync.Once
 }
 
 // ID возвращает уникальный идентификатор диалога.
 // Формат: "callID:localTag:remoteTag" или "callID:localTag:pending" если remoteTag еще не установлен.
 func (s *Dialog) ID() string {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
 	return s.id
 }
 
 // SetID устанавливает новый идентификатор диалога.
 // Используется менеджером диалогов при необходимости обновления ID.
 func (s *Dialog) SetID(newID string) {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
 	s.id = newID
 }
 
@@ -239,9 +307,19 @@ func (s *Dialog) LocalTag() string {
 // RemoteTag возвращает удаленный тег диалога.
 // Устанавливается из ответа удаленной стороны.
 func (s *Dialog) RemoteTag() string {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
 	return s.remoteTag
 }
 
+// setRemoteTag сохраняет удаленный тег и обновляет ID диалога
+func (s *Dialog) setRemoteTag(tag string) {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
+	s.remoteTag = tag
+	s.updateDialogID()
+}
+
 // CallID возвращает заголовок Call-ID диалога.
 // Call-ID уникально идентифицирует SIP диалог вместе с тегами.
 func (s *Dialog) CallID() sip.CallIDHeader {
@@ -285,6 +363,8 @@ func (s *Dialog) RemoteTarget() sip.Uri {
 // RouteSet возвращает набор Route заголовков для маршрутизации.
 // Устанавливается из Record-Route заголовков при установлении диалога.
 func (s *Dialog) RouteSet() []sip.RouteHeader {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
 	return s.routeHeaders
 }
 
@@ -313,12 +393,12 @@ func (s *Dialog) Terminate() error {
 
 	// Логируем вызов
 	slog.Debug("Dialog.Terminate",
-		slog.String("dialogID", s.id),
+		slog.String("dialogID", s.ID()),
 		slog.String("state", s.State().String()),
 		slog.String("callID", string(s.callID)))
 
 	// Используем общий метод sendBye для отправки BYE запроса
-	tx, err := s.sendBye(ctx)
+	tx, err := s.sendBye(ctx, nil)
 	if err != nil {
 		slog.Debug("Dialog.Terminate failed", slog.String("error", err.Error()))
 		return err
@@ -331,6 +411,23 @@ func (s *Dialog) Terminate() error {
 	return nil
 }
 
+// TerminateWithCause завершает диалог так же, как Terminate, передавая причину
+// завершения удаленной стороне в заголовке Reason (RFC 3326).
+// Например, при пропадании медиа: d.TerminateWithCause(MediaTimeoutCause()).
+func (s *Dialog) TerminateWithCause(cause ReleaseCause) error {
+	ctx := context.Background()
+	if s.ctx != nil {
+		ctx = s.ctx
+	}
+
+	slog.Debug("Dialog.TerminateWithCause",
+		slog.String("dialogID", s.ID()),
+		slog.String("cause", cause.String()))
+
+	_, err := s.sendBye(ctx, &cause)
+	return err
+}
+
 // Start начинает новый диалог, отправляя INVITE запрос.
 // target - SIP URI вызываемого абонента (например, "sip:alice@example.com").
 // opts - дополнительные опции для настройки запроса.
@@ -339,8 +436,8 @@ func (s *Dialog) Terminate() error {
 func (s *Dialog) Start(ctx context.Context, target string, opts ...RequestOpt) (IClientTX, error) {
 	// Отправляем INVITE запрос для начала диалога
 	slog.Debug("Dialog.Start",
-		slog.String("dialogID", s.id),
-		slog.String("target", target),
+		slog.String("dialogID", s.ID()),
+		slog.String("target", s.uu.redact(target)),
 		slog.String("state", s.State().String()))
 
 	if s.State() != IDLE {
@@ -354,7 +451,7 @@ func (s *Dialog) Start(ctx context.Context, target string, opts ...RequestOpt) (
 	err := sip.ParseUri(target, &targetURI)
 	if err != nil {
 		slog.Debug("Dialog.Start parse URI failed",
-			slog.String("target", target),
+			slog.String("target", s.uu.redact(target)),
 			slog.String("error", err.Error()))
 		return nil, errors.Wrap(err, "failed to parse target URI")
 	}
@@ -373,8 +470,16 @@ func (s *Dialog) Start(ctx context.Context, target string, opts ...RequestOpt) (
 		opt(req)
 	}
 
+	// Подписываем INVITE (STIR/SHAKEN) после опций, задающих From и To
+	if err := s.uu.signIdentity(req); err != nil {
+		return nil, errors.Wrap(err, "failed to sign INVITE identity")
+	}
+
 	slog.Debug("Dialog.Start creating INVITE",
-		slog.String("request", req.String()))
+		slog.String("request", s.uu.redact(req.String())))
+
+	// Адрес и транспорт первого контакта (Config.ParallelContact)
+	s.uu.selectContactPath(ctx, req)
 
 	// Переводим диалог в состояние вызова
 	reason := StateTransitionReason{
@@ -389,6 +494,7 @@ func (s *Dialog) Start(ctx context.Context, target string, opts ...RequestOpt) (
 	}
 
 	// Отправляем запрос
+	sentAt := time.Now()
 	tx, err := s.sendReq(ctx, req)
 	if err != nil {
 		slog.Debug("Dialog.Start sendReq failed",
@@ -400,6 +506,8 @@ func (s *Dialog) Start(ctx context.Context, target string, opts ...RequestOpt) (
 
 	// Сохраняем как первую транзакцию диалога
 	s.setFirstTX(tx)
+	s.markInviteSent(sentAt)
+	s.startSetupTimers(req.Recipient)
 
 	slog.Debug("Dialog.Start INVITE sent successfully",
 		slog.String("branchID", GetBranchID(tx.Request())))
@@ -414,8 +522,8 @@ func (s *Dialog) Start(ctx context.Context, target string, opts ...RequestOpt) (
 func (s *Dialog) Refer(ctx context.Context, target sip.Uri, opts ...RequestOpt) (IClientTX, error) {
 	// Отправляем REFER запрос для переадресации
 	slog.Debug("Dialog.Refer",
-		slog.String("dialogID", s.id),
-		slog.String("target", target.String()),
+		slog.String("dialogID", s.ID()),
+		slog.String("target", s.uu.redact(target.String())),
 		slog.String("state", s.State().String()))
 
 	if s.State() != InCall {
@@ -433,7 +541,7 @@ func (s *Dialog) Refer(ctx context.Context, target sip.Uri, opts ...RequestOpt)
 	}
 
 	slog.Debug("Dialog.Refer creating REFER request",
-		slog.String("request", req.String()))
+		slog.String("request", s.uu.redact(req.String())))
 
 	// Отправляем запрос
 	tx, err := s.sendReq(ctx, req)
@@ -456,7 +564,7 @@ func (s *Dialog) Refer(ctx context.Context, target sip.Uri, opts ...RequestOpt)
 func (s *Dialog) ReferReplace(ctx context.Context, replaceDialog IDialog, opts ...RequestOpt) (IClientTX, error) {
 	// Отправляем REFER с заменой существующего диалога
 	slog.Debug("Dialog.ReferReplace",
-		slog.String("dialogID", s.id),
+		slog.String("dialogID", s.ID()),
 		slog.String("replaceDialogID", replaceDialog.ID()),
 		slog.String("state", s.State().String()))
 
@@ -498,7 +606,7 @@ func (s *Dialog) ReferReplace(ctx context.Context, replaceDialog IDialog, opts .
 	}
 
 	slog.Debug("Dialog.ReferReplace creating REFER with Replaces",
-		slog.String("request", req.String()))
+		slog.String("request", s.uu.redact(req.String())))
 
 	// Отправляем запрос
 	tx, err := s.sendReq(ctx, req)
@@ -516,11 +624,12 @@ func (s *Dialog) ReferReplace(ctx context.Context, replaceDialog IDialog, opts .
 
 // SendRequest отправляет произвольный SIP запрос в рамках диалога.
 // По умолчанию создает INFO запрос. Метод можно изменить через RequestOpt.
-// Не может быть вызван в состоянии Ended.
+// Не может быть вызван в состоянии Ended. INVITE, UPDATE и REFER проходят
+// через очередь диалога, как в ReInvite.
 func (s *Dialog) SendRequest(ctx context.Context, opts ...RequestOpt) (IClientTX, error) {
 	// Отправляем произвольный запрос в рамках диалога
 	slog.Debug("Dialog.SendRequest",
-		slog.String("dialogID", s.id),
+		slog.String("dialogID", s.ID()),
 		slog.String("state", s.State().String()))
 
 	if s.State() == Ended {
@@ -545,7 +654,7 @@ func (s *Dialog) SendRequest(ctx context.Context, opts ...RequestOpt) (IClientTX
 
 	slog.Debug("Dialog.SendRequest creating request",
 		slog.String("method", string(method)),
-		slog.String("request", req.String()))
+		slog.String("request", s.uu.redact(req.String())))
 
 	// Отправляем запрос
 	tx, err := s.sendReq(ctx, req)
@@ -639,6 +748,27 @@ func (s *Dialog) GetTransitionHistory() []StateTransitionReason {
 	return history
 }
 
+// ReleaseCause возвращает причину завершения вызова или nil, если вызов не завершается.
+// Метод потокобезопасен.
+func (s *Dialog) ReleaseCause() *ReleaseCause {
+	s.releaseMu.Lock()
+	defer s.releaseMu.Unlock()
+	if s.releaseCause == nil {
+		return nil
+	}
+	cause := *s.releaseCause
+	return &cause
+}
+
+// setReleaseCause сохраняет причину завершения, если она еще не установлена
+func (s *Dialog) setReleaseCause(cause ReleaseCause) {
+	s.releaseMu.Lock()
+	defer s.releaseMu.Unlock()
+	if s.releaseCause == nil {
+		s.releaseCause = &cause
+	}
+}
+
 // OnStateChange устанавливает обработчик изменения состояния диалога.
 // Обработчик будет вызван при каждом переходе между состояниями.
 // Метод потокобезопасен.
@@ -675,6 +805,16 @@ func (s *Dialog) OnTerminate(handler func()) {
 	s.terminateHandler = handler
 }
 
+// OnRelease устанавливает обработчик завершения диалога с причиной завершения.
+// Вызывается при переходе в состояние Ended после обработчика OnTerminate.
+// Причина содержит данные заголовка Reason из BYE/CANCEL или финального ответа.
+// Метод потокобезопасен.
+func (s *Dialog) OnRelease(handler func(cause ReleaseCause)) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.releaseHandler = handler
+}
+
 // NewDialog создает новый SIP диалог.
 // Диалог создается в состоянии IDLE и готов для отправки исходящего вызова.
 //
@@ -698,9 +838,10 @@ func (u *UACUAS) NewDialog(ctx context.Context, opts ...OptDialog) (*Dialog, err
 		opt(di)
 	}
 
-	di.localCSeq.Swap(uint32(rand.Int31()))
+	cseq, _ := random.Uint32(u.randomSource)
+	di.localCSeq.Swap(cseq & 0x7fffffff)
 	di.initFSM()
-	di.callID = sip.CallIDHeader(newCallId())
+	di.callID = sip.CallIDHeader(u.newCallID())
 
 	// Инициализируем временные метки
 	di.createdAt = time.Now()
@@ -710,7 +851,7 @@ func (u *UACUAS) NewDialog(ctx context.Context, opts ...OptDialog) (*Dialog, err
 	di.ctx = ctx
 
 	// Генерируем localTag
-	di.localTag = generateTag()
+	di.localTag = u.generateTag()
 	// и сохраняем
 	u.dialogs.Put(di.callID, di.localTag, "", di)
 
@@ -759,7 +900,7 @@ func (u *UACUAS) newUAS(req *sip.Request, tx sip.ServerTransaction) *Dialog {
 	}
 
 	// Генерируем localTag для UAS
-	di.localTag = generateTag()
+	di.localTag = u.generateTag()
 
 	di.initFSM()
 
@@ -773,6 +914,7 @@ func (u *UACUAS) newUAS(req *sip.Request, tx sip.ServerTransaction) *Dialog {
 	if req.Contact() != nil {
 		di.remoteTarget = req.Contact().Address
 	}
+	di.setRouteSet(routeSetFromRecordRoute(req, false))
 
 	di.localContact = &sip.ContactHeader{
 		DisplayName: "",
@@ -894,16 +1036,44 @@ func (s *Dialog) afterStateChange(ctx context.Context, e *fsm.Event) {
 	s.handlersMu.Lock()
 	handler := s.stateChangeHandler
 	terminateHandler := s.terminateHandler
+	releaseHandler := s.releaseHandler
 	s.handlersMu.Unlock()
 
 	if handler != nil {
 		handler(DialogState(e.Dst))
 	}
 
+	// Отсчет лимита длительности идет от ответа на вызов, после ответа
+	// завершается диалог, замененный вызовом, а завершение освобождает слот парковки
+	switch DialogState(e.Dst) {
+	case InCall:
+		s.stopSetupTimers()
+		s.startCallLimit()
+		s.completeReplaces()
+	case Terminating, Ended:
+		s.stopSetupTimers()
+		s.stopCallLimit()
+		s.releasePark()
+		s.requests.cancelWaiting("диалог завершается")
+	}
+	s.updateLineAppearance()
+	s.notifyWebhooks(DialogState(e.Src), DialogState(e.Dst))
+
+	if DialogState(e.Dst) == Ended {
+		s.markEnded()
+	}
+
 	// Если перешли в состояние Ended, вызываем terminateHandler
 	if DialogState(e.Dst) == Ended && terminateHandler != nil {
 		terminateHandler()
 	}
+
+	// Причина завершения для биллинга и аналитики
+	if DialogState(e.Dst) == Ended && releaseHandler != nil {
+		if cause := s.ReleaseCause(); cause != nil {
+			releaseHandler(*cause)
+		}
+	}
 }
 
 func (s *Dialog) enterRinging(ctx context.Context, e *fsm.Event) {
@@ -933,6 +1103,16 @@ func (s *Dialog) setStateWithReason(status DialogState, tx *TX, reason StateTran
 	reason.ToState = status
 	reason.Timestamp = time.Now()
 
+	// Переходы к завершению несут причину; первая установленная причина сохраняется
+	if status == Terminating || status == Ended {
+		if reason.Cause != nil {
+			s.setReleaseCause(*reason.Cause)
+		} else if status == Ended {
+			s.setReleaseCause(NormalClearingCause())
+		}
+		reason.Cause = s.ReleaseCause()
+	}
+
 	// Сохраняем в историю
 	s.transitionMu.Lock()
 	s.transitionHistory = append(s.transitionHistory, reason)
@@ -940,7 +1120,7 @@ func (s *Dialog) setStateWithReason(status DialogState, tx *TX, reason StateTran
 
 	// Логируем переход с контекстом
 	slog.Info("Dialog state transition",
-		slog.String("dialogID", s.id),
+		slog.String("dialogID", s.ID()),
 		slog.String("from", reason.FromState.String()),
 		slog.String("to", reason.ToState.String()),
 		slog.String("reason", reason.Reason),
@@ -961,10 +1141,14 @@ func (s *Dialog) setState(status DialogState, tx *TX) error {
 }
 
 func (s *Dialog) setFirstTX(tx *TX) {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
 	s.firstTX = tx
 }
 
 func (s *Dialog) getFirstTX() *TX {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
 	return s.firstTX
 }
 
@@ -982,16 +1166,22 @@ func (s *Dialog) setReInviteTX(tx *TX) {
 // 	return s.reInviteTX
 // }
 
-// generateTag генерирует уникальный тег для диалога
-func generateTag() string {
-	return fmt.Sprintf("%d.%d", time.Now().UnixNano(), rand.Int63())
+// generateTag генерирует уникальный тег для диалога из источника UACUAS
+func (u *UACUAS) generateTag() string {
+	return random.String(u.randomSource, 16)
 }
 
 // makeRequest создает новый SIP запрос в рамках диалога.
 // Автоматически добавляет необходимые заголовки: From, To, Call-ID, CSeq, Route.
 // Устанавливает локальный адрес (Laddr) в зависимости от типа диалога (UAS/UAC).
 func (s *Dialog) makeRequest(method sip.RequestMethod) *sip.Request {
-	trg := s.remoteTarget
+	// Идентификация диалога меняется ответами из других горутин: запрос
+	// строится по согласованному снимку target, тега и route set
+	s.uriMu.Lock()
+	remoteTarget, remoteTag, routeSet := s.remoteTarget, s.remoteTag, s.routeSet
+	s.uriMu.Unlock()
+
+	trg := remoteTarget
 	trg.Port = 0
 	newRequest := sip.NewRequest(method, trg)
 
@@ -1023,15 +1213,14 @@ func (s *Dialog) makeRequest(method sip.RequestMethod) *sip.Request {
 
 	toHeader := sip.ToHeader{
 		DisplayName: "",
-		Address:     s.remoteTarget,
+		Address:     remoteTarget,
 		Params:      nil,
 	}
 	// Добавляем tag для запросов внутри диалога (согласно RFC 3261)
-	if s.remoteTag != "" {
-		toHeader.Params = sip.NewParams().Add("tag", s.remoteTag)
+	if remoteTag != "" {
+		toHeader.Params = sip.NewParams().Add("tag", remoteTag)
 	}
 	newRequest.AppendHeader(&toHeader)
-	newRequest.Recipient = s.remoteTarget
 
 	// Добавляем Contact заголовок
 	if s.profile != nil {
@@ -1049,14 +1238,17 @@ func (s *Dialog) makeRequest(method sip.RequestMethod) *sip.Request {
 	}
 
 	newRequest.AppendHeader(&s.callID)
-	newRequest.AppendHeader(&sip.CSeqHeader{SeqNo: s.NextLocalCSeq(), MethodName: method})
+	// CSeq не добавляется: sendReq назначает следующий номер непосредственно
+	// перед отправкой, если он не задан опцией WithCSeq, а ACK на 2xx получает
+	// номер подтверждаемого INVITE
 	maxForwards := sip.MaxForwardsHeader(70)
 	newRequest.AppendHeader(&maxForwards)
 
-	if len(s.routeSet) > 0 {
-		for _, val := range s.routeSet {
-			newRequest.AppendHeader(&sip.RouteHeader{Address: val})
-		}
+	applyRouteSet(newRequest, remoteTarget, routeSet)
+
+	// INVITE сообщает удаленной стороне наши возможности (RFC 3261 Section 13.2.1)
+	if method == sip.INVITE && s.uu != nil && s.uu.capabilities != nil {
+		s.uu.capabilities.appendCapabilityHeaders(newRequest)
 	}
 
 	slog.Debug("Dialog.makeRequest created",
@@ -1070,6 +1262,7 @@ func (s *Dialog) makeRequest(method sip.RequestMethod) *sip.Request {
 
 func (s *Dialog) buildFromHeader() sip.FromHeader {
 	var fromHeader = sip.FromHeader{}
+	firstTX := s.getFirstTX()
 
 	// если профиль nil то берем из первой транзакции
 	if s.profile != nil {
@@ -1078,11 +1271,11 @@ func (s *Dialog) buildFromHeader() sip.FromHeader {
 			Address:     s.profile.Address,
 			Params:      sip.NewParams().Add("tag", s.localTag),
 		}
-	} else if s.firstTX != nil && s.firstTX.req != nil {
+	} else if firstTX != nil && firstTX.req != nil {
 		switch s.uaType {
 		case UAS:
 			// Для UAS берем To заголовок из первого запроса (это наш локальный адрес)
-			if toHeader := s.firstTX.req.To(); toHeader != nil {
+			if toHeader := firstTX.req.To(); toHeader != nil {
 				fromHeader = sip.FromHeader{
 					DisplayName: toHeader.DisplayName,
 					Address:     toHeader.Address,
@@ -1091,7 +1284,7 @@ func (s *Dialog) buildFromHeader() sip.FromHeader {
 			}
 		case UAC:
 			// Для UAC берем From заголовок из первого запроса
-			if fromHeaderOrig := s.firstTX.req.From(); fromHeaderOrig != nil {
+			if fromHeaderOrig := firstTX.req.From(); fromHeaderOrig != nil {
 				fromHeader = sip.FromHeader{
 					DisplayName: fromHeaderOrig.DisplayName,
 					Address:     fromHeaderOrig.Address,
@@ -1122,9 +1315,44 @@ func (s *Dialog) sendReq(ctx context.Context, req *sip.Request) (*TX, error) {
 	{
 		slog.Debug("sendReq", slog.Any("req.Laddr", req.Laddr))
 	}
+	// Re-INVITE, UPDATE и REFER установленного диалога ждут финального ответа
+	// на предыдущий такой запрос. Начальный INVITE в очередь не попадает:
+	// UPDATE раннего диалога (RFC 3311) уходит до финального ответа на него
+	var queued *queueEntry
+	if isSerializedMethod(req.Method) && s.State() == InCall {
+		var err error
+		if queued, err = s.requests.acquire(ctx, req.Method); err != nil {
+			return nil, err
+		}
+	}
+
+	s.uu.prepareOutgoing(req)
+	s.uu.selectRequestTransport(req)
+
+	// CSeq назначается под cseqMu, и запрос передается транспорту до его
+	// освобождения: удаленная сторона получает запросы диалога в порядке
+	// возрастания CSeq (RFC 3261 Section 12.2.1.1), иначе запрос с меньшим
+	// номером, отправленный позже, будет отклонен 500 (Section 12.2.2)
+	s.cseqMu.Lock()
+	if h := req.CSeq(); h == nil {
+		req.AppendHeader(&sip.CSeqHeader{SeqNo: s.NextLocalCSeq(), MethodName: req.Method})
+	} else if h.SeqNo > s.localCSeq.Load() && req.Method != sip.ACK && req.Method != sip.CANCEL {
+		// Номер, заданный WithCSeq, сохраняется; следующие запросы диалога
+		// получают большие номера
+		s.localCSeq.Store(h.SeqNo)
+	}
+
 	// Отправляем через глобальный UAC
 	tx, err := s.uu.uac.TransactionRequest(ctx, req, sipgo.ClientRequestAddVia)
+	if errors.Is(err, sip.ErrUDPMTUCongestion) && s.uu.fallbackToTCP(req) {
+		// Транспортный уровень отклонил запрос по размеру - повторяем по TCP
+		tx, err = s.uu.uac.TransactionRequest(ctx, req, sipgo.ClientRequestAddVia)
+	}
+	s.cseqMu.Unlock()
 	if err != nil {
+		if queued != nil {
+			s.requests.release(queued)
+		}
 		return nil, errors.Wrap(err, "failed to send request")
 	}
 
@@ -1133,11 +1361,16 @@ func (s *Dialog) sendReq(ctx context.Context, req *sip.Request) (*TX, error) {
 		slog.String("branchID", GetBranchID(req)))
 
 	// Создаем обертку транзакции
-	txWrapper := newTX(req, tx, s)
+	var onFinal func()
+	if queued != nil {
+		onFinal = func() { s.requests.release(queued) }
+	}
+	txWrapper := newClientQueuedTX(req, tx, s, onFinal)
 	return txWrapper, nil
 }
 
-// updateDialogID обновляет ID диалога на основе CallID и тегов
+// updateDialogID обновляет ID диалога на основе CallID и тегов.
+// Вызывается под uriMu или до публикации диалога
 func (s *Dialog) updateDialogID() {
 	if s.callID != "" && s.localTag != "" && s.remoteTag != "" {
 		s.id = fmt.Sprintf("%s:%s:%s", s.callID, s.localTag, s.remoteTag)
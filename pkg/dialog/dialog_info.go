@@ -0,0 +1,118 @@
+package dialog
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+)
+
+// ContentTypeDialogInfo - тип тела с состоянием диалогов (RFC 4235)
+const ContentTypeDialogInfo = "application/dialog-info+xml"
+
+// Состояния элемента dialog документа dialog-info (RFC 4235 Section 3.7.1)
+const (
+	DialogInfoTrying     = "trying"
+	DialogInfoProceeding = "proceeding"
+	DialogInfoEarly      = "early"
+	DialogInfoConfirmed  = "confirmed"
+	DialogInfoTerminated = "terminated"
+)
+
+// DialogInfo - документ application/dialog-info+xml (RFC 4235)
+type DialogInfo struct {
+	XMLName xml.Name           `xml:"urn:ietf:params:xml:ns:dialog-info dialog-info"`
+	Version uint32             `xml:"version,attr"`
+	State   string             `xml:"state,attr"` // full или partial
+	Entity  string             `xml:"entity,attr"`
+	Dialogs []DialogInfoDialog `xml:"dialog"`
+}
+
+// DialogInfoDialog - элемент dialog документа dialog-info. Теги указываются
+// с точки зрения уведомителя (например, парковки).
+type DialogInfoDialog struct {
+	ID        string                 `xml:"id,attr"`
+	CallID    string                 `xml:"call-id,attr,omitempty"`
+	LocalTag  string                 `xml:"local-tag,attr,omitempty"`
+	RemoteTag string                 `xml:"remote-tag,attr,omitempty"`
+	Direction string                 `xml:"direction,attr,omitempty"`
+	State     string                 `xml:"state"` // DialogInfoTrying ... DialogInfoTerminated
+	Duration  string                 `xml:"duration,omitempty"`
+	Local     *DialogInfoParticipant `xml:"local,omitempty"`
+	Remote    *DialogInfoParticipant `xml:"remote,omitempty"`
+
+	// Appearance - номер appearance общей линии (RFC 7463 Section 5.2)
+	Appearance int `xml:"urn:ietf:params:xml:ns:sa-dialog-info appearance,omitempty"`
+	// Exclusive - вызов на appearance нельзя подхватить или присоединиться к нему
+	Exclusive string `xml:"urn:ietf:params:xml:ns:sa-dialog-info exclusive,omitempty"`
+}
+
+// DialogInfoParticipant - элемент local или remote диалога
+type DialogInfoParticipant struct {
+	Identity string            `xml:"identity,omitempty"`
+	Target   *DialogInfoTarget `xml:"target,omitempty"`
+}
+
+// DialogInfoTarget - Contact участника диалога
+type DialogInfoTarget struct {
+	URI    string            `xml:"uri,attr"`
+	Params []DialogInfoParam `xml:"param,omitempty"`
+}
+
+// DialogInfoParam - параметр target, например +sip.rendering=no для
+// вызова на удержании
+type DialogInfoParam struct {
+	Name  string `xml:"pname,attr"`
+	Value string `xml:"pvalue,attr"`
+}
+
+// ParseDialogInfo разбирает документ application/dialog-info+xml
+func ParseDialogInfo(data []byte) (*DialogInfo, error) {
+	var info DialogInfo
+	if err := xml.Unmarshal(data, &info); err != nil {
+		return nil, errors.Wrap(err, "failed to parse dialog-info")
+	}
+	return &info, nil
+}
+
+// marshalDialogInfo сериализует документ dialog-info с XML заголовком
+func marshalDialogInfo(info DialogInfo) ([]byte, error) {
+	data, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal dialog-info")
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// Replaces возвращает значение заголовка Replaces для INVITE удаленному
+// участнику диалога (подхват вызова, опубликованного уведомителем)
+func (d DialogInfoDialog) Replaces() ReplacesInfo {
+	return ReplacesInfo{
+		CallID:  d.CallID,
+		ToTag:   d.RemoteTag,
+		FromTag: d.LocalTag,
+	}
+}
+
+// RemoteTarget возвращает адрес удаленного участника диалога для подхвата
+func (d DialogInfoDialog) RemoteTarget() (sip.Uri, error) {
+	var uri sip.Uri
+	if d.Remote == nil || d.Remote.Target == nil || d.Remote.Target.URI == "" {
+		return uri, fmt.Errorf("dialog %s has no remote target", d.ID)
+	}
+	if err := sip.ParseUri(d.Remote.Target.URI, &uri); err != nil {
+		return uri, errors.Wrap(err, "failed to parse remote target")
+	}
+	return uri, nil
+}
+
+// WithDialogInfo добавляет к NOTIFY документ dialog-info и заголовок Event: dialog
+func WithDialogInfo(body []byte) RequestOpt {
+	return func(msg sip.Message) {
+		msg.AppendHeader(sip.NewHeader("Event", "dialog"))
+		ct := sip.ContentTypeHeader(ContentTypeDialogInfo)
+		msg.AppendHeader(&ct)
+		msg.SetBody(body)
+	}
+}
@@ -37,7 +37,11 @@
 //   - Поддержка различных транспортов (UDP, TCP, TLS, WS, WSS)
 //   - Автоматическое управление CSeq и тегами диалога
 //   - Обработка SIP транзакций с гарантиями доставки
-//   - Поддержка SIP регистрации
+//   - Встроенный регистратор с маршрутизацией вызовов на контакты (Registrar)
+//   - Статистика повторов, таймаутов и задержек транзакций по хостам назначения (TransactionStats)
+//   - Гистограммы PDD и времени установления исходящих вызовов (CallSetupMetrics)
+//   - Раздельные таймеры ожидания 18x и ответа на исходящий вызов по назначению (SetupTimeouts)
+//   - Синтетические входящие вызовы для тестирования приложений (InjectIncomingCall)
 //
 // # Быстрый старт
 //
@@ -107,6 +111,14 @@
 //	    }
 //	}()
 //
+// Для скриптов и простых утилит есть блокирующие варианты без обработчиков:
+//
+//	resp, err := tx.WaitFinal(ctx)
+//	if err == nil && resp.StatusCode == 200 {
+//	    // диалог уже в состоянии InCall
+//	    err = dialog.WaitEnded(ctx)
+//	}
+//
 // # Входящий вызов
 //
 // Обработка входящих вызовов:
@@ -216,6 +228,15 @@
 //	    dialog.WithAuthorization("Digest username=\"alice\", realm=\"example.com\"..."),
 //	)
 //
+// Методы, которые диалог не моделирует (например, проприетарные),
+// отправляются через DoRequest с той же маршрутизацией и транзакцией:
+//
+//	tx, err := dialog.DoRequest(ctx, "X-PING", dialog.WithHeaderString("X-Vendor", "acme"))
+//	if err == nil {
+//	    resp := <-tx.Responses()
+//	    log.Printf("Ответ на X-PING: %d", resp.StatusCode)
+//	}
+//
 // # Отслеживание состояния
 //
 // Диалог сохраняет историю переходов состояний с контекстной информацией:
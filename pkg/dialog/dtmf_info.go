@@ -0,0 +1,94 @@
+package dialog
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// Типы тела INFO с DTMF: application/dtmf-relay (Cisco, "Signal=5\r\nDuration=160")
+// и application/dtmf (только символ)
+const (
+	ContentTypeDTMFRelay = "application/dtmf-relay"
+	ContentTypeDTMF      = "application/dtmf"
+)
+
+// DefaultDTMFInfoDuration - длительность цифры, если INFO ее не содержит
+const DefaultDTMFInfoDuration = 160 * time.Millisecond
+
+// dtmfInfoEvents - коды событий RFC 4733, которые некоторые шлюзы передают
+// в Signal вместо символа
+const dtmfInfoEvents = "0123456789*#ABCD"
+
+// DTMFInfo - DTMF цифра, переданная в запросе INFO
+type DTMFInfo struct {
+	Signal   rune          // 0-9, *, #, A-D
+	Duration time.Duration // Длительность нажатия
+}
+
+// ParseDTMFInfo разбирает тело INFO с DTMF типа application/dtmf-relay или
+// application/dtmf. Для остальных типов возвращает ошибку.
+func ParseDTMFInfo(body *Body) (DTMFInfo, error) {
+	if body == nil {
+		return DTMFInfo{}, fmt.Errorf("INFO has no body")
+	}
+
+	info := DTMFInfo{Duration: DefaultDTMFInfoDuration}
+	var signal string
+	switch contentType := strings.ToLower(strings.TrimSpace(body.ContentType())); contentType {
+	case ContentTypeDTMF:
+		signal = strings.TrimSpace(string(body.Content()))
+	case ContentTypeDTMFRelay:
+		scanner := bufio.NewScanner(bytes.NewReader(body.Content()))
+		for scanner.Scan() {
+			name, value, ok := strings.Cut(scanner.Text(), "=")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "signal":
+				signal = value
+			case "duration":
+				ms, err := strconv.Atoi(value)
+				if err != nil || ms <= 0 {
+					return DTMFInfo{}, fmt.Errorf("invalid DTMF duration %q", value)
+				}
+				info.Duration = time.Duration(ms) * time.Millisecond
+			}
+		}
+	default:
+		return DTMFInfo{}, fmt.Errorf("unsupported DTMF content type %q", contentType)
+	}
+
+	switch {
+	case len(signal) == 1 && strings.ContainsRune(dtmfInfoEvents, rune(strings.ToUpper(signal)[0])):
+		info.Signal = rune(strings.ToUpper(signal)[0])
+	default:
+		// Код события RFC 4733: 10 - *, 11 - #, 12-15 - A-D
+		event, err := strconv.Atoi(signal)
+		if err != nil || event < 0 || event >= len(dtmfInfoEvents) {
+			return DTMFInfo{}, fmt.Errorf("invalid DTMF signal %q", signal)
+		}
+		info.Signal = rune(dtmfInfoEvents[event])
+	}
+	return info, nil
+}
+
+// WithDTMFInfo добавляет к INFO тело application/dtmf-relay с цифрой info
+func WithDTMFInfo(info DTMFInfo) RequestOpt {
+	return func(msg sip.Message) {
+		duration := info.Duration
+		if duration <= 0 {
+			duration = DefaultDTMFInfoDuration
+		}
+		ct := sip.ContentTypeHeader(ContentTypeDTMFRelay)
+		msg.AppendHeader(&ct)
+		msg.SetBody([]byte(fmt.Sprintf("Signal=%c\r\nDuration=%d\r\n", info.Signal, duration.Milliseconds())))
+	}
+}
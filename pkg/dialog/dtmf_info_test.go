@@ -0,0 +1,138 @@
+package dialog
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/emiago/sipgo/sip"
+	pionrtp "github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDTMFInfo проверяет формирование и разбор DTMF в теле INFO
+func TestDTMFInfo(t *testing.T) {
+	req := sip.NewRequest(sip.INFO, sip.Uri{Host: "example.com"})
+	WithDTMFInfo(DTMFInfo{Signal: '#', Duration: 250 * time.Millisecond})(req)
+	assert.Equal(t, ContentTypeDTMFRelay, req.ContentType().Value())
+	assert.Equal(t, "Signal=#\r\nDuration=250\r\n", string(req.Body()))
+
+	info, err := ParseDTMFInfo(&Body{contentType: req.ContentType().Value(), content: req.Body()})
+	require.NoError(t, err)
+	assert.Equal(t, DTMFInfo{Signal: '#', Duration: 250 * time.Millisecond}, info)
+
+	tests := []struct {
+		contentType string
+		body        string
+		expected    DTMFInfo
+	}{
+		{ContentTypeDTMFRelay, "signal= 5\nduration=100", DTMFInfo{Signal: '5', Duration: 100 * time.Millisecond}},
+		{ContentTypeDTMFRelay, "Signal=10\r\n", DTMFInfo{Signal: '*', Duration: DefaultDTMFInfoDuration}},
+		{ContentTypeDTMFRelay, "Signal=a\r\nDuration=80\r\n", DTMFInfo{Signal: 'A', Duration: 80 * time.Millisecond}},
+		{"Application/DTMF", "7\r\n", DTMFInfo{Signal: '7', Duration: DefaultDTMFInfoDuration}},
+	}
+	for _, tt := range tests {
+		info, err := ParseDTMFInfo(&Body{contentType: tt.contentType, content: []byte(tt.body)})
+		require.NoError(t, err, tt.body)
+		assert.Equal(t, tt.expected, info, tt.body)
+	}
+
+	for _, body := range []*Body{
+		nil,
+		{contentType: "application/sdp", content: []byte("v=0")},
+		{contentType: ContentTypeDTMFRelay, content: []byte("Signal=X")},
+		{contentType: ContentTypeDTMFRelay, content: []byte("Signal=16")},
+		{contentType: ContentTypeDTMFRelay, content: []byte("Signal=1\r\nDuration=-5")},
+		{contentType: ContentTypeDTMF, content: []byte("")},
+	} {
+		_, err := ParseDTMFInfo(body)
+		assert.Error(t, err)
+	}
+}
+
+// TestDTMFInfoToRFC4733 проверяет пересылку цифры из INFO, принятого по сети
+// в диалоге, в RFC 4733 пакеты медиа сессии другого плеча
+func TestDTMFInfoToRFC4733(t *testing.T) {
+	bob := newRegistrarPeer(t, "bob")
+	d, _ := startSetupTimeoutCall(t, 15113, Config{}, bob)
+
+	msg, from := bob.read(matchRequest(sip.INVITE))
+	respond(bob, msg.(*sip.Request), sip.StatusOK, "OK", from)
+	require.Eventually(t, func() bool { return d.State() == InCall }, 2*time.Second, 5*time.Millisecond)
+
+	// Плечо RFC 4733 отправляет RTP на сокет теста
+	rtpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer rtpConn.Close()
+	transport, err := rtp.NewUDPTransport(rtp.TransportConfig{
+		LocalAddr:  "127.0.0.1:0",
+		RemoteAddr: rtpConn.LocalAddr().String(),
+	})
+	require.NoError(t, err)
+	rtpSession, err := rtp.NewSession(rtp.SessionConfig{
+		PayloadType: rtp.PayloadTypePCMU,
+		MediaType:   rtp.MediaTypeAudio,
+		ClockRate:   8000,
+		Transport:   transport,
+	})
+	require.NoError(t, err)
+
+	config := media.DefaultMediaSessionConfig()
+	config.SessionID = "dtmf-info"
+	config.DTMFEnabled = true
+	session, err := media.NewSession(config)
+	require.NoError(t, err)
+	require.NoError(t, session.AddRTPSession("primary", rtpSession))
+	require.NoError(t, session.Start())
+	defer session.Stop()
+
+	iw, err := media.NewDTMFInterworking(media.DTMFInterworkingConfig{
+		LegA: media.DTMFLegConfig{Mechanism: media.DTMFMechanismRFC4733, Session: session},
+		LegB: media.DTMFLegConfig{Mechanism: media.DTMFMechanismSIPInfo, SendInfo: func(media.DTMFEvent) error { return nil }},
+	})
+	require.NoError(t, err)
+	defer iw.Close()
+
+	d.OnRequestHandler(func(tx IServerTX) {
+		info, err := ParseDTMFInfo(tx.Body())
+		if err != nil {
+			_ = tx.Reject(sip.StatusUnsupportedMediaType, "Unsupported Media Type")
+			return
+		}
+		digits, err := media.ParseDTMFString(string(info.Signal))
+		if err != nil {
+			_ = tx.Reject(sip.StatusBadRequest, "Bad Request")
+			return
+		}
+		iw.Receive(media.DTMFLegB, media.DTMFMechanismSIPInfo, media.DTMFEvent{Digit: digits[0], Duration: info.Duration})
+		_ = tx.Accept()
+	})
+
+	local := sip.Uri{Scheme: "sip", Host: "127.0.0.1", Port: 15113}
+	info := bob.newRequest(sip.INFO, bob.uri, local, bob.uri, string(d.CallID()), "z9hG4bK-info")
+	info.To().Params = sip.NewParams().Add("tag", d.LocalTag())
+	WithDTMFInfo(DTMFInfo{Signal: '5', Duration: 100 * time.Millisecond})(info)
+	bob.send(info, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 15113})
+
+	msg, _ = bob.read(matchResponse(sip.INFO, sip.StatusOK))
+	assert.Equal(t, sip.StatusOK, msg.(*sip.Response).StatusCode)
+
+	// Цифра приходит пакетами telephone-event (RFC 4733 Section 2.3)
+	buf := make([]byte, 1500)
+	require.NoError(t, rtpConn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		n, _, err := rtpConn.ReadFromUDP(buf)
+		require.NoError(t, err, "RFC 4733 пакет не получен")
+		var packet pionrtp.Packet
+		require.NoError(t, packet.Unmarshal(buf[:n]))
+		if packet.PayloadType != config.DTMFPayloadType {
+			continue
+		}
+		require.NotEmpty(t, packet.Payload)
+		assert.Equal(t, byte(5), packet.Payload[0], "Неверный код события")
+		break
+	}
+}
@@ -0,0 +1,265 @@
+package dialog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+)
+
+// forkedByeTimeout ограничивает время ожидания ответа на BYE, отправленный
+// в лишнюю ветку разветвленного INVITE
+const forkedByeTimeout = 32 * time.Second
+
+// EarlyDialog описывает ранний диалог, созданный предварительным ответом
+// одной из веток разветвленного INVITE (RFC 3261 Section 12.1, 13.2.2.4).
+// Каждая ветка идентифицируется своим to-tag.
+type EarlyDialog struct {
+	RemoteTag  string    // to-tag ветки
+	StatusCode int       // Код последнего ответа ветки
+	Reason     string    // Фраза причины последнего ответа
+	Contact    sip.Uri   // Contact из ответа ветки
+	Body       *Body     // Тело ответа (например, SDP для early media)
+	Accepted   bool      // Ветка, 2xx которой принят для диалога
+	UpdatedAt  time.Time // Время получения последнего ответа
+}
+
+// forkTracker отслеживает ветки разветвленного INVITE в порядке их появления
+type forkTracker struct {
+	mu          sync.Mutex
+	branches    map[string]*EarlyDialog
+	order       []string
+	acceptedTag string
+	accepted    bool
+}
+
+// update сохраняет ответ ветки. Возвращает true, если ветка появилась впервые.
+func (f *forkTracker) update(tag string, resp *sip.Response) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.branches == nil {
+		f.branches = make(map[string]*EarlyDialog)
+	}
+
+	early, exists := f.branches[tag]
+	if !exists {
+		early = &EarlyDialog{RemoteTag: tag}
+		f.branches[tag] = early
+		f.order = append(f.order, tag)
+	}
+
+	early.StatusCode = resp.StatusCode
+	early.Reason = resp.Reason
+	if contact := resp.Contact(); contact != nil {
+		early.Contact = contact.Address
+	}
+	if body := extractBody(resp); body != nil {
+		early.Body = body
+	}
+	early.UpdatedAt = time.Now()
+
+	return !exists
+}
+
+// accept фиксирует ветку, 2xx которой первым пришел на INVITE.
+// Возвращает false, если уже принят 2xx от другой ветки.
+func (f *forkTracker) accept(tag string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.accepted {
+		f.accepted = true
+		f.acceptedTag = tag
+		if early, ok := f.branches[tag]; ok {
+			early.Accepted = true
+		}
+		return true
+	}
+	return f.acceptedTag == tag
+}
+
+// forked возвращает true, если ответы пришли более чем от одной ветки
+func (f *forkTracker) forked() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.order) > 1
+}
+
+// snapshot возвращает копию веток в порядке их появления
+func (f *forkTracker) snapshot() []EarlyDialog {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := make([]EarlyDialog, 0, len(f.order))
+	for _, tag := range f.order {
+		result = append(result, *f.branches[tag])
+	}
+	return result
+}
+
+// EarlyDialogs возвращает ранние диалоги, созданные предварительными ответами
+// на исходящий INVITE, в порядке их появления.
+// Метод потокобезопасен.
+func (s *Dialog) EarlyDialogs() []EarlyDialog {
+	return s.forks.snapshot()
+}
+
+// OnEarlyDialogForked устанавливает обработчик разветвления INVITE.
+// Обработчик вызывается каждый раз, когда предварительный ответ приходит от новой
+// ветки, если всего веток больше одной, и получает список всех ранних диалогов.
+// Позволяет показать в интерфейсе несколько звонящих устройств.
+// Метод потокобезопасен.
+func (s *Dialog) OnEarlyDialogForked(handler func(early []EarlyDialog)) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.forkedHandler = handler
+}
+
+// responseToTag возвращает to-tag ответа
+func responseToTag(resp *sip.Response) string {
+	if to := resp.To(); to != nil && to.Params != nil {
+		if tag, ok := to.Params.Get("tag"); ok {
+			return tag
+		}
+	}
+	return ""
+}
+
+// trackEarlyDialog регистрирует ветку по предварительному ответу на INVITE
+// и уведомляет о разветвлении при появлении новой ветки
+func (s *Dialog) trackEarlyDialog(resp *sip.Response) {
+	tag := responseToTag(resp)
+	// 100 Trying и ответы без to-tag не создают ранний диалог
+	if tag == "" || resp.StatusCode == sip.StatusTrying {
+		return
+	}
+
+	if !s.forks.update(tag, resp) || !s.forks.forked() {
+		return
+	}
+
+	slog.Info("INVITE разветвлен, получен ответ от новой ветки",
+		slog.String("dialogID", s.ID()),
+		slog.String("remoteTag", tag),
+		slog.Int("status", resp.StatusCode))
+
+	s.handlersMu.Lock()
+	handler := s.forkedHandler
+	s.handlersMu.Unlock()
+
+	if handler != nil {
+		handler(s.forks.snapshot())
+	}
+}
+
+// acceptBranch проверяет 2xx на INVITE. Первый 2xx принимается для диалога,
+// 2xx от остальных веток подтверждаются ACK и сразу завершаются BYE
+// (RFC 3261 Section 13.2.2.4). Возвращает false, если ответ от лишней ветки.
+func (s *Dialog) acceptBranch(invite *sip.Request, resp *sip.Response) bool {
+	tag := responseToTag(resp)
+	s.forks.update(tag, resp)
+	if s.forks.accept(tag) {
+		return true
+	}
+
+	slog.Info("Получен 2xx от другой ветки разветвленного INVITE, ветка завершается",
+		slog.String("dialogID", s.ID()),
+		slog.String("remoteTag", tag))
+
+	go s.terminateForkedBranch(invite, resp)
+	return false
+}
+
+// terminateForkedBranch отправляет ACK и BYE в ветку, 2xx которой не был принят
+func (s *Dialog) terminateForkedBranch(invite *sip.Request, resp *sip.Response) {
+	if s.uu == nil || s.uu.uac == nil {
+		return
+	}
+
+	ack := newForkedBranchRequest(sip.ACK, invite, resp)
+	if err := s.uu.writeMsg(ack); err != nil {
+		slog.Error("Не удалось отправить ACK в ветку разветвленного INVITE",
+			slog.String("remoteTag", responseToTag(resp)),
+			slog.String("error", err.Error()))
+		return
+	}
+
+	bye := newForkedBranchRequest(sip.BYE, invite, resp)
+	s.uu.prepareOutgoing(bye)
+
+	ctx, cancel := context.WithTimeout(context.Background(), forkedByeTimeout)
+	defer cancel()
+
+	tx, err := s.uu.uac.TransactionRequest(ctx, bye, sipgo.ClientRequestAddVia)
+	if err != nil {
+		slog.Error("Не удалось отправить BYE в ветку разветвленного INVITE",
+			slog.String("remoteTag", responseToTag(resp)),
+			slog.String("error", err.Error()))
+		return
+	}
+	defer tx.Terminate()
+
+	for {
+		select {
+		case r := <-tx.Responses():
+			if r.StatusCode >= 200 {
+				slog.Debug("Ветка разветвленного INVITE завершена",
+					slog.String("remoteTag", responseToTag(resp)),
+					slog.Int("status", r.StatusCode))
+				return
+			}
+		case <-tx.Done():
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// newForkedBranchRequest создает ACK или BYE для диалога ветки, заданного
+// исходным INVITE и 2xx ответом этой ветки. Via добавляется при отправке.
+func newForkedBranchRequest(method sip.RequestMethod, invite *sip.Request, resp *sip.Response) *sip.Request {
+	recipient := invite.Recipient
+	if contact := resp.Contact(); contact != nil {
+		recipient = contact.Address
+	}
+
+	req := sip.NewRequest(method, *recipient.Clone())
+	req.SipVersion = invite.SipVersion
+	req.Laddr = invite.Laddr
+
+	// Route set ветки строится из Record-Route ее ответа в обратном порядке
+	recordRoutes := resp.GetHeaders("Record-Route")
+	for i := len(recordRoutes) - 1; i >= 0; i-- {
+		if rr, ok := recordRoutes[i].(*sip.RecordRouteHeader); ok {
+			req.AppendHeader(&sip.RouteHeader{Address: rr.Address})
+		}
+	}
+
+	maxForwards := sip.MaxForwardsHeader(70)
+	req.AppendHeader(&maxForwards)
+	if h := invite.From(); h != nil {
+		req.AppendHeader(sip.HeaderClone(h))
+	}
+	if h := resp.To(); h != nil {
+		req.AppendHeader(sip.HeaderClone(h))
+	}
+	if h := invite.CallID(); h != nil {
+		req.AppendHeader(sip.HeaderClone(h))
+	}
+	if h := invite.CSeq(); h != nil {
+		cseq := &sip.CSeqHeader{SeqNo: h.SeqNo, MethodName: method}
+		// BYE - новый запрос в диалоге ветки, ACK использует номер INVITE
+		if method != sip.ACK {
+			cseq.SeqNo++
+		}
+		req.AppendHeader(cseq)
+	}
+
+	req.SetTransport(invite.Transport())
+	return req
+}
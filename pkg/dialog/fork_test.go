@@ -0,0 +1,87 @@
+package dialog
+
+import (
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBranchResponse(invite *sip.Request, code int, reason, tag, contactHost string) *sip.Response {
+	resp := sip.NewResponseFromRequest(invite, code, reason, nil)
+	resp.To().Params = sip.NewParams().Add("tag", tag)
+	resp.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Scheme: "sip", User: "bob", Host: contactHost}})
+	return resp
+}
+
+func TestForkedInviteEarlyDialogs(t *testing.T) {
+	d := &Dialog{}
+	invite := newTestRequest(sip.INVITE)
+
+	var notifications [][]EarlyDialog
+	d.OnEarlyDialogForked(func(early []EarlyDialog) {
+		notifications = append(notifications, early)
+	})
+
+	// 100 Trying не создает ранний диалог
+	d.trackEarlyDialog(sip.NewResponseFromRequest(invite, sip.StatusTrying, "Trying", nil))
+	assert.Empty(t, d.EarlyDialogs())
+
+	// Первая ветка - разветвления еще нет
+	d.trackEarlyDialog(newBranchResponse(invite, 180, "Ringing", "branch-a", "10.0.0.1"))
+	assert.Len(t, d.EarlyDialogs(), 1)
+	assert.Empty(t, notifications)
+
+	// Вторая ветка - уведомление со списком всех веток
+	d.trackEarlyDialog(newBranchResponse(invite, 180, "Ringing", "branch-b", "10.0.0.2"))
+	require.Len(t, notifications, 1)
+	require.Len(t, notifications[0], 2)
+	assert.Equal(t, "branch-a", notifications[0][0].RemoteTag)
+	assert.Equal(t, "branch-b", notifications[0][1].RemoteTag)
+	assert.Equal(t, "10.0.0.2", notifications[0][1].Contact.Host)
+
+	// Повторный ответ известной ветки обновляет ее, но не уведомляет
+	d.trackEarlyDialog(newBranchResponse(invite, 183, "Session Progress", "branch-a", "10.0.0.1"))
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, 183, d.EarlyDialogs()[0].StatusCode)
+
+	// Первый 2xx принимается, повтор от той же ветки тоже
+	assert.True(t, d.acceptBranch(invite, newBranchResponse(invite, 200, "OK", "branch-b", "10.0.0.2")))
+	assert.True(t, d.acceptBranch(invite, newBranchResponse(invite, 200, "OK", "branch-b", "10.0.0.2")))
+
+	// 2xx от другой ветки отклоняется
+	assert.False(t, d.acceptBranch(invite, newBranchResponse(invite, 200, "OK", "branch-a", "10.0.0.1")))
+
+	early := d.EarlyDialogs()
+	require.Len(t, early, 2)
+	assert.False(t, early[0].Accepted)
+	assert.True(t, early[1].Accepted)
+}
+
+func TestForkedBranchRequests(t *testing.T) {
+	invite := newTestRequest(sip.INVITE)
+	invite.CSeq().SeqNo = 5
+	resp := newBranchResponse(invite, 200, "OK", "branch-b", "10.0.0.2")
+	resp.AppendHeader(&sip.RecordRouteHeader{Address: sip.Uri{Scheme: "sip", Host: "proxy1"}})
+	resp.AppendHeader(&sip.RecordRouteHeader{Address: sip.Uri{Scheme: "sip", Host: "proxy2"}})
+
+	ack := newForkedBranchRequest(sip.ACK, invite, resp)
+	assert.Equal(t, sip.ACK, ack.Method)
+	assert.Equal(t, "10.0.0.2", ack.Recipient.Host, "Запрос идет на Contact ветки")
+	assert.Equal(t, uint32(5), ack.CSeq().SeqNo)
+	assert.Equal(t, sip.ACK, ack.CSeq().MethodName)
+	tag, _ := ack.To().Params.Get("tag")
+	assert.Equal(t, "branch-b", tag)
+	assert.Nil(t, ack.Via(), "Via добавляется при отправке")
+
+	routes := ack.GetHeaders("Route")
+	require.Len(t, routes, 2)
+	assert.Equal(t, "proxy2", routes[0].(*sip.RouteHeader).Address.Host)
+	assert.Equal(t, "proxy1", routes[1].(*sip.RouteHeader).Address.Host)
+
+	bye := newForkedBranchRequest(sip.BYE, invite, resp)
+	assert.Equal(t, uint32(6), bye.CSeq().SeqNo)
+	assert.Equal(t, sip.BYE, bye.CSeq().MethodName)
+	assert.Equal(t, invite.CallID().Value(), bye.CallID().Value())
+}
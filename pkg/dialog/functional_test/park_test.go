@@ -0,0 +1,136 @@
+package dialog_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParkAndRetrieve - парковка вызова на UA2 и подхват с UA3 через INVITE с Replaces
+func TestParkAndRetrieve(t *testing.T) {
+	ua1, ua2, _, ports, cleanup := setupTest(t)
+	defer cleanup()
+
+	port3, _ := getTestPorts()
+	ua3 := initTestUA(t, port3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := ua3.ListenTransports(ctx); err != nil && ctx.Err() == nil {
+			t.Logf("UA3 transport error: %v", err)
+		}
+	}()
+	time.Sleep(300 * time.Millisecond)
+
+	parkedDialogs := make(chan *dialog.Dialog, 1)
+	ua2.OnIncomingCall(func(d dialog.IDialog, tx dialog.IServerTX) {
+		require.NoError(t, tx.Accept(dialog.ResponseWithSDP(getTestSDP(7300))))
+		parkedDialogs <- d.(*dialog.Dialog)
+	})
+
+	replacing := make(chan dialog.IDialog, 1)
+	ua1.OnIncomingCall(func(d dialog.IDialog, tx dialog.IServerTX) {
+		replacing <- d.ReplacedDialog()
+		require.NoError(t, tx.Accept(dialog.ResponseWithSDP(getTestSDP(5300))))
+	})
+
+	d1, err := ua1.NewDialog(ctx)
+	require.NoError(t, err)
+	tx, err := d1.Start(ctx, fmt.Sprintf("sip:user2@127.0.0.1:%d", ports.Port2),
+		dialog.WithSDP(getTestSDP(5200)))
+	require.NoError(t, err)
+	select {
+	case resp := <-tx.Responses():
+		require.Equal(t, 200, resp.StatusCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for 200 response")
+	}
+
+	var d2 *dialog.Dialog
+	select {
+	case d2 = <-parkedDialogs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for call on UA2")
+	}
+	require.Eventually(t, func() bool { return d2.State() == dialog.InCall }, 5*time.Second, 20*time.Millisecond)
+
+	// UA2 паркует вызов: удержание с музыкой на удержании (sendonly)
+	lot := dialog.NewParkLot("701", "702")
+	events := make(chan dialog.ParkEvent, 4)
+	lot.OnEvent(func(event dialog.ParkEvent) { events <- event })
+
+	holdSDP := strings.Replace(getTestSDP(7300), "a=sendrecv", "a=sendonly", 1)
+	call, err := lot.Park(ctx, d2, "", dialog.WithSDP(holdSDP))
+	require.NoError(t, err)
+	assert.Equal(t, "701", call.Slot)
+	assert.Equal(t, "701", d2.ParkSlot())
+	assert.Equal(t, dialog.ParkEventParked, (<-events).Type)
+
+	_, err = lot.Park(ctx, d2, "")
+	assert.Error(t, err, "Вызов уже припаркован")
+
+	// UA3 получает состояние слота и подхватывает вызов у UA1
+	entity := sip.Uri{Scheme: "sip", User: "701", Host: "127.0.0.1", Port: ports.Port2}
+	body, err := lot.DialogInfo("701", entity)
+	require.NoError(t, err)
+	info, err := dialog.ParseDialogInfo(body)
+	require.NoError(t, err)
+	require.Len(t, info.Dialogs, 1)
+	target, err := info.Dialogs[0].RemoteTarget()
+	require.NoError(t, err)
+
+	// Replaces несуществующего диалога отклоняется с 481
+	bogus, err := ua3.NewDialog(ctx)
+	require.NoError(t, err)
+	tx, err = bogus.Start(ctx, target.String(),
+		dialog.WithReplaces(dialog.ReplacesInfo{CallID: "unknown", ToTag: "1", FromTag: "2"}),
+		dialog.WithSDP(getTestSDP(9300)))
+	require.NoError(t, err)
+	select {
+	case resp := <-tx.Responses():
+		assert.Equal(t, sip.StatusCallTransactionDoesNotExists, resp.StatusCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for 481 response")
+	}
+
+	d3, err := ua3.NewDialog(ctx)
+	require.NoError(t, err)
+	tx, err = d3.Start(ctx, target.String(),
+		dialog.WithReplaces(info.Dialogs[0].Replaces()),
+		dialog.WithSDP(getTestSDP(9302)))
+	require.NoError(t, err)
+	select {
+	case resp := <-tx.Responses():
+		require.Equal(t, 200, resp.StatusCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for 200 response to INVITE with Replaces")
+	}
+
+	select {
+	case replaced := <-replacing:
+		require.NotNil(t, replaced)
+		assert.Equal(t, d1.ID(), replaced.ID())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for INVITE with Replaces on UA1")
+	}
+
+	// UA1 завершает замененный диалог, слот парковки освобождается
+	select {
+	case event := <-events:
+		assert.Equal(t, dialog.ParkEventReleased, event.Type)
+		assert.Equal(t, "701", event.Call.Slot)
+		require.NotNil(t, event.Cause)
+		assert.Equal(t, "Call replaced", event.Cause.Text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for park slot release")
+	}
+	assert.Empty(t, lot.Parked())
+	assert.Equal(t, dialog.InCall, d3.State())
+}
@@ -0,0 +1,115 @@
+package dialog_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReasonHeaderOnBye - BYE несет заголовок Reason, а принимающая сторона
+// получает причину завершения в OnRelease
+func TestReasonHeaderOnBye(t *testing.T) {
+	ua1, ua2, _, ports, cleanup := setupTest(t)
+	defer cleanup()
+
+	released := make(chan dialog.ReleaseCause, 1)
+	ua2.OnIncomingCall(func(d dialog.IDialog, tx dialog.IServerTX) {
+		d.OnRelease(func(cause dialog.ReleaseCause) {
+			released <- cause
+		})
+		require.NoError(t, tx.Accept(dialog.ResponseWithSDP(getTestSDP(7100))))
+	})
+
+	ctx := context.Background()
+	d1, err := ua1.NewDialog(ctx)
+	require.NoError(t, err)
+
+	tx, err := d1.Start(ctx, fmt.Sprintf("sip:user2@127.0.0.1:%d", ports.Port2),
+		dialog.WithSDP(getTestSDP(5100)))
+	require.NoError(t, err)
+
+	select {
+	case resp := <-tx.Responses():
+		require.NotNil(t, resp)
+		require.Equal(t, 200, resp.StatusCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for 200 response")
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// Причина передается удаленной стороне
+	require.NoError(t, d1.TerminateWithCause(dialog.MediaTimeoutCause()))
+
+	select {
+	case cause := <-released:
+		// Категория media_timeout локальна, по сети передается только Q.850 причина
+		assert.Equal(t, dialog.CategoryNetworkError, cause.Category)
+		assert.Equal(t, dialog.Q850RecoveryOnTimerExpiry, cause.Q850Cause)
+		assert.Equal(t, "Media timeout", cause.Text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for release on UA2")
+	}
+
+	cause := d1.ReleaseCause()
+	require.NotNil(t, cause)
+	assert.Equal(t, dialog.CategoryMediaTimeout, cause.Category)
+}
+
+// TestCallLimitBye - по исчерпании лимита длительности вызов завершается BYE
+// с заголовком Reason после предупреждения
+func TestCallLimitBye(t *testing.T) {
+	ua1, ua2, _, ports, cleanup := setupTest(t)
+	defer cleanup()
+
+	stages := make(chan dialog.CallLimitStage, 2)
+	ua2.OnIncomingCall(func(d dialog.IDialog, tx dialog.IServerTX) {
+		d.SetCallLimit(dialog.CallLimit{MaxDuration: 600 * time.Millisecond, WarningBefore: 300 * time.Millisecond})
+		d.OnCallLimit(func(event dialog.CallLimitEvent) {
+			stages <- event.Stage
+		})
+		require.NoError(t, tx.Accept(dialog.ResponseWithSDP(getTestSDP(7200))))
+	})
+
+	ctx := context.Background()
+	d1, err := ua1.NewDialog(ctx)
+	require.NoError(t, err)
+
+	released := make(chan dialog.ReleaseCause, 1)
+	d1.OnRelease(func(cause dialog.ReleaseCause) {
+		released <- cause
+	})
+
+	tx, err := d1.Start(ctx, fmt.Sprintf("sip:user2@127.0.0.1:%d", ports.Port2),
+		dialog.WithSDP(getTestSDP(5200)))
+	require.NoError(t, err)
+
+	select {
+	case resp := <-tx.Responses():
+		require.NotNil(t, resp)
+		require.Equal(t, 200, resp.StatusCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for 200 response")
+	}
+
+	for _, expected := range []dialog.CallLimitStage{dialog.CallLimitWarning, dialog.CallLimitReached} {
+		select {
+		case stage := <-stages:
+			assert.Equal(t, expected, stage)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for call limit %s", expected)
+		}
+	}
+
+	select {
+	case cause := <-released:
+		assert.Equal(t, dialog.Q850NormalClearing, cause.Q850Cause)
+		assert.Equal(t, "Call duration limit reached", cause.Text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for BYE on UA1")
+	}
+}
@@ -0,0 +1,101 @@
+package dialog_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSharedLineBargeIn - вызов на appearance общей линии UA2 и barge-in второго вызова UA1
+func TestSharedLineBargeIn(t *testing.T) {
+	ua1, ua2, _, ports, cleanup := setupTest(t)
+	defer cleanup()
+
+	aor := sip.Uri{Scheme: "sip", User: "line", Host: "127.0.0.1", Port: ports.Port2}
+	line := dialog.NewSharedLine(aor, 2)
+	ua2.SetSharedLine(line)
+
+	bargeIns := make(chan dialog.BargeIn, 1)
+	line.OnBargeIn(func(b dialog.BargeIn) {
+		// Приложение подключает медиа b.Joining к микшеру вызова b.Call
+		require.NoError(t, b.TX.Accept(dialog.ResponseWithSDP(getTestSDP(7402))))
+		bargeIns <- b
+	})
+
+	calls := make(chan *dialog.Dialog, 2)
+	ua2.OnIncomingCall(func(d dialog.IDialog, tx dialog.IServerTX) {
+		_, err := line.Attach(d.(*dialog.Dialog), 0)
+		require.NoError(t, err)
+		require.NoError(t, tx.Accept(dialog.ResponseWithSDP(getTestSDP(7400))))
+		calls <- d.(*dialog.Dialog)
+	})
+
+	ctx := context.Background()
+	target := fmt.Sprintf("sip:line@127.0.0.1:%d", ports.Port2)
+	call := func(port int, opts ...dialog.RequestOpt) *dialog.Dialog {
+		d, err := ua1.NewDialog(ctx)
+		require.NoError(t, err)
+		tx, err := d.Start(ctx, target, append(opts, dialog.WithSDP(getTestSDP(port)))...)
+		require.NoError(t, err)
+		select {
+		case resp := <-tx.Responses():
+			require.Equal(t, 200, resp.StatusCode)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout waiting for 200 response")
+		}
+		return d
+	}
+
+	first := call(5400)
+	var onLine *dialog.Dialog
+	select {
+	case onLine = <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for call on UA2")
+	}
+	require.Eventually(t, func() bool {
+		a, _ := line.Appearance(1)
+		return a.State == dialog.AppearanceActive
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 1, onLine.LineAppearance())
+
+	// Активный вызов публикуется в dialog-info с номером appearance
+	body, err := line.DialogInfo()
+	require.NoError(t, err)
+	info, err := dialog.ParseDialogInfo(body)
+	require.NoError(t, err)
+	require.Len(t, info.Dialogs, 1)
+	assert.Equal(t, 1, info.Dialogs[0].Appearance)
+	assert.Equal(t, dialog.DialogInfoConfirmed, info.Dialogs[0].State)
+
+	require.NoError(t, line.SetHeld(1, true))
+	a, _ := line.Appearance(1)
+	assert.Equal(t, dialog.AppearanceHeld, a.State)
+	require.NoError(t, line.SetHeld(1, false))
+
+	// Второй UA присоединяется к вызову на appearance 1
+	call(5402, dialog.WithHeaderString("Call-Info", fmt.Sprintf("<%s>;appearance-index=1", aor.String())))
+	select {
+	case b := <-bargeIns:
+		assert.Equal(t, 1, b.Appearance)
+		assert.Equal(t, onLine.ID(), b.Call.ID())
+		assert.Zero(t, b.Joining.LineAppearance(), "Присоединившийся вызов не занимает appearance")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for barge-in")
+	}
+	a, _ = line.Appearance(2)
+	assert.Equal(t, dialog.AppearanceIdle, a.State)
+
+	// Завершение вызова освобождает appearance
+	require.NoError(t, first.Terminate())
+	require.Eventually(t, func() bool {
+		a, _ := line.Appearance(1)
+		return a.State == dialog.AppearanceIdle
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,406 @@
+package dialog
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/arzzra/soft_phone/pkg/random"
+	"github.com/emiago/sipgo/sip"
+)
+
+// Заголовки передачи местоположения (RFC 6442)
+const (
+	HeaderGeolocation        = "Geolocation"
+	HeaderGeolocationRouting = "Geolocation-Routing"
+
+	// ContentTypePIDF - тип содержимого PIDF-LO (RFC 4119)
+	ContentTypePIDF = "application/pidf+xml"
+)
+
+// Пространства имен и идентификаторы PIDF-LO (RFC 4119, RFC 5491)
+const (
+	nsPIDF    = "urn:ietf:params:xml:ns:pidf"
+	nsGeopriv = "urn:ietf:params:xml:ns:pidf:geopriv10"
+
+	srsWGS84 = "urn:ogc:def:crs:EPSG::4326"
+	uomMeter = "urn:ogc:def:uom:EPSG::9001"
+)
+
+// GeoPoint - географические координаты WGS 84. Radius задает круг
+// неопределенности в метрах (gs:Circle), 0 - точка (gml:Point)
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+	Radius    float64
+}
+
+// CivicAddress - гражданский адрес (RFC 5139)
+type CivicAddress struct {
+	Country string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr country,omitempty"`
+	A1      string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr A1,omitempty"` // Регион
+	A2      string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr A2,omitempty"` // Район
+	A3      string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr A3,omitempty"` // Город
+	A4      string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr A4,omitempty"` // Район города
+	RD      string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr RD,omitempty"` // Улица
+	STS     string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr STS,omitempty"`
+	HNO     string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr HNO,omitempty"` // Номер дома
+	HNS     string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr HNS,omitempty"`
+	LMK     string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr LMK,omitempty"` // Ориентир
+	LOC     string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr LOC,omitempty"`
+	FLR     string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr FLR,omitempty"` // Этаж
+	ROOM    string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr ROOM,omitempty"`
+	NAM     string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr NAM,omitempty"` // Название
+	PC      string `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr PC,omitempty"`  // Индекс
+}
+
+// Location - местоположение из PIDF-LO
+type Location struct {
+	// Entity - субъект местоположения, например "pres:alice@example.com"
+	Entity string
+	// Point - координаты, если местоположение задано геодезически
+	Point *GeoPoint
+	// Civic - адрес, если местоположение задано гражданским адресом
+	Civic *CivicAddress
+	// Method - способ определения местоположения (GPS, Cell, Manual, ...)
+	Method string
+}
+
+// GeolocationInfo - местоположение, переданное во входящем запросе
+type GeolocationInfo struct {
+	// Locations - местоположения из частей PIDF-LO, на которые ссылается
+	// Geolocation (cid:)
+	Locations []Location
+	// References - ссылки на местоположение (http:, https:, sip:, pres:),
+	// которые приложение разрешает самостоятельно
+	References []string
+	// RoutingAllowed - значение Geolocation-Routing: nil, если заголовок
+	// отсутствует (RFC 6442 Section 3.2 трактует это как "no")
+	RoutingAllowed *bool
+}
+
+// GeolocationOptions - местоположение для исходящего запроса (RFC 6442)
+type GeolocationOptions struct {
+	// Location - местоположение по значению: PIDF-LO добавляется частью
+	// составного тела вместе с уже установленным телом (например SDP)
+	Location *Location
+	// References - ссылки на местоположение по ссылке
+	References []string
+	// RoutingAllowed - значение Geolocation-Routing. nil - заголовок не добавляется
+	RoutingAllowed *bool
+}
+
+// pidfPresence - документ PIDF-LO для сериализации
+type pidfPresence struct {
+	XMLName xml.Name  `xml:"urn:ietf:params:xml:ns:pidf presence"`
+	Entity  string    `xml:"entity,attr"`
+	Tuple   pidfTuple `xml:"urn:ietf:params:xml:ns:pidf tuple"`
+}
+
+type pidfTuple struct {
+	ID     string     `xml:"id,attr"`
+	Status pidfStatus `xml:"urn:ietf:params:xml:ns:pidf status"`
+}
+
+type pidfStatus struct {
+	Geopriv pidfGeopriv `xml:"urn:ietf:params:xml:ns:pidf:geopriv10 geopriv"`
+}
+
+type pidfGeopriv struct {
+	LocationInfo pidfLocationInfo `xml:"urn:ietf:params:xml:ns:pidf:geopriv10 location-info"`
+	Method       string           `xml:"urn:ietf:params:xml:ns:pidf:geopriv10 method,omitempty"`
+}
+
+type pidfLocationInfo struct {
+	Point  *gmlShape     `xml:"http://www.opengis.net/gml Point,omitempty"`
+	Circle *gmlShape     `xml:"http://www.opengis.net/pidflo/1.0 Circle,omitempty"`
+	Civic  *CivicAddress `xml:"urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr civicAddress,omitempty"`
+}
+
+type gmlShape struct {
+	SRSName string     `xml:"srsName,attr"`
+	Pos     string     `xml:"http://www.opengis.net/gml pos"`
+	Radius  *gsMeasure `xml:"http://www.opengis.net/pidflo/1.0 radius,omitempty"`
+}
+
+type gsMeasure struct {
+	UOM   string  `xml:"uom,attr"`
+	Value float64 `xml:",chardata"`
+}
+
+// MarshalPIDF сериализует местоположение в документ PIDF-LO
+func (l Location) MarshalPIDF() ([]byte, error) {
+	if l.Point == nil && l.Civic == nil {
+		return nil, fmt.Errorf("location has neither point nor civic address")
+	}
+	entity := l.Entity
+	if entity == "" {
+		entity = "pres:anonymous@anonymous.invalid"
+	}
+
+	doc := pidfPresence{Entity: entity, Tuple: pidfTuple{ID: "loc"}}
+	doc.Tuple.Status.Geopriv.Method = l.Method
+	info := &doc.Tuple.Status.Geopriv.LocationInfo
+	info.Civic = l.Civic
+	if p := l.Point; p != nil {
+		shape := &gmlShape{
+			SRSName: srsWGS84,
+			Pos: strconv.FormatFloat(p.Latitude, 'f', -1, 64) + " " +
+				strconv.FormatFloat(p.Longitude, 'f', -1, 64),
+		}
+		if p.Radius > 0 {
+			shape.Radius = &gsMeasure{UOM: uomMeter, Value: p.Radius}
+			info.Circle = shape
+		} else {
+			info.Point = shape
+		}
+	}
+
+	data, err := xml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal PIDF-LO: %w", err)
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// ParsePIDF разбирает документ PIDF-LO. Элементы geopriv ищутся в tuple,
+// device и person (RFC 5491), каждый дает отдельное местоположение
+func ParsePIDF(data []byte) ([]Location, error) {
+	var (
+		entity    string
+		locations []Location
+	)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid PIDF-LO: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case start.Name.Space == nsPIDF && start.Name.Local == "presence":
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "entity" {
+					entity = attr.Value
+				}
+			}
+		case start.Name.Space == nsGeopriv && start.Name.Local == "geopriv":
+			var geopriv pidfGeopriv
+			if err := dec.DecodeElement(&geopriv, &start); err != nil {
+				return nil, fmt.Errorf("invalid PIDF-LO geopriv: %w", err)
+			}
+			location, err := geopriv.location()
+			if err != nil {
+				return nil, err
+			}
+			location.Entity = entity
+			locations = append(locations, location)
+		}
+	}
+
+	if entity == "" {
+		return nil, fmt.Errorf("invalid PIDF-LO: presence element not found")
+	}
+	return locations, nil
+}
+
+func (g pidfGeopriv) location() (Location, error) {
+	location := Location{Method: strings.TrimSpace(g.Method), Civic: g.LocationInfo.Civic}
+	shape := g.LocationInfo.Point
+	if shape == nil {
+		shape = g.LocationInfo.Circle
+	}
+	if shape != nil {
+		fields := strings.Fields(shape.Pos)
+		if len(fields) < 2 {
+			return location, fmt.Errorf("invalid PIDF-LO position %q", shape.Pos)
+		}
+		lat, errLat := strconv.ParseFloat(fields[0], 64)
+		lon, errLon := strconv.ParseFloat(fields[1], 64)
+		if errLat != nil || errLon != nil {
+			return location, fmt.Errorf("invalid PIDF-LO position %q", shape.Pos)
+		}
+		location.Point = &GeoPoint{Latitude: lat, Longitude: lon}
+		if shape.Radius != nil {
+			location.Point.Radius = shape.Radius.Value
+		}
+	}
+	return location, nil
+}
+
+// WithGeolocation добавляет местоположение к запросу (RFC 6442): заголовки
+// Geolocation и Geolocation-Routing и, для местоположения по значению,
+// часть PIDF-LO составного тела. Уже установленное тело (например через
+// WithSDP) становится первой частью, поэтому опция указывается после него:
+//
+//	tx, err := d.Start(ctx, "sip:112@psap.example.com",
+//	    dialog.WithSDP(sdp),
+//	    dialog.WithGeolocation(dialog.GeolocationOptions{Location: &location}))
+func WithGeolocation(opts GeolocationOptions) RequestOpt {
+	return func(msg sip.Message) {
+		var refs []string
+		if opts.Location != nil {
+			pidf, err := opts.Location.MarshalPIDF()
+			if err == nil {
+				contentID := geolocationContentID(msg)
+				attachLocationBody(msg, pidf, contentID)
+				refs = append(refs, "<cid:"+contentID+">")
+			}
+		}
+		for _, ref := range opts.References {
+			refs = append(refs, "<"+ref+">")
+		}
+		if len(refs) > 0 {
+			msg.AppendHeader(sip.NewHeader(HeaderGeolocation, strings.Join(refs, ", ")))
+		}
+		if opts.RoutingAllowed != nil {
+			value := "no"
+			if *opts.RoutingAllowed {
+				value = "yes"
+			}
+			msg.AppendHeader(sip.NewHeader(HeaderGeolocationRouting, value))
+		}
+	}
+}
+
+// geolocationContentIDs нумерует части PIDF-LO запросов, CSeq которых
+// назначается при отправке
+var geolocationContentIDs atomic.Uint64
+
+// geolocationContentID возвращает Content-ID части PIDF-LO. Тег From
+// создан источником случайных данных UACUAS, поэтому Content-ID уникален
+// для запросов диалога и воспроизводим с Config.RandomSource. Без тега From
+// используется crypto/rand
+func geolocationContentID(msg sip.Message) string {
+	id := ""
+	if from := msg.From(); from != nil && from.Params != nil {
+		id, _ = from.Params.Get("tag")
+	}
+	switch cseq := msg.CSeq(); {
+	case id == "":
+		id = random.String(nil, 12)
+	case cseq != nil:
+		id = fmt.Sprintf("%s.%d", id, cseq.SeqNo)
+	default:
+		id = fmt.Sprintf("%s.n%d", id, geolocationContentIDs.Add(1))
+	}
+	return "loc-" + id + "@" + geolocationHost(msg)
+}
+
+func geolocationHost(msg sip.Message) string {
+	if from := msg.From(); from != nil && from.Address.Host != "" {
+		return from.Address.Host
+	}
+	return "localhost"
+}
+
+// attachLocationBody добавляет PIDF-LO к телу сообщения, объединяя его с
+// существующим телом в multipart/mixed
+func attachLocationBody(msg sip.Message, pidf []byte, contentID string) {
+	location := NewBody(ContentTypePIDF, pidf)
+	location.SetHeader("Content-ID", "<"+contentID+">")
+
+	parts := []*Body{location}
+	if existing := extractBody(msg); existing != nil {
+		parts = append([]*Body{existing}, parts...)
+	}
+	body := NewMultipartBody(parts...)
+
+	if m, ok := msg.(interface{ RemoveHeader(name string) bool }); ok {
+		for m.RemoveHeader("Content-Type") {
+		}
+		for m.RemoveHeader("Content-Length") {
+		}
+	}
+	WithMultipartBody(body)(msg)
+}
+
+// ParseGeolocation извлекает местоположение из запроса: ссылки заголовков
+// Geolocation, части PIDF-LO тела, на которые они ссылаются (cid:), и
+// Geolocation-Routing. Возвращает nil, если запрос не содержит Geolocation
+func ParseGeolocation(req *sip.Request) (*GeolocationInfo, error) {
+	headers := req.GetHeaders(HeaderGeolocation)
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	info := &GeolocationInfo{}
+	if routing := req.GetHeader(HeaderGeolocationRouting); routing != nil {
+		allowed := strings.EqualFold(strings.TrimSpace(routing.Value()), "yes")
+		info.RoutingAllowed = &allowed
+	}
+
+	var contentIDs []string
+	for _, h := range headers {
+		for _, value := range strings.Split(h.Value(), ",") {
+			ref := strings.TrimSpace(value)
+			if i := strings.Index(ref, ">"); strings.HasPrefix(ref, "<") && i > 0 {
+				ref = ref[1:i]
+			}
+			if ref == "" {
+				continue
+			}
+			if cid, ok := strings.CutPrefix(ref, "cid:"); ok {
+				contentIDs = append(contentIDs, cid)
+				continue
+			}
+			info.References = append(info.References, ref)
+		}
+	}
+	if len(contentIDs) == 0 {
+		return info, nil
+	}
+
+	body := extractBody(req)
+	if body == nil {
+		return info, fmt.Errorf("geolocation references cid but request has no body")
+	}
+	parts, err := body.Parts()
+	if err != nil {
+		return info, err
+	}
+	for _, cid := range contentIDs {
+		part := findContentID(parts, cid)
+		if part == nil {
+			return info, fmt.Errorf("geolocation body part cid:%s not found", cid)
+		}
+		locations, err := ParsePIDF(part.Content())
+		if err != nil {
+			return info, err
+		}
+		info.Locations = append(info.Locations, locations...)
+	}
+	return info, nil
+}
+
+// findContentID ищет часть тела по Content-ID (RFC 2392)
+func findContentID(parts []*Body, cid string) *Body {
+	for _, part := range parts {
+		id := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(part.Header("Content-ID")), "<"), ">")
+		if id == cid {
+			return part
+		}
+	}
+	return nil
+}
+
+// Geolocation возвращает местоположение, переданное во входящем INVITE
+// (RFC 6442). Возвращает nil, если INVITE не содержит Geolocation или
+// диалог исходящий
+func (s *Dialog) Geolocation() (*GeolocationInfo, error) {
+	if s.uaType != UAS || s.initReq == nil {
+		return nil, nil
+	}
+	return ParseGeolocation(s.initReq)
+}
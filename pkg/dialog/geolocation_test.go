@@ -0,0 +1,142 @@
+package dialog
+
+import (
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPIDFDevice - PIDF-LO с элементом device и префиксами пространств имен (RFC 5491)
+const testPIDFDevice = `<?xml version="1.0" encoding="UTF-8"?>
+<presence xmlns="urn:ietf:params:xml:ns:pidf"
+    xmlns:dm="urn:ietf:params:xml:ns:pidf:data-model"
+    xmlns:gp="urn:ietf:params:xml:ns:pidf:geopriv10"
+    xmlns:ca="urn:ietf:params:xml:ns:pidf:geopriv10:civicAddr"
+    xmlns:gml="http://www.opengis.net/gml"
+    xmlns:gs="http://www.opengis.net/pidflo/1.0"
+    entity="pres:alice@example.com">
+  <dm:device id="phone">
+    <gp:geopriv>
+      <gp:location-info>
+        <gs:Circle srsName="urn:ogc:def:crs:EPSG::4326">
+          <gml:pos>55.7522 37.6156</gml:pos>
+          <gs:radius uom="urn:ogc:def:uom:EPSG::9001">35</gs:radius>
+        </gs:Circle>
+        <ca:civicAddress>
+          <ca:country>RU</ca:country>
+          <ca:A3>Москва</ca:A3>
+          <ca:RD>Тверская</ca:RD>
+          <ca:HNO>1</ca:HNO>
+        </ca:civicAddress>
+      </gp:location-info>
+      <gp:usage-rules/>
+      <gp:method>GPS</gp:method>
+    </gp:geopriv>
+  </dm:device>
+</presence>`
+
+func TestParsePIDF(t *testing.T) {
+	locations, err := ParsePIDF([]byte(testPIDFDevice))
+	require.NoError(t, err)
+	require.Len(t, locations, 1)
+
+	loc := locations[0]
+	assert.Equal(t, "pres:alice@example.com", loc.Entity)
+	assert.Equal(t, "GPS", loc.Method)
+	require.NotNil(t, loc.Point)
+	assert.Equal(t, GeoPoint{Latitude: 55.7522, Longitude: 37.6156, Radius: 35}, *loc.Point)
+	require.NotNil(t, loc.Civic)
+	assert.Equal(t, "RU", loc.Civic.Country)
+	assert.Equal(t, "Москва", loc.Civic.A3)
+	assert.Equal(t, "Тверская", loc.Civic.RD)
+
+	_, err = ParsePIDF([]byte(`<presence xmlns="urn:example">`))
+	assert.Error(t, err)
+	_, err = ParsePIDF([]byte(`<presence xmlns="urn:ietf:params:xml:ns:pidf" entity="pres:a@b">
+		<tuple id="t"><status><geopriv xmlns="urn:ietf:params:xml:ns:pidf:geopriv10"><location-info>
+		<Point xmlns="http://www.opengis.net/gml"><pos>north</pos></Point>
+		</location-info></geopriv></status></tuple></presence>`))
+	assert.Error(t, err, "Некорректные координаты")
+}
+
+func TestLocationMarshalPIDF(t *testing.T) {
+	location := Location{
+		Entity: "pres:bob@example.com",
+		Point:  &GeoPoint{Latitude: 59.9386, Longitude: 30.3141},
+		Civic:  &CivicAddress{Country: "RU", A3: "Санкт-Петербург", FLR: "3"},
+		Method: "Manual",
+	}
+	data, err := location.MarshalPIDF()
+	require.NoError(t, err)
+
+	parsed, err := ParsePIDF(data)
+	require.NoError(t, err)
+	require.Len(t, parsed, 1)
+	assert.Equal(t, location, parsed[0])
+
+	_, err = Location{}.MarshalPIDF()
+	assert.Error(t, err)
+}
+
+func TestWithGeolocation(t *testing.T) {
+	routing := true
+	location := Location{Entity: "pres:alice@127.0.0.1", Point: &GeoPoint{Latitude: 55.75, Longitude: 37.62, Radius: 100}}
+
+	req := newTestRequest(sip.INVITE)
+	WithSDP(testMultipartSDP)(req)
+	WithGeolocation(GeolocationOptions{
+		Location:       &location,
+		References:     []string{"https://lis.example.com/loc/123"},
+		RoutingAllowed: &routing,
+	})(req)
+
+	// Запрос разбирается так же, как при приеме из сети
+	parsed, err := sip.ParseMessage([]byte(req.String()))
+	require.NoError(t, err)
+	incoming := parsed.(*sip.Request)
+	assert.Len(t, incoming.GetHeaders("Content-Type"), 1)
+
+	d := &Dialog{uaType: UAS, initReq: incoming}
+	info, err := d.Geolocation()
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	require.Len(t, info.Locations, 1)
+	assert.Equal(t, location, info.Locations[0])
+	assert.Equal(t, []string{"https://lis.example.com/loc/123"}, info.References)
+	require.NotNil(t, info.RoutingAllowed)
+	assert.True(t, *info.RoutingAllowed)
+
+	// SDP остается доступен как часть составного тела
+	d.SetRemoteSDP(extractBody(incoming).ContentType(), incoming.Body())
+	sdp := d.RemoteSDP()
+	assert.Equal(t, testMultipartSDP, string(sdp.Content()))
+}
+
+func TestParseGeolocationReferences(t *testing.T) {
+	req := newTestRequest(sip.INVITE)
+	info, err := ParseGeolocation(req)
+	assert.NoError(t, err)
+	assert.Nil(t, info, "Без заголовка Geolocation")
+
+	req.AppendHeader(sip.NewHeader(HeaderGeolocation, "<sips:3sdefrhy2jj7@lis.example.com;transport=tls>"))
+	req.AppendHeader(sip.NewHeader(HeaderGeolocationRouting, "no"))
+	info, err = ParseGeolocation(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sips:3sdefrhy2jj7@lis.example.com;transport=tls"}, info.References)
+	require.NotNil(t, info.RoutingAllowed)
+	assert.False(t, *info.RoutingAllowed)
+
+	// Ссылка cid: без соответствующей части тела
+	req = newTestRequest(sip.INVITE)
+	req.AppendHeader(sip.NewHeader(HeaderGeolocation, "<cid:missing@example.com>"))
+	_, err = ParseGeolocation(req)
+	assert.Error(t, err)
+
+	// Исходящий диалог не содержит входящего местоположения
+	d := &Dialog{uaType: UAC, initReq: req}
+	info, err = d.Geolocation()
+	assert.NoError(t, err)
+	assert.Nil(t, info)
+}
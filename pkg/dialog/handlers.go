@@ -2,9 +2,11 @@ package dialog
 
 import (
 	"fmt"
+	"github.com/emiago/sipgo"
 	"github.com/emiago/sipgo/sip"
 	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,6 +17,56 @@ var (
 	CallDoesNotExist = "transaction not found"
 )
 
+// withCapabilities проверяет входящий запрос по набору возможностей перед обработкой.
+// Запросы выключенных методов отклоняются с 405, запросы с Require, содержащим
+// неподдерживаемые расширения, отклоняются с 420 (RFC 3261 Section 8.2.2),
+// запросы со сжатым телом, которое не удалось распаковать, - с 415 или 400.
+func (u *UACUAS) withCapabilities(handler sipgo.RequestHandler) sipgo.RequestHandler {
+	return func(req *sip.Request, tx sip.ServerTransaction) {
+		if !u.capabilities.MethodAllowed(req.Method) {
+			u.handleMethodNotAllowed(req, tx)
+			return
+		}
+
+		if err := u.prepareIncoming(req); err != nil {
+			u.rejectUndecodableBody(req, tx, err)
+			return
+		}
+
+		// Require не применяется к ACK и CANCEL (RFC 3261 Section 8.2.2.3)
+		if req.Method != sip.ACK && req.Method != sip.CANCEL {
+			if unsupported := u.capabilities.Unsupported(req); len(unsupported) > 0 {
+				resp := sip.NewResponseFromRequest(req, sip.StatusBadExtension, "Bad Extension", nil)
+				resp.AppendHeader(sip.NewHeader("Unsupported", strings.Join(unsupported, ", ")))
+				if err := tx.Respond(resp); err != nil {
+					slog.Error("Ошибка отправки ответа 420",
+						slog.Any("error", err),
+						slog.String("Method", req.Method.String()))
+				}
+				return
+			}
+		}
+
+		handler(req, tx)
+	}
+}
+
+// handleMethodNotAllowed отвечает 405 с заголовком Allow на запросы неподдерживаемых методов
+func (u *UACUAS) handleMethodNotAllowed(req *sip.Request, tx sip.ServerTransaction) {
+	// На ACK ответ не отправляется
+	if req.Method == sip.ACK || tx == nil {
+		return
+	}
+
+	resp := sip.NewResponseFromRequest(req, sip.StatusMethodNotAllowed, "Method Not Allowed", nil)
+	resp.AppendHeader(u.capabilities.AllowHeader())
+	if err := tx.Respond(resp); err != nil {
+		slog.Error("Ошибка отправки ответа 405",
+			slog.Any("error", err),
+			slog.String("Method", req.Method.String()))
+	}
+}
+
 // handleInvite обрабатывает входящие INVITE запросы
 func (u *UACUAS) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 	// Проверяем, не остановлен ли UACUAS
@@ -28,8 +80,8 @@ func (u *UACUAS) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 	u.stopMutex.Unlock()
 
 	slog.Debug("handleInvite",
-		slog.String("req", req.String()),
-		slog.String("body", string(req.Body())))
+		slog.String("req", u.redact(req.String())),
+		slog.String("body", u.redact(string(req.Body()))))
 
 	callID := req.CallID()
 	if callID == nil {
@@ -69,6 +121,7 @@ func (u *UACUAS) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 
 				// Сохраняем re-INVITE транзакцию
 				sessia.setReInviteTX(ltx)
+				sessia.refreshRemoteTarget(req.Contact())
 
 				// Извлекаем тело из re-INVITE запроса
 				if body := extractBody(req); body != nil {
@@ -122,7 +175,48 @@ func (u *UACUAS) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 			}
 			return
 		} else {
+			// Вызов абонента, зарегистрированного на встроенном регистраторе
+			if registrar := u.Registrar(); registrar != nil && registrar.routeInvite(req, tx) {
+				return
+			}
+
+			// Проверка Identity (STIR/SHAKEN) может отклонить вызов
+			identity, verified := u.applyIdentityVerification(req, tx)
+			if !verified {
+				return
+			}
+
+			// Политика входящих вызовов может отклонить или перенаправить вызов
+			decision, accepted := u.applyCallPolicy(req, tx)
+			if !accepted {
+				return
+			}
+
+			// INVITE с Replaces заменяет существующий диалог (RFC 3891)
+			replaced, status := u.findReplacedDialog(req)
+			if status != 0 {
+				resp := sip.NewResponseFromRequest(req, status, "", nil)
+				if err := tx.Respond(resp); err != nil {
+					slog.Error("Не удалось отклонить INVITE с Replaces",
+						slog.Any("error", err),
+						slog.String("CallID", callID.String()),
+						slog.Int("status", status))
+				}
+				return
+			}
+
 			sessionDialog := u.newUAS(req, tx)
+			sessionDialog.replaces = replaced
+			sessionDialog.policyDecision = decision
+			sessionDialog.identity = identity
+			// Вызов на appearance общей линии: исходящий вызов после захвата
+			// appearance или barge-in к активному вызову
+			var bargeInCall *Dialog
+			var bargeInIndex int
+			line := u.SharedLine()
+			if line != nil {
+				bargeInCall, bargeInIndex = line.routeInvite(req, sessionDialog)
+			}
 			u.dialogs.Put(*callID, sessionDialog.LocalTag(), GetBranchID(req), sessionDialog)
 			lTX := newTX(req, tx, sessionDialog)
 			sessionDialog.setFirstTX(lTX)
@@ -146,6 +240,15 @@ func (u *UACUAS) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 				slog.Error("Не удалось установить состояние Ringing", "error", err)
 				return
 			}
+			if bargeInCall != nil {
+				line.dispatchBargeIn(BargeIn{
+					Appearance: bargeInIndex,
+					Call:       bargeInCall,
+					Joining:    sessionDialog,
+					TX:         lTX,
+				})
+				return
+			}
 			// Вызываем колбэк о новом входящем вызове
 			if u.cb != nil {
 				u.cb(sessionDialog, lTX)
@@ -160,7 +263,7 @@ func (u *UACUAS) handleInvite(req *sip.Request, tx sip.ServerTransaction) {
 // handleCancel обрабатывает входящие CANCEL запросы
 func (u *UACUAS) handleCancel(req *sip.Request, tx sip.ServerTransaction) {
 	slog.Debug("handleCancel",
-		slog.String("req", req.String()), slog.String("body", string(req.Body())))
+		slog.String("req", u.redact(req.String())), slog.String("body", u.redact(string(req.Body()))))
 
 	// CANCEL завершает диалог, который еще не установлен (до получения 200 OK на INVITE)
 
@@ -176,6 +279,10 @@ func (u *UACUAS) handleCancel(req *sip.Request, tx sip.ServerTransaction) {
 
 	tagTo := GetToTag(req)
 	sess, ok := u.dialogs.Get(*callID, tagTo)
+	if !ok && tagTo == "" {
+		// CANCEL повторяет ветку Via отменяемого INVITE (RFC 3261 Section 9.1)
+		sess, ok = u.dialogs.GetWithTX(GetBranchID(req))
+	}
 	if ok {
 		ltx := newTX(req, tx, sess)
 		if ltx == nil {
@@ -185,10 +292,12 @@ func (u *UACUAS) handleCancel(req *sip.Request, tx sip.ServerTransaction) {
 			return
 		}
 		// Изменяем состояние диалога на Terminating
+		cause := releaseCauseFromRequest(req, CancelledCause())
 		reason := StateTransitionReason{
 			Reason:  "CANCEL received",
 			Method:  sip.CANCEL,
 			Details: "Call cancelled before answer",
+			Cause:   &cause,
 		}
 		err := sess.setStateWithReason(Terminating, ltx, reason)
 		if err != nil {
@@ -248,8 +357,8 @@ func (u *UACUAS) handleCancel(req *sip.Request, tx sip.ServerTransaction) {
 // handleBye обрабатывает входящие BYE запросы
 func (u *UACUAS) handleBye(req *sip.Request, tx sip.ServerTransaction) {
 	slog.Debug("handleBye",
-		slog.String("req", req.String()),
-		slog.String("body", string(req.Body())))
+		slog.String("req", u.redact(req.String())),
+		slog.String("body", u.redact(string(req.Body()))))
 
 	callID := req.CallID()
 	if callID == nil {
@@ -279,10 +388,12 @@ func (u *UACUAS) handleBye(req *sip.Request, tx sip.ServerTransaction) {
 	if ltx != nil {
 		// Обрабатываем BYE в рамках диалога
 		// Изменяем состояние диалога на Terminating
+		cause := releaseCauseFromRequest(req, NormalClearingCause())
 		reason := StateTransitionReason{
 			Reason:  "BYE received from remote party",
 			Method:  sip.BYE,
 			Details: fmt.Sprintf("Remote party %s terminated the call", req.From().Address.String()),
+			Cause:   &cause,
 		}
 		err := sess.setStateWithReason(Terminating, ltx, reason)
 		if err != nil {
@@ -332,8 +443,8 @@ func (u *UACUAS) handleBye(req *sip.Request, tx sip.ServerTransaction) {
 // обработка ACK на ответ клиента на 200 OK
 func (u *UACUAS) handleACK(req *sip.Request, tx sip.ServerTransaction) {
 	slog.Debug("handleAck",
-		slog.String("request", req.String()),
-		slog.String("body", string(req.Body())))
+		slog.String("request", u.redact(req.String())),
+		slog.String("body", u.redact(string(req.Body()))))
 
 	callID := req.CallID()
 	if callID != nil {
@@ -372,14 +483,15 @@ func (u *UACUAS) handleACK(req *sip.Request, tx sip.ServerTransaction) {
 // handleUpdate обрабатывает входящие UPDATE запросы
 func (u *UACUAS) handleUpdate(req *sip.Request, tx sip.ServerTransaction) {
 	slog.Debug("handleUpdate",
-		slog.String("req", req.String()),
-		slog.String("body", string(req.Body())))
+		slog.String("req", u.redact(req.String())),
+		slog.String("body", u.redact(string(req.Body()))))
 
 	// Пытаемся найти диалог для UPDATE
 	callID := req.CallID()
 	if callID != nil {
 		tagTo := GetToTag(req)
 		if sess, ok := u.dialogs.Get(*callID, tagTo); ok {
+			sess.refreshRemoteTarget(req.Contact())
 			// Извлекаем тело из UPDATE запроса
 			if body := extractBody(req); body != nil {
 				// Сохраняем тело от удаленной стороны
@@ -404,10 +516,12 @@ func (u *UACUAS) handleUpdate(req *sip.Request, tx sip.ServerTransaction) {
 // handleOptions обрабатывает входящие OPTIONS запросы
 func (u *UACUAS) handleOptions(req *sip.Request, tx sip.ServerTransaction) {
 	slog.Debug("handleOptions",
-		slog.String("req", req.String()),
-		slog.String("body", string(req.Body())))
+		slog.String("req", u.redact(req.String())),
+		slog.String("body", u.redact(string(req.Body()))))
 
 	response := sip.NewResponseFromRequest(req, sip.StatusOK, "", nil)
+	// Ответ на OPTIONS описывает возможности UA (RFC 3261 Section 11.2)
+	u.capabilities.appendCapabilityHeaders(response)
 	err := tx.Respond(response)
 	if err != nil {
 		slog.Error("Ошибка отправки ответа на OPTIONS",
@@ -416,11 +530,125 @@ func (u *UACUAS) handleOptions(req *sip.Request, tx sip.ServerTransaction) {
 	}
 }
 
+// handleRefer обрабатывает входящие REFER запросы внутри диалога.
+// Запрос передается обработчику запросов диалога (OnRequestHandler), который
+// принимает решение о переводе вызова.
+func (u *UACUAS) handleRefer(req *sip.Request, tx sip.ServerTransaction) {
+	slog.Debug("handleRefer",
+		slog.String("req", u.redact(req.String())))
+
+	callID := req.CallID()
+	if callID == nil {
+		resp := sip.NewResponseFromRequest(req, sip.StatusBadRequest, CallIDDoesNotExist, nil)
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки ответа на REFER", slog.Any("error", err))
+		}
+		return
+	}
+
+	sess, ok := u.dialogs.Get(*callID, GetToTag(req))
+	if !ok {
+		resp := sip.NewResponseFromRequest(req, sip.StatusCallTransactionDoesNotExists, "Диалог не найден", nil)
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки ответа 481 на REFER",
+				slog.Any("error", err),
+				slog.String("CallID", callID.String()))
+		}
+		return
+	}
+
+	sess.handlersMu.Lock()
+	handler := sess.requestHandler
+	sess.handlersMu.Unlock()
+
+	if handler == nil {
+		// Без обработчика выполнить перевод некому
+		resp := sip.NewResponseFromRequest(req, sip.StatusNotImplemented, "Not Implemented", nil)
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки ответа 501 на REFER",
+				slog.Any("error", err),
+				slog.String("CallID", callID.String()))
+		}
+		return
+	}
+
+	if ltx := newTX(req, tx, sess); ltx != nil {
+		handler(ltx)
+	}
+}
+
+// handleInfo обрабатывает входящие INFO запросы внутри диалога (RFC 6086).
+// Запрос передается обработчику запросов диалога (OnRequestHandler), например
+// для DTMF в теле application/dtmf-relay (ParseDTMFInfo). Без обработчика
+// INFO подтверждается ответом 200 OK.
+func (u *UACUAS) handleInfo(req *sip.Request, tx sip.ServerTransaction) {
+	slog.Debug("handleInfo",
+		slog.String("req", u.redact(req.String())),
+		slog.String("body", u.redact(string(req.Body()))))
+
+	callID := req.CallID()
+	if callID == nil {
+		resp := sip.NewResponseFromRequest(req, sip.StatusBadRequest, CallIDDoesNotExist, nil)
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки ответа на INFO", slog.Any("error", err))
+		}
+		return
+	}
+
+	sess, ok := u.dialogs.Get(*callID, GetToTag(req))
+	if !ok {
+		resp := sip.NewResponseFromRequest(req, sip.StatusCallTransactionDoesNotExists, CallDoesNotExist, nil)
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки ответа 481 на INFO",
+				slog.Any("error", err),
+				slog.String("CallID", callID.String()))
+		}
+		return
+	}
+
+	sess.handlersMu.Lock()
+	handler := sess.requestHandler
+	sess.handlersMu.Unlock()
+
+	if handler == nil {
+		resp := sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil)
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки ответа 200 OK на INFO",
+				slog.Any("error", err),
+				slog.String("CallID", callID.String()))
+		}
+		return
+	}
+
+	if ltx := newTX(req, tx, sess); ltx != nil {
+		handler(ltx)
+	}
+}
+
+// handlePrack обрабатывает входящие PRACK запросы (RFC 3262)
+func (u *UACUAS) handlePrack(req *sip.Request, tx sip.ServerTransaction) {
+	slog.Debug("handlePrack",
+		slog.String("req", u.redact(req.String())))
+
+	status := sip.StatusOK
+	reason := "OK"
+	if callID := req.CallID(); callID == nil {
+		status, reason = sip.StatusBadRequest, CallIDDoesNotExist
+	} else if _, ok := u.dialogs.Get(*callID, GetToTag(req)); !ok {
+		status, reason = sip.StatusCallTransactionDoesNotExists, CallDoesNotExist
+	}
+
+	resp := sip.NewResponseFromRequest(req, status, reason, nil)
+	if err := tx.Respond(resp); err != nil {
+		slog.Error("Ошибка отправки ответа на PRACK", slog.Any("error", err))
+	}
+}
+
 // handleNotify обрабатывает входящие NOTIFY запросы
 func (u *UACUAS) handleNotify(req *sip.Request, tx sip.ServerTransaction) {
 	slog.Debug("handleNotify",
-		slog.String("req", req.String()),
-		slog.String("body", string(req.Body())))
+		slog.String("req", u.redact(req.String())),
+		slog.String("body", u.redact(string(req.Body()))))
 
 	// Пытаемся найти диалог для NOTIFY
 	callID := req.CallID()
@@ -449,8 +677,13 @@ func (u *UACUAS) handleNotify(req *sip.Request, tx sip.ServerTransaction) {
 // handleRegister обрабатывает входящие REGISTER запросы
 func (u *UACUAS) handleRegister(req *sip.Request, tx sip.ServerTransaction) {
 	slog.Debug("handleRegister",
-		slog.String("req", req.String()),
-		slog.String("body", string(req.Body())))
+		slog.String("req", u.redact(req.String())),
+		slog.String("body", u.redact(string(req.Body()))))
+
+	if registrar := u.Registrar(); registrar != nil {
+		registrar.handleRegister(req, tx)
+		return
+	}
 
 	// REGISTER обычно используется для регистрации на SIP сервере
 	// В контексте софтфона это может быть не нужно, но добавим базовую обработку
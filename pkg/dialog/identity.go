@@ -0,0 +1,406 @@
+package dialog
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	// HeaderIdentity - заголовок Identity (RFC 8224)
+	HeaderIdentity = "Identity"
+
+	// PassportTypeShaken - расширение PASSporT для STIR/SHAKEN (RFC 8588)
+	PassportTypeShaken = "shaken"
+	// PassportAlgES256 - единственный алгоритм подписи SHAKEN
+	PassportAlgES256 = "ES256"
+
+	// DefaultIdentityMaxAge - допустимый возраст PASSporT (RFC 8224 Section 6.2.1)
+	DefaultIdentityMaxAge = 60 * time.Second
+	// DefaultIdentityVerifyTimeout - время на проверку подписи по умолчанию
+	DefaultIdentityVerifyTimeout = 5 * time.Second
+
+	// statusStaleDate - 403 Stale Date (RFC 8224 Section 6.2.2)
+	statusStaleDate = 403
+	// statusInvalidIdentity - 438 Invalid Identity Header (RFC 8224 Section 13.3)
+	statusInvalidIdentity = 438
+)
+
+// Уровни заверения вызова SHAKEN (RFC 8588 Section 4)
+const (
+	AttestationFull    = "A" // Оператор знает абонента и его право на номер
+	AttestationPartial = "B" // Оператор знает абонента, но не право на номер
+	AttestationGateway = "C" // Вызов получен через шлюз
+)
+
+// PassportHeader - заголовок JWS PASSporT (RFC 8225 Section 4)
+type PassportHeader struct {
+	Alg string `json:"alg"`
+	Ppt string `json:"ppt,omitempty"`
+	Typ string `json:"typ"`
+	X5U string `json:"x5u"`
+}
+
+// PassportOrig - идентификатор вызывающего абонента
+type PassportOrig struct {
+	TN  string `json:"tn,omitempty"`
+	URI string `json:"uri,omitempty"`
+}
+
+// PassportDest - идентификаторы вызываемых абонентов
+type PassportDest struct {
+	TN  []string `json:"tn,omitempty"`
+	URI []string `json:"uri,omitempty"`
+}
+
+// PassportClaims - утверждения PASSporT (RFC 8225, RFC 8588)
+type PassportClaims struct {
+	Attest string       `json:"attest,omitempty"`
+	Dest   PassportDest `json:"dest"`
+	IAT    int64        `json:"iat"`
+	Orig   PassportOrig `json:"orig"`
+	OrigID string       `json:"origid,omitempty"`
+}
+
+// Identity - разобранный заголовок Identity с PASSporT
+type Identity struct {
+	Header PassportHeader
+	Claims PassportClaims
+	// Info - URL сертификата из параметра info
+	Info string
+	// Params - параметры заголовка Identity (alg, ppt, ...)
+	Params map[string]string
+
+	signingInput []byte
+	signature    []byte
+}
+
+// IdentityVerifier проверяет подпись PASSporT входящего INVITE. Загрузка и
+// проверка сертификата (CertURL) выполняется приложением, подпись проверяется
+// через Identity.VerifySignature. Ошибка означает непрошедшую проверку
+type IdentityVerifier func(ctx context.Context, identity *Identity) error
+
+// VerificationStatus - результат проверки Identity (значения verstat, ATIS-1000074)
+type VerificationStatus string
+
+const (
+	VerificationPassed VerificationStatus = "TN-Validation-Passed"
+	VerificationFailed VerificationStatus = "TN-Validation-Failed"
+	VerificationNone   VerificationStatus = "No-TN-Validation"
+)
+
+// IdentityVerification - результат проверки Identity входящего вызова
+type IdentityVerification struct {
+	Status   VerificationStatus
+	Identity *Identity // nil, если заголовок отсутствует или не разобран
+	Err      error     // Причина непрошедшей проверки
+}
+
+// Attestation возвращает уровень заверения прошедшего проверку вызова
+func (v *IdentityVerification) Attestation() string {
+	if v == nil || v.Status != VerificationPassed || v.Identity == nil {
+		return ""
+	}
+	return v.Identity.Claims.Attest
+}
+
+// IdentityConfig - проверка и подпись заголовков Identity (STIR/SHAKEN)
+type IdentityConfig struct {
+	// Verifier проверяет PASSporT входящих INVITE. Если nil, входящие
+	// Identity не проверяются
+	Verifier IdentityVerifier
+	// VerifyTimeout - время на проверку (по умолчанию DefaultIdentityVerifyTimeout)
+	VerifyTimeout time.Duration
+	// MaxAge - допустимый возраст iat (по умолчанию DefaultIdentityMaxAge)
+	MaxAge time.Duration
+	// RejectInvalid - отклонять INVITE с непрошедшим проверку Identity
+	// (403 Stale Date, 438 Invalid Identity Header). Иначе вызов принимается
+	// со статусом VerificationFailed
+	RejectInvalid bool
+
+	// Signer - ключ ECDSA P-256 для подписи исходящих INVITE (может быть в HSM).
+	// Если nil, исходящие INVITE не подписываются
+	Signer crypto.Signer
+	// CertURL - URL сертификата подписи (x5u и info)
+	CertURL string
+	// Attestation - уровень заверения исходящих вызовов (по умолчанию C)
+	Attestation string
+}
+
+func (c *IdentityConfig) maxAge() time.Duration {
+	if c.MaxAge > 0 {
+		return c.MaxAge
+	}
+	return DefaultIdentityMaxAge
+}
+
+func (c *IdentityConfig) verifyTimeout() time.Duration {
+	if c.VerifyTimeout > 0 {
+		return c.VerifyTimeout
+	}
+	return DefaultIdentityVerifyTimeout
+}
+
+// CertURL возвращает URL сертификата подписи (x5u, иначе параметр info)
+func (i *Identity) CertURL() string {
+	if i.Header.X5U != "" {
+		return i.Header.X5U
+	}
+	return i.Info
+}
+
+// ParseIdentity разбирает значение заголовка Identity:
+// <PASSporT>;info=<https://cert.example.org/passport.cer>;alg=ES256;ppt=shaken
+func ParseIdentity(value string) (*Identity, error) {
+	token, rest, _ := strings.Cut(strings.TrimSpace(value), ";")
+	identity := &Identity{Params: make(map[string]string)}
+	for _, param := range strings.Split(rest, ";") {
+		name, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if name == "" {
+			continue
+		}
+		identity.Params[strings.ToLower(name)] = val
+	}
+	if info, ok := identity.Params["info"]; ok {
+		identity.Info = strings.TrimSuffix(strings.TrimPrefix(info, "<"), ">")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid PASSporT: expected 3 JWS segments, got %d", len(parts))
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSporT header encoding: %w", err)
+	}
+	if err := json.Unmarshal(header, &identity.Header); err != nil {
+		return nil, fmt.Errorf("invalid PASSporT header: %w", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSporT payload encoding: %w", err)
+	}
+	if err := json.Unmarshal(payload, &identity.Claims); err != nil {
+		return nil, fmt.Errorf("invalid PASSporT payload: %w", err)
+	}
+	identity.signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSporT signature encoding: %w", err)
+	}
+	identity.signingInput = []byte(parts[0] + "." + parts[1])
+	return identity, nil
+}
+
+// VerifySignature проверяет подпись ES256 PASSporT открытым ключом сертификата
+func (i *Identity) VerifySignature(key crypto.PublicKey) error {
+	if i.Header.Alg != PassportAlgES256 {
+		return fmt.Errorf("unsupported PASSporT algorithm %q", i.Header.Alg)
+	}
+	pub, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("ES256 requires an ECDSA public key, got %T", key)
+	}
+	if len(i.signature) != 64 {
+		return fmt.Errorf("invalid ES256 signature length %d", len(i.signature))
+	}
+	digest := sha256.Sum256(i.signingInput)
+	r := new(big.Int).SetBytes(i.signature[:32])
+	s := new(big.Int).SetBytes(i.signature[32:])
+	if !ecdsa.Verify(pub, digest[:], r, s) {
+		return fmt.Errorf("PASSporT signature verification failed")
+	}
+	return nil
+}
+
+// SignPassport подписывает PASSporT SHAKEN ключом ECDSA P-256 и возвращает
+// значение заголовка Identity
+func SignPassport(signer crypto.Signer, certURL string, claims PassportClaims) (string, error) {
+	header, err := json.Marshal(PassportHeader{Alg: PassportAlgES256, Ppt: PassportTypeShaken, Typ: "passport", X5U: certURL})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	der, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign PASSporT: %w", err)
+	}
+	// crypto.Signer возвращает подпись ECDSA в ASN.1, JWS требует r||s (RFC 7518 Section 3.4)
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return "", fmt.Errorf("unexpected ECDSA signature format: %w", err)
+	}
+	raw := make([]byte, 64)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:])
+
+	return fmt.Sprintf("%s.%s;info=<%s>;alg=%s;ppt=%s", signingInput,
+		base64.RawURLEncoding.EncodeToString(raw), certURL, PassportAlgES256, PassportTypeShaken), nil
+}
+
+// canonicalTN приводит номер из user части URI к каноническому виду
+// (только цифры, RFC 8224 Section 8.3). Возвращает пустую строку, если
+// user часть не является телефонным номером
+func canonicalTN(user string) string {
+	user, _, _ = strings.Cut(user, ";")
+	var b strings.Builder
+	for i, r := range user {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0, r == '-', r == '.', r == '(', r == ')':
+		default:
+			return ""
+		}
+	}
+	return b.String()
+}
+
+// newOrigID создает идентификатор origid (UUID версии 4)
+func newOrigID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// signIdentity добавляет заголовок Identity в исходящий INVITE, если
+// настроена подпись и номера From и To являются телефонными номерами
+func (u *UACUAS) signIdentity(req *sip.Request) error {
+	if u == nil || u.config.Identity == nil {
+		return nil
+	}
+	cfg := u.config.Identity
+	if cfg.Signer == nil || len(req.GetHeaders(HeaderIdentity)) > 0 {
+		return nil
+	}
+
+	orig, dest := canonicalTN(req.From().Address.User), canonicalTN(req.To().Address.User)
+	if orig == "" || dest == "" {
+		slog.Debug("INVITE не подписан: From или To не содержит телефонный номер",
+			slog.String("from", u.activeRedactor().redactUser(req.From().Address.User)),
+			slog.String("to", u.activeRedactor().redactUser(req.To().Address.User)))
+		return nil
+	}
+
+	attest := cfg.Attestation
+	if attest == "" {
+		attest = AttestationGateway
+	}
+	value, err := SignPassport(cfg.Signer, cfg.CertURL, PassportClaims{
+		Attest: attest,
+		Dest:   PassportDest{TN: []string{dest}},
+		IAT:    time.Now().Unix(),
+		Orig:   PassportOrig{TN: orig},
+		OrigID: newOrigID(),
+	})
+	if err != nil {
+		return err
+	}
+	req.AppendHeader(sip.NewHeader(HeaderIdentity, value))
+	return nil
+}
+
+// verifyIdentity проверяет Identity входящего INVITE: формат PASSporT,
+// возраст iat, соответствие orig/dest номерам From/To и подпись через
+// IdentityConfig.Verifier
+func (c *IdentityConfig) verifyIdentity(req *sip.Request, now time.Time) (*IdentityVerification, int) {
+	header := req.GetHeader(HeaderIdentity)
+	if header == nil {
+		return &IdentityVerification{Status: VerificationNone}, 0
+	}
+
+	identity, err := ParseIdentity(header.Value())
+	if err != nil {
+		return &IdentityVerification{Status: VerificationFailed, Err: err}, statusInvalidIdentity
+	}
+	result := &IdentityVerification{Status: VerificationFailed, Identity: identity}
+
+	if identity.Header.Alg != PassportAlgES256 || identity.Header.Typ != "passport" {
+		result.Err = fmt.Errorf("unsupported PASSporT alg %q or typ %q", identity.Header.Alg, identity.Header.Typ)
+		return result, statusInvalidIdentity
+	}
+	iat := time.Unix(identity.Claims.IAT, 0)
+	if age := now.Sub(iat); age > c.maxAge() || age < -c.maxAge() {
+		result.Err = fmt.Errorf("PASSporT iat is %s old", age.Round(time.Second))
+		return result, statusStaleDate
+	}
+	if orig := canonicalTN(req.From().Address.User); orig == "" || orig != identity.Claims.Orig.TN {
+		result.Err = fmt.Errorf("PASSporT orig %q does not match From %q", identity.Claims.Orig.TN, req.From().Address.User)
+		return result, statusInvalidIdentity
+	}
+	dest := canonicalTN(req.To().Address.User)
+	matched := false
+	for _, tn := range identity.Claims.Dest.TN {
+		matched = matched || (tn != "" && tn == dest)
+	}
+	if !matched {
+		result.Err = fmt.Errorf("PASSporT dest %v does not match To %q", identity.Claims.Dest.TN, req.To().Address.User)
+		return result, statusInvalidIdentity
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.verifyTimeout())
+	defer cancel()
+	if err := c.Verifier(ctx, identity); err != nil {
+		result.Err = err
+		return result, statusInvalidIdentity
+	}
+
+	result.Status = VerificationPassed
+	return result, 0
+}
+
+// applyIdentityVerification проверяет Identity нового входящего INVITE.
+// Если проверка не пройдена и включен RejectInvalid, INVITE отклоняется
+// и возвращается false
+func (u *UACUAS) applyIdentityVerification(req *sip.Request, tx sip.ServerTransaction) (*IdentityVerification, bool) {
+	cfg := u.config.Identity
+	if cfg == nil || cfg.Verifier == nil {
+		return nil, true
+	}
+
+	result, status := cfg.verifyIdentity(req, time.Now())
+	if result.Err != nil {
+		slog.Warn("Identity входящего INVITE не прошел проверку",
+			slog.Any("error", result.Err),
+			slog.String("CallID", req.CallID().Value()))
+	}
+	if status == 0 || !cfg.RejectInvalid {
+		return result, true
+	}
+
+	reason := "Invalid Identity Header"
+	if status == statusStaleDate {
+		reason = "Stale Date"
+	}
+	if err := tx.Respond(sip.NewResponseFromRequest(req, status, reason, nil)); err != nil {
+		slog.Error("Не удалось отклонить INVITE с некорректным Identity",
+			slog.Any("error", err),
+			slog.Int("status", status))
+	}
+	return nil, false
+}
+
+// IdentityVerification возвращает результат проверки Identity (STIR/SHAKEN)
+// входящего вызова или nil, если проверка не настроена или вызов исходящий
+func (s *Dialog) IdentityVerification() *IdentityVerification {
+	return s.identity
+}
@@ -0,0 +1,135 @@
+package dialog
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCertURL = "https://cert.example.org/passport.cer"
+
+// newIdentityRequest создает INVITE с телефонными номерами в From и To
+func newIdentityRequest() *sip.Request {
+	req := newTestRequest(sip.INVITE)
+	req.From().Address.User = "+1-215-555-1212"
+	req.To().Address.User = "12155551213"
+	return req
+}
+
+func newIdentityKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+// keyVerifier проверяет подпись ключом, как после загрузки сертификата
+func keyVerifier(key *ecdsa.PrivateKey) IdentityVerifier {
+	return func(ctx context.Context, identity *Identity) error {
+		if identity.CertURL() != testCertURL {
+			return errors.New("unknown certificate")
+		}
+		return identity.VerifySignature(&key.PublicKey)
+	}
+}
+
+func TestSignAndParseIdentity(t *testing.T) {
+	key := newIdentityKey(t)
+	u := &UACUAS{config: Config{Identity: &IdentityConfig{Signer: key, CertURL: testCertURL, Attestation: AttestationFull}}}
+
+	req := newIdentityRequest()
+	require.NoError(t, u.signIdentity(req))
+	header := req.GetHeader(HeaderIdentity)
+	require.NotNil(t, header)
+
+	identity, err := ParseIdentity(header.Value())
+	require.NoError(t, err)
+	assert.Equal(t, PassportHeader{Alg: "ES256", Ppt: "shaken", Typ: "passport", X5U: testCertURL}, identity.Header)
+	assert.Equal(t, "A", identity.Claims.Attest)
+	assert.Equal(t, "12155551212", identity.Claims.Orig.TN)
+	assert.Equal(t, []string{"12155551213"}, identity.Claims.Dest.TN)
+	assert.Len(t, identity.Claims.OrigID, 36)
+	assert.Equal(t, testCertURL, identity.Info)
+	assert.Equal(t, "shaken", identity.Params["ppt"])
+	assert.NoError(t, identity.VerifySignature(&key.PublicKey))
+	assert.Error(t, identity.VerifySignature(&newIdentityKey(t).PublicKey))
+
+	// Уже подписанный запрос и номера не в виде TN не подписываются
+	require.NoError(t, u.signIdentity(req))
+	assert.Len(t, req.GetHeaders(HeaderIdentity), 1)
+	plain := newTestRequest(sip.INVITE)
+	require.NoError(t, u.signIdentity(plain))
+	assert.Empty(t, plain.GetHeaders(HeaderIdentity))
+
+	_, err = ParseIdentity("not-a-jwt;info=<" + testCertURL + ">")
+	assert.Error(t, err)
+}
+
+func TestVerifyIdentity(t *testing.T) {
+	key := newIdentityKey(t)
+	signer := &UACUAS{config: Config{Identity: &IdentityConfig{Signer: key, CertURL: testCertURL}}}
+	cfg := &IdentityConfig{Verifier: keyVerifier(key)}
+
+	// Без заголовка Identity
+	result, status := cfg.verifyIdentity(newIdentityRequest(), time.Now())
+	assert.Equal(t, VerificationNone, result.Status)
+	assert.Zero(t, status)
+
+	req := newIdentityRequest()
+	require.NoError(t, signer.signIdentity(req))
+	result, status = cfg.verifyIdentity(req, time.Now())
+	require.NoError(t, result.Err)
+	assert.Equal(t, VerificationPassed, result.Status)
+	assert.Equal(t, AttestationGateway, result.Attestation())
+	assert.Zero(t, status)
+
+	// Устаревший PASSporT
+	result, status = cfg.verifyIdentity(req, time.Now().Add(2*time.Minute))
+	assert.Equal(t, VerificationFailed, result.Status)
+	assert.Equal(t, statusStaleDate, status)
+	assert.Empty(t, result.Attestation())
+
+	// Номер To не совпадает с dest
+	req.To().Address.User = "12155550000"
+	result, status = cfg.verifyIdentity(req, time.Now())
+	assert.Equal(t, VerificationFailed, result.Status)
+	assert.Equal(t, statusInvalidIdentity, status)
+
+	// Подпись другим ключом
+	other := &IdentityConfig{Verifier: keyVerifier(newIdentityKey(t))}
+	req = newIdentityRequest()
+	require.NoError(t, signer.signIdentity(req))
+	result, status = other.verifyIdentity(req, time.Now())
+	assert.Error(t, result.Err)
+	assert.Equal(t, statusInvalidIdentity, status)
+}
+
+func TestIdentityHandleInvite(t *testing.T) {
+	key := newIdentityKey(t)
+	u, err := NewUACUAS(Config{
+		TestMode: true,
+		Identity: &IdentityConfig{Verifier: keyVerifier(key), RejectInvalid: true},
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 35980},
+		},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	// PASSporT, подписанный неизвестным ключом
+	signer := &UACUAS{config: Config{Identity: &IdentityConfig{Signer: newIdentityKey(t), CertURL: testCertURL}}}
+	req := newIdentityRequest()
+	require.NoError(t, signer.signIdentity(req))
+
+	tx := newRecordingServerTX()
+	u.handleInvite(req, tx)
+	require.Len(t, tx.responses, 1)
+	assert.Equal(t, statusInvalidIdentity, tx.responses[0].StatusCode)
+}
@@ -0,0 +1,363 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+)
+
+// IncomingCallDesc описывает синтетический входящий вызов для
+// InjectIncomingCall. Пустые поля заполняются значениями по умолчанию.
+type IncomingCallDesc struct {
+	// From - SIP URI вызывающего, по умолчанию sip:caller@127.0.0.1
+	From string
+	// FromName - отображаемое имя вызывающего
+	FromName string
+	// To - вызываемый SIP URI (Request-URI и To), по умолчанию адрес
+	// первого транспорта UACUAS
+	To string
+	// CallID - Call-ID вызова, по умолчанию генерируется
+	CallID string
+	// Source - адрес отправителя "host:port" для Via и Contact,
+	// по умолчанию 127.0.0.1:5060
+	Source string
+	// Transport - транспорт Via, по умолчанию UDP
+	Transport string
+	// Headers - дополнительные заголовки INVITE (P-Asserted-Identity,
+	// Diversion и т.п.)
+	Headers []sip.Header
+	// SDP - тело INVITE
+	SDP []byte
+	// ContentType - тип тела, по умолчанию application/sdp
+	ContentType string
+}
+
+// InjectedCall - синтетический входящий вызов. Ответы приложения на INVITE
+// не уходят в сеть, а сохраняются в InjectedCall.
+type InjectedCall struct {
+	u   *UACUAS
+	req *sip.Request
+	tx  *injectedServerTX
+}
+
+// InjectIncomingCall формирует входящий INVITE по desc и передает его
+// обработчику входящих INVITE так же, как запрос из сети: с проверкой
+// возможностей, политикой вызовов, регистратором и колбэком
+// OnIncomingCall. Транспорт не используется, поэтому приложение может
+// детерминированно тестировать обработку входящих вызовов и правила
+// маршрутизации.
+//
+// Ответы на INVITE доступны через Responses и WaitFinal. Запросы внутри
+// диалога после ответа (BYE, re-INVITE) отправляются обычным путем на
+// адрес Source.
+func (u *UACUAS) InjectIncomingCall(desc IncomingCallDesc) (*InjectedCall, error) {
+	req, err := u.newInjectedInvite(desc)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &InjectedCall{u: u, req: req, tx: newInjectedServerTX()}
+	u.withCapabilities(u.handleInvite)(req.Clone(), call.tx)
+	return call, nil
+}
+
+// newInjectedInvite формирует INVITE по описанию вызова
+func (u *UACUAS) newInjectedInvite(desc IncomingCallDesc) (*sip.Request, error) {
+	if desc.From == "" {
+		desc.From = "sip:caller@127.0.0.1"
+	}
+	if desc.To == "" {
+		desc.To = "sip:127.0.0.1"
+		if len(u.config.TransportConfigs) > 0 {
+			tc := u.config.TransportConfigs[0]
+			desc.To = "sip:" + net.JoinHostPort(tc.Host, strconv.Itoa(tc.Port))
+		}
+	}
+	if desc.CallID == "" {
+		desc.CallID = "injected-" + u.generateTag()
+	}
+	if desc.Source == "" {
+		desc.Source = "127.0.0.1:5060"
+	}
+	if desc.Transport == "" {
+		desc.Transport = "UDP"
+	}
+
+	var from, to sip.Uri
+	if err := sip.ParseUri(desc.From, &from); err != nil {
+		return nil, errors.Wrap(err, "invalid From URI")
+	}
+	if err := sip.ParseUri(desc.To, &to); err != nil {
+		return nil, errors.Wrap(err, "invalid To URI")
+	}
+	host, portValue, err := net.SplitHostPort(desc.Source)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid source address")
+	}
+	port, err := strconv.Atoi(portValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid source port")
+	}
+
+	req := sip.NewRequest(sip.INVITE, to)
+	req.AppendHeader(&sip.ViaHeader{
+		ProtocolName:    "SIP",
+		ProtocolVersion: "2.0",
+		Transport:       desc.Transport,
+		Host:            host,
+		Port:            port,
+		Params:          sip.NewParams().Add("branch", sip.GenerateBranch()),
+	})
+	req.AppendHeader(&sip.FromHeader{
+		DisplayName: desc.FromName,
+		Address:     from,
+		Params:      sip.NewParams().Add("tag", u.generateTag()),
+	})
+	req.AppendHeader(&sip.ToHeader{Address: to, Params: sip.NewParams()})
+	callID := sip.CallIDHeader(desc.CallID)
+	req.AppendHeader(&callID)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: sip.INVITE})
+	maxForwards := sip.MaxForwardsHeader(70)
+	req.AppendHeader(&maxForwards)
+	req.AppendHeader(&sip.ContactHeader{
+		Address: sip.Uri{Scheme: "sip", User: from.User, Host: host, Port: port},
+	})
+	for _, h := range desc.Headers {
+		req.AppendHeader(h)
+	}
+	if len(desc.SDP) > 0 {
+		contentType := desc.ContentType
+		if contentType == "" {
+			contentType = "application/sdp"
+		}
+		ct := sip.ContentTypeHeader(contentType)
+		req.AppendHeader(&ct)
+		req.SetBody(desc.SDP)
+	}
+	req.SetTransport(desc.Transport)
+	req.SetSource(desc.Source)
+	return req, nil
+}
+
+// Request возвращает копию синтетического INVITE
+func (c *InjectedCall) Request() *sip.Request {
+	return c.req.Clone()
+}
+
+// Dialog возвращает диалог, созданный для вызова. false - вызов отклонен
+// до создания диалога (политикой, проверкой возможностей и т.п.)
+func (c *InjectedCall) Dialog() (IDialog, bool) {
+	d, ok := c.u.dialogs.GetWithTX(GetBranchID(c.req))
+	if !ok {
+		return nil, false
+	}
+	return d, true
+}
+
+// Responses возвращает ответы, отправленные на INVITE
+func (c *InjectedCall) Responses() []*sip.Response {
+	return c.tx.Responses()
+}
+
+// WaitFinal ждет финальный ответ на INVITE
+func (c *InjectedCall) WaitFinal(ctx context.Context) (*sip.Response, error) {
+	select {
+	case <-c.tx.final:
+		return c.tx.finalResponse(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Ack подтверждает ответ 2xx на INVITE так же, как ACK из сети
+func (c *InjectedCall) Ack() error {
+	resp := c.tx.finalResponse()
+	if resp == nil || !resp.IsSuccess() {
+		return fmt.Errorf("no 2xx response to acknowledge")
+	}
+
+	ack := sip.NewRequest(sip.ACK, c.req.Recipient)
+	if contact := resp.Contact(); contact != nil {
+		ack.Recipient = contact.Address
+	}
+	ack.AppendHeader(&sip.ViaHeader{
+		ProtocolName:    "SIP",
+		ProtocolVersion: "2.0",
+		Transport:       c.req.Transport(),
+		Host:            c.req.Via().Host,
+		Port:            c.req.Via().Port,
+		Params:          sip.NewParams().Add("branch", sip.GenerateBranch()),
+	})
+	ack.AppendHeader(sip.HeaderClone(c.req.From()))
+	ack.AppendHeader(sip.HeaderClone(resp.To()))
+	ack.AppendHeader(sip.HeaderClone(c.req.CallID()))
+	ack.AppendHeader(&sip.CSeqHeader{SeqNo: c.req.CSeq().SeqNo, MethodName: sip.ACK})
+	ack.SetTransport(c.req.Transport())
+	ack.SetSource(c.req.Source())
+
+	c.tx.ack(ack)
+	c.u.withCapabilities(c.u.handleACK)(ack, nil)
+	return nil
+}
+
+// Cancel отменяет вызов до ответа так же, как CANCEL из сети, и
+// возвращает ответ на CANCEL
+func (c *InjectedCall) Cancel() (*sip.Response, error) {
+	cancel := sip.NewRequest(sip.CANCEL, c.req.Recipient)
+	cancel.AppendHeader(sip.HeaderClone(c.req.Via()))
+	cancel.AppendHeader(sip.HeaderClone(c.req.From()))
+	cancel.AppendHeader(sip.HeaderClone(c.req.To()))
+	cancel.AppendHeader(sip.HeaderClone(c.req.CallID()))
+	cancel.AppendHeader(&sip.CSeqHeader{SeqNo: c.req.CSeq().SeqNo, MethodName: sip.CANCEL})
+	maxForwards := sip.MaxForwardsHeader(70)
+	cancel.AppendHeader(&maxForwards)
+	cancel.SetTransport(c.req.Transport())
+	cancel.SetSource(c.req.Source())
+
+	c.tx.cancel(cancel)
+	cancelTx := newInjectedServerTX()
+	c.u.withCapabilities(c.u.handleCancel)(cancel, cancelTx)
+	resp := cancelTx.finalResponse()
+	if resp == nil {
+		return nil, errors.New("no response to CANCEL")
+	}
+	return resp, nil
+}
+
+// injectedServerTX - серверная транзакция синтетического запроса,
+// сохраняющая ответы вместо отправки
+type injectedServerTX struct {
+	mu          sync.Mutex
+	responses   []*sip.Response
+	final       chan struct{}
+	acks        chan *sip.Request
+	done        chan struct{}
+	answered    bool // Отправлен финальный ответ
+	terminated  bool
+	onTerminate []sip.FnTxTerminate
+	onCancel    []sip.FnTxCancel
+}
+
+func newInjectedServerTX() *injectedServerTX {
+	return &injectedServerTX{
+		final: make(chan struct{}),
+		acks:  make(chan *sip.Request, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// Respond сохраняет ответ. Финальный ответ, кроме 2xx, завершает
+// транзакцию: ACK на него поглощается транзакцией (RFC 3261 Section 17.2.1)
+func (t *injectedServerTX) Respond(res *sip.Response) error {
+	t.mu.Lock()
+	if t.terminated {
+		t.mu.Unlock()
+		return sip.ErrTransactionTerminated
+	}
+	// Повторные 2xx не являются первым финальным ответом
+	first := res.StatusCode >= 200 && !t.answered
+	t.answered = t.answered || first
+	t.responses = append(t.responses, res)
+	t.mu.Unlock()
+
+	if first {
+		close(t.final)
+		if !res.IsSuccess() {
+			t.Terminate()
+		}
+	}
+	return nil
+}
+
+// Responses возвращает сохраненные ответы
+func (t *injectedServerTX) Responses() []*sip.Response {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*sip.Response(nil), t.responses...)
+}
+
+// finalResponse возвращает первый финальный ответ
+func (t *injectedServerTX) finalResponse() *sip.Response {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, resp := range t.responses {
+		if resp.StatusCode >= 200 {
+			return resp
+		}
+	}
+	return nil
+}
+
+func (t *injectedServerTX) ack(req *sip.Request) {
+	select {
+	case t.acks <- req:
+	default:
+	}
+}
+
+func (t *injectedServerTX) cancel(req *sip.Request) {
+	t.mu.Lock()
+	handlers := append([]sip.FnTxCancel(nil), t.onCancel...)
+	t.mu.Unlock()
+	for _, f := range handlers {
+		f(req)
+	}
+}
+
+func (t *injectedServerTX) Acks() <-chan *sip.Request {
+	return t.acks
+}
+
+func (t *injectedServerTX) OnCancel(f sip.FnTxCancel) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.terminated {
+		return false
+	}
+	t.onCancel = append(t.onCancel, f)
+	return true
+}
+
+func (t *injectedServerTX) OnTerminate(f sip.FnTxTerminate) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.terminated {
+		return false
+	}
+	t.onTerminate = append(t.onTerminate, f)
+	return true
+}
+
+func (t *injectedServerTX) Terminate() {
+	t.mu.Lock()
+	if t.terminated {
+		t.mu.Unlock()
+		return
+	}
+	t.terminated = true
+	handlers := t.onTerminate
+	t.mu.Unlock()
+
+	close(t.done)
+	for _, f := range handlers {
+		f("", sip.ErrTransactionTerminated)
+	}
+}
+
+func (t *injectedServerTX) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *injectedServerTX) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.terminated {
+		return sip.ErrTransactionTerminated
+	}
+	return nil
+}
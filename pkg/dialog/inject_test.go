@@ -0,0 +1,104 @@
+package dialog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInjectIncomingCall(t *testing.T) {
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15111}},
+	})
+	require.NoError(t, err)
+	defer u.Stop()
+
+	// Правило маршрутизации приложения: вызовы с анонимного номера отклоняются
+	acked := make(chan error, 1)
+	u.OnIncomingCall(func(d IDialog, tx IServerTX) {
+		if strings.HasPrefix(tx.Request().From().Address.User, "anonymous") {
+			_ = tx.Reject(sip.StatusBusyHere, "Busy Here")
+			return
+		}
+		if tx.Request().To().Address.User == "queue" {
+			_ = tx.Provisional(sip.StatusRinging, "Ringing")
+			return
+		}
+		require.NoError(t, tx.Accept(ResponseWithBodyString("v=0\r\n")))
+		go func() { acked <- tx.WaitAck() }()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	t.Run("ответ и ACK", func(t *testing.T) {
+		call, err := u.InjectIncomingCall(IncomingCallDesc{
+			From:     "sip:alice@10.0.0.5",
+			FromName: "Alice",
+			To:       "sip:bob@127.0.0.1:15111",
+			Headers:  []sip.Header{sip.NewHeader("P-Asserted-Identity", "<sip:+15551234@10.0.0.5>")},
+			SDP:      []byte("v=0\r\no=alice 1 1 IN IP4 10.0.0.5\r\n"),
+		})
+		require.NoError(t, err)
+
+		resp, err := call.WaitFinal(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, sip.StatusOK, resp.StatusCode)
+		assert.Equal(t, "v=0\r\n", string(resp.Body()))
+
+		d, ok := call.Dialog()
+		require.True(t, ok)
+		assert.Equal(t, InCall, d.State())
+		callID := d.CallID()
+		assert.Equal(t, call.Request().CallID().Value(), callID.Value())
+
+		require.NoError(t, call.Ack())
+		select {
+		case err := <-acked:
+			assert.NoError(t, err)
+		case <-ctx.Done():
+			t.Fatal("ACK не доставлен приложению")
+		}
+	})
+
+	t.Run("отклонение правилом приложения", func(t *testing.T) {
+		call, err := u.InjectIncomingCall(IncomingCallDesc{From: "sip:anonymous@anonymous.invalid"})
+		require.NoError(t, err)
+
+		resp, err := call.WaitFinal(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, sip.StatusBusyHere, resp.StatusCode)
+		assert.Error(t, call.Ack(), "ACK на отказ поглощается транзакцией")
+	})
+
+	t.Run("отмена до ответа", func(t *testing.T) {
+		call, err := u.InjectIncomingCall(IncomingCallDesc{To: "sip:queue@127.0.0.1"})
+		require.NoError(t, err)
+		require.Len(t, call.Responses(), 1)
+		assert.Equal(t, sip.StatusRinging, call.Responses()[0].StatusCode)
+
+		resp, err := call.Cancel()
+		require.NoError(t, err)
+		assert.Equal(t, sip.StatusOK, resp.StatusCode)
+
+		final, err := call.WaitFinal(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, sip.StatusRequestTerminated, final.StatusCode)
+
+		d, ok := call.Dialog()
+		require.True(t, ok)
+		assert.Equal(t, Ended, d.State())
+		require.NotNil(t, d.ReleaseCause())
+		assert.Equal(t, CategoryCancelled, d.ReleaseCause().Category)
+	})
+
+	t.Run("некорректное описание", func(t *testing.T) {
+		_, err := u.InjectIncomingCall(IncomingCallDesc{Source: "no-port"})
+		assert.Error(t, err)
+	})
+}
@@ -24,6 +24,11 @@ type IUU interface {
 	// Обработчик будет вызван при получении INVITE запроса.
 	OnIncomingCall(handler OnIncomingCall)
 
+	// InjectIncomingCall передает обработчику входящих вызовов синтетический
+	// INVITE без участия сети. Используется для тестирования логики
+	// OnIncomingCall и правил маршрутизации в приложении.
+	InjectIncomingCall(desc IncomingCallDesc) (*InjectedCall, error)
+
 	// ListenTransports запускает прослушивание на всех настроенных транспортах.
 	// Транспорты определяются в конфигурации при создании менеджера.
 	// Поддерживаются: UDP, TCP, WS. TLS и WSS планируются к реализации.
@@ -86,6 +91,9 @@ type IDialog interface {
 	// Terminate завершает диалог, отправляя BYE запрос (не ждет ответа)
 	Terminate() error
 
+	// TerminateWithCause завершает диалог, передавая причину в заголовке Reason BYE
+	TerminateWithCause(cause ReleaseCause) error
+
 	// Start начинает новый диалог, отправляя INVITE запрос на указанный адрес
 	Start(ctx context.Context, target string, opts ...RequestOpt) (IClientTX, error)
 
@@ -105,6 +113,10 @@ type IDialog interface {
 	// SendRequest отправляет произвольный SIP запрос в рамках диалога
 	SendRequest(ctx context.Context, opts ...RequestOpt) (IClientTX, error)
 
+	// DoRequest отправляет запрос с произвольным методом в рамках диалога
+	// (например, проприетарным), кроме INVITE, ACK, CANCEL и BYE
+	DoRequest(ctx context.Context, method sip.RequestMethod, opts ...RequestOpt) (IClientTX, error)
+
 	// Контекст и время жизни
 	// Context возвращает контекст диалога
 	Context() context.Context
@@ -118,6 +130,9 @@ type IDialog interface {
 	// Close закрывает диалог без отправки BYE запроса и освобождает ресурсы
 	Close() error
 
+	// WaitEnded блокирует до перехода диалога в состояние Ended
+	WaitEnded(ctx context.Context) error
+
 	// История переходов состояний
 	// GetLastTransitionReason возвращает последнюю причину перехода состояния диалога.
 	// Возвращает nil если история переходов пуста.
@@ -129,6 +144,14 @@ type IDialog interface {
 	// Метод потокобезопасен.
 	GetTransitionHistory() []StateTransitionReason
 
+	// ReleaseCause возвращает причину завершения вызова (категория, код SIP, причина Q.850).
+	// Возвращает nil, пока вызов не начал завершаться.
+	ReleaseCause() *ReleaseCause
+
+	// SetupTimes возвращает моменты отправки INVITE, первого 18x и 2xx
+	// исходящего вызова (PDD и время установления)
+	SetupTimes() CallSetupTimes
+
 	// Обработчики событий
 	// OnStateChange устанавливает обработчик изменения состояния диалога
 	OnStateChange(handler func(DialogState))
@@ -151,6 +174,50 @@ type IDialog interface {
 	OnRequestHandler(handler func(IServerTX))
 	// OnTerminate устанавливает обработчик для события завершения диалога
 	OnTerminate(handler func())
+	// OnRelease устанавливает обработчик завершения диалога с причиной завершения
+	OnRelease(handler func(cause ReleaseCause))
+	// OnRemoteTargetChanged устанавливает обработчик смены remote target
+	// по Contact в re-INVITE, UPDATE или 2xx на них
+	OnRemoteTargetChanged(handler func(previous, current sip.Uri))
+
+	// Разветвление исходящего INVITE
+	// EarlyDialogs возвращает ранние диалоги веток исходящего INVITE в порядке появления
+	EarlyDialogs() []EarlyDialog
+	// OnEarlyDialogForked устанавливает обработчик появления новой ветки разветвленного INVITE
+	OnEarlyDialogForked(handler func(early []EarlyDialog))
+
+	// Таймеры установления исходящего вызова
+	// SetSetupTimeouts задает таймеры ожидания 18x и финального ответа для этого вызова
+	SetSetupTimeouts(timeouts SetupTimeouts)
+	// SetupTimeouts возвращает действующие таймеры установления вызова
+	SetupTimeouts() SetupTimeouts
+
+	// Лимит длительности вызова
+	// SetCallLimit задает лимит длительности разговора для этого вызова
+	SetCallLimit(limit CallLimit)
+	// SetTenant задает tenant вызова для выбора лимита из Config.TenantCallLimits
+	SetTenant(tenant string)
+	// CallLimit возвращает действующий лимит длительности вызова
+	CallLimit() CallLimit
+	// OnCallLimit устанавливает обработчик предупреждения и исчерпания лимита
+	OnCallLimit(handler func(event CallLimitEvent))
+
+	// ReplacedDialog возвращает диалог, заменяемый входящим INVITE с Replaces
+	ReplacedDialog() IDialog
+	// PolicyDecision возвращает решение политики входящих вызовов (Config.CallPolicy)
+	PolicyDecision() *PolicyDecision
+	// IdentityVerification возвращает результат проверки Identity (STIR/SHAKEN)
+	IdentityVerification() *IdentityVerification
+	// MediaProbe возвращает результат проверки медиа пути перед ответом
+	MediaProbe() *MediaProbeResult
+	// ParkSlot возвращает слот парковки вызова или пустую строку
+	ParkSlot() string
+	// LineAppearance возвращает номер appearance общей линии вызова или 0
+	LineAppearance() int
+	// QueuedRequests возвращает re-INVITE, UPDATE и REFER в очереди диалога
+	QueuedRequests() []QueuedRequest
+	// CancelQueuedRequest удаляет ожидающий запрос из очереди диалога
+	CancelQueuedRequest(id uint64) bool
 }
 
 // RequestOpt определяет функцию-опцию для настройки SIP запросов.
@@ -192,6 +259,8 @@ type IClientTX interface {
 	ITx
 	// получаем responce
 	Responses() <-chan *sip.Response
+	// WaitFinal блокирует до финального ответа транзакции
+	WaitFinal(ctx context.Context) (*sip.Response, error)
 	// Cancel отменяет транзакцию
 	Cancel() error
 }
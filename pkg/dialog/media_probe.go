@@ -0,0 +1,318 @@
+package dialog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pion/sdp/v3"
+)
+
+// ErrMediaUnreachable возвращается из Accept, если проверка медиа пути
+// (Config.MediaProbe) отклонила вызов ответом 488
+var ErrMediaUnreachable = errors.New("медиа адрес удаленной стороны недоступен")
+
+const (
+	// DefaultMediaProbeTimeout - время ожидания результата проверки по умолчанию
+	DefaultMediaProbeTimeout = time.Second
+	// DefaultMediaProbeInterval - интервал повторной отправки проверочных пакетов
+	DefaultMediaProbeInterval = 200 * time.Millisecond
+)
+
+// stunMagicCookie - фиксированное значение заголовка STUN (RFC 5389)
+const stunMagicCookie = 0x2112A442
+
+// MediaProbeMode определяет тип проверочных пакетов
+type MediaProbeMode int
+
+const (
+	// MediaProbeSTUN - STUN Binding Request. Ответ подтверждает доступность,
+	// если удаленная сторона поддерживает STUN на медиа порту (ICE, SBC)
+	MediaProbeSTUN MediaProbeMode = iota
+	// MediaProbeRTP - RTP пакет без полезной нагрузки с payload type из offer.
+	// Ответа не бывает, недоступность определяется по ICMP port unreachable
+	MediaProbeRTP
+)
+
+// MediaProbePolicy определяет, при каком результате проверки вызов отклоняется
+type MediaProbePolicy int
+
+const (
+	// MediaProbeProceed - отвечать 200 OK при любом результате, результат
+	// доступен через Dialog.MediaProbe
+	MediaProbeProceed MediaProbePolicy = iota
+	// MediaProbeRejectUnreachable - 488, если получен ICMP unreachable
+	MediaProbeRejectUnreachable
+	// MediaProbeRejectNoResponse - 488 также, если за Timeout не получено ни
+	// одного пакета. Подходит только для MediaProbeSTUN и узлов с поддержкой STUN
+	MediaProbeRejectNoResponse
+)
+
+// MediaProbeStatus - результат проверки медиа пути
+type MediaProbeStatus int
+
+const (
+	// MediaProbeSkipped - проверка не выполнялась (нет SDP offer, порт 0, удержание)
+	MediaProbeSkipped MediaProbeStatus = iota
+	// MediaProbeReachable - от удаленного адреса получен ответ
+	MediaProbeReachable
+	// MediaProbeNoResponse - ответа нет, но и ошибки ICMP тоже
+	MediaProbeNoResponse
+	// MediaProbeUnreachable - ОС сообщила о недоступности адреса (ICMP)
+	MediaProbeUnreachable
+)
+
+// String возвращает строковое представление статуса
+func (s MediaProbeStatus) String() string {
+	switch s {
+	case MediaProbeSkipped:
+		return "skipped"
+	case MediaProbeReachable:
+		return "reachable"
+	case MediaProbeNoResponse:
+		return "no-response"
+	case MediaProbeUnreachable:
+		return "unreachable"
+	default:
+		return "unknown"
+	}
+}
+
+// MediaProbeConfig - проверка доступности медиа адреса из SDP offer
+// входящего INVITE перед отправкой 200 OK. Проверка выполняется в Accept
+// первой транзакции INVITE и задерживает ответ не более чем на Timeout.
+//
+// Пакеты отправляются с отдельного сокета, а не с медиа порта, поэтому
+// проверка выявляет недоступные и несуществующие адреса, но не проблемы
+// NAT привязки самого медиа порта.
+type MediaProbeConfig struct {
+	Mode     MediaProbeMode
+	Policy   MediaProbePolicy
+	Timeout  time.Duration // По умолчанию DefaultMediaProbeTimeout
+	Interval time.Duration // По умолчанию DefaultMediaProbeInterval
+	// LocalAddr - локальный адрес сокета проверки (по умолчанию выбирает ОС)
+	LocalAddr string
+}
+
+// MediaProbeResult - результат проверки медиа пути
+type MediaProbeResult struct {
+	Status     MediaProbeStatus
+	RemoteAddr string
+	Sent       int           // Отправлено проверочных пакетов
+	RTT        time.Duration // Время до первого ответа (MediaProbeReachable)
+	Err        error         // Ошибка ОС для MediaProbeUnreachable
+}
+
+// mediaProbeTarget - адрес и payload type первого медиа потока offer
+type mediaProbeTarget struct {
+	addr        string
+	payloadType uint8
+}
+
+// parseMediaProbeTarget извлекает медиа адрес из SDP. Возвращает false для
+// отключенного потока (порт 0) и удержания (c=0.0.0.0)
+func parseMediaProbeTarget(body []byte) (mediaProbeTarget, bool) {
+	var desc sdp.SessionDescription
+	if err := desc.UnmarshalString(string(body)); err != nil || len(desc.MediaDescriptions) == 0 {
+		return mediaProbeTarget{}, false
+	}
+
+	media := desc.MediaDescriptions[0]
+	connection := desc.ConnectionInformation
+	if media.ConnectionInformation != nil {
+		connection = media.ConnectionInformation
+	}
+	if connection == nil || connection.Address == nil || media.MediaName.Port.Value == 0 {
+		return mediaProbeTarget{}, false
+	}
+
+	ip := net.ParseIP(connection.Address.Address)
+	if ip == nil || ip.IsUnspecified() {
+		return mediaProbeTarget{}, false
+	}
+
+	target := mediaProbeTarget{addr: net.JoinHostPort(ip.String(), strconv.Itoa(media.MediaName.Port.Value))}
+	if len(media.MediaName.Formats) > 0 {
+		if pt, err := strconv.ParseUint(media.MediaName.Formats[0], 10, 7); err == nil {
+			target.payloadType = uint8(pt)
+		}
+	}
+	return target, true
+}
+
+// Probe отправляет проверочные пакеты на remoteAddr до получения ответа,
+// ошибки ICMP или истечения Timeout
+func (c *MediaProbeConfig) Probe(ctx context.Context, remoteAddr string) *MediaProbeResult {
+	return c.probe(ctx, mediaProbeTarget{addr: remoteAddr})
+}
+
+func (c *MediaProbeConfig) probe(ctx context.Context, target mediaProbeTarget) *MediaProbeResult {
+	result := &MediaProbeResult{RemoteAddr: target.addr}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultMediaProbeTimeout
+	}
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultMediaProbeInterval
+	}
+
+	dialer := net.Dialer{}
+	if c.LocalAddr != "" {
+		localAddr, err := net.ResolveUDPAddr("udp", c.LocalAddr)
+		if err != nil {
+			result.Status, result.Err = MediaProbeSkipped, err
+			return result
+		}
+		dialer.LocalAddr = localAddr
+	}
+
+	// Соединенный UDP сокет получает ошибки ICMP как ECONNREFUSED при чтении
+	conn, err := dialer.DialContext(ctx, "udp", target.addr)
+	if err != nil {
+		result.Status, result.Err = MediaProbeUnreachable, err
+		return result
+	}
+	defer conn.Close()
+
+	packet, transactionID := c.probePacket(target.payloadType)
+	start := time.Now()
+	deadline := start.Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	buf := make([]byte, 1500)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		if _, err := conn.Write(packet); err != nil {
+			if isPortUnreachable(err) {
+				result.Status, result.Err = MediaProbeUnreachable, err
+				return result
+			}
+		} else {
+			result.Sent++
+		}
+
+		readDeadline := time.Now().Add(interval)
+		if readDeadline.After(deadline) {
+			readDeadline = deadline
+		}
+		_ = conn.SetReadDeadline(readDeadline)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				if isPortUnreachable(err) {
+					result.Status, result.Err = MediaProbeUnreachable, err
+					return result
+				}
+				break
+			}
+			// Любой пакет с адреса подтверждает доступность; для STUN чужие
+			// транзакции пропускаем, чтобы не принять запоздавший ответ
+			if c.Mode == MediaProbeSTUN && isSTUNMessage(buf[:n]) && !isSTUNResponse(buf[:n], transactionID) {
+				continue
+			}
+			result.Status = MediaProbeReachable
+			result.RTT = time.Since(start)
+			return result
+		}
+	}
+
+	result.Status = MediaProbeNoResponse
+	return result
+}
+
+// probePacket формирует проверочный пакет: STUN Binding Request или RTP без
+// полезной нагрузки. Для STUN возвращается идентификатор транзакции
+func (c *MediaProbeConfig) probePacket(payloadType uint8) ([]byte, []byte) {
+	if c.Mode == MediaProbeRTP {
+		packet := make([]byte, 12)
+		_, _ = rand.Read(packet[2:]) // случайные sequence, timestamp и SSRC
+		packet[0] = 0x80             // версия 2
+		packet[1] = payloadType & 0x7f
+		return packet, nil
+	}
+
+	packet := make([]byte, 20)
+	binary.BigEndian.PutUint16(packet[0:2], 0x0001) // Binding Request
+	binary.BigEndian.PutUint32(packet[4:8], stunMagicCookie)
+	_, _ = rand.Read(packet[8:20])
+	return packet, packet[8:20]
+}
+
+// isSTUNMessage проверяет заголовок STUN сообщения (RFC 5389)
+func isSTUNMessage(data []byte) bool {
+	return len(data) >= 20 && data[0]&0xc0 == 0 && binary.BigEndian.Uint32(data[4:8]) == stunMagicCookie
+}
+
+// isSTUNResponse проверяет, что пакет - ответ (успешный или ошибка) на нашу транзакцию
+func isSTUNResponse(data []byte, transactionID []byte) bool {
+	if !isSTUNMessage(data) {
+		return false
+	}
+	class := binary.BigEndian.Uint16(data[0:2]) & 0x0110
+	return (class == 0x0100 || class == 0x0110) && string(data[8:20]) == string(transactionID)
+}
+
+// isPortUnreachable проверяет, что ОС получила ICMP unreachable для адреса
+func isPortUnreachable(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH)
+}
+
+// rejects проверяет, отклоняет ли политика вызов с данным результатом
+func (c *MediaProbeConfig) rejects(status MediaProbeStatus) bool {
+	switch c.Policy {
+	case MediaProbeRejectUnreachable:
+		return status == MediaProbeUnreachable
+	case MediaProbeRejectNoResponse:
+		return status == MediaProbeUnreachable || status == MediaProbeNoResponse
+	default:
+		return false
+	}
+}
+
+// probeMedia проверяет медиа адрес offer входящего INVITE перед 200 OK
+// и отвечает 488, если политика отклоняет вызов
+func (t *TX) probeMedia() error {
+	if t.dialog.uu == nil || t.dialog.uu.config.MediaProbe == nil || t.dialog.getFirstTX() != t {
+		return nil
+	}
+	cfg := t.dialog.uu.config.MediaProbe
+
+	result := &MediaProbeResult{Status: MediaProbeSkipped}
+	sdpBody := t.dialog.RemoteSDP()
+	if target, ok := parseMediaProbeTarget(sdpBody.Content()); ok {
+		ctx := t.dialog.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		result = cfg.probe(ctx, target)
+	}
+	t.dialog.mediaProbe.Store(result)
+
+	if !cfg.rejects(result.Status) {
+		return nil
+	}
+	warning := fmt.Sprintf(`399 - "Media path %s %s"`, result.RemoteAddr, result.Status)
+	if err := t.Reject(sip.StatusNotAcceptableHere, "Not Acceptable Here",
+		ResponseWithHeaderString("Warning", warning)); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s (%s)", ErrMediaUnreachable, result.RemoteAddr, result.Status)
+}
+
+// MediaProbe возвращает результат проверки медиа пути входящего вызова
+// (Config.MediaProbe) или nil, если проверка не выполнялась
+func (s *Dialog) MediaProbe() *MediaProbeResult {
+	return s.mediaProbe.Load()
+}
@@ -0,0 +1,127 @@
+package dialog
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probeSDP(addr *net.UDPAddr) string {
+	return fmt.Sprintf("v=0\r\no=- 1 1 IN IP4 %[1]s\r\ns=-\r\nc=IN IP4 %[1]s\r\nt=0 0\r\nm=audio %[2]d RTP/AVP 8 0\r\n",
+		addr.IP, addr.Port)
+}
+
+// closedUDPAddr возвращает адрес порта, который никто не слушает
+func closedUDPAddr(t *testing.T) *net.UDPAddr {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	require.NoError(t, conn.Close())
+	return addr
+}
+
+// stunResponder отвечает Binding Success Response на STUN запросы
+func stunResponder(t *testing.T) *net.UDPAddr {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if n < 20 || !isSTUNMessage(buf[:n]) {
+				continue
+			}
+			resp := make([]byte, 20)
+			copy(resp, buf[:20])
+			binary.BigEndian.PutUint16(resp[0:2], 0x0101)
+			_, _ = conn.WriteToUDP(resp, from)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func TestParseMediaProbeTarget(t *testing.T) {
+	target, ok := parseMediaProbeTarget([]byte(probeSDP(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 4000})))
+	require.True(t, ok)
+	assert.Equal(t, "10.0.0.5:4000", target.addr)
+	assert.Equal(t, uint8(8), target.payloadType)
+
+	_, ok = parseMediaProbeTarget([]byte(probeSDP(&net.UDPAddr{IP: net.IPv4zero, Port: 4000})))
+	assert.False(t, ok, "Удержание c=0.0.0.0")
+	_, ok = parseMediaProbeTarget([]byte(probeSDP(&net.UDPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 0})))
+	assert.False(t, ok, "Отключенный поток")
+	_, ok = parseMediaProbeTarget(nil)
+	assert.False(t, ok, "Без SDP")
+}
+
+func TestMediaProbe(t *testing.T) {
+	ctx := context.Background()
+
+	// STUN ответ подтверждает доступность
+	cfg := &MediaProbeConfig{Mode: MediaProbeSTUN, Timeout: time.Second, Interval: 50 * time.Millisecond}
+	result := cfg.Probe(ctx, stunResponder(t).String())
+	assert.Equal(t, MediaProbeReachable, result.Status)
+	assert.Positive(t, result.Sent)
+
+	// ICMP port unreachable
+	cfg = &MediaProbeConfig{Mode: MediaProbeRTP, Timeout: time.Second, Interval: 50 * time.Millisecond}
+	result = cfg.Probe(ctx, closedUDPAddr(t).String())
+	assert.Equal(t, MediaProbeUnreachable, result.Status)
+	assert.Error(t, result.Err)
+
+	// Порт слушается, но не отвечает
+	silent, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer silent.Close()
+	cfg = &MediaProbeConfig{Mode: MediaProbeRTP, Timeout: 150 * time.Millisecond, Interval: 50 * time.Millisecond}
+	result = cfg.Probe(ctx, silent.LocalAddr().String())
+	assert.Equal(t, MediaProbeNoResponse, result.Status)
+	assert.GreaterOrEqual(t, result.Sent, 2)
+
+	assert.False(t, (&MediaProbeConfig{Policy: MediaProbeProceed}).rejects(MediaProbeUnreachable))
+	assert.False(t, (&MediaProbeConfig{Policy: MediaProbeRejectUnreachable}).rejects(MediaProbeNoResponse))
+	assert.True(t, (&MediaProbeConfig{Policy: MediaProbeRejectNoResponse}).rejects(MediaProbeNoResponse))
+}
+
+func TestMediaProbeAccept(t *testing.T) {
+	u, err := NewUACUAS(Config{
+		TestMode:   true,
+		MediaProbe: &MediaProbeConfig{Mode: MediaProbeRTP, Policy: MediaProbeRejectUnreachable, Timeout: time.Second},
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 35990},
+		},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	var acceptErr error
+	var incoming IDialog
+	u.OnIncomingCall(func(d IDialog, tx IServerTX) {
+		incoming = d
+		acceptErr = tx.Accept()
+	})
+
+	req := newTestRequest(sip.INVITE)
+	WithSDP(probeSDP(closedUDPAddr(t)))(req)
+	tx := newRecordingServerTX()
+	u.handleInvite(req, tx)
+
+	require.Len(t, tx.responses, 1)
+	assert.Equal(t, sip.StatusNotAcceptableHere, tx.responses[0].StatusCode)
+	require.NotNil(t, tx.responses[0].GetHeader("Warning"))
+	assert.ErrorIs(t, acceptErr, ErrMediaUnreachable)
+	require.NotNil(t, incoming.MediaProbe())
+	assert.Equal(t, MediaProbeUnreachable, incoming.MediaProbe().Status)
+}
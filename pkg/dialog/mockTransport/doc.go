@@ -9,6 +9,7 @@
 //   - Поддержка deadlines и timeouts
 //   - Централизованный Registry для управления соединениями
 //   - Эмуляция сетевых ошибок для тестирования
+//   - Воспроизведение захватов pcap с исходными интервалами (ReadPcapFile, Registry.Replay)
 //
 // Пример использования:
 //
@@ -24,4 +25,11 @@
 //	// Чтение данных на conn2
 //	buf := make([]byte, 1024)
 //	n, addr, err := conn2.ReadFrom(buf)
+//
+// Воспроизведение захвата реального трафика: соединения создаются с адресами
+// назначения из захвата в виде "ip:port"
+//
+//	packets, err := mockTransport.ReadPcapFile("testdata/call.pcap")
+//	rtpConn := registry.CreateConnection("10.0.0.2:50000")
+//	result, err := registry.Replay(ctx, packets, mockTransport.ReplayOptions{Speed: 1})
 package mockTransport
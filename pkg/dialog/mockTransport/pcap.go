@@ -0,0 +1,263 @@
+package mockTransport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// Магические числа заголовка pcap файла (микро- и наносекундные метки времени)
+const (
+	pcapMagicMicros = 0xa1b2c3d4
+	pcapMagicNanos  = 0xa1b23c4d
+)
+
+// Типы канального уровня pcap (LINKTYPE_*), которые умеет разбирать reader
+const (
+	LinkTypeNull     = 0   // BSD loopback
+	LinkTypeEthernet = 1   // Ethernet II, в том числе с 802.1Q VLAN
+	LinkTypeRaw      = 101 // IPv4/IPv6 без канального заголовка
+	LinkTypeLinuxSLL = 113 // Linux cooked capture (tcpdump -i any)
+)
+
+// CapturedPacket - UDP датаграмма из pcap файла с исходным временем захвата
+type CapturedPacket struct {
+	Timestamp time.Time
+	Src       *net.UDPAddr
+	Dst       *net.UDPAddr
+	Payload   []byte
+}
+
+// ReadPcapFile читает UDP датаграммы из pcap файла
+func ReadPcapFile(path string) ([]CapturedPacket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadPcap(f)
+}
+
+// ReadPcap читает UDP датаграммы из потока в формате pcap (libpcap).
+// Пакеты других протоколов, фрагменты IP и усеченные при захвате пакеты
+// пропускаются. Формат pcapng не поддерживается: такие файлы нужно
+// сохранить как pcap (editcap -F pcap).
+func ReadPcap(r io.Reader) ([]CapturedPacket, error) {
+	var header [24]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("pcap: чтение заголовка: %w", err)
+	}
+
+	var order binary.ByteOrder
+	var nanos bool
+	switch {
+	case binary.LittleEndian.Uint32(header[0:4]) == pcapMagicMicros:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header[0:4]) == pcapMagicMicros:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header[0:4]) == pcapMagicNanos:
+		order, nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(header[0:4]) == pcapMagicNanos:
+		order, nanos = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("pcap: неизвестный формат файла (magic %x)", header[0:4])
+	}
+	linkType := order.Uint32(header[20:24]) & 0x0fffffff
+
+	var packets []CapturedPacket
+	var record [16]byte
+	for {
+		if _, err := io.ReadFull(r, record[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return packets, nil
+			}
+			return nil, fmt.Errorf("pcap: чтение записи %d: %w", len(packets)+1, err)
+		}
+
+		sec := int64(order.Uint32(record[0:4]))
+		frac := int64(order.Uint32(record[4:8]))
+		capLen := order.Uint32(record[8:12])
+		origLen := order.Uint32(record[12:16])
+		if capLen > 1<<18 {
+			return nil, fmt.Errorf("pcap: некорректная длина записи %d", capLen)
+		}
+
+		data := make([]byte, capLen)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("pcap: чтение данных записи: %w", err)
+		}
+		if capLen < origLen {
+			continue
+		}
+
+		if !nanos {
+			frac *= int64(time.Microsecond)
+		}
+		pkt, ok := decodeUDP(linkType, data)
+		if !ok {
+			continue
+		}
+		pkt.Timestamp = time.Unix(sec, frac)
+		packets = append(packets, pkt)
+	}
+}
+
+// decodeUDP извлекает UDP датаграмму из кадра канального уровня
+func decodeUDP(linkType uint32, frame []byte) (CapturedPacket, bool) {
+	var etherType uint16
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(frame) < 14 {
+			return CapturedPacket{}, false
+		}
+		etherType = binary.BigEndian.Uint16(frame[12:14])
+		frame = frame[14:]
+		// 802.1Q и 802.1ad VLAN теги
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(frame) >= 4 {
+			etherType = binary.BigEndian.Uint16(frame[2:4])
+			frame = frame[4:]
+		}
+	case LinkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return CapturedPacket{}, false
+		}
+		etherType = binary.BigEndian.Uint16(frame[14:16])
+		frame = frame[16:]
+	case LinkTypeNull:
+		if len(frame) < 4 {
+			return CapturedPacket{}, false
+		}
+		// Семейство адресов записано в порядке байт захватившей машины
+		family := binary.LittleEndian.Uint32(frame[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(frame[0:4])
+		}
+		etherType = 0x0800
+		if family != 2 {
+			etherType = 0x86dd
+		}
+		frame = frame[4:]
+	case LinkTypeRaw:
+		if len(frame) == 0 {
+			return CapturedPacket{}, false
+		}
+		etherType = 0x0800
+		if frame[0]>>4 == 6 {
+			etherType = 0x86dd
+		}
+	default:
+		return CapturedPacket{}, false
+	}
+
+	var src, dst net.IP
+	var udp []byte
+	switch etherType {
+	case 0x0800:
+		if len(frame) < 20 || frame[0]>>4 != 4 {
+			return CapturedPacket{}, false
+		}
+		headerLen := int(frame[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(frame[2:4]))
+		fragment := binary.BigEndian.Uint16(frame[6:8])
+		// Протокол UDP, без фрагментации (MF=0, offset=0)
+		if frame[9] != 17 || fragment&0x3fff != 0 || headerLen < 20 || totalLen > len(frame) || totalLen < headerLen {
+			return CapturedPacket{}, false
+		}
+		src, dst = net.IP(frame[12:16]), net.IP(frame[16:20])
+		udp = frame[headerLen:totalLen]
+	case 0x86dd:
+		// Заголовки расширения IPv6 не поддерживаются
+		if len(frame) < 40 || frame[6] != 17 {
+			return CapturedPacket{}, false
+		}
+		payloadLen := int(binary.BigEndian.Uint16(frame[4:6]))
+		if 40+payloadLen > len(frame) {
+			return CapturedPacket{}, false
+		}
+		src, dst = net.IP(frame[8:24]), net.IP(frame[24:40])
+		udp = frame[40 : 40+payloadLen]
+	default:
+		return CapturedPacket{}, false
+	}
+
+	if len(udp) < 8 {
+		return CapturedPacket{}, false
+	}
+	length := int(binary.BigEndian.Uint16(udp[4:6]))
+	if length < 8 || length > len(udp) {
+		return CapturedPacket{}, false
+	}
+	return CapturedPacket{
+		Src:     &net.UDPAddr{IP: append(net.IP(nil), src...), Port: int(binary.BigEndian.Uint16(udp[0:2]))},
+		Dst:     &net.UDPAddr{IP: append(net.IP(nil), dst...), Port: int(binary.BigEndian.Uint16(udp[2:4]))},
+		Payload: append([]byte(nil), udp[8:length]...),
+	}, true
+}
+
+// PcapWriter записывает UDP датаграммы в pcap файл (Ethernet, IPv4).
+// Используется для подготовки фикстур регрессионных тестов
+type PcapWriter struct {
+	w io.Writer
+}
+
+// NewPcapWriter записывает заголовок pcap файла и возвращает writer
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	var header [24]byte
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagicMicros)
+	binary.LittleEndian.PutUint16(header[4:6], 2) // Версия 2.4
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], 65535) // snaplen
+	binary.LittleEndian.PutUint32(header[20:24], LinkTypeEthernet)
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+// WritePacket записывает датаграмму с IPv4 адресами
+func (p *PcapWriter) WritePacket(pkt CapturedPacket) error {
+	src, dst := pkt.Src.IP.To4(), pkt.Dst.IP.To4()
+	if src == nil || dst == nil {
+		return fmt.Errorf("pcap: поддерживаются только IPv4 адреса")
+	}
+
+	frame := make([]byte, 14+20+8+len(pkt.Payload))
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+
+	ip := frame[14:34]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+8+len(pkt.Payload)))
+	ip[8] = 64 // TTL
+	ip[9] = 17 // UDP
+	copy(ip[12:16], src)
+	copy(ip[16:20], dst)
+	var sum uint32
+	for i := 0; i < 20; i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(ip[i:]))
+	}
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	binary.BigEndian.PutUint16(ip[10:12], ^uint16(sum))
+
+	udp := frame[34:]
+	binary.BigEndian.PutUint16(udp[0:2], uint16(pkt.Src.Port))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(pkt.Dst.Port))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(pkt.Payload)))
+	copy(udp[8:], pkt.Payload)
+
+	var record [16]byte
+	binary.LittleEndian.PutUint32(record[0:4], uint32(pkt.Timestamp.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(pkt.Timestamp.Nanosecond()/int(time.Microsecond)))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(frame)))
+	if _, err := p.w.Write(record[:]); err != nil {
+		return err
+	}
+	_, err := p.w.Write(frame)
+	return err
+}
@@ -0,0 +1,132 @@
+package mockTransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func testCapture(base time.Time) []CapturedPacket {
+	caller := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 40000}
+	callee := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 50000}
+	return []CapturedPacket{
+		{Timestamp: base, Src: caller, Dst: callee, Payload: []byte("first")},
+		{Timestamp: base.Add(20 * time.Millisecond), Src: callee, Dst: caller, Payload: []byte("reply")},
+		{Timestamp: base.Add(40 * time.Millisecond), Src: caller, Dst: callee, Payload: []byte("second")},
+	}
+}
+
+func TestPcapRoundTrip(t *testing.T) {
+	base := time.Unix(1700000000, 123456000)
+	var buf bytes.Buffer
+	w, err := NewPcapWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewPcapWriter() error = %v", err)
+	}
+	for _, pkt := range testCapture(base) {
+		if err := w.WritePacket(pkt); err != nil {
+			t.Fatalf("WritePacket() error = %v", err)
+		}
+	}
+
+	packets, err := ReadPcap(&buf)
+	if err != nil {
+		t.Fatalf("ReadPcap() error = %v", err)
+	}
+	if len(packets) != 3 {
+		t.Fatalf("ReadPcap() = %d packets, want 3", len(packets))
+	}
+	if !packets[0].Timestamp.Equal(base) || packets[2].Timestamp.Sub(packets[0].Timestamp) != 40*time.Millisecond {
+		t.Errorf("timestamps = %v, %v", packets[0].Timestamp, packets[2].Timestamp)
+	}
+	if packets[1].Src.String() != "10.0.0.2:50000" || packets[1].Dst.String() != "10.0.0.1:40000" {
+		t.Errorf("addresses = %v -> %v", packets[1].Src, packets[1].Dst)
+	}
+	if string(packets[2].Payload) != "second" {
+		t.Errorf("payload = %q, want %q", packets[2].Payload, "second")
+	}
+}
+
+func TestPcapLinkTypes(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewPcapWriter(&buf)
+	_ = w.WritePacket(testCapture(time.Unix(0, 0))[0])
+	ethernet := buf.Bytes()[24+16:]
+	ipv4 := ethernet[14:]
+
+	vlan := append(append(append([]byte(nil), ethernet[:12]...), 0x81, 0x00, 0x00, 0x64), ethernet[12:]...)
+	sll := append(make([]byte, 16), ipv4...)
+	binary.BigEndian.PutUint16(sll[14:16], 0x0800)
+	fragment := append([]byte(nil), ipv4...)
+	fragment[6] = 0x20 // MF
+
+	tests := []struct {
+		name     string
+		linkType uint32
+		frame    []byte
+		want     bool
+	}{
+		{"ethernet", LinkTypeEthernet, ethernet, true},
+		{"vlan", LinkTypeEthernet, vlan, true},
+		{"linux sll", LinkTypeLinuxSLL, sll, true},
+		{"raw", LinkTypeRaw, ipv4, true},
+		{"loopback", LinkTypeNull, append([]byte{2, 0, 0, 0}, ipv4...), true},
+		{"fragment", LinkTypeRaw, fragment, false},
+		{"truncated", LinkTypeEthernet, ethernet[:30], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, ok := decodeUDP(tt.linkType, tt.frame)
+			if ok != tt.want {
+				t.Fatalf("decodeUDP() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && (string(pkt.Payload) != "first" || pkt.Dst.Port != 50000) {
+				t.Errorf("decodeUDP() = %v -> %v %q", pkt.Src, pkt.Dst, pkt.Payload)
+			}
+		})
+	}
+
+	if _, err := ReadPcap(bytes.NewReader([]byte{0x0a, 0x0d, 0x0d, 0x0a})); err == nil {
+		t.Error("ReadPcap() should reject pcapng and short files")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	registry := NewRegistry()
+	defer registry.CloseAll()
+	callee := registry.CreateConnection("10.0.0.2:50000")
+
+	packets := testCapture(time.Now())
+	started := time.Now()
+	result, err := registry.Replay(context.Background(), packets, ReplayOptions{Speed: 1})
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Errorf("Replay() took %v, original timing is 40ms", elapsed)
+	}
+	if result.Delivered != 2 || result.Skipped != 1 {
+		t.Errorf("Replay() = %+v, want 2 delivered, 1 skipped", result)
+	}
+
+	buf := make([]byte, 64)
+	for _, want := range []string{"first", "second"} {
+		_ = callee.SetReadDeadline(time.Now().Add(time.Second))
+		n, from, err := callee.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom() error = %v", err)
+		}
+		if string(buf[:n]) != want || from.String() != "10.0.0.1:40000" {
+			t.Errorf("ReadFrom() = %q from %v, want %q from 10.0.0.1:40000", buf[:n], from, want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := registry.Replay(ctx, packets, ReplayOptions{Speed: 1}); err == nil {
+		t.Error("Replay() should stop on cancelled context")
+	}
+}
@@ -0,0 +1,72 @@
+package mockTransport
+
+import (
+	"context"
+	"time"
+)
+
+// ReplayOptions - параметры воспроизведения захваченного трафика
+type ReplayOptions struct {
+	// Speed - множитель скорости: 1 - исходный темп захвата, 2 - вдвое
+	// быстрее. 0 или меньше - без пауз между пакетами
+	Speed float64
+
+	// Filter отбирает пакеты для воспроизведения. nil - все пакеты
+	Filter func(CapturedPacket) bool
+}
+
+// ReplayResult - итоги воспроизведения
+type ReplayResult struct {
+	Delivered int // Доставлено в соединения Registry
+	Skipped   int // Нет соединения с адресом получателя или отфильтрованы
+	Failed    int // Соединение закрыто или буфер переполнен
+}
+
+// Replay доставляет захваченные датаграммы в соединения Registry с
+// сохранением интервалов между пакетами. Получатель определяется по адресу
+// назначения пакета в виде "ip:port", поэтому соединения нужно создать с
+// адресами из захвата. Отправитель передается как MockAddr с адресом
+// источника. Воспроизведение прерывается при отмене ctx.
+func (r *Registry) Replay(ctx context.Context, packets []CapturedPacket, opts ReplayOptions) (ReplayResult, error) {
+	var result ReplayResult
+	if len(packets) == 0 {
+		return result, nil
+	}
+
+	start := time.Now()
+	first := packets[0].Timestamp
+	for _, pkt := range packets {
+		if opts.Filter != nil && !opts.Filter(pkt) {
+			result.Skipped++
+			continue
+		}
+
+		if opts.Speed > 0 {
+			due := start.Add(time.Duration(float64(pkt.Timestamp.Sub(first)) / opts.Speed))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return result, ctx.Err()
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		to := pkt.Dst.String()
+		if _, ok := r.GetConnection(to); !ok {
+			result.Skipped++
+			continue
+		}
+		if err := r.DeliverPacket(to, pkt.Payload, NewMockAddr(pkt.Src.String())); err != nil {
+			result.Failed++
+			continue
+		}
+		result.Delivered++
+	}
+	return result, nil
+}
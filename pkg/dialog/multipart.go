@@ -0,0 +1,172 @@
+package dialog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	// ContentTypeSDP - тип содержимого SDP
+	ContentTypeSDP = "application/sdp"
+	// ContentTypeMultipartMixed - тип содержимого составного тела (RFC 5621)
+	ContentTypeMultipartMixed = "multipart/mixed"
+
+	// defaultPartContentType - тип части без Content-Type (RFC 2046 Section 5.1)
+	defaultPartContentType = "text/plain"
+)
+
+// NewBody создает тело сообщения с типом содержимого contentType
+func NewBody(contentType string, content []byte) *Body {
+	return &Body{contentType: contentType, content: content}
+}
+
+// NewMultipartBody создает составное тело multipart/mixed (RFC 5621),
+// например SDP вместе с ISUP или PIDF-LO:
+//
+//	isup := dialog.NewBody("application/isup;version=itu-t92+", isupData)
+//	isup.SetHeader("Content-Disposition", "signal;handling=optional")
+//	body := dialog.NewMultipartBody(dialog.NewBody(dialog.ContentTypeSDP, sdp), isup)
+//	tx, err := d.Start(ctx, target, dialog.WithMultipartBody(body))
+func NewMultipartBody(parts ...*Body) *Body {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, part := range parts {
+		header := make(textproto.MIMEHeader, len(part.headers)+1)
+		for name, values := range part.headers {
+			header[name] = values
+		}
+		header.Set("Content-Type", part.contentType)
+		// Запись в bytes.Buffer не возвращает ошибок
+		w, _ := mw.CreatePart(header)
+		_, _ = w.Write(part.content)
+	}
+	_ = mw.Close()
+
+	return &Body{
+		contentType: mime.FormatMediaType(ContentTypeMultipartMixed, map[string]string{"boundary": mw.Boundary()}),
+		content:     buf.Bytes(),
+	}
+}
+
+// mediaType возвращает тип содержимого без параметров в нижнем регистре
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt, _, _ = strings.Cut(contentType, ";")
+		return strings.ToLower(strings.TrimSpace(mt))
+	}
+	return mt
+}
+
+// IsMultipart проверяет, является ли тело составным (multipart/*)
+func (b *Body) IsMultipart() bool {
+	return strings.HasPrefix(mediaType(b.contentType), "multipart/")
+}
+
+// Header возвращает заголовок части составного тела, например Content-Disposition
+func (b *Body) Header(name string) string {
+	return b.headers.Get(name)
+}
+
+// SetHeader задает заголовок части для NewMultipartBody (кроме Content-Type)
+func (b *Body) SetHeader(name, value string) {
+	if b.headers == nil {
+		b.headers = make(textproto.MIMEHeader)
+	}
+	b.headers.Set(name, value)
+}
+
+// Parts возвращает части составного тела. Для обычного тела возвращается
+// само тело
+func (b *Body) Parts() ([]*Body, error) {
+	if !b.IsMultipart() {
+		return []*Body{b}, nil
+	}
+
+	_, params, err := mime.ParseMediaType(b.contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multipart content type %q: %w", b.contentType, err)
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, fmt.Errorf("multipart content type %q has no boundary", b.contentType)
+	}
+
+	var parts []*Body
+	mr := multipart.NewReader(bytes.NewReader(b.content), boundary)
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read multipart body: %w", err)
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read multipart body: %w", err)
+		}
+
+		part := &Body{contentType: p.Header.Get("Content-Type"), content: content}
+		if part.contentType == "" {
+			part.contentType = defaultPartContentType
+		}
+		p.Header.Del("Content-Type")
+		if len(p.Header) > 0 {
+			part.headers = p.Header
+		}
+		parts = append(parts, part)
+	}
+}
+
+// Part возвращает первую часть тела с типом содержимого contentType
+// (параметры типа не учитываются). Вложенные составные части
+// (например multipart/alternative) просматриваются рекурсивно. Обычное
+// тело возвращается, если его тип совпадает с contentType
+func (b *Body) Part(contentType string) (*Body, bool) {
+	want := mediaType(contentType)
+	if !b.IsMultipart() {
+		return b, mediaType(b.contentType) == want
+	}
+
+	parts, err := b.Parts()
+	if err != nil {
+		return nil, false
+	}
+	for _, part := range parts {
+		if found, ok := part.Part(want); ok {
+			return found, true
+		}
+	}
+	return nil, false
+}
+
+// WithMultipartBody устанавливает тело сообщения с его Content-Type, например
+// составное тело из NewMultipartBody
+func WithMultipartBody(body *Body) RequestOpt {
+	return func(msg sip.Message) {
+		ct := sip.ContentTypeHeader(body.contentType)
+		msg.AppendHeader(&ct)
+		msg.SetBody(body.content)
+	}
+}
+
+// ResponseWithMultipartBody устанавливает тело ответа с его Content-Type
+func ResponseWithMultipartBody(body *Body) ResponseOpt {
+	return ResponseOpt(WithMultipartBody(body))
+}
+
+// RemoteBody возвращает тело удаленного участника целиком, включая все части
+// составного тела
+func (s *Dialog) RemoteBody() Body {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
+	return s.remoteBody
+}
@@ -0,0 +1,103 @@
+package dialog
+
+import (
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testMultipartSDP  = "v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\nm=audio 10000 RTP/AVP 0\r\n"
+	testMultipartPIDF = `<?xml version="1.0"?><presence xmlns="urn:ietf:params:xml:ns:pidf" entity="pres:alice@example.com"/>`
+)
+
+func TestMultipartBodyRoundTrip(t *testing.T) {
+	isup := NewBody("application/isup;version=itu-t92+", []byte{0x01, 0x00, 0x49, 0x00, 0x00})
+	isup.SetHeader("Content-Disposition", "signal;handling=optional")
+	body := NewMultipartBody(
+		NewBody(ContentTypeSDP, []byte(testMultipartSDP)),
+		NewBody("application/pidf+xml", []byte(testMultipartPIDF)),
+		isup,
+	)
+	assert.True(t, body.IsMultipart())
+	assert.Contains(t, body.ContentType(), "multipart/mixed;")
+
+	// Тело передается в SIP сообщении и разбирается на принимающей стороне
+	req := newTestRequest(sip.INVITE)
+	WithMultipartBody(body)(req)
+	parsed, err := sip.ParseMessage([]byte(req.String()))
+	require.NoError(t, err)
+	received := extractBody(parsed)
+	require.NotNil(t, received)
+
+	parts, err := received.Parts()
+	require.NoError(t, err)
+	require.Len(t, parts, 3)
+	assert.Equal(t, ContentTypeSDP, parts[0].ContentType())
+	assert.Equal(t, testMultipartSDP, string(parts[0].Content()))
+	assert.Equal(t, []byte{0x01, 0x00, 0x49, 0x00, 0x00}, parts[2].Content())
+	assert.Equal(t, "signal;handling=optional", parts[2].Header("Content-Disposition"))
+	assert.Empty(t, parts[0].Header("Content-Disposition"))
+
+	pidf, ok := received.Part("Application/PIDF+XML")
+	require.True(t, ok)
+	assert.Equal(t, testMultipartPIDF, string(pidf.Content()))
+	_, ok = received.Part("application/resource-lists+xml")
+	assert.False(t, ok)
+}
+
+func TestMultipartBodyParsing(t *testing.T) {
+	// Вложенное multipart/alternative и часть без Content-Type
+	content := "--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+		"--inner\r\nContent-Type: application/sdp\r\n\r\n" + testMultipartSDP + "\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\n\r\nhello\r\n" +
+		"--outer--\r\n"
+	body := NewBody(`multipart/mixed; boundary="outer"`, []byte(content))
+
+	parts, err := body.Parts()
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	assert.True(t, parts[0].IsMultipart())
+	assert.Equal(t, "text/plain", parts[1].ContentType())
+
+	sdp, ok := body.Part(ContentTypeSDP)
+	require.True(t, ok)
+	assert.Equal(t, testMultipartSDP, string(sdp.Content()))
+
+	// Обычное тело
+	single := NewBody("application/sdp", []byte(testMultipartSDP))
+	parts, err = single.Parts()
+	require.NoError(t, err)
+	assert.Equal(t, []*Body{single}, parts)
+	found, ok := single.Part("application/sdp")
+	assert.True(t, ok)
+	assert.Same(t, single, found)
+
+	// Некорректное составное тело
+	_, err = NewBody("multipart/mixed", []byte(content)).Parts()
+	assert.Error(t, err, "Без boundary")
+	_, ok = NewBody("multipart/mixed;boundary=missing", []byte(content)).Part(ContentTypeSDP)
+	assert.False(t, ok)
+}
+
+func TestDialogRemoteSDPMultipart(t *testing.T) {
+	body := NewMultipartBody(
+		NewBody("application/pidf+xml", []byte(testMultipartPIDF)),
+		NewBody(ContentTypeSDP, []byte(testMultipartSDP)),
+	)
+
+	d := &Dialog{}
+	d.SetRemoteSDP(body.ContentType(), body.Content())
+	sdp, full := d.RemoteSDP(), d.RemoteBody()
+	assert.Equal(t, ContentTypeSDP, sdp.ContentType())
+	assert.Equal(t, testMultipartSDP, string(sdp.Content()))
+	assert.Equal(t, body.ContentType(), full.ContentType())
+
+	d.SetRemoteSDP(ContentTypeSDP, []byte(testMultipartSDP))
+	sdp = d.RemoteSDP()
+	assert.Equal(t, testMultipartSDP, string(sdp.Content()))
+}
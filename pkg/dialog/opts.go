@@ -118,6 +118,12 @@ func WithUserAgent(userAgent string) RequestOpt {
 // WithAllow устанавливает Allow заголовок со списком разрешенных методов
 func WithAllow(methods ...string) RequestOpt {
 	return func(msg sip.Message) {
+		// Удаляем существующий заголовок, в том числе добавленный автоматически
+		if req, ok := msg.(*sip.Request); ok {
+			req.RemoveHeader("Allow")
+		} else if resp, ok := msg.(*sip.Response); ok {
+			resp.RemoveHeader("Allow")
+		}
 		allow := sip.NewHeader("Allow", strings.Join(methods, ", "))
 		msg.AppendHeader(allow)
 	}
@@ -126,6 +132,12 @@ func WithAllow(methods ...string) RequestOpt {
 // WithSupported устанавливает Supported заголовок со списком поддерживаемых опций
 func WithSupported(options ...string) RequestOpt {
 	return func(msg sip.Message) {
+		// Удаляем существующий заголовок, в том числе добавленный автоматически
+		if req, ok := msg.(*sip.Request); ok {
+			req.RemoveHeader("Supported")
+		} else if resp, ok := msg.(*sip.Response); ok {
+			resp.RemoveHeader("Supported")
+		}
 		supported := sip.NewHeader("Supported", strings.Join(options, ", "))
 		msg.AppendHeader(supported)
 	}
@@ -304,7 +316,8 @@ func WithCallID(callID string) RequestOpt {
 	}
 }
 
-// WithCSeq устанавливает CSeq заголовок
+// WithCSeq устанавливает CSeq заголовок. Dialog отправляет запрос с этим
+// номером, а следующие запросы диалога получают номера больше него
 func WithCSeq(seqNo uint32, method sip.RequestMethod) RequestOpt {
 	return func(msg sip.Message) {
 		cseq := &sip.CSeqHeader{
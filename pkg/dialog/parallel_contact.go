@@ -0,0 +1,319 @@
+package dialog
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	// DefaultParallelContactStagger - задержка запуска следующей попытки
+	DefaultParallelContactStagger = 250 * time.Millisecond
+	// DefaultParallelContactTimeout - общее время выбора пути
+	DefaultParallelContactTimeout = 2 * time.Second
+	// DefaultParallelContactCacheTTL - время, в течение которого выбранный
+	// путь используется без повторных попыток
+	DefaultParallelContactCacheTTL = 5 * time.Minute
+)
+
+// ParallelContactConfig - выбор пути к адресату первичного INVITE в стиле
+// Happy Eyeballs (RFC 8305). Если имя адресата разрешается в несколько
+// адресов или сконфигурировано несколько транспортов, каждой паре
+// адрес/транспорт отправляется OPTIONS. Попытки запускаются по очереди с
+// интервалом Stagger (следующая - сразу, если предыдущая завершилась
+// ошибкой), INVITE уходит по первому ответившему пути. Любой ответ на OPTIONS,
+// включая 4xx/5xx, подтверждает доступность пути.
+//
+// Пробы OPTIONS, а не параллельные INVITE, не дают адресату получить один
+// вызов несколько раз. Выбранный путь запоминается на CacheTTL, поэтому
+// задержка выбора приходится только на первый контакт. Если не ответил ни
+// один путь, INVITE отправляется как обычно.
+type ParallelContactConfig struct {
+	// Transports - транспорты попыток в порядке предпочтения. Должны быть
+	// сконфигурированы в TransportConfigs. По умолчанию - все сконфигурированные
+	// UDP, TCP и TLS
+	Transports []TransportType
+	Stagger    time.Duration // По умолчанию DefaultParallelContactStagger
+	Timeout    time.Duration // По умолчанию DefaultParallelContactTimeout
+	CacheTTL   time.Duration // По умолчанию DefaultParallelContactCacheTTL
+}
+
+// ContactPath - выбранный путь к адресату
+type ContactPath struct {
+	Address   string // host:port
+	Transport TransportType
+}
+
+// contactCandidate - попытка выбора пути
+type contactCandidate struct {
+	path   ContactPath
+	config TransportConfig
+}
+
+// contactPathCache запоминает выбранные пути по host:port адресата
+type contactPathCache struct {
+	mu    sync.Mutex
+	paths map[string]cachedContactPath
+}
+
+type cachedContactPath struct {
+	path    ContactPath
+	expires time.Time
+}
+
+func (c *contactPathCache) get(key string, now time.Time) (ContactPath, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.paths[key]
+	if !ok || now.After(entry.expires) {
+		return ContactPath{}, false
+	}
+	return entry.path, true
+}
+
+func (c *contactPathCache) set(key string, path ContactPath, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paths == nil {
+		c.paths = make(map[string]cachedContactPath)
+	}
+	c.paths[key] = cachedContactPath{path: path, expires: expires}
+}
+
+// ContactPath возвращает путь, выбранный для адресата (host или host:port из
+// Request-URI первичного INVITE) по Config.ParallelContact
+func (u *UACUAS) ContactPath(target string) (ContactPath, bool) {
+	return u.contactPaths.get(target, time.Now())
+}
+
+// interleaveFamilies чередует адреса IPv6 и IPv4, начиная с семейства
+// первого адреса (RFC 8305 Section 4)
+func interleaveFamilies(ips []net.IP) []net.IP {
+	var first, second []net.IP
+	for _, ip := range ips {
+		if (ip.To4() == nil) == (ips[0].To4() == nil) {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+	result := make([]net.IP, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			result = append(result, first[i])
+		}
+		if i < len(second) {
+			result = append(result, second[i])
+		}
+	}
+	return result
+}
+
+// contactCandidates строит попытки для адресата: адреса чередуются по
+// семействам, для каждого адреса перебираются транспорты
+func (u *UACUAS) contactCandidates(ctx context.Context, target sip.Uri) ([]contactCandidate, error) {
+	cfg := u.config.ParallelContact
+
+	var configs []TransportConfig
+	if len(cfg.Transports) > 0 {
+		for _, tp := range cfg.Transports {
+			for _, tc := range u.config.TransportConfigs {
+				if tc.Type == tp {
+					configs = append(configs, tc)
+					break
+				}
+			}
+		}
+	} else {
+		for _, tc := range u.config.TransportConfigs {
+			if tc.Type == TransportUDP || tc.Type == TransportTCP || tc.Type == TransportTLS {
+				configs = append(configs, tc)
+			}
+		}
+	}
+
+	ips := []net.IP{net.ParseIP(target.Host)}
+	if ips[0] == nil {
+		var err error
+		if ips, err = u.lookupIP(ctx, target.Host); err != nil {
+			return nil, err
+		}
+	}
+	if len(ips) == 0 {
+		return nil, nil
+	}
+
+	var candidates []contactCandidate
+	for _, ip := range interleaveFamilies(ips) {
+		for _, tc := range configs {
+			port := target.Port
+			if port == 0 {
+				port = sip.DefaultPort(string(tc.Type))
+			}
+			candidates = append(candidates, contactCandidate{
+				path:   ContactPath{Address: net.JoinHostPort(ip.String(), strconv.Itoa(port)), Transport: tc.Type},
+				config: tc,
+			})
+		}
+	}
+	return candidates, nil
+}
+
+// lookupIP разрешает имя адресата; подменяется в тестах
+func (u *UACUAS) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if u.resolveHost != nil {
+		return u.resolveHost(ctx, host)
+	}
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// selectContactPath выбирает путь для первичного INVITE и задает его адрес
+// назначения, транспорт и локальный адрес. Без Config.ParallelContact и при
+// единственной попытке запрос не меняется.
+func (u *UACUAS) selectContactPath(ctx context.Context, req *sip.Request) {
+	cfg := u.config.ParallelContact
+	if cfg == nil {
+		return
+	}
+
+	key := req.Recipient.Host
+	if req.Recipient.Port > 0 {
+		key = net.JoinHostPort(req.Recipient.Host, strconv.Itoa(req.Recipient.Port))
+	}
+
+	candidates, err := u.contactCandidates(ctx, req.Recipient)
+	if err != nil {
+		slog.Warn("Не удалось разрешить адресат INVITE",
+			slog.String("target", key),
+			slog.String("error", err.Error()))
+		return
+	}
+	if len(candidates) < 2 {
+		return
+	}
+
+	if path, ok := u.contactPaths.get(key, time.Now()); ok {
+		for _, c := range candidates {
+			if c.path == path {
+				applyContactPath(req, c)
+				return
+			}
+		}
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultParallelContactTimeout
+	}
+	stagger := cfg.Stagger
+	if stagger <= 0 {
+		stagger = DefaultParallelContactStagger
+	}
+
+	winner, ok := u.raceContactCandidates(ctx, req.Recipient, candidates, stagger, timeout)
+	if !ok {
+		slog.Warn("Ни один путь к адресату INVITE не ответил",
+			slog.String("target", key),
+			slog.Int("attempts", len(candidates)))
+		return
+	}
+
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultParallelContactCacheTTL
+	}
+	u.contactPaths.set(key, winner.path, time.Now().Add(ttl))
+	applyContactPath(req, winner)
+
+	slog.Debug("Выбран путь к адресату INVITE",
+		slog.String("target", key),
+		slog.String("address", winner.path.Address),
+		slog.String("transport", string(winner.path.Transport)))
+}
+
+// raceContactCandidates запускает попытки со сдвигом stagger и возвращает
+// первую успешную
+func (u *UACUAS) raceContactCandidates(ctx context.Context, target sip.Uri, candidates []contactCandidate, stagger, timeout time.Duration) (contactCandidate, bool) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		candidate contactCandidate
+		ok        bool
+	}
+	results := make(chan result, len(candidates))
+	start := func(c contactCandidate) {
+		go func() {
+			results <- result{candidate: c, ok: u.probeContactPath(ctx, target, c) == nil}
+		}()
+	}
+
+	next, running := 0, 0
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if next < len(candidates) {
+				start(candidates[next])
+				next++
+				running++
+				timer.Reset(stagger)
+			}
+		case r := <-results:
+			running--
+			if r.ok {
+				return r.candidate, true
+			}
+			// Неудачная попытка сразу запускает следующую
+			if next < len(candidates) {
+				start(candidates[next])
+				next++
+				running++
+				timer.Reset(stagger)
+			} else if running == 0 {
+				return contactCandidate{}, false
+			}
+		case <-ctx.Done():
+			return contactCandidate{}, false
+		}
+	}
+}
+
+// probeContactPath отправляет OPTIONS по пути и ждет любого ответа
+func (u *UACUAS) probeContactPath(ctx context.Context, target sip.Uri, c contactCandidate) error {
+	probe := sip.NewRequest(sip.OPTIONS, *target.Clone())
+	applyContactPath(probe, c)
+
+	tx, err := u.uac.TransactionRequest(ctx, probe, sipgo.ClientRequestBuild)
+	if err != nil {
+		return err
+	}
+	defer tx.Terminate()
+
+	select {
+	case <-tx.Responses():
+		return nil
+	case <-tx.Done():
+		return tx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// applyContactPath направляет запрос по выбранному пути
+func applyContactPath(req *sip.Request, c contactCandidate) {
+	req.SetDestination(c.path.Address)
+	req.SetTransport(string(c.path.Transport))
+	req.Laddr = sip.Addr{
+		IP:       net.ParseIP(c.config.Host),
+		Hostname: c.config.Host,
+		Port:     c.config.Port,
+	}
+}
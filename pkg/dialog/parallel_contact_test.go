@@ -0,0 +1,108 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterleaveFamilies(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::3"),
+		net.ParseIP("192.0.2.1"),
+	}
+	var got []string
+	for _, ip := range interleaveFamilies(ips) {
+		got = append(got, ip.String())
+	}
+	assert.Equal(t, []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "2001:db8::3"}, got)
+}
+
+// TestParallelContact проверяет, что INVITE уходит по первому ответившему
+// адресу, а повторный вызов использует запомненный путь без проб
+func TestParallelContact(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	port := peer.LocalAddr().(*net.UDPAddr).Port
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15102}},
+		ParallelContact:  &ParallelContactConfig{Stagger: 50 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+	// Первый адрес не отвечает
+	u.resolveHost = func(ctx context.Context, host string) ([]net.IP, error) {
+		return []net.IP{net.IPv4(127, 0, 0, 2), net.IPv4(127, 0, 0, 1)}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	// Удаленная сторона отвечает на OPTIONS и сообщает методы полученных запросов
+	methods := make(chan sip.RequestMethod, 16)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, from, err := peer.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			msg, err := sip.ParseMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			req, ok := msg.(*sip.Request)
+			if !ok {
+				continue
+			}
+			if req.Method == sip.OPTIONS {
+				resp := sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil)
+				_, _ = peer.WriteToUDP([]byte(resp.String()), from)
+			}
+			methods <- req.Method
+		}
+	}()
+	read := func() sip.RequestMethod {
+		select {
+		case method := <-methods:
+			return method
+		case <-time.After(3 * time.Second):
+			t.Fatal("Запрос не получен")
+			return ""
+		}
+	}
+	target := fmt.Sprintf("sip:bob@pbx.test:%d", port)
+	key := fmt.Sprintf("pbx.test:%d", port)
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	started := time.Now()
+	_, err = d.Start(ctx, target)
+	require.NoError(t, err)
+
+	assert.Less(t, time.Since(started), DefaultParallelContactTimeout)
+	assert.Equal(t, sip.OPTIONS, read(), "Пути проверяются OPTIONS")
+	assert.Equal(t, sip.INVITE, read())
+
+	path, ok := u.ContactPath(key)
+	require.True(t, ok)
+	assert.Equal(t, ContactPath{Address: fmt.Sprintf("127.0.0.1:%d", port), Transport: TransportUDP}, path)
+
+	// Повторный вызов идет по запомненному пути сразу
+	d2, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	_, err = d2.Start(ctx, target)
+	require.NoError(t, err)
+	assert.Equal(t, sip.INVITE, read())
+}
@@ -0,0 +1,409 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/store"
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrParkLotFull ошибка при отсутствии свободных слотов парковки
+	ErrParkLotFull = errors.New("no free park slots")
+	// ErrParkSlotBusy ошибка при парковке в занятый слот
+	ErrParkSlotBusy = errors.New("park slot is busy")
+	// ErrParkSlotUnknown ошибка при обращении к несуществующему слоту
+	ErrParkSlotUnknown = errors.New("unknown park slot")
+	// ErrParkSlotEmpty ошибка при снятии с парковки пустого слота
+	ErrParkSlotEmpty = errors.New("park slot is empty")
+)
+
+// ParkEventType - тип события парковки
+type ParkEventType int
+
+const (
+	// ParkEventParked - вызов поставлен на удержание в слоте парковки.
+	// По этому событию приложение начинает воспроизводить музыку на удержании.
+	ParkEventParked ParkEventType = iota
+	// ParkEventUnparked - вызов снят с парковки локально (Unpark)
+	ParkEventUnparked
+	// ParkEventReleased - припаркованный диалог завершен: вызов подхвачен
+	// другим UA через INVITE с Replaces или абонент положил трубку
+	ParkEventReleased
+)
+
+// String возвращает название типа события
+func (t ParkEventType) String() string {
+	switch t {
+	case ParkEventParked:
+		return "parked"
+	case ParkEventUnparked:
+		return "unparked"
+	case ParkEventReleased:
+		return "released"
+	default:
+		return "unknown"
+	}
+}
+
+// ParkedCall описывает вызов, припаркованный в слоте
+type ParkedCall struct {
+	Slot      string
+	DialogID  string
+	CallID    string
+	LocalTag  string
+	RemoteTag string
+
+	LocalURI     sip.Uri // Локальная сторона припаркованного диалога
+	RemoteURI    sip.Uri // Припаркованный абонент
+	RemoteTarget sip.Uri // Contact припаркованного абонента, адрес для подхвата
+
+	Initiator bool // Вызов был исходящим для парковавшей стороны
+	ParkedAt  time.Time
+}
+
+// Replaces возвращает значение заголовка Replaces для подхвата вызова:
+// INVITE с ним отправляется на RemoteTarget припаркованного абонента
+func (c ParkedCall) Replaces() ReplacesInfo {
+	return ReplacesInfo{
+		CallID:  c.CallID,
+		ToTag:   c.RemoteTag,
+		FromTag: c.LocalTag,
+	}
+}
+
+// ParkEvent - событие изменения состояния слота парковки
+type ParkEvent struct {
+	Type  ParkEventType
+	Call  ParkedCall
+	Cause *ReleaseCause // Причина завершения для ParkEventReleased
+}
+
+// ParkLot - набор слотов парковки вызовов.
+//
+// Парковка ставит вызов на удержание re-INVITE (SDP с a=sendonly и потоком
+// музыки на удержании передается опциями) и закрепляет его за слотом.
+// Состояние слота публикуется документом dialog-info (RFC 4235), по которому
+// другой UA подхватывает вызов: отправляет припаркованному абоненту INVITE
+// с Replaces, тот заменяет диалог с парковкой новым и завершает старый BYE.
+//
+//	lot := dialog.NewParkLot("701", "702")
+//	lot.OnEvent(func(event dialog.ParkEvent) { /* запуск/остановка MOH */ })
+//	call, err := lot.Park(ctx, d, "", dialog.WithSDP(holdSDP))
+//
+//	// На подхватывающем UA
+//	d, _ := ua.NewDialog(ctx)
+//	d.Start(ctx, call.RemoteTarget.String(), dialog.WithReplaces(call.Replaces()), dialog.WithSDP(sdp))
+type ParkLot struct {
+	mu      sync.Mutex
+	slots   []string
+	parked  map[string]*parkedDialog
+	version uint32 // Версия документов dialog-info
+	handler func(ParkEvent)
+
+	store    store.Store // Общее хранилище слотов, см. SetStore
+	instance string
+}
+
+// parkedDialog - занятый слот парковки
+type parkedDialog struct {
+	dialog *Dialog
+	call   ParkedCall
+	ready  bool // re-INVITE удержания отправлен
+}
+
+// NewParkLot создает парковку с указанными слотами
+func NewParkLot(slots ...string) *ParkLot {
+	return &ParkLot{
+		slots:  append([]string(nil), slots...),
+		parked: make(map[string]*parkedDialog),
+	}
+}
+
+// OnEvent устанавливает обработчик событий парковки.
+// Метод потокобезопасен.
+func (p *ParkLot) OnEvent(handler func(event ParkEvent)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handler = handler
+}
+
+// Park ставит вызов на удержание и закрепляет его за слотом. Пустой slot
+// выбирает первый свободный слот. opts применяются к re-INVITE удержания
+// (например, dialog.WithSDP с a=sendonly).
+func (p *ParkLot) Park(ctx context.Context, d *Dialog, slot string, opts ...RequestOpt) (ParkedCall, error) {
+	if d.State() != InCall {
+		return ParkedCall{}, fmt.Errorf("dialog not in call state, current state: %s", d.State())
+	}
+
+	d.parkMu.Lock()
+	if d.parkLot != nil {
+		d.parkMu.Unlock()
+		return ParkedCall{}, fmt.Errorf("dialog already parked in slot %s", d.parkSlot)
+	}
+
+	p.mu.Lock()
+	slot, err := p.reserve(slot)
+	if err != nil {
+		p.mu.Unlock()
+		d.parkMu.Unlock()
+		return ParkedCall{}, err
+	}
+	entry := &parkedDialog{dialog: d, call: newParkedCall(slot, d)}
+	p.parked[slot] = entry
+	p.mu.Unlock()
+
+	d.parkLot = p
+	d.parkSlot = slot
+	d.parkMu.Unlock()
+
+	if _, err := d.ReInvite(ctx, opts...); err != nil {
+		p.remove(slot, d)
+		return ParkedCall{}, errors.Wrap(err, "failed to put call on hold")
+	}
+
+	p.mu.Lock()
+	// Диалог мог завершиться, пока отправлялся re-INVITE
+	if p.parked[slot] != entry {
+		p.mu.Unlock()
+		return ParkedCall{}, fmt.Errorf("dialog %s ended while parking", d.ID())
+	}
+	entry.ready = true
+	call := entry.call
+	p.mu.Unlock()
+
+	p.saveRecord(call)
+
+	slog.Info("Вызов припаркован",
+		slog.String("dialogID", d.ID()),
+		slog.String("slot", slot))
+
+	p.notify(ParkEvent{Type: ParkEventParked, Call: call})
+	return call, nil
+}
+
+// Unpark снимает вызов с парковки и возвращает его диалог. opts применяются
+// к re-INVITE, возобновляющему разговор (например, SDP с a=sendrecv).
+func (p *ParkLot) Unpark(ctx context.Context, slot string, opts ...RequestOpt) (*Dialog, error) {
+	p.mu.Lock()
+	entry, ok := p.parked[slot]
+	if !ok || !entry.ready {
+		p.mu.Unlock()
+		if !p.hasSlot(slot) {
+			return nil, ErrParkSlotUnknown
+		}
+		return nil, ErrParkSlotEmpty
+	}
+	p.mu.Unlock()
+
+	d := entry.dialog
+	if !p.remove(slot, d) {
+		return nil, ErrParkSlotEmpty
+	}
+
+	if _, err := d.ReInvite(ctx, opts...); err != nil {
+		return d, errors.Wrap(err, "failed to resume parked call")
+	}
+
+	p.notify(ParkEvent{Type: ParkEventUnparked, Call: entry.call})
+	return d, nil
+}
+
+// Get возвращает вызов, припаркованный в слоте. При подключенном
+// хранилище (SetStore) возвращаются и вызовы, припаркованные другими
+// экземплярами.
+func (p *ParkLot) Get(slot string) (ParkedCall, bool) {
+	p.mu.Lock()
+	entry, ok := p.parked[slot]
+	p.mu.Unlock()
+	if ok {
+		if !entry.ready {
+			return ParkedCall{}, false
+		}
+		return entry.call, true
+	}
+	return p.loadRecord(slot)
+}
+
+// Parked возвращает припаркованные вызовы в порядке слотов
+func (p *ParkLot) Parked() []ParkedCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calls := make([]ParkedCall, 0, len(p.parked))
+	for _, slot := range p.slots {
+		if entry, ok := p.parked[slot]; ok && entry.ready {
+			calls = append(calls, entry.call)
+		}
+	}
+	return calls
+}
+
+// Slots возвращает список слотов парковки
+func (p *ParkLot) Slots() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.slots...)
+}
+
+// DialogInfo формирует документ dialog-info (RFC 4235) о состоянии слота
+// для NOTIFY подписчикам. entity - URI слота (например, sip:701@pbx.example.com).
+// Пустой слот публикуется документом без диалогов.
+func (p *ParkLot) DialogInfo(slot string, entity sip.Uri) ([]byte, error) {
+	if !p.hasSlot(slot) {
+		return nil, ErrParkSlotUnknown
+	}
+
+	p.mu.Lock()
+	p.version++
+	info := DialogInfo{
+		Version: p.version,
+		State:   "full",
+		Entity:  entity.String(),
+	}
+	if entry, ok := p.parked[slot]; ok && entry.ready {
+		info.Dialogs = append(info.Dialogs, entry.call.dialogInfo())
+	}
+	p.mu.Unlock()
+
+	return marshalDialogInfo(info)
+}
+
+// reserve выбирает слот для парковки. Вызывается под mu.
+func (p *ParkLot) reserve(slot string) (string, error) {
+	if slot != "" {
+		if !p.containsSlot(slot) {
+			return "", ErrParkSlotUnknown
+		}
+		if p.busyLocked(slot) {
+			return "", ErrParkSlotBusy
+		}
+		return slot, nil
+	}
+	for _, s := range p.slots {
+		if !p.busyLocked(s) {
+			return s, nil
+		}
+	}
+	return "", ErrParkLotFull
+}
+
+func (p *ParkLot) hasSlot(slot string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.containsSlot(slot)
+}
+
+// containsSlot проверяет наличие слота. Вызывается под mu.
+func (p *ParkLot) containsSlot(slot string) bool {
+	for _, s := range p.slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// remove освобождает слот, если в нем припаркован диалог d
+func (p *ParkLot) remove(slot string, d *Dialog) bool {
+	d.parkMu.Lock()
+	defer d.parkMu.Unlock()
+	if d.parkLot != p || d.parkSlot != slot {
+		return false
+	}
+	d.parkLot = nil
+	d.parkSlot = ""
+
+	p.mu.Lock()
+	delete(p.parked, slot)
+	p.mu.Unlock()
+	p.deleteRecord(slot)
+	return true
+}
+
+// release освобождает слот завершенного припаркованного диалога
+func (p *ParkLot) release(slot string, d *Dialog) {
+	p.mu.Lock()
+	entry, ok := p.parked[slot]
+	ready := ok && entry.ready
+	p.mu.Unlock()
+
+	if !ok || !p.remove(slot, d) || !ready {
+		return
+	}
+
+	slog.Info("Припаркованный вызов завершен",
+		slog.String("dialogID", d.ID()),
+		slog.String("slot", slot))
+
+	p.notify(ParkEvent{Type: ParkEventReleased, Call: entry.call, Cause: d.ReleaseCause()})
+}
+
+func (p *ParkLot) notify(event ParkEvent) {
+	p.mu.Lock()
+	handler := p.handler
+	p.mu.Unlock()
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// ParkSlot возвращает слот парковки диалога или пустую строку
+func (s *Dialog) ParkSlot() string {
+	s.parkMu.Lock()
+	defer s.parkMu.Unlock()
+	return s.parkSlot
+}
+
+// releasePark освобождает слот парковки при завершении диалога
+func (s *Dialog) releasePark() {
+	s.parkMu.Lock()
+	lot, slot := s.parkLot, s.parkSlot
+	s.parkMu.Unlock()
+	if lot != nil {
+		lot.release(slot, s)
+	}
+}
+
+func newParkedCall(slot string, d *Dialog) ParkedCall {
+	return ParkedCall{
+		Slot:         slot,
+		DialogID:     d.ID(),
+		CallID:       string(d.CallID()),
+		LocalTag:     d.LocalTag(),
+		RemoteTag:    d.RemoteTag(),
+		LocalURI:     d.LocalURI(),
+		RemoteURI:    d.RemoteURI(),
+		RemoteTarget: d.RemoteTarget(),
+		Initiator:    d.uaType == UAC,
+		ParkedAt:     time.Now(),
+	}
+}
+
+func (c ParkedCall) dialogInfo() DialogInfoDialog {
+	direction := "recipient"
+	if c.Initiator {
+		direction = "initiator"
+	}
+	return DialogInfoDialog{
+		ID:        c.DialogID,
+		CallID:    c.CallID,
+		LocalTag:  c.LocalTag,
+		RemoteTag: c.RemoteTag,
+		Direction: direction,
+		State:     DialogInfoConfirmed,
+		Duration:  strconv.Itoa(int(time.Since(c.ParkedAt).Seconds())),
+		Local: &DialogInfoParticipant{
+			Identity: c.LocalURI.String(),
+		},
+		Remote: &DialogInfoParticipant{
+			Identity: c.RemoteURI.String(),
+			Target:   &DialogInfoTarget{URI: c.RemoteTarget.String()},
+		},
+	}
+}
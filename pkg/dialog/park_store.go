@@ -0,0 +1,189 @@
+package dialog
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"log/slog"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/store"
+	"github.com/emiago/sipgo/sip"
+)
+
+// ParkStorePrefix - префикс ключей слотов парковки в хранилище
+const ParkStorePrefix = "park/"
+
+// parkRecord - запись о припаркованном вызове в хранилище
+type parkRecord struct {
+	Instance     string    `json:"instance"`
+	Slot         string    `json:"slot"`
+	DialogID     string    `json:"dialog_id"`
+	CallID       string    `json:"call_id"`
+	LocalTag     string    `json:"local_tag"`
+	RemoteTag    string    `json:"remote_tag"`
+	LocalURI     string    `json:"local_uri"`
+	RemoteURI    string    `json:"remote_uri"`
+	RemoteTarget string    `json:"remote_target"`
+	Initiator    bool      `json:"initiator"`
+	ParkedAt     time.Time `json:"parked_at"`
+}
+
+// SetStore подключает хранилище, в котором публикуются занятые слоты
+// парковки (ключи ParkStorePrefix + слот). Экземпляры с общим хранилищем
+// не паркуют вызовы в слоты, занятые друг другом, а Get возвращает вызов,
+// припаркованный другим экземпляром, для подхвата через INVITE с Replaces.
+//
+// instance отличает экземпляры, использующие хранилище. Диалоги не
+// переживают перезапуск процесса, поэтому записи этого экземпляра,
+// оставшиеся в персистентном хранилище, при подключении удаляются.
+// Хранилище не обеспечивает атомарного захвата слота: одновременная парковка
+// в один слот разными экземплярами не исключается.
+func (p *ParkLot) SetStore(st store.Store, instance string) error {
+	p.mu.Lock()
+	p.store = st
+	p.instance = instance
+	p.mu.Unlock()
+
+	if st == nil {
+		return nil
+	}
+
+	ctx := context.Background()
+	entries, err := st.List(ctx, ParkStorePrefix)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		var record parkRecord
+		if json.Unmarshal(entry.Value, &record) != nil || record.Instance != instance {
+			continue
+		}
+		if err := st.Delete(ctx, entry.Key); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		slog.Info("Удалена устаревшая запись парковки",
+			slog.String("slot", record.Slot),
+			slog.String("callID", record.CallID))
+	}
+	return nil
+}
+
+// busyLocked проверяет, занят ли слот этим или другим экземпляром.
+// Вызывается под mu.
+func (p *ParkLot) busyLocked(slot string) bool {
+	if _, busy := p.parked[slot]; busy {
+		return true
+	}
+	if p.store == nil {
+		return false
+	}
+	_, err := p.store.Get(context.Background(), ParkStorePrefix+slot)
+	return !errors.Is(err, store.ErrNotFound)
+}
+
+// saveRecord публикует припаркованный вызов в хранилище
+func (p *ParkLot) saveRecord(call ParkedCall) {
+	p.mu.Lock()
+	st, instance := p.store, p.instance
+	p.mu.Unlock()
+	if st == nil {
+		return
+	}
+
+	value, err := json.Marshal(parkRecord{
+		Instance:     instance,
+		Slot:         call.Slot,
+		DialogID:     call.DialogID,
+		CallID:       call.CallID,
+		LocalTag:     call.LocalTag,
+		RemoteTag:    call.RemoteTag,
+		LocalURI:     call.LocalURI.String(),
+		RemoteURI:    call.RemoteURI.String(),
+		RemoteTarget: call.RemoteTarget.String(),
+		Initiator:    call.Initiator,
+		ParkedAt:     call.ParkedAt,
+	})
+	if err == nil {
+		err = st.Put(context.Background(), ParkStorePrefix+call.Slot, value, 0)
+	}
+	if err != nil {
+		slog.Error("Ошибка сохранения слота парковки",
+			slog.String("slot", call.Slot),
+			slog.String("error", err.Error()))
+	}
+}
+
+// deleteRecord удаляет запись слота, если ее создал этот экземпляр
+func (p *ParkLot) deleteRecord(slot string) {
+	p.mu.Lock()
+	st, instance := p.store, p.instance
+	p.mu.Unlock()
+	if st == nil {
+		return
+	}
+
+	ctx := context.Background()
+	record, ok := readParkRecord(st, slot)
+	if !ok || record.Instance != instance {
+		return
+	}
+	if err := st.Delete(ctx, ParkStorePrefix+slot); err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.Error("Ошибка удаления слота парковки",
+			slog.String("slot", slot),
+			slog.String("error", err.Error()))
+	}
+}
+
+// loadRecord возвращает вызов, опубликованный в хранилище другим экземпляром
+func (p *ParkLot) loadRecord(slot string) (ParkedCall, bool) {
+	p.mu.Lock()
+	st := p.store
+	p.mu.Unlock()
+	if st == nil {
+		return ParkedCall{}, false
+	}
+
+	record, ok := readParkRecord(st, slot)
+	if !ok {
+		return ParkedCall{}, false
+	}
+	call := ParkedCall{
+		Slot:      record.Slot,
+		DialogID:  record.DialogID,
+		CallID:    record.CallID,
+		LocalTag:  record.LocalTag,
+		RemoteTag: record.RemoteTag,
+		Initiator: record.Initiator,
+		ParkedAt:  record.ParkedAt,
+	}
+	for _, uri := range []struct {
+		value string
+		dst   *sip.Uri
+	}{
+		{record.LocalURI, &call.LocalURI},
+		{record.RemoteURI, &call.RemoteURI},
+		{record.RemoteTarget, &call.RemoteTarget},
+	} {
+		if err := sip.ParseUri(uri.value, uri.dst); err != nil {
+			slog.Warn("Неверный URI в записи парковки",
+				slog.String("slot", slot),
+				slog.String("uri", uri.value))
+			return ParkedCall{}, false
+		}
+	}
+	return call, true
+}
+
+// readParkRecord читает запись слота из хранилища
+func readParkRecord(st store.Store, slot string) (parkRecord, bool) {
+	entry, err := st.Get(context.Background(), ParkStorePrefix+slot)
+	if err != nil {
+		return parkRecord{}, false
+	}
+	var record parkRecord
+	if err := json.Unmarshal(entry.Value, &record); err != nil {
+		return parkRecord{}, false
+	}
+	return record, true
+}
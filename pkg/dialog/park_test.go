@@ -0,0 +1,161 @@
+package dialog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/store"
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReplaces(t *testing.T) {
+	info, err := ParseReplaces("abc@10.0.0.1; to-tag=111;from-tag=222;early-only")
+	require.NoError(t, err)
+	assert.Equal(t, ReplacesInfo{CallID: "abc@10.0.0.1", ToTag: "111", FromTag: "222", EarlyOnly: true}, info)
+	assert.Equal(t, "abc@10.0.0.1;to-tag=111;from-tag=222;early-only", info.String())
+
+	_, err = ParseReplaces("abc@10.0.0.1;to-tag=111")
+	assert.Error(t, err, "Нет from-tag")
+	_, err = ParseReplaces(";to-tag=1;from-tag=2")
+	assert.Error(t, err, "Нет Call-ID")
+
+	// Теги меняются местами: получатель INVITE - удаленная сторона диалога
+	d := &Dialog{callID: "abc", localTag: "local", remoteTag: "remote"}
+	assert.Equal(t, ReplacesInfo{CallID: "abc", ToTag: "remote", FromTag: "local"}, ReplacesFor(d))
+}
+
+func TestParkLotSlots(t *testing.T) {
+	lot := NewParkLot("701", "702")
+
+	lot.mu.Lock()
+	slot, err := lot.reserve("")
+	require.NoError(t, err)
+	assert.Equal(t, "701", slot)
+	lot.parked["701"] = &parkedDialog{ready: true}
+
+	_, err = lot.reserve("701")
+	assert.ErrorIs(t, err, ErrParkSlotBusy)
+	_, err = lot.reserve("799")
+	assert.ErrorIs(t, err, ErrParkSlotUnknown)
+
+	slot, err = lot.reserve("")
+	require.NoError(t, err)
+	assert.Equal(t, "702", slot)
+	lot.parked["702"] = &parkedDialog{}
+
+	_, err = lot.reserve("")
+	assert.ErrorIs(t, err, ErrParkLotFull)
+	lot.mu.Unlock()
+
+	// Слот в процессе парковки не публикуется
+	assert.Len(t, lot.Parked(), 1)
+	_, ok := lot.Get("702")
+	assert.False(t, ok)
+
+	_, err = lot.Unpark(context.Background(), "799")
+	assert.ErrorIs(t, err, ErrParkSlotUnknown)
+	_, err = lot.Unpark(context.Background(), "702")
+	assert.ErrorIs(t, err, ErrParkSlotEmpty)
+}
+
+func TestParkDialogInfo(t *testing.T) {
+	lot := NewParkLot("701", "702")
+	call := ParkedCall{
+		Slot:         "701",
+		DialogID:     "abc:local:remote",
+		CallID:       "abc",
+		LocalTag:     "local",
+		RemoteTag:    "remote",
+		LocalURI:     sip.Uri{Scheme: "sip", User: "park", Host: "10.0.0.1"},
+		RemoteURI:    sip.Uri{Scheme: "sip", User: "alice", Host: "10.0.0.2"},
+		RemoteTarget: sip.Uri{Scheme: "sip", User: "alice", Host: "10.0.0.2", Port: 5070},
+		ParkedAt:     time.Now(),
+	}
+	lot.parked["701"] = &parkedDialog{call: call, ready: true}
+
+	entity := sip.Uri{Scheme: "sip", User: "701", Host: "pbx.example.com"}
+	data, err := lot.DialogInfo("701", entity)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `xmlns="urn:ietf:params:xml:ns:dialog-info"`)
+
+	info, err := ParseDialogInfo(data)
+	require.NoError(t, err)
+	assert.Equal(t, "full", info.State)
+	assert.Equal(t, "sip:701@pbx.example.com", info.Entity)
+	require.Len(t, info.Dialogs, 1)
+
+	parked := info.Dialogs[0]
+	assert.Equal(t, "confirmed", parked.State)
+	assert.Equal(t, "recipient", parked.Direction)
+	assert.Equal(t, call.Replaces(), parked.Replaces())
+	target, err := parked.RemoteTarget()
+	require.NoError(t, err)
+	assert.Equal(t, 5070, target.Port)
+
+	// Пустой слот - документ без диалогов с увеличенной версией
+	data, err = lot.DialogInfo("702", entity)
+	require.NoError(t, err)
+	empty, err := ParseDialogInfo(data)
+	require.NoError(t, err)
+	assert.Empty(t, empty.Dialogs)
+	assert.Greater(t, empty.Version, info.Version)
+
+	_, err = lot.DialogInfo("799", entity)
+	assert.ErrorIs(t, err, ErrParkSlotUnknown)
+}
+
+func TestParkLotStore(t *testing.T) {
+	st := store.NewMemoryStore()
+	defer st.Close()
+
+	a := NewParkLot("701", "702")
+	b := NewParkLot("701", "702")
+	require.NoError(t, a.SetStore(st, "a"))
+	require.NoError(t, b.SetStore(st, "b"))
+
+	call := ParkedCall{
+		Slot:         "701",
+		DialogID:     "abc:local:remote",
+		CallID:       "abc",
+		LocalTag:     "local",
+		RemoteTag:    "remote",
+		LocalURI:     sip.Uri{Scheme: "sip", User: "park", Host: "10.0.0.1"},
+		RemoteURI:    sip.Uri{Scheme: "sip", User: "alice", Host: "10.0.0.2"},
+		RemoteTarget: sip.Uri{Scheme: "sip", User: "alice", Host: "10.0.0.2", Port: 5070},
+		ParkedAt:     time.Now().Round(time.Second),
+	}
+	a.mu.Lock()
+	a.parked["701"] = &parkedDialog{call: call, ready: true}
+	a.mu.Unlock()
+	a.saveRecord(call)
+
+	// Слот, занятый другим экземпляром, не выдается
+	b.mu.Lock()
+	slot, err := b.reserve("")
+	require.NoError(t, err)
+	assert.Equal(t, "702", slot)
+	_, err = b.reserve("701")
+	assert.ErrorIs(t, err, ErrParkSlotBusy)
+	b.mu.Unlock()
+
+	// Вызов другого экземпляра доступен для подхвата
+	got, ok := b.Get("701")
+	require.True(t, ok)
+	assert.Equal(t, call.Replaces(), got.Replaces())
+	assert.Equal(t, call.RemoteTarget.String(), got.RemoteTarget.String())
+	assert.True(t, call.ParkedAt.Equal(got.ParkedAt))
+
+	// Экземпляр не удаляет чужие записи
+	b.deleteRecord("701")
+	_, ok = b.Get("701")
+	assert.True(t, ok)
+
+	// Записи прежнего экземпляра "a" удаляются при подключении после перезапуска
+	restarted := NewParkLot("701", "702")
+	require.NoError(t, restarted.SetStore(st, "a"))
+	_, ok = b.Get("701")
+	assert.False(t, ok)
+}
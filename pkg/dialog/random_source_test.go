@@ -0,0 +1,60 @@
+package dialog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomSourceReproducible(t *testing.T) {
+	newDialog := func() *Dialog {
+		u, err := NewUACUAS(Config{
+			RandomSource:     random.NewSeeded(2024),
+			TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15096}},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = u.Stop() })
+
+		d, err := u.NewDialog(context.Background())
+		require.NoError(t, err)
+		return d
+	}
+
+	first, second := newDialog(), newDialog()
+	assert.Equal(t, first.callID, second.callID, "Call-ID повторяется при одинаковом seed")
+	assert.Equal(t, first.localTag, second.localTag)
+	assert.Equal(t, first.localCSeq.Load(), second.localCSeq.Load())
+	assert.Len(t, first.callID.Value(), 32)
+}
+
+// TestRandomSourcePerUACUAS проверяет, что каждый UACUAS генерирует
+// идентификаторы из своего источника, а не из созданного последним
+func TestRandomSourcePerUACUAS(t *testing.T) {
+	newUA := func(seed uint64) *UACUAS {
+		u, err := NewUACUAS(Config{
+			RandomSource:     random.NewSeeded(seed),
+			TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15096}},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = u.Stop() })
+		return u
+	}
+	newDialog := func(u *UACUAS) *Dialog {
+		d, err := u.NewDialog(context.Background())
+		require.NoError(t, err)
+		return d
+	}
+
+	expected := newDialog(newUA(2024))
+	first := newUA(2024)
+	other := newDialog(newUA(7))
+	d := newDialog(first)
+
+	assert.Equal(t, expected.callID, d.callID, "UACUAS использует свой источник после создания другого")
+	assert.Equal(t, expected.localTag, d.localTag)
+	assert.Equal(t, expected.localCSeq.Load(), d.localCSeq.Load())
+	assert.NotEqual(t, expected.callID, other.callID)
+}
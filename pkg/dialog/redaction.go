@@ -0,0 +1,241 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// DefaultRedactionMask - замена скрываемых значений по умолчанию
+const DefaultRedactionMask = "***"
+
+// RedactionConfig задает, какие персональные данные скрываются в трассировке
+// SIP сообщений и логах пакета. Позволяет держать трассировку включенной в
+// production без записи номеров и имен пользователей (GDPR).
+type RedactionConfig struct {
+	// MaskUsers - скрывать user часть sip:/sips:/tel: URI и display name
+	MaskUsers bool
+	// MaskCredentials - скрывать username и response в заголовках
+	// Authorization/Proxy-Authorization
+	MaskCredentials bool
+	// Headers - заголовки, значение которых скрывается целиком
+	// (например, Subject, User-to-User). Регистр имени не важен
+	Headers []string
+	// Mask - замена скрытых значений, по умолчанию DefaultRedactionMask
+	Mask string
+}
+
+// DefaultRedactionConfig возвращает конфигурацию, скрывающую пользователей,
+// учетные данные и заголовки с произвольным содержимым
+func DefaultRedactionConfig() *RedactionConfig {
+	return &RedactionConfig{
+		MaskUsers:       true,
+		MaskCredentials: true,
+		Headers:         []string{"Subject", "User-to-User", "Call-Info", "Geolocation"},
+	}
+}
+
+var (
+	// redactURIUser - user часть SIP URI, включая пароль (sip:user:pass@host)
+	redactURIUser = regexp.MustCompile(`(?i)\b(sips?):[^@\s;>,"<]+@`)
+	// redactTelURI - номер в tel URI до параметров
+	redactTelURI = regexp.MustCompile(`(?i)\btel:[^\s;>,"<]+`)
+	// redactDisplayName - display name в кавычках перед <URI>
+	redactDisplayName = regexp.MustCompile(`"(?:[^"\\]|\\.)*"(\s*<)`)
+	// redactCredential - параметры учетных данных в заголовках авторизации
+	redactCredential = regexp.MustCompile(`(?i)\b(username|response)\s*=\s*("[^"]*"|[^,\s]+)`)
+)
+
+// redactor - скомпилированная RedactionConfig
+type redactor struct {
+	config  RedactionConfig
+	headers map[string]bool
+}
+
+// defaultRedactor - конфигурация процесса (SetRedaction), nil - редактирование
+// выключено. Действует для UACUAS без собственной Config.Redaction
+var defaultRedactor atomic.Pointer[redactor]
+
+// newRedactor компилирует RedactionConfig. nil - редактирование выключено
+func newRedactor(cfg *RedactionConfig) *redactor {
+	if cfg == nil {
+		return nil
+	}
+
+	r := &redactor{config: *cfg, headers: make(map[string]bool, len(cfg.Headers))}
+	if r.config.Mask == "" {
+		r.config.Mask = DefaultRedactionMask
+	}
+	for _, name := range cfg.Headers {
+		name = strings.ToLower(name)
+		r.headers[name] = true
+		if compact, ok := compactHeaderNames[name]; ok {
+			r.headers[compact] = true
+		}
+	}
+	return r
+}
+
+// SetRedaction включает, меняет или выключает (nil) скрытие персональных данных
+// в трассировке SIP и логах для всех UACUAS процесса, у которых не задана
+// собственная конфигурация (Config.Redaction, UACUAS.SetRedaction).
+// Безопасно вызывать во время работы: новые правила применяются к следующим
+// сообщениям. Сообщения пишет в slog.Debug SIP tracer пакета (как sipgo, но
+// после редактирования).
+func SetRedaction(cfg *RedactionConfig) {
+	defaultRedactor.Store(newRedactor(cfg))
+	if cfg != nil {
+		installSIPTracer()
+	}
+}
+
+// RedactionEnabled сообщает, включено ли скрытие персональных данных
+// конфигурацией процесса (SetRedaction)
+func RedactionEnabled() bool {
+	return defaultRedactor.Load() != nil
+}
+
+// Redact возвращает SIP сообщение или строку лога со скрытыми персональными
+// данными по конфигурации процесса (SetRedaction). Если редактирование
+// выключено, s возвращается без изменений.
+func Redact(s string) string {
+	return defaultRedactor.Load().redact(s)
+}
+
+// redactUser скрывает отдельное значение user части URI (номер или имя)
+func (r *redactor) redactUser(user string) string {
+	if r == nil || !r.config.MaskUsers || user == "" {
+		return user
+	}
+	return r.config.Mask
+}
+
+// redact скрывает персональные данные в s. nil redactor возвращает s без изменений
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+	if len(r.headers) > 0 || r.config.MaskCredentials {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			lines[i] = r.redactHeaderLine(line)
+		}
+		s = strings.Join(lines, "\n")
+	}
+
+	if r.config.MaskUsers {
+		mask := r.config.Mask
+		s = redactURIUser.ReplaceAllString(s, "${1}:"+mask+"@")
+		s = redactTelURI.ReplaceAllString(s, "tel:"+mask)
+		s = redactDisplayName.ReplaceAllString(s, `"`+mask+`"${1}`)
+	}
+	return s
+}
+
+// redactHeaderLine скрывает значение заголовка из RedactionConfig.Headers
+// или учетные данные в заголовках авторизации
+func (r *redactor) redactHeaderLine(line string) string {
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 {
+		return line
+	}
+	name := strings.ToLower(strings.TrimSpace(line[:colon]))
+	if strings.ContainsAny(name, " \t") {
+		return line
+	}
+
+	if r.headers[name] {
+		suffix := ""
+		if strings.HasSuffix(line, "\r") {
+			suffix = "\r"
+		}
+		return line[:colon+1] + " " + r.config.Mask + suffix
+	}
+
+	if r.config.MaskCredentials && (name == "authorization" || name == "proxy-authorization") {
+		return line[:colon+1] + redactCredential.ReplaceAllStringFunc(line[colon+1:], func(param string) string {
+			key := param[:strings.IndexByte(param, '=')]
+			return key + `="` + r.config.Mask + `"`
+		})
+	}
+	return line
+}
+
+// sipTracerOnce устанавливает SIP tracer один раз на процесс
+var sipTracerOnce sync.Once
+
+// installSIPTracer включает отладку sipgo и устанавливает SIP tracer пакета.
+// Оба параметра sipgo глобальны и читаются транспортами других UACUAS,
+// поэтому они задаются один раз на процесс.
+func installSIPTracer() {
+	sipTracerOnce.Do(func() {
+		sip.SIPDebug = true
+		sip.SIPDebugTracer(sipTracer{})
+	})
+}
+
+// tracedUAs - работающие UACUAS процесса. SIP tracer sipgo общий, поэтому
+// правила скрытия выбираются по локальному адресу сообщения
+var tracedUAs sync.Map // *UACUAS -> struct{}
+
+// sipTracer - SIP tracer sipgo: редактирует сообщения перед записью в лог
+// и собирает статистику транзакций (TransactionStats)
+type sipTracer struct{}
+
+// redactorFor возвращает правила скрытия UACUAS, слушающего порт laddr.
+// Сообщение, которое не удалось отнести к UACUAS (например, исходящее TCP
+// соединение с временного порта), редактируется конфигурацией процесса, а без
+// нее - правилами любого UACUAS со скрытием: трассировка не должна раскрывать
+// данные экземпляра, которому редактирование требуется
+func (sipTracer) redactorFor(laddr string) *redactor {
+	port := -1
+	if _, portStr, err := net.SplitHostPort(laddr); err == nil {
+		if p, err := strconv.Atoi(portStr); err == nil {
+			port = p
+		}
+	}
+
+	var matched, fallback *redactor
+	found := false
+	tracedUAs.Range(func(key, _ any) bool {
+		u := key.(*UACUAS)
+		if u.listensOn(port) {
+			matched, found = u.activeRedactor(), true
+			return false
+		}
+		if fallback == nil {
+			fallback = u.redaction.Load()
+		}
+		return true
+	})
+	if found {
+		return matched
+	}
+	if r := defaultRedactor.Load(); r != nil {
+		return r
+	}
+	return fallback
+}
+
+func (t sipTracer) SIPTraceRead(transport string, laddr string, raddr string, sipmsg []byte) {
+	txStats.observeRead(sipmsg, time.Now())
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		slog.Debug(fmt.Sprintf("%s read from %s <- %s:\n%s", transport, laddr, raddr, t.redactorFor(laddr).redact(string(sipmsg))))
+	}
+}
+
+func (t sipTracer) SIPTraceWrite(transport string, laddr string, raddr string, sipmsg []byte) {
+	txStats.observeWrite(raddr, sipmsg, time.Now())
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		slog.Debug(fmt.Sprintf("%s write to %s -> %s:\n%s", transport, laddr, raddr, t.redactorFor(laddr).redact(string(sipmsg))))
+	}
+}
@@ -0,0 +1,87 @@
+package dialog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedact(t *testing.T) {
+	t.Cleanup(func() { SetRedaction(nil) })
+
+	msg := strings.Join([]string{
+		"INVITE sip:+74951234567@carrier.example.com;user=phone SIP/2.0",
+		`From: "Ivan Petrov" <sip:ivan:secret@example.com>;tag=abc`,
+		"t: <tel:+74957654321;phone-context=example.com>",
+		`Authorization: Digest username="ivan", realm="example.com", response="6629fae49393a05397450978507c4ef1"`,
+		"Subject: Звонок по заказу 42",
+		"Call-ID: a84b4c76e66710",
+		"",
+		"v=0",
+	}, "\r\n")
+
+	SetRedaction(nil)
+	assert.False(t, RedactionEnabled())
+	assert.Equal(t, msg, Redact(msg), "Выключенное редактирование не меняет сообщение")
+
+	SetRedaction(DefaultRedactionConfig())
+	assert.True(t, RedactionEnabled())
+	redacted := Redact(msg)
+	for _, secret := range []string{"74951234567", "74957654321", "Ivan Petrov", "secret", `"ivan"`, "6629fae4", "заказу"} {
+		assert.NotContains(t, redacted, secret)
+	}
+	assert.Contains(t, redacted, "INVITE sip:***@carrier.example.com;user=phone SIP/2.0\r\n")
+	assert.Contains(t, redacted, `From: "***" <sip:***@example.com>;tag=abc`)
+	assert.Contains(t, redacted, "t: <tel:***;phone-context=example.com>")
+	assert.Contains(t, redacted, `realm="example.com"`)
+	assert.Contains(t, redacted, "Subject: ***\r\n")
+	assert.Contains(t, redacted, "Call-ID: a84b4c76e66710")
+	assert.Equal(t, redacted, Redact(redacted), "Повторное редактирование не меняет результат")
+
+	// Конфигурация меняется во время работы
+	SetRedaction(&RedactionConfig{Headers: []string{"call-id"}, Mask: "<hidden>"})
+	redacted = Redact(msg)
+	assert.Contains(t, redacted, "Call-ID: <hidden>")
+	assert.Contains(t, redacted, "sip:+74951234567@", "Пользователи не скрываются без MaskUsers")
+	assert.Equal(t, "ivan", defaultRedactor.Load().redactUser("ivan"))
+}
+
+// TestRedactionPerUACUAS проверяет, что Config.Redaction действует только на
+// свой UACUAS, а SIP tracer выбирает правила по локальному порту сообщения
+func TestRedactionPerUACUAS(t *testing.T) {
+	t.Cleanup(func() { SetRedaction(nil) })
+	SetRedaction(nil)
+
+	newUA := func(port int, redaction *RedactionConfig) *UACUAS {
+		u, err := NewUACUAS(Config{
+			TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: port}},
+			Redaction:        redaction,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = u.Stop() })
+		return u
+	}
+	masked := newUA(15114, DefaultRedactionConfig())
+	plain := newUA(15115, nil)
+
+	const line = "INVITE sip:+74951234567@carrier.example.com SIP/2.0"
+	assert.Equal(t, "INVITE sip:***@carrier.example.com SIP/2.0", masked.redact(line))
+	assert.Equal(t, line, plain.redact(line), "Конфигурация другого UACUAS не должна применяться")
+	assert.False(t, RedactionEnabled(), "Config.Redaction не меняет конфигурацию процесса")
+
+	var tracer sipTracer
+	assert.Equal(t, masked.redact(line), tracer.redactorFor("127.0.0.1:15114").redact(line))
+	assert.Equal(t, line, tracer.redactorFor("127.0.0.1:15115").redact(line))
+	assert.Equal(t, masked.redact(line), tracer.redactorFor("127.0.0.1:40000").redact(line),
+		"Сообщение неизвестного UACUAS редактируется, если редактирование кому-то требуется")
+
+	// Без собственной конфигурации действует конфигурация процесса
+	SetRedaction(&RedactionConfig{MaskUsers: true, Mask: "<hidden>"})
+	assert.Equal(t, "INVITE sip:<hidden>@carrier.example.com SIP/2.0", plain.redact(line))
+	assert.Equal(t, "INVITE sip:***@carrier.example.com SIP/2.0", masked.redact(line))
+
+	masked.SetRedaction(nil)
+	assert.Equal(t, plain.redact(line), masked.redact(line))
+}
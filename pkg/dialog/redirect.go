@@ -0,0 +1,284 @@
+package dialog
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+)
+
+// defaultMaxRedirects ограничивает количество автоматических повторов INVITE
+// по ответам 3xx, если Config.MaxRedirects не задан
+const defaultMaxRedirects = 5
+
+// statusAlternativeService - 380 Alternative Service (RFC 3261 Section 21.3.6)
+const statusAlternativeService = 380
+
+// ContentTypeIMS3GPP - тип тела с информацией об альтернативной услуге в
+// ответе 380 (3GPP TS 24.229 Section 7.6)
+const ContentTypeIMS3GPP = "application/3gpp-ims+xml"
+
+// AlternativeServiceEmergency - тип альтернативной услуги для экстренного
+// вызова: сеть требует повторить вызов как экстренный
+const AlternativeServiceEmergency = "emergency"
+
+// Redirect описывает ответ 3xx на исходящий INVITE (RFC 3261 Section 21.3).
+type Redirect struct {
+	StatusCode int
+	Reason     string
+
+	// Contacts - альтернативные адреса из заголовков Contact, упорядоченные
+	// по убыванию q (при равном q - в порядке следования в ответе)
+	Contacts []RedirectContact
+
+	// AlternativeService - информация об альтернативной услуге из тела
+	// ответа 380 Alternative Service (nil, если тела нет)
+	AlternativeService *AlternativeService
+}
+
+// RedirectContact - альтернативный адрес вызова из заголовка Contact ответа 3xx
+type RedirectContact struct {
+	URI         sip.Uri
+	DisplayName string
+	Q           float64 // Приоритет 0.0-1.0, по умолчанию 1.0
+	Expires     int     // Параметр expires в секундах, -1 если не указан
+}
+
+// AlternativeService - элемент alternative-service тела application/3gpp-ims+xml
+type AlternativeService struct {
+	Type   string `xml:"type"`   // Тип услуги, например "emergency"
+	Reason string `xml:"reason"` // Текстовое пояснение
+	Action string `xml:"action"` // Действие, например "emergency-registration"
+}
+
+// IsEmergency проверяет, требует ли сеть повторить вызов как экстренный
+func (a *AlternativeService) IsEmergency() bool {
+	return a != nil && strings.EqualFold(strings.TrimSpace(a.Type), AlternativeServiceEmergency)
+}
+
+// ims3GPP - корневой элемент тела application/3gpp-ims+xml
+type ims3GPP struct {
+	XMLName            xml.Name            `xml:"ims-3gpp"`
+	AlternativeService *AlternativeService `xml:"alternative-service"`
+}
+
+// ParseRedirect разбирает ответ 3xx: адреса Contact с приоритетами и
+// информацию об альтернативной услуге ответа 380.
+func ParseRedirect(resp *sip.Response) (*Redirect, error) {
+	if resp == nil || resp.StatusCode < 300 || resp.StatusCode > 399 {
+		return nil, fmt.Errorf("response is not a redirect")
+	}
+
+	redirect := &Redirect{
+		StatusCode: resp.StatusCode,
+		Reason:     resp.Reason,
+	}
+
+	for _, h := range resp.GetHeaders("Contact") {
+		contact, ok := h.(*sip.ContactHeader)
+		if !ok {
+			continue
+		}
+		entry := RedirectContact{
+			URI:         *contact.Address.Clone(),
+			DisplayName: contact.DisplayName,
+			Q:           1.0,
+			Expires:     -1,
+		}
+		if contact.Params != nil {
+			if q, ok := contact.Params.Get("q"); ok {
+				value, err := strconv.ParseFloat(q, 64)
+				if err != nil || value < 0 || value > 1 {
+					return nil, fmt.Errorf("invalid q parameter %q in Contact %s", q, contact.Address.String())
+				}
+				entry.Q = value
+			}
+			if expires, ok := contact.Params.Get("expires"); ok {
+				if value, err := strconv.Atoi(expires); err == nil {
+					entry.Expires = value
+				}
+			}
+		}
+		redirect.Contacts = append(redirect.Contacts, entry)
+	}
+	sort.SliceStable(redirect.Contacts, func(i, j int) bool {
+		return redirect.Contacts[i].Q > redirect.Contacts[j].Q
+	})
+
+	if body := extractBody(resp); body != nil && strings.EqualFold(body.ContentType(), ContentTypeIMS3GPP) {
+		var doc ims3GPP
+		if err := xml.Unmarshal(body.Content(), &doc); err != nil {
+			return nil, errors.Wrap(err, "failed to parse alternative service body")
+		}
+		redirect.AlternativeService = doc.AlternativeService
+	}
+
+	return redirect, nil
+}
+
+// RedirectPolicy решает, повторять ли INVITE по ответу 3xx. Возвращает
+// адрес повторного вызова и true, либо false, чтобы завершить вызов.
+type RedirectPolicy func(redirect *Redirect) (target sip.Uri, retry bool)
+
+// FollowContacts возвращает политику, повторяющую вызов на Contact с
+// наибольшим приоритетом (кроме 380, где повтор требует смены услуги)
+func FollowContacts() RedirectPolicy {
+	return func(redirect *Redirect) (sip.Uri, bool) {
+		if redirect.StatusCode == statusAlternativeService {
+			return sip.Uri{}, false
+		}
+		for _, contact := range redirect.Contacts {
+			scheme := strings.ToLower(contact.URI.Scheme)
+			if scheme == "sip" || scheme == "sips" {
+				return contact.URI, true
+			}
+		}
+		return sip.Uri{}, false
+	}
+}
+
+// EmergencyRedirect возвращает политику для ответа 380 с альтернативной
+// услугой emergency: вызов повторяется на адрес экстренной службы target.
+// Остальные ответы 3xx обрабатываются политикой next (может быть nil).
+func EmergencyRedirect(target sip.Uri, next RedirectPolicy) RedirectPolicy {
+	return func(redirect *Redirect) (sip.Uri, bool) {
+		if redirect.StatusCode == statusAlternativeService && redirect.AlternativeService.IsEmergency() {
+			return target, true
+		}
+		if next != nil {
+			return next(redirect)
+		}
+		return sip.Uri{}, false
+	}
+}
+
+// LastRedirect возвращает последний ответ 3xx, полученный на INVITE диалога
+func (s *Dialog) LastRedirect() *Redirect {
+	s.redirectMu.Lock()
+	defer s.redirectMu.Unlock()
+	if len(s.redirects) == 0 {
+		return nil
+	}
+	return s.redirects[len(s.redirects)-1]
+}
+
+// OnRedirect устанавливает обработчик ответов 3xx на исходящий INVITE.
+// Обработчик вызывается до применения Config.RedirectPolicy.
+// Метод потокобезопасен.
+func (s *Dialog) OnRedirect(handler func(redirect *Redirect)) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.redirectHandler = handler
+}
+
+// processRedirect обрабатывает ответ 3xx на первичный INVITE. Возвращает
+// true, если INVITE повторен на альтернативный адрес и диалог продолжается.
+func (s *Dialog) processRedirect(invite *sip.Request, resp *sip.Response) bool {
+	redirect, err := ParseRedirect(resp)
+	if err != nil {
+		slog.Warn("Некорректный ответ 3xx на INVITE",
+			slog.String("dialogID", s.ID()),
+			slog.Int("status", resp.StatusCode),
+			slog.String("error", err.Error()))
+		return false
+	}
+
+	s.redirectMu.Lock()
+	s.redirects = append(s.redirects, redirect)
+	hops := len(s.redirects)
+	s.redirectMu.Unlock()
+
+	s.handlersMu.Lock()
+	handler := s.redirectHandler
+	s.handlersMu.Unlock()
+	if handler != nil {
+		handler(redirect)
+	}
+
+	if s.uu == nil || s.uu.config.RedirectPolicy == nil {
+		return false
+	}
+	maxRedirects := s.uu.config.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+	if hops > maxRedirects {
+		slog.Warn("Превышено количество перенаправлений INVITE",
+			slog.String("dialogID", s.ID()),
+			slog.Int("maxRedirects", maxRedirects))
+		return false
+	}
+
+	target, retry := s.uu.config.RedirectPolicy(redirect)
+	if !retry {
+		return false
+	}
+	if invite.Recipient.String() == target.String() {
+		slog.Warn("Перенаправление INVITE на тот же адрес",
+			slog.String("dialogID", s.ID()),
+			slog.String("target", s.uu.redact(target.String())))
+		return false
+	}
+
+	if err := s.retryInvite(invite, target); err != nil {
+		slog.Error("Не удалось повторить INVITE по перенаправлению",
+			slog.String("dialogID", s.ID()),
+			slog.String("target", s.uu.redact(target.String())),
+			slog.String("error", err.Error()))
+		return false
+	}
+	return true
+}
+
+// retryInvite повторяет INVITE на новый Request-URI с тем же Call-ID,
+// From и телом и увеличенным CSeq (RFC 3261 Section 8.1.3.4)
+func (s *Dialog) retryInvite(invite *sip.Request, target sip.Uri) error {
+	// Новый CSeq назначается в sendReq
+	req := newRedirectedInvite(invite, target)
+
+	s.uriMu.Lock()
+	s.remoteTarget = target
+	s.uriMu.Unlock()
+
+	if err := s.resendInvite(req); err != nil {
+		return err
+	}
+
+	slog.Info("INVITE перенаправлен",
+		slog.String("dialogID", s.ID()),
+		slog.String("target", s.uu.redact(target.String())))
+	return nil
+}
+
+// resendInvite отправляет повторный первичный INVITE и делает его транзакцию
+// первой транзакцией диалога
+func (s *Dialog) resendInvite(req *sip.Request) error {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = s.uu.ctx
+	}
+	tx, err := s.sendReq(ctx, req)
+	if err != nil {
+		return err
+	}
+	s.setFirstTX(tx)
+	return nil
+}
+
+// newRedirectedInvite создает повторный INVITE на target. Via и новый CSeq
+// добавляются при отправке, адрес назначения вычисляется заново по Request-URI.
+func newRedirectedInvite(invite *sip.Request, target sip.Uri) *sip.Request {
+	req := invite.Clone()
+	req.Recipient = *target.Clone()
+	req.Laddr = invite.Laddr
+	req.SetDestination("")
+	req.SetBody(invite.Body())
+	req.RemoveHeader("Via")
+	req.RemoveHeader("CSeq")
+	return req
+}
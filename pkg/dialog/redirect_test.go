@@ -0,0 +1,117 @@
+package dialog
+
+import (
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const alternativeServiceBody = `<?xml version="1.0" encoding="UTF-8"?>
+<ims-3gpp version="1">
+  <alternative-service>
+    <type>emergency</type>
+    <reason>Emergency call, retry as emergency</reason>
+    <action>emergency-registration</action>
+  </alternative-service>
+</ims-3gpp>`
+
+func newRedirectResponse(invite *sip.Request, code int, reason string, contacts ...string) *sip.Response {
+	resp := sip.NewResponseFromRequest(invite, code, reason, nil)
+	for _, contact := range contacts {
+		h := &sip.ContactHeader{Params: sip.NewParams()}
+		if _, err := sip.ParseAddressValue(contact, &h.Address, h.Params); err != nil {
+			panic(err)
+		}
+		resp.AppendHeader(h)
+	}
+	return resp
+}
+
+func TestParseRedirect(t *testing.T) {
+	invite := newTestRequest(sip.INVITE)
+
+	resp := newRedirectResponse(invite, sip.StatusMovedTemporarily, "Moved Temporarily",
+		"<sip:bob@10.0.0.1>;q=0.5",
+		"<sip:bob@10.0.0.2>;q=0.9;expires=60",
+		"<tel:+74951234567>")
+	redirect, err := ParseRedirect(resp)
+	require.NoError(t, err)
+
+	assert.Equal(t, 302, redirect.StatusCode)
+	require.Len(t, redirect.Contacts, 3)
+	assert.Equal(t, "tel", redirect.Contacts[0].URI.Scheme, "Contact без q имеет приоритет 1.0")
+	assert.Equal(t, "10.0.0.2", redirect.Contacts[1].URI.Host)
+	assert.Equal(t, 60, redirect.Contacts[1].Expires)
+	assert.Equal(t, -1, redirect.Contacts[2].Expires)
+	assert.Nil(t, redirect.AlternativeService)
+
+	// FollowContacts пропускает адреса не SIP
+	target, retry := FollowContacts()(redirect)
+	assert.True(t, retry)
+	assert.Equal(t, "10.0.0.2", target.Host)
+
+	_, err = ParseRedirect(newRedirectResponse(invite, 302, "Moved", "<sip:bob@10.0.0.1>;q=2"))
+	assert.Error(t, err, "q вне диапазона 0-1")
+
+	_, err = ParseRedirect(sip.NewResponseFromRequest(invite, 486, "Busy Here", nil))
+	assert.Error(t, err, "Ответ не 3xx")
+}
+
+func TestParseAlternativeService(t *testing.T) {
+	invite := newTestRequest(sip.INVITE)
+	resp := newRedirectResponse(invite, 380, "Alternative Service")
+	contentType := sip.ContentTypeHeader(ContentTypeIMS3GPP)
+	resp.AppendHeader(&contentType)
+	resp.SetBody([]byte(alternativeServiceBody))
+
+	redirect, err := ParseRedirect(resp)
+	require.NoError(t, err)
+	require.NotNil(t, redirect.AlternativeService)
+	assert.True(t, redirect.AlternativeService.IsEmergency())
+	assert.Equal(t, "emergency-registration", redirect.AlternativeService.Action)
+
+	// 380 без смены услуги не повторяется по Contact
+	_, retry := FollowContacts()(redirect)
+	assert.False(t, retry)
+
+	sos := sip.Uri{Scheme: "sip", User: "sos", Host: "psap.example.net"}
+	policy := EmergencyRedirect(sos, FollowContacts())
+	target, retry := policy(redirect)
+	assert.True(t, retry)
+	assert.Equal(t, "sos", target.User)
+
+	// Остальные 3xx передаются следующей политике
+	target, retry = policy(&Redirect{StatusCode: 302, Contacts: []RedirectContact{{URI: sip.Uri{Scheme: "sip", Host: "10.0.0.3"}}}})
+	assert.True(t, retry)
+	assert.Equal(t, "10.0.0.3", target.Host)
+
+	resp.SetBody([]byte("<ims-3gpp"))
+	_, err = ParseRedirect(resp)
+	assert.Error(t, err, "Некорректный XML")
+}
+
+func TestRedirectedInvite(t *testing.T) {
+	invite := newTestRequest(sip.INVITE)
+	invite.SetBody([]byte("v=0"))
+	target := sip.Uri{Scheme: "sip", User: "bob", Host: "10.0.0.2", Port: 5070}
+
+	req := newRedirectedInvite(invite, target)
+	assert.Equal(t, "10.0.0.2", req.Recipient.Host)
+	assert.Nil(t, req.CSeq(), "CSeq назначается при отправке")
+	assert.Equal(t, uint32(1), invite.CSeq().SeqNo, "Исходный INVITE не меняется")
+	assert.Equal(t, invite.CallID().Value(), req.CallID().Value())
+	assert.Equal(t, invite.From().Value(), req.From().Value())
+	assert.Equal(t, "v=0", string(req.Body()))
+	assert.Nil(t, req.Via(), "Via добавляется при отправке")
+	assert.Equal(t, "10.0.0.2:5070", req.Destination())
+
+	// Без политики перенаправление только сохраняется и передается обработчику
+	d := &Dialog{}
+	var notified *Redirect
+	d.OnRedirect(func(redirect *Redirect) { notified = redirect })
+	assert.False(t, d.processRedirect(invite, newRedirectResponse(invite, 302, "Moved", "<sip:bob@10.0.0.2>")))
+	require.NotNil(t, notified)
+	assert.Same(t, notified, d.LastRedirect())
+}
@@ -0,0 +1,678 @@
+package dialog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/store"
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+)
+
+// RegistrarStorePrefix - префикс ключей привязок регистратора в хранилище
+const RegistrarStorePrefix = "reg/"
+
+// Значения expires регистратора по умолчанию, в секундах
+const (
+	defaultRegistrarExpires    = 3600
+	defaultRegistrarMinExpires = 60
+	defaultRegistrarMaxExpires = 7200
+)
+
+// statusIntervalTooBrief - 423 Interval Too Brief (RFC 3261 Section 21.4.17)
+const statusIntervalTooBrief = 423
+
+// RegistrarConfig содержит параметры встроенного регистратора
+type RegistrarConfig struct {
+	// Store - хранилище привязок. nil - store.MemoryStore регистратора
+	Store store.Store
+	// Domains - домены, для которых принимаются REGISTER и маршрутизируются
+	// INVITE. Пустой список - любой домен
+	Domains []string
+	// DefaultExpires - время жизни привязки без expires в запросе (3600 с)
+	DefaultExpires int
+	// MinExpires - минимальное время жизни привязки, меньшие значения
+	// отклоняются ответом 423 (60 с)
+	MinExpires int
+	// MaxExpires - максимальное время жизни, большие значения уменьшаются (7200 с)
+	MaxExpires int
+	// Redirect - отвечать на INVITE зарегистрированному абоненту 302 с его
+	// контактами вместо проксирования
+	Redirect bool
+	// NATPing - периодическая проверка зарегистрированных контактов.
+	// Проверки поддерживают отображение адреса в NAT открытым, а привязки
+	// контактов, переставших отвечать, удаляются. nil - проверки отключены
+	NATPing *NATPingConfig
+}
+
+// Binding - привязка контакта к AOR (RFC 3261 Section 10)
+type Binding struct {
+	AOR        string  // Канонический AOR: схема, пользователь и домен
+	Contact    sip.Uri // Адрес для входящих вызовов
+	Source     string  // Адрес, с которого получен REGISTER (host:port)
+	Transport  string
+	CallID     string
+	CSeq       uint32
+	Q          float64 // Приоритет 0.0-1.0
+	Registered time.Time
+	Expires    time.Time
+	// PingFailures - число неудачных проверок NATPing подряд
+	PingFailures int
+}
+
+// bindingRecord - привязка в хранилище
+type bindingRecord struct {
+	AOR        string    `json:"aor"`
+	Contact    string    `json:"contact"`
+	Source     string    `json:"source"`
+	Transport  string    `json:"transport"`
+	CallID     string    `json:"call_id"`
+	CSeq       uint32    `json:"cseq"`
+	Q          float64   `json:"q"`
+	Registered time.Time `json:"registered"`
+}
+
+// Registrar - встроенный регистратор для лабораторных и edge установок.
+//
+// Регистратор принимает REGISTER (RFC 3261 Section 10.3), хранит привязки
+// контактов к AOR с ограниченным временем жизни и отвечает текущим списком
+// привязок. INVITE к зарегистрированному AOR проксируется на его контакты
+// по убыванию q (или перенаправляется ответом 302, если задан Redirect),
+// поэтому два телефона, зарегистрированные на UACUAS, звонят
+// друг другу без внешней АТС. Прокси не добавляет Record-Route: запросы
+// внутри установленного диалога идут напрямую между телефонами.
+//
+//	registrar := dialog.NewRegistrar(dialog.RegistrarConfig{Domains: []string{"192.168.1.10"}})
+//	defer registrar.Close()
+//	ua.SetRegistrar(registrar)
+//
+// INVITE к AOR без привязок обрабатывается UACUAS как обычный входящий вызов.
+type Registrar struct {
+	config    RegistrarConfig
+	store     store.Store
+	ownsStore bool
+
+	// mu упорядочивает обработку REGISTER: изменения привязок AOR
+	// выполняются чтением и записью хранилища
+	mu sync.Mutex
+
+	uuMu sync.Mutex
+	uu   *UACUAS
+
+	pingOnce     sync.Once
+	pingWG       sync.WaitGroup
+	pingStop     chan struct{}
+	pingStopOnce sync.Once
+	pingMu       sync.Mutex
+	pingFailures map[string]int
+}
+
+// NewRegistrar создает регистратор
+func NewRegistrar(config RegistrarConfig) *Registrar {
+	if config.DefaultExpires <= 0 {
+		config.DefaultExpires = defaultRegistrarExpires
+	}
+	if config.MinExpires <= 0 {
+		config.MinExpires = defaultRegistrarMinExpires
+	}
+	if config.MaxExpires <= 0 {
+		config.MaxExpires = defaultRegistrarMaxExpires
+	}
+	if ping := config.NATPing; ping != nil {
+		copied := *ping
+		if copied.Method == "" {
+			copied.Method = NATPingOptions
+		}
+		if copied.Interval <= 0 {
+			copied.Interval = defaultNATPingInterval
+		}
+		if copied.Timeout <= 0 {
+			copied.Timeout = defaultNATPingTimeout
+		}
+		if copied.MaxFailures <= 0 {
+			copied.MaxFailures = defaultNATPingMaxFailures
+		}
+		config.NATPing = &copied
+	}
+	r := &Registrar{
+		config:       config,
+		store:        config.Store,
+		pingStop:     make(chan struct{}),
+		pingFailures: make(map[string]int),
+	}
+	if r.store == nil {
+		r.store = store.NewMemoryStore()
+		r.ownsStore = true
+	}
+	return r
+}
+
+// SetRegistrar подключает регистратор к UACUAS: входящие REGISTER
+// обрабатываются регистратором, INVITE к зарегистрированным AOR
+// маршрутизируются на их контакты, запускаются проверки NATPing.
+// nil отключает регистратор.
+func (u *UACUAS) SetRegistrar(r *Registrar) {
+	if r != nil {
+		r.uuMu.Lock()
+		r.uu = u
+		r.uuMu.Unlock()
+		r.startNATPing(u)
+	}
+	u.registrarMu.Lock()
+	u.registrar = r
+	u.registrarMu.Unlock()
+}
+
+// Registrar возвращает подключенный регистратор или nil
+func (u *UACUAS) Registrar() *Registrar {
+	u.registrarMu.Lock()
+	defer u.registrarMu.Unlock()
+	return u.registrar
+}
+
+// Close останавливает проверки NATPing и закрывает хранилище, созданное
+// регистратором. Хранилище из RegistrarConfig.Store закрывает приложение.
+func (r *Registrar) Close() error {
+	r.pingStopOnce.Do(func() { close(r.pingStop) })
+	r.pingWG.Wait()
+	if r.ownsStore {
+		return r.store.Close()
+	}
+	return nil
+}
+
+// Bindings возвращает действующие привязки AOR по убыванию приоритета
+// (при равном приоритете - сначала отвечающие на проверки NATPing, затем
+// последние зарегистрированные)
+func (r *Registrar) Bindings(aor sip.Uri) ([]Binding, error) {
+	return r.bindings(context.Background(), canonicalAOR(aor))
+}
+
+// bindings читает привязки канонического AOR из хранилища
+func (r *Registrar) bindings(ctx context.Context, aor string) ([]Binding, error) {
+	entries, err := r.store.List(ctx, RegistrarStorePrefix+aor+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	bindings := make([]Binding, 0, len(entries))
+	r.pingMu.Lock()
+	for _, entry := range entries {
+		binding, ok := decodeBinding(entry.Key, entry.Value)
+		if !ok {
+			continue
+		}
+		binding.Expires = entry.ExpiresAt
+		binding.PingFailures = r.pingFailures[entry.Key]
+		bindings = append(bindings, binding)
+	}
+	r.pingMu.Unlock()
+
+	// При равном q первыми идут контакты, отвечающие на проверки NATPing
+	sort.SliceStable(bindings, func(i, j int) bool {
+		if bindings[i].Q != bindings[j].Q {
+			return bindings[i].Q > bindings[j].Q
+		}
+		if bindings[i].PingFailures != bindings[j].PingFailures {
+			return bindings[i].PingFailures < bindings[j].PingFailures
+		}
+		return bindings[i].Registered.After(bindings[j].Registered)
+	})
+	return bindings, nil
+}
+
+// decodeBinding разбирает запись привязки из хранилища
+func decodeBinding(key string, value []byte) (Binding, bool) {
+	var record bindingRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		slog.Warn("Неверная запись привязки регистратора", slog.String("key", key))
+		return Binding{}, false
+	}
+	binding := Binding{
+		AOR:        record.AOR,
+		Source:     record.Source,
+		Transport:  record.Transport,
+		CallID:     record.CallID,
+		CSeq:       record.CSeq,
+		Q:          record.Q,
+		Registered: record.Registered,
+	}
+	if err := sip.ParseUri(record.Contact, &binding.Contact); err != nil {
+		slog.Warn("Неверный контакт привязки регистратора", slog.String("key", key))
+		return Binding{}, false
+	}
+	return binding, true
+}
+
+// handleRegister обрабатывает REGISTER (RFC 3261 Section 10.3)
+func (r *Registrar) handleRegister(req *sip.Request, tx sip.ServerTransaction) {
+	resp := r.register(req)
+	if err := tx.Respond(resp); err != nil {
+		slog.Error("Ошибка отправки ответа на REGISTER",
+			slog.Any("error", err),
+			slog.Int("status", resp.StatusCode))
+	}
+}
+
+// registerContact - контакт REGISTER с вычисленным временем жизни
+type registerContact struct {
+	uri     sip.Uri
+	q       float64
+	expires int
+}
+
+// register применяет REGISTER к привязкам и формирует ответ
+func (r *Registrar) register(req *sip.Request) *sip.Response {
+	reject := func(code int, reason string) *sip.Response {
+		return sip.NewResponseFromRequest(req, code, reason, nil)
+	}
+
+	if !r.servesDomain(req.Recipient.Host) {
+		return reject(sip.StatusNotFound, "Domain Not Served")
+	}
+	to, callID, cseq := req.To(), req.CallID(), req.CSeq()
+	if to == nil || callID == nil || cseq == nil {
+		return reject(sip.StatusBadRequest, "Bad Request")
+	}
+	aor := canonicalAOR(to.Address)
+
+	expires := r.config.DefaultExpires
+	if h := req.GetHeader("Expires"); h != nil {
+		v, err := strconv.Atoi(strings.TrimSpace(h.Value()))
+		if err != nil || v < 0 {
+			return reject(sip.StatusBadRequest, "Invalid Expires")
+		}
+		expires = v
+	}
+
+	// Разбор контактов: "*" удаляет все привязки и допустим только с Expires: 0
+	var contacts []registerContact
+	wildcard := false
+	for _, h := range req.GetHeaders("Contact") {
+		contact, ok := h.(*sip.ContactHeader)
+		if !ok {
+			continue
+		}
+		if contact.Address.Wildcard {
+			wildcard = true
+			continue
+		}
+		c := registerContact{uri: contact.Address, q: 1, expires: expires}
+		if value, ok := contact.Params.Get("expires"); ok {
+			if v, err := strconv.Atoi(value); err == nil {
+				c.expires = v
+			}
+		}
+		if value, ok := contact.Params.Get("q"); ok {
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				c.q = v
+			}
+		}
+		if c.expires > 0 && c.expires < r.config.MinExpires {
+			resp := reject(statusIntervalTooBrief, "Interval Too Brief")
+			resp.AppendHeader(sip.NewHeader("Min-Expires", strconv.Itoa(r.config.MinExpires)))
+			return resp
+		}
+		if c.expires > r.config.MaxExpires {
+			c.expires = r.config.MaxExpires
+		}
+		contacts = append(contacts, c)
+	}
+	if wildcard && (len(contacts) > 0 || expires != 0) {
+		return reject(sip.StatusBadRequest, "Invalid Wildcard Contact")
+	}
+
+	ctx := context.Background()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.bindings(ctx, aor)
+	if err != nil {
+		slog.Error("Ошибка чтения привязок регистратора", slog.String("aor", aor), slog.Any("error", err))
+		return reject(sip.StatusInternalServerError, "Server Internal Error")
+	}
+
+	if wildcard {
+		for _, binding := range current {
+			if binding.CallID == callID.Value() && binding.CSeq >= cseq.SeqNo {
+				continue
+			}
+			if err := r.store.Delete(ctx, bindingKey(aor, binding.Contact)); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return reject(sip.StatusInternalServerError, "Server Internal Error")
+			}
+		}
+		slog.Info("Регистратор: удалены все привязки", slog.String("aor", aor))
+	}
+
+	for _, c := range contacts {
+		key := bindingKey(aor, c.uri)
+		for _, binding := range current {
+			if bindingKey(aor, binding.Contact) == key && binding.CallID == callID.Value() && binding.CSeq >= cseq.SeqNo {
+				// Повтор или запрос вне очереди (RFC 3261 Section 10.3, шаг 7)
+				return reject(sip.StatusInternalServerError, "Out Of Order Request")
+			}
+		}
+
+		if c.expires == 0 {
+			if err := r.store.Delete(ctx, key); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return reject(sip.StatusInternalServerError, "Server Internal Error")
+			}
+			slog.Info("Регистратор: привязка удалена",
+				slog.String("aor", aor),
+				slog.String("contact", c.uri.String()))
+			continue
+		}
+
+		value, err := json.Marshal(bindingRecord{
+			AOR:        aor,
+			Contact:    c.uri.String(),
+			Source:     req.Source(),
+			Transport:  req.Transport(),
+			CallID:     callID.Value(),
+			CSeq:       cseq.SeqNo,
+			Q:          c.q,
+			Registered: time.Now(),
+		})
+		if err == nil {
+			err = r.store.Put(ctx, key, value, time.Duration(c.expires)*time.Second)
+		}
+		if err != nil {
+			slog.Error("Ошибка сохранения привязки регистратора", slog.String("aor", aor), slog.Any("error", err))
+			return reject(sip.StatusInternalServerError, "Server Internal Error")
+		}
+		slog.Info("Регистратор: привязка обновлена",
+			slog.String("aor", aor),
+			slog.String("contact", c.uri.String()),
+			slog.Int("expires", c.expires))
+	}
+
+	// Ответ содержит все действующие привязки AOR
+	bindings, err := r.bindings(ctx, aor)
+	if err != nil {
+		return reject(sip.StatusInternalServerError, "Server Internal Error")
+	}
+	resp := sip.NewResponseFromRequest(req, sip.StatusOK, "OK", nil)
+	now := time.Now()
+	for _, binding := range bindings {
+		params := sip.NewParams()
+		params.Add("expires", strconv.Itoa(binding.expiresIn(now)))
+		resp.AppendHeader(&sip.ContactHeader{Address: binding.Contact, Params: params})
+	}
+	resp.AppendHeader(sip.NewHeader("Date", now.UTC().Format(time.RFC1123)))
+	return resp
+}
+
+// routeInvite маршрутизирует новый INVITE к зарегистрированному AOR.
+// Возвращает false, если у адресата нет привязок и вызов обрабатывается
+// UACUAS как обычный входящий.
+func (r *Registrar) routeInvite(req *sip.Request, tx sip.ServerTransaction) bool {
+	if !r.servesDomain(req.Recipient.Host) {
+		return false
+	}
+	bindings, err := r.bindings(context.Background(), canonicalAOR(req.Recipient))
+	if err != nil || len(bindings) == 0 {
+		return false
+	}
+
+	if r.config.Redirect {
+		resp := sip.NewResponseFromRequest(req, sip.StatusMovedTemporarily, "Moved Temporarily", nil)
+		for _, binding := range bindings {
+			params := sip.NewParams()
+			params.Add("q", strconv.FormatFloat(binding.Q, 'f', -1, 64))
+			resp.AppendHeader(&sip.ContactHeader{Address: binding.Contact, Params: params})
+		}
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки 302 на INVITE", slog.Any("error", err))
+		}
+		return true
+	}
+
+	// Серверная транзакция завершается по возврату из обработчика,
+	// поэтому пересылка блокирует до финального ответа
+	r.proxyInvite(req, tx, bindings)
+	return true
+}
+
+// proxyInvite пересылает INVITE на контакты привязок и передает ответы
+// обратно (RFC 3261 Section 16). Контакты перебираются последовательно по
+// убыванию q: если контакт не ответил или ответил 408, 480 или 5xx, вызов
+// пересылается следующему. CANCEL вызывающей стороны пересылается текущему
+// контакту и прекращает перебор.
+func (r *Registrar) proxyInvite(req *sip.Request, tx sip.ServerTransaction, bindings []Binding) {
+	respond := func(code int, reason string) {
+		if err := tx.Respond(sip.NewResponseFromRequest(req, code, reason, nil)); err != nil {
+			slog.Error("Ошибка отправки ответа на проксируемый INVITE",
+				slog.Any("error", err),
+				slog.Int("status", code))
+		}
+	}
+
+	r.uuMu.Lock()
+	u := r.uu
+	r.uuMu.Unlock()
+	if u == nil {
+		respond(sip.StatusServiceUnavailable, "Service Unavailable")
+		return
+	}
+
+	if h := req.MaxForwards(); h != nil && h.Val() <= 1 {
+		respond(sip.StatusTooManyHops, "Too Many Hops")
+		return
+	}
+
+	var (
+		mu       sync.Mutex
+		current  *sip.Request
+		canceled bool
+	)
+	tx.OnCancel(func(*sip.Request) {
+		mu.Lock()
+		canceled = true
+		forward := current
+		mu.Unlock()
+		if forward == nil {
+			return
+		}
+		if err := u.uac.WriteRequest(proxyCancel(forward)); err != nil {
+			slog.Warn("Регистратор: ошибка пересылки CANCEL", slog.Any("error", err))
+		}
+	})
+
+	for i, binding := range bindings {
+		forward := r.forwardRequest(u, req, binding)
+		mu.Lock()
+		if canceled {
+			mu.Unlock()
+			return
+		}
+		current = forward
+		mu.Unlock()
+
+		resp, err := r.forwardInvite(u, req, tx, forward, binding)
+		if errors.Is(err, sip.ErrTransactionTerminated) {
+			return
+		}
+
+		mu.Lock()
+		stop := canceled
+		mu.Unlock()
+		last := i == len(bindings)-1
+		if !stop && !last && (err != nil || proxyFailover(resp.StatusCode)) {
+			slog.Info("Регистратор: контакт недоступен, вызов передается следующему",
+				slog.String("contact", binding.Contact.String()))
+			continue
+		}
+
+		switch {
+		case err == nil:
+			proxyResponse(req, tx, resp)
+		case errors.Is(err, sip.ErrTransactionTimeout):
+			respond(sip.StatusRequestTimeout, "Request Timeout")
+		default:
+			respond(sip.StatusServiceUnavailable, "Service Unavailable")
+		}
+		return
+	}
+}
+
+// forwardRequest формирует INVITE для пересылки на контакт привязки
+func (r *Registrar) forwardRequest(u *UACUAS, req *sip.Request, binding Binding) *sip.Request {
+	forward := req.Clone()
+	forward.Recipient = binding.Contact
+	if forward.MaxForwards() == nil {
+		maxForwards := sip.MaxForwardsHeader(70)
+		forward.AppendHeader(&maxForwards)
+	}
+	applyBindingPath(u, forward, binding)
+	return forward
+}
+
+// forwardInvite отправляет INVITE на контакт и передает предварительные
+// ответы вызывающей стороне. Возвращает финальный ответ контакта или ошибку
+// клиентской транзакции; sip.ErrTransactionTerminated - входящая транзакция
+// завершена.
+func (r *Registrar) forwardInvite(u *UACUAS, req *sip.Request, tx sip.ServerTransaction, forward *sip.Request, binding Binding) (*sip.Response, error) {
+	clientTx, err := u.uac.TransactionRequest(u.ctx, forward,
+		sipgo.ClientRequestDecreaseMaxForward,
+		sipgo.ClientRequestAddVia)
+	if err != nil {
+		slog.Warn("Регистратор: ошибка пересылки INVITE",
+			slog.String("contact", binding.Contact.String()),
+			slog.Any("error", err))
+		return nil, err
+	}
+
+	slog.Info("Регистратор: INVITE проксирован",
+		slog.String("aor", binding.AOR),
+		slog.String("contact", binding.Contact.String()))
+
+	for {
+		select {
+		case resp := <-clientTx.Responses():
+			if resp.StatusCode >= 200 {
+				return resp, nil
+			}
+			if resp.StatusCode != sip.StatusTrying {
+				proxyResponse(req, tx, resp)
+			}
+		case <-clientTx.Done():
+			return nil, clientTx.Err()
+		case <-tx.Done():
+			clientTx.Terminate()
+			return nil, sip.ErrTransactionTerminated
+		}
+	}
+}
+
+// proxyResponse передает ответ контакта вызывающей стороне без Via прокси
+func proxyResponse(req *sip.Request, tx sip.ServerTransaction, resp *sip.Response) {
+	upstream := resp.Clone()
+	upstream.RemoveHeader("Via")
+	upstream.SetTransport(req.Transport())
+	upstream.SetDestination(req.Source())
+	if err := tx.Respond(upstream); err != nil {
+		slog.Debug("Регистратор: ответ на проксируемый INVITE не отправлен",
+			slog.Int("status", resp.StatusCode),
+			slog.Any("error", err))
+	}
+}
+
+// proxyFailover проверяет, передается ли вызов следующему контакту после
+// финального ответа status
+func proxyFailover(status int) bool {
+	return status == sip.StatusRequestTimeout || status == sip.StatusTemporarilyUnavailable ||
+		(status >= 500 && status < 600)
+}
+
+// applyBindingPath направляет запрос к контакту привязки с того же сокета,
+// на который пришел REGISTER: ответы телефона за NAT и запросы к нему
+// проходят через открытое им отображение адреса
+func applyBindingPath(u *UACUAS, req *sip.Request, binding Binding) {
+	if binding.Transport != "" {
+		req.SetTransport(binding.Transport)
+	}
+	if binding.Source != "" {
+		req.SetDestination(binding.Source)
+	}
+	if len(u.config.TransportConfigs) > 0 {
+		tc := u.config.TransportConfigs[0]
+		req.Laddr = sip.Addr{IP: net.ParseIP(tc.Host), Hostname: tc.Host, Port: tc.Port}
+	}
+}
+
+// proxyCancel формирует CANCEL для пересланного INVITE: та же ветка Via,
+// что у INVITE (RFC 3261 Section 9.1)
+func proxyCancel(invite *sip.Request) *sip.Request {
+	cancel := sip.NewRequest(sip.CANCEL, invite.Recipient)
+	cancel.SipVersion = invite.SipVersion
+	if via := invite.Via(); via != nil {
+		cancel.AppendHeader(via.Clone())
+	}
+	maxForwards := sip.MaxForwardsHeader(70)
+	cancel.AppendHeader(&maxForwards)
+	for _, h := range []sip.Header{invite.From(), invite.To(), invite.CallID()} {
+		if h != nil {
+			cancel.AppendHeader(sip.HeaderClone(h))
+		}
+	}
+	if h := invite.CSeq(); h != nil {
+		cseq := sip.HeaderClone(h).(*sip.CSeqHeader)
+		cseq.MethodName = sip.CANCEL
+		cancel.AppendHeader(cseq)
+	}
+	cancel.SetTransport(invite.Transport())
+	cancel.SetDestination(invite.Destination())
+	return cancel
+}
+
+// servesDomain проверяет, обслуживает ли регистратор домен host
+func (r *Registrar) servesDomain(host string) bool {
+	if len(r.config.Domains) == 0 {
+		return true
+	}
+	for _, domain := range r.config.Domains {
+		if strings.EqualFold(domain, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// expiresIn возвращает оставшееся время жизни привязки в секундах
+func (b Binding) expiresIn(now time.Time) int {
+	if b.Expires.IsZero() {
+		return 0
+	}
+	seconds := int(b.Expires.Sub(now).Round(time.Second) / time.Second)
+	if seconds < 0 {
+		return 0
+	}
+	return seconds
+}
+
+// canonicalAOR приводит URI к каноническому AOR: схема, пользователь и
+// домен без порта и параметров (RFC 3261 Section 10.3, шаг 5)
+func canonicalAOR(uri sip.Uri) string {
+	scheme := strings.ToLower(uri.Scheme)
+	if scheme == "" {
+		scheme = "sip"
+	}
+	if uri.User == "" {
+		return scheme + ":" + strings.ToLower(uri.Host)
+	}
+	return fmt.Sprintf("%s:%s@%s", scheme, uri.User, strings.ToLower(uri.Host))
+}
+
+// bindingKey возвращает ключ привязки в хранилище
+func bindingKey(aor string, contact sip.Uri) string {
+	return RegistrarStorePrefix + aor + "/" + contact.String()
+}
@@ -0,0 +1,229 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+)
+
+// NATPingMethod - способ проверки доступности зарегистрированного контакта
+type NATPingMethod string
+
+const (
+	// NATPingOptions - запрос OPTIONS; любой ответ подтверждает доступность
+	NATPingOptions NATPingMethod = "options"
+	// NATPingCRLF - двойной CRLF через соединение, на которое пришел
+	// REGISTER (RFC 5626 Section 4.4.1). Ответ не ожидается: ошибкой
+	// считается только закрытое или отсутствующее соединение, поэтому
+	// способ предназначен для TCP и TLS
+	NATPingCRLF NATPingMethod = "crlf"
+)
+
+// Параметры проверки контактов по умолчанию
+const (
+	defaultNATPingInterval    = 30 * time.Second
+	defaultNATPingTimeout     = 5 * time.Second
+	defaultNATPingMaxFailures = 3
+)
+
+// natPingKeepalive - сообщение keepalive для NATPingCRLF
+var natPingKeepalive = []byte("\r\n\r\n")
+
+// NATPingConfig содержит параметры keepalive к контактам за NAT
+type NATPingConfig struct {
+	// Method - способ проверки (NATPingOptions)
+	Method NATPingMethod
+	// Interval - период проверки каждой привязки (30 с)
+	Interval time.Duration
+	// Timeout - время ожидания ответа на OPTIONS (5 с)
+	Timeout time.Duration
+	// MaxFailures - число неудачных проверок подряд, после которого
+	// привязка удаляется (3)
+	MaxFailures int
+}
+
+// startNATPing запускает проверку контактов, если она настроена.
+// Повторные вызовы ничего не делают.
+func (r *Registrar) startNATPing(u *UACUAS) {
+	if r.config.NATPing == nil {
+		return
+	}
+	r.pingOnce.Do(func() {
+		r.pingWG.Add(1)
+		go r.natPingLoop(u)
+	})
+}
+
+// natPingLoop периодически проверяет все привязки до Close регистратора
+// или остановки UACUAS
+func (r *Registrar) natPingLoop(u *UACUAS) {
+	defer r.pingWG.Done()
+
+	ticker := time.NewTicker(r.config.NATPing.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.pingBindings(u)
+		case <-r.pingStop:
+			return
+		case <-u.ctx.Done():
+			return
+		}
+	}
+}
+
+// pingBindings проверяет все привязки параллельно и удаляет привязки,
+// не ответившие MaxFailures раз подряд
+func (r *Registrar) pingBindings(u *UACUAS) {
+	ctx := u.ctx
+	entries, err := r.store.List(ctx, RegistrarStorePrefix)
+	if err != nil {
+		slog.Warn("Регистратор: ошибка чтения привязок для проверки", slog.Any("error", err))
+		return
+	}
+
+	type result struct {
+		key     string
+		binding Binding
+		err     error
+	}
+	results := make(chan result, len(entries))
+	keys := make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		binding, ok := decodeBinding(entry.Key, entry.Value)
+		if !ok {
+			continue
+		}
+		binding.Expires = entry.ExpiresAt
+		keys[entry.Key] = struct{}{}
+		go func(key string, binding Binding) {
+			results <- result{key: key, binding: binding, err: r.pingBinding(ctx, u, binding)}
+		}(entry.Key, binding)
+	}
+
+	r.pingMu.Lock()
+	for key := range r.pingFailures {
+		if _, ok := keys[key]; !ok {
+			delete(r.pingFailures, key)
+		}
+	}
+	r.pingMu.Unlock()
+
+	for range keys {
+		res := <-results
+		if res.err == nil {
+			r.pingMu.Lock()
+			delete(r.pingFailures, res.key)
+			r.pingMu.Unlock()
+			continue
+		}
+
+		r.pingMu.Lock()
+		r.pingFailures[res.key]++
+		failures := r.pingFailures[res.key]
+		r.pingMu.Unlock()
+
+		slog.Debug("Регистратор: контакт не ответил на проверку",
+			slog.String("contact", res.binding.Contact.String()),
+			slog.Int("failures", failures),
+			slog.Any("error", res.err))
+
+		if failures < r.config.NATPing.MaxFailures {
+			continue
+		}
+		r.removeUnreachable(ctx, res.key, res.binding)
+	}
+}
+
+// removeUnreachable удаляет привязку недоступного контакта
+func (r *Registrar) removeUnreachable(ctx context.Context, key string, binding Binding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pingMu.Lock()
+	delete(r.pingFailures, key)
+	r.pingMu.Unlock()
+
+	// Привязка могла быть обновлена новым REGISTER во время проверки
+	entry, err := r.store.Get(ctx, key)
+	if err != nil {
+		return
+	}
+	current, ok := decodeBinding(key, entry.Value)
+	if !ok || current.CallID != binding.CallID || current.CSeq != binding.CSeq {
+		return
+	}
+	if err := r.store.Delete(ctx, key); err != nil {
+		slog.Warn("Регистратор: ошибка удаления привязки", slog.String("key", key), slog.Any("error", err))
+		return
+	}
+	slog.Info("Регистратор: привязка удалена, контакт не отвечает на проверки",
+		slog.String("aor", binding.AOR),
+		slog.String("contact", binding.Contact.String()))
+}
+
+// pingBinding проверяет доступность контакта привязки
+func (r *Registrar) pingBinding(ctx context.Context, u *UACUAS, binding Binding) error {
+	if r.config.NATPing.Method == NATPingCRLF {
+		return pingCRLF(u, binding)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.NATPing.Timeout)
+	defer cancel()
+
+	probe := sip.NewRequest(sip.OPTIONS, *binding.Contact.Clone())
+	applyBindingPath(u, probe, binding)
+
+	tx, err := u.uac.TransactionRequest(ctx, probe, sipgo.ClientRequestBuild)
+	if err != nil {
+		return err
+	}
+	defer tx.Terminate()
+
+	select {
+	case <-tx.Responses():
+		return nil
+	case <-tx.Done():
+		return tx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pingCRLF отправляет keepalive через соединение, на которое пришел REGISTER
+func pingCRLF(u *UACUAS, binding Binding) error {
+	transport := binding.Transport
+	if transport == "" {
+		transport = "udp"
+	}
+	conn, err := u.ua.TransportLayer().GetConnection(transport, binding.Source)
+	if err != nil {
+		return err
+	}
+
+	switch c := conn.(type) {
+	case *sip.UDPConnection:
+		if c.PacketConn == nil {
+			_, err = c.Write(natPingKeepalive)
+			return err
+		}
+		addr, err := net.ResolveUDPAddr("udp", binding.Source)
+		if err != nil {
+			return err
+		}
+		_, err = c.WriteTo(natPingKeepalive, addr)
+		return err
+	case *sip.TCPConnection:
+		_, err = c.Write(natPingKeepalive)
+		return err
+	default:
+		return fmt.Errorf("crlf keepalive is not supported for %s", transport)
+	}
+}
@@ -0,0 +1,358 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRegister создает REGISTER для AOR bob@example.com
+func newRegister(callID string, cseq uint32, expires int, contacts ...string) *sip.Request {
+	aor := sip.Uri{Scheme: "sip", User: "bob", Host: "example.com"}
+	req := sip.NewRequest(sip.REGISTER, sip.Uri{Scheme: "sip", Host: "example.com"})
+	req.AppendHeader(&sip.FromHeader{Address: aor, Params: sip.NewParams().Add("tag", "reg")})
+	req.AppendHeader(&sip.ToHeader{Address: aor})
+	callIDHeader := sip.CallIDHeader(callID)
+	req.AppendHeader(&callIDHeader)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: cseq, MethodName: sip.REGISTER})
+	if expires >= 0 {
+		expiresHeader := sip.ExpiresHeader(expires)
+		req.AppendHeader(&expiresHeader)
+	}
+	for _, contact := range contacts {
+		req.AppendHeader(sip.NewHeader("Contact", contact))
+	}
+	raw, err := sip.ParseMessage([]byte(req.String()))
+	if err != nil {
+		panic(err)
+	}
+	return raw.(*sip.Request)
+}
+
+// responseContacts возвращает контакты ответа REGISTER со значениями expires
+func responseContacts(resp *sip.Response) map[string]string {
+	contacts := make(map[string]string)
+	for _, h := range resp.GetHeaders("Contact") {
+		contact := h.(*sip.ContactHeader)
+		expires, _ := contact.Params.Get("expires")
+		contacts[contact.Address.String()] = expires
+	}
+	return contacts
+}
+
+func TestRegistrarRegister(t *testing.T) {
+	r := NewRegistrar(RegistrarConfig{Domains: []string{"example.com"}, MaxExpires: 600})
+	defer r.Close()
+	aor := sip.Uri{Scheme: "sip", User: "bob", Host: "EXAMPLE.com", Port: 5060}
+
+	resp := r.register(newRegister("c1", 1, 300, "<sip:bob@10.0.0.1:5060>"))
+	require.Equal(t, sip.StatusOK, resp.StatusCode)
+	assert.Equal(t, map[string]string{"sip:bob@10.0.0.1:5060": "300"}, responseContacts(resp))
+	assert.NotNil(t, resp.GetHeader("Date"))
+
+	// Второе устройство с большим expires и приоритетом: время жизни
+	// ограничено MaxExpires, в ответе все привязки AOR
+	resp = r.register(newRegister("c2", 1, -1, "<sip:bob@10.0.0.2:5060>;expires=3600;q=0.5"))
+	require.Equal(t, sip.StatusOK, resp.StatusCode)
+	assert.Equal(t, map[string]string{
+		"sip:bob@10.0.0.1:5060": "300",
+		"sip:bob@10.0.0.2:5060": "600",
+	}, responseContacts(resp))
+
+	bindings, err := r.Bindings(aor)
+	require.NoError(t, err)
+	require.Len(t, bindings, 2)
+	assert.Equal(t, "sip:bob@example.com", bindings[0].AOR)
+	assert.Equal(t, "10.0.0.1", bindings[0].Contact.Host, "Привязка с большим q первая")
+	assert.Equal(t, 0.5, bindings[1].Q)
+
+	// Слишком короткий интервал
+	resp = r.register(newRegister("c1", 2, 10, "<sip:bob@10.0.0.1:5060>"))
+	assert.Equal(t, statusIntervalTooBrief, resp.StatusCode)
+	assert.Equal(t, "60", resp.GetHeader("Min-Expires").Value())
+
+	// Повтор с тем же Call-ID и CSeq отклоняется
+	resp = r.register(newRegister("c1", 1, 300, "<sip:bob@10.0.0.1:5060>"))
+	assert.Equal(t, sip.StatusInternalServerError, resp.StatusCode)
+
+	// Запрос без Contact возвращает текущие привязки
+	resp = r.register(newRegister("c3", 1, -1))
+	require.Equal(t, sip.StatusOK, resp.StatusCode)
+	assert.Len(t, responseContacts(resp), 2)
+
+	// Удаление одной привязки
+	resp = r.register(newRegister("c1", 2, 0, "<sip:bob@10.0.0.1:5060>"))
+	require.Equal(t, sip.StatusOK, resp.StatusCode)
+	assert.Equal(t, map[string]string{"sip:bob@10.0.0.2:5060": "600"}, responseContacts(resp))
+
+	// "*" допустим только с Expires: 0
+	resp = r.register(newRegister("c4", 1, 60, "*"))
+	assert.Equal(t, sip.StatusBadRequest, resp.StatusCode)
+	resp = r.register(newRegister("c4", 1, 0, "*"))
+	require.Equal(t, sip.StatusOK, resp.StatusCode)
+	assert.Empty(t, responseContacts(resp))
+
+	bindings, err = r.Bindings(aor)
+	require.NoError(t, err)
+	assert.Empty(t, bindings)
+
+	// Чужой домен
+	req := newRegister("c5", 1, 60, "<sip:bob@10.0.0.1:5060>")
+	req.Recipient.Host = "other.com"
+	assert.Equal(t, sip.StatusNotFound, r.register(req).StatusCode)
+}
+
+func TestRegistrarExpiry(t *testing.T) {
+	r := NewRegistrar(RegistrarConfig{MinExpires: 1})
+	defer r.Close()
+
+	resp := r.register(newRegister("c1", 1, 1, "<sip:bob@10.0.0.1:5060>"))
+	require.Equal(t, sip.StatusOK, resp.StatusCode)
+
+	aor := sip.Uri{Scheme: "sip", User: "bob", Host: "example.com"}
+	assert.Eventually(t, func() bool {
+		bindings, err := r.Bindings(aor)
+		return err == nil && len(bindings) == 0
+	}, 3*time.Second, 50*time.Millisecond, "Привязка удаляется по истечении expires")
+}
+
+// TestRegistrarProxy проверяет лабораторный сценарий: телефон bob
+// регистрируется на UACUAS, вызов alice к bob проксируется на его контакт,
+// CANCEL вызывающей стороны пересылается вызываемой
+// registrarPeer - телефон на сыром UDP сокете для тестов регистратора
+type registrarPeer struct {
+	t    *testing.T
+	conn *net.UDPConn
+	uri  sip.Uri
+}
+
+func newRegistrarPeer(t *testing.T, user string) *registrarPeer {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return &registrarPeer{
+		t:    t,
+		conn: conn,
+		uri:  sip.Uri{Scheme: "sip", User: user, Host: "127.0.0.1", Port: conn.LocalAddr().(*net.UDPAddr).Port},
+	}
+}
+
+// read ждет сообщение, удовлетворяющее match
+func (p *registrarPeer) read(match func(sip.Message) bool) (sip.Message, *net.UDPAddr) {
+	buf := make([]byte, 4096)
+	require.NoError(p.t, p.conn.SetReadDeadline(time.Now().Add(3*time.Second)))
+	for {
+		n, from, err := p.conn.ReadFromUDP(buf)
+		require.NoError(p.t, err)
+		msg, err := sip.ParseMessage(buf[:n])
+		require.NoError(p.t, err)
+		if match(msg) {
+			return msg, from
+		}
+	}
+}
+
+func (p *registrarPeer) send(msg sip.Message, to *net.UDPAddr) {
+	_, err := p.conn.WriteToUDP([]byte(msg.String()), to)
+	require.NoError(p.t, err)
+}
+
+func (p *registrarPeer) newRequest(method sip.RequestMethod, from, to, contact sip.Uri, callID, branch string) *sip.Request {
+	req := sip.NewRequest(method, to)
+	req.AppendHeader(sip.NewHeader("Via", fmt.Sprintf("SIP/2.0/UDP %s;branch=%s", p.conn.LocalAddr(), branch)))
+	req.AppendHeader(&sip.FromHeader{Address: from, Params: sip.NewParams().Add("tag", from.User)})
+	req.AppendHeader(&sip.ToHeader{Address: to})
+	callIDHeader := sip.CallIDHeader(callID)
+	req.AppendHeader(&callIDHeader)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: method})
+	maxForwards := sip.MaxForwardsHeader(70)
+	req.AppendHeader(&maxForwards)
+	req.AppendHeader(&sip.ContactHeader{Address: contact})
+	return req
+}
+
+// register регистрирует контакт пира на регистраторе server
+func (p *registrarPeer) register(server *net.UDPAddr, aor sip.Uri, callID, q string) {
+	req := p.newRequest(sip.REGISTER, aor, aor, p.uri, callID, "z9hG4bK-"+callID)
+	req.Recipient = sip.Uri{Scheme: "sip", Host: server.IP.String(), Port: server.Port}
+	if q != "" {
+		req.Contact().Params = sip.NewParams().Add("q", q)
+	}
+	p.send(req, server)
+	p.read(matchResponse(sip.REGISTER, sip.StatusOK))
+}
+
+func matchRequest(method sip.RequestMethod) func(sip.Message) bool {
+	return func(msg sip.Message) bool {
+		req, ok := msg.(*sip.Request)
+		return ok && req.Method == method
+	}
+}
+
+func matchResponse(method sip.RequestMethod, code int) func(sip.Message) bool {
+	return func(msg sip.Message) bool {
+		resp, ok := msg.(*sip.Response)
+		return ok && resp.StatusCode == code && resp.CSeq().MethodName == method
+	}
+}
+
+// startRegistrarUA запускает UACUAS с регистратором на порту port
+func startRegistrarUA(t *testing.T, port int, config RegistrarConfig) (*Registrar, *net.UDPAddr) {
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: port}},
+	})
+	require.NoError(t, err)
+
+	registrar := NewRegistrar(config)
+	u.SetRegistrar(registrar)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { _ = u.ListenTransports(ctx) }()
+	t.Cleanup(func() {
+		_ = registrar.Close()
+		cancel()
+		_ = u.Stop()
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	return registrar, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+}
+
+func TestRegistrarProxy(t *testing.T) {
+	alice := newRegistrarPeer(t, "alice")
+	bob := newRegistrarPeer(t, "bob")
+	registrar, server := startRegistrarUA(t, 15106, RegistrarConfig{})
+
+	serverURI := sip.Uri{Scheme: "sip", Host: "127.0.0.1", Port: 15106}
+	bobAOR := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1"}
+
+	// Регистрация bob
+	register := bob.newRequest(sip.REGISTER, bobAOR, bobAOR, bob.uri, "reg-1", "z9hG4bK-reg1")
+	register.Recipient = serverURI
+	bob.send(register, server)
+	msg, _ := bob.read(matchResponse(sip.REGISTER, sip.StatusOK))
+	assert.Equal(t, map[string]string{bob.uri.String(): "3600"}, responseContacts(msg.(*sip.Response)))
+
+	// Вызов alice -> bob проксируется на контакт bob
+	target := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: 15106}
+	invite := alice.newRequest(sip.INVITE, alice.uri, target, alice.uri, "call-1", "z9hG4bK-call1")
+	alice.send(invite, server)
+
+	msg, proxy := bob.read(matchRequest(sip.INVITE))
+	forwarded := msg.(*sip.Request)
+	assert.Equal(t, bob.uri.String(), forwarded.Recipient.String())
+	assert.Len(t, forwarded.GetHeaders("Via"), 2, "Прокси добавляет свой Via")
+	assert.Equal(t, 69, int(forwarded.MaxForwards().Val()))
+	assert.Equal(t, server.Port, proxy.Port, "INVITE отправлен с порта, на который пришел REGISTER")
+
+	ringing := sip.NewResponseFromRequest(forwarded, sip.StatusRinging, "Ringing", nil)
+	ringing.To().Params.Add("tag", "bobtag")
+	bob.send(ringing, proxy)
+	msg, _ = alice.read(matchResponse(sip.INVITE, sip.StatusRinging))
+	assert.Len(t, msg.(*sip.Response).GetHeaders("Via"), 1, "Via прокси удален из ответа")
+
+	ok := sip.NewResponseFromRequest(forwarded, sip.StatusOK, "OK", nil)
+	ok.To().Params.Add("tag", "bobtag")
+	ok.AppendHeader(&sip.ContactHeader{Address: bob.uri})
+	bob.send(ok, proxy)
+	msg, _ = alice.read(matchResponse(sip.INVITE, sip.StatusOK))
+	assert.Equal(t, bob.uri.String(), msg.(*sip.Response).Contact().Address.String(),
+		"ACK и BYE идут напрямую на контакт bob")
+
+	// Отмена вызова пересылается bob
+	invite = alice.newRequest(sip.INVITE, alice.uri, target, alice.uri, "call-2", "z9hG4bK-call2")
+	alice.send(invite, server)
+	msg, proxy = bob.read(matchRequest(sip.INVITE))
+	forwarded = msg.(*sip.Request)
+	ringing = sip.NewResponseFromRequest(forwarded, sip.StatusRinging, "Ringing", nil)
+	ringing.To().Params.Add("tag", "bobtag2")
+	bob.send(ringing, proxy)
+	alice.read(matchResponse(sip.INVITE, sip.StatusRinging))
+
+	cancelReq := alice.newRequest(sip.CANCEL, alice.uri, target, alice.uri, "call-2", "z9hG4bK-call2")
+	cancelReq.CSeq().MethodName = sip.CANCEL
+	alice.send(cancelReq, server)
+	alice.read(matchResponse(sip.INVITE, sip.StatusRequestTerminated))
+
+	msg, _ = bob.read(matchRequest(sip.CANCEL))
+	assert.Equal(t, forwarded.Via().Params["branch"], msg.(*sip.Request).Via().Params["branch"],
+		"CANCEL в той же ветке, что пересланный INVITE")
+
+	// После отмены регистрации вызов bob не маршрутизируется
+	unregister := bob.newRequest(sip.REGISTER, bobAOR, bobAOR, bob.uri, "reg-1", "z9hG4bK-reg2")
+	unregister.Recipient = serverURI
+	unregister.CSeq().SeqNo = 2
+	expires := sip.ExpiresHeader(0)
+	unregister.AppendHeader(&expires)
+	bob.send(unregister, server)
+	msg, _ = bob.read(matchResponse(sip.REGISTER, sip.StatusOK))
+	assert.Empty(t, responseContacts(msg.(*sip.Response)))
+
+	bindings, err := registrar.Bindings(bobAOR)
+	require.NoError(t, err)
+	assert.Empty(t, bindings)
+}
+
+func TestRegistrarProxyFailover(t *testing.T) {
+	alice := newRegistrarPeer(t, "alice")
+	desk := newRegistrarPeer(t, "desk")
+	mobile := newRegistrarPeer(t, "mobile")
+	_, server := startRegistrarUA(t, 15107, RegistrarConfig{})
+
+	bobAOR := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1"}
+	mobile.register(server, bobAOR, "reg-mobile", "0.5")
+	desk.register(server, bobAOR, "reg-desk", "1.0")
+
+	// Контакт с наибольшим q отвечает 480, вызов передается следующему
+	target := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: 15107}
+	alice.send(alice.newRequest(sip.INVITE, alice.uri, target, alice.uri, "call-1", "z9hG4bK-call1"), server)
+
+	msg, proxy := desk.read(matchRequest(sip.INVITE))
+	unavailable := sip.NewResponseFromRequest(msg.(*sip.Request), sip.StatusTemporarilyUnavailable, "Temporarily Unavailable", nil)
+	unavailable.To().Params.Add("tag", "desktag")
+	desk.send(unavailable, proxy)
+
+	msg, proxy = mobile.read(matchRequest(sip.INVITE))
+	forwarded := msg.(*sip.Request)
+	assert.Equal(t, mobile.uri.String(), forwarded.Recipient.String())
+
+	// Финальный ответ последнего контакта передается вызывающей стороне
+	busy := sip.NewResponseFromRequest(forwarded, sip.StatusBusyHere, "Busy Here", nil)
+	busy.To().Params.Add("tag", "mobiletag")
+	mobile.send(busy, proxy)
+	alice.read(matchResponse(sip.INVITE, sip.StatusBusyHere))
+}
+
+func TestRegistrarNATPing(t *testing.T) {
+	bob := newRegistrarPeer(t, "bob")
+	registrar, server := startRegistrarUA(t, 15108, RegistrarConfig{
+		NATPing: &NATPingConfig{Interval: 100 * time.Millisecond, Timeout: 300 * time.Millisecond, MaxFailures: 2},
+	})
+
+	bobAOR := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1"}
+	bob.register(server, bobAOR, "reg-1", "")
+
+	// Проверка OPTIONS приходит на адрес, с которого получен REGISTER
+	msg, from := bob.read(matchRequest(sip.OPTIONS))
+	options := msg.(*sip.Request)
+	assert.Equal(t, bob.uri.String(), options.Recipient.String())
+	assert.Equal(t, server.Port, from.Port)
+	bob.send(sip.NewResponseFromRequest(options, sip.StatusOK, "OK", nil), from)
+
+	bindings, err := registrar.Bindings(bobAOR)
+	require.NoError(t, err)
+	require.Len(t, bindings, 1)
+
+	// Контакт перестал отвечать: привязка удаляется после MaxFailures проверок
+	assert.Eventually(t, func() bool {
+		bindings, err := registrar.Bindings(bobAOR)
+		return err == nil && len(bindings) == 0
+	}, 3*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,396 @@
+package dialog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// ReleaseCategory определяет обобщенную категорию причины завершения вызова.
+// Используется для единообразной классификации завершений в биллинге и аналитике.
+type ReleaseCategory string
+
+const (
+	// CategoryNormal - нормальное завершение вызова одной из сторон
+	CategoryNormal ReleaseCategory = "normal"
+	// CategoryBusy - вызываемый абонент занят
+	CategoryBusy ReleaseCategory = "busy"
+	// CategoryNoAnswer - вызываемый абонент не ответил или недоступен временно
+	CategoryNoAnswer ReleaseCategory = "no_answer"
+	// CategoryRejected - вызов отклонен вызываемой стороной или политикой
+	CategoryRejected ReleaseCategory = "rejected"
+	// CategoryCancelled - вызов отменен до ответа (CANCEL, 487)
+	CategoryCancelled ReleaseCategory = "cancelled"
+	// CategoryUnreachable - адресат не существует или номер неверен
+	CategoryUnreachable ReleaseCategory = "unreachable"
+	// CategoryNetworkError - ошибка сети или сервера
+	CategoryNetworkError ReleaseCategory = "network_error"
+	// CategoryMediaError - несовместимые параметры медиа
+	CategoryMediaError ReleaseCategory = "media_error"
+	// CategoryMediaTimeout - медиа поток прекратился (нет RTP)
+	CategoryMediaTimeout ReleaseCategory = "media_timeout"
+	// CategoryCallLimit - исчерпан лимит длительности вызова (CallLimit)
+	CategoryCallLimit ReleaseCategory = "call_limit"
+	// CategoryReplaced - вызов заменен новым диалогом (INVITE с Replaces)
+	CategoryReplaced ReleaseCategory = "replaced"
+	// CategoryError - прочие ошибки протокола
+	CategoryError ReleaseCategory = "error"
+)
+
+// Коды причин Q.850, используемые при отображении SIP ответов (RFC 3398 Section 8.2.6)
+const (
+	Q850UnallocatedNumber       = 1
+	Q850NormalClearing          = 16
+	Q850UserBusy                = 17
+	Q850NoUserResponding        = 18
+	Q850NoAnswer                = 19
+	Q850CallRejected            = 21
+	Q850NumberChanged           = 22
+	Q850ExchangeRoutingError    = 25
+	Q850InvalidNumberFormat     = 28
+	Q850NormalUnspecified       = 31
+	Q850NetworkOutOfOrder       = 38
+	Q850TemporaryFailure        = 41
+	Q850BearerNotAvailable      = 58
+	Q850ServiceUnavailable      = 63
+	Q850BearerNotImplemented    = 65
+	Q850ServiceNotImplemented   = 79
+	Q850IncompatibleDestination = 88
+	Q850RecoveryOnTimerExpiry   = 102
+	Q850Interworking            = 127
+)
+
+// ReleaseCause описывает причину завершения вызова.
+// Формируется из заголовка Reason в BYE/CANCEL (RFC 3326), финальных ответов
+// на INVITE и медиа таймаутов.
+type ReleaseCause struct {
+	Category  ReleaseCategory `json:"category"`           // Обобщенная категория
+	SIPCode   int             `json:"sip_code,omitempty"` // Код SIP ответа, 0 если завершение не связано с ответом
+	Q850Cause int             `json:"q850_cause"`         // Код причины Q.850
+	Text      string          `json:"text,omitempty"`     // Текстовое описание причины
+}
+
+// String возвращает описание причины для логов
+func (c ReleaseCause) String() string {
+	return fmt.Sprintf("%s (sip=%d, q850=%d): %s", c.Category, c.SIPCode, c.Q850Cause, c.Text)
+}
+
+// ReasonHeader создает заголовок Reason (RFC 3326) для BYE или CANCEL.
+// Используется протокол Q.850, если известна причина Q.850, иначе SIP.
+func (c ReleaseCause) ReasonHeader() sip.Header {
+	if c.Q850Cause == 0 {
+		return c.SIPReasonHeader()
+	}
+	return newReasonHeader("Q.850", c.Q850Cause, c.Text)
+}
+
+// SIPReasonHeader создает заголовок Reason с протоколом SIP, например
+// SIP;cause=487 для CANCEL
+func (c ReleaseCause) SIPReasonHeader() sip.Header {
+	return newReasonHeader("SIP", c.SIPCode, c.Text)
+}
+
+func newReasonHeader(protocol string, cause int, text string) sip.Header {
+	value := fmt.Sprintf("%s;cause=%d", protocol, cause)
+	if text != "" {
+		value += fmt.Sprintf(";text=%q", text)
+	}
+	return sip.NewHeader("Reason", value)
+}
+
+// sipCauseMapping описывает отображение кода SIP ответа в причину завершения
+type sipCauseMapping struct {
+	category ReleaseCategory
+	q850     int
+}
+
+// sipToQ850 отображает финальные ответы SIP в причины Q.850 (RFC 3398 Section 8.2.6.1)
+var sipToQ850 = map[int]sipCauseMapping{
+	400: {CategoryError, Q850TemporaryFailure},
+	401: {CategoryRejected, Q850CallRejected},
+	402: {CategoryRejected, Q850CallRejected},
+	403: {CategoryRejected, Q850CallRejected},
+	404: {CategoryUnreachable, Q850UnallocatedNumber},
+	405: {CategoryError, Q850ServiceUnavailable},
+	406: {CategoryError, Q850ServiceNotImplemented},
+	407: {CategoryRejected, Q850CallRejected},
+	408: {CategoryNoAnswer, Q850RecoveryOnTimerExpiry},
+	410: {CategoryUnreachable, Q850NumberChanged},
+	413: {CategoryError, Q850Interworking},
+	414: {CategoryError, Q850Interworking},
+	415: {CategoryMediaError, Q850ServiceNotImplemented},
+	416: {CategoryError, Q850Interworking},
+	420: {CategoryError, Q850Interworking},
+	421: {CategoryError, Q850Interworking},
+	423: {CategoryError, Q850Interworking},
+	480: {CategoryNoAnswer, Q850NoUserResponding},
+	481: {CategoryError, Q850TemporaryFailure},
+	482: {CategoryError, Q850ExchangeRoutingError},
+	483: {CategoryError, Q850ExchangeRoutingError},
+	484: {CategoryUnreachable, Q850InvalidNumberFormat},
+	485: {CategoryUnreachable, Q850UnallocatedNumber},
+	486: {CategoryBusy, Q850UserBusy},
+	487: {CategoryCancelled, Q850NormalUnspecified},
+	488: {CategoryMediaError, Q850IncompatibleDestination},
+	500: {CategoryNetworkError, Q850TemporaryFailure},
+	501: {CategoryNetworkError, Q850ServiceNotImplemented},
+	502: {CategoryNetworkError, Q850NetworkOutOfOrder},
+	503: {CategoryNetworkError, Q850TemporaryFailure},
+	504: {CategoryNetworkError, Q850RecoveryOnTimerExpiry},
+	505: {CategoryError, Q850Interworking},
+	513: {CategoryError, Q850Interworking},
+	600: {CategoryBusy, Q850UserBusy},
+	603: {CategoryRejected, Q850CallRejected},
+	604: {CategoryUnreachable, Q850UnallocatedNumber},
+	606: {CategoryMediaError, Q850BearerNotAvailable},
+}
+
+// q850Texts содержит описания причин Q.850
+var q850Texts = map[int]string{
+	Q850UnallocatedNumber:       "Unallocated number",
+	Q850NormalClearing:          "Normal call clearing",
+	Q850UserBusy:                "User busy",
+	Q850NoUserResponding:        "No user responding",
+	Q850NoAnswer:                "No answer from user",
+	Q850CallRejected:            "Call rejected",
+	Q850NumberChanged:           "Number changed",
+	Q850ExchangeRoutingError:    "Exchange routing error",
+	Q850InvalidNumberFormat:     "Invalid number format",
+	Q850NormalUnspecified:       "Normal, unspecified",
+	Q850NetworkOutOfOrder:       "Network out of order",
+	Q850TemporaryFailure:        "Temporary failure",
+	Q850BearerNotAvailable:      "Bearer capability not presently available",
+	Q850ServiceUnavailable:      "Service or option not available",
+	Q850BearerNotImplemented:    "Bearer capability not implemented",
+	Q850ServiceNotImplemented:   "Service or option not implemented",
+	Q850IncompatibleDestination: "Incompatible destination",
+	Q850RecoveryOnTimerExpiry:   "Recovery on timer expiry",
+	Q850Interworking:            "Interworking, unspecified",
+}
+
+// q850Categories отображает причины Q.850 из заголовка Reason в категории
+var q850Categories = map[int]ReleaseCategory{
+	Q850UnallocatedNumber:       CategoryUnreachable,
+	Q850NormalClearing:          CategoryNormal,
+	Q850UserBusy:                CategoryBusy,
+	Q850NoUserResponding:        CategoryNoAnswer,
+	Q850NoAnswer:                CategoryNoAnswer,
+	Q850CallRejected:            CategoryRejected,
+	Q850NumberChanged:           CategoryUnreachable,
+	Q850InvalidNumberFormat:     CategoryUnreachable,
+	Q850NormalUnspecified:       CategoryNormal,
+	Q850NetworkOutOfOrder:       CategoryNetworkError,
+	Q850TemporaryFailure:        CategoryNetworkError,
+	Q850BearerNotAvailable:      CategoryMediaError,
+	Q850BearerNotImplemented:    CategoryMediaError,
+	Q850IncompatibleDestination: CategoryMediaError,
+	Q850RecoveryOnTimerExpiry:   CategoryNetworkError,
+}
+
+// NormalClearingCause возвращает причину нормального завершения вызова
+func NormalClearingCause() ReleaseCause {
+	return ReleaseCause{
+		Category:  CategoryNormal,
+		Q850Cause: Q850NormalClearing,
+		Text:      q850Texts[Q850NormalClearing],
+	}
+}
+
+// CancelledCause возвращает причину отмены вызова до ответа
+func CancelledCause() ReleaseCause {
+	return ReleaseCause{
+		Category:  CategoryCancelled,
+		SIPCode:   sip.StatusRequestTerminated,
+		Q850Cause: Q850NormalUnspecified,
+		Text:      "Request Terminated",
+	}
+}
+
+// MediaTimeoutCause возвращает причину завершения вызова из-за отсутствия медиа
+func MediaTimeoutCause() ReleaseCause {
+	return ReleaseCause{
+		Category:  CategoryMediaTimeout,
+		Q850Cause: Q850RecoveryOnTimerExpiry,
+		Text:      "Media timeout",
+	}
+}
+
+// CallLimitCause возвращает причину завершения вызова по исчерпании лимита
+// длительности. По сети передается как нормальное завершение (Q.850 cause 16)
+func CallLimitCause() ReleaseCause {
+	return ReleaseCause{
+		Category:  CategoryCallLimit,
+		Q850Cause: Q850NormalClearing,
+		Text:      "Call duration limit reached",
+	}
+}
+
+// ReplacedCause возвращает причину завершения диалога, замененного INVITE
+// с Replaces (например, при подхвате вызова с парковки)
+func ReplacedCause() ReleaseCause {
+	return ReleaseCause{
+		Category:  CategoryReplaced,
+		Q850Cause: Q850NormalClearing,
+		Text:      "Call replaced",
+	}
+}
+
+// TransactionTimeoutCause возвращает причину завершения диалога, запрос
+// внутри которого остался без ответа (таймаут транзакции, RFC 3261 Section 12.2.1.2)
+func TransactionTimeoutCause() ReleaseCause {
+	return ReleaseCause{
+		Category:  CategoryNetworkError,
+		SIPCode:   sip.StatusRequestTimeout,
+		Q850Cause: Q850RecoveryOnTimerExpiry,
+		Text:      "Transaction timeout",
+	}
+}
+
+// NoProvisionalCause возвращает причину завершения вызова, на INVITE
+// которого не получен предварительный ответ за SetupTimeouts.Provisional
+func NoProvisionalCause() ReleaseCause {
+	return ReleaseCause{
+		Category:  CategoryUnreachable,
+		SIPCode:   sip.StatusRequestTimeout,
+		Q850Cause: Q850NoUserResponding,
+		Text:      "No provisional response",
+	}
+}
+
+// NoAnswerCause возвращает причину завершения вызова, не отвеченного за
+// SetupTimeouts.Answer
+func NoAnswerCause() ReleaseCause {
+	return ReleaseCause{
+		Category:  CategoryNoAnswer,
+		SIPCode:   sip.StatusRequestTerminated,
+		Q850Cause: Q850NoAnswer,
+		Text:      q850Texts[Q850NoAnswer],
+	}
+}
+
+// ReleaseCauseFromSIP создает причину завершения из кода финального ответа SIP
+func ReleaseCauseFromSIP(code int, reason string) ReleaseCause {
+	mapping, ok := sipToQ850[code]
+	if !ok {
+		// Неизвестные коды отображаются по классу ответа
+		switch {
+		case code >= 200 && code < 300:
+			mapping = sipCauseMapping{CategoryNormal, Q850NormalClearing}
+		case code >= 500 && code < 600:
+			mapping = sipCauseMapping{CategoryNetworkError, Q850TemporaryFailure}
+		default:
+			mapping = sipCauseMapping{CategoryError, Q850Interworking}
+		}
+	}
+
+	if reason == "" {
+		reason = q850Texts[mapping.q850]
+	}
+	return ReleaseCause{
+		Category:  mapping.category,
+		SIPCode:   code,
+		Q850Cause: mapping.q850,
+		Text:      reason,
+	}
+}
+
+// releaseCauseFromResponse создает причину завершения из финального ответа.
+// Заголовок Reason в ответе имеет приоритет над отображением кода.
+func releaseCauseFromResponse(resp *sip.Response) ReleaseCause {
+	cause := ReleaseCauseFromSIP(resp.StatusCode, resp.Reason)
+	applyReasonHeaders(&cause, resp)
+	return cause
+}
+
+// releaseCauseFromRequest создает причину завершения из заголовков Reason
+// запроса BYE или CANCEL. Без заголовка Reason возвращается fallback.
+func releaseCauseFromRequest(req *sip.Request, fallback ReleaseCause) ReleaseCause {
+	cause := fallback
+	applyReasonHeaders(&cause, req)
+	return cause
+}
+
+// applyReasonHeaders дополняет причину данными из заголовков Reason (RFC 3326).
+// Протокол Q.850 задает категорию и код Q.850, протокол SIP - код SIP.
+func applyReasonHeaders(cause *ReleaseCause, msg sip.Message) {
+	for _, header := range msg.GetHeaders("Reason") {
+		protocol, code, text, ok := parseReasonValue(header.Value())
+		if !ok {
+			continue
+		}
+
+		switch strings.ToUpper(protocol) {
+		case "Q.850":
+			cause.Q850Cause = code
+			if category, known := q850Categories[code]; known {
+				cause.Category = category
+			} else {
+				cause.Category = CategoryError
+			}
+			cause.Text = q850Texts[code]
+		case "SIP":
+			cause.SIPCode = code
+		default:
+			continue
+		}
+
+		if text != "" {
+			cause.Text = text
+		}
+	}
+}
+
+// parseReasonValue разбирает значение заголовка Reason:
+// protocol ;cause=N [;text="..."]
+func parseReasonValue(value string) (protocol string, cause int, text string, ok bool) {
+	parts := splitReasonParams(value)
+	protocol = strings.TrimSpace(parts[0])
+
+	for _, part := range parts[1:] {
+		key, val, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		val = strings.TrimSpace(val)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "cause":
+			code, err := strconv.Atoi(val)
+			if err != nil {
+				return "", 0, "", false
+			}
+			cause = code
+			ok = true
+		case "text":
+			if unquoted, err := strconv.Unquote(val); err == nil {
+				val = unquoted
+			}
+			text = val
+		}
+	}
+
+	return protocol, cause, text, ok && protocol != ""
+}
+
+// splitReasonParams разбивает значение заголовка по ';' вне кавычек
+func splitReasonParams(value string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, value[start:])
+}
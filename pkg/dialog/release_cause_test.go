@@ -0,0 +1,112 @@
+package dialog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseCauseFromSIP(t *testing.T) {
+	tests := []struct {
+		code     int
+		category ReleaseCategory
+		q850     int
+	}{
+		{486, CategoryBusy, Q850UserBusy},
+		{404, CategoryUnreachable, Q850UnallocatedNumber},
+		{480, CategoryNoAnswer, Q850NoUserResponding},
+		{487, CategoryCancelled, Q850NormalUnspecified},
+		{488, CategoryMediaError, Q850IncompatibleDestination},
+		{603, CategoryRejected, Q850CallRejected},
+		{503, CategoryNetworkError, Q850TemporaryFailure},
+		{599, CategoryNetworkError, Q850TemporaryFailure},
+		{499, CategoryError, Q850Interworking},
+	}
+
+	for _, tt := range tests {
+		cause := ReleaseCauseFromSIP(tt.code, "")
+		assert.Equal(t, tt.category, cause.Category, "код %d", tt.code)
+		assert.Equal(t, tt.q850, cause.Q850Cause, "код %d", tt.code)
+		assert.Equal(t, tt.code, cause.SIPCode)
+		assert.NotEmpty(t, cause.Text)
+	}
+
+	assert.Equal(t, "Busy Here", ReleaseCauseFromSIP(486, "Busy Here").Text)
+}
+
+func TestReleaseCauseReasonHeader(t *testing.T) {
+	req := newTestRequest(sip.BYE)
+	req.AppendHeader(sip.NewHeader("Reason", `Q.850;cause=17;text="User busy; try later"`))
+	req.AppendHeader(sip.NewHeader("Reason", "SIP ;cause=486"))
+
+	cause := releaseCauseFromRequest(req, NormalClearingCause())
+	assert.Equal(t, CategoryBusy, cause.Category)
+	assert.Equal(t, 17, cause.Q850Cause)
+	assert.Equal(t, 486, cause.SIPCode)
+	assert.Equal(t, "User busy; try later", cause.Text)
+
+	// Без заголовка Reason используется причина по умолчанию
+	plain := releaseCauseFromRequest(newTestRequest(sip.BYE), NormalClearingCause())
+	assert.Equal(t, NormalClearingCause(), plain)
+
+	// Некорректный заголовок игнорируется
+	broken := newTestRequest(sip.BYE)
+	broken.AppendHeader(sip.NewHeader("Reason", "Q.850;cause=abc"))
+	assert.Equal(t, NormalClearingCause(), releaseCauseFromRequest(broken, NormalClearingCause()))
+
+	// Сформированный заголовок разбирается обратно
+	header := MediaTimeoutCause().ReasonHeader()
+	assert.Equal(t, `Q.850;cause=102;text="Media timeout"`, header.Value())
+	assert.Equal(t, `SIP;cause=487;text="Request Terminated"`, CancelledCause().SIPReasonHeader().Value())
+	assert.Equal(t, "SIP;cause=487", ReleaseCause{SIPCode: 487}.ReasonHeader().Value())
+
+	bye := newTestRequest(sip.BYE)
+	bye.AppendHeader(header)
+	parsed := releaseCauseFromRequest(bye, NormalClearingCause())
+	assert.Equal(t, Q850RecoveryOnTimerExpiry, parsed.Q850Cause)
+	assert.Equal(t, "Media timeout", parsed.Text)
+
+	// Ответ с заголовком Reason
+	resp := sip.NewResponseFromRequest(newTestRequest(sip.INVITE), 603, "Decline", nil)
+	resp.AppendHeader(sip.NewHeader("Reason", "Q.850;cause=21"))
+	fromResp := releaseCauseFromResponse(resp)
+	assert.Equal(t, 603, fromResp.SIPCode)
+	assert.Equal(t, CategoryRejected, fromResp.Category)
+	assert.Equal(t, "Call rejected", fromResp.Text)
+}
+
+func TestDialogReleaseCause(t *testing.T) {
+	uacuas, err := NewUACUAS(Config{
+		UserAgent: "TestUA/1.0",
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 15096},
+		},
+	})
+	require.NoError(t, err)
+	defer uacuas.Stop()
+
+	d, err := uacuas.NewDialog(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, d.ReleaseCause())
+
+	var released []ReleaseCause
+	d.OnRelease(func(cause ReleaseCause) { released = append(released, cause) })
+
+	busy := ReleaseCauseFromSIP(486, "Busy Here")
+	require.NoError(t, d.setStateWithReason(Terminating, nil, StateTransitionReason{Reason: "test", Cause: &busy}))
+	require.NoError(t, d.setStateWithReason(Ended, nil, StateTransitionReason{Reason: "test"}))
+
+	cause := d.ReleaseCause()
+	require.NotNil(t, cause)
+	assert.Equal(t, busy, *cause)
+	assert.Equal(t, []ReleaseCause{busy}, released)
+
+	// Причина доступна в истории переходов
+	last := d.GetLastTransitionReason()
+	require.NotNil(t, last)
+	require.NotNil(t, last.Cause)
+	assert.Equal(t, CategoryBusy, last.Cause.Category)
+}
@@ -0,0 +1,319 @@
+package dialog
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pion/sdp/v3"
+)
+
+// defaultMaxReoffers ограничивает количество повторов INVITE с новым offer
+// по ответам 488, если Config.MaxReoffers не задан
+const defaultMaxReoffers = 2
+
+// Коды Warning о несовместимости SDP (RFC 3261 Section 20.43)
+const (
+	WarningIncompatibleNetworkProtocol = 300
+	WarningIncompatibleAddressFormat   = 301
+	WarningIncompatibleTransport       = 302
+	WarningIncompatibleBandwidthUnits  = 303
+	WarningMediaTypeNotAvailable       = 304
+	WarningIncompatibleMediaFormat     = 305
+	WarningAttributeNotUnderstood      = 306
+	WarningSessionParameterUnknown     = 307
+	WarningMulticastNotAvailable       = 330
+	WarningUnicastNotAvailable         = 331
+	WarningInsufficientBandwidth       = 370
+)
+
+// SIPWarning - значение заголовка Warning: код, агент и текст предупреждения
+type SIPWarning struct {
+	Code  int
+	Agent string
+	Text  string
+}
+
+// NotAcceptable описывает ответ 488 на исходящий INVITE. Вызываемая сторона
+// может указать причину в заголовках Warning и описать свои возможности в
+// SDP теле ответа (RFC 3261 Section 21.4.26)
+type NotAcceptable struct {
+	StatusCode int
+	Reason     string
+	Warnings   []SIPWarning
+	// Capabilities - SDP тело ответа с возможностями вызываемой стороны
+	Capabilities []byte
+	// Offer - отклоненный offer из INVITE
+	Offer []byte
+	// Attempt - номер отказа в вызове, начиная с 1
+	Attempt int
+}
+
+// HasWarning проверяет наличие Warning с кодом code
+func (n *NotAcceptable) HasWarning(code int) bool {
+	for _, w := range n.Warnings {
+		if w.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// ReofferPolicy формирует новый offer по ответу 488 на первичный INVITE.
+// Возвращает тело offer и true, если INVITE нужно повторить
+type ReofferPolicy func(rejection *NotAcceptable) (offer []byte, retry bool)
+
+// ReduceOffer возвращает политику, сокращающую offer до возможностей из SDP
+// ответа 488: в каждой m= строке остаются только форматы, которые есть в
+// одноименной m= строке ответа, транспортный протокол заменяется на
+// протокол ответа (например RTP/SAVP на RTP/AVP без crypto), медиа без общих
+// форматов отклоняются портом 0. Без SDP в ответе offer не повторяется.
+func ReduceOffer() ReofferPolicy {
+	return func(rejection *NotAcceptable) ([]byte, bool) {
+		if len(rejection.Capabilities) == 0 || len(rejection.Offer) == 0 {
+			return nil, false
+		}
+		offer := &sdp.SessionDescription{}
+		if err := offer.Unmarshal(rejection.Offer); err != nil {
+			return nil, false
+		}
+		caps := &sdp.SessionDescription{}
+		if err := caps.Unmarshal(rejection.Capabilities); err != nil {
+			return nil, false
+		}
+
+		changed, active := false, 0
+		used := make(map[int]bool)
+		for _, media := range offer.MediaDescriptions {
+			if media.MediaName.Port.Value == 0 {
+				continue
+			}
+			capability := matchCapability(caps, media.MediaName.Media, used)
+			if reduceMedia(media, capability) {
+				changed = true
+			}
+			if media.MediaName.Port.Value != 0 {
+				active++
+			}
+		}
+		if !changed || active == 0 {
+			return nil, false
+		}
+
+		// Измененный offer - новая версия сессии (RFC 3264 Section 8)
+		offer.Origin.SessionVersion++
+		body, err := offer.Marshal()
+		if err != nil {
+			return nil, false
+		}
+		return body, true
+	}
+}
+
+// matchCapability возвращает первую еще не сопоставленную m= строку ответа
+// с тем же типом медиа
+func matchCapability(caps *sdp.SessionDescription, mediaType string, used map[int]bool) *sdp.MediaDescription {
+	for i, media := range caps.MediaDescriptions {
+		if !used[i] && media.MediaName.Media == mediaType {
+			used[i] = true
+			return media
+		}
+	}
+	return nil
+}
+
+// reduceMedia оставляет в m= строке offer форматы и протокол из capability.
+// Возвращает true, если m= строка изменена. Порт m= строки возможностей не
+// учитывается: как и в ответе на OPTIONS, он может быть 0 (RFC 3261 Section 11.2)
+func reduceMedia(media, capability *sdp.MediaDescription) bool {
+	if capability == nil {
+		media.MediaName.Port = sdp.RangedPort{Value: 0}
+		return true
+	}
+
+	supported := make(map[string]bool)
+	for _, format := range capability.MediaName.Formats {
+		supported[formatKey(capability, format)] = true
+	}
+	var formats []string
+	removed := make(map[string]bool)
+	codecs := 0
+	for _, format := range media.MediaName.Formats {
+		if !supported[formatKey(media, format)] {
+			removed[format] = true
+			continue
+		}
+		formats = append(formats, format)
+		if !strings.HasPrefix(formatKey(media, format), "telephone-event/") {
+			codecs++
+		}
+	}
+	if codecs == 0 {
+		media.MediaName.Port = sdp.RangedPort{Value: 0}
+		return true
+	}
+
+	changed := len(removed) > 0
+	media.MediaName.Formats = formats
+
+	proto := strings.Join(capability.MediaName.Protos, "/")
+	if proto != strings.Join(media.MediaName.Protos, "/") {
+		media.MediaName.Protos = capability.MediaName.Protos
+		changed = true
+	}
+
+	attributes := media.Attributes[:0]
+	for _, attr := range media.Attributes {
+		switch attr.Key {
+		case "rtpmap", "fmtp":
+			format, _, _ := strings.Cut(attr.Value, " ")
+			if removed[format] {
+				continue
+			}
+		case "crypto":
+			if !strings.Contains(proto, "SAVP") || strings.Contains(proto, "TLS") {
+				continue
+			}
+		case "fingerprint", "setup":
+			if !strings.Contains(proto, "TLS") {
+				continue
+			}
+		}
+		attributes = append(attributes, attr)
+	}
+	media.Attributes = attributes
+	return changed
+}
+
+// formatKey возвращает ключ сопоставления формата: номер для статических
+// payload types и кодировку rtpmap для динамических
+func formatKey(media *sdp.MediaDescription, format string) string {
+	pt, err := strconv.Atoi(format)
+	if err == nil && pt < 96 {
+		return format
+	}
+	for _, attr := range media.Attributes {
+		if attr.Key != "rtpmap" {
+			continue
+		}
+		if value, ok := strings.CutPrefix(attr.Value, format+" "); ok {
+			name, rate, _ := strings.Cut(strings.ToLower(strings.TrimSpace(value)), "/")
+			rate, _, _ = strings.Cut(rate, "/")
+			return name + "/" + rate
+		}
+	}
+	return format
+}
+
+// parseWarnings разбирает заголовки Warning:
+// warn-code SP warn-agent SP "warn-text", значения через запятую
+func parseWarnings(msg sip.Message) []SIPWarning {
+	var warnings []SIPWarning
+	for _, h := range msg.GetHeaders("Warning") {
+		value := h.Value()
+		for value != "" {
+			var entry string
+			entry, value = cutWarning(value)
+			fields := strings.SplitN(strings.TrimSpace(entry), " ", 3)
+			if len(fields) < 2 {
+				continue
+			}
+			code, err := strconv.Atoi(fields[0])
+			if err != nil {
+				continue
+			}
+			warning := SIPWarning{Code: code, Agent: fields[1]}
+			if len(fields) == 3 {
+				warning.Text = strings.Trim(strings.TrimSpace(fields[2]), `"`)
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// cutWarning отделяет первое значение Warning по запятой вне кавычек
+func cutWarning(value string) (entry, rest string) {
+	quoted := false
+	for i, c := range value {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			return value[:i], value[i+1:]
+		}
+	}
+	return value, ""
+}
+
+// OnNotAcceptable устанавливает обработчик ответов 488 на исходящий INVITE.
+// Обработчик вызывается до применения Config.ReofferPolicy.
+// Метод потокобезопасен.
+func (s *Dialog) OnNotAcceptable(handler func(rejection *NotAcceptable)) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.notAcceptableHandler = handler
+}
+
+// processNotAcceptable обрабатывает ответ 488 на первичный INVITE. Возвращает
+// true, если INVITE повторен с новым offer и диалог продолжается.
+func (s *Dialog) processNotAcceptable(invite *sip.Request, resp *sip.Response) bool {
+	rejection := &NotAcceptable{
+		StatusCode: resp.StatusCode,
+		Reason:     resp.Reason,
+		Warnings:   parseWarnings(resp),
+		Offer:      invite.Body(),
+		Attempt:    int(s.reoffers.Load()) + 1,
+	}
+	if body := extractBody(resp); body != nil && strings.HasPrefix(body.ContentType(), "application/sdp") {
+		rejection.Capabilities = body.Content()
+	}
+
+	s.handlersMu.Lock()
+	handler := s.notAcceptableHandler
+	s.handlersMu.Unlock()
+	if handler != nil {
+		handler(rejection)
+	}
+
+	if s.uu == nil || s.uu.config.ReofferPolicy == nil {
+		return false
+	}
+	maxReoffers := s.uu.config.MaxReoffers
+	if maxReoffers <= 0 {
+		maxReoffers = defaultMaxReoffers
+	}
+	if rejection.Attempt > maxReoffers {
+		slog.Warn("Превышено количество повторов INVITE с новым offer",
+			slog.String("dialogID", s.ID()),
+			slog.Int("maxReoffers", maxReoffers))
+		return false
+	}
+
+	offer, retry := s.uu.config.ReofferPolicy(rejection)
+	if !retry || len(offer) == 0 {
+		return false
+	}
+	s.reoffers.Add(1)
+
+	// Новый CSeq назначается в sendReq, адресат и путь INVITE не меняются
+	req := newRedirectedInvite(invite, invite.Recipient)
+	req.SetDestination(invite.Destination())
+	req.SetBody(offer)
+
+	if err := s.resendInvite(req); err != nil {
+		slog.Error("Не удалось повторить INVITE с новым offer",
+			slog.String("dialogID", s.ID()),
+			slog.String("error", err.Error()))
+		return false
+	}
+	if contentType := req.ContentType(); contentType != nil {
+		s.SetLocalSDP(contentType.Value(), offer)
+	}
+
+	slog.Info("INVITE повторен с новым offer",
+		slog.String("dialogID", s.ID()),
+		slog.Int("attempt", rejection.Attempt))
+	return true
+}
@@ -0,0 +1,177 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pion/sdp/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reofferOffer = "v=0\r\n" +
+	"o=- 100 1 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 127.0.0.1\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 40000 RTP/SAVP 0 8 96 101\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=rtpmap:8 PCMA/8000\r\n" +
+	"a=rtpmap:96 opus/48000/2\r\n" +
+	"a=rtpmap:101 telephone-event/8000\r\n" +
+	"a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR\r\n" +
+	"a=sendrecv\r\n" +
+	"m=video 40002 RTP/SAVP 97\r\n" +
+	"a=rtpmap:97 H264/90000\r\n"
+
+const reofferCapabilities = "v=0\r\n" +
+	"o=- 200 1 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 127.0.0.1\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 0 RTP/AVP 8 100\r\n" +
+	"a=rtpmap:100 telephone-event/8000\r\n"
+
+func TestReduceOffer(t *testing.T) {
+	offer, retry := ReduceOffer()(&NotAcceptable{
+		Offer:        []byte(reofferOffer),
+		Capabilities: []byte(reofferCapabilities),
+	})
+	require.True(t, retry)
+
+	reduced := &sdp.SessionDescription{}
+	require.NoError(t, reduced.Unmarshal(offer))
+	assert.Equal(t, uint64(2), reduced.Origin.SessionVersion, "Новый offer - новая версия o=")
+	require.Len(t, reduced.MediaDescriptions, 2)
+
+	audio := reduced.MediaDescriptions[0]
+	assert.Equal(t, []string{"8", "101"}, audio.MediaName.Formats, "Остаются общие кодеки и telephone-event")
+	assert.Equal(t, "RTP/AVP", strings.Join(audio.MediaName.Protos, "/"))
+	assert.Equal(t, 40000, audio.MediaName.Port.Value)
+	_, hasCrypto := audio.Attribute("crypto")
+	assert.False(t, hasCrypto, "crypto не используется с RTP/AVP")
+	rtpmap, _ := audio.Attribute("rtpmap")
+	assert.Equal(t, "8 PCMA/8000", rtpmap)
+	_, hasDirection := audio.Attribute("sendrecv")
+	assert.True(t, hasDirection)
+
+	assert.Equal(t, 0, reduced.MediaDescriptions[1].MediaName.Port.Value, "Видео без возможностей отклоняется")
+
+	// Без общих кодеков, без SDP в ответе или без изменений offer не повторяется
+	_, retry = ReduceOffer()(&NotAcceptable{
+		Offer:        []byte(reofferOffer),
+		Capabilities: []byte(strings.Replace(reofferCapabilities, "RTP/AVP 8 100", "RTP/AVP 18", 1)),
+	})
+	assert.False(t, retry)
+	_, retry = ReduceOffer()(&NotAcceptable{Offer: []byte(reofferOffer)})
+	assert.False(t, retry)
+	_, retry = ReduceOffer()(&NotAcceptable{Offer: offer, Capabilities: []byte(reofferCapabilities)})
+	assert.False(t, retry)
+}
+
+func TestParseWarnings(t *testing.T) {
+	resp := sip.NewResponseFromRequest(newTestRequest(sip.INVITE), sip.StatusNotAcceptableHere, "Not Acceptable Here", nil)
+	resp.AppendHeader(sip.NewHeader("Warning", `305 gw.example.com "Incompatible media format, try PCMA", 302 gw.example.com "Incompatible transport"`))
+	resp.AppendHeader(sip.NewHeader("Warning", `370 10.0.0.1:5060 "Insufficient bandwidth"`))
+
+	warnings := parseWarnings(resp)
+	require.Len(t, warnings, 3)
+	assert.Equal(t, SIPWarning{Code: 305, Agent: "gw.example.com", Text: "Incompatible media format, try PCMA"}, warnings[0])
+	assert.Equal(t, WarningIncompatibleTransport, warnings[1].Code)
+	assert.Equal(t, "10.0.0.1:5060", warnings[2].Agent)
+
+	rejection := &NotAcceptable{Warnings: warnings}
+	assert.True(t, rejection.HasWarning(WarningInsufficientBandwidth))
+	assert.False(t, rejection.HasWarning(WarningMediaTypeNotAvailable))
+}
+
+// TestReofferOnNotAcceptable проверяет повтор первичного INVITE с новым
+// offer по ответу 488 и ограничение количества повторов
+func TestReofferOnNotAcceptable(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	peerPort := peer.LocalAddr().(*net.UDPAddr).Port
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15103}},
+		ReofferPolicy:    ReduceOffer(),
+		MaxReoffers:      1,
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	read := func() (*sip.Request, *net.UDPAddr) {
+		buf := make([]byte, 8192)
+		require.NoError(t, peer.SetReadDeadline(time.Now().Add(3*time.Second)))
+		for {
+			n, from, err := peer.ReadFromUDP(buf)
+			require.NoError(t, err, "ожидался INVITE")
+			msg, err := sip.ParseMessage(buf[:n])
+			require.NoError(t, err)
+			if req, ok := msg.(*sip.Request); ok && req.Method == sip.INVITE {
+				return req, from
+			}
+		}
+	}
+	reject := func(req *sip.Request, from *net.UDPAddr) {
+		resp := sip.NewResponseFromRequest(req, sip.StatusNotAcceptableHere, "Not Acceptable Here", []byte(reofferCapabilities))
+		resp.To().Params.Add("tag", "peertag")
+		resp.AppendHeader(sip.NewHeader("Content-Type", "application/sdp"))
+		resp.AppendHeader(sip.NewHeader("Warning", `305 peer "Incompatible media format"`))
+		_, err := peer.WriteToUDP([]byte(resp.String()), from)
+		require.NoError(t, err)
+	}
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	rejections := make(chan *NotAcceptable, 2)
+	d.OnNotAcceptable(func(rejection *NotAcceptable) { rejections <- rejection })
+	ended := make(chan struct{})
+	d.OnStateChange(func(state DialogState) {
+		if state == Ended {
+			close(ended)
+		}
+	})
+
+	_, err = d.Start(ctx, fmt.Sprintf("sip:bob@127.0.0.1:%d", peerPort), WithSDP(reofferOffer))
+	require.NoError(t, err)
+
+	first, from := read()
+	reject(first, from)
+
+	second, from := read()
+	assert.Equal(t, first.CallID().Value(), second.CallID().Value())
+	assert.Equal(t, first.From().Value(), second.From().Value())
+	assert.Greater(t, second.CSeq().SeqNo, first.CSeq().SeqNo)
+	assert.NotEqual(t, first.Via().Params["branch"], second.Via().Params["branch"])
+	assert.Contains(t, string(second.Body()), "m=audio 40000 RTP/AVP 8 101")
+	local := d.LocalSDP()
+	assert.Contains(t, string(local.Content()), "RTP/AVP 8 101")
+	assert.Equal(t, Calling, d.State(), "Диалог продолжается после повтора")
+
+	rejection := <-rejections
+	assert.Equal(t, 1, rejection.Attempt)
+	assert.True(t, rejection.HasWarning(WarningIncompatibleMediaFormat))
+	assert.Equal(t, reofferCapabilities, string(rejection.Capabilities))
+
+	// Второй отказ превышает MaxReoffers - вызов завершается
+	reject(second, from)
+	select {
+	case <-ended:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Диалог должен завершиться после превышения MaxReoffers")
+	}
+	assert.Equal(t, 2, (<-rejections).Attempt)
+}
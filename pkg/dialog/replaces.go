@@ -0,0 +1,140 @@
+package dialog
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// ReplacesInfo - значение заголовка Replaces (RFC 3891): идентификатор
+// диалога, который заменяется новым вызовом. Теги указываются с точки
+// зрения получателя INVITE: to-tag - его локальный тег, from-tag - удаленный.
+type ReplacesInfo struct {
+	CallID  string
+	ToTag   string
+	FromTag string
+	// EarlyOnly - параметр early-only: заменять только диалог, на который
+	// еще не ответили
+	EarlyOnly bool
+}
+
+// String формирует значение заголовка Replaces
+func (r ReplacesInfo) String() string {
+	builder := strings.Builder{}
+	builder.WriteString(r.CallID)
+	builder.WriteString(";to-tag=")
+	builder.WriteString(r.ToTag)
+	builder.WriteString(";from-tag=")
+	builder.WriteString(r.FromTag)
+	if r.EarlyOnly {
+		builder.WriteString(";early-only")
+	}
+	return builder.String()
+}
+
+// ParseReplaces разбирает значение заголовка Replaces
+func ParseReplaces(value string) (ReplacesInfo, error) {
+	parts := strings.Split(value, ";")
+	info := ReplacesInfo{CallID: strings.TrimSpace(parts[0])}
+	if info.CallID == "" {
+		return ReplacesInfo{}, fmt.Errorf("replaces without call-id")
+	}
+
+	for _, part := range parts[1:] {
+		name, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "to-tag":
+			info.ToTag = strings.TrimSpace(val)
+		case "from-tag":
+			info.FromTag = strings.TrimSpace(val)
+		case "early-only":
+			info.EarlyOnly = true
+		}
+	}
+	if info.ToTag == "" || info.FromTag == "" {
+		return ReplacesInfo{}, fmt.Errorf("replaces without to-tag or from-tag: %q", value)
+	}
+	return info, nil
+}
+
+// ReplacesFor возвращает значение Replaces для замены диалога d вызовом,
+// отправленным удаленной стороне d (например, для подхвата вызова с парковки).
+func ReplacesFor(d IDialog) ReplacesInfo {
+	return ReplacesInfo{
+		CallID:  string(d.CallID()),
+		ToTag:   d.RemoteTag(),
+		FromTag: d.LocalTag(),
+	}
+}
+
+// WithReplaces добавляет к INVITE заголовок Replaces и тег replaces в Require
+func WithReplaces(info ReplacesInfo) RequestOpt {
+	return func(msg sip.Message) {
+		msg.AppendHeader(sip.NewHeader("Replaces", info.String()))
+		msg.AppendHeader(sip.NewHeader("Require", "replaces"))
+	}
+}
+
+// ReplacedDialog возвращает диалог, который заменяет входящий INVITE с
+// заголовком Replaces, или nil. Замененный диалог завершается BYE после
+// перехода нового диалога в InCall.
+func (s *Dialog) ReplacedDialog() IDialog {
+	if s.replaces == nil {
+		return nil
+	}
+	return s.replaces
+}
+
+// findReplacedDialog ищет диалог из заголовка Replaces входящего INVITE.
+// Возвращает nil и код ответа, если INVITE нужно отклонить (RFC 3891 Section 3).
+func (u *UACUAS) findReplacedDialog(req *sip.Request) (*Dialog, int) {
+	h := req.GetHeader("Replaces")
+	if h == nil {
+		return nil, 0
+	}
+	if !u.capabilities.Has(FeatureReplaces) {
+		return nil, sip.StatusBadExtension
+	}
+
+	info, err := ParseReplaces(h.Value())
+	if err != nil {
+		return nil, sip.StatusBadRequest
+	}
+
+	replaced, ok := u.dialogs.Get(sip.CallIDHeader(info.CallID), info.ToTag)
+	if !ok || replaced.RemoteTag() != info.FromTag {
+		return nil, sip.StatusCallTransactionDoesNotExists
+	}
+
+	// Замена ранних диалогов не поддерживается: заменяется только
+	// установленный вызов
+	if replaced.State() != InCall {
+		return nil, sip.StatusCallTransactionDoesNotExists
+	}
+	if info.EarlyOnly {
+		return nil, sip.StatusBusyHere
+	}
+
+	return replaced, 0
+}
+
+// completeReplaces завершает диалог, замененный текущим, после ответа на
+// INVITE с Replaces
+func (s *Dialog) completeReplaces() {
+	replaced := s.replaces
+	if replaced == nil || replaced.State() != InCall {
+		return
+	}
+
+	slog.Info("Диалог заменен новым вызовом",
+		slog.String("dialogID", s.ID()),
+		slog.String("replacedDialogID", replaced.ID()))
+
+	if err := replaced.TerminateWithCause(ReplacedCause()); err != nil {
+		slog.Error("Не удалось завершить замененный диалог",
+			slog.String("dialogID", replaced.ID()),
+			slog.String("error", err.Error()))
+	}
+}
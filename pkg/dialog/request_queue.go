@@ -0,0 +1,144 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+)
+
+// ErrRequestCanceled возвращается запросу, удаленному из очереди диалога до
+// отправки: CancelQueuedRequest, отмена контекста или завершение диалога
+var ErrRequestCanceled = errors.New("запрос отменен в очереди диалога")
+
+// QueuedRequest описывает запрос в очереди диалога. Re-INVITE, UPDATE и
+// REFER установленного диалога отправляются по одному: следующий уходит после
+// финального ответа на предыдущий (RFC 3261 Section 14.1 запрещает новый
+// INVITE, пока не завершен предыдущий). Запросы раннего диалога, в том числе
+// начальный INVITE и UPDATE до ответа 200 (RFC 3311), очередь не проходят
+type QueuedRequest struct {
+	ID       uint64
+	Method   sip.RequestMethod
+	Position int // 0 - запрос отправлен и ждет финального ответа, 1.. - место в очереди
+	Enqueued time.Time
+}
+
+// queueEntry - запрос в очереди
+type queueEntry struct {
+	id       uint64
+	method   sip.RequestMethod
+	enqueued time.Time
+	ready    chan struct{} // закрывается, когда запрос может быть отправлен
+	err      error         // причина удаления из очереди
+}
+
+// requestQueue сериализует клиентские транзакции диалога, которые не могут
+// выполняться одновременно
+type requestQueue struct {
+	mu      sync.Mutex
+	nextID  uint64
+	active  *queueEntry
+	waiting []*queueEntry
+}
+
+// isSerializedMethod сообщает, проходит ли метод через очередь диалога
+func isSerializedMethod(method sip.RequestMethod) bool {
+	return method == sip.INVITE || method == sip.UPDATE || method == sip.REFER
+}
+
+// acquire ставит запрос в очередь и ждет, пока он станет активным
+func (q *requestQueue) acquire(ctx context.Context, method sip.RequestMethod) (*queueEntry, error) {
+	q.mu.Lock()
+	q.nextID++
+	entry := &queueEntry{id: q.nextID, method: method, enqueued: time.Now(), ready: make(chan struct{})}
+	if q.active == nil {
+		q.active = entry
+		close(entry.ready)
+	} else {
+		q.waiting = append(q.waiting, entry)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		q.remove(entry.id, ctx.Err())
+		<-entry.ready
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return entry, nil
+}
+
+// release завершает активный запрос и пропускает следующий
+func (q *requestQueue) release(entry *queueEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active != entry {
+		return
+	}
+	q.active = nil
+	if len(q.waiting) > 0 {
+		q.active = q.waiting[0]
+		q.waiting = q.waiting[1:]
+		close(q.active.ready)
+	}
+}
+
+// remove удаляет ожидающий запрос из очереди. Возвращает false, если запрос
+// не найден или уже отправлен
+func (q *requestQueue) remove(id uint64, cause error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, entry := range q.waiting {
+		if entry.id == id {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			entry.err = errors.Wrap(ErrRequestCanceled, fmt.Sprintf("%s: %v", entry.method, cause))
+			close(entry.ready)
+			return true
+		}
+	}
+	return false
+}
+
+// cancelWaiting удаляет из очереди все ожидающие запросы
+func (q *requestQueue) cancelWaiting(cause string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, entry := range q.waiting {
+		entry.err = errors.Wrap(ErrRequestCanceled, fmt.Sprintf("%s: %s", entry.method, cause))
+		close(entry.ready)
+	}
+	q.waiting = nil
+}
+
+// snapshot возвращает активный и ожидающие запросы по порядку
+func (q *requestQueue) snapshot() []QueuedRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make([]QueuedRequest, 0, len(q.waiting)+1)
+	if q.active != nil {
+		result = append(result, QueuedRequest{ID: q.active.id, Method: q.active.method, Enqueued: q.active.enqueued})
+	}
+	for i, entry := range q.waiting {
+		result = append(result, QueuedRequest{ID: entry.id, Method: entry.method, Position: i + 1, Enqueued: entry.enqueued})
+	}
+	return result
+}
+
+// QueuedRequests возвращает запросы очереди диалога: отправленный и ждущий
+// финального ответа (Position 0) и ожидающие отправки по порядку
+func (s *Dialog) QueuedRequests() []QueuedRequest {
+	return s.requests.snapshot()
+}
+
+// CancelQueuedRequest удаляет ожидающий запрос из очереди диалога. Вызов,
+// поставивший запрос, получает ErrRequestCanceled. Возвращает false, если
+// запрос уже отправлен или не найден
+func (s *Dialog) CancelQueuedRequest(id uint64) bool {
+	return s.requests.remove(id, errors.New("отменен приложением"))
+}
@@ -0,0 +1,131 @@
+package dialog
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRequestQueue проверяет, что UPDATE, REFER и re-INVITE уходят по одному,
+// очередь показывает позиции, а ожидающий запрос можно отменить
+func TestRequestQueue(t *testing.T) {
+	remote, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer remote.Close()
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15098}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	d.remoteTarget = sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: remote.LocalAddr().(*net.UDPAddr).Port}
+	// Очередь работает в установленном диалоге
+	require.NoError(t, d.setState(Calling, nil))
+	require.NoError(t, d.setState(InCall, nil))
+
+	read := func() (*sip.Request, *net.UDPAddr) {
+		buf := make([]byte, 4096)
+		require.NoError(t, remote.SetReadDeadline(time.Now().Add(3*time.Second)))
+		for {
+			n, from, err := remote.ReadFromUDP(buf)
+			require.NoError(t, err)
+			msg, err := sip.ParseMessage(buf[:n])
+			require.NoError(t, err)
+			if req, ok := msg.(*sip.Request); ok {
+				return req, from
+			}
+		}
+	}
+	waitQueue := func(n int) []QueuedRequest {
+		require.Eventually(t, func() bool { return len(d.QueuedRequests()) == n }, 2*time.Second, 5*time.Millisecond)
+		return d.QueuedRequests()
+	}
+
+	_, err = d.SendRequest(ctx, withMethod(sip.UPDATE))
+	require.NoError(t, err)
+	update, from := read()
+	assert.Equal(t, sip.UPDATE, update.Method)
+
+	referDone := make(chan error, 1)
+	go func() {
+		_, err := d.SendRequest(ctx, withMethod(sip.REFER))
+		referDone <- err
+	}()
+	waitQueue(2)
+
+	inviteDone := make(chan error, 1)
+	go func() {
+		_, err := d.SendRequest(ctx, withMethod(sip.INVITE))
+		inviteDone <- err
+	}()
+	queue := waitQueue(3)
+	assert.Equal(t, []sip.RequestMethod{sip.UPDATE, sip.REFER, sip.INVITE},
+		[]sip.RequestMethod{queue[0].Method, queue[1].Method, queue[2].Method})
+	assert.Equal(t, []int{0, 1, 2}, []int{queue[0].Position, queue[1].Position, queue[2].Position})
+
+	// Ожидающий re-INVITE отменяется, REFER не уходит до ответа на UPDATE
+	require.True(t, d.CancelQueuedRequest(queue[2].ID))
+	assert.ErrorIs(t, <-inviteDone, ErrRequestCanceled)
+	assert.False(t, d.CancelQueuedRequest(queue[0].ID), "Отправленный запрос не отменяется")
+	select {
+	case err := <-referDone:
+		t.Fatalf("REFER отправлен до ответа на UPDATE: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	resp := sip.NewResponseFromRequest(update, sip.StatusOK, "OK", nil)
+	_, err = remote.WriteToUDP([]byte(resp.String()), from)
+	require.NoError(t, err)
+
+	require.NoError(t, <-referDone)
+	refer, _ := read()
+	assert.Equal(t, sip.REFER, refer.Method)
+	assert.Greater(t, refer.CSeq().SeqNo, update.CSeq().SeqNo)
+
+	queue = waitQueue(1)
+	assert.Equal(t, sip.REFER, queue[0].Method)
+}
+
+// TestEarlyUpdateBypassesInitialInvite проверяет, что UPDATE раннего диалога
+// (RFC 3311) уходит после 180 до финального ответа на начальный INVITE
+func TestEarlyUpdateBypassesInitialInvite(t *testing.T) {
+	bob := newRegistrarPeer(t, "bob")
+	d, _ := startSetupTimeoutCall(t, 15112, Config{}, bob)
+
+	msg, from := bob.read(matchRequest(sip.INVITE))
+	invite := msg.(*sip.Request)
+	respond(bob, invite, sip.StatusRinging, "Ringing", from)
+	require.Eventually(t, func() bool { return len(d.EarlyDialogs()) == 1 }, 2*time.Second, 5*time.Millisecond)
+	assert.Empty(t, d.QueuedRequests(), "Начальный INVITE не должен занимать очередь диалога")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	updateDone := make(chan error, 1)
+	go func() {
+		_, err := d.SendRequest(ctx, withMethod(sip.UPDATE))
+		updateDone <- err
+	}()
+
+	msg, _ = bob.read(matchRequest(sip.UPDATE))
+	update := msg.(*sip.Request)
+	require.NoError(t, <-updateDone)
+	assert.Greater(t, update.CSeq().SeqNo, invite.CSeq().SeqNo)
+	bob.send(sip.NewResponseFromRequest(update, sip.StatusOK, "OK", nil), from)
+
+	respond(bob, invite, sip.StatusOK, "OK", from)
+	require.Eventually(t, func() bool { return d.State() == InCall }, 2*time.Second, 5*time.Millisecond)
+}
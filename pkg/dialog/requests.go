@@ -28,7 +28,9 @@ func (s *Dialog) Invite(ctx context.Context, target string, opts ...RequestOpt)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse target URI")
 	}
+	s.uriMu.Lock()
 	s.remoteTarget = targetURI
+	s.uriMu.Unlock()
 
 	// сначала устаниавливаем все данные
 
@@ -39,10 +41,8 @@ func (s *Dialog) Invite(ctx context.Context, target string, opts ...RequestOpt)
 		opt(req)
 	}
 
-	fmt.Println("target", req.String())
-
 	{
-		slog.Debug("session.Invite", slog.String("request", req.String()), slog.String("body", string(req.Body())))
+		slog.Debug("session.Invite", slog.String("request", s.uu.redact(req.String())), slog.String("body", s.uu.redact(string(req.Body()))))
 	}
 
 	return s.sendReq(ctx, req)
@@ -51,6 +51,9 @@ func (s *Dialog) Invite(ctx context.Context, target string, opts ...RequestOpt)
 // ReInvite отправляет re-INVITE запрос для изменения параметров существующего диалога.
 // Может использоваться для изменения кодеков, добавления/удаления медиа потоков и т.д.
 // Возвращает клиентскую транзакцию для отслеживания ответов.
+// Если в диалоге уже выполняется re-INVITE, UPDATE или REFER, запрос ждет в
+// очереди финального ответа на него (QueuedRequests); отмена ctx удаляет
+// запрос из очереди с ошибкой ErrRequestCanceled.
 func (s *Dialog) ReInvite(ctx context.Context, opts ...RequestOpt) (IClientTX, error) {
 	// Проверяем, что диалог в правильном состоянии
 	if s.State() != InCall {
@@ -77,9 +80,70 @@ func (s *Dialog) ReInvite(ctx context.Context, opts ...RequestOpt) (IClientTX, e
 	return tx, nil
 }
 
+// DoRequest отправляет в рамках диалога запрос с произвольным методом, в том
+// числе с методом, который диалог не моделирует (например, проприетарным).
+// Запрос формируется как остальные запросы диалога: From и To с тегами,
+// Call-ID, следующий CSeq, Contact и route set (RFC 3261 Section 12.2.1.1),
+// после чего применяются opts. Ответы доступны через Responses() возвращаемой
+// транзакции, отмена ctx прекращает ожидание.
+//
+// INVITE, ACK, CANCEL и BYE меняют состояние диалога или транзакции и
+// отправляются через ReInvite, Bye/Terminate и Cancel транзакции, для них
+// возвращается ErrReservedMethod. UPDATE и REFER, как и в ReInvite, ждут
+// финального ответа на предыдущий такой запрос в очереди диалога.
+func (s *Dialog) DoRequest(ctx context.Context, method sip.RequestMethod, opts ...RequestOpt) (IClientTX, error) {
+	if !isMethodToken(string(method)) {
+		return nil, fmt.Errorf("некорректный метод запроса %q", method)
+	}
+	switch method {
+	case sip.INVITE, sip.ACK, sip.CANCEL, sip.BYE:
+		return nil, errors.Wrapf(ErrReservedMethod, "%s", method)
+	}
+	if state := s.State(); state == Ended || s.RemoteTag() == "" {
+		return nil, fmt.Errorf("%s отправляется только в установленном диалоге, текущее состояние: %s", method, state)
+	}
+
+	req := s.makeRequest(method)
+	for _, opt := range opts {
+		opt(req)
+	}
+	// Опции не меняют метод: CSeq должен совпадать с методом запроса
+	req.Method = method
+	if h := req.CSeq(); h != nil {
+		h.MethodName = method
+	}
+
+	slog.Debug("Dialog.DoRequest",
+		slog.String("dialogID", s.ID()),
+		slog.String("method", string(method)),
+		slog.String("request", s.uu.redact(req.String())))
+
+	tx, err := s.sendReq(ctx, req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "не удалось отправить %s", method)
+	}
+	return tx, nil
+}
+
+// isMethodToken проверяет, что метод является token (RFC 3261 Section 25.1)
+func isMethodToken(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range method {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-.!%*_+`'~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // sendBye отправляет BYE запрос и переводит диалог в состояние Terminating.
 // Это приватный метод, используемый как в Bye(), так и в Terminate().
-func (s *Dialog) sendBye(ctx context.Context) (*TX, error) {
+func (s *Dialog) sendBye(ctx context.Context, cause *ReleaseCause) (*TX, error) {
 	// Проверяем состояние диалога
 	currentState := s.State()
 	if currentState != InCall {
@@ -89,6 +153,17 @@ func (s *Dialog) sendBye(ctx context.Context) (*TX, error) {
 	// Создаем BYE запрос
 	req := s.makeRequest(sip.BYE)
 
+	// Явно указанная причина всегда передается удаленной стороне (RFC 3326),
+	// причина по умолчанию - если включено в конфигурации
+	sendReason := cause != nil || s.uu.config.SendReasonHeader
+	if cause == nil {
+		normal := NormalClearingCause()
+		cause = &normal
+	}
+	if sendReason {
+		req.AppendHeader(cause.ReasonHeader())
+	}
+
 	// Отправляем запрос
 	tx, err := s.sendReq(ctx, req)
 	if err != nil {
@@ -100,6 +175,7 @@ func (s *Dialog) sendBye(ctx context.Context) (*TX, error) {
 		Reason:  "BYE request sent",
 		Method:  sip.BYE,
 		Details: "User initiated call termination",
+		Cause:   cause,
 	}
 	if err := s.setStateWithReason(Terminating, tx, reason); err != nil {
 		return nil, errors.Wrap(err, "не удалось изменить состояние")
@@ -112,7 +188,7 @@ func (s *Dialog) sendBye(ctx context.Context) (*TX, error) {
 // Этот метод является альтернативой методу Terminate().
 func (s *Dialog) Bye(ctx context.Context) error {
 	// Отправляем BYE и получаем транзакцию
-	tx, err := s.sendBye(ctx)
+	tx, err := s.sendBye(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -153,17 +229,11 @@ var (
 	ErrTagToNotFount = errors.New("tag to not found")
 	// ErrTagFromNotFount ошибка при отсутствии тега from
 	ErrTagFromNotFount = errors.New("tag from not found")
+	// ErrReservedMethod возвращается DoRequest для методов, которые
+	// отправляются собственными методами диалога (INVITE, ACK, CANCEL, BYE)
+	ErrReservedMethod = errors.New("метод отправляется отдельным методом диалога")
 )
 
-func (s *Dialog) sendAckWithoutTX() error {
-	req := s.makeRequest(sip.ACK)
-	err := s.uu.writeMsg(req)
-	if err != nil {
-		slog.Debug("failed to send ack", "error", err)
-	}
-	return nil
-}
-
 func createReferByHeader(contact sip.Uri) sip.Header {
 	builder := strings.Builder{}
 
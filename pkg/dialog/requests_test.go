@@ -0,0 +1,110 @@
+package dialog
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDoRequest проверяет отправку запроса с проприетарным методом: route
+// set, CSeq с тем же методом, номер из WithCSeq и доставку ответа в транзакцию
+func TestDoRequest(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	peerURI := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: peer.LocalAddr().(*net.UDPAddr).Port}
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15104}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	read := func(method sip.RequestMethod) (*sip.Request, *net.UDPAddr) {
+		buf := make([]byte, 4096)
+		require.NoError(t, peer.SetReadDeadline(time.Now().Add(3*time.Second)))
+		for {
+			n, from, err := peer.ReadFromUDP(buf)
+			require.NoError(t, err, "ожидался %s", method)
+			msg, err := sip.ParseMessage(buf[:n])
+			require.NoError(t, err)
+			if req, ok := msg.(*sip.Request); ok && req.Method == method {
+				return req, from
+			}
+		}
+	}
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+
+	_, err = d.DoRequest(ctx, "X-PING")
+	assert.Error(t, err, "До установления диалога запрос не отправляется")
+
+	_, err = d.Start(ctx, peerURI.String())
+	require.NoError(t, err)
+	invite, from := read(sip.INVITE)
+	proxy := sip.Uri{Scheme: "sip", Host: "127.0.0.1", Port: peerURI.Port, UriParams: sip.NewParams().Add("lr", "")}
+	ok := sip.NewResponseFromRequest(invite, sip.StatusOK, "OK", nil)
+	ok.To().Params.Add("tag", "peertag")
+	ok.AppendHeader(&sip.RecordRouteHeader{Address: proxy})
+	ok.AppendHeader(&sip.ContactHeader{Address: peerURI})
+	_, err = peer.WriteToUDP([]byte(ok.String()), from)
+	require.NoError(t, err)
+	ack, _ := read(sip.ACK)
+	assert.Equal(t, invite.CSeq().SeqNo, ack.CSeq().SeqNo, "ACK использует номер CSeq INVITE")
+	assert.Equal(t, sip.ACK, ack.CSeq().MethodName)
+
+	for _, method := range []sip.RequestMethod{sip.INVITE, sip.ACK, sip.CANCEL, sip.BYE} {
+		_, err := d.DoRequest(ctx, method)
+		assert.ErrorIs(t, err, ErrReservedMethod, method)
+	}
+	_, err = d.DoRequest(ctx, "X PING")
+	assert.Error(t, err, "Метод должен быть token")
+
+	seqNo := invite.CSeq().SeqNo + 50
+	tx, err := d.DoRequest(ctx, "X-PING",
+		WithHeaderString("X-Vendor", "acme"),
+		WithCSeq(seqNo, sip.INFO))
+	require.NoError(t, err)
+
+	req, from := read("X-PING")
+	assert.Equal(t, sip.RequestMethod("X-PING"), req.CSeq().MethodName, "CSeq содержит метод запроса")
+	assert.Equal(t, seqNo, req.CSeq().SeqNo, "Номер WithCSeq сохраняется")
+	require.NotNil(t, req.Route())
+	assert.Equal(t, proxy.String(), req.Route().Address.String(), "Запрос проходит по route set")
+	assert.Equal(t, peerURI.String(), req.Recipient.String())
+	if h := req.GetHeader("X-Vendor"); assert.NotNil(t, h) {
+		assert.Equal(t, "acme", h.Value())
+	}
+	toTag, _ := req.To().Params.Get("tag")
+	assert.Equal(t, "peertag", toTag)
+
+	resp := sip.NewResponseFromRequest(req, sip.StatusAccepted, "Accepted", nil)
+	_, err = peer.WriteToUDP([]byte(resp.String()), from)
+	require.NoError(t, err)
+
+	select {
+	case resp := <-tx.Responses():
+		require.NotNil(t, resp)
+		assert.Equal(t, sip.StatusAccepted, resp.StatusCode)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Ответ на X-PING не доставлен в транзакцию")
+	}
+
+	// Следующий запрос диалога получает номер больше заданного WithCSeq
+	_, err = d.DoRequest(ctx, "X-PING")
+	require.NoError(t, err)
+	req, _ = read("X-PING")
+	assert.Equal(t, seqNo+1, req.CSeq().SeqNo)
+}
@@ -0,0 +1,168 @@
+package dialog
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// routeSetFromRecordRoute строит route set диалога из заголовков Record-Route.
+// UAC берет их из ответа в обратном порядке (RFC 3261 Section 12.1.2), UAS -
+// из запроса в прямом порядке (Section 12.1.1)
+func routeSetFromRecordRoute(msg sip.Message, reverse bool) []sip.Uri {
+	hdrs := msg.GetHeaders("Record-Route")
+	routes := make([]sip.Uri, 0, len(hdrs))
+	for _, h := range hdrs {
+		if rr, ok := h.(*sip.RecordRouteHeader); ok {
+			routes = append(routes, *rr.Address.Clone())
+		}
+	}
+	if reverse {
+		for i, j := 0, len(routes)-1; i < j; i, j = i+1, j-1 {
+			routes[i], routes[j] = routes[j], routes[i]
+		}
+	}
+	return routes
+}
+
+// isLooseRoute проверяет параметр lr - признак loose routing (RFC 3261 Section 16.12)
+func isLooseRoute(uri sip.Uri) bool {
+	return uri.UriParams != nil && uri.UriParams.Has("lr")
+}
+
+// applyRouteSet заполняет Request-URI, Route и адрес назначения запроса в
+// диалоге по RFC 3261 Section 12.2.1.1:
+//   - пустой route set: Request-URI - remote target, запрос уходит ему же;
+//   - loose routing (первый URI с lr): Request-URI - remote target, Route -
+//     весь route set, запрос уходит первому URI;
+//   - strict routing: Request-URI - первый URI route set, Route - остальные
+//     URI и remote target последним, запрос уходит по Request-URI
+func applyRouteSet(req *sip.Request, target sip.Uri, routes []sip.Uri) {
+	for len(req.GetHeaders("Route")) > 0 {
+		req.RemoveHeader("Route")
+	}
+	if len(routes) == 0 {
+		req.Recipient = target
+		return
+	}
+
+	if isLooseRoute(routes[0]) {
+		req.Recipient = target
+		for _, uri := range routes {
+			req.AppendHeader(&sip.RouteHeader{Address: *uri.Clone()})
+		}
+		return
+	}
+
+	req.Recipient = *routes[0].Clone()
+	for _, uri := range routes[1:] {
+		req.AppendHeader(&sip.RouteHeader{Address: *uri.Clone()})
+	}
+	req.AppendHeader(&sip.RouteHeader{Address: *target.Clone()})
+	// sipgo отправляет запрос по первому Route, а при strict routing
+	// следующий узел - Request-URI
+	req.SetDestination(uriHostPort(req.Recipient, req.Transport()))
+}
+
+// uriHostPort возвращает адрес назначения URI с портом по умолчанию для транспорта
+func uriHostPort(uri sip.Uri, transport string) string {
+	port := uri.Port
+	if port == 0 {
+		port = sip.DefaultPort(transport)
+	}
+	return fmt.Sprintf("%s:%d", uri.Host, port)
+}
+
+// setRouteSet сохраняет route set диалога. Route set фиксируется при
+// установлении диалога и не меняется запросами внутри него (RFC 3261 Section 12.2)
+func (s *Dialog) setRouteSet(routes []sip.Uri) {
+	headers := make([]sip.RouteHeader, 0, len(routes))
+	for _, uri := range routes {
+		headers = append(headers, sip.RouteHeader{Address: uri})
+	}
+
+	s.uriMu.Lock()
+	s.routeSet = routes
+	s.routeHeaders = headers
+	s.uriMu.Unlock()
+
+	if len(routes) > 0 {
+		slog.Debug("Dialog route set",
+			slog.String("dialogID", s.ID()),
+			slog.Int("routes", len(routes)),
+			slog.Bool("looseRouting", isLooseRoute(routes[0])))
+	}
+}
+
+// establishFrom2xx сохраняет remote target и route set диалога из 2xx ответа
+// на исходящий INVITE, устанавливающего диалог (RFC 3261 Section 12.1.2)
+func (s *Dialog) establishFrom2xx(resp *sip.Response) {
+	if contact := resp.Contact(); contact != nil {
+		s.uriMu.Lock()
+		s.remoteTarget = *contact.Address.Clone()
+		s.uriMu.Unlock()
+	}
+	s.setRouteSet(routeSetFromRecordRoute(resp, true))
+}
+
+// refreshRemoteTarget заменяет remote target на URI из Contact запроса или
+// ответа, обновляющего target (RFC 3261 Section 12.2): re-INVITE и UPDATE,
+// а также 2xx на них. Последующие запросы диалога уходят на новый адрес.
+// Route set при этом не меняется.
+func (s *Dialog) refreshRemoteTarget(contact *sip.ContactHeader) {
+	if contact == nil || contact.Address.Wildcard || contact.Address.Host == "" {
+		return
+	}
+
+	s.uriMu.Lock()
+	previous := s.remoteTarget
+	if previous.String() == contact.Address.String() {
+		s.uriMu.Unlock()
+		return
+	}
+	s.remoteTarget = *contact.Address.Clone()
+	s.remoteContact = contact
+	current := s.remoteTarget
+	s.uriMu.Unlock()
+
+	slog.Debug("Dialog remote target refreshed",
+		slog.String("dialogID", s.ID()),
+		slog.String("previous", s.uu.redact(previous.String())),
+		slog.String("current", s.uu.redact(current.String())))
+
+	s.handlersMu.Lock()
+	handler := s.targetHandler
+	s.handlersMu.Unlock()
+	if handler != nil {
+		handler(previous, current)
+	}
+}
+
+// OnRemoteTargetChanged устанавливает обработчик смены remote target.
+// Вызывается, когда удаленная сторона меняет Contact в re-INVITE, UPDATE или
+// в 2xx на наш re-INVITE/UPDATE, например после смены IP адреса. Медиа адреса
+// задаются SDP и этим событием не меняются.
+// Метод потокобезопасен.
+func (s *Dialog) OnRemoteTargetChanged(handler func(previous, current sip.Uri)) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.targetHandler = handler
+}
+
+// sendAck2xx подтверждает 2xx ответ на INVITE. ACK на 2xx - отдельная
+// транзакция: он уходит на remote target через route set диалога с номером
+// CSeq подтверждаемого INVITE (RFC 3261 Section 13.2.2.4). ACK на ответы
+// 3xx-6xx отправляет клиентская транзакция sipgo тому же адресату, что и
+// INVITE (Section 17.1.1.3)
+func (s *Dialog) sendAck2xx(invite *sip.Request) error {
+	req := s.makeRequest(sip.ACK)
+	if h := invite.CSeq(); h != nil {
+		req.AppendHeader(&sip.CSeqHeader{SeqNo: h.SeqNo, MethodName: sip.ACK})
+	}
+	if err := s.uu.writeMsg(req); err != nil {
+		slog.Debug("failed to send ack", "error", err)
+		return err
+	}
+	return nil
+}
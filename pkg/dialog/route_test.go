@@ -0,0 +1,249 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyRouteSet(t *testing.T) {
+	target := sip.Uri{Scheme: "sip", User: "bob", Host: "10.0.0.9", Port: 5080}
+	loose := sip.Uri{Scheme: "sip", Host: "proxy1.example.com", UriParams: sip.NewParams().Add("lr", "")}
+	strict := sip.Uri{Scheme: "sip", Host: "proxy1.example.com", Port: 5070}
+	second := sip.Uri{Scheme: "sip", Host: "proxy2.example.com", UriParams: sip.NewParams().Add("lr", "")}
+
+	routes := func(req *sip.Request) []string {
+		var result []string
+		for _, h := range req.GetHeaders("Route") {
+			result = append(result, h.(*sip.RouteHeader).Address.String())
+		}
+		return result
+	}
+
+	req := sip.NewRequest(sip.INFO, sip.Uri{})
+	applyRouteSet(req, target, nil)
+	assert.Equal(t, target.String(), req.Recipient.String())
+	assert.Empty(t, routes(req))
+	assert.Equal(t, "10.0.0.9:5080", req.Destination())
+
+	req = sip.NewRequest(sip.INFO, sip.Uri{})
+	applyRouteSet(req, target, []sip.Uri{loose, second})
+	assert.Equal(t, target.String(), req.Recipient.String(), "Loose routing: Request-URI - remote target")
+	assert.Equal(t, []string{loose.String(), second.String()}, routes(req))
+	assert.Equal(t, "proxy1.example.com:5060", req.Destination())
+
+	req = sip.NewRequest(sip.INFO, sip.Uri{})
+	applyRouteSet(req, target, []sip.Uri{strict, second})
+	assert.Equal(t, strict.String(), req.Recipient.String(), "Strict routing: Request-URI - первый URI route set")
+	assert.Equal(t, []string{second.String(), target.String()}, routes(req), "Remote target - последний Route")
+	assert.Equal(t, "proxy1.example.com:5070", req.Destination())
+
+	// Повторное применение заменяет Route, а не добавляет
+	applyRouteSet(req, target, []sip.Uri{loose})
+	assert.Equal(t, []string{loose.String()}, routes(req))
+}
+
+// TestAckRoutingStrictProxy проверяет ACK на 2xx и последующие запросы через
+// прокси со strict routing, а ACK на 4xx - в транзакции INVITE
+func TestAckRoutingStrictProxy(t *testing.T) {
+	proxy, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer proxy.Close()
+	proxyPort := proxy.LocalAddr().(*net.UDPAddr).Port
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15099}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	read := func(method sip.RequestMethod) (*sip.Request, *net.UDPAddr) {
+		buf := make([]byte, 4096)
+		require.NoError(t, proxy.SetReadDeadline(time.Now().Add(3*time.Second)))
+		for {
+			n, from, err := proxy.ReadFromUDP(buf)
+			require.NoError(t, err, "ожидался %s", method)
+			msg, err := sip.ParseMessage(buf[:n])
+			require.NoError(t, err)
+			if req, ok := msg.(*sip.Request); ok && req.Method == method {
+				return req, from
+			}
+		}
+	}
+	respond := func(req *sip.Request, from *net.UDPAddr, code int, headers ...sip.Header) {
+		resp := sip.NewResponseFromRequest(req, code, "", nil)
+		resp.To().Params.Add("tag", "proxytag")
+		for _, h := range headers {
+			resp.AppendHeader(h)
+		}
+		_, err := proxy.WriteToUDP([]byte(resp.String()), from)
+		require.NoError(t, err)
+	}
+
+	t.Run("2xx", func(t *testing.T) {
+		d, err := u.NewDialog(ctx)
+		require.NoError(t, err)
+		_, err = d.Start(ctx, fmt.Sprintf("sip:bob@127.0.0.1:%d", proxyPort))
+		require.NoError(t, err)
+
+		invite, from := read(sip.INVITE)
+		contact := sip.Uri{Scheme: "sip", User: "bob", Host: "192.0.2.10", Port: 5099}
+		strictProxy := sip.Uri{Scheme: "sip", Host: "127.0.0.1", Port: proxyPort}
+		respond(invite, from, sip.StatusOK,
+			&sip.RecordRouteHeader{Address: strictProxy},
+			&sip.ContactHeader{Address: contact})
+
+		ack, _ := read(sip.ACK)
+		assert.Equal(t, strictProxy.String(), ack.Recipient.String(), "ACK на 2xx уходит первому URI route set")
+		require.NotNil(t, ack.Route())
+		assert.Equal(t, contact.String(), ack.Route().Address.String(), "Remote target - в Route")
+		assert.Equal(t, invite.CSeq().SeqNo, ack.CSeq().SeqNo, "ACK использует CSeq INVITE")
+		assert.NotEqual(t, invite.Via().Params["branch"], ack.Via().Params["branch"], "ACK на 2xx - отдельная транзакция")
+
+		target := d.RemoteTarget()
+		assert.Equal(t, contact.String(), target.String())
+		require.Len(t, d.RouteSet(), 1)
+
+		_, err = d.SendRequest(ctx)
+		require.NoError(t, err)
+		info, _ := read(sip.INFO)
+		assert.Equal(t, strictProxy.String(), info.Recipient.String())
+		assert.Equal(t, contact.String(), info.Route().Address.String())
+		assert.Greater(t, info.CSeq().SeqNo, invite.CSeq().SeqNo)
+	})
+
+	t.Run("non-2xx", func(t *testing.T) {
+		d, err := u.NewDialog(ctx)
+		require.NoError(t, err)
+		_, err = d.Start(ctx, fmt.Sprintf("sip:bob@127.0.0.1:%d", proxyPort))
+		require.NoError(t, err)
+
+		invite, from := read(sip.INVITE)
+		respond(invite, from, sip.StatusBusyHere,
+			&sip.RecordRouteHeader{Address: sip.Uri{Scheme: "sip", Host: "192.0.2.20"}})
+
+		ack, _ := read(sip.ACK)
+		assert.Equal(t, invite.Recipient.String(), ack.Recipient.String(), "ACK на 4xx уходит адресату INVITE")
+		assert.Empty(t, d.RouteSet(), "Ответ 4xx не образует route set")
+		assert.Equal(t, invite.Via().Params["branch"], ack.Via().Params["branch"], "ACK на 4xx - в транзакции INVITE")
+		assert.Equal(t, invite.CSeq().SeqNo, ack.CSeq().SeqNo)
+	})
+}
+
+// TestRemoteTargetRefresh проверяет смену remote target по Contact во
+// входящем UPDATE и в 2xx на исходящий UPDATE
+func TestRemoteTargetRefresh(t *testing.T) {
+	listen := func() (*net.UDPConn, sip.Uri) {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn, sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: conn.LocalAddr().(*net.UDPAddr).Port}
+	}
+	peer, peerURI := listen()
+	moved, movedURI := listen()
+	movedAgain, movedAgainURI := listen()
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15100}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	read := func(conn *net.UDPConn, match func(sip.Message) bool) (sip.Message, *net.UDPAddr) {
+		buf := make([]byte, 4096)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(3*time.Second)))
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			require.NoError(t, err)
+			msg, err := sip.ParseMessage(buf[:n])
+			require.NoError(t, err)
+			if match(msg) {
+				return msg, from
+			}
+		}
+	}
+	request := func(method sip.RequestMethod) func(sip.Message) bool {
+		return func(msg sip.Message) bool {
+			req, ok := msg.(*sip.Request)
+			return ok && req.Method == method
+		}
+	}
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	changes := make(chan [2]string, 2)
+	d.OnRemoteTargetChanged(func(previous, current sip.Uri) {
+		changes <- [2]string{previous.String(), current.String()}
+	})
+
+	_, err = d.Start(ctx, peerURI.String())
+	require.NoError(t, err)
+	msg, from := read(peer, request(sip.INVITE))
+	invite := msg.(*sip.Request)
+	ok := sip.NewResponseFromRequest(invite, sip.StatusOK, "OK", nil)
+	ok.To().Params.Add("tag", "peertag")
+	ok.AppendHeader(&sip.ContactHeader{Address: peerURI})
+	_, err = peer.WriteToUDP([]byte(ok.String()), from)
+	require.NoError(t, err)
+	read(peer, request(sip.ACK))
+	assert.Empty(t, changes, "Установление диалога не является сменой target")
+
+	// Входящий UPDATE с новым Contact
+	localTag, _ := invite.From().Params.Get("tag")
+	update := sip.NewRequest(sip.UPDATE, sip.Uri{Scheme: "sip", Host: "127.0.0.1", Port: 15100})
+	update.AppendHeader(sip.NewHeader("Via", fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK-refresh", moved.LocalAddr())))
+	update.AppendHeader(&sip.FromHeader{Address: peerURI, Params: sip.NewParams().Add("tag", "peertag")})
+	update.AppendHeader(&sip.ToHeader{Address: invite.From().Address, Params: sip.NewParams().Add("tag", localTag)})
+	update.AppendHeader(invite.CallID())
+	update.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: sip.UPDATE})
+	update.AppendHeader(&sip.ContactHeader{Address: movedURI})
+	_, err = moved.WriteToUDP([]byte(update.String()), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 15100})
+	require.NoError(t, err)
+	read(moved, func(msg sip.Message) bool { _, ok := msg.(*sip.Response); return ok })
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, [2]string{peerURI.String(), movedURI.String()}, change)
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnRemoteTargetChanged не вызван для UPDATE")
+	}
+
+	// Исходящий UPDATE уходит на новый target, 2xx снова меняет Contact
+	_, err = d.SendRequest(ctx, withMethod(sip.UPDATE))
+	require.NoError(t, err)
+	msg, from = read(moved, request(sip.UPDATE))
+	resp := sip.NewResponseFromRequest(msg.(*sip.Request), sip.StatusOK, "OK", nil)
+	resp.AppendHeader(&sip.ContactHeader{Address: movedAgainURI})
+	_, err = moved.WriteToUDP([]byte(resp.String()), from)
+	require.NoError(t, err)
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, [2]string{movedURI.String(), movedAgainURI.String()}, change)
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnRemoteTargetChanged не вызван для 2xx на UPDATE")
+	}
+
+	_, err = d.SendRequest(ctx)
+	require.NoError(t, err)
+	msg, _ = read(movedAgain, request(sip.INFO))
+	assert.Equal(t, movedAgainURI.String(), msg.(*sip.Request).Recipient.String())
+}
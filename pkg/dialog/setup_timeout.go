@@ -0,0 +1,205 @@
+package dialog
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// SetupTimeouts - таймеры установления исходящего вызова. Отсчет обоих
+// таймеров идет от отправки первого INVITE.
+//
+// Provisional ограничивает ожидание первого ответа 18x: вызов, который
+// назначение не начало обслуживать, быстро завершается с причиной
+// NoProvisionalCause, и приложение может перейти к следующему маршруту.
+// Answer ограничивает ожидание финального ответа (ring-no-answer), вызов
+// завершается с причиной NoAnswerCause.
+//
+// Если получен хотя бы 100 Trying, INVITE отменяется CANCEL с причиной в
+// заголовке Reason, иначе клиентская транзакция прекращается локально:
+// RFC 3261 Section 9.1 не допускает CANCEL до предварительного ответа.
+type SetupTimeouts struct {
+	// Provisional - ожидание 18x, 0 - без ограничения
+	Provisional time.Duration
+	// Answer - ожидание финального ответа, 0 - без ограничения
+	Answer time.Duration
+}
+
+// setupTimer - таймеры установления исходящего вызова
+type setupTimer struct {
+	mu          sync.Mutex
+	timeouts    SetupTimeouts
+	explicit    bool // Таймауты заданы для вызова через SetSetupTimeouts
+	provisional *time.Timer
+	answer      *time.Timer
+	trying      bool // Получен предварительный ответ, CANCEL допустим
+	stopped     bool
+}
+
+// SetSetupTimeouts задает таймеры установления для этого вызова вместо
+// Config.DestinationSetupTimeouts и Config.SetupTimeouts. Вызывается до
+// Start. Метод потокобезопасен.
+func (s *Dialog) SetSetupTimeouts(timeouts SetupTimeouts) {
+	s.setupTimer.mu.Lock()
+	defer s.setupTimer.mu.Unlock()
+	s.setupTimer.timeouts = timeouts
+	s.setupTimer.explicit = true
+}
+
+// SetupTimeouts возвращает таймеры установления вызова: заданные
+// SetSetupTimeouts или, после Start, выбранные по назначению
+func (s *Dialog) SetupTimeouts() SetupTimeouts {
+	s.setupTimer.mu.Lock()
+	defer s.setupTimer.mu.Unlock()
+	return s.setupTimer.timeouts
+}
+
+// destinationSetupTimeouts выбирает таймеры по назначению INVITE: сначала
+// Config.DestinationSetupTimeouts для host:port, затем для host, иначе
+// Config.SetupTimeouts
+func (u *UACUAS) destinationSetupTimeouts(target sip.Uri) SetupTimeouts {
+	if target.Port > 0 {
+		if timeouts, ok := u.config.DestinationSetupTimeouts[net.JoinHostPort(target.Host, strconv.Itoa(target.Port))]; ok {
+			return timeouts
+		}
+	}
+	if timeouts, ok := u.config.DestinationSetupTimeouts[target.Host]; ok {
+		return timeouts
+	}
+	return u.config.SetupTimeouts
+}
+
+// startSetupTimers запускает таймеры установления после отправки INVITE
+func (s *Dialog) startSetupTimers(target sip.Uri) {
+	t := &s.setupTimer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped || t.provisional != nil || t.answer != nil {
+		return
+	}
+	if !t.explicit {
+		t.timeouts = s.uu.destinationSetupTimeouts(target)
+	}
+	if t.timeouts.Provisional > 0 {
+		t.provisional = time.AfterFunc(t.timeouts.Provisional, s.onProvisionalTimeout)
+	}
+	if t.timeouts.Answer > 0 {
+		t.answer = time.AfterFunc(t.timeouts.Answer, s.onAnswerTimeout)
+	}
+}
+
+// markSetupProvisional останавливает таймер Provisional при получении 18x.
+// Любой предварительный ответ, включая 100, разрешает отмену CANCEL.
+func (s *Dialog) markSetupProvisional(statusCode int) {
+	t := &s.setupTimer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trying = true
+	if statusCode > sip.StatusTrying && t.provisional != nil {
+		t.provisional.Stop()
+		t.provisional = nil
+	}
+}
+
+// stopSetupTimers останавливает таймеры после ответа или завершения вызова
+func (s *Dialog) stopSetupTimers() {
+	t := &s.setupTimer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.provisional != nil {
+		t.provisional.Stop()
+		t.provisional = nil
+	}
+	if t.answer != nil {
+		t.answer.Stop()
+		t.answer = nil
+	}
+}
+
+func (s *Dialog) onProvisionalTimeout() {
+	s.setupTimer.mu.Lock()
+	fired := s.setupTimer.provisional != nil
+	s.setupTimer.provisional = nil
+	s.setupTimer.mu.Unlock()
+	if fired {
+		s.expireSetup(NoProvisionalCause(), s.SetupTimeouts().Provisional)
+	}
+}
+
+func (s *Dialog) onAnswerTimeout() {
+	s.setupTimer.mu.Lock()
+	fired := s.setupTimer.answer != nil
+	s.setupTimer.answer = nil
+	s.setupTimer.mu.Unlock()
+	if fired {
+		s.expireSetup(NoAnswerCause(), s.SetupTimeouts().Answer)
+	}
+}
+
+// expireSetup завершает неотвеченный вызов по таймеру установления. После
+// предварительного ответа INVITE отменяется CANCEL, до него - прекращается
+// локально.
+func (s *Dialog) expireSetup(cause ReleaseCause, timeout time.Duration) {
+	if s.State() != Calling {
+		return
+	}
+	slog.Info("Истек таймер установления вызова",
+		slog.String("dialogID", s.ID()),
+		slog.String("cause", cause.Text),
+		slog.Duration("timeout", timeout))
+
+	s.setupTimer.mu.Lock()
+	trying := s.setupTimer.trying
+	s.setupTimer.mu.Unlock()
+
+	tx := s.getFirstTX()
+	if !trying || tx == nil {
+		s.abortSetup(cause)
+		return
+	}
+	// Причина сохраняется до CANCEL: ответ 487 на INVITE ее не заменяет
+	s.setReleaseCause(cause)
+	if err := tx.cancel(cause); err != nil {
+		slog.Warn("Ошибка отправки CANCEL по таймеру установления",
+			slog.String("dialogID", s.ID()),
+			slog.String("error", err.Error()))
+		s.abortSetup(cause)
+	}
+}
+
+// abortSetup завершает исходящий вызов без отправки CANCEL: клиентская
+// транзакция INVITE прекращается, поздние ответы на нее не обрабатываются
+func (s *Dialog) abortSetup(cause ReleaseCause) {
+	tx := s.getFirstTX()
+	if tx != nil && tx.tx != nil {
+		tx.tx.Terminate()
+	}
+
+	reason := StateTransitionReason{
+		Reason:  "Call setup timeout",
+		Method:  sip.INVITE,
+		Details: fmt.Sprintf("Call setup aborted: %s", cause.Text),
+		Cause:   &cause,
+	}
+	if err := s.setStateWithReason(Terminating, tx, reason); err != nil {
+		slog.Error("Failed to set dialog state to Terminating",
+			slog.String("error", err.Error()),
+			slog.String("dialogID", s.ID()))
+	}
+	endReason := StateTransitionReason{
+		Reason:  "Dialog terminated after setup timeout",
+		Method:  sip.INVITE,
+		Details: cause.Text,
+	}
+	if err := s.setStateWithReason(Ended, tx, endReason); err != nil {
+		slog.Error("Failed to set dialog state to Ended",
+			slog.String("error", err.Error()),
+			slog.String("dialogID", s.ID()))
+	}
+}
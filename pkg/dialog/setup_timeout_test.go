@@ -0,0 +1,140 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestinationSetupTimeouts(t *testing.T) {
+	u := &UACUAS{config: Config{
+		SetupTimeouts: SetupTimeouts{Provisional: 5 * time.Second, Answer: time.Minute},
+		DestinationSetupTimeouts: map[string]SetupTimeouts{
+			"trunk.example.com":      {Provisional: 2 * time.Second},
+			"trunk.example.com:5080": {Provisional: time.Second, Answer: 30 * time.Second},
+		},
+	}}
+
+	assert.Equal(t, SetupTimeouts{Provisional: time.Second, Answer: 30 * time.Second},
+		u.destinationSetupTimeouts(sip.Uri{Host: "trunk.example.com", Port: 5080}))
+	assert.Equal(t, SetupTimeouts{Provisional: 2 * time.Second},
+		u.destinationSetupTimeouts(sip.Uri{Host: "trunk.example.com", Port: 5060}))
+	assert.Equal(t, SetupTimeouts{Provisional: 5 * time.Second, Answer: time.Minute},
+		u.destinationSetupTimeouts(sip.Uri{Host: "pbx.example.com"}))
+
+	// Таймеры вызова заменяют таймеры назначения
+	d := &Dialog{uu: u}
+	d.SetSetupTimeouts(SetupTimeouts{Answer: 10 * time.Second})
+	d.startSetupTimers(sip.Uri{Host: "trunk.example.com", Port: 5080})
+	defer d.stopSetupTimers()
+	assert.Equal(t, SetupTimeouts{Answer: 10 * time.Second}, d.SetupTimeouts())
+}
+
+// startSetupTimeoutCall запускает исходящий вызов на пира с таймерами из config
+func startSetupTimeoutCall(t *testing.T, port int, config Config, peer *registrarPeer) (*Dialog, <-chan ReleaseCause) {
+	config.TestMode = true
+	config.TransportConfigs = []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: port}}
+	u, err := NewUACUAS(config)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { _ = u.ListenTransports(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		_ = u.Stop()
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	released := make(chan ReleaseCause, 1)
+	d.OnRelease(func(cause ReleaseCause) { released <- cause })
+
+	_, err = d.Start(ctx, fmt.Sprintf("sip:bob@127.0.0.1:%d", peer.uri.Port))
+	require.NoError(t, err)
+	return d, released
+}
+
+// respond отправляет ответ на INVITE с тегом To
+func respond(peer *registrarPeer, req *sip.Request, code int, reason string, to *net.UDPAddr) {
+	resp := sip.NewResponseFromRequest(req, code, reason, nil)
+	if code > sip.StatusTrying {
+		resp.To().Params = sip.NewParams().Add("tag", "bob")
+	}
+	peer.send(resp, to)
+}
+
+func TestSetupTimeoutNoProvisional(t *testing.T) {
+	bob := newRegistrarPeer(t, "bob")
+	d, released := startSetupTimeoutCall(t, 15109, Config{
+		DestinationSetupTimeouts: map[string]SetupTimeouts{
+			"127.0.0.1": {Provisional: 300 * time.Millisecond, Answer: 5 * time.Second},
+		},
+	}, bob)
+
+	// INVITE остается без ответа
+	bob.read(matchRequest(sip.INVITE))
+
+	select {
+	case cause := <-released:
+		assert.Equal(t, NoProvisionalCause(), cause)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Вызов не завершен по таймеру Provisional")
+	}
+	assert.Equal(t, Ended, d.State())
+	assert.Equal(t, 300*time.Millisecond, d.SetupTimeouts().Provisional)
+
+	// До предварительного ответа CANCEL не отправляется
+	require.NoError(t, bob.conn.SetReadDeadline(time.Now().Add(300*time.Millisecond)))
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := bob.conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		msg, err := sip.ParseMessage(buf[:n])
+		require.NoError(t, err)
+		assert.False(t, matchRequest(sip.CANCEL)(msg), "CANCEL до предварительного ответа")
+	}
+}
+
+func TestSetupTimeoutNoAnswer(t *testing.T) {
+	bob := newRegistrarPeer(t, "bob")
+	d, released := startSetupTimeoutCall(t, 15110, Config{
+		SendReasonHeader: true,
+		SetupTimeouts:    SetupTimeouts{Provisional: 300 * time.Millisecond, Answer: 800 * time.Millisecond},
+	}, bob)
+
+	msg, from := bob.read(matchRequest(sip.INVITE))
+	invite := msg.(*sip.Request)
+	respond(bob, invite, sip.StatusTrying, "Trying", from)
+	respond(bob, invite, sip.StatusRinging, "Ringing", from)
+
+	// Вызов звонит дольше Provisional, но отменяется только по таймеру Answer
+	start := time.Now()
+	msg, from = bob.read(matchRequest(sip.CANCEL))
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+	cancel := msg.(*sip.Request)
+	reason := cancel.GetHeader("Reason")
+	require.NotNil(t, reason)
+	assert.Contains(t, reason.Value(), "cause=487")
+	assert.Contains(t, reason.Value(), NoAnswerCause().Text)
+
+	bob.send(sip.NewResponseFromRequest(cancel, sip.StatusOK, "OK", nil), from)
+	respond(bob, invite, sip.StatusRequestTerminated, "Request Terminated", from)
+
+	select {
+	case cause := <-released:
+		assert.Equal(t, CategoryNoAnswer, cause.Category)
+		assert.Equal(t, Q850NoAnswer, cause.Q850Cause)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Вызов не завершен по таймеру Answer")
+	}
+	assert.Equal(t, Ended, d.State())
+}
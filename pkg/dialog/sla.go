@@ -0,0 +1,796 @@
+package dialog
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo"
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+)
+
+// Пакеты событий общей линии
+const (
+	// EventDialog - состояние диалогов линии (RFC 4235, RFC 7463)
+	EventDialog = "dialog"
+	// EventLineSeize - захват appearance линии перед исходящим вызовом
+	EventLineSeize = "line-seize"
+)
+
+const (
+	// defaultDialogSubscriptionExpires - время подписки на состояние линии по умолчанию
+	defaultDialogSubscriptionExpires = 3600
+	// defaultLineSeizeExpires - время захвата appearance по умолчанию
+	defaultLineSeizeExpires = 15
+	// statusBadEvent - 489 Bad Event (RFC 6665 Section 8.3.2)
+	statusBadEvent = 489
+)
+
+var (
+	// ErrAppearanceUnknown ошибка при обращении к несуществующему appearance
+	ErrAppearanceUnknown = errors.New("unknown line appearance")
+	// ErrAppearanceBusy ошибка при занятии занятого appearance
+	ErrAppearanceBusy = errors.New("line appearance is busy")
+	// ErrNoFreeAppearance ошибка при отсутствии свободных appearance
+	ErrNoFreeAppearance = errors.New("no free line appearances")
+)
+
+// AppearanceState - состояние appearance общей линии
+type AppearanceState string
+
+const (
+	// AppearanceIdle - appearance свободен
+	AppearanceIdle AppearanceState = "idle"
+	// AppearanceSeized - appearance захвачен подпиской line-seize для исходящего вызова
+	AppearanceSeized AppearanceState = "seized"
+	// AppearanceProgressing - вызов на appearance устанавливается
+	AppearanceProgressing AppearanceState = "progressing"
+	// AppearanceActive - идет разговор
+	AppearanceActive AppearanceState = "active"
+	// AppearanceHeld - вызов на удержании
+	AppearanceHeld AppearanceState = "held"
+)
+
+// Appearance описывает состояние appearance (линии) общей линии
+type Appearance struct {
+	Index     int
+	State     AppearanceState
+	DialogID  string // Диалог вызова на appearance
+	SeizedBy  string // URI UA, захватившего appearance
+	Exclusive bool   // К вызову нельзя присоединиться (barge-in)
+}
+
+// BargeIn - запрос на присоединение второго UA к вызову на appearance:
+// входящий INVITE с Call-Info appearance-index активного appearance.
+// Приложение принимает TX и подключает медиа Joining к конференц-микшеру
+// вместе с медиа Call.
+type BargeIn struct {
+	Appearance int
+	Call       *Dialog   // Существующий вызов на appearance
+	Joining    IDialog   // Диалог присоединяющегося UA
+	TX         IServerTX // Транзакция INVITE присоединяющегося UA
+}
+
+// SharedLine - общая линия (shared line appearance, RFC 7463) с несколькими
+// appearance. UACUAS выступает агентом appearance: публикует состояние
+// линии подписчикам Event: dialog, обслуживает захват appearance подписками
+// Event: line-seize и передает приложению запросы barge-in.
+//
+//	line := dialog.NewSharedLine(aor, 2)
+//	line.OnBargeIn(func(b dialog.BargeIn) { _ = b.TX.Accept(...) /* подключить к микшеру */ })
+//	ua.SetSharedLine(line)
+//	ua.OnIncomingCall(func(d dialog.IDialog, tx dialog.IServerTX) {
+//	    _, _ = line.Attach(d.(*dialog.Dialog), 0)
+//	})
+type SharedLine struct {
+	mu          sync.Mutex
+	uu          *UACUAS
+	aor         sip.Uri
+	appearances []*lineAppearance
+	version     uint32 // Версия документов dialog-info
+	subs        map[string]*lineSubscription
+
+	changeHandler  func(Appearance)
+	bargeInHandler func(BargeIn)
+}
+
+// lineAppearance - состояние одного appearance
+type lineAppearance struct {
+	index     int
+	held      bool
+	exclusive bool
+	dialog    *Dialog
+	seizedBy  *lineSubscription
+}
+
+// lineSubscription - подписка на события линии (dialog или line-seize)
+type lineSubscription struct {
+	key        string // Call-ID и тег From подписчика
+	event      string
+	callID     sip.CallIDHeader
+	localTag   string
+	remoteTag  string
+	local      sip.Uri // Request-URI SUBSCRIBE
+	remote     sip.Uri // From подписчика
+	target     sip.Uri // Contact подписчика
+	cseq       uint32
+	appearance int // Захваченный appearance для line-seize
+	timer      *time.Timer
+}
+
+// NewSharedLine создает общую линию aor с указанным количеством appearance
+func NewSharedLine(aor sip.Uri, appearances int) *SharedLine {
+	line := &SharedLine{
+		aor:  aor,
+		subs: make(map[string]*lineSubscription),
+	}
+	for i := 1; i <= appearances; i++ {
+		line.appearances = append(line.appearances, &lineAppearance{index: i})
+	}
+	return line
+}
+
+// SetSharedLine подключает общую линию к UACUAS и включает обработку
+// SUBSCRIBE (FeatureSharedLine). nil отключает линию.
+func (u *UACUAS) SetSharedLine(line *SharedLine) {
+	u.sharedLineMu.Lock()
+	u.sharedLine = line
+	u.sharedLineMu.Unlock()
+
+	if line == nil {
+		u.capabilities.Disable(FeatureSharedLine)
+		return
+	}
+	line.mu.Lock()
+	line.uu = u
+	line.mu.Unlock()
+	u.capabilities.Enable(FeatureSharedLine)
+}
+
+// SharedLine возвращает подключенную общую линию или nil
+func (u *UACUAS) SharedLine() *SharedLine {
+	u.sharedLineMu.Lock()
+	defer u.sharedLineMu.Unlock()
+	return u.sharedLine
+}
+
+// OnChange устанавливает обработчик изменения состояния appearance.
+// Метод потокобезопасен.
+func (l *SharedLine) OnChange(handler func(appearance Appearance)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changeHandler = handler
+}
+
+// OnBargeIn устанавливает обработчик запросов barge-in. Без обработчика
+// INVITE к активному appearance обрабатывается как обычный входящий вызов.
+// Метод потокобезопасен.
+func (l *SharedLine) OnBargeIn(handler func(bargeIn BargeIn)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bargeInHandler = handler
+}
+
+// Appearances возвращает состояние всех appearance линии
+func (l *SharedLine) Appearances() []Appearance {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make([]Appearance, 0, len(l.appearances))
+	for _, a := range l.appearances {
+		result = append(result, a.snapshot())
+	}
+	return result
+}
+
+// Appearance возвращает состояние appearance по номеру (с 1)
+func (l *SharedLine) Appearance(index int) (Appearance, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a := l.get(index)
+	if a == nil {
+		return Appearance{}, false
+	}
+	return a.snapshot(), true
+}
+
+// Attach закрепляет вызов за appearance. index 0 выбирает первый свободный
+// appearance. Состояние appearance далее следует за состоянием диалога,
+// после завершения вызова appearance освобождается.
+func (l *SharedLine) Attach(d *Dialog, index int) (int, error) {
+	l.mu.Lock()
+	a, err := l.reserve(index)
+	if err != nil {
+		l.mu.Unlock()
+		return 0, err
+	}
+	l.attachLocked(a, d)
+	l.mu.Unlock()
+
+	l.changed(a.index)
+	return a.index, nil
+}
+
+// SetHeld отмечает вызов на appearance как поставленный на удержание или снятый с него
+func (l *SharedLine) SetHeld(index int, held bool) error {
+	return l.update(index, func(a *lineAppearance) error {
+		if a.dialog == nil {
+			return fmt.Errorf("line appearance %d has no call", index)
+		}
+		a.held = held
+		return nil
+	})
+}
+
+// SetExclusive запрещает или разрешает barge-in к вызову на appearance
+func (l *SharedLine) SetExclusive(index int, exclusive bool) error {
+	return l.update(index, func(a *lineAppearance) error {
+		a.exclusive = exclusive
+		return nil
+	})
+}
+
+// DialogInfo формирует полный документ dialog-info о состоянии линии
+// (RFC 7463). Свободные appearance не публикуются.
+func (l *SharedLine) DialogInfo() ([]byte, error) {
+	l.mu.Lock()
+	data, err := l.dialogInfoLocked()
+	l.mu.Unlock()
+	return data, err
+}
+
+func (l *SharedLine) update(index int, apply func(a *lineAppearance) error) error {
+	l.mu.Lock()
+	a := l.get(index)
+	if a == nil {
+		l.mu.Unlock()
+		return ErrAppearanceUnknown
+	}
+	if err := apply(a); err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	l.mu.Unlock()
+
+	l.changed(index)
+	return nil
+}
+
+// get возвращает appearance по номеру. Вызывается под mu.
+func (l *SharedLine) get(index int) *lineAppearance {
+	if index < 1 || index > len(l.appearances) {
+		return nil
+	}
+	return l.appearances[index-1]
+}
+
+// reserve выбирает appearance для вызова. Вызывается под mu.
+func (l *SharedLine) reserve(index int) (*lineAppearance, error) {
+	if index != 0 {
+		a := l.get(index)
+		if a == nil {
+			return nil, ErrAppearanceUnknown
+		}
+		if a.dialog != nil {
+			return nil, ErrAppearanceBusy
+		}
+		return a, nil
+	}
+	for _, a := range l.appearances {
+		if a.dialog == nil && a.seizedBy == nil {
+			return a, nil
+		}
+	}
+	return nil, ErrNoFreeAppearance
+}
+
+// attachLocked закрепляет диалог за appearance. Вызывается под mu.
+func (l *SharedLine) attachLocked(a *lineAppearance, d *Dialog) {
+	a.dialog = d
+	a.held = false
+	// Захват line-seize завершается исходящим вызовом
+	if sub := a.seizedBy; sub != nil {
+		if sub.timer != nil {
+			sub.timer.Stop()
+		}
+		sub.appearance = 0
+		delete(l.subs, sub.key)
+		a.seizedBy = nil
+	}
+
+	d.lineMu.Lock()
+	d.line = l
+	d.lineIndex = a.index
+	d.lineMu.Unlock()
+}
+
+// detach освобождает appearance завершенного вызова
+func (l *SharedLine) detach(index int, d *Dialog) {
+	l.mu.Lock()
+	a := l.get(index)
+	if a == nil || a.dialog != d {
+		l.mu.Unlock()
+		return
+	}
+	a.dialog = nil
+	a.held = false
+	a.exclusive = false
+	l.mu.Unlock()
+
+	l.changed(index)
+}
+
+// changed уведомляет обработчик и подписчиков Event: dialog об изменении appearance
+func (l *SharedLine) changed(index int) {
+	l.mu.Lock()
+	a := l.get(index)
+	if a == nil {
+		l.mu.Unlock()
+		return
+	}
+	snapshot := a.snapshot()
+	handler := l.changeHandler
+	body, err := l.dialogInfoLocked()
+	var notifies []*sip.Request
+	if err == nil && l.uu != nil {
+		for _, sub := range l.subs {
+			if sub.event == EventDialog {
+				notifies = append(notifies, l.notifyLocked(sub, "active", body))
+			}
+		}
+	}
+	l.mu.Unlock()
+
+	if handler != nil {
+		handler(snapshot)
+	}
+	for _, req := range notifies {
+		l.sendNotify(req)
+	}
+}
+
+func (a *lineAppearance) snapshot() Appearance {
+	appearance := Appearance{
+		Index:     a.index,
+		State:     AppearanceIdle,
+		Exclusive: a.exclusive,
+	}
+	switch {
+	case a.dialog != nil:
+		appearance.DialogID = a.dialog.ID()
+		switch a.dialog.State() {
+		case InCall:
+			appearance.State = AppearanceActive
+			if a.held {
+				appearance.State = AppearanceHeld
+			}
+		case Terminating, Ended:
+			appearance.State = AppearanceIdle
+		default:
+			appearance.State = AppearanceProgressing
+		}
+	case a.seizedBy != nil:
+		appearance.State = AppearanceSeized
+		appearance.SeizedBy = a.seizedBy.remote.String()
+	}
+	return appearance
+}
+
+// dialogInfoLocked формирует документ dialog-info. Вызывается под mu.
+func (l *SharedLine) dialogInfoLocked() ([]byte, error) {
+	l.version++
+	info := DialogInfo{
+		Version: l.version,
+		State:   "full",
+		Entity:  l.aor.String(),
+	}
+	for _, a := range l.appearances {
+		if entry, ok := a.dialogInfo(); ok {
+			info.Dialogs = append(info.Dialogs, entry)
+		}
+	}
+	return marshalDialogInfo(info)
+}
+
+func (a *lineAppearance) dialogInfo() (DialogInfoDialog, bool) {
+	snapshot := a.snapshot()
+	entry := DialogInfoDialog{
+		ID:         fmt.Sprintf("appearance-%d", a.index),
+		Appearance: a.index,
+	}
+	if a.exclusive {
+		entry.Exclusive = "true"
+	}
+
+	switch snapshot.State {
+	case AppearanceSeized:
+		entry.State = DialogInfoTrying
+		entry.Local = &DialogInfoParticipant{Identity: snapshot.SeizedBy}
+		return entry, true
+	case AppearanceIdle:
+		return entry, false
+	}
+
+	d := a.dialog
+	entry.ID = d.ID()
+	entry.CallID = string(d.CallID())
+	entry.LocalTag = d.LocalTag()
+	entry.RemoteTag = d.RemoteTag()
+	entry.Direction = "recipient"
+	if d.uaType == UAC {
+		entry.Direction = "initiator"
+	}
+	entry.State = DialogInfoEarly
+	if snapshot.State != AppearanceProgressing {
+		entry.State = DialogInfoConfirmed
+	}
+
+	localURI, localTarget := d.LocalURI(), d.LocalTarget()
+	remoteURI, remoteTarget := d.RemoteURI(), d.RemoteTarget()
+	local := &DialogInfoTarget{URI: localTarget.String()}
+	if snapshot.State == AppearanceHeld {
+		local.Params = append(local.Params, DialogInfoParam{Name: "+sip.rendering", Value: "no"})
+	}
+	entry.Local = &DialogInfoParticipant{Identity: localURI.String(), Target: local}
+	entry.Remote = &DialogInfoParticipant{
+		Identity: remoteURI.String(),
+		Target:   &DialogInfoTarget{URI: remoteTarget.String()},
+	}
+	return entry, true
+}
+
+// updateLineAppearance обновляет appearance общей линии при смене состояния диалога
+func (s *Dialog) updateLineAppearance() {
+	s.lineMu.Lock()
+	line, index := s.line, s.lineIndex
+	if line != nil && (s.State() == Terminating || s.State() == Ended) {
+		s.line = nil
+		s.lineIndex = 0
+	}
+	s.lineMu.Unlock()
+
+	if line == nil {
+		return
+	}
+	if s.State() == Terminating || s.State() == Ended {
+		line.detach(index, s)
+		return
+	}
+	line.changed(index)
+}
+
+// LineAppearance возвращает номер appearance общей линии вызова или 0
+func (s *Dialog) LineAppearance() int {
+	s.lineMu.Lock()
+	defer s.lineMu.Unlock()
+	return s.lineIndex
+}
+
+// appearanceIndex возвращает appearance-index из заголовка Call-Info.
+// Значение "*" (любой appearance) возвращается как 0.
+func appearanceIndex(msg sip.Message) (int, bool) {
+	for _, h := range msg.GetHeaders("Call-Info") {
+		for _, param := range strings.Split(h.Value(), ";")[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "appearance-index") {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if value == "*" {
+				return 0, true
+			}
+			index, err := strconv.Atoi(value)
+			if err != nil {
+				return 0, false
+			}
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+// routeInvite связывает входящий INVITE с appearance по Call-Info: вызов UA,
+// захватившего appearance, закрепляется за ним, INVITE к активному
+// appearance возвращается как barge-in.
+func (l *SharedLine) routeInvite(req *sip.Request, d *Dialog) (*Dialog, int) {
+	index, ok := appearanceIndex(req)
+	if !ok || index == 0 {
+		return nil, 0
+	}
+
+	l.mu.Lock()
+	a := l.get(index)
+	if a == nil {
+		l.mu.Unlock()
+		return nil, 0
+	}
+	if a.dialog != nil {
+		call, exclusive := a.dialog, a.exclusive
+		bargeIn := l.bargeInHandler != nil && !exclusive && call.State() == InCall
+		l.mu.Unlock()
+		if bargeIn {
+			return call, index
+		}
+		return nil, 0
+	}
+	if a.seizedBy != nil {
+		l.attachLocked(a, d)
+	}
+	l.mu.Unlock()
+	return nil, 0
+}
+
+// dispatchBargeIn передает запрос barge-in приложению
+func (l *SharedLine) dispatchBargeIn(bargeIn BargeIn) {
+	l.mu.Lock()
+	handler := l.bargeInHandler
+	l.mu.Unlock()
+
+	slog.Info("Запрос barge-in на appearance общей линии",
+		slog.String("dialogID", bargeIn.Call.ID()),
+		slog.Int("appearance", bargeIn.Appearance))
+
+	if handler != nil {
+		handler(bargeIn)
+	}
+}
+
+// handleSubscribe обрабатывает подписки на состояние общей линии
+// (Event: dialog) и захват appearance (Event: line-seize)
+func (u *UACUAS) handleSubscribe(req *sip.Request, tx sip.ServerTransaction) {
+	slog.Debug("handleSubscribe",
+		slog.String("req", u.redact(req.String())))
+
+	line := u.SharedLine()
+	event := ""
+	if h := req.GetHeader("Event"); h != nil {
+		event, _, _ = strings.Cut(h.Value(), ";")
+		event = strings.ToLower(strings.TrimSpace(event))
+	}
+	if line == nil || (event != EventDialog && event != EventLineSeize) {
+		resp := sip.NewResponseFromRequest(req, statusBadEvent, "Bad Event", nil)
+		resp.AppendHeader(sip.NewHeader("Allow-Events", EventDialog+", "+EventLineSeize))
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки ответа 489 на SUBSCRIBE",
+				slog.Any("error", err),
+				slog.String("event", event))
+		}
+		return
+	}
+
+	line.subscribe(req, tx, event)
+}
+
+// subscribe создает, обновляет или завершает подписку
+func (l *SharedLine) subscribe(req *sip.Request, tx sip.ServerTransaction, event string) {
+	expires := defaultDialogSubscriptionExpires
+	if event == EventLineSeize {
+		expires = defaultLineSeizeExpires
+	}
+	if h, ok := req.GetHeader("Expires").(*sip.ExpiresHeader); ok {
+		expires = int(*h)
+	}
+
+	respond := func(code int, reason string, sub *lineSubscription) {
+		resp := sip.NewResponseFromRequest(req, code, reason, nil)
+		if sub != nil {
+			if to := resp.To(); to != nil {
+				if to.Params == nil {
+					to.Params = sip.NewParams()
+				}
+				to.Params.Add("tag", sub.localTag)
+			}
+			resp.AppendHeader(sip.NewHeader("Expires", strconv.Itoa(expires)))
+			if l.uu != nil {
+				resp.AppendHeader(l.uu.profile.Contact())
+			}
+		}
+		if err := tx.Respond(resp); err != nil {
+			slog.Error("Ошибка отправки ответа на SUBSCRIBE",
+				slog.Any("error", err),
+				slog.Int("status", code))
+		}
+	}
+
+	if req.CallID() == nil || req.From() == nil || req.Contact() == nil {
+		respond(sip.StatusBadRequest, "Bad Request", nil)
+		return
+	}
+	fromTag, _ := req.From().Params.Get("tag")
+	key := req.CallID().Value() + ":" + fromTag
+
+	l.mu.Lock()
+	sub, exists := l.subs[key]
+	if !exists {
+		sub = &lineSubscription{
+			key:       key,
+			event:     event,
+			callID:    *req.CallID(),
+			localTag:  l.uu.generateTag(),
+			remoteTag: fromTag,
+			local:     req.Recipient,
+			remote:    req.From().Address,
+			target:    req.Contact().Address,
+		}
+	}
+
+	changedIndex := 0
+	if event == EventLineSeize && expires > 0 && sub.appearance == 0 {
+		index, _ := appearanceIndex(req)
+		a, err := l.seizeLocked(index)
+		if err != nil {
+			l.mu.Unlock()
+			respond(sip.StatusForbidden, "Appearance Busy", nil)
+			return
+		}
+		a.seizedBy = sub
+		sub.appearance = a.index
+		changedIndex = a.index
+	}
+
+	var notify *sip.Request
+	if expires == 0 {
+		delete(l.subs, key)
+		changedIndex = l.releaseSeizeLocked(sub)
+		notify = l.notifyLocked(sub, "terminated;reason=timeout", nil)
+	} else {
+		l.subs[key] = sub
+		l.scheduleExpiryLocked(key, sub, time.Duration(expires)*time.Second)
+		state := "active;expires=" + strconv.Itoa(expires)
+		if event == EventDialog {
+			body, err := l.dialogInfoLocked()
+			if err == nil {
+				notify = l.notifyLocked(sub, state, body)
+			}
+		} else {
+			notify = l.notifyLocked(sub, state, nil)
+		}
+	}
+	l.mu.Unlock()
+
+	respond(sip.StatusOK, "OK", sub)
+	if notify != nil {
+		l.sendNotify(notify)
+	}
+	if changedIndex != 0 {
+		l.changed(changedIndex)
+	}
+}
+
+// seizeLocked выбирает appearance для захвата. Вызывается под mu.
+func (l *SharedLine) seizeLocked(index int) (*lineAppearance, error) {
+	if index != 0 {
+		a := l.get(index)
+		if a == nil {
+			return nil, ErrAppearanceUnknown
+		}
+		if a.dialog != nil || a.seizedBy != nil {
+			return nil, ErrAppearanceBusy
+		}
+		return a, nil
+	}
+	return l.reserve(0)
+}
+
+// releaseSeizeLocked освобождает appearance, захваченный подпиской.
+// Возвращает номер освобожденного appearance или 0. Вызывается под mu.
+func (l *SharedLine) releaseSeizeLocked(sub *lineSubscription) int {
+	if sub.timer != nil {
+		sub.timer.Stop()
+	}
+	index := sub.appearance
+	sub.appearance = 0
+	if a := l.get(index); a != nil && a.seizedBy == sub {
+		a.seizedBy = nil
+		return index
+	}
+	return 0
+}
+
+// scheduleExpiryLocked завершает подписку по истечении expires. Вызывается под mu.
+func (l *SharedLine) scheduleExpiryLocked(key string, sub *lineSubscription, expires time.Duration) {
+	if sub.timer != nil {
+		sub.timer.Stop()
+	}
+	sub.timer = time.AfterFunc(expires, func() {
+		l.mu.Lock()
+		if l.subs[key] != sub {
+			l.mu.Unlock()
+			return
+		}
+		delete(l.subs, key)
+		index := l.releaseSeizeLocked(sub)
+		notify := l.notifyLocked(sub, "terminated;reason=timeout", nil)
+		l.mu.Unlock()
+
+		l.sendNotify(notify)
+		if index != 0 {
+			l.changed(index)
+		}
+	})
+}
+
+// notifyLocked формирует NOTIFY подписки. Вызывается под mu.
+func (l *SharedLine) notifyLocked(sub *lineSubscription, state string, body []byte) *sip.Request {
+	sub.cseq++
+	req := sip.NewRequest(sip.NOTIFY, sub.target)
+	if l.uu != nil && len(l.uu.config.TransportConfigs) > 0 {
+		tc := l.uu.config.TransportConfigs[0]
+		req.Laddr = sip.Addr{IP: net.ParseIP(tc.Host), Hostname: tc.Host, Port: tc.Port}
+	}
+	req.AppendHeader(&sip.FromHeader{Address: sub.local, Params: sip.NewParams().Add("tag", sub.localTag)})
+	req.AppendHeader(&sip.ToHeader{Address: sub.remote, Params: sip.NewParams().Add("tag", sub.remoteTag)})
+	callID := sub.callID
+	req.AppendHeader(&callID)
+	req.AppendHeader(&sip.CSeqHeader{SeqNo: sub.cseq, MethodName: sip.NOTIFY})
+	maxForwards := sip.MaxForwardsHeader(70)
+	req.AppendHeader(&maxForwards)
+	if l.uu != nil {
+		req.AppendHeader(l.uu.profile.Contact())
+	}
+	req.AppendHeader(sip.NewHeader("Event", sub.event))
+	req.AppendHeader(sip.NewHeader("Subscription-State", state))
+
+	if sub.event == EventLineSeize && sub.appearance != 0 {
+		req.AppendHeader(sip.NewHeader("Call-Info",
+			fmt.Sprintf("<%s>;appearance-index=%d", l.aor.String(), sub.appearance)))
+	}
+	if body != nil {
+		ct := sip.ContentTypeHeader(ContentTypeDialogInfo)
+		req.AppendHeader(&ct)
+		req.SetBody(body)
+	}
+	return req
+}
+
+// sendNotify отправляет NOTIFY подписчику вне диалога вызова
+func (l *SharedLine) sendNotify(req *sip.Request) {
+	l.mu.Lock()
+	u := l.uu
+	l.mu.Unlock()
+	if u == nil {
+		return
+	}
+	if err := u.sendNotify(req); err != nil {
+		slog.Warn("Не удалось отправить NOTIFY общей линии",
+			slog.String("target", l.uu.redact(req.Recipient.String())),
+			slog.String("error", err.Error()))
+	}
+}
+
+// sendNotify отправляет NOTIFY в отдельной транзакции и завершает ее по
+// финальному ответу
+func (u *UACUAS) sendNotify(req *sip.Request) error {
+	u.stopMutex.Lock()
+	if u.stopped {
+		u.stopMutex.Unlock()
+		return ErrUACUASStopped
+	}
+	u.stopMutex.Unlock()
+
+	u.prepareOutgoing(req)
+	u.selectRequestTransport(req)
+
+	tx, err := u.uac.TransactionRequest(u.ctx, req, sipgo.ClientRequestAddVia)
+	if err != nil {
+		return errors.Wrap(err, "failed to send NOTIFY")
+	}
+	go func() {
+		defer tx.Terminate()
+		for {
+			select {
+			case resp := <-tx.Responses():
+				if resp != nil && resp.StatusCode >= 200 {
+					return
+				}
+			case <-tx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
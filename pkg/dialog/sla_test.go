@@ -0,0 +1,110 @@
+package dialog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSubscribeRequest(callID, event string, expires int, callInfo string) *sip.Request {
+	req := newTestRequest(sip.SUBSCRIBE)
+	req.RemoveHeader("Call-ID")
+	id := sip.CallIDHeader(callID)
+	req.AppendHeader(&id)
+	req.AppendHeader(&sip.ContactHeader{Address: sip.Uri{Scheme: "sip", User: "alice", Host: "127.0.0.1", Port: 5999}})
+	req.AppendHeader(sip.NewHeader("Event", event))
+	expiresHeader := sip.ExpiresHeader(expires)
+	req.AppendHeader(&expiresHeader)
+	if callInfo != "" {
+		req.AppendHeader(sip.NewHeader("Call-Info", callInfo))
+	}
+	return req
+}
+
+func TestAppearanceIndex(t *testing.T) {
+	req := newTestRequest(sip.INVITE)
+	_, ok := appearanceIndex(req)
+	assert.False(t, ok)
+
+	req.AppendHeader(sip.NewHeader("Call-Info", "<sip:line@pbx.example.com>; appearance-index=2"))
+	index, ok := appearanceIndex(req)
+	assert.True(t, ok)
+	assert.Equal(t, 2, index)
+
+	req = newTestRequest(sip.SUBSCRIBE)
+	req.AppendHeader(sip.NewHeader("Call-Info", "<sip:line@pbx.example.com>;appearance-index=*"))
+	index, ok = appearanceIndex(req)
+	assert.True(t, ok)
+	assert.Zero(t, index, "Любой appearance")
+}
+
+func TestSharedLineSubscribe(t *testing.T) {
+	u, err := NewUACUAS(Config{TestMode: true, TransportConfigs: []TransportConfig{
+		{Type: TransportUDP, Host: "127.0.0.1", Port: 35960},
+	}})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	// Без общей линии подписки отклоняются
+	tx := newRecordingServerTX()
+	u.handleSubscribe(newSubscribeRequest("sub-1", EventDialog, 60, ""), tx)
+	require.Len(t, tx.responses, 1)
+	assert.Equal(t, statusBadEvent, tx.responses[0].StatusCode)
+
+	aor := sip.Uri{Scheme: "sip", User: "line", Host: "pbx.example.com"}
+	line := NewSharedLine(aor, 2)
+	u.SetSharedLine(line)
+	assert.True(t, u.Capabilities().MethodAllowed(sip.SUBSCRIBE))
+
+	changes := make(chan Appearance, 8)
+	line.OnChange(func(a Appearance) { changes <- a })
+
+	// Захват appearance 2
+	tx = newRecordingServerTX()
+	u.handleSubscribe(newSubscribeRequest("seize-1", EventLineSeize, 15, "<sip:line@pbx.example.com>;appearance-index=2"), tx)
+	require.Len(t, tx.responses, 1)
+	resp := tx.responses[0]
+	assert.Equal(t, sip.StatusOK, resp.StatusCode)
+	assert.Equal(t, "15", resp.GetHeader("Expires").Value())
+	toTag, ok := resp.To().Params.Get("tag")
+	assert.True(t, ok)
+	assert.NotEmpty(t, toTag)
+
+	seized := <-changes
+	assert.Equal(t, 2, seized.Index)
+	assert.Equal(t, AppearanceSeized, seized.State)
+	assert.Equal(t, "sip:alice@127.0.0.1", seized.SeizedBy)
+
+	// Захваченный appearance недоступен другим UA
+	tx = newRecordingServerTX()
+	u.handleSubscribe(newSubscribeRequest("seize-2", EventLineSeize, 15, "<sip:line@pbx.example.com>;appearance-index=2"), tx)
+	assert.Equal(t, sip.StatusForbidden, tx.responses[0].StatusCode)
+
+	// Состояние линии публикуется документом dialog-info
+	tx = newRecordingServerTX()
+	u.handleSubscribe(newSubscribeRequest("state-1", EventDialog+";sla", 3600, ""), tx)
+	assert.Equal(t, sip.StatusOK, tx.responses[0].StatusCode)
+
+	body, err := line.DialogInfo()
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(string(body), "urn:ietf:params:xml:ns:sa-dialog-info"))
+	info, err := ParseDialogInfo(body)
+	require.NoError(t, err)
+	require.Len(t, info.Dialogs, 1)
+	assert.Equal(t, 2, info.Dialogs[0].Appearance)
+	assert.Equal(t, DialogInfoTrying, info.Dialogs[0].State)
+
+	// Отмена захвата освобождает appearance
+	tx = newRecordingServerTX()
+	u.handleSubscribe(newSubscribeRequest("seize-1", EventLineSeize, 0, ""), tx)
+	assert.Equal(t, sip.StatusOK, tx.responses[0].StatusCode)
+	released := <-changes
+	assert.Equal(t, AppearanceIdle, released.State)
+
+	tx = newRecordingServerTX()
+	u.handleSubscribe(newSubscribeRequest("presence-1", "presence", 60, ""), tx)
+	assert.Equal(t, statusBadEvent, tx.responses[0].StatusCode)
+}
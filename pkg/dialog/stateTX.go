@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"github.com/emiago/sipgo/sip"
 	"log/slog"
+	"sync"
+	"time"
 )
 
 // TX представляет обертку над SIP транзакцией.
@@ -24,6 +26,17 @@ type TX struct {
 	respChan     chan *sip.Response
 	lastResponse *sip.Response // последний полученный ответ
 	body         *Body         // тело сообщения
+
+	// onFinal освобождает очередь запросов диалога при финальном ответе
+	// или завершении клиентской транзакции
+	onFinal   func()
+	finalOnce sync.Once
+
+	// final закрывается при финальном ответе или завершении клиентской
+	// транзакции, finalResp - финальный ответ (WaitFinal)
+	final      chan struct{}
+	finalResp  *sip.Response
+	finalClose sync.Once
 }
 
 func (t *TX) Accept(opts ...ResponseOpt) error {
@@ -32,6 +45,13 @@ func (t *TX) Accept(opts ...ResponseOpt) error {
 		return fmt.Errorf("cannot accept on client transaction")
 	}
 
+	// Проверяем медиа путь до отправки 200 OK на INVITE
+	if t.req.Method == sip.INVITE {
+		if err := t.probeMedia(); err != nil {
+			return err
+		}
+	}
+
 	// Создаем ответ 200 OK
 	resp := newRespFromReq(t.Request(), sip.StatusOK, "OK", nil, t.dialog.localTag)
 
@@ -40,7 +60,13 @@ func (t *TX) Accept(opts ...ResponseOpt) error {
 		opt(resp)
 	}
 
+	// 2xx на INVITE сообщает удаленной стороне наши возможности
+	if t.req.Method == sip.INVITE && t.dialog.uu != nil && t.dialog.uu.capabilities != nil {
+		t.dialog.uu.capabilities.appendCapabilityHeaders(resp)
+	}
+
 	slog.Debug("Transaction accepted", slog.Any("to-tag", resp.To().Params))
+	t.dialog.uu.prepareOutgoing(resp)
 
 	// Отправляем ответ через серверную транзакцию
 	if sTx, ok := t.tx.(sip.ServerTransaction); ok {
@@ -48,7 +74,9 @@ func (t *TX) Accept(opts ...ResponseOpt) error {
 		if err != nil {
 			return err
 		}
-		return t.processingOutgoingResponse(resp)
+		err = t.processingOutgoingResponse(resp)
+		t.dialog.notifyMediaAnswer(t.req, resp, true)
+		return err
 	}
 
 	return nil
@@ -77,12 +105,14 @@ func (t *TX) processingOutgoingResponse(resp *sip.Response) error {
 			}
 			return t.dialog.setStateWithReason(Ringing, t, reason)
 		case resp.StatusCode >= 400 && resp.StatusCode < 700:
+			cause := releaseCauseFromResponse(resp)
 			reason := StateTransitionReason{
 				Reason:       "Rejected by user",
 				Method:       t.req.Method,
 				StatusCode:   resp.StatusCode,
 				StatusReason: resp.Reason,
 				Details:      "",
+				Cause:        &cause,
 			}
 			return t.dialog.setStateWithReason(Terminating, t, reason)
 		default:
@@ -112,6 +142,7 @@ func (t *TX) Provisional(code int, reason string, opts ...ResponseOpt) error {
 	for _, opt := range opts {
 		opt(resp)
 	}
+	t.dialog.uu.prepareOutgoing(resp)
 
 	// Отправляем ответ через серверную транзакцию
 	if sTx, ok := t.tx.(sip.ServerTransaction); ok {
@@ -171,17 +202,19 @@ func (t *TX) Reject(code int, reason string, opts ...ResponseOpt) error {
 	if t.IsClient() {
 		return fmt.Errorf("cannot answer client transaction")
 	}
-	resp := newRespFromReq(t.Request(), code, reason, nil, t.dialog.remoteTag)
+	resp := newRespFromReq(t.Request(), code, reason, nil, t.dialog.RemoteTag())
 
 	for _, opt := range opts {
 		opt(resp)
 	}
+	t.dialog.uu.prepareOutgoing(resp)
 
 	if sTx, ok := t.tx.(sip.ServerTransaction); ok {
 		err := sTx.Respond(resp)
 		if err != nil {
 			return err
 		}
+		t.dialog.notifyMediaRejected(t.req, resp, true)
 		return t.processingOutgoingResponse(resp)
 	}
 	return errors.New("not supported for client transactions")
@@ -224,6 +257,11 @@ func (t *TX) Responses() <-chan *sip.Response {
 }
 
 func (t *TX) Cancel() error {
+	return t.cancel(CancelledCause())
+}
+
+// cancel отправляет CANCEL с причиной cause в заголовке Reason
+func (t *TX) cancel(cause ReleaseCause) error {
 	// Метод доступен только для клиентских транзакций
 	if t.IsServer() {
 		return fmt.Errorf("cannot cancel server transaction")
@@ -250,6 +288,11 @@ func (t *TX) Cancel() error {
 	maxForwards := sip.MaxForwardsHeader(70)
 	cancelReq.AppendHeader(&maxForwards)
 
+	// Причина отмены для биллинга (RFC 3326)
+	if t.dialog != nil && t.dialog.uu != nil && t.dialog.uu.config.SendReasonHeader {
+		cancelReq.AppendHeader(cause.SIPReasonHeader())
+	}
+
 	// Копируем From, To, Call-ID и CSeq
 	if h := t.req.From(); h != nil {
 		cancelReq.AppendHeader(sip.HeaderClone(h))
@@ -283,10 +326,17 @@ func (t *TX) Cancel() error {
 
 // newTX создает новый объект TX
 func newTX(req *sip.Request, tx sip.Transaction, di *Dialog) *TX {
+	return newClientQueuedTX(req, tx, di, nil)
+}
+
+// newClientQueuedTX создает TX, вызывающий onFinal один раз при финальном
+// ответе или завершении транзакции
+func newClientQueuedTX(req *sip.Request, tx sip.Transaction, di *Dialog, onFinal func()) *TX {
 	mTx := new(TX)
 	mTx.tx = tx
 	mTx.req = req
 	mTx.dialog = di
+	mTx.onFinal = onFinal
 	if _, ok := tx.(sip.ServerTransaction); ok {
 		mTx.isServer = true
 	}
@@ -297,6 +347,11 @@ func newTX(req *sip.Request, tx sip.Transaction, di *Dialog) *TX {
 	// Инициализируем канал для клиентских транзакций
 	if mTx.IsClient() {
 		mTx.respChan = make(chan *sip.Response, 10)
+		mTx.final = make(chan struct{})
+		// Повторные 2xx и 2xx от других веток разветвленного INVITE
+		if cTx, ok := tx.(sip.ClientTransaction); ok && req.Method == sip.INVITE {
+			cTx.OnRetransmission(mTx.processingRetransmittedResponse)
+		}
 		// Запускаем горутину для обработки ответов
 		go mTx.loopResponse()
 	}
@@ -316,7 +371,8 @@ func (t *TX) toRespChan(resp *sip.Response) {
 }
 
 func (t *TX) byeResponseProcessing() {
-	if t.lastResponse != nil && t.lastResponse.StatusCode == 200 {
+	// 481 и 408 на BYE тоже завершают диалог (RFC 3261 Section 15.1.1)
+	if t.lastResponse != nil && (t.lastResponse.StatusCode == 200 || isDialogGoneStatus(t.lastResponse.StatusCode)) {
 		reason := StateTransitionReason{
 			Reason:     "200 ok response received",
 			Method:     sip.BYE,
@@ -330,6 +386,19 @@ func (t *TX) byeResponseProcessing() {
 }
 
 func (t *TX) processingIncomingResponse(resp *sip.Response) {
+	// Ответ может содержать заголовки в компактной форме и сжатое тело.
+	// Ответ с телом, которое не удалось распаковать, не обрабатывается
+	if err := t.dialog.uu.prepareIncoming(resp); err != nil {
+		return
+	}
+
+	// 2xx от лишней ветки разветвленного INVITE не влияет на диалог
+	if t.req.Method == sip.INVITE && t.IsClient() && resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		if !t.dialog.acceptBranch(t.req, resp) {
+			return
+		}
+	}
+
 	// Сохраняем последний ответ
 	t.lastResponse = resp
 
@@ -339,9 +408,21 @@ func (t *TX) processingIncomingResponse(resp *sip.Response) {
 		return
 	}
 
+	if isDialogGoneStatus(resp.StatusCode) && t.isInDialogRequest() {
+		t.terminateGoneDialog(releaseCauseFromResponse(resp),
+			fmt.Sprintf("%d %s on in-dialog %s", resp.StatusCode, resp.Reason, t.req.Method))
+		return
+	}
+
 	switch true {
 	case resp.StatusCode >= 100 && resp.StatusCode <= 199:
 		// Информационные ответы (1xx)
+		// Каждый to-tag создает отдельный ранний диалог
+		if t.req.Method == sip.INVITE && t.IsClient() && t.dialog.State() == Calling {
+			t.dialog.trackEarlyDialog(resp)
+			t.dialog.markSetupResponse(resp.StatusCode, time.Now())
+			t.dialog.markSetupProvisional(resp.StatusCode)
+		}
 		// Меняем состояние диалога
 		// тут всегда false, потом удалить
 		if t.dialog.State() == IDLE {
@@ -372,6 +453,14 @@ func (t *TX) processingIncomingResponse(resp *sip.Response) {
 			}
 		}
 
+		establishing := t.req.Method == sip.INVITE && t.IsClient() && t.dialog.State() == Calling
+		if establishing {
+			t.dialog.establishFrom2xx(resp)
+			t.dialog.markSetupResponse(resp.StatusCode, time.Now())
+		} else if t.IsClient() && (t.req.Method == sip.INVITE || t.req.Method == sip.UPDATE) {
+			t.dialog.refreshRemoteTarget(resp.Contact())
+		}
+
 		if t.dialog.State() == Calling {
 			reason := StateTransitionReason{
 				Reason:       "Call answered",
@@ -384,15 +473,37 @@ func (t *TX) processingIncomingResponse(resp *sip.Response) {
 			if err != nil {
 				slog.Error("failed to set dialog state to InCall", "error", err)
 			}
-			_ = t.dialog.sendAckWithoutTX()
+		}
+		if t.IsClient() {
+			t.dialog.notifyMediaAnswer(t.req, resp, false)
+		}
+		// 2xx на INVITE и re-INVITE подтверждается ACK вне транзакции
+		if t.req.Method == sip.INVITE && t.IsClient() {
+			_ = t.dialog.sendAck2xx(t.req)
 		}
 	case resp.StatusCode >= 300 && resp.StatusCode <= 399:
-		//todo
 		// Перенаправления (3xx)
-		slog.Debug("received redirect response", "status", resp.StatusCode)
+		slog.Debug("received redirect response", "status", resp.StatusCode, "reason", resp.Reason)
+		if t.req.Method == sip.INVITE && t.IsClient() && t.dialog.getFirstTX() == t && t.dialog.State() == Calling {
+			// Повтор INVITE по политике перенаправления, иначе вызов завершается
+			if t.dialog.processRedirect(t.req, resp) {
+				return
+			}
+			t.processErrorResponse(resp)
+		}
 	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
 		// Ошибки клиента (4xx)
 		slog.Debug("received client error response", "status", resp.StatusCode, "reason", resp.Reason)
+		if resp.StatusCode == sip.StatusNotAcceptableHere && t.req.Method == sip.INVITE && t.IsClient() &&
+			t.dialog.getFirstTX() == t && t.dialog.State() == Calling {
+			// Повтор INVITE с новым offer по политике, иначе вызов завершается
+			if t.dialog.processNotAcceptable(t.req, resp) {
+				return
+			}
+		}
+		if t.IsClient() {
+			t.dialog.notifyMediaRejected(t.req, resp, false)
+		}
 		t.processErrorResponse(resp)
 	case resp.StatusCode >= 500 && resp.StatusCode <= 599:
 		// Ошибки сервера (5xx)
@@ -408,7 +519,25 @@ func (t *TX) processingIncomingResponse(resp *sip.Response) {
 	}
 }
 
-// processErrorResponse обрабатывает ошибочные ответы (4xx, 5xx, 6xx) на запросы
+// processingRetransmittedResponse обрабатывает 2xx на INVITE, пришедшие после
+// принятия первого 2xx: повторы принятого ответа и ответы других веток
+// разветвленного INVITE, которые транзакция sipgo передает только через хук
+func (t *TX) processingRetransmittedResponse(resp *sip.Response) {
+	if err := t.dialog.uu.prepareIncoming(resp); err != nil {
+		return
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return
+	}
+
+	if t.dialog.acceptBranch(t.req, resp) {
+		slog.Debug("Received 2xx retransmission", "status", resp.StatusCode)
+	}
+}
+
+// processErrorResponse обрабатывает ошибочные ответы (4xx, 5xx, 6xx) и
+// ответы 3xx без повтора INVITE
 func (t *TX) processErrorResponse(resp *sip.Response) {
 	// Проверяем, является ли это ответом на первичный INVITE
 	if t.dialog.getFirstTX() == t && t.req.Method == sip.INVITE {
@@ -416,12 +545,14 @@ func (t *TX) processErrorResponse(resp *sip.Response) {
 		// Обрабатываем только если диалог в начальных состояниях
 		if currentState == Calling || currentState == IDLE {
 			// Создаем причину перехода в Terminating
+			cause := releaseCauseFromResponse(resp)
 			reason := StateTransitionReason{
 				Reason:       "Error response for initial INVITE",
 				Method:       sip.INVITE,
 				StatusCode:   resp.StatusCode,
 				StatusReason: resp.Reason,
 				Details:      fmt.Sprintf("Call failed with %d %s", resp.StatusCode, resp.Reason),
+				Cause:        &cause,
 			}
 
 			// Переводим в Terminating
@@ -429,7 +560,7 @@ func (t *TX) processErrorResponse(resp *sip.Response) {
 			if err != nil {
 				slog.Error("Failed to set dialog state to Terminating",
 					slog.String("error", err.Error()),
-					slog.String("dialogID", t.dialog.id))
+					slog.String("dialogID", t.dialog.ID()))
 			}
 
 			// Затем сразу в Ended
@@ -444,12 +575,74 @@ func (t *TX) processErrorResponse(resp *sip.Response) {
 			if err != nil {
 				slog.Error("Failed to set dialog state to Ended",
 					slog.String("error", err.Error()),
-					slog.String("dialogID", t.dialog.id))
+					slog.String("dialogID", t.dialog.ID()))
 			}
 		}
 	}
 }
 
+// isDialogGoneStatus сообщает, означает ли ответ на запрос внутри диалога,
+// что диалога больше нет: 481 - удаленная сторона его не знает, 408 -
+// таймаут (RFC 3261 Section 12.2.1.2)
+func isDialogGoneStatus(code int) bool {
+	return code == sip.StatusCallTransactionDoesNotExists || code == sip.StatusRequestTimeout
+}
+
+// isInDialogRequest сообщает, является ли транзакция клиентским запросом
+// внутри установленного диалога (не первичный INVITE)
+func (t *TX) isInDialogRequest() bool {
+	return t.IsClient() && t.dialog.getFirstTX() != t && t.req.Method != sip.ACK && t.req.Method != sip.CANCEL
+}
+
+// terminateGoneDialog завершает диалог, который удаленная сторона больше не
+// обслуживает, без отправки BYE: иначе диалог висел бы до срабатывания
+// таймеров сессии. Причина завершения передается в OnRelease.
+func (t *TX) terminateGoneDialog(cause ReleaseCause, details string) {
+	d := t.dialog
+	state := d.State()
+	if state != InCall && state != Terminating {
+		return
+	}
+
+	slog.Warn("Диалог считается завершенным",
+		slog.String("dialogID", d.ID()),
+		slog.String("method", string(t.req.Method)),
+		slog.String("cause", cause.String()))
+
+	if state == InCall {
+		reason := StateTransitionReason{
+			Reason:  "In-dialog request failed",
+			Method:  t.req.Method,
+			Details: details,
+			Cause:   &cause,
+		}
+		if t.lastResponse != nil {
+			reason.StatusCode = t.lastResponse.StatusCode
+			reason.StatusReason = t.lastResponse.Reason
+		}
+		if err := d.setStateWithReason(Terminating, t, reason); err != nil {
+			slog.Error("Failed to set dialog state to Terminating",
+				slog.String("error", err.Error()),
+				slog.String("dialogID", d.ID()))
+		}
+	}
+
+	endReason := StateTransitionReason{
+		Reason:  "Dialog gone",
+		Method:  t.req.Method,
+		Details: details,
+	}
+	if err := d.setStateWithReason(Ended, t, endReason); err != nil {
+		slog.Error("Failed to set dialog state to Ended",
+			slog.String("error", err.Error()),
+			slog.String("dialogID", d.ID()))
+	}
+
+	if d.uu != nil && d.uu.dialogs != nil {
+		d.uu.dialogs.Delete(d.callID, d.localTag, "")
+	}
+}
+
 // saveRemoteTag сохраняет remote tag из ответа для UAC
 func (t *TX) saveRemoteTag(resp *sip.Response) {
 	// Только для клиентских транзакций (UAC)
@@ -461,13 +654,11 @@ func (t *TX) saveRemoteTag(resp *sip.Response) {
 	toHeader := resp.To()
 	if toHeader != nil && toHeader.Params != nil && toHeader.Params.Has("tag") {
 		if tagValue, ok := toHeader.Params.Get("tag"); ok {
-			// Сохраняем remote tag
-			t.dialog.remoteTag = tagValue
-			// Обновляем ID диалога после установки remoteTag
-			t.dialog.updateDialogID()
+			// Сохраняем remote tag и обновляем ID диалога
+			t.dialog.setRemoteTag(tagValue)
 			slog.Debug("Saved remote tag from response",
 				slog.String("remoteTag", tagValue),
-				slog.String("dialogID", t.dialog.id))
+				slog.String("dialogID", t.dialog.ID()))
 		}
 	}
 }
@@ -478,16 +669,38 @@ func (t *TX) loopResponse() {
 	for {
 		select {
 		case <-tx.Done():
+			t.finish()
+			t.setFinal(nil)
+			if errors.Is(tx.Err(), sip.ErrTransactionTimeout) && t.isInDialogRequest() {
+				t.terminateGoneDialog(TransactionTimeoutCause(),
+					fmt.Sprintf("No response to in-dialog %s", t.req.Method))
+			}
 			close(t.respChan)
 			return
 		case resp := <-tx.Responses():
 			slog.Debug("Received response", "status", resp.StatusCode)
+			// Неуспешный финальный ответ освобождает очередь до обработки:
+			// обработка 3xx может сама отправить новый INVITE
+			if resp.StatusCode >= 300 {
+				t.finish()
+			}
 			t.processingIncomingResponse(resp)
+			if resp.StatusCode >= 200 {
+				t.finish()
+				t.setFinal(resp)
+			}
 			t.toRespChan(resp)
 		}
 	}
 }
 
+// finish вызывает onFinal один раз
+func (t *TX) finish() {
+	if t.onFinal != nil {
+		t.finalOnce.Do(t.onFinal)
+	}
+}
+
 // Request возвращает копию запроса по которой была создана транзакция
 func (t *TX) Request() *sip.Request {
 	return t.req.Clone()
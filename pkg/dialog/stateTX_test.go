@@ -1,6 +1,15 @@
 package dialog
 
-import "testing"
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
 
 // testDialog представляет тестовую реализацию диалога (зарезервировано для будущих тестов)
 // type testDialog struct {
@@ -19,3 +28,83 @@ import "testing"
 
 func TestStateTX(t *testing.T) {
 }
+
+// TestInDialogRequestFailureEndsDialog проверяет, что 481 на запрос внутри
+// диалога завершает диалог с причиной без ожидания таймеров сессии. Таймаут
+// транзакции проверяется в pkg/dialog/timeout_test с укороченными таймерами sipgo
+func TestInDialogRequestFailureEndsDialog(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	peerURI := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: peer.LocalAddr().(*net.UDPAddr).Port}
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 0}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	read := func(method sip.RequestMethod) (*sip.Request, *net.UDPAddr) {
+		buf := make([]byte, 4096)
+		require.NoError(t, peer.SetReadDeadline(time.Now().Add(3*time.Second)))
+		for {
+			n, from, err := peer.ReadFromUDP(buf)
+			require.NoError(t, err, "ожидался %s", method)
+			msg, err := sip.ParseMessage(buf[:n])
+			require.NoError(t, err)
+			if req, ok := msg.(*sip.Request); ok && req.Method == method {
+				return req, from
+			}
+		}
+	}
+	respond := func(req *sip.Request, from *net.UDPAddr, code int) {
+		resp := sip.NewResponseFromRequest(req, code, "", nil)
+		resp.To().Params.Add("tag", "peertag")
+		resp.AppendHeader(&sip.ContactHeader{Address: peerURI})
+		_, err := peer.WriteToUDP([]byte(resp.String()), from)
+		require.NoError(t, err)
+	}
+	establish := func() (*Dialog, chan ReleaseCause) {
+		d, err := u.NewDialog(ctx)
+		require.NoError(t, err)
+		released := make(chan ReleaseCause, 1)
+		d.OnRelease(func(cause ReleaseCause) { released <- cause })
+
+		_, err = d.Start(ctx, peerURI.String())
+		require.NoError(t, err)
+		invite, from := read(sip.INVITE)
+		respond(invite, from, sip.StatusOK)
+		read(sip.ACK)
+		require.Eventually(t, func() bool { return d.State() == InCall }, time.Second, 5*time.Millisecond)
+		return d, released
+	}
+	waitRelease := func(released chan ReleaseCause) ReleaseCause {
+		select {
+		case cause := <-released:
+			return cause
+		case <-time.After(3 * time.Second):
+			t.Fatal("Диалог не завершен")
+			return ReleaseCause{}
+		}
+	}
+
+	t.Run("481", func(t *testing.T) {
+		d, released := establish()
+		_, err := d.SendRequest(ctx)
+		require.NoError(t, err)
+		info, from := read(sip.INFO)
+		respond(info, from, sip.StatusCallTransactionDoesNotExists)
+
+		cause := waitRelease(released)
+		assert.Equal(t, sip.StatusCallTransactionDoesNotExists, cause.SIPCode)
+		assert.Equal(t, Ended, d.State())
+		_, found := u.dialogs.Get(d.callID, d.localTag)
+		assert.False(t, found, "Диалог удален из UACUAS")
+	})
+}
@@ -0,0 +1,89 @@
+// Package timeout_test проверяет завершение диалога по таймауту транзакции.
+// Таймеры sipgo глобальные, поэтому тесты вынесены в отдельный пакет: таймеры
+// укорачиваются в TestMain до запуска транспортов и транзакций и не влияют на
+// тесты других пакетов
+package timeout_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog"
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	t1, t2, t4 := sip.T1, sip.T2, sip.T4
+	sip.SetTimers(10*time.Millisecond, 40*time.Millisecond, 50*time.Millisecond)
+	code := m.Run()
+	sip.SetTimers(t1, t2, t4)
+	os.Exit(code)
+}
+
+// TestInDialogRequestTimeoutEndsDialog проверяет, что запрос внутри диалога
+// без ответа завершает диалог с причиной таймаута транзакции
+func TestInDialogRequestTimeoutEndsDialog(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	peerURI := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: peer.LocalAddr().(*net.UDPAddr).Port}
+
+	u, err := dialog.NewUACUAS(dialog.Config{
+		TestMode:         true,
+		TransportConfigs: []dialog.TransportConfig{{Type: dialog.TransportUDP, Host: "127.0.0.1", Port: 0}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	read := func(method sip.RequestMethod) (*sip.Request, *net.UDPAddr) {
+		buf := make([]byte, 4096)
+		require.NoError(t, peer.SetReadDeadline(time.Now().Add(3*time.Second)))
+		for {
+			n, from, err := peer.ReadFromUDP(buf)
+			require.NoError(t, err, "ожидался %s", method)
+			msg, err := sip.ParseMessage(buf[:n])
+			require.NoError(t, err)
+			if req, ok := msg.(*sip.Request); ok && req.Method == method {
+				return req, from
+			}
+		}
+	}
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	released := make(chan dialog.ReleaseCause, 1)
+	d.OnRelease(func(cause dialog.ReleaseCause) { released <- cause })
+
+	_, err = d.Start(ctx, peerURI.String())
+	require.NoError(t, err)
+	invite, from := read(sip.INVITE)
+	resp := sip.NewResponseFromRequest(invite, sip.StatusOK, "OK", nil)
+	resp.To().Params.Add("tag", "peertag")
+	resp.AppendHeader(&sip.ContactHeader{Address: peerURI})
+	_, err = peer.WriteToUDP([]byte(resp.String()), from)
+	require.NoError(t, err)
+	read(sip.ACK)
+	require.Eventually(t, func() bool { return d.State() == dialog.InCall }, time.Second, 5*time.Millisecond)
+
+	_, err = d.SendRequest(ctx)
+	require.NoError(t, err)
+	read(sip.INFO) // ответа нет
+
+	select {
+	case cause := <-released:
+		assert.Equal(t, dialog.TransactionTimeoutCause(), cause)
+	case <-time.After(3 * time.Second):
+		t.Fatal("Диалог не завершен")
+	}
+	assert.Equal(t, dialog.Ended, d.State())
+}
@@ -0,0 +1,315 @@
+package dialog
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+// DestinationStats - статистика клиентских транзакций к одному хосту.
+//
+// Повторы и задержки считаются по сообщениям на проводе, поэтому
+// учитываются все транзакции UACUAS, включая созданные sipgo (ACK не
+// является транзакцией и не учитывается). Частые повторы, рост задержки
+// и таймауты позволяют заметить нестабильный транк до отказа.
+type DestinationStats struct {
+	Host string
+	// Requests - число отправленных запросов (транзакций)
+	Requests uint64
+	// Retransmissions - число повторных отправок запросов (UDP)
+	Retransmissions uint64
+	// Answered - число транзакций, получивших ответ
+	Answered uint64
+	// Timeouts - число транзакций без ответа за 64*T1 (RFC 3261 Timer B/F)
+	Timeouts uint64
+	// TotalLatency - суммарное время до первого ответа по Answered транзакциям
+	TotalLatency time.Duration
+	// LastResponse - время последнего ответа, нулевое если ответов не было
+	LastResponse time.Time
+}
+
+// AverageLatency возвращает среднее время до первого ответа
+func (s DestinationStats) AverageLatency() time.Duration {
+	if s.Answered == 0 {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Answered)
+}
+
+// TimeoutRate возвращает долю завершенных транзакций, закончившихся таймаутом
+func (s DestinationStats) TimeoutRate() float64 {
+	completed := s.Answered + s.Timeouts
+	if completed == 0 {
+		return 0
+	}
+	return float64(s.Timeouts) / float64(completed)
+}
+
+// RetransmissionRate возвращает среднее число повторов на запрос
+func (s DestinationStats) RetransmissionRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Retransmissions) / float64(s.Requests)
+}
+
+// TransactionStats возвращает статистику транзакций по хостам назначения,
+// отсортированную по хосту. Статистика общая для процесса: трассировка
+// сообщений sipgo, на которой она основана, глобальна.
+func TransactionStats() []DestinationStats {
+	return txStats.snapshot(time.Now())
+}
+
+// DestinationTransactionStats возвращает статистику транзакций к хосту
+func DestinationTransactionStats(host string) (DestinationStats, bool) {
+	for _, s := range TransactionStats() {
+		if s.Host == host {
+			return s, true
+		}
+	}
+	return DestinationStats{}, false
+}
+
+// ResetTransactionStats сбрасывает накопленную статистику транзакций.
+// Транзакции, ожидающие ответа, продолжают отслеживаться.
+func ResetTransactionStats() {
+	txStats.mu.Lock()
+	txStats.hosts = make(map[string]*DestinationStats)
+	txStats.mu.Unlock()
+}
+
+// pendingTx - отправленный запрос, ожидающий ответа
+type pendingTx struct {
+	host     string
+	method   string
+	sent     time.Time
+	answered bool
+}
+
+// transactionStats собирает статистику по сообщениям из трассировки sipgo
+type transactionStats struct {
+	mu        sync.Mutex
+	hosts     map[string]*DestinationStats
+	pending   map[string]*pendingTx // ветка Via + метод CSeq -> запрос
+	lastSweep time.Time
+}
+
+var txStats = &transactionStats{
+	hosts:   make(map[string]*DestinationStats),
+	pending: make(map[string]*pendingTx),
+}
+
+// txStatsSweepInterval - период поиска транзакций с истекшим таймаутом
+const txStatsSweepInterval = time.Second
+
+// observeWrite учитывает отправленное сообщение. Повторная отправка
+// запроса с той же веткой Via считается повтором.
+func (c *transactionStats) observeWrite(raddr string, data []byte, now time.Time) {
+	for _, msg := range splitTraceMessages(data) {
+		c.observeRequest(raddr, msg, now)
+	}
+}
+
+// observeRead учитывает полученные ответы на отправленные запросы. Чтение
+// из потокового транспорта может содержать несколько сообщений.
+func (c *transactionStats) observeRead(data []byte, now time.Time) {
+	for _, msg := range splitTraceMessages(data) {
+		c.observeResponse(msg, now)
+	}
+}
+
+func (c *transactionStats) observeRequest(raddr string, msg []byte, now time.Time) {
+	request, method, branch, ok := parseTraceMessage(msg)
+	if !ok || !request || method == string(sip.ACK) {
+		return
+	}
+	host := traceHost(raddr)
+	key := branch + " " + method
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweepLocked(now)
+
+	if tx, exists := c.pending[key]; exists {
+		c.hostLocked(tx.host).Retransmissions++
+		return
+	}
+	c.pending[key] = &pendingTx{host: host, method: method, sent: now}
+	c.hostLocked(host).Requests++
+}
+
+func (c *transactionStats) observeResponse(msg []byte, now time.Time) {
+	request, method, branch, ok := parseTraceMessage(msg)
+	if !ok || request {
+		return
+	}
+	key := branch + " " + method
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, exists := c.pending[key]
+	if !exists {
+		return
+	}
+	final := traceStatusCode(msg) >= 200
+	if !tx.answered {
+		tx.answered = true
+		stats := c.hostLocked(tx.host)
+		stats.Answered++
+		stats.TotalLatency += now.Sub(tx.sent)
+		stats.LastResponse = now
+	}
+	// После предварительного ответа INVITE не повторяется (RFC 3261
+	// Section 17.1.1.2), запрос без INVITE повторяется до финального ответа
+	if final || tx.method == string(sip.INVITE) {
+		delete(c.pending, key)
+	}
+}
+
+// sweepLocked засчитывает таймауты транзакциям без ответа за 64*T1.
+// Вызывается под mu.
+func (c *transactionStats) sweepLocked(now time.Time) {
+	if now.Sub(c.lastSweep) < txStatsSweepInterval {
+		return
+	}
+	c.lastSweep = now
+
+	for key, tx := range c.pending {
+		if now.Sub(tx.sent) < sip.Timer_B {
+			continue
+		}
+		if !tx.answered {
+			c.hostLocked(tx.host).Timeouts++
+		}
+		delete(c.pending, key)
+	}
+}
+
+// hostLocked возвращает статистику хоста. Вызывается под mu.
+func (c *transactionStats) hostLocked(host string) *DestinationStats {
+	stats, ok := c.hosts[host]
+	if !ok {
+		stats = &DestinationStats{Host: host}
+		c.hosts[host] = stats
+	}
+	return stats
+}
+
+func (c *transactionStats) snapshot(now time.Time) []DestinationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweepLocked(now)
+
+	result := make([]DestinationStats, 0, len(c.hosts))
+	for _, stats := range c.hosts {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Host < result[j].Host })
+	return result
+}
+
+// splitTraceMessages делит данные трассировки на сообщения по Content-Length
+func splitTraceMessages(data []byte) [][]byte {
+	var messages [][]byte
+	for len(bytes.TrimLeft(data, "\r\n")) > 0 {
+		data = bytes.TrimLeft(data, "\r\n")
+		end := bytes.Index(data, []byte("\r\n\r\n"))
+		if end < 0 {
+			return append(messages, data)
+		}
+		length := 0
+		for _, line := range strings.Split(string(data[:end]), "\r\n")[1:] {
+			name, value, found := strings.Cut(line, ":")
+			if !found {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "content-length", "l":
+				length, _ = strconv.Atoi(strings.TrimSpace(value))
+			}
+		}
+		next := end + 4 + length
+		if length < 0 || next > len(data) {
+			next = len(data)
+		}
+		messages = append(messages, data[:next])
+		data = data[next:]
+	}
+	return messages
+}
+
+// parseTraceMessage извлекает из сообщения тип, метод CSeq и ветку
+// верхнего Via без полного разбора
+func parseTraceMessage(msg []byte) (request bool, method, branch string, ok bool) {
+	end := bytes.Index(msg, []byte("\r\n\r\n"))
+	if end < 0 {
+		end = len(msg)
+	}
+	lines := strings.Split(string(msg[:end]), "\r\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return false, "", "", false
+	}
+	request = !strings.HasPrefix(lines[0], "SIP/")
+
+	for _, line := range lines[1:] {
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+		switch name {
+		case "via", "v":
+			if branch != "" {
+				continue
+			}
+			for _, param := range strings.Split(value, ";")[1:] {
+				if k, v, found := strings.Cut(strings.TrimSpace(param), "="); found && strings.EqualFold(k, "branch") {
+					branch = strings.TrimSpace(strings.Split(v, ",")[0])
+					break
+				}
+			}
+		case "cseq":
+			if fields := strings.Fields(value); len(fields) == 2 {
+				method = strings.ToUpper(fields[1])
+			}
+		}
+	}
+	return request, method, branch, method != "" && branch != ""
+}
+
+// traceStatusCode возвращает код ответа из стартовой строки
+func traceStatusCode(msg []byte) int {
+	// SIP/2.0 200 OK
+	if len(msg) < 11 || !bytes.HasPrefix(msg, []byte("SIP/")) {
+		return 0
+	}
+	space := bytes.IndexByte(msg, ' ')
+	if space < 0 || len(msg) < space+4 {
+		return 0
+	}
+	code := 0
+	for _, b := range msg[space+1 : space+4] {
+		if b < '0' || b > '9' {
+			return 0
+		}
+		code = code*10 + int(b-'0')
+	}
+	return code
+}
+
+// traceHost возвращает хост адреса трассировки
+func traceHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
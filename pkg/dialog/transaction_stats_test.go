@@ -0,0 +1,110 @@
+package dialog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// traceRequest формирует запрос в том виде, в каком его видит SIP tracer
+func traceRequest(method sip.RequestMethod, branch string) []byte {
+	return []byte(fmt.Sprintf("%s sip:bob@trunk.example.com SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 10.0.0.1:5060;branch=%s\r\n"+
+		"CSeq: 1 %s\r\n"+
+		"Content-Length: 0\r\n\r\n", method, branch, method))
+}
+
+// traceResponse формирует ответ на запрос traceRequest
+func traceResponse(method sip.RequestMethod, branch string, code int) []byte {
+	return []byte(fmt.Sprintf("SIP/2.0 %d Reason\r\n"+
+		"v: SIP/2.0/UDP 10.0.0.1:5060;rport;branch=%s\r\n"+
+		"CSeq: 1 %s\r\n"+
+		"Content-Length: 4\r\n\r\nbody", code, branch, method))
+}
+
+func newTestTransactionStats() *transactionStats {
+	return &transactionStats{
+		hosts:   make(map[string]*DestinationStats),
+		pending: make(map[string]*pendingTx),
+	}
+}
+
+func TestTransactionStatsLatencyAndRetransmissions(t *testing.T) {
+	c := newTestTransactionStats()
+	start := time.Now()
+	trunk := "203.0.113.5:5060"
+
+	// INVITE повторен дважды, затем получены 100 и 200
+	c.observeWrite(trunk, traceRequest(sip.INVITE, "z9hG4bK-1"), start)
+	c.observeWrite(trunk, traceRequest(sip.INVITE, "z9hG4bK-1"), start.Add(500*time.Millisecond))
+	c.observeWrite(trunk, traceRequest(sip.INVITE, "z9hG4bK-1"), start.Add(1500*time.Millisecond))
+	c.observeRead(traceResponse(sip.INVITE, "z9hG4bK-1", 100), start.Add(1600*time.Millisecond))
+	c.observeRead(traceResponse(sip.INVITE, "z9hG4bK-1", 200), start.Add(3*time.Second))
+	// ACK не является транзакцией
+	c.observeWrite(trunk, traceRequest(sip.ACK, "z9hG4bK-2"), start.Add(3*time.Second))
+
+	// OPTIONS продолжает повторяться после предварительного ответа
+	c.observeWrite(trunk, traceRequest(sip.OPTIONS, "z9hG4bK-3"), start)
+	c.observeRead(traceResponse(sip.OPTIONS, "z9hG4bK-3", 100), start.Add(400*time.Millisecond))
+	c.observeWrite(trunk, traceRequest(sip.OPTIONS, "z9hG4bK-3"), start.Add(time.Second))
+	c.observeRead(traceResponse(sip.OPTIONS, "z9hG4bK-3", 200), start.Add(1100*time.Millisecond))
+
+	// Ответ на неизвестную транзакцию не учитывается
+	c.observeRead(traceResponse(sip.BYE, "z9hG4bK-4", 200), start)
+
+	stats := c.snapshot(start.Add(4 * time.Second))
+	require.Len(t, stats, 1)
+	s := stats[0]
+	assert.Equal(t, "203.0.113.5", s.Host)
+	assert.EqualValues(t, 2, s.Requests)
+	assert.EqualValues(t, 3, s.Retransmissions)
+	assert.EqualValues(t, 2, s.Answered)
+	assert.Zero(t, s.Timeouts)
+	assert.Equal(t, time.Second, s.AverageLatency())
+	assert.Equal(t, 1.5, s.RetransmissionRate())
+	assert.Empty(t, c.pending, "Завершенные транзакции не отслеживаются")
+}
+
+func TestTransactionStatsTimeout(t *testing.T) {
+	c := newTestTransactionStats()
+	start := time.Now()
+
+	c.observeWrite("198.51.100.7:5060", traceRequest(sip.INVITE, "z9hG4bK-lost"), start)
+	c.observeWrite("198.51.100.8:5060", traceRequest(sip.REGISTER, "z9hG4bK-ok"), start)
+	c.observeRead(traceResponse(sip.REGISTER, "z9hG4bK-ok", 200), start.Add(20*time.Millisecond))
+
+	stats := c.snapshot(start.Add(time.Second))
+	require.Len(t, stats, 2)
+	assert.Zero(t, stats[0].Timeouts, "Таймаут до истечения 64*T1")
+
+	stats = c.snapshot(start.Add(sip.Timer_B + time.Second))
+	require.Len(t, stats, 2)
+	assert.Equal(t, "198.51.100.7", stats[0].Host)
+	assert.EqualValues(t, 1, stats[0].Timeouts)
+	assert.Equal(t, 1.0, stats[0].TimeoutRate())
+	assert.Zero(t, stats[1].TimeoutRate())
+	assert.Equal(t, 20*time.Millisecond, stats[1].AverageLatency())
+	assert.Empty(t, c.pending)
+}
+
+func TestSplitTraceMessages(t *testing.T) {
+	stream := append(append([]byte("\r\n\r\n"), traceResponse(sip.INVITE, "z9hG4bK-a", 180)...),
+		traceResponse(sip.INVITE, "z9hG4bK-b", 200)...)
+
+	messages := splitTraceMessages(stream)
+	require.Len(t, messages, 2)
+
+	for i, branch := range []string{"z9hG4bK-a", "z9hG4bK-b"} {
+		request, method, gotBranch, ok := parseTraceMessage(messages[i])
+		require.True(t, ok)
+		assert.False(t, request)
+		assert.Equal(t, "INVITE", method)
+		assert.Equal(t, branch, gotBranch)
+	}
+	assert.Equal(t, 180, traceStatusCode(messages[0]))
+	assert.Empty(t, splitTraceMessages([]byte("\r\n\r\n")), "Keepalive не является сообщением")
+}
@@ -0,0 +1,117 @@
+package dialog
+
+import (
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/emiago/sipgo/sip"
+)
+
+const (
+	// udpMaxRequestSize - максимальный размер запроса для UDP при неизвестном MTU.
+	// Запросы большего размера должны отправляться по TCP (RFC 3261 Section 18.1.1)
+	udpMaxRequestSize = 1300
+	// viaHeaderReserve - запас под заголовок Via, который добавляется при отправке
+	viaHeaderReserve = 120
+)
+
+// transportPreferences запоминает транспорт, выбранный для адреса назначения
+// после перехода с UDP на TCP, чтобы последующие запросы сразу шли по TCP
+type transportPreferences struct {
+	mu    sync.RWMutex
+	prefs map[string]TransportType
+}
+
+func (p *transportPreferences) get(destination string) (TransportType, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	tp, ok := p.prefs[destination]
+	return tp, ok
+}
+
+func (p *transportPreferences) set(destination string, tp TransportType) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.prefs == nil {
+		p.prefs = make(map[string]TransportType)
+	}
+	p.prefs[destination] = tp
+}
+
+// PreferredTransport возвращает транспорт, запомненный для адреса назначения
+// (host:port) после автоматического перехода на TCP
+func (u *UACUAS) PreferredTransport(destination string) (TransportType, bool) {
+	return u.transportPrefs.get(destination)
+}
+
+// tcpTransportConfig возвращает сконфигурированный TCP транспорт
+func (u *UACUAS) tcpTransportConfig() (TransportConfig, bool) {
+	for _, tc := range u.config.TransportConfigs {
+		if tc.Type == TransportTCP {
+			return tc, true
+		}
+	}
+	return TransportConfig{}, false
+}
+
+// selectRequestTransport выбирает транспорт для исходящего запроса.
+// Если для адреса назначения уже запомнен TCP или запрос не помещается в UDP
+// пакет, запрос переводится на TCP (при наличии TCP транспорта в конфигурации).
+func (u *UACUAS) selectRequestTransport(req *sip.Request) {
+	if u == nil || !strings.EqualFold(req.Transport(), string(TransportUDP)) {
+		return
+	}
+
+	destination := req.Destination()
+	if tp, ok := u.transportPrefs.get(destination); ok && tp == TransportTCP {
+		u.switchToTCP(req)
+		return
+	}
+
+	if len(req.String())+viaHeaderReserve > udpMaxRequestSize {
+		u.fallbackToTCP(req)
+	}
+}
+
+// fallbackToTCP переводит запрос, не помещающийся в UDP, на TCP и запоминает
+// выбор для адреса назначения. Возвращает false, если TCP не сконфигурирован.
+func (u *UACUAS) fallbackToTCP(req *sip.Request) bool {
+	if !u.switchToTCP(req) {
+		slog.Warn("Запрос превышает допустимый размер для UDP, но TCP транспорт не сконфигурирован",
+			slog.String("method", req.Method.String()),
+			slog.String("destination", req.Destination()),
+			slog.Int("size", len(req.String())))
+		return false
+	}
+
+	u.transportPrefs.set(req.Destination(), TransportTCP)
+	slog.Info("Запрос превышает допустимый размер для UDP, используется TCP",
+		slog.String("method", req.Method.String()),
+		slog.String("destination", req.Destination()),
+		slog.Int("size", len(req.String())))
+	return true
+}
+
+// switchToTCP устанавливает TCP транспорт и локальный адрес TCP транспорта.
+// Via, добавленный при неудачной попытке отправки по UDP, удаляется, чтобы
+// при повторной отправке он был создан заново с транспортом TCP.
+func (u *UACUAS) switchToTCP(req *sip.Request) bool {
+	tc, ok := u.tcpTransportConfig()
+	if !ok {
+		return false
+	}
+
+	if via := req.Via(); via != nil && strings.EqualFold(via.Transport, string(TransportUDP)) {
+		req.RemoveHeader("Via")
+	}
+
+	req.SetTransport(string(TransportTCP))
+	req.Laddr = sip.Addr{
+		IP:       net.ParseIP(tc.Host),
+		Hostname: tc.Host,
+		Port:     tc.Port,
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package dialog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLargeInvite() *sip.Request {
+	req := newTestRequest(sip.INVITE)
+	req.SetBody([]byte(strings.Repeat("a=candidate:1 1 UDP 2130706431 192.168.1.100 10000 typ host\r\n", 30)))
+	return req
+}
+
+func TestTransportFallbackToTCP(t *testing.T) {
+	uacuas, err := NewUACUAS(Config{
+		UserAgent: "TestUA/1.0",
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 15093},
+			{Type: TransportTCP, Host: "127.0.0.1", Port: 15094},
+		},
+	})
+	require.NoError(t, err)
+	defer uacuas.Stop()
+
+	// Маленький запрос остается на UDP
+	small := newTestRequest(sip.OPTIONS)
+	uacuas.selectRequestTransport(small)
+	assert.Equal(t, "UDP", small.Transport())
+
+	// Большой INVITE переводится на TCP, Via от попытки по UDP удаляется
+	large := newLargeInvite()
+	require.Greater(t, len(large.String()), udpMaxRequestSize)
+	uacuas.selectRequestTransport(large)
+	assert.Equal(t, "TCP", large.Transport())
+	assert.Nil(t, large.Via(), "Via должен быть создан заново при отправке по TCP")
+	assert.Equal(t, 15094, large.Laddr.Port)
+
+	// Выбор запоминается для адреса назначения
+	tp, ok := uacuas.PreferredTransport(large.Destination())
+	require.True(t, ok)
+	assert.Equal(t, TransportTCP, tp)
+
+	next := newTestRequest(sip.BYE)
+	uacuas.selectRequestTransport(next)
+	assert.Equal(t, "TCP", next.Transport(), "Последующие запросы к адресу идут по TCP")
+}
+
+func TestTransportFallbackWithoutTCP(t *testing.T) {
+	uacuas, err := NewUACUAS(Config{
+		UserAgent: "TestUA/1.0",
+		TransportConfigs: []TransportConfig{
+			{Type: TransportUDP, Host: "127.0.0.1", Port: 15095},
+		},
+	})
+	require.NoError(t, err)
+	defer uacuas.Stop()
+
+	large := newLargeInvite()
+	uacuas.selectRequestTransport(large)
+	assert.Equal(t, "UDP", large.Transport(), "Без TCP транспорта запрос остается на UDP")
+
+	_, ok := uacuas.PreferredTransport(large.Destination())
+	assert.False(t, ok)
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/arzzra/soft_phone/pkg/random"
 	"github.com/emiago/sipgo"
 	"github.com/emiago/sipgo/sip"
 	"golang.org/x/sync/errgroup"
@@ -31,6 +34,74 @@ type Config struct {
 	TransportConfigs []TransportConfig
 	// TestMode - включает тестовый режим с предсказуемыми значениями
 	TestMode bool
+	// Features - включенные опциональные возможности (REFER, UPDATE, PRACK, timer, replaces).
+	// Определяют заголовки Allow/Supported и обработку входящих запросов.
+	// Если nil, используется DefaultFeatures()
+	Features []Feature
+	// CompactHeaders - отправлять заголовки в компактной форме (f, t, i, m, ...),
+	// чтобы уменьшить размер UDP пакетов с большими SDP телами
+	CompactHeaders bool
+	// BodyCompression - сжатие тел сообщений gzip для доверенных узлов.
+	// Если nil, тела не сжимаются и не распаковываются
+	BodyCompression *BodyCompressionConfig
+	// SendReasonHeader - добавлять заголовок Reason (RFC 3326) в отправляемые BYE
+	// (Q.850;cause=16) и CANCEL (SIP;cause=487). Требуется многим операторам для биллинга
+	SendReasonHeader bool
+	// RedirectPolicy - политика повтора исходящего INVITE по ответам 3xx
+	// (например, FollowContacts или EmergencyRedirect для 380). Если nil,
+	// вызов завершается по ответу 3xx
+	RedirectPolicy RedirectPolicy
+	// MaxRedirects - максимальное количество повторов INVITE по ответам 3xx
+	// в одном вызове (по умолчанию 5)
+	MaxRedirects int
+	// ReofferPolicy - формирование нового offer для повтора исходящего INVITE
+	// по ответу 488 (например, ReduceOffer по SDP возможностей в ответе).
+	// Если nil, вызов завершается по ответу 488
+	ReofferPolicy ReofferPolicy
+	// MaxReoffers - максимальное количество повторов INVITE с новым offer
+	// в одном вызове (по умолчанию 2)
+	MaxReoffers int
+	// CallLimit - лимит длительности разговора для всех вызовов
+	// (Dialog.SetCallLimit задает лимит отдельного вызова)
+	CallLimit CallLimit
+	// TenantCallLimits - лимиты длительности по tenant вызова (Dialog.SetTenant),
+	// имеют приоритет над CallLimit
+	TenantCallLimits map[string]CallLimit
+	// Webhooks - отправка событий вызовов (создан, отвечен, завершен)
+	// внешним системам HTTP запросами. Если nil, события не отправляются
+	Webhooks *WebhookConfig
+	// CallPolicy - правила приема, отклонения и перенаправления входящих
+	// вызовов. Правила можно обновлять во время работы (CallPolicy.LoadFile)
+	CallPolicy *CallPolicy
+	// Identity - проверка заголовков Identity входящих INVITE и подпись
+	// исходящих (STIR/SHAKEN). Если nil, Identity не обрабатывается
+	Identity *IdentityConfig
+	// MediaProbe - проверка доступности медиа адреса offer (STUN или RTP)
+	// перед отправкой 200 OK на входящий INVITE. Если nil, не выполняется
+	MediaProbe *MediaProbeConfig
+	// Redaction - скрытие номеров, имен и учетных данных в трассировке SIP
+	// и логах этого UACUAS. Во время работы меняется UACUAS.SetRedaction.
+	// Если nil, действует конфигурация процесса (SetRedaction пакета)
+	Redaction *RedactionConfig
+	// RandomSource - источник случайных данных для Call-ID, тегов и начального
+	// CSeq. nil - crypto/rand; тесты задают random.NewSeeded, чтобы повторить
+	// точную последовательность идентификаторов
+	RandomSource io.Reader
+	// ParallelContact - выбор адреса и транспорта первичного INVITE попытками
+	// OPTIONS со сдвигом по времени (Happy Eyeballs), если адресат разрешается
+	// в несколько адресов или настроено несколько транспортов. Если nil,
+	// INVITE отправляется по первому адресу
+	ParallelContact *ParallelContactConfig
+	// CallSetupBuckets - границы корзин гистограмм PDD и времени установления
+	// исходящих вызовов (CallSetupMetrics) в секундах. Пустой -
+	// DefaultCallSetupBuckets
+	CallSetupBuckets []float64
+	// SetupTimeouts - таймеры ожидания 18x и финального ответа на исходящий
+	// INVITE по умолчанию. Нулевые значения - без ограничения
+	SetupTimeouts SetupTimeouts
+	// DestinationSetupTimeouts - таймеры установления по назначению
+	// Request-URI: ключ "host:port" или "host". Переопределяют SetupTimeouts
+	DestinationSetupTimeouts map[string]SetupTimeouts
 }
 
 // UACUAS является менеджером SIP диалогов, объединяющим функциональность
@@ -54,6 +125,35 @@ type UACUAS struct {
 	cb      OnIncomingCall
 	// registrations - хранилище регистраций SIP пользователей
 	registrations map[string]*Registration
+	// capabilities - включенные возможности для Allow/Supported и проверки запросов
+	capabilities *Capabilities
+	// transportPrefs - транспорт, выбранный для адресов назначения после перехода на TCP
+	transportPrefs transportPreferences
+	// compressionPeers - доверенные узлы, объявившие Accept-Encoding: gzip
+	compressionPeers compressionPeers
+	// contactPaths - пути к адресатам, выбранные по Config.ParallelContact
+	contactPaths contactPathCache
+	// resolveHost разрешает имена адресатов (nil - net.DefaultResolver)
+	resolveHost func(ctx context.Context, host string) ([]net.IP, error)
+	// sharedLine - общая линия, для которой UACUAS является агентом appearance
+	sharedLine   *SharedLine
+	sharedLineMu sync.Mutex
+	// registrar - встроенный регистратор (SetRegistrar)
+	registrar   *Registrar
+	registrarMu sync.Mutex
+	// webhooks - отправка событий вызовов по Config.Webhooks
+	webhooks *WebhookNotifier
+	// setupMetrics - гистограммы установления исходящих вызовов
+	setupMetrics *CallSetupMetrics
+	// redaction - скрытие персональных данных этого UACUAS, nil - действует
+	// конфигурация процесса
+	redaction atomic.Pointer[redactor]
+
+	// randomSource - источник идентификаторов из Config.RandomSource, nil - crypto/rand
+	randomSource io.Reader
+	// newTag и newCallID генерируют теги и Call-ID диалогов этого UACUAS
+	newTag    tagGen
+	newCallID callIdGen
 
 	dialogs *dialogsMap
 
@@ -80,9 +180,6 @@ type Registration struct {
 type tagGen func() string
 type callIdGen func() string
 
-var newTag tagGen
-var newCallId callIdGen
-
 // NewUACUAS создает новый менеджер SIP диалогов с указанной конфигурацией.
 // Инициализирует SIP user agent, сервер и клиент для обработки сообщений.
 //
@@ -97,6 +194,9 @@ func NewUACUAS(cfg Config) (*UACUAS, error) {
 	}
 
 	// Устанавливаем значения по умолчанию
+	if cfg.Features == nil {
+		cfg.Features = DefaultFeatures()
+	}
 	userAgent := cfg.UserAgent
 	if userAgent == "" {
 		userAgent = "SoftPhone/1.0"
@@ -115,47 +215,59 @@ func NewUACUAS(cfg Config) (*UACUAS, error) {
 		return nil, err
 	}
 
-	sip.SIPDebug = true
+	installSIPTracer()
 
 	// Создаем контекст с функцией отмены
 	ctx, cancel := context.WithCancel(context.Background())
 
 	uu := &UACUAS{
-		ua:     ua,
-		uas:    srv,
-		uac:    uac,
-		config: cfg,
-		ctx:    ctx,
-		cancel: cancel,
+		ua:           ua,
+		uas:          srv,
+		uac:          uac,
+		config:       cfg,
+		randomSource: cfg.RandomSource,
+		capabilities: NewCapabilities(cfg.Features...),
+		setupMetrics: NewCallSetupMetrics(cfg.CallSetupBuckets),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	if cfg.Webhooks != nil {
+		webhooks, err := NewWebhookNotifier(*cfg.Webhooks)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		uu.webhooks = webhooks
 	}
+	uu.redaction.Store(newRedactor(cfg.Redaction))
+	tracedUAs.Store(uu, struct{}{})
 	uu.onRequests()
+	// Входящие запросы могут содержать заголовки в компактной форме. Сжатые
+	// тела распаковываются в withCapabilities, где на ошибку можно ответить
+	srv.ServeRequest(func(r *sip.Request) { expandCompactHeaders(r) })
 	// Инициализируем профиль по умолчанию
 	uu.profile = *uu.defaultProfile()
 	// TODO: cb пока не используется
 	// cb = callbacks
-	newTag = func() string { return sip.RandString(8) }
-	newCallId = func() string { return sip.RandString(32) }
+	uu.newTag = func() string { return random.String(uu.randomSource, 8) }
+	uu.newCallID = func() string { return random.String(uu.randomSource, 32) }
 
 	// доп настройки для тестов
 	if uu.config.TestMode {
-		sip.SIPDebug = true
 		// В тестовом режиме используем предсказуемые, но уникальные значения
-		testCounter := 0
-		newTag = func() string {
-			testCounter++
-			return fmt.Sprintf("testMode%d", testCounter)
+		var testCounter, testCallIDCounter atomic.Int64
+		uu.newTag = func() string {
+			return fmt.Sprintf("testMode%d", testCounter.Add(1))
 		}
-		testCallIdCounter := 0
-		newCallId = func() string {
-			testCallIdCounter++
-			return fmt.Sprintf("test%d%d", time.Now().UnixNano(), testCallIdCounter)
+		uu.newCallID = func() string {
+			return fmt.Sprintf("test%d%d", time.Now().UnixNano(), testCallIDCounter.Add(1))
 		}
 
 		uu.initSessionsMap(func() string {
 			return "qwerty"
 		})
 	} else {
-		uu.initSessionsMap(newTag)
+		uu.initSessionsMap(uu.newTag)
 	}
 
 	return uu, nil
@@ -264,14 +376,58 @@ func (u *UACUAS) ServeTCP(l net.Listener) error {
 }
 
 func (u *UACUAS) onRequests() {
-	u.uas.OnInvite(u.handleInvite)
-	u.uas.OnCancel(u.handleCancel)
-	u.uas.OnBye(u.handleBye)
-	u.uas.OnAck(u.handleACK)
-	u.uas.OnUpdate(u.handleUpdate)
-	u.uas.OnOptions(u.handleOptions)
-	u.uas.OnNotify(u.handleNotify)
-	u.uas.OnRegister(u.handleRegister)
+	u.uas.OnInvite(u.withCapabilities(u.handleInvite))
+	u.uas.OnCancel(u.withCapabilities(u.handleCancel))
+	u.uas.OnBye(u.withCapabilities(u.handleBye))
+	u.uas.OnAck(u.withCapabilities(u.handleACK))
+	u.uas.OnUpdate(u.withCapabilities(u.handleUpdate))
+	u.uas.OnOptions(u.withCapabilities(u.handleOptions))
+	u.uas.OnNotify(u.withCapabilities(u.handleNotify))
+	u.uas.OnRegister(u.withCapabilities(u.handleRegister))
+	u.uas.OnRefer(u.withCapabilities(u.handleRefer))
+	u.uas.OnPrack(u.withCapabilities(u.handlePrack))
+	u.uas.OnInfo(u.withCapabilities(u.handleInfo))
+	u.uas.OnSubscribe(u.withCapabilities(u.handleSubscribe))
+	u.uas.OnNoRoute(u.handleMethodNotAllowed)
+}
+
+// SetRedaction включает, меняет или выключает скрытие персональных данных
+// в трассировке SIP и логах этого UACUAS. nil - действует конфигурация
+// процесса (SetRedaction пакета). Безопасно вызывать во время работы
+func (u *UACUAS) SetRedaction(cfg *RedactionConfig) {
+	u.redaction.Store(newRedactor(cfg))
+}
+
+// activeRedactor возвращает действующие правила скрытия данных UACUAS.
+// Для nil UACUAS (диалоги вне UACUAS) - конфигурацию процесса
+func (u *UACUAS) activeRedactor() *redactor {
+	if u != nil {
+		if r := u.redaction.Load(); r != nil {
+			return r
+		}
+	}
+	return defaultRedactor.Load()
+}
+
+// redact скрывает персональные данные в SIP сообщении или строке лога
+func (u *UACUAS) redact(s string) string {
+	return u.activeRedactor().redact(s)
+}
+
+// listensOn сообщает, слушает ли UACUAS порт port
+func (u *UACUAS) listensOn(port int) bool {
+	for _, tc := range u.config.TransportConfigs {
+		if tc.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities возвращает набор возможностей UACUAS.
+// Изменения набора сразу влияют на заголовки Allow/Supported и обработку запросов.
+func (u *UACUAS) Capabilities() *Capabilities {
+	return u.capabilities
 }
 
 func (u *UACUAS) writeMsg(req *sip.Request) error {
@@ -283,7 +439,14 @@ func (u *UACUAS) writeMsg(req *sip.Request) error {
 	}
 	u.stopMutex.Unlock()
 
-	return u.uac.WriteRequest(req, sipgo.ClientRequestAddVia)
+	u.prepareOutgoing(req)
+	u.selectRequestTransport(req)
+
+	err := u.uac.WriteRequest(req, sipgo.ClientRequestAddVia)
+	if errors.Is(err, sip.ErrUDPMTUCongestion) && u.fallbackToTCP(req) {
+		err = u.uac.WriteRequest(req, sipgo.ClientRequestAddVia)
+	}
+	return err
 }
 
 func (u *UACUAS) initSessionsMap(f func() string) {
@@ -328,6 +491,7 @@ func (u *UACUAS) Stop() error {
 
 	// Устанавливаем флаг остановки
 	u.stopped = true
+	tracedUAs.Delete(u)
 
 	// Собираем ошибки при закрытии диалогов
 	var errs []error
@@ -360,6 +524,11 @@ func (u *UACUAS) Stop() error {
 		u.cancel()
 	}
 
+	// Отправляем оставшиеся события завершения вызовов
+	if u.webhooks != nil {
+		u.webhooks.Close()
+	}
+
 	// Возвращаем агрегированную ошибку, если были проблемы при закрытии диалогов
 	if len(errs) > 0 {
 		return fmt.Errorf("ошибки при остановке UACUAS: %v", errs)
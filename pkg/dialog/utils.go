@@ -43,13 +43,17 @@ func (s *Dialog) RemoteContact() *sip.ContactHeader {
 
 // LocalURIPtr возвращает указатель на локальный URI
 func (s *Dialog) LocalURIPtr() *sip.Uri {
+	s.uriMu.Lock()
 	ret := s.localTarget
+	s.uriMu.Unlock()
 	return &ret
 }
 
 // RemoteURIPtr возвращает указатель на удаленный URI
 func (s *Dialog) RemoteURIPtr() *sip.Uri {
+	s.uriMu.Lock()
 	ret := s.remoteTarget
+	s.uriMu.Unlock()
 	return &ret
 }
 
@@ -61,27 +65,42 @@ func (s *Dialog) RemoteURIPtr() *sip.Uri {
 //	return s.localURI
 //}
 
-// RemoteSDP возвращает удаленный SDP
+// RemoteSDP возвращает удаленный SDP. Для составного тела возвращается
+// часть application/sdp (пустое тело, если ее нет), тело целиком доступно
+// через RemoteBody
 func (s *Dialog) RemoteSDP() Body {
-	return s.remoteBody
+	remoteBody := s.RemoteBody()
+	if remoteBody.IsMultipart() {
+		if part, ok := remoteBody.Part(ContentTypeSDP); ok {
+			return *part
+		}
+		return Body{}
+	}
+	return remoteBody
 }
 
 // LocalSDP возвращает локальный SDP
 func (s *Dialog) LocalSDP() Body {
+	s.uriMu.Lock()
+	defer s.uriMu.Unlock()
 	return s.localBody
 }
 
 // SetRemoteSDP сохраняет тело внешнего участника
 func (s *Dialog) SetRemoteSDP(contentType string, content []byte) *Dialog {
+	s.uriMu.Lock()
 	s.remoteBody.contentType = contentType
 	s.remoteBody.content = content
+	s.uriMu.Unlock()
 	return s
 }
 
 // SetLocalSDP сохраняет тело локального участника
 func (s *Dialog) SetLocalSDP(contentType string, content []byte) *Dialog {
+	s.uriMu.Lock()
 	s.localBody.contentType = contentType
 	s.localBody.content = content
+	s.uriMu.Unlock()
 	return s
 }
 
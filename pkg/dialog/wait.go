@@ -0,0 +1,69 @@
+package dialog
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pkg/errors"
+)
+
+// Блокирующие варианты API для скриптов и простых утилит: вместо обработчиков
+// и каналов вызывающий код ждет нужного события с ограничением по ctx.
+
+// WaitFinal блокирует до финального ответа (2xx-6xx) клиентской транзакции и
+// возвращает его. Ответ возвращается после обработки диалогом: после 2xx на
+// INVITE диалог уже находится в InCall. Если транзакция завершилась без
+// финального ответа (таймаут, ошибка транспорта), возвращается ее ошибка.
+// Канал Responses() при этом не читается и остается доступен.
+func (t *TX) WaitFinal(ctx context.Context) (*sip.Response, error) {
+	if t.IsServer() {
+		return nil, fmt.Errorf("cannot wait for final response on server transaction")
+	}
+
+	select {
+	case <-t.final:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if t.finalResp != nil {
+		return t.finalResp, nil
+	}
+	if err := t.tx.Err(); err != nil {
+		return nil, errors.Wrapf(err, "%s завершен без финального ответа", t.req.Method)
+	}
+	return nil, fmt.Errorf("%s завершен без финального ответа", t.req.Method)
+}
+
+// setFinal фиксирует финальный ответ, nil - завершение без ответа
+func (t *TX) setFinal(resp *sip.Response) {
+	t.finalClose.Do(func() {
+		t.finalResp = resp
+		close(t.final)
+	})
+}
+
+// WaitEnded блокирует до перехода диалога в состояние Ended. Причина
+// завершения доступна через ReleaseCause. Если диалог уже завершен,
+// возвращает nil сразу.
+func (s *Dialog) WaitEnded(ctx context.Context) error {
+	select {
+	case <-s.endedChan():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// endedChan возвращает канал, закрываемый при переходе в Ended
+func (s *Dialog) endedChan() chan struct{} {
+	s.endedOnce.Do(func() { s.ended = make(chan struct{}) })
+	return s.ended
+}
+
+// markEnded закрывает канал WaitEnded
+func (s *Dialog) markEnded() {
+	ch := s.endedChan()
+	s.endedClose.Do(func() { close(ch) })
+}
@@ -0,0 +1,106 @@
+package dialog
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWaitFinalAndEnded проверяет блокирующие варианты API: WaitFinal
+// возвращает финальный ответ после его обработки диалогом, WaitEnded
+// завершается при получении BYE
+func TestWaitFinalAndEnded(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	peerURI := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: peer.LocalAddr().(*net.UDPAddr).Port}
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 15105}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	read := func(method sip.RequestMethod) (*sip.Request, *net.UDPAddr) {
+		buf := make([]byte, 4096)
+		require.NoError(t, peer.SetReadDeadline(time.Now().Add(3*time.Second)))
+		for {
+			n, from, err := peer.ReadFromUDP(buf)
+			require.NoError(t, err, "ожидался %s", method)
+			msg, err := sip.ParseMessage(buf[:n])
+			require.NoError(t, err)
+			if req, ok := msg.(*sip.Request); ok && req.Method == method {
+				return req, from
+			}
+		}
+	}
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+
+	tx, err := d.Start(ctx, peerURI.String())
+	require.NoError(t, err)
+	invite, from := read(sip.INVITE)
+
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	_, err = tx.WaitFinal(short)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "До ответа WaitFinal ждет")
+
+	ringing := sip.NewResponseFromRequest(invite, sip.StatusRinging, "Ringing", nil)
+	ringing.To().Params.Add("tag", "peertag")
+	_, err = peer.WriteToUDP([]byte(ringing.String()), from)
+	require.NoError(t, err)
+	ok := sip.NewResponseFromRequest(invite, sip.StatusOK, "OK", nil)
+	ok.To().Params.Add("tag", "peertag")
+	ok.AppendHeader(&sip.ContactHeader{Address: peerURI})
+	_, err = peer.WriteToUDP([]byte(ok.String()), from)
+	require.NoError(t, err)
+
+	waitCtx, cancelWait := context.WithTimeout(ctx, 2*time.Second)
+	defer cancelWait()
+	resp, err := tx.WaitFinal(waitCtx)
+	require.NoError(t, err)
+	assert.Equal(t, sip.StatusOK, resp.StatusCode, "Предварительный ответ пропускается")
+	assert.Equal(t, InCall, d.State(), "Ответ обработан диалогом до возврата")
+	read(sip.ACK)
+
+	resp, err = tx.WaitFinal(waitCtx)
+	require.NoError(t, err, "Повторный вызов возвращает тот же ответ")
+	assert.Equal(t, sip.StatusOK, resp.StatusCode)
+
+	short2, cancelShort2 := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort2()
+	assert.ErrorIs(t, d.WaitEnded(short2), context.DeadlineExceeded, "Диалог еще активен")
+
+	bye := sip.NewRequest(sip.BYE, invite.Contact().Address)
+	bye.AppendHeader(&sip.ViaHeader{
+		ProtocolName: "SIP", ProtocolVersion: "2.0", Transport: "UDP",
+		Host: "127.0.0.1", Port: peerURI.Port,
+		Params: sip.NewParams().Add("branch", sip.GenerateBranch()),
+	})
+	from2 := ok.To().AsFrom()
+	bye.AppendHeader(&from2)
+	to := invite.From().AsTo()
+	bye.AppendHeader(&to)
+	bye.AppendHeader(sip.HeaderClone(invite.CallID()))
+	bye.AppendHeader(&sip.CSeqHeader{SeqNo: 1, MethodName: sip.BYE})
+	bye.AppendHeader(sip.NewHeader("Max-Forwards", "70"))
+	_, err = peer.WriteToUDP([]byte(bye.String()), from)
+	require.NoError(t, err)
+
+	require.NoError(t, d.WaitEnded(waitCtx))
+	assert.Equal(t, Ended, d.State())
+	assert.NoError(t, d.WaitEnded(context.Background()), "Для завершенного диалога возврат сразу")
+}
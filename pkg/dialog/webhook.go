@@ -0,0 +1,556 @@
+package dialog
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/pion/sdp/v3"
+)
+
+// WebhookEvent - тип события, отправляемого webhook
+type WebhookEvent string
+
+const (
+	// WebhookCallCreated - создан вызов (отправлен или получен INVITE)
+	WebhookCallCreated WebhookEvent = "call.created"
+	// WebhookCallAnswered - вызов отвечен
+	WebhookCallAnswered WebhookEvent = "call.answered"
+	// WebhookCallEnded - вызов завершен, содержит причину завершения
+	WebhookCallEnded WebhookEvent = "call.ended"
+	// WebhookMediaNegotiated - медиа согласовано: на SDP offer в INVITE или
+	// UPDATE получен или отправлен 2xx с SDP answer
+	WebhookMediaNegotiated WebhookEvent = "media.negotiated"
+	// WebhookMediaFailed - согласование медиа не удалось: offer отклонен
+	// ответом 488 или answer не принял ни одного аудио потока
+	WebhookMediaFailed WebhookEvent = "media.failed"
+)
+
+// Заголовки запросов webhook
+const (
+	WebhookHeaderEvent     = "X-Softphone-Event"
+	WebhookHeaderID        = "X-Softphone-Delivery"
+	WebhookHeaderSignature = "X-Softphone-Signature"
+	WebhookHeaderRetry     = "X-Softphone-Retry"
+)
+
+// Значения по умолчанию для WebhookConfig
+const (
+	defaultWebhookRetries    = 3
+	defaultWebhookRetryDelay = 500 * time.Millisecond
+	defaultWebhookTimeout    = 5 * time.Second
+	defaultWebhookQueueSize  = 256
+)
+
+// WebhookConfig содержит параметры отправки событий вызовов внешним
+// системам (CRM, биллинг) HTTP POST запросами с JSON телом.
+type WebhookConfig struct {
+	// URLs - адреса, на которые отправляется каждое событие
+	URLs []string
+	// Secret - ключ подписи HMAC-SHA256 тела запроса. Подпись передается в
+	// заголовке X-Softphone-Signature в виде "sha256=<hex>". Пустой ключ -
+	// без подписи
+	Secret string
+	// Events - отправляемые события. nil - все события
+	Events []WebhookEvent
+	// MaxRetries - количество повторов при сетевой ошибке, ответе 5xx или
+	// 429 (по умолчанию 3). Отрицательное значение отключает повторы
+	MaxRetries int
+	// RetryDelay - задержка перед первым повтором, далее удваивается
+	// (по умолчанию 500ms)
+	RetryDelay time.Duration
+	// Timeout - таймаут одного запроса (по умолчанию 5s)
+	Timeout time.Duration
+	// QueueSize - размер очереди событий (по умолчанию 256). При
+	// переполнении новые события отбрасываются
+	QueueSize int
+	// Client - HTTP клиент. Если nil, используется клиент с Timeout
+	Client *http.Client
+}
+
+// WebhookMedia описывает согласованное медиа
+type WebhookMedia struct {
+	Codec         string `json:"codec,omitempty"`
+	PayloadType   int    `json:"payload_type"`
+	ClockRate     int    `json:"clock_rate,omitempty"`
+	Direction     string `json:"direction,omitempty"`
+	LocalAddress  string `json:"local_address,omitempty"`
+	RemoteAddress string `json:"remote_address,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// WebhookPayload - JSON тело запроса webhook
+type WebhookPayload struct {
+	ID           string        `json:"id"`
+	Event        WebhookEvent  `json:"event"`
+	Timestamp    time.Time     `json:"timestamp"`
+	DialogID     string        `json:"dialog_id"`
+	CallID       string        `json:"call_id"`
+	LocalTag     string        `json:"local_tag,omitempty"`
+	RemoteTag    string        `json:"remote_tag,omitempty"`
+	Role         string        `json:"role"` // UAC или UAS
+	LocalURI     string        `json:"local_uri,omitempty"`
+	RemoteURI    string        `json:"remote_uri,omitempty"`
+	State        DialogState   `json:"state"`
+	Tenant       string        `json:"tenant,omitempty"`
+	ReleaseCause *ReleaseCause `json:"release_cause,omitempty"`
+	Media        *WebhookMedia `json:"media,omitempty"`
+}
+
+// WebhookNotifier отправляет события вызовов на настроенные адреса.
+// События отправляются одной горутиной в порядке возникновения, поэтому
+// получатель видит call.created раньше call.ended. Ошибки доставки
+// логируются и не влияют на обработку вызовов.
+//
+// Пример конфигурации:
+//
+//	cfg := dialog.Config{
+//	    Webhooks: &dialog.WebhookConfig{
+//	        URLs:   []string{"https://crm.example.com/sip-events"},
+//	        Secret: os.Getenv("WEBHOOK_SECRET"),
+//	        Events: []dialog.WebhookEvent{dialog.WebhookCallAnswered, dialog.WebhookCallEnded},
+//	    },
+//	}
+//
+// Проверка подписи на стороне получателя:
+//
+//	mac := hmac.New(sha256.New, []byte(secret))
+//	mac.Write(body)
+//	valid := hmac.Equal([]byte(r.Header.Get("X-Softphone-Signature")),
+//	    []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+type WebhookNotifier struct {
+	config WebhookConfig
+	client *http.Client
+	events map[WebhookEvent]bool
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan WebhookPayload
+	seq    atomic.Uint64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWebhookNotifier проверяет конфигурацию и запускает отправку событий
+func NewWebhookNotifier(config WebhookConfig) (*WebhookNotifier, error) {
+	if len(config.URLs) == 0 {
+		return nil, fmt.Errorf("webhook URLs are not configured")
+	}
+	for _, raw := range config.URLs {
+		parsed, err := url.Parse(raw)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid webhook URL %q", raw)
+		}
+	}
+
+	if config.MaxRetries == 0 {
+		config.MaxRetries = defaultWebhookRetries
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultWebhookRetryDelay
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultWebhookTimeout
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultWebhookQueueSize
+	}
+
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+
+	var events map[WebhookEvent]bool
+	if config.Events != nil {
+		events = make(map[WebhookEvent]bool, len(config.Events))
+		for _, event := range config.Events {
+			events[event] = true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &WebhookNotifier{
+		config: config,
+		client: client,
+		events: events,
+		queue:  make(chan WebhookPayload, config.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	n.wg.Add(1)
+	go n.run()
+
+	return n, nil
+}
+
+// Close прекращает прием событий и ожидает отправки очереди не дольше
+// Timeout, после чего незавершенные отправки прерываются
+func (n *WebhookNotifier) Close() {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.queue)
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(n.config.Timeout):
+		n.cancel()
+		<-done
+	}
+	n.cancel()
+}
+
+// MediaNegotiated отправляет событие media.negotiated. Диалог отправляет
+// события медиа сам по SDP offer/answer в INVITE и UPDATE; метод нужен, если
+// медиа согласуется иначе, например при offer в 2xx и answer в ACK.
+// Методы событий медиа допускают nil WebhookNotifier
+func (n *WebhookNotifier) MediaNegotiated(d IDialog, media WebhookMedia) {
+	n.notify(WebhookMediaNegotiated, d, &media)
+}
+
+// MediaFailed отправляет событие media.failed с описанием ошибки
+func (n *WebhookNotifier) MediaFailed(d IDialog, err error) {
+	media := WebhookMedia{}
+	if err != nil {
+		media.Error = err.Error()
+	}
+	n.notify(WebhookMediaFailed, d, &media)
+}
+
+// notify формирует событие диалога и ставит его в очередь
+func (n *WebhookNotifier) notify(event WebhookEvent, d IDialog, media *WebhookMedia) {
+	if n == nil || (n.events != nil && !n.events[event]) {
+		return
+	}
+
+	localURI, remoteURI := d.LocalURI(), d.RemoteURI()
+	payload := WebhookPayload{
+		ID:           fmt.Sprintf("%d-%d", time.Now().UnixNano(), n.seq.Add(1)),
+		Event:        event,
+		Timestamp:    time.Now().UTC(),
+		DialogID:     d.ID(),
+		CallID:       string(d.CallID()),
+		LocalTag:     d.LocalTag(),
+		RemoteTag:    d.RemoteTag(),
+		LocalURI:     localURI.String(),
+		RemoteURI:    remoteURI.String(),
+		State:        d.State(),
+		ReleaseCause: d.ReleaseCause(),
+		Media:        media,
+	}
+	if dialog, ok := d.(*Dialog); ok {
+		payload.Role = dialog.uaType.String()
+		dialog.callLimit.mu.Lock()
+		payload.Tenant = dialog.callLimit.tenant
+		dialog.callLimit.mu.Unlock()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.queue <- payload:
+	default:
+		slog.Warn("Очередь webhook переполнена, событие отброшено",
+			slog.String("event", string(event)),
+			slog.String("dialogID", payload.DialogID))
+	}
+}
+
+// run отправляет события из очереди до закрытия
+func (n *WebhookNotifier) run() {
+	defer n.wg.Done()
+
+	for payload := range n.queue {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			slog.Error("Не удалось сериализовать событие webhook",
+				slog.String("event", string(payload.Event)),
+				slog.String("error", err.Error()))
+			continue
+		}
+		for _, target := range n.config.URLs {
+			if err := n.deliver(target, payload, body); err != nil {
+				slog.Error("Не удалось доставить событие webhook",
+					slog.String("url", target),
+					slog.String("event", string(payload.Event)),
+					slog.String("dialogID", payload.DialogID),
+					slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// deliver отправляет событие на один адрес с повторами
+func (n *WebhookNotifier) deliver(target string, payload WebhookPayload, body []byte) error {
+	delay := n.config.RetryDelay
+	var err error
+	for attempt := 0; attempt <= max(n.config.MaxRetries, 0); attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-n.ctx.Done():
+				return n.ctx.Err()
+			}
+			delay *= 2
+		}
+
+		var retry bool
+		retry, err = n.post(target, payload, body, attempt)
+		if err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// post выполняет одну попытку отправки. Возвращает признак, что попытку
+// имеет смысл повторить
+func (n *WebhookNotifier) post(target string, payload WebhookPayload, body []byte, attempt int) (bool, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, n.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderEvent, string(payload.Event))
+	req.Header.Set(WebhookHeaderID, payload.ID)
+	if attempt > 0 {
+		req.Header.Set(WebhookHeaderRetry, strconv.Itoa(attempt))
+	}
+	if n.config.Secret != "" {
+		req.Header.Set(WebhookHeaderSignature, SignWebhook(n.config.Secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("webhook responded with %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook rejected event with %d", resp.StatusCode)
+	}
+}
+
+// SignWebhook вычисляет значение заголовка X-Softphone-Signature для тела
+// запроса: "sha256=" и HMAC-SHA256 в шестнадцатеричном виде
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Webhooks возвращает отправку событий, настроенную Config.Webhooks, или nil
+func (u *UACUAS) Webhooks() *WebhookNotifier {
+	return u.webhooks
+}
+
+// Кодеки статических payload types (RFC 3551 Section 6)
+var staticPayloadTypes = map[int]struct {
+	codec     string
+	clockRate int
+}{
+	0:  {"PCMU", 8000},
+	3:  {"GSM", 8000},
+	4:  {"G723", 8000},
+	8:  {"PCMA", 8000},
+	9:  {"G722", 8000},
+	18: {"G729", 8000},
+}
+
+// errNoAudioAnswer - SDP answer не принял ни одного аудио потока
+var errNoAudioAnswer = errors.New("SDP answer has no accepted audio stream")
+
+// notifyMediaAnswer отправляет media.negotiated или media.failed по ответу
+// 2xx на INVITE или UPDATE с SDP offer. localAnswer - answer сформирован
+// локальной стороной (ответ отправлен). Без offer в запросе или answer в
+// ответе событие не отправляется
+func (s *Dialog) notifyMediaAnswer(req *sip.Request, resp *sip.Response, localAnswer bool) {
+	if s.uu == nil || s.uu.webhooks == nil || (req.Method != sip.INVITE && req.Method != sip.UPDATE) {
+		return
+	}
+	offer, answer := sdpContent(req), sdpContent(resp)
+	if offer == nil || answer == nil {
+		return
+	}
+
+	media, err := negotiatedMedia(offer, answer, localAnswer)
+	if err != nil {
+		s.uu.webhooks.MediaFailed(s, err)
+		return
+	}
+	s.uu.webhooks.MediaNegotiated(s, media)
+}
+
+// notifyMediaRejected отправляет media.failed по ответу 488 на INVITE или
+// UPDATE. sent - ответ отправлен локальной стороной
+func (s *Dialog) notifyMediaRejected(req *sip.Request, resp *sip.Response, sent bool) {
+	if s.uu == nil || s.uu.webhooks == nil || resp.StatusCode != sip.StatusNotAcceptableHere ||
+		(req.Method != sip.INVITE && req.Method != sip.UPDATE) {
+		return
+	}
+	side := "remote party"
+	if sent {
+		side = "local party"
+	}
+	s.uu.webhooks.MediaFailed(s, fmt.Errorf("%s rejected SDP offer with %d %s", side, resp.StatusCode, resp.Reason))
+}
+
+// sdpContent возвращает SDP из тела сообщения, в том числе из составного
+func sdpContent(msg sip.Message) []byte {
+	body := extractBody(msg)
+	if body == nil {
+		return nil
+	}
+	if body.IsMultipart() {
+		if part, ok := body.Part(ContentTypeSDP); ok {
+			return part.Content()
+		}
+		return nil
+	}
+	if !strings.HasPrefix(strings.ToLower(body.ContentType()), ContentTypeSDP) {
+		return nil
+	}
+	return body.Content()
+}
+
+// negotiatedMedia описывает первый аудио поток SDP answer: кодек первого
+// формата, направление с точки зрения локальной стороны и медиа адреса сторон
+func negotiatedMedia(offer, answer []byte, localAnswer bool) (WebhookMedia, error) {
+	var answerDesc, offerDesc sdp.SessionDescription
+	if err := answerDesc.UnmarshalString(string(answer)); err != nil {
+		return WebhookMedia{}, fmt.Errorf("invalid SDP answer: %w", err)
+	}
+	audio := firstAudioMedia(&answerDesc)
+	if audio == nil || audio.MediaName.Port.Value == 0 || len(audio.MediaName.Formats) == 0 {
+		return WebhookMedia{}, errNoAudioAnswer
+	}
+
+	media := WebhookMedia{Direction: mediaDirection(&answerDesc, audio)}
+	media.PayloadType, _ = strconv.Atoi(audio.MediaName.Formats[0])
+	if static, ok := staticPayloadTypes[media.PayloadType]; ok {
+		media.Codec, media.ClockRate = static.codec, static.clockRate
+	}
+	for _, attr := range audio.Attributes {
+		if attr.Key != "rtpmap" {
+			continue
+		}
+		if value, ok := strings.CutPrefix(attr.Value, audio.MediaName.Formats[0]+" "); ok {
+			codec, rate, _ := strings.Cut(strings.TrimSpace(value), "/")
+			rate, _, _ = strings.Cut(rate, "/")
+			media.Codec = codec
+			media.ClockRate, _ = strconv.Atoi(rate)
+			break
+		}
+	}
+
+	answerAddr, offerAddr := mediaAddress(&answerDesc, audio), ""
+	if offerDesc.UnmarshalString(string(offer)) == nil {
+		if offerAudio := firstAudioMedia(&offerDesc); offerAudio != nil {
+			offerAddr = mediaAddress(&offerDesc, offerAudio)
+		}
+	}
+	if localAnswer {
+		media.LocalAddress, media.RemoteAddress = answerAddr, offerAddr
+	} else {
+		media.LocalAddress, media.RemoteAddress = offerAddr, answerAddr
+		// Направление answer указано со стороны удаленного участника
+		switch media.Direction {
+		case "sendonly":
+			media.Direction = "recvonly"
+		case "recvonly":
+			media.Direction = "sendonly"
+		}
+	}
+	return media, nil
+}
+
+func firstAudioMedia(desc *sdp.SessionDescription) *sdp.MediaDescription {
+	for _, media := range desc.MediaDescriptions {
+		if media.MediaName.Media == "audio" {
+			return media
+		}
+	}
+	return nil
+}
+
+// mediaDirection возвращает направление потока: атрибут потока, затем
+// атрибут сессии, по умолчанию sendrecv (RFC 3264 Section 5.1)
+func mediaDirection(desc *sdp.SessionDescription, media *sdp.MediaDescription) string {
+	for _, attrs := range [][]sdp.Attribute{media.Attributes, desc.Attributes} {
+		for _, attr := range attrs {
+			switch attr.Key {
+			case "sendrecv", "sendonly", "recvonly", "inactive":
+				return attr.Key
+			}
+		}
+	}
+	return "sendrecv"
+}
+
+// mediaAddress возвращает адрес host:port потока
+func mediaAddress(desc *sdp.SessionDescription, media *sdp.MediaDescription) string {
+	connection := desc.ConnectionInformation
+	if media.ConnectionInformation != nil {
+		connection = media.ConnectionInformation
+	}
+	if connection == nil || connection.Address == nil {
+		return ""
+	}
+	return net.JoinHostPort(connection.Address.Address, strconv.Itoa(media.MediaName.Port.Value))
+}
+
+// notifyWebhooks отправляет события вызова по переходу состояния диалога
+func (s *Dialog) notifyWebhooks(src, dst DialogState) {
+	if s.uu == nil || s.uu.webhooks == nil || src == dst {
+		return
+	}
+
+	switch {
+	case src == IDLE && (dst == Calling || dst == Ringing):
+		s.uu.webhooks.notify(WebhookCallCreated, s, nil)
+	case dst == InCall:
+		s.uu.webhooks.notify(WebhookCallAnswered, s, nil)
+	case dst == Ended:
+		s.uu.webhooks.notify(WebhookCallEnded, s, nil)
+	}
+}
@@ -0,0 +1,281 @@
+package dialog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emiago/sipgo/sip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRequest struct {
+	header  http.Header
+	payload WebhookPayload
+	body    []byte
+}
+
+// newWebhookServer запускает получатель webhook, отвечающий статусами
+// из statuses по очереди (далее 200)
+func newWebhookServer(t *testing.T, statuses ...int) (*httptest.Server, <-chan webhookRequest) {
+	requests := make(chan webhookRequest, 16)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		requests <- webhookRequest{header: r.Header, payload: payload, body: body}
+
+		if i := int(calls.Add(1)) - 1; i < len(statuses) {
+			w.WriteHeader(statuses[i])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func receiveWebhook(t *testing.T, requests <-chan webhookRequest) webhookRequest {
+	t.Helper()
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for webhook")
+		return webhookRequest{}
+	}
+}
+
+func TestNewWebhookNotifierValidation(t *testing.T) {
+	_, err := NewWebhookNotifier(WebhookConfig{})
+	assert.Error(t, err)
+	_, err = NewWebhookNotifier(WebhookConfig{URLs: []string{"ftp://crm.example.com"}})
+	assert.Error(t, err)
+}
+
+func TestWebhookCallEvents(t *testing.T) {
+	server, requests := newWebhookServer(t)
+	notifier, err := NewWebhookNotifier(WebhookConfig{URLs: []string{server.URL}, Secret: "s3cret"})
+	require.NoError(t, err)
+	defer notifier.Close()
+
+	d := &Dialog{uu: &UACUAS{webhooks: notifier}, id: "dialog-1", callID: "call-1", localTag: "local", uaType: UAC}
+	d.initFSM()
+	d.SetTenant("acme")
+
+	ctx := context.Background()
+	require.NoError(t, d.fsm.Event(ctx, formEventName(IDLE, Calling)))
+	require.NoError(t, d.fsm.Event(ctx, formEventName(Calling, InCall)))
+	d.setReleaseCause(ReleaseCause{Category: CategoryNormal, Q850Cause: Q850NormalClearing, Text: "Normal"})
+	require.NoError(t, d.fsm.Event(ctx, formEventName(InCall, Terminating)))
+	require.NoError(t, d.fsm.Event(ctx, formEventName(Terminating, Ended)))
+
+	created := receiveWebhook(t, requests)
+	assert.Equal(t, WebhookCallCreated, created.payload.Event)
+	assert.Equal(t, "call.created", created.header.Get(WebhookHeaderEvent))
+	assert.Equal(t, created.payload.ID, created.header.Get(WebhookHeaderID))
+	assert.Equal(t, SignWebhook("s3cret", created.body), created.header.Get(WebhookHeaderSignature))
+	assert.Equal(t, "dialog-1", created.payload.DialogID)
+	assert.Equal(t, "call-1", created.payload.CallID)
+	assert.Equal(t, "UAC", created.payload.Role)
+	assert.Equal(t, "acme", created.payload.Tenant)
+	assert.Equal(t, Calling, created.payload.State)
+
+	answered := receiveWebhook(t, requests)
+	assert.Equal(t, WebhookCallAnswered, answered.payload.Event)
+
+	ended := receiveWebhook(t, requests)
+	assert.Equal(t, WebhookCallEnded, ended.payload.Event)
+	require.NotNil(t, ended.payload.ReleaseCause)
+	assert.Equal(t, Q850NormalClearing, ended.payload.ReleaseCause.Q850Cause)
+
+	notifier.MediaFailed(d, errors.New("no common codec"))
+	failed := receiveWebhook(t, requests)
+	assert.Equal(t, WebhookMediaFailed, failed.payload.Event)
+	require.NotNil(t, failed.payload.Media)
+	assert.Equal(t, "no common codec", failed.payload.Media.Error)
+}
+
+func TestWebhookRetryAndFilter(t *testing.T) {
+	server, requests := newWebhookServer(t, http.StatusServiceUnavailable, http.StatusOK, http.StatusBadRequest)
+	notifier, err := NewWebhookNotifier(WebhookConfig{
+		URLs:       []string{server.URL},
+		Events:     []WebhookEvent{WebhookMediaNegotiated},
+		RetryDelay: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	d := &Dialog{id: "dialog-2", callID: "call-2", uaType: UAS}
+	d.initFSM()
+
+	// Событие не из списка Events не отправляется
+	notifier.notify(WebhookCallCreated, d, nil)
+
+	notifier.MediaNegotiated(d, WebhookMedia{Codec: "PCMU", PayloadType: 0, ClockRate: 8000})
+	first := receiveWebhook(t, requests)
+	assert.Equal(t, WebhookMediaNegotiated, first.payload.Event)
+	assert.Empty(t, first.header.Get(WebhookHeaderRetry))
+	assert.Empty(t, first.header.Get(WebhookHeaderSignature), "Без Secret запрос не подписывается")
+
+	// Ответ 503 повторяется с тем же ID
+	retry := receiveWebhook(t, requests)
+	assert.Equal(t, first.payload.ID, retry.payload.ID)
+	assert.Equal(t, "1", retry.header.Get(WebhookHeaderRetry))
+	assert.Equal(t, "PCMU", retry.payload.Media.Codec)
+
+	// Ответ 400 не повторяется
+	notifier.MediaNegotiated(d, WebhookMedia{Codec: "PCMA", PayloadType: 8})
+	rejected := receiveWebhook(t, requests)
+	notifier.Close()
+	assert.Equal(t, "PCMA", rejected.payload.Media.Codec)
+	assert.Empty(t, requests)
+
+	// После закрытия события не принимаются
+	notifier.MediaNegotiated(d, WebhookMedia{})
+	var nilNotifier *WebhookNotifier
+	nilNotifier.MediaFailed(d, nil)
+}
+
+const (
+	webhookOfferSDP = "v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\ns=-\r\nc=IN IP4 10.0.0.1\r\nt=0 0\r\n" +
+		"m=audio 4000 RTP/AVP 0 8 101\r\na=rtpmap:101 telephone-event/8000\r\na=sendrecv\r\n"
+	webhookAnswerSDP = "v=0\r\no=- 2 2 IN IP4 10.0.0.2\r\ns=-\r\nc=IN IP4 10.0.0.2\r\nt=0 0\r\n" +
+		"m=audio 5000 RTP/AVP 8 101\r\na=rtpmap:8 PCMA/8000\r\na=rtpmap:101 telephone-event/8000\r\na=recvonly\r\n"
+)
+
+func TestNegotiatedMedia(t *testing.T) {
+	// Answer получен от удаленной стороны: направление приводится к локальной
+	media, err := negotiatedMedia([]byte(webhookOfferSDP), []byte(webhookAnswerSDP), false)
+	require.NoError(t, err)
+	assert.Equal(t, "PCMA", media.Codec)
+	assert.Equal(t, 8, media.PayloadType)
+	assert.Equal(t, 8000, media.ClockRate)
+	assert.Equal(t, "sendonly", media.Direction)
+	assert.Equal(t, "10.0.0.1:4000", media.LocalAddress)
+	assert.Equal(t, "10.0.0.2:5000", media.RemoteAddress)
+
+	// Answer сформирован локально, статический payload type без rtpmap
+	answer := strings.Replace(webhookAnswerSDP, "m=audio 5000 RTP/AVP 8 101", "m=audio 5000 RTP/AVP 0", 1)
+	media, err = negotiatedMedia([]byte(webhookOfferSDP), []byte(answer), true)
+	require.NoError(t, err)
+	assert.Equal(t, "PCMU", media.Codec)
+	assert.Equal(t, 8000, media.ClockRate)
+	assert.Equal(t, "recvonly", media.Direction)
+	assert.Equal(t, "10.0.0.2:5000", media.LocalAddress)
+	assert.Equal(t, "10.0.0.1:4000", media.RemoteAddress)
+
+	// Отклоненный аудио поток
+	rejected := strings.Replace(webhookAnswerSDP, "m=audio 5000", "m=audio 0", 1)
+	_, err = negotiatedMedia([]byte(webhookOfferSDP), []byte(rejected), false)
+	assert.ErrorIs(t, err, errNoAudioAnswer)
+}
+
+// TestWebhookMediaEvents проверяет, что события медиа отправляются по SDP
+// offer/answer диалога без вызова MediaNegotiated/MediaFailed приложением
+func TestWebhookMediaEvents(t *testing.T) {
+	server, requests := newWebhookServer(t)
+	notifier, err := NewWebhookNotifier(WebhookConfig{URLs: []string{server.URL}})
+	require.NoError(t, err)
+	defer notifier.Close()
+
+	d := &Dialog{uu: &UACUAS{webhooks: notifier}, id: "dialog-1", callID: "call-1", localTag: "local", uaType: UAC}
+	d.initFSM()
+
+	withSDP := func(msg sip.Message, body string) {
+		msg.AppendHeader(sip.NewHeader("Content-Type", ContentTypeSDP))
+		msg.SetBody([]byte(body))
+	}
+	invite := newTestRequest(sip.INVITE)
+	withSDP(invite, webhookOfferSDP)
+
+	ok := sip.NewResponseFromRequest(invite, sip.StatusOK, "OK", nil)
+	withSDP(ok, webhookAnswerSDP)
+	d.notifyMediaAnswer(invite, ok, false)
+	negotiated := receiveWebhook(t, requests)
+	assert.Equal(t, WebhookMediaNegotiated, negotiated.payload.Event)
+	require.NotNil(t, negotiated.payload.Media)
+	assert.Equal(t, "PCMA", negotiated.payload.Media.Codec)
+
+	// Без offer в запросе согласование не завершено
+	d.notifyMediaAnswer(newTestRequest(sip.INVITE), ok, false)
+	// Ответ 488 на offer
+	d.notifyMediaRejected(invite, sip.NewResponseFromRequest(invite, sip.StatusNotAcceptableHere, "Not Acceptable Here", nil), false)
+	failed := receiveWebhook(t, requests)
+	assert.Equal(t, WebhookMediaFailed, failed.payload.Event)
+	require.NotNil(t, failed.payload.Media)
+	assert.Contains(t, failed.payload.Media.Error, "488")
+
+	// Другие отказы не относятся к согласованию медиа
+	d.notifyMediaRejected(invite, sip.NewResponseFromRequest(invite, sip.StatusBusyHere, "Busy Here", nil), true)
+	select {
+	case req := <-requests:
+		t.Fatalf("Неожиданное событие %s", req.payload.Event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestWebhookMediaNegotiatedOnAnswer проверяет отправку media.negotiated
+// по 2xx с SDP answer на исходящий INVITE
+func TestWebhookMediaNegotiatedOnAnswer(t *testing.T) {
+	server, requests := newWebhookServer(t)
+
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	peerURI := sip.Uri{Scheme: "sip", User: "bob", Host: "127.0.0.1", Port: peer.LocalAddr().(*net.UDPAddr).Port}
+
+	u, err := NewUACUAS(Config{
+		TestMode:         true,
+		TransportConfigs: []TransportConfig{{Type: TransportUDP, Host: "127.0.0.1", Port: 0}},
+		Webhooks:         &WebhookConfig{URLs: []string{server.URL}},
+	})
+	require.NoError(t, err)
+	defer func() { _ = u.Stop() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = u.ListenTransports(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+
+	d, err := u.NewDialog(ctx)
+	require.NoError(t, err)
+	_, err = d.Start(ctx, peerURI.String(), WithSDP(webhookOfferSDP))
+	require.NoError(t, err)
+
+	buf := make([]byte, 4096)
+	require.NoError(t, peer.SetReadDeadline(time.Now().Add(3*time.Second)))
+	n, from, err := peer.ReadFromUDP(buf)
+	require.NoError(t, err)
+	msg, err := sip.ParseMessage(buf[:n])
+	require.NoError(t, err)
+	invite := msg.(*sip.Request)
+
+	ok := sip.NewResponseFromRequest(invite, sip.StatusOK, "OK", nil)
+	ok.To().Params.Add("tag", "peertag")
+	ok.AppendHeader(&sip.ContactHeader{Address: peerURI})
+	ok.AppendHeader(sip.NewHeader("Content-Type", ContentTypeSDP))
+	ok.SetBody([]byte(webhookAnswerSDP))
+	_, err = peer.WriteToUDP([]byte(ok.String()), from)
+	require.NoError(t, err)
+
+	for _, event := range []WebhookEvent{WebhookCallCreated, WebhookCallAnswered, WebhookMediaNegotiated} {
+		req := receiveWebhook(t, requests)
+		require.Equal(t, event, req.payload.Event)
+		if event == WebhookMediaNegotiated {
+			require.NotNil(t, req.payload.Media)
+			assert.Equal(t, "PCMA", req.payload.Media.Codec)
+			assert.Equal(t, "10.0.0.2:5000", req.payload.Media.RemoteAddress)
+		}
+	}
+}
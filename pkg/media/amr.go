@@ -0,0 +1,419 @@
+//go:build !small
+
+package media
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Формат RTP payload для AMR и AMR-WB (RFC 4867).
+//
+// Payload состоит из заголовка с запросом режима (CMR), таблицы содержимого
+// (ToC) с типом каждого кадра и речевых кадров. В режиме octet-aligned
+// каждое поле выравнивается по границе байта, в bandwidth-efficient поля
+// следуют друг за другом без выравнивания.
+
+const (
+	// AMRNoModeRequest - значение CMR "нет запроса режима"
+	AMRNoModeRequest = 15
+
+	// AMRFrameTypeNoData - тип кадра "нет данных" (кадр без речевых бит)
+	AMRFrameTypeNoData = 15
+
+	// AMRFrameTypeSpeechLost - тип кадра AMR-WB "речь потеряна"
+	AMRFrameTypeSpeechLost = 14
+
+	// amrFrameDuration - длительность одного кадра AMR и AMR-WB
+	amrFrameDuration = 20 * time.Millisecond
+)
+
+// Размеры речевых кадров в битах по типу кадра (3GPP TS 26.101, TS 26.201).
+// Последний речевой режим следует за SID кадром: 8 для AMR, 9 для AMR-WB.
+var (
+	amrFrameBits   = []int{95, 103, 118, 134, 148, 159, 204, 244, 39}
+	amrWBFrameBits = []int{132, 177, 253, 285, 317, 365, 397, 461, 477, 40}
+)
+
+// AMRFrame - один кадр AMR. Data содержит речевые биты старшим битом вперед,
+// дополненные нулями до целого байта.
+type AMRFrame struct {
+	FrameType uint8 // Режим кодека, SID или AMRFrameTypeNoData
+	Quality   bool  // Q бит: false означает поврежденный кадр
+	Data      []byte
+}
+
+// AMRPayloadFormat описывает формат payload AMR/AMR-WB
+type AMRPayloadFormat struct {
+	WideBand     bool // AMR-WB (16 кГц) вместо AMR (8 кГц)
+	OctetAligned bool // octet-align=1 в fmtp
+}
+
+// MaxSpeechMode возвращает наибольший речевой режим: 7 (12.2 кбит/с) для
+// AMR и 8 (23.85 кбит/с) для AMR-WB
+func (f AMRPayloadFormat) MaxSpeechMode() uint8 {
+	if f.WideBand {
+		return uint8(len(amrWBFrameBits) - 2)
+	}
+	return uint8(len(amrFrameBits) - 2)
+}
+
+// FrameBits возвращает размер кадра в битах для типа кадра
+func (f AMRPayloadFormat) FrameBits(frameType uint8) (int, error) {
+	table := amrFrameBits
+	if f.WideBand {
+		table = amrWBFrameBits
+		if frameType == AMRFrameTypeSpeechLost {
+			return 0, nil
+		}
+	}
+	if frameType == AMRFrameTypeNoData {
+		return 0, nil
+	}
+	if int(frameType) >= len(table) {
+		return 0, amrError("неподдерживаемый тип кадра: %d", frameType)
+	}
+	return table[frameType], nil
+}
+
+// Pack упаковывает кадры в RTP payload с запросом режима cmr
+func (f AMRPayloadFormat) Pack(cmr uint8, frames []AMRFrame) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, amrError("нет кадров для упаковки")
+	}
+
+	var w amrBitWriter
+	w.write(uint32(cmr), 4)
+	if f.OctetAligned {
+		w.write(0, 4)
+	}
+
+	// Таблица содержимого: F бит означает, что за записью следует еще одна
+	for i, frame := range frames {
+		follow := uint32(0)
+		if i < len(frames)-1 {
+			follow = 1
+		}
+		quality := uint32(0)
+		if frame.Quality {
+			quality = 1
+		}
+		w.write(follow, 1)
+		w.write(uint32(frame.FrameType), 4)
+		w.write(quality, 1)
+		if f.OctetAligned {
+			w.write(0, 2)
+		}
+	}
+
+	for _, frame := range frames {
+		bits, err := f.FrameBits(frame.FrameType)
+		if err != nil {
+			return nil, err
+		}
+		if len(frame.Data)*8 < bits {
+			return nil, amrError("кадр типа %d содержит %d бит, ожидается %d",
+				frame.FrameType, len(frame.Data)*8, bits)
+		}
+		w.writeBits(frame.Data, bits)
+		if f.OctetAligned {
+			w.align()
+		}
+	}
+
+	w.align()
+	return w.data, nil
+}
+
+// Unpack разбирает RTP payload и возвращает запрос режима и кадры
+func (f AMRPayloadFormat) Unpack(payload []byte) (uint8, []AMRFrame, error) {
+	r := amrBitReader{data: payload}
+
+	cmr, ok := r.read(4)
+	if f.OctetAligned {
+		r.read(4)
+	}
+	if !ok {
+		return 0, nil, amrError("payload короче заголовка")
+	}
+
+	var frames []AMRFrame
+	for {
+		follow, _ := r.read(1)
+		frameType, _ := r.read(4)
+		quality, ok := r.read(1)
+		if f.OctetAligned {
+			_, ok = r.read(2)
+		}
+		if !ok {
+			return 0, nil, amrError("обрезанная таблица содержимого")
+		}
+		frames = append(frames, AMRFrame{FrameType: uint8(frameType), Quality: quality == 1})
+		if follow == 0 {
+			break
+		}
+	}
+
+	for i := range frames {
+		bits, err := f.FrameBits(frames[i].FrameType)
+		if err != nil {
+			return 0, nil, err
+		}
+		data, ok := r.readBits(bits)
+		if !ok {
+			return 0, nil, amrError("обрезанный кадр типа %d", frames[i].FrameType)
+		}
+		frames[i].Data = data
+		if f.OctetAligned {
+			r.align()
+		}
+	}
+
+	return uint8(cmr), frames, nil
+}
+
+// AMRFrameCodec - внешний кодер речевых кадров AMR или AMR-WB (например,
+// привязка к opencore-amr или vo-amrwbenc). Работает с кадрами по 20ms,
+// упаковку в RTP payload выполняет пакет media.
+type AMRFrameCodec interface {
+	// EncodeFrame кодирует 20ms аудио в кадр указанного режима
+	EncodeFrame(audio []byte, mode uint8) (AMRFrame, error)
+
+	// DecodeFrame декодирует кадр в 20ms аудио
+	DecodeFrame(frame AMRFrame) ([]byte, error)
+}
+
+// AMRFrameCodecFactory создает внешний кодер кадров для одного вызова
+type AMRFrameCodecFactory func() (AMRFrameCodec, error)
+
+// RegisterAMRFrameCodec регистрирует внешний кодер кадров и кодек "AMR" или
+// "AMR-WB" в реестре кодеков. Параметры fmtp octet-align и mode-set
+// применяются к каждому создаваемому кодеку. nil удаляет регистрацию.
+func RegisterAMRFrameCodec(wideBand bool, factory AMRFrameCodecFactory) {
+	name := "AMR"
+	if wideBand {
+		name = "AMR-WB"
+	}
+	if factory == nil {
+		RegisterCodec(name, nil)
+		return
+	}
+
+	RegisterCodec(name, func(params CodecParams) (Codec, error) {
+		format := AMRPayloadFormat{WideBand: wideBand, OctetAligned: params.Fmtp["octet-align"] == "1"}
+		for _, key := range []string{"crc", "robust-sorting"} {
+			if params.Fmtp[key] == "1" {
+				return nil, amrError("параметр %s не поддерживается", key)
+			}
+		}
+		if _, ok := params.Fmtp["interleaving"]; ok {
+			return nil, amrError("interleaving не поддерживается")
+		}
+
+		modeSet, err := ParseAMRModeSet(params.Fmtp["mode-set"], format)
+		if err != nil {
+			return nil, err
+		}
+
+		frames, err := factory()
+		if err != nil {
+			return nil, err
+		}
+		return newAMRCodec(format, frames, modeSet, params.Ptime), nil
+	})
+}
+
+// ParseAMRModeSet разбирает параметр fmtp mode-set ("0,2,5,7"). Пустое
+// значение означает, что разрешены все режимы: возвращается nil.
+func ParseAMRModeSet(value string, format AMRPayloadFormat) ([]uint8, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var modes []uint8
+	for _, item := range strings.Split(value, ",") {
+		mode, err := strconv.ParseUint(strings.TrimSpace(item), 10, 8)
+		if err != nil || uint8(mode) > format.MaxSpeechMode() {
+			return nil, amrError("некорректный mode-set: %q", value)
+		}
+		modes = append(modes, uint8(mode))
+	}
+	return modes, nil
+}
+
+// amrCodec реализует Codec поверх внешнего кодера кадров
+type amrCodec struct {
+	format          AMRPayloadFormat
+	frames          AMRFrameCodec
+	modeSet         []uint8 // Разрешенные режимы, nil - все речевые режимы
+	framesPerPacket int
+	mode            atomic.Uint32 // Текущий режим кодирования
+}
+
+// newAMRCodec создает кодек. Начальный режим - наибольший из разрешенных.
+func newAMRCodec(format AMRPayloadFormat, frames AMRFrameCodec, modeSet []uint8, ptime time.Duration) *amrCodec {
+	codec := &amrCodec{
+		format:          format,
+		frames:          frames,
+		modeSet:         modeSet,
+		framesPerPacket: int(ptime / amrFrameDuration),
+	}
+	if codec.framesPerPacket < 1 {
+		codec.framesPerPacket = 1
+	}
+
+	mode := format.MaxSpeechMode()
+	if len(modeSet) > 0 {
+		mode = modeSet[0]
+		for _, m := range modeSet {
+			if m > mode {
+				mode = m
+			}
+		}
+	}
+	codec.mode.Store(uint32(mode))
+	return codec
+}
+
+// Encode кодирует аудио пакета кадрами по 20ms в текущем режиме
+func (c *amrCodec) Encode(audio []byte) ([]byte, error) {
+	if len(audio) == 0 || len(audio)%c.framesPerPacket != 0 {
+		return nil, amrError("размер аудио %d не делится на %d кадров", len(audio), c.framesPerPacket)
+	}
+
+	frameSize := len(audio) / c.framesPerPacket
+	mode := uint8(c.mode.Load())
+	frames := make([]AMRFrame, 0, c.framesPerPacket)
+	for offset := 0; offset < len(audio); offset += frameSize {
+		frame, err := c.frames.EncodeFrame(audio[offset:offset+frameSize], mode)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+
+	return c.format.Pack(AMRNoModeRequest, frames)
+}
+
+// Decode декодирует кадры payload. Запрос режима (CMR) удаленной стороны
+// применяется к кодированию, если режим разрешен mode-set.
+func (c *amrCodec) Decode(payload []byte) ([]byte, error) {
+	cmr, frames, err := c.format.Unpack(payload)
+	if err != nil {
+		return nil, err
+	}
+	if cmr != AMRNoModeRequest && c.allowed(cmr) {
+		c.mode.Store(uint32(cmr))
+	}
+
+	var audio []byte
+	for _, frame := range frames {
+		if frame.FrameType == AMRFrameTypeNoData {
+			continue
+		}
+		decoded, err := c.frames.DecodeFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		audio = append(audio, decoded...)
+	}
+	return audio, nil
+}
+
+// allowed проверяет, разрешен ли речевой режим
+func (c *amrCodec) allowed(mode uint8) bool {
+	if mode > c.format.MaxSpeechMode() {
+		return false
+	}
+	if len(c.modeSet) == 0 {
+		return true
+	}
+	for _, m := range c.modeSet {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// amrError создает ошибку формата AMR payload
+func amrError(format string, args ...interface{}) error {
+	return codecError("AMR", format, args...)
+}
+
+// amrBitWriter записывает поля старшим битом вперед
+type amrBitWriter struct {
+	data []byte
+	bits int // Количество записанных бит
+}
+
+// write записывает младшие n бит value
+func (w *amrBitWriter) write(value uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.writeBit(byte(value>>uint(i)) & 1)
+	}
+}
+
+// writeBits записывает первые n бит data
+func (w *amrBitWriter) writeBits(data []byte, n int) {
+	for i := 0; i < n; i++ {
+		w.writeBit(data[i/8] >> uint(7-i%8) & 1)
+	}
+}
+
+func (w *amrBitWriter) writeBit(bit byte) {
+	if w.bits%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+	w.data[len(w.data)-1] |= bit << uint(7-w.bits%8)
+	w.bits++
+}
+
+// align дополняет запись нулями до границы байта
+func (w *amrBitWriter) align() {
+	w.bits = len(w.data) * 8
+}
+
+// amrBitReader читает поля старшим битом вперед
+type amrBitReader struct {
+	data []byte
+	bits int // Количество прочитанных бит
+}
+
+// read читает n бит как число
+func (r *amrBitReader) read(n int) (uint32, bool) {
+	if r.bits+n > len(r.data)*8 {
+		r.bits = len(r.data) * 8
+		return 0, false
+	}
+	var value uint32
+	for i := 0; i < n; i++ {
+		value = value<<1 | uint32(r.readBit())
+	}
+	return value, true
+}
+
+// readBits читает n бит в байты, дополненные нулями
+func (r *amrBitReader) readBits(n int) ([]byte, bool) {
+	if r.bits+n > len(r.data)*8 {
+		return nil, false
+	}
+	data := make([]byte, (n+7)/8)
+	for i := 0; i < n; i++ {
+		data[i/8] |= r.readBit() << uint(7-i%8)
+	}
+	return data, true
+}
+
+func (r *amrBitReader) readBit() byte {
+	bit := r.data[r.bits/8] >> uint(7-r.bits%8) & 1
+	r.bits++
+	return bit
+}
+
+// align пропускает биты до границы байта
+func (r *amrBitReader) align() {
+	r.bits = (r.bits + 7) / 8 * 8
+}
@@ -0,0 +1,280 @@
+//go:build !small
+
+package media
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// testAMRFrame создает кадр с заполненными речевыми битами
+func testAMRFrame(t *testing.T, format AMRPayloadFormat, frameType uint8, fill byte) AMRFrame {
+	bits, err := format.FrameBits(frameType)
+	if err != nil {
+		t.Fatalf("Ошибка размера кадра: %v", err)
+	}
+	data := bytes.Repeat([]byte{fill}, (bits+7)/8)
+	// Биты за пределами кадра должны быть нулевыми
+	if bits%8 != 0 {
+		data[len(data)-1] &= 0xFF << uint(8-bits%8)
+	}
+	return AMRFrame{FrameType: frameType, Quality: true, Data: data}
+}
+
+// TestAMRPayloadPacking проверяет упаковку и разбор payload в обоих режимах
+func TestAMRPayloadPacking(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   AMRPayloadFormat
+		frames   []uint8
+		expected int // Размер payload в байтах
+	}{
+		// 4 бита CMR + 6 бит ToC + 244 бита = 254 бита
+		{"AMR 12.2 bandwidth-efficient", AMRPayloadFormat{}, []uint8{7}, 32},
+		// 1 байт CMR + 1 байт ToC + 31 байт кадра
+		{"AMR 12.2 octet-aligned", AMRPayloadFormat{OctetAligned: true}, []uint8{7}, 33},
+		// 4 + 3*6 + 95 + 39 = 156 бит, кадр NO_DATA без речевых бит
+		{"AMR несколько кадров", AMRPayloadFormat{}, []uint8{0, 8, AMRFrameTypeNoData}, 20},
+		// 4 + 6 + 477 = 487 бит
+		{"AMR-WB 23.85 bandwidth-efficient", AMRPayloadFormat{WideBand: true}, []uint8{8}, 61},
+		// 1 + 2 + 17 + 60 байт
+		{"AMR-WB octet-aligned", AMRPayloadFormat{WideBand: true, OctetAligned: true}, []uint8{0, 8}, 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var frames []AMRFrame
+			for i, frameType := range tt.frames {
+				frames = append(frames, testAMRFrame(t, tt.format, frameType, byte(0xA5+i)))
+			}
+
+			payload, err := tt.format.Pack(5, frames)
+			if err != nil {
+				t.Fatalf("Ошибка упаковки: %v", err)
+			}
+			if len(payload) != tt.expected {
+				t.Errorf("Размер payload %d, ожидается %d", len(payload), tt.expected)
+			}
+
+			cmr, unpacked, err := tt.format.Unpack(payload)
+			if err != nil {
+				t.Fatalf("Ошибка разбора: %v", err)
+			}
+			if cmr != 5 {
+				t.Errorf("CMR %d, ожидается 5", cmr)
+			}
+			if len(unpacked) != len(frames) {
+				t.Fatalf("Разобрано %d кадров, ожидается %d", len(unpacked), len(frames))
+			}
+			for i := range frames {
+				if unpacked[i].FrameType != frames[i].FrameType || !unpacked[i].Quality {
+					t.Errorf("Кадр %d: тип %d, ожидается %d", i, unpacked[i].FrameType, frames[i].FrameType)
+				}
+				if !bytes.Equal(unpacked[i].Data, frames[i].Data) {
+					t.Errorf("Кадр %d: данные не совпадают", i)
+				}
+			}
+		})
+	}
+}
+
+// TestAMRPayloadHeader проверяет расположение полей заголовка в режиме octet-aligned
+func TestAMRPayloadHeader(t *testing.T) {
+	format := AMRPayloadFormat{OctetAligned: true}
+	payload, err := format.Pack(AMRNoModeRequest, []AMRFrame{testAMRFrame(t, format, 7, 0xFF)})
+	if err != nil {
+		t.Fatalf("Ошибка упаковки: %v", err)
+	}
+
+	// CMR=15 и 4 резервных бита, затем F=0, FT=7, Q=1 и 2 бита выравнивания
+	if payload[0] != 0xF0 || payload[1] != 0x3C {
+		t.Errorf("Заголовок %#x %#x, ожидается 0xf0 0x3c", payload[0], payload[1])
+	}
+}
+
+// TestAMRPayloadErrors проверяет обработку некорректных payload
+func TestAMRPayloadErrors(t *testing.T) {
+	format := AMRPayloadFormat{}
+
+	if _, _, err := format.Unpack(nil); err == nil {
+		t.Error("Пустой payload должен возвращать ошибку")
+	}
+
+	payload, err := format.Pack(AMRNoModeRequest, []AMRFrame{testAMRFrame(t, format, 7, 0xFF)})
+	if err != nil {
+		t.Fatalf("Ошибка упаковки: %v", err)
+	}
+	if _, _, err := format.Unpack(payload[:20]); err == nil {
+		t.Error("Обрезанный кадр должен возвращать ошибку")
+	}
+
+	if _, err := format.Pack(AMRNoModeRequest, []AMRFrame{{FrameType: 12}}); err == nil {
+		t.Error("Зарезервированный тип кадра должен возвращать ошибку")
+	}
+	if _, err := format.Pack(AMRNoModeRequest, []AMRFrame{{FrameType: 7, Data: []byte{1}}}); err == nil {
+		t.Error("Кадр короче размера режима должен возвращать ошибку")
+	}
+
+	if _, err := ParseAMRModeSet("0,2,9", format); err == nil {
+		t.Error("Режим 9 недопустим для AMR")
+	}
+	if modes, err := ParseAMRModeSet("0,2,8", AMRPayloadFormat{WideBand: true}); err != nil || len(modes) != 3 {
+		t.Errorf("Режим 8 допустим для AMR-WB: %v (%v)", modes, err)
+	}
+}
+
+// fakeAMRFrameCodec имитирует внешний кодер и запоминает режимы кодирования
+type fakeAMRFrameCodec struct {
+	mutex sync.Mutex
+	modes []uint8
+}
+
+func (c *fakeAMRFrameCodec) EncodeFrame(audio []byte, mode uint8) (AMRFrame, error) {
+	c.mutex.Lock()
+	c.modes = append(c.modes, mode)
+	c.mutex.Unlock()
+
+	bits, _ := AMRPayloadFormat{}.FrameBits(mode)
+	data := make([]byte, (bits+7)/8)
+	data[0] = audio[0]
+	return AMRFrame{FrameType: mode, Quality: true, Data: data}, nil
+}
+
+func (c *fakeAMRFrameCodec) DecodeFrame(frame AMRFrame) ([]byte, error) {
+	return bytes.Repeat([]byte{frame.Data[0]}, StandardPCMSamples20ms), nil
+}
+
+// TestAMRCodecRegistry проверяет регистрацию внешнего кодера, применение
+// mode-set и запроса режима (CMR) удаленной стороны
+func TestAMRCodecRegistry(t *testing.T) {
+	if _, err := NewCodec("AMR", CodecParams{}); err == nil {
+		t.Fatal("Без внешнего кодера AMR не должен создаваться")
+	}
+
+	frames := &fakeAMRFrameCodec{}
+	RegisterAMRFrameCodec(false, func() (AMRFrameCodec, error) { return frames, nil })
+	defer RegisterAMRFrameCodec(false, nil)
+
+	if !IsCodecRegistered("amr") {
+		t.Fatal("Кодек AMR должен быть зарегистрирован")
+	}
+
+	if _, err := NewCodec("AMR", CodecParams{Fmtp: map[string]string{"crc": "1"}}); err == nil {
+		t.Error("Параметр crc не поддерживается и должен возвращать ошибку")
+	}
+
+	codec, err := NewCodec("AMR", CodecParams{
+		PayloadType: 96,
+		ClockRate:   8000,
+		Ptime:       40 * time.Millisecond,
+		Fmtp:        map[string]string{"mode-set": "0,2,5", "octet-align": "1"},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания кодека: %v", err)
+	}
+
+	// 40ms - два кадра, начальный режим - наибольший из mode-set
+	payload, err := codec.Encode(generateTestAudioData(StandardPCMSamples20ms * 2))
+	if err != nil {
+		t.Fatalf("Ошибка кодирования: %v", err)
+	}
+	_, packed, err := AMRPayloadFormat{OctetAligned: true}.Unpack(payload)
+	if err != nil || len(packed) != 2 || packed[0].FrameType != 5 {
+		t.Fatalf("Ожидалось 2 кадра режима 5: %+v (%v)", packed, err)
+	}
+
+	// Запрос режима 2 применяется, режим 7 вне mode-set игнорируется
+	for _, cmr := range []uint8{2, 7} {
+		request, _ := AMRPayloadFormat{OctetAligned: true}.Pack(cmr, []AMRFrame{{FrameType: AMRFrameTypeNoData}})
+		if _, err := codec.Decode(request); err != nil {
+			t.Fatalf("Ошибка декодирования: %v", err)
+		}
+	}
+	if _, err := codec.Encode(generateTestAudioData(StandardPCMSamples20ms * 2)); err != nil {
+		t.Fatalf("Ошибка кодирования: %v", err)
+	}
+	frames.mutex.Lock()
+	lastMode := frames.modes[len(frames.modes)-1]
+	frames.mutex.Unlock()
+	if lastMode != 2 {
+		t.Errorf("После CMR=2 ожидался режим 2, получен %d", lastMode)
+	}
+
+	decoded, err := codec.Decode(payload)
+	if err != nil || len(decoded) != StandardPCMSamples20ms*2 {
+		t.Errorf("Ожидалось 40ms аудио после декодирования: %d байт (%v)", len(decoded), err)
+	}
+}
+
+// TestSessionWithPluggableCodec проверяет отправку и прием через подключаемый
+// кодек с динамическим payload type
+func TestSessionWithPluggableCodec(t *testing.T) {
+	RegisterAMRFrameCodec(false, func() (AMRFrameCodec, error) { return &fakeAMRFrameCodec{}, nil })
+	defer RegisterAMRFrameCodec(false, nil)
+
+	codec, err := NewCodec("AMR", CodecParams{PayloadType: 96, ClockRate: 8000, Ptime: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Ошибка создания кодека: %v", err)
+	}
+
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-amr-session"
+	config.PayloadType = 96
+	config.Codec = codec
+
+	var received []byte
+	config.OnAudioReceived = func(data []byte, _ PayloadType, _ time.Duration, _ string) {
+		received = append([]byte(nil), data...)
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии с динамическим payload type: %v", err)
+	}
+	defer session.Stop()
+
+	var mutex sync.Mutex
+	var sent [][]byte
+	mock := NewMockSessionRTP("primary", "AMR")
+	mock.SetSendAudioCallback(func(data []byte, _ time.Duration) error {
+		mutex.Lock()
+		sent = append(sent, data)
+		mutex.Unlock()
+		return nil
+	})
+	if err := session.AddRTPSession("primary", mock); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := session.SendAudio(generateTestAudioData(StandardPCMSamples20ms)); err != nil {
+			t.Fatalf("Ошибка отправки аудио: %v", err)
+		}
+	}
+	time.Sleep(80 * time.Millisecond)
+
+	mutex.Lock()
+	packets := len(sent)
+	var first []byte
+	if packets > 0 {
+		first = sent[0]
+	}
+	mutex.Unlock()
+
+	// 4 бита CMR + 6 бит ToC + 244 бита режима 7
+	if packets != 2 || len(first) != 32 {
+		t.Fatalf("Ожидалось 2 пакета AMR по 32 байта, отправлено %d", packets)
+	}
+
+	session.processDecodedPacketWithID(&rtp.Packet{Header: rtp.Header{PayloadType: 96}, Payload: first}, "primary", packetMetadata{})
+	if len(received) != StandardPCMSamples20ms {
+		t.Errorf("Ожидалось 20ms декодированного аудио, получено %d байт", len(received))
+	}
+}
@@ -0,0 +1,302 @@
+package media
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+)
+
+// DefaultAnalyticsInterval - интервал записей аналитики по умолчанию
+const DefaultAnalyticsInterval = time.Second
+
+// AnalyticsSpeechThreshold - порог RMS 16-битного отсчета (около -36 dBFS),
+// выше которого кадр считается речью
+const AnalyticsSpeechThreshold = 500
+
+// AudioAnalytics содержит энергию и активность речи одного направления
+// за интервал записи
+type AudioAnalytics struct {
+	Frames         uint64  // Все аудио кадры направления
+	MeasuredFrames uint64  // Кадры, декодированные для измерения (G.711, L16)
+	SpeechFrames   uint64  // Кадры с RMS выше AnalyticsSpeechThreshold
+	RMS            float64 // Среднеквадратичное значение отсчетов (0-32767)
+	Level          float64 // Уровень RMS в dBFS, math.Inf(-1) для тишины
+	SpeechRatio    float64 // Доля речевых кадров среди измеренных (0-1)
+}
+
+// IsSilent возвращает true, если за интервал в направлении не было речи
+func (a AudioAnalytics) IsSilent() bool {
+	return a.SpeechFrames == 0
+}
+
+// AnalyticsRecord - сводка аудио аналитики сессии за интервал: энергия обоих
+// направлений, доля речи, принятые DTMF и потери пакетов. Запись легче
+// полной записи разговора и подходит для дашбордов качества и логики
+// обнаружения тишины.
+type AnalyticsRecord struct {
+	SessionID string
+	Timestamp time.Time     // Время формирования записи
+	Interval  time.Duration // Фактическая длительность интервала
+
+	TX AudioAnalytics // Отправленное аудио
+	RX AudioAnalytics // Принятое аудио
+
+	// DTMF - цифры, принятые за интервал
+	DTMF []DTMFDigit
+
+	// Потери входящих пакетов за интервал по sequence number
+	PacketsExpected uint64
+	PacketsLost     uint64
+	PacketLossRate  float64
+}
+
+// AnalyticsStream возвращает канал, в который с заданным интервалом
+// отправляются записи аудио аналитики сессии. Измерение включается при
+// первом вызове; первая запись отправляется через interval.
+//
+// Как и в StatsStream, сессия не блокируется на отправке: если подписчик не
+// успевает читать, запись пропускается, а ее данные входят в следующую.
+// Канал закрывается при остановке сессии. Интервал <= 0 заменяется на
+// DefaultAnalyticsInterval.
+//
+// Пример использования:
+//
+//	for record := range session.AnalyticsStream(time.Second) {
+//	    if record.RX.IsSilent() && record.TX.IsSilent() {
+//	        silenceDetector.Tick(record.SessionID)
+//	    }
+//	}
+func (ms *MediaSession) AnalyticsStream(interval time.Duration) <-chan AnalyticsRecord {
+	if interval <= 0 {
+		interval = DefaultAnalyticsInterval
+	}
+
+	ms.analytics.CompareAndSwap(nil, &analyticsMeter{})
+	meter := ms.analytics.Load()
+	last := meter.snapshot()
+	lastTime := time.Now()
+
+	stream := make(chan AnalyticsRecord, 1)
+
+	go func() {
+		defer close(stream)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ms.ctx.Done():
+				return
+			}
+
+			now := time.Now()
+			current := meter.snapshot()
+			record := current.record(last, ms.sessionID, now, now.Sub(lastTime))
+
+			select {
+			case stream <- record:
+				last = current
+				lastTime = now
+			default:
+				// Подписчик еще не прочитал предыдущую запись
+			}
+		}
+	}()
+
+	return stream
+}
+
+// analyticsDirection - накопленные значения одного направления
+type analyticsDirection struct {
+	frames       uint64
+	measured     uint64
+	speechFrames uint64
+	samples      uint64
+	sumSquares   float64
+}
+
+// analyticsTotals - накопленные с момента включения значения аналитики.
+// Записи вычисляются как разница двух снимков, поэтому несколько подписчиков
+// не мешают друг другу.
+type analyticsTotals struct {
+	tx, rx   analyticsDirection
+	dtmf     []DTMFDigit
+	received uint64 // Принятые аудио пакеты
+	expected uint64 // Ожидаемые пакеты по sequence number
+}
+
+// analyticsMeter накапливает аналитику на путях отправки и приема
+type analyticsMeter struct {
+	mu     sync.Mutex
+	totals analyticsTotals
+
+	// Отслеживание sequence number входящего потока
+	ssrc    uint32
+	lastSeq uint16
+	started bool
+}
+
+// observeTX учитывает отправленный кадр
+func (m *analyticsMeter) observeTX(samples []int16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totals.tx.observe(samples)
+}
+
+// observeRX учитывает принятый кадр и его sequence number
+func (m *analyticsMeter) observeRX(samples []int16, ssrc uint32, seq uint16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totals.rx.observe(samples)
+
+	switch {
+	case !m.started || ssrc != m.ssrc:
+		// Новый источник: отсчет потерь начинается заново
+		m.ssrc = ssrc
+		m.started = true
+		m.totals.expected++
+	case int16(seq-m.lastSeq) > 0:
+		m.totals.expected += uint64(seq - m.lastSeq)
+	default:
+		// Дубликат или опоздавший пакет: ожидаемые пакеты уже учтены
+		return
+	}
+	m.lastSeq = seq
+	m.totals.received++
+}
+
+// observeDTMF учитывает принятую DTMF цифру
+func (m *analyticsMeter) observeDTMF(digit DTMFDigit) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totals.dtmf = append(m.totals.dtmf, digit)
+}
+
+// snapshot возвращает копию накопленных значений
+func (m *analyticsMeter) snapshot() analyticsTotals {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	totals := m.totals
+	totals.dtmf = totals.dtmf[:len(totals.dtmf):len(totals.dtmf)]
+	return totals
+}
+
+// observe учитывает кадр направления. nil - кадр не удалось декодировать
+func (d *analyticsDirection) observe(samples []int16) {
+	d.frames++
+	if len(samples) == 0 {
+		return
+	}
+
+	var sum float64
+	for _, sample := range samples {
+		sum += float64(sample) * float64(sample)
+	}
+	d.measured++
+	d.samples += uint64(len(samples))
+	d.sumSquares += sum
+	if math.Sqrt(sum/float64(len(samples))) > AnalyticsSpeechThreshold {
+		d.speechFrames++
+	}
+}
+
+// since возвращает аналитику направления с момента снимка prev
+func (d analyticsDirection) since(prev analyticsDirection) AudioAnalytics {
+	result := AudioAnalytics{
+		Frames:         d.frames - prev.frames,
+		MeasuredFrames: d.measured - prev.measured,
+		SpeechFrames:   d.speechFrames - prev.speechFrames,
+		Level:          math.Inf(-1),
+	}
+	if samples := d.samples - prev.samples; samples > 0 {
+		result.RMS = math.Sqrt((d.sumSquares - prev.sumSquares) / float64(samples))
+	}
+	if result.RMS > 0 {
+		result.Level = 20 * math.Log10(result.RMS/math.MaxInt16)
+	}
+	if result.MeasuredFrames > 0 {
+		result.SpeechRatio = float64(result.SpeechFrames) / float64(result.MeasuredFrames)
+	}
+	return result
+}
+
+// record формирует запись аналитики с момента снимка prev
+func (t analyticsTotals) record(prev analyticsTotals, sessionID string, now time.Time, interval time.Duration) AnalyticsRecord {
+	record := AnalyticsRecord{
+		SessionID:       sessionID,
+		Timestamp:       now,
+		Interval:        interval,
+		TX:              t.tx.since(prev.tx),
+		RX:              t.rx.since(prev.rx),
+		PacketsExpected: t.expected - prev.expected,
+	}
+	if len(t.dtmf) > len(prev.dtmf) {
+		record.DTMF = append([]DTMFDigit(nil), t.dtmf[len(prev.dtmf):]...)
+	}
+	if received := t.received - prev.received; record.PacketsExpected > received {
+		record.PacketsLost = record.PacketsExpected - received
+		record.PacketLossRate = float64(record.PacketsLost) / float64(record.PacketsExpected)
+	}
+	return record
+}
+
+// analyticsSamples декодирует payload основного кодека сессии в линейные
+// отсчеты для измерения энергии. Возвращает nil для кодеков, которые не
+// декодируются без состояния (G.729, AMR и др.)
+func (ms *MediaSession) analyticsSamples(payload []byte) []int16 {
+	switch {
+	case ms.sampleSize.Load() > 0, ms.payloadType == PayloadTypeL16_1CH, ms.payloadType == PayloadTypeL16_2CH:
+		samples := make([]int16, len(payload)/l16SampleSize)
+		for i := range samples {
+			samples[i] = int16(binary.BigEndian.Uint16(payload[i*l16SampleSize:]))
+		}
+		return samples
+	case ms.codecEnabled.Load():
+		return nil
+	case ms.payloadType == PayloadTypePCMU, ms.payloadType == PayloadTypePCMA:
+		return testsignal.Codec(ms.payloadType).DecodePCM(payload)
+	default:
+		return nil
+	}
+}
+
+// observeSentAudio передает отправленный payload в аналитику и распознавание,
+// если они включены
+func (ms *MediaSession) observeSentAudio(payload []byte) {
+	meter := ms.analyticsMeter()
+	if meter == nil && ms.transcriptionTap() == nil {
+		return
+	}
+	samples := ms.analyticsSamples(payload)
+	if meter != nil {
+		meter.observeTX(samples)
+	}
+	ms.observeTranscription(samples, false)
+}
+
+// observeReceivedAudio передает принятый пакет в аналитику и распознавание,
+// если они включены
+func (ms *MediaSession) observeReceivedAudio(payload []byte, ssrc uint32, seq uint16) {
+	meter := ms.analyticsMeter()
+	if meter == nil && ms.transcriptionTap() == nil {
+		return
+	}
+	samples := ms.analyticsSamples(payload)
+	if meter != nil {
+		meter.observeRX(samples, ssrc, seq)
+	}
+	ms.observeTranscription(samples, true)
+}
+
+// observeReceivedDTMF передает принятую DTMF цифру в аналитику, если она включена
+func (ms *MediaSession) observeReceivedDTMF(digit DTMFDigit) {
+	if meter := ms.analyticsMeter(); meter != nil {
+		meter.observeDTMF(digit)
+	}
+}
@@ -0,0 +1,119 @@
+package media
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+	"github.com/pion/rtp"
+)
+
+// TestAnalyticsStream проверяет энергию, долю речи, DTMF и потери в записях аналитики
+func TestAnalyticsStream(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-analytics"
+	config.DTMFEnabled = true
+	config.DTMFPayloadType = DTMFPayloadTypeRFC
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer func() { _ = session.Stop() }()
+
+	// До включения аналитики пакеты не измеряются
+	session.sendRTPPacket(testsignal.Encode(testsignal.PCMU, testsignal.Sine(1000, 0.5), 160))
+
+	stream := session.AnalyticsStream(20 * time.Millisecond)
+
+	// Принимаем речь: пакеты 1, 2 и 4, пакет 3 потерян
+	speech := testsignal.NewGenerator(testsignal.Speech(0.5, 1), testsignal.PCMU, 20*time.Millisecond)
+	for _, seq := range []uint16{1, 2, 4} {
+		session.processIncomingPacketWithID(&rtp.Packet{
+			Header:  rtp.Header{PayloadType: PayloadTypePCMU, SequenceNumber: seq, SSRC: 1234, Timestamp: uint32(seq) * 160},
+			Payload: speech.NextFrame(),
+		}, "", packetMetadata{arrival: time.Now()})
+	}
+
+	// DTMF цифра 5
+	sender := NewDTMFSender(DTMFPayloadTypeRFC)
+	packets, err := sender.GeneratePackets(DTMFEvent{Digit: DTMF5, Duration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Ошибка генерации DTMF: %v", err)
+	}
+	for _, packet := range packets {
+		session.processIncomingPacketWithID(packet, "", packetMetadata{arrival: time.Now()})
+	}
+
+	// Отправляем тишину
+	for i := 0; i < 2; i++ {
+		session.sendRTPPacket(testsignal.Encode(testsignal.PCMU, testsignal.Silence(), 160))
+	}
+
+	// Данные могут попасть в несколько записей - суммируем их
+	var tx, rx, speechFrames, expected, lost uint64
+	var digits []DTMFDigit
+	var rxLevel float64
+	deadline := time.After(2 * time.Second)
+	for tx < 2 || rx < 3 || len(digits) == 0 {
+		select {
+		case record := <-stream:
+			if record.SessionID != config.SessionID {
+				t.Errorf("Ожидался SessionID %s, получено %s", config.SessionID, record.SessionID)
+			}
+			if record.TX.SpeechFrames != 0 {
+				t.Errorf("Отправленная тишина не должна считаться речью: %+v", record.TX)
+			}
+			tx += record.TX.Frames
+			rx += record.RX.Frames
+			speechFrames += record.RX.SpeechFrames
+			expected += record.PacketsExpected
+			lost += record.PacketsLost
+			digits = append(digits, record.DTMF...)
+			if record.RX.Frames > 0 {
+				rxLevel = record.RX.Level
+			}
+		case <-deadline:
+			t.Fatalf("Записи аналитики не получены: tx=%d rx=%d dtmf=%v", tx, rx, digits)
+		}
+	}
+
+	if tx != 2 {
+		t.Errorf("Ожидалось 2 отправленных кадра, получено %d", tx)
+	}
+	if speechFrames != 3 {
+		t.Errorf("Ожидалось 3 речевых кадра, получено %d", speechFrames)
+	}
+	if rxLevel >= 0 || math.IsInf(rxLevel, -1) {
+		t.Errorf("Некорректный уровень принятой речи: %v dBFS", rxLevel)
+	}
+	if expected != 4 || lost != 1 {
+		t.Errorf("Ожидалось 4 ожидаемых и 1 потерянный пакет, получено %d и %d", expected, lost)
+	}
+	if len(digits) != 1 || digits[0] != DTMF5 {
+		t.Errorf("Ожидалась цифра 5, получено %v", digits)
+	}
+}
+
+// TestAnalyticsRecordSilence проверяет запись интервала без аудио
+func TestAnalyticsRecordSilence(t *testing.T) {
+	meter := &analyticsMeter{}
+	prev := meter.snapshot()
+	meter.observeTX(make([]int16, 160))
+	meter.observeTX(nil)
+
+	record := meter.snapshot().record(prev, "s", time.Now(), time.Second)
+	if !record.TX.IsSilent() || !record.RX.IsSilent() {
+		t.Error("Интервал без речи должен быть тихим")
+	}
+	if record.TX.Frames != 2 || record.TX.MeasuredFrames != 1 {
+		t.Errorf("Ожидалось 2 кадра, из них 1 измеренный: %+v", record.TX)
+	}
+	if !math.IsInf(record.TX.Level, -1) || record.TX.SpeechRatio != 0 {
+		t.Errorf("Некорректный уровень тишины: %+v", record.TX)
+	}
+	if record.PacketsLost != 0 || record.PacketLossRate != 0 {
+		t.Error("Без входящих пакетов потерь нет")
+	}
+}
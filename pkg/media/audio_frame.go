@@ -0,0 +1,79 @@
+package media
+
+import (
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// AudioFrame представляет полученный аудио кадр вместе с метаданными RTP пакета.
+//
+// В отличие от OnAudioReceived, который передает только байты, payload type и ptime,
+// AudioFrame содержит всю информацию, необходимую для точного выравнивания кадров
+// при записи разговора или аналитике:
+//   - RTP timestamp и sequence number исходного пакета
+//   - SSRC источника
+//   - Время получения пакета из сети
+//   - Задержку воспроизведения, внесенную jitter buffer
+//
+// Пример использования:
+//
+//	config := media.DefaultMediaSessionConfig()
+//	config.OnAudioFrame = func(frame media.AudioFrame) {
+//	    recorder.Write(frame.SSRC, frame.Timestamp, frame.Audio)
+//	    log.Printf("seq=%d задержка=%v", frame.SequenceNumber, frame.PlayoutDelay)
+//	}
+//	session, err := media.NewSession(config)
+type AudioFrame struct {
+	// Payload содержит сырые данные из RTP пакета (без декодирования)
+	Payload []byte
+	// Audio содержит данные после аудио процессора (nil если процессор недоступен)
+	Audio []byte
+
+	PayloadType PayloadType
+	Ptime       time.Duration
+	Channels    int // Количество каналов, отсчеты каналов в Audio чередуются
+
+	// Метаданные RTP пакета
+	Timestamp      uint32 // RTP timestamp
+	SequenceNumber uint16 // RTP sequence number
+	SSRC           uint32 // Идентификатор источника
+
+	// TalkspurtStart установлен для первого кадра talkspurt (RTP marker bit).
+	// Предыдущий кадр от того же источника завершил talkspurt, а интервал между
+	// ними является паузой (подавление тишины), а не потерей пакетов
+	TalkspurtStart bool
+
+	// ArrivalTime время получения пакета из сети
+	ArrivalTime time.Time
+	// PlayoutDelay время, которое пакет провел в jitter buffer (0 если буфер отключен)
+	PlayoutDelay time.Duration
+
+	// RTPSessionID идентификатор RTP сессии, через которую получен пакет
+	RTPSessionID string
+}
+
+// packetMetadata содержит метаданные приема пакета, которые передаются
+// вместе с ним через jitter buffer до callback'ов
+type packetMetadata struct {
+	arrival      time.Time
+	playoutDelay time.Duration
+	dequeued     time.Time // Начало обработки после jitter buffer, если измеряется задержка
+}
+
+// newAudioFrame создает AudioFrame из RTP пакета и метаданных приема
+func newAudioFrame(packet *rtp.Packet, payloadType PayloadType, ptime time.Duration,
+	meta packetMetadata, rtpSessionID string) AudioFrame {
+	return AudioFrame{
+		Payload:        packet.Payload,
+		PayloadType:    payloadType,
+		Ptime:          ptime,
+		Timestamp:      packet.Timestamp,
+		SequenceNumber: packet.SequenceNumber,
+		SSRC:           packet.SSRC,
+		TalkspurtStart: packet.Marker,
+		ArrivalTime:    meta.arrival,
+		PlayoutDelay:   meta.playoutDelay,
+		RTPSessionID:   rtpSessionID,
+	}
+}
@@ -3,6 +3,7 @@ package media
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,6 +13,9 @@ import (
 //   - Шумоподавление
 //   - Подавление эха (для входящих пакетов)
 //
+// Многоканальное аудио передается чередующимися отсчетами (interleaved PCM),
+// обработка и статистика уровня выполняются для каждого канала отдельно.
+//
 // Поддерживаемые кодеки: G.711 (μ-law/A-law), G.722, GSM.
 type AudioProcessor struct {
 	config AudioProcessorConfig
@@ -21,10 +25,14 @@ type AudioProcessor struct {
 	bytesProcessed uint64
 	packetsIn      uint64
 	packetsOut     uint64
+	channelStats   []AudioChannelStatistics
 
 	// Буферы для обработки
 	inputBuffer  []byte
 	outputBuffer []byte
+
+	// AGC, шумоподавление и эхоподавление временно не применяются
+	enhancementBypassed atomic.Bool
 }
 
 // AudioProcessorConfig содержит конфигурацию для создания AudioProcessor.
@@ -34,6 +42,7 @@ type AudioProcessorConfig struct {
 	Ptime       time.Duration // Packet time
 	SampleRate  uint32        // Частота дискретизации
 	Channels    int           // Количество каналов (1 или 2)
+	Codec       Codec         // Подключаемый кодек, заменяет встроенное кодирование
 
 	// Настройки обработки
 	EnableAGC      bool    // Automatic Gain Control
@@ -77,6 +86,7 @@ func NewAudioProcessor(config AudioProcessorConfig) *AudioProcessor {
 
 	return &AudioProcessor{
 		config:       config,
+		channelStats: make([]AudioChannelStatistics, config.Channels),
 		inputBuffer:  make([]byte, bufferSize),
 		outputBuffer: make([]byte, bufferSize),
 	}
@@ -90,9 +100,10 @@ func (ap *AudioProcessor) ProcessOutgoing(audioData []byte) ([]byte, error) {
 	ap.packetsIn++
 	ap.bytesProcessed += uint64(len(audioData))
 
-	// Проверяем размер данных
+	// Проверяем размер данных. Размер аудио для подключаемого кодека
+	// проверяет сам кодек
 	expectedSize := ap.getExpectedPacketSize()
-	if len(audioData) != expectedSize {
+	if ap.config.Codec == nil && len(audioData) != expectedSize {
 		return nil, NewAudioError(ErrorCodeAudioSizeInvalid, "",
 			fmt.Sprintf("неожиданный размер аудио данных: %d, ожидается: %d",
 				len(audioData), expectedSize),
@@ -100,19 +111,25 @@ func (ap *AudioProcessor) ProcessOutgoing(audioData []byte) ([]byte, error) {
 	}
 
 	// Копируем данные в рабочий буфер
+	if len(audioData) > len(ap.inputBuffer) {
+		ap.inputBuffer = make([]byte, len(audioData))
+	}
 	copy(ap.inputBuffer[:len(audioData)], audioData)
 
 	// Применяем обработку
 	processedData := ap.inputBuffer[:len(audioData)]
+	ap.updateChannelStats(processedData, true)
+
+	enhance := !ap.enhancementBypassed.Load()
 
 	// AGC (Automatic Gain Control)
-	if ap.config.EnableAGC {
-		processedData = ap.applyAGC(processedData)
+	if enhance && ap.config.EnableAGC {
+		processedData = ap.processChannels(processedData, ap.applyAGC)
 	}
 
 	// Noise Reduction
-	if ap.config.EnableNR {
-		processedData = ap.applyNoiseReduction(processedData)
+	if enhance && ap.config.EnableNR {
+		processedData = ap.processChannels(processedData, ap.applyNoiseReduction)
 	}
 
 	// Кодируем в нужный формат (если требуется)
@@ -139,31 +156,47 @@ func (ap *AudioProcessor) ProcessIncoming(audioData []byte) ([]byte, error) {
 		return nil, WrapMediaError(ErrorCodeAudioProcessingFailed, "", "ошибка декодирования аудио", err)
 	}
 
+	// Удаленная сторона может использовать другой ptime (асимметричная пакетизация),
+	// поэтому размер входящих данных не обязан совпадать с нашим размером пакета
+	if len(decodedData) > len(ap.inputBuffer) {
+		ap.inputBuffer = make([]byte, len(decodedData))
+	}
+
 	// Копируем данные в рабочий буфер
 	copy(ap.inputBuffer[:len(decodedData)], decodedData)
 
 	// Применяем обработку
 	processedData := ap.inputBuffer[:len(decodedData)]
 
+	enhance := !ap.enhancementBypassed.Load()
+
 	// Echo Cancellation
-	if ap.config.EnableEcho {
-		processedData = ap.applyEchoCancellation(processedData)
+	if enhance && ap.config.EnableEcho {
+		processedData = ap.processChannels(processedData, ap.applyEchoCancellation)
 	}
 
 	// Noise Reduction
-	if ap.config.EnableNR {
-		processedData = ap.applyNoiseReduction(processedData)
+	if enhance && ap.config.EnableNR {
+		processedData = ap.processChannels(processedData, ap.applyNoiseReduction)
 	}
 
 	// AGC
-	if ap.config.EnableAGC {
-		processedData = ap.applyAGC(processedData)
+	if enhance && ap.config.EnableAGC {
+		processedData = ap.processChannels(processedData, ap.applyAGC)
 	}
+	ap.updateChannelStats(processedData, false)
 
 	ap.packetsOut++
 	return processedData, nil
 }
 
+// SetEnhancementBypassed временно отключает AGC, шумоподавление и
+// эхоподавление без изменения конфигурации, например при деградации
+// сессии под нагрузкой. Кодирование и декодирование не затрагиваются
+func (ap *AudioProcessor) SetEnhancementBypassed(bypassed bool) {
+	ap.enhancementBypassed.Store(bypassed)
+}
+
 // SetPtime изменяет packet time
 func (ap *AudioProcessor) SetPtime(ptime time.Duration) {
 	ap.mutex.Lock()
@@ -179,6 +212,25 @@ func (ap *AudioProcessor) SetPtime(ptime time.Duration) {
 	ap.outputBuffer = make([]byte, bufferSize)
 }
 
+// SetPayloadType переключает процессор на другой тип кодека и его частоту
+// дискретизации. Буферы пересчитывает следующий SetPtime
+func (ap *AudioProcessor) SetPayloadType(payloadType PayloadType) {
+	ap.mutex.Lock()
+	defer ap.mutex.Unlock()
+
+	ap.config.PayloadType = payloadType
+	ap.config.SampleRate = getSampleRateForPayloadType(payloadType)
+}
+
+// SetCodec заменяет подключаемый кодек, например после согласования
+// параметров fmtp в SDP answer. nil возвращает встроенное кодирование.
+func (ap *AudioProcessor) SetCodec(codec Codec) {
+	ap.mutex.Lock()
+	defer ap.mutex.Unlock()
+
+	ap.config.Codec = codec
+}
+
 // GetStatistics возвращает статистику аудио процессора
 func (ap *AudioProcessor) GetStatistics() AudioProcessorStatistics {
 	ap.mutex.RLock()
@@ -192,6 +244,7 @@ func (ap *AudioProcessor) GetStatistics() AudioProcessorStatistics {
 		SampleRate:     ap.config.SampleRate,
 		Channels:       ap.config.Channels,
 		Ptime:          ap.config.Ptime,
+		ChannelStats:   append([]AudioChannelStatistics(nil), ap.channelStats...),
 	}
 }
 
@@ -204,6 +257,87 @@ type AudioProcessorStatistics struct {
 	SampleRate     uint32
 	Channels       int
 	Ptime          time.Duration
+	ChannelStats   []AudioChannelStatistics // Статистика по каналам, индекс - номер канала
+}
+
+// AudioChannelStatistics статистика одного канала аудио процессора
+type AudioChannelStatistics struct {
+	BytesOut uint64  // Байт исходящего аудио канала
+	BytesIn  uint64  // Байт входящего аудио канала
+	PeakOut  float32 // Пиковый уровень последнего исходящего пакета (0.0-1.0)
+	PeakIn   float32 // Пиковый уровень последнего входящего пакета (0.0-1.0)
+}
+
+// sampleWidth возвращает размер отсчета одного канала в байтах:
+// 2 для 16-битного PCM кодеков без кадров (L16), иначе 1 байт
+func (ap *AudioProcessor) sampleWidth() int {
+	if codec, ok := ap.config.Codec.(SampleCodec); ok && ap.config.Channels > 0 {
+		if width := codec.SampleSize() / ap.config.Channels; width > 0 {
+			return width
+		}
+	}
+	return getBytesPerSample(ap.config.PayloadType)
+}
+
+// processChannels применяет обработку к каждому каналу чередующегося аудио
+func (ap *AudioProcessor) processChannels(audioData []byte, process func([]byte) []byte) []byte {
+	if ap.config.Channels <= 1 {
+		return process(audioData)
+	}
+
+	channels, err := DeinterleaveChannels(audioData, ap.config.Channels, ap.sampleWidth())
+	if err != nil {
+		return process(audioData)
+	}
+	for i := range channels {
+		channels[i] = process(channels[i])
+	}
+	result, err := InterleaveChannels(channels, ap.sampleWidth())
+	if err != nil {
+		return audioData
+	}
+	return result
+}
+
+// updateChannelStats обновляет статистику каналов по аудио пакета
+func (ap *AudioProcessor) updateChannelStats(audioData []byte, outgoing bool) {
+	width := ap.sampleWidth()
+	frameSize := width * len(ap.channelStats)
+	if frameSize == 0 || len(audioData)%frameSize != 0 {
+		return
+	}
+
+	for ch := range ap.channelStats {
+		var peak float32
+		for offset := ch * width; offset < len(audioData); offset += frameSize {
+			if level := sampleLevel(audioData[offset : offset+width]); level > peak {
+				peak = level
+			}
+		}
+
+		stats := &ap.channelStats[ch]
+		bytes := uint64(len(audioData) / len(ap.channelStats))
+		if outgoing {
+			stats.BytesOut += bytes
+			stats.PeakOut = peak
+		} else {
+			stats.BytesIn += bytes
+			stats.PeakIn = peak
+		}
+	}
+}
+
+// sampleLevel возвращает нормализованный уровень отсчета (0.0-1.0):
+// 16-битный PCM в порядке little-endian или 8-битный отсчет
+func sampleLevel(sample []byte) float32 {
+	if len(sample) >= 2 {
+		value := int16(uint16(sample[0]) | uint16(sample[1])<<8)
+		if value < 0 {
+			return float32(-int32(value)) / 32768
+		}
+		return float32(value) / 32768
+	}
+	return float32(sample[0]) / 255
 }
 
 // getExpectedPacketSize вычисляет ожидаемый размер пакета
@@ -214,6 +348,10 @@ func (ap *AudioProcessor) getExpectedPacketSize() int {
 
 // encodeAudio кодирует аудио данные в заданный формат
 func (ap *AudioProcessor) encodeAudio(audioData []byte) ([]byte, error) {
+	if ap.config.Codec != nil {
+		return ap.config.Codec.Encode(audioData)
+	}
+
 	switch ap.config.PayloadType {
 	case PayloadTypePCMU:
 		return ap.encodePCMU(audioData), nil
@@ -233,6 +371,10 @@ func (ap *AudioProcessor) encodeAudio(audioData []byte) ([]byte, error) {
 
 // decodeAudio декодирует аудио данные из заданного формата
 func (ap *AudioProcessor) decodeAudio(audioData []byte) ([]byte, error) {
+	if ap.config.Codec != nil {
+		return ap.config.Codec.Decode(audioData)
+	}
+
 	switch ap.config.PayloadType {
 	case PayloadTypePCMU:
 		return ap.decodePCMU(audioData), nil
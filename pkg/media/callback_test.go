@@ -1,7 +1,9 @@
 package media
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -446,3 +448,439 @@ func TestErrorCallbacks(t *testing.T) {
 		t.Logf("Concurrent error callback тест завершен: обработано %d ошибок", errorCount)
 	})
 }
+
+// TestAudioFrameCallback тестирует передачу метаданных RTP в callback аудио кадров
+func TestAudioFrameCallback(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-audio-frame"
+
+	var mutex sync.Mutex
+	var frames []AudioFrame
+	config.OnAudioFrame = func(frame AudioFrame) {
+		mutex.Lock()
+		frames = append(frames, frame)
+		mutex.Unlock()
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	if !session.HasAudioFrameHandler() {
+		t.Fatal("Callback аудио кадров должен быть установлен из конфигурации")
+	}
+
+	mockRTP := NewMockSessionRTP("audio-frame", "PCMU")
+	_ = session.AddRTPSession("primary", mockRTP)
+	_ = session.Start()
+
+	before := time.Now()
+	packet := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    uint8(PayloadTypePCMU),
+			SequenceNumber: 4242,
+			Timestamp:      160000,
+			SSRC:           0xCAFEBABE,
+		},
+		Payload: generateTestAudioData(StandardPCMSamples20ms),
+	}
+	session.handleIncomingRTPPacketWithID(packet, "primary")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(frames) != 1 {
+		t.Fatalf("Ожидался 1 аудио кадр, получено %d", len(frames))
+	}
+
+	frame := frames[0]
+	if frame.SequenceNumber != 4242 || frame.Timestamp != 160000 || frame.SSRC != 0xCAFEBABE {
+		t.Errorf("Неверные метаданные RTP: seq=%d ts=%d ssrc=%x",
+			frame.SequenceNumber, frame.Timestamp, frame.SSRC)
+	}
+	if frame.RTPSessionID != "primary" {
+		t.Errorf("Неверный ID RTP сессии: %s", frame.RTPSessionID)
+	}
+	if frame.ArrivalTime.Before(before) {
+		t.Error("Время получения должно быть установлено при приеме пакета")
+	}
+	if frame.PlayoutDelay != 0 {
+		t.Errorf("Без jitter buffer задержка воспроизведения должна быть 0, получено %v", frame.PlayoutDelay)
+	}
+	if len(frame.Payload) != StandardPCMSamples20ms || len(frame.Audio) == 0 {
+		t.Errorf("Кадр должен содержать сырые и обработанные данные: payload=%d audio=%d",
+			len(frame.Payload), len(frame.Audio))
+	}
+
+	session.ClearAudioFrameHandler()
+	if session.HasAudioFrameHandler() {
+		t.Error("Callback аудио кадров должен быть очищен")
+	}
+}
+
+func TestAsymmetricPtime(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-asymmetric-ptime"
+	config.Ptime = time.Millisecond * 20
+
+	var mutex sync.Mutex
+	var frames []AudioFrame
+	config.OnAudioFrame = func(frame AudioFrame) {
+		mutex.Lock()
+		frames = append(frames, frame)
+		mutex.Unlock()
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	mockRTP := NewMockSessionRTP("asymmetric", "PCMU")
+	_ = session.AddRTPSession("primary", mockRTP)
+	_ = session.Start()
+
+	if session.GetRemotePtime() != config.Ptime {
+		t.Errorf("До приема пакетов ptime удаленной стороны должен совпадать с нашим: %v", session.GetRemotePtime())
+	}
+
+	// Удаленная сторона отправляет пакеты по 30ms (240 сэмплов PCMU)
+	const samples30ms = 240
+	for i := 0; i < 3; i++ {
+		packet := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    uint8(PayloadTypePCMU),
+				SequenceNumber: uint16(1000 + i),
+				Timestamp:      uint32(8000 + i*samples30ms),
+				SSRC:           0x12345678,
+			},
+			Payload: generateTestAudioData(samples30ms),
+		}
+		session.handleIncomingRTPPacketWithID(packet, "primary")
+	}
+
+	if remote := session.GetRemotePtime(); remote != time.Millisecond*30 {
+		t.Errorf("Ожидался ptime удаленной стороны 30ms, получено %v", remote)
+	}
+	if session.GetPtime() != time.Millisecond*20 {
+		t.Errorf("Ptime отправки не должен меняться: %v", session.GetPtime())
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(frames) != 3 {
+		t.Fatalf("Ожидалось 3 аудио кадра, получено %d", len(frames))
+	}
+	last := frames[len(frames)-1]
+	if last.Ptime != time.Millisecond*30 {
+		t.Errorf("Кадр должен содержать ptime удаленной стороны, получено %v", last.Ptime)
+	}
+	if len(last.Audio) != samples30ms {
+		t.Errorf("Кадр 30ms должен обрабатываться целиком: получено %d байт", len(last.Audio))
+	}
+}
+
+// TestDynamicClockRates проверяет таблицу частот RTP clock из rtpmap:
+// telephone-event/16000 должен пересчитывать длительность DTMF по 16 кГц
+func TestDynamicClockRates(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-clock-rates"
+	config.DTMFEnabled = true
+	config.DTMFPayloadType = 101
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	if rate := session.GetClockRate(101); rate != DTMFClockRate {
+		t.Errorf("Без rtpmap telephone-event должен использовать 8000, получено %d", rate)
+	}
+	if rate := session.GetClockRate(PayloadTypeG722); rate != 8000 {
+		t.Errorf("G.722 использует RTP clock 8000 (RFC 3551), получено %d", rate)
+	}
+
+	session.SetClockRates(map[PayloadType]uint32{101: 16000, 96: 48000})
+	if rate := session.GetClockRate(101); rate != 16000 {
+		t.Errorf("Ожидалась частота 16000 из rtpmap, получено %d", rate)
+	}
+	if rate := session.GetClockRate(96); rate != 48000 {
+		t.Errorf("Ожидалась частота 48000 для динамического payload type, получено %d", rate)
+	}
+
+	packets, err := session.dtmfSender.GeneratePackets(DTMFEvent{Digit: DTMF7, Duration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Ошибка генерации DTMF: %v", err)
+	}
+	duration := uint16(packets[0].Payload[2])<<8 | uint16(packets[0].Payload[3])
+	if duration != 1600 {
+		t.Errorf("100ms при 16 кГц - это 1600 единиц timestamp, получено %d", duration)
+	}
+
+	var received DTMFEvent
+	session.dtmfReceiver.SetCallback(func(event DTMFEvent) { received = event })
+	if _, err := session.dtmfReceiver.ProcessPacket(packets[0]); err != nil {
+		t.Fatalf("Ошибка обработки DTMF пакета: %v", err)
+	}
+	if received.Duration != 100*time.Millisecond {
+		t.Errorf("Длительность должна восстанавливаться по частоте 16000, получено %v", received.Duration)
+	}
+}
+
+// TestG729VADFramesDisabled проверяет отбрасывание SID кадров G.729 Annex B
+// при согласованном annexb=no
+func TestG729VADFramesDisabled(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-g729-annexb"
+	config.PayloadType = PayloadTypeG729
+	config.DisableVADFrames = true
+
+	var received [][]byte
+	config.OnRawAudioReceived = func(data []byte, _ PayloadType, _ time.Duration, _ string) {
+		received = append(received, data)
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	var sent [][]byte
+	mock := NewMockSessionRTP("primary", "G729")
+	mock.SetSendAudioCallback(func(data []byte, _ time.Duration) error {
+		sent = append(sent, data)
+		return nil
+	})
+	if err := session.AddRTPSession("primary", mock); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := mock.Start(); err != nil {
+		t.Fatalf("Ошибка запуска RTP сессии: %v", err)
+	}
+
+	// Два речевых кадра и SID, затем отдельный SID кадр
+	session.sendRTPPacket(make([]byte, 22))
+	session.sendRTPPacket(make([]byte, 2))
+	if len(sent) != 1 || len(sent[0]) != 20 {
+		t.Errorf("Ожидался один пакет из 20 байт без SID кадра, отправлено %d пакетов", len(sent))
+	}
+
+	incoming := &rtp.Packet{Header: rtp.Header{PayloadType: PayloadTypeG729, SSRC: 1}, Payload: make([]byte, 22)}
+	session.processDecodedPacketWithID(incoming, "primary", packetMetadata{})
+	session.processDecodedPacketWithID(&rtp.Packet{Header: rtp.Header{PayloadType: PayloadTypeG729, SSRC: 1, SequenceNumber: 1}, Payload: make([]byte, 2)}, "primary", packetMetadata{})
+	if len(received) != 1 || len(received[0]) != 20 {
+		t.Errorf("Ожидался один принятый пакет из 20 байт без SID кадра, принято %d", len(received))
+	}
+	if len(incoming.Payload) != 22 {
+		t.Error("Исходный пакет не должен изменяться")
+	}
+
+	// С разрешенным Annex B SID кадры проходят без изменений
+	session.SetVADFramesDisabled(false)
+	session.sendRTPPacket(make([]byte, 2))
+	if len(sent) != 2 || len(sent[1]) != 2 {
+		t.Error("При разрешенном Annex B SID кадр должен отправляться")
+	}
+}
+
+// talkspurtMockRTP - RTP сессия, запоминающая отметки начала talkspurt
+type talkspurtMockRTP struct {
+	*MockSessionRTP
+	marks int
+}
+
+func (m *talkspurtMockRTP) MarkTalkspurtStart() { m.marks++ }
+
+// TestSuppressedFrameMarksTalkspurt проверяет, что после подавленного кадра
+// RTP сессия получает отметку начала talkspurt для marker bit
+func TestSuppressedFrameMarksTalkspurt(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-talkspurt"
+	config.PayloadType = PayloadTypeG729
+	config.DisableVADFrames = true
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	mock := &talkspurtMockRTP{MockSessionRTP: NewMockSessionRTP("primary", "G729")}
+	if err := session.AddRTPSession("primary", mock); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := mock.Start(); err != nil {
+		t.Fatalf("Ошибка запуска RTP сессии: %v", err)
+	}
+
+	session.sendRTPPacket(make([]byte, 20))
+	if mock.marks != 0 {
+		t.Errorf("Речевой кадр не начинает talkspurt, отметок %d", mock.marks)
+	}
+	session.sendRTPPacket(make([]byte, 2))
+	if mock.marks != 1 {
+		t.Errorf("Подавленный SID кадр должен отмечать начало talkspurt, отметок %d", mock.marks)
+	}
+
+	session.MarkTalkspurtStart()
+	if mock.marks != 2 {
+		t.Errorf("MarkTalkspurtStart должен передаваться RTP сессиям, отметок %d", mock.marks)
+	}
+}
+
+// TestCallbackPanicRecovery проверяет, что паника в callback приложения
+// не останавливает сессию и передается в OnMediaError со стеком
+func TestCallbackPanicRecovery(t *testing.T) {
+	errors := make(chan error, 4)
+	var received int32
+
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-callback-panic"
+	config.DTMFEnabled = false
+	config.OnAudioReceived = func([]byte, PayloadType, time.Duration, string) {
+		if atomic.AddInt32(&received, 1) == 1 {
+			panic("ошибка приложения")
+		}
+	}
+	config.OnMediaError = func(err error, _ string) {
+		errors <- err
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	packet := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: PayloadTypePCMU, SequenceNumber: 1, SSRC: 1},
+		Payload: generateTestAudioData(StandardPCMSamples20ms),
+	}
+	session.processIncomingPacket(packet)
+	session.processIncomingPacket(packet)
+
+	if atomic.LoadInt32(&received) != 2 {
+		t.Errorf("После паники callback должен вызываться дальше, вызовов %d", received)
+	}
+
+	select {
+	case err := <-errors:
+		if !HasErrorCode(err, ErrorCodeCallbackPanic) {
+			t.Fatalf("Ожидалась ошибка CallbackPanic, получено %v", err)
+		}
+		panicErr, ok := err.(*CallbackPanicError)
+		if !ok {
+			t.Fatalf("Ожидался *CallbackPanicError, получено %T", err)
+		}
+		if panicErr.Callback != "OnAudioReceived" || panicErr.Value != "ошибка приложения" {
+			t.Errorf("Неверное описание паники: %s %v", panicErr.Callback, panicErr.Value)
+		}
+		if !strings.Contains(panicErr.Stack, "TestCallbackPanicRecovery") {
+			t.Errorf("Стек должен указывать на место паники:\n%s", panicErr.Stack)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnMediaError не получил ошибку паники")
+	}
+
+	// Паника в самом обработчике ошибок подавляется
+	session.SetAudioFrameHandler(func(AudioFrame) { panic("кадр") })
+	session.callbacksMutex.Lock()
+	session.onMediaError = func(error, string) { panic("обработчик ошибок") }
+	session.callbacksMutex.Unlock()
+	session.processIncomingPacket(packet)
+	time.Sleep(20 * time.Millisecond)
+}
+
+// TestCallbackRepanic проверяет режим разработки с повторной паникой
+func TestCallbackRepanic(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-callback-repanic"
+	config.DTMFEnabled = false
+	config.RepanicCallbacks = true
+	config.OnRawPacketReceived = func(*rtp.Packet, string) {
+		panic("ошибка приложения")
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	defer func() {
+		if r := recover(); r != "ошибка приложения" {
+			t.Errorf("Ожидалась исходная паника, получено %v", r)
+		}
+	}()
+	session.processIncomingPacket(&rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: PayloadTypePCMU, SequenceNumber: 1, SSRC: 1},
+		Payload: generateTestAudioData(StandardPCMSamples20ms),
+	})
+	t.Error("Паника должна быть возбуждена повторно")
+}
+
+// TestReceiveAudio проверяет получение аудио без callback: пакеты из очереди,
+// отмену по контексту и завершение после Stop
+func TestReceiveAudio(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-receive-audio"
+	config.DTMFEnabled = false
+	config.ReceiveQueueSize = 2
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+
+	payload := generateTestAudioData(StandardPCMSamples20ms)
+	for seq := uint16(1); seq <= 3; seq++ {
+		session.processIncomingPacket(&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: PayloadTypePCMU, SequenceNumber: seq, SSRC: 1},
+			Payload: payload,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		audio, err := session.ReceiveAudio(ctx)
+		if err != nil {
+			t.Fatalf("Ошибка получения аудио: %v", err)
+		}
+		if len(audio) == 0 {
+			t.Error("Получен пустой пакет аудио")
+		}
+	}
+
+	// Третий пакет вытеснил первый, очередь пуста
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := session.ReceiveAudio(short); err != context.DeadlineExceeded {
+		t.Errorf("Ожидался context.DeadlineExceeded, получено %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := session.ReceiveAudio(context.Background())
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	session.Stop()
+
+	select {
+	case err := <-done:
+		if !HasErrorCode(err, ErrorCodeSessionClosed) {
+			t.Errorf("Ожидалась ошибка SessionClosed, получено %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReceiveAudio не завершился после Stop")
+	}
+}
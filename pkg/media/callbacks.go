@@ -0,0 +1,39 @@
+package media
+
+import (
+	"log/slog"
+	"runtime/debug"
+)
+
+// invokeCallback вызывает пользовательский callback с перехватом паники.
+// Callback выполняются в общих горутинах приема и отправки, поэтому паника
+// в коде приложения не должна останавливать сессию: она преобразуется в
+// CallbackPanicError со стеком и передается в OnMediaError.
+func (ms *MediaSession) invokeCallback(name, rtpSessionID string, callback func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if ms.repanicCallbacks {
+				panic(r)
+			}
+			ms.handleError(NewCallbackPanicError(ms.sessionID, name, r, debug.Stack()), rtpSessionID)
+		}
+	}()
+	callback()
+}
+
+// invokeErrorHandler вызывает OnMediaError. Паника в самом обработчике
+// ошибок не может быть передана в него же и только записывается в лог.
+func (ms *MediaSession) invokeErrorHandler(handler func(error, string), err error, rtpSessionID string) {
+	defer func() {
+		if r := recover(); r != nil {
+			if ms.repanicCallbacks {
+				panic(r)
+			}
+			slog.Error("media: паника в OnMediaError",
+				slog.String("session", ms.sessionID),
+				slog.Any("panic", r),
+				slog.String("stack", string(debug.Stack())))
+		}
+	}()
+	handler(err, rtpSessionID)
+}
@@ -0,0 +1,68 @@
+package media
+
+import (
+	"fmt"
+)
+
+// Многоканальное аудио в медиа сессии передается чередующимися отсчетами
+// (interleaved PCM, RFC 3551 Section 4.1): отсчет канала 0, отсчет канала 1
+// и так далее. Порядок каналов для стерео - левый, правый.
+
+// InterleaveChannels объединяет аудио отдельных каналов в чередующиеся
+// отсчеты. sampleWidth - размер отсчета одного канала в байтах. Каналы
+// должны содержать одинаковое количество отсчетов.
+func InterleaveChannels(channels [][]byte, sampleWidth int) ([]byte, error) {
+	if len(channels) == 0 || sampleWidth <= 0 {
+		return nil, channelError("некорректные параметры: каналов %d, размер отсчета %d", len(channels), sampleWidth)
+	}
+
+	size := len(channels[0])
+	for i, channel := range channels {
+		if len(channel) != size || len(channel)%sampleWidth != 0 {
+			return nil, channelError("канал %d: размер %d не совпадает с каналом 0 (%d) или не кратен отсчету %d",
+				i, len(channel), size, sampleWidth)
+		}
+	}
+
+	frameSize := sampleWidth * len(channels)
+	result := make([]byte, size*len(channels))
+	for offset := 0; offset < size; offset += sampleWidth {
+		frame := result[offset/sampleWidth*frameSize:]
+		for ch, channel := range channels {
+			copy(frame[ch*sampleWidth:(ch+1)*sampleWidth], channel[offset:offset+sampleWidth])
+		}
+	}
+	return result, nil
+}
+
+// DeinterleaveChannels разделяет чередующиеся отсчеты на каналы
+func DeinterleaveChannels(audio []byte, channels, sampleWidth int) ([][]byte, error) {
+	if channels <= 0 || sampleWidth <= 0 {
+		return nil, channelError("некорректные параметры: каналов %d, размер отсчета %d", channels, sampleWidth)
+	}
+
+	frameSize := sampleWidth * channels
+	if len(audio)%frameSize != 0 {
+		return nil, channelError("размер аудио %d не кратен размеру кадра %d (%d каналов)", len(audio), frameSize, channels)
+	}
+
+	result := make([][]byte, channels)
+	for ch := range result {
+		result[ch] = make([]byte, 0, len(audio)/channels)
+	}
+	for offset := 0; offset < len(audio); offset += frameSize {
+		for ch := range result {
+			start := offset + ch*sampleWidth
+			result[ch] = append(result[ch], audio[start:start+sampleWidth]...)
+		}
+	}
+	return result, nil
+}
+
+// channelError создает ошибку разбора многоканального аудио
+func channelError(format string, args ...interface{}) error {
+	return &MediaError{
+		Code:    ErrorCodeAudioSizeInvalid,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
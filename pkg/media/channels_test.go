@@ -0,0 +1,104 @@
+package media
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestInterleaveChannels проверяет чередование и разделение отсчетов каналов
+func TestInterleaveChannels(t *testing.T) {
+	left := []byte{0x01, 0x02, 0x03, 0x04}
+	right := []byte{0x11, 0x12, 0x13, 0x14}
+
+	interleaved, err := InterleaveChannels([][]byte{left, right}, 2)
+	if err != nil {
+		t.Fatalf("Ошибка чередования: %v", err)
+	}
+	expected := []byte{0x01, 0x02, 0x11, 0x12, 0x03, 0x04, 0x13, 0x14}
+	if !bytes.Equal(interleaved, expected) {
+		t.Errorf("Ожидалось %x, получено %x", expected, interleaved)
+	}
+
+	channels, err := DeinterleaveChannels(interleaved, 2, 2)
+	if err != nil {
+		t.Fatalf("Ошибка разделения: %v", err)
+	}
+	if !bytes.Equal(channels[0], left) || !bytes.Equal(channels[1], right) {
+		t.Errorf("Каналы после разделения не совпадают: %x %x", channels[0], channels[1])
+	}
+
+	if _, err := InterleaveChannels([][]byte{left, right[:2]}, 2); err == nil {
+		t.Error("Каналы разной длины должны возвращать ошибку")
+	}
+	if _, err := DeinterleaveChannels(interleaved[:6], 2, 2); err == nil {
+		t.Error("Размер некратный кадру должен возвращать ошибку")
+	}
+}
+
+// TestAudioProcessorStereo проверяет размер пакета и статистику по каналам
+func TestAudioProcessorStereo(t *testing.T) {
+	codec, err := NewCodec("L16", CodecParams{ClockRate: 8000, Channels: 2})
+	if err != nil {
+		t.Fatalf("Ошибка создания кодека: %v", err)
+	}
+
+	processor := NewAudioProcessor(AudioProcessorConfig{
+		PayloadType: 96,
+		Ptime:       20 * time.Millisecond,
+		SampleRate:  8000,
+		Channels:    2,
+		Codec:       codec,
+		EnableNR:    true,
+	})
+
+	// Левый канал - полная амплитуда, правый - тишина
+	left := bytes.Repeat([]byte{0xff, 0x7f}, 160)
+	right := make([]byte, 320)
+	audio, err := InterleaveChannels([][]byte{left, right}, 2)
+	if err != nil {
+		t.Fatalf("Ошибка чередования: %v", err)
+	}
+
+	payload, err := processor.ProcessOutgoing(audio)
+	if err != nil {
+		t.Fatalf("Ошибка обработки: %v", err)
+	}
+	if len(payload) != 640 {
+		t.Errorf("20ms стерео L16/8000 - 640 байт, получено %d", len(payload))
+	}
+	if _, err := processor.ProcessIncoming(payload); err != nil {
+		t.Fatalf("Ошибка обработки входящего аудио: %v", err)
+	}
+
+	stats := processor.GetStatistics()
+	if stats.Channels != 2 || len(stats.ChannelStats) != 2 {
+		t.Fatalf("Ожидалась статистика 2 каналов: %+v", stats)
+	}
+	if stats.ChannelStats[0].BytesOut != 320 || stats.ChannelStats[1].BytesIn != 320 {
+		t.Errorf("Некорректное количество байт по каналам: %+v", stats.ChannelStats)
+	}
+	if stats.ChannelStats[0].PeakOut < 0.99 || stats.ChannelStats[1].PeakOut != 0 {
+		t.Errorf("Уровни каналов не разделены: %+v", stats.ChannelStats)
+	}
+}
+
+// TestSessionChannels проверяет ожидаемый размер payload многоканальной сессии
+func TestSessionChannels(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-stereo-session"
+	config.Channels = 2
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	if session.GetChannels() != 2 {
+		t.Errorf("Ожидалось 2 канала, получено %d", session.GetChannels())
+	}
+	if session.GetExpectedPayloadSize() != 320 {
+		t.Errorf("20ms стерео PCMU - 320 байт, получено %d", session.GetExpectedPayloadSize())
+	}
+}
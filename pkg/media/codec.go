@@ -0,0 +1,110 @@
+package media
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Codec - подключаемый кодек для payload types, которые не реализованы
+// встроенным AudioProcessor (AMR, AMR-WB и другие кодеки с динамическим
+// payload type). Кодек отвечает и за формат RTP payload: один вызов Encode
+// получает аудио одного пакета (ptime) и возвращает готовый payload.
+//
+// Реализации должны быть безопасны для одновременного вызова Encode и Decode.
+type Codec interface {
+	// Encode кодирует аудио одного пакета в RTP payload
+	Encode(audio []byte) ([]byte, error)
+
+	// Decode декодирует RTP payload в аудио
+	Decode(payload []byte) ([]byte, error)
+}
+
+// CodecParams содержит согласованные параметры для создания кодека
+type CodecParams struct {
+	PayloadType PayloadType
+	ClockRate   uint32
+	Channels    int // Количество каналов из rtpmap, 0 - один канал
+	Ptime       time.Duration
+	Fmtp        map[string]string // Параметры fmtp, ключи в нижнем регистре
+}
+
+// CodecFactory создает кодек с согласованными параметрами
+type CodecFactory func(params CodecParams) (Codec, error)
+
+var codecRegistry = struct {
+	mutex     sync.RWMutex
+	factories map[string]CodecFactory
+}{factories: make(map[string]CodecFactory)}
+
+// RegisterCodec регистрирует фабрику кодека по имени кодировки из rtpmap
+// (например, "AMR" или "AMR-WB"). Имя не зависит от регистра. Повторная
+// регистрация заменяет фабрику, nil удаляет ее.
+func RegisterCodec(name string, factory CodecFactory) {
+	codecRegistry.mutex.Lock()
+	defer codecRegistry.mutex.Unlock()
+
+	name = strings.ToUpper(name)
+	if factory == nil {
+		delete(codecRegistry.factories, name)
+		return
+	}
+	codecRegistry.factories[name] = factory
+}
+
+// IsCodecRegistered проверяет, зарегистрирован ли кодек с указанным именем
+func IsCodecRegistered(name string) bool {
+	codecRegistry.mutex.RLock()
+	defer codecRegistry.mutex.RUnlock()
+
+	_, ok := codecRegistry.factories[strings.ToUpper(name)]
+	return ok
+}
+
+// RegisteredCodecs возвращает отсортированный список зарегистрированных кодеков
+func RegisteredCodecs() []string {
+	codecRegistry.mutex.RLock()
+	defer codecRegistry.mutex.RUnlock()
+
+	names := make([]string, 0, len(codecRegistry.factories))
+	for name := range codecRegistry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// codecError создает ошибку формата payload подключаемого кодека
+func codecError(codec string, format string, args ...interface{}) error {
+	return &MediaError{
+		Code:    ErrorCodeAudioProcessingFailed,
+		Message: codec + ": " + fmt.Sprintf(format, args...),
+	}
+}
+
+// NewCodec создает зарегистрированный кодек
+func NewCodec(name string, params CodecParams) (Codec, error) {
+	codecRegistry.mutex.RLock()
+	factory, ok := codecRegistry.factories[strings.ToUpper(name)]
+	codecRegistry.mutex.RUnlock()
+
+	if !ok {
+		return nil, &MediaError{
+			Code:    ErrorCodeAudioCodecUnsupported,
+			Message: fmt.Sprintf("кодек %s не зарегистрирован", name),
+			Context: map[string]interface{}{
+				"codec":        name,
+				"payload_type": params.PayloadType,
+			},
+		}
+	}
+
+	codec, err := factory(params)
+	if err != nil {
+		return nil, WrapMediaError(ErrorCodeAudioCodecUnsupported, "",
+			fmt.Sprintf("ошибка создания кодека %s", name), err)
+	}
+	return codec, nil
+}
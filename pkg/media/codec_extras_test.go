@@ -0,0 +1,106 @@
+//go:build !small
+
+package media
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// fakeILBCFrameCodec имитирует внешний кодер iLBC
+type fakeILBCFrameCodec struct {
+	decodedModes []time.Duration
+}
+
+func (c *fakeILBCFrameCodec) EncodeFrame(audio []byte, mode time.Duration) ([]byte, error) {
+	return bytes.Repeat([]byte{audio[0]}, ilbcFrameSize(mode)), nil
+}
+
+func (c *fakeILBCFrameCodec) DecodeFrame(frame []byte, mode time.Duration) ([]byte, error) {
+	c.decodedModes = append(c.decodedModes, mode)
+	return bytes.Repeat([]byte{frame[0]}, int(mode/time.Millisecond)*8), nil
+}
+
+// TestILBCCodec проверяет упаковку кадров iLBC и определение режима по размеру payload
+func TestILBCCodec(t *testing.T) {
+	frames := &fakeILBCFrameCodec{}
+	RegisterILBCFrameCodec(func() (ILBCFrameCodec, error) { return frames, nil })
+	defer RegisterILBCFrameCodec(nil)
+
+	if _, err := NewCodec("iLBC", CodecParams{Ptime: 20 * time.Millisecond}); err == nil {
+		t.Error("Без mode используется 30ms, ptime 20ms должен быть ошибкой")
+	}
+	if _, err := NewCodec("iLBC", CodecParams{Ptime: 20 * time.Millisecond, Fmtp: map[string]string{"mode": "25"}}); err == nil {
+		t.Error("mode=25 не поддерживается")
+	}
+
+	codec, err := NewCodec("ilbc", CodecParams{Ptime: 40 * time.Millisecond, Fmtp: map[string]string{"mode": "20"}})
+	if err != nil {
+		t.Fatalf("Ошибка создания кодека: %v", err)
+	}
+
+	payload, err := codec.Encode(generateTestAudioData(StandardPCMSamples40ms))
+	if err != nil {
+		t.Fatalf("Ошибка кодирования: %v", err)
+	}
+	if len(payload) != 2*ILBCFrameSize20ms {
+		t.Errorf("40ms в режиме 20ms - два кадра по 38 байт, получено %d байт", len(payload))
+	}
+
+	if _, err := codec.Decode(payload); err != nil {
+		t.Fatalf("Ошибка декодирования: %v", err)
+	}
+	// Удаленная сторона отправляет кадры 30ms
+	if _, err := codec.Decode(make([]byte, ILBCFrameSize30ms)); err != nil {
+		t.Fatalf("Ошибка декодирования кадра 30ms: %v", err)
+	}
+	expected := []time.Duration{20 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}
+	if len(frames.decodedModes) != len(expected) {
+		t.Fatalf("Ожидалось %d кадров, декодировано %d", len(expected), len(frames.decodedModes))
+	}
+	for i := range expected {
+		if frames.decodedModes[i] != expected[i] {
+			t.Errorf("Кадр %d декодирован в режиме %v, ожидается %v", i, frames.decodedModes[i], expected[i])
+		}
+	}
+
+	if _, err := codec.Decode(make([]byte, 40)); err == nil {
+		t.Error("Payload некратный размеру кадра должен возвращать ошибку")
+	}
+}
+
+// TestSpeexCodecParams проверяет разбор параметров Speex перед вызовом внешней фабрики
+func TestSpeexCodecParams(t *testing.T) {
+	var received SpeexParams
+	RegisterSpeexCodec(func(params SpeexParams) (Codec, error) {
+		received = params
+		return nil, nil
+	})
+	defer RegisterSpeexCodec(nil)
+
+	if _, err := NewCodec("speex", CodecParams{ClockRate: 48000}); err == nil {
+		t.Error("Частота 48000 не поддерживается Speex")
+	}
+	if _, err := NewCodec("speex", CodecParams{ClockRate: 8000, Fmtp: map[string]string{"vbr": "maybe"}}); err == nil {
+		t.Error("Некорректный vbr должен возвращать ошибку")
+	}
+
+	_, err := NewCodec("SPEEX", CodecParams{
+		ClockRate: 16000,
+		Ptime:     40 * time.Millisecond,
+		Fmtp:      map[string]string{"mode": `"3,any"`, "vbr": "VAD", "cng": "on"},
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания кодека: %v", err)
+	}
+	if received.ClockRate != 16000 || received.Frames != 2 || received.Mode != "3,any" ||
+		received.VBR != "vad" || !received.CNG {
+		t.Errorf("Некорректные параметры Speex: %+v", received)
+	}
+
+	names := RegisteredCodecs()
+	if len(names) == 0 || names[len(names)-1] != "SPEEX" {
+		t.Errorf("Speex должен присутствовать в списке кодеков: %v", names)
+	}
+}
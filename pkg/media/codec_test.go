@@ -0,0 +1,104 @@
+package media
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// TestL16Codec проверяет сетевой порядок байт L16 и кратность размеру отсчета
+func TestL16Codec(t *testing.T) {
+	if !IsCodecRegistered("l16") {
+		t.Fatal("L16 должен быть зарегистрирован по умолчанию")
+	}
+
+	codec, err := NewCodec("L16", CodecParams{PayloadType: PayloadTypeL16_2CH, ClockRate: 44100, Channels: 2})
+	if err != nil {
+		t.Fatalf("Ошибка создания кодека: %v", err)
+	}
+	if codec.(SampleCodec).SampleSize() != 4 {
+		t.Errorf("Отсчет стерео L16 - 4 байта, получено %d", codec.(SampleCodec).SampleSize())
+	}
+
+	payload, err := codec.Encode([]byte{0x34, 0x12, 0x78, 0x56})
+	if err != nil {
+		t.Fatalf("Ошибка кодирования: %v", err)
+	}
+	if !bytes.Equal(payload, []byte{0x12, 0x34, 0x56, 0x78}) {
+		t.Errorf("Payload должен быть в big-endian, получено %x", payload)
+	}
+	audio, err := codec.Decode(payload)
+	if err != nil {
+		t.Fatalf("Ошибка декодирования: %v", err)
+	}
+	if !bytes.Equal(audio, []byte{0x34, 0x12, 0x78, 0x56}) {
+		t.Errorf("Декодированное аудио не совпадает с исходным: %x", audio)
+	}
+
+	if _, err := codec.Encode(make([]byte, 6)); err == nil {
+		t.Error("Аудио некратное отсчету стерео должно возвращать ошибку")
+	}
+	if _, err := NewCodec("L16", CodecParams{}); err == nil {
+		t.Error("L16 без частоты должен возвращать ошибку")
+	}
+}
+
+// TestL16Fragmentation проверяет деление payload L16 больше MTU на RTP
+// пакеты с шагом timestamp, равным количеству отсчетов
+func TestL16Fragmentation(t *testing.T) {
+	codec, err := NewCodec("L16", CodecParams{PayloadType: PayloadTypeL16_2CH, ClockRate: 44100, Channels: 2})
+	if err != nil {
+		t.Fatalf("Ошибка создания кодека: %v", err)
+	}
+
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-l16-session"
+	config.PayloadType = PayloadTypeL16_2CH
+	config.Codec = codec
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	type sentPacket struct {
+		size     int
+		duration time.Duration
+	}
+	var sent []sentPacket
+	mock := NewMockSessionRTP("primary", "L16")
+	mock.SetSendAudioCallback(func(data []byte, duration time.Duration) error {
+		sent = append(sent, sentPacket{size: len(data), duration: duration})
+		return nil
+	})
+	if err := session.AddRTPSession("primary", mock); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := mock.Start(); err != nil {
+		t.Fatalf("Ошибка запуска RTP сессии: %v", err)
+	}
+
+	// 20ms стерео 44100 Гц: 882 отсчета по 4 байта
+	payload := make([]byte, 882*4)
+	session.sendRTPPacket(payload)
+
+	if len(sent) != 3 {
+		t.Fatalf("3528 байт должны быть разделены на 3 пакета, отправлено %d", len(sent))
+	}
+	for i, packet := range sent {
+		if packet.size != 294*4 {
+			t.Errorf("Пакет %d: размер %d, ожидается %d", i, packet.size, 294*4)
+		}
+		if samples := uint32(packet.duration.Seconds() * 44100); samples != 294 {
+			t.Errorf("Пакет %d: шаг timestamp %d, ожидается 294", i, samples)
+		}
+	}
+
+	// Payload в пределах MTU отправляется одним пакетом
+	sent = nil
+	session.sendRTPPacket(make([]byte, 160*2*2))
+	if len(sent) != 1 || sent[0].duration != session.GetPtime() {
+		t.Errorf("Payload 640 байт должен быть отправлен одним пакетом: %+v", sent)
+	}
+}
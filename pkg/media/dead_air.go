@@ -0,0 +1,273 @@
+package media
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeadAirKind определяет вид обнаруженной тишины
+type DeadAirKind int
+
+const (
+	// DeadAirBidirectional - тишина в обоих направлениях
+	DeadAirBidirectional DeadAirKind = iota
+	// DeadAirNoRemoteAudio - односторонний звук: мы говорим, удаленная сторона молчит
+	DeadAirNoRemoteAudio
+	// DeadAirNoLocalAudio - односторонний звук: удаленная сторона говорит, мы молчим
+	DeadAirNoLocalAudio
+)
+
+// String возвращает название вида тишины
+func (k DeadAirKind) String() string {
+	switch k {
+	case DeadAirBidirectional:
+		return "bidirectional"
+	case DeadAirNoRemoteAudio:
+		return "no-remote-audio"
+	case DeadAirNoLocalAudio:
+		return "no-local-audio"
+	default:
+		return "unknown"
+	}
+}
+
+// DeadAirAction определяет действие при обнаружении тишины
+type DeadAirAction int
+
+const (
+	// DeadAirActionEvent - только событие OnDeadAir
+	DeadAirActionEvent DeadAirAction = iota
+	// DeadAirActionPrompt - воспроизвести подсказку ("вы еще на линии?")
+	DeadAirActionPrompt
+	// DeadAirActionHangup - завершить вызов через OnHangup
+	DeadAirActionHangup
+)
+
+// String возвращает название действия
+func (a DeadAirAction) String() string {
+	switch a {
+	case DeadAirActionEvent:
+		return "event"
+	case DeadAirActionPrompt:
+		return "prompt"
+	case DeadAirActionHangup:
+		return "hangup"
+	default:
+		return "unknown"
+	}
+}
+
+// DeadAirEvent описывает обнаруженную тишину и выполненное действие
+type DeadAirEvent struct {
+	SessionID string
+	Kind      DeadAirKind
+	Duration  time.Duration // Длительность тишины на момент срабатывания
+	Action    DeadAirAction
+	Err       error // Ошибка воспроизведения подсказки
+}
+
+// DeadAirConfig содержит параметры контроля тишины.
+//
+// Тишина определяется по энергии аудио (см. AnalyticsStream), а не по
+// отсутствию пакетов: кадры комфортного шума и нулевой звук с работающим
+// RTP потоком также считаются тишиной. Интервалы с кодеками, которые не
+// декодируются для измерения, не учитываются.
+type DeadAirConfig struct {
+	// Timeout - длительность тишины в обоих направлениях. 0 - не контролируется
+	Timeout time.Duration
+	// OneWayTimeout - длительность одностороннего звука. 0 - не контролируется
+	OneWayTimeout time.Duration
+
+	// Action - действие при срабатывании
+	Action DeadAirAction
+	// Prompt - подсказка в кодировке payload type сессии для DeadAirActionPrompt
+	Prompt []byte
+
+	// OnDeadAir вызывается при каждом срабатывании (опционально)
+	OnDeadAir func(DeadAirEvent)
+	// OnHangup завершает вызов для DeadAirActionHangup, например
+	// через Terminate SIP диалога
+	OnHangup func(DeadAirEvent)
+
+	// Interval - интервал измерения. 0 - DefaultAnalyticsInterval
+	Interval time.Duration
+}
+
+// DeadAirSupervisor контролирует продолжительную тишину медиа сессии и
+// выполняет корректирующие действия. После срабатывания отсчет начинается
+// заново, поэтому подсказка повторяется, пока тишина продолжается. После
+// DeadAirActionHangup контроль прекращается.
+//
+// Пример использования:
+//
+//	supervisor, err := session.SuperviseDeadAir(media.DeadAirConfig{
+//	    Timeout:       30 * time.Second,
+//	    OneWayTimeout: 10 * time.Second,
+//	    Action:        media.DeadAirActionHangup,
+//	    OnHangup: func(event media.DeadAirEvent) {
+//	        _ = dlg.Terminate()
+//	    },
+//	})
+//	defer supervisor.Stop()
+type DeadAirSupervisor struct {
+	session *MediaSession
+	config  DeadAirConfig
+
+	mu            sync.Mutex
+	bidirectional time.Duration // Накопленная тишина в обоих направлениях
+	noRemote      time.Duration // Накопленная тишина только удаленной стороны
+	noLocal       time.Duration // Накопленная тишина только нашей стороны
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// SuperviseDeadAir запускает контроль тишины медиа сессии. Контроль
+// прекращается при остановке сессии или вызове Stop.
+func (ms *MediaSession) SuperviseDeadAir(config DeadAirConfig) (*DeadAirSupervisor, error) {
+	supervisor, err := newDeadAirSupervisor(ms, config)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := ms.AnalyticsStream(config.Interval)
+	go func() {
+		for {
+			select {
+			case record, ok := <-stream:
+				if !ok {
+					return
+				}
+				if event, triggered := supervisor.observe(record); triggered {
+					supervisor.act(event)
+				}
+			case <-supervisor.done:
+				return
+			}
+		}
+	}()
+
+	return supervisor, nil
+}
+
+// newDeadAirSupervisor проверяет конфигурацию и создает контроль тишины
+func newDeadAirSupervisor(ms *MediaSession, config DeadAirConfig) (*DeadAirSupervisor, error) {
+	if config.Timeout <= 0 && config.OneWayTimeout <= 0 {
+		return nil, deadAirError("не задан ни Timeout, ни OneWayTimeout")
+	}
+	switch config.Action {
+	case DeadAirActionEvent:
+	case DeadAirActionPrompt:
+		if len(config.Prompt) == 0 {
+			return nil, deadAirError("для действия %s не задана подсказка Prompt", config.Action)
+		}
+	case DeadAirActionHangup:
+		if config.OnHangup == nil {
+			return nil, deadAirError("для действия %s не задан OnHangup", config.Action)
+		}
+	default:
+		return nil, deadAirError("неизвестное действие %d", config.Action)
+	}
+
+	return &DeadAirSupervisor{
+		session: ms,
+		config:  config,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Stop прекращает контроль тишины
+func (s *DeadAirSupervisor) Stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+// observe учитывает запись аналитики и возвращает событие, если тишина
+// превысила порог. Накопленная тишина после срабатывания сбрасывается.
+func (s *DeadAirSupervisor) observe(record AnalyticsRecord) (DeadAirEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Энергию направления с неизмеряемым кодеком определить нельзя
+	if unmeasured(record.TX) || unmeasured(record.RX) {
+		return DeadAirEvent{}, false
+	}
+
+	txSilent, rxSilent := record.TX.IsSilent(), record.RX.IsSilent()
+	s.bidirectional = accumulate(s.bidirectional, txSilent && rxSilent, record.Interval)
+	s.noRemote = accumulate(s.noRemote, rxSilent && !txSilent, record.Interval)
+	s.noLocal = accumulate(s.noLocal, txSilent && !rxSilent, record.Interval)
+
+	event := DeadAirEvent{SessionID: record.SessionID, Action: s.config.Action}
+	switch {
+	case s.config.Timeout > 0 && s.bidirectional >= s.config.Timeout:
+		event.Kind, event.Duration = DeadAirBidirectional, s.bidirectional
+	case s.config.OneWayTimeout > 0 && s.noRemote >= s.config.OneWayTimeout:
+		event.Kind, event.Duration = DeadAirNoRemoteAudio, s.noRemote
+	case s.config.OneWayTimeout > 0 && s.noLocal >= s.config.OneWayTimeout:
+		event.Kind, event.Duration = DeadAirNoLocalAudio, s.noLocal
+	default:
+		return DeadAirEvent{}, false
+	}
+
+	s.bidirectional, s.noRemote, s.noLocal = 0, 0, 0
+	return event, true
+}
+
+// act выполняет действие для сработавшего события
+func (s *DeadAirSupervisor) act(event DeadAirEvent) {
+	switch event.Action {
+	case DeadAirActionPrompt:
+		event.Err = s.session.playPrompt(s.config.Prompt)
+	case DeadAirActionHangup:
+		s.Stop()
+	}
+
+	if s.config.OnDeadAir != nil {
+		s.session.invokeCallback("OnDeadAir", "", func() {
+			s.config.OnDeadAir(event)
+		})
+	}
+	if event.Action == DeadAirActionHangup {
+		s.session.invokeCallback("OnHangup", "", func() {
+			s.config.OnHangup(event)
+		})
+	}
+}
+
+// unmeasured проверяет, что в направлении были кадры, но ни один не измерен
+func unmeasured(a AudioAnalytics) bool {
+	return a.Frames > 0 && a.MeasuredFrames == 0
+}
+
+// accumulate увеличивает длительность тишины или сбрасывает ее
+func accumulate(current time.Duration, silent bool, interval time.Duration) time.Duration {
+	if !silent {
+		return 0
+	}
+	return current + interval
+}
+
+// playPrompt отправляет закодированную подсказку пакетами ptime сессии.
+// Неполный последний пакет отбрасывается.
+func (ms *MediaSession) playPrompt(prompt []byte) error {
+	if ms.codecEnabled.Load() {
+		return ms.SendAudioRaw(prompt)
+	}
+
+	size := ms.GetExpectedPayloadSize()
+	for offset := 0; offset+size <= len(prompt); offset += size {
+		if err := ms.SendAudioRaw(prompt[offset : offset+size]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deadAirError создает ошибку конфигурации контроля тишины
+func deadAirError(format string, args ...interface{}) error {
+	return &MediaError{
+		Code:    ErrorCodeSessionInvalidConfig,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,132 @@
+package media
+
+import (
+	"testing"
+	"time"
+)
+
+// analyticsInterval создает запись аналитики за секунду с заданной речью
+func analyticsInterval(txSpeech, rxSpeech bool) AnalyticsRecord {
+	direction := func(speech bool) AudioAnalytics {
+		a := AudioAnalytics{Frames: 50, MeasuredFrames: 50}
+		if speech {
+			a.SpeechFrames = 50
+		}
+		return a
+	}
+	return AnalyticsRecord{SessionID: "s", Interval: time.Second, TX: direction(txSpeech), RX: direction(rxSpeech)}
+}
+
+// TestDeadAirConfigValidation проверяет проверку конфигурации
+func TestDeadAirConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DeadAirConfig
+	}{
+		{"без таймаутов", DeadAirConfig{}},
+		{"подсказка без Prompt", DeadAirConfig{Timeout: time.Second, Action: DeadAirActionPrompt}},
+		{"завершение без OnHangup", DeadAirConfig{Timeout: time.Second, Action: DeadAirActionHangup}},
+		{"неизвестное действие", DeadAirConfig{Timeout: time.Second, Action: DeadAirAction(42)}},
+	}
+	for _, tt := range tests {
+		if _, err := newDeadAirSupervisor(nil, tt.config); err == nil {
+			t.Errorf("%s: ожидалась ошибка", tt.name)
+		}
+	}
+}
+
+// TestDeadAirObserve проверяет накопление тишины и сброс после срабатывания
+func TestDeadAirObserve(t *testing.T) {
+	supervisor, err := newDeadAirSupervisor(nil, DeadAirConfig{Timeout: 3 * time.Second, OneWayTimeout: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("Ошибка создания: %v", err)
+	}
+
+	// Речь прерывает отсчет двусторонней тишины
+	for _, record := range []AnalyticsRecord{
+		analyticsInterval(false, false),
+		analyticsInterval(false, false),
+		analyticsInterval(true, true),
+		analyticsInterval(false, false),
+		analyticsInterval(false, false),
+	} {
+		if _, triggered := supervisor.observe(record); triggered {
+			t.Fatal("Тишина прерывалась речью и не должна срабатывать")
+		}
+	}
+	event, triggered := supervisor.observe(analyticsInterval(false, false))
+	if !triggered || event.Kind != DeadAirBidirectional || event.Duration != 3*time.Second {
+		t.Fatalf("Ожидалась двусторонняя тишина 3s, получено %+v (%v)", event, triggered)
+	}
+	if event.Action != DeadAirActionEvent || event.SessionID != "s" {
+		t.Errorf("Некорректное событие: %+v", event)
+	}
+
+	// После срабатывания отсчет начинается заново
+	if _, triggered := supervisor.observe(analyticsInterval(false, false)); triggered {
+		t.Error("Отсчет должен сбрасываться после срабатывания")
+	}
+
+	// Односторонний звук в каждом направлении
+	supervisor.observe(analyticsInterval(true, false))
+	event, triggered = supervisor.observe(analyticsInterval(true, false))
+	if !triggered || event.Kind != DeadAirNoRemoteAudio {
+		t.Errorf("Ожидалась тишина удаленной стороны, получено %+v", event)
+	}
+	supervisor.observe(analyticsInterval(false, true))
+	event, triggered = supervisor.observe(analyticsInterval(false, true))
+	if !triggered || event.Kind != DeadAirNoLocalAudio {
+		t.Errorf("Ожидалась тишина нашей стороны, получено %+v", event)
+	}
+
+	// Интервалы с неизмеряемым кодеком не учитываются
+	unmeasurable := analyticsInterval(false, false)
+	unmeasurable.RX.MeasuredFrames = 0
+	for i := 0; i < 5; i++ {
+		if _, triggered := supervisor.observe(unmeasurable); triggered {
+			t.Fatal("Неизмеряемый интервал не должен считаться тишиной")
+		}
+	}
+}
+
+// TestSuperviseDeadAirHangup проверяет завершение вызова при тишине в сессии
+func TestSuperviseDeadAirHangup(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-dead-air"
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer func() { _ = session.Stop() }()
+
+	events := make(chan DeadAirEvent, 4)
+	hangups := make(chan DeadAirEvent, 4)
+	_, err = session.SuperviseDeadAir(DeadAirConfig{
+		Timeout:   30 * time.Millisecond,
+		Action:    DeadAirActionHangup,
+		Interval:  10 * time.Millisecond,
+		OnDeadAir: func(event DeadAirEvent) { events <- event },
+		OnHangup:  func(event DeadAirEvent) { hangups <- event },
+	})
+	if err != nil {
+		t.Fatalf("Ошибка запуска контроля: %v", err)
+	}
+
+	select {
+	case event := <-hangups:
+		if event.Kind != DeadAirBidirectional || event.SessionID != config.SessionID {
+			t.Errorf("Некорректное событие: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Вызов не завершен при тишине")
+	}
+	<-events
+
+	// После завершения контроль прекращается
+	select {
+	case event := <-hangups:
+		t.Errorf("Повторное завершение: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
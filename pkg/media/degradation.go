@@ -0,0 +1,222 @@
+package media
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// DegradationLevel - уровень отключения необязательной обработки сессии при
+// нехватке CPU. Каждый уровень включает отключения предыдущих
+type DegradationLevel int32
+
+const (
+	// DegradationNone - вся обработка включена
+	DegradationNone DegradationLevel = iota
+	// DegradationAnalytics - не измеряется аудио аналитика (AnalyticsStream,
+	// контроль тишины)
+	DegradationAnalytics
+	// DegradationTaps - не передается аудио на распознавание (StartTranscription)
+	DegradationTaps
+	// DegradationEnhancement - аудио процессор не применяет AGC,
+	// шумоподавление и эхоподавление, остается только кодирование
+	DegradationEnhancement
+)
+
+// String возвращает название уровня
+func (l DegradationLevel) String() string {
+	switch l {
+	case DegradationNone:
+		return "none"
+	case DegradationAnalytics:
+		return "analytics"
+	case DegradationTaps:
+		return "taps"
+	case DegradationEnhancement:
+		return "enhancement"
+	default:
+		return "unknown"
+	}
+}
+
+// Значения DegradationConfig по умолчанию
+const (
+	DefaultDegradationWindow         = time.Second
+	DefaultDegradationMissThreshold  = 0.05
+	DefaultDegradationRecoverWindows = 10
+)
+
+// DegradationEvent сообщает об изменении уровня деградации сессии
+type DegradationEvent struct {
+	SessionID string
+	Level     DegradationLevel // Новый уровень
+	Previous  DegradationLevel // Предыдущий уровень
+	Ticks     uint64           // Сроков отправки в окне измерения
+	Misses    uint64           // Пропущенных сроков в окне измерения
+}
+
+// DegradationConfig включает контроль сроков цикла отправки. Если за окно
+// Window доля пропущенных сроков отправки (тик опоздал больше чем на
+// половину ptime) достигает MissThreshold, сессия отключает следующую
+// ступень необязательной обработки. После RecoverWindows окон без пропусков
+// обработка включается обратно по одной ступени.
+//
+// RTP поток, DTMF и RTCP не отключаются ни на одном уровне.
+type DegradationConfig struct {
+	// Window - окно измерения, по умолчанию DefaultDegradationWindow
+	Window time.Duration
+	// MissThreshold - доля пропущенных сроков (0-1) для повышения уровня,
+	// по умолчанию DefaultDegradationMissThreshold
+	MissThreshold float64
+	// RecoverWindows - окон без пропусков для понижения уровня,
+	// по умолчанию DefaultDegradationRecoverWindows
+	RecoverWindows int
+	// MaxLevel - максимальный уровень, по умолчанию DegradationEnhancement
+	MaxLevel DegradationLevel
+
+	// OnDegradation вызывается при каждом изменении уровня (опционально).
+	// Вызов выполняется вне цикла отправки
+	OnDegradation func(DegradationEvent)
+}
+
+// degradationController отслеживает сроки цикла отправки. Методы
+// наблюдения вызываются под sendMutex
+type degradationController struct {
+	config DegradationConfig
+	level  atomic.Int32
+
+	lastTick    time.Time
+	windowStart time.Time
+	ticks       uint64
+	misses      uint64
+	clean       int // Окон без пропусков подряд
+}
+
+func newDegradationController(config DegradationConfig) *degradationController {
+	if config.Window <= 0 {
+		config.Window = DefaultDegradationWindow
+	}
+	if config.MissThreshold <= 0 {
+		config.MissThreshold = DefaultDegradationMissThreshold
+	}
+	if config.RecoverWindows <= 0 {
+		config.RecoverWindows = DefaultDegradationRecoverWindows
+	}
+	if config.MaxLevel <= DegradationNone || config.MaxLevel > DegradationEnhancement {
+		config.MaxLevel = DegradationEnhancement
+	}
+	return &degradationController{config: config}
+}
+
+// observeTick учитывает тик отправки с интервалом interval. Возвращает
+// событие и true, если по итогам окна изменился уровень
+func (c *degradationController) observeTick(now time.Time, interval time.Duration) (DegradationEvent, bool) {
+	if c.lastTick.IsZero() || interval <= 0 {
+		c.lastTick = now
+		c.windowStart = now
+		return DegradationEvent{}, false
+	}
+
+	// Тикер пропускает сроки, которые не успел выдать, поэтому опоздание
+	// видно по промежутку между тиками
+	gap := now.Sub(c.lastTick)
+	c.lastTick = now
+	deadlines := max(uint64((gap+interval/2)/interval), 1)
+	c.ticks += deadlines
+	if gap > interval+interval/2 {
+		c.misses += deadlines - 1
+	}
+
+	if now.Sub(c.windowStart) < c.config.Window {
+		return DegradationEvent{}, false
+	}
+
+	previous := DegradationLevel(c.level.Load())
+	level := previous
+	switch {
+	case float64(c.misses) >= c.config.MissThreshold*float64(c.ticks):
+		c.clean = 0
+		if level < c.config.MaxLevel {
+			level++
+		}
+	case c.misses == 0:
+		c.clean++
+		if c.clean >= c.config.RecoverWindows && level > DegradationNone {
+			c.clean = 0
+			level--
+		}
+	}
+
+	event := DegradationEvent{Level: level, Previous: previous, Ticks: c.ticks, Misses: c.misses}
+	c.windowStart = now
+	c.ticks = 0
+	c.misses = 0
+
+	if level == previous {
+		return DegradationEvent{}, false
+	}
+	c.level.Store(int32(level))
+	return event, true
+}
+
+// GetDegradationLevel возвращает текущий уровень деградации. Без
+// Config.Degradation всегда DegradationNone
+func (ms *MediaSession) GetDegradationLevel() DegradationLevel {
+	if ms.degradation == nil {
+		return DegradationNone
+	}
+	return DegradationLevel(ms.degradation.level.Load())
+}
+
+// observeSendDeadline учитывает срок отправки в контроле деградации.
+// Вызывается под sendMutex
+func (ms *MediaSession) observeSendDeadline(now time.Time) {
+	controller := ms.degradation
+	if controller == nil {
+		return
+	}
+
+	ms.bufferMutex.Lock()
+	interval := ms.packetDuration
+	ms.bufferMutex.Unlock()
+
+	event, changed := controller.observeTick(now, interval)
+	if !changed {
+		return
+	}
+	event.SessionID = ms.sessionID
+
+	if ms.audioProcessor != nil {
+		ms.audioProcessor.SetEnhancementBypassed(event.Level >= DegradationEnhancement)
+	}
+
+	slog.Warn("media: изменен уровень деградации обработки",
+		slog.String("session", ms.sessionID),
+		slog.String("level", event.Level.String()),
+		slog.String("previous", event.Previous.String()),
+		slog.Uint64("misses", event.Misses),
+		slog.Uint64("ticks", event.Ticks))
+
+	if handler := controller.config.OnDegradation; handler != nil {
+		go ms.invokeCallback("OnDegradation", "", func() {
+			handler(event)
+		})
+	}
+}
+
+// analyticsMeter возвращает включенную и не отключенную деградацией аналитику
+func (ms *MediaSession) analyticsMeter() *analyticsMeter {
+	if ms.GetDegradationLevel() >= DegradationAnalytics {
+		return nil
+	}
+	return ms.analytics.Load()
+}
+
+// transcriptionTap возвращает запущенную и не отключенную деградацией
+// передачу на распознавание
+func (ms *MediaSession) transcriptionTap() *TranscriptionTap {
+	if ms.GetDegradationLevel() >= DegradationTaps {
+		return nil
+	}
+	return ms.transcription.Load()
+}
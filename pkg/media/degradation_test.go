@@ -0,0 +1,116 @@
+package media
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDegradationController проверяет повышение уровня при пропусках сроков
+// отправки и восстановление после окон без пропусков
+func TestDegradationController(t *testing.T) {
+	controller := newDegradationController(DegradationConfig{
+		Window:         100 * time.Millisecond,
+		MissThreshold:  0.2,
+		RecoverWindows: 2,
+		MaxLevel:       DegradationTaps,
+	})
+	const ptime = 20 * time.Millisecond
+	now := time.Now()
+	controller.observeTick(now, ptime)
+
+	// tick продвигает время на gap и возвращает изменение уровня
+	tick := func(gap time.Duration) (DegradationEvent, bool) {
+		now = now.Add(gap)
+		return controller.observeTick(now, ptime)
+	}
+	window := func(gap time.Duration) (event DegradationEvent, changed bool) {
+		for elapsed := time.Duration(0); elapsed < 100*time.Millisecond; elapsed += gap {
+			if e, ok := tick(gap); ok {
+				event, changed = e, ok
+			}
+		}
+		return event, changed
+	}
+
+	// Тики вовремя и с небольшим дрожанием не являются пропусками
+	if _, changed := window(25 * time.Millisecond); changed {
+		t.Fatal("Уровень не должен меняться без пропусков")
+	}
+
+	// Тики через 60 мс: 2 пропущенных срока из 3
+	event, changed := window(60 * time.Millisecond)
+	if !changed || event.Level != DegradationAnalytics || event.Previous != DegradationNone || event.Misses == 0 {
+		t.Fatalf("Ожидалось повышение до analytics, получено %+v (%v)", event, changed)
+	}
+	if event, _ = window(60 * time.Millisecond); event.Level != DegradationTaps {
+		t.Fatalf("Ожидалось повышение до taps, получено %v", event.Level)
+	}
+	// MaxLevel не превышается
+	if _, changed := window(60 * time.Millisecond); changed {
+		t.Fatal("Уровень не должен превышать MaxLevel")
+	}
+
+	// Восстановление по одной ступени после RecoverWindows чистых окон
+	if _, changed := window(ptime); changed {
+		t.Fatal("Уровень не должен понижаться после одного чистого окна")
+	}
+	if event, changed := window(ptime); !changed || event.Level != DegradationAnalytics {
+		t.Fatalf("Ожидалось понижение до analytics, получено %+v", event)
+	}
+}
+
+// TestSessionDegradation проверяет отключение обработки сессии по уровням
+func TestSessionDegradation(t *testing.T) {
+	events := make(chan DegradationEvent, 4)
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-degradation"
+	config.Degradation = &DegradationConfig{
+		Window: 50 * time.Millisecond,
+		OnDegradation: func(event DegradationEvent) {
+			events <- event
+		},
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer func() { _ = session.Stop() }()
+
+	session.AnalyticsStream(time.Hour)
+	tap, err := session.StartTranscription(TranscriptionConsumerFunc(func(TranscriptionChunk) {}), TranscriptionConfig{})
+	if err != nil {
+		t.Fatalf("Ошибка запуска распознавания: %v", err)
+	}
+	defer tap.Stop()
+
+	if session.analyticsMeter() == nil || session.transcriptionTap() == nil {
+		t.Fatal("Без деградации обработка должна быть включена")
+	}
+
+	// Каждый тик опаздывает на 3 срока и завершает окно измерения
+	now := time.Now()
+	session.observeSendDeadline(now)
+	for level := DegradationAnalytics; level <= DegradationEnhancement; level++ {
+		now = now.Add(60 * time.Millisecond)
+		session.observeSendDeadline(now)
+		if got := session.GetDegradationLevel(); got != level {
+			t.Fatalf("Ожидался уровень %v, получено %v", level, got)
+		}
+		select {
+		case event := <-events:
+			if event.Level != level || event.SessionID != "test-degradation" {
+				t.Errorf("Неверное событие деградации: %+v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Не получено событие уровня %v", level)
+		}
+	}
+
+	if session.analyticsMeter() != nil || session.transcriptionTap() != nil {
+		t.Error("Аналитика и распознавание должны быть отключены")
+	}
+	if !session.audioProcessor.enhancementBypassed.Load() {
+		t.Error("Обработка аудио процессора должна быть отключена")
+	}
+}
@@ -70,6 +70,29 @@
 //   - G728 - PayloadType 15
 //   - G729 - PayloadType 18
 //
+// # Подключаемые кодеки
+//
+// Кодеки с динамическим payload type подключаются через реестр: RegisterCodec
+// регистрирует фабрику по имени из rtpmap, NewCodec создает кодек с
+// согласованными параметрами fmtp, Config.Codec передает его сессии.
+//
+// Для AMR и AMR-WB (RFC 4867) пакет сам упаковывает кадры в payload в
+// режимах octet-aligned и bandwidth-efficient и учитывает mode-set и CMR.
+// Внешний кодер речевых кадров регистрируется через RegisterAMRFrameCodec:
+//
+//	media.RegisterAMRFrameCodec(false, func() (media.AMRFrameCodec, error) {
+//	    return opencore.NewAMRNB() // ваша привязка к кодеру
+//	})
+//
+// Для iLBC (RFC 3952) пакет делит payload на кадры режима mode=20 или
+// mode=30 (внешний кодер - RegisterILBCFrameCodec), для Speex (RFC 5574)
+// проверяет частоту и параметры fmtp и передает их внешнему кодеку
+// (RegisterSpeexCodec).
+//
+// L16 (RFC 3551) зарегистрирован по умолчанию: статические payload types 10
+// и 11 для 44100 Гц и динамические для остальных частот. Payload больше
+// Config.MaxPayloadSize делится на несколько RTP пакетов по границе отсчета.
+//
 // # DTMF
 //
 // DTMF поддержка реализована согласно RFC 4733 (telephone-event):
@@ -82,6 +105,11 @@
 //	    fmt.Printf("Получена DTMF цифра: %s\n", event.Digit)
 //	}
 //
+// Для сторон без RFC 4733 цифры передаются тонами в аудио (SendInbandDTMF,
+// InbandDTMFDetector, только G.711). DTMFInterworking пересылает цифры между
+// плечами B2BUA со своими способами передачи (RFC 4733, SIP INFO, in-band)
+// и подавляет дубликаты одной цифры, пришедшей разными способами.
+//
 // # Jitter Buffer
 //
 // Адаптивный jitter buffer автоматически компенсирует вариации сетевых задержек:
@@ -138,6 +166,20 @@
 // из разных горутин одновременно. Внутренняя синхронизация обеспечивает
 // защиту от race conditions при работе с callback'ами и внутренним состоянием.
 //
+// # Общий планировщик
+//
+// По умолчанию каждая сессия запускает собственные горутины: отправка аудио,
+// вывод jitter buffer, аудио процессор и RTCP. При тысячах одновременных
+// вызовов периодическую работу всех сессий выгоднее выполнять одним
+// планировщиком (timing wheel) с фиксированным пулом исполнителей:
+//
+//	scheduler := media.NewScheduler(media.DefaultSchedulerConfig())
+//	defer scheduler.Stop()
+//
+//	config.Scheduler = scheduler
+//
+// Собственной горутиной сессии остается только блокирующий прием из jitter buffer.
+//
 // # Интеграция с RTP транспортом
 //
 // Пакет использует интерфейс SessionRTP для абстракции RTP транспорта:
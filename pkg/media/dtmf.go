@@ -2,6 +2,8 @@ package media
 
 import (
 	"fmt"
+	"math"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/rtp"
@@ -85,18 +87,32 @@ type DTMFPayload struct {
 	Duration uint16 // Duration in timestamp units
 }
 
+// DTMFClockRate - частота RTP clock telephone-event по умолчанию (RFC 4733)
+const DTMFClockRate = 8000
+
 // DTMFSender отправляет DTMF события
 type DTMFSender struct {
 	payloadType uint8
 	ssrc        uint32
 	seqNum      uint16
+	clockRate   atomic.Uint32 // Частота RTP clock из rtpmap telephone-event
 }
 
 // NewDTMFSender создает новый DTMF sender
 func NewDTMFSender(payloadType uint8) *DTMFSender {
-	return &DTMFSender{
+	ds := &DTMFSender{
 		payloadType: payloadType,
 	}
+	ds.clockRate.Store(DTMFClockRate)
+	return ds
+}
+
+// SetClockRate устанавливает частоту RTP clock для пересчета длительности
+// события (например, telephone-event/16000). Нулевое значение игнорируется
+func (ds *DTMFSender) SetClockRate(rate uint32) {
+	if rate > 0 {
+		ds.clockRate.Store(rate)
+	}
 }
 
 // SetSSRC устанавливает SSRC для DTMF пакетов
@@ -110,8 +126,10 @@ func (ds *DTMFSender) GeneratePackets(event DTMFEvent) ([]*rtp.Packet, error) {
 		return nil, fmt.Errorf("длительность DTMF должна быть положительной")
 	}
 
-	// Конвертируем duration в RTP timestamp units (8000 Hz)
-	durationInSamples := uint16(event.Duration.Seconds() * 8000)
+	// Конвертируем duration в RTP timestamp units. Поле длительности 16-битное,
+	// при высокой частоте clock длинные события ограничиваются максимумом
+	samples := event.Duration.Seconds() * float64(ds.clockRate.Load())
+	durationInSamples := uint16(math.Min(samples, math.MaxUint16))
 
 	// Конвертируем volume (от -dBm к 0-63)
 	volume := uint8(0)
@@ -211,13 +229,24 @@ type DTMFReceiver struct {
 	onDTMFReceived func(DTMFEvent)
 	lastEvent      *DTMFEvent
 	eventActive    bool
+	clockRate      atomic.Uint32 // Частота RTP clock из rtpmap telephone-event
 }
 
 // NewDTMFReceiver создает новый DTMF receiver
 func NewDTMFReceiver(payloadType uint8) *DTMFReceiver {
-	return &DTMFReceiver{
+	dr := &DTMFReceiver{
 		payloadType: payloadType,
 	}
+	dr.clockRate.Store(DTMFClockRate)
+	return dr
+}
+
+// SetClockRate устанавливает частоту RTP clock для пересчета длительности
+// события. Нулевое значение игнорируется
+func (dr *DTMFReceiver) SetClockRate(rate uint32) {
+	if rate > 0 {
+		dr.clockRate.Store(rate)
+	}
 }
 
 // SetCallback устанавливает callback для обработки DTMF событий по одной руне
@@ -244,10 +273,13 @@ func (dr *DTMFReceiver) ProcessPacket(packet *rtp.Packet) (bool, error) {
 	}
 
 	// Создаем DTMF событие
+	// Конвертируем длительность из единиц RTP timestamp
+	duration := time.Duration(payload.Duration) * time.Second / time.Duration(dr.clockRate.Load())
+
 	event := DTMFEvent{
 		Digit:     DTMFDigit(payload.Event),
-		Duration:  time.Duration(payload.Duration) * time.Second / 8000, // Конвертируем из RTP timestamp
-		Volume:    -int8(payload.Volume),                                // Конвертируем обратно в -dBm
+		Duration:  duration,
+		Volume:    -int8(payload.Volume), // Конвертируем обратно в -dBm
 		Timestamp: packet.Timestamp,
 	}
 
@@ -0,0 +1,270 @@
+package media
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+)
+
+// Частоты DTMF (ITU-T Q.23): строки - низкая группа, столбцы - высокая
+var (
+	dtmfLowFrequencies  = [4]float64{697, 770, 852, 941}
+	dtmfHighFrequencies = [4]float64{1209, 1336, 1477, 1633}
+	dtmfMatrix          = [4][4]DTMFDigit{
+		{DTMF1, DTMF2, DTMF3, DTMFA},
+		{DTMF4, DTMF5, DTMF6, DTMFB},
+		{DTMF7, DTMF8, DTMF9, DTMFC},
+		{DTMFStar, DTMF0, DTMFPound, DTMFD},
+	}
+)
+
+// Параметры детектора in-band DTMF
+const (
+	inbandBlock8k       = 205 // Отсчетов в блоке при 8 кГц (~25.6 мс)
+	inbandMinRMS        = 300 // Минимальный уровень сигнала (~-40 dBFS)
+	inbandToneShare     = 0.6 // Минимальная доля энергии блока в двух тонах
+	inbandGroupRatio    = 4.0 // Превышение тона над остальными в группе (6 дБ)
+	inbandMaxTwist      = 6.3 // Допустимый перекос уровней тонов (8 дБ)
+	inbandConfirmBlocks = 2   // Блоков для подтверждения начала и конца цифры
+	inbandToneAmplitude = 0.4 // Амплитуда генерируемых тонов (доля полной шкалы)
+)
+
+// InbandDTMFDetector обнаруживает DTMF тоны в декодированном аудио
+// алгоритмом Герцеля. Цифра сообщается в callback по окончании тона вместе
+// с длительностью, как DTMFReceiver сообщает конец события RFC 4733.
+//
+// Начало и конец цифры подтверждаются двумя блоками подряд (~50 мс), что
+// отсекает короткие совпадения в речи. Безопасен для использования из
+// нескольких горутин.
+type InbandDTMFDetector struct {
+	mu       sync.Mutex
+	block    int
+	coeffs   [8]float64
+	blockDur time.Duration
+	buffer   []int16
+	callback func(DTMFEvent)
+
+	current   DTMFDigit // Подтвержденная цифра
+	active    bool
+	candidate DTMFDigit // Цифра, ожидающая подтверждения
+	hits      int
+	misses    int
+	blocks    int // Блоков с подтвержденной цифрой
+}
+
+// NewInbandDTMFDetector создает детектор для аудио с частотой sampleRate
+// (0 - 8000 Гц). callback вызывается для каждой обнаруженной цифры.
+func NewInbandDTMFDetector(sampleRate int, callback func(DTMFEvent)) *InbandDTMFDetector {
+	if sampleRate <= 0 {
+		sampleRate = 8000
+	}
+	d := &InbandDTMFDetector{
+		block:    inbandBlock8k * sampleRate / 8000,
+		callback: callback,
+	}
+	d.blockDur = time.Duration(d.block) * time.Second / time.Duration(sampleRate)
+	for i, freq := range append(dtmfLowFrequencies[:], dtmfHighFrequencies[:]...) {
+		k := math.Round(float64(d.block) * freq / float64(sampleRate))
+		d.coeffs[i] = 2 * math.Cos(2*math.Pi*k/float64(d.block))
+	}
+	return d
+}
+
+// Process обрабатывает очередную порцию линейных 16-битных отсчетов.
+// Порции могут быть произвольной длины.
+func (d *InbandDTMFDetector) Process(samples []int16) {
+	var events []DTMFEvent
+
+	d.mu.Lock()
+	d.buffer = append(d.buffer, samples...)
+	for len(d.buffer) >= d.block {
+		digit, ok := d.detectBlock(d.buffer[:d.block])
+		if event, ended := d.advance(digit, ok); ended {
+			events = append(events, event)
+		}
+		d.buffer = d.buffer[d.block:]
+	}
+	// Сдвигаем остаток в начало, чтобы буфер не рос
+	d.buffer = append(d.buffer[:0:0], d.buffer...)
+	callback := d.callback
+	d.mu.Unlock()
+
+	if callback != nil {
+		for _, event := range events {
+			callback(event)
+		}
+	}
+}
+
+// Reset сбрасывает состояние детектора, например при смене кодека
+func (d *InbandDTMFDetector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.buffer = d.buffer[:0]
+	d.active, d.hits, d.misses, d.blocks = false, 0, 0, 0
+}
+
+// advance обновляет состояние по результату блока и возвращает событие,
+// если подтвержден конец цифры
+func (d *InbandDTMFDetector) advance(digit DTMFDigit, ok bool) (DTMFEvent, bool) {
+	if d.active {
+		if ok && digit == d.current {
+			d.blocks++
+			d.misses = 0
+			return DTMFEvent{}, false
+		}
+		// Другая цифра сразу после текущей начинает подтверждение новой
+		d.misses++
+		if ok && d.hits > 0 && digit == d.candidate {
+			d.hits++
+		} else if ok {
+			d.candidate, d.hits = digit, 1
+		} else {
+			d.hits = 0
+		}
+		if d.misses < inbandConfirmBlocks {
+			return DTMFEvent{}, false
+		}
+		event := DTMFEvent{Digit: d.current, Duration: time.Duration(d.blocks) * d.blockDur}
+		d.active, d.misses = false, 0
+		if d.hits >= inbandConfirmBlocks {
+			d.current, d.active, d.blocks = d.candidate, true, d.hits
+		}
+		return event, true
+	}
+
+	if !ok {
+		d.hits = 0
+		return DTMFEvent{}, false
+	}
+	if d.hits > 0 && digit == d.candidate {
+		d.hits++
+	} else {
+		d.candidate, d.hits = digit, 1
+	}
+	if d.hits >= inbandConfirmBlocks {
+		d.current, d.active, d.blocks, d.misses = digit, true, d.hits, 0
+	}
+	return DTMFEvent{}, false
+}
+
+// detectBlock ищет пару DTMF тонов в блоке отсчетов
+func (d *InbandDTMFDetector) detectBlock(samples []int16) (DTMFDigit, bool) {
+	var energy float64
+	for _, s := range samples {
+		energy += float64(s) * float64(s)
+	}
+	n := float64(len(samples))
+	if energy/n < inbandMinRMS*inbandMinRMS {
+		return 0, false
+	}
+
+	var powers [8]float64
+	for i, coeff := range d.coeffs {
+		var s1, s2 float64
+		for _, s := range samples {
+			s0 := float64(s) + coeff*s1 - s2
+			s2, s1 = s1, s0
+		}
+		powers[i] = s1*s1 + s2*s2 - coeff*s1*s2
+	}
+
+	low, lowPower := strongest(powers[:4])
+	high, highPower := strongest(powers[4:])
+
+	// Каждый тон заметно сильнее остальных в своей группе
+	for i := 0; i < 4; i++ {
+		if (i != low && powers[i]*inbandGroupRatio > lowPower) ||
+			(i != high && powers[4+i]*inbandGroupRatio > highPower) {
+			return 0, false
+		}
+	}
+	// Уровни тонов сопоставимы
+	if highPower > lowPower*inbandMaxTwist || lowPower > highPower*inbandMaxTwist {
+		return 0, false
+	}
+	// Тоны содержат основную часть энергии блока (для чистого тона
+	// мощность Герцеля равна энергии * N / 2)
+	if (lowPower+highPower)*2/n < energy*inbandToneShare {
+		return 0, false
+	}
+
+	return dtmfMatrix[low][high], true
+}
+
+// strongest возвращает индекс и мощность наибольшего значения
+func strongest(powers []float64) (int, float64) {
+	index := 0
+	for i, p := range powers {
+		if p > powers[index] {
+			index = i
+		}
+	}
+	return index, powers[index]
+}
+
+// dtmfRune возвращает символ цифры для генерации тона
+func dtmfRune(digit DTMFDigit) (rune, error) {
+	if !IsValidDTMFDigit(uint8(digit)) {
+		return 0, fmt.Errorf("неверная DTMF цифра: %d", digit)
+	}
+	return rune(digit.String()[0]), nil
+}
+
+// GenerateInbandDTMF возвращает кадры G.711 (PCMU или PCMA) длительностью
+// ptime с тоном цифры digit общей длительностью duration.
+func GenerateInbandDTMF(digit DTMFDigit, duration, ptime time.Duration, payloadType PayloadType) ([][]byte, error) {
+	if payloadType != PayloadTypePCMU && payloadType != PayloadTypePCMA {
+		return nil, fmt.Errorf("in-band DTMF поддерживается только для G.711, payload type %d", payloadType)
+	}
+	if ptime <= 0 {
+		return nil, fmt.Errorf("неверный ptime: %v", ptime)
+	}
+
+	symbol, err := dtmfRune(digit)
+	if err != nil {
+		return nil, err
+	}
+	signal, err := testsignal.DTMF(symbol, inbandToneAmplitude)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := int((duration + ptime - 1) / ptime)
+	generator := testsignal.NewGenerator(signal, testsignal.Codec(payloadType), ptime)
+	return generator.Frames(frames), nil
+}
+
+// SendInbandDTMF передает цифру тоном в аудио потоке (для сторон без
+// поддержки RFC 4733). Кадры отправляются с интервалом ptime, поэтому вызов
+// блокирует на длительность цифры; аудио приложения в это время должно быть
+// приостановлено. Поддерживаются только кодеки G.711.
+func (ms *MediaSession) SendInbandDTMF(digit DTMFDigit, duration time.Duration) error {
+	frames, err := GenerateInbandDTMF(digit, duration, ms.GetPtime(), ms.GetPayloadType())
+	if err != nil {
+		return NewDTMFError(ErrorCodeDTMFSendFailed, ms.sessionID, err.Error(), digit, duration)
+	}
+
+	ticker := time.NewTicker(ms.GetPtime())
+	defer ticker.Stop()
+	for i, frame := range frames {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-ms.ctx.Done():
+				return &MediaError{
+					Code:      ErrorCodeSessionClosed,
+					Message:   "медиа сессия остановлена",
+					SessionID: ms.sessionID,
+				}
+			}
+		}
+		if err := ms.SendAudioRaw(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package media
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+)
+
+// DTMFMechanism - способ передачи DTMF на плече вызова
+type DTMFMechanism int
+
+const (
+	DTMFMechanismRFC4733 DTMFMechanism = iota // telephone-event в RTP (RFC 4733)
+	DTMFMechanismSIPInfo                      // SIP INFO с application/dtmf-relay
+	DTMFMechanismInband                       // Тоны в аудио потоке (только G.711)
+)
+
+func (m DTMFMechanism) String() string {
+	switch m {
+	case DTMFMechanismRFC4733:
+		return "rfc4733"
+	case DTMFMechanismSIPInfo:
+		return "sip-info"
+	case DTMFMechanismInband:
+		return "inband"
+	default:
+		return "unknown"
+	}
+}
+
+// DTMFLeg обозначает плечо вызова в DTMFInterworking
+type DTMFLeg int
+
+const (
+	DTMFLegA DTMFLeg = iota
+	DTMFLegB
+)
+
+func (l DTMFLeg) String() string {
+	if l == DTMFLegA {
+		return "A"
+	}
+	return "B"
+}
+
+// other возвращает противоположное плечо
+func (l DTMFLeg) other() DTMFLeg {
+	return 1 - l
+}
+
+// DefaultDTMFDuplicateWindow - окно подавления дубликатов по умолчанию
+const DefaultDTMFDuplicateWindow = 300 * time.Millisecond
+
+// DTMFLegConfig описывает способ передачи DTMF, согласованный на плече
+type DTMFLegConfig struct {
+	// Mechanism - способ отправки цифр в это плечо
+	Mechanism DTMFMechanism
+
+	// Session - медиа сессия плеча. Обязательна для RFC 4733 и in-band отправки
+	Session *MediaSession
+
+	// SendInfo отправляет цифру запросом INFO, например через
+	// dialog.DoRequest(ctx, sip.INFO, dialog.WithDTMFInfo(...)).
+	// Обязателен для DTMFMechanismSIPInfo
+	SendInfo func(event DTMFEvent) error
+}
+
+// DTMFInterworkingConfig содержит параметры DTMFInterworking
+type DTMFInterworkingConfig struct {
+	LegA DTMFLegConfig
+	LegB DTMFLegConfig
+
+	// DuplicateWindow - интервал, в течение которого та же цифра с того же
+	// плеча, полученная другим способом, считается дубликатом (например,
+	// RFC 4733 и просочившийся в аудио тон). 0 - DefaultDTMFDuplicateWindow
+	DuplicateWindow time.Duration
+
+	// OnDigit вызывается для каждой пересланной цифры (опционально)
+	OnDigit func(from DTMFLeg, mechanism DTMFMechanism, event DTMFEvent)
+}
+
+// DTMFInterworkingStatistics содержит счетчики DTMFInterworking
+type DTMFInterworkingStatistics struct {
+	Forwarded  uint64 // Цифры, переданные в другое плечо
+	Suppressed uint64 // Отброшенные дубликаты
+	Errors     uint64 // Ошибки отправки
+}
+
+// dtmfSeen - последняя принятая с плеча цифра для подавления дубликатов
+type dtmfSeen struct {
+	digit     DTMFDigit
+	mechanism DTMFMechanism
+	at        time.Time
+	valid     bool
+}
+
+// dtmfForward - цифра в очереди отправки плеча
+type dtmfForward struct {
+	event     DTMFEvent
+	from      DTMFLeg
+	mechanism DTMFMechanism
+}
+
+// DTMFInterworking пересылает DTMF между плечами вызова (B2BUA, шлюз),
+// на которых согласованы разные способы передачи: цифра, принятая на одном
+// плече любым способом, отправляется в другое плечо его способом.
+//
+// Приложение передает принятые цифры через Receive (RFC 4733 из
+// OnDTMFReceived, SIP INFO из обработчика запросов диалога) и аудио через
+// ProcessAudio для обнаружения in-band тонов. Отправка в каждое плечо
+// выполняется в отдельной горутине по порядку приема, поэтому Receive не
+// блокирует путь приема медиа.
+//
+// Пример использования:
+//
+//	var iw *media.DTMFInterworking
+//	configA.OnDTMFReceived = func(event media.DTMFEvent, _ string) {
+//	    iw.Receive(media.DTMFLegA, media.DTMFMechanismRFC4733, event)
+//	}
+//	sessionA, _ := media.NewSession(configA)
+//	iw, _ = media.NewDTMFInterworking(media.DTMFInterworkingConfig{
+//	    LegA: media.DTMFLegConfig{Mechanism: media.DTMFMechanismRFC4733, Session: sessionA},
+//	    LegB: media.DTMFLegConfig{Mechanism: media.DTMFMechanismSIPInfo, SendInfo: sendInfoB},
+//	})
+//	defer iw.Close()
+type DTMFInterworking struct {
+	legs     [2]DTMFLegConfig
+	window   time.Duration
+	onDigit  func(DTMFLeg, DTMFMechanism, DTMFEvent)
+	queues   [2]chan dtmfForward
+	detector [2]*InbandDTMFDetector
+
+	mu     sync.Mutex
+	seen   [2]dtmfSeen
+	closed bool
+
+	forwarded  atomic.Uint64
+	suppressed atomic.Uint64
+	errors     atomic.Uint64
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewDTMFInterworking создает модуль пересылки DTMF и запускает отправку
+func NewDTMFInterworking(config DTMFInterworkingConfig) (*DTMFInterworking, error) {
+	iw := &DTMFInterworking{
+		legs:    [2]DTMFLegConfig{config.LegA, config.LegB},
+		window:  config.DuplicateWindow,
+		onDigit: config.OnDigit,
+	}
+	if iw.window <= 0 {
+		iw.window = DefaultDTMFDuplicateWindow
+	}
+
+	for i, leg := range iw.legs {
+		switch leg.Mechanism {
+		case DTMFMechanismRFC4733, DTMFMechanismInband:
+			if leg.Session == nil {
+				return nil, fmt.Errorf("плечо %s: для %s требуется медиа сессия", DTMFLeg(i), leg.Mechanism)
+			}
+		case DTMFMechanismSIPInfo:
+			if leg.SendInfo == nil {
+				return nil, fmt.Errorf("плечо %s: для %s требуется SendInfo", DTMFLeg(i), leg.Mechanism)
+			}
+		default:
+			return nil, fmt.Errorf("плечо %s: неизвестный способ передачи DTMF %d", DTMFLeg(i), leg.Mechanism)
+		}
+	}
+
+	for i := range iw.legs {
+		from := DTMFLeg(i)
+		iw.detector[i] = NewInbandDTMFDetector(0, func(event DTMFEvent) {
+			iw.Receive(from, DTMFMechanismInband, event)
+		})
+		iw.queues[i] = make(chan dtmfForward, 32)
+		iw.wg.Add(1)
+		go iw.sendLoop(DTMFLeg(i))
+	}
+	return iw, nil
+}
+
+// Receive передает цифру, принятую на плече from способом mechanism, в
+// другое плечо. Возвращает false, если цифра отброшена: дубликат,
+// переполнение очереди или вызов после Close.
+func (iw *DTMFInterworking) Receive(from DTMFLeg, mechanism DTMFMechanism, event DTMFEvent) bool {
+	now := time.Now()
+	if event.Duration <= 0 {
+		event.Duration = 100 * time.Millisecond
+	}
+
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	if iw.closed {
+		return false
+	}
+
+	last := iw.seen[from]
+	if last.valid && last.digit == event.Digit && last.mechanism != mechanism && now.Sub(last.at) < iw.window {
+		iw.suppressed.Add(1)
+		return false
+	}
+	iw.seen[from] = dtmfSeen{digit: event.Digit, mechanism: mechanism, at: now, valid: true}
+
+	select {
+	case iw.queues[from.other()] <- dtmfForward{event: event, from: from, mechanism: mechanism}:
+	default:
+		iw.errors.Add(1)
+		slog.Warn("DTMF interworking: очередь отправки переполнена",
+			slog.String("leg", from.other().String()),
+			slog.String("digit", event.Digit.String()))
+		return false
+	}
+	return true
+}
+
+// ProcessAudio ищет in-band тоны в аудио, принятом на плече from (данные
+// OnAudioReceived в формате кодека сессии плеча). Поддерживаются G.711.
+func (iw *DTMFInterworking) ProcessAudio(from DTMFLeg, audio []byte, payloadType PayloadType) {
+	if payloadType != PayloadTypePCMU && payloadType != PayloadTypePCMA {
+		return
+	}
+	iw.detector[from].Process(testsignal.Codec(payloadType).DecodePCM(audio))
+}
+
+// sendLoop отправляет цифры в плечо leg его способом передачи
+func (iw *DTMFInterworking) sendLoop(leg DTMFLeg) {
+	defer iw.wg.Done()
+
+	config := iw.legs[leg]
+	for forward := range iw.queues[leg] {
+		var err error
+		switch config.Mechanism {
+		case DTMFMechanismRFC4733:
+			err = config.Session.SendDTMF(forward.event.Digit, forward.event.Duration)
+		case DTMFMechanismSIPInfo:
+			err = config.SendInfo(forward.event)
+		case DTMFMechanismInband:
+			err = config.Session.SendInbandDTMF(forward.event.Digit, forward.event.Duration)
+		}
+		if err != nil {
+			iw.errors.Add(1)
+			slog.Warn("DTMF interworking: ошибка отправки",
+				slog.String("leg", leg.String()),
+				slog.String("mechanism", config.Mechanism.String()),
+				slog.String("digit", forward.event.Digit.String()),
+				slog.String("error", err.Error()))
+			continue
+		}
+		iw.forwarded.Add(1)
+		if iw.onDigit != nil {
+			iw.onDigit(forward.from, forward.mechanism, forward.event)
+		}
+	}
+}
+
+// GetStatistics возвращает счетчики пересылки
+func (iw *DTMFInterworking) GetStatistics() DTMFInterworkingStatistics {
+	return DTMFInterworkingStatistics{
+		Forwarded:  iw.forwarded.Load(),
+		Suppressed: iw.suppressed.Load(),
+		Errors:     iw.errors.Load(),
+	}
+}
+
+// Close завершает отправку после передачи цифр, уже поставленных в очередь.
+// Цифры, принятые после Close, отбрасываются.
+func (iw *DTMFInterworking) Close() {
+	iw.closeOnce.Do(func() {
+		iw.mu.Lock()
+		iw.closed = true
+		iw.mu.Unlock()
+		for _, queue := range iw.queues {
+			close(queue)
+		}
+		iw.wg.Wait()
+	})
+}
@@ -0,0 +1,130 @@
+package media
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+)
+
+// TestInbandDTMFDetector проверяет обнаружение всех цифр в G.711 и
+// отсутствие ложных срабатываний на речи и одиночном тоне
+func TestInbandDTMFDetector(t *testing.T) {
+	for _, payloadType := range []PayloadType{PayloadTypePCMU, PayloadTypePCMA} {
+		var events []DTMFEvent
+		detector := NewInbandDTMFDetector(8000, func(event DTMFEvent) {
+			events = append(events, event)
+		})
+		silence := testsignal.PCM(testsignal.Silence(), 0, 400)
+
+		for digit := DTMF0; digit <= DTMFD; digit++ {
+			frames, err := GenerateInbandDTMF(digit, 100*time.Millisecond, 20*time.Millisecond, payloadType)
+			if err != nil {
+				t.Fatalf("Ошибка генерации %s: %v", digit, err)
+			}
+			if len(frames) != 5 {
+				t.Fatalf("Ожидалось 5 кадров, получено %d", len(frames))
+			}
+			for _, frame := range frames {
+				detector.Process(testsignal.Codec(payloadType).DecodePCM(frame))
+			}
+			detector.Process(silence)
+		}
+		detector.Process(silence)
+
+		if len(events) != 16 {
+			t.Fatalf("%d: ожидалось 16 цифр, обнаружено %d", payloadType, len(events))
+		}
+		for i, event := range events {
+			if event.Digit != DTMFDigit(i) {
+				t.Errorf("Цифра %d распознана как %s", i, event.Digit)
+			}
+			if event.Duration < 50*time.Millisecond || event.Duration > 130*time.Millisecond {
+				t.Errorf("Неверная длительность %s: %v", event.Digit, event.Duration)
+			}
+		}
+	}
+
+	var falsePositives int
+	detector := NewInbandDTMFDetector(0, func(DTMFEvent) { falsePositives++ })
+	detector.Process(testsignal.PCM(testsignal.Speech(0.5, 1), 0, 8000*3))
+	detector.Process(testsignal.PCM(testsignal.Sine(1000, 0.5), 0, 8000))
+	if falsePositives != 0 {
+		t.Errorf("Ложные срабатывания детектора: %d", falsePositives)
+	}
+
+	if _, err := GenerateInbandDTMF(DTMF1, time.Second, 20*time.Millisecond, PayloadTypeG722); err == nil {
+		t.Error("Ожидалась ошибка для кодека не G.711")
+	}
+}
+
+// TestDTMFInterworking проверяет пересылку цифр между плечами и подавление
+// дубликатов, полученных разными способами
+func TestDTMFInterworking(t *testing.T) {
+	var mu sync.Mutex
+	sent := map[DTMFLeg][]DTMFEvent{}
+	sender := func(leg DTMFLeg) func(DTMFEvent) error {
+		return func(event DTMFEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent[leg] = append(sent[leg], event)
+			return nil
+		}
+	}
+
+	if _, err := NewDTMFInterworking(DTMFInterworkingConfig{
+		LegA: DTMFLegConfig{Mechanism: DTMFMechanismRFC4733},
+		LegB: DTMFLegConfig{Mechanism: DTMFMechanismSIPInfo, SendInfo: sender(DTMFLegB)},
+	}); err == nil {
+		t.Error("Ожидалась ошибка для RFC 4733 без медиа сессии")
+	}
+
+	iw, err := NewDTMFInterworking(DTMFInterworkingConfig{
+		LegA:            DTMFLegConfig{Mechanism: DTMFMechanismSIPInfo, SendInfo: sender(DTMFLegA)},
+		LegB:            DTMFLegConfig{Mechanism: DTMFMechanismSIPInfo, SendInfo: sender(DTMFLegB)},
+		DuplicateWindow: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания: %v", err)
+	}
+
+	// RFC 4733 с плеча A и тот же тон в аудио - одна цифра
+	if !iw.Receive(DTMFLegA, DTMFMechanismRFC4733, DTMFEvent{Digit: DTMF5, Duration: 100 * time.Millisecond}) {
+		t.Error("Первая цифра должна быть переслана")
+	}
+	frames, _ := GenerateInbandDTMF(DTMF5, 100*time.Millisecond, 20*time.Millisecond, PayloadTypePCMU)
+	for _, frame := range frames {
+		iw.ProcessAudio(DTMFLegA, frame, PayloadTypePCMU)
+	}
+	iw.ProcessAudio(DTMFLegA, make([]byte, 480), PayloadTypePCMU)
+
+	// Повтор той же цифры тем же способом - новое нажатие
+	iw.Receive(DTMFLegA, DTMFMechanismRFC4733, DTMFEvent{Digit: DTMF5})
+	// Тон на плече B пересылается в A
+	frames, _ = GenerateInbandDTMF(DTMFPound, 100*time.Millisecond, 20*time.Millisecond, PayloadTypePCMA)
+	for _, frame := range frames {
+		iw.ProcessAudio(DTMFLegB, frame, PayloadTypePCMA)
+	}
+	iw.ProcessAudio(DTMFLegB, testsignal.Encode(testsignal.PCMA, testsignal.Silence(), 480), PayloadTypePCMA)
+
+	iw.Close()
+	if iw.Receive(DTMFLegA, DTMFMechanismSIPInfo, DTMFEvent{Digit: DTMF1}) {
+		t.Error("После Close цифры не принимаются")
+	}
+
+	if len(sent[DTMFLegB]) != 2 || sent[DTMFLegB][0].Digit != DTMF5 || sent[DTMFLegB][1].Digit != DTMF5 {
+		t.Errorf("В плечо B ожидались две цифры 5: %+v", sent[DTMFLegB])
+	}
+	if sent[DTMFLegB][1].Duration != 100*time.Millisecond {
+		t.Errorf("Длительность по умолчанию не подставлена: %v", sent[DTMFLegB][1].Duration)
+	}
+	if len(sent[DTMFLegA]) != 1 || sent[DTMFLegA][0].Digit != DTMFPound {
+		t.Errorf("В плечо A ожидалась цифра #: %+v", sent[DTMFLegA])
+	}
+
+	stats := iw.GetStatistics()
+	if stats.Forwarded != 3 || stats.Suppressed != 1 || stats.Errors != 0 {
+		t.Errorf("Неверная статистика: %+v", stats)
+	}
+}
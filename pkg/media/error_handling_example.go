@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
 )
 
 // ExampleErrorHandling демонстрирует использование новых типов ошибок
@@ -154,11 +156,7 @@ func ExampleErrorContext() error {
 
 // generateTestAudio создает тестовые аудио данные заданного размера
 func generateTestAudio(size int) []byte {
-	data := make([]byte, size)
-	for i := range data {
-		data[i] = byte(i % 256)
-	}
-	return data
+	return testsignal.Encode(testsignal.PCMU, testsignal.Sine(1000, 0.5), size)
 }
 
 // ExampleAllMediaErrors демонстрирует все примеры ошибок
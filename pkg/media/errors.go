@@ -47,6 +47,12 @@ const (
 	ErrorCodeJitterBufferFull
 	ErrorCodeJitterBufferStopped
 	ErrorCodeJitterBufferConfigInvalid
+
+	// Ошибки пользовательских callback
+	ErrorCodeCallbackPanic
+
+	// Ошибки лимитов ресурсов tenant
+	ErrorCodeResourceLimitExceeded
 )
 
 // String возвращает строковое представление кода ошибки
@@ -104,6 +110,10 @@ func (code MediaErrorCode) String() string {
 		return "JitterBufferStopped"
 	case ErrorCodeJitterBufferConfigInvalid:
 		return "JitterBufferConfigInvalid"
+	case ErrorCodeCallbackPanic:
+		return "CallbackPanic"
+	case ErrorCodeResourceLimitExceeded:
+		return "ResourceLimitExceeded"
 	default:
 		return fmt.Sprintf("Unknown(%d)", int(code))
 	}
@@ -265,6 +275,33 @@ func NewJitterBufferError(code MediaErrorCode, sessionID, message string, buffer
 	}
 }
 
+// CallbackPanicError - паника в пользовательском callback, перехваченная
+// медиа сессией. Сессия продолжает работу, ошибка передается в OnMediaError
+type CallbackPanicError struct {
+	*MediaError
+	Callback string      // Имя callback, например "OnAudioReceived"
+	Value    interface{} // Значение, переданное в panic
+	Stack    string      // Стек горутины в момент паники
+}
+
+func NewCallbackPanicError(sessionID, callback string, value interface{}, stack []byte) *CallbackPanicError {
+	return &CallbackPanicError{
+		MediaError: &MediaError{
+			Code:      ErrorCodeCallbackPanic,
+			Message:   fmt.Sprintf("паника в %s: %v", callback, value),
+			SessionID: sessionID,
+			Context: map[string]interface{}{
+				"callback": callback,
+				"panic":    value,
+				"stack":    string(stack),
+			},
+		},
+		Callback: callback,
+		Value:    value,
+		Stack:    string(stack),
+	}
+}
+
 // WrapMediaError оборачивает существующую ошибку в MediaError
 func WrapMediaError(code MediaErrorCode, sessionID, message string, err error) *MediaError {
 	return &MediaError{
@@ -313,6 +350,10 @@ func AsMediaError(err error, target **MediaError) bool {
 		*target = jbErr.MediaError
 		return true
 	}
+	if cbErr, ok := err.(*CallbackPanicError); ok {
+		*target = cbErr.MediaError
+		return true
+	}
 
 	return false
 }
@@ -337,6 +378,10 @@ func GetErrorSuggestion(err error) string {
 		return "Увеличьте размер Jitter Buffer или проверьте скорость обработки пакетов"
 	case ErrorCodeRTCPNotEnabled:
 		return "Включите RTCP поддержку в конфигурации сессии"
+	case ErrorCodeCallbackPanic:
+		return "Исправьте ошибку в callback приложения, стек паники доступен в контексте ошибки по ключу \"stack\""
+	case ErrorCodeResourceLimitExceeded:
+		return "Дождитесь освобождения ресурсов tenant или увеличьте его лимиты в TenantResources"
 	default:
 		return "Проверьте документацию API для данного типа ошибки"
 	}
@@ -354,6 +399,7 @@ func IsRecoverableError(err error) bool {
 		ErrorCodeJitterBufferFull,
 		ErrorCodeRTPSendFailed,
 		ErrorCodeRTCPSendFailed,
+		ErrorCodeResourceLimitExceeded,
 	}
 
 	for _, code := range recoverableCodes {
@@ -3,12 +3,12 @@ package media
 import (
 	"fmt"
 	"log"
-	"math"
 	"math/rand"
 	"net"
 	"strings"
 	"time"
 
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
 	"github.com/pion/rtp"
 )
 
@@ -755,12 +755,7 @@ func (m *MockRTPSession) RegisterIncomingHandler(handler func(*rtp.Packet, net.A
 
 // generateTestAudioSoftphone генерирует тестовые аудио данные
 func generateTestAudioSoftphone(samples int) []byte {
-	data := make([]byte, samples)
-	for i := range data {
-		// Генерируем простую синусоиду
-		data[i] = byte(128 + 64*math.Sin(float64(i)*0.1))
-	}
-	return data
+	return testsignal.Encode(testsignal.PCMU, testsignal.Speech(0.5, 1), samples)
 }
 
 // RunAllExamples запускает все примеры
@@ -0,0 +1,140 @@
+//go:build !small
+
+package media
+
+import (
+	"time"
+)
+
+// Формат RTP payload для iLBC (RFC 3952).
+//
+// Payload состоит из одного или нескольких кадров фиксированного размера:
+// 38 байт для режима 20ms (15.2 кбит/с) и 50 байт для режима 30ms
+// (13.33 кбит/с). Режим согласуется параметром fmtp mode.
+
+const (
+	// ILBCFrameSize20ms - размер кадра iLBC в режиме 20ms
+	ILBCFrameSize20ms = 38
+
+	// ILBCFrameSize30ms - размер кадра iLBC в режиме 30ms
+	ILBCFrameSize30ms = 50
+)
+
+// ILBCMode возвращает длительность кадра iLBC для значения fmtp mode.
+// Отсутствие параметра означает режим 30ms (RFC 3952 Section 5).
+func ILBCMode(value string) (time.Duration, error) {
+	switch value {
+	case "", "30":
+		return 30 * time.Millisecond, nil
+	case "20":
+		return 20 * time.Millisecond, nil
+	default:
+		return 0, codecError("iLBC", "неподдерживаемый mode=%s", value)
+	}
+}
+
+// ilbcFrameSize возвращает размер кадра для длительности кадра
+func ilbcFrameSize(mode time.Duration) int {
+	if mode == 20*time.Millisecond {
+		return ILBCFrameSize20ms
+	}
+	return ILBCFrameSize30ms
+}
+
+// ILBCFrameCodec - внешний кодер кадров iLBC (например, привязка к libilbc).
+// mode - длительность кадра: 20ms или 30ms.
+type ILBCFrameCodec interface {
+	// EncodeFrame кодирует аудио одного кадра
+	EncodeFrame(audio []byte, mode time.Duration) ([]byte, error)
+
+	// DecodeFrame декодирует один кадр в аудио
+	DecodeFrame(frame []byte, mode time.Duration) ([]byte, error)
+}
+
+// ILBCFrameCodecFactory создает внешний кодер кадров iLBC для одного вызова
+type ILBCFrameCodecFactory func() (ILBCFrameCodec, error)
+
+// RegisterILBCFrameCodec регистрирует внешний кодер кадров и кодек "iLBC" в
+// реестре кодеков. Параметр fmtp mode определяет длительность кадра, ptime
+// должен быть кратен ей. nil удаляет регистрацию.
+func RegisterILBCFrameCodec(factory ILBCFrameCodecFactory) {
+	if factory == nil {
+		RegisterCodec("iLBC", nil)
+		return
+	}
+
+	RegisterCodec("iLBC", func(params CodecParams) (Codec, error) {
+		mode, err := ILBCMode(params.Fmtp["mode"])
+		if err != nil {
+			return nil, err
+		}
+		if params.Ptime < mode || params.Ptime%mode != 0 {
+			return nil, codecError("iLBC", "ptime %v не кратен длительности кадра %v", params.Ptime, mode)
+		}
+
+		frames, err := factory()
+		if err != nil {
+			return nil, err
+		}
+		return &ilbcCodec{
+			frames:          frames,
+			mode:            mode,
+			framesPerPacket: int(params.Ptime / mode),
+		}, nil
+	})
+}
+
+// ilbcCodec реализует Codec поверх внешнего кодера кадров
+type ilbcCodec struct {
+	frames          ILBCFrameCodec
+	mode            time.Duration // Согласованная длительность кадра
+	framesPerPacket int
+}
+
+// Encode кодирует аудио пакета кадрами согласованного режима
+func (c *ilbcCodec) Encode(audio []byte) ([]byte, error) {
+	if len(audio) == 0 || len(audio)%c.framesPerPacket != 0 {
+		return nil, codecError("iLBC", "размер аудио %d не делится на %d кадров", len(audio), c.framesPerPacket)
+	}
+
+	frameSize := len(audio) / c.framesPerPacket
+	payload := make([]byte, 0, c.framesPerPacket*ilbcFrameSize(c.mode))
+	for offset := 0; offset < len(audio); offset += frameSize {
+		frame, err := c.frames.EncodeFrame(audio[offset:offset+frameSize], c.mode)
+		if err != nil {
+			return nil, err
+		}
+		if len(frame) != ilbcFrameSize(c.mode) {
+			return nil, codecError("iLBC", "кодер вернул кадр %d байт, ожидается %d", len(frame), ilbcFrameSize(c.mode))
+		}
+		payload = append(payload, frame...)
+	}
+	return payload, nil
+}
+
+// Decode декодирует кадры payload. Режим определяется по размеру payload:
+// удаленная сторона может отправлять кадры другого режима (RFC 3952 Section 5)
+func (c *ilbcCodec) Decode(payload []byte) ([]byte, error) {
+	mode := c.mode
+	if len(payload)%ilbcFrameSize(mode) != 0 {
+		if mode == 20*time.Millisecond {
+			mode = 30 * time.Millisecond
+		} else {
+			mode = 20 * time.Millisecond
+		}
+	}
+	frameSize := ilbcFrameSize(mode)
+	if len(payload) == 0 || len(payload)%frameSize != 0 {
+		return nil, codecError("iLBC", "размер payload %d не кратен размеру кадра", len(payload))
+	}
+
+	var audio []byte
+	for offset := 0; offset < len(payload); offset += frameSize {
+		decoded, err := c.frames.DecodeFrame(payload[offset:offset+frameSize], mode)
+		if err != nil {
+			return nil, err
+		}
+		audio = append(audio, decoded...)
+	}
+	return audio, nil
+}
@@ -59,7 +59,9 @@ type Session interface {
 	GetState() SessionState
 	GetDirection() Direction
 	GetPtime() time.Duration
+	GetRemotePtime() time.Duration
 	GetStatistics() Statistics
+	StatsStream(interval time.Duration) <-chan StatsSnapshot
 	GetPayloadType() PayloadType
 	GetPayloadTypeName() string
 	GetExpectedPayloadSize() int
@@ -2,13 +2,18 @@ package media
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/arzzra/soft_phone/pkg/profiling"
 	"github.com/pion/rtp"
 )
 
+// outputInterval - период проверки готовых к выводу пакетов
+const outputInterval = 5 * time.Millisecond
+
 // JitterBufferConfig содержит параметры конфигурации для создания JitterBuffer.
 // Определяет размер буфера, начальную задержку и ограничения.
 type JitterBufferConfig struct {
@@ -41,6 +46,7 @@ type JitterBuffer struct {
 	packetsReceived uint64
 	packetsDropped  uint64
 	packetsLate     uint64
+	talkspurts      uint64
 
 	// Управление временем
 	baseTime     time.Time
@@ -54,6 +60,7 @@ type JitterBuffer struct {
 	outputChanExtended chan *PacketWithSessionID // Новый канал с поддержкой ID сессии
 	stopChan           chan struct{}
 	stopped            bool
+	outputTask         *ScheduledTask // Вывод пакетов через общий планировщик
 }
 
 // JitterPacket представляет RTP пакет в jitter buffer с метаданными о времени.
@@ -70,6 +77,8 @@ type JitterPacket struct {
 type PacketWithSessionID struct {
 	Packet       *rtp.Packet
 	RTPSessionID string
+	Arrival      time.Time     // Время получения пакета из сети
+	PlayoutDelay time.Duration // Время, проведенное пакетом в буфере
 }
 
 // packetHeap реализует heap.Interface для сортировки по timestamp
@@ -103,6 +112,13 @@ func (h *packetHeap) Pop() interface{} {
 // NewJitterBuffer создает новый адаптивный jitter buffer с указанной конфигурацией.
 // Автоматически запускает внутренний worker для обработки пакетов.
 func NewJitterBuffer(config JitterBufferConfig) (*JitterBuffer, error) {
+	return newJitterBuffer(context.Background(), config, nil)
+}
+
+// newJitterBuffer создает jitter buffer сессии. Если задан scheduler, вывод
+// пакетов выполняется общим планировщиком вместо отдельной горутины; ctx
+// задает метки профилирования и время жизни задачи вывода.
+func newJitterBuffer(ctx context.Context, config JitterBufferConfig, scheduler *Scheduler) (*JitterBuffer, error) {
 	if config.BufferSize <= 0 {
 		config.BufferSize = 10 // По умолчанию 10 пакетов
 	}
@@ -135,7 +151,11 @@ func NewJitterBuffer(config JitterBufferConfig) (*JitterBuffer, error) {
 	heap.Init(&jb.packets)
 
 	// Запускаем worker для вывода пакетов
-	go jb.outputWorker()
+	if scheduler != nil {
+		jb.outputTask = scheduler.Every(ctx, outputInterval, jb.scheduledOutput)
+	} else {
+		profiling.Go(ctx, jb.outputWorker)
+	}
 
 	return jb, nil
 }
@@ -147,6 +167,29 @@ func (jb *JitterBuffer) SetClockRate(rate uint32) {
 	jb.rtpClockRate = rate
 }
 
+// SetPacketTime обновляет длительность пакета, используемую как минимальная задержка.
+// Вызывается при изменении ptime удаленной стороны, которая может отличаться от нашего.
+func (jb *JitterBuffer) SetPacketTime(ptime time.Duration) {
+	if ptime <= 0 {
+		return
+	}
+
+	jb.mutex.Lock()
+	defer jb.mutex.Unlock()
+
+	jb.config.PacketTime = ptime
+	if jb.config.MaxDelay < ptime {
+		jb.config.MaxDelay = ptime * time.Duration(jb.maxSize)
+	}
+}
+
+// GetPacketTime возвращает текущую длительность пакета
+func (jb *JitterBuffer) GetPacketTime() time.Duration {
+	jb.mutex.RLock()
+	defer jb.mutex.RUnlock()
+	return jb.config.PacketTime
+}
+
 // Put добавляет пакет в jitter buffer (для обратной совместимости)
 func (jb *JitterBuffer) Put(packet *rtp.Packet) error {
 	return jb.PutWithSessionID(packet, "")
@@ -173,6 +216,15 @@ func (jb *JitterBuffer) PutWithSessionID(packet *rtp.Packet, rtpSessionID string
 
 	jb.packetsReceived++
 
+	// Marker bit означает начало нового talkspurt (RFC 3551): отправитель мог
+	// молчать произвольное время, поэтому перестраиваем расписание воспроизведения
+	// относительно этого пакета, чтобы пауза не превратилась в лишнюю задержку
+	if packet.Marker && jb.packetsReceived > 1 {
+		jb.lastTimestamp = packet.Timestamp
+		jb.baseTime = now
+		jb.talkspurts++
+	}
+
 	// Проверяем sequence number
 	expectedSeq := jb.expectedSeq
 	if packet.SequenceNumber != expectedSeq {
@@ -262,6 +314,28 @@ func (jb *JitterBuffer) GetBlockingWithSessionID() (*rtp.Packet, string, error)
 	}
 }
 
+// GetWithMetadata получает пакет из jitter buffer вместе с ID сессии,
+// временем получения и задержкой воспроизведения (неблокирующий)
+func (jb *JitterBuffer) GetWithMetadata() (*PacketWithSessionID, bool) {
+	select {
+	case packetWithID, ok := <-jb.outputChanExtended:
+		return packetWithID, ok
+	default:
+		return nil, false
+	}
+}
+
+// GetBlockingWithMetadata получает пакет из jitter buffer вместе с ID сессии,
+// временем получения и задержкой воспроизведения (блокирующий)
+func (jb *JitterBuffer) GetBlockingWithMetadata() (*PacketWithSessionID, error) {
+	select {
+	case packetWithID := <-jb.outputChanExtended:
+		return packetWithID, nil
+	case <-jb.stopChan:
+		return nil, fmt.Errorf("jitter buffer остановлен")
+	}
+}
+
 // Stop останавливает jitter buffer
 func (jb *JitterBuffer) Stop() {
 	jb.mutex.Lock()
@@ -269,6 +343,7 @@ func (jb *JitterBuffer) Stop() {
 
 	if !jb.stopped {
 		jb.stopped = true
+		jb.outputTask.Cancel()
 		close(jb.stopChan)
 		close(jb.outputChan)
 		close(jb.outputChanExtended)
@@ -298,6 +373,7 @@ func (jb *JitterBuffer) GetStatistics() JitterBufferStatistics {
 		PacketsDropped:  jb.packetsDropped,
 		PacketsLate:     jb.packetsLate,
 		PacketLossRate:  lossRate,
+		Talkspurts:      jb.talkspurts,
 	}
 }
 
@@ -311,11 +387,12 @@ type JitterBufferStatistics struct {
 	PacketsDropped  uint64
 	PacketsLate     uint64
 	PacketLossRate  float64
+	Talkspurts      uint64 // Количество перестроений расписания по marker bit
 }
 
 // outputWorker обрабатывает вывод пакетов в правильном порядке
 func (jb *JitterBuffer) outputWorker() {
-	ticker := time.NewTicker(time.Millisecond * 5) // Проверяем каждые 5ms
+	ticker := time.NewTicker(outputInterval)
 	defer ticker.Stop()
 
 	for {
@@ -328,6 +405,18 @@ func (jb *JitterBuffer) outputWorker() {
 	}
 }
 
+// scheduledOutput выполняет вывод пакетов из задачи планировщика. Удерживает
+// mutex на чтение, чтобы Stop не закрыл выходные каналы во время вывода.
+func (jb *JitterBuffer) scheduledOutput() {
+	jb.mutex.RLock()
+	defer jb.mutex.RUnlock()
+
+	if jb.stopped {
+		return
+	}
+	jb.processOutput()
+}
+
 // processOutput обрабатывает вывод готовых пакетов
 func (jb *JitterBuffer) processOutput() {
 	jb.heapMutex.Lock()
@@ -351,6 +440,8 @@ func (jb *JitterBuffer) processOutput() {
 		packetWithID := &PacketWithSessionID{
 			Packet:       jitterPacket.packet,
 			RTPSessionID: jitterPacket.rtpSessionID,
+			Arrival:      jitterPacket.arrival,
+			PlayoutDelay: now.Sub(jitterPacket.arrival),
 		}
 
 		select {
@@ -467,3 +467,119 @@ func BenchmarkJitterBufferOperations(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkJitterBufferPushPop измеряет полный путь пакета через буфер:
+// Put, извлечение из кучи по времени воспроизведения и Get.
+// Расписание сдвинуто в прошлое, чтобы пакеты выдавались сразу, без ожидания задержки.
+func BenchmarkJitterBufferPushPop(b *testing.B) {
+	buffer, err := NewJitterBuffer(JitterBufferConfig{
+		BufferSize:   10,
+		InitialDelay: time.Millisecond * 40,
+	})
+	if err != nil {
+		b.Fatalf("Ошибка создания буфера: %v", err)
+	}
+	defer buffer.Stop()
+
+	audioData := generateTestAudioData(160)
+	if err := buffer.Put(createTestRTPPacket(0, 0, audioData)); err != nil {
+		b.Fatalf("Ошибка добавления пакета: %v", err)
+	}
+	buffer.mutex.Lock()
+	buffer.baseTime = buffer.baseTime.Add(-time.Minute)
+	buffer.mutex.Unlock()
+	if _, err := buffer.GetBlocking(); err != nil {
+		b.Fatalf("Ошибка получения первого пакета: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 1; i <= b.N; i++ {
+		if err := buffer.Put(createTestRTPPacket(uint16(i), 0, audioData)); err != nil {
+			b.Fatalf("Ошибка добавления пакета: %v", err)
+		}
+		buffer.processOutput()
+		if _, ok := buffer.Get(); !ok {
+			b.Fatal("Пакет не был выдан из буфера")
+		}
+	}
+}
+
+// TestJitterBufferTalkspurt тестирует перестроение расписания по marker bit
+// Пакет с marker после длинной паузы (скачок timestamp) должен быть выдан
+// с обычной задержкой, а не через длительность паузы
+func TestJitterBufferTalkspurt(t *testing.T) {
+	buffer, err := NewJitterBuffer(JitterBufferConfig{
+		BufferSize:   10,
+		InitialDelay: time.Millisecond * 20,
+		MaxDelay:     time.Millisecond * 100,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания буфера: %v", err)
+	}
+	defer buffer.Stop()
+
+	first := createTestRTPPacket(100, 0, generateTestAudioData(160))
+	if err := buffer.Put(first); err != nil {
+		t.Fatalf("Ошибка добавления пакета: %v", err)
+	}
+	if _, err := buffer.GetBlockingWithMetadata(); err != nil {
+		t.Fatalf("Ошибка получения первого пакета: %v", err)
+	}
+
+	// Новый talkspurt через 10 секунд RTP времени
+	next := createTestRTPPacket(101, 8000*10, generateTestAudioData(160))
+	next.Marker = true
+	if err := buffer.Put(next); err != nil {
+		t.Fatalf("Ошибка добавления пакета: %v", err)
+	}
+
+	received := make(chan *PacketWithSessionID, 1)
+	go func() {
+		item, err := buffer.GetBlockingWithMetadata()
+		if err == nil {
+			received <- item
+		}
+	}()
+
+	select {
+	case item := <-received:
+		if !item.Packet.Marker {
+			t.Error("Marker bit должен сохраниться")
+		}
+		if item.PlayoutDelay <= 0 {
+			t.Errorf("Задержка воспроизведения должна быть положительной, получено %v", item.PlayoutDelay)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Пакет с marker bit не был выдан: расписание не перестроено")
+	}
+
+	if stats := buffer.GetStatistics(); stats.Talkspurts != 1 {
+		t.Errorf("Ожидался 1 talkspurt, получено %d", stats.Talkspurts)
+	}
+}
+
+func TestJitterBufferSetPacketTime(t *testing.T) {
+	buffer, err := NewJitterBuffer(JitterBufferConfig{
+		BufferSize:   10,
+		InitialDelay: time.Millisecond * 60,
+		PacketTime:   time.Millisecond * 20,
+		MaxDelay:     time.Millisecond * 200,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания буфера: %v", err)
+	}
+	defer buffer.Stop()
+
+	buffer.SetPacketTime(time.Millisecond * 30)
+	if buffer.GetPacketTime() != time.Millisecond*30 {
+		t.Errorf("Ожидалась длительность пакета 30ms, получено %v", buffer.GetPacketTime())
+	}
+
+	// Некорректное значение игнорируется
+	buffer.SetPacketTime(0)
+	if buffer.GetPacketTime() != time.Millisecond*30 {
+		t.Errorf("Нулевая длительность пакета должна игнорироваться, получено %v", buffer.GetPacketTime())
+	}
+}
@@ -0,0 +1,90 @@
+package media
+
+// Формат RTP payload для L16 (RFC 3551 Section 4.5.11).
+//
+// L16 - несжатый 16-битный линейный PCM в сетевом порядке байт (big-endian),
+// отсчеты каналов чередуются. Статические payload types 10 (44100 Гц стерео)
+// и 11 (44100 Гц моно), остальные частоты (L16/8000, L16/16000) используют
+// динамический payload type. Кодек принимает и возвращает 16-битный PCM в
+// порядке байт little-endian.
+//
+// Пакет L16 может не помещаться в MTU (20ms стерео 44100 Гц - 3528 байт),
+// поэтому медиа сессия делит payload на несколько RTP пакетов по границе
+// отсчета (см. SampleCodec и Config.MaxPayloadSize).
+
+// Статические payload types L16 44100 Гц (RFC 3551)
+const (
+	PayloadTypeL16_2CH = PayloadType(10) // L16 стерео
+	PayloadTypeL16_1CH = PayloadType(11) // L16 моно
+)
+
+// DefaultMaxPayloadSize - максимальный размер RTP payload по умолчанию:
+// MTU Ethernet 1500 байт без заголовков IP, UDP и RTP с запасом для SRTP
+// и расширений заголовка RTP
+const DefaultMaxPayloadSize = 1400
+
+// l16SampleSize - размер отсчета одного канала L16 в байтах
+const l16SampleSize = 2
+
+// SampleCodec - кодек без кадров: payload состоит из отсчетов и может быть
+// разделен на несколько RTP пакетов по границе отсчета
+type SampleCodec interface {
+	Codec
+
+	// SampleSize возвращает размер отсчета всех каналов в байтах
+	SampleSize() int
+}
+
+func init() {
+	RegisterCodec("L16", newL16Codec)
+}
+
+// l16Codec реализует L16 с заданным количеством каналов
+type l16Codec struct {
+	channels int
+}
+
+// newL16Codec создает кодек L16. Количество каналов берется из rtpmap
+func newL16Codec(params CodecParams) (Codec, error) {
+	channels := params.Channels
+	if channels == 0 {
+		channels = 1
+	}
+	if channels < 0 {
+		return nil, codecError("L16", "некорректное количество каналов %d", channels)
+	}
+	if params.ClockRate == 0 {
+		return nil, codecError("L16", "частота не указана")
+	}
+	return &l16Codec{channels: channels}, nil
+}
+
+// SampleSize возвращает размер отсчета всех каналов
+func (c *l16Codec) SampleSize() int {
+	return l16SampleSize * c.channels
+}
+
+// Encode переводит отсчеты в сетевой порядок байт
+func (c *l16Codec) Encode(audio []byte) ([]byte, error) {
+	if len(audio)%c.SampleSize() != 0 {
+		return nil, codecError("L16", "размер аудио %d не кратен размеру отсчета %d", len(audio), c.SampleSize())
+	}
+	return swapSampleBytes(audio), nil
+}
+
+// Decode переводит отсчеты из сетевого порядка байт
+func (c *l16Codec) Decode(payload []byte) ([]byte, error) {
+	if len(payload) == 0 || len(payload)%c.SampleSize() != 0 {
+		return nil, codecError("L16", "размер payload %d не кратен размеру отсчета %d", len(payload), c.SampleSize())
+	}
+	return swapSampleBytes(payload), nil
+}
+
+// swapSampleBytes меняет порядок байт 16-битных отсчетов
+func swapSampleBytes(data []byte) []byte {
+	result := make([]byte, len(data))
+	for i := 0; i+1 < len(data); i += l16SampleSize {
+		result[i], result[i+1] = data[i+1], data[i]
+	}
+	return result
+}
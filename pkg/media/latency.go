@@ -0,0 +1,296 @@
+package media
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// LatencyWindowSize - количество последних измерений этапа, по которым
+// вычисляются перцентили задержки
+const LatencyWindowSize = 512
+
+// LatencyStats - распределение задержки одного этапа аудио тракта.
+// Среднее, перцентили и максимум вычисляются по последним
+// LatencyWindowSize измерениям
+type LatencyStats struct {
+	Samples uint64 // Все измерения с момента включения
+	Mean    time.Duration
+	P50     time.Duration
+	P95     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+// String возвращает краткое описание распределения
+func (s LatencyStats) String() string {
+	return fmt.Sprintf("p50=%v p95=%v p99=%v max=%v n=%d", s.P50, s.P95, s.P99, s.Max, s.Samples)
+}
+
+// SendLatency - задержки пути отправки: SendAudio -> кодирование ->
+// буфер отправки -> запись в сокет
+type SendLatency struct {
+	Encode LatencyStats // Обработка и кодирование в SendAudio
+	Buffer LatencyStats // Ожидание в буфере до тика отправки
+	Socket LatencyStats // Отправка пакета во все RTP сессии
+	Total  LatencyStats // От вызова SendAudio до завершения отправки
+}
+
+// ReceiveLatency - задержки пути приема: получение пакета -> jitter buffer ->
+// декодирование -> callback приложения
+type ReceiveLatency struct {
+	Jitter   LatencyStats // Ожидание в jitter buffer (0 без jitter buffer)
+	Decode   LatencyStats // Декодирование аудио процессором
+	Callback LatencyStats // Выполнение callback-ов приложения
+	Total    LatencyStats // От получения пакета до возврата из callback-ов
+}
+
+// LatencyReport - разбивка внутренней задержки аудио тракта сессии по
+// этапам. Показывает, на каком этапе теряется время: в буфере отправки,
+// jitter buffer, кодеке или в обработчиках приложения. Задержка сети и
+// аудио устройств в отчет не входит.
+type LatencyReport struct {
+	SessionID string
+	Send      SendLatency
+	Receive   ReceiveLatency
+}
+
+// String возвращает многострочное описание отчета для логов
+func (r LatencyReport) String() string {
+	return fmt.Sprintf("session %s\n"+
+		"send encode:      %s\nsend buffer:      %s\nsend socket:      %s\nsend total:       %s\n"+
+		"receive jitter:   %s\nreceive decode:   %s\nreceive callback: %s\nreceive total:    %s",
+		r.SessionID,
+		r.Send.Encode, r.Send.Buffer, r.Send.Socket, r.Send.Total,
+		r.Receive.Jitter, r.Receive.Decode, r.Receive.Callback, r.Receive.Total)
+}
+
+// EnableLatencyMeasurement включает/отключает измерение задержки аудио
+// тракта. Повторное включение сохраняет накопленные измерения, отключение
+// их сбрасывает. Измеряется только аудио, добавленное после включения.
+func (ms *MediaSession) EnableLatencyMeasurement(enabled bool) {
+	if enabled {
+		ms.latency.CompareAndSwap(nil, &latencyMeter{})
+		return
+	}
+	ms.latency.Store(nil)
+}
+
+// GetLatencyReport возвращает разбивку задержки аудио тракта по этапам.
+// false - измерение не включено (Config.MeasureLatency или
+// EnableLatencyMeasurement)
+func (ms *MediaSession) GetLatencyReport() (LatencyReport, bool) {
+	meter := ms.latency.Load()
+	if meter == nil {
+		return LatencyReport{}, false
+	}
+	report := meter.report()
+	report.SessionID = ms.sessionID
+	return report, true
+}
+
+// latencyWindow - кольцевой буфер последних измерений этапа
+type latencyWindow struct {
+	samples [LatencyWindowSize]time.Duration
+	count   uint64
+}
+
+// observe добавляет измерение
+func (w *latencyWindow) observe(d time.Duration) {
+	w.samples[w.count%LatencyWindowSize] = max(d, 0)
+	w.count++
+}
+
+// stats вычисляет распределение по окну измерений
+func (w *latencyWindow) stats() LatencyStats {
+	n := int(min(w.count, LatencyWindowSize))
+	if n == 0 {
+		return LatencyStats{}
+	}
+
+	sorted := slices.Clone(w.samples[:n])
+	slices.Sort(sorted)
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+
+	// Перцентиль по ближайшему рангу
+	percentile := func(p float64) time.Duration {
+		return sorted[max(int(math.Ceil(p*float64(n)))-1, 0)]
+	}
+	return LatencyStats{
+		Samples: w.count,
+		Mean:    sum / time.Duration(n),
+		P50:     percentile(0.50),
+		P95:     percentile(0.95),
+		P99:     percentile(0.99),
+		Max:     sorted[n-1],
+	}
+}
+
+// sendMark - время поступления данных в буфер отправки
+type sendMark struct {
+	captured time.Time // Вызов SendAudio
+	enqueued time.Time // Данные добавлены в буфер после кодирования
+	size     int       // Размер данных: байты аудио буфера или 1 пакет кодека
+}
+
+// sendMarks - метки данных буфера отправки в порядке поступления. Метки
+// описывают хвост буфера: данные, добавленные до включения измерения, меток
+// не имеют, а метки данных, удаленных при очистке буфера, отбрасываются
+// при следующей отправке
+type sendMarks struct {
+	marks []sendMark
+	size  int
+}
+
+// push добавляет метку новых данных буфера
+func (q *sendMarks) push(mark sendMark) {
+	q.marks = append(q.marks, mark)
+	q.size += mark.size
+}
+
+// take снимает метки пакета размера size из начала буфера длины buffered.
+// Возвращает метку первого байта пакета; false - начало пакета без метки
+func (q *sendMarks) take(buffered, size int) (sendMark, bool) {
+	// Метки данных, удаленных из буфера без отправки
+	if excess := q.size - buffered; excess > 0 {
+		q.consume(excess)
+	}
+
+	unmarked := buffered - q.size
+	if unmarked >= size || len(q.marks) == 0 {
+		return sendMark{}, false
+	}
+	first := q.marks[0]
+	q.consume(size - unmarked)
+	return first, unmarked == 0
+}
+
+// consume снимает метки n первых единиц данных
+func (q *sendMarks) consume(n int) {
+	for n > 0 && len(q.marks) > 0 {
+		if q.marks[0].size > n {
+			q.marks[0].size -= n
+			q.size -= n
+			return
+		}
+		n -= q.marks[0].size
+		q.size -= q.marks[0].size
+		q.marks = q.marks[1:]
+	}
+}
+
+// latencyMeter накапливает измерения задержки на путях отправки и приема
+type latencyMeter struct {
+	mu sync.Mutex
+
+	encode, buffer, socket, sendTotal      latencyWindow
+	jitter, decode, callback, receiveTotal latencyWindow
+
+	// Метки аудио буфера (байты) и очереди пакетов подключаемого кодека
+	audioMarks, codecMarks sendMarks
+}
+
+// now возвращает текущее время, если измерение включено
+func (m *latencyMeter) now() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// since возвращает время с момента start, если измерение включено
+func (m *latencyMeter) since(start time.Time) time.Duration {
+	if m == nil {
+		return 0
+	}
+	return time.Since(start)
+}
+
+// marksFor выбирает очередь меток: пакетов подключаемого кодека или аудио буфера
+func (m *latencyMeter) marksFor(codec bool) *sendMarks {
+	if codec {
+		return &m.codecMarks
+	}
+	return &m.audioMarks
+}
+
+// markEnqueued запоминает время поступления данных в буфер отправки.
+// Вызывается под bufferMutex сессии
+func (m *latencyMeter) markEnqueued(codec bool, captured time.Time, size int) {
+	if m == nil || size == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.marksFor(codec).push(sendMark{captured: captured, enqueued: time.Now(), size: size})
+}
+
+// takeEnqueued снимает метки отправляемого пакета. Вызывается под
+// bufferMutex сессии до удаления пакета из буфера
+func (m *latencyMeter) takeEnqueued(codec bool, buffered, size int) (sendMark, bool) {
+	if m == nil {
+		return sendMark{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.marksFor(codec).take(buffered, size)
+}
+
+// observeSend учитывает отправку пакета, начавшуюся в sent и завершенную в done
+func (m *latencyMeter) observeSend(mark sendMark, marked bool, sent, done time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.socket.observe(done.Sub(sent))
+	if !marked {
+		return
+	}
+	m.encode.observe(mark.enqueued.Sub(mark.captured))
+	m.buffer.observe(sent.Sub(mark.enqueued))
+	m.sendTotal.observe(done.Sub(mark.captured))
+}
+
+// observeReceive учитывает принятый пакет: arrival - получение пакета,
+// dequeued - выход из jitter buffer, done - возврат из callback-ов
+func (m *latencyMeter) observeReceive(arrival, dequeued, done time.Time, decode, callback time.Duration) {
+	if m == nil || arrival.IsZero() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jitter.observe(dequeued.Sub(arrival))
+	if decode > 0 {
+		m.decode.observe(decode)
+	}
+	m.callback.observe(callback)
+	m.receiveTotal.observe(done.Sub(arrival))
+}
+
+// report формирует отчет по накопленным измерениям
+func (m *latencyMeter) report() LatencyReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return LatencyReport{
+		Send: SendLatency{
+			Encode: m.encode.stats(),
+			Buffer: m.buffer.stats(),
+			Socket: m.socket.stats(),
+			Total:  m.sendTotal.stats(),
+		},
+		Receive: ReceiveLatency{
+			Jitter:   m.jitter.stats(),
+			Decode:   m.decode.stats(),
+			Callback: m.callback.stats(),
+			Total:    m.receiveTotal.stats(),
+		},
+	}
+}
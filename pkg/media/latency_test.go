@@ -0,0 +1,140 @@
+package media
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// TestSendMarksAlignment проверяет сопоставление меток с началом буфера
+// отправки: данные без меток и метки очищенного буфера
+func TestSendMarksAlignment(t *testing.T) {
+	base := time.Now()
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+	var q sendMarks
+	q.push(sendMark{captured: at(1), size: 100})
+	q.push(sendMark{captured: at(2), size: 100})
+
+	// 60 байт в начале буфера добавлены до включения измерения
+	if _, ok := q.take(260, 160); ok {
+		t.Error("Пакет, начинающийся с данных без метки, не измеряется")
+	}
+	mark, ok := q.take(100, 80)
+	if !ok || !mark.captured.Equal(at(2)) {
+		t.Errorf("Ожидалась метка второй записи, получено %v %v", mark.captured.Sub(base), ok)
+	}
+	if q.size != 20 {
+		t.Errorf("Ожидалось 20 байт с метками, осталось %d", q.size)
+	}
+
+	// Буфер очищен, затем добавлены новые данные
+	q.push(sendMark{captured: at(3), size: 160})
+	mark, ok = q.take(160, 160)
+	if !ok || !mark.captured.Equal(at(3)) {
+		t.Errorf("Метки очищенных данных должны быть отброшены, получено %v %v", mark.captured.Sub(base), ok)
+	}
+	if q.size != 0 || len(q.marks) != 0 {
+		t.Errorf("Все метки должны быть сняты: %d байт, %d меток", q.size, len(q.marks))
+	}
+}
+
+// TestLatencyWindowStats проверяет перцентили по окну последних измерений
+func TestLatencyWindowStats(t *testing.T) {
+	var w latencyWindow
+	for i := 1; i <= 100; i++ {
+		w.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	stats := w.stats()
+	if stats.Samples != 100 || stats.P50 != 50*time.Millisecond || stats.P95 != 95*time.Millisecond ||
+		stats.P99 != 99*time.Millisecond || stats.Max != 100*time.Millisecond {
+		t.Errorf("Неверное распределение: %s", stats)
+	}
+	if stats.Mean != 50500*time.Microsecond {
+		t.Errorf("Ожидалось среднее 50.5ms, получено %v", stats.Mean)
+	}
+
+	// Старые измерения вытесняются из окна
+	for i := 0; i < LatencyWindowSize; i++ {
+		w.observe(time.Millisecond)
+	}
+	if stats = w.stats(); stats.Max != time.Millisecond || stats.Samples != 100+LatencyWindowSize {
+		t.Errorf("Окно должно содержать только последние измерения: %s", stats)
+	}
+}
+
+// TestLatencyReport проверяет разбивку задержки путей отправки и приема
+func TestLatencyReport(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-latency"
+	config.DTMFEnabled = false
+	config.MeasureLatency = true
+	config.OnAudioReceived = func([]byte, PayloadType, time.Duration, string) {
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	mock := NewMockSessionRTP("primary", "PCMU")
+	mock.SetNetworkLatency(3 * time.Millisecond)
+	if err := session.AddRTPSession("primary", mock); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+
+	// Три пакета в буфере: последний ждет отправки два тика
+	for i := 0; i < 3; i++ {
+		if err := session.SendAudioRaw(generateTestAudioData(StandardPCMSamples20ms)); err != nil {
+			t.Fatalf("Ошибка отправки аудио: %v", err)
+		}
+	}
+	time.Sleep(5 * config.Ptime)
+
+	session.processIncomingPacket(&rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: PayloadTypePCMU, SequenceNumber: 1, SSRC: 1},
+		Payload: generateTestAudioData(StandardPCMSamples20ms),
+	})
+
+	report, ok := session.GetLatencyReport()
+	if !ok {
+		t.Fatal("Измерение задержки должно быть включено")
+	}
+	send := report.Send
+	if send.Total.Samples != 3 || send.Socket.Samples != 3 {
+		t.Fatalf("Ожидалось 3 измерения отправки: %+v", send)
+	}
+	if send.Socket.P50 < 3*time.Millisecond {
+		t.Errorf("Отправка в сокет должна включать задержку mock: %s", send.Socket)
+	}
+	if send.Buffer.Max < config.Ptime {
+		t.Errorf("Последний пакет должен ждать в буфере не меньше ptime: %s", send.Buffer)
+	}
+	if send.Total.Max < send.Buffer.Max {
+		t.Errorf("Общая задержка меньше ожидания в буфере: %s", send.Total)
+	}
+
+	receive := report.Receive
+	if receive.Total.Samples != 1 || receive.Decode.Samples != 1 {
+		t.Fatalf("Ожидалось 1 измерение приема: %+v", receive)
+	}
+	if receive.Callback.P50 < 2*time.Millisecond || receive.Total.P50 < receive.Callback.P50 {
+		t.Errorf("Неверная задержка callback: %s, всего %s", receive.Callback, receive.Total)
+	}
+	if !strings.Contains(report.String(), "receive callback") {
+		t.Errorf("Отчет должен содержать этапы:\n%s", report)
+	}
+
+	session.EnableLatencyMeasurement(false)
+	if _, ok := session.GetLatencyReport(); ok {
+		t.Error("После отключения отчет недоступен")
+	}
+}
@@ -0,0 +1,290 @@
+package media
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// SupervisorMode определяет, как супервизор участвует в разговоре агента
+type SupervisorMode int
+
+const (
+	// SupervisorListen - супервизор слышит разговор, его не слышит никто
+	SupervisorListen SupervisorMode = iota
+	// SupervisorWhisper - супервизор слышит разговор, его слышит только агент (coach)
+	SupervisorWhisper
+	// SupervisorBarge - супервизор полноценный участник разговора
+	SupervisorBarge
+)
+
+// String возвращает название режима
+func (m SupervisorMode) String() string {
+	switch m {
+	case SupervisorListen:
+		return "listen"
+	case SupervisorWhisper:
+		return "whisper"
+	case SupervisorBarge:
+		return "barge"
+	default:
+		return "unknown"
+	}
+}
+
+// Mixer смешивает 16-битный линейный PCM участников конференции (мост).
+// Каждый участник (leg) получает сумму кадров участников, которых он слышит
+// по матрице маршрутизации: по умолчанию все слышат всех, кроме себя
+// (mix-minus). Супервизор подключается к агенту в одном из режимов
+// SupervisorMode, который можно менять во время разговора.
+//
+// Кадры записываются Write за период ptime и смешиваются вызовом Mix:
+//
+//	mixer := media.NewMixer(160) // 20 мс при 8 кГц
+//	_ = mixer.AddLeg("agent")
+//	_ = mixer.AddLeg("customer")
+//	_ = mixer.AddSupervisor("supervisor", "agent", media.SupervisorWhisper)
+//	// каждые 20 мс:
+//	_ = mixer.Write("customer", customerPCM)
+//	out := mixer.Mix() // out["agent"] - кадр для отправки агенту
+type Mixer struct {
+	mu           sync.Mutex
+	frameSamples int
+	legs         map[string]*mixerLeg
+	order        []string
+}
+
+// mixerLeg - участник микшера
+type mixerLeg struct {
+	input []int16
+	// hears - участники, которых слышит этот участник
+	hears map[string]bool
+	// Для супервизора: агент и режим подключения
+	agent string
+	mode  SupervisorMode
+}
+
+// NewMixer создает микшер с размером кадра frameSamples отсчетов
+func NewMixer(frameSamples int) *Mixer {
+	return &Mixer{
+		frameSamples: frameSamples,
+		legs:         make(map[string]*mixerLeg),
+	}
+}
+
+// AddLeg добавляет участника, который слышит всех и которого слышат все
+// участники, кроме супервизоров в режимах listen и whisper
+func (m *Mixer) AddLeg(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.addLegLocked(id); err != nil {
+		return err
+	}
+	for other, leg := range m.legs {
+		if other == id {
+			continue
+		}
+		// Нового участника слышат все, включая супервизоров
+		leg.hears[id] = true
+		// Супервизора новый участник слышит только в режиме barge:
+		// в режиме whisper супервизора слышит лишь его агент
+		m.legs[id].hears[other] = leg.agent == "" || leg.mode == SupervisorBarge
+	}
+	return nil
+}
+
+// AddSupervisor подключает супервизора к разговору агента agent в режиме mode
+func (m *Mixer) AddSupervisor(id, agent string, mode SupervisorMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.legs[agent]; !ok {
+		return mixerError("агент %s не подключен к микшеру", agent)
+	}
+	if err := m.addLegLocked(id); err != nil {
+		return err
+	}
+	supervisor := m.legs[id]
+	supervisor.agent = agent
+	for other, leg := range m.legs {
+		if other != id {
+			supervisor.hears[other] = true
+			leg.hears[id] = false
+		}
+	}
+	m.applyModeLocked(id, mode)
+	return nil
+}
+
+// SetSupervisorMode переключает режим супервизора во время разговора
+func (m *Mixer) SetSupervisorMode(id string, mode SupervisorMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	leg, ok := m.legs[id]
+	if !ok || leg.agent == "" {
+		return mixerError("супервизор %s не подключен к микшеру", id)
+	}
+	m.applyModeLocked(id, mode)
+	return nil
+}
+
+// SupervisorMode возвращает режим супервизора
+func (m *Mixer) SupervisorMode(id string) (SupervisorMode, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	leg, ok := m.legs[id]
+	if !ok || leg.agent == "" {
+		return 0, false
+	}
+	return leg.mode, true
+}
+
+// SetRoute включает или выключает передачу звука участника from участнику to
+func (m *Mixer) SetRoute(from, to string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	listener, ok := m.legs[to]
+	if _, exists := m.legs[from]; !ok || !exists || from == to {
+		return mixerError("некорректный маршрут %s -> %s", from, to)
+	}
+	listener.hears[from] = enabled
+	return nil
+}
+
+// Hears проверяет, слышит ли участник listener участника source
+func (m *Mixer) Hears(listener, source string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	leg, ok := m.legs[listener]
+	return ok && leg.hears[source]
+}
+
+// RemoveLeg отключает участника. Вместе с агентом отключаются его супервизоры.
+func (m *Mixer) RemoveLeg(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.legs[id]; !ok {
+		return
+	}
+	m.removeLegLocked(id)
+	for other, leg := range m.legs {
+		if leg.agent == id {
+			m.removeLegLocked(other)
+		}
+	}
+}
+
+// Legs возвращает участников в порядке подключения
+func (m *Mixer) Legs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.order...)
+}
+
+// Write записывает кадр участника для следующего вызова Mix. Короткий кадр
+// дополняется тишиной, длинный обрезается до размера кадра микшера.
+func (m *Mixer) Write(id string, samples []int16) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	leg, ok := m.legs[id]
+	if !ok {
+		return mixerError("участник %s не подключен к микшеру", id)
+	}
+	if leg.input == nil {
+		leg.input = make([]int16, m.frameSamples)
+	}
+	n := copy(leg.input, samples)
+	clear(leg.input[n:])
+	return nil
+}
+
+// Mix смешивает записанные кадры и возвращает кадр для каждого участника.
+// Участники без записанного кадра считаются молчащими.
+func (m *Mixer) Mix() map[string][]int16 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sum := make([]int32, m.frameSamples)
+	out := make(map[string][]int16, len(m.legs))
+	for id, leg := range m.legs {
+		clear(sum)
+		for source, heard := range leg.hears {
+			input := m.legs[source].input
+			if !heard || input == nil {
+				continue
+			}
+			for i, sample := range input {
+				sum[i] += int32(sample)
+			}
+		}
+		frame := make([]int16, m.frameSamples)
+		for i, value := range sum {
+			frame[i] = int16(max(math.MinInt16, min(math.MaxInt16, value)))
+		}
+		out[id] = frame
+	}
+
+	for _, leg := range m.legs {
+		leg.input = nil
+	}
+	return out
+}
+
+// addLegLocked добавляет участника без маршрутов. Вызывается под mu.
+func (m *Mixer) addLegLocked(id string) error {
+	if id == "" {
+		return mixerError("пустой идентификатор участника")
+	}
+	if _, exists := m.legs[id]; exists {
+		return mixerError("участник %s уже подключен к микшеру", id)
+	}
+	m.legs[id] = &mixerLeg{hears: make(map[string]bool)}
+	m.order = append(m.order, id)
+	return nil
+}
+
+// removeLegLocked удаляет участника и маршруты к нему. Вызывается под mu.
+func (m *Mixer) removeLegLocked(id string) {
+	delete(m.legs, id)
+	for _, leg := range m.legs {
+		delete(leg.hears, id)
+	}
+	for i, other := range m.order {
+		if other == id {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// applyModeLocked перестраивает маршруты от супервизора по режиму. Вызывается под mu.
+func (m *Mixer) applyModeLocked(id string, mode SupervisorMode) {
+	supervisor := m.legs[id]
+	supervisor.mode = mode
+	for other, leg := range m.legs {
+		if other == id {
+			continue
+		}
+		switch mode {
+		case SupervisorListen:
+			leg.hears[id] = false
+		case SupervisorWhisper:
+			leg.hears[id] = other == supervisor.agent
+		case SupervisorBarge:
+			leg.hears[id] = true
+		}
+	}
+}
+
+// mixerError создает ошибку конфигурации микшера
+func mixerError(format string, args ...interface{}) error {
+	return &MediaError{
+		Code:    ErrorCodeSessionInvalidConfig,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
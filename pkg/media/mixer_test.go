@@ -0,0 +1,167 @@
+package media
+
+import (
+	"math"
+	"testing"
+)
+
+// constantFrame создает кадр из одинаковых отсчетов
+func constantFrame(n int, value int16) []int16 {
+	frame := make([]int16, n)
+	for i := range frame {
+		frame[i] = value
+	}
+	return frame
+}
+
+// mixSupervised записывает кадры агента, клиента и супервизора и смешивает их
+func mixSupervised(t *testing.T, mixer *Mixer) map[string][]int16 {
+	t.Helper()
+	for id, value := range map[string]int16{"agent": 100, "customer": 20, "supervisor": 3} {
+		if err := mixer.Write(id, constantFrame(4, value)); err != nil {
+			t.Fatalf("Ошибка записи кадра %s: %v", id, err)
+		}
+	}
+	return mixer.Mix()
+}
+
+// TestMixerMixMinus проверяет, что участник не слышит сам себя
+func TestMixerMixMinus(t *testing.T) {
+	mixer := NewMixer(4)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := mixer.AddLeg(id); err != nil {
+			t.Fatalf("Ошибка добавления участника: %v", err)
+		}
+	}
+	if err := mixer.AddLeg("a"); err == nil {
+		t.Error("Повторное добавление участника должно возвращать ошибку")
+	}
+
+	_ = mixer.Write("a", constantFrame(4, 1))
+	_ = mixer.Write("b", constantFrame(4, 10))
+	// c молчит, короткий кадр дополняется тишиной
+	_ = mixer.Write("c", []int16{100})
+
+	out := mixer.Mix()
+	expected := map[string][]int16{
+		"a": {110, 10, 10, 10},
+		"b": {101, 1, 1, 1},
+		"c": {11, 11, 11, 11},
+	}
+	for id, frame := range expected {
+		for i := range frame {
+			if out[id][i] != frame[i] {
+				t.Fatalf("Участник %s: ожидалось %v, получено %v", id, frame, out[id])
+			}
+		}
+	}
+
+	// Кадры не переносятся в следующий период
+	for id, frame := range mixer.Mix() {
+		if frame[0] != 0 {
+			t.Errorf("Участник %s должен слышать тишину, получено %v", id, frame)
+		}
+	}
+}
+
+// TestMixerClipping проверяет ограничение суммы диапазоном int16
+func TestMixerClipping(t *testing.T) {
+	mixer := NewMixer(1)
+	for _, id := range []string{"a", "b", "c"} {
+		_ = mixer.AddLeg(id)
+	}
+	_ = mixer.Write("a", []int16{math.MaxInt16})
+	_ = mixer.Write("b", []int16{math.MaxInt16})
+	if got := mixer.Mix()["c"][0]; got != math.MaxInt16 {
+		t.Errorf("Ожидалось %d, получено %d", math.MaxInt16, got)
+	}
+
+	_ = mixer.Write("a", []int16{math.MinInt16})
+	_ = mixer.Write("b", []int16{-1})
+	if got := mixer.Mix()["c"][0]; got != math.MinInt16 {
+		t.Errorf("Ожидалось %d, получено %d", math.MinInt16, got)
+	}
+}
+
+// TestMixerSupervisorModes проверяет маршруты супервизора в режимах
+// listen, whisper и barge и их переключение во время разговора
+func TestMixerSupervisorModes(t *testing.T) {
+	mixer := NewMixer(4)
+	_ = mixer.AddLeg("agent")
+	_ = mixer.AddLeg("customer")
+	if err := mixer.AddSupervisor("supervisor", "unknown", SupervisorListen); err == nil {
+		t.Error("Подключение к неизвестному агенту должно возвращать ошибку")
+	}
+	if err := mixer.AddSupervisor("supervisor", "agent", SupervisorListen); err != nil {
+		t.Fatalf("Ошибка подключения супервизора: %v", err)
+	}
+
+	tests := []struct {
+		mode     SupervisorMode
+		agent    int16
+		customer int16
+	}{
+		{SupervisorListen, 20, 100},
+		{SupervisorWhisper, 23, 100},
+		{SupervisorBarge, 23, 103},
+		{SupervisorListen, 20, 100},
+	}
+	for _, tt := range tests {
+		if err := mixer.SetSupervisorMode("supervisor", tt.mode); err != nil {
+			t.Fatalf("Ошибка переключения режима: %v", err)
+		}
+		if mode, _ := mixer.SupervisorMode("supervisor"); mode != tt.mode {
+			t.Errorf("Ожидался режим %s, получен %s", tt.mode, mode)
+		}
+
+		out := mixSupervised(t, mixer)
+		if out["agent"][0] != tt.agent {
+			t.Errorf("%s: агент должен слышать %d, получено %d", tt.mode, tt.agent, out["agent"][0])
+		}
+		if out["customer"][0] != tt.customer {
+			t.Errorf("%s: клиент должен слышать %d, получено %d", tt.mode, tt.customer, out["customer"][0])
+		}
+		// Супервизор всегда слышит весь разговор
+		if out["supervisor"][0] != 120 {
+			t.Errorf("%s: супервизор должен слышать 120, получено %d", tt.mode, out["supervisor"][0])
+		}
+	}
+
+	if err := mixer.SetSupervisorMode("customer", SupervisorBarge); err == nil {
+		t.Error("Переключение режима не супервизора должно возвращать ошибку")
+	}
+}
+
+// TestMixerLegJoinsSupervisedCall проверяет маршруты участника,
+// подключившегося после супервизора в режиме whisper
+func TestMixerLegJoinsSupervisedCall(t *testing.T) {
+	mixer := NewMixer(4)
+	_ = mixer.AddLeg("agent")
+	_ = mixer.AddSupervisor("supervisor", "agent", SupervisorWhisper)
+	_ = mixer.AddLeg("customer")
+
+	if mixer.Hears("customer", "supervisor") {
+		t.Error("Клиент не должен слышать супервизора в режиме whisper")
+	}
+	if !mixer.Hears("supervisor", "customer") || !mixer.Hears("agent", "supervisor") {
+		t.Error("Супервизор должен слышать клиента, агент - супервизора")
+	}
+
+	// Явный маршрут поверх режима
+	if err := mixer.SetRoute("agent", "customer", false); err != nil {
+		t.Fatalf("Ошибка установки маршрута: %v", err)
+	}
+	if mixer.Hears("customer", "agent") {
+		t.Error("Маршрут agent -> customer должен быть выключен")
+	}
+	if err := mixer.SetRoute("agent", "agent", true); err == nil {
+		t.Error("Маршрут на себя должен возвращать ошибку")
+	}
+
+	// Отключение агента отключает его супервизоров
+	mixer.RemoveLeg("agent")
+	legs := mixer.Legs()
+	if len(legs) != 1 || legs[0] != "customer" {
+		t.Errorf("Ожидался только customer, получено %v", legs)
+	}
+}
@@ -0,0 +1,268 @@
+package media
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/dialog/mockTransport"
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+	rtpPkg "github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/rtp"
+)
+
+// pcapExpectation - ожидаемый результат воспроизведения захвата из
+// testdata/pcap/<имя>.json рядом с <имя>.pcap
+type pcapExpectation struct {
+	Description     string   `json:"description"`
+	RTPAddress      string   `json:"rtp_address"` // Адрес назначения RTP потока в захвате
+	SIPAddress      string   `json:"sip_address"` // Адрес назначения SIP, пусто - SIP не проверяется
+	PayloadType     uint8    `json:"payload_type"`
+	DTMFPayloadType uint8    `json:"dtmf_payload_type"`
+	RTPPackets      uint64   `json:"rtp_packets"`
+	AudioFrames     int      `json:"audio_frames"`
+	PacketsLost     uint32   `json:"packets_lost"`
+	DTMF            string   `json:"dtmf"`
+	DTMFPackets     uint64   `json:"dtmf_packets"` // RFC 4733 пакеты, включая повторы конечного
+	AudioCRC32      uint32   `json:"audio_crc32"`  // CRC32 декодированных отсчетов (int16 big endian)
+	SIPMethods      []string `json:"sip_methods"`
+}
+
+// TestPcapRegression воспроизводит захваты реального трафика из
+// testdata/pcap через mockTransport в исходном темпе и проверяет
+// декодированное аудио, распознанные DTMF и статистику RTP. Новый захват
+// добавляется парой файлов <имя>.pcap и <имя>.json
+func TestPcapRegression(t *testing.T) {
+	captures, err := filepath.Glob(filepath.Join("testdata", "pcap", "*.pcap"))
+	if err != nil {
+		t.Fatalf("Ошибка поиска захватов: %v", err)
+	}
+	if len(captures) == 0 {
+		t.Skip("Нет захватов в testdata/pcap")
+	}
+
+	for _, capture := range captures {
+		name := strings.TrimSuffix(filepath.Base(capture), ".pcap")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(strings.TrimSuffix(capture, ".pcap") + ".json")
+			if err != nil {
+				t.Fatalf("Нет ожиданий для захвата: %v", err)
+			}
+			var expect pcapExpectation
+			if err := json.Unmarshal(data, &expect); err != nil {
+				t.Fatalf("Ошибка разбора ожиданий: %v", err)
+			}
+			packets, err := mockTransport.ReadPcapFile(capture)
+			if err != nil {
+				t.Fatalf("Ошибка чтения захвата: %v", err)
+			}
+			replayCapture(t, packets, expect)
+		})
+	}
+}
+
+// replayCapture воспроизводит захват через RTP и медиа сессии и сравнивает
+// результат с ожиданиями
+func replayCapture(t *testing.T, packets []mockTransport.CapturedPacket, expect pcapExpectation) {
+	registry := mockTransport.NewRegistry()
+	registry.SetBufferSize(len(packets))
+	defer registry.CloseAll()
+
+	var mutex sync.Mutex
+	var digits strings.Builder
+	audioCRC := crc32.NewIEEE()
+	audioFrames := 0
+
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "pcap-replay"
+	config.PayloadType = expect.PayloadType
+	config.DTMFPayloadType = expect.DTMFPayloadType
+	config.OnRawAudioReceived = func(payload []byte, pt PayloadType, _ time.Duration, _ string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		audioFrames++
+		var sample [2]byte
+		for _, value := range testsignal.Codec(pt).DecodePCM(payload) {
+			binary.BigEndian.PutUint16(sample[:], uint16(value))
+			audioCRC.Write(sample[:])
+		}
+	}
+	config.OnDTMFReceived = func(event DTMFEvent, _ string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		digits.WriteString(event.Digit.String())
+	}
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	transport := &replayTransport{conn: registry.CreateConnection(expect.RTPAddress)}
+	rtpSession, err := rtpPkg.NewSession(rtpPkg.SessionConfig{
+		PayloadType: rtpPkg.PayloadType(expect.PayloadType),
+		MediaType:   rtpPkg.MediaTypeAudio,
+		Transport:   transport,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания RTP сессии: %v", err)
+	}
+	if err := session.AddRTPSession("replay", rtpSession); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+
+	var sip *mockTransport.MockPacketConn
+	if expect.SIPAddress != "" {
+		sip = registry.CreateConnection(expect.SIPAddress)
+	}
+
+	result, err := registry.Replay(context.Background(), packets, mockTransport.ReplayOptions{Speed: 1})
+	if err != nil {
+		t.Fatalf("Ошибка воспроизведения: %v", err)
+	}
+	if result.Failed > 0 {
+		t.Errorf("Не доставлено пакетов: %d", result.Failed)
+	}
+
+	// Ожидаем обработку последних пакетов
+	deadline := time.Now().Add(time.Second)
+	for rtpSession.GetStatistics().PacketsReceived < expect.RTPPackets && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stats := rtpSession.GetStatistics()
+	if stats.PacketsReceived != expect.RTPPackets {
+		t.Errorf("Получено RTP пакетов %d, ожидалось %d", stats.PacketsReceived, expect.RTPPackets)
+	}
+	if lost := transport.lost(); lost != expect.PacketsLost {
+		t.Errorf("Потеряно пакетов %d, ожидалось %d", lost, expect.PacketsLost)
+	}
+	mediaStats := session.GetStatistics()
+	if mediaStats.AudioPacketsReceived != uint64(expect.AudioFrames) {
+		t.Errorf("Медиа сессия приняла %d аудио пакетов, ожидалось %d", mediaStats.AudioPacketsReceived, expect.AudioFrames)
+	}
+	if mediaStats.DTMFEventsReceived != expect.DTMFPackets {
+		t.Errorf("Медиа сессия приняла %d DTMF пакетов, ожидалось %d", mediaStats.DTMFEventsReceived, expect.DTMFPackets)
+	}
+
+	mutex.Lock()
+	if audioFrames != expect.AudioFrames {
+		t.Errorf("Декодировано аудио кадров %d, ожидалось %d", audioFrames, expect.AudioFrames)
+	}
+	if sum := audioCRC.Sum32(); sum != expect.AudioCRC32 {
+		t.Errorf("Контрольная сумма аудио %d, ожидалась %d", sum, expect.AudioCRC32)
+	}
+	if digits.String() != expect.DTMF {
+		t.Errorf("Распознаны DTMF %q, ожидалось %q", digits.String(), expect.DTMF)
+	}
+	mutex.Unlock()
+
+	if sip != nil {
+		var methods []string
+		buf := make([]byte, 4096)
+		for {
+			_ = sip.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+			n, _, err := sip.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			if method, _, ok := strings.Cut(string(buf[:n]), " "); ok && !strings.HasPrefix(method, "SIP/") {
+				methods = append(methods, method)
+			}
+		}
+		if strings.Join(methods, ",") != strings.Join(expect.SIPMethods, ",") {
+			t.Errorf("SIP запросы %v, ожидалось %v", methods, expect.SIPMethods)
+		}
+	}
+}
+
+// replayTransport - RTP транспорт поверх соединения mockTransport. Считает
+// потери по sequence number всех принятых пакетов (RFC 3550 Appendix A.3)
+type replayTransport struct {
+	conn   *mockTransport.MockPacketConn
+	remote net.Addr
+
+	mutex    sync.Mutex
+	received uint32
+	baseSeq  uint32
+	maxSeq   uint32 // Расширенный sequence number
+}
+
+// lost возвращает количество потерянных пакетов
+func (r *replayTransport) lost() uint32 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.received == 0 {
+		return 0
+	}
+	return r.maxSeq - r.baseSeq + 1 - r.received
+}
+
+// track учитывает sequence number принятого пакета
+func (r *replayTransport) track(seq uint16) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.received == 0 {
+		r.baseSeq, r.maxSeq = uint32(seq), uint32(seq)
+	} else if delta := int16(seq - uint16(r.maxSeq)); delta > 0 {
+		r.maxSeq += uint32(delta)
+	}
+	r.received++
+}
+
+func (r *replayTransport) Send(packet *rtp.Packet) error {
+	if r.remote == nil {
+		return errors.New("удаленный адрес не известен")
+	}
+	data, err := packet.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = r.conn.WriteTo(data, r.remote)
+	return err
+}
+
+func (r *replayTransport) Receive(ctx context.Context) (*rtp.Packet, net.Addr, error) {
+	buf := make([]byte, 1500)
+	for {
+		_ = r.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, addr, err := r.conn.ReadFrom(buf)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		packet := &rtp.Packet{}
+		if err := packet.Unmarshal(buf[:n]); err != nil {
+			continue
+		}
+		r.remote = addr
+		r.track(packet.SequenceNumber)
+		return packet, addr, nil
+	}
+}
+
+func (r *replayTransport) LocalAddr() net.Addr  { return r.conn.LocalAddr() }
+func (r *replayTransport) RemoteAddr() net.Addr { return r.remote }
+func (r *replayTransport) Close() error         { return r.conn.Close() }
+func (r *replayTransport) IsActive() bool       { return true }
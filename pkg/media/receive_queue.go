@@ -0,0 +1,67 @@
+package media
+
+import (
+	"context"
+)
+
+// DefaultReceiveQueueSize - размер очереди ReceiveAudio по умолчанию
+// (1 секунда аудио при ptime 20ms)
+const DefaultReceiveQueueSize = 50
+
+// receiveQueue - очередь декодированного аудио для ReceiveAudio. При
+// переполнении вытесняются самые старые пакеты: скрипт, который читает
+// медленнее реального времени, получает свежее аудио.
+type receiveQueue struct {
+	packets chan []byte
+}
+
+func newReceiveQueue(size int) *receiveQueue {
+	if size <= 0 {
+		size = DefaultReceiveQueueSize
+	}
+	return &receiveQueue{packets: make(chan []byte, size)}
+}
+
+// push добавляет пакет без блокировки пути приема
+func (q *receiveQueue) push(audio []byte) {
+	audio = append([]byte(nil), audio...)
+	for {
+		select {
+		case q.packets <- audio:
+			return
+		default:
+		}
+		select {
+		case <-q.packets:
+		default:
+		}
+	}
+}
+
+// ReceiveAudio блокирует до получения следующего пакета декодированного
+// аудио (те же данные, что и в OnAudioReceived) и возвращает его. Вариант без
+// callback для скриптов и простых утилит.
+//
+// Очередь создается первым вызовом, поэтому аудио, полученное раньше, не
+// сохраняется; Config.ReceiveQueueSize создает очередь вместе с сессией.
+// Возвращает ошибку ErrorCodeSessionClosed после Stop и ctx.Err() при отмене ctx.
+func (ms *MediaSession) ReceiveAudio(ctx context.Context) ([]byte, error) {
+	queue := ms.receiveQueue.Load()
+	if queue == nil {
+		ms.receiveQueue.CompareAndSwap(nil, newReceiveQueue(DefaultReceiveQueueSize))
+		queue = ms.receiveQueue.Load()
+	}
+
+	select {
+	case audio := <-queue.packets:
+		return audio, nil
+	case <-ms.ctx.Done():
+		return nil, &MediaError{
+			Code:      ErrorCodeSessionClosed,
+			Message:   "медиа сессия остановлена",
+			SessionID: ms.sessionID,
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -2,6 +2,7 @@ package media
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -233,6 +234,91 @@ func TestRTCPStatisticsUpdate(t *testing.T) {
 	}
 }
 
+// TestStatisticsConcurrentPolling тестирует опрос статистики во время отправки аудио
+// Проверяет:
+// - Отсутствие гонок при чтении статистики параллельно с отправкой (go test -race)
+// - Изменение возвращенной детальной статистики не влияет на RTP сессию
+func TestStatisticsConcurrentPolling(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-stats-polling"
+	config.Ptime = 10 * time.Millisecond
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания медиа сессии: %v", err)
+	}
+	defer session.Stop()
+
+	mockSession := NewMockSessionRTP("polling-session", "PCMU")
+	if err := session.AddRTPSession("polling", mockSession); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+	if err := session.EnableRTCP(true); err != nil {
+		t.Fatalf("Ошибка включения RTCP: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	// Отправка аудио
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		audio := make([]byte, 80)
+		for i := 0; i < 50; i++ {
+			_ = session.SendAudioRaw(audio)
+			time.Sleep(time.Millisecond)
+		}
+		close(stop)
+	}()
+
+	// Опрос статистики с изменением полученных результатов
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				stats := session.GetStatistics()
+				stats.AudioPacketsSent = 0
+
+				rtcpStats := session.GetRTCPStatistics()
+				rtcpStats.PacketsSent = 0
+
+				for _, detailed := range session.GetDetailedRTCPStatistics() {
+					if statsMap, ok := detailed.(map[uint32]*RTCPStatistics); ok {
+						for ssrc, stat := range statsMap {
+							stat.Jitter = 12345
+							delete(statsMap, ssrc)
+						}
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	detailed := session.GetDetailedRTCPStatistics()
+	statsMap, ok := detailed["polling"].(map[uint32]*RTCPStatistics)
+	if !ok || len(statsMap) == 0 {
+		t.Fatal("Детальная статистика RTP сессии должна сохраниться")
+	}
+	for ssrc, stat := range statsMap {
+		if stat.Jitter == 12345 {
+			t.Errorf("Изменение копии статистики не должно влиять на SSRC %d", ssrc)
+		}
+	}
+}
+
 // TestRTCPReportProcessing тестирует обработку RTCP отчетов
 func TestRTCPReportProcessing(t *testing.T) {
 	config := DefaultMediaSessionConfig()
@@ -850,3 +936,39 @@ func BenchmarkAudioProcessing(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkDTMFGeneration бенчмарк для генерации и разбора DTMF пакетов (RFC 4733)
+func BenchmarkDTMFGeneration(b *testing.B) {
+	sender := NewDTMFSender(101)
+	sender.SetSSRC(0x12345678)
+	receiver := NewDTMFReceiver(101)
+
+	event := DTMFEvent{Digit: DTMF5, Duration: 100 * time.Millisecond, Volume: -10}
+
+	b.ResetTimer()
+
+	b.Run("GeneratePackets", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			event.Timestamp = uint32(i * 800)
+			if _, err := sender.GeneratePackets(event); err != nil {
+				b.Fatalf("Ошибка генерации DTMF: %v", err)
+			}
+		}
+	})
+
+	b.Run("ProcessPacket", func(b *testing.B) {
+		packets, err := sender.GeneratePackets(event)
+		if err != nil {
+			b.Fatalf("Ошибка генерации DTMF: %v", err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, packet := range packets {
+				_, _ = receiver.ProcessPacket(packet)
+			}
+		}
+	})
+}
@@ -0,0 +1,284 @@
+package media
+
+import (
+	"context"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SchedulerConfig содержит параметры общего планировщика периодических задач
+type SchedulerConfig struct {
+	Tick      time.Duration // Разрешение колеса (по умолчанию 5ms)
+	WheelSize int           // Количество слотов колеса (по умолчанию 512)
+	Workers   int           // Количество горутин исполнения задач (по умолчанию GOMAXPROCS)
+	QueueSize int           // Размер очереди готовых к исполнению задач (по умолчанию 1024)
+}
+
+// DefaultSchedulerConfig возвращает конфигурацию планировщика по умолчанию
+func DefaultSchedulerConfig() SchedulerConfig {
+	return SchedulerConfig{
+		Tick:      5 * time.Millisecond,
+		WheelSize: 512,
+		Workers:   runtime.GOMAXPROCS(0),
+		QueueSize: 1024,
+	}
+}
+
+// SchedulerStatistics содержит счетчики работы планировщика
+type SchedulerStatistics struct {
+	Tasks      int    // Количество зарегистрированных задач
+	Executions uint64 // Количество выполненных запусков
+	Skipped    uint64 // Запуски, пропущенные из-за незавершенного предыдущего или переполненной очереди
+}
+
+// Scheduler - общий планировщик периодической работы медиа сессий на основе
+// timing wheel.
+//
+// Без планировщика каждая сессия запускает собственные горутины с тикерами:
+// отправка аудио, вывод jitter buffer, аудио процессор и RTCP. При тысячах
+// вызовов это тысячи горутин и таймеров. Планировщик обслуживает задачи всех
+// сессий одной горутиной колеса и фиксированным пулом исполнителей:
+//
+//	scheduler := media.NewScheduler(media.DefaultSchedulerConfig())
+//	defer scheduler.Stop()
+//
+//	config := media.DefaultMediaSessionConfig()
+//	config.Scheduler = scheduler
+//
+// Интервалы задач округляются до Tick. Семантика совпадает с time.Ticker: если
+// предыдущий запуск задачи еще выполняется, очередной запуск пропускается.
+type Scheduler struct {
+	tick  time.Duration
+	slots [][]*ScheduledTask
+
+	mutex   sync.Mutex
+	current uint64 // Номер последнего обработанного тика
+	tasks   int
+	stopped bool
+
+	work     chan *ScheduledTask
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+
+	executions atomic.Uint64
+	skipped    atomic.Uint64
+}
+
+// ScheduledTask - периодическая задача, зарегистрированная в Scheduler
+type ScheduledTask struct {
+	ctx      context.Context
+	fn       func()
+	interval uint64 // Интервал в тиках
+	due      uint64 // Тик следующего запуска
+
+	running   atomic.Bool
+	cancelled atomic.Bool
+}
+
+// NewScheduler создает и запускает планировщик
+func NewScheduler(config SchedulerConfig) *Scheduler {
+	defaults := DefaultSchedulerConfig()
+	if config.Tick <= 0 {
+		config.Tick = defaults.Tick
+	}
+	if config.WheelSize <= 0 {
+		config.WheelSize = defaults.WheelSize
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+
+	s := &Scheduler{
+		tick:     config.Tick,
+		slots:    make([][]*ScheduledTask, config.WheelSize),
+		work:     make(chan *ScheduledTask, config.QueueSize),
+		stopChan: make(chan struct{}),
+	}
+
+	s.wg.Add(config.Workers + 1)
+	for i := 0; i < config.Workers; i++ {
+		go s.worker()
+	}
+	go s.run()
+
+	return s
+}
+
+// Every регистрирует fn для периодического выполнения с интервалом interval.
+// Задача снимается с расписания при Cancel или отмене ctx. Метки pprof из ctx
+// устанавливаются на время выполнения fn, поэтому работа сессий атрибутируется
+// в профилях так же, как при отдельных горутинах.
+func (s *Scheduler) Every(ctx context.Context, interval time.Duration, fn func()) *ScheduledTask {
+	ticks := uint64((interval + s.tick/2) / s.tick)
+	if ticks == 0 {
+		ticks = 1
+	}
+
+	task := &ScheduledTask{
+		ctx:      ctx,
+		fn:       fn,
+		interval: ticks,
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopped {
+		task.cancelled.Store(true)
+		return task
+	}
+
+	task.due = s.current + ticks
+	s.insertLocked(task)
+	s.tasks++
+
+	return task
+}
+
+// Cancel снимает задачу с расписания. Уже начатое выполнение не прерывается.
+// Безопасно вызывать из самой задачи и повторно.
+func (t *ScheduledTask) Cancel() {
+	if t != nil {
+		t.cancelled.Store(true)
+	}
+}
+
+// active проверяет, должна ли задача выполняться
+func (t *ScheduledTask) active() bool {
+	return !t.cancelled.Load() && t.ctx.Err() == nil
+}
+
+// Stop останавливает планировщик и ждет завершения выполняющихся задач
+func (s *Scheduler) Stop() {
+	s.mutex.Lock()
+	if s.stopped {
+		s.mutex.Unlock()
+		return
+	}
+	s.stopped = true
+	close(s.stopChan)
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+}
+
+// GetStatistics возвращает статистику планировщика
+func (s *Scheduler) GetStatistics() SchedulerStatistics {
+	s.mutex.Lock()
+	tasks := s.tasks
+	s.mutex.Unlock()
+
+	return SchedulerStatistics{
+		Tasks:      tasks,
+		Executions: s.executions.Load(),
+		Skipped:    s.skipped.Load(),
+	}
+}
+
+// insertLocked помещает задачу в слот ее тика. Вызывается под mutex
+func (s *Scheduler) insertLocked(task *ScheduledTask) {
+	slot := task.due % uint64(len(s.slots))
+	s.slots[slot] = append(s.slots[slot], task)
+}
+
+// run продвигает колесо. Номер тика вычисляется от времени старта, поэтому
+// задержки горутины не накапливают дрейф: пропущенные тики обрабатываются
+// при следующем пробуждении.
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+	defer close(s.work)
+
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			s.advance(uint64(now.Sub(start) / s.tick))
+		}
+	}
+}
+
+// advance обрабатывает все тики до target включительно
+func (s *Scheduler) advance(target uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for s.current < target {
+		s.current++
+		s.processSlotLocked(s.current)
+	}
+}
+
+// processSlotLocked запускает задачи слота, срок которых наступил, и
+// переставляет их на следующий запуск
+func (s *Scheduler) processSlotLocked(tick uint64) {
+	index := tick % uint64(len(s.slots))
+	slot := s.slots[index]
+	if len(slot) == 0 {
+		return
+	}
+
+	kept := slot[:0]
+	var due []*ScheduledTask
+	for _, task := range slot {
+		switch {
+		case !task.active():
+			s.tasks--
+		case task.due > tick:
+			// Задача следующего оборота колеса
+			kept = append(kept, task)
+		default:
+			due = append(due, task)
+		}
+	}
+	for i := len(kept); i < len(slot); i++ {
+		slot[i] = nil
+	}
+	s.slots[index] = kept
+
+	for _, task := range due {
+		s.dispatch(task)
+		task.due = tick + task.interval
+		s.insertLocked(task)
+	}
+}
+
+// dispatch передает задачу исполнителям без блокировки колеса
+func (s *Scheduler) dispatch(task *ScheduledTask) {
+	if !task.running.CompareAndSwap(false, true) {
+		s.skipped.Add(1)
+		return
+	}
+
+	select {
+	case s.work <- task:
+	default:
+		task.running.Store(false)
+		s.skipped.Add(1)
+	}
+}
+
+// worker выполняет готовые задачи
+func (s *Scheduler) worker() {
+	defer s.wg.Done()
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	for task := range s.work {
+		if task.active() {
+			pprof.SetGoroutineLabels(task.ctx)
+			task.fn()
+			s.executions.Add(1)
+		}
+		task.running.Store(false)
+	}
+}
@@ -0,0 +1,181 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSchedulerPeriodicTask проверяет периодическое выполнение и отмену задачи
+func TestSchedulerPeriodicTask(t *testing.T) {
+	scheduler := NewScheduler(SchedulerConfig{Tick: time.Millisecond, WheelSize: 8})
+	defer scheduler.Stop()
+
+	var runs atomic.Int32
+	// Интервал больше размера колеса проверяет задачи следующего оборота
+	task := scheduler.Every(context.Background(), 20*time.Millisecond, func() { runs.Add(1) })
+
+	time.Sleep(110 * time.Millisecond)
+	task.Cancel()
+	count := runs.Load()
+	if count < 3 || count > 6 {
+		t.Errorf("За 110ms с интервалом 20ms ожидалось около 5 запусков, получено %d", count)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if runs.Load() != count {
+		t.Errorf("Отмененная задача не должна выполняться: %d -> %d", count, runs.Load())
+	}
+	if tasks := scheduler.GetStatistics().Tasks; tasks != 0 {
+		t.Errorf("Отмененная задача должна быть удалена из колеса, задач: %d", tasks)
+	}
+}
+
+// TestSchedulerContextCancel проверяет снятие задачи при отмене контекста
+func TestSchedulerContextCancel(t *testing.T) {
+	scheduler := NewScheduler(SchedulerConfig{Tick: time.Millisecond})
+	defer scheduler.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs atomic.Int32
+	scheduler.Every(ctx, 5*time.Millisecond, func() { runs.Add(1) })
+
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+	count := runs.Load()
+	if count == 0 {
+		t.Fatal("Задача должна выполняться до отмены контекста")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if runs.Load() != count {
+		t.Errorf("Задача не должна выполняться после отмены контекста: %d -> %d", count, runs.Load())
+	}
+}
+
+// TestSchedulerSkipsOverlappingRuns проверяет, что долгая задача не выполняется
+// параллельно сама с собой
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	scheduler := NewScheduler(SchedulerConfig{Tick: time.Millisecond, Workers: 4})
+	defer scheduler.Stop()
+
+	var running, maxRunning atomic.Int32
+	task := scheduler.Every(context.Background(), time.Millisecond, func() {
+		current := running.Add(1)
+		if current > maxRunning.Load() {
+			maxRunning.Store(current)
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	task.Cancel()
+
+	if maxRunning.Load() != 1 {
+		t.Errorf("Задача выполнялась параллельно: %d", maxRunning.Load())
+	}
+	if scheduler.GetStatistics().Skipped == 0 {
+		t.Error("Запуски во время выполнения задачи должны пропускаться")
+	}
+}
+
+// TestSchedulerStop проверяет, что остановленный планировщик не принимает задачи
+func TestSchedulerStop(t *testing.T) {
+	scheduler := NewScheduler(DefaultSchedulerConfig())
+	scheduler.Stop()
+	scheduler.Stop() // Повторная остановка безопасна
+
+	var runs atomic.Int32
+	scheduler.Every(context.Background(), time.Millisecond, func() { runs.Add(1) })
+	time.Sleep(20 * time.Millisecond)
+
+	if runs.Load() != 0 {
+		t.Error("Остановленный планировщик не должен выполнять задачи")
+	}
+}
+
+// TestSessionSharedScheduler проверяет, что сессии с общим планировщиком
+// отправляют аудио без собственных горутин отправки, аудио процессора,
+// RTCP и вывода jitter buffer
+func TestSessionSharedScheduler(t *testing.T) {
+	const sessions = 20
+
+	scheduler := NewScheduler(SchedulerConfig{Workers: 2})
+	defer scheduler.Stop()
+
+	start := func(shared bool) ([]*MediaSession, []*MockSessionRTP) {
+		var list []*MediaSession
+		var mocks []*MockSessionRTP
+		for i := 0; i < sessions; i++ {
+			config := DefaultMediaSessionConfig()
+			config.SessionID = fmt.Sprintf("test-scheduler-%t-%d", shared, i)
+			config.JitterEnabled = true
+			config.RTCPEnabled = true
+			config.RTCPInterval = 100 * time.Millisecond
+			if shared {
+				config.Scheduler = scheduler
+			}
+
+			session, err := NewSession(config)
+			if err != nil {
+				t.Fatalf("Ошибка создания сессии: %v", err)
+			}
+			mock := NewMockSessionRTP(config.SessionID, "PCMU")
+			if err := session.AddRTPSession("primary", mock); err != nil {
+				t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+			}
+			if err := session.Start(); err != nil {
+				t.Fatalf("Ошибка запуска сессии: %v", err)
+			}
+			list = append(list, session)
+			mocks = append(mocks, mock)
+		}
+		return list, mocks
+	}
+	stop := func(list []*MediaSession) {
+		for _, session := range list {
+			_ = session.Stop()
+		}
+	}
+
+	before := runtime.NumGoroutine()
+	own, _ := start(false)
+	ownGoroutines := runtime.NumGoroutine() - before
+	stop(own)
+
+	time.Sleep(20 * time.Millisecond)
+	before = runtime.NumGoroutine()
+	shared, mocks := start(true)
+	sharedGoroutines := runtime.NumGoroutine() - before
+	defer stop(shared)
+
+	if sharedGoroutines*3 > ownGoroutines {
+		t.Errorf("Общий планировщик должен сократить число горутин минимум в 3 раза: %d против %d",
+			sharedGoroutines, ownGoroutines)
+	}
+	// Число горутин не растет вместе с числом сессий
+	if sharedGoroutines >= sessions {
+		t.Errorf("С общим планировщиком сессии не должны запускать собственные горутины: %d горутин на %d сессий",
+			sharedGoroutines, sessions)
+	}
+
+	// Аудио по-прежнему отправляется с интервалом ptime
+	for _, session := range shared {
+		if err := session.SendAudio(generateTestAudioData(StandardPCMSamples20ms)); err != nil {
+			t.Fatalf("Ошибка отправки аудио: %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for _, mock := range mocks {
+		for mock.GetPacketsSent() == 0 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if mock.GetPacketsSent() == 0 {
+			t.Fatal("Аудио должно отправляться задачей планировщика")
+		}
+	}
+}
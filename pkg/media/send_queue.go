@@ -0,0 +1,104 @@
+package media
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// sendClass - класс приоритета исходящих пакетов. Меньшее значение означает
+// более высокий приоритет. Аудио не входит в очередь: оно отправляется из
+// буфера по одному пакету за тик после всех приоритетных пакетов.
+type sendClass int
+
+const (
+	sendClassControl sendClass = iota // RTCP отчеты
+	sendClassDTMF                     // Пакеты DTMF событий (RFC 4733)
+
+	sendClassCount
+)
+
+// sendItem - отложенная отправка с запланированным временем
+type sendItem struct {
+	due  time.Time
+	seq  uint64 // Порядок постановки для пакетов с одинаковым временем
+	send func()
+}
+
+// sendItemHeap упорядочивает отправки по времени, затем по порядку постановки
+type sendItemHeap []*sendItem
+
+func (h sendItemHeap) Len() int { return len(h) }
+func (h sendItemHeap) Less(i, j int) bool {
+	if h[i].due.Equal(h[j].due) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].due.Before(h[j].due)
+}
+func (h sendItemHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *sendItemHeap) Push(x interface{}) { *h = append(*h, x.(*sendItem)) }
+
+func (h *sendItemHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// sendQueue - приоритетная очередь отправки. Пакеты высшего класса, срок
+// которых наступил, выдаются раньше пакетов низшего класса независимо от
+// времени постановки.
+type sendQueue struct {
+	mutex   sync.Mutex
+	classes [sendClassCount]sendItemHeap
+	seq     uint64
+}
+
+// push ставит отправку в очередь класса на время due
+func (q *sendQueue) push(class sendClass, due time.Time, send func()) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.seq++
+	heap.Push(&q.classes[class], &sendItem{due: due, seq: q.seq, send: send})
+}
+
+// popDue возвращает отправку наивысшего класса, срок которой наступил,
+// или nil, если таких нет
+func (q *sendQueue) popDue(now time.Time) func() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for class := range q.classes {
+		items := &q.classes[class]
+		if items.Len() > 0 && !(*items)[0].due.After(now) {
+			return heap.Pop(items).(*sendItem).send
+		}
+	}
+	return nil
+}
+
+// len возвращает количество ожидающих отправок
+func (q *sendQueue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	total := 0
+	for class := range q.classes {
+		total += q.classes[class].Len()
+	}
+	return total
+}
+
+// clear удаляет все ожидающие отправки
+func (q *sendQueue) clear() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for class := range q.classes {
+		q.classes[class] = nil
+	}
+}
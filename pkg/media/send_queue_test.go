@@ -0,0 +1,184 @@
+package media
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// TestSendQueuePriority проверяет порядок выдачи: сначала класс, затем время
+func TestSendQueuePriority(t *testing.T) {
+	var queue sendQueue
+	var order []string
+
+	now := time.Now()
+	queue.push(sendClassDTMF, now.Add(-2*time.Millisecond), func() { order = append(order, "dtmf-1") })
+	queue.push(sendClassDTMF, now.Add(-2*time.Millisecond), func() { order = append(order, "dtmf-2") })
+	queue.push(sendClassControl, now, func() { order = append(order, "rtcp") })
+	queue.push(sendClassControl, now.Add(time.Second), func() { order = append(order, "rtcp-later") })
+	queue.push(sendClassDTMF, now.Add(-5*time.Millisecond), func() { order = append(order, "dtmf-0") })
+
+	for send := queue.popDue(now); send != nil; send = queue.popDue(now) {
+		send()
+	}
+
+	expected := []string{"rtcp", "dtmf-0", "dtmf-1", "dtmf-2"}
+	if len(order) != len(expected) {
+		t.Fatalf("Ожидался порядок %v, получен %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("Ожидался порядок %v, получен %v", expected, order)
+		}
+	}
+
+	if queue.len() != 1 {
+		t.Errorf("Отправка с будущим временем должна остаться в очереди, осталось %d", queue.len())
+	}
+	queue.clear()
+	if queue.len() != 0 {
+		t.Error("Очередь должна быть пуста после clear")
+	}
+}
+
+// TestDTMFNotStarvedByAudio проверяет, что DTMF пакеты уходят по расписанию,
+// когда в буфере отправки накоплено много аудио
+func TestDTMFNotStarvedByAudio(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-dtmf-priority"
+	config.DTMFEnabled = true
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	type sent struct {
+		dtmf bool
+		end  bool
+		at   time.Time
+	}
+	var mutex sync.Mutex
+	var log []sent
+
+	mock := NewMockSessionRTP("primary", "PCMU")
+	mock.SetSendAudioCallback(func([]byte, time.Duration) error {
+		mutex.Lock()
+		log = append(log, sent{at: time.Now()})
+		mutex.Unlock()
+		return nil
+	})
+	mock.SetSendPacketCallback(func(packet *rtp.Packet) error {
+		mutex.Lock()
+		log = append(log, sent{dtmf: true, end: isDTMFEndPacket(packet), at: time.Now()})
+		mutex.Unlock()
+		return nil
+	})
+	if err := session.AddRTPSession("primary", mock); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+
+	// Секунда аудио в буфере - 50 пакетов по 20ms
+	backlog := generateTestAudioData(StandardPCMSamples20ms * 50)
+	if err := session.SendAudioRaw(backlog[:StandardPCMSamples20ms]); err != nil {
+		t.Fatalf("Ошибка отправки аудио: %v", err)
+	}
+	if err := session.addToAudioBuffer(backlog[StandardPCMSamples20ms:], time.Now()); err != nil {
+		t.Fatalf("Ошибка заполнения буфера: %v", err)
+	}
+
+	sentAt := time.Now()
+	if err := session.SendDTMF(DTMF5, 100*time.Millisecond); err != nil {
+		t.Fatalf("Ошибка отправки DTMF: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var starts, ends int
+	for _, entry := range log {
+		if !entry.dtmf {
+			continue
+		}
+		delay := entry.at.Sub(sentAt)
+		if entry.end {
+			ends++
+			// Конечные пакеты уходят по окончании события, не позже следующего тика
+			if delay < 100*time.Millisecond || delay > 100*time.Millisecond+2*config.Ptime {
+				t.Errorf("Конечный DTMF пакет отправлен через %v, ожидалось около 100ms", delay)
+			}
+		} else {
+			starts++
+			if delay > 10*time.Millisecond {
+				t.Errorf("Начальный DTMF пакет задержан на %v", delay)
+			}
+		}
+	}
+	if starts != 3 || ends != 3 {
+		t.Errorf("Ожидалось 3 начальных и 3 конечных DTMF пакета, получено %d и %d", starts, ends)
+	}
+
+	if remaining := session.GetBufferedAudioSize(); remaining == 0 {
+		t.Error("Аудио должно оставаться в буфере: тест проверяет отправку при накопленном аудио")
+	}
+}
+
+// TestDTMFEventsDoNotOverlap проверяет, что следующее DTMF событие
+// начинается после окончания предыдущего
+func TestDTMFEventsDoNotOverlap(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-dtmf-sequence"
+	config.DTMFEnabled = true
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	var mutex sync.Mutex
+	var digits []uint8
+	mock := NewMockSessionRTP("primary", "PCMU")
+	mock.SetSendPacketCallback(func(packet *rtp.Packet) error {
+		mutex.Lock()
+		digits = append(digits, packet.Payload[0])
+		mutex.Unlock()
+		return nil
+	})
+	if err := session.AddRTPSession("primary", mock); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+
+	for _, digit := range []DTMFDigit{DTMF1, DTMF2} {
+		if err := session.SendDTMF(digit, 60*time.Millisecond); err != nil {
+			t.Fatalf("Ошибка отправки DTMF: %v", err)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(digits) != 12 {
+		t.Fatalf("Ожидалось 12 DTMF пакетов, получено %d", len(digits))
+	}
+	for i, digit := range digits {
+		expected := uint8(DTMF1)
+		if i >= 6 {
+			expected = uint8(DTMF2)
+		}
+		if digit != expected {
+			t.Fatalf("Пакеты событий перемешаны: %v", digits)
+		}
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/arzzra/soft_phone/pkg/profiling"
 	rtpPkg "github.com/arzzra/soft_phone/pkg/rtp"
 	"github.com/pion/rtp"
 )
@@ -44,6 +46,17 @@ const (
 	DefaultDTMFDuration = 100 * time.Millisecond // Стандартная длительность DTMF
 	DTMFVolumeMaxDbm    = 63                     // Максимальная громкость DTMF в -dBm
 	DTMFPayloadTypeRFC  = 101                    // Стандартный payload type для DTMF согласно RFC 4733
+
+	// Допустимый диапазон ptime удаленной стороны
+	minRemotePtime = 5 * time.Millisecond
+	maxRemotePtime = 200 * time.Millisecond
+
+	// Период обновления статистики аудио процессора
+	audioProcessorInterval = 10 * time.Millisecond
+
+	// Размеры кадров G.729: речевой кадр 10ms и SID кадр Annex B
+	g729FrameSize    = 10
+	g729SIDFrameSize = 2
 )
 
 // Константы payload типов из RFC 3551
@@ -82,6 +95,39 @@ func (d Direction) String() string {
 	}
 }
 
+// canSend сообщает, разрешает ли направление отправку медиа
+func (d Direction) canSend() bool {
+	return d == DirectionSendRecv || d == DirectionSendOnly
+}
+
+// canReceive сообщает, разрешает ли направление прием медиа
+func (d Direction) canReceive() bool {
+	return d == DirectionSendRecv || d == DirectionRecvOnly
+}
+
+// SilenceSuppression - согласованное подавление пауз (VAD/CNG), атрибут
+// SDP a=silenceSupp (RFC 3108 Section 5.6)
+type SilenceSuppression int
+
+const (
+	SilenceSuppressionDefault SilenceSuppression = iota // Не согласовано, решает кодек (fmtp annexb)
+	SilenceSuppressionOn                                // a=silenceSupp:on
+	SilenceSuppressionOff                               // a=silenceSupp:off, VAD/CNG отключены
+)
+
+func (s SilenceSuppression) String() string {
+	switch s {
+	case SilenceSuppressionDefault:
+		return "default"
+	case SilenceSuppressionOn:
+		return "on"
+	case SilenceSuppressionOff:
+		return "off"
+	default:
+		return "unknown"
+	}
+}
+
 // SessionState представляет текущее состояние медиа сессии.
 // Сессия проходит через различные состояния в течение своего жизненного цикла.
 type SessionState int
@@ -145,22 +191,55 @@ func (s SessionState) String() string {
 type MediaSession struct {
 	// Основные параметры
 	sessionID   string
-	direction   Direction
 	ptime       time.Duration // Packet time (длительность одного пакета)
 	payloadType PayloadType
+	channels    int // Количество каналов аудио
 
 	// RTP сессии (может быть несколько для разных кодеков)
 	rtpSessions   map[string]SessionRTP
 	sessionsMutex sync.RWMutex
 
 	// Управление RTP потоком и timing
-	audioBuffer      []byte        // Буфер накопления аудио данных
-	bufferMutex      sync.Mutex    // Защита буфера
-	lastSendTime     time.Time     // Время последней отправки
-	sendTicker       *time.Ticker  // Тикер для регулярной отправки
-	packetDuration   time.Duration // Длительность одного пакета (равна ptime)
-	samplesPerPacket int           // Количество samples на пакет
-	stopChan         chan struct{} // Канал для остановки
+	audioBuffer      []byte         // Буфер накопления аудио данных
+	codecPackets     [][]byte       // Пакеты подключаемого кодека: размер payload может меняться
+	codecEnabled     atomic.Bool    // Используется подключаемый кодек
+	sampleSize       atomic.Int32   // Размер отсчета кодека без кадров (SampleCodec), 0 - payload не делится
+	maxPayloadSize   int            // Максимальный размер RTP payload для кодеков без кадров
+	bufferMutex      sync.Mutex     // Защита буфера
+	lastSendTime     time.Time      // Время последней отправки
+	sendTicker       *time.Ticker   // Тикер для регулярной отправки
+	sendTask         *ScheduledTask // Задача отправки в общем планировщике
+	packetDuration   time.Duration  // Длительность одного пакета (равна ptime)
+	samplesPerPacket int            // Количество samples на пакет
+	stopChan         chan struct{}  // Канал для остановки
+
+	// Приоритетная отправка: DTMF и RTCP уходят раньше накопленного аудио.
+	// sendMutex сериализует путь отправки, чтобы приоритетные пакеты
+	// ожидали не дольше одного аудио пакета
+	sendQueue     sendQueue
+	sendMutex     sync.Mutex
+	dtmfBusyUntil time.Time // Окончание последнего запланированного DTMF события (под sendMutex)
+
+	// Частоты RTP clock из согласованных rtpmap
+	clockRates      map[PayloadType]uint32
+	clockRatesMutex sync.RWMutex
+
+	// SID кадры G.729 Annex B отбрасываются (согласовано annexb=no)
+	vadFramesDisabled atomic.Bool
+	// Согласованное подавление пауз (a=silenceSupp)
+	silenceSuppression atomic.Int32
+
+	// Направление медиа потока (Direction). Атомарно: цикл отправки читает
+	// его без stateMutex, который Stop удерживает до завершения горутин
+	direction atomic.Int32
+
+	// Параметры приема: ptime удаленной стороны может отличаться от нашего
+	remotePtime time.Duration // Наблюдаемая длительность входящих пакетов
+	rxSSRC      uint32        // SSRC последнего входящего аудио пакета
+	rxSeq       uint16        // Sequence number последнего входящего аудио пакета
+	rxTimestamp uint32        // RTP timestamp последнего входящего аудио пакета
+	rxTracking  bool          // Получен хотя бы один аудио пакет
+	rxMutex     sync.Mutex    // Защита параметров приема
 
 	// Состояние
 	state      SessionState
@@ -169,6 +248,7 @@ type MediaSession struct {
 	// Jitter buffer
 	jitterBuffer  *JitterBuffer
 	jitterEnabled bool
+	jitterTask    *ScheduledTask // Вывод jitter buffer в общем планировщике
 
 	// DTMF поддержка
 	dtmfSender   *DTMFSender
@@ -177,12 +257,17 @@ type MediaSession struct {
 
 	// Аудио обработка
 	audioProcessor *AudioProcessor
+	processorTask  *ScheduledTask
+
+	// Общий планировщик периодической работы (опционально)
+	scheduler *Scheduler
 
 	// Обработчики событий
 	callbacksMutex      sync.RWMutex                                     // Защита callback'ов от race conditions
 	onAudioReceived     func([]byte, PayloadType, time.Duration, string) // Callback для обработанных аудио данных (после аудио процессора)
 	onRawAudioReceived  func([]byte, PayloadType, time.Duration, string) // Callback для сырых аудио данных (payload без обработки)
 	onRawPacketReceived func(*rtp.Packet, string)                        // Callback для сырых RTP пакетов (весь пакет)
+	onAudioFrame        func(AudioFrame)                                 // Callback для аудио кадров с метаданными RTP
 	onDTMFReceived      func(DTMFEvent, string)                          // Callback для DTMF событий
 	onMediaError        func(error, string)                              // Callback для ошибок
 
@@ -202,6 +287,31 @@ type MediaSession struct {
 	rtcpHandler    func(RTCPReport)
 	rtcpInterval   time.Duration
 	lastRTCPSent   time.Time
+	rtcpTask       *ScheduledTask
+
+	// Аудио аналитика, включается AnalyticsStream
+	analytics atomic.Pointer[analyticsMeter]
+
+	// Передача аудио на распознавание, включается StartTranscription
+	transcription atomic.Pointer[TranscriptionTap]
+
+	// Контроль сроков отправки и деградации, Config.Degradation
+	degradation *degradationController
+
+	// Учет ресурсов tenant (Config.Resources)
+	tenant             string
+	resources          *TenantResources
+	reservedAudio      int // Зарезервировано байт буфера отправки, под bufferMutex
+	reservedGoroutines int // Зарезервировано горутин, под stateMutex
+
+	// Измерение задержки аудио тракта, включается EnableLatencyMeasurement
+	latency atomic.Pointer[latencyMeter]
+
+	// Паника в пользовательских callback не перехватывается (Config.RepanicCallbacks)
+	repanicCallbacks bool
+
+	// Очередь декодированного аудио для ReceiveAudio
+	receiveQueue atomic.Pointer[receiveQueue]
 }
 
 // Config содержит параметры конфигурации для создания MediaSession.
@@ -234,9 +344,35 @@ type MediaSession struct {
 //	}
 type Config struct {
 	SessionID   string
+	CallID      string // Call-ID SIP диалога для меток профилирования (опционально)
 	Direction   Direction
 	Ptime       time.Duration // Packet time (по умолчанию 20ms)
 	PayloadType PayloadType   // Основной payload type
+	Channels    int           // Количество каналов (по умолчанию 1), аудио передается чередующимися отсчетами
+
+	// Codec - подключаемый кодек из реестра (см. NewCodec) для payload types,
+	// не поддерживаемых встроенным аудио процессором, например AMR с
+	// динамическим payload type. Каждый вызов SendAudio дает один RTP пакет
+	Codec Codec
+
+	// ClockRates - частоты RTP clock из согласованных rtpmap. Используются для
+	// динамических payload types (96-127) и telephone-event с частотой,
+	// отличной от 8000. Для остальных используются значения RFC 3551
+	ClockRates map[PayloadType]uint32
+
+	// DisableVADFrames отключает SID кадры G.729 Annex B (VAD/CNG) при
+	// отправке и приеме. Устанавливается при согласовании fmtp annexb=no
+	DisableVADFrames bool
+
+	// SilenceSuppression - подавление пауз, согласованное атрибутом
+	// a=silenceSupp. SilenceSuppressionOff отключает VAD/CNG независимо
+	// от DisableVADFrames
+	SilenceSuppression SilenceSuppression
+
+	// MaxPayloadSize - максимальный размер RTP payload. Payload кодеков без
+	// кадров (L16) большего размера делится на несколько RTP пакетов.
+	// 0 - DefaultMaxPayloadSize
+	MaxPayloadSize int
 
 	// Jitter buffer настройки
 	JitterEnabled    bool
@@ -251,6 +387,7 @@ type Config struct {
 	OnAudioReceived     func([]byte, PayloadType, time.Duration, string) // Callback для обработанных аудио данных (после аудио процессора)
 	OnRawAudioReceived  func([]byte, PayloadType, time.Duration, string) // Callback для сырых аудио данных (payload без обработки)
 	OnRawPacketReceived func(*rtp.Packet, string)                        // Callback для сырых RTP пакетов (весь пакет без декодирования)
+	OnAudioFrame        func(AudioFrame)                                 // Callback для аудио кадров с timestamp, seq, SSRC и временем получения
 	OnDTMFReceived      func(DTMFEvent, string)                          // Callback для DTMF событий
 	OnMediaError        func(error, string)                              // Callback для ошибок
 
@@ -258,6 +395,34 @@ type Config struct {
 	RTCPEnabled  bool
 	RTCPInterval time.Duration    // Интервал отправки RTCP отчетов (по умолчанию 5 секунд)
 	OnRTCPReport func(RTCPReport) // Callback для обработки RTCP отчетов
+
+	// Scheduler - общий планировщик периодической работы (опционально).
+	// Если задан, отправка аудио, вывод jitter buffer, аудио процессор и RTCP
+	// выполняются планировщиком вместо отдельных горутин сессии.
+	Scheduler *Scheduler
+
+	// MeasureLatency включает измерение задержки аудио тракта по этапам
+	// (см. GetLatencyReport)
+	MeasureLatency bool
+
+	// RepanicCallbacks повторно возбуждает панику из пользовательских
+	// callback вместо передачи CallbackPanicError в OnMediaError. Только
+	// для разработки: паника остановит горутину приема или отправки
+	RepanicCallbacks bool
+
+	// ReceiveQueueSize создает очередь ReceiveAudio вместе с сессией, чтобы
+	// не терять аудио до первого вызова. 0 - очередь создается первым вызовом
+	ReceiveQueueSize int
+
+	// Degradation включает отключение необязательной обработки при пропуске
+	// сроков отправки под нагрузкой на CPU (опционально)
+	Degradation *DegradationConfig
+
+	// Tenant - tenant вызова для учета ресурсов в Resources
+	Tenant string
+	// Resources - общий учет ресурсов по tenant (опционально). Горутины
+	// сессии и буфер отправки проверяются по лимитам Tenant
+	Resources *TenantResources
 }
 
 // Statistics содержит статистику работы медиа сессии.
@@ -320,7 +485,7 @@ func NewSession(config Config) (*MediaSession, error) {
 	}
 
 	// Проверяем поддерживается ли payload type
-	if !isSupportedPayloadType(config.PayloadType) {
+	if !isSupportedPayloadType(config.PayloadType) && config.Codec == nil {
 		return nil, &MediaError{
 			Code:      ErrorCodePayloadTypeUnsupported,
 			Message:   fmt.Sprintf("неподдерживаемый payload type: %d", config.PayloadType),
@@ -335,11 +500,18 @@ func NewSession(config Config) (*MediaSession, error) {
 	if config.Ptime == 0 {
 		config.Ptime = time.Millisecond * 20
 	}
+	if config.MaxPayloadSize <= 0 {
+		config.MaxPayloadSize = DefaultMaxPayloadSize
+	}
+	if config.Channels <= 0 {
+		config.Channels = 1
+	}
 	if config.RTCPInterval == 0 {
 		config.RTCPInterval = time.Second * 5 // Стандартный интервал согласно RFC 3550
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// Горутины сессии помечаются session_id и call_id для атрибуции в профилях pprof
+	ctx, cancel := context.WithCancel(profiling.WithLabels(context.Background(), config.SessionID, config.CallID))
 
 	// Вычисляем параметры для RTP потока
 	sampleRate := getSampleRateForPayloadType(config.PayloadType)
@@ -347,15 +519,17 @@ func NewSession(config Config) (*MediaSession, error) {
 
 	session := &MediaSession{
 		sessionID:        config.SessionID,
-		direction:        config.Direction,
 		ptime:            config.Ptime,
 		payloadType:      config.PayloadType,
+		channels:         config.Channels,
 		rtpSessions:      make(map[string]SessionRTP),
 		state:            MediaStateIdle,
 		jitterEnabled:    config.JitterEnabled,
 		dtmfEnabled:      config.DTMFEnabled,
 		packetDuration:   config.Ptime,
+		remotePtime:      config.Ptime,
 		samplesPerPacket: samplesPerPacket,
+		maxPayloadSize:   config.MaxPayloadSize,
 		audioBuffer:      make([]byte, 0, samplesPerPacket*4), // Буфер с запасом
 		stopChan:         make(chan struct{}),
 		ctx:              ctx,
@@ -365,6 +539,7 @@ func NewSession(config Config) (*MediaSession, error) {
 		onAudioReceived:     config.OnAudioReceived,
 		onRawAudioReceived:  config.OnRawAudioReceived,
 		onRawPacketReceived: config.OnRawPacketReceived,
+		onAudioFrame:        config.OnAudioFrame,
 		onDTMFReceived:      config.OnDTMFReceived,
 		onMediaError:        config.OnMediaError,
 
@@ -372,6 +547,12 @@ func NewSession(config Config) (*MediaSession, error) {
 		rtcpEnabled:  config.RTCPEnabled,
 		rtcpHandler:  config.OnRTCPReport,
 		rtcpInterval: config.RTCPInterval,
+
+		scheduler: config.Scheduler,
+
+		clockRates: copyClockRates(config.ClockRates),
+
+		repanicCallbacks: config.RepanicCallbacks,
 	}
 
 	// Создаем jitter buffer если включен
@@ -383,7 +564,7 @@ func NewSession(config Config) (*MediaSession, error) {
 		}
 
 		var err error
-		session.jitterBuffer, err = NewJitterBuffer(jitterConfig)
+		session.jitterBuffer, err = newJitterBuffer(ctx, jitterConfig, session.scheduler)
 		if err != nil {
 			cancel()
 			return nil, WrapMediaError(ErrorCodeJitterBufferConfigInvalid, config.SessionID, "ошибка создания jitter buffer", err)
@@ -396,10 +577,15 @@ func NewSession(config Config) (*MediaSession, error) {
 		session.dtmfReceiver = NewDTMFReceiver(config.DTMFPayloadType)
 
 		// Устанавливаем callback для DTMF receiver (безопасно в конструкторе)
-		if session.dtmfReceiver != nil && config.OnDTMFReceived != nil {
+		if session.dtmfReceiver != nil {
 			// Создаем обертку для вызова с пустым rtpSessionID для обратной совместимости
 			session.dtmfReceiver.SetCallback(func(event DTMFEvent) {
-				config.OnDTMFReceived(event, "")
+				session.observeReceivedDTMF(event.Digit)
+				if config.OnDTMFReceived != nil {
+					session.invokeCallback("OnDTMFReceived", "", func() {
+						config.OnDTMFReceived(event, "")
+					})
+				}
 			})
 		}
 	}
@@ -409,11 +595,121 @@ func NewSession(config Config) (*MediaSession, error) {
 		PayloadType: config.PayloadType,
 		Ptime:       config.Ptime,
 		SampleRate:  getSampleRateForPayloadType(config.PayloadType),
+		Channels:    config.Channels,
+		Codec:       config.Codec,
 	})
+	session.codecEnabled.Store(config.Codec != nil)
+	session.sampleSize.Store(codecSampleSize(config.Codec))
+
+	session.applyClockRates()
+
+	if config.ReceiveQueueSize > 0 {
+		session.receiveQueue.Store(newReceiveQueue(config.ReceiveQueueSize))
+	}
+	session.vadFramesDisabled.Store(config.DisableVADFrames)
+	session.silenceSuppression.Store(int32(config.SilenceSuppression))
+	session.direction.Store(int32(config.Direction))
+	session.EnableLatencyMeasurement(config.MeasureLatency)
+	if config.Degradation != nil {
+		session.degradation = newDegradationController(*config.Degradation)
+	}
+	session.tenant = config.Tenant
+	session.resources = config.Resources
 
 	return session, nil
 }
 
+// SetVADFramesDisabled включает/отключает отбрасывание SID кадров G.729
+// Annex B, например после согласования fmtp annexb=no в SDP answer
+func (ms *MediaSession) SetVADFramesDisabled(disabled bool) {
+	ms.vadFramesDisabled.Store(disabled)
+}
+
+// IsVADFramesDisabled возвращает, отбрасываются ли SID кадры G.729 Annex B:
+// по fmtp annexb=no или по a=silenceSupp:off
+func (ms *MediaSession) IsVADFramesDisabled() bool {
+	return ms.vadFramesDisabled.Load() || ms.GetSilenceSuppression() == SilenceSuppressionOff
+}
+
+// SetSilenceSuppression устанавливает подавление пауз, согласованное
+// атрибутом a=silenceSupp, например после обработки SDP answer
+func (ms *MediaSession) SetSilenceSuppression(mode SilenceSuppression) {
+	ms.silenceSuppression.Store(int32(mode))
+}
+
+// GetSilenceSuppression возвращает согласованное подавление пауз
+func (ms *MediaSession) GetSilenceSuppression() SilenceSuppression {
+	return SilenceSuppression(ms.silenceSuppression.Load())
+}
+
+// stripVADFrames удаляет SID кадр G.729 Annex B из payload, если Annex B
+// не согласован. SID кадр (2 байта) может быть только последним кадром
+// пакета после 10-байтовых речевых кадров (RFC 3551 Section 4.5.6)
+func (ms *MediaSession) stripVADFrames(payload []byte) []byte {
+	if ms.payloadType != PayloadTypeG729 || !ms.IsVADFramesDisabled() {
+		return payload
+	}
+	if len(payload)%g729FrameSize == g729SIDFrameSize {
+		return payload[:len(payload)-g729SIDFrameSize]
+	}
+	return payload
+}
+
+// SetClockRates заменяет таблицу частот RTP clock, например после обработки
+// SDP answer или re-INVITE. Новые частоты применяются к jitter buffer и DTMF.
+func (ms *MediaSession) SetClockRates(rates map[PayloadType]uint32) {
+	ms.clockRatesMutex.Lock()
+	ms.clockRates = copyClockRates(rates)
+	ms.clockRatesMutex.Unlock()
+
+	ms.applyClockRates()
+}
+
+// GetClockRate возвращает частоту RTP clock payload типа: из согласованных
+// rtpmap, а при их отсутствии - по RFC 3551
+func (ms *MediaSession) GetClockRate(pt PayloadType) uint32 {
+	ms.clockRatesMutex.RLock()
+	rate, ok := ms.clockRates[pt]
+	ms.clockRatesMutex.RUnlock()
+
+	if ok && rate > 0 {
+		return rate
+	}
+	if ms.dtmfSender != nil && uint8(pt) == ms.dtmfSender.payloadType {
+		return DTMFClockRate
+	}
+	return getRTPClockRateForPayloadType(pt)
+}
+
+// applyClockRates передает частоты RTP clock jitter buffer и DTMF компонентам
+func (ms *MediaSession) applyClockRates() {
+	ms.stateMutex.RLock()
+	jitterBuffer := ms.jitterBuffer
+	ms.stateMutex.RUnlock()
+	if jitterBuffer != nil {
+		jitterBuffer.SetClockRate(ms.GetClockRate(ms.GetPayloadType()))
+	}
+
+	if ms.dtmfSender != nil {
+		ms.dtmfSender.SetClockRate(ms.GetClockRate(PayloadType(ms.dtmfSender.payloadType)))
+	}
+	if ms.dtmfReceiver != nil {
+		ms.dtmfReceiver.SetClockRate(ms.GetClockRate(PayloadType(ms.dtmfReceiver.payloadType)))
+	}
+}
+
+// copyClockRates копирует таблицу частот, чтобы вызывающий код мог ее изменять
+func copyClockRates(rates map[PayloadType]uint32) map[PayloadType]uint32 {
+	if len(rates) == 0 {
+		return nil
+	}
+	copied := make(map[PayloadType]uint32, len(rates))
+	for pt, rate := range rates {
+		copied[pt] = rate
+	}
+	return copied
+}
+
 // AddRTPSession добавляет RTP сессию к медиа сессии.
 // Каждая MediaSession может управлять несколькими RTP сессиями одновременно.
 // Это позволяет реализовать резервные каналы или использовать разные кодеки.
@@ -509,38 +805,62 @@ func (ms *MediaSession) Start() error {
 		}
 	}
 
+	// Резервируем горутины сессии в бюджете tenant
+	goroutines := ms.sessionGoroutines()
+	if err := ms.reserveGoroutines(goroutines); err != nil {
+		return err
+	}
+	ms.reservedGoroutines = goroutines
+
 	// Инициализируем timing для RTP потока
 	ms.lastSendTime = time.Now()
 
-	// Создаем тикер для регулярной отправки пакетов
-	if ms.canSend() {
+	// Создаем тикер для регулярной отправки пакетов. Тикер запускается и в
+	// режимах без отправки (inactive, recvonly): смена направления на
+	// sendrecv начинает поток со следующего тика, без перезапуска сессии
+	if ms.scheduler != nil {
+		ms.sendTask = ms.scheduler.Every(ms.ctx, ms.packetDuration, ms.sendBufferedAudio)
+	} else {
 		ms.sendTicker = time.NewTicker(ms.packetDuration)
 		ms.wg.Add(1)
-		go ms.audioSendLoop()
+		sendTicker := ms.sendTicker
+		profiling.Go(ms.ctx, func() { ms.audioSendLoop(sendTicker) })
 	}
 
 	ms.state = MediaStateActive
 
 	// Запускаем jitter buffer если включен
 	if ms.jitterEnabled && ms.jitterBuffer != nil {
-		ms.wg.Add(1)
-		go ms.jitterBufferLoop()
+		if ms.scheduler != nil {
+			jitterBuffer := ms.jitterBuffer
+			ms.jitterTask = ms.scheduler.Every(ms.ctx, outputInterval, func() { ms.drainJitterBuffer(jitterBuffer) })
+		} else {
+			jitterBuffer := ms.jitterBuffer
+			ms.wg.Add(1)
+			profiling.Go(ms.ctx, func() { ms.jitterBufferLoop(jitterBuffer) })
+		}
 	}
 
 	// Запускаем аудио процессор
-	ms.wg.Add(1)
-	go ms.audioProcessorLoop()
-
-	// Запускаем RTCP цикл если включен (избегаем deadlock)
-	ms.rtcpStatsMutex.RLock()
-	rtcpEnabled := ms.rtcpEnabled
-	ms.rtcpStatsMutex.RUnlock()
-	if rtcpEnabled {
+	if ms.scheduler != nil {
+		if ms.audioProcessor != nil {
+			ms.processorTask = ms.scheduler.Every(ms.ctx, audioProcessorInterval, ms.updateAudioProcessorStats)
+		}
+	} else {
 		ms.wg.Add(1)
-		go ms.rtcpSendLoop()
+		profiling.Go(ms.ctx, ms.audioProcessorLoop)
 	}
 
-	// Запускаем все RTP сессии
+	// Запускаем RTCP цикл если включен
+	ms.rtcpStatsMutex.Lock()
+	if ms.rtcpEnabled {
+		ms.startRTCPLocked()
+	}
+	ms.rtcpStatsMutex.Unlock()
+
+	// Запускаем все RTP сессии. Их горутины наследуют метки профилирования
+	reset := profiling.Apply(ms.ctx)
+	defer reset()
 	ms.sessionsMutex.RLock()
 	for _, rtpSession := range ms.rtpSessions {
 		if err := rtpSession.Start(); err != nil {
@@ -570,6 +890,11 @@ func (ms *MediaSession) Stop() error {
 		ms.sendTicker = nil
 	}
 
+	// Задачи планировщика. RTCP задача снимается с расписания отменой ctx
+	ms.sendTask.Cancel()
+	ms.processorTask.Cancel()
+	ms.jitterTask.Cancel()
+
 	// Закрываем канал остановки
 	close(ms.stopChan)
 
@@ -580,10 +905,13 @@ func (ms *MediaSession) Stop() error {
 		ms.jitterBuffer = nil
 	}
 
-	// Очищаем буфер
+	// Очищаем буфер и очередь приоритетной отправки
 	ms.bufferMutex.Lock()
 	ms.audioBuffer = ms.audioBuffer[:0]
+	ms.codecPackets = nil
+	ms.releaseAudioLocked()
 	ms.bufferMutex.Unlock()
+	ms.sendQueue.clear()
 
 	// Останавливаем все RTP сессии
 	ms.sessionsMutex.Lock()
@@ -594,6 +922,8 @@ func (ms *MediaSession) Stop() error {
 
 	// Ждем завершения всех горутин
 	ms.wg.Wait()
+	ms.releaseGoroutines(ms.reservedGoroutines)
+	ms.reservedGoroutines = 0
 
 	return nil
 }
@@ -604,10 +934,10 @@ func (ms *MediaSession) SendAudio(audioData []byte) error {
 	if !ms.canSend() {
 		return &MediaError{
 			Code:      ErrorCodeSessionInvalidDirection,
-			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.direction),
+			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.GetDirection()),
 			SessionID: ms.sessionID,
 			Context: map[string]interface{}{
-				"direction": ms.direction,
+				"direction": ms.GetDirection(),
 			},
 		}
 	}
@@ -625,13 +955,19 @@ func (ms *MediaSession) SendAudio(audioData []byte) error {
 	}
 
 	// Обрабатываем аудио через процессор
+	captured := ms.latency.Load().now()
 	processedData, err := ms.audioProcessor.ProcessOutgoing(audioData)
 	if err != nil {
 		return WrapMediaError(ErrorCodeAudioProcessingFailed, ms.sessionID, "ошибка обработки аудио", err)
 	}
 
+	// Пакет подключаемого кодека отправляется целиком
+	if ms.codecEnabled.Load() {
+		return ms.addCodecPacket(processedData, captured)
+	}
+
 	// Добавляем в буфер для отправки с правильным timing
-	return ms.addToAudioBuffer(processedData)
+	return ms.addToAudioBuffer(processedData, captured)
 }
 
 // SendAudioRaw отправляет уже закодированные аудио данные без обработки.
@@ -661,10 +997,10 @@ func (ms *MediaSession) SendAudioRaw(encodedData []byte) error {
 	if !ms.canSend() {
 		return &MediaError{
 			Code:      ErrorCodeSessionInvalidDirection,
-			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.direction),
+			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.GetDirection()),
 			SessionID: ms.sessionID,
 			Context: map[string]interface{}{
-				"direction": ms.direction,
+				"direction": ms.GetDirection(),
 			},
 		}
 	}
@@ -681,6 +1017,12 @@ func (ms *MediaSession) SendAudioRaw(encodedData []byte) error {
 		}
 	}
 
+	// Размер payload подключаемого кодека не фиксирован
+	captured := ms.latency.Load().now()
+	if ms.codecEnabled.Load() {
+		return ms.addCodecPacket(encodedData, captured)
+	}
+
 	// Проверяем размер данных для заданного payload типа и ptime
 	expectedSize := ms.GetExpectedPayloadSize()
 	if len(encodedData) != expectedSize {
@@ -691,7 +1033,7 @@ func (ms *MediaSession) SendAudioRaw(encodedData []byte) error {
 	}
 
 	// Добавляем в буфер для отправки с правильным timing
-	return ms.addToAudioBuffer(encodedData)
+	return ms.addToAudioBuffer(encodedData, captured)
 }
 
 // SendAudioWithFormat отправляет аудио данные в указанном payload type.
@@ -713,10 +1055,10 @@ func (ms *MediaSession) SendAudioWithFormat(audioData []byte, payloadType Payloa
 	if !ms.canSend() {
 		return &MediaError{
 			Code:      ErrorCodeSessionInvalidDirection,
-			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.direction),
+			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.GetDirection()),
 			SessionID: ms.sessionID,
 			Context: map[string]interface{}{
-				"direction": ms.direction,
+				"direction": ms.GetDirection(),
 			},
 		}
 	}
@@ -735,6 +1077,7 @@ func (ms *MediaSession) SendAudioWithFormat(audioData []byte, payloadType Payloa
 
 	var finalData []byte
 	var err error
+	captured := ms.latency.Load().now()
 
 	if skipProcessing {
 		// Отправляем данные как есть, без обработки
@@ -757,7 +1100,7 @@ func (ms *MediaSession) SendAudioWithFormat(audioData []byte, payloadType Payloa
 	}
 
 	// Добавляем в буфер для отправки с правильным timing
-	return ms.addToAudioBuffer(finalData)
+	return ms.addToAudioBuffer(finalData, captured)
 }
 
 // WriteAudioDirect напрямую записывает аудио данные во все RTP сессии.
@@ -782,10 +1125,10 @@ func (ms *MediaSession) WriteAudioDirect(rtpPayload []byte) error {
 	if !ms.canSend() {
 		return &MediaError{
 			Code:      ErrorCodeSessionInvalidDirection,
-			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.direction),
+			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.GetDirection()),
 			SessionID: ms.sessionID,
 			Context: map[string]interface{}{
-				"direction": ms.direction,
+				"direction": ms.GetDirection(),
 			},
 		}
 	}
@@ -817,6 +1160,7 @@ func (ms *MediaSession) WriteAudioDirect(rtpPayload []byte) error {
 
 	// Обновляем статистику
 	ms.updateSendStats(len(rtpPayload))
+	ms.observeSentAudio(rtpPayload)
 
 	return nil
 }
@@ -826,10 +1170,10 @@ func (ms *MediaSession) SendDTMF(digit DTMFDigit, duration time.Duration) error
 	if !ms.canSend() {
 		return &MediaError{
 			Code:      ErrorCodeSessionInvalidDirection,
-			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.direction),
+			Message:   fmt.Sprintf("отправка запрещена в режиме %s", ms.GetDirection()),
 			SessionID: ms.sessionID,
 			Context: map[string]interface{}{
-				"direction": ms.direction,
+				"direction": ms.GetDirection(),
 			},
 		}
 	}
@@ -865,19 +1209,27 @@ func (ms *MediaSession) SendDTMF(digit DTMFDigit, duration time.Duration) error
 		return WrapMediaError(ErrorCodeDTMFSendFailed, ms.sessionID, "ошибка генерации DTMF", err)
 	}
 
-	// Отправляем через все RTP сессии
-	ms.sessionsMutex.RLock()
-	defer ms.sessionsMutex.RUnlock()
+	// Планируем пакеты в приоритетной очереди: начальные пакеты уходят сразу,
+	// конечные (с EndFlag) - по окончании события. События не перекрываются:
+	// следующее начинается после окончания предыдущего
+	ms.sendMutex.Lock()
+	start := time.Now()
+	if ms.dtmfBusyUntil.After(start) {
+		start = ms.dtmfBusyUntil
+	}
+	end := start.Add(duration)
+	ms.dtmfBusyUntil = end
+	ms.sendMutex.Unlock()
 
-	for _, rtpSession := range ms.rtpSessions {
-		for _, packet := range packets {
-			err := rtpSession.SendPacket(packet)
-			if err != nil {
-				ms.handleError(fmt.Errorf("ошибка отправки DTMF: %w", err))
-				continue
-			}
+	for _, packet := range packets {
+		due := start
+		if isDTMFEndPacket(packet) {
+			due = end
 		}
+		packet := packet
+		ms.sendQueue.push(sendClassDTMF, due, func() { ms.sendDTMFPacket(packet) })
 	}
+	ms.drainSendQueue()
 
 	// Обновляем статистику
 	ms.updateDTMFSendStats()
@@ -885,6 +1237,41 @@ func (ms *MediaSession) SendDTMF(digit DTMFDigit, duration time.Duration) error
 	return nil
 }
 
+// isDTMFEndPacket проверяет флаг окончания события в payload RFC 4733
+func isDTMFEndPacket(packet *rtp.Packet) bool {
+	return len(packet.Payload) > 1 && packet.Payload[1]&0x80 != 0
+}
+
+// sendDTMFPacket отправляет DTMF пакет через все RTP сессии
+func (ms *MediaSession) sendDTMFPacket(packet *rtp.Packet) {
+	ms.sessionsMutex.RLock()
+	defer ms.sessionsMutex.RUnlock()
+
+	for _, rtpSession := range ms.rtpSessions {
+		if err := rtpSession.SendPacket(packet); err != nil {
+			ms.handleError(fmt.Errorf("ошибка отправки DTMF: %w", err))
+		}
+	}
+}
+
+// drainSendQueue отправляет все приоритетные пакеты, срок которых наступил
+func (ms *MediaSession) drainSendQueue() {
+	ms.sendMutex.Lock()
+	defer ms.sendMutex.Unlock()
+
+	now := time.Now()
+	ms.observeSendDeadline(now)
+	ms.drainSendQueueLocked(now)
+}
+
+// drainSendQueueLocked отправляет наступившие приоритетные пакеты в порядке
+// классов. Вызывается под sendMutex
+func (ms *MediaSession) drainSendQueueLocked(now time.Time) {
+	for send := ms.sendQueue.popDue(now); send != nil; send = ms.sendQueue.popDue(now) {
+		send()
+	}
+}
+
 // SetPtime изменяет длительность аудио пакета (packet time).
 // Автоматически переконфигурирует аудио процессор и тайминг отправки.
 //
@@ -931,6 +1318,8 @@ func (ms *MediaSession) SetPtime(ptime time.Duration) error {
 
 	// Очищаем буфер при изменении ptime
 	ms.audioBuffer = ms.audioBuffer[:0]
+	ms.codecPackets = nil
+	ms.releaseAudioLocked()
 	ms.bufferMutex.Unlock()
 
 	// Обновляем аудио процессор
@@ -944,6 +1333,10 @@ func (ms *MediaSession) SetPtime(ptime time.Duration) error {
 		ms.sendTicker.Stop()
 		ms.sendTicker = time.NewTicker(ptime)
 	}
+	if ms.sendTask != nil && ms.state == MediaStateActive {
+		ms.sendTask.Cancel()
+		ms.sendTask = ms.scheduler.Every(ms.ctx, ptime, ms.sendBufferedAudio)
+	}
 	ms.stateMutex.Unlock()
 
 	return nil
@@ -982,10 +1375,11 @@ func (ms *MediaSession) EnableJitterBuffer(enabled bool) error {
 		}
 
 		var err error
-		ms.jitterBuffer, err = NewJitterBuffer(config)
+		ms.jitterBuffer, err = newJitterBuffer(ms.ctx, config, ms.scheduler)
 		if err != nil {
 			return fmt.Errorf("ошибка создания jitter buffer: %w", err)
 		}
+		ms.jitterBuffer.SetClockRate(ms.GetClockRate(ms.payloadType))
 	}
 
 	return nil
@@ -1002,13 +1396,23 @@ func (ms *MediaSession) GetState() SessionState {
 func (ms *MediaSession) SetDirection(direction Direction) error {
 	ms.stateMutex.Lock()
 	defer ms.stateMutex.Unlock()
-	ms.direction = direction
+	ms.direction.Store(int32(direction))
+
+	// Аудио, накопленное до запрета отправки, сбрасывается: после
+	// возобновления поток начинается с новых кадров, без пачки устаревших
+	if !direction.canSend() {
+		ms.bufferMutex.Lock()
+		ms.audioBuffer = ms.audioBuffer[:0]
+		ms.codecPackets = nil
+		ms.releaseAudioLocked()
+		ms.bufferMutex.Unlock()
+	}
 	return nil
 }
 
 // GetDirection возвращает направление медиа потока
 func (ms *MediaSession) GetDirection() Direction {
-	return ms.direction
+	return Direction(ms.direction.Load())
 }
 
 // GetPtime возвращает текущий packet time
@@ -1016,7 +1420,16 @@ func (ms *MediaSession) GetPtime() time.Duration {
 	return ms.ptime
 }
 
-// GetStatistics возвращает статистику медиа сессии
+// GetRemotePtime возвращает длительность пакетов удаленной стороны, вычисленную
+// по RTP timestamp входящих пакетов. До получения первых пакетов совпадает с GetPtime.
+func (ms *MediaSession) GetRemotePtime() time.Duration {
+	ms.rxMutex.Lock()
+	defer ms.rxMutex.Unlock()
+	return ms.remotePtime
+}
+
+// GetStatistics возвращает статистику медиа сессии.
+// Возвращаемая структура является копией и не изменяется вместе с сессией.
 func (ms *MediaSession) GetStatistics() Statistics {
 	ms.statsMutex.RLock()
 	defer ms.statsMutex.RUnlock()
@@ -1025,12 +1438,12 @@ func (ms *MediaSession) GetStatistics() Statistics {
 
 // canSend проверяет можно ли отправлять данные в текущем режиме
 func (ms *MediaSession) canSend() bool {
-	return ms.direction == DirectionSendRecv || ms.direction == DirectionSendOnly
+	return ms.GetDirection().canSend()
 }
 
 // canReceive проверяет можно ли получать данные в текущем режиме
 func (ms *MediaSession) canReceive() bool {
-	return ms.direction == DirectionSendRecv || ms.direction == DirectionRecvOnly
+	return ms.GetDirection().canReceive()
 }
 
 // handleError обрабатывает ошибки медиа сессии
@@ -1044,7 +1457,7 @@ func (ms *MediaSession) handleError(err error, rtpSessionID ...string) {
 		if len(rtpSessionID) > 0 {
 			sessionID = rtpSessionID[0]
 		}
-		go errorHandler(err, sessionID)
+		go ms.invokeErrorHandler(errorHandler, err, sessionID)
 	}
 }
 
@@ -1101,34 +1514,53 @@ func getSampleRateForPayloadType(pt PayloadType) uint32 {
 		return 8000
 	case PayloadTypeG722:
 		return 16000
+	case PayloadTypeL16_2CH, PayloadTypeL16_1CH:
+		return 44100
 	default:
 		return 8000 // По умолчанию для телефонии
 	}
 }
 
+// getRTPClockRateForPayloadType возвращает частоту RTP clock для payload типа.
+// Для G.722 она равна 8000 Гц несмотря на частоту дискретизации 16 кГц (RFC 3551).
+func getRTPClockRateForPayloadType(pt PayloadType) uint32 {
+	if pt == PayloadTypeG722 {
+		return 8000
+	}
+	return getSampleRateForPayloadType(pt)
+}
+
 // GetExpectedPayloadSize возвращает ожидаемый размер payload для текущих настроек
 // Размер зависит от типа кодека и времени пакетизации (ptime)
 func (ms *MediaSession) GetExpectedPayloadSize() int {
 	// Используем предварительно рассчитанное значение вместо пересчета
 	samplesPerPacket := ms.samplesPerPacket
 
+	// Размер одного канала, каналы передаются чередующимися отсчетами
+	var channelSize int
 	switch ms.payloadType {
 	case PayloadTypePCMU, PayloadTypePCMA:
-		return samplesPerPacket // 1 байт на sample
+		channelSize = samplesPerPacket // 1 байт на sample
 	case PayloadTypeG722:
-		return samplesPerPacket // 1 байт на sample (сжатый)
+		channelSize = samplesPerPacket // 1 байт на sample (сжатый)
 	case PayloadTypeGSM:
 		// GSM: 160 samples (20ms) = 33 байта
-		return (samplesPerPacket * 33) / 160
+		channelSize = (samplesPerPacket * 33) / 160
 	case PayloadTypeG728:
 		// G.728: 2.5 байта на 20 samples
-		return (samplesPerPacket * 25) / 200
+		channelSize = (samplesPerPacket * 25) / 200
 	case PayloadTypeG729:
 		// G.729: 10 байт на 80 samples (10ms)
-		return (samplesPerPacket * 10) / 80
+		channelSize = (samplesPerPacket * 10) / 80
 	default:
-		return samplesPerPacket
+		channelSize = samplesPerPacket
 	}
+	return channelSize * max(ms.channels, 1)
+}
+
+// GetChannels возвращает количество каналов аудио
+func (ms *MediaSession) GetChannels() int {
+	return max(ms.channels, 1)
 }
 
 // GetPayloadTypeName возвращает человекочитаемое название кодека для текущего payload типа
@@ -1147,6 +1579,10 @@ func (ms *MediaSession) GetPayloadTypeName() string {
 		return "G.728"
 	case PayloadTypeG729:
 		return "G.729"
+	case PayloadTypeL16_2CH:
+		return "L16 стерео"
+	case PayloadTypeL16_1CH:
+		return "L16 моно"
 	default:
 		return fmt.Sprintf("Unknown (%d)", ms.payloadType)
 	}
@@ -1158,13 +1594,15 @@ func (ms *MediaSession) SetPayloadType(payloadType PayloadType) error {
 
 	// Обновляем аудио процессор
 	if ms.audioProcessor != nil {
-		ms.audioProcessor.config.PayloadType = payloadType
-		ms.audioProcessor.config.SampleRate = getSampleRateForPayloadType(payloadType)
+		ms.audioProcessor.SetPayloadType(payloadType)
 
 		// Пересчитываем буферы
 		ms.audioProcessor.SetPtime(ms.ptime)
 	}
 
+	// Частота RTP clock нового кодека для jitter buffer
+	ms.applyClockRates()
+
 	return nil
 }
 
@@ -1180,25 +1618,62 @@ func (ms *MediaSession) updateLastActivity() {
 	ms.statsMutex.Unlock()
 }
 
-// addToAudioBuffer добавляет аудио данные в буфер для отправки с правильным timing
-func (ms *MediaSession) addToAudioBuffer(audioData []byte) error {
+// addToAudioBuffer добавляет аудио данные в буфер для отправки с правильным timing.
+// captured - время вызова метода отправки для измерения задержки
+func (ms *MediaSession) addToAudioBuffer(audioData []byte, captured time.Time) error {
 	ms.bufferMutex.Lock()
 	defer ms.bufferMutex.Unlock()
 
+	if err := ms.reserveAudioLocked(len(audioData)); err != nil {
+		return err
+	}
+
 	// Добавляем данные в буфер
 	ms.audioBuffer = append(ms.audioBuffer, audioData...)
+	ms.latency.Load().markEnqueued(false, captured, len(audioData))
 
 	return nil
 }
 
-// audioSendLoop регулярно отправляет накопленные аудио данные с интервалом ptime
-func (ms *MediaSession) audioSendLoop() {
-	defer ms.wg.Done()
+// addCodecPacket добавляет готовый payload подключаемого кодека в буфер отправки
+func (ms *MediaSession) addCodecPacket(payload []byte, captured time.Time) error {
+	packet := make([]byte, len(payload))
+	copy(packet, payload)
 
-	// Получаем ticker под защитой мьютекса
-	ms.stateMutex.RLock()
-	ticker := ms.sendTicker
-	ms.stateMutex.RUnlock()
+	ms.bufferMutex.Lock()
+	defer ms.bufferMutex.Unlock()
+
+	if err := ms.reserveAudioLocked(len(packet)); err != nil {
+		return err
+	}
+	ms.codecPackets = append(ms.codecPackets, packet)
+	ms.latency.Load().markEnqueued(true, captured, 1)
+	return nil
+}
+
+// SetCodec заменяет подключаемый кодек, например после согласования
+// параметров fmtp в SDP answer. nil возвращает встроенное кодирование.
+func (ms *MediaSession) SetCodec(codec Codec) {
+	if ms.audioProcessor != nil {
+		ms.audioProcessor.SetCodec(codec)
+	}
+	ms.codecEnabled.Store(codec != nil)
+	ms.sampleSize.Store(codecSampleSize(codec))
+}
+
+// codecSampleSize возвращает размер отсчета кодека без кадров или 0
+func codecSampleSize(codec Codec) int32 {
+	if sampleCodec, ok := codec.(SampleCodec); ok {
+		return int32(sampleCodec.SampleSize())
+	}
+	return 0
+}
+
+// audioSendLoop регулярно отправляет накопленные аудио данные с интервалом ptime.
+// Ticker передается при запуске, чтобы не захватывать stateMutex, который
+// удерживается в Stop на время ожидания завершения горутин.
+func (ms *MediaSession) audioSendLoop(ticker *time.Ticker) {
+	defer ms.wg.Done()
 
 	if ticker == nil {
 		return
@@ -1216,10 +1691,40 @@ func (ms *MediaSession) audioSendLoop() {
 	}
 }
 
-// sendBufferedAudio отправляет накопленные в буфере аудио данные
+// sendBufferedAudio отправляет накопленные в буфере аудио данные.
+// Сначала отправляются наступившие DTMF и RTCP пакеты, затем один аудио пакет,
+// поэтому накопленное аудио не задерживает приоритетные пакеты дольше тика.
 func (ms *MediaSession) sendBufferedAudio() {
+	ms.sendMutex.Lock()
+	defer ms.sendMutex.Unlock()
+
+	ms.drainSendQueueLocked(time.Now())
+
+	// В режимах без отправки тикер работает вхолостую, RTCP из очереди
+	// отправляется (RFC 3264 Section 5.1)
+	if !ms.canSend() {
+		return
+	}
+
+	meter := ms.latency.Load()
 	ms.bufferMutex.Lock()
 
+	// Пакеты подключаемого кодека отправляются по одному за тик
+	if len(ms.codecPackets) > 0 {
+		mark, marked := meter.takeEnqueued(true, len(ms.codecPackets), 1)
+		packetData := ms.codecPackets[0]
+		ms.codecPackets[0] = nil
+		ms.codecPackets = ms.codecPackets[1:]
+		ms.releaseAudioLocked()
+		ms.bufferMutex.Unlock()
+
+		sent := meter.now()
+		ms.sendRTPPacket(packetData)
+		ms.lastSendTime = time.Now()
+		meter.observeSend(mark, marked, sent, ms.lastSendTime)
+		return
+	}
+
 	// Проверяем, есть ли данные для отправки
 	if len(ms.audioBuffer) == 0 {
 		ms.bufferMutex.Unlock()
@@ -1236,48 +1741,108 @@ func (ms *MediaSession) sendBufferedAudio() {
 	}
 
 	// Извлекаем данные для одного пакета
+	mark, marked := meter.takeEnqueued(false, len(ms.audioBuffer), expectedSize)
 	packetData := make([]byte, expectedSize)
 	copy(packetData, ms.audioBuffer[:expectedSize])
 
 	// Удаляем отправленные данные из буфера
 	ms.audioBuffer = ms.audioBuffer[expectedSize:]
+	ms.releaseAudioLocked()
 
 	ms.bufferMutex.Unlock()
 
 	// Отправляем пакет
+	sent := meter.now()
 	ms.sendRTPPacket(packetData)
 
 	// Обновляем время последней отправки
 	ms.lastSendTime = time.Now()
+	meter.observeSend(mark, marked, sent, ms.lastSendTime)
 }
 
 // sendRTPPacket отправляет RTP пакет через все сессии
 func (ms *MediaSession) sendRTPPacket(packetData []byte) {
+	packetData = ms.stripVADFrames(packetData)
+	if len(packetData) == 0 {
+		// Кадр подавлен: следующий отправленный пакет начинает talkspurt
+		ms.MarkTalkspurtStart()
+		return
+	}
+
+	fragments := ms.fragmentPayload(packetData)
+
 	ms.sessionsMutex.RLock()
 	defer ms.sessionsMutex.RUnlock()
 
 	for _, rtpSession := range ms.rtpSessions {
-		err := rtpSession.SendAudio(packetData, ms.ptime)
-		if err != nil {
-			ms.handleError(fmt.Errorf("ошибка отправки RTP пакета: %w", err))
-			continue
+		for _, fragment := range fragments {
+			err := rtpSession.SendAudio(fragment.payload, fragment.duration)
+			if err != nil {
+				ms.handleError(fmt.Errorf("ошибка отправки RTP пакета: %w", err))
+				break
+			}
 		}
 	}
 
 	// Обновляем статистику
-	ms.updateSendStats(len(packetData))
+	for _, fragment := range fragments {
+		ms.updateSendStats(len(fragment.payload))
+	}
+	ms.observeSentAudio(packetData)
 
 	// Обновляем RTCP статистику если включен
 	if ms.IsRTCPEnabled() {
-		ms.updateRTCPStats(1, uint32(len(packetData)))
+		ms.updateRTCPStats(uint32(len(fragments)), uint32(len(packetData)))
+	}
+}
+
+// payloadFragment - часть payload, отправляемая отдельным RTP пакетом
+type payloadFragment struct {
+	payload  []byte
+	duration time.Duration
+}
+
+// fragmentPayload делит payload кодека без кадров (L16), превышающий
+// maxPayloadSize, на RTP пакеты равной длительности по границе отсчета.
+// Равные части сохраняют шаг RTP timestamp между пакетами, длительность
+// округляется вверх до наносекунды, чтобы timestamp увеличивался ровно на
+// количество отсчетов части
+func (ms *MediaSession) fragmentPayload(payload []byte) []payloadFragment {
+	sampleSize := int(ms.sampleSize.Load())
+	if sampleSize == 0 || len(payload) <= ms.maxPayloadSize || len(payload)%sampleSize != 0 {
+		return []payloadFragment{{payload: payload, duration: ms.ptime}}
+	}
+
+	samples := len(payload) / sampleSize
+	maxSamples := max(ms.maxPayloadSize/sampleSize, 1)
+	count := (samples + maxSamples - 1) / maxSamples
+	for samples%count != 0 {
+		count++
 	}
+
+	fragmentSize := len(payload) / count
+	duration := (ms.ptime*time.Duration(samples/count) + time.Duration(samples) - 1) / time.Duration(samples)
+
+	fragments := make([]payloadFragment, 0, count)
+	for offset := 0; offset < len(payload); offset += fragmentSize {
+		fragments = append(fragments, payloadFragment{
+			payload:  payload[offset : offset+fragmentSize],
+			duration: duration,
+		})
+	}
+	return fragments
 }
 
 // GetBufferedAudioSize возвращает размер данных в буфере отправки
 func (ms *MediaSession) GetBufferedAudioSize() int {
 	ms.bufferMutex.Lock()
 	defer ms.bufferMutex.Unlock()
-	return len(ms.audioBuffer)
+
+	size := len(ms.audioBuffer)
+	for _, packet := range ms.codecPackets {
+		size += len(packet)
+	}
+	return size
 }
 
 // GetTimeSinceLastSend возвращает время с последней отправки пакета
@@ -1289,6 +1854,21 @@ func (ms *MediaSession) GetTimeSinceLastSend() time.Duration {
 func (ms *MediaSession) FlushAudioBuffer() error {
 	ms.bufferMutex.Lock()
 
+	// Пакеты подключаемого кодека отправляются целиком
+	packets := ms.codecPackets
+	ms.codecPackets = nil
+	ms.releaseAudioLocked()
+	if len(packets) > 0 {
+		ms.bufferMutex.Unlock()
+
+		ms.sendMutex.Lock()
+		for _, packet := range packets {
+			ms.sendRTPPacket(packet)
+		}
+		ms.sendMutex.Unlock()
+		return nil
+	}
+
 	if len(ms.audioBuffer) == 0 {
 		ms.bufferMutex.Unlock()
 		return nil
@@ -1298,15 +1878,33 @@ func (ms *MediaSession) FlushAudioBuffer() error {
 	packetData := make([]byte, len(ms.audioBuffer))
 	copy(packetData, ms.audioBuffer)
 	ms.audioBuffer = ms.audioBuffer[:0]
+	ms.releaseAudioLocked()
 
 	ms.bufferMutex.Unlock()
 
 	// Отправляем пакет
+	ms.sendMutex.Lock()
 	ms.sendRTPPacket(packetData)
+	ms.sendMutex.Unlock()
 
 	return nil
 }
 
+// MarkTalkspurtStart отмечает, что следующий отправленный пакет начинает
+// новый talkspurt и получает marker bit (RFC 3551 Section 4.1). Вызывается
+// после паузы подавления тишины, в том числе приложением с собственным VAD,
+// которое не отправляет аудио во время пауз
+func (ms *MediaSession) MarkTalkspurtStart() {
+	ms.sessionsMutex.RLock()
+	defer ms.sessionsMutex.RUnlock()
+
+	for _, rtpSession := range ms.rtpSessions {
+		if marker, ok := rtpSession.(interface{ MarkTalkspurtStart() }); ok {
+			marker.MarkTalkspurtStart()
+		}
+	}
+}
+
 // EnableSilenceSuppression включает/отключает подавление тишины
 // При включении пустые пакеты не отправляются
 func (ms *MediaSession) EnableSilenceSuppression(enabled bool) {
@@ -1336,6 +1934,28 @@ func (ms *MediaSession) HasRawAudioHandler() bool {
 	return ms.onRawAudioReceived != nil
 }
 
+// SetAudioFrameHandler устанавливает callback для получения аудио кадров с метаданными RTP.
+// Кадр содержит RTP timestamp, sequence number, SSRC, время получения и задержку jitter buffer.
+func (ms *MediaSession) SetAudioFrameHandler(handler func(AudioFrame)) {
+	ms.callbacksMutex.Lock()
+	defer ms.callbacksMutex.Unlock()
+	ms.onAudioFrame = handler
+}
+
+// ClearAudioFrameHandler убирает callback для аудио кадров
+func (ms *MediaSession) ClearAudioFrameHandler() {
+	ms.callbacksMutex.Lock()
+	defer ms.callbacksMutex.Unlock()
+	ms.onAudioFrame = nil
+}
+
+// HasAudioFrameHandler проверяет, установлен ли callback для аудио кадров
+func (ms *MediaSession) HasAudioFrameHandler() bool {
+	ms.callbacksMutex.RLock()
+	defer ms.callbacksMutex.RUnlock()
+	return ms.onAudioFrame != nil
+}
+
 // SetRawPacketHandler устанавливает callback для получения сырых аудио RTP пакетов без декодирования
 // DTMF пакеты продолжают обрабатываться отдельно через DTMF callback
 func (ms *MediaSession) SetRawPacketHandler(handler func(*rtp.Packet, string)) {
@@ -1361,14 +1981,11 @@ func (ms *MediaSession) HasRawPacketHandler() bool {
 
 // Методы циклов (перенесены из session_loops.go)
 
-// jitterBufferLoop основной цикл обработки jitter buffer
-func (ms *MediaSession) jitterBufferLoop() {
+// jitterBufferLoop основной цикл обработки jitter buffer. Буфер передается
+// явно, так как Stop обнуляет ms.jitterBuffer
+func (ms *MediaSession) jitterBufferLoop(jitterBuffer *JitterBuffer) {
 	defer ms.wg.Done()
 
-	if ms.jitterBuffer == nil {
-		return
-	}
-
 	slog.Debug("media.jitterBufferLoop Started")
 	for {
 		select {
@@ -1376,8 +1993,8 @@ func (ms *MediaSession) jitterBufferLoop() {
 			slog.Debug("media.jitterBufferLoop Stopped")
 			return
 		default:
-			// Получаем пакет из jitter buffer с ID сессии
-			packet, rtpSessionID, err := ms.jitterBuffer.GetBlockingWithSessionID()
+			// Получаем пакет из jitter buffer с ID сессии и метаданными приема
+			item, err := jitterBuffer.GetBlockingWithMetadata()
 			if err != nil {
 				if ms.ctx.Err() != nil {
 					slog.Debug("media.jitterBufferLoop Stopped")
@@ -1389,12 +2006,28 @@ func (ms *MediaSession) jitterBufferLoop() {
 
 			// Обрабатываем пакет если можем принимать
 			if ms.canReceive() && ms.GetState() == MediaStateActive {
-				ms.processIncomingPacketWithID(packet, rtpSessionID)
+				meta := packetMetadata{arrival: item.Arrival, playoutDelay: item.PlayoutDelay}
+				ms.processIncomingPacketWithID(item.Packet, item.RTPSessionID, meta)
 			}
 		}
 	}
 }
 
+// drainJitterBuffer обрабатывает готовые пакеты jitter buffer из задачи
+// общего планировщика вместо jitterBufferLoop
+func (ms *MediaSession) drainJitterBuffer(jitterBuffer *JitterBuffer) {
+	for {
+		item, ok := jitterBuffer.GetWithMetadata()
+		if !ok {
+			return
+		}
+		if ms.canReceive() && ms.GetState() == MediaStateActive {
+			meta := packetMetadata{arrival: item.Arrival, playoutDelay: item.PlayoutDelay}
+			ms.processIncomingPacketWithID(item.Packet, item.RTPSessionID, meta)
+		}
+	}
+}
+
 // audioProcessorLoop основной цикл обработки аудио
 func (ms *MediaSession) audioProcessorLoop() {
 	defer ms.wg.Done()
@@ -1404,7 +2037,7 @@ func (ms *MediaSession) audioProcessorLoop() {
 	}
 
 	// Создаем ticker для периодической обработки
-	ticker := time.NewTicker(time.Millisecond * 10) // Обрабатываем каждые 10ms
+	ticker := time.NewTicker(audioProcessorInterval)
 	defer ticker.Stop()
 
 	slog.Debug("media.audioProcessorLoop Started")
@@ -1457,18 +2090,21 @@ func (ms *MediaSession) handleIncomingRTPPacketWithID(packet *rtp.Packet, rtpSes
 		}
 	} else {
 		// Иначе обрабатываем пакет напрямую с ID сессии
-		ms.processIncomingPacketWithID(packet, rtpSessionID)
+		ms.processIncomingPacketWithID(packet, rtpSessionID, packetMetadata{arrival: time.Now()})
 	}
 }
 
 // processIncomingPacket обрабатывает входящий RTP пакет
 func (ms *MediaSession) processIncomingPacket(packet *rtp.Packet) {
 	// Вызываем новый метод с пустым ID для обратной совместимости
-	ms.processIncomingPacketWithID(packet, "")
+	ms.processIncomingPacketWithID(packet, "", packetMetadata{arrival: time.Now()})
 }
 
 // processIncomingPacketWithID обрабатывает входящий RTP пакет с известным ID сессии
-func (ms *MediaSession) processIncomingPacketWithID(packet *rtp.Packet, rtpSessionID string) {
+func (ms *MediaSession) processIncomingPacketWithID(packet *rtp.Packet, rtpSessionID string, meta packetMetadata) {
+	meter := ms.latency.Load()
+	meta.dequeued = meter.now()
+
 	// Сначала всегда проверяем DTMF пакеты (независимо от режима)
 	if ms.dtmfEnabled && ms.dtmfReceiver != nil {
 		if isDTMF, err := ms.dtmfReceiver.ProcessPacket(packet); isDTMF {
@@ -1481,13 +2117,21 @@ func (ms *MediaSession) processIncomingPacketWithID(packet *rtp.Packet, rtpSessi
 		}
 	}
 
+	if PayloadType(packet.PayloadType) == ms.payloadType {
+		ms.observeReceivedAudio(packet.Payload, packet.SSRC, packet.SequenceNumber)
+	}
+
 	// Если установлен callback для сырых аудио пакетов, отправляем аудио пакет как есть
 	ms.callbacksMutex.RLock()
 	rawPacketHandler := ms.onRawPacketReceived
 	ms.callbacksMutex.RUnlock()
 
 	if rawPacketHandler != nil {
-		rawPacketHandler(packet, rtpSessionID)
+		ms.invokeCallback("OnRawPacketReceived", rtpSessionID, func() {
+			rawPacketHandler(packet, rtpSessionID)
+		})
+		done := meter.now()
+		meter.observeReceive(meta.arrival, meta.dequeued, done, 0, done.Sub(meta.dequeued))
 		// Также обновляем статистику для сырых пакетов
 		ms.updateReceiveStats(len(packet.Payload))
 		ms.updateLastActivity()
@@ -1495,11 +2139,11 @@ func (ms *MediaSession) processIncomingPacketWithID(packet *rtp.Packet, rtpSessi
 	}
 
 	// Стандартная обработка аудио с декодированием
-	ms.processDecodedPacketWithID(packet, rtpSessionID)
+	ms.processDecodedPacketWithID(packet, rtpSessionID, meta)
 }
 
 // processDecodedPacketWithID обрабатывает аудио пакет с декодированием и ID сессии
-func (ms *MediaSession) processDecodedPacketWithID(packet *rtp.Packet, rtpSessionID string) {
+func (ms *MediaSession) processDecodedPacketWithID(packet *rtp.Packet, rtpSessionID string, meta packetMetadata) {
 	// Проверяем payload type - должен соответствовать нашему аудио кодеку
 	if PayloadType(packet.PayloadType) != ms.payloadType {
 		// Игнорируем пакеты с неизвестным payload type
@@ -1511,34 +2155,125 @@ func (ms *MediaSession) processDecodedPacketWithID(packet *rtp.Packet, rtpSessio
 		return
 	}
 
+	// SID кадр без речевых кадров не содержит аудио для воспроизведения
+	if payload := ms.stripVADFrames(packet.Payload); len(payload) != len(packet.Payload) {
+		if len(payload) == 0 {
+			return
+		}
+		stripped := *packet
+		stripped.Payload = payload
+		packet = &stripped
+	}
+
+	// Длительность входящего кадра определяется удаленной стороной
+	// и может отличаться от нашего ptime отправки
+	remotePtime := ms.observeRemotePtime(packet)
+
 	// Безопасно получаем callback-и под мьютексом
 	ms.callbacksMutex.RLock()
 	rawAudioHandler := ms.onRawAudioReceived
 	audioHandler := ms.onAudioReceived
+	frameHandler := ms.onAudioFrame
 	ms.callbacksMutex.RUnlock()
+	queue := ms.receiveQueue.Load()
+
+	// Время декодирования и callback-ов для измерения задержки
+	meter := ms.latency.Load()
+	var decodeTime, callbackTime time.Duration
 
 	// Сначала вызываем callback для сырых аудио данных если установлен
 	if rawAudioHandler != nil {
-		rawAudioHandler(packet.Payload, ms.payloadType, ms.ptime, rtpSessionID)
+		started := meter.now()
+		ms.invokeCallback("OnRawAudioReceived", rtpSessionID, func() {
+			rawAudioHandler(packet.Payload, ms.payloadType, remotePtime, rtpSessionID)
+		})
+		callbackTime += meter.since(started)
 	}
 
 	// Затем обрабатываем через аудио процессор для обработанных данных
-	if ms.audioProcessor != nil && audioHandler != nil {
-		processedData, err := ms.audioProcessor.ProcessIncoming(packet.Payload)
+	var processedData []byte
+	if ms.audioProcessor != nil && (audioHandler != nil || frameHandler != nil || queue != nil) {
+		var err error
+		started := meter.now()
+		processedData, err = ms.audioProcessor.ProcessIncoming(packet.Payload)
+		decodeTime = meter.since(started)
 		if err != nil {
 			ms.handleError(err, rtpSessionID)
 			return
 		}
 
 		// Вызываем callback для обработанных данных
-		audioHandler(processedData, ms.payloadType, ms.ptime, rtpSessionID)
+		if audioHandler != nil {
+			started = meter.now()
+			ms.invokeCallback("OnAudioReceived", rtpSessionID, func() {
+				audioHandler(processedData, ms.payloadType, remotePtime, rtpSessionID)
+			})
+			callbackTime += meter.since(started)
+		}
+		if queue != nil {
+			queue.push(processedData)
+		}
+	}
+
+	// Вызываем callback для кадра с метаданными RTP
+	if frameHandler != nil {
+		frame := newAudioFrame(packet, ms.payloadType, remotePtime, meta, rtpSessionID)
+		frame.Channels = ms.GetChannels()
+		frame.Audio = processedData
+		started := meter.now()
+		ms.invokeCallback("OnAudioFrame", rtpSessionID, func() {
+			frameHandler(frame)
+		})
+		callbackTime += meter.since(started)
 	}
+	meter.observeReceive(meta.arrival, meta.dequeued, meter.now(), decodeTime, callbackTime)
 
 	// Обновляем статистику (используем размер исходных данных)
 	ms.updateReceiveStats(len(packet.Payload))
 	ms.updateLastActivity()
 }
 
+// observeRemotePtime вычисляет длительность входящего пакета по разнице RTP timestamp
+// между последовательными пакетами одного источника и возвращает текущий ptime
+// удаленной стороны. При изменении ptime подстраивает jitter buffer.
+func (ms *MediaSession) observeRemotePtime(packet *rtp.Packet) time.Duration {
+	ms.rxMutex.Lock()
+
+	consecutive := ms.rxTracking && packet.SSRC == ms.rxSSRC &&
+		packet.SequenceNumber == ms.rxSeq+1 && !packet.Marker
+	tsDelta := packet.Timestamp - ms.rxTimestamp
+
+	ms.rxSSRC = packet.SSRC
+	ms.rxSeq = packet.SequenceNumber
+	ms.rxTimestamp = packet.Timestamp
+	ms.rxTracking = true
+
+	changed := false
+	if consecutive && tsDelta > 0 {
+		clockRate := ms.GetClockRate(PayloadType(packet.PayloadType))
+		observed := time.Duration(tsDelta) * time.Second / time.Duration(clockRate)
+
+		// Отбрасываем неправдоподобные значения (скачки timestamp без marker)
+		if observed >= minRemotePtime && observed <= maxRemotePtime && observed != ms.remotePtime {
+			ms.remotePtime = observed
+			changed = true
+		}
+	}
+	remotePtime := ms.remotePtime
+	ms.rxMutex.Unlock()
+
+	if changed {
+		ms.stateMutex.RLock()
+		jitterBuffer := ms.jitterBuffer
+		ms.stateMutex.RUnlock()
+		if jitterBuffer != nil {
+			jitterBuffer.SetPacketTime(remotePtime)
+		}
+	}
+
+	return remotePtime
+}
+
 // updateAudioProcessorStats обновляет статистику аудио процессора
 func (ms *MediaSession) updateAudioProcessorStats() {
 	if ms.audioProcessor == nil {
@@ -1577,9 +2312,11 @@ func (ms *MediaSession) EnableRTCP(enabled bool) error {
 		isActive := ms.state == MediaStateActive
 		ms.stateMutex.RUnlock()
 		if isActive {
-			ms.wg.Add(1)
-			go ms.rtcpSendLoop()
+			ms.startRTCPLocked()
 		}
+	} else {
+		ms.rtcpTask.Cancel()
+		ms.rtcpTask = nil
 	}
 
 	// Уведомляем все RTP сессии об изменении RTCP состояния
@@ -1603,7 +2340,8 @@ func (ms *MediaSession) IsRTCPEnabled() bool {
 }
 
 // GetRTCPStatistics возвращает агрегированную RTCP статистику со всех RTP сессий
-// Если RTCP отключен, возвращает локальную статистику
+// Если RTCP отключен, возвращает локальную статистику.
+// Возвращаемая структура является независимой копией.
 func (ms *MediaSession) GetRTCPStatistics() RTCPStatistics {
 	ms.rtcpStatsMutex.RLock()
 	defer ms.rtcpStatsMutex.RUnlock()
@@ -1626,6 +2364,17 @@ func (ms *MediaSession) GetRTCPStatistics() RTCPStatistics {
 
 		rtpStats := rtpSession.GetRTCPStatistics()
 
+		// RTP сессии пакета rtp возвращают собственный тип статистики
+		if rtpStatsMap, ok := rtpStats.(map[uint32]*rtpPkg.RTCPStatistics); ok {
+			converted := make(map[uint32]*RTCPStatistics, len(rtpStatsMap))
+			for ssrc, stat := range rtpStatsMap {
+				if stat != nil {
+					converted[ssrc] = rtcpStatisticsFromRTP(stat)
+				}
+			}
+			rtpStats = converted
+		}
+
 		// Проверяем тип возвращаемых данных согласно SessionRTP интерфейсу
 		if statsMap, ok := rtpStats.(map[uint32]*RTCPStatistics); ok {
 			// Агрегируем статистику из всех SSRC источников
@@ -1662,7 +2411,11 @@ func (ms *MediaSession) GetRTCPStatistics() RTCPStatistics {
 }
 
 // GetDetailedRTCPStatistics возвращает детальную RTCP статистику по каждой RTP сессии
-// Полезно для диагностики и мониторинга отдельных потоков
+// Полезно для диагностики и мониторинга отдельных потоков.
+//
+// Результат является глубокой копией: карты и структуры статистики не связаны
+// с внутренним состоянием RTP сессий, поэтому их можно читать и изменять
+// без синхронизации, пока сессия продолжает принимать и отправлять пакеты.
 func (ms *MediaSession) GetDetailedRTCPStatistics() map[string]interface{} {
 	if !ms.IsRTCPEnabled() {
 		return nil
@@ -1675,7 +2428,7 @@ func (ms *MediaSession) GetDetailedRTCPStatistics() map[string]interface{} {
 
 	for sessionID, rtpSession := range ms.rtpSessions {
 		if rtpSession.IsRTCPEnabled() {
-			result[sessionID] = rtpSession.GetRTCPStatistics()
+			result[sessionID] = copyRTCPStatisticsValue(rtpSession.GetRTCPStatistics())
 		}
 	}
 
@@ -1734,6 +2487,33 @@ func (ms *MediaSession) HasRTCPHandler() bool {
 	return ms.rtcpHandler != nil
 }
 
+// startRTCPLocked запускает периодическую отправку RTCP отчетов.
+// Вызывается под rtcpStatsMutex
+func (ms *MediaSession) startRTCPLocked() {
+	if ms.scheduler != nil {
+		ms.rtcpTask = ms.scheduler.Every(ms.ctx, ms.rtcpInterval, ms.sendPeriodicRTCP)
+		return
+	}
+
+	ms.wg.Add(1)
+	profiling.Go(ms.ctx, ms.rtcpSendLoop)
+}
+
+// sendPeriodicRTCP отправляет очередной RTCP отчет активной сессии.
+// Отчет проходит через приоритетную очередь и опережает накопленное аудио
+func (ms *MediaSession) sendPeriodicRTCP() {
+	if ms.GetState() != MediaStateActive || !ms.IsRTCPEnabled() {
+		return
+	}
+
+	ms.sendQueue.push(sendClassControl, time.Now(), func() {
+		if err := ms.SendRTCPReport(); err != nil {
+			ms.handleError(fmt.Errorf("ошибка отправки RTCP отчета: %w", err))
+		}
+	})
+	ms.drainSendQueue()
+}
+
 // rtcpSendLoop основной цикл отправки RTCP отчетов
 func (ms *MediaSession) rtcpSendLoop() {
 	defer ms.wg.Done()
@@ -1752,11 +2532,7 @@ func (ms *MediaSession) rtcpSendLoop() {
 			slog.Debug("media.rtcpSendLoop Stopped")
 			return
 		case <-ticker.C:
-			if ms.GetState() == MediaStateActive && ms.IsRTCPEnabled() {
-				if err := ms.SendRTCPReport(); err != nil {
-					ms.handleError(fmt.Errorf("ошибка отправки RTCP отчета: %w", err))
-				}
-			}
+			ms.sendPeriodicRTCP()
 		}
 	}
 }
@@ -1787,7 +2563,12 @@ func (ms *MediaSession) processRTCPReport(report RTCPReport) {
 	ms.rtcpStatsMutex.Unlock()
 
 	// Вызываем обработчик если установлен
-	if ms.HasRTCPHandler() {
-		ms.rtcpHandler(report)
+	ms.rtcpStatsMutex.RLock()
+	handler := ms.rtcpHandler
+	ms.rtcpStatsMutex.RUnlock()
+	if handler != nil {
+		ms.invokeCallback("OnRTCPReport", "", func() {
+			handler(report)
+		})
 	}
 }
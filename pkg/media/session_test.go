@@ -1,8 +1,15 @@
 package media
 
 import (
+	"bytes"
+	"runtime/pprof"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+	"github.com/pion/rtp"
 )
 
 // === ТЕСТЫ СОЗДАНИЯ И КОНФИГУРАЦИИ МЕДИА СЕССИИ ===
@@ -501,6 +508,103 @@ func TestMediaDirections(t *testing.T) {
 	}
 }
 
+// TestInactiveStartActivation проверяет вызов, начатый в режиме inactive:
+// отправка готова заранее и начинается в пределах ptime после смены
+// направления, без пачки пакетов
+func TestInactiveStartActivation(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-inactive-start"
+	config.Direction = DirectionInactive
+	config.DTMFEnabled = true
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	var mutex sync.Mutex
+	var audioSent []time.Time
+	var dtmfSent int
+	mock := NewMockSessionRTP("primary", "PCMU")
+	mock.SetSendAudioCallback(func([]byte, time.Duration) error {
+		mutex.Lock()
+		audioSent = append(audioSent, time.Now())
+		mutex.Unlock()
+		return nil
+	})
+	mock.SetSendPacketCallback(func(*rtp.Packet) error {
+		mutex.Lock()
+		dtmfSent++
+		mutex.Unlock()
+		return nil
+	})
+	if err := session.AddRTPSession("primary", mock); err != nil {
+		t.Fatalf("Ошибка добавления RTP сессии: %v", err)
+	}
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+	if err := session.SendAudio(generateTestAudioData(StandardPCMSamples20ms)); err == nil {
+		t.Fatal("Отправка в режиме inactive должна быть запрещена")
+	}
+	time.Sleep(3 * config.Ptime)
+
+	if err := session.SetDirection(DirectionSendRecv); err != nil {
+		t.Fatalf("Ошибка смены направления: %v", err)
+	}
+	activated := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := session.SendAudioRaw(generateTestAudioData(StandardPCMSamples20ms)); err != nil {
+			t.Fatalf("Ошибка отправки аудио после активации: %v", err)
+		}
+	}
+	if err := session.SendDTMF(DTMF1, 60*time.Millisecond); err != nil {
+		t.Fatalf("Ошибка отправки DTMF после активации: %v", err)
+	}
+	time.Sleep(6 * config.Ptime)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(audioSent) != 3 {
+		t.Fatalf("Ожидалось 3 аудио пакета, отправлено %d", len(audioSent))
+	}
+	if delay := audioSent[0].Sub(activated); delay > config.Ptime+10*time.Millisecond {
+		t.Errorf("Первый пакет после активации отправлен через %v, ожидалось не более ptime", delay)
+	}
+	for i := 1; i < len(audioSent); i++ {
+		if gap := audioSent[i].Sub(audioSent[i-1]); gap < config.Ptime/2 {
+			t.Errorf("Пакеты %d и %d отправлены пачкой с интервалом %v", i-1, i, gap)
+		}
+	}
+	if dtmfSent == 0 {
+		t.Error("DTMF пакеты не отправлены после активации")
+	}
+}
+
+// TestInactiveDropsBufferedAudio проверяет сброс буфера отправки при
+// переходе в режим без отправки
+func TestInactiveDropsBufferedAudio(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-inactive-buffer"
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	if err := session.addToAudioBuffer(generateTestAudioData(StandardPCMSamples20ms*10), time.Now()); err != nil {
+		t.Fatalf("Ошибка заполнения буфера: %v", err)
+	}
+	if err := session.SetDirection(DirectionRecvOnly); err != nil {
+		t.Fatalf("Ошибка смены направления: %v", err)
+	}
+	if size := session.GetBufferedAudioSize(); size != 0 {
+		t.Errorf("Буфер отправки не сброшен: %d байт", size)
+	}
+}
+
 // === ТЕСТЫ PAYLOAD ТИПОВ ===
 
 // TestPayloadTypes тестирует поддержку различных аудио кодеков
@@ -658,16 +762,114 @@ func TestMediaStatistics(t *testing.T) {
 	}
 }
 
+// TestStatsStream тестирует периодическую отправку снимков статистики
+// Проверяет:
+// - Первый снимок отправляется сразу
+// - Снимки отражают обновление статистики
+// - Снимок является копией и не меняется вместе с сессией
+// - Канал закрывается при остановке сессии
+func TestStatsStream(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-stats-stream"
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+
+	stream := session.StatsStream(10 * time.Millisecond)
+
+	var first StatsSnapshot
+	select {
+	case first = <-stream:
+	case <-time.After(time.Second):
+		t.Fatal("Первый снимок не получен")
+	}
+	if first.SessionID != config.SessionID {
+		t.Errorf("Ожидался SessionID %s, получено %s", config.SessionID, first.SessionID)
+	}
+	if first.Media.AudioPacketsSent != 0 {
+		t.Errorf("Начальное количество отправленных пакетов должно быть 0, получено %d", first.Media.AudioPacketsSent)
+	}
+	if first.RTCPEnabled {
+		t.Error("RTCP отключен по умолчанию")
+	}
+
+	session.updateSendStats(160)
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case snapshot := <-stream:
+			if snapshot.Media.AudioPacketsSent == 0 {
+				continue
+			}
+			if !snapshot.Timestamp.After(first.Timestamp) {
+				t.Error("Время снимка должно увеличиваться")
+			}
+			if first.Media.AudioPacketsSent != 0 {
+				t.Error("Ранее полученный снимок не должен изменяться")
+			}
+		case <-deadline:
+			t.Fatal("Снимок с обновленной статистикой не получен")
+		}
+		break
+	}
+
+	session.Stop()
+
+	select {
+	case _, ok := <-stream:
+		for ok {
+			_, ok = <-stream
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Канал должен закрываться при остановке сессии")
+	}
+}
+
+// TestProfilingLabels проверяет, что горутины сессии помечены session_id и call_id
+func TestProfilingLabels(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-profiling-labels"
+	config.CallID = "call-profiling-42"
+	config.JitterEnabled = true
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+
+	// Циклы отправки, jitter buffer, аудио процессора и вывода jitter buffer.
+	// Горутины могут еще не запуститься, поэтому профиль снимается повторно
+	var profile bytes.Buffer
+	labelled := 0
+	for deadline := time.Now().Add(time.Second); labelled < 4 && time.Now().Before(deadline); {
+		profile.Reset()
+		if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+			t.Fatalf("Ошибка получения профиля горутин: %v", err)
+		}
+		labelled = strings.Count(profile.String(), `"call_id":"call-profiling-42"`)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if labelled < 4 {
+		t.Errorf("Ожидалось не менее 4 помеченных горутин, найдено %d", labelled)
+	}
+	if !strings.Contains(profile.String(), `"session_id":"test-profiling-labels"`) {
+		t.Error("Горутины сессии должны быть помечены session_id")
+	}
+}
+
 // === ВСПОМОГАТЕЛЬНЫЕ ФУНКЦИИ ===
 
 // generateTestAudioData генерирует тестовые аудио данные заданного размера
 func generateTestAudioData(samples int) []byte {
-	data := make([]byte, samples)
-	for i := range data {
-		// Генерируем простую синусоиду (имитация μ-law)
-		data[i] = byte(128 + 64*(i%32)/16)
-	}
-	return data
+	return testsignal.Encode(testsignal.PCMU, testsignal.Sine(440, 0.5), samples)
 }
 
 // === БЕНЧМАРКИ ===
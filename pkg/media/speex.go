@@ -0,0 +1,71 @@
+//go:build !small
+
+package media
+
+import (
+	"strings"
+	"time"
+)
+
+// speexFrameDuration - длительность кадра Speex во всех режимах
+const speexFrameDuration = 20 * time.Millisecond
+
+// SpeexParams содержит согласованные параметры Speex (RFC 5574).
+//
+// Payload Speex - битовый поток кодера с одним или несколькими кадрами,
+// поэтому упаковку выполняет внешний кодер (например, привязка к libspeex).
+type SpeexParams struct {
+	ClockRate uint32 // 8000 (narrowband), 16000 (wideband) или 32000 (ultra-wideband)
+	Frames    int    // Количество кадров по 20ms в пакете
+	Mode      string // Параметр fmtp mode: список режимов ("3,any"), пусто - на выбор кодера
+	VBR       string // Параметр fmtp vbr: on, off или vad, пусто - off
+	CNG       bool   // Параметр fmtp cng=on
+}
+
+// SpeexCodecFactory создает внешний кодек Speex с согласованными параметрами
+type SpeexCodecFactory func(params SpeexParams) (Codec, error)
+
+// RegisterSpeexCodec регистрирует внешний кодек "speex" в реестре кодеков.
+// Перед вызовом фабрики проверяются частота RTP clock и параметры fmtp.
+// nil удаляет регистрацию.
+func RegisterSpeexCodec(factory SpeexCodecFactory) {
+	if factory == nil {
+		RegisterCodec("speex", nil)
+		return
+	}
+
+	RegisterCodec("speex", func(params CodecParams) (Codec, error) {
+		speexParams, err := parseSpeexParams(params)
+		if err != nil {
+			return nil, err
+		}
+		return factory(speexParams)
+	})
+}
+
+// parseSpeexParams проверяет параметры Speex из rtpmap и fmtp
+func parseSpeexParams(params CodecParams) (SpeexParams, error) {
+	switch params.ClockRate {
+	case 8000, 16000, 32000:
+	default:
+		return SpeexParams{}, codecError("speex", "неподдерживаемая частота %d", params.ClockRate)
+	}
+
+	result := SpeexParams{
+		ClockRate: params.ClockRate,
+		Frames:    int(params.Ptime / speexFrameDuration),
+		Mode:      strings.Trim(params.Fmtp["mode"], `"`),
+		VBR:       strings.ToLower(params.Fmtp["vbr"]),
+		CNG:       strings.EqualFold(params.Fmtp["cng"], "on"),
+	}
+	if result.Frames < 1 {
+		result.Frames = 1
+	}
+
+	switch result.VBR {
+	case "", "on", "off", "vad":
+	default:
+		return SpeexParams{}, codecError("speex", "некорректный параметр vbr=%s", result.VBR)
+	}
+	return result, nil
+}
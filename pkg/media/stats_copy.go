@@ -0,0 +1,58 @@
+package media
+
+import (
+	rtpPkg "github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// copyRTCPStatisticsValue возвращает глубокую копию RTCP статистики, полученной
+// от RTP сессии через SessionRTP.GetRTCPStatistics.
+//
+// Реализации SessionRTP могут возвращать внутренние карты, которые продолжают
+// обновляться при приеме пакетов. Копия гарантирует, что вызывающий код может
+// читать и изменять результат без гонок с сессией. Значения неизвестных типов
+// возвращаются без изменений.
+func copyRTCPStatisticsValue(value interface{}) interface{} {
+	switch stats := value.(type) {
+	case map[uint32]*rtpPkg.RTCPStatistics:
+		return copyStatisticsMap(stats)
+	case map[uint32]*RTCPStatistics:
+		return copyStatisticsMap(stats)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(stats))
+		for key, item := range stats {
+			result[key] = copyRTCPStatisticsValue(item)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// copyStatisticsMap копирует карту статистики по SSRC вместе со значениями
+func copyStatisticsMap[T any](stats map[uint32]*T) map[uint32]*T {
+	result := make(map[uint32]*T, len(stats))
+	for ssrc, stat := range stats {
+		if stat == nil {
+			continue
+		}
+		statCopy := *stat
+		result[ssrc] = &statCopy
+	}
+	return result
+}
+
+// rtcpStatisticsFromRTP преобразует статистику источника пакета rtp
+// в RTCPStatistics медиа слоя
+func rtcpStatisticsFromRTP(stat *rtpPkg.RTCPStatistics) *RTCPStatistics {
+	return &RTCPStatistics{
+		PacketsSent:     stat.PacketsSent,
+		PacketsReceived: stat.PacketsReceived,
+		OctetsSent:      stat.OctetsSent,
+		OctetsReceived:  stat.OctetsReceived,
+		PacketsLost:     stat.PacketsLost,
+		FractionLost:    stat.FractionLost,
+		Jitter:          stat.Jitter,
+		LastSRTimestamp: stat.LastSRTimestamp,
+		LastSRReceived:  stat.LastSRReceived,
+	}
+}
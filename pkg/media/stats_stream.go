@@ -0,0 +1,83 @@
+package media
+
+import (
+	"time"
+)
+
+// DefaultStatsStreamInterval - интервал снимков статистики по умолчанию
+const DefaultStatsStreamInterval = time.Second
+
+// StatsSnapshot представляет снимок статистики медиа сессии на момент времени.
+// Содержит только копии значений, поэтому подписчик не удерживает ссылок
+// на внутренние структуры сессии.
+type StatsSnapshot struct {
+	SessionID string
+	Timestamp time.Time // Время снятия снимка
+	State     SessionState
+
+	// Media содержит счетчики пакетов, байт и DTMF событий
+	Media Statistics
+
+	// RTCP содержит сводную RTCP статистику со всех RTP сессий.
+	// Заполняется только при включенном RTCP.
+	RTCPEnabled bool
+	RTCP        RTCPStatistics
+}
+
+// StatsStream возвращает канал, в который с заданным интервалом отправляются
+// снимки статистики сессии. Первый снимок отправляется сразу.
+//
+// Если подписчик не успевает читать, очередной снимок пропускается - сессия
+// никогда не блокируется на отправке. Канал закрывается при остановке сессии.
+// Интервал <= 0 заменяется на DefaultStatsStreamInterval.
+//
+// Пример использования:
+//
+//	for snapshot := range session.StatsStream(5 * time.Second) {
+//	    dashboard.Update(snapshot.SessionID, snapshot.Media.AudioPacketsReceived, snapshot.RTCP.Jitter)
+//	}
+func (ms *MediaSession) StatsStream(interval time.Duration) <-chan StatsSnapshot {
+	if interval <= 0 {
+		interval = DefaultStatsStreamInterval
+	}
+
+	stream := make(chan StatsSnapshot, 1)
+
+	go func() {
+		defer close(stream)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case stream <- ms.statsSnapshot():
+			default:
+				// Подписчик еще не прочитал предыдущий снимок
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ms.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return stream
+}
+
+// statsSnapshot собирает снимок текущей статистики сессии
+func (ms *MediaSession) statsSnapshot() StatsSnapshot {
+	snapshot := StatsSnapshot{
+		SessionID:   ms.sessionID,
+		Timestamp:   time.Now(),
+		State:       ms.GetState(),
+		Media:       ms.GetStatistics(),
+		RTCPEnabled: ms.IsRTCPEnabled(),
+	}
+	if snapshot.RTCPEnabled {
+		snapshot.RTCP = ms.GetRTCPStatistics()
+	}
+	return snapshot
+}
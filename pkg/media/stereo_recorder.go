@@ -0,0 +1,235 @@
+package media
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// RecordingChannel - канал стерео записи разговора
+type RecordingChannel int
+
+const (
+	RecordingCaller RecordingChannel = iota // Вызывающий, левый канал
+	RecordingCallee                         // Вызываемый, правый канал
+)
+
+// String возвращает название канала
+func (c RecordingChannel) String() string {
+	switch c {
+	case RecordingCaller:
+		return "caller"
+	case RecordingCallee:
+		return "callee"
+	default:
+		return "unknown"
+	}
+}
+
+// WallClock переводит RTP timestamp источника во время по часам отправителя
+// из RTCP Sender Report. Реализуется *rtp.Session
+type WallClock interface {
+	ConvertRTPTimeToWallClock(ssrc uint32, ts uint32) (time.Time, error)
+}
+
+// DefaultRecordingMaxSkew - время ожидания отстающего канала по умолчанию
+const DefaultRecordingMaxSkew = 200 * time.Millisecond
+
+// StereoRecorderConfig - параметры стерео записи
+type StereoRecorderConfig struct {
+	// SampleRate - частота дискретизации записи, по умолчанию 8000
+	SampleRate int
+	// Start - время начала записи. Нулевое значение - время первого кадра
+	Start time.Time
+	// MaxSkew - сколько ждать кадры отстающего канала, прежде чем записать
+	// вместо них тишину, по умолчанию DefaultRecordingMaxSkew
+	MaxSkew time.Duration
+}
+
+// StereoRecorder записывает разговор в стерео: вызывающий в левом канале,
+// вызываемый в правом. Кадры каждого канала размещаются по времени
+// звучания (по часам NTP отправителя из RTCP SR), а не по порядку
+// получения, поэтому каналы выровнены с точностью до отсчета, а паузы
+// одностороннего звука (удержание, подавление тишины, потери) заполняются
+// тишиной.
+//
+// Результат - чередующиеся 16-битные отсчеты little-endian (данные WAV,
+// 2 канала). Запись отстает от самого нового кадра на MaxSkew: столько
+// времени отстающий канал может догнать другой.
+//
+//	recorder := media.NewStereoRecorder(file, media.StereoRecorderConfig{})
+//	// отправленный нами звук - по локальным часам
+//	_ = recorder.Write(media.RecordingCaller, time.Now(), micPCM)
+//	// принятый звук - по часам отправителя из SR
+//	_ = recorder.WriteFrame(media.RecordingCallee, frame, pcm, rtpSession)
+//	defer recorder.Close()
+//
+// Методы безопасны для одновременного вызова.
+type StereoRecorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	config StereoRecorderConfig
+
+	started bool
+	start   time.Time
+	flushed int64      // Отсчетов каждого канала, записанных в w
+	pending [2][]int16 // Отсчеты каналов начиная с flushed
+	err     error      // Первая ошибка записи в w
+	closed  bool
+	onClose func() // Освобождение записи в TenantResources
+}
+
+// NewStereoRecorder создает стерео запись в w
+func NewStereoRecorder(w io.Writer, config StereoRecorderConfig) *StereoRecorder {
+	if config.SampleRate <= 0 {
+		config.SampleRate = 8000
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = DefaultRecordingMaxSkew
+	}
+	return &StereoRecorder{
+		w:       w,
+		config:  config,
+		started: !config.Start.IsZero(),
+		start:   config.Start,
+	}
+}
+
+// Write размещает отсчеты канала, первый из которых звучит в момент at.
+// Отсчеты, которые перекрывают уже записанный звук канала, заменяют его;
+// отсчеты раньше уже выданной части записи отбрасываются
+func (r *StereoRecorder) Write(channel RecordingChannel, at time.Time, samples []int16) error {
+	if channel != RecordingCaller && channel != RecordingCallee {
+		return recorderError("неизвестный канал записи %d", channel)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return recorderError("запись закрыта")
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if !r.started {
+		r.start = at
+		r.started = true
+	}
+
+	pos := r.samplesAt(at) - r.flushed
+	if pos < 0 {
+		if -pos >= int64(len(samples)) {
+			return nil
+		}
+		samples = samples[-pos:]
+		pos = 0
+	}
+
+	buf := r.pending[channel]
+	if end := int(pos) + len(samples); end > len(buf) {
+		buf = append(buf, make([]int16, end-len(buf))...)
+	}
+	copy(buf[pos:], samples)
+	r.pending[channel] = buf
+
+	// Выдаем то, что старше самого нового кадра на MaxSkew
+	latest := max(len(r.pending[RecordingCaller]), len(r.pending[RecordingCallee]))
+	if ready := latest - r.durationSamples(r.config.MaxSkew); ready > 0 {
+		return r.flushLocked(ready)
+	}
+	return nil
+}
+
+// WriteFrame размещает декодированные отсчеты принятого RTP кадра по
+// времени его звучания у отправителя. До первого Sender Report источника
+// используется время получения кадра
+func (r *StereoRecorder) WriteFrame(channel RecordingChannel, frame AudioFrame, samples []int16, clock WallClock) error {
+	at := frame.ArrivalTime
+	if clock != nil {
+		if wallClock, err := clock.ConvertRTPTimeToWallClock(frame.SSRC, frame.Timestamp); err == nil {
+			at = wallClock
+		}
+	}
+	return r.Write(channel, at, samples)
+}
+
+// Duration возвращает длительность записанной в w части
+func (r *StereoRecorder) Duration() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Duration(r.flushed * int64(time.Second) / int64(r.config.SampleRate))
+}
+
+// Close записывает оставшиеся отсчеты, дополняя короткий канал тишиной.
+// Writer не закрывается
+func (r *StereoRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.onClose != nil {
+		r.onClose()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return r.flushLocked(max(len(r.pending[RecordingCaller]), len(r.pending[RecordingCallee])))
+}
+
+// flushLocked записывает n отсчетов каждого канала, недостающие отсчеты
+// заменяются тишиной. Вызывается под mu.
+func (r *StereoRecorder) flushLocked(n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	data := make([]byte, n*4)
+	for ch := range r.pending {
+		buf := r.pending[ch]
+		for i := 0; i < n && i < len(buf); i++ {
+			binary.LittleEndian.PutUint16(data[i*4+ch*2:], uint16(buf[i]))
+		}
+		if n < len(buf) {
+			r.pending[ch] = buf[n:]
+		} else {
+			r.pending[ch] = buf[:0]
+		}
+	}
+	r.flushed += int64(n)
+
+	if _, err := r.w.Write(data); err != nil {
+		r.err = fmt.Errorf("ошибка записи: %w", err)
+		return r.err
+	}
+	return nil
+}
+
+// samplesAt возвращает номер отсчета записи для момента at
+func (r *StereoRecorder) samplesAt(at time.Time) int64 {
+	offset := at.Sub(r.start)
+	// Округление до ближайшего отсчета
+	half := time.Second / time.Duration(2*r.config.SampleRate)
+	if offset < 0 {
+		half = -half
+	}
+	return int64(offset+half) * int64(r.config.SampleRate) / int64(time.Second)
+}
+
+// durationSamples возвращает число отсчетов за d
+func (r *StereoRecorder) durationSamples(d time.Duration) int {
+	return int(int64(d) * int64(r.config.SampleRate) / int64(time.Second))
+}
+
+// recorderError создает ошибку стерео записи
+func recorderError(format string, args ...interface{}) error {
+	return &MediaError{
+		Code:    ErrorCodeSessionInvalidConfig,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,124 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeWallClock переводит RTP timestamp с частотой 8000 от заданного начала
+type fakeWallClock struct {
+	base time.Time
+	ok   bool
+}
+
+func (c fakeWallClock) ConvertRTPTimeToWallClock(_ uint32, ts uint32) (time.Time, error) {
+	if !c.ok {
+		return time.Time{}, errors.New("нет SR")
+	}
+	return c.base.Add(time.Duration(ts) * time.Second / 8000), nil
+}
+
+// stereoSamples разбирает запись на отсчеты левого и правого каналов
+func stereoSamples(data []byte) (left, right []int16) {
+	for i := 0; i+4 <= len(data); i += 4 {
+		left = append(left, int16(binary.LittleEndian.Uint16(data[i:])))
+		right = append(right, int16(binary.LittleEndian.Uint16(data[i+2:])))
+	}
+	return left, right
+}
+
+// TestStereoRecorderAlignment проверяет выравнивание каналов по времени
+// звучания независимо от порядка получения кадров
+func TestStereoRecorderAlignment(t *testing.T) {
+	var out bytes.Buffer
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recorder := NewStereoRecorder(&out, StereoRecorderConfig{Start: start, MaxSkew: 100 * time.Millisecond})
+
+	// Вызывающий: 3 кадра по 20 мс по локальным часам
+	for i := 0; i < 3; i++ {
+		at := start.Add(time.Duration(i) * 20 * time.Millisecond)
+		if err := recorder.Write(RecordingCaller, at, constantFrame(160, int16(i+1))); err != nil {
+			t.Fatalf("Ошибка записи: %v", err)
+		}
+	}
+
+	// Вызываемый: кадры с RTP timestamp от start+5 мс, второй получен раньше первого
+	clock := fakeWallClock{base: start.Add(5 * time.Millisecond), ok: true}
+	for _, ts := range []uint32{160, 0} {
+		frame := AudioFrame{SSRC: 1, Timestamp: ts, ArrivalTime: start.Add(time.Hour)}
+		if err := recorder.WriteFrame(RecordingCallee, frame, constantFrame(160, int16(100+ts/160)), clock); err != nil {
+			t.Fatalf("Ошибка записи кадра: %v", err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Ошибка закрытия записи: %v", err)
+	}
+
+	left, right := stereoSamples(out.Bytes())
+	if len(left) != 480 {
+		t.Fatalf("Ожидалось 480 отсчетов (60 мс), получено %d", len(left))
+	}
+	if left[0] != 1 || left[160] != 2 || left[479] != 3 {
+		t.Errorf("Неверный левый канал: %d %d %d", left[0], left[160], left[479])
+	}
+	// 5 мс = 40 отсчетов тишины перед первым кадром вызываемого
+	if right[39] != 0 || right[40] != 100 || right[199] != 100 || right[200] != 101 || right[359] != 101 {
+		t.Errorf("Неверное выравнивание правого канала: %v", right[38:42])
+	}
+	// Односторонний звук: после кадров вызываемого - тишина
+	if right[360] != 0 || right[479] != 0 {
+		t.Error("Пауза вызываемого должна заполняться тишиной")
+	}
+	if recorder.Duration() != 60*time.Millisecond {
+		t.Errorf("Ожидалась длительность 60 мс, получено %v", recorder.Duration())
+	}
+}
+
+// TestStereoRecorderSkew проверяет выдачу записи с отставанием MaxSkew и
+// отбрасывание кадров, опоздавших больше MaxSkew
+func TestStereoRecorderSkew(t *testing.T) {
+	var out bytes.Buffer
+	recorder := NewStereoRecorder(&out, StereoRecorderConfig{MaxSkew: 20 * time.Millisecond})
+	start := time.Now()
+
+	// Начало записи - первый кадр
+	for i := 0; i < 5; i++ {
+		_ = recorder.Write(RecordingCaller, start.Add(time.Duration(i)*20*time.Millisecond), constantFrame(160, 1))
+	}
+	// Выдано 100 - 20 = 80 мс, пока правый канал молчит
+	if got := out.Len() / 4; got != 640 {
+		t.Errorf("Ожидалось 640 выданных отсчетов, получено %d", got)
+	}
+
+	// Кадр до start+80 мс уже выдан тишиной, его часть за пределами принимается
+	_ = recorder.Write(RecordingCallee, start.Add(70*time.Millisecond), constantFrame(160, 7))
+	_ = recorder.Close()
+
+	_, right := stereoSamples(out.Bytes())
+	if right[639] != 0 || right[640] != 7 || right[719] != 7 || right[720] != 0 {
+		t.Errorf("Неверная обработка опоздавшего кадра: %v", right[638:642])
+	}
+
+	if err := recorder.Write(RecordingCaller, start, constantFrame(1, 1)); err == nil {
+		t.Error("Запись после Close должна возвращать ошибку")
+	}
+}
+
+// TestStereoRecorderArrivalFallback проверяет время получения до SR
+func TestStereoRecorderArrivalFallback(t *testing.T) {
+	var out bytes.Buffer
+	start := time.Now()
+	recorder := NewStereoRecorder(&out, StereoRecorderConfig{Start: start})
+
+	frame := AudioFrame{SSRC: 1, Timestamp: 12345, ArrivalTime: start.Add(10 * time.Millisecond)}
+	_ = recorder.WriteFrame(RecordingCallee, frame, constantFrame(80, 5), fakeWallClock{})
+	_ = recorder.Close()
+
+	_, right := stereoSamples(out.Bytes())
+	if len(right) != 160 || right[79] != 0 || right[80] != 5 {
+		t.Errorf("Ожидался кадр через 10 мс после начала записи, получено %d отсчетов", len(right))
+	}
+}
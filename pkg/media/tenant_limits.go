@@ -0,0 +1,281 @@
+package media
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// TenantResource - вид ресурса, ограничиваемого по tenant
+type TenantResource int
+
+const (
+	// TenantGoroutines - горутины медиа сессий и передачи на распознавание
+	TenantGoroutines TenantResource = iota
+	// TenantBufferedAudio - байты аудио в буферах отправки сессий
+	TenantBufferedAudio
+	// TenantRecordings - одновременные записи разговоров
+	TenantRecordings
+)
+
+// String возвращает название ресурса
+func (r TenantResource) String() string {
+	switch r {
+	case TenantGoroutines:
+		return "goroutines"
+	case TenantBufferedAudio:
+		return "buffered_audio"
+	case TenantRecordings:
+		return "recordings"
+	default:
+		return "unknown"
+	}
+}
+
+// TenantLimits - бюджет ресурсов tenant. 0 - без ограничения
+type TenantLimits struct {
+	MaxGoroutines    int // Горутин всех медиа сессий tenant
+	MaxBufferedAudio int // Байт аудио, ожидающего отправки во всех сессиях
+	MaxRecordings    int // Одновременных записей разговоров
+}
+
+// limit возвращает лимит ресурса
+func (l TenantLimits) limit(resource TenantResource) int {
+	switch resource {
+	case TenantGoroutines:
+		return l.MaxGoroutines
+	case TenantBufferedAudio:
+		return l.MaxBufferedAudio
+	case TenantRecordings:
+		return l.MaxRecordings
+	default:
+		return 0
+	}
+}
+
+// TenantUsage - текущее потребление ресурсов tenant
+type TenantUsage struct {
+	Goroutines    int
+	BufferedAudio int
+	Recordings    int
+}
+
+// TenantResources учитывает ресурсы медиа сессий по tenant и не дает одному
+// tenant занять ресурсы процесса в ущерб остальным. Лимиты проверяются в
+// местах выделения: запуск сессии (Config.Resources, Config.Tenant) и
+// StartTranscription резервируют горутины, SendAudio - байты буфера
+// отправки, NewStereoRecorder - запись. При превышении возвращается
+// MediaError с кодом ErrorCodeResourceLimitExceeded, уже работающие вызовы
+// tenant не затрагиваются.
+//
+// Пример использования:
+//
+//	resources := media.NewTenantResources(media.TenantLimits{MaxGoroutines: 2000}, map[string]media.TenantLimits{
+//	    "trial": {MaxGoroutines: 40, MaxBufferedAudio: 64 * 1024, MaxRecordings: 2},
+//	})
+//
+//	config := media.DefaultMediaSessionConfig()
+//	config.Tenant = "trial"
+//	config.Resources = resources
+//
+// Один экземпляр используется всеми сессиями процесса. Методы безопасны
+// для одновременного вызова.
+type TenantResources struct {
+	mu       sync.Mutex
+	defaults TenantLimits
+	limits   map[string]TenantLimits
+	usage    map[string]*TenantUsage
+}
+
+// NewTenantResources создает учет ресурсов. defaults применяются к tenant
+// без собственных лимитов, в том числе к вызовам без tenant
+func NewTenantResources(defaults TenantLimits, limits map[string]TenantLimits) *TenantResources {
+	r := &TenantResources{
+		defaults: defaults,
+		limits:   make(map[string]TenantLimits, len(limits)),
+		usage:    make(map[string]*TenantUsage),
+	}
+	for tenant, limit := range limits {
+		r.limits[tenant] = limit
+	}
+	return r
+}
+
+// SetLimits задает лимиты tenant. Уже выделенные ресурсы не отзываются,
+// новый лимит действует для следующих выделений
+func (r *TenantResources) SetLimits(tenant string, limits TenantLimits) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limits[tenant] = limits
+}
+
+// Limits возвращает лимиты tenant
+func (r *TenantResources) Limits(tenant string) TenantLimits {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.limitsLocked(tenant)
+}
+
+// Usage возвращает текущее потребление ресурсов tenant
+func (r *TenantResources) Usage(tenant string) TenantUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if usage := r.usage[tenant]; usage != nil {
+		return *usage
+	}
+	return TenantUsage{}
+}
+
+// NewStereoRecorder создает стерео запись с учетом лимита записей tenant.
+// Запись освобождается при Close
+func (r *TenantResources) NewStereoRecorder(tenant string, w io.Writer, config StereoRecorderConfig) (*StereoRecorder, error) {
+	if err := r.acquire(tenant, "", TenantRecordings, 1); err != nil {
+		return nil, err
+	}
+	recorder := NewStereoRecorder(w, config)
+	recorder.onClose = func() {
+		r.release(tenant, TenantRecordings, 1)
+	}
+	return recorder, nil
+}
+
+// acquire выделяет n единиц ресурса или возвращает ошибку превышения лимита.
+// sessionID указывается в ошибке
+func (r *TenantResources) acquire(tenant, sessionID string, resource TenantResource, n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usage := r.usage[tenant]
+	if usage == nil {
+		usage = &TenantUsage{}
+		r.usage[tenant] = usage
+	}
+	used := usage.counter(resource)
+
+	if limit := r.limitsLocked(tenant).limit(resource); limit > 0 && *used+n > limit {
+		return &MediaError{
+			Code:      ErrorCodeResourceLimitExceeded,
+			Message:   fmt.Sprintf("превышен лимит %s tenant %q: занято %d, запрошено %d, лимит %d", resource, tenant, *used, n, limit),
+			SessionID: sessionID,
+			Context: map[string]interface{}{
+				"tenant":    tenant,
+				"resource":  resource.String(),
+				"used":      *used,
+				"requested": n,
+				"limit":     limit,
+			},
+		}
+	}
+	*used += n
+	return nil
+}
+
+// release возвращает n единиц ресурса
+func (r *TenantResources) release(tenant string, resource TenantResource, n int) {
+	if n <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	usage := r.usage[tenant]
+	if usage == nil {
+		return
+	}
+	used := usage.counter(resource)
+	*used = max(*used-n, 0)
+	if *usage == (TenantUsage{}) {
+		delete(r.usage, tenant)
+	}
+}
+
+// limitsLocked возвращает лимиты tenant. Вызывается под mu
+func (r *TenantResources) limitsLocked(tenant string) TenantLimits {
+	if limits, ok := r.limits[tenant]; ok {
+		return limits
+	}
+	return r.defaults
+}
+
+// counter возвращает счетчик ресурса
+func (u *TenantUsage) counter(resource TenantResource) *int {
+	switch resource {
+	case TenantGoroutines:
+		return &u.Goroutines
+	case TenantBufferedAudio:
+		return &u.BufferedAudio
+	default:
+		return &u.Recordings
+	}
+}
+
+// reserveGoroutines резервирует n горутин сессии для ее tenant
+func (ms *MediaSession) reserveGoroutines(n int) error {
+	if ms.resources == nil {
+		return nil
+	}
+	return ms.resources.acquire(ms.tenant, ms.sessionID, TenantGoroutines, n)
+}
+
+// releaseGoroutines освобождает n горутин сессии
+func (ms *MediaSession) releaseGoroutines(n int) {
+	if ms.resources != nil {
+		ms.resources.release(ms.tenant, TenantGoroutines, n)
+	}
+}
+
+// reserveAudioLocked резервирует n байт буфера отправки. Вызывается под
+// bufferMutex
+func (ms *MediaSession) reserveAudioLocked(n int) error {
+	if ms.resources == nil {
+		return nil
+	}
+	if err := ms.resources.acquire(ms.tenant, ms.sessionID, TenantBufferedAudio, n); err != nil {
+		return err
+	}
+	ms.reservedAudio += n
+	return nil
+}
+
+// releaseAudioLocked освобождает резерв сверх текущего размера буфера
+// отправки. Вызывается под bufferMutex после изъятия данных из буфера
+func (ms *MediaSession) releaseAudioLocked() {
+	if ms.resources == nil {
+		return
+	}
+	buffered := len(ms.audioBuffer)
+	for _, packet := range ms.codecPackets {
+		buffered += len(packet)
+	}
+	if excess := ms.reservedAudio - buffered; excess > 0 {
+		ms.resources.release(ms.tenant, TenantBufferedAudio, excess)
+		ms.reservedAudio = buffered
+	}
+}
+
+// sessionGoroutines возвращает число горутин, которые запускает Start.
+// С планировщиком периодическая работа выполняется его горутинами
+func (ms *MediaSession) sessionGoroutines() int {
+	// С общим планировщиком работа сессии выполняется его задачами
+	if ms.scheduler != nil {
+		return 0
+	}
+
+	n := 0
+	if ms.jitterEnabled && ms.jitterBuffer != nil {
+		n++
+	}
+
+	n += 2 // Аудио процессор и цикл отправки, который работает в любом направлении
+	ms.rtcpStatsMutex.RLock()
+	if ms.rtcpEnabled {
+		n++
+	}
+	ms.rtcpStatsMutex.RUnlock()
+	return n
+}
@@ -0,0 +1,154 @@
+package media
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// requireLimitError проверяет ошибку превышения лимита ресурса
+func requireLimitError(t *testing.T, err error, resource TenantResource) {
+	t.Helper()
+	var mediaErr *MediaError
+	if !AsMediaError(err, &mediaErr) || mediaErr.Code != ErrorCodeResourceLimitExceeded {
+		t.Fatalf("Ожидалась ошибка превышения лимита, получено %v", err)
+	}
+	if mediaErr.Context["resource"] != resource.String() {
+		t.Errorf("Неверный ресурс в ошибке: %v", mediaErr.Context["resource"])
+	}
+}
+
+// TestTenantGoroutineLimit проверяет лимит горутин сессий tenant при запуске
+func TestTenantGoroutineLimit(t *testing.T) {
+	resources := NewTenantResources(TenantLimits{}, nil)
+
+	newSession := func(id, tenant string) *MediaSession {
+		config := DefaultMediaSessionConfig()
+		config.SessionID = id
+		config.Tenant = tenant
+		config.Resources = resources
+		session, err := NewSession(config)
+		if err != nil {
+			t.Fatalf("Ошибка создания сессии: %v", err)
+		}
+		t.Cleanup(func() { _ = session.Stop() })
+		return session
+	}
+
+	first := newSession("first", "noisy")
+	second := newSession("second", "noisy")
+	other := newSession("other", "quiet")
+	resources.SetLimits("noisy", TenantLimits{MaxGoroutines: first.sessionGoroutines()})
+
+	if err := first.Start(); err != nil {
+		t.Fatalf("Ошибка запуска первой сессии: %v", err)
+	}
+	err := second.Start()
+	requireLimitError(t, err, TenantGoroutines)
+	if second.GetState() != MediaStateIdle {
+		t.Error("Сессия сверх лимита не должна запускаться")
+	}
+
+	// Лимит одного tenant не затрагивает другие
+	if err := other.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии другого tenant: %v", err)
+	}
+
+	if err := first.Stop(); err != nil {
+		t.Fatalf("Ошибка остановки сессии: %v", err)
+	}
+	if usage := resources.Usage("noisy"); usage.Goroutines != 0 {
+		t.Errorf("Горутины не освобождены: %+v", usage)
+	}
+	if err := second.Start(); err != nil {
+		t.Errorf("Ошибка запуска после освобождения горутин: %v", err)
+	}
+}
+
+// TestSessionGoroutinesMatchStart проверяет, что резерв горутин совпадает
+// с числом горутин, которые запускает Start. Цикл отправки работает во всех
+// направлениях, поэтому резерв от направления не зависит
+func TestSessionGoroutinesMatchStart(t *testing.T) {
+	for _, direction := range []Direction{DirectionSendRecv, DirectionRecvOnly, DirectionInactive} {
+		t.Run(direction.String(), func(t *testing.T) {
+			config := DefaultMediaSessionConfig()
+			config.SessionID = "goroutines-" + direction.String()
+			config.Direction = direction
+			session, err := NewSession(config)
+			if err != nil {
+				t.Fatalf("Ошибка создания сессии: %v", err)
+			}
+			defer func() { _ = session.Stop() }()
+
+			before := runtime.NumGoroutine()
+			if err := session.Start(); err != nil {
+				t.Fatalf("Ошибка запуска сессии: %v", err)
+			}
+			started := runtime.NumGoroutine() - before
+			if started != session.sessionGoroutines() {
+				t.Errorf("Start запустил %d горутин, зарезервировано %d", started, session.sessionGoroutines())
+			}
+		})
+	}
+}
+
+// TestTenantBufferedAudioLimit проверяет лимит буфера отправки tenant
+func TestTenantBufferedAudioLimit(t *testing.T) {
+	resources := NewTenantResources(TenantLimits{MaxBufferedAudio: 320}, nil)
+
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "buffered"
+	config.Resources = resources
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer func() { _ = session.Stop() }()
+
+	frame := make([]byte, 160)
+	for i := 0; i < 2; i++ {
+		if err := session.addToAudioBuffer(frame, time.Now()); err != nil {
+			t.Fatalf("Ошибка добавления кадра %d: %v", i, err)
+		}
+	}
+	requireLimitError(t, session.addToAudioBuffer(frame, time.Now()), TenantBufferedAudio)
+
+	// Отправленный кадр освобождает буфер
+	session.sendBufferedAudio()
+	if usage := resources.Usage(""); usage.BufferedAudio != 160 {
+		t.Errorf("Ожидалось 160 байт в буфере, получено %d", usage.BufferedAudio)
+	}
+	if err := session.addToAudioBuffer(frame, time.Now()); err != nil {
+		t.Errorf("Ошибка добавления кадра после отправки: %v", err)
+	}
+
+	_ = session.Stop()
+	if usage := resources.Usage(""); usage.BufferedAudio != 0 {
+		t.Errorf("Буфер не освобожден после Stop: %d", usage.BufferedAudio)
+	}
+}
+
+// TestTenantRecordingLimit проверяет лимит одновременных записей tenant
+func TestTenantRecordingLimit(t *testing.T) {
+	resources := NewTenantResources(TenantLimits{}, map[string]TenantLimits{
+		"trial": {MaxRecordings: 1},
+	})
+
+	var out bytes.Buffer
+	recorder, err := resources.NewStereoRecorder("trial", &out, StereoRecorderConfig{})
+	if err != nil {
+		t.Fatalf("Ошибка создания записи: %v", err)
+	}
+	_, err = resources.NewStereoRecorder("trial", &out, StereoRecorderConfig{})
+	requireLimitError(t, err, TenantRecordings)
+
+	_ = recorder.Close()
+	_ = recorder.Close()
+	if usage := resources.Usage("trial"); usage.Recordings != 0 {
+		t.Errorf("Запись не освобождена: %+v", usage)
+	}
+	if _, err := resources.NewStereoRecorder("trial", &out, StereoRecorderConfig{}); err != nil {
+		t.Errorf("Ошибка создания записи после освобождения: %v", err)
+	}
+}
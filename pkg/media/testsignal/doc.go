@@ -0,0 +1,23 @@
+// Package testsignal генерирует тестовые аудио сигналы для тестов и примеров.
+//
+// В отличие от "псевдо-PCMU" шума, пакет формирует корректные кадры G.711
+// (ITU-T G.711 μ-law и A-law) из 16-битного линейного PCM с частотой
+// дискретизации 8 кГц. Доступны сигналы:
+//   - Sine: синусоидальный тон заданной частоты
+//   - WhiteNoise: детерминированный белый шум
+//   - DTMF: двухтональные сигналы DTMF (ITU-T Q.23)
+//   - Speech: речеподобный сигнал с формантами и слоговой огибающей
+//   - Silence: тишина
+//   - Cadence: прерывистый сигнал (гудки), WarningTone: предупреждающий тон
+//
+// Generator разбивает сигнал на кадры заданной длительности с сохранением
+// фазы между кадрами:
+//
+//	gen := testsignal.NewGenerator(testsignal.Sine(440, 0.5), testsignal.PCMU, 20*time.Millisecond)
+//	frame := gen.NextFrame() // 160 байт μ-law
+//	_ = session.SendAudio(frame, 20*time.Millisecond)
+//
+// Для однократной генерации буфера используется Encode:
+//
+//	payload := testsignal.Encode(testsignal.PCMA, testsignal.Sine(1000, 0.3), 160)
+package testsignal
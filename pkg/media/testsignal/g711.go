@@ -0,0 +1,151 @@
+package testsignal
+
+// Codec определяет кодек G.711. Значения совпадают со статическими
+// RTP payload type из RFC 3551.
+type Codec uint8
+
+const (
+	PCMU Codec = 0 // G.711 μ-law
+	PCMA Codec = 8 // G.711 A-law
+)
+
+// String возвращает имя кодека в формате SDP rtpmap
+func (c Codec) String() string {
+	switch c {
+	case PCMU:
+		return "PCMU"
+	case PCMA:
+		return "PCMA"
+	default:
+		return "unknown"
+	}
+}
+
+// Silence возвращает байт тишины для кодека (кодированный нулевой отсчет)
+func (c Codec) Silence() byte {
+	return c.EncodeSample(0)
+}
+
+// EncodeSample кодирует один линейный 16-битный отсчет
+func (c Codec) EncodeSample(sample int16) byte {
+	if c == PCMA {
+		return LinearToALaw(sample)
+	}
+	return LinearToMuLaw(sample)
+}
+
+// DecodeSample декодирует один байт G.711 в линейный 16-битный отсчет
+func (c Codec) DecodeSample(value byte) int16 {
+	if c == PCMA {
+		return ALawToLinear(value)
+	}
+	return MuLawToLinear(value)
+}
+
+// EncodePCM кодирует линейный PCM в кадр G.711
+func (c Codec) EncodePCM(samples []int16) []byte {
+	result := make([]byte, len(samples))
+	for i, sample := range samples {
+		result[i] = c.EncodeSample(sample)
+	}
+	return result
+}
+
+// DecodePCM декодирует кадр G.711 в линейный PCM
+func (c Codec) DecodePCM(data []byte) []int16 {
+	result := make([]int16, len(data))
+	for i, value := range data {
+		result[i] = c.DecodeSample(value)
+	}
+	return result
+}
+
+const (
+	muLawBias = 0x84  // Смещение μ-law (132)
+	muLawClip = 32635 // Максимальная амплитуда перед добавлением смещения
+)
+
+// LinearToMuLaw кодирует линейный отсчет в μ-law согласно ITU-T G.711
+func LinearToMuLaw(sample int16) byte {
+	value := int(sample)
+	sign := 0
+	if value < 0 {
+		value = -value
+		sign = 0x80
+	}
+	if value > muLawClip {
+		value = muLawClip
+	}
+	value += muLawBias
+
+	// Номер сегмента - позиция старшего бита выше 7-го
+	exponent := 7
+	for mask := 0x4000; value&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (value >> (exponent + 3)) & 0x0F
+
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+// MuLawToLinear декодирует μ-law в линейный отсчет согласно ITU-T G.711
+func MuLawToLinear(value byte) int16 {
+	value = ^value
+	exponent := int(value>>4) & 0x07
+	mantissa := int(value) & 0x0F
+
+	sample := ((mantissa << 3) + muLawBias) << exponent
+	sample -= muLawBias
+	if value&0x80 != 0 {
+		return int16(-sample)
+	}
+	return int16(sample)
+}
+
+// LinearToALaw кодирует линейный отсчет в A-law согласно ITU-T G.711
+func LinearToALaw(sample int16) byte {
+	// A-law работает с 13-битными отсчетами
+	value := int(sample) >> 3
+	sign := 0x80
+	if value < 0 {
+		value = -value - 1
+		sign = 0
+	}
+	if value > 0x0FFF {
+		value = 0x0FFF
+	}
+
+	var encoded int
+	if value < 32 {
+		encoded = value >> 1
+	} else {
+		exponent := 1
+		for v := value >> 5; v > 1; v >>= 1 {
+			exponent++
+		}
+		mantissa := (value >> exponent) & 0x0F
+		encoded = exponent<<4 | mantissa
+	}
+
+	// Четные биты инвертируются (XOR 0x55)
+	return byte(sign|encoded) ^ 0x55
+}
+
+// ALawToLinear декодирует A-law в линейный отсчет согласно ITU-T G.711
+func ALawToLinear(value byte) int16 {
+	value ^= 0x55
+	exponent := int(value>>4) & 0x07
+	mantissa := int(value) & 0x0F
+
+	var sample int
+	if exponent == 0 {
+		sample = mantissa<<4 + 8
+	} else {
+		sample = (mantissa<<4 + 0x108) << (exponent - 1)
+	}
+
+	if value&0x80 != 0 {
+		return int16(sample)
+	}
+	return int16(-sample)
+}
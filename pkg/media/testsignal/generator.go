@@ -0,0 +1,85 @@
+package testsignal
+
+import (
+	"time"
+)
+
+// DefaultPtime - стандартная длительность кадра для телефонии
+const DefaultPtime = 20 * time.Millisecond
+
+// FrameSamples возвращает количество отсчетов в кадре длительности ptime
+// (для G.711 совпадает с размером кадра в байтах: 20 мс = 160 байт)
+func FrameSamples(ptime time.Duration) int {
+	return int(int64(ptime) * SampleRate / int64(time.Second))
+}
+
+// Encode кодирует samples отсчетов сигнала в G.711
+func Encode(codec Codec, signal Signal, samples int) []byte {
+	return codec.EncodePCM(PCM(signal, 0, samples))
+}
+
+// Generator нарезает сигнал на кадры G.711 заданной длительности.
+// Последовательные кадры продолжают сигнал без разрыва фазы.
+// Generator не потокобезопасен.
+type Generator struct {
+	signal Signal
+	codec  Codec
+	ptime  time.Duration
+	frame  int
+	pos    int
+}
+
+// NewGenerator создает генератор кадров. Значение ptime <= 0 заменяется на DefaultPtime.
+func NewGenerator(signal Signal, codec Codec, ptime time.Duration) *Generator {
+	if ptime <= 0 {
+		ptime = DefaultPtime
+	}
+	return &Generator{
+		signal: signal,
+		codec:  codec,
+		ptime:  ptime,
+		frame:  FrameSamples(ptime),
+	}
+}
+
+// Codec возвращает кодек генератора
+func (g *Generator) Codec() Codec {
+	return g.codec
+}
+
+// Ptime возвращает длительность кадра
+func (g *Generator) Ptime() time.Duration {
+	return g.ptime
+}
+
+// FrameSize возвращает размер кадра в байтах
+func (g *Generator) FrameSize() int {
+	return g.frame
+}
+
+// NextFrame возвращает следующий кадр сигнала
+func (g *Generator) NextFrame() []byte {
+	frame := g.codec.EncodePCM(PCM(g.signal, g.pos, g.frame))
+	g.pos += g.frame
+	return frame
+}
+
+// Frames возвращает count следующих кадров сигнала
+func (g *Generator) Frames(count int) [][]byte {
+	frames := make([][]byte, count)
+	for i := range frames {
+		frames[i] = g.NextFrame()
+	}
+	return frames
+}
+
+// Duration возвращает длительность сигнала, сгенерированного с момента создания
+// или последнего Reset
+func (g *Generator) Duration() time.Duration {
+	return time.Duration(int64(g.pos) * int64(time.Second) / SampleRate)
+}
+
+// Reset начинает сигнал заново
+func (g *Generator) Reset() {
+	g.pos = 0
+}
@@ -0,0 +1,197 @@
+package testsignal
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"unicode"
+)
+
+// SampleRate - частота дискретизации G.711 в Гц
+const SampleRate = 8000
+
+// Signal описывает аудио сигнал как функцию номера отсчета.
+// Sample возвращает значение в диапазоне [-1, 1] для отсчета n
+// при частоте дискретизации SampleRate.
+type Signal interface {
+	Sample(n int) float64
+}
+
+// SignalFunc позволяет использовать функцию как Signal
+type SignalFunc func(n int) float64
+
+// Sample реализует Signal
+func (f SignalFunc) Sample(n int) float64 {
+	return f(n)
+}
+
+// Silence возвращает тишину
+func Silence() Signal {
+	return SignalFunc(func(int) float64 { return 0 })
+}
+
+// Sine возвращает синусоидальный тон частоты freq (Гц) с амплитудой amplitude (0..1)
+func Sine(freq, amplitude float64) Signal {
+	step := 2 * math.Pi * freq / SampleRate
+	return SignalFunc(func(n int) float64 {
+		return amplitude * math.Sin(step*float64(n))
+	})
+}
+
+// Cadence прерывает сигнал: on - звук, off - пауза (например, гудки
+// 1 с / 4 с). Фаза сигнала не сбрасывается между включениями.
+func Cadence(signal Signal, on, off time.Duration) Signal {
+	onSamples := FrameSamples(on)
+	period := onSamples + FrameSamples(off)
+	if onSamples <= 0 || period <= onSamples {
+		return signal
+	}
+	return SignalFunc(func(n int) float64 {
+		if n%period >= onSamples {
+			return 0
+		}
+		return signal.Sample(n)
+	})
+}
+
+// Параметры предупреждающего тона (тон 1400 Гц ITU-T E.180)
+const (
+	warningToneFreq = 1400.0
+	warningToneOn   = 200 * time.Millisecond
+	warningToneOff  = 200 * time.Millisecond
+)
+
+// WarningTone возвращает предупреждающий тон: короткие сигналы 1400 Гц.
+// Используется, например, перед окончанием лимита длительности вызова:
+// 1.2 с тона дают три сигнала.
+func WarningTone(amplitude float64) Signal {
+	return Cadence(Sine(warningToneFreq, amplitude), warningToneOn, warningToneOff)
+}
+
+// WhiteNoise возвращает равномерный белый шум с амплитудой amplitude (0..1).
+// Шум детерминирован: одинаковый seed дает одинаковую последовательность,
+// что позволяет воспроизводить тесты.
+func WhiteNoise(amplitude float64, seed uint64) Signal {
+	return SignalFunc(func(n int) float64 {
+		return amplitude * noiseValue(seed, n)
+	})
+}
+
+// dtmfFrequencies - пары частот DTMF (низкая, высокая) согласно ITU-T Q.23
+var dtmfFrequencies = map[rune][2]float64{
+	'1': {697, 1209}, '2': {697, 1336}, '3': {697, 1477}, 'A': {697, 1633},
+	'4': {770, 1209}, '5': {770, 1336}, '6': {770, 1477}, 'B': {770, 1633},
+	'7': {852, 1209}, '8': {852, 1336}, '9': {852, 1477}, 'C': {852, 1633},
+	'*': {941, 1209}, '0': {941, 1336}, '#': {941, 1477}, 'D': {941, 1633},
+}
+
+// DTMFFrequencies возвращает низкую и высокую частоты DTMF символа
+func DTMFFrequencies(digit rune) (low, high float64, err error) {
+	freqs, ok := dtmfFrequencies[unicode.ToUpper(digit)]
+	if !ok {
+		return 0, 0, fmt.Errorf("неизвестный DTMF символ: %q", digit)
+	}
+	return freqs[0], freqs[1], nil
+}
+
+// DTMF возвращает двухтональный сигнал DTMF символа (0-9, *, #, A-D).
+// Амплитуда amplitude делится между тонами поровну.
+func DTMF(digit rune, amplitude float64) (Signal, error) {
+	low, high, err := DTMFFrequencies(digit)
+	if err != nil {
+		return nil, err
+	}
+	lowTone := Sine(low, amplitude/2)
+	highTone := Sine(high, amplitude/2)
+	return SignalFunc(func(n int) float64 {
+		return lowTone.Sample(n) + highTone.Sample(n)
+	}), nil
+}
+
+// Параметры речеподобного сигнала
+const (
+	speechPitch        = 120.0  // Основной тон, Гц
+	speechPitchVibrato = 8.0    // Отклонение основного тона, Гц
+	speechSyllableRate = 4.0    // Слогов в секунду
+	speechBandLimit    = 3400.0 // Верхняя граница телефонной полосы, Гц
+	speechNoiseLevel   = 0.05   // Уровень шумовой (фрикативной) составляющей
+)
+
+// speechFormants - форманты гласного звука (частота, ширина полосы) в Гц
+var speechFormants = [][2]float64{{700, 130}, {1220, 70}, {2600, 160}}
+
+// Speech возвращает речеподобный сигнал: гармоники основного тона, усиленные
+// на формантах гласного звука, со слоговой огибающей и паузами между слогами.
+// Сигнал подходит для проверки VAD, кодеков и расчета качества, где чистый
+// тон дает нереалистичные результаты. Одинаковый seed дает одинаковый сигнал.
+func Speech(amplitude float64, seed uint64) Signal {
+	harmonics := int(math.Floor(speechBandLimit / speechPitch))
+	gains := make([]float64, harmonics)
+	var total float64
+	for k := 1; k <= harmonics; k++ {
+		gains[k-1] = formantGain(float64(k) * speechPitch)
+		total += gains[k-1]
+	}
+	for i := range gains {
+		gains[i] /= total
+	}
+
+	return SignalFunc(func(n int) float64 {
+		t := float64(n) / SampleRate
+
+		// Слоговая огибающая: половина периода - звук, половина - пауза
+		envelope := math.Sin(2 * math.Pi * speechSyllableRate * t)
+		if envelope <= 0 {
+			return 0
+		}
+
+		// Фаза основного тона с медленным вибрато
+		phase := 2 * math.Pi * (speechPitch*t -
+			speechPitchVibrato/(2*math.Pi*5)*math.Cos(2*math.Pi*5*t))
+
+		var voiced float64
+		for k, gain := range gains {
+			voiced += gain * math.Sin(float64(k+1)*phase)
+		}
+
+		value := voiced + speechNoiseLevel*noiseValue(seed, n)
+		return amplitude * envelope * value / (1 + speechNoiseLevel)
+	})
+}
+
+// formantGain возвращает усиление гармоники частоты freq формантным фильтром
+func formantGain(freq float64) float64 {
+	var gain float64
+	for i, formant := range speechFormants {
+		d := (freq - formant[0]) / formant[1]
+		// Верхние форманты слабее нижних
+		gain += 1 / (1 + d*d) / float64(i+1)
+	}
+	return gain
+}
+
+// noiseValue возвращает детерминированное псевдослучайное значение в [-1, 1)
+// для отсчета n (хэш SplitMix64)
+func noiseValue(seed uint64, n int) float64 {
+	z := seed + uint64(n)*0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	z ^= z >> 31
+	return float64(z>>11)/float64(1<<52) - 1
+}
+
+// PCM формирует samples отсчетов линейного 16-битного PCM, начиная с отсчета start.
+// Значения сигнала за пределами [-1, 1] ограничиваются.
+func PCM(signal Signal, start, samples int) []int16 {
+	result := make([]int16, samples)
+	for i := range result {
+		value := signal.Sample(start + i)
+		if value > 1 {
+			value = 1
+		} else if value < -1 {
+			value = -1
+		}
+		result[i] = int16(math.Round(value * math.MaxInt16))
+	}
+	return result
+}
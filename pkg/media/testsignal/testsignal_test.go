@@ -0,0 +1,191 @@
+package testsignal
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestG711KnownValues проверяет опорные значения ITU-T G.711
+func TestG711KnownValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		codec  Codec
+		sample int16
+		want   byte
+	}{
+		{"μ-law ноль", PCMU, 0, 0xFF},
+		{"μ-law максимум", PCMU, math.MaxInt16, 0x80},
+		{"μ-law минимум", PCMU, math.MinInt16, 0x00},
+		{"A-law ноль", PCMA, 0, 0xD5},
+		{"A-law максимум", PCMA, math.MaxInt16, 0xAA},
+		{"A-law минимум", PCMA, math.MinInt16, 0x2A},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.codec.EncodeSample(tt.sample); got != tt.want {
+				t.Errorf("Ожидался 0x%02X, получено 0x%02X", tt.want, got)
+			}
+		})
+	}
+
+	if PCMU.Silence() != 0xFF || PCMA.Silence() != 0xD5 {
+		t.Error("Неверные байты тишины G.711")
+	}
+}
+
+// TestG711RoundTrip проверяет, что все 256 кодов декодируются и кодируются обратно
+// в тот же код, а ошибка квантования для линейных отсчетов укладывается в шаг сегмента
+func TestG711RoundTrip(t *testing.T) {
+	for _, codec := range []Codec{PCMU, PCMA} {
+		for code := 0; code < 256; code++ {
+			value := byte(code)
+			decoded := codec.DecodeSample(value)
+			encoded := codec.EncodeSample(decoded)
+			// В μ-law коды 0x7F и 0xFF оба означают ноль
+			if encoded != value && !(codec == PCMU && decoded == 0) {
+				t.Errorf("%s: код 0x%02X -> %d -> 0x%02X", codec, value, decoded, encoded)
+			}
+		}
+
+		for sample := math.MinInt16; sample <= math.MaxInt16; sample += 7 {
+			decoded := codec.DecodeSample(codec.EncodeSample(int16(sample)))
+			diff := math.Abs(float64(int(decoded) - sample))
+			// Максимальный шаг квантования G.711 - 1024 (μ-law) и 1024 (A-law)
+			if diff > 1024 {
+				t.Fatalf("%s: слишком большая ошибка квантования для %d: %d", codec, sample, decoded)
+			}
+		}
+	}
+}
+
+// TestFrameSizes проверяет размеры кадров для стандартных ptime
+func TestFrameSizes(t *testing.T) {
+	for ptime, want := range map[time.Duration]int{
+		10 * time.Millisecond: 80,
+		20 * time.Millisecond: 160,
+		30 * time.Millisecond: 240,
+		40 * time.Millisecond: 320,
+	} {
+		if got := FrameSamples(ptime); got != want {
+			t.Errorf("ptime %v: ожидалось %d отсчетов, получено %d", ptime, want, got)
+		}
+		gen := NewGenerator(Sine(440, 0.5), PCMU, ptime)
+		if got := len(gen.NextFrame()); got != want {
+			t.Errorf("ptime %v: ожидался кадр %d байт, получено %d", ptime, want, got)
+		}
+	}
+}
+
+// TestGeneratorPhaseContinuity проверяет, что кадры генератора продолжают сигнал
+func TestGeneratorPhaseContinuity(t *testing.T) {
+	signal := Sine(1000, 0.5)
+	gen := NewGenerator(signal, PCMA, 20*time.Millisecond)
+
+	frames := gen.Frames(3)
+	whole := Encode(PCMA, signal, 480)
+	for i, frame := range frames {
+		for j, value := range frame {
+			if value != whole[i*160+j] {
+				t.Fatalf("Кадр %d, отсчет %d не совпадает с непрерывным сигналом", i, j)
+			}
+		}
+	}
+	if gen.Duration() != 60*time.Millisecond {
+		t.Errorf("Ожидалась длительность 60ms, получено %v", gen.Duration())
+	}
+
+	gen.Reset()
+	if string(gen.NextFrame()) != string(frames[0]) {
+		t.Error("После Reset сигнал должен начинаться заново")
+	}
+}
+
+// TestDTMFTones проверяет наличие обеих частот DTMF и отсутствие соседних
+func TestDTMFTones(t *testing.T) {
+	for _, digit := range "0123456789*#ABCD" {
+		signal, err := DTMF(digit, 0.8)
+		if err != nil {
+			t.Fatalf("Ошибка создания DTMF %q: %v", digit, err)
+		}
+		low, high, _ := DTMFFrequencies(digit)
+
+		pcm := PCMU.DecodePCM(Encode(PCMU, signal, 400))
+		lowPower := goertzel(pcm, low)
+		highPower := goertzel(pcm, high)
+
+		for _, freq := range []float64{697, 770, 852, 941, 1209, 1336, 1477, 1633} {
+			if freq == low || freq == high {
+				continue
+			}
+			if power := goertzel(pcm, freq); power*10 > lowPower || power*10 > highPower {
+				t.Errorf("DTMF %q: частота %.0f Гц не должна присутствовать", digit, freq)
+			}
+		}
+	}
+
+	if _, err := DTMF('X', 0.5); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного DTMF символа")
+	}
+}
+
+// TestNoiseAndSpeech проверяет детерминированность и уровень шума и речи
+func TestNoiseAndSpeech(t *testing.T) {
+	noise := Encode(PCMU, WhiteNoise(0.5, 42), 8000)
+	if string(noise) != string(Encode(PCMU, WhiteNoise(0.5, 42), 8000)) {
+		t.Error("Шум с одинаковым seed должен совпадать")
+	}
+	if string(noise) == string(Encode(PCMU, WhiteNoise(0.5, 43), 8000)) {
+		t.Error("Шум с разными seed должен различаться")
+	}
+
+	speech := PCM(Speech(0.8, 1), 0, SampleRate)
+	var silent, peak int
+	for _, sample := range speech {
+		if sample == 0 {
+			silent++
+		}
+		if abs := int(math.Abs(float64(sample))); abs > peak {
+			peak = abs
+		}
+	}
+	if silent < SampleRate/4 {
+		t.Errorf("Речеподобный сигнал должен содержать паузы, нулевых отсчетов: %d", silent)
+	}
+	if peak < math.MaxInt16/10 || peak > math.MaxInt16 {
+		t.Errorf("Неожиданный пиковый уровень речеподобного сигнала: %d", peak)
+	}
+}
+
+// TestWarningTone проверяет каденцию предупреждающего тона
+func TestWarningTone(t *testing.T) {
+	samples := PCM(WarningTone(0.5), 0, FrameSamples(800*time.Millisecond))
+	on := FrameSamples(200 * time.Millisecond)
+
+	for i, part := range [][]int16{samples[:on], samples[2*on : 3*on]} {
+		if goertzel(part, 1400) <= 100*goertzel(part, 1000) {
+			t.Errorf("Сигнал %d должен содержать тон 1400 Гц", i)
+		}
+	}
+	for _, sample := range samples[on : 2*on] {
+		if sample != 0 {
+			t.Fatal("Между сигналами должна быть пауза")
+		}
+	}
+
+	if Cadence(Sine(440, 0.5), 0, time.Second).Sample(1) == 0 {
+		t.Error("Каденция без длительности звука должна возвращать исходный сигнал")
+	}
+}
+
+// goertzel вычисляет мощность частоты freq в сигнале
+func goertzel(samples []int16, freq float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/SampleRate)
+	var s1, s2 float64
+	for _, sample := range samples {
+		s0 := float64(sample) + coeff*s1 - s2
+		s2, s1 = s1, s0
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
@@ -0,0 +1,353 @@
+package media
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TranscriptionSampleRate - частота дискретизации аудио для распознавания речи
+const TranscriptionSampleRate = 16000
+
+// DefaultTranscriptionChunkDuration - длительность фрагмента по умолчанию
+const DefaultTranscriptionChunkDuration = 5 * time.Second
+
+// DefaultTranscriptionQueueSize - размер очереди кадров по умолчанию
+// (2.5 секунды обоих направлений при ptime 20ms)
+const DefaultTranscriptionQueueSize = 250
+
+// transcriptionMaxGap - пауза в потоке говорящего, после которой текущий
+// фрагмент завершается досрочно, чтобы время начала следующего оставалось точным
+const transcriptionMaxGap = 500 * time.Millisecond
+
+// TranscriptionChunk - фрагмент речи одного говорящего для распознавания
+type TranscriptionChunk struct {
+	SessionID string
+	Speaker   string        // Метка говорящего из TranscriptionConfig
+	Sequence  uint64        // Номер фрагмента говорящего, начиная с 0
+	Start     time.Time     // Время первого отсчета фрагмента
+	Duration  time.Duration // Длительность фрагмента
+	Samples   []int16       // Моно отсчеты с частотой TranscriptionSampleRate
+}
+
+// PCM возвращает отсчеты фрагмента в виде 16-битных little-endian данных,
+// которые принимает большинство ASR сервисов
+func (c TranscriptionChunk) PCM() []byte {
+	data := make([]byte, len(c.Samples)*2)
+	for i, sample := range c.Samples {
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(sample))
+	}
+	return data
+}
+
+// TranscriptionConsumer получает фрагменты речи сессии, например клиент ASR
+type TranscriptionConsumer interface {
+	ConsumeTranscription(chunk TranscriptionChunk)
+}
+
+// TranscriptionConsumerFunc позволяет использовать функцию как TranscriptionConsumer
+type TranscriptionConsumerFunc func(chunk TranscriptionChunk)
+
+// ConsumeTranscription вызывает f(chunk)
+func (f TranscriptionConsumerFunc) ConsumeTranscription(chunk TranscriptionChunk) {
+	f(chunk)
+}
+
+// TranscriptionConfig - параметры передачи аудио на распознавание
+type TranscriptionConfig struct {
+	// ChunkDuration - длительность фрагмента, по умолчанию
+	// DefaultTranscriptionChunkDuration
+	ChunkDuration time.Duration
+	// LocalSpeaker - метка отправленного аудио, по умолчанию "local"
+	LocalSpeaker string
+	// RemoteSpeaker - метка принятого аудио, по умолчанию "remote"
+	RemoteSpeaker string
+	// QueueSize - размер очереди кадров между путем RTP и обработкой,
+	// по умолчанию DefaultTranscriptionQueueSize
+	QueueSize int
+}
+
+// transcriptionFrame - декодированный кадр одного направления
+type transcriptionFrame struct {
+	remote   bool
+	at       time.Time
+	rate     int
+	channels int
+	samples  []int16
+}
+
+// transcriptionSpeaker накапливает фрагмент одного направления
+type transcriptionSpeaker struct {
+	label     string
+	resampler linearResampler
+	sequence  uint64
+	start     time.Time
+	end       time.Time // Ожидаемое время следующего кадра
+	samples   []int16
+}
+
+// TranscriptionTap передает декодированное аудио сессии на распознавание:
+// переводит его в моно 16 кГц, собирает фрагменты по ChunkDuration с
+// временем начала и меткой говорящего и отдает их TranscriptionConsumer.
+//
+// Путь RTP только кладет декодированный кадр в очередь без блокировки; при
+// переполнении очереди (потребитель не успевает) кадры отбрасываются и
+// учитываются в Dropped. Передискретизация, сборка фрагментов и вызов
+// потребителя выполняются в отдельной горутине, фрагменты доставляются по
+// одному в порядке формирования.
+//
+// Аудио декодируется без состояния, как для AnalyticsStream: G.711 и L16.
+// Кадры кодеков с состоянием (G.729, AMR и др.) в фрагменты не попадают.
+type TranscriptionTap struct {
+	ms       *MediaSession
+	consumer TranscriptionConsumer
+	config   TranscriptionConfig
+
+	frames  chan transcriptionFrame
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// StartTranscription начинает передачу аудио сессии потребителю. Одновременно
+// в сессии может работать одна передача; она завершается Stop или остановкой
+// сессии, при этом недособранные фрагменты доставляются.
+//
+// Пример использования:
+//
+//	tap, err := session.StartTranscription(media.TranscriptionConsumerFunc(
+//	    func(chunk media.TranscriptionChunk) {
+//	        asr.Recognize(chunk.Speaker, chunk.Start, chunk.PCM())
+//	    }), media.TranscriptionConfig{ChunkDuration: 3 * time.Second})
+//	if err != nil {
+//	    return err
+//	}
+//	defer tap.Stop()
+func (ms *MediaSession) StartTranscription(consumer TranscriptionConsumer, config TranscriptionConfig) (*TranscriptionTap, error) {
+	if consumer == nil {
+		return nil, &MediaError{
+			Code:      ErrorCodeSessionInvalidConfig,
+			Message:   "не задан потребитель фрагментов распознавания",
+			SessionID: ms.sessionID,
+		}
+	}
+	if config.ChunkDuration <= 0 {
+		config.ChunkDuration = DefaultTranscriptionChunkDuration
+	}
+	if config.LocalSpeaker == "" {
+		config.LocalSpeaker = "local"
+	}
+	if config.RemoteSpeaker == "" {
+		config.RemoteSpeaker = "remote"
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultTranscriptionQueueSize
+	}
+
+	tap := &TranscriptionTap{
+		ms:       ms,
+		consumer: consumer,
+		config:   config,
+		frames:   make(chan transcriptionFrame, config.QueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := ms.reserveGoroutines(1); err != nil {
+		return nil, err
+	}
+	if !ms.transcription.CompareAndSwap(nil, tap) {
+		ms.releaseGoroutines(1)
+		return nil, &MediaError{
+			Code:      ErrorCodeSessionAlreadyStarted,
+			Message:   "передача аудио на распознавание уже запущена",
+			SessionID: ms.sessionID,
+		}
+	}
+
+	go tap.run()
+	return tap, nil
+}
+
+// Stop прекращает передачу, доставляет накопленные фрагменты и дожидается
+// завершения последнего вызова потребителя
+func (t *TranscriptionTap) Stop() {
+	t.once.Do(func() {
+		t.ms.transcription.CompareAndSwap(t, nil)
+		close(t.stop)
+	})
+	<-t.done
+}
+
+// Dropped возвращает число кадров, отброшенных из-за переполнения очереди
+func (t *TranscriptionTap) Dropped() uint64 {
+	return t.dropped.Load()
+}
+
+// push добавляет кадр без блокировки пути RTP
+func (t *TranscriptionTap) push(frame transcriptionFrame) {
+	select {
+	case t.frames <- frame:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// run собирает фрагменты из очереди кадров
+func (t *TranscriptionTap) run() {
+	defer close(t.done)
+	defer t.ms.releaseGoroutines(1)
+
+	speakers := [2]*transcriptionSpeaker{
+		{label: t.config.LocalSpeaker},
+		{label: t.config.RemoteSpeaker},
+	}
+	chunkSamples := max(int(int64(t.config.ChunkDuration)*TranscriptionSampleRate/int64(time.Second)), 1)
+
+	process := func(frame transcriptionFrame) {
+		speaker := speakers[0]
+		if frame.remote {
+			speaker = speakers[1]
+		}
+
+		if len(speaker.samples) > 0 && frame.at.Sub(speaker.end) > transcriptionMaxGap {
+			t.deliver(speaker)
+		}
+		if frame.rate != speaker.resampler.from {
+			speaker.resampler = linearResampler{from: frame.rate, to: TranscriptionSampleRate}
+		}
+
+		samples := speaker.resampler.process(downmix(frame.samples, frame.channels))
+		if len(speaker.samples) == 0 {
+			speaker.start = frame.at
+		}
+		speaker.end = frame.at.Add(time.Duration(len(frame.samples)/max(frame.channels, 1)) * time.Second / time.Duration(frame.rate))
+
+		for len(samples) > 0 {
+			n := min(chunkSamples-len(speaker.samples), len(samples))
+			speaker.samples = append(speaker.samples, samples[:n]...)
+			samples = samples[n:]
+			if len(speaker.samples) >= chunkSamples {
+				t.deliver(speaker)
+				speaker.start = speaker.start.Add(t.config.ChunkDuration)
+			}
+		}
+	}
+
+loop:
+	for {
+		select {
+		case frame := <-t.frames:
+			process(frame)
+		case <-t.stop:
+			break loop
+		case <-t.ms.ctx.Done():
+			t.ms.transcription.CompareAndSwap(t, nil)
+			break loop
+		}
+	}
+
+	// Кадры, попавшие в очередь до остановки
+	for len(t.frames) > 0 {
+		process(<-t.frames)
+	}
+	for _, speaker := range speakers {
+		if len(speaker.samples) > 0 {
+			t.deliver(speaker)
+		}
+	}
+}
+
+// deliver передает накопленный фрагмент говорящего потребителю
+func (t *TranscriptionTap) deliver(speaker *transcriptionSpeaker) {
+	chunk := TranscriptionChunk{
+		SessionID: t.ms.sessionID,
+		Speaker:   speaker.label,
+		Sequence:  speaker.sequence,
+		Start:     speaker.start,
+		Duration:  time.Duration(len(speaker.samples)) * time.Second / TranscriptionSampleRate,
+		Samples:   speaker.samples,
+	}
+	speaker.sequence++
+	speaker.samples = nil
+
+	t.ms.invokeCallback("TranscriptionConsumer", "", func() {
+		t.consumer.ConsumeTranscription(chunk)
+	})
+}
+
+// observeTranscription передает декодированный кадр в распознавание, если
+// оно запущено. nil - кадр не удалось декодировать
+func (ms *MediaSession) observeTranscription(samples []int16, remote bool) {
+	tap := ms.transcriptionTap()
+	if tap == nil || len(samples) == 0 {
+		return
+	}
+	channels := 1
+	if ms.payloadType == PayloadTypeL16_2CH {
+		channels = 2
+	}
+	tap.push(transcriptionFrame{
+		remote:   remote,
+		at:       time.Now(),
+		rate:     int(ms.GetClockRate(ms.payloadType)),
+		channels: channels,
+		samples:  samples,
+	})
+}
+
+// downmix сводит чередующиеся отсчеты нескольких каналов в моно
+func downmix(samples []int16, channels int) []int16 {
+	if channels <= 1 {
+		return samples
+	}
+	mono := make([]int16, len(samples)/channels)
+	for i := range mono {
+		var sum int
+		for ch := 0; ch < channels; ch++ {
+			sum += int(samples[i*channels+ch])
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono
+}
+
+// linearResampler меняет частоту дискретизации линейной интерполяцией.
+// Положение между кадрами сохраняется, поэтому на стыках кадров нет щелчков
+type linearResampler struct {
+	from, to int
+	pos      float64 // Положение следующего отсчета относительно начала кадра
+	last     int16   // Последний отсчет предыдущего кадра (индекс -1)
+}
+
+// process передискретизирует очередной кадр
+func (r *linearResampler) process(in []int16) []int16 {
+	if r.from == r.to || r.from <= 0 {
+		return append([]int16(nil), in...)
+	}
+
+	step := float64(r.from) / float64(r.to)
+	out := make([]int16, 0, int(float64(len(in))/step)+1)
+	sample := func(i int) float64 {
+		if i < 0 {
+			return float64(r.last)
+		}
+		return float64(in[i])
+	}
+
+	for ; r.pos <= float64(len(in)-1); r.pos += step {
+		i := int(math.Floor(r.pos))
+		value := sample(i)
+		if frac := r.pos - float64(i); frac > 0 {
+			value += (sample(i+1) - value) * frac
+		}
+		out = append(out, int16(math.Round(value)))
+	}
+	r.pos -= float64(len(in))
+	if len(in) > 0 {
+		r.last = in[len(in)-1]
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package media
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
+	"github.com/pion/rtp"
+)
+
+// TestTranscriptionTap проверяет сборку фрагментов 16 кГц по говорящим
+func TestTranscriptionTap(t *testing.T) {
+	config := DefaultMediaSessionConfig()
+	config.SessionID = "test-transcription"
+
+	session, err := NewSession(config)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer func() { _ = session.Stop() }()
+
+	var mu sync.Mutex
+	chunks := make(map[string][]TranscriptionChunk)
+	tap, err := session.StartTranscription(TranscriptionConsumerFunc(func(chunk TranscriptionChunk) {
+		mu.Lock()
+		defer mu.Unlock()
+		chunks[chunk.Speaker] = append(chunks[chunk.Speaker], chunk)
+	}), TranscriptionConfig{ChunkDuration: 100 * time.Millisecond, RemoteSpeaker: "agent"})
+	if err != nil {
+		t.Fatalf("Ошибка запуска распознавания: %v", err)
+	}
+
+	if _, err := session.StartTranscription(TranscriptionConsumerFunc(func(TranscriptionChunk) {}), TranscriptionConfig{}); err == nil {
+		t.Error("Повторный запуск распознавания должен возвращать ошибку")
+	}
+
+	// 200 мс отправленного и 100 мс принятого аудио
+	started := time.Now()
+	for i := 0; i < 10; i++ {
+		session.sendRTPPacket(testsignal.Encode(testsignal.PCMU, testsignal.Sine(1000, 0.5), 160))
+	}
+	for seq := uint16(1); seq <= 5; seq++ {
+		session.processIncomingPacketWithID(&rtp.Packet{
+			Header:  rtp.Header{PayloadType: PayloadTypePCMU, SequenceNumber: seq, SSRC: 1234, Timestamp: uint32(seq) * 160},
+			Payload: testsignal.Encode(testsignal.PCMU, testsignal.Sine(500, 0.5), 160),
+		}, "", packetMetadata{arrival: time.Now()})
+	}
+	tap.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	local := chunks["local"]
+	if len(local) != 2 {
+		t.Fatalf("Ожидалось 2 фрагмента local, получено %d", len(local))
+	}
+	if len(local[0].Samples) != 1600 || local[0].Duration != 100*time.Millisecond {
+		t.Errorf("Неверный полный фрагмент: %d отсчетов, %v", len(local[0].Samples), local[0].Duration)
+	}
+	// Передискретизация 8 -> 16 кГц удваивает число отсчетов (без последнего)
+	if total := len(local[0].Samples) + len(local[1].Samples); total < 3198 || total > 3200 {
+		t.Errorf("Ожидалось около 3200 отсчетов, получено %d", total)
+	}
+	if local[0].Sequence != 0 || local[1].Sequence != 1 || local[1].Start.Sub(local[0].Start) != 100*time.Millisecond {
+		t.Errorf("Неверная нумерация или время фрагментов: %+v", local[1].Start.Sub(local[0].Start))
+	}
+	if local[0].Start.Before(started) || local[0].SessionID != "test-transcription" {
+		t.Error("Неверное время начала или ID сессии фрагмента")
+	}
+
+	if remote := chunks["agent"]; len(remote) != 1 || len(remote[0].Samples) < 1598 {
+		t.Errorf("Ожидался 1 фрагмент agent около 1600 отсчетов, получено %d", len(remote))
+	}
+	if len(local[0].PCM()) != 2*len(local[0].Samples) {
+		t.Error("Неверный размер PCM фрагмента")
+	}
+
+	// Отправка после Stop не обращается к остановленной передаче
+	session.sendRTPPacket(testsignal.Encode(testsignal.PCMU, testsignal.Silence(), 160))
+	if tap.Dropped() != 0 {
+		t.Errorf("Неожиданно отброшено %d кадров", tap.Dropped())
+	}
+}
+
+// TestLinearResampler проверяет передискретизацию синуса 8 -> 16 кГц
+// через границы кадров
+func TestLinearResampler(t *testing.T) {
+	resampler := linearResampler{from: 8000, to: 16000}
+
+	var out []int16
+	for frame := 0; frame < 4; frame++ {
+		in := make([]int16, 160)
+		for i := range in {
+			n := frame*160 + i
+			in[i] = int16(10000 * math.Sin(2*math.Pi*200*float64(n)/8000))
+		}
+		out = append(out, resampler.process(in)...)
+	}
+
+	if len(out) != 4*320-1 {
+		t.Fatalf("Ожидалось %d отсчетов, получено %d", 4*320-1, len(out))
+	}
+	for n, sample := range out {
+		want := 10000 * math.Sin(2*math.Pi*200*float64(n)/16000)
+		if math.Abs(float64(sample)-want) > 100 {
+			t.Fatalf("Отсчет %d: %d, ожидалось %.0f", n, sample, want)
+		}
+	}
+
+	if mono := downmix([]int16{100, 300, -200, 0}, 2); len(mono) != 2 || mono[0] != 200 || mono[1] != -100 {
+		t.Errorf("Неверное сведение в моно: %v", mono)
+	}
+}
@@ -1,4 +1,12 @@
+// Package media_builder - ранний черновик общего интерфейса SDP offer/answer
+// и медиа сессии. Реализации интерфейса нет: развитие продолжилось в
+// pkg/media_sdp, а стабильный API для приложений - пакет pkg/api/v1.
+//
+// Deprecated: используйте пакет github.com/arzzra/soft_phone/pkg/api/v1.
 package media_builder
 
+// Config - конфигурация Builder.
+//
+// Deprecated: используйте v1.Config.
 type Config struct {
 }
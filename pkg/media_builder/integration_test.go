@@ -2,12 +2,12 @@ package media_builder
 
 import (
 	"fmt"
-	"math"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
 	"github.com/arzzra/soft_phone/pkg/media_sdp"
 	"github.com/arzzra/soft_phone/pkg/rtp"
 	"github.com/pion/sdp/v3"
@@ -526,20 +526,7 @@ func verifyStatistics(t *testing.T, caller media_sdp.SDPMediaBuilder, callee med
 
 // generateTestAudio генерирует тестовые аудио данные (синусоида)
 func generateTestAudio(samples int, frequency float64) []byte {
-	data := make([]byte, samples)
-	sampleRate := 8000.0 // G.711 sample rate
-
-	for i := range data {
-		// Генерируем синусоиду
-		t := float64(i) / sampleRate
-		amplitude := 0.3 // Умеренная громкость
-		sample := amplitude * math.Sin(2*math.Pi*frequency*t)
-
-		// Конвертируем в μ-law (упрощенно для тестирования)
-		data[i] = byte(128 + sample*127)
-	}
-
-	return data
+	return testsignal.Encode(testsignal.PCMU, testsignal.Sine(frequency, 0.3), samples)
 }
 
 // debugTransportAddresses отладка адресов транспортов
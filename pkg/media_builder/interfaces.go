@@ -5,6 +5,10 @@ import (
 	"github.com/pion/sdp/v3"
 )
 
+// Builder - создание и обработка SDP offer/answer с доступом к медиа сессии.
+//
+// Deprecated: используйте v1.Call: v1.NewOutgoingCall для offer и
+// v1.NewIncomingCall для answer.
 type Builder interface {
 	// CreateOffer создает SDP offer на основе конфигурации
 	CreateOffer() (*sdp.SessionDescription, error)
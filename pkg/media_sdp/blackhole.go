@@ -0,0 +1,218 @@
+package media_sdp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// SocketStats - состояние RTP/RTCP сокетов вызова на момент отчета
+type SocketStats struct {
+	LocalRTPAddr   string
+	RemoteRTPAddr  string
+	LocalRTCPAddr  string // Пусто без отдельного RTCP сокета
+	RemoteRTCPAddr string
+	Active         bool // RTP сокет открыт
+
+	// SocketOptions - результат применения опций сокета (DSCP, буферы)
+	SocketOptions rtp.SocketOptionsReport
+}
+
+// NoInboundMediaReport - диагностика вызова, в котором мы отправляем RTP, но
+// за пробное окно после ответа не получили ни одного пакета ("черная дыра").
+// В отличие от прекращения входящего потока (см. OneWayAudioReport) медиа
+// путь к нам не работал с начала вызова: обычно NAT, межсетевой экран или
+// неверный адрес в SDP одной из сторон
+type NoInboundMediaReport struct {
+	SessionID string
+	CreatedAt time.Time
+	// Elapsed - время от начала пробного окна (ответ и запуск сессии)
+	Elapsed time.Duration
+
+	// LocalSDP и RemoteSDP - последние offer и answer вызова
+	LocalSDP  string
+	RemoteSDP string
+
+	Socket SocketStats
+	Path   rtp.MediaPathInfo
+
+	// Findings - выводы о вероятной причине
+	Findings []string
+}
+
+// findings формирует выводы по собранным сведениям
+func (r NoInboundMediaReport) findings() []string {
+	findings := []string{fmt.Sprintf(
+		"За %v после ответа не получено ни одного RTP пакета на %s, отправлено %d",
+		r.Elapsed.Round(time.Millisecond), r.Socket.LocalRTPAddr, r.Path.PacketsSent)}
+
+	if r.Path.RTCPReceived > 0 {
+		findings = append(findings, "RTCP от удаленной стороны получен: она доступна, "+
+			"но RTP отправляет на другой адрес или порт, либо RTP блокируется")
+	} else {
+		findings = append(findings, "RTCP от удаленной стороны тоже не получен: "+
+			"входящий трафик блокируется NAT/межсетевым экраном или в нашем SDP неверный адрес")
+	}
+	if err := r.Socket.SocketOptions.Err(); err != nil {
+		findings = append(findings, fmt.Sprintf("Не применены опции сокета: %v", err))
+	}
+	return findings
+}
+
+// socketOptionsSource - транспорт, сообщающий результат опций сокета
+// (*rtp.UDPTransport)
+type socketOptionsSource interface {
+	SocketOptionsReport() rtp.SocketOptionsReport
+}
+
+// newSocketStats собирает состояние сокетов пары транспортов
+func newSocketStats(pair *rtp.TransportPair) SocketStats {
+	var stats SocketStats
+	if pair == nil || pair.RTP == nil {
+		return stats
+	}
+	stats.LocalRTPAddr, stats.RemoteRTPAddr, _ = ExtractTransportInfo(pair.RTP)
+	stats.Active = pair.RTP.IsActive()
+	if source, ok := pair.RTP.(socketOptionsSource); ok {
+		stats.SocketOptions = source.SocketOptionsReport()
+	}
+	if pair.RTCP != nil {
+		if addr := pair.RTCP.LocalAddr(); addr != nil {
+			stats.LocalRTCPAddr = addr.String()
+		}
+		if addr := pair.RTCP.RemoteAddr(); addr != nil {
+			stats.RemoteRTCPAddr = addr.String()
+		}
+	}
+	return stats
+}
+
+// inboundMediaProbe - пробное окно после ответа. Если за timeout при
+// отправке RTP не получен ни один пакет, один раз вызывает callback.
+// Пакет, полученный хотя бы раз, снимает проверку: пропадание потока
+// позже - другой случай
+type inboundMediaProbe struct {
+	sessionID string
+	timeout   time.Duration
+	callback  func(NoInboundMediaReport)
+	// call возвращает текущие транспорт, RTP и медиа сессии вызова
+	call func() (*rtp.TransportPair, rtp.SessionRTP, *media.MediaSession)
+
+	mu        sync.Mutex
+	localSDP  string
+	remoteSDP string
+	startedAt time.Time
+	timer     *time.Timer
+	stopped   bool
+}
+
+// newInboundMediaProbe создает пробное окно. nil - проверка отключена
+func newInboundMediaProbe(sessionID string, timeout time.Duration, callback func(NoInboundMediaReport),
+	call func() (*rtp.TransportPair, rtp.SessionRTP, *media.MediaSession)) *inboundMediaProbe {
+	if timeout <= 0 || callback == nil {
+		return nil
+	}
+	return &inboundMediaProbe{sessionID: sessionID, timeout: timeout, callback: callback, call: call}
+}
+
+// setSDP запоминает offer и answer вызова для отчета
+func (p *inboundMediaProbe) setSDP(local, remote *sdp.SessionDescription) {
+	if p == nil {
+		return
+	}
+	localSDP, remoteSDP := sdpText(local), sdpText(remote)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if localSDP != "" {
+		p.localSDP = localSDP
+	}
+	if remoteSDP != "" {
+		p.remoteSDP = remoteSDP
+	}
+}
+
+// sdpText возвращает текст SDP, пусто для nil или ошибки сериализации
+func sdpText(desc *sdp.SessionDescription) string {
+	if desc == nil {
+		return ""
+	}
+	text, err := desc.Marshal()
+	if err != nil {
+		return ""
+	}
+	return string(text)
+}
+
+// start открывает пробное окно. Повторные вызовы ничего не делают
+func (p *inboundMediaProbe) start() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil || p.stopped {
+		return
+	}
+	p.startedAt = time.Now()
+	p.timer = time.AfterFunc(p.timeout, p.check)
+}
+
+// stop закрывает пробное окно без отчета
+func (p *inboundMediaProbe) stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
+
+// check проверяет входящий поток по окончании окна
+func (p *inboundMediaProbe) check() {
+	p.mu.Lock()
+	stopped := p.stopped
+	p.mu.Unlock()
+	if stopped {
+		return
+	}
+
+	pair, session, mediaSession := p.call()
+	// Удержание и односторонние направления не предполагают встречного потока
+	if mediaSession == nil || mediaSession.GetDirection() != media.DirectionSendRecv {
+		return
+	}
+	source, ok := session.(mediaPathSource)
+	if !ok {
+		return
+	}
+	path := source.MediaPath()
+	if path.PacketsReceived > 0 || path.PacketsSent == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	report := NoInboundMediaReport{
+		SessionID: p.sessionID,
+		CreatedAt: time.Now(),
+		Elapsed:   time.Since(p.startedAt),
+		LocalSDP:  p.localSDP,
+		RemoteSDP: p.remoteSDP,
+		Socket:    newSocketStats(pair),
+		Path:      path,
+	}
+	p.mu.Unlock()
+
+	report.Findings = report.findings()
+	p.callback(report)
+}
@@ -1,9 +1,13 @@
 package media_sdp
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/arzzra/soft_phone/pkg/media"
@@ -13,11 +17,43 @@ import (
 
 // sdpMediaBuilder реализует интерфейс SDPMediaBuilder
 type sdpMediaBuilder struct {
-	config        BuilderConfig
-	mediaSession  *media.MediaSession
-	rtpSession    rtp.SessionRTP
-	transportPair *rtp.TransportPair
-	started       bool
+	config         BuilderConfig
+	mediaSession   *media.MediaSession
+	rtpSession     rtp.SessionRTP
+	transportPair  *rtp.TransportPair
+	started        bool
+	remoteHold     bool                       // Удаленная сторона на удержании (c=0.0.0.0)
+	remoteFmtp     map[uint8]FormatParameters // Параметры fmtp из answer
+	offerCodecs    []CodecInfo                // Кодеки последнего offer в порядке предпочтения
+	dtmfNegotiated bool                       // telephone-event принят в answer
+
+	// Подавление пауз, согласованное a=silenceSupp answer
+	silenceSuppression media.SilenceSuppression
+
+	// Возможности offer (RFC 5939) и конфигурация, выбранная в answer
+	offerCapabilities capabilitySet
+	acceptedConfig    *AcceptedConfiguration
+
+	// Последний обработанный answer для распознавания повторов 200 OK
+	answerProcessed bool
+	answerOrigin    sdp.Origin
+	answerHash      [sha256.Size]byte
+	// appliedAnswer - answer, адрес которого применен к транспорту
+	appliedAnswer *sdp.SessionDescription
+	// remoteAddr - RTP адрес answer, примененный к транспорту
+	remoteAddr string
+	// remoteRTCPAddr - RTCP адрес из a=rtcp answer или RTP порт + 1
+	remoteRTCPAddr string
+
+	// inboundProbe - пробное окно входящего медиа (nil - отключено)
+	inboundProbe *inboundMediaProbe
+	// callMu защищает замену транспорта и сессий от чтения mediaCall из
+	// таймера пробного окна
+	callMu sync.Mutex
+
+	// Результат Close для повторных вызовов
+	closed   bool
+	closeErr error
 }
 
 // NewSDPMediaBuilder создает новый SDP Media Builder
@@ -27,8 +63,11 @@ func NewSDPMediaBuilder(config BuilderConfig) (SDPMediaBuilder, error) {
 	}
 
 	builder := &sdpMediaBuilder{
-		config: config,
+		config:             config,
+		silenceSuppression: negotiateSilenceSuppression(config.SilenceSuppression, media.SilenceSuppressionDefault),
 	}
+	builder.inboundProbe = newInboundMediaProbe(config.SessionID, config.NoInboundMediaTimeout,
+		config.OnNoInboundMedia, builder.mediaCall)
 
 	// Создаем транспорт
 	if err := builder.createTransport(); err != nil {
@@ -52,24 +91,35 @@ func NewSDPMediaBuilder(config BuilderConfig) (SDPMediaBuilder, error) {
 
 // createTransport создает транспорт для RTP
 func (b *sdpMediaBuilder) createTransport() error {
-	transportPair, err := CreateTransportPair(b.config.Transport)
+	transportConfig := b.config.Transport
+	transportConfig.randomSource = b.config.RandomSource
+	transportConfig.dryRun = b.config.DryRun
+	transportPair, err := CreateTransportPair(transportConfig)
 	if err != nil {
 		return WrapSDPError(ErrorCodeTransportCreation, b.config.SessionID, err,
 			"Не удалось создать транспорт")
 	}
 
+	b.callMu.Lock()
 	b.transportPair = transportPair
+	b.callMu.Unlock()
 	return nil
 }
 
 // createRTPSession создает RTP сессию
 func (b *sdpMediaBuilder) createRTPSession() error {
+	// Пробное согласование не создает RTP сессию
+	if b.config.DryRun != nil {
+		return nil
+	}
+
 	// Подготавливаем конфигурацию RTP сессии
 	rtpConfig := rtp.SessionConfig{
-		PayloadType: b.config.PayloadType,
-		MediaType:   b.config.MediaType,
-		ClockRate:   b.config.ClockRate,
-		Transport:   b.transportPair.RTP,
+		PayloadType:  b.config.PayloadType,
+		MediaType:    b.config.MediaType,
+		ClockRate:    b.config.ClockRate,
+		Transport:    b.transportPair.RTP,
+		RandomSource: b.config.RandomSource,
 		LocalSDesc: rtp.SourceDescription{
 			CNAME: fmt.Sprintf("%s@%s", b.config.SessionID, getLocalHostname()),
 			NAME:  b.config.SessionName,
@@ -90,7 +140,9 @@ func (b *sdpMediaBuilder) createRTPSession() error {
 			"Не удалось создать RTP сессию")
 	}
 
+	b.callMu.Lock()
 	b.rtpSession = rtpSession
+	b.callMu.Unlock()
 	return nil
 }
 
@@ -102,10 +154,30 @@ func (b *sdpMediaBuilder) createMediaSession() error {
 	mediaConfig.Direction = b.config.Direction
 	mediaConfig.Ptime = b.config.Ptime
 	mediaConfig.PayloadType = media.PayloadType(b.config.PayloadType)
+	mediaConfig.Channels = int(b.config.Channels)
 
 	// DTMF настройки
 	mediaConfig.DTMFEnabled = b.config.DTMFEnabled
 	mediaConfig.DTMFPayloadType = b.config.DTMFPayloadType
+	mediaConfig.ClockRates = b.offerClockRates()
+	mediaConfig.DisableVADFrames = b.vadFramesDisabled()
+	mediaConfig.SilenceSuppression = b.silenceSuppression
+
+	// Кодек из реестра media (динамические payload types и L16)
+	if usesCodecRegistry(uint8(b.config.PayloadType)) {
+		codec, err := newRegistryCodec(b.codecName(), uint8(b.config.PayloadType),
+			b.config.ClockRate, b.config.Channels, b.config.Ptime, b.config.Fmtp)
+		if err != nil {
+			return WrapSDPError(ErrorCodeIncompatibleCodec, b.config.SessionID, err,
+				"Не удалось создать кодек %s", b.codecName())
+		}
+		mediaConfig.Codec = codec
+	}
+
+	// Пробное согласование проверяет кодек, но не создает медиа сессию
+	if b.config.DryRun != nil {
+		return nil
+	}
 
 	// Создаем медиа сессию
 	mediaSession, err := media.NewSession(mediaConfig)
@@ -122,7 +194,9 @@ func (b *sdpMediaBuilder) createMediaSession() error {
 			"Не удалось зарегистрировать RTP сессию в медиа сессии")
 	}
 
+	b.callMu.Lock()
 	b.mediaSession = mediaSession
+	b.callMu.Unlock()
 
 	// Устанавливаем обработчик сырых пакетов для связи с RTP сессией
 	// Этот handler будет вызываться внутри media сессии, но нам нужно
@@ -185,13 +259,20 @@ func (b *sdpMediaBuilder) CreateOffer() (*sdp.SessionDescription, error) {
 		},
 	}
 
+	// Кодеки offer в порядке предпочтения (с учетом CodecPolicy)
+	b.offerCodecs = b.orderedCodecs()
+	formats := make([]string, 0, len(b.offerCodecs)+1)
+	for _, codec := range b.offerCodecs {
+		formats = append(formats, strconv.Itoa(int(codec.PayloadType)))
+	}
+
 	// Создаем медиа описание
 	mediaDesc := &sdp.MediaDescription{
 		MediaName: sdp.MediaName{
 			Media:   "audio",
 			Port:    sdp.RangedPort{Value: port},
 			Protos:  []string{"RTP", "AVP"},
-			Formats: []string{strconv.Itoa(int(b.config.PayloadType))},
+			Formats: formats,
 		},
 		ConnectionInformation: &sdp.ConnectionInformation{
 			NetworkType: "IN",
@@ -201,7 +282,19 @@ func (b *sdpMediaBuilder) CreateOffer() (*sdp.SessionDescription, error) {
 	}
 
 	// Добавляем атрибуты медиа
-	mediaDesc.Attributes = b.buildMediaAttributes()
+	mediaDesc.Attributes = b.buildMediaAttributes(b.offerCodecs)
+
+	// RTCP не на соседнем порту (RFC 3605)
+	if attr, ok := localRTCPAttribute(b.transportPair, b.config.Transport.RTCPEnabled, host, port); ok {
+		mediaDesc.Attributes = append(mediaDesc.Attributes, attr)
+	}
+
+	// Потенциальные конфигурации (RFC 5939)
+	if len(b.config.PotentialConfigurations) > 0 {
+		capAttrs, set := buildCapabilityAttributes(b.config.PotentialConfigurations)
+		mediaDesc.Attributes = append(mediaDesc.Attributes, capAttrs...)
+		b.offerCapabilities = set
+	}
 
 	// Добавляем DTMF если включен
 	if b.config.DTMFEnabled {
@@ -213,12 +306,14 @@ func (b *sdpMediaBuilder) CreateOffer() (*sdp.SessionDescription, error) {
 	}
 
 	offer.MediaDescriptions = []*sdp.MediaDescription{mediaDesc}
+	b.config.Quirks.apply(offer)
 
+	b.inboundProbe.setSDP(offer, nil)
 	return offer, nil
 }
 
 // buildMediaAttributes создает атрибуты для медиа описания
-func (b *sdpMediaBuilder) buildMediaAttributes() []sdp.Attribute {
+func (b *sdpMediaBuilder) buildMediaAttributes(codecs []CodecInfo) []sdp.Attribute {
 	var attributes []sdp.Attribute
 
 	// Направление медиа потока
@@ -239,10 +334,22 @@ func (b *sdpMediaBuilder) buildMediaAttributes() []sdp.Attribute {
 		attributes = append(attributes, sdp.NewAttribute("ptime", strconv.Itoa(ptimeMs)))
 	}
 
-	// Payload type атрибут (rtpmap)
-	codecName := getCodecName(b.config.PayloadType)
-	rtpmap := fmt.Sprintf("%d %s/%d", b.config.PayloadType, codecName, b.config.ClockRate)
-	attributes = append(attributes, sdp.NewAttribute("rtpmap", rtpmap))
+	// Подавление пауз (RFC 3108)
+	if b.config.SilenceSuppression != media.SilenceSuppressionDefault {
+		attributes = append(attributes, sdp.NewAttribute(silenceSuppAttribute,
+			formatSilenceSupp(b.config.SilenceSuppression)))
+	}
+
+	// Payload type атрибуты (rtpmap) и параметры кодеков (fmtp)
+	for _, codec := range codecs {
+		rtpmap := formatRtpmap(uint8(codec.PayloadType), codec.Name, codec.ClockRate, codec.Channels)
+		attributes = append(attributes, sdp.NewAttribute("rtpmap", rtpmap))
+
+		if codec.Fmtp != "" {
+			fmtp := fmt.Sprintf("%d %s", codec.PayloadType, codec.Fmtp)
+			attributes = append(attributes, sdp.NewAttribute("fmtp", fmtp))
+		}
+	}
 
 	// Дополнительные атрибуты из конфигурации
 	for key, value := range b.config.CustomAttributes {
@@ -257,7 +364,7 @@ func (b *sdpMediaBuilder) buildDTMFAttributes() []sdp.Attribute {
 	var attributes []sdp.Attribute
 
 	// DTMF rtpmap
-	dtmfRtpmap := fmt.Sprintf("%d telephone-event/8000", b.config.DTMFPayloadType)
+	dtmfRtpmap := fmt.Sprintf("%d telephone-event/%d", b.config.DTMFPayloadType, media.DTMFClockRate)
 	attributes = append(attributes, sdp.NewAttribute("rtpmap", dtmfRtpmap))
 
 	// DTMF fmtp (поддерживаемые события 0-15)
@@ -285,27 +392,67 @@ func (b *sdpMediaBuilder) Start() error {
 	}
 
 	// Запускаем медиа сессию (она сама запустит RTP сессию)
-	if err := b.mediaSession.Start(); err != nil {
-		return WrapSDPError(ErrorCodeSessionStart, b.config.SessionID, err,
-			"Не удалось запустить медиа сессию")
+	if b.mediaSession != nil {
+		if err := b.mediaSession.Start(); err != nil {
+			return WrapSDPError(ErrorCodeSessionStart, b.config.SessionID, err,
+				"Не удалось запустить медиа сессию")
+		}
 	}
 
 	b.started = true
+	if b.answerProcessed {
+		b.inboundProbe.start()
+	}
 	return nil
 }
 
-// ProcessAnswer обрабатывает SDP answer для установки удаленного адреса
+// ProcessAnswer обрабатывает SDP answer для установки удаленного адреса.
+// Идемпотентен для одинакового answer: повтор определяется по версии o= и хешу тела.
 func (b *sdpMediaBuilder) ProcessAnswer(answer *sdp.SessionDescription) error {
 	if answer == nil {
 		return NewSDPErrorWithSession(ErrorCodeSDPParsing, b.config.SessionID,
 			"SDP answer не может быть nil")
 	}
 
+	// Повторный 200 OK несет тот же answer - ресурсы уже настроены
+	hash, err := answerDigest(answer)
+	if err != nil {
+		return WrapSDPError(ErrorCodeSDPParsing, b.config.SessionID, err,
+			"Не удалось сериализовать SDP answer")
+	}
+	if b.answerProcessed && sameOriginVersion(b.answerOrigin, answer.Origin) {
+		if hash == b.answerHash {
+			return nil
+		}
+		return NewSDPErrorWithSession(ErrorCodeAnswerConflict, b.config.SessionID,
+			"SDP answer изменен без увеличения версии o= (%d)", answer.Origin.SessionVersion)
+	}
+
+	if err := b.applyAnswer(answer); err != nil {
+		return err
+	}
+
+	b.answerProcessed = true
+	b.answerOrigin = answer.Origin
+	b.answerHash = hash
+
+	b.inboundProbe.setSDP(nil, answer)
+	if b.started {
+		b.inboundProbe.start()
+	}
+	return nil
+}
+
+// applyAnswer применяет SDP answer к транспорту и медиа сессии
+func (b *sdpMediaBuilder) applyAnswer(answer *sdp.SessionDescription) error {
+
 	// Ищем аудио медиа описание
 	var audioMedia *sdp.MediaDescription
-	for _, media := range answer.MediaDescriptions {
+	audioIndex := -1
+	for i, media := range answer.MediaDescriptions {
 		if media.MediaName.Media == "audio" {
 			audioMedia = media
+			audioIndex = i
 			break
 		}
 	}
@@ -315,6 +462,12 @@ func (b *sdpMediaBuilder) ProcessAnswer(answer *sdp.SessionDescription) error {
 			"Аудио медиа описание не найдено в SDP answer")
 	}
 
+	if err := b.applyAcceptedConfiguration(audioMedia); err != nil {
+		return err
+	}
+
+	b.dtmfNegotiated = b.config.DTMFEnabled && hasFormat(audioMedia, b.config.DTMFPayloadType)
+
 	// Извлекаем информацию о соединении
 	var connectionInfo *sdp.ConnectionInformation
 
@@ -333,6 +486,22 @@ func (b *sdpMediaBuilder) ProcessAnswer(answer *sdp.SessionDescription) error {
 	ip := connectionInfo.Address.Address
 	port := audioMedia.MediaName.Port.Value
 
+	wasOnHold := b.remoteHold
+	defer b.notifyHoldChanged(wasOnHold)
+
+	// Нулевой адрес означает удержание вызова (RFC 2543): прекращаем отправку
+	// и сохраняем текущий удаленный адрес для восстановления потока
+	b.remoteHold = isHoldConnectionAddress(ip)
+	if b.remoteHold {
+		if b.mediaSession != nil {
+			if err := b.mediaSession.SetDirection(media.DirectionInactive); err != nil {
+				return WrapSDPError(ErrorCodeInvalidDirection, b.config.SessionID, err,
+					"Не удалось установить направление медиа потока")
+			}
+		}
+		return nil
+	}
+
 	remoteAddr, err := ParseMediaAddress(
 		fmt.Sprintf("%s %s %s", connectionInfo.NetworkType, connectionInfo.AddressType, ip),
 		port)
@@ -341,44 +510,313 @@ func (b *sdpMediaBuilder) ProcessAnswer(answer *sdp.SessionDescription) error {
 			"Не удалось разобрать адрес соединения из SDP answer")
 	}
 
-	// Обновляем удаленный адрес в транспорте
-	err = b.updateTransportRemoteAddr(remoteAddr)
+	// RTCP адрес из a=rtcp (RFC 3605), без атрибута - RTP порт + 1
+	rtcpAddr, err := remoteRTCPAddress(audioMedia, remoteAddr)
 	if err != nil {
-		return WrapSDPError(ErrorCodeTransportCreation, b.config.SessionID, err,
-			"Не удалось обновить удаленный адрес транспорта")
+		return WrapSDPError(ErrorCodeSDPParsing, b.config.SessionID, err,
+			"Не удалось разобрать RTCP адрес из SDP answer")
+	}
+	previousRTCPAddr := b.remoteRTCPAddr
+	rtcpChanged := rtcpAddr != previousRTCPAddr
+	b.remoteRTCPAddr = rtcpAddr
+
+	// Обновляем удаленный адрес в транспорте. Answer на повторный offer
+	// (re-INVITE) с тем же адресом, портом и протоколом транспорт не
+	// затрагивает, новый адрес применяется к работающим сокетам: поток
+	// продолжается без смены сокетов и SSRC
+	change, changed := Diff(b.appliedAnswer, answer).Stream(audioIndex)
+	if b.appliedAnswer == nil || (changed && change.TransportChanged()) || rtcpChanged {
+		err = b.updateTransportRemoteAddr(remoteAddr)
+		if err != nil {
+			b.remoteRTCPAddr = previousRTCPAddr
+			return WrapSDPError(ErrorCodeTransportCreation, b.config.SessionID, err,
+				"Не удалось обновить удаленный адрес транспорта")
+		}
+		notifyRemoteMediaAddressChanged(b.config.OnRemoteMediaAddressChanged, RemoteMediaAddressChange{
+			SessionID:        b.config.SessionID,
+			PreviousRTPAddr:  b.remoteAddr,
+			RTPAddr:          remoteAddr,
+			PreviousRTCPAddr: previousRTCPAddr,
+			RTCPAddr:         rtcpAddr,
+		})
+		b.remoteAddr = remoteAddr
+	}
+	b.appliedAnswer = answer
+
+	// Answer может выбрать не основной кодек из offer
+	if err := b.applyAnswerPayloadType(audioMedia); err != nil {
+		return err
+	}
+
+	// Частоты RTP clock из rtpmap answer. Для payload types без rtpmap в
+	// answer остаются частоты из нашего offer
+	if b.mediaSession != nil {
+		rates := b.offerClockRates()
+		for pt, rate := range parseRtpmapClockRates(audioMedia) {
+			rates[pt] = rate
+		}
+		b.mediaSession.SetClockRates(rates)
+	}
+
+	// Параметры форматов (fmtp) из answer: annexb=no отключает SID кадры G.729
+	b.remoteFmtp = parseFormatParametersAttributes(audioMedia)
+	// a=silenceSupp:off отключает VAD/CNG независимо от annexb
+	b.silenceSuppression = negotiateSilenceSuppression(b.config.SilenceSuppression,
+		resolveSilenceSuppression(answer, audioMedia))
+	if b.mediaSession != nil {
+		b.mediaSession.SetVADFramesDisabled(b.vadFramesDisabled())
+		b.mediaSession.SetSilenceSuppression(b.silenceSuppression)
+	}
+	if err := b.applyAnswerCodec(); err != nil {
+		return err
+	}
+
+	// Применяем направление из answer (атрибут уровня медиа имеет приоритет над уровнем сессии)
+	if b.mediaSession != nil {
+		direction := negotiateDirection(b.config.Direction, resolveDirectionAttribute(answer, audioMedia))
+		if err := b.mediaSession.SetDirection(direction); err != nil {
+			return WrapSDPError(ErrorCodeInvalidDirection, b.config.SessionID, err,
+				"Не удалось установить направление медиа потока")
+		}
 	}
 
 	return nil
 }
 
-// updateTransportRemoteAddr обновляет удаленный адрес в существующем транспорте
-func (b *sdpMediaBuilder) updateTransportRemoteAddr(remoteAddr string) error {
-	// Проверяем если у нас есть UDP транспорт с SetRemoteAddr методом
-	if udpTransport, ok := b.transportPair.RTP.(*rtp.UDPTransport); ok {
-		// Используем SetRemoteAddr для обновления удаленного адреса
-		err := udpTransport.SetRemoteAddr(remoteAddr)
+// applyAcceptedConfiguration сохраняет потенциальную конфигурацию, выбранную
+// в answer (a=acfg). Answer без acfg использует актуальную конфигурацию
+func (b *sdpMediaBuilder) applyAcceptedConfiguration(audioMedia *sdp.MediaDescription) error {
+	b.acceptedConfig = nil
+	if b.offerCapabilities.transports == nil {
+		return nil
+	}
+
+	config, ok, err := parseAcceptedConfiguration(audioMedia, b.offerCapabilities)
+	if err != nil {
+		return WrapSDPError(ErrorCodeSDPParsing, b.config.SessionID, err,
+			"Некорректная выбранная конфигурация в SDP answer")
+	}
+	if !ok {
+		return nil
+	}
+	if config.Protocol != "" && config.Protocol != strings.Join(audioMedia.MediaName.Protos, "/") {
+		return NewSDPErrorWithSession(ErrorCodeSDPParsing, b.config.SessionID,
+			"Протокол answer %s не соответствует выбранной конфигурации %s",
+			strings.Join(audioMedia.MediaName.Protos, "/"), config.Protocol)
+	}
+	b.acceptedConfig = &config
+	return nil
+}
+
+// AcceptedConfiguration возвращает потенциальную конфигурацию, выбранную в answer
+func (b *sdpMediaBuilder) AcceptedConfiguration() (AcceptedConfiguration, bool) {
+	if b.acceptedConfig == nil {
+		return AcceptedConfiguration{}, false
+	}
+	return *b.acceptedConfig, true
+}
+
+// offerClockRates возвращает частоты RTP clock, объявленные в нашем offer
+func (b *sdpMediaBuilder) offerClockRates() map[media.PayloadType]uint32 {
+	rates := make(map[media.PayloadType]uint32)
+	for _, codec := range b.configuredCodecs() {
+		rates[media.PayloadType(codec.PayloadType)] = codec.ClockRate
+	}
+	if b.config.DTMFEnabled {
+		rates[media.PayloadType(b.config.DTMFPayloadType)] = media.DTMFClockRate
+	}
+	return rates
+}
+
+// vadFramesDisabled возвращает true, если для G.729 не согласован Annex B:
+// annexb=no указан в нашем offer или в answer
+func (b *sdpMediaBuilder) vadFramesDisabled() bool {
+	if media.PayloadType(b.config.PayloadType) != media.PayloadTypeG729 {
+		return false
+	}
+	local, err := ParseFormatParameters(fmt.Sprintf("%d %s", b.config.PayloadType, b.config.Fmtp))
+	if err == nil && !local.AnnexB() {
+		return true
+	}
+	return !b.remoteFmtp[uint8(b.config.PayloadType)].AnnexB()
+}
+
+// primaryCodec возвращает основной кодек конфигурации
+func (b *sdpMediaBuilder) primaryCodec() CodecInfo {
+	return CodecInfo{
+		PayloadType: b.config.PayloadType,
+		Name:        b.codecName(),
+		ClockRate:   b.config.ClockRate,
+		Channels:    b.config.Channels,
+		Ptime:       b.config.Ptime,
+		Fmtp:        b.config.Fmtp,
+	}
+}
+
+// configuredCodecs возвращает основной и дополнительные кодеки конфигурации
+func (b *sdpMediaBuilder) configuredCodecs() []CodecInfo {
+	return append([]CodecInfo{b.primaryCodec()}, b.config.AlternativeCodecs...)
+}
+
+// orderedCodecs возвращает кодеки offer в порядке предпочтения CodecPolicy
+func (b *sdpMediaBuilder) orderedCodecs() []CodecInfo {
+	codecs := b.configuredCodecs()
+	if b.config.CodecPolicy == nil {
+		return codecs
+	}
+	return b.config.CodecPolicy.Order(b.destination(), codecs)
+}
+
+// destination возвращает адрес назначения для CodecPolicy
+func (b *sdpMediaBuilder) destination() string {
+	if b.config.Destination != "" {
+		return b.config.Destination
+	}
+	return b.config.Transport.RemoteAddr
+}
+
+// applyAnswerPayloadType переключает RTP и медиа сессии на кодек, выбранный
+// в answer: первый формат answer среди предложенных в offer (RFC 3264 Section 6.1)
+func (b *sdpMediaBuilder) applyAnswerPayloadType(audioMedia *sdp.MediaDescription) error {
+	offered := b.offerCodecs
+	if offered == nil {
+		offered = b.configuredCodecs()
+	}
+
+	var selected *CodecInfo
+	for _, format := range audioMedia.MediaName.Formats {
+		pt, err := strconv.Atoi(format)
 		if err != nil {
-			return fmt.Errorf("не удалось установить удаленный адрес: %w", err)
+			continue
+		}
+		for i := range offered {
+			if int(offered[i].PayloadType) == pt {
+				selected = &offered[i]
+				break
+			}
 		}
+		if selected != nil {
+			break
+		}
+	}
+	if selected == nil || selected.PayloadType == b.config.PayloadType {
+		return nil
+	}
 
-		// Обновляем RTCP транспорт если есть
-		if b.transportPair.RTCP != nil {
-			if udpRtcpTransport, ok := b.transportPair.RTCP.(*rtp.UDPRTCPTransport); ok {
-				// RTCP порт обычно RTP порт + 1
-				rtcpRemoteAddr, err := adjustPortInAddress(remoteAddr, 1)
-				if err != nil {
-					return fmt.Errorf("не удалось вычислить RTCP адрес: %w", err)
-				}
+	// Выбранный кодек становится основным: от него зависят fmtp, Annex B и
+	// кодек из реестра media
+	previous := b.primaryCodec()
+	b.config.AlternativeCodecs = replaceCodec(b.config.AlternativeCodecs, *selected, previous)
+	b.config.PayloadType = selected.PayloadType
+	b.config.CodecName = selected.Name
+	b.config.ClockRate = selected.ClockRate
+	b.config.Channels = selected.Channels
+	b.config.Fmtp = selected.Fmtp
+
+	if setter, ok := b.rtpSession.(interface {
+		SetPayloadType(rtp.PayloadType, uint32)
+	}); ok {
+		setter.SetPayloadType(selected.PayloadType, selected.ClockRate)
+	}
 
-				err = udpRtcpTransport.SetRemoteAddr(rtcpRemoteAddr)
-				if err != nil {
-					return fmt.Errorf("не удалось установить удаленный RTCP адрес: %w", err)
-				}
-			}
+	if b.mediaSession != nil {
+		if err := b.mediaSession.SetPayloadType(media.PayloadType(selected.PayloadType)); err != nil {
+			return WrapSDPError(ErrorCodeIncompatibleCodec, b.config.SessionID, err,
+				"Не удалось переключить медиа сессию на кодек %s", selected.Name)
+		}
+		if !usesCodecRegistry(uint8(selected.PayloadType)) {
+			b.mediaSession.SetCodec(nil)
 		}
+	}
+	return nil
+}
+
+// replaceCodec заменяет в списке кодек old на replacement
+func replaceCodec(codecs []CodecInfo, old, replacement CodecInfo) []CodecInfo {
+	result := make([]CodecInfo, 0, len(codecs))
+	for _, codec := range codecs {
+		if codec.PayloadType == old.PayloadType {
+			codec = replacement
+		}
+		result = append(result, codec)
+	}
+	return result
+}
+
+// applyAnswerCodec пересоздает кодек динамического payload type с
+// параметрами fmtp, согласованными с answer (например, mode-set AMR)
+func (b *sdpMediaBuilder) applyAnswerCodec() error {
+	pt := uint8(b.config.PayloadType)
+	if !usesCodecRegistry(pt) {
+		return nil
+	}
+
+	local, err := ParseFormatParameters(fmt.Sprintf("%d %s", pt, b.config.Fmtp))
+	if err != nil {
+		return WrapSDPError(ErrorCodeInvalidConfig, b.config.SessionID, err,
+			"Некорректные параметры fmtp в конфигурации")
+	}
+	fmtp, ok := negotiateFormatParameters(b.codecName(), local, b.remoteFmtp[pt])
+	if !ok {
+		return NewSDPErrorWithSession(ErrorCodeIncompatibleCodec, b.config.SessionID,
+			"Параметры fmtp answer несовместимы с offer: %q", b.remoteFmtp[pt].Raw)
+	}
 
+	// Режим answer может требовать другой ptime (iLBC mode=30)
+	current := b.config.Ptime
+	if b.mediaSession != nil {
+		current = b.mediaSession.GetPtime()
+	}
+	ptime := negotiatePtime(b.codecName(), fmtp, current)
+	codec, err := newRegistryCodec(b.codecName(), pt, b.config.ClockRate, b.config.Channels, ptime, fmtp)
+	if err != nil {
+		return WrapSDPError(ErrorCodeIncompatibleCodec, b.config.SessionID, err,
+			"Не удалось создать кодек %s", b.codecName())
+	}
+	if b.mediaSession == nil {
 		return nil
 	}
+	if ptime != b.mediaSession.GetPtime() {
+		if err := b.mediaSession.SetPtime(ptime); err != nil {
+			return WrapSDPError(ErrorCodeIncompatibleCodec, b.config.SessionID, err,
+				"Не удалось установить ptime %v", ptime)
+		}
+	}
+	b.mediaSession.SetCodec(codec)
+	return nil
+}
+
+// GetRemoteFormatParameters возвращает параметры fmtp из answer для payload type
+func (b *sdpMediaBuilder) GetRemoteFormatParameters(pt uint8) (FormatParameters, bool) {
+	params, ok := b.remoteFmtp[pt]
+	return params, ok
+}
+
+// notifyHoldChanged вызывает OnHoldChanged если состояние удержания изменилось
+func (b *sdpMediaBuilder) notifyHoldChanged(wasOnHold bool) {
+	if wasOnHold != b.remoteHold && b.config.OnHoldChanged != nil {
+		b.config.OnHoldChanged(b.remoteHold)
+	}
+}
+
+// IsOnHold возвращает true, если удаленная сторона поставила вызов на удержание
+func (b *sdpMediaBuilder) IsOnHold() bool {
+	return b.remoteHold
+}
+
+// updateTransportRemoteAddr обновляет удаленный адрес в существующем транспорте
+func (b *sdpMediaBuilder) updateTransportRemoteAddr(remoteAddr string) error {
+	rtcpRemoteAddr := b.remoteRTCPAddr
+	if rtcpRemoteAddr == "" {
+		var err error
+		if rtcpRemoteAddr, err = adjustPortInAddress(remoteAddr, 1); err != nil {
+			return fmt.Errorf("не удалось вычислить RTCP адрес: %w", err)
+		}
+	}
+	err := b.transportPair.SetRemoteAddr(remoteAddr, rtcpRemoteAddr)
+	if !errors.Is(err, rtp.ErrRemoteAddrUnsupported) {
+		return err
+	}
 
 	// Fallback к полному пересозданию транспорта для других типов
 	return b.recreateTransportWithRemoteAddr(remoteAddr)
@@ -398,6 +836,8 @@ func (b *sdpMediaBuilder) recreateTransportWithRemoteAddr(remoteAddr string) err
 	newTransportConfig := b.config.Transport
 	newTransportConfig.RemoteAddr = remoteAddr
 	newTransportConfig.LocalAddr = ":0" // Используем новый порт
+	newTransportConfig.randomSource = b.config.RandomSource
+	newTransportConfig.rtcpRemoteAddr = b.remoteRTCPAddr
 
 	// Создаем новую пару транспортов с удаленным адресом
 	newTransportPair, err := CreateTransportPair(newTransportConfig)
@@ -406,7 +846,9 @@ func (b *sdpMediaBuilder) recreateTransportWithRemoteAddr(remoteAddr string) err
 	}
 
 	// Заменяем транспорт
+	b.callMu.Lock()
 	b.transportPair = newTransportPair
+	b.callMu.Unlock()
 
 	// Если сессия уже запущена, нужно обновить транспорт в RTP сессии
 	if b.started && b.rtpSession != nil {
@@ -432,10 +874,11 @@ func (b *sdpMediaBuilder) recreateTransportWithRemoteAddr(remoteAddr string) err
 // recreateRTPSession пересоздает RTP сессию с новым транспортом
 func (b *sdpMediaBuilder) recreateRTPSession() error {
 	rtpConfig := rtp.SessionConfig{
-		PayloadType: b.config.PayloadType,
-		MediaType:   b.config.MediaType,
-		ClockRate:   b.config.ClockRate,
-		Transport:   b.transportPair.RTP,
+		PayloadType:  b.config.PayloadType,
+		MediaType:    b.config.MediaType,
+		ClockRate:    b.config.ClockRate,
+		Transport:    b.transportPair.RTP,
+		RandomSource: b.config.RandomSource,
 		LocalSDesc: rtp.SourceDescription{
 			CNAME: fmt.Sprintf("%s@%s", b.config.SessionID, getLocalHostname()),
 			NAME:  b.config.SessionName,
@@ -465,7 +908,9 @@ func (b *sdpMediaBuilder) recreateRTPSession() error {
 	}
 
 	// Заменяем RTP сессию
+	b.callMu.Lock()
 	b.rtpSession = rtpSession
+	b.callMu.Unlock()
 
 	// Добавляем новую сессию в медиа сессию
 	if b.mediaSession != nil {
@@ -478,39 +923,55 @@ func (b *sdpMediaBuilder) recreateRTPSession() error {
 	return nil
 }
 
-// Stop останавливает все сессии и освобождает ресурсы
-func (b *sdpMediaBuilder) Stop() error {
-	if !b.started {
-		return nil
+// Close завершает медиа вызов в порядке ShutdownStep и освобождает
+// ресурсы. Повторные вызовы ничего не делают и возвращают результат первого
+func (b *sdpMediaBuilder) Close() error {
+	if b.closed {
+		return b.closeErr
 	}
-
-	var lastErr error
-
-	// Останавливаем медиа сессию
-	if b.mediaSession != nil {
-		if err := b.mediaSession.Stop(); err != nil {
-			lastErr = err
-		}
+	b.closed = true
+	b.inboundProbe.stop()
+
+	teardown := mediaTeardown{
+		sessionID:     b.config.SessionID,
+		mediaSession:  b.mediaSession,
+		rtpSession:    b.rtpSession,
+		transportPair: b.transportPair,
 	}
-
-	// Останавливаем RTP сессию
-	if b.rtpSession != nil {
-		if err := b.rtpSession.Stop(); err != nil {
-			lastErr = err
+	if b.started {
+		teardown.report = func() {
+			reportOneWayAudio(b.config.OnOneWayAudio, b.OneWayAudioReport)
+
+			// Качество вызова учитывается при выборе кодеков следующих вызовов.
+			// Ошибка сохранения истории не мешает завершению вызова
+			if b.config.CodecPolicy != nil && b.mediaSession != nil {
+				if quality, ok := sessionNetworkQuality(b.mediaSession, b.config.ClockRate); ok {
+					_ = b.config.CodecPolicy.Record(b.destination(), quality)
+				}
+			}
 		}
 	}
-
-	// Закрываем транспорты
-	b.cleanup()
-
 	b.started = false
 
-	if lastErr != nil {
-		return WrapSDPError(ErrorCodeSessionStop, b.config.SessionID, lastErr,
+	if err := teardown.run(); err != nil {
+		b.closeErr = WrapSDPError(ErrorCodeSessionStop, b.config.SessionID, err,
 			"Ошибка при остановке сессий")
 	}
+	return b.closeErr
+}
 
-	return nil
+// Stop останавливает все сессии и освобождает ресурсы (см. Close).
+//
+// Deprecated: используйте Close
+func (b *sdpMediaBuilder) Stop() error {
+	return b.Close()
+}
+
+// mediaCall возвращает транспорт, RTP и медиа сессии вызова
+func (b *sdpMediaBuilder) mediaCall() (*rtp.TransportPair, rtp.SessionRTP, *media.MediaSession) {
+	b.callMu.Lock()
+	defer b.callMu.Unlock()
+	return b.transportPair, b.rtpSession, b.mediaSession
 }
 
 // cleanup освобождает ресурсы транспортов
@@ -537,6 +998,14 @@ func getLocalHostname() string {
 	return "127.0.0.1"
 }
 
+// codecName возвращает имя кодека для rtpmap и реестра кодеков media
+func (b *sdpMediaBuilder) codecName() string {
+	if b.config.CodecName != "" {
+		return b.config.CodecName
+	}
+	return getCodecName(b.config.PayloadType)
+}
+
 // getCodecName возвращает имя кодека по payload type
 func getCodecName(pt rtp.PayloadType) string {
 	switch pt {
@@ -552,6 +1021,8 @@ func getCodecName(pt rtp.PayloadType) string {
 		return "G728"
 	case rtp.PayloadTypeG729:
 		return "G729"
+	case rtp.PayloadTypeL16_2CH, rtp.PayloadTypeL16_1CH:
+		return "L16"
 	default:
 		return fmt.Sprintf("codec%d", pt)
 	}
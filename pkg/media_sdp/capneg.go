@@ -0,0 +1,284 @@
+package media_sdp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pion/sdp/v3"
+)
+
+// Атрибуты согласования возможностей SDP (RFC 5939)
+const (
+	attrTransportCap = "tcap" // a=tcap:<номер> <протокол> [<протокол>...]
+	attrAttributeCap = "acap" // a=acap:<номер> <атрибут>
+	attrPotentialCfg = "pcfg" // a=pcfg:<номер> [t=<tcap>] [a=<acap>,...]
+	attrAcceptedCfg  = "acfg" // a=acfg:<номер> [t=<tcap>] [a=<acap>,...]
+)
+
+// PotentialConfiguration - потенциальная конфигурация медиа потока для offer
+// (RFC 5939). Offer содержит обычную (актуальную) конфигурацию в m= строке
+// и потенциальные конфигурации, одну из которых может выбрать answerer,
+// например RTP/SAVP с атрибутом crypto при актуальной RTP/AVP.
+type PotentialConfiguration struct {
+	// Protocol - транспортный протокол конфигурации, например "RTP/SAVP".
+	// Пустое значение - протокол m= строки
+	Protocol string
+	// Attributes - атрибуты конфигурации в виде "имя:значение" или "имя",
+	// например "crypto:1 AES_CM_128_HMAC_SHA1_80 inline:..."
+	Attributes []string
+}
+
+// AcceptedConfiguration - потенциальная конфигурация, выбранная answerer
+// (a=acfg в answer)
+type AcceptedConfiguration struct {
+	// Number - номер потенциальной конфигурации (a=pcfg) в offer
+	Number     int
+	Protocol   string
+	Attributes []string
+
+	transport  int   // Номер tcap, 0 - протокол m= строки
+	attributes []int // Номера acap
+}
+
+// capabilitySet - пронумерованные возможности offer (a=tcap и a=acap)
+type capabilitySet struct {
+	transports map[int]string
+	attributes map[int]string
+}
+
+func (c PotentialConfiguration) validate() error {
+	if c.Protocol == "" && len(c.Attributes) == 0 {
+		return fmt.Errorf("потенциальная конфигурация не содержит протокол и атрибуты")
+	}
+	if strings.ContainsAny(c.Protocol, " \t") {
+		return fmt.Errorf("некорректный протокол %q", c.Protocol)
+	}
+	for _, attr := range c.Attributes {
+		if strings.TrimSpace(attr) == "" {
+			return fmt.Errorf("пустой атрибут потенциальной конфигурации")
+		}
+	}
+	return nil
+}
+
+// buildCapabilityAttributes создает атрибуты tcap, acap и pcfg для
+// потенциальных конфигураций. Конфигурации нумеруются по порядку, меньший
+// номер означает больший приоритет (RFC 5939 Section 3.5.1)
+func buildCapabilityAttributes(configs []PotentialConfiguration) ([]sdp.Attribute, capabilitySet) {
+	set := capabilitySet{transports: map[int]string{}, attributes: map[int]string{}}
+	transportNums := map[string]int{}
+	attributeNums := map[string]int{}
+
+	var tcaps, acaps, pcfgs []sdp.Attribute
+	for i, config := range configs {
+		var params []string
+		if config.Protocol != "" {
+			num, ok := transportNums[config.Protocol]
+			if !ok {
+				num = len(transportNums) + 1
+				transportNums[config.Protocol] = num
+				set.transports[num] = config.Protocol
+				tcaps = append(tcaps, sdp.NewAttribute(attrTransportCap, fmt.Sprintf("%d %s", num, config.Protocol)))
+			}
+			params = append(params, "t="+strconv.Itoa(num))
+		}
+
+		if len(config.Attributes) > 0 {
+			nums := make([]string, 0, len(config.Attributes))
+			for _, attr := range config.Attributes {
+				num, ok := attributeNums[attr]
+				if !ok {
+					num = len(attributeNums) + 1
+					attributeNums[attr] = num
+					set.attributes[num] = attr
+					acaps = append(acaps, sdp.NewAttribute(attrAttributeCap, fmt.Sprintf("%d %s", num, attr)))
+				}
+				nums = append(nums, strconv.Itoa(num))
+			}
+			params = append(params, "a="+strings.Join(nums, ","))
+		}
+
+		pcfgs = append(pcfgs, sdp.NewAttribute(attrPotentialCfg,
+			strconv.Itoa(i+1)+" "+strings.Join(params, " ")))
+	}
+
+	attributes := append(tcaps, acaps...)
+	return append(attributes, pcfgs...), set
+}
+
+// parseCapabilitySet извлекает атрибуты tcap и acap медиа описания
+func parseCapabilitySet(mediaDesc *sdp.MediaDescription) (capabilitySet, error) {
+	set := capabilitySet{transports: map[int]string{}, attributes: map[int]string{}}
+	for _, attr := range mediaDesc.Attributes {
+		switch attr.Key {
+		case attrTransportCap:
+			// Список протоколов нумеруется последовательно с первого номера
+			fields := strings.Fields(attr.Value)
+			if len(fields) < 2 {
+				return set, fmt.Errorf("некорректный атрибут tcap: %q", attr.Value)
+			}
+			first, err := strconv.Atoi(fields[0])
+			if err != nil || first < 1 {
+				return set, fmt.Errorf("некорректный номер tcap: %q", attr.Value)
+			}
+			for i, proto := range fields[1:] {
+				set.transports[first+i] = proto
+			}
+		case attrAttributeCap:
+			parts := strings.SplitN(strings.TrimSpace(attr.Value), " ", 2)
+			num, err := strconv.Atoi(parts[0])
+			if len(parts) != 2 || err != nil || num < 1 {
+				return set, fmt.Errorf("некорректный атрибут acap: %q", attr.Value)
+			}
+			set.attributes[num] = strings.TrimSpace(parts[1])
+		}
+	}
+	return set, nil
+}
+
+// parseConfigurationValue разбирает значение pcfg или acfg. Каждый параметр
+// может содержать альтернативы через "|", результат - список вариантов
+// конфигурации в порядке предпочтения.
+//
+// Поддерживается базовый синтаксис RFC 5939: t=<tcap>[|<tcap>...] и
+// a=<acap>[,<acap>...][|...]. Необязательные атрибуты ([...]) и удаление
+// атрибутов (a=-m:, a=-s:) не поддерживаются: такие варианты пропускаются.
+// Неизвестные параметры расширений игнорируются.
+func parseConfigurationValue(value string, set capabilitySet) ([]AcceptedConfiguration, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("пустая конфигурация")
+	}
+	number, err := strconv.Atoi(fields[0])
+	if err != nil || number < 1 {
+		return nil, fmt.Errorf("некорректный номер конфигурации: %q", value)
+	}
+
+	transports := []int{0}
+	attributeSets := [][]int{nil}
+	for _, field := range fields[1:] {
+		switch {
+		case strings.HasPrefix(field, "t="):
+			transports = nil
+			for _, alt := range strings.Split(field[2:], "|") {
+				num, err := strconv.Atoi(alt)
+				if err != nil {
+					return nil, fmt.Errorf("некорректный параметр %q", field)
+				}
+				if _, ok := set.transports[num]; ok {
+					transports = append(transports, num)
+				}
+			}
+		case strings.HasPrefix(field, "a="):
+			attributeSets = nil
+		alternatives:
+			for _, alt := range strings.Split(field[2:], "|") {
+				var nums []int
+				for _, item := range strings.Split(alt, ",") {
+					num, err := strconv.Atoi(item)
+					if err != nil {
+						continue alternatives
+					}
+					if _, ok := set.attributes[num]; !ok {
+						continue alternatives
+					}
+					nums = append(nums, num)
+				}
+				attributeSets = append(attributeSets, nums)
+			}
+		}
+	}
+
+	var configs []AcceptedConfiguration
+	for _, transport := range transports {
+		for _, attrs := range attributeSets {
+			config := AcceptedConfiguration{
+				Number:     number,
+				Protocol:   set.transports[transport],
+				transport:  transport,
+				attributes: attrs,
+			}
+			for _, num := range attrs {
+				config.Attributes = append(config.Attributes, set.attributes[num])
+			}
+			configs = append(configs, config)
+		}
+	}
+	return configs, nil
+}
+
+// parsePotentialConfigurations возвращает варианты потенциальных конфигураций
+// offer в порядке предпочтения (по номеру pcfg)
+func parsePotentialConfigurations(mediaDesc *sdp.MediaDescription) ([]AcceptedConfiguration, error) {
+	set, err := parseCapabilitySet(mediaDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []AcceptedConfiguration
+	for _, attr := range mediaDesc.Attributes {
+		if attr.Key != attrPotentialCfg {
+			continue
+		}
+		variants, err := parseConfigurationValue(attr.Value, set)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, variants...)
+	}
+	sort.SliceStable(configs, func(i, j int) bool { return configs[i].Number < configs[j].Number })
+	return configs, nil
+}
+
+// parseAcceptedConfiguration извлекает конфигурацию, выбранную answerer,
+// из атрибута acfg answer. Номера tcap и acap относятся к нашему offer
+func parseAcceptedConfiguration(mediaDesc *sdp.MediaDescription, set capabilitySet) (AcceptedConfiguration, bool, error) {
+	value, ok := mediaDesc.Attribute(attrAcceptedCfg)
+	if !ok {
+		return AcceptedConfiguration{}, false, nil
+	}
+	configs, err := parseConfigurationValue(value, set)
+	if err != nil {
+		return AcceptedConfiguration{}, false, err
+	}
+	if len(configs) != 1 {
+		return AcceptedConfiguration{}, false, fmt.Errorf("некорректный атрибут acfg: %q", value)
+	}
+	return configs[0], true, nil
+}
+
+// sdpAttributes возвращает атрибуты конфигурации для m= секции answer
+func (c AcceptedConfiguration) sdpAttributes() []sdp.Attribute {
+	attributes := make([]sdp.Attribute, 0, len(c.Attributes)+1)
+	for _, attr := range c.Attributes {
+		if key, value, ok := strings.Cut(attr, ":"); ok {
+			attributes = append(attributes, sdp.NewAttribute(key, value))
+		} else {
+			attributes = append(attributes, sdp.NewPropertyAttribute(attr))
+		}
+	}
+
+	// a=acfg повторяет номера выбранных возможностей из offer
+	params := []string{strconv.Itoa(c.Number)}
+	if c.transport > 0 {
+		params = append(params, "t="+strconv.Itoa(c.transport))
+	}
+	if len(c.attributes) > 0 {
+		nums := make([]string, len(c.attributes))
+		for i, num := range c.attributes {
+			nums[i] = strconv.Itoa(num)
+		}
+		params = append(params, "a="+strings.Join(nums, ","))
+	}
+	return append(attributes, sdp.NewAttribute(attrAcceptedCfg, strings.Join(params, " ")))
+}
+
+// protos возвращает протокол m= строки для конфигурации
+func protos(protocol string, fallback []string) []string {
+	if protocol == "" {
+		return fallback
+	}
+	return strings.Split(protocol, "/")
+}
@@ -0,0 +1,302 @@
+package media_sdp
+
+import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+)
+
+// CodecPolicy упорядочивает кодеки offer по качеству сети до адресата.
+//
+// Для каждого префикса адресов назначения (по умолчанию /24 для IPv4 и /64
+// для IPv6) хранится сглаженная история потерь и jitter завершенных
+// вызовов. На линиях с потерями вперед переносятся кодеки с низким битрейтом
+// (G.729), на чистых линиях - широкополосные (G.722). Без истории порядок
+// кодеков из конфигурации не меняется.
+//
+// История сохраняется в JSON файл CodecPolicyConfig.StatePath и
+// загружается при создании политики. Методы безопасны для одновременного
+// вызова.
+type CodecPolicy struct {
+	config CodecPolicyConfig
+
+	mutex   sync.RWMutex
+	history map[string]*NetworkQuality
+}
+
+// CodecPolicyConfig содержит настройки политики выбора кодеков
+type CodecPolicyConfig struct {
+	// StatePath - файл истории качества сети, пусто - история только в памяти
+	StatePath string
+
+	// Длина префикса адреса назначения (по умолчанию 24 и 64)
+	PrefixBitsV4 int
+	PrefixBitsV6 int
+
+	// LossyThreshold - доля потерь, начиная с которой линия считается
+	// линией с потерями (по умолчанию 0.03)
+	LossyThreshold float64
+
+	// CleanLossThreshold и CleanJitterThreshold - границы чистой линии
+	// (по умолчанию 0.005 и 20ms)
+	CleanLossThreshold   float64
+	CleanJitterThreshold time.Duration
+
+	// Smoothing - вес нового измерения в скользящем среднем (по умолчанию 0.3)
+	Smoothing float64
+}
+
+// NetworkQuality содержит измеренное или сглаженное качество сети
+type NetworkQuality struct {
+	PacketLoss float64       `json:"packet_loss"` // Доля потерянных пакетов (0.0-1.0)
+	Jitter     time.Duration `json:"jitter"`      // Jitter входящего потока
+	Samples    int           `json:"samples"`     // Количество учтенных вызовов
+	Updated    time.Time     `json:"updated"`
+}
+
+// NetworkClass - класс линии по истории качества
+type NetworkClass int
+
+const (
+	NetworkUnknown NetworkClass = iota // Нет истории
+	NetworkClean                       // Чистая линия: широкополосные кодеки
+	NetworkNormal                      // Порядок из конфигурации
+	NetworkLossy                       // Линия с потерями: кодеки с низким битрейтом
+)
+
+// codecBitrates - битрейт кодеков в кбит/с для упорядочивания на линиях с потерями
+var codecBitrates = map[string]int{
+	"G723":   6,
+	"G729":   8,
+	"AMR":    12,
+	"GSM":    13,
+	"ILBC":   15,
+	"SPEEX":  15,
+	"G728":   16,
+	"AMR-WB": 23,
+	"PCMU":   64,
+	"PCMA":   64,
+	"G722":   64,
+	"L16":    128,
+}
+
+// DefaultCodecPolicyConfig возвращает настройки политики по умолчанию
+func DefaultCodecPolicyConfig() CodecPolicyConfig {
+	return CodecPolicyConfig{
+		PrefixBitsV4:         24,
+		PrefixBitsV6:         64,
+		LossyThreshold:       0.03,
+		CleanLossThreshold:   0.005,
+		CleanJitterThreshold: 20 * time.Millisecond,
+		Smoothing:            0.3,
+	}
+}
+
+// NewCodecPolicy создает политику и загружает сохраненную историю
+func NewCodecPolicy(config CodecPolicyConfig) (*CodecPolicy, error) {
+	defaults := DefaultCodecPolicyConfig()
+	if config.PrefixBitsV4 <= 0 || config.PrefixBitsV4 > 32 {
+		config.PrefixBitsV4 = defaults.PrefixBitsV4
+	}
+	if config.PrefixBitsV6 <= 0 || config.PrefixBitsV6 > 128 {
+		config.PrefixBitsV6 = defaults.PrefixBitsV6
+	}
+	if config.LossyThreshold <= 0 {
+		config.LossyThreshold = defaults.LossyThreshold
+	}
+	if config.CleanLossThreshold <= 0 {
+		config.CleanLossThreshold = defaults.CleanLossThreshold
+	}
+	if config.CleanJitterThreshold <= 0 {
+		config.CleanJitterThreshold = defaults.CleanJitterThreshold
+	}
+	if config.Smoothing <= 0 || config.Smoothing > 1 {
+		config.Smoothing = defaults.Smoothing
+	}
+
+	policy := &CodecPolicy{
+		config:  config,
+		history: make(map[string]*NetworkQuality),
+	}
+
+	if config.StatePath != "" {
+		data, err := os.ReadFile(config.StatePath)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, &policy.history); err != nil {
+				return nil, WrapSDPError(ErrorCodeInvalidConfig, "", err,
+					"Некорректный файл истории качества сети %s", config.StatePath)
+			}
+		case !os.IsNotExist(err):
+			return nil, WrapSDPError(ErrorCodeInvalidConfig, "", err,
+				"Не удалось прочитать историю качества сети %s", config.StatePath)
+		}
+	}
+
+	return policy, nil
+}
+
+// destinationPrefix возвращает ключ истории для адреса назначения
+// (IP, host:port или имя хоста)
+func (p *CodecPolicy) destinationPrefix(destination string) string {
+	host := destination
+	if h, _, err := net.SplitHostPort(destination); err == nil {
+		host = h
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	if err != nil {
+		return strings.ToLower(host)
+	}
+	addr = addr.Unmap()
+
+	bits := p.config.PrefixBitsV6
+	if addr.Is4() {
+		bits = p.config.PrefixBitsV4
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
+}
+
+// Record учитывает качество завершенного вызова к адресату и сохраняет
+// историю, если указан StatePath
+func (p *CodecPolicy) Record(destination string, quality NetworkQuality) error {
+	if destination == "" {
+		return nil
+	}
+	key := p.destinationPrefix(destination)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	entry, ok := p.history[key]
+	if !ok {
+		entry = &NetworkQuality{PacketLoss: quality.PacketLoss, Jitter: quality.Jitter}
+		p.history[key] = entry
+	} else {
+		alpha := p.config.Smoothing
+		entry.PacketLoss = alpha*quality.PacketLoss + (1-alpha)*entry.PacketLoss
+		entry.Jitter = time.Duration(alpha*float64(quality.Jitter) + (1-alpha)*float64(entry.Jitter))
+	}
+	entry.Samples++
+	entry.Updated = time.Now()
+
+	if p.config.StatePath == "" {
+		return nil
+	}
+	// Запись под блокировкой сохраняет порядок обновлений файла
+	data, err := json.MarshalIndent(p.history, "", "  ")
+	if err != nil {
+		return WrapSDPError(ErrorCodeInvalidConfig, "", err, "Не удалось сохранить историю качества сети")
+	}
+	return p.save(data)
+}
+
+// save атомарно записывает историю в StatePath
+func (p *CodecPolicy) save(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(p.config.StatePath), ".codec-policy-*")
+	if err != nil {
+		return WrapSDPError(ErrorCodeInvalidConfig, "", err, "Не удалось сохранить историю качества сети")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return WrapSDPError(ErrorCodeInvalidConfig, "", err, "Не удалось сохранить историю качества сети")
+	}
+	if err := tmp.Close(); err != nil {
+		return WrapSDPError(ErrorCodeInvalidConfig, "", err, "Не удалось сохранить историю качества сети")
+	}
+	if err := os.Rename(tmp.Name(), p.config.StatePath); err != nil {
+		return WrapSDPError(ErrorCodeInvalidConfig, "", err, "Не удалось сохранить историю качества сети")
+	}
+	return nil
+}
+
+// Quality возвращает сглаженное качество сети до адресата
+func (p *CodecPolicy) Quality(destination string) (NetworkQuality, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	entry, ok := p.history[p.destinationPrefix(destination)]
+	if !ok {
+		return NetworkQuality{}, false
+	}
+	return *entry, true
+}
+
+// Classify возвращает класс линии до адресата
+func (p *CodecPolicy) Classify(destination string) NetworkClass {
+	quality, ok := p.Quality(destination)
+	if !ok {
+		return NetworkUnknown
+	}
+
+	switch {
+	case quality.PacketLoss >= p.config.LossyThreshold:
+		return NetworkLossy
+	case quality.PacketLoss <= p.config.CleanLossThreshold && quality.Jitter <= p.config.CleanJitterThreshold:
+		return NetworkClean
+	default:
+		return NetworkNormal
+	}
+}
+
+// Order возвращает кодеки в порядке предпочтения для адресата. Исходный
+// срез не изменяется, кодеки с одинаковым приоритетом сохраняют порядок
+func (p *CodecPolicy) Order(destination string, codecs []CodecInfo) []CodecInfo {
+	result := append([]CodecInfo(nil), codecs...)
+
+	switch p.Classify(destination) {
+	case NetworkLossy:
+		sort.SliceStable(result, func(i, j int) bool {
+			return codecBitrate(result[i]) < codecBitrate(result[j])
+		})
+	case NetworkClean:
+		sort.SliceStable(result, func(i, j int) bool {
+			return isWideband(result[i]) && !isWideband(result[j])
+		})
+	}
+	return result
+}
+
+// codecBitrate возвращает битрейт кодека, неизвестные кодеки - в конце
+func codecBitrate(codec CodecInfo) int {
+	if bitrate, ok := codecBitrates[strings.ToUpper(codec.Name)]; ok {
+		return bitrate
+	}
+	return int(^uint(0) >> 1)
+}
+
+// isWideband проверяет, передает ли кодек широкополосное аудио. Для G.722
+// частота rtpmap равна 8000 (RFC 3551), поэтому он проверяется по имени
+func isWideband(codec CodecInfo) bool {
+	name := strings.ToUpper(codec.Name)
+	return name == "G722" || name == "AMR-WB" || codec.ClockRate >= 16000
+}
+
+// sessionNetworkQuality вычисляет качество входящего потока медиа сессии по
+// статистике RTCP. false - пакеты не были получены
+func sessionNetworkQuality(session *media.MediaSession, clockRate uint32) (NetworkQuality, bool) {
+	stats := session.GetRTCPStatistics()
+	total := uint64(stats.PacketsReceived) + uint64(stats.PacketsLost)
+	if total == 0 || clockRate == 0 {
+		return NetworkQuality{}, false
+	}
+
+	return NetworkQuality{
+		PacketLoss: float64(stats.PacketsLost) / float64(total),
+		Jitter:     time.Duration(uint64(stats.Jitter) * uint64(time.Second) / uint64(clockRate)),
+	}, true
+}
@@ -1,6 +1,7 @@
 package media_sdp
 
 import (
+	"io"
 	"time"
 
 	"github.com/arzzra/soft_phone/pkg/media"
@@ -29,6 +30,45 @@ type TransportConfig struct {
 	// RTCP настройки
 	RTCPEnabled bool
 	RTCPMuxMode rtp.RTCPMuxMode // Мультиплексирование RTCP
+
+	// SocketOptions - DSCP, буферы и другие опции сокетов RTP и RTCP (опционально).
+	// Результат применения доступен через SocketOptionsReport транспорта
+	SocketOptions *rtp.SocketOptions
+
+	// PortRange - диапазон локальных RTP портов (опционально). Порт из
+	// LocalAddr игнорируется, при отказе ОС в bind перебираются другие порты
+	// диапазона, затем AlternatePortRanges. Ошибка содержит причину для
+	// каждого порта (*PortAllocationError)
+	PortRange           *PortRange
+	AlternatePortRanges []PortRange
+	MaxPortAttempts     int // Попыток bind в одном диапазоне, по умолчанию DefaultMaxPortAttempts
+
+	// randomSource - источник для выбора порта из PortRange
+	// (BuilderConfig.RandomSource или HandlerConfig.RandomSource)
+	randomSource io.Reader
+	// dryRun - таблица портов вместо сокетов (BuilderConfig.DryRun или HandlerConfig.DryRun)
+	dryRun *DryRun
+	// rtcpRemoteAddr - удаленный RTCP адрес из a=rtcp, пусто - RemoteAddr порт + 1
+	rtcpRemoteAddr string
+}
+
+// validate проверяет диапазоны портов транспорта
+func (c *TransportConfig) validate() error {
+	if c.LocalAddr == "" {
+		return NewSDPError(ErrorCodeInvalidConfig, "Transport.LocalAddr не может быть пустым")
+	}
+	if c.PortRange == nil {
+		if len(c.AlternatePortRanges) > 0 {
+			return NewSDPError(ErrorCodeInvalidConfig, "Transport.AlternatePortRanges требует PortRange")
+		}
+		return nil
+	}
+	for _, r := range append([]PortRange{*c.PortRange}, c.AlternatePortRanges...) {
+		if err := r.validate(); err != nil {
+			return NewSDPError(ErrorCodeInvalidConfig, "Transport: %v", err)
+		}
+	}
+	return nil
 }
 
 // BuilderConfig содержит конфигурацию для создания SDP Offer
@@ -41,9 +81,21 @@ type BuilderConfig struct {
 	// Медиа параметры
 	MediaType   rtp.MediaType
 	PayloadType rtp.PayloadType
+	CodecName   string // Имя кодека в rtpmap, обязательно для динамического payload type (96-127)
 	ClockRate   uint32
+	Channels    uint8 // Количество каналов в rtpmap, 0 - один канал (2 для L16 стерео)
 	Ptime       time.Duration
 	Direction   media.Direction
+	Fmtp        string // Параметры fmtp кодека без payload type, например "annexb=no"
+
+	// AlternativeCodecs - дополнительные кодеки offer после основного
+	// (PayloadType). Answer может выбрать любой из предложенных кодеков
+	AlternativeCodecs []CodecInfo
+
+	// CodecPolicy упорядочивает кодеки offer по истории качества сети до
+	// Destination и учитывает качество вызова при Stop (опционально)
+	CodecPolicy *CodecPolicy
+	Destination string // Адрес назначения (IP или host:port), по умолчанию Transport.RemoteAddr
 
 	// Транспорт
 	Transport TransportConfig
@@ -54,9 +106,52 @@ type BuilderConfig struct {
 	// Дополнительные SDP атрибуты
 	CustomAttributes map[string]string
 
+	// SilenceSuppression - подавление пауз в offer (a=silenceSupp, RFC 3108).
+	// SilenceSuppressionOff отключает VAD/CNG вызова, Default - атрибут не
+	// добавляется
+	SilenceSuppression media.SilenceSuppression
+
+	// Quirks - особенности SDP оператора или транка, применяемые к offer
+	// (опционально, см. SDPQuirks)
+	Quirks *SDPQuirks
+
+	// PotentialConfigurations - потенциальные конфигурации offer (RFC 5939),
+	// например RTP/SAVP с crypto в дополнение к RTP/AVP в m= строке.
+	// Выбранная answerer конфигурация доступна через AcceptedConfiguration
+	PotentialConfigurations []PotentialConfiguration
+
 	// DTMF поддержка
 	DTMFEnabled     bool
 	DTMFPayloadType uint8 // RFC 4733, обычно 101
+
+	// OnHoldChanged вызывается при переходе удаленной стороны в удержание
+	// (c=0.0.0.0 в SDP answer) и при выходе из него
+	OnHoldChanged func(onHold bool)
+
+	// OnRemoteMediaAddressChanged вызывается, когда answer на повторный offer
+	// меняет удаленный RTP или RTCP адрес и он применен к транспорту
+	OnRemoteMediaAddressChanged func(change RemoteMediaAddressChange)
+
+	// OnOneWayAudio вызывается в Stop, если число пакетов в направлениях
+	// заметно различается, с отчетом для записи в CDR
+	OnOneWayAudio func(report OneWayAudioReport)
+
+	// NoInboundMediaTimeout - пробное окно после ответа: если за это время
+	// при отправке RTP не получен ни один пакет, вызывается OnNoInboundMedia.
+	// 0 - проверка отключена
+	NoInboundMediaTimeout time.Duration
+	// OnNoInboundMedia вызывается один раз из отдельной горутины с
+	// диагностикой вызова без входящего медиа
+	OnNoInboundMedia func(report NoInboundMediaReport)
+
+	// RandomSource - источник случайных данных для выбора порта из
+	// Transport.PortRange, SSRC и начальных RTP sequence number и timestamp.
+	// nil - crypto/rand; тесты задают random.NewSeeded для воспроизводимости
+	RandomSource io.Reader
+
+	// DryRun включает пробное согласование: SDP и выбор портов без сокетов,
+	// RTP и медиа сессий (опционально, см. DryRun)
+	DryRun *DryRun
 }
 
 // HandlerConfig содержит конфигурацию для обработки SDP Offer и создания Answer
@@ -69,6 +164,11 @@ type HandlerConfig struct {
 	// Поддерживаемые кодеки (приоритет по порядку)
 	SupportedCodecs []CodecInfo
 
+	// PreferLocalCodecOrder - выбирать для answer кодек по порядку
+	// SupportedCodecs. По умолчанию выбирается первый общий кодек в порядке
+	// предпочтения offer (RFC 3264 Section 6.1)
+	PreferLocalCodecOrder bool
+
 	// Транспорт конфигурация
 	Transport TransportConfig
 
@@ -79,10 +179,58 @@ type HandlerConfig struct {
 	DTMFEnabled     bool
 	DTMFPayloadType uint8
 
+	// SilenceSuppression - локальное подавление пауз (a=silenceSupp, RFC 3108).
+	// SilenceSuppressionOff отключает VAD/CNG вызова. Согласованное значение
+	// добавляется в answer, если его задал offer или конфигурация
+	SilenceSuppression media.SilenceSuppression
+
+	// Quirks - особенности SDP оператора или транка, применяемые к answer
+	// (опционально, см. SDPQuirks)
+	Quirks *SDPQuirks
+
 	// Политики обработки
 	StrictMode           bool // Строгая проверка совместимости
 	AllowCodecChange     bool // Разрешить изменение кодека
 	AllowDirectionChange bool // Разрешить изменение направления медиа
+
+	// AcceptConfiguration выбирает потенциальную конфигурацию offer (RFC 5939).
+	// Вызывается для вариантов в порядке предпочтения offer, первая принятая
+	// конфигурация используется в answer. Если nil или ни одна не принята,
+	// answer использует актуальную конфигурацию m= строки
+	AcceptConfiguration func(config AcceptedConfiguration) bool
+
+	// OnHoldChanged вызывается при переходе удаленной стороны в удержание
+	// (c=0.0.0.0 в SDP offer) и при выходе из него
+	OnHoldChanged func(onHold bool)
+
+	// OnRemoteMediaAddressChanged вызывается, когда повторный offer (re-INVITE)
+	// меняет удаленный RTP или RTCP адрес и он применен к транспорту
+	OnRemoteMediaAddressChanged func(change RemoteMediaAddressChange)
+
+	// OnOneWayAudio вызывается в Stop, если число пакетов в направлениях
+	// заметно различается, с отчетом для записи в CDR
+	OnOneWayAudio func(report OneWayAudioReport)
+
+	// NoInboundMediaTimeout - пробное окно после ответа: если за это время
+	// при отправке RTP не получен ни один пакет, вызывается OnNoInboundMedia.
+	// 0 - проверка отключена
+	NoInboundMediaTimeout time.Duration
+	// OnNoInboundMedia вызывается один раз из отдельной горутины с
+	// диагностикой вызова без входящего медиа
+	OnNoInboundMedia func(report NoInboundMediaReport)
+
+	// OnPayloadTypeWarning вызывается в ProcessOffer для каждого повторяющегося,
+	// конфликтующего или неизвестного номера payload type аудио m= строки
+	OnPayloadTypeWarning func(warning PayloadTypeWarning)
+
+	// RandomSource - источник случайных данных для выбора порта из
+	// Transport.PortRange, SSRC и начальных RTP sequence number и timestamp.
+	// nil - crypto/rand; тесты задают random.NewSeeded для воспроизводимости
+	RandomSource io.Reader
+
+	// DryRun включает пробное согласование: SDP и выбор портов без сокетов,
+	// RTP и медиа сессий (опционально, см. DryRun)
+	DryRun *DryRun
 }
 
 // CodecInfo содержит информацию о поддерживаемом кодеке
@@ -92,6 +240,7 @@ type CodecInfo struct {
 	ClockRate   uint32
 	Channels    uint8         // Количество каналов (1 для моно, 2 для стерео)
 	Ptime       time.Duration // Предпочтительное время пакетизации
+	Fmtp        string        // Параметры fmtp без payload type (пусто - повторить параметры offer)
 }
 
 // DefaultBuilderConfig возвращает конфигурацию по умолчанию для Builder
@@ -184,12 +333,40 @@ func (c *BuilderConfig) Validate() error {
 		return NewSDPError(ErrorCodeInvalidConfig, "ClockRate должен быть больше 0")
 	}
 
+	if isDynamicPayloadType(uint8(c.PayloadType)) && c.CodecName == "" {
+		return NewSDPError(ErrorCodeInvalidConfig,
+			"CodecName обязателен для динамического PayloadType %d", c.PayloadType)
+	}
+
 	if c.Ptime <= 0 {
 		return NewSDPError(ErrorCodeInvalidConfig, "Ptime должен быть больше 0")
 	}
 
-	if c.Transport.LocalAddr == "" {
-		return NewSDPError(ErrorCodeInvalidConfig, "Transport.LocalAddr не может быть пустым")
+	for _, codec := range c.AlternativeCodecs {
+		if codec.Name == "" || codec.ClockRate == 0 {
+			return NewSDPError(ErrorCodeInvalidConfig,
+				"Для дополнительного кодека %d должны быть указаны Name и ClockRate", codec.PayloadType)
+		}
+		if codec.PayloadType == c.PayloadType || (c.DTMFEnabled && codec.PayloadType == rtp.PayloadType(c.DTMFPayloadType)) {
+			return NewSDPError(ErrorCodeInvalidConfig,
+				"Payload type %d дополнительного кодека %s уже используется", codec.PayloadType, codec.Name)
+		}
+	}
+
+	for i, config := range c.PotentialConfigurations {
+		if err := config.validate(); err != nil {
+			return NewSDPError(ErrorCodeInvalidConfig, "Потенциальная конфигурация %d: %v", i+1, err)
+		}
+	}
+
+	if err := c.Transport.validate(); err != nil {
+		return err
+	}
+
+	if c.Quirks != nil {
+		if err := c.Quirks.Validate(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -205,8 +382,8 @@ func (c *HandlerConfig) Validate() error {
 		return NewSDPError(ErrorCodeInvalidConfig, "SupportedCodecs не может быть пустым")
 	}
 
-	if c.Transport.LocalAddr == "" {
-		return NewSDPError(ErrorCodeInvalidConfig, "Transport.LocalAddr не может быть пустым")
+	if err := c.Transport.validate(); err != nil {
+		return err
 	}
 
 	// Проверяем уникальность payload types
@@ -224,5 +401,11 @@ func (c *HandlerConfig) Validate() error {
 		}
 	}
 
+	if c.Quirks != nil {
+		if err := c.Quirks.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,137 @@
+package media_sdp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+const (
+	// oneWayMinPackets - минимум пакетов в одном направлении (~1 с при 20 мс),
+	// начиная с которого разница направлений считается односторонним звуком
+	oneWayMinPackets = 50
+	// oneWayStreamGap - на сколько раньше исходящего должен прекратиться
+	// входящий поток, чтобы это попало в выводы отчета
+	oneWayStreamGap = 5 * time.Second
+)
+
+// OneWayAudioReport - диагностика медиа пути вызова для разбора жалоб на
+// односторонний звук: адреса, фактический источник RTP и смены адреса
+// (NAT latching), время первого и последнего пакета в каждом направлении,
+// полученный RTCP и выводы о вероятной причине
+type OneWayAudioReport struct {
+	SessionID string
+	CreatedAt time.Time
+
+	LocalAddr          string // Локальный RTP адрес
+	SignaledRemoteAddr string // Удаленный адрес из SDP
+	Direction          media.Direction
+	OnHold             bool
+	RTCPEnabled        bool
+
+	// Path - счетчики, время пакетов, источник RTP и события latching
+	Path rtp.MediaPathInfo
+
+	// Findings - выводы о вероятной причине одностороннего звука
+	Findings []string
+}
+
+// Asymmetric сообщает, что в одном направлении прошло заметно меньше пакетов,
+// чем в другом, при двустороннем направлении медиа
+func (r OneWayAudioReport) Asymmetric() bool {
+	if r.OnHold || r.Direction != media.DirectionSendRecv {
+		return false
+	}
+	sent, received := r.Path.PacketsSent, r.Path.PacketsReceived
+	high, low := max(sent, received), min(sent, received)
+	return high >= oneWayMinPackets && low*2 < high
+}
+
+// mediaPathSource - RTP сессия, сообщающая сведения о медиа пути (*rtp.Session)
+type mediaPathSource interface {
+	MediaPath() rtp.MediaPathInfo
+}
+
+// newOneWayAudioReport собирает отчет по транспорту и RTP сессии
+func newOneWayAudioReport(sessionID string, pair *rtp.TransportPair, session rtp.SessionRTP,
+	direction media.Direction, onHold, rtcpEnabled bool) OneWayAudioReport {
+	report := OneWayAudioReport{
+		SessionID:   sessionID,
+		CreatedAt:   time.Now(),
+		Direction:   direction,
+		OnHold:      onHold,
+		RTCPEnabled: rtcpEnabled,
+	}
+	if pair != nil && pair.RTP != nil {
+		report.LocalAddr, report.SignaledRemoteAddr, _ = ExtractTransportInfo(pair.RTP)
+	}
+	if source, ok := session.(mediaPathSource); ok {
+		report.Path = source.MediaPath()
+	}
+	report.Findings = report.findings()
+	return report
+}
+
+// findings формирует выводы по собранным сведениям
+func (r OneWayAudioReport) findings() []string {
+	var findings []string
+	path := r.Path
+
+	switch {
+	case path.PacketsReceived == 0 && path.PacketsSent > 0:
+		findings = append(findings, fmt.Sprintf(
+			"Входящие RTP пакеты не получены: удаленная сторона не отправляет медиа на %s "+
+				"или пакеты блокируются NAT/межсетевым экраном", r.LocalAddr))
+	case path.PacketsSent == 0 && path.PacketsReceived > 0:
+		findings = append(findings, "Исходящие RTP пакеты не отправлялись: нет источника аудио "+
+			"или направление медиа не позволяет отправку")
+	}
+
+	if path.RemoteSource != "" && r.SignaledRemoteAddr != "" && path.RemoteSource != r.SignaledRemoteAddr {
+		findings = append(findings, fmt.Sprintf(
+			"RTP приходит с %s, а в SDP указан %s: удаленная сторона за NAT, исходящий поток "+
+				"может не доходить без symmetric RTP (latching)", path.RemoteSource, r.SignaledRemoteAddr))
+	}
+	if len(path.LatchEvents) > 1 {
+		findings = append(findings, fmt.Sprintf("Адрес источника RTP менялся %d раз", len(path.LatchEvents)-1))
+	}
+
+	if !path.LastReceived.IsZero() && path.LastSent.Sub(path.LastReceived) > oneWayStreamGap {
+		findings = append(findings, fmt.Sprintf("Входящий поток прекратился в %s, исходящий продолжался до %s",
+			path.LastReceived.Format(time.RFC3339), path.LastSent.Format(time.RFC3339)))
+	}
+	if !path.LastSent.IsZero() && path.LastReceived.Sub(path.LastSent) > oneWayStreamGap {
+		findings = append(findings, fmt.Sprintf("Исходящий поток прекратился в %s, входящий продолжался до %s",
+			path.LastSent.Format(time.RFC3339), path.LastReceived.Format(time.RFC3339)))
+	}
+
+	if r.RTCPEnabled && path.RTCPReceived == 0 && path.PacketsSent > 0 {
+		findings = append(findings, "RTCP от удаленной стороны не получен: нет подтверждения, "+
+			"что исходящий поток доходит до получателя")
+	}
+	return findings
+}
+
+// OneWayAudioReport возвращает диагностику медиа пути текущего вызова
+func (b *sdpMediaBuilder) OneWayAudioReport() OneWayAudioReport {
+	return newOneWayAudioReport(b.config.SessionID, b.transportPair, b.rtpSession,
+		b.config.Direction, b.remoteHold, b.config.Transport.RTCPEnabled)
+}
+
+// OneWayAudioReport возвращает диагностику медиа пути текущего вызова
+func (h *sdpMediaHandler) OneWayAudioReport() OneWayAudioReport {
+	return newOneWayAudioReport(h.config.SessionID, h.transportPair, h.rtpSession,
+		h.direction, h.remoteHold, h.config.Transport.RTCPEnabled)
+}
+
+// reportOneWayAudio вызывает обработчик, если вызов завершился с асимметрией пакетов
+func reportOneWayAudio(handler func(OneWayAudioReport), report func() OneWayAudioReport) {
+	if handler == nil {
+		return
+	}
+	if r := report(); r.Asymmetric() {
+		handler(r)
+	}
+}
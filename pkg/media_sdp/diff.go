@@ -0,0 +1,184 @@
+package media_sdp
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pion/sdp/v3"
+)
+
+// SDPChanges - изменения между двумя версиями SDP одной сессии по m= строкам.
+// m= строки сопоставляются по позиции (RFC 3264 Section 8)
+type SDPChanges struct {
+	Streams []StreamChange // Только измененные m= строки
+}
+
+// StreamChange - изменения одной m= строки. Поля Old*/New* заполнены для
+// обеих версий, признаки *Changed отмечают различия
+type StreamChange struct {
+	Index int    // Номер m= строки
+	Media string // Тип медиа: audio, video, ...
+
+	Added   bool // m= строка появилась или ее порт стал ненулевым
+	Removed bool // m= строка исчезла или отклонена портом 0
+
+	AddressChanged bool
+	OldAddress     string // host:port, адрес 0.0.0.0 означает удержание
+	NewAddress     string
+
+	ProtocolChanged bool
+	OldProtocol     string
+	NewProtocol     string
+
+	CodecChanged bool
+	OldCodec     string // Первый формат m= строки: кодировка rtpmap или номер payload type
+	NewCodec     string
+
+	DirectionChanged bool
+	OldDirection     string // sendrecv, sendonly, recvonly или inactive
+	NewDirection     string
+
+	PtimeChanged bool
+	OldPtime     time.Duration // 0 - ptime не указан
+	NewPtime     time.Duration
+}
+
+// TransportChanged сообщает, что для m= строки нужно обновить транспорт:
+// изменился адрес или протокол, либо поток добавлен
+func (c StreamChange) TransportChanged() bool {
+	return c.Added || c.AddressChanged || c.ProtocolChanged
+}
+
+// DirectionOnly сообщает, что изменилось только направление медиа потока
+func (c StreamChange) DirectionOnly() bool {
+	return c.DirectionChanged && !c.Added && !c.Removed && !c.AddressChanged &&
+		!c.ProtocolChanged && !c.CodecChanged && !c.PtimeChanged
+}
+
+// Empty сообщает об отсутствии изменений
+func (c SDPChanges) Empty() bool {
+	return len(c.Streams) == 0
+}
+
+// Stream возвращает изменения m= строки index
+func (c SDPChanges) Stream(index int) (StreamChange, bool) {
+	for _, stream := range c.Streams {
+		if stream.Index == index {
+			return stream, true
+		}
+	}
+	return StreamChange{}, false
+}
+
+// Diff сравнивает две версии SDP и возвращает изменения адресов, кодеков,
+// направления, ptime и состава потоков. Используется при повторном offer,
+// чтобы отличить смену адреса, требующую обновления транспорта, от смены
+// направления. nil описание считается SDP без потоков.
+func Diff(oldSDP, newSDP *sdp.SessionDescription) SDPChanges {
+	var changes SDPChanges
+	count := max(mediaCount(oldSDP), mediaCount(newSDP))
+	for i := 0; i < count; i++ {
+		oldStream := describeStream(oldSDP, i)
+		newStream := describeStream(newSDP, i)
+		if !oldStream.active && !newStream.active {
+			continue
+		}
+
+		change := StreamChange{
+			Index:       i,
+			Media:       newStream.media,
+			Added:       !oldStream.active,
+			Removed:     !newStream.active,
+			OldAddress:  oldStream.address,
+			NewAddress:  newStream.address,
+			OldProtocol: oldStream.protocol,
+			NewProtocol: newStream.protocol,
+			OldCodec:    oldStream.codec,
+			NewCodec:    newStream.codec,
+			OldPtime:    oldStream.ptime,
+			NewPtime:    newStream.ptime,
+
+			OldDirection: oldStream.direction,
+			NewDirection: newStream.direction,
+		}
+		if change.Media == "" {
+			change.Media = oldStream.media
+		}
+		if oldStream.active && newStream.active {
+			change.AddressChanged = oldStream.address != newStream.address
+			change.ProtocolChanged = oldStream.protocol != newStream.protocol
+			change.CodecChanged = !strings.EqualFold(oldStream.codec, newStream.codec)
+			change.DirectionChanged = oldStream.direction != newStream.direction
+			change.PtimeChanged = oldStream.ptime != newStream.ptime
+		}
+
+		if change.Added || change.Removed || change.AddressChanged || change.ProtocolChanged ||
+			change.CodecChanged || change.DirectionChanged || change.PtimeChanged {
+			changes.Streams = append(changes.Streams, change)
+		}
+	}
+	return changes
+}
+
+// streamDescription - сравниваемые параметры m= строки
+type streamDescription struct {
+	active    bool
+	media     string
+	address   string
+	protocol  string
+	codec     string
+	direction string
+	ptime     time.Duration
+}
+
+// mediaCount возвращает количество m= строк
+func mediaCount(desc *sdp.SessionDescription) int {
+	if desc == nil {
+		return 0
+	}
+	return len(desc.MediaDescriptions)
+}
+
+// describeStream извлекает параметры m= строки index
+func describeStream(desc *sdp.SessionDescription, index int) streamDescription {
+	if index >= mediaCount(desc) {
+		return streamDescription{}
+	}
+	mediaDesc := desc.MediaDescriptions[index]
+	stream := streamDescription{
+		active:    mediaDesc.MediaName.Port.Value != 0,
+		media:     mediaDesc.MediaName.Media,
+		protocol:  strings.Join(mediaDesc.MediaName.Protos, "/"),
+		direction: resolveDirectionAttribute(desc, mediaDesc),
+	}
+
+	connection := mediaDesc.ConnectionInformation
+	if connection == nil {
+		connection = desc.ConnectionInformation
+	}
+	host := ""
+	if connection != nil && connection.Address != nil {
+		host = connection.Address.Address
+	}
+	stream.address = net.JoinHostPort(host, strconv.Itoa(mediaDesc.MediaName.Port.Value))
+
+	if len(mediaDesc.MediaName.Formats) > 0 {
+		format := mediaDesc.MediaName.Formats[0]
+		stream.codec = format
+		for _, attr := range mediaDesc.Attributes {
+			if value, ok := strings.CutPrefix(attr.Value, format+" "); ok && attr.Key == "rtpmap" {
+				stream.codec = strings.TrimSpace(value)
+				break
+			}
+		}
+	}
+
+	if value, ok := mediaDesc.Attribute("ptime"); ok {
+		if ms, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			stream.ptime = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return stream
+}
@@ -0,0 +1,98 @@
+package media_sdp
+
+import (
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/pion/sdp/v3"
+)
+
+// Атрибуты направления медиа потока согласно RFC 4566 Section 6
+const (
+	directionSendRecv = "sendrecv"
+	directionSendOnly = "sendonly"
+	directionRecvOnly = "recvonly"
+	directionInactive = "inactive"
+)
+
+// findDirectionAttribute ищет атрибут направления среди атрибутов.
+// Возвращает последний найденный атрибут и признак его наличия.
+func findDirectionAttribute(attributes []sdp.Attribute) (string, bool) {
+	direction := ""
+	found := false
+
+	for _, attr := range attributes {
+		switch attr.Key {
+		case directionSendRecv, directionSendOnly, directionRecvOnly, directionInactive:
+			direction = attr.Key
+			found = true
+		}
+	}
+
+	return direction, found
+}
+
+// resolveDirectionAttribute определяет направление медиа потока для m= строки.
+//
+// Согласно RFC 4566 Section 6 и RFC 3264 Section 5.1 атрибут направления
+// на уровне сессии применяется ко всем m= строкам, если он не переопределен
+// на уровне медиа. При отсутствии атрибутов используется sendrecv.
+func resolveDirectionAttribute(session *sdp.SessionDescription, mediaDesc *sdp.MediaDescription) string {
+	if mediaDesc != nil {
+		if direction, ok := findDirectionAttribute(mediaDesc.Attributes); ok {
+			return direction
+		}
+	}
+
+	if session != nil {
+		if direction, ok := findDirectionAttribute(session.Attributes); ok {
+			return direction
+		}
+	}
+
+	return directionSendRecv
+}
+
+// reverseDirection преобразует направление удаленной стороны в локальное:
+// если удаленная сторона sendonly, мы recvonly и наоборот
+func reverseDirection(remote string) media.Direction {
+	switch remote {
+	case directionSendOnly:
+		return media.DirectionRecvOnly
+	case directionRecvOnly:
+		return media.DirectionSendOnly
+	case directionInactive:
+		return media.DirectionInactive
+	default:
+		return media.DirectionSendRecv
+	}
+}
+
+// negotiateDirection вычисляет итоговое локальное направление по предложенному
+// нами направлению и направлению из answer удаленной стороны (RFC 3264 Section 6.1).
+// Мы отправляем, только если сами готовы отправлять и удаленная сторона готова принимать.
+func negotiateDirection(local media.Direction, remote string) media.Direction {
+	allowed := reverseDirection(remote)
+
+	canSend := directionCanSend(local) && directionCanSend(allowed)
+	canRecv := directionCanReceive(local) && directionCanReceive(allowed)
+
+	switch {
+	case canSend && canRecv:
+		return media.DirectionSendRecv
+	case canSend:
+		return media.DirectionSendOnly
+	case canRecv:
+		return media.DirectionRecvOnly
+	default:
+		return media.DirectionInactive
+	}
+}
+
+// directionCanSend проверяет, разрешает ли направление отправку
+func directionCanSend(d media.Direction) bool {
+	return d == media.DirectionSendRecv || d == media.DirectionSendOnly
+}
+
+// directionCanReceive проверяет, разрешает ли направление прием
+func directionCanReceive(d media.Direction) bool {
+	return d == media.DirectionSendRecv || d == media.DirectionRecvOnly
+}
@@ -0,0 +1,255 @@
+package media_sdp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	pionrtp "github.com/pion/rtp"
+)
+
+// Диапазон портов, из которого DryRun выделяет порт для LocalAddr с портом 0
+const (
+	dryRunEphemeralMin = 49152
+	dryRunEphemeralMax = 65535
+)
+
+// errDryRun возвращается при попытке передать медиа в режиме DryRun
+var errDryRun = errors.New("транспорт пробного согласования не передает медиа")
+
+// DryRun - пространство портов пробного согласования. Builder и Handler с
+// заданным DryRun выполняют полное согласование SDP и выбор портов из
+// Transport.PortRange, но вместо сокетов занимают порты в таблице DryRun и
+// не создают RTP и медиа сессии. Один DryRun, общий для множества
+// согласований, моделирует нагрузку на диапазон портов: занятый порт
+// отвергается как при отказе ОС в bind (EADDRINUSE), Stop освобождает порты.
+//
+// Используется для оценки емкости и проверки конфигурации без сети.
+// Методы потокобезопасны.
+type DryRun struct {
+	// Address - адрес в SDP для LocalAddr без хоста, по умолчанию 127.0.0.1
+	Address string
+
+	mu        sync.Mutex
+	bound     map[string]bool // Занятые адреса host:port
+	ephemeral int             // Следующий порт для LocalAddr с портом 0
+	stats     DryRunStats
+}
+
+// DryRunStats - счетчики пробного согласования
+type DryRunStats struct {
+	// Bound - число успешных занятий порта (RTP и отдельный RTCP)
+	Bound uint64
+	// Conflicts - число отказов из-за занятого порта
+	Conflicts uint64
+	// PortsInUse - число занятых портов
+	PortsInUse int
+	// PeakPortsInUse - наибольшее число одновременно занятых портов
+	PeakPortsInUse int
+}
+
+// NewDryRun создает пустое пространство портов пробного согласования
+func NewDryRun() *DryRun {
+	return &DryRun{
+		bound:     make(map[string]bool),
+		ephemeral: dryRunEphemeralMin,
+	}
+}
+
+// Stats возвращает счетчики пробного согласования
+func (d *DryRun) Stats() DryRunStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := d.stats
+	stats.PortsInUse = len(d.bound)
+	return stats
+}
+
+// bind занимает адрес так же, как bind сокета: порт 0 выбирает свободный
+// порт, занятый порт возвращает *net.OpError с syscall.EADDRINUSE
+func (d *DryRun) bind(localAddr string) (*net.UDPAddr, error) {
+	host, portStr, err := net.SplitHostPort(localAddr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		address := d.Address
+		if address == "" {
+			address = "127.0.0.1"
+		}
+		ip = net.ParseIP(address)
+		if ip == nil {
+			return nil, errors.New("некорректный DryRun.Address: " + address)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if port == 0 {
+		port, err = d.ephemeralPortLocked(ip)
+		if err != nil {
+			return nil, err
+		}
+	}
+	addr := &net.UDPAddr{IP: ip, Port: port}
+	if d.bound[addr.String()] {
+		d.stats.Conflicts++
+		return nil, &net.OpError{Op: "listen", Net: "udp", Addr: addr, Err: syscall.EADDRINUSE}
+	}
+	d.bound[addr.String()] = true
+	d.stats.Bound++
+	if len(d.bound) > d.stats.PeakPortsInUse {
+		d.stats.PeakPortsInUse = len(d.bound)
+	}
+	return addr, nil
+}
+
+// ephemeralPortLocked выбирает свободный порт из динамического диапазона.
+// Вызывается под mu.
+func (d *DryRun) ephemeralPortLocked(ip net.IP) (int, error) {
+	for i := 0; i <= dryRunEphemeralMax-dryRunEphemeralMin; i++ {
+		port := d.ephemeral
+		d.ephemeral++
+		if d.ephemeral > dryRunEphemeralMax {
+			d.ephemeral = dryRunEphemeralMin
+		}
+		if !d.bound[(&net.UDPAddr{IP: ip, Port: port}).String()] {
+			return port, nil
+		}
+	}
+	d.stats.Conflicts++
+	return 0, &net.OpError{Op: "listen", Net: "udp", Err: syscall.EADDRINUSE}
+}
+
+// release освобождает адрес
+func (d *DryRun) release(addr net.Addr) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.bound, addr.String())
+}
+
+// dryRunTransport - RTP и RTCP транспорт пробного согласования без сокета
+type dryRunTransport struct {
+	dryRun *DryRun
+	local  *net.UDPAddr
+
+	mu     sync.Mutex
+	remote *net.UDPAddr
+	closed bool
+}
+
+// newDryRunTransport занимает localAddr в таблице DryRun
+func newDryRunTransport(dryRun *DryRun, localAddr, remoteAddr string) (*dryRunTransport, error) {
+	local, err := dryRun.bind(localAddr)
+	if err != nil {
+		return nil, err
+	}
+	t := &dryRunTransport{dryRun: dryRun, local: local}
+	if remoteAddr != "" {
+		if err := t.SetRemoteAddr(remoteAddr); err != nil {
+			dryRun.release(local)
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// SetRemoteAddr запоминает удаленный адрес
+func (t *dryRunTransport) SetRemoteAddr(remoteAddr string) error {
+	addr, err := net.ResolveUDPAddr("udp", remoteAddr)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.remote = addr
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *dryRunTransport) Send(*pionrtp.Packet) error {
+	return errDryRun
+}
+
+func (t *dryRunTransport) Receive(context.Context) (*pionrtp.Packet, net.Addr, error) {
+	return nil, nil, errDryRun
+}
+
+func (t *dryRunTransport) SendRTCP([]byte) error {
+	return errDryRun
+}
+
+func (t *dryRunTransport) ReceiveRTCP(context.Context) ([]byte, net.Addr, error) {
+	return nil, nil, errDryRun
+}
+
+func (t *dryRunTransport) LocalAddr() net.Addr {
+	return t.local
+}
+
+func (t *dryRunTransport) RemoteAddr() net.Addr {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.remote == nil {
+		return nil
+	}
+	return t.remote
+}
+
+// Close освобождает порт в таблице DryRun
+func (t *dryRunTransport) Close() error {
+	t.mu.Lock()
+	closed := t.closed
+	t.closed = true
+	t.mu.Unlock()
+	if !closed {
+		t.dryRun.release(t.local)
+	}
+	return nil
+}
+
+func (t *dryRunTransport) IsActive() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.closed
+}
+
+// createDryRunTransportPair занимает порты RTP и RTCP (RTP + 1) в таблице
+// DryRun по тем же правилам, что и createTransportPair
+func createDryRunTransportPair(config TransportConfig) (*rtp.TransportPair, error) {
+	rtpTransport, err := newDryRunTransport(config.dryRun, config.LocalAddr, config.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	if !config.RTCPEnabled || config.RTCPMuxMode != rtp.RTCPMuxNone {
+		return rtp.NewTransportPair(rtpTransport, nil, config.RTCPMuxMode), nil
+	}
+
+	rtcpLocal, err := generateRTCPAddress(rtpTransport.local.String())
+	if err != nil {
+		_ = rtpTransport.Close()
+		return nil, err
+	}
+	rtcpRemote := config.rtcpRemoteAddr
+	if rtcpRemote == "" && config.RemoteAddr != "" {
+		if rtcpRemote, err = generateRTCPAddress(config.RemoteAddr); err != nil {
+			_ = rtpTransport.Close()
+			return nil, err
+		}
+	}
+	rtcpTransport, err := newDryRunTransport(config.dryRun, rtcpLocal, rtcpRemote)
+	if err != nil {
+		_ = rtpTransport.Close()
+		return nil, err
+	}
+	return rtp.NewTransportPair(rtpTransport, rtcpTransport, config.RTCPMuxMode), nil
+}
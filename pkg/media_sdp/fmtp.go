@@ -0,0 +1,222 @@
+package media_sdp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// FormatParameters содержит параметры формата из атрибута
+// a=fmtp:<payload type> <параметры> (RFC 4566 Section 6).
+//
+// Параметры вида key=value, разделенные ";", доступны через Get по ключу
+// в нижнем регистре. Параметры без ключа, например список событий
+// telephone-event "0-15" (RFC 4733), доступны через Raw.
+type FormatParameters struct {
+	PayloadType uint8
+	Raw         string            // Строка параметров без payload type
+	Params      map[string]string // Параметры key=value, ключи в нижнем регистре
+}
+
+// ParseFormatParameters разбирает значение атрибута fmtp, например
+// "18 annexb=no" или "96 mode-set=0,2,5,7; octet-align=1"
+func ParseFormatParameters(value string) (FormatParameters, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), " ", 2)
+	pt, err := strconv.ParseUint(parts[0], 10, 7)
+	if err != nil {
+		return FormatParameters{}, NewSDPError(ErrorCodeSDPParsing,
+			"Некорректный payload type в fmtp: %q", value)
+	}
+
+	params := FormatParameters{
+		PayloadType: uint8(pt),
+		Params:      make(map[string]string),
+	}
+	if len(parts) == 2 {
+		params.Raw = strings.TrimSpace(parts[1])
+	}
+
+	for _, param := range strings.Split(params.Raw, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || key == "" {
+			continue
+		}
+		params.Params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(val)
+	}
+
+	return params, nil
+}
+
+// String возвращает значение атрибута fmtp
+func (p FormatParameters) String() string {
+	return strconv.Itoa(int(p.PayloadType)) + " " + p.Raw
+}
+
+// Get возвращает значение параметра по ключу без учета регистра
+func (p FormatParameters) Get(key string) (string, bool) {
+	value, ok := p.Params[strings.ToLower(key)]
+	return value, ok
+}
+
+// AnnexB возвращает, разрешено ли G.729 Annex B (VAD/CNG, SID кадры).
+// По умолчанию annexb=yes (RFC 4856 Section 2.1.9)
+func (p FormatParameters) AnnexB() bool {
+	value, ok := p.Get("annexb")
+	return !ok || !strings.EqualFold(value, "no")
+}
+
+// Events возвращает список событий telephone-event ("0-15,66"). Если
+// параметры отсутствуют, возвращает события 0-15 (RFC 4733 Section 7.1.1)
+func (p FormatParameters) Events() ([]uint8, error) {
+	list := p.Raw
+	if value, ok := p.Get("events"); ok {
+		list = value
+	}
+	if list == "" {
+		list = "0-15"
+	}
+
+	var events []uint8
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		low, high, isRange := strings.Cut(item, "-")
+		first, err := strconv.ParseUint(low, 10, 8)
+		if err != nil {
+			return nil, NewSDPError(ErrorCodeSDPParsing, "Некорректный список событий fmtp: %q", list)
+		}
+		last := first
+		if isRange {
+			last, err = strconv.ParseUint(high, 10, 8)
+			if err != nil || last < first {
+				return nil, NewSDPError(ErrorCodeSDPParsing, "Некорректный диапазон событий fmtp: %q", item)
+			}
+		}
+		for event := first; event <= last; event++ {
+			events = append(events, uint8(event))
+		}
+	}
+	return events, nil
+}
+
+// ModeSet возвращает набор режимов AMR/AMR-WB из mode-set (RFC 4867).
+// Отсутствие параметра означает, что разрешены все режимы: возвращается nil
+func (p FormatParameters) ModeSet() ([]int, error) {
+	value, ok := p.Get("mode-set")
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	var modes []int
+	for _, item := range strings.Split(value, ",") {
+		mode, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, NewSDPError(ErrorCodeSDPParsing, "Некорректный mode-set: %q", value)
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// Mode возвращает целочисленный параметр mode (например, mode=30 для iLBC)
+func (p FormatParameters) Mode() (int, bool) {
+	return p.intParam("mode")
+}
+
+// Bitrate возвращает ограничение битрейта из bitrate или maxaveragebitrate
+func (p FormatParameters) Bitrate() (int, bool) {
+	if bitrate, ok := p.intParam("bitrate"); ok {
+		return bitrate, true
+	}
+	return p.intParam("maxaveragebitrate")
+}
+
+// intParam возвращает целочисленное значение параметра
+func (p FormatParameters) intParam(key string) (int, bool) {
+	value, ok := p.Get(key)
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// parseFormatParametersAttributes извлекает параметры fmtp медиа описания
+// по payload type. Некорректные атрибуты пропускаются.
+func parseFormatParametersAttributes(mediaDesc *sdp.MediaDescription) map[uint8]FormatParameters {
+	result := make(map[uint8]FormatParameters)
+	for _, attr := range mediaDesc.Attributes {
+		if attr.Key != "fmtp" {
+			continue
+		}
+		params, err := ParseFormatParameters(attr.Value)
+		if err != nil {
+			continue
+		}
+		result[params.PayloadType] = params
+	}
+	return result
+}
+
+// isDynamicPayloadType проверяет, относится ли payload type к динамическому
+// диапазону 96-127 (RFC 3551). Кодек такого payload type определяется rtpmap.
+func isDynamicPayloadType(pt uint8) bool {
+	return pt >= 96 && pt <= 127
+}
+
+// usesCodecRegistry проверяет, создается ли кодек payload type из реестра
+// media: динамические payload types и статические L16 (10, 11)
+func usesCodecRegistry(pt uint8) bool {
+	return isDynamicPayloadType(pt) ||
+		pt == uint8(rtp.PayloadTypeL16_2CH) || pt == uint8(rtp.PayloadTypeL16_1CH)
+}
+
+// formatRtpmap формирует значение rtpmap. Количество каналов указывается
+// только для многоканальных кодировок (RFC 4566 Section 6)
+func formatRtpmap(pt uint8, name string, clockRate uint32, channels uint8) string {
+	if channels > 1 {
+		return fmt.Sprintf("%d %s/%d/%d", pt, name, clockRate, channels)
+	}
+	return fmt.Sprintf("%d %s/%d", pt, name, clockRate)
+}
+
+// negotiateFormatParameters согласует параметры fmtp кодека и возвращает
+// параметры для answer. false означает, что параметры несовместимы.
+// Для кодеков без правил согласования используются локальные параметры,
+// а при их отсутствии повторяются параметры удаленной стороны.
+func negotiateFormatParameters(codecName string, local, remote FormatParameters) (string, bool) {
+	switch strings.ToUpper(codecName) {
+	case "AMR", "AMR-WB":
+		return negotiateAMRParameters(strings.EqualFold(codecName, "AMR-WB"), local, remote)
+	case "ILBC":
+		return negotiateILBCParameters(local, remote)
+	}
+
+	if local.Raw != "" {
+		return local.Raw, true
+	}
+	return remote.Raw, true
+}
+
+// newRegistryCodec создает кодек из реестра media с согласованными
+// параметрами rtpmap и fmtp
+func newRegistryCodec(name string, pt uint8, clockRate uint32, channels uint8, ptime time.Duration, fmtp string) (media.Codec, error) {
+	params, err := ParseFormatParameters(strconv.Itoa(int(pt)) + " " + fmtp)
+	if err != nil {
+		return nil, err
+	}
+	return media.NewCodec(name, media.CodecParams{
+		PayloadType: media.PayloadType(pt),
+		ClockRate:   clockRate,
+		Channels:    int(channels),
+		Ptime:       ptime,
+		Fmtp:        params.Params,
+	})
+}
@@ -0,0 +1,124 @@
+//go:build !small
+
+package media_sdp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+)
+
+// negotiateAMRParameters согласует параметры AMR/AMR-WB (RFC 4867 Section 8.3):
+// octet-align должен совпадать, mode-set - пересечение наборов сторон.
+// crc, robust-sorting и interleaving не поддерживаются.
+func negotiateAMRParameters(wideBand bool, local, remote FormatParameters) (string, bool) {
+	for _, key := range []string{"crc", "robust-sorting"} {
+		if value, _ := remote.Get(key); value == "1" {
+			return "", false
+		}
+	}
+	if _, ok := remote.Get("interleaving"); ok {
+		return "", false
+	}
+
+	localAligned, _ := local.Get("octet-align")
+	remoteAligned, _ := remote.Get("octet-align")
+	if (localAligned == "1") != (remoteAligned == "1") {
+		return "", false
+	}
+
+	format := media.AMRPayloadFormat{WideBand: wideBand}
+	localModes, err := media.ParseAMRModeSet(local.Params["mode-set"], format)
+	if err != nil {
+		return "", false
+	}
+	remoteModes, err := media.ParseAMRModeSet(remote.Params["mode-set"], format)
+	if err != nil {
+		return "", false
+	}
+
+	modes := localModes
+	switch {
+	case localModes == nil:
+		modes = remoteModes
+	case remoteModes != nil:
+		modes = intersectModes(localModes, remoteModes)
+		if len(modes) == 0 {
+			return "", false
+		}
+	}
+
+	var params []string
+	if len(modes) > 0 {
+		items := make([]string, len(modes))
+		for i, mode := range modes {
+			items[i] = strconv.Itoa(int(mode))
+		}
+		params = append(params, "mode-set="+strings.Join(items, ","))
+	}
+	if localAligned == "1" {
+		params = append(params, "octet-align=1")
+	}
+	return strings.Join(params, "; "), true
+}
+
+// negotiateILBCParameters согласует режим iLBC (RFC 3952 Section 5): если
+// одна из сторон требует 30ms, используется 30ms. Без локального mode
+// принимается режим удаленной стороны.
+func negotiateILBCParameters(local, remote FormatParameters) (string, bool) {
+	remoteMode, err := media.ILBCMode(remote.Params["mode"])
+	if err != nil {
+		return "", false
+	}
+
+	mode := remoteMode
+	if localValue, ok := local.Get("mode"); ok {
+		localMode, err := media.ILBCMode(localValue)
+		if err != nil {
+			return "", false
+		}
+		if localMode > mode {
+			mode = localMode
+		}
+	}
+	return "mode=" + strconv.Itoa(int(mode/time.Millisecond)), true
+}
+
+// negotiatePtime подстраивает ptime под длительность кадра кодека: ptime
+// iLBC должен быть кратен длительности кадра согласованного режима
+func negotiatePtime(codecName, fmtp string, ptime time.Duration) time.Duration {
+	if !strings.EqualFold(codecName, "iLBC") {
+		return ptime
+	}
+
+	params, err := ParseFormatParameters("0 " + fmtp)
+	if err != nil {
+		return ptime
+	}
+	mode, err := media.ILBCMode(params.Params["mode"])
+	if err != nil || (ptime >= mode && ptime%mode == 0) {
+		return ptime
+	}
+	return mode
+}
+
+// intersectModes возвращает отсортированное пересечение наборов режимов
+func intersectModes(a, b []uint8) []uint8 {
+	allowed := make(map[uint8]bool, len(b))
+	for _, mode := range b {
+		allowed[mode] = true
+	}
+
+	var result []uint8
+	for _, mode := range a {
+		if allowed[mode] {
+			result = append(result, mode)
+			delete(allowed, mode)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
@@ -0,0 +1,24 @@
+//go:build small
+
+package media_sdp
+
+import "time"
+
+// В минимальной сборке кодеки AMR, AMR-WB и iLBC исключены из пакета media,
+// поэтому их параметры не согласуются и такие форматы отклоняются.
+
+// negotiateAMRParameters отклоняет AMR/AMR-WB в минимальной сборке
+func negotiateAMRParameters(bool, FormatParameters, FormatParameters) (string, bool) {
+	return "", false
+}
+
+// negotiateILBCParameters отклоняет iLBC в минимальной сборке
+func negotiateILBCParameters(FormatParameters, FormatParameters) (string, bool) {
+	return "", false
+}
+
+// negotiatePtime не изменяет ptime: кодеков с ограничениями на длительность
+// кадра в минимальной сборке нет
+func negotiatePtime(_, _ string, ptime time.Duration) time.Duration {
+	return ptime
+}
@@ -0,0 +1,162 @@
+//go:build !small
+
+package functional_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+)
+
+// stubAMRFrameCodec - внешний кодер кадров AMR для тестов: кодирует тишину
+type stubAMRFrameCodec struct{}
+
+func (stubAMRFrameCodec) EncodeFrame(_ []byte, mode uint8) (media.AMRFrame, error) {
+	bits, err := media.AMRPayloadFormat{}.FrameBits(mode)
+	if err != nil {
+		return media.AMRFrame{}, err
+	}
+	return media.AMRFrame{FrameType: mode, Quality: true, Data: make([]byte, (bits+7)/8)}, nil
+}
+
+func (stubAMRFrameCodec) DecodeFrame(media.AMRFrame) ([]byte, error) {
+	return make([]byte, 160), nil
+}
+
+// amrHandlerConfig возвращает конфигурацию handler с поддержкой AMR
+func amrHandlerConfig(sessionID, fmtp string) media_sdp.HandlerConfig {
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = sessionID
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.SupportedCodecs = []media_sdp.CodecInfo{{
+		PayloadType: 97,
+		Name:        "AMR",
+		ClockRate:   8000,
+		Channels:    1,
+		Ptime:       20 * time.Millisecond,
+		Fmtp:        fmtp,
+	}}
+	return config
+}
+
+// TestAMRNegotiation проверяет согласование mode-set и octet-align AMR
+func TestAMRNegotiation(t *testing.T) {
+	media.RegisterAMRFrameCodec(false, func() (media.AMRFrameCodec, error) { return stubAMRFrameCodec{}, nil })
+	defer media.RegisterAMRFrameCodec(false, nil)
+
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "amr-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.PayloadType = 96
+	builderConfig.CodecName = "AMR"
+	builderConfig.Fmtp = "mode-set=0,2,5,7; octet-align=1"
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	offerText := string(mustMarshal(t, offer))
+	if !strings.Contains(offerText, "a=rtpmap:96 AMR/8000") ||
+		!strings.Contains(offerText, "a=fmtp:96 mode-set=0,2,5,7; octet-align=1") {
+		t.Errorf("Offer должен содержать rtpmap и fmtp AMR:\n%s", offerText)
+	}
+
+	handler, err := media_sdp.NewSDPMediaHandler(amrHandlerConfig("amr-callee", "mode-set=2,5,7; octet-align=1"))
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+
+	// Answer использует payload type из offer и пересечение mode-set
+	answerText := string(mustMarshal(t, answer))
+	if !strings.Contains(answerText, "m=audio") || !strings.Contains(answerText, "a=rtpmap:96 AMR/8000") {
+		t.Errorf("Answer должен выбрать AMR с payload type 96 из offer:\n%s", answerText)
+	}
+	if !strings.Contains(answerText, "a=fmtp:96 mode-set=2,5,7; octet-align=1") {
+		t.Errorf("Answer должен содержать пересечение mode-set:\n%s", answerText)
+	}
+
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	if err := builder.Start(); err != nil {
+		t.Fatalf("Не удалось запустить builder: %v", err)
+	}
+	if err := builder.GetMediaSession().SendAudio(make([]byte, 160)); err != nil {
+		t.Errorf("Отправка через кодек AMR: %v", err)
+	}
+}
+
+// TestAMRIncompatibleParameters проверяет отказ от AMR с несовместимыми
+// параметрами и без зарегистрированного кодера
+func TestAMRIncompatibleParameters(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "amr-incompatible-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.PayloadType = 96
+	builderConfig.CodecName = "AMR"
+	builderConfig.Fmtp = "mode-set=0,2; octet-align=1"
+
+	// Без внешнего кодера кодек AMR не может быть создан
+	if _, err := media_sdp.NewSDPMediaBuilder(builderConfig); !media_sdp.IsSDPError(err, media_sdp.ErrorCodeIncompatibleCodec) {
+		t.Fatalf("Ожидалась ошибка несовместимого кодека без кодера AMR, получено %v", err)
+	}
+
+	media.RegisterAMRFrameCodec(false, func() (media.AMRFrameCodec, error) { return stubAMRFrameCodec{}, nil })
+	defer media.RegisterAMRFrameCodec(false, nil)
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		fmtp string
+	}{
+		{"octet-align не совпадает", "mode-set=0,2"},
+		{"mode-set не пересекается", "mode-set=5,7; octet-align=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := media_sdp.NewSDPMediaHandler(amrHandlerConfig("amr-incompatible-callee", tt.fmtp))
+			if err != nil {
+				t.Fatalf("Не удалось создать handler: %v", err)
+			}
+			defer func() { _ = handler.Stop() }()
+
+			if err := handler.ProcessOffer(offer); !media_sdp.IsSDPError(err, media_sdp.ErrorCodeIncompatibleCodec) {
+				t.Errorf("Ожидалась ошибка несовместимого кодека, получено %v", err)
+			}
+		})
+	}
+
+	config := builderConfig
+	config.CodecName = ""
+	if _, err := media_sdp.NewSDPMediaBuilder(config); !media_sdp.IsSDPError(err, media_sdp.ErrorCodeInvalidConfig) {
+		t.Errorf("Динамический payload type без CodecName должен быть ошибкой конфигурации, получено %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package functional_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// TestProcessAnswerIdempotent тестирует повторную обработку answer из повторов 200 OK
+func TestProcessAnswerIdempotent(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "answer-retransmit-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+
+	holdEvents := 0
+	builderConfig.OnHoldChanged = func(bool) { holdEvents++ }
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "answer-retransmit-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	activeAddress := answer.ConnectionInformation.Address.Address
+
+	setConnectionAddress(answer, "0.0.0.0")
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+
+	// Повтор того же answer не должен ничего менять
+	for i := 0; i < 3; i++ {
+		if err := builder.ProcessAnswer(answer); err != nil {
+			t.Fatalf("Повторный answer должен обрабатываться без ошибки: %v", err)
+		}
+	}
+	if holdEvents != 1 {
+		t.Errorf("Ожидалось 1 событие удержания, получено %d", holdEvents)
+	}
+
+	// Измененный answer с той же версией o= - ошибка
+	setConnectionAddress(answer, activeAddress)
+	err = builder.ProcessAnswer(answer)
+	if !media_sdp.IsSDPError(err, media_sdp.ErrorCodeAnswerConflict) {
+		t.Fatalf("Ожидалась ошибка ErrorCodeAnswerConflict, получено: %v", err)
+	}
+	if !builder.IsOnHold() {
+		t.Error("Отклоненный answer не должен применяться")
+	}
+
+	// С увеличенной версией измененный answer применяется
+	answer.Origin.SessionVersion++
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать новую версию answer: %v", err)
+	}
+	if builder.IsOnHold() {
+		t.Error("Удержание должно быть снято")
+	}
+}
+
+// TestAnswerCodecOrder проверяет выбор кодека answer: по умолчанию - в
+// порядке предпочтения offer, с PreferLocalCodecOrder - в локальном порядке
+func TestAnswerCodecOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		preferLocal bool
+		want        string
+	}{
+		{"порядок offer", false, "RTP/AVP 0\r\n"},
+		{"локальный порядок", true, "RTP/AVP 8\r\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := media_sdp.DefaultHandlerConfig()
+			config.SessionID = "codec-order"
+			config.Transport.LocalAddr = "127.0.0.1:0"
+			config.PreferLocalCodecOrder = tc.preferLocal
+			config.SupportedCodecs = []media_sdp.CodecInfo{
+				{PayloadType: rtp.PayloadTypePCMA, Name: "PCMA", ClockRate: 8000, Channels: 1, Ptime: 20 * time.Millisecond},
+				{PayloadType: rtp.PayloadTypePCMU, Name: "PCMU", ClockRate: 8000, Channels: 1, Ptime: 20 * time.Millisecond},
+			}
+			handler, err := media_sdp.NewSDPMediaHandler(config)
+			if err != nil {
+				t.Fatalf("Не удалось создать handler: %v", err)
+			}
+			defer func() { _ = handler.Stop() }()
+
+			// Offer предпочитает PCMU и предлагает неподдерживаемый G.729 первым
+			offer := legacyOffer(t, "18 0 8", "rtpmap:18 G729/8000", "rtpmap:0 PCMU/8000", "rtpmap:8 PCMA/8000")
+			if err := handler.ProcessOffer(offer); err != nil {
+				t.Fatalf("Не удалось обработать offer: %v", err)
+			}
+			if text := answerText(t, handler); !strings.Contains(text, tc.want) {
+				t.Errorf("Ожидалась m= строка с %q:\n%s", tc.want, text)
+			}
+		})
+	}
+}
+
+// TestAnswerRejectsUnsupportedMedia проверяет, что неподдерживаемые m= строки
+// offer отклоняются в answer портом 0, а аудио принимается
+func TestAnswerRejectsUnsupportedMedia(t *testing.T) {
+	offer := &sdp.SessionDescription{}
+	err := offer.Unmarshal([]byte("v=0\r\n" +
+		"o=phone 1 1 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"c=IN IP4 127.0.0.1\r\n" +
+		"t=0 0\r\n" +
+		"m=video 40002 RTP/AVP 96\r\n" +
+		"a=rtpmap:96 H264/90000\r\n" +
+		"m=audio 40004 UDP/TLS/RTP/SAVPF 0\r\n" +
+		"m=audio 40000 RTP/AVP 0\r\n" +
+		"a=rtpmap:0 PCMU/8000\r\n"))
+	if err != nil {
+		t.Fatalf("Не удалось разобрать offer: %v", err)
+	}
+
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = "reject-media"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	handler, err := media_sdp.NewSDPMediaHandler(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Неподдерживаемые m= строки не должны прерывать обработку offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+
+	if len(answer.MediaDescriptions) != 3 {
+		t.Fatalf("Answer должен содержать 3 m= строки, получено %d", len(answer.MediaDescriptions))
+	}
+	for i, want := range []string{"video 0 RTP/AVP 96", "audio 0 UDP/TLS/RTP/SAVPF 0"} {
+		if got := answer.MediaDescriptions[i].MediaName.String(); got != want {
+			t.Errorf("m= строка %d: ожидалось %q, получено %q", i, want, got)
+		}
+	}
+	audio := answer.MediaDescriptions[2]
+	if audio.MediaName.Port.Value == 0 || strings.Join(audio.MediaName.Formats, " ") != "0" {
+		t.Errorf("Аудио должно быть принято с PCMU: %s", audio.MediaName.String())
+	}
+
+	// Offer без поддерживаемого аудио - ошибка
+	offer.MediaDescriptions = offer.MediaDescriptions[:2]
+	other, err := media_sdp.NewSDPMediaHandler(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = other.Stop() }()
+	if err := other.ProcessOffer(offer); !media_sdp.IsSDPError(err, media_sdp.ErrorCodeSDPParsing) {
+		t.Errorf("Ожидалась ошибка ErrorCodeSDPParsing, получено: %v", err)
+	}
+}
+
+// TestAnswerAcceptsRTPProfiles проверяет, что аудио с профилями RTP/AVPF и
+// RTP/SAVP(F) принимается, а не отклоняется как неподдерживаемое
+func TestAnswerAcceptsRTPProfiles(t *testing.T) {
+	for _, proto := range []string{"RTP/AVP", "RTP/AVPF", "RTP/SAVP", "RTP/SAVPF"} {
+		t.Run(proto, func(t *testing.T) {
+			offer := &sdp.SessionDescription{}
+			err := offer.Unmarshal([]byte("v=0\r\n" +
+				"o=phone 1 1 IN IP4 127.0.0.1\r\n" +
+				"s=-\r\n" +
+				"c=IN IP4 127.0.0.1\r\n" +
+				"t=0 0\r\n" +
+				"m=audio 40000 " + proto + " 0\r\n" +
+				"a=rtpmap:0 PCMU/8000\r\n"))
+			if err != nil {
+				t.Fatalf("Не удалось разобрать offer: %v", err)
+			}
+
+			config := media_sdp.DefaultHandlerConfig()
+			config.SessionID = "profile-" + proto
+			config.Transport.LocalAddr = "127.0.0.1:0"
+			handler, err := media_sdp.NewSDPMediaHandler(config)
+			if err != nil {
+				t.Fatalf("Не удалось создать handler: %v", err)
+			}
+			defer func() { _ = handler.Stop() }()
+
+			if err := handler.ProcessOffer(offer); err != nil {
+				t.Fatalf("Offer с %s должен приниматься: %v", proto, err)
+			}
+			answer, err := handler.CreateAnswer()
+			if err != nil {
+				t.Fatalf("Не удалось создать answer: %v", err)
+			}
+			if port := answer.MediaDescriptions[0].MediaName.Port.Value; port == 0 {
+				t.Errorf("Аудио с %s не должно отклоняться портом 0", proto)
+			}
+		})
+	}
+}
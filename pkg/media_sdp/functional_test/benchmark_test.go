@@ -0,0 +1,114 @@
+package functional_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/pion/sdp/v3"
+)
+
+// BenchmarkBuilderCreation измеряет создание builder вместе с RTP транспортами
+func BenchmarkBuilderCreation(b *testing.B) {
+	config := media_sdp.DefaultBuilderConfig()
+	config.Transport.LocalAddr = "127.0.0.1:0"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		config.SessionID = fmt.Sprintf("bench-builder-%d", i)
+		builder, err := media_sdp.NewSDPMediaBuilder(config)
+		if err != nil {
+			b.Fatalf("Не удалось создать builder: %v", err)
+		}
+		_ = builder.Stop()
+	}
+}
+
+// BenchmarkSDPOfferAnswer измеряет согласование offer/answer с сериализацией SDP,
+// как это происходит при обработке INVITE и 200 OK
+func BenchmarkSDPOfferAnswer(b *testing.B) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+
+	b.Run("CreateOffer", func(b *testing.B) {
+		builderConfig.SessionID = "bench-offer"
+		builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+		if err != nil {
+			b.Fatalf("Не удалось создать builder: %v", err)
+		}
+		defer func() { _ = builder.Stop() }()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			offer, err := builder.CreateOffer()
+			if err != nil {
+				b.Fatalf("Не удалось создать offer: %v", err)
+			}
+			if _, err := offer.Marshal(); err != nil {
+				b.Fatalf("Не удалось сериализовать offer: %v", err)
+			}
+		}
+	})
+
+	b.Run("Negotiate", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			// Создание сессий измеряется в BenchmarkBuilderCreation
+			b.StopTimer()
+			builderConfig.SessionID = fmt.Sprintf("bench-caller-%d", i)
+			builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+			if err != nil {
+				b.Fatalf("Не удалось создать builder: %v", err)
+			}
+			handlerConfig.SessionID = fmt.Sprintf("bench-callee-%d", i)
+			handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+			if err != nil {
+				b.Fatalf("Не удалось создать handler: %v", err)
+			}
+			b.StartTimer()
+
+			offer, err := builder.CreateOffer()
+			if err != nil {
+				b.Fatalf("Не удалось создать offer: %v", err)
+			}
+			received := roundTripSDP(b, offer)
+			if err := handler.ProcessOffer(received); err != nil {
+				b.Fatalf("Не удалось обработать offer: %v", err)
+			}
+
+			answer, err := handler.CreateAnswer()
+			if err != nil {
+				b.Fatalf("Не удалось создать answer: %v", err)
+			}
+			if err := builder.ProcessAnswer(roundTripSDP(b, answer)); err != nil {
+				b.Fatalf("Не удалось обработать answer: %v", err)
+			}
+
+			b.StopTimer()
+			_ = builder.Stop()
+			_ = handler.Stop()
+			b.StartTimer()
+		}
+	})
+}
+
+// roundTripSDP сериализует и разбирает SDP, имитируя передачу в теле SIP сообщения
+func roundTripSDP(b *testing.B, desc *sdp.SessionDescription) *sdp.SessionDescription {
+	data, err := desc.Marshal()
+	if err != nil {
+		b.Fatalf("Не удалось сериализовать SDP: %v", err)
+	}
+
+	var parsed sdp.SessionDescription
+	if err := parsed.Unmarshal(data); err != nil {
+		b.Fatalf("Не удалось разобрать SDP: %v", err)
+	}
+	return &parsed
+}
@@ -0,0 +1,199 @@
+package functional_test
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	pionrtp "github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// startProbedCall запускает вызов builder с пробным окном входящего медиа к
+// удаленной стороне remote и отправляет один RTP пакет
+func startProbedCall(t *testing.T, sessionID string, remote net.PacketConn) (media_sdp.SDPMediaBuilder, <-chan media_sdp.NoInboundMediaReport) {
+	t.Helper()
+	reports := make(chan media_sdp.NoInboundMediaReport, 1)
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = sessionID
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.NoInboundMediaTimeout = 200 * time.Millisecond
+	config.OnNoInboundMedia = func(report media_sdp.NoInboundMediaReport) {
+		reports <- report
+	}
+
+	builder, err := media_sdp.NewSDPMediaBuilder(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	t.Cleanup(func() { _ = builder.Close() })
+
+	if _, err := builder.CreateOffer(); err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	answer := legacyOffer(t, "0", "sendrecv")
+	answer.MediaDescriptions[0].MediaName.Port.Value = remote.LocalAddr().(*net.UDPAddr).Port
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	if err := builder.Start(); err != nil {
+		t.Fatalf("Не удалось запустить builder: %v", err)
+	}
+	if err := builder.GetRTPSession().SendAudio(make([]byte, 160), 20*time.Millisecond); err != nil {
+		t.Fatalf("Не удалось отправить аудио: %v", err)
+	}
+	return builder, reports
+}
+
+// TestNoInboundMedia проверяет отчет о вызове, в котором мы отправляем RTP,
+// но не получаем ни одного пакета
+func TestNoInboundMedia(t *testing.T) {
+	remote, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось открыть сокет: %v", err)
+	}
+	defer remote.Close()
+
+	builder, reports := startProbedCall(t, "blackhole-caller", remote)
+	local, _ := builder.GetNegotiatedMedia()
+
+	var report media_sdp.NoInboundMediaReport
+	select {
+	case report = <-reports:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Отчет об отсутствии входящего медиа не получен")
+	}
+
+	if report.SessionID != "blackhole-caller" || report.Elapsed < 200*time.Millisecond {
+		t.Errorf("Неверный отчет: %+v", report)
+	}
+	if report.Path.PacketsSent == 0 || report.Path.PacketsReceived != 0 {
+		t.Errorf("Неверные счетчики пакетов: %+v", report.Path)
+	}
+	if !strings.Contains(report.LocalSDP, "m=audio "+strconv.Itoa(localPort(t, local.LocalRTPAddr))) {
+		t.Errorf("Отчет должен содержать наш SDP:\n%s", report.LocalSDP)
+	}
+	if !strings.Contains(report.RemoteSDP, "m=audio "+strconv.Itoa(remote.LocalAddr().(*net.UDPAddr).Port)) {
+		t.Errorf("Отчет должен содержать SDP удаленной стороны:\n%s", report.RemoteSDP)
+	}
+	if report.Socket.LocalRTPAddr != local.LocalRTPAddr || report.Socket.RemoteRTPAddr != remote.LocalAddr().String() ||
+		!report.Socket.Active {
+		t.Errorf("Неверное состояние сокетов: %+v", report.Socket)
+	}
+	if len(report.Findings) == 0 {
+		t.Error("Отчет должен содержать выводы")
+	}
+
+	// Отчет отправляется один раз
+	select {
+	case report := <-reports:
+		t.Errorf("Повторный отчет: %+v", report)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+// TestInboundMediaReceived проверяет, что полученный пакет снимает проверку
+func TestInboundMediaReceived(t *testing.T) {
+	remote, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось открыть сокет: %v", err)
+	}
+	defer remote.Close()
+
+	builder, reports := startProbedCall(t, "inbound-caller", remote)
+	local, _ := builder.GetNegotiatedMedia()
+
+	packet := &pionrtp.Packet{
+		Header:  pionrtp.Header{Version: 2, PayloadType: 0, SequenceNumber: 1, Timestamp: 160, SSRC: 0x1234},
+		Payload: make([]byte, 160),
+	}
+	data, err := packet.Marshal()
+	if err != nil {
+		t.Fatalf("Не удалось сериализовать RTP пакет: %v", err)
+	}
+	localAddr, err := net.ResolveUDPAddr("udp", local.LocalRTPAddr)
+	if err != nil {
+		t.Fatalf("Не удалось разобрать локальный адрес: %v", err)
+	}
+	if _, err := remote.WriteTo(data, localAddr); err != nil {
+		t.Fatalf("Не удалось отправить RTP пакет: %v", err)
+	}
+
+	select {
+	case report := <-reports:
+		t.Errorf("Отчет не ожидался: %+v", report)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+// localPort возвращает порт адреса host:port
+func localPort(t *testing.T, addr string) int {
+	t.Helper()
+	_, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("Некорректный адрес %s: %v", addr, err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	return port
+}
+
+// TestInboundProbeDuringRestart проверяет, что проверка пробного окна на
+// таймере не конфликтует с пересозданием RTP сессии повторным offer и Close
+// (запускается с -race)
+func TestInboundProbeDuringRestart(t *testing.T) {
+	remote, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось открыть сокет: %v", err)
+	}
+	defer remote.Close()
+
+	reports := make(chan media_sdp.NoInboundMediaReport, 1)
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = "probe-restart"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.SupportedCodecs = append(config.SupportedCodecs, media_sdp.CodecInfo{
+		PayloadType: 96, Name: "L16", ClockRate: 16000, Channels: 1, Ptime: 20 * time.Millisecond,
+	})
+	config.NoInboundMediaTimeout = 20 * time.Millisecond
+	config.OnNoInboundMedia = func(report media_sdp.NoInboundMediaReport) {
+		reports <- report
+	}
+	handler, err := media_sdp.NewSDPMediaHandler(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+
+	offer := func(formats string, attrs ...string) *sdp.SessionDescription {
+		offer := legacyOffer(t, formats, attrs...)
+		offer.MediaDescriptions[0].MediaName.Port.Value = remote.LocalAddr().(*net.UDPAddr).Port
+		return offer
+	}
+	if err := handler.ProcessOffer(offer("0", "sendrecv")); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Не удалось запустить handler: %v", err)
+	}
+	if err := handler.GetRTPSession().SendAudio(make([]byte, 160), 20*time.Millisecond); err != nil {
+		t.Fatalf("Не удалось отправить аудио: %v", err)
+	}
+
+	// Смена частоты RTP clock пересоздает транспорт и RTP сессию, пока
+	// таймер пробного окна проверяет вызов
+	for i := 0; i < 2; i++ {
+		reOffer := offer("0", "sendrecv")
+		if i%2 == 0 {
+			reOffer = offer("96", "sendrecv", "rtpmap:96 L16/16000")
+		}
+		if err := handler.ProcessOffer(reOffer); err != nil {
+			t.Fatalf("Не удалось обработать повторный offer: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := handler.Close(); err != nil {
+		t.Errorf("Ошибка Close: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package functional_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/pion/sdp/v3"
+)
+
+const testCryptoAttribute = "crypto:1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz"
+
+// negotiateCapabilities выполняет обмен offer/answer с потенциальными
+// конфигурациями и возвращает answer
+func negotiateCapabilities(t *testing.T, accept func(media_sdp.AcceptedConfiguration) bool) (media_sdp.SDPMediaBuilder, media_sdp.SDPMediaHandler, *sdp.SessionDescription, *sdp.SessionDescription) {
+	t.Helper()
+
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "capneg-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.PotentialConfigurations = []media_sdp.PotentialConfiguration{
+		{Protocol: "RTP/SAVP", Attributes: []string{testCryptoAttribute}},
+		{Protocol: "RTP/AVPF"},
+	}
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	t.Cleanup(func() { _ = builder.Stop() })
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "capneg-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.AcceptConfiguration = accept
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	t.Cleanup(func() { _ = handler.Stop() })
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	return builder, handler, offer, answer
+}
+
+// TestCapabilityNegotiationOffer проверяет атрибуты tcap, acap и pcfg в offer
+func TestCapabilityNegotiationOffer(t *testing.T) {
+	_, _, offer, _ := negotiateCapabilities(t, nil)
+
+	audio := offer.MediaDescriptions[0]
+	if got := strings.Join(audio.MediaName.Protos, "/"); got != "RTP/AVP" {
+		t.Errorf("Актуальная конфигурация offer должна использовать RTP/AVP, получено %s", got)
+	}
+
+	expected := map[string][]string{
+		"tcap": {"1 RTP/SAVP", "2 RTP/AVPF"},
+		"acap": {"1 " + testCryptoAttribute},
+		"pcfg": {"1 t=1 a=1", "2 t=2"},
+	}
+	for key, values := range expected {
+		var got []string
+		for _, attr := range audio.Attributes {
+			if attr.Key == key {
+				got = append(got, attr.Value)
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(values, "\n") {
+			t.Errorf("Атрибуты %s: ожидалось %q, получено %q", key, values, got)
+		}
+	}
+}
+
+// TestCapabilityNegotiationAccepted проверяет выбор конфигурации answerer
+func TestCapabilityNegotiationAccepted(t *testing.T) {
+	var offered []media_sdp.AcceptedConfiguration
+	builder, handler, _, answer := negotiateCapabilities(t, func(config media_sdp.AcceptedConfiguration) bool {
+		offered = append(offered, config)
+		return config.Protocol == "RTP/SAVP"
+	})
+
+	if len(offered) != 1 || offered[0].Number != 1 {
+		t.Errorf("Конфигурации должны проверяться по приоритету, проверены %+v", offered)
+	}
+
+	audio := answer.MediaDescriptions[0]
+	if got := strings.Join(audio.MediaName.Protos, "/"); got != "RTP/SAVP" {
+		t.Errorf("Answer должен использовать RTP/SAVP, получено %s", got)
+	}
+	if acfg, _ := audio.Attribute("acfg"); acfg != "1 t=1 a=1" {
+		t.Errorf("Некорректный атрибут acfg: %q", acfg)
+	}
+	if crypto, _ := audio.Attribute("crypto"); !strings.HasPrefix(crypto, "1 AES_CM_128_HMAC_SHA1_80") {
+		t.Errorf("Answer должен содержать атрибут crypto, получено %q", crypto)
+	}
+
+	selected, ok := handler.AcceptedConfiguration()
+	if !ok || selected.Number != 1 {
+		t.Errorf("Handler должен вернуть выбранную конфигурацию 1, получено %+v", selected)
+	}
+	accepted, ok := builder.AcceptedConfiguration()
+	if !ok {
+		t.Fatal("Builder должен получить выбранную конфигурацию из answer")
+	}
+	if accepted.Number != 1 || accepted.Protocol != "RTP/SAVP" ||
+		len(accepted.Attributes) != 1 || accepted.Attributes[0] != testCryptoAttribute {
+		t.Errorf("Некорректная выбранная конфигурация: %+v", accepted)
+	}
+}
+
+// TestCapabilityNegotiationFallback проверяет answer с актуальной конфигурацией
+func TestCapabilityNegotiationFallback(t *testing.T) {
+	builder, handler, _, answer := negotiateCapabilities(t, func(media_sdp.AcceptedConfiguration) bool { return false })
+
+	audio := answer.MediaDescriptions[0]
+	if got := strings.Join(audio.MediaName.Protos, "/"); got != "RTP/AVP" {
+		t.Errorf("Answer без выбранной конфигурации должен использовать RTP/AVP, получено %s", got)
+	}
+	if _, ok := audio.Attribute("acfg"); ok {
+		t.Error("Answer без выбранной конфигурации не должен содержать acfg")
+	}
+	if _, ok := handler.AcceptedConfiguration(); ok {
+		t.Error("Handler не должен выбирать конфигурацию")
+	}
+	if _, ok := builder.AcceptedConfiguration(); ok {
+		t.Error("Builder не должен получать выбранную конфигурацию")
+	}
+}
+
+// TestCapabilityNegotiationAlternatives проверяет разбор альтернатив pcfg
+func TestCapabilityNegotiationAlternatives(t *testing.T) {
+	offer := &sdp.SessionDescription{}
+	if err := offer.Unmarshal([]byte("v=0\r\n" +
+		"o=- 1 1 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"c=IN IP4 127.0.0.1\r\n" +
+		"t=0 0\r\n" +
+		"m=audio 40000 RTP/AVP 0\r\n" +
+		"a=rtpmap:0 PCMU/8000\r\n" +
+		"a=tcap:1 RTP/SAVPF RTP/SAVP\r\n" +
+		"a=acap:1 " + testCryptoAttribute + "\r\n" +
+		"a=acap:2 rtcp-fb:0 nack\r\n" +
+		"a=pcfg:2 t=2 a=1\r\n" +
+		"a=pcfg:1 t=1 a=1,2|[2]\r\n")); err != nil {
+		t.Fatalf("Не удалось разобрать offer: %v", err)
+	}
+
+	var offered []string
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "capneg-alternatives"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.AcceptConfiguration = func(config media_sdp.AcceptedConfiguration) bool {
+		offered = append(offered, config.Protocol)
+		return config.Protocol == "RTP/SAVP"
+	}
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+
+	// Вариант с необязательными атрибутами [2] не поддерживается и пропускается
+	if strings.Join(offered, ",") != "RTP/SAVPF,RTP/SAVP" {
+		t.Errorf("Некорректный порядок вариантов: %v", offered)
+	}
+	selected, ok := handler.AcceptedConfiguration()
+	if !ok || selected.Number != 2 || len(selected.Attributes) != 1 {
+		t.Errorf("Ожидалась конфигурация 2 с crypto, получено %+v", selected)
+	}
+}
@@ -0,0 +1,89 @@
+package functional_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/pion/sdp/v3"
+)
+
+// TestRtpmapClockRates проверяет, что частоты RTP clock берутся из
+// согласованных rtpmap, а не считаются равными 8000
+func TestRtpmapClockRates(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "clock-rate-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+
+	// Удаленная сторона предлагает telephone-event с частотой 16000
+	dtmfPT := builderConfig.DTMFPayloadType
+	setDTMFClockRate(offer, dtmfPT, 16000)
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "clock-rate-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	if rate := handler.GetMediaSession().GetClockRate(media.PayloadType(dtmfPT)); rate != 16000 {
+		t.Errorf("Handler должен использовать частоту из rtpmap offer, получено %d", rate)
+	}
+
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if !strings.Contains(string(mustMarshal(t, answer)), fmt.Sprintf("a=rtpmap:%d telephone-event/16000", dtmfPT)) {
+		t.Error("Answer должен повторять частоту telephone-event из offer")
+	}
+
+	if rate := builder.GetMediaSession().GetClockRate(media.PayloadType(dtmfPT)); rate != 8000 {
+		t.Errorf("До answer builder использует частоту своего offer, получено %d", rate)
+	}
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	if rate := builder.GetMediaSession().GetClockRate(media.PayloadType(dtmfPT)); rate != 16000 {
+		t.Errorf("Builder должен использовать частоту из rtpmap answer, получено %d", rate)
+	}
+}
+
+// setDTMFClockRate заменяет частоту в rtpmap telephone-event
+func setDTMFClockRate(desc *sdp.SessionDescription, pt uint8, rate int) {
+	for _, mediaDesc := range desc.MediaDescriptions {
+		for i, attr := range mediaDesc.Attributes {
+			if attr.Key == "rtpmap" && strings.Contains(attr.Value, "telephone-event") {
+				mediaDesc.Attributes[i] = sdp.NewAttribute("rtpmap", fmt.Sprintf("%d telephone-event/%d", pt, rate))
+			}
+		}
+	}
+}
+
+// mustMarshal сериализует SDP
+func mustMarshal(t *testing.T, desc *sdp.SessionDescription) []byte {
+	data, err := desc.Marshal()
+	if err != nil {
+		t.Fatalf("Не удалось сериализовать SDP: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,115 @@
+//go:build !small
+
+package functional_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+)
+
+// stubILBCFrameCodec - внешний кодер iLBC для тестов
+type stubILBCFrameCodec struct{}
+
+func (stubILBCFrameCodec) EncodeFrame(_ []byte, mode time.Duration) ([]byte, error) {
+	if mode == 20*time.Millisecond {
+		return make([]byte, media.ILBCFrameSize20ms), nil
+	}
+	return make([]byte, media.ILBCFrameSize30ms), nil
+}
+
+func (stubILBCFrameCodec) DecodeFrame(_ []byte, mode time.Duration) ([]byte, error) {
+	return make([]byte, int(mode/time.Millisecond)*8), nil
+}
+
+// stubSpeexCodec - внешний кодек Speex для тестов
+type stubSpeexCodec struct{}
+
+func (stubSpeexCodec) Encode(audio []byte) ([]byte, error)   { return audio[:len(audio)/8], nil }
+func (stubSpeexCodec) Decode(payload []byte) ([]byte, error) { return bytes.Repeat(payload, 8), nil }
+
+// TestILBCNegotiation проверяет согласование режима iLBC с legacy устройством
+func TestILBCNegotiation(t *testing.T) {
+	media.RegisterILBCFrameCodec(func() (media.ILBCFrameCodec, error) { return stubILBCFrameCodec{}, nil })
+	defer media.RegisterILBCFrameCodec(nil)
+
+	ilbc := media_sdp.CodecInfo{PayloadType: 98, Name: "iLBC", ClockRate: 8000, Channels: 1, Ptime: 20 * time.Millisecond}
+
+	t.Run("режим 20ms устройства", func(t *testing.T) {
+		handler := legacyHandler(t, "ilbc-20", ilbc)
+		offer := legacyOffer(t, "102", "rtpmap:102 iLBC/8000", "fmtp:102 mode=20", "ptime:20")
+		if err := handler.ProcessOffer(offer); err != nil {
+			t.Fatalf("Не удалось обработать offer: %v", err)
+		}
+
+		text := answerText(t, handler)
+		if !strings.Contains(text, "a=rtpmap:102 iLBC/8000") || !strings.Contains(text, "a=fmtp:102 mode=20") {
+			t.Errorf("Answer должен принять iLBC mode=20 с payload type из offer:\n%s", text)
+		}
+		if handler.GetMediaSession().GetPtime() != 20*time.Millisecond {
+			t.Errorf("Ожидался ptime 20ms, получено %v", handler.GetMediaSession().GetPtime())
+		}
+	})
+
+	t.Run("локальный режим 30ms", func(t *testing.T) {
+		local := ilbc
+		local.Fmtp = "mode=30"
+		handler := legacyHandler(t, "ilbc-30", local)
+		offer := legacyOffer(t, "102", "rtpmap:102 iLBC/8000", "fmtp:102 mode=20", "ptime:20")
+		if err := handler.ProcessOffer(offer); err != nil {
+			t.Fatalf("Не удалось обработать offer: %v", err)
+		}
+
+		// При разных режимах используется 30ms, ptime подстраивается под кадр
+		text := answerText(t, handler)
+		if !strings.Contains(text, "a=fmtp:102 mode=30") || !strings.Contains(text, "a=ptime:30") {
+			t.Errorf("Answer должен выбрать mode=30 и ptime 30:\n%s", text)
+		}
+	})
+
+	t.Run("кодер не зарегистрирован", func(t *testing.T) {
+		media.RegisterILBCFrameCodec(nil)
+		defer media.RegisterILBCFrameCodec(func() (media.ILBCFrameCodec, error) { return stubILBCFrameCodec{}, nil })
+
+		handler := legacyHandler(t, "ilbc-fallback", ilbc)
+		offer := legacyOffer(t, "102 0", "rtpmap:102 iLBC/8000", "fmtp:102 mode=20")
+		if err := handler.ProcessOffer(offer); err != nil {
+			t.Fatalf("Не удалось обработать offer: %v", err)
+		}
+		if text := answerText(t, handler); !strings.Contains(text, "m=audio") || !strings.Contains(text, "a=rtpmap:0 PCMU/8000") {
+			t.Errorf("Без кодера iLBC должен быть выбран PCMU:\n%s", text)
+		}
+	})
+}
+
+// TestSpeexNegotiation проверяет выбор Speex по частоте из rtpmap
+func TestSpeexNegotiation(t *testing.T) {
+	var params media.SpeexParams
+	media.RegisterSpeexCodec(func(p media.SpeexParams) (media.Codec, error) {
+		params = p
+		return stubSpeexCodec{}, nil
+	})
+	defer media.RegisterSpeexCodec(nil)
+
+	handler := legacyHandler(t, "speex-callee",
+		media_sdp.CodecInfo{PayloadType: 97, Name: "speex", ClockRate: 8000, Channels: 1, Ptime: 20 * time.Millisecond},
+		media_sdp.CodecInfo{PayloadType: 99, Name: "speex", ClockRate: 16000, Channels: 1, Ptime: 20 * time.Millisecond},
+	)
+
+	offer := legacyOffer(t, "110 0", "rtpmap:110 speex/16000", "fmtp:110 vbr=on;cng=on", "ptime:20")
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+
+	text := answerText(t, handler)
+	if !strings.Contains(text, "a=rtpmap:110 speex/16000") || !strings.Contains(text, "a=fmtp:110 vbr=on;cng=on") {
+		t.Errorf("Answer должен выбрать speex/16000 и повторить fmtp:\n%s", text)
+	}
+	if params.ClockRate != 16000 || params.VBR != "on" || !params.CNG {
+		t.Errorf("Кодек Speex создан с некорректными параметрами: %+v", params)
+	}
+}
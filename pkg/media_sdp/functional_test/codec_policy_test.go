@@ -0,0 +1,144 @@
+package functional_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+var (
+	policyPCMU = media_sdp.CodecInfo{PayloadType: rtp.PayloadTypePCMU, Name: "PCMU", ClockRate: 8000, Channels: 1}
+	policyG729 = media_sdp.CodecInfo{PayloadType: rtp.PayloadTypeG729, Name: "G729", ClockRate: 8000, Channels: 1}
+	policyG722 = media_sdp.CodecInfo{PayloadType: rtp.PayloadTypeG722, Name: "G722", ClockRate: 8000, Channels: 1}
+)
+
+// codecNames возвращает имена кодеков в порядке списка
+func codecNames(codecs []media_sdp.CodecInfo) string {
+	names := make([]string, 0, len(codecs))
+	for _, codec := range codecs {
+		names = append(names, codec.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// TestCodecPolicyOrder проверяет порядок кодеков по истории качества сети
+// и сохранение истории по префиксам адресов
+func TestCodecPolicyOrder(t *testing.T) {
+	config := media_sdp.DefaultCodecPolicyConfig()
+	config.StatePath = filepath.Join(t.TempDir(), "codec-policy.json")
+
+	policy, err := media_sdp.NewCodecPolicy(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать политику: %v", err)
+	}
+
+	codecs := []media_sdp.CodecInfo{policyPCMU, policyG722, policyG729}
+	if got := codecNames(policy.Order("10.0.0.5", codecs)); got != "PCMU,G722,G729" {
+		t.Errorf("Без истории порядок не должен меняться, получено %s", got)
+	}
+
+	if err := policy.Record("10.0.0.5:5060", media_sdp.NetworkQuality{PacketLoss: 0.08, Jitter: 40 * time.Millisecond}); err != nil {
+		t.Fatalf("Не удалось записать качество: %v", err)
+	}
+	if err := policy.Record("192.168.1.10", media_sdp.NetworkQuality{PacketLoss: 0.001, Jitter: 2 * time.Millisecond}); err != nil {
+		t.Fatalf("Не удалось записать качество: %v", err)
+	}
+
+	// Загруженная из файла история дает тот же порядок
+	reloaded, err := media_sdp.NewCodecPolicy(config)
+	if err != nil {
+		t.Fatalf("Не удалось загрузить историю: %v", err)
+	}
+
+	for _, p := range []*media_sdp.CodecPolicy{policy, reloaded} {
+		if got := codecNames(p.Order("10.0.0.77", codecs)); got != "G729,PCMU,G722" {
+			t.Errorf("На линии с потерями G.729 должен быть первым, получено %s", got)
+		}
+		if got := codecNames(p.Order("192.168.1.200", codecs)); got != "G722,PCMU,G729" {
+			t.Errorf("На чистой линии G.722 должен быть первым, получено %s", got)
+		}
+		if p.Classify("10.0.1.5") != media_sdp.NetworkUnknown {
+			t.Error("Другой префикс /24 не должен иметь истории")
+		}
+	}
+
+	// Скользящее среднее: одно хорошее измерение не отменяет историю потерь
+	_ = policy.Record("10.0.0.5", media_sdp.NetworkQuality{PacketLoss: 0})
+	if quality, _ := policy.Quality("10.0.0.5"); quality.Samples != 2 || policy.Classify("10.0.0.5") != media_sdp.NetworkLossy {
+		t.Errorf("Ожидалась линия с потерями после 2 измерений: %+v", quality)
+	}
+}
+
+// TestCodecPolicyOffer проверяет применение политики в CreateOffer и
+// переключение на кодек, выбранный в answer
+func TestCodecPolicyOffer(t *testing.T) {
+	policy, err := media_sdp.NewCodecPolicy(media_sdp.DefaultCodecPolicyConfig())
+	if err != nil {
+		t.Fatalf("Не удалось создать политику: %v", err)
+	}
+	_ = policy.Record("127.0.0.1", media_sdp.NetworkQuality{PacketLoss: 0.1})
+
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "policy-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.AlternativeCodecs = []media_sdp.CodecInfo{policyG722, policyG729}
+	builderConfig.CodecPolicy = policy
+	builderConfig.Destination = "127.0.0.1:5060"
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer builder.Stop()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	formats := strings.Join(offer.MediaDescriptions[0].MediaName.Formats, " ")
+	if formats != "18 0 9 101" {
+		t.Errorf("На линии с потерями ожидался порядок 18 0 9 101, получено %s", formats)
+	}
+	if text := string(mustMarshal(t, offer)); !strings.Contains(text, "a=rtpmap:18 G729/8000") ||
+		!strings.Contains(text, "a=rtpmap:9 G722/8000") {
+		t.Errorf("Offer должен содержать rtpmap всех кодеков:\n%s", text)
+	}
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "policy-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.SupportedCodecs = []media_sdp.CodecInfo{policyG729, policyPCMU}
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer handler.Stop()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+
+	if builder.GetMediaSession().GetPayloadType() != media.PayloadTypeG729 {
+		t.Errorf("Медиа сессия должна перейти на G.729, payload type %d", builder.GetMediaSession().GetPayloadType())
+	}
+	rtpSession, ok := builder.GetRTPSession().(*rtp.Session)
+	if !ok {
+		t.Fatalf("Ожидалась RTP сессия *rtp.Session, получено %T", builder.GetRTPSession())
+	}
+	if rtpSession.GetPayloadType() != rtp.PayloadTypeG729 {
+		t.Errorf("RTP сессия должна перейти на G.729, payload type %d", rtpSession.GetPayloadType())
+	}
+}
@@ -0,0 +1,119 @@
+package functional_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	pionrtp "github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// TestOneWayAudioReport проверяет отчет при асимметрии потоков: удаленная
+// сторона за NAT отправляет RTP не с адреса из SDP и почти не отвечает
+func TestOneWayAudioReport(t *testing.T) {
+	// Адрес из SDP offer, на него уходит исходящий поток
+	signaled, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Не удалось создать UDP сокет: %v", err)
+	}
+	defer signaled.Close()
+
+	// Фактический источник входящего RTP (внешний адрес NAT)
+	natted, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Не удалось создать UDP сокет: %v", err)
+	}
+	defer natted.Close()
+
+	signaledAddr := signaled.LocalAddr().(*net.UDPAddr)
+	offerSDP := fmt.Sprintf("v=0\r\no=- 1 1 IN IP4 127.0.0.1\r\ns=-\r\nc=IN IP4 127.0.0.1\r\nt=0 0\r\n"+
+		"m=audio %d RTP/AVP 0\r\na=rtpmap:0 PCMU/8000\r\na=sendrecv\r\n", signaledAddr.Port)
+	var offer sdp.SessionDescription
+	if err := offer.UnmarshalString(offerSDP); err != nil {
+		t.Fatalf("Не удалось разобрать offer: %v", err)
+	}
+
+	reports := make(chan media_sdp.OneWayAudioReport, 1)
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = "one-way-audio"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.Transport.RTCPEnabled = false
+	config.OnOneWayAudio = func(report media_sdp.OneWayAudioReport) { reports <- report }
+
+	handler, err := media_sdp.NewSDPMediaHandler(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	if err := handler.ProcessOffer(&offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Не удалось запустить handler: %v", err)
+	}
+
+	rtpSession := handler.GetRTPSession()
+	for i := 0; i < 60; i++ {
+		if err := rtpSession.SendAudio(make([]byte, 160), 20*time.Millisecond); err != nil {
+			t.Fatalf("Не удалось отправить аудио: %v", err)
+		}
+	}
+
+	localAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: answer.MediaDescriptions[0].MediaName.Port.Value}
+	for i := 0; i < 3; i++ {
+		packet := &pionrtp.Packet{
+			Header:  pionrtp.Header{Version: 2, PayloadType: 0, SequenceNumber: uint16(i), SSRC: 0x1234},
+			Payload: make([]byte, 160),
+		}
+		data, _ := packet.Marshal()
+		if _, err := natted.WriteToUDP(data, localAddr); err != nil {
+			t.Fatalf("Не удалось отправить RTP: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for handler.OneWayAudioReport().Path.PacketsReceived < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := handler.Stop(); err != nil {
+		t.Fatalf("Ошибка остановки handler: %v", err)
+	}
+
+	var report media_sdp.OneWayAudioReport
+	select {
+	case report = <-reports:
+	default:
+		t.Fatal("OnOneWayAudio не вызван при асимметрии потоков")
+	}
+
+	if report.Path.PacketsSent != 60 || report.Path.PacketsReceived != 3 {
+		t.Errorf("Неожиданные счетчики: отправлено %d, получено %d", report.Path.PacketsSent, report.Path.PacketsReceived)
+	}
+	if report.SignaledRemoteAddr != signaledAddr.String() {
+		t.Errorf("Ожидался адрес из SDP %s, получен %s", signaledAddr, report.SignaledRemoteAddr)
+	}
+	if report.Path.RemoteSource != natted.LocalAddr().String() {
+		t.Errorf("Ожидался источник %s, получен %s", natted.LocalAddr(), report.Path.RemoteSource)
+	}
+	if len(report.Path.LatchEvents) != 1 || report.Path.FirstReceived.IsZero() || report.Path.FirstSent.IsZero() {
+		t.Errorf("Неожиданные сведения о пути: %+v", report.Path)
+	}
+
+	found := false
+	for _, finding := range report.Findings {
+		if strings.Contains(finding, "symmetric RTP") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Ожидался вывод о NAT, получено: %v", report.Findings)
+	}
+}
@@ -0,0 +1,85 @@
+package functional_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/pion/sdp/v3"
+)
+
+const diffBaseSDP = "v=0\r\n" +
+	"o=- 1 1 IN IP4 10.0.0.1\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 10.0.0.1\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 40000 RTP/AVP 0 8\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n" +
+	"a=rtpmap:8 PCMA/8000\r\n" +
+	"a=ptime:20\r\n" +
+	"a=sendrecv\r\n"
+
+// parseDiffSDP разбирает базовый SDP после замен old -> new
+func parseDiffSDP(t *testing.T, replacements ...string) *sdp.SessionDescription {
+	text := strings.NewReplacer(replacements...).Replace(diffBaseSDP)
+	desc := &sdp.SessionDescription{}
+	if err := desc.Unmarshal([]byte(text)); err != nil {
+		t.Fatalf("Не удалось разобрать SDP: %v", err)
+	}
+	return desc
+}
+
+// TestDiff проверяет обнаружение изменений между версиями SDP
+func TestDiff(t *testing.T) {
+	base := parseDiffSDP(t)
+
+	if changes := media_sdp.Diff(base, parseDiffSDP(t, "o=- 1 1", "o=- 1 2")); !changes.Empty() {
+		t.Errorf("Новая версия o= без изменений медиа: %+v", changes)
+	}
+
+	change := singleChange(t, media_sdp.Diff(base, parseDiffSDP(t, "c=IN IP4 10.0.0.1", "c=IN IP4 10.0.0.2")))
+	if !change.AddressChanged || change.OldAddress != "10.0.0.1:40000" || change.NewAddress != "10.0.0.2:40000" {
+		t.Errorf("Ожидалась смена адреса: %+v", change)
+	}
+	if !change.TransportChanged() || change.DirectionOnly() {
+		t.Error("Смена адреса требует обновления транспорта")
+	}
+
+	change = singleChange(t, media_sdp.Diff(base, parseDiffSDP(t, "a=sendrecv", "a=sendonly")))
+	if !change.DirectionOnly() || change.TransportChanged() || change.NewDirection != "sendonly" {
+		t.Errorf("Ожидалась только смена направления: %+v", change)
+	}
+
+	change = singleChange(t, media_sdp.Diff(base, parseDiffSDP(t, "RTP/AVP 0 8", "RTP/AVP 8 0", "a=ptime:20", "a=ptime:30")))
+	if !change.CodecChanged || change.OldCodec != "PCMU/8000" || change.NewCodec != "PCMA/8000" {
+		t.Errorf("Ожидалась смена кодека: %+v", change)
+	}
+	if !change.PtimeChanged || change.NewPtime != 30*time.Millisecond || change.TransportChanged() {
+		t.Errorf("Ожидалась смена ptime: %+v", change)
+	}
+
+	// Добавление и отклонение потоков
+	withVideo := parseDiffSDP(t, "a=sendrecv\r\n", "a=sendrecv\r\nm=video 40002 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\n")
+	change = singleChange(t, media_sdp.Diff(base, withVideo))
+	if !change.Added || change.Index != 1 || change.Media != "video" {
+		t.Errorf("Ожидалось добавление видео: %+v", change)
+	}
+	change = singleChange(t, media_sdp.Diff(withVideo, parseDiffSDP(t, "a=sendrecv\r\n", "a=sendrecv\r\nm=video 0 RTP/AVP 96\r\n")))
+	if !change.Removed || change.Index != 1 {
+		t.Errorf("Ожидалось отклонение видео: %+v", change)
+	}
+	change = singleChange(t, media_sdp.Diff(withVideo, base))
+	if !change.Removed || change.Media != "video" {
+		t.Errorf("Ожидалось удаление видео: %+v", change)
+	}
+}
+
+// singleChange возвращает единственное изменение
+func singleChange(t *testing.T, changes media_sdp.SDPChanges) media_sdp.StreamChange {
+	t.Helper()
+	if len(changes.Streams) != 1 {
+		t.Fatalf("Ожидалось одно изменение, получено %+v", changes.Streams)
+	}
+	return changes.Streams[0]
+}
@@ -0,0 +1,138 @@
+package functional_test
+
+import (
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/pion/sdp/v3"
+)
+
+// setDirection заменяет атрибуты направления на уровне сессии и медиа.
+// Пустая строка означает отсутствие атрибута на соответствующем уровне.
+func setDirection(desc *sdp.SessionDescription, sessionLevel, mediaLevel string) {
+	isDirection := func(key string) bool {
+		return key == "sendrecv" || key == "sendonly" || key == "recvonly" || key == "inactive"
+	}
+
+	desc.Attributes = nil
+	if sessionLevel != "" {
+		desc.Attributes = append(desc.Attributes, sdp.NewPropertyAttribute(sessionLevel))
+	}
+
+	for _, md := range desc.MediaDescriptions {
+		var attrs []sdp.Attribute
+		for _, attr := range md.Attributes {
+			if !isDirection(attr.Key) {
+				attrs = append(attrs, attr)
+			}
+		}
+		if mediaLevel != "" {
+			attrs = append(attrs, sdp.NewPropertyAttribute(mediaLevel))
+		}
+		md.Attributes = attrs
+	}
+}
+
+// TestSDPDirectionInheritance тестирует наследование атрибута направления
+// с уровня сессии и приоритет атрибута уровня медиа (RFC 4566, RFC 3264)
+func TestSDPDirectionInheritance(t *testing.T) {
+	tests := []struct {
+		name         string
+		sessionLevel string
+		mediaLevel   string
+		expected     media.Direction
+	}{
+		{"Без атрибутов", "", "", media.DirectionSendRecv},
+		{"Hold на уровне сессии", "sendonly", "", media.DirectionRecvOnly},
+		{"Inactive на уровне сессии", "inactive", "", media.DirectionInactive},
+		{"Уровень медиа переопределяет сессию", "sendonly", "sendrecv", media.DirectionSendRecv},
+		{"Только уровень медиа", "", "recvonly", media.DirectionSendOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builderConfig := media_sdp.DefaultBuilderConfig()
+			builderConfig.SessionID = "direction-caller"
+			builderConfig.Transport.LocalAddr = ":0"
+
+			builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+			if err != nil {
+				t.Fatalf("Не удалось создать builder: %v", err)
+			}
+			defer func() { _ = builder.Stop() }()
+
+			offer, err := builder.CreateOffer()
+			if err != nil {
+				t.Fatalf("Не удалось создать offer: %v", err)
+			}
+			setDirection(offer, tt.sessionLevel, tt.mediaLevel)
+
+			handlerConfig := media_sdp.DefaultHandlerConfig()
+			handlerConfig.SessionID = "direction-callee"
+			handlerConfig.Transport.LocalAddr = ":0"
+
+			handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+			if err != nil {
+				t.Fatalf("Не удалось создать handler: %v", err)
+			}
+			defer func() { _ = handler.Stop() }()
+
+			if err := handler.ProcessOffer(offer); err != nil {
+				t.Fatalf("Не удалось обработать offer: %v", err)
+			}
+
+			if got := handler.GetMediaSession().GetDirection(); got != tt.expected {
+				t.Errorf("Направление: получено %s, ожидалось %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSDPAnswerDirection тестирует применение направления из answer на стороне caller
+func TestSDPAnswerDirection(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "answer-direction-caller"
+	builderConfig.Transport.LocalAddr = ":0"
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "answer-direction-callee"
+	handlerConfig.Transport.LocalAddr = ":0"
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+
+	// Удаленная сторона только принимает, сигнализируя это на уровне сессии
+	setDirection(answer, "recvonly", "")
+
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+
+	if got := builder.GetMediaSession().GetDirection(); got != media.DirectionSendOnly {
+		t.Errorf("Направление: получено %s, ожидалось %s", got, media.DirectionSendOnly)
+	}
+}
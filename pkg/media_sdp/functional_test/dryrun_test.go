@@ -0,0 +1,150 @@
+package functional_test
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+)
+
+// TestDryRunCapacity проверяет, что пробное согласование исчерпывает диапазон
+// портов так же, как сокеты, и освобождает порты при Stop
+func TestDryRunCapacity(t *testing.T) {
+	dryRun := media_sdp.NewDryRun()
+
+	newBuilder := func(id string) (media_sdp.SDPMediaBuilder, error) {
+		config := media_sdp.DefaultBuilderConfig()
+		config.SessionID = id
+		config.Transport.LocalAddr = "0.0.0.0:0"
+		config.Transport.PortRange = &media_sdp.PortRange{Min: 42000, Max: 42007}
+		config.DryRun = dryRun
+		return media_sdp.NewSDPMediaBuilder(config)
+	}
+
+	var builders []media_sdp.SDPMediaBuilder
+	ports := make(map[int]bool)
+	for {
+		builder, err := newBuilder("dryrun-capacity")
+		if err != nil {
+			var allocErr *media_sdp.PortAllocationError
+			if !errors.As(err, &allocErr) {
+				t.Fatalf("Ожидалась PortAllocationError, получено: %v", err)
+			}
+			if !errors.Is(err, syscall.EADDRINUSE) {
+				t.Errorf("Ожидалась причина EADDRINUSE, получено: %v", err)
+			}
+			break
+		}
+		builders = append(builders, builder)
+
+		offer, err := builder.CreateOffer()
+		if err != nil {
+			t.Fatalf("Не удалось создать offer: %v", err)
+		}
+		if builder.GetMediaSession() != nil || builder.GetRTPSession() != nil {
+			t.Error("Пробное согласование не должно создавать сессии")
+		}
+		port := offer.MediaDescriptions[0].MediaName.Port.Value
+		if ports[port] {
+			t.Errorf("Порт %d выделен повторно", port)
+		}
+		ports[port] = true
+		if len(builders) > 8 {
+			t.Fatal("Диапазон портов не исчерпан")
+		}
+	}
+
+	// Четные порты 42000-42006 для RTP и нечетные для RTCP
+	if len(builders) != 4 {
+		t.Errorf("Ожидалось 4 согласования в диапазоне, получено %d", len(builders))
+	}
+	stats := dryRun.Stats()
+	if stats.PortsInUse != 8 || stats.PeakPortsInUse != 8 {
+		t.Errorf("Ожидалось 8 занятых портов, получено %+v", stats)
+	}
+	if stats.Conflicts == 0 {
+		t.Error("Исчерпание диапазона должно учитываться в Conflicts")
+	}
+
+	for _, builder := range builders {
+		if err := builder.Stop(); err != nil {
+			t.Errorf("Ошибка остановки: %v", err)
+		}
+	}
+	if stats := dryRun.Stats(); stats.PortsInUse != 0 || stats.PeakPortsInUse != 8 {
+		t.Errorf("Stop должен освободить порты, получено %+v", stats)
+	}
+
+	builder, err := newBuilder("dryrun-capacity-after-stop")
+	if err != nil {
+		t.Fatalf("Освобожденный порт не выделен повторно: %v", err)
+	}
+	_ = builder.Stop()
+}
+
+// TestDryRunOfferAnswer проверяет полное согласование offer/answer без сокетов
+func TestDryRunOfferAnswer(t *testing.T) {
+	dryRun := media_sdp.NewDryRun()
+	dryRun.Address = "192.0.2.10"
+
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "dryrun-caller"
+	builderConfig.Transport.LocalAddr = ":5004"
+	builderConfig.DryRun = dryRun
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "dryrun-callee"
+	handlerConfig.Transport.LocalAddr = ":6004"
+	handlerConfig.DryRun = dryRun
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if addr := offer.ConnectionInformation.Address.Address; addr != "192.0.2.10" {
+		t.Errorf("Ожидался адрес DryRun.Address в offer, получен %s", addr)
+	}
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if port := answer.MediaDescriptions[0].MediaName.Port.Value; port != 6004 {
+		t.Errorf("Ожидался порт 6004 в answer, получен %d", port)
+	}
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+
+	if err := builder.Start(); err != nil {
+		t.Fatalf("Не удалось запустить builder: %v", err)
+	}
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Не удалось запустить handler: %v", err)
+	}
+	if handler.GetMediaSession() != nil {
+		t.Error("Пробное согласование не должно создавать медиа сессию")
+	}
+
+	// Повторный offer (re-INVITE) обрабатывается без медиа сессии
+	reOffer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать повторный offer: %v", err)
+	}
+	if err := handler.ProcessOffer(reOffer); err != nil {
+		t.Fatalf("Не удалось обработать повторный offer: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package functional_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// TestParseFormatParameters проверяет разбор параметров fmtp
+func TestParseFormatParameters(t *testing.T) {
+	g729, err := media_sdp.ParseFormatParameters("18 annexb=no")
+	if err != nil {
+		t.Fatalf("Ошибка разбора fmtp G.729: %v", err)
+	}
+	if g729.PayloadType != 18 || g729.AnnexB() {
+		t.Errorf("Ожидался PT 18 с annexb=no, получено %+v", g729)
+	}
+
+	defaults, _ := media_sdp.ParseFormatParameters("18")
+	if !defaults.AnnexB() {
+		t.Error("Без параметра annexb по умолчанию разрешен Annex B")
+	}
+
+	dtmf, err := media_sdp.ParseFormatParameters("101 0-11,16")
+	if err != nil {
+		t.Fatalf("Ошибка разбора fmtp telephone-event: %v", err)
+	}
+	events, err := dtmf.Events()
+	if err != nil {
+		t.Fatalf("Ошибка разбора списка событий: %v", err)
+	}
+	if len(events) != 13 || events[11] != 11 || events[12] != 16 {
+		t.Errorf("Некорректный список событий: %v", events)
+	}
+
+	// Список событий проверяется только при обращении к Events
+	invalid, err := media_sdp.ParseFormatParameters("101 5-2")
+	if err != nil {
+		t.Fatalf("Ошибка разбора fmtp telephone-event: %v", err)
+	}
+	if _, err := invalid.Events(); !media_sdp.IsSDPError(err, media_sdp.ErrorCodeSDPParsing) {
+		t.Errorf("Ожидалась ошибка разбора обратного диапазона, получено %v", err)
+	}
+
+	amr, err := media_sdp.ParseFormatParameters("96 mode-set=0,2,5,7; Octet-Align=1; maxaveragebitrate=12200")
+	if err != nil {
+		t.Fatalf("Ошибка разбора fmtp AMR: %v", err)
+	}
+	modes, err := amr.ModeSet()
+	if err != nil || len(modes) != 4 || modes[3] != 7 {
+		t.Errorf("Некорректный mode-set: %v (%v)", modes, err)
+	}
+	if value, ok := amr.Get("octet-align"); !ok || value != "1" {
+		t.Errorf("Ключи параметров не должны зависеть от регистра: %q", value)
+	}
+	if bitrate, ok := amr.Bitrate(); !ok || bitrate != 12200 {
+		t.Errorf("Ожидался битрейт 12200, получено %d", bitrate)
+	}
+
+	ilbc, _ := media_sdp.ParseFormatParameters("97 mode=30")
+	if mode, ok := ilbc.Mode(); !ok || mode != 30 {
+		t.Errorf("Ожидался mode=30, получено %d", mode)
+	}
+
+	if _, err := media_sdp.ParseFormatParameters("abc annexb=no"); !media_sdp.IsSDPError(err, media_sdp.ErrorCodeSDPParsing) {
+		t.Errorf("Ожидалась ошибка разбора payload type, получено %v", err)
+	}
+}
+
+// TestG729AnnexBNegotiation проверяет, что annexb=no из offer отключает SID
+// кадры на обеих сторонах и повторяется в answer
+func TestG729AnnexBNegotiation(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "fmtp-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.PayloadType = rtp.PayloadTypeG729
+	builderConfig.Fmtp = "annexb=no"
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if !strings.Contains(string(mustMarshal(t, offer)), "a=fmtp:18 annexb=no") {
+		t.Error("Offer должен содержать fmtp G.729 из конфигурации")
+	}
+	if !builder.GetMediaSession().IsVADFramesDisabled() {
+		t.Error("Builder с annexb=no должен отбрасывать SID кадры")
+	}
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "fmtp-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.SupportedCodecs = []media_sdp.CodecInfo{{
+		PayloadType: rtp.PayloadTypeG729,
+		Name:        "G729",
+		ClockRate:   8000,
+		Channels:    1,
+		Ptime:       20 * time.Millisecond,
+	}}
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	if !handler.GetMediaSession().IsVADFramesDisabled() {
+		t.Error("Handler должен отключить SID кадры при annexb=no в offer")
+	}
+	params, ok := handler.GetRemoteFormatParameters(18)
+	if !ok || params.AnnexB() {
+		t.Errorf("Параметры fmtp offer должны быть доступны приложению: %+v", params)
+	}
+	if dtmf, ok := handler.GetRemoteFormatParameters(builderConfig.DTMFPayloadType); !ok || dtmf.Raw != "0-15" {
+		t.Errorf("Ожидались параметры telephone-event 0-15, получено %+v", dtmf)
+	}
+
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if !strings.Contains(string(mustMarshal(t, answer)), "a=fmtp:18 annexb=no") {
+		t.Error("Answer должен повторять fmtp выбранного кодека")
+	}
+
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	if params, ok := builder.GetRemoteFormatParameters(18); !ok || params.AnnexB() {
+		t.Errorf("Параметры fmtp answer должны быть доступны приложению: %+v", params)
+	}
+}
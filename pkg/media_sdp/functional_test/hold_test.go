@@ -0,0 +1,160 @@
+package functional_test
+
+import (
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/pion/sdp/v3"
+)
+
+// setConnectionAddress заменяет адрес соединения на уровне сессии и медиа
+func setConnectionAddress(desc *sdp.SessionDescription, address string) {
+	if desc.ConnectionInformation != nil {
+		desc.ConnectionInformation.Address = &sdp.Address{Address: address}
+	}
+	for _, md := range desc.MediaDescriptions {
+		if md.ConnectionInformation != nil {
+			md.ConnectionInformation.Address = &sdp.Address{Address: address}
+		}
+	}
+}
+
+// TestZeroAddressHoldOffer тестирует удержание через c=0.0.0.0 в offer и его снятие
+func TestZeroAddressHoldOffer(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "hold-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	activeAddress := offer.ConnectionInformation.Address.Address
+
+	var holdEvents []bool
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "hold-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.OnHoldChanged = func(onHold bool) {
+		holdEvents = append(holdEvents, onHold)
+	}
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	// Первоначальный offer уже на удержании
+	setConnectionAddress(offer, "0.0.0.0")
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer с нулевым адресом: %v", err)
+	}
+
+	if !handler.IsOnHold() {
+		t.Error("Handler должен быть на удержании")
+	}
+	if got := handler.GetMediaSession().GetDirection(); got != media.DirectionInactive {
+		t.Errorf("При удержании направление должно быть inactive, получено %s", got)
+	}
+
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if _, ok := answer.MediaDescriptions[0].Attribute("inactive"); !ok {
+		t.Error("Answer на удержании должен содержать a=inactive")
+	}
+
+	// Снятие удержания повторным offer с реальным адресом
+	setConnectionAddress(offer, activeAddress)
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать повторный offer: %v", err)
+	}
+
+	if handler.IsOnHold() {
+		t.Error("Удержание должно быть снято")
+	}
+	if got := handler.GetMediaSession().GetDirection(); got != media.DirectionSendRecv {
+		t.Errorf("После снятия удержания направление должно быть sendrecv, получено %s", got)
+	}
+
+	if len(holdEvents) != 2 || !holdEvents[0] || holdEvents[1] {
+		t.Errorf("Ожидались события [true false], получено %v", holdEvents)
+	}
+}
+
+// TestZeroAddressHoldAnswer тестирует удержание через c=0.0.0.0 в answer на стороне caller
+func TestZeroAddressHoldAnswer(t *testing.T) {
+	var holdEvents []bool
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "hold-answer-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.OnHoldChanged = func(onHold bool) {
+		holdEvents = append(holdEvents, onHold)
+	}
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "hold-answer-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	activeAddress := answer.ConnectionInformation.Address.Address
+
+	setConnectionAddress(answer, "0.0.0.0")
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer с нулевым адресом: %v", err)
+	}
+	if !builder.IsOnHold() {
+		t.Error("Builder должен быть на удержании")
+	}
+	if got := builder.GetMediaSession().GetDirection(); got != media.DirectionInactive {
+		t.Errorf("При удержании направление должно быть inactive, получено %s", got)
+	}
+
+	setConnectionAddress(answer, activeAddress)
+	answer.Origin.SessionVersion++
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	if builder.IsOnHold() {
+		t.Error("Удержание должно быть снято")
+	}
+	if got := builder.GetMediaSession().GetDirection(); got != media.DirectionSendRecv {
+		t.Errorf("После снятия удержания направление должно быть sendrecv, получено %s", got)
+	}
+
+	if len(holdEvents) != 2 || !holdEvents[0] || holdEvents[1] {
+		t.Errorf("Ожидались события [true false], получено %v", holdEvents)
+	}
+}
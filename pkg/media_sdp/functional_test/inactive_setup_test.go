@@ -0,0 +1,92 @@
+package functional_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+)
+
+// TestInactiveSetupActivation проверяет вызов, установленный с a=inactive и
+// сразу активированный повторным offer с a=sendrecv: транспорты, RTCP и DTMF
+// готовы заранее, первый пакет уходит в пределах ptime, sequence number и
+// timestamp идут без пропусков
+func TestInactiveSetupActivation(t *testing.T) {
+	remote, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось открыть сокет: %v", err)
+	}
+	defer remote.Close()
+	remotePort := remote.LocalAddr().(*net.UDPAddr).Port
+
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = "inactive-setup"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	handler, err := media_sdp.NewSDPMediaHandler(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Close() }()
+
+	offer := legacyOffer(t, "0 101", "rtpmap:101 telephone-event/8000", "inactive")
+	offer.MediaDescriptions[0].MediaName.Port.Value = remotePort
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	if _, err := handler.CreateAnswer(); err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Не удалось запустить handler: %v", err)
+	}
+
+	mediaSession := handler.GetMediaSession()
+	negotiated, _ := handler.GetNegotiatedMedia()
+	if negotiated.Direction != media.DirectionInactive || negotiated.LocalRTCPAddr == "" || !negotiated.DTMFEnabled {
+		t.Fatalf("В режиме inactive RTCP и DTMF должны быть согласованы: %+v", negotiated)
+	}
+	if err := mediaSession.SendAudioRaw(make([]byte, 160)); err == nil {
+		t.Fatal("Отправка в режиме inactive должна быть запрещена")
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	reOffer := legacyOffer(t, "0 101", "rtpmap:101 telephone-event/8000", "sendrecv")
+	reOffer.MediaDescriptions[0].MediaName.Port.Value = remotePort
+	rtpSession := handler.GetRTPSession()
+	if err := handler.ProcessOffer(reOffer); err != nil {
+		t.Fatalf("Не удалось обработать повторный offer: %v", err)
+	}
+	activated := time.Now()
+	if handler.GetRTPSession() != rtpSession {
+		t.Fatal("RTP сессия не должна пересоздаваться при активации")
+	}
+	for i := 0; i < 3; i++ {
+		if err := mediaSession.SendAudioRaw(make([]byte, 160)); err != nil {
+			t.Fatalf("Не удалось отправить аудио после активации: %v", err)
+		}
+	}
+
+	first := readRTP(t, remote)
+	if delay := time.Since(activated); delay > 20*time.Millisecond+15*time.Millisecond {
+		t.Errorf("Первый пакет после активации получен через %v, ожидалось не более ptime", delay)
+	}
+	previous := first
+	for i := 1; i < 3; i++ {
+		packet := readRTP(t, remote)
+		if packet.SequenceNumber != previous.SequenceNumber+1 || packet.Timestamp != previous.Timestamp+160 ||
+			packet.SSRC != first.SSRC {
+			t.Errorf("Разрыв потока: seq %d -> %d, timestamp %d -> %d",
+				previous.SequenceNumber, packet.SequenceNumber, previous.Timestamp, packet.Timestamp)
+		}
+		previous = packet
+	}
+
+	if err := mediaSession.SendDTMF(media.DTMF1, 60*time.Millisecond); err != nil {
+		t.Fatalf("Не удалось отправить DTMF после активации: %v", err)
+	}
+	if packet := readRTP(t, remote); packet.PayloadType != 101 {
+		t.Errorf("Ожидался DTMF пакет, получен payload type %d", packet.PayloadType)
+	}
+}
@@ -0,0 +1,76 @@
+package functional_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// TestL16Negotiation проверяет выбор L16 по частоте и количеству каналов rtpmap
+func TestL16Negotiation(t *testing.T) {
+	l16Wide := media_sdp.CodecInfo{PayloadType: 96, Name: "L16", ClockRate: 16000, Channels: 1, Ptime: 20 * time.Millisecond}
+	l16Stereo := media_sdp.CodecInfo{PayloadType: rtp.PayloadTypeL16_2CH, Name: "L16", ClockRate: 44100, Channels: 2, Ptime: 20 * time.Millisecond}
+
+	t.Run("динамический L16/16000", func(t *testing.T) {
+		handler := legacyHandler(t, "l16-wide", l16Wide)
+		offer := legacyOffer(t, "112 0", "rtpmap:112 L16/16000", "ptime:20")
+		if err := handler.ProcessOffer(offer); err != nil {
+			t.Fatalf("Не удалось обработать offer: %v", err)
+		}
+		if text := answerText(t, handler); !strings.Contains(text, "a=rtpmap:112 L16/16000\r\n") {
+			t.Errorf("Answer должен выбрать L16/16000:\n%s", text)
+		}
+	})
+
+	t.Run("количество каналов не совпадает", func(t *testing.T) {
+		handler := legacyHandler(t, "l16-channels", l16Wide)
+		offer := legacyOffer(t, "112 0", "rtpmap:112 L16/16000/2")
+		if err := handler.ProcessOffer(offer); err != nil {
+			t.Fatalf("Не удалось обработать offer: %v", err)
+		}
+		if text := answerText(t, handler); !strings.Contains(text, "a=rtpmap:0 PCMU/8000") {
+			t.Errorf("Стерео L16 не поддерживается, должен быть выбран PCMU:\n%s", text)
+		}
+	})
+
+	t.Run("статический L16 стерео", func(t *testing.T) {
+		handler := legacyHandler(t, "l16-stereo", l16Stereo)
+		offer := legacyOffer(t, "10", "rtpmap:10 L16/44100/2", "ptime:20")
+		if err := handler.ProcessOffer(offer); err != nil {
+			t.Fatalf("Не удалось обработать offer: %v", err)
+		}
+		if text := answerText(t, handler); !strings.Contains(text, "a=rtpmap:10 L16/44100/2") {
+			t.Errorf("Answer должен выбрать L16/44100/2:\n%s", text)
+		}
+		if handler.GetMediaSession().GetChannels() != 2 {
+			t.Errorf("Медиа сессия должна быть стерео, каналов: %d", handler.GetMediaSession().GetChannels())
+		}
+	})
+}
+
+// TestL16Offer проверяет rtpmap L16 стерео в offer
+func TestL16Offer(t *testing.T) {
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = "l16-offer"
+	config.PayloadType = rtp.PayloadTypeL16_2CH
+	config.ClockRate = 44100
+	config.Channels = 2
+	config.Transport.LocalAddr = "127.0.0.1:0"
+
+	builder, err := media_sdp.NewSDPMediaBuilder(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer builder.Stop()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if text := string(mustMarshal(t, offer)); !strings.Contains(text, "a=rtpmap:10 L16/44100/2") {
+		t.Errorf("Offer должен содержать L16/44100/2:\n%s", text)
+	}
+}
@@ -0,0 +1,56 @@
+package functional_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// legacyOffer создает SDP offer устройства, поддерживающего только
+// перечисленные форматы
+func legacyOffer(t *testing.T, formats string, attributes ...string) *sdp.SessionDescription {
+	text := "v=0\r\n" +
+		"o=ata 1 1 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"c=IN IP4 127.0.0.1\r\n" +
+		"t=0 0\r\n" +
+		"m=audio 40000 RTP/AVP " + formats + "\r\n"
+	for _, attr := range attributes {
+		text += "a=" + attr + "\r\n"
+	}
+
+	offer := &sdp.SessionDescription{}
+	if err := offer.Unmarshal([]byte(text)); err != nil {
+		t.Fatalf("Не удалось разобрать offer: %v", err)
+	}
+	return offer
+}
+
+// legacyHandler создает handler с поддержкой кодеков legacy устройств
+func legacyHandler(t *testing.T, sessionID string, codecs ...media_sdp.CodecInfo) media_sdp.SDPMediaHandler {
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = sessionID
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.SupportedCodecs = append(codecs, media_sdp.CodecInfo{
+		PayloadType: rtp.PayloadTypePCMU, Name: "PCMU", ClockRate: 8000, Channels: 1, Ptime: 20 * time.Millisecond,
+	})
+
+	handler, err := media_sdp.NewSDPMediaHandler(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	t.Cleanup(func() { _ = handler.Stop() })
+	return handler
+}
+
+// answerText создает answer и возвращает его текст
+func answerText(t *testing.T, handler media_sdp.SDPMediaHandler) string {
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	return string(mustMarshal(t, answer))
+}
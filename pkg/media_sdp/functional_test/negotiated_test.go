@@ -0,0 +1,94 @@
+package functional_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// TestGetNegotiatedMedia проверяет параметры медиа, согласованные в
+// offer/answer, на стороне builder и handler
+func TestGetNegotiatedMedia(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "negotiated-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.DTMFEnabled = true
+	builderConfig.DTMFPayloadType = 101
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "negotiated-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.DTMFEnabled = true
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if _, ok := builder.GetNegotiatedMedia(); ok {
+		t.Error("До answer параметры не согласованы")
+	}
+	if _, ok := handler.GetNegotiatedMedia(); ok {
+		t.Error("До offer параметры не согласованы")
+	}
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+
+	caller, ok := builder.GetNegotiatedMedia()
+	if !ok {
+		t.Fatal("Builder: параметры должны быть согласованы после answer")
+	}
+	callee, ok := handler.GetNegotiatedMedia()
+	if !ok {
+		t.Fatal("Handler: параметры должны быть согласованы после offer")
+	}
+
+	for name, negotiated := range map[string]media_sdp.NegotiatedMedia{"builder": caller, "handler": callee} {
+		if negotiated.CodecName != "PCMU" || negotiated.PayloadType != rtp.PayloadTypePCMU ||
+			negotiated.ClockRate != 8000 || negotiated.Channels != 1 {
+			t.Errorf("%s: неверный кодек %+v", name, negotiated)
+		}
+		if negotiated.Ptime != 20*time.Millisecond || negotiated.Direction != media.DirectionSendRecv {
+			t.Errorf("%s: ptime %v, направление %v", name, negotiated.Ptime, negotiated.Direction)
+		}
+		if !negotiated.DTMFEnabled || negotiated.DTMFPayloadType != 101 {
+			t.Errorf("%s: DTMF %v/%d", name, negotiated.DTMFEnabled, negotiated.DTMFPayloadType)
+		}
+		if negotiated.Protocol != "RTP/AVP" || negotiated.Encrypted {
+			t.Errorf("%s: протокол %s, шифрование %v", name, negotiated.Protocol, negotiated.Encrypted)
+		}
+		if negotiated.LocalRTCPAddr == "" || negotiated.LocalRTCPAddr == negotiated.LocalRTPAddr {
+			t.Errorf("%s: RTCP на отдельном порту, получено %q", name, negotiated.LocalRTCPAddr)
+		}
+	}
+
+	if caller.RemoteRTPAddr != callee.LocalRTPAddr {
+		t.Errorf("Удаленный RTP адрес builder %s, локальный адрес handler %s", caller.RemoteRTPAddr, callee.LocalRTPAddr)
+	}
+	if callee.RemoteRTPAddr != caller.LocalRTPAddr {
+		t.Errorf("Удаленный RTP адрес handler %s, локальный адрес builder %s", callee.RemoteRTPAddr, caller.LocalRTPAddr)
+	}
+}
@@ -0,0 +1,81 @@
+package functional_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+)
+
+// TestPayloadTypeConflicts проверяет обработку повторяющихся, конфликтующих
+// и неизвестных номеров payload type в offer
+func TestPayloadTypeConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		formats    string
+		attributes []string
+		want       string
+		warnings   []string
+	}{
+		{
+			name:       "разные rtpmap одного номера",
+			formats:    "96 0 96",
+			attributes: []string{"rtpmap:96 AMR/8000", "rtpmap:0 PCMU/8000", "rtpmap:96 opus/48000/2"},
+			want:       "RTP/AVP 0",
+			warnings: []string{
+				"payload type 96: rtpmap conflict (AMR/8000, opus/48000/2)",
+				"payload type 96: duplicate",
+			},
+		},
+		{
+			name:       "статический номер другого кодека",
+			formats:    "0 8",
+			attributes: []string{"rtpmap:0 G729/8000", "rtpmap:8 PCMA/8000"},
+			want:       "RTP/AVP 8",
+			warnings:   []string{"payload type 0: static remapped (G729/8000)"},
+		},
+		{
+			name:     "динамический номер без rtpmap",
+			formats:  "97 x 0",
+			want:     "RTP/AVP 0",
+			warnings: []string{"payload type 97: unknown", "payload type x: unknown"},
+		},
+		{
+			name:       "повтор одинакового rtpmap",
+			formats:    "0",
+			attributes: []string{"rtpmap:0 PCMU/8000", "rtpmap:0 pcmu/8000"},
+			want:       "RTP/AVP 0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var warnings []string
+			config := media_sdp.DefaultHandlerConfig()
+			config.SessionID = "payload-types"
+			config.Transport.LocalAddr = "127.0.0.1:0"
+			config.DTMFEnabled = false
+			config.OnPayloadTypeWarning = func(warning media_sdp.PayloadTypeWarning) {
+				warnings = append(warnings, warning.String())
+			}
+			handler, err := media_sdp.NewSDPMediaHandler(config)
+			if err != nil {
+				t.Fatalf("Не удалось создать handler: %v", err)
+			}
+			defer func() { _ = handler.Stop() }()
+
+			if err := handler.ProcessOffer(legacyOffer(t, tc.formats, tc.attributes...)); err != nil {
+				t.Fatalf("Не удалось обработать offer: %v", err)
+			}
+			text := answerText(t, handler)
+			if !strings.Contains(text, tc.want+"\r\n") {
+				t.Errorf("Ожидалась m= строка с %q:\n%s", tc.want, text)
+			}
+			if strings.Count(text, "a=rtpmap:") != 1 {
+				t.Errorf("Answer должен содержать один rtpmap:\n%s", text)
+			}
+			if !reflect.DeepEqual(warnings, tc.warnings) {
+				t.Errorf("Предупреждения: ожидалось %q, получено %q", tc.warnings, warnings)
+			}
+		})
+	}
+}
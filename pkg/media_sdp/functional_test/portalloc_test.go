@@ -0,0 +1,120 @@
+package functional_test
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+)
+
+// occupyPorts занимает UDP порты на 127.0.0.1, имитируя чужие сокеты вне пула
+func occupyPorts(t *testing.T, ports ...int) {
+	t.Helper()
+	for _, port := range ports {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+		if err != nil {
+			t.Skipf("Порт %d недоступен для теста: %v", port, err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+	}
+}
+
+// TestPortAllocationAlternateRange проверяет переход к альтернативному
+// диапазону, когда все порты основного заняты
+func TestPortAllocationAlternateRange(t *testing.T) {
+	occupyPorts(t, 41000, 41002)
+
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = "portalloc-alternate"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.Transport.PortRange = &media_sdp.PortRange{Min: 41000, Max: 41003}
+	config.Transport.AlternatePortRanges = []media_sdp.PortRange{{Min: 41010, Max: 41013}}
+
+	builder, err := media_sdp.NewSDPMediaBuilder(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	port := offer.MediaDescriptions[0].MediaName.Port.Value
+	if port != 41010 && port != 41012 {
+		t.Errorf("Ожидался порт из альтернативного диапазона, получен %d", port)
+	}
+}
+
+// TestPortAllocationRetryRTCP проверяет повтор, когда занят порт RTCP (RTP + 1)
+func TestPortAllocationRetryRTCP(t *testing.T) {
+	occupyPorts(t, 41021)
+
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = "portalloc-rtcp"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.Transport.PortRange = &media_sdp.PortRange{Min: 41020, Max: 41023}
+
+	builder, err := media_sdp.NewSDPMediaBuilder(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if port := offer.MediaDescriptions[0].MediaName.Port.Value; port != 41022 {
+		t.Errorf("Ожидался порт 41022, получен %d", port)
+	}
+}
+
+// TestPortAllocationExhausted проверяет диагностику при исчерпании диапазонов
+func TestPortAllocationExhausted(t *testing.T) {
+	occupyPorts(t, 41030, 41040)
+
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = "portalloc-exhausted"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.Transport.PortRange = &media_sdp.PortRange{Min: 41030, Max: 41031}
+	config.Transport.AlternatePortRanges = []media_sdp.PortRange{{Min: 41040, Max: 41040}}
+
+	_, err := media_sdp.NewSDPMediaBuilder(config)
+	if err == nil {
+		t.Fatal("Ожидалась ошибка выделения порта")
+	}
+
+	var allocErr *media_sdp.PortAllocationError
+	if !errors.As(err, &allocErr) {
+		t.Fatalf("Ожидалась PortAllocationError, получено: %v", err)
+	}
+	if len(allocErr.Attempts) != 2 || allocErr.Attempts[0].Port != 41030 || allocErr.Attempts[1].Port != 41040 {
+		t.Errorf("Неожиданные попытки: %+v", allocErr.Attempts)
+	}
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		t.Errorf("Ожидалась ошибка ОС EADDRINUSE: %v", err)
+	}
+	t.Logf("Диагностика: %v", err)
+}
+
+// TestPortRangeValidation проверяет проверку диапазонов в конфигурации
+func TestPortRangeValidation(t *testing.T) {
+	invalid := []media_sdp.TransportConfig{
+		{LocalAddr: ":0", PortRange: &media_sdp.PortRange{Min: 20000, Max: 10000}},
+		{LocalAddr: ":0", PortRange: &media_sdp.PortRange{Min: 10001, Max: 10001}},
+		{LocalAddr: ":0", AlternatePortRanges: []media_sdp.PortRange{{Min: 10000, Max: 10010}}},
+		{LocalAddr: ":0", PortRange: &media_sdp.PortRange{Min: 10000, Max: 10010},
+			AlternatePortRanges: []media_sdp.PortRange{{Min: 0, Max: 70000}}},
+	}
+
+	for i, transport := range invalid {
+		config := media_sdp.DefaultBuilderConfig()
+		config.Transport = transport
+		if err := config.Validate(); err == nil {
+			t.Errorf("Конфигурация %d должна быть отклонена", i)
+		}
+	}
+}
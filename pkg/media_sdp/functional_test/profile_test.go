@@ -0,0 +1,105 @@
+package functional_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// TestProfileSelector проверяет выбор медиа профиля по tenant и адресату
+func TestProfileSelector(t *testing.T) {
+	selector := media_sdp.NewProfileSelector()
+
+	register := func(tenant, pattern string, profile media_sdp.MediaProfile) {
+		t.Helper()
+		if err := selector.Register(tenant, pattern, profile); err != nil {
+			t.Fatalf("Не удалось зарегистрировать профиль %s: %v", profile.Name, err)
+		}
+	}
+	register("acme", "", media_sdp.MediaProfile{Name: "acme"})
+	register("", "10.0.0.0/8", media_sdp.MediaProfile{Name: "lan"})
+	register("", "*.carrier.example", media_sdp.MediaProfile{Name: "carrier"})
+
+	if _, ok := selector.Select("", "192.0.2.1:5060"); ok {
+		t.Error("Без профиля по умолчанию вызов без правила не должен получить профиль")
+	}
+
+	cases := []struct {
+		tenant      string
+		destination string
+		profile     string
+	}{
+		{"acme", "10.1.2.3:5060", "acme"},
+		{"other", "10.1.2.3:5060", "lan"},
+		{"", "sbc1.Carrier.example:5060", "carrier"},
+		{"", "192.0.2.1", "default"},
+	}
+
+	if err := selector.SetDefault(media_sdp.MediaProfile{Name: "default"}); err != nil {
+		t.Fatalf("Не удалось задать профиль по умолчанию: %v", err)
+	}
+	for _, tc := range cases {
+		profile, ok := selector.Select(tc.tenant, tc.destination)
+		if !ok || profile.Name != tc.profile {
+			t.Errorf("Для %s/%s ожидался профиль %s, получен %s", tc.tenant, tc.destination, tc.profile, profile.Name)
+		}
+	}
+
+	if err := selector.Register("", "[", media_sdp.MediaProfile{Name: "bad"}); !media_sdp.IsSDPError(err, media_sdp.ErrorCodeInvalidConfig) {
+		t.Errorf("Некорректный шаблон должен вернуть ErrorCodeInvalidConfig, получено %v", err)
+	}
+}
+
+// TestProfileBuilder проверяет применение профиля к offer
+func TestProfileBuilder(t *testing.T) {
+	selector := media_sdp.NewProfileSelector()
+	err := selector.Register("", "203.0.113.0/24", media_sdp.MediaProfile{
+		Name: "trunk",
+		Codecs: []media_sdp.CodecInfo{
+			{PayloadType: rtp.PayloadTypeG729, Name: "G729", ClockRate: 8000, Fmtp: "annexb=no"},
+			{PayloadType: rtp.PayloadTypePCMA, Name: "PCMA", ClockRate: 8000},
+		},
+		Ptime:    30 * time.Millisecond,
+		DTMFMode: media_sdp.DTMFModeNone,
+	})
+	if err != nil {
+		t.Fatalf("Не удалось зарегистрировать профиль: %v", err)
+	}
+
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = "profile-trunk"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+
+	builder, err := selector.NewBuilder(config, "", "203.0.113.7:5060")
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer builder.Stop()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if formats := strings.Join(offer.MediaDescriptions[0].MediaName.Formats, " "); formats != "18 8" {
+		t.Errorf("Ожидались форматы 18 8 без telephone-event, получено %s", formats)
+	}
+	text := string(mustMarshal(t, offer))
+	for _, attr := range []string{"a=fmtp:18 annexb=no", "a=ptime:30"} {
+		if !strings.Contains(text, attr) {
+			t.Errorf("Offer должен содержать %s:\n%s", attr, text)
+		}
+	}
+
+	srtp := media_sdp.MediaProfile{Name: "secure", RequireSRTP: true}
+	config.Transport.Type = media_sdp.TransportTypeMultiplexed
+	if err := srtp.Apply(&config); !media_sdp.IsSDPError(err, media_sdp.ErrorCodeInvalidConfig) {
+		t.Errorf("SRTP профиль без DTLS транспорта должен вернуть ошибку, получено %v", err)
+	}
+	config.Transport.Type = media_sdp.TransportTypeUDP
+	if err := srtp.Apply(&config); err != nil || config.Transport.Type != media_sdp.TransportTypeDTLS {
+		t.Errorf("SRTP профиль должен выбрать DTLS транспорт: %v, %d", err, config.Transport.Type)
+	}
+}
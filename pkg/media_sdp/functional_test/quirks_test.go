@@ -0,0 +1,124 @@
+package functional_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/pion/sdp/v3"
+)
+
+// mediaAttribute возвращает значение атрибута аудио m= строки
+func mediaAttribute(desc *sdp.SessionDescription, key string) (string, bool) {
+	return desc.MediaDescriptions[0].Attribute(key)
+}
+
+// TestSDPQuirksPerTrunk проверяет особенности SDP транков, заданные данными
+// и выбранные профилем по адресату
+func TestSDPQuirksPerTrunk(t *testing.T) {
+	var quirks []media_sdp.SDPQuirks
+	err := json.Unmarshal([]byte(`[
+		{"name": "carrier-a", "omit_ptime": true, "media_attributes": ["silenceSupp:off - - - -"]},
+		{"name": "carrier-b", "session_name": "SBC", "origin_username": "softphone",
+		 "session_attributes": ["x-carrier:b"], "remove_attributes": ["sendrecv"]}
+	]`), &quirks)
+	if err != nil {
+		t.Fatalf("Не удалось разобрать особенности: %v", err)
+	}
+
+	selector := media_sdp.NewProfileSelector()
+	if err := selector.Register("", "10.1.0.0/16", media_sdp.MediaProfile{Name: "a", Quirks: &quirks[0]}); err != nil {
+		t.Fatalf("Не удалось зарегистрировать профиль: %v", err)
+	}
+	if err := selector.Register("", "*.carrier-b.example", media_sdp.MediaProfile{Name: "b", Quirks: &quirks[1]}); err != nil {
+		t.Fatalf("Не удалось зарегистрировать профиль: %v", err)
+	}
+
+	dryRun := media_sdp.NewDryRun()
+	createOffer := func(destination string) *sdp.SessionDescription {
+		t.Helper()
+		config := media_sdp.DefaultBuilderConfig()
+		config.SessionID = "quirks-" + destination
+		config.Transport.LocalAddr = "127.0.0.1:0"
+		config.DryRun = dryRun
+		builder, err := selector.NewBuilder(config, "", destination)
+		if err != nil {
+			t.Fatalf("Не удалось создать builder: %v", err)
+		}
+		t.Cleanup(func() { _ = builder.Stop() })
+		offer, err := builder.CreateOffer()
+		if err != nil {
+			t.Fatalf("Не удалось создать offer: %v", err)
+		}
+		return offer
+	}
+
+	offerA := createOffer("10.1.2.3:5060")
+	if _, ok := mediaAttribute(offerA, "ptime"); ok {
+		t.Error("Транк A не принимает a=ptime")
+	}
+	if value, ok := mediaAttribute(offerA, "silenceSupp"); !ok || value != "off - - - -" {
+		t.Errorf("Ожидался a=silenceSupp:off - - - -, получено %q", value)
+	}
+	if _, ok := mediaAttribute(offerA, "rtpmap"); !ok {
+		t.Error("Особенности не должны затрагивать остальные атрибуты")
+	}
+
+	offerB := createOffer("gw1.carrier-b.example")
+	if offerB.SessionName != "SBC" || offerB.Origin.Username != "softphone" {
+		t.Errorf("Ожидались s=SBC и o=softphone, получено s=%s o=%s", offerB.SessionName, offerB.Origin.Username)
+	}
+	if value, ok := offerB.Attribute("x-carrier"); !ok || value != "b" {
+		t.Errorf("Ожидался атрибут сессии x-carrier:b, получено %q", value)
+	}
+	if _, ok := mediaAttribute(offerB, "sendrecv"); ok {
+		t.Error("Транк B не принимает a=sendrecv")
+	}
+	if _, ok := mediaAttribute(offerB, "ptime"); !ok {
+		t.Error("Транк B сохраняет a=ptime")
+	}
+
+	// Адресат без профиля получает SDP без изменений
+	offerDefault := createOffer("192.0.2.1")
+	if _, ok := mediaAttribute(offerDefault, "silenceSupp"); ok {
+		t.Error("Особенности транка не должны применяться к другим адресатам")
+	}
+
+	// Answer на offer транка A с особенностями транка A
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "quirks-answer"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.DryRun = dryRun
+	handlerConfig.Quirks = &quirks[0]
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+	if err := handler.ProcessOffer(offerDefault); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if _, ok := mediaAttribute(answer, "ptime"); ok {
+		t.Error("Answer транку A не должен содержать a=ptime")
+	}
+	if _, ok := mediaAttribute(answer, "silenceSupp"); !ok {
+		t.Error("Answer транку A должен содержать a=silenceSupp")
+	}
+}
+
+// TestSDPQuirksValidation проверяет отказ от некорректных атрибутов
+func TestSDPQuirksValidation(t *testing.T) {
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = "quirks-invalid"
+	config.Quirks = &media_sdp.SDPQuirks{Name: "broken", MediaAttributes: []string{"bad name:x"}}
+
+	_, err := media_sdp.NewSDPMediaBuilder(config)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Ожидалась ошибка конфигурации особенностей, получено: %v", err)
+	}
+}
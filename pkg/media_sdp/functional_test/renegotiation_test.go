@@ -0,0 +1,365 @@
+package functional_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	pionrtp "github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// TestReOfferInPlace проверяет, что повторный offer без смены адреса меняет
+// направление, кодек и ptime на месте: локальный адрес и SSRC сохраняются
+func TestReOfferInPlace(t *testing.T) {
+	handler := legacyHandler(t, "renegotiation-callee", media_sdp.CodecInfo{
+		PayloadType: rtp.PayloadTypePCMA, Name: "PCMA", ClockRate: 8000, Channels: 1, Ptime: 20 * time.Millisecond,
+	})
+
+	if err := handler.ProcessOffer(legacyOffer(t, "0 8", "sendrecv")); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	initial, _ := handler.GetNegotiatedMedia()
+	ssrc := handler.GetRTPSession().GetSSRC()
+
+	steps := []struct {
+		name      string
+		formats   string
+		attrs     []string
+		codec     string
+		ptime     time.Duration
+		direction media.Direction
+	}{
+		{"только направление", "0 8", []string{"sendonly"}, "PCMU", 20 * time.Millisecond, media.DirectionRecvOnly},
+		{"кодек с той же частотой", "8", []string{"sendrecv"}, "PCMA", 20 * time.Millisecond, media.DirectionSendRecv},
+		{"ptime", "8", []string{"sendrecv", "ptime:30"}, "PCMA", 30 * time.Millisecond, media.DirectionSendRecv},
+	}
+	for _, step := range steps {
+		if err := handler.ProcessOffer(legacyOffer(t, step.formats, step.attrs...)); err != nil {
+			t.Fatalf("%s: не удалось обработать повторный offer: %v", step.name, err)
+		}
+		negotiated, _ := handler.GetNegotiatedMedia()
+		if negotiated.LocalRTPAddr != initial.LocalRTPAddr {
+			t.Errorf("%s: локальный адрес изменился %s -> %s", step.name, initial.LocalRTPAddr, negotiated.LocalRTPAddr)
+		}
+		if got := handler.GetRTPSession().GetSSRC(); got != ssrc {
+			t.Errorf("%s: SSRC изменился %d -> %d", step.name, ssrc, got)
+		}
+		if negotiated.CodecName != step.codec {
+			t.Errorf("%s: ожидался кодек %s, получен %s", step.name, step.codec, negotiated.CodecName)
+		}
+		if got := handler.GetMediaSession().GetPtime(); got != step.ptime {
+			t.Errorf("%s: ожидался ptime %v, получен %v", step.name, step.ptime, got)
+		}
+		if got := handler.GetMediaSession().GetDirection(); got != step.direction {
+			t.Errorf("%s: ожидалось направление %s, получено %s", step.name, step.direction, got)
+		}
+	}
+
+	if text := answerText(t, handler); !strings.Contains(text, "m=audio") || !strings.Contains(text, " RTP/AVP 8") {
+		t.Errorf("Answer должен принять PCMA:\n%s", text)
+	}
+}
+
+// TestReAnswerKeepsTransport проверяет, что answer на повторный offer с тем
+// же адресом не пересоздает транспорт builder
+func TestReAnswerKeepsTransport(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "renegotiation-caller"
+	builderConfig.Transport.Type = media_sdp.TransportTypeMultiplexed
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	handler := legacyHandler(t, "renegotiation-peer")
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	initial, _ := builder.GetNegotiatedMedia()
+	rtpSession := builder.GetRTPSession()
+
+	// Новая версия answer меняет только направление
+	reAnswer := &sdp.SessionDescription{}
+	if err := reAnswer.Unmarshal(mustMarshal(t, answer)); err != nil {
+		t.Fatalf("Не удалось разобрать answer: %v", err)
+	}
+	reAnswer.Origin.SessionVersion++
+	setDirection(reAnswer, "", "recvonly")
+	if err := builder.ProcessAnswer(reAnswer); err != nil {
+		t.Fatalf("Не удалось обработать повторный answer: %v", err)
+	}
+
+	negotiated, _ := builder.GetNegotiatedMedia()
+	if negotiated.LocalRTPAddr != initial.LocalRTPAddr {
+		t.Errorf("Локальный адрес изменился %s -> %s", initial.LocalRTPAddr, negotiated.LocalRTPAddr)
+	}
+	if builder.GetRTPSession() != rtpSession {
+		t.Error("RTP сессия не должна пересоздаваться при смене направления")
+	}
+	if negotiated.Direction != media.DirectionSendOnly {
+		t.Errorf("Ожидалось направление sendonly, получено %s", negotiated.Direction)
+	}
+}
+
+// readRTP читает RTP пакет из сокета удаленной стороны
+func readRTP(t *testing.T, conn net.PacketConn) *pionrtp.Packet {
+	t.Helper()
+	buf := make([]byte, 1500)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("RTP пакет не получен на %s: %v", conn.LocalAddr(), err)
+	}
+	packet := &pionrtp.Packet{}
+	if err := packet.Unmarshal(buf[:n]); err != nil {
+		t.Fatalf("Не удалось разобрать RTP пакет: %v", err)
+	}
+	return packet
+}
+
+// TestReAnswerRemoteAddressChange проверяет перенос медиа SBC: answer на
+// повторный offer с другим портом применяется к работающему сокету без
+// пересоздания RTP сессии, поток продолжается с тем же SSRC
+func TestReAnswerRemoteAddressChange(t *testing.T) {
+	var changes []media_sdp.RemoteMediaAddressChange
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "reanchor-caller"
+	builderConfig.Transport.Type = media_sdp.TransportTypeMultiplexed
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.OnRemoteMediaAddressChanged = func(change media_sdp.RemoteMediaAddressChange) {
+		changes = append(changes, change)
+	}
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Close() }()
+
+	listen := func() net.PacketConn {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Не удалось открыть сокет: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+	oldSBC, newSBC := listen(), listen()
+	answerFrom := func(conn net.PacketConn, version uint64) *sdp.SessionDescription {
+		answer := legacyOffer(t, "0", "sendrecv", "rtcp-mux")
+		answer.Origin.SessionVersion = version
+		answer.MediaDescriptions[0].MediaName.Port.Value = conn.LocalAddr().(*net.UDPAddr).Port
+		return answer
+	}
+
+	if _, err := builder.CreateOffer(); err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if err := builder.ProcessAnswer(answerFrom(oldSBC, 1)); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	if err := builder.Start(); err != nil {
+		t.Fatalf("Не удалось запустить builder: %v", err)
+	}
+	rtpSession := builder.GetRTPSession()
+	if err := rtpSession.SendAudio(make([]byte, 160), 20*time.Millisecond); err != nil {
+		t.Fatalf("Не удалось отправить аудио: %v", err)
+	}
+	before := readRTP(t, oldSBC)
+	if len(changes) != 0 {
+		t.Fatalf("Первый answer не должен сообщать смену адреса: %+v", changes)
+	}
+
+	if err := builder.ProcessAnswer(answerFrom(newSBC, 2)); err != nil {
+		t.Fatalf("Не удалось обработать повторный answer: %v", err)
+	}
+	if builder.GetRTPSession() != rtpSession {
+		t.Fatal("RTP сессия не должна пересоздаваться при смене адреса")
+	}
+	if err := rtpSession.SendAudio(make([]byte, 160), 20*time.Millisecond); err != nil {
+		t.Fatalf("Не удалось отправить аудио после смены адреса: %v", err)
+	}
+	after := readRTP(t, newSBC)
+	if after.SSRC != before.SSRC || after.SequenceNumber <= before.SequenceNumber {
+		t.Errorf("Поток должен продолжиться: SSRC %d -> %d, seq %d -> %d",
+			before.SSRC, after.SSRC, before.SequenceNumber, after.SequenceNumber)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("Ожидалось одно событие смены адреса, получено %d", len(changes))
+	}
+	if changes[0].PreviousRTPAddr != oldSBC.LocalAddr().String() || changes[0].RTPAddr != newSBC.LocalAddr().String() ||
+		changes[0].SessionID != "reanchor-caller" {
+		t.Errorf("Неверное событие смены адреса: %+v", changes[0])
+	}
+	if negotiated, _ := builder.GetNegotiatedMedia(); negotiated.RemoteRTPAddr != newSBC.LocalAddr().String() {
+		t.Errorf("Удаленный адрес транспорта не обновлен: %s", negotiated.RemoteRTPAddr)
+	}
+}
+
+// TestReOfferRemoteAddressChange проверяет смену адреса повторным offer на
+// стороне answerer
+func TestReOfferRemoteAddressChange(t *testing.T) {
+	var changes []media_sdp.RemoteMediaAddressChange
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = "reanchor-callee"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	config.OnRemoteMediaAddressChanged = func(change media_sdp.RemoteMediaAddressChange) {
+		changes = append(changes, change)
+	}
+	handler, err := media_sdp.NewSDPMediaHandler(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Close() }()
+
+	if err := handler.ProcessOffer(legacyOffer(t, "0", "sendrecv")); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	ssrc := handler.GetRTPSession().GetSSRC()
+	rtpSession := handler.GetRTPSession()
+
+	// Повторный offer без изменений адреса не сообщается
+	if err := handler.ProcessOffer(legacyOffer(t, "0", "sendonly")); err != nil {
+		t.Fatalf("Не удалось обработать повторный offer: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("Смена направления не должна сообщать смену адреса: %+v", changes)
+	}
+
+	reOffer := legacyOffer(t, "0", "sendrecv", "rtcp:41001")
+	reOffer.MediaDescriptions[0].MediaName.Port.Value = 41000
+	if err := handler.ProcessOffer(reOffer); err != nil {
+		t.Fatalf("Не удалось обработать повторный offer: %v", err)
+	}
+	if handler.GetRTPSession() != rtpSession || handler.GetRTPSession().GetSSRC() != ssrc {
+		t.Error("RTP сессия и SSRC должны сохраниться")
+	}
+
+	expected := media_sdp.RemoteMediaAddressChange{
+		SessionID:        "reanchor-callee",
+		PreviousRTPAddr:  "127.0.0.1:40000",
+		RTPAddr:          "127.0.0.1:41000",
+		PreviousRTCPAddr: "127.0.0.1:40001",
+		RTCPAddr:         "127.0.0.1:41001",
+	}
+	if len(changes) != 1 || changes[0] != expected {
+		t.Fatalf("Неверные события смены адреса: %+v", changes)
+	}
+	negotiated, _ := handler.GetNegotiatedMedia()
+	if negotiated.RemoteRTPAddr != expected.RTPAddr || negotiated.RemoteRTCPAddr != expected.RTCPAddr {
+		t.Errorf("Адреса транспорта не обновлены: %s, %s", negotiated.RemoteRTPAddr, negotiated.RemoteRTCPAddr)
+	}
+}
+
+// TestReOfferRejectedCodec проверяет, что повторный offer без совместимого
+// кодека отклоняется, а вызов продолжается с прежними кодеком, направлением
+// и RTP сессией
+func TestReOfferRejectedCodec(t *testing.T) {
+	handler := legacyHandler(t, "reoffer-rejected")
+
+	if err := handler.ProcessOffer(legacyOffer(t, "0", "sendrecv")); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	rtpSession := handler.GetRTPSession()
+	ssrc := rtpSession.GetSSRC()
+
+	err := handler.ProcessOffer(legacyOffer(t, "18", "sendonly", "rtpmap:18 G729/8000"))
+	if !media_sdp.IsSDPError(err, media_sdp.ErrorCodeIncompatibleCodec) {
+		t.Fatalf("Ожидалась ошибка несовместимого кодека, получено: %v", err)
+	}
+
+	if handler.GetRTPSession() != rtpSession || rtpSession.GetSSRC() != ssrc {
+		t.Error("RTP сессия и SSRC должны сохраниться")
+	}
+	negotiated, _ := handler.GetNegotiatedMedia()
+	if negotiated.CodecName != "PCMU" {
+		t.Errorf("Ожидался прежний кодек PCMU, получен %s", negotiated.CodecName)
+	}
+	if got := handler.GetMediaSession().GetDirection(); got != media.DirectionSendRecv {
+		t.Errorf("Ожидалось прежнее направление sendrecv, получено %s", got)
+	}
+}
+
+// TestReOfferClockRateRestart проверяет, что кодек с другой частотой RTP
+// clock пересоздает транспорт и RTP сессию: поток продолжается с новым SSRC
+// и payload type, медиа сессия сохраняется
+func TestReOfferClockRateRestart(t *testing.T) {
+	handler := legacyHandler(t, "reoffer-restart", media_sdp.CodecInfo{
+		PayloadType: 96, Name: "L16", ClockRate: 16000, Channels: 1, Ptime: 20 * time.Millisecond,
+	})
+
+	remote, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось открыть сокет: %v", err)
+	}
+	defer func() { _ = remote.Close() }()
+	offerFrom := func(formats string, attrs ...string) *sdp.SessionDescription {
+		offer := legacyOffer(t, formats, append(attrs, "rtcp-mux")...)
+		offer.MediaDescriptions[0].MediaName.Port.Value = remote.LocalAddr().(*net.UDPAddr).Port
+		return offer
+	}
+
+	if err := handler.ProcessOffer(offerFrom("0", "sendrecv")); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Не удалось запустить handler: %v", err)
+	}
+	initial, _ := handler.GetNegotiatedMedia()
+	rtpSession := handler.GetRTPSession()
+	mediaSession := handler.GetMediaSession()
+	if err := rtpSession.SendAudio(make([]byte, 160), 20*time.Millisecond); err != nil {
+		t.Fatalf("Не удалось отправить аудио: %v", err)
+	}
+	before := readRTP(t, remote)
+
+	if err := handler.ProcessOffer(offerFrom("96", "sendrecv", "rtpmap:96 L16/16000")); err != nil {
+		t.Fatalf("Не удалось обработать повторный offer: %v", err)
+	}
+
+	if handler.GetRTPSession() == rtpSession {
+		t.Fatal("RTP сессия должна пересоздаваться при смене частоты RTP clock")
+	}
+	if handler.GetMediaSession() != mediaSession {
+		t.Error("Медиа сессия должна сохраниться")
+	}
+	negotiated, _ := handler.GetNegotiatedMedia()
+	if negotiated.CodecName != "L16" || negotiated.ClockRate != 16000 {
+		t.Errorf("Ожидался кодек L16/16000, получен %s/%d", negotiated.CodecName, negotiated.ClockRate)
+	}
+	if negotiated.LocalRTPAddr == initial.LocalRTPAddr {
+		t.Errorf("Ожидался новый локальный адрес, остался %s", negotiated.LocalRTPAddr)
+	}
+	if text := answerText(t, handler); !strings.Contains(text, " RTP/AVP 96") {
+		t.Errorf("Answer должен принять L16:\n%s", text)
+	}
+
+	if err := handler.GetRTPSession().SendAudio(make([]byte, 640), 20*time.Millisecond); err != nil {
+		t.Fatalf("Не удалось отправить аудио после перезапуска: %v", err)
+	}
+	after := readRTP(t, remote)
+	if after.PayloadType != 96 || after.SSRC == before.SSRC {
+		t.Errorf("Ожидался поток L16 с новым SSRC: PT %d, SSRC %d -> %d",
+			after.PayloadType, before.SSRC, after.SSRC)
+	}
+}
@@ -0,0 +1,114 @@
+package functional_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// TestRTCPAttributeRemotePort проверяет, что RTCP отправляется на порт из
+// a=rtcp offer, а не на RTP порт + 1
+func TestRTCPAttributeRemotePort(t *testing.T) {
+	rtcpListener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Не удалось открыть RTCP сокет: %v", err)
+	}
+	defer func() { _ = rtcpListener.Close() }()
+	rtcpPort := rtcpListener.LocalAddr().(*net.UDPAddr).Port
+
+	var offer sdp.SessionDescription
+	if err := offer.Unmarshal([]byte("v=0\r\n" +
+		"o=- 1 1 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"c=IN IP4 127.0.0.1\r\n" +
+		"t=0 0\r\n" +
+		"m=audio 40100 RTP/AVP 0\r\n" +
+		"a=rtpmap:0 PCMU/8000\r\n" +
+		fmt.Sprintf("a=rtcp:%d\r\n", rtcpPort))); err != nil {
+		t.Fatalf("Не удалось разобрать offer: %v", err)
+	}
+
+	config := media_sdp.DefaultHandlerConfig()
+	config.SessionID = "rtcp-attribute"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	handler, err := media_sdp.NewSDPMediaHandler(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(&offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Не удалось запустить handler: %v", err)
+	}
+
+	session, ok := handler.GetRTPSession().(interface{ SendSourceDescription() error })
+	if !ok {
+		t.Fatal("RTP сессия не поддерживает отправку SDES")
+	}
+	if err := session.SendSourceDescription(); err != nil {
+		t.Fatalf("Не удалось отправить SDES: %v", err)
+	}
+
+	_ = rtcpListener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	if _, _, err := rtcpListener.ReadFromUDP(buf); err != nil {
+		t.Errorf("RTCP не получен на порт из a=rtcp: %v", err)
+	}
+}
+
+// TestRTCPAttributeEmission проверяет a=rtcp в offer при RTCP не на
+// соседнем порту и его отсутствие при RTP порт + 1
+func TestRTCPAttributeEmission(t *testing.T) {
+	createOffer := func(id string, configure func(*media_sdp.BuilderConfig)) *sdp.MediaDescription {
+		t.Helper()
+		config := media_sdp.DefaultBuilderConfig()
+		config.SessionID = id
+		config.Transport.LocalAddr = "127.0.0.1:0"
+		configure(&config)
+		builder, err := media_sdp.NewSDPMediaBuilder(config)
+		if err != nil {
+			t.Fatalf("Не удалось создать builder: %v", err)
+		}
+		t.Cleanup(func() { _ = builder.Stop() })
+		offer, err := builder.CreateOffer()
+		if err != nil {
+			t.Fatalf("Не удалось создать offer: %v", err)
+		}
+		return offer.MediaDescriptions[0]
+	}
+
+	// Мультиплексирование: RTCP на порту RTP
+	muxed := createOffer("rtcp-mux", func(c *media_sdp.BuilderConfig) {
+		c.Transport.RTCPMuxMode = rtp.RTCPMuxDemux
+	})
+	if value, ok := muxed.Attribute("rtcp"); !ok || value != fmt.Sprint(muxed.MediaName.Port.Value) {
+		t.Errorf("Ожидался a=rtcp:%d, получено %q", muxed.MediaName.Port.Value, value)
+	}
+
+	// Диапазон портов занимает RTP и RTCP на соседних портах
+	adjacent := createOffer("rtcp-adjacent", func(c *media_sdp.BuilderConfig) {
+		c.Transport.PortRange = &media_sdp.PortRange{Min: 41100, Max: 41119}
+	})
+	if value, ok := adjacent.Attribute("rtcp"); ok {
+		t.Errorf("a=rtcp не нужен при RTCP на RTP порт + 1, получено %q", value)
+	}
+
+	// Без RTCP атрибут не добавляется
+	disabled := createOffer("rtcp-disabled", func(c *media_sdp.BuilderConfig) {
+		c.Transport.RTCPEnabled = false
+	})
+	for _, attr := range disabled.Attributes {
+		if strings.HasPrefix(attr.Key, "rtcp") {
+			t.Errorf("Атрибут %s без RTCP", attr.Key)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package functional_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+)
+
+// requirePortReleased проверяет, что RTP порт из SDP снова можно занять
+func requirePortReleased(t *testing.T, port int) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Errorf("Порт %d не освобожден: %v", port, err)
+		return
+	}
+	_ = conn.Close()
+}
+
+// TestCloseShutdownOrdering проверяет завершение запущенного вызова одним
+// Close и идемпотентность повторных Close/Stop
+func TestCloseShutdownOrdering(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "close-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Close() }()
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "close-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Close() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	if err := builder.Start(); err != nil {
+		t.Fatalf("Не удалось запустить builder: %v", err)
+	}
+	if err := handler.Start(); err != nil {
+		t.Fatalf("Не удалось запустить handler: %v", err)
+	}
+	if err := builder.GetMediaSession().SendAudio(make([]byte, 160)); err != nil {
+		t.Fatalf("Не удалось отправить аудио: %v", err)
+	}
+
+	for name, closer := range map[string]interface{ Close() error }{"handler": handler, "builder": builder} {
+		if err := closer.Close(); err != nil {
+			t.Errorf("Ошибка Close %s: %v", name, err)
+		}
+		if err := closer.Close(); err != nil {
+			t.Errorf("Повторный Close %s должен быть идемпотентным: %v", name, err)
+		}
+	}
+	if err := builder.Stop(); err != nil {
+		t.Errorf("Stop после Close должен быть идемпотентным: %v", err)
+	}
+
+	if state := builder.GetMediaSession().GetState(); state != media.MediaStateClosed {
+		t.Errorf("Медиа сессия не остановлена: %v", state)
+	}
+	if err := builder.GetMediaSession().RemoveRTPSession("primary"); err == nil {
+		t.Error("RTP сессия должна быть отключена от медиа сессии")
+	}
+	requirePortReleased(t, offer.MediaDescriptions[0].MediaName.Port.Value)
+	requirePortReleased(t, answer.MediaDescriptions[0].MediaName.Port.Value)
+}
+
+// TestCloseBeforeStart проверяет освобождение ресурсов вызова, который не
+// запускался (отказ до ответа)
+func TestCloseBeforeStart(t *testing.T) {
+	config := media_sdp.DefaultBuilderConfig()
+	config.SessionID = "close-rejected"
+	config.Transport.LocalAddr = "127.0.0.1:0"
+	builder, err := media_sdp.NewSDPMediaBuilder(config)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if err := builder.Close(); err != nil {
+		t.Fatalf("Ошибка Close: %v", err)
+	}
+	if state := builder.GetMediaSession().GetState(); state != media.MediaStateClosed {
+		t.Errorf("Медиа сессия не остановлена: %v", state)
+	}
+	requirePortReleased(t, offer.MediaDescriptions[0].MediaName.Port.Value)
+}
+
+// TestShutdownErrorSteps проверяет доступ к ошибкам отдельных шагов
+func TestShutdownErrorSteps(t *testing.T) {
+	closeErr := errors.New("close failed")
+	err := media_sdp.WrapSDPError(media_sdp.ErrorCodeSessionStop, "call-1", &media_sdp.ShutdownError{
+		SessionID: "call-1",
+		Steps: []*media_sdp.ShutdownStepError{
+			{Step: media_sdp.ShutdownCloseTransport, Err: closeErr},
+		},
+	}, "Ошибка при остановке сессий")
+
+	var shutdownErr *media_sdp.ShutdownError
+	if !errors.As(err, &shutdownErr) {
+		t.Fatal("ShutdownError должна извлекаться из ошибки Close")
+	}
+	if shutdownErr.Failed(media_sdp.ShutdownCloseTransport) != closeErr || shutdownErr.Failed(media_sdp.ShutdownStopMedia) != nil {
+		t.Error("Неверные ошибки шагов")
+	}
+	if !errors.Is(err, closeErr) || !media_sdp.IsSDPError(err, media_sdp.ErrorCodeSessionStop) {
+		t.Error("Ошибка шага должна находиться через errors.Is")
+	}
+	if shutdownErr.Error() != "ошибка завершения медиа сессии call-1: close-transport: close failed" {
+		t.Errorf("Неверный текст ошибки: %s", shutdownErr.Error())
+	}
+}
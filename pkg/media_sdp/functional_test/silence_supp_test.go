@@ -0,0 +1,136 @@
+package functional_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/media_sdp"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// TestSilenceSuppNegotiation проверяет, что a=silenceSupp:off удаленной
+// стороны отключает VAD/CNG на обеих сторонах вызова G.729 с Annex B
+func TestSilenceSuppNegotiation(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "silence-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.PayloadType = rtp.PayloadTypeG729
+	builderConfig.SilenceSuppression = media.SilenceSuppressionOn
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+	if !strings.Contains(string(mustMarshal(t, offer)), "a=silenceSupp:on - - - -") {
+		t.Error("Offer должен содержать a=silenceSupp:on")
+	}
+	if mode := builder.GetMediaSession().GetSilenceSuppression(); mode != media.SilenceSuppressionDefault {
+		t.Errorf("До answer подавление пауз не согласовано, получено %s", mode)
+	}
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "silence-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.SilenceSuppression = media.SilenceSuppressionOff
+	handlerConfig.SupportedCodecs = []media_sdp.CodecInfo{{
+		PayloadType: rtp.PayloadTypeG729,
+		Name:        "G729",
+		ClockRate:   8000,
+		Channels:    1,
+		Ptime:       20 * time.Millisecond,
+	}}
+
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	handlerSession := handler.GetMediaSession()
+	if mode := handlerSession.GetSilenceSuppression(); mode != media.SilenceSuppressionOff {
+		t.Errorf("Локальный отказ должен отключить подавление пауз, получено %s", mode)
+	}
+	if !handlerSession.IsVADFramesDisabled() {
+		t.Error("a=silenceSupp:off должен отключить SID кадры G.729")
+	}
+
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if !strings.Contains(string(mustMarshal(t, answer)), "a=silenceSupp:off - - - -") {
+		t.Error("Answer должен содержать согласованный a=silenceSupp:off")
+	}
+
+	if err := builder.ProcessAnswer(answer); err != nil {
+		t.Fatalf("Не удалось обработать answer: %v", err)
+	}
+	builderSession := builder.GetMediaSession()
+	if mode := builderSession.GetSilenceSuppression(); mode != media.SilenceSuppressionOff {
+		t.Errorf("a=silenceSupp:off в answer должен отключить подавление пауз, получено %s", mode)
+	}
+	if !builderSession.IsVADFramesDisabled() {
+		t.Error("Builder должен отключить SID кадры G.729 по a=silenceSupp:off")
+	}
+}
+
+// TestSilenceSuppAbsent проверяет, что без a=silenceSupp в offer answer его
+// не содержит, а Annex B остается в силе
+func TestSilenceSuppAbsent(t *testing.T) {
+	builderConfig := media_sdp.DefaultBuilderConfig()
+	builderConfig.SessionID = "silence-absent-caller"
+	builderConfig.Transport.LocalAddr = "127.0.0.1:0"
+	builderConfig.PayloadType = rtp.PayloadTypeG729
+
+	builder, err := media_sdp.NewSDPMediaBuilder(builderConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать builder: %v", err)
+	}
+	defer func() { _ = builder.Stop() }()
+
+	offer, err := builder.CreateOffer()
+	if err != nil {
+		t.Fatalf("Не удалось создать offer: %v", err)
+	}
+
+	handlerConfig := media_sdp.DefaultHandlerConfig()
+	handlerConfig.SessionID = "silence-absent-callee"
+	handlerConfig.Transport.LocalAddr = "127.0.0.1:0"
+	handlerConfig.SupportedCodecs = []media_sdp.CodecInfo{{
+		PayloadType: rtp.PayloadTypeG729,
+		Name:        "G729",
+		ClockRate:   8000,
+		Channels:    1,
+		Ptime:       20 * time.Millisecond,
+	}}
+	handler, err := media_sdp.NewSDPMediaHandler(handlerConfig)
+	if err != nil {
+		t.Fatalf("Не удалось создать handler: %v", err)
+	}
+	defer func() { _ = handler.Stop() }()
+
+	if err := handler.ProcessOffer(offer); err != nil {
+		t.Fatalf("Не удалось обработать offer: %v", err)
+	}
+	answer, err := handler.CreateAnswer()
+	if err != nil {
+		t.Fatalf("Не удалось создать answer: %v", err)
+	}
+	if strings.Contains(string(mustMarshal(t, answer)), "silenceSupp") {
+		t.Error("Answer не должен содержать a=silenceSupp без запроса в offer")
+	}
+	if handler.GetMediaSession().IsVADFramesDisabled() {
+		t.Error("Без annexb=no и a=silenceSupp:off SID кадры разрешены")
+	}
+}
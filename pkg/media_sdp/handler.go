@@ -5,6 +5,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/arzzra/soft_phone/pkg/media"
@@ -18,16 +19,36 @@ type sdpMediaHandler struct {
 	config          HandlerConfig
 	processedOffer  *sdp.SessionDescription
 	selectedCodec   CodecInfo
+	selectedFmtp    string // Согласованные параметры fmtp выбранного кодека для answer
 	remoteAddr      string
+	remoteRTCPAddr  string // RTCP адрес из a=rtcp offer или RTP порт + 1
 	direction       media.Direction
 	ptime           time.Duration
 	dtmfEnabled     bool
 	dtmfPayloadType uint8
+	remoteHold      bool                         // Удаленная сторона на удержании (c=0.0.0.0)
+	clockRates      map[media.PayloadType]uint32 // Частоты RTP clock из rtpmap offer
+	remoteFmtp      map[uint8]FormatParameters   // Параметры fmtp из offer
+	acceptedConfig  *AcceptedConfiguration       // Выбранная потенциальная конфигурация offer (RFC 5939)
+	audioIndex      int                          // Номер принятого аудио m= в offer, остальные отклоняются
+
+	// Подавление пауз, согласованное с a=silenceSupp offer
+	silenceSuppression media.SilenceSuppression
 
 	mediaSession  *media.MediaSession
 	rtpSession    rtp.SessionRTP
 	transportPair *rtp.TransportPair
 	started       bool
+
+	// inboundProbe - пробное окно входящего медиа (nil - отключено)
+	inboundProbe *inboundMediaProbe
+	// callMu защищает замену транспорта и сессий от чтения mediaCall из
+	// таймера пробного окна
+	callMu sync.Mutex
+
+	// Результат Close для повторных вызовов
+	closed   bool
+	closeErr error
 }
 
 // NewSDPMediaHandler создает новый SDP Media Handler
@@ -39,6 +60,8 @@ func NewSDPMediaHandler(config HandlerConfig) (SDPMediaHandler, error) {
 	handler := &sdpMediaHandler{
 		config: config,
 	}
+	handler.inboundProbe = newInboundMediaProbe(config.SessionID, config.NoInboundMediaTimeout,
+		config.OnNoInboundMedia, handler.mediaCall)
 
 	return handler, nil
 }
@@ -50,39 +73,67 @@ func (h *sdpMediaHandler) ProcessOffer(offer *sdp.SessionDescription) error {
 			"SDP offer не может быть nil")
 	}
 
-	// Ищем аудио медиа описание
-	var audioMedia *sdp.MediaDescription
-	for _, media := range offer.MediaDescriptions {
-		if media.MediaName.Media == "audio" {
-			audioMedia = media
-			break
+	// Ищем аудио медиа описание. Неподдерживаемые m= строки (видео,
+	// неизвестный транспорт) не прерывают обработку: в answer они
+	// отклоняются портом 0
+	audioIndex := findSupportedAudioMedia(offer, h.config.Transport.Type)
+	if audioIndex < 0 {
+		return NewSDPErrorWithSession(ErrorCodeSDPParsing, h.config.SessionID,
+			"Поддерживаемое аудио медиа описание не найдено в SDP offer")
+	}
+	// Повторы и конфликты номеров payload type исключаются до выбора кодека
+	audioMedia, warnings := resolvePayloadTypes(offer.MediaDescriptions[audioIndex])
+	if h.config.OnPayloadTypeWarning != nil {
+		for _, warning := range warnings {
+			h.config.OnPayloadTypeWarning(warning)
 		}
 	}
 
-	if audioMedia == nil {
-		return NewSDPErrorWithSession(ErrorCodeSDPParsing, h.config.SessionID,
-			"Аудио медиа описание не найдено в SDP offer")
+	// Повторный offer (re-INVITE) в рамках уже созданной сессии
+	if h.processedOffer != nil && (h.mediaSession != nil || h.config.DryRun != nil) {
+		audioChange, _ := Diff(h.processedOffer, offer).Stream(audioIndex)
+		if err := h.processReOffer(offer, audioMedia, audioChange); err != nil {
+			return err
+		}
+		h.audioIndex = audioIndex
+		return nil
 	}
 
+	// Параметры форматов (fmtp) из offer участвуют в выборе кодека
+	h.remoteFmtp = parseFormatParametersAttributes(audioMedia)
+
 	// Парсим и выбираем кодек
 	if err := h.parseAndSelectCodec(audioMedia); err != nil {
 		return err
 	}
 
+	// Выбираем потенциальную конфигурацию offer (RFC 5939)
+	if err := h.selectConfiguration(audioMedia); err != nil {
+		return err
+	}
+
 	// Извлекаем информацию о соединении
 	if err := h.extractConnectionInfo(offer, audioMedia); err != nil {
 		return err
 	}
 
-	// Парсим направление медиа потока
-	h.parseMediaDirection(audioMedia)
+	// Парсим направление медиа потока (с учетом атрибутов уровня сессии)
+	h.parseMediaDirection(offer, audioMedia)
 
 	// Парсим ptime
 	h.parsePtime(audioMedia)
+	h.ptime = negotiatePtime(h.selectedCodec.Name, h.selectedFmtp, h.ptime)
 
 	// Парсим DTMF поддержку
 	h.parseDTMFSupport(audioMedia)
 
+	// Подавление пауз (a=silenceSupp)
+	h.silenceSuppression = negotiateSilenceSuppression(h.config.SilenceSuppression,
+		resolveSilenceSuppression(offer, audioMedia))
+
+	// Частоты RTP clock динамических payload types из rtpmap
+	h.clockRates = parseRtpmapClockRates(audioMedia)
+
 	// Создаем транспорт на основе полученной информации
 	if err := h.createTransportFromOffer(); err != nil {
 		return err
@@ -101,10 +152,285 @@ func (h *sdpMediaHandler) ProcessOffer(offer *sdp.SessionDescription) error {
 	}
 
 	h.processedOffer = offer
+	h.audioIndex = audioIndex
+	h.notifyHoldChanged(false)
 	return nil
 }
 
-// parseAndSelectCodec парсит кодеки из SDP и выбирает подходящий
+// findSupportedAudioMedia возвращает номер первой аудио m= строки offer с
+// ненулевым портом и транспортом, который поддерживает transportType, или -1
+func findSupportedAudioMedia(offer *sdp.SessionDescription, transportType TransportType) int {
+	for i, mediaDesc := range offer.MediaDescriptions {
+		if mediaDesc.MediaName.Media != "audio" || mediaDesc.MediaName.Port.Value == 0 {
+			continue
+		}
+		if supportsRTPProfile(mediaDesc.MediaName.Protos, transportType) {
+			return i
+		}
+	}
+	return -1
+}
+
+// supportsRTPProfile проверяет транспортный протокол m= строки: профили
+// RTP/AVP, RTP/AVPF, RTP/SAVP и RTP/SAVPF принимаются любым транспортом,
+// UDP/TLS/RTP/SAVP(F) - только DTLS транспортом
+func supportsRTPProfile(protos []string, transportType TransportType) bool {
+	proto := strings.Join(protos, "/")
+	if strings.HasPrefix(proto, "RTP/AVP") || strings.HasPrefix(proto, "RTP/SAVP") {
+		return true
+	}
+	return transportType == TransportTypeDTLS && strings.HasPrefix(proto, "UDP/TLS/RTP/SAVP")
+}
+
+// rejectedMedia создает отклоненную m= строку answer: порт 0, тип медиа,
+// транспорт и форматы как в offer (RFC 3264 Section 6)
+func rejectedMedia(offered *sdp.MediaDescription) *sdp.MediaDescription {
+	return &sdp.MediaDescription{
+		MediaName: sdp.MediaName{
+			Media:   offered.MediaName.Media,
+			Port:    sdp.RangedPort{Value: 0},
+			Protos:  offered.MediaName.Protos,
+			Formats: offered.MediaName.Formats,
+		},
+	}
+}
+
+// selectConfiguration выбирает первую потенциальную конфигурацию offer,
+// принятую HandlerConfig.AcceptConfiguration
+func (h *sdpMediaHandler) selectConfiguration(mediaDesc *sdp.MediaDescription) error {
+	h.acceptedConfig = nil
+	if h.config.AcceptConfiguration == nil {
+		return nil
+	}
+
+	configs, err := parsePotentialConfigurations(mediaDesc)
+	if err != nil {
+		return WrapSDPError(ErrorCodeSDPParsing, h.config.SessionID, err,
+			"Некорректные потенциальные конфигурации в SDP offer")
+	}
+	for _, config := range configs {
+		if h.config.AcceptConfiguration(config) {
+			h.acceptedConfig = &config
+			return nil
+		}
+	}
+	return nil
+}
+
+// AcceptedConfiguration возвращает потенциальную конфигурацию offer,
+// выбранную для answer
+func (h *sdpMediaHandler) AcceptedConfiguration() (AcceptedConfiguration, bool) {
+	if h.acceptedConfig == nil {
+		return AcceptedConfiguration{}, false
+	}
+	return *h.acceptedConfig, true
+}
+
+// processReOffer обрабатывает повторный offer без пересоздания транспорта и
+// сессий: направление, ptime и кодек с той же частотой RTP clock меняются на
+// месте, сокеты и SSRC сохраняются. Адрес транспорта обновляется, только если
+// change (Diff с предыдущим offer) сообщает о смене адреса, порта или
+// протокола аудио потока. Кодек с другой частотой RTP clock и смена профиля
+// транспорта пересоздают транспорт и RTP сессию (restartRTPSession)
+func (h *sdpMediaHandler) processReOffer(offer *sdp.SessionDescription, audioMedia *sdp.MediaDescription, change StreamChange) error {
+	wasOnHold := h.remoteHold
+	previousAddr, previousRTCPAddr := h.remoteAddr, h.remoteRTCPAddr
+	previousCodec, previousFmtp, previousRemoteFmtp := h.selectedCodec, h.selectedFmtp, h.remoteFmtp
+
+	// Кодек выбирается до изменения сессии: offer без совместимого кодека
+	// отклоняется, и вызов продолжается с прежними параметрами. Текущий
+	// кодек сохраняется, пока он есть в offer
+	h.remoteFmtp = parseFormatParametersAttributes(audioMedia)
+	codecChanged := !h.offersSelectedCodec(audioMedia)
+	if codecChanged {
+		if err := h.parseAndSelectCodec(audioMedia); err != nil {
+			h.remoteFmtp = previousRemoteFmtp
+			return err
+		}
+	}
+	restart := change.ProtocolChanged || h.selectedCodec.ClockRate != previousCodec.ClockRate
+
+	if err := h.extractConnectionInfo(offer, audioMedia); err != nil {
+		h.selectedCodec, h.selectedFmtp, h.remoteFmtp = previousCodec, previousFmtp, previousRemoteFmtp
+		return err
+	}
+
+	h.parseMediaDirection(offer, audioMedia)
+
+	// При удержании адрес не меняем, чтобы восстановить поток после снятия
+	// удержания. Новый адрес (перенос медиа SBC) применяется к работающим
+	// сокетам без пересоздания RTP сессии
+	addressChanged := !h.remoteHold && (change.TransportChanged() || h.remoteRTCPAddr != previousRTCPAddr)
+	if addressChanged && !restart {
+		if err := h.updateTransportRemoteAddr(); err != nil {
+			h.remoteAddr, h.remoteRTCPAddr = previousAddr, previousRTCPAddr
+			return WrapSDPError(ErrorCodeTransportCreation, h.config.SessionID, err,
+				"Не удалось обновить удаленный адрес транспорта")
+		}
+	}
+
+	h.clockRates = parseRtpmapClockRates(audioMedia)
+	h.silenceSuppression = negotiateSilenceSuppression(h.config.SilenceSuppression,
+		resolveSilenceSuppression(offer, audioMedia))
+	if h.mediaSession != nil {
+		if err := h.mediaSession.SetDirection(h.direction); err != nil {
+			return WrapSDPError(ErrorCodeInvalidDirection, h.config.SessionID, err,
+				"Не удалось установить направление медиа потока")
+		}
+		h.mediaSession.SetClockRates(h.clockRates)
+		h.mediaSession.SetVADFramesDisabled(h.vadFramesDisabled())
+		h.mediaSession.SetSilenceSuppression(h.silenceSuppression)
+	}
+
+	switch {
+	case restart:
+		if err := h.restartRTPSession(); err != nil {
+			return err
+		}
+	case codecChanged:
+		if err := h.applySelectedCodec(); err != nil {
+			return err
+		}
+	}
+
+	if addressChanged {
+		notifyRemoteMediaAddressChanged(h.config.OnRemoteMediaAddressChanged, RemoteMediaAddressChange{
+			SessionID:        h.config.SessionID,
+			PreviousRTPAddr:  previousAddr,
+			RTPAddr:          h.remoteAddr,
+			PreviousRTCPAddr: previousRTCPAddr,
+			RTCPAddr:         h.remoteRTCPAddr,
+		})
+	}
+
+	if change.PtimeChanged {
+		h.parsePtime(audioMedia)
+		h.ptime = negotiatePtime(h.selectedCodec.Name, h.selectedFmtp, h.ptime)
+		if h.mediaSession != nil && h.ptime != h.mediaSession.GetPtime() {
+			if err := h.mediaSession.SetPtime(h.ptime); err != nil {
+				return WrapSDPError(ErrorCodeIncompatibleCodec, h.config.SessionID, err,
+					"Не удалось установить ptime %v", h.ptime)
+			}
+		}
+	}
+
+	h.processedOffer = offer
+	h.notifyHoldChanged(wasOnHold)
+	return nil
+}
+
+// restartRTPSession пересоздает транспорт и RTP сессию: работающая сессия не
+// может сменить частоту RTP clock или профиль транспорта, поток начинается
+// с новыми сокетами и SSRC. Медиа сессия сохраняется и переключается на
+// новую RTP сессию и выбранный кодек
+func (h *sdpMediaHandler) restartRTPSession() error {
+	if h.mediaSession != nil && h.rtpSession != nil {
+		if err := h.mediaSession.RemoveRTPSession("primary"); err != nil {
+			return WrapSDPError(ErrorCodeSessionStop, h.config.SessionID, err,
+				"Не удалось остановить RTP сессию")
+		}
+	} else if h.rtpSession != nil {
+		_ = h.rtpSession.Stop()
+	}
+	h.cleanup()
+	h.callMu.Lock()
+	h.rtpSession = nil
+	h.callMu.Unlock()
+
+	if err := h.createTransportFromOffer(); err != nil {
+		return err
+	}
+	if err := h.createRTPSession(); err != nil {
+		h.cleanup()
+		return err
+	}
+
+	// Пробное согласование не создает сессии
+	if h.mediaSession == nil {
+		return nil
+	}
+	if err := h.mediaSession.AddRTPSession("primary", h.rtpSession); err != nil {
+		return WrapSDPError(ErrorCodeMediaSessionCreation, h.config.SessionID, err,
+			"Не удалось зарегистрировать RTP сессию в медиа сессии")
+	}
+	if err := h.applySelectedCodec(); err != nil {
+		return err
+	}
+	if h.started {
+		if err := h.rtpSession.Start(); err != nil {
+			return WrapSDPError(ErrorCodeSessionStart, h.config.SessionID, err,
+				"Не удалось запустить RTP сессию")
+		}
+	}
+	return nil
+}
+
+// offersSelectedCodec проверяет, предлагает ли повторный offer выбранный кодек
+// с тем же payload type
+func (h *sdpMediaHandler) offersSelectedCodec(mediaDesc *sdp.MediaDescription) bool {
+	pt := strconv.Itoa(int(h.selectedCodec.PayloadType))
+	for _, format := range mediaDesc.MediaName.Formats {
+		if format != pt {
+			continue
+		}
+		for _, attr := range mediaDesc.Attributes {
+			if value, ok := strings.CutPrefix(attr.Value, pt+" "); ok && attr.Key == "rtpmap" {
+				return h.validateRtpmap(value, h.selectedCodec)
+			}
+		}
+		return !isDynamicPayloadType(uint8(h.selectedCodec.PayloadType))
+	}
+	return false
+}
+
+// applySelectedCodec переключает RTP и медиа сессии на выбранный кодек без
+// пересоздания транспорта
+func (h *sdpMediaHandler) applySelectedCodec() error {
+	codec := h.selectedCodec
+	if setter, ok := h.rtpSession.(interface {
+		SetPayloadType(rtp.PayloadType, uint32)
+	}); ok {
+		setter.SetPayloadType(codec.PayloadType, codec.ClockRate)
+	}
+
+	// Пробное согласование не создает медиа сессию
+	if h.mediaSession == nil {
+		return nil
+	}
+	if err := h.mediaSession.SetPayloadType(media.PayloadType(codec.PayloadType)); err != nil {
+		return WrapSDPError(ErrorCodeIncompatibleCodec, h.config.SessionID, err,
+			"Не удалось переключить медиа сессию на кодек %s", codec.Name)
+	}
+	if !usesCodecRegistry(uint8(codec.PayloadType)) {
+		h.mediaSession.SetCodec(nil)
+		return nil
+	}
+	registryCodec, err := newRegistryCodec(codec.Name, uint8(codec.PayloadType),
+		codec.ClockRate, codec.Channels, h.mediaSession.GetPtime(), h.selectedFmtp)
+	if err != nil {
+		return WrapSDPError(ErrorCodeIncompatibleCodec, h.config.SessionID, err,
+			"Не удалось создать кодек %s", codec.Name)
+	}
+	h.mediaSession.SetCodec(registryCodec)
+	return nil
+}
+
+// notifyHoldChanged вызывает OnHoldChanged если состояние удержания изменилось
+func (h *sdpMediaHandler) notifyHoldChanged(wasOnHold bool) {
+	if wasOnHold != h.remoteHold && h.config.OnHoldChanged != nil {
+		h.config.OnHoldChanged(h.remoteHold)
+	}
+}
+
+// IsOnHold возвращает true, если удаленная сторона поставила вызов на удержание
+func (h *sdpMediaHandler) IsOnHold() bool {
+	return h.remoteHold
+}
+
+// parseAndSelectCodec парсит кодеки из SDP и выбирает кодек answer.
+// По умолчанию выбирается первый поддерживаемый кодек в порядке предпочтения
+// offer (RFC 3264 Section 6.1), с PreferLocalCodecOrder - первый кодек
+// SupportedCodecs, присутствующий в offer
 func (h *sdpMediaHandler) parseAndSelectCodec(mediaDesc *sdp.MediaDescription) error {
 	// Извлекаем rtpmap атрибуты
 	rtpmapAttrs := make(map[string]string)
@@ -117,30 +443,19 @@ func (h *sdpMediaHandler) parseAndSelectCodec(mediaDesc *sdp.MediaDescription) e
 		}
 	}
 
-	// Ищем совместимый кодек среди предложенных форматов
-	for _, format := range mediaDesc.MediaName.Formats {
-		pt, err := strconv.Atoi(format)
-		if err != nil {
-			continue
-		}
-
-		// Пропускаем DTMF payload types
-		if pt >= 96 {
-			continue
-		}
-
-		// Ищем среди поддерживаемых кодеков
+	formats := mediaDesc.MediaName.Formats
+	if h.config.PreferLocalCodecOrder {
 		for _, supportedCodec := range h.config.SupportedCodecs {
-			if rtp.PayloadType(pt) == supportedCodec.PayloadType {
-				// Проверяем rtpmap если есть
-				if rtpmap, exists := rtpmapAttrs[format]; exists {
-					if h.validateRtpmap(rtpmap, supportedCodec) {
-						h.selectedCodec = supportedCodec
-						return nil
-					}
-				} else {
-					// Используем статический payload type
-					h.selectedCodec = supportedCodec
+			for _, format := range formats {
+				if h.selectOfferedCodec(format, rtpmapAttrs[format], supportedCodec) {
+					return nil
+				}
+			}
+		}
+	} else {
+		for _, format := range formats {
+			for _, supportedCodec := range h.config.SupportedCodecs {
+				if h.selectOfferedCodec(format, rtpmapAttrs[format], supportedCodec) {
 					return nil
 				}
 			}
@@ -148,7 +463,72 @@ func (h *sdpMediaHandler) parseAndSelectCodec(mediaDesc *sdp.MediaDescription) e
 	}
 
 	return NewSDPErrorWithSession(ErrorCodeIncompatibleCodec, h.config.SessionID,
-		"Не найден совместимый кодек среди предложенных: %v", mediaDesc.MediaName.Formats)
+		"Не найден совместимый кодек среди предложенных: %v", formats)
+}
+
+// selectOfferedCodec выбирает поддерживаемый кодек для формата offer, если
+// они совместимы
+func (h *sdpMediaHandler) selectOfferedCodec(format, rtpmap string, supportedCodec CodecInfo) bool {
+	pt, err := strconv.Atoi(format)
+	if err != nil {
+		return false
+	}
+
+	// Динамические payload types (AMR и другие кодеки из реестра media)
+	// определяются по rtpmap, telephone-event среди кодеков отсутствует
+	if isDynamicPayloadType(uint8(pt)) {
+		return h.selectDynamicCodec(uint8(pt), rtpmap, supportedCodec)
+	}
+
+	if rtp.PayloadType(pt) != supportedCodec.PayloadType {
+		return false
+	}
+	// Проверяем rtpmap если есть, иначе используем статический payload type
+	if rtpmap != "" && !h.validateRtpmap(rtpmap, supportedCodec) {
+		return false
+	}
+	h.selectCodec(supportedCodec)
+	return true
+}
+
+// selectCodec выбирает кодек статического payload type
+func (h *sdpMediaHandler) selectCodec(codec CodecInfo) {
+	h.selectedCodec = codec
+	h.selectedFmtp, _ = negotiateFormatParameters(codec.Name,
+		localFormatParameters(codec), h.remoteFmtp[uint8(codec.PayloadType)])
+}
+
+// selectDynamicCodec выбирает кодек для динамического payload type по rtpmap.
+// Кодек должен быть зарегистрирован в реестре media, а параметры fmtp
+// (например, mode-set и octet-align для AMR) - совместимы.
+func (h *sdpMediaHandler) selectDynamicCodec(pt uint8, rtpmap string, supportedCodec CodecInfo) bool {
+	if rtpmap == "" ||
+		!isDynamicPayloadType(uint8(supportedCodec.PayloadType)) ||
+		!h.validateRtpmap(rtpmap, supportedCodec) ||
+		!media.IsCodecRegistered(supportedCodec.Name) {
+		return false
+	}
+
+	fmtp, ok := negotiateFormatParameters(supportedCodec.Name,
+		localFormatParameters(supportedCodec), h.remoteFmtp[pt])
+	if !ok {
+		return false
+	}
+
+	// Answer использует payload type из offer
+	h.selectedCodec = supportedCodec
+	h.selectedCodec.PayloadType = rtp.PayloadType(pt)
+	h.selectedFmtp = fmtp
+	return true
+}
+
+// localFormatParameters возвращает локальные параметры fmtp кодека
+func localFormatParameters(codec CodecInfo) FormatParameters {
+	params, err := ParseFormatParameters(fmt.Sprintf("%d %s", codec.PayloadType, codec.Fmtp))
+	if err != nil {
+		return FormatParameters{}
+	}
+	return params
 }
 
 // validateRtpmap проверяет соответствие rtpmap поддерживаемому кодеку
@@ -166,7 +546,17 @@ func (h *sdpMediaHandler) validateRtpmap(rtpmap string, codec CodecInfo) bool {
 		return false
 	}
 
-	return strings.ToUpper(codec.Name) == codecName && codec.ClockRate == uint32(clockRate)
+	// Количество каналов по умолчанию - один (RFC 4566 Section 6)
+	channels := 1
+	if len(parts) > 2 {
+		channels, err = strconv.Atoi(parts[2])
+		if err != nil {
+			return false
+		}
+	}
+
+	return strings.ToUpper(codec.Name) == codecName && codec.ClockRate == uint32(clockRate) &&
+		max(int(codec.Channels), 1) == channels
 }
 
 // extractConnectionInfo извлекает информацию о соединении
@@ -188,6 +578,13 @@ func (h *sdpMediaHandler) extractConnectionInfo(offer *sdp.SessionDescription, m
 	ip := connectionInfo.Address.Address
 	port := mediaDesc.MediaName.Port.Value
 
+	// Нулевой адрес означает удержание вызова (RFC 2543): сохраняем
+	// предыдущий удаленный адрес для восстановления потока
+	h.remoteHold = isHoldConnectionAddress(ip)
+	if h.remoteHold {
+		return nil
+	}
+
 	remoteAddr, err := ParseMediaAddress(
 		fmt.Sprintf("%s %s %s", connectionInfo.NetworkType, connectionInfo.AddressType, ip),
 		port)
@@ -196,25 +593,27 @@ func (h *sdpMediaHandler) extractConnectionInfo(offer *sdp.SessionDescription, m
 			"Не удалось разобрать адрес соединения")
 	}
 
+	// RTCP адрес из a=rtcp (RFC 3605), без атрибута - RTP порт + 1
+	rtcpAddr, err := remoteRTCPAddress(mediaDesc, remoteAddr)
+	if err != nil {
+		return WrapSDPError(ErrorCodeSDPParsing, h.config.SessionID, err,
+			"Не удалось разобрать RTCP адрес")
+	}
+
 	h.remoteAddr = remoteAddr
+	h.remoteRTCPAddr = rtcpAddr
 	return nil
 }
 
-// parseMediaDirection парсит направление медиа потока
-func (h *sdpMediaHandler) parseMediaDirection(mediaDesc *sdp.MediaDescription) {
-	h.direction = media.DirectionSendRecv // по умолчанию
+// parseMediaDirection парсит направление медиа потока.
+// Атрибут уровня медиа имеет приоритет над атрибутом уровня сессии.
+func (h *sdpMediaHandler) parseMediaDirection(offer *sdp.SessionDescription, mediaDesc *sdp.MediaDescription) {
+	// Если отправитель sendonly, мы recvonly и наоборот
+	h.direction = reverseDirection(resolveDirectionAttribute(offer, mediaDesc))
 
-	for _, attr := range mediaDesc.Attributes {
-		switch attr.Key {
-		case "sendonly":
-			h.direction = media.DirectionRecvOnly // если отправитель sendonly, мы recvonly
-		case "recvonly":
-			h.direction = media.DirectionSendOnly // если отправитель recvonly, мы sendonly
-		case "sendrecv":
-			h.direction = media.DirectionSendRecv
-		case "inactive":
-			h.direction = media.DirectionInactive
-		}
+	// Удержание через c=0.0.0.0 останавливает медиа поток в обе стороны
+	if h.remoteHold {
+		h.direction = media.DirectionInactive
 	}
 }
 
@@ -258,6 +657,8 @@ func (h *sdpMediaHandler) createTransportFromOffer() error {
 	// после создания answer и отправки его обратно
 	transportConfig := h.config.Transport
 	transportConfig.RemoteAddr = "" // Очищаем RemoteAddr
+	transportConfig.randomSource = h.config.RandomSource
+	transportConfig.dryRun = h.config.DryRun
 
 	transportPair, err := CreateTransportPair(transportConfig)
 	if err != nil {
@@ -265,7 +666,14 @@ func (h *sdpMediaHandler) createTransportFromOffer() error {
 			"Не удалось создать транспорт для answer")
 	}
 
+	h.callMu.Lock()
 	h.transportPair = transportPair
+	h.callMu.Unlock()
+
+	// При удержании удаленный адрес неизвестен, он будет установлен при снятии удержания
+	if h.remoteHold {
+		return nil
+	}
 
 	// Теперь устанавливаем удаленный адрес в транспорте после его создания
 	err = h.updateTransportRemoteAddr()
@@ -279,11 +687,17 @@ func (h *sdpMediaHandler) createTransportFromOffer() error {
 
 // createRTPSession создает RTP сессию
 func (h *sdpMediaHandler) createRTPSession() error {
+	// Пробное согласование не создает RTP сессию
+	if h.config.DryRun != nil {
+		return nil
+	}
+
 	rtpConfig := rtp.SessionConfig{
-		PayloadType: h.selectedCodec.PayloadType,
-		MediaType:   rtp.MediaTypeAudio,
-		ClockRate:   h.selectedCodec.ClockRate,
-		Transport:   h.transportPair.RTP,
+		PayloadType:  h.selectedCodec.PayloadType,
+		MediaType:    rtp.MediaTypeAudio,
+		ClockRate:    h.selectedCodec.ClockRate,
+		Transport:    h.transportPair.RTP,
+		RandomSource: h.config.RandomSource,
 		LocalSDesc: rtp.SourceDescription{
 			CNAME: fmt.Sprintf("%s@%s", h.config.SessionID, getLocalHostname()),
 			NAME:  h.config.SessionName,
@@ -306,7 +720,9 @@ func (h *sdpMediaHandler) createRTPSession() error {
 			"Не удалось создать RTP сессию для answer")
 	}
 
+	h.callMu.Lock()
 	h.rtpSession = rtpSession
+	h.callMu.Unlock()
 	return nil
 }
 
@@ -317,10 +733,30 @@ func (h *sdpMediaHandler) createMediaSession() error {
 	mediaConfig.Direction = h.direction
 	mediaConfig.Ptime = h.ptime
 	mediaConfig.PayloadType = media.PayloadType(h.selectedCodec.PayloadType)
+	mediaConfig.Channels = int(h.selectedCodec.Channels)
 
 	// DTMF настройки
 	mediaConfig.DTMFEnabled = h.dtmfEnabled
 	mediaConfig.DTMFPayloadType = h.dtmfPayloadType
+	mediaConfig.ClockRates = h.clockRates
+	mediaConfig.DisableVADFrames = h.vadFramesDisabled()
+	mediaConfig.SilenceSuppression = h.silenceSuppression
+
+	// Кодек из реестра media (динамические payload types и L16)
+	if usesCodecRegistry(uint8(h.selectedCodec.PayloadType)) {
+		codec, err := newRegistryCodec(h.selectedCodec.Name, uint8(h.selectedCodec.PayloadType),
+			h.selectedCodec.ClockRate, h.selectedCodec.Channels, h.ptime, h.selectedFmtp)
+		if err != nil {
+			return WrapSDPError(ErrorCodeIncompatibleCodec, h.config.SessionID, err,
+				"Не удалось создать кодек %s", h.selectedCodec.Name)
+		}
+		mediaConfig.Codec = codec
+	}
+
+	// Пробное согласование проверяет кодек, но не создает медиа сессию
+	if h.config.DryRun != nil {
+		return nil
+	}
 
 	// Создаем медиа сессию
 	mediaSession, err := media.NewSession(mediaConfig)
@@ -337,7 +773,9 @@ func (h *sdpMediaHandler) createMediaSession() error {
 			"Не удалось зарегистрировать RTP сессию в медиа сессии")
 	}
 
+	h.callMu.Lock()
 	h.mediaSession = mediaSession
+	h.callMu.Unlock()
 	return nil
 }
 
@@ -410,6 +848,17 @@ func (h *sdpMediaHandler) CreateAnswer() (*sdp.SessionDescription, error) {
 	// Добавляем атрибуты медиа
 	mediaDesc.Attributes = h.buildAnswerMediaAttributes()
 
+	// RTCP не на соседнем порту (RFC 3605)
+	if attr, ok := localRTCPAttribute(h.transportPair, h.config.Transport.RTCPEnabled, host, port); ok {
+		mediaDesc.Attributes = append(mediaDesc.Attributes, attr)
+	}
+
+	// Выбранная потенциальная конфигурация заменяет протокол и добавляет атрибуты
+	if h.acceptedConfig != nil {
+		mediaDesc.MediaName.Protos = protos(h.acceptedConfig.Protocol, mediaDesc.MediaName.Protos)
+		mediaDesc.Attributes = append(mediaDesc.Attributes, h.acceptedConfig.sdpAttributes()...)
+	}
+
 	// Добавляем DTMF если поддерживается
 	if h.dtmfEnabled {
 		mediaDesc.MediaName.Formats = append(mediaDesc.MediaName.Formats,
@@ -419,8 +868,19 @@ func (h *sdpMediaHandler) CreateAnswer() (*sdp.SessionDescription, error) {
 		mediaDesc.Attributes = append(mediaDesc.Attributes, dtmfAttrs...)
 	}
 
-	answer.MediaDescriptions = []*sdp.MediaDescription{mediaDesc}
+	// Answer содержит столько же m= строк, сколько offer, в том же порядке
+	// (RFC 3264 Section 6)
+	answer.MediaDescriptions = make([]*sdp.MediaDescription, 0, len(h.processedOffer.MediaDescriptions))
+	for i, offered := range h.processedOffer.MediaDescriptions {
+		if i == h.audioIndex {
+			answer.MediaDescriptions = append(answer.MediaDescriptions, mediaDesc)
+		} else {
+			answer.MediaDescriptions = append(answer.MediaDescriptions, rejectedMedia(offered))
+		}
+	}
+	h.config.Quirks.apply(answer)
 
+	h.inboundProbe.setSDP(answer, h.processedOffer)
 	return answer, nil
 }
 
@@ -444,20 +904,54 @@ func (h *sdpMediaHandler) buildAnswerMediaAttributes() []sdp.Attribute {
 	ptimeMs := int(h.ptime.Nanoseconds() / 1000000)
 	attributes = append(attributes, sdp.NewAttribute("ptime", strconv.Itoa(ptimeMs)))
 
+	// Подавление пауз (RFC 3108)
+	if h.silenceSuppression != media.SilenceSuppressionDefault {
+		attributes = append(attributes, sdp.NewAttribute(silenceSuppAttribute,
+			formatSilenceSupp(h.silenceSuppression)))
+	}
+
 	// Rtpmap для выбранного кодека
-	rtpmap := fmt.Sprintf("%d %s/%d", h.selectedCodec.PayloadType,
-		h.selectedCodec.Name, h.selectedCodec.ClockRate)
+	rtpmap := formatRtpmap(uint8(h.selectedCodec.PayloadType),
+		h.selectedCodec.Name, h.selectedCodec.ClockRate, h.selectedCodec.Channels)
 	attributes = append(attributes, sdp.NewAttribute("rtpmap", rtpmap))
 
+	// Fmtp для выбранного кодека
+	if h.selectedFmtp != "" {
+		attributes = append(attributes, sdp.NewAttribute("fmtp",
+			fmt.Sprintf("%d %s", h.selectedCodec.PayloadType, h.selectedFmtp)))
+	}
+
 	return attributes
 }
 
+// vadFramesDisabled возвращает true, если для G.729 не согласован Annex B:
+// annexb=no указан в offer или в локальных параметрах кодека
+func (h *sdpMediaHandler) vadFramesDisabled() bool {
+	if media.PayloadType(h.selectedCodec.PayloadType) != media.PayloadTypeG729 {
+		return false
+	}
+	if !localFormatParameters(h.selectedCodec).AnnexB() {
+		return true
+	}
+	return !h.remoteFmtp[uint8(h.selectedCodec.PayloadType)].AnnexB()
+}
+
+// GetRemoteFormatParameters возвращает параметры fmtp из offer для payload type
+func (h *sdpMediaHandler) GetRemoteFormatParameters(pt uint8) (FormatParameters, bool) {
+	params, ok := h.remoteFmtp[pt]
+	return params, ok
+}
+
 // buildAnswerDTMFAttributes создает DTMF атрибуты для answer
 func (h *sdpMediaHandler) buildAnswerDTMFAttributes() []sdp.Attribute {
 	var attributes []sdp.Attribute
 
-	// DTMF rtpmap
-	dtmfRtpmap := fmt.Sprintf("%d telephone-event/8000", h.dtmfPayloadType)
+	// DTMF rtpmap с частотой clock из offer (RFC 4733 допускает не только 8000)
+	clockRate, ok := h.clockRates[media.PayloadType(h.dtmfPayloadType)]
+	if !ok {
+		clockRate = media.DTMFClockRate
+	}
+	dtmfRtpmap := fmt.Sprintf("%d telephone-event/%d", h.dtmfPayloadType, clockRate)
 	attributes = append(attributes, sdp.NewAttribute("rtpmap", dtmfRtpmap))
 
 	// DTMF fmtp
@@ -490,48 +984,59 @@ func (h *sdpMediaHandler) Start() error {
 	}
 
 	// Запускаем медиа сессию (она сама запустит RTP сессию)
-	if err := h.mediaSession.Start(); err != nil {
-		return WrapSDPError(ErrorCodeSessionStart, h.config.SessionID, err,
-			"Не удалось запустить медиа сессию")
+	if h.mediaSession != nil {
+		if err := h.mediaSession.Start(); err != nil {
+			return WrapSDPError(ErrorCodeSessionStart, h.config.SessionID, err,
+				"Не удалось запустить медиа сессию")
+		}
 	}
 
 	h.started = true
+	h.inboundProbe.start()
 	return nil
 }
 
-// Stop останавливает все сессии и освобождает ресурсы
-func (h *sdpMediaHandler) Stop() error {
-	if !h.started {
-		return nil
+// Close завершает медиа вызов в порядке ShutdownStep и освобождает
+// ресурсы. Повторные вызовы ничего не делают и возвращают результат первого
+func (h *sdpMediaHandler) Close() error {
+	if h.closed {
+		return h.closeErr
 	}
-
-	var lastErr error
-
-	// Останавливаем медиа сессию
-	if h.mediaSession != nil {
-		if err := h.mediaSession.Stop(); err != nil {
-			lastErr = err
-		}
+	h.closed = true
+	h.inboundProbe.stop()
+
+	teardown := mediaTeardown{
+		sessionID:     h.config.SessionID,
+		mediaSession:  h.mediaSession,
+		rtpSession:    h.rtpSession,
+		transportPair: h.transportPair,
 	}
-
-	// Останавливаем RTP сессию
-	if h.rtpSession != nil {
-		if err := h.rtpSession.Stop(); err != nil {
-			lastErr = err
+	if h.started {
+		teardown.report = func() {
+			reportOneWayAudio(h.config.OnOneWayAudio, h.OneWayAudioReport)
 		}
 	}
-
-	// Закрываем транспорты
-	h.cleanup()
-
 	h.started = false
 
-	if lastErr != nil {
-		return WrapSDPError(ErrorCodeSessionStop, h.config.SessionID, lastErr,
+	if err := teardown.run(); err != nil {
+		h.closeErr = WrapSDPError(ErrorCodeSessionStop, h.config.SessionID, err,
 			"Ошибка при остановке сессий")
 	}
+	return h.closeErr
+}
 
-	return nil
+// Stop останавливает все сессии и освобождает ресурсы (см. Close).
+//
+// Deprecated: используйте Close
+func (h *sdpMediaHandler) Stop() error {
+	return h.Close()
+}
+
+// mediaCall возвращает транспорт, RTP и медиа сессии вызова
+func (h *sdpMediaHandler) mediaCall() (*rtp.TransportPair, rtp.SessionRTP, *media.MediaSession) {
+	h.callMu.Lock()
+	defer h.callMu.Unlock()
+	return h.transportPair, h.rtpSession, h.mediaSession
 }
 
 // cleanup освобождает ресурсы транспортов
@@ -546,35 +1051,7 @@ func (h *sdpMediaHandler) updateTransportRemoteAddr() error {
 	if h.remoteAddr == "" {
 		return fmt.Errorf("удаленный адрес не установлен")
 	}
-
-	// Проверяем если у нас есть UDP транспорт с SetRemoteAddr методом
-	if udpTransport, ok := h.transportPair.RTP.(*rtp.UDPTransport); ok {
-		// Используем SetRemoteAddr для обновления удаленного адреса
-		err := udpTransport.SetRemoteAddr(h.remoteAddr)
-		if err != nil {
-			return fmt.Errorf("не удалось установить удаленный адрес: %w", err)
-		}
-
-		// Обновляем RTCP транспорт если есть
-		if h.transportPair.RTCP != nil {
-			if udpRtcpTransport, ok := h.transportPair.RTCP.(*rtp.UDPRTCPTransport); ok {
-				// RTCP порт обычно RTP порт + 1
-				rtcpRemoteAddr, err := adjustPortInAddress(h.remoteAddr, 1)
-				if err != nil {
-					return fmt.Errorf("не удалось вычислить RTCP адрес: %w", err)
-				}
-
-				err = udpRtcpTransport.SetRemoteAddr(rtcpRemoteAddr)
-				if err != nil {
-					return fmt.Errorf("не удалось установить удаленный RTCP адрес: %w", err)
-				}
-			}
-		}
-
-		return nil
-	}
-
-	return fmt.Errorf("транспорт не поддерживает установку удаленного адреса")
+	return h.transportPair.SetRemoteAddr(h.remoteAddr, h.remoteRTCPAddr)
 }
 
 // handleIncomingRTPPacket обрабатывает входящие RTP пакеты
@@ -0,0 +1,120 @@
+package media_sdp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// NegotiatedMedia - параметры медиа, согласованные в offer/answer: кодек,
+// пакетизация, направление, адреса RTP/RTCP, DTMF и шифрование. Избавляет
+// приложение от повторного разбора SDP
+type NegotiatedMedia struct {
+	CodecName   string
+	PayloadType rtp.PayloadType
+	ClockRate   uint32
+	Channels    uint8
+	Ptime       time.Duration
+	Direction   media.Direction
+	OnHold      bool // Удаленная сторона на удержании (c=0.0.0.0)
+
+	LocalRTPAddr   string
+	RemoteRTPAddr  string
+	LocalRTCPAddr  string // Пусто, если RTCP отключен
+	RemoteRTCPAddr string
+	RTCPMux        bool // RTP и RTCP на одном порту
+
+	DTMFEnabled     bool // telephone-event есть в offer и в answer
+	DTMFPayloadType uint8
+
+	Protocol  string // Транспортный протокол m= строки, например "RTP/AVP"
+	Encrypted bool   // Медиа шифруется: DTLS-SRTP транспорт или SAVP протокол
+}
+
+// newNegotiatedMedia заполняет адреса, протокол и шифрование по транспорту
+// и выбранной потенциальной конфигурации
+func newNegotiatedMedia(pair *rtp.TransportPair, transportType TransportType, accepted *AcceptedConfiguration) NegotiatedMedia {
+	negotiated := NegotiatedMedia{Protocol: "RTP/AVP"}
+	if accepted != nil && accepted.Protocol != "" {
+		negotiated.Protocol = accepted.Protocol
+	}
+	negotiated.Encrypted = transportType == TransportTypeDTLS || strings.Contains(negotiated.Protocol, "SAVP")
+
+	if pair == nil || pair.RTP == nil {
+		return negotiated
+	}
+	negotiated.LocalRTPAddr, negotiated.RemoteRTPAddr, _ = ExtractTransportInfo(pair.RTP)
+	negotiated.RTCPMux = pair.MuxMode != rtp.RTCPMuxNone
+	switch {
+	case negotiated.RTCPMux:
+		negotiated.LocalRTCPAddr, negotiated.RemoteRTCPAddr = negotiated.LocalRTPAddr, negotiated.RemoteRTPAddr
+	case pair.RTCP != nil:
+		negotiated.LocalRTCPAddr = pair.RTCP.LocalAddr().String()
+		if remote := pair.RTCP.RemoteAddr(); remote != nil {
+			negotiated.RemoteRTCPAddr = remote.String()
+		}
+	}
+	return negotiated
+}
+
+// hasFormat проверяет наличие payload type в списке форматов m= строки
+func hasFormat(mediaDesc *sdp.MediaDescription, pt uint8) bool {
+	for _, format := range mediaDesc.MediaName.Formats {
+		if format == strconv.Itoa(int(pt)) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNegotiatedMedia возвращает параметры медиа, согласованные с answer.
+// false - answer еще не обработан
+func (b *sdpMediaBuilder) GetNegotiatedMedia() (NegotiatedMedia, bool) {
+	if !b.answerProcessed {
+		return NegotiatedMedia{}, false
+	}
+
+	negotiated := newNegotiatedMedia(b.transportPair, b.config.Transport.Type, b.acceptedConfig)
+	negotiated.CodecName = b.codecName()
+	negotiated.PayloadType = b.config.PayloadType
+	negotiated.ClockRate = b.config.ClockRate
+	negotiated.Channels = max(b.config.Channels, 1)
+	negotiated.Ptime = b.config.Ptime
+	negotiated.Direction = b.config.Direction
+	if b.mediaSession != nil {
+		negotiated.Ptime = b.mediaSession.GetPtime()
+		negotiated.Direction = b.mediaSession.GetDirection()
+	}
+	negotiated.OnHold = b.remoteHold
+	negotiated.DTMFEnabled = b.dtmfNegotiated
+	if b.dtmfNegotiated {
+		negotiated.DTMFPayloadType = b.config.DTMFPayloadType
+	}
+	return negotiated, true
+}
+
+// GetNegotiatedMedia возвращает параметры медиа, выбранные для answer.
+// false - offer еще не обработан
+func (h *sdpMediaHandler) GetNegotiatedMedia() (NegotiatedMedia, bool) {
+	if h.processedOffer == nil {
+		return NegotiatedMedia{}, false
+	}
+
+	negotiated := newNegotiatedMedia(h.transportPair, h.config.Transport.Type, h.acceptedConfig)
+	negotiated.CodecName = h.selectedCodec.Name
+	negotiated.PayloadType = h.selectedCodec.PayloadType
+	negotiated.ClockRate = h.selectedCodec.ClockRate
+	negotiated.Channels = max(h.selectedCodec.Channels, 1)
+	negotiated.Ptime = h.ptime
+	negotiated.Direction = h.direction
+	negotiated.OnHold = h.remoteHold
+	negotiated.DTMFEnabled = h.dtmfEnabled
+	if h.dtmfEnabled {
+		negotiated.DTMFPayloadType = h.dtmfPayloadType
+	}
+	return negotiated, true
+}
@@ -0,0 +1,171 @@
+package media_sdp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// PayloadTypeIssue - вид проблемы с номером payload type в m= строке offer
+type PayloadTypeIssue int
+
+const (
+	// PayloadTypeDuplicate - номер повторяется в списке форматов m= строки.
+	// Учитывается первое вхождение
+	PayloadTypeDuplicate PayloadTypeIssue = iota
+	// PayloadTypeRtpmapConflict - одному номеру назначено несколько разных
+	// кодеков в rtpmap. Номер исключается из выбора кодека
+	PayloadTypeRtpmapConflict
+	// PayloadTypeStaticRemapped - статический номер (RFC 3551) назначен в
+	// rtpmap другому кодеку. Кодек выбирается по rtpmap
+	PayloadTypeStaticRemapped
+	// PayloadTypeUnknown - формат не является номером 0-127 или
+	// динамический номер не описан rtpmap. Формат исключается из выбора кодека
+	PayloadTypeUnknown
+)
+
+// String возвращает название проблемы
+func (i PayloadTypeIssue) String() string {
+	switch i {
+	case PayloadTypeDuplicate:
+		return "duplicate"
+	case PayloadTypeRtpmapConflict:
+		return "rtpmap conflict"
+	case PayloadTypeStaticRemapped:
+		return "static remapped"
+	case PayloadTypeUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("issue%d", int(i))
+	}
+}
+
+// PayloadTypeWarning описывает проблему с номером payload type в offer.
+// Конфликты разрешаются детерминированно: отклоняется только конфликтующий
+// номер, остальные кодеки offer участвуют в выборе как обычно
+type PayloadTypeWarning struct {
+	Format  string // Формат из m= строки, например "96"
+	Issue   PayloadTypeIssue
+	Rtpmaps []string // Значения rtpmap номера (кодировка/частота[/каналы])
+}
+
+// String возвращает описание предупреждения
+func (w PayloadTypeWarning) String() string {
+	if len(w.Rtpmaps) == 0 {
+		return fmt.Sprintf("payload type %s: %s", w.Format, w.Issue)
+	}
+	return fmt.Sprintf("payload type %s: %s (%s)", w.Format, w.Issue, strings.Join(w.Rtpmaps, ", "))
+}
+
+// resolvePayloadTypes проверяет номера payload type медиа описания offer и
+// возвращает копию без повторов и конфликтующих номеров: из списка форматов
+// и атрибутов rtpmap/fmtp удаляются номера с разными rtpmap и неизвестные
+// форматы, одинаковые повторы rtpmap схлопываются
+func resolvePayloadTypes(mediaDesc *sdp.MediaDescription) (*sdp.MediaDescription, []PayloadTypeWarning) {
+	var warnings []PayloadTypeWarning
+
+	// Значения rtpmap по номеру в порядке появления
+	rtpmaps := make(map[string][]string)
+	for _, attr := range mediaDesc.Attributes {
+		if attr.Key != "rtpmap" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(attr.Value), " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		encoding := strings.TrimSpace(parts[1])
+		duplicate := false
+		for _, known := range rtpmaps[parts[0]] {
+			duplicate = duplicate || strings.EqualFold(known, encoding)
+		}
+		if !duplicate {
+			rtpmaps[parts[0]] = append(rtpmaps[parts[0]], encoding)
+		}
+	}
+
+	rejected := make(map[string]bool)
+	seen := make(map[string]bool)
+	formats := make([]string, 0, len(mediaDesc.MediaName.Formats))
+	for _, format := range mediaDesc.MediaName.Formats {
+		if seen[format] {
+			warnings = append(warnings, PayloadTypeWarning{Format: format, Issue: PayloadTypeDuplicate})
+			continue
+		}
+		seen[format] = true
+
+		pt, err := strconv.ParseUint(format, 10, 7)
+		maps := rtpmaps[format]
+		switch {
+		case err != nil || (isDynamicPayloadType(uint8(pt)) && len(maps) == 0):
+			warnings = append(warnings, PayloadTypeWarning{Format: format, Issue: PayloadTypeUnknown})
+			rejected[format] = true
+			continue
+		case len(maps) > 1:
+			warnings = append(warnings, PayloadTypeWarning{Format: format, Issue: PayloadTypeRtpmapConflict, Rtpmaps: maps})
+			rejected[format] = true
+			continue
+		case len(maps) == 1 && isStaticRemapped(rtp.PayloadType(pt), maps[0]):
+			warnings = append(warnings, PayloadTypeWarning{Format: format, Issue: PayloadTypeStaticRemapped, Rtpmaps: maps})
+		}
+		formats = append(formats, format)
+	}
+
+	if len(warnings) == 0 && !hasRepeatedRtpmap(mediaDesc) {
+		return mediaDesc, nil
+	}
+
+	resolved := *mediaDesc
+	resolved.MediaName.Formats = formats
+	resolved.Attributes = make([]sdp.Attribute, 0, len(mediaDesc.Attributes))
+	kept := make(map[string]bool)
+	for _, attr := range mediaDesc.Attributes {
+		if attr.Key == "rtpmap" || attr.Key == "fmtp" {
+			format, _, _ := strings.Cut(strings.TrimSpace(attr.Value), " ")
+			if rejected[format] {
+				continue
+			}
+			if attr.Key == "rtpmap" {
+				if kept[format] {
+					continue
+				}
+				kept[format] = true
+			}
+		}
+		resolved.Attributes = append(resolved.Attributes, attr)
+	}
+	return &resolved, warnings
+}
+
+// hasRepeatedRtpmap проверяет, встречается ли rtpmap одного номера несколько раз
+func hasRepeatedRtpmap(mediaDesc *sdp.MediaDescription) bool {
+	seen := make(map[string]bool)
+	for _, attr := range mediaDesc.Attributes {
+		if attr.Key != "rtpmap" {
+			continue
+		}
+		format, _, _ := strings.Cut(strings.TrimSpace(attr.Value), " ")
+		if seen[format] {
+			return true
+		}
+		seen[format] = true
+	}
+	return false
+}
+
+// isStaticRemapped проверяет, назначен ли статический payload type в rtpmap
+// кодеку с другим именем
+func isStaticRemapped(pt rtp.PayloadType, encoding string) bool {
+	if isDynamicPayloadType(uint8(pt)) {
+		return false
+	}
+	name := getCodecName(pt)
+	if strings.HasPrefix(name, "codec") {
+		return false
+	}
+	encodingName, _, _ := strings.Cut(encoding, "/")
+	return !strings.EqualFold(encodingName, name)
+}
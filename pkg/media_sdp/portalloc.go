@@ -0,0 +1,157 @@
+package media_sdp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/arzzra/soft_phone/pkg/random"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// DefaultMaxPortAttempts - количество попыток bind в одном диапазоне портов по умолчанию
+const DefaultMaxPortAttempts = 16
+
+// PortRange - диапазон локальных портов для RTP (включительно)
+type PortRange struct {
+	Min int
+	Max int
+}
+
+// String возвращает диапазон в виде "Min-Max"
+func (r PortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// validate проверяет границы диапазона. RTP использует четный порт,
+// поэтому в диапазоне должен быть хотя бы один четный порт
+func (r PortRange) validate() error {
+	if r.Min <= 0 || r.Max > 65535 || r.Min > r.Max {
+		return fmt.Errorf("некорректный диапазон портов %s", r)
+	}
+	if r.Min == r.Max && r.Min%2 != 0 {
+		return fmt.Errorf("диапазон портов %s не содержит четного порта", r)
+	}
+	return nil
+}
+
+// PortAttempt - результат неудачной попытки занять порт
+type PortAttempt struct {
+	Port int
+	Err  error
+}
+
+// PortAllocationError возвращается, если ни один порт из диапазонов не удалось занять.
+// Attempts содержит ошибку ОС для каждого опробованного порта
+type PortAllocationError struct {
+	Ranges   []PortRange
+	Attempts []PortAttempt
+}
+
+// Error реализует интерфейс error
+func (e *PortAllocationError) Error() string {
+	ranges := make([]string, len(e.Ranges))
+	for i, r := range e.Ranges {
+		ranges[i] = r.String()
+	}
+	attempts := make([]string, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		attempts[i] = fmt.Sprintf("%d: %v", attempt.Port, osError(attempt.Err))
+	}
+	return fmt.Sprintf("не удалось занять медиа порт в диапазонах %s после %d попыток: %s",
+		strings.Join(ranges, ", "), len(e.Attempts), strings.Join(attempts, "; "))
+}
+
+// Unwrap возвращает ошибки попыток для поддержки errors.Is (например, syscall.EADDRINUSE)
+func (e *PortAllocationError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		errs[i] = attempt.Err
+	}
+	return errs
+}
+
+// osError возвращает ошибку ОС без оберток транспорта
+func osError(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Err != nil {
+		return opErr.Err
+	}
+	return err
+}
+
+// isBindError проверяет, что транспорт не создан из-за отказа ОС в bind.
+// Остальные ошибки (некорректный адрес, DTLS конфигурация) повтором не исправить
+func isBindError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "listen"
+}
+
+// portCandidates возвращает порядок перебора четных портов диапазона.
+// Перебор начинается со случайного порта и удаляется от него экспоненциально
+// (0, 2, 4, 8, 16, ...), чтобы быстрее выйти из занятой области,
+// затем оставшиеся порты перебираются подряд до limit попыток
+func portCandidates(r PortRange, limit int, source io.Reader) []int {
+	first := r.Min + r.Min%2
+	count := (r.Max-first)/2 + 1
+	if limit > count {
+		limit = count
+	}
+
+	start := random.IntN(source, count)
+	seen := make(map[int]bool, limit)
+	candidates := make([]int, 0, limit)
+	add := func(index int) {
+		index %= count
+		if !seen[index] && len(candidates) < limit {
+			seen[index] = true
+			candidates = append(candidates, first+2*index)
+		}
+	}
+
+	add(start)
+	for step := 1; step < count; step *= 2 {
+		add(start + step)
+	}
+	for i := 1; len(candidates) < limit; i++ {
+		add(start + i)
+	}
+	return candidates
+}
+
+// allocateTransportPair создает пару транспортов на порту из Transport.PortRange,
+// при отказе ОС переходя к следующим портам и к AlternatePortRanges
+func allocateTransportPair(config TransportConfig) (*rtp.TransportPair, error) {
+	host, _, err := net.SplitHostPort(config.LocalAddr)
+	if err != nil {
+		return nil, NewSDPError(ErrorCodeInvalidConfig, "некорректный локальный адрес: %s", config.LocalAddr)
+	}
+
+	limit := config.MaxPortAttempts
+	if limit <= 0 {
+		limit = DefaultMaxPortAttempts
+	}
+
+	ranges := append([]PortRange{*config.PortRange}, config.AlternatePortRanges...)
+	allocErr := &PortAllocationError{Ranges: ranges}
+	for _, portRange := range ranges {
+		for _, port := range portCandidates(portRange, limit, config.randomSource) {
+			attempt := config
+			attempt.LocalAddr = net.JoinHostPort(host, strconv.Itoa(port))
+
+			pair, err := createTransportPair(attempt)
+			if err == nil {
+				return pair, nil
+			}
+			if !isBindError(err) {
+				return nil, err
+			}
+			allocErr.Attempts = append(allocErr.Attempts, PortAttempt{Port: port, Err: err})
+		}
+	}
+
+	return nil, allocErr
+}
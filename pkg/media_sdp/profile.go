@@ -0,0 +1,218 @@
+package media_sdp
+
+import (
+	"net"
+	"net/netip"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DTMFMode определяет передачу DTMF в медиа профиле
+type DTMFMode int
+
+const (
+	DTMFModeDefault DTMFMode = iota // Настройка BuilderConfig не меняется
+	DTMFModeRFC4733                 // telephone-event (RFC 4733)
+	DTMFModeNone                    // DTMF не предлагается
+)
+
+// MediaProfile описывает медиа параметры вызовов к группе адресатов
+type MediaProfile struct {
+	Name string
+
+	// Codecs - кодеки offer в порядке предпочтения, первый становится
+	// основным кодеком. Пусто - кодеки BuilderConfig не меняются
+	Codecs []CodecInfo
+
+	// Ptime - время пакетизации, 0 - Ptime основного кодека или BuilderConfig
+	Ptime time.Duration
+
+	DTMFMode        DTMFMode
+	DTMFPayloadType uint8 // 0 - payload type из BuilderConfig
+
+	// RequireSRTP требует шифрование медиа (DTLS-SRTP транспорт)
+	RequireSRTP bool
+
+	// Quirks - особенности SDP транка, nil - BuilderConfig.Quirks не меняется
+	Quirks *SDPQuirks
+}
+
+// ProfileSelector выбирает медиа профиль вызова по tenant и адресату.
+//
+// Правила проверяются в порядке регистрации, выбирается первое
+// подходящее. Шаблон адресата - подсеть ("10.0.0.0/8"), IP адрес или
+// glob шаблон path.Match ("*.example.com", "7495*"), сравниваемый с
+// адресатом без порта. Пустой tenant или шаблон подходит для любого вызова.
+// Методы безопасны для одновременного вызова.
+type ProfileSelector struct {
+	mutex    sync.RWMutex
+	rules    []profileRule
+	fallback *MediaProfile
+}
+
+// profileRule связывает условие выбора с профилем
+type profileRule struct {
+	tenant  string
+	pattern string
+	prefix  netip.Prefix // Подсеть, если шаблон задан адресом или CIDR
+	profile MediaProfile
+}
+
+// NewProfileSelector создает пустой селектор профилей
+func NewProfileSelector() *ProfileSelector {
+	return &ProfileSelector{}
+}
+
+// Register добавляет профиль для вызовов tenant к адресатам по шаблону
+func (s *ProfileSelector) Register(tenant, destinationPattern string, profile MediaProfile) error {
+	if err := profile.validate(); err != nil {
+		return err
+	}
+
+	rule := profileRule{
+		tenant:  tenant,
+		pattern: strings.ToLower(destinationPattern),
+		profile: profile,
+	}
+	if prefix, err := netip.ParsePrefix(destinationPattern); err == nil {
+		rule.prefix = prefix.Masked()
+	} else if addr, err := netip.ParseAddr(destinationPattern); err == nil {
+		rule.prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+	} else if _, err := path.Match(rule.pattern, ""); err != nil {
+		return WrapSDPError(ErrorCodeInvalidConfig, "", err,
+			"Некорректный шаблон адресата %q профиля %s", destinationPattern, profile.Name)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.rules = append(s.rules, rule)
+	return nil
+}
+
+// SetDefault задает профиль для вызовов без подходящего правила
+func (s *ProfileSelector) SetDefault(profile MediaProfile) error {
+	if err := profile.validate(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fallback = &profile
+	return nil
+}
+
+// Select возвращает профиль вызова. false - нет ни подходящего правила,
+// ни профиля по умолчанию
+func (s *ProfileSelector) Select(tenant, destination string) (MediaProfile, bool) {
+	host := destination
+	if h, _, err := net.SplitHostPort(destination); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	addr, addrErr := netip.ParseAddr(host)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, rule := range s.rules {
+		if rule.tenant != "" && rule.tenant != tenant {
+			continue
+		}
+		switch {
+		case rule.pattern == "":
+		case rule.prefix.IsValid():
+			if addrErr != nil || !rule.prefix.Contains(addr.Unmap()) {
+				continue
+			}
+		default:
+			if matched, _ := path.Match(rule.pattern, host); !matched {
+				continue
+			}
+		}
+		return rule.profile, true
+	}
+
+	if s.fallback != nil {
+		return *s.fallback, true
+	}
+	return MediaProfile{}, false
+}
+
+// NewBuilder создает SDPMediaBuilder для вызова с профилем, выбранным по
+// tenant и адресату. Без подходящего профиля используется config как есть
+func (s *ProfileSelector) NewBuilder(config BuilderConfig, tenant, destination string) (SDPMediaBuilder, error) {
+	if profile, ok := s.Select(tenant, destination); ok {
+		if err := profile.Apply(&config); err != nil {
+			return nil, err
+		}
+	}
+	if config.Destination == "" {
+		config.Destination = destination
+	}
+	return NewSDPMediaBuilder(config)
+}
+
+// Apply переносит параметры профиля в конфигурацию Builder
+func (p MediaProfile) Apply(config *BuilderConfig) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	if len(p.Codecs) > 0 {
+		primary := p.Codecs[0]
+		config.PayloadType = primary.PayloadType
+		config.CodecName = primary.Name
+		config.ClockRate = primary.ClockRate
+		config.Channels = primary.Channels
+		config.Fmtp = primary.Fmtp
+		if primary.Ptime > 0 {
+			config.Ptime = primary.Ptime
+		}
+		config.AlternativeCodecs = append([]CodecInfo(nil), p.Codecs[1:]...)
+	}
+	if p.Ptime > 0 {
+		config.Ptime = p.Ptime
+	}
+
+	switch p.DTMFMode {
+	case DTMFModeRFC4733:
+		config.DTMFEnabled = true
+	case DTMFModeNone:
+		config.DTMFEnabled = false
+	}
+	if p.DTMFPayloadType != 0 {
+		config.DTMFPayloadType = p.DTMFPayloadType
+	}
+
+	if p.Quirks != nil {
+		config.Quirks = p.Quirks
+	}
+
+	if p.RequireSRTP {
+		switch config.Transport.Type {
+		case TransportTypeDTLS:
+		case TransportTypeUDP:
+			config.Transport.Type = TransportTypeDTLS
+		default:
+			return NewSDPError(ErrorCodeInvalidConfig,
+				"Профиль %s требует SRTP, транспорт %d не поддерживает DTLS", p.Name, config.Transport.Type)
+		}
+	}
+	return nil
+}
+
+// validate проверяет кодеки профиля
+func (p MediaProfile) validate() error {
+	for _, codec := range p.Codecs {
+		if codec.Name == "" || codec.ClockRate == 0 {
+			return NewSDPError(ErrorCodeInvalidConfig,
+				"Для кодека %d профиля %s должны быть указаны Name и ClockRate", codec.PayloadType, p.Name)
+		}
+	}
+	if p.Quirks != nil {
+		return p.Quirks.Validate()
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package media_sdp
+
+import (
+	"strings"
+
+	"github.com/pion/sdp/v3"
+)
+
+// SDPQuirks описывает особенности SDP, которых требует оператор или транк:
+// отсутствие a=ptime, обязательный a=silenceSupp:off, определенное имя
+// сессии. Особенности применяются к offer и answer при их формировании,
+// поэтому для нового оператора достаточно описать их данными (например,
+// загрузить из JSON конфигурации), не меняя Builder и Handler.
+type SDPQuirks struct {
+	Name string `json:"name"`
+
+	// SessionName заменяет s= строку, пусто - BuilderConfig/HandlerConfig.SessionName
+	SessionName string `json:"session_name,omitempty"`
+	// OriginUsername заменяет username o= строки, пусто - "-"
+	OriginUsername string `json:"origin_username,omitempty"`
+
+	// OmitPtime удаляет a=ptime и a=maxptime из аудио m= строки
+	OmitPtime bool `json:"omit_ptime,omitempty"`
+	// RemoveAttributes - имена атрибутов, удаляемых из сессии и аудио m= строки
+	RemoveAttributes []string `json:"remove_attributes,omitempty"`
+
+	// SessionAttributes и MediaAttributes - атрибуты "имя" или "имя:значение",
+	// добавляемые на уровне сессии и аудио m= строки. Атрибут с тем же
+	// именем заменяется
+	SessionAttributes []string `json:"session_attributes,omitempty"`
+	MediaAttributes   []string `json:"media_attributes,omitempty"`
+}
+
+// Validate проверяет имена и значения атрибутов
+func (q *SDPQuirks) Validate() error {
+	if strings.ContainsAny(q.SessionName+q.OriginUsername, "\r\n") {
+		return NewSDPError(ErrorCodeInvalidConfig, "Профиль SDP %s: перевод строки в s= или o=", q.Name)
+	}
+	if strings.ContainsAny(q.OriginUsername, " \t") {
+		return NewSDPError(ErrorCodeInvalidConfig, "Профиль SDP %s: пробел в username o= строки", q.Name)
+	}
+	for _, name := range q.RemoveAttributes {
+		if !validAttributeName(name) {
+			return NewSDPError(ErrorCodeInvalidConfig, "Профиль SDP %s: некорректное имя атрибута %q", q.Name, name)
+		}
+	}
+	for _, value := range append(append([]string(nil), q.SessionAttributes...), q.MediaAttributes...) {
+		name, _, _ := strings.Cut(value, ":")
+		if !validAttributeName(name) || strings.ContainsAny(value, "\r\n") {
+			return NewSDPError(ErrorCodeInvalidConfig, "Профиль SDP %s: некорректный атрибут %q", q.Name, value)
+		}
+	}
+	return nil
+}
+
+// validAttributeName проверяет имя атрибута (token, RFC 4566 Section 9)
+func validAttributeName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n:")
+}
+
+// apply применяет особенности к сформированному offer или answer.
+// nil - SDP не меняется
+func (q *SDPQuirks) apply(desc *sdp.SessionDescription) {
+	if q == nil {
+		return
+	}
+
+	if q.SessionName != "" {
+		desc.SessionName = sdp.SessionName(q.SessionName)
+	}
+	if q.OriginUsername != "" {
+		desc.Origin.Username = q.OriginUsername
+	}
+
+	remove := make(map[string]bool, len(q.RemoveAttributes)+2)
+	for _, name := range q.RemoveAttributes {
+		remove[name] = true
+	}
+	desc.Attributes = replaceAttributes(removeAttributes(desc.Attributes, remove), q.SessionAttributes)
+
+	if q.OmitPtime {
+		remove["ptime"] = true
+		remove["maxptime"] = true
+	}
+	for _, mediaDesc := range desc.MediaDescriptions {
+		// Отклоненные m= строки (порт 0) не меняются
+		if mediaDesc.MediaName.Media != "audio" || mediaDesc.MediaName.Port.Value == 0 {
+			continue
+		}
+		mediaDesc.Attributes = replaceAttributes(removeAttributes(mediaDesc.Attributes, remove), q.MediaAttributes)
+	}
+}
+
+// removeAttributes удаляет атрибуты с именами из remove
+func removeAttributes(attributes []sdp.Attribute, remove map[string]bool) []sdp.Attribute {
+	if len(remove) == 0 {
+		return attributes
+	}
+	result := attributes[:0]
+	for _, attr := range attributes {
+		if !remove[attr.Key] {
+			result = append(result, attr)
+		}
+	}
+	return result
+}
+
+// replaceAttributes добавляет атрибуты "имя[:значение]", заменяя атрибуты с
+// тем же именем
+func replaceAttributes(attributes []sdp.Attribute, values []string) []sdp.Attribute {
+	for _, value := range values {
+		name, attrValue, _ := strings.Cut(value, ":")
+		attributes = removeAttributes(attributes, map[string]bool{name: true})
+		attributes = append(attributes, sdp.Attribute{Key: name, Value: attrValue})
+	}
+	return attributes
+}
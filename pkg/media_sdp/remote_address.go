@@ -0,0 +1,28 @@
+package media_sdp
+
+// RemoteMediaAddressChange - смена удаленного медиа адреса повторным
+// согласованием (re-INVITE), например при переносе медиа на другой узел SBC.
+// Адрес меняется на работающих сокетах: SSRC, sequence number и timestamp
+// исходящего потока сохраняются
+type RemoteMediaAddressChange struct {
+	SessionID string
+
+	PreviousRTPAddr string
+	RTPAddr         string
+
+	// RTCP адреса из a=rtcp или RTP порт + 1
+	PreviousRTCPAddr string
+	RTCPAddr         string
+}
+
+// notifyRemoteMediaAddressChanged вызывает callback, если к транспорту уже
+// был применен другой адрес. Первое согласование адреса не сообщается
+func notifyRemoteMediaAddressChanged(callback func(RemoteMediaAddressChange), change RemoteMediaAddressChange) {
+	if callback == nil || change.PreviousRTPAddr == "" {
+		return
+	}
+	if change.PreviousRTPAddr == change.RTPAddr && change.PreviousRTCPAddr == change.RTCPAddr {
+		return
+	}
+	callback(change)
+}
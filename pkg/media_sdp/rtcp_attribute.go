@@ -0,0 +1,79 @@
+package media_sdp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/arzzra/soft_phone/pkg/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// rtcpAttribute - порт и адрес RTCP, отличные от RTP порт + 1 (RFC 3605):
+// a=rtcp:<port> [<nettype> <addrtype> <connection-address>]
+const rtcpAttribute = "rtcp"
+
+// parseRTCPAttribute разбирает значение a=rtcp. Без адреса RTCP использует
+// хост rtpAddr
+func parseRTCPAttribute(value, rtpAddr string) (string, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 1 && len(fields) != 4 {
+		return "", fmt.Errorf("некорректный атрибут rtcp: %q", value)
+	}
+	port, err := strconv.Atoi(fields[0])
+	if err != nil || port <= 0 || port > 65535 {
+		return "", fmt.Errorf("некорректный порт в атрибуте rtcp: %q", value)
+	}
+
+	if len(fields) == 4 {
+		return ParseMediaAddress(strings.Join(fields[1:], " "), port)
+	}
+	host, _, err := net.SplitHostPort(rtpAddr)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// remoteRTCPAddress возвращает адрес RTCP удаленной стороны: из a=rtcp
+// m= строки, а без атрибута - RTP порт + 1 (RFC 3550 Section 11)
+func remoteRTCPAddress(mediaDesc *sdp.MediaDescription, rtpAddr string) (string, error) {
+	if value, ok := mediaDesc.Attribute(rtcpAttribute); ok {
+		return parseRTCPAttribute(value, rtpAddr)
+	}
+	return adjustPortInAddress(rtpAddr, 1)
+}
+
+// localRTCPAttribute формирует a=rtcp, если наш RTCP порт не соседний с RTP:
+// при мультиплексировании RTCP (порт RTP) и при отдельном RTCP сокете на
+// произвольном порту. rtpHost - адрес c= строки
+func localRTCPAttribute(pair *rtp.TransportPair, rtcpEnabled bool, rtpHost string, rtpPort int) (sdp.Attribute, bool) {
+	if pair == nil || !rtcpEnabled {
+		return sdp.Attribute{}, false
+	}
+
+	var rtcpAddr net.Addr
+	switch {
+	case pair.RTCP != nil:
+		rtcpAddr = pair.RTCP.LocalAddr()
+	case pair.MuxMode != rtp.RTCPMuxNone:
+		return sdp.NewAttribute(rtcpAttribute, strconv.Itoa(rtpPort)), true
+	default:
+		return sdp.Attribute{}, false
+	}
+
+	udpAddr, ok := rtcpAddr.(*net.UDPAddr)
+	if !ok || udpAddr.Port == rtpPort+1 {
+		return sdp.Attribute{}, false
+	}
+	value := strconv.Itoa(udpAddr.Port)
+	if !udpAddr.IP.IsUnspecified() && udpAddr.IP.String() != rtpHost {
+		addrType := "IP4"
+		if udpAddr.IP.To4() == nil {
+			addrType = "IP6"
+		}
+		value += " IN " + addrType + " " + udpAddr.IP.String()
+	}
+	return sdp.NewAttribute(rtcpAttribute, value), true
+}
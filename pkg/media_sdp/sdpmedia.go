@@ -14,7 +14,10 @@ type SDPMediaBuilder interface {
 	// CreateOffer создает SDP offer на основе конфигурации
 	CreateOffer() (*sdp.SessionDescription, error)
 
-	// ProcessAnswer обрабатывает SDP answer для установки удаленного адреса
+	// ProcessAnswer обрабатывает SDP answer для установки удаленного адреса.
+	// Повторный вызов с тем же answer (повтор 200 OK) ничего не делает.
+	// Answer с той же версией o=, но другим содержимым, возвращает ошибку
+	// с кодом ErrorCodeAnswerConflict.
 	ProcessAnswer(answer *sdp.SessionDescription) error
 
 	// GetMediaSession возвращает созданную медиа сессию
@@ -23,10 +26,45 @@ type SDPMediaBuilder interface {
 	// GetRTPSession возвращает созданную RTP сессию
 	GetRTPSession() rtp.SessionRTP
 
+	// IsOnHold возвращает true, если удаленная сторона поставила вызов на удержание
+	// через нулевой адрес соединения (c=0.0.0.0)
+	IsOnHold() bool
+
+	// GetRemoteFormatParameters возвращает параметры fmtp удаленной стороны
+	// для payload type, например annexb для G.729 или mode-set для AMR
+	GetRemoteFormatParameters(pt uint8) (FormatParameters, bool)
+
+	// AcceptedConfiguration возвращает потенциальную конфигурацию (RFC 5939),
+	// выбранную в answer (a=acfg)
+	AcceptedConfiguration() (AcceptedConfiguration, bool)
+
+	// OneWayAudioReport возвращает диагностику медиа пути для разбора
+	// одностороннего звука (адреса, источник RTP, время пакетов, RTCP)
+	OneWayAudioReport() OneWayAudioReport
+
+	// GetNegotiatedMedia возвращает параметры медиа, согласованные с answer:
+	// кодек, ptime, направление, адреса RTP/RTCP, DTMF и шифрование.
+	// false - answer еще не обработан
+	GetNegotiatedMedia() (NegotiatedMedia, bool)
+
 	// Start запускает все созданные сессии
 	Start() error
 
-	// Stop останавливает все сессии и освобождает ресурсы
+	// Close завершает медиа вызов и освобождает ресурсы. Шаги выполняются
+	// в порядке ShutdownStep: отчеты, отключение RTP сессии от медиа
+	// сессии, остановка медиа сессии, остановка RTP сессии, закрытие
+	// транспортов. Ошибка шага не прерывает следующие; ошибки всех шагов
+	// возвращаются в ShutdownError, обернутой в SDPError с кодом
+	// ErrorCodeSessionStop. Повторные вызовы возвращают результат первого.
+	//
+	// Close вызывается после завершения SIP диалога (BYE, CANCEL, отказ),
+	// когда удаленная сторона больше не ждет медиа
+	Close() error
+
+	// Stop - то же, что Close.
+	//
+	// Deprecated: используйте Close. Stop будет удален вместе с переходом
+	// приложений на пакет pkg/api/v1
 	Stop() error
 }
 
@@ -44,10 +82,45 @@ type SDPMediaHandler interface {
 	// GetRTPSession возвращает созданную RTP сессию
 	GetRTPSession() rtp.SessionRTP
 
+	// IsOnHold возвращает true, если удаленная сторона поставила вызов на удержание
+	// через нулевой адрес соединения (c=0.0.0.0)
+	IsOnHold() bool
+
+	// GetRemoteFormatParameters возвращает параметры fmtp удаленной стороны
+	// для payload type, например annexb для G.729 или mode-set для AMR
+	GetRemoteFormatParameters(pt uint8) (FormatParameters, bool)
+
+	// AcceptedConfiguration возвращает потенциальную конфигурацию (RFC 5939),
+	// выбранную в answer (a=acfg)
+	AcceptedConfiguration() (AcceptedConfiguration, bool)
+
+	// OneWayAudioReport возвращает диагностику медиа пути для разбора
+	// одностороннего звука (адреса, источник RTP, время пакетов, RTCP)
+	OneWayAudioReport() OneWayAudioReport
+
+	// GetNegotiatedMedia возвращает параметры медиа, выбранные для answer:
+	// кодек, ptime, направление, адреса RTP/RTCP, DTMF и шифрование.
+	// false - offer еще не обработан
+	GetNegotiatedMedia() (NegotiatedMedia, bool)
+
 	// Start запускает все созданные сессии
 	Start() error
 
-	// Stop останавливает все сессии и освобождает ресурсы
+	// Close завершает медиа вызов и освобождает ресурсы. Шаги выполняются
+	// в порядке ShutdownStep: отчеты, отключение RTP сессии от медиа
+	// сессии, остановка медиа сессии, остановка RTP сессии, закрытие
+	// транспортов. Ошибка шага не прерывает следующие; ошибки всех шагов
+	// возвращаются в ShutdownError, обернутой в SDPError с кодом
+	// ErrorCodeSessionStop. Повторные вызовы возвращают результат первого.
+	//
+	// Close вызывается после завершения SIP диалога (BYE, CANCEL, отказ),
+	// когда удаленная сторона больше не ждет медиа
+	Close() error
+
+	// Stop - то же, что Close.
+	//
+	// Deprecated: используйте Close. Stop будет удален вместе с переходом
+	// приложений на пакет pkg/api/v1
 	Stop() error
 }
 
@@ -65,6 +138,7 @@ const (
 	ErrorCodeInvalidDirection
 	ErrorCodeSessionStart
 	ErrorCodeSessionStop
+	ErrorCodeAnswerConflict
 )
 
 // SDPError представляет ошибку в SDP операциях
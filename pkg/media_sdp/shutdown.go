@@ -0,0 +1,155 @@
+package media_sdp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// ShutdownStep - шаг завершения медиа вызова. Close выполняет шаги в порядке
+// констант; ошибка шага не прерывает следующие
+type ShutdownStep int
+
+const (
+	// ShutdownReport - отчет OnOneWayAudio и запись качества в CodecPolicy,
+	// пока транспорты и статистика еще доступны
+	ShutdownReport ShutdownStep = iota
+	// ShutdownDetachRTP - RTP сессия отключается от медиа сессии, после чего
+	// медиа сессия больше не отправляет в нее пакеты
+	ShutdownDetachRTP
+	// ShutdownStopMedia - остановка медиа сессии и ее горутин
+	ShutdownStopMedia
+	// ShutdownStopRTP - остановка RTP/RTCP сессии
+	ShutdownStopRTP
+	// ShutdownCloseTransport - закрытие RTP/RTCP сокетов и освобождение портов
+	ShutdownCloseTransport
+)
+
+// String возвращает название шага
+func (s ShutdownStep) String() string {
+	switch s {
+	case ShutdownReport:
+		return "report"
+	case ShutdownDetachRTP:
+		return "detach-rtp"
+	case ShutdownStopMedia:
+		return "stop-media"
+	case ShutdownStopRTP:
+		return "stop-rtp"
+	case ShutdownCloseTransport:
+		return "close-transport"
+	default:
+		return "unknown"
+	}
+}
+
+// ShutdownStepError - ошибка одного шага завершения
+type ShutdownStepError struct {
+	Step ShutdownStep
+	Err  error
+}
+
+// Error реализует интерфейс error
+func (e *ShutdownStepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+// Unwrap возвращает ошибку шага
+func (e *ShutdownStepError) Unwrap() error {
+	return e.Err
+}
+
+// ShutdownError содержит ошибки всех неудачных шагов завершения в порядке
+// их выполнения. Close возвращает ее обернутой в SDPError с кодом
+// ErrorCodeSessionStop:
+//
+//	var shutdownErr *media_sdp.ShutdownError
+//	if errors.As(builder.Close(), &shutdownErr) {
+//	    if err := shutdownErr.Failed(media_sdp.ShutdownCloseTransport); err != nil {
+//	        log.Printf("порты не освобождены: %v", err)
+//	    }
+//	}
+type ShutdownError struct {
+	SessionID string
+	Steps     []*ShutdownStepError
+}
+
+// Error реализует интерфейс error
+func (e *ShutdownError) Error() string {
+	steps := make([]string, len(e.Steps))
+	for i, step := range e.Steps {
+		steps[i] = step.Error()
+	}
+	return fmt.Sprintf("ошибка завершения медиа сессии %s: %s", e.SessionID, strings.Join(steps, "; "))
+}
+
+// Unwrap возвращает ошибки шагов для errors.Is/errors.As
+func (e *ShutdownError) Unwrap() []error {
+	errs := make([]error, len(e.Steps))
+	for i, step := range e.Steps {
+		errs[i] = step
+	}
+	return errs
+}
+
+// Failed возвращает ошибку шага. nil - шаг выполнен успешно
+func (e *ShutdownError) Failed(step ShutdownStep) error {
+	for _, stepErr := range e.Steps {
+		if stepErr.Step == step {
+			return stepErr.Err
+		}
+	}
+	return nil
+}
+
+// mediaTeardown - ресурсы медиа вызова, которые освобождает Close
+type mediaTeardown struct {
+	sessionID     string
+	report        func() // nil - вызов не запускался, отчет не собирается
+	mediaSession  *media.MediaSession
+	rtpSession    rtp.SessionRTP
+	transportPair *rtp.TransportPair
+}
+
+// run выполняет шаги завершения по порядку и собирает их ошибки
+func (t mediaTeardown) run() error {
+	result := &ShutdownError{SessionID: t.sessionID}
+	fail := func(step ShutdownStep, err error) {
+		if err != nil {
+			result.Steps = append(result.Steps, &ShutdownStepError{Step: step, Err: err})
+		}
+	}
+
+	if t.report != nil {
+		t.report()
+	}
+
+	if t.mediaSession != nil && t.rtpSession != nil {
+		err := t.mediaSession.RemoveRTPSession("primary")
+		var mediaErr *media.MediaError
+		if media.AsMediaError(err, &mediaErr) && mediaErr.Code == media.ErrorCodeRTPSessionNotFound {
+			// Сессия уже отключена (повторное согласование или dry run)
+			err = nil
+		}
+		fail(ShutdownDetachRTP, err)
+	}
+
+	if t.mediaSession != nil {
+		fail(ShutdownStopMedia, t.mediaSession.Stop())
+	}
+
+	if t.rtpSession != nil {
+		fail(ShutdownStopRTP, t.rtpSession.Stop())
+	}
+
+	if t.transportPair != nil {
+		fail(ShutdownCloseTransport, t.transportPair.Close())
+	}
+
+	if len(result.Steps) == 0 {
+		return nil
+	}
+	return result
+}
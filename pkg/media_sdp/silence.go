@@ -0,0 +1,67 @@
+package media_sdp
+
+import (
+	"strings"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/pion/sdp/v3"
+)
+
+// silenceSuppAttribute - атрибут подавления пауз (RFC 3108 Section 5.6):
+// a=silenceSupp:<on|off> <silenceTimer> <suppPref> <sidUse> <fxnslevel>
+const silenceSuppAttribute = "silenceSupp"
+
+// formatSilenceSupp формирует значение a=silenceSupp. Остальные параметры
+// не задаются ("-") и остаются на усмотрение удаленной стороны
+func formatSilenceSupp(mode media.SilenceSuppression) string {
+	return mode.String() + " - - - -"
+}
+
+// parseSilenceSupp разбирает значение a=silenceSupp. false - значение не
+// распознано
+func parseSilenceSupp(value string) (media.SilenceSuppression, bool) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return media.SilenceSuppressionDefault, false
+	}
+	switch strings.ToLower(fields[0]) {
+	case "on":
+		return media.SilenceSuppressionOn, true
+	case "off":
+		return media.SilenceSuppressionOff, true
+	}
+	return media.SilenceSuppressionDefault, false
+}
+
+// resolveSilenceSuppression определяет подавление пауз для m= строки:
+// атрибут уровня медиа имеет приоритет над уровнем сессии
+func resolveSilenceSuppression(session *sdp.SessionDescription, mediaDesc *sdp.MediaDescription) media.SilenceSuppression {
+	if mediaDesc != nil {
+		if value, ok := mediaDesc.Attribute(silenceSuppAttribute); ok {
+			if mode, ok := parseSilenceSupp(value); ok {
+				return mode
+			}
+		}
+	}
+	if session != nil {
+		if value, ok := session.Attribute(silenceSuppAttribute); ok {
+			if mode, ok := parseSilenceSupp(value); ok {
+				return mode
+			}
+		}
+	}
+	return media.SilenceSuppressionDefault
+}
+
+// negotiateSilenceSuppression вычисляет подавление пауз вызова: отказ любой
+// стороны отключает VAD/CNG, включение требует согласия удаленной стороны
+func negotiateSilenceSuppression(local, remote media.SilenceSuppression) media.SilenceSuppression {
+	switch {
+	case local == media.SilenceSuppressionOff || remote == media.SilenceSuppressionOff:
+		return media.SilenceSuppressionOff
+	case remote == media.SilenceSuppressionOn:
+		return media.SilenceSuppressionOn
+	default:
+		return media.SilenceSuppressionDefault
+	}
+}
@@ -27,9 +27,10 @@ func CreateTransport(config TransportConfig) (rtp.Transport, error) {
 // createUDPTransport создает UDP транспорт
 func createUDPTransport(config TransportConfig) (rtp.Transport, error) {
 	transportConfig := rtp.TransportConfig{
-		LocalAddr:  config.LocalAddr,
-		RemoteAddr: config.RemoteAddr,
-		BufferSize: config.BufferSize,
+		LocalAddr:     config.LocalAddr,
+		RemoteAddr:    config.RemoteAddr,
+		BufferSize:    config.BufferSize,
+		SocketOptions: config.SocketOptions,
 	}
 
 	if config.BufferSize == 0 {
@@ -56,6 +57,7 @@ func createDTLSTransport(config TransportConfig) (rtp.Transport, error) {
 	config.DTLSConfig.LocalAddr = config.LocalAddr
 	config.DTLSConfig.RemoteAddr = config.RemoteAddr
 	config.DTLSConfig.BufferSize = config.BufferSize
+	config.DTLSConfig.SocketOptions = config.SocketOptions
 
 	if config.DTLSConfig.BufferSize == 0 {
 		config.DTLSConfig.BufferSize = rtp.DefaultBufferSize
@@ -73,9 +75,10 @@ func createDTLSTransport(config TransportConfig) (rtp.Transport, error) {
 // createMultiplexedTransport создает мультиплексированный транспорт
 func createMultiplexedTransport(config TransportConfig) (rtp.Transport, error) {
 	transportConfig := rtp.TransportConfig{
-		LocalAddr:  config.LocalAddr,
-		RemoteAddr: config.RemoteAddr,
-		BufferSize: config.BufferSize,
+		LocalAddr:     config.LocalAddr,
+		RemoteAddr:    config.RemoteAddr,
+		BufferSize:    config.BufferSize,
+		SocketOptions: config.SocketOptions,
 	}
 
 	if config.BufferSize == 0 {
@@ -110,11 +113,14 @@ func CreateRTCPTransport(config TransportConfig) (rtp.RTCPTransport, error) {
 	}
 
 	rtcpConfig := rtp.RTCPTransportConfig{
-		LocalAddr:  rtcpAddr,
-		BufferSize: config.BufferSize,
+		LocalAddr:     rtcpAddr,
+		BufferSize:    config.BufferSize,
+		SocketOptions: config.SocketOptions,
 	}
 
-	if config.RemoteAddr != "" {
+	if config.rtcpRemoteAddr != "" {
+		rtcpConfig.RemoteAddr = config.rtcpRemoteAddr
+	} else if config.RemoteAddr != "" {
 		rtcpRemoteAddr, err := generateRTCPAddress(config.RemoteAddr)
 		if err != nil {
 			return nil, WrapSDPError(ErrorCodeTransportCreation, "", err,
@@ -170,8 +176,21 @@ func generateRTCPAddress(rtpAddr string) (string, error) {
 	return fmt.Sprintf("%s:%d", host, rtcpPort), nil
 }
 
-// CreateTransportPair создает пару RTP/RTCP транспортов
+// CreateTransportPair создает пару RTP/RTCP транспортов.
+// Если задан PortRange, порт выбирается из диапазона с повтором при ошибке bind
 func CreateTransportPair(config TransportConfig) (*rtp.TransportPair, error) {
+	if config.PortRange != nil {
+		return allocateTransportPair(config)
+	}
+	return createTransportPair(config)
+}
+
+// createTransportPair создает пару RTP/RTCP транспортов на LocalAddr
+func createTransportPair(config TransportConfig) (*rtp.TransportPair, error) {
+	if config.dryRun != nil {
+		return createDryRunTransportPair(config)
+	}
+
 	// Создаем RTP транспорт
 	rtpTransport, err := CreateTransport(config)
 	if err != nil {
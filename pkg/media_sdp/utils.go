@@ -1,8 +1,13 @@
 package media_sdp
 
 import (
+	"crypto/sha256"
 	"net"
 	"strconv"
+	"strings"
+
+	"github.com/arzzra/soft_phone/pkg/media"
+	"github.com/pion/sdp/v3"
 )
 
 // adjustPortInAddress увеличивает порт в адресе на указанное количество
@@ -20,3 +25,61 @@ func adjustPortInAddress(addr string, offset int) (string, error) {
 	newPort := port + offset
 	return net.JoinHostPort(host, strconv.Itoa(newPort)), nil
 }
+
+// isHoldConnectionAddress проверяет, является ли адрес соединения признаком
+// удержания вызова в стиле RFC 2543 (c=IN IP4 0.0.0.0). Такой адрес означает,
+// что удаленная сторона не готова принимать медиа.
+func isHoldConnectionAddress(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.IsUnspecified()
+}
+
+// answerDigest возвращает хеш сериализованного SDP для сравнения повторов
+func answerDigest(desc *sdp.SessionDescription) ([sha256.Size]byte, error) {
+	data, err := desc.Marshal()
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+// sameOriginVersion проверяет, что o= описывает ту же версию той же сессии.
+// Любое изменение SDP должно сопровождаться увеличением версии (RFC 3264 Section 8).
+func sameOriginVersion(a, b sdp.Origin) bool {
+	return a.Username == b.Username &&
+		a.SessionID == b.SessionID &&
+		a.SessionVersion == b.SessionVersion &&
+		a.UnicastAddress == b.UnicastAddress
+}
+
+// parseRtpmapClockRates строит таблицу частот RTP clock из атрибутов
+// a=rtpmap:<pt> <encoding>/<clock rate>[/<channels>] медиа описания
+func parseRtpmapClockRates(mediaDesc *sdp.MediaDescription) map[media.PayloadType]uint32 {
+	rates := make(map[media.PayloadType]uint32)
+	for _, attr := range mediaDesc.Attributes {
+		if attr.Key != "rtpmap" {
+			continue
+		}
+
+		parts := strings.SplitN(attr.Value, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		pt, err := strconv.ParseUint(parts[0], 10, 7)
+		if err != nil {
+			continue
+		}
+
+		encoding := strings.Split(parts[1], "/")
+		if len(encoding) < 2 {
+			continue
+		}
+		rate, err := strconv.ParseUint(encoding[1], 10, 32)
+		if err != nil || rate == 0 {
+			continue
+		}
+
+		rates[media.PayloadType(pt)] = uint32(rate)
+	}
+	return rates
+}
@@ -0,0 +1,57 @@
+// Package profiling содержит метки pprof для атрибуции CPU и памяти
+// конкретным вызовам в профилях production.
+//
+// Долгоживущие горутины медиа стека (циклы отправки и приема RTP, jitter buffer,
+// RTCP) помечаются метками session_id и call_id. В профиле их можно отфильтровать:
+//
+//	go tool pprof -tagfocus=call_id=abc123 http://host:6060/debug/pprof/profile
+//
+// Метки наследуются горутинами, запущенными из помеченной горутины, поэтому
+// достаточно пометить горутину, которая запускает сессию.
+package profiling
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+const (
+	// LabelSessionID - метка с идентификатором медиа сессии
+	LabelSessionID = "session_id"
+	// LabelCallID - метка с Call-ID SIP диалога
+	LabelCallID = "call_id"
+)
+
+// WithLabels возвращает контекст с метками session_id и call_id.
+// Пустые значения не добавляются.
+func WithLabels(ctx context.Context, sessionID, callID string) context.Context {
+	var labels []string
+	if sessionID != "" {
+		labels = append(labels, LabelSessionID, sessionID)
+	}
+	if callID != "" {
+		labels = append(labels, LabelCallID, callID)
+	}
+	if len(labels) == 0 {
+		return ctx
+	}
+	return pprof.WithLabels(ctx, pprof.Labels(labels...))
+}
+
+// Apply устанавливает метки из ctx на текущую горутину. Горутины, запущенные
+// после вызова, наследуют метки. Возвращаемая функция снимает метки с текущей
+// горутины; метки, установленные вызывающим кодом ранее, не восстанавливаются.
+func Apply(ctx context.Context) (reset func()) {
+	pprof.SetGoroutineLabels(ctx)
+	return func() {
+		pprof.SetGoroutineLabels(context.Background())
+	}
+}
+
+// Go запускает fn в новой горутине с метками из ctx
+func Go(ctx context.Context, fn func()) {
+	go func() {
+		pprof.SetGoroutineLabels(ctx)
+		fn()
+	}()
+}
@@ -0,0 +1,63 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWithLabels проверяет добавление меток и пропуск пустых значений
+func TestWithLabels(t *testing.T) {
+	ctx := WithLabels(context.Background(), "session-1", "call-1")
+	if v, _ := pprof.Label(ctx, LabelSessionID); v != "session-1" {
+		t.Errorf("Ожидалась метка session_id=session-1, получено %q", v)
+	}
+	if v, _ := pprof.Label(ctx, LabelCallID); v != "call-1" {
+		t.Errorf("Ожидалась метка call_id=call-1, получено %q", v)
+	}
+
+	ctx = WithLabels(context.Background(), "session-2", "")
+	if _, ok := pprof.Label(ctx, LabelCallID); ok {
+		t.Error("Пустой call_id не должен добавляться")
+	}
+
+	background := context.Background()
+	if WithLabels(background, "", "") != background {
+		t.Error("Без меток должен возвращаться исходный контекст")
+	}
+}
+
+// TestGoAndApply проверяет метки горутин, запущенных через Go и после Apply
+func TestGoAndApply(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	Go(WithLabels(context.Background(), "session-go", "call-go"), func() { <-stop })
+
+	reset := Apply(WithLabels(context.Background(), "session-apply", ""))
+	go func() { <-stop }()
+	reset()
+	go func() { <-stop }()
+
+	var profile bytes.Buffer
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		profile.Reset()
+		if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+			t.Fatalf("Ошибка получения профиля горутин: %v", err)
+		}
+		if strings.Contains(profile.String(), `"call_id":"call-go"`) &&
+			strings.Contains(profile.String(), `"session_id":"session-apply"`) {
+			break
+		}
+	}
+
+	if !strings.Contains(profile.String(), `"call_id":"call-go", "session_id":"session-go"`) {
+		t.Errorf("Горутина, запущенная через Go, должна быть помечена:\n%s", profile.String())
+	}
+	if strings.Count(profile.String(), `"session_id":"session-apply"`) != 1 {
+		t.Errorf("Метки Apply должна унаследовать только горутина, запущенная до reset:\n%s", profile.String())
+	}
+}
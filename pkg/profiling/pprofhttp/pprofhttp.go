@@ -0,0 +1,49 @@
+// Package pprofhttp регистрирует HTTP обработчики pprof и expvar для
+// диагностики работающего приложения.
+//
+// Пакет вынесен отдельно от profiling: импорт net/http/pprof регистрирует
+// обработчики в http.DefaultServeMux, и это должно происходить только по
+// явному выбору приложения.
+//
+//	mux := http.NewServeMux()
+//	pprofhttp.Register(mux)
+//	go http.ListenAndServe("127.0.0.1:6060", mux)
+package pprofhttp
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+)
+
+var publishOnce sync.Once
+
+// Register регистрирует в mux обработчики /debug/pprof/ и /debug/vars.
+// В expvar публикуется переменная goroutines с текущим числом горутин.
+func Register(mux *http.ServeMux) {
+	publishOnce.Do(func() {
+		if expvar.Get("goroutines") == nil {
+			expvar.Publish("goroutines", expvar.Func(func() interface{} {
+				return runtime.NumGoroutine()
+			}))
+		}
+	})
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+}
+
+// ListenAndServe запускает HTTP сервер диагностики на addr.
+// Рекомендуется слушать только локальный адрес: профили раскрывают
+// внутреннее состояние приложения.
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	Register(mux)
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,42 @@
+package pprofhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRegister проверяет регистрацию обработчиков pprof и expvar
+func TestRegister(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux)
+	// Повторная регистрация в другом mux не должна паниковать на expvar.Publish
+	Register(http.NewServeMux())
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/debug/vars")
+	if err != nil {
+		t.Fatalf("Ошибка запроса /debug/vars: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var vars map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatalf("Некорректный ответ /debug/vars: %v", err)
+	}
+	if n, ok := vars["goroutines"].(float64); !ok || n < 1 {
+		t.Errorf("Ожидалась переменная goroutines, получено %v", vars["goroutines"])
+	}
+
+	resp, err = http.Get(server.URL + "/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		t.Fatalf("Ошибка запроса профиля горутин: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Ожидался статус 200, получено %d", resp.StatusCode)
+	}
+}
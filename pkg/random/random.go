@@ -0,0 +1,95 @@
+// Package random содержит источник случайных данных для идентификаторов SIP
+// (Call-ID, теги, начальный CSeq), RTP (SSRC, начальные sequence number и
+// timestamp) и выбора медиа портов.
+//
+// По умолчанию используется crypto/rand. Тесты подставляют детерминированный
+// источник, чтобы повторить точную последовательность значений упавшего
+// прогона:
+//
+//	src := random.NewSeeded(42)
+//	uu, _ := dialog.NewUACUAS(dialog.Config{RandomSource: src, ...})
+//	handler, _ := media_sdp.NewSDPMediaHandler(media_sdp.HandlerConfig{RandomSource: src, ...})
+package random
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	mathrand "math/rand/v2"
+	"sync"
+)
+
+// Default - источник по умолчанию (crypto/rand)
+var Default io.Reader = rand.Reader
+
+// alphabet - символы строк String, совпадает с sip.RandString
+const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// seededSource - детерминированный потокобезопасный источник на ChaCha8
+type seededSource struct {
+	mu  sync.Mutex
+	rng *mathrand.ChaCha8
+}
+
+// NewSeeded создает детерминированный источник: одинаковый seed дает одинаковую
+// последовательность байт. Безопасен для одновременного использования, но
+// последовательность воспроизводится, только если порядок обращений
+// между горутинами тот же. Не использовать вне тестов.
+func NewSeeded(seed uint64) io.Reader {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], seed)
+	return &seededSource{rng: mathrand.NewChaCha8(key)}
+}
+
+func (s *seededSource) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Read(p)
+}
+
+// orDefault возвращает r или Default, если r не задан
+func orDefault(r io.Reader) io.Reader {
+	if r == nil {
+		return Default
+	}
+	return r
+}
+
+// Uint32 читает случайное 32-битное число из r (nil - Default)
+func Uint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(orDefault(r), buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+// Uint64 читает случайное 64-битное число из r (nil - Default)
+func Uint64(r io.Reader) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(orDefault(r), buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// IntN возвращает случайное число в [0, n). n должно быть > 0.
+// При ошибке чтения возвращается 0
+func IntN(r io.Reader, n int) int {
+	v, _ := Uint64(r)
+	return int(v % uint64(n))
+}
+
+// String возвращает случайную строку длины n из латинских букв и цифр
+func String(r io.Reader, n int) string {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(orDefault(r), buf); err != nil {
+		// crypto/rand не возвращает ошибок на поддерживаемых платформах;
+		// при сбое пользовательского источника используем Default
+		_, _ = io.ReadFull(Default, buf)
+	}
+	for i, b := range buf {
+		buf[i] = alphabet[int(b)%len(alphabet)]
+	}
+	return string(buf)
+}
@@ -0,0 +1,44 @@
+package random
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewSeeded(t *testing.T) {
+	a, b := NewSeeded(42), NewSeeded(42)
+	for i := 0; i < 8; i++ {
+		va, _ := Uint32(a)
+		vb, _ := Uint32(b)
+		if va != vb {
+			t.Fatalf("Шаг %d: последовательности с одинаковым seed различаются: %d != %d", i, va, vb)
+		}
+	}
+	if String(a, 32) != String(b, 32) {
+		t.Error("Строки с одинаковым seed различаются")
+	}
+
+	other, _ := Uint64(NewSeeded(43))
+	same, _ := Uint64(NewSeeded(42))
+	if other == same {
+		t.Error("Разные seed дали одинаковое значение")
+	}
+}
+
+func TestString(t *testing.T) {
+	s := String(nil, 64)
+	if len(s) != 64 {
+		t.Fatalf("Ожидалась длина 64, получено %d", len(s))
+	}
+	for _, c := range s {
+		if !strings.ContainsRune(alphabet, c) {
+			t.Errorf("Недопустимый символ %q", c)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if n := IntN(NewSeeded(uint64(i)), 7); n < 0 || n >= 7 {
+			t.Fatalf("IntN вне диапазона: %d", n)
+		}
+	}
+}
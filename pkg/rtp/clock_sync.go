@@ -0,0 +1,76 @@
+package rtp
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClockMapping - соответствие RTP timestamp источника и NTP времени его
+// часов из последнего Sender Report (RFC 3550 Section 6.4.1). Позволяет
+// перевести RTP timestamp любого пакета источника во время по часам
+// отправителя и синхронизировать несколько потоков (аудио разных
+// участников, запись, аналитика).
+type ClockMapping struct {
+	SSRC         uint32
+	RTPTimestamp uint32    // RTP timestamp из SR
+	NTPTime      time.Time // NTP время отправителя, соответствующее RTPTimestamp
+	ReceivedAt   time.Time // Локальное время получения SR
+}
+
+// WallClock переводит RTP timestamp источника во время по часам
+// отправителя. Разность timestamp считается со знаком, поэтому переход
+// счетчика через 2^32 и пакеты до SR обрабатываются корректно
+func (m ClockMapping) WallClock(ts uint32, clockRate uint32) time.Time {
+	ticks := int64(int32(ts - m.RTPTimestamp))
+	return m.NTPTime.Add(time.Duration(ticks * int64(time.Second) / int64(clockRate)))
+}
+
+// updateClockMapping сохраняет соответствие часов из SR. Вызывается под
+// statisticsMutex
+func (rs *RTCPSession) updateClockMapping(sr *SenderReport, received time.Time) {
+	if rs.clockMappings == nil {
+		rs.clockMappings = make(map[uint32]ClockMapping)
+	}
+	rs.clockMappings[sr.SSRC] = ClockMapping{
+		SSRC:         sr.SSRC,
+		RTPTimestamp: sr.RTPTimestamp,
+		NTPTime:      NTPTimestampToTime(sr.NTPTimestamp),
+		ReceivedAt:   received,
+	}
+}
+
+// GetClockMapping возвращает соответствие часов источника из последнего
+// SR. false - от источника еще не получен Sender Report
+func (rs *RTCPSession) GetClockMapping(ssrc uint32) (ClockMapping, bool) {
+	rs.statisticsMutex.RLock()
+	defer rs.statisticsMutex.RUnlock()
+	mapping, ok := rs.clockMappings[ssrc]
+	return mapping, ok
+}
+
+// GetClockMapping возвращает соответствие RTP timestamp и NTP времени
+// удаленного источника из последнего полученного Sender Report
+func (s *Session) GetClockMapping(ssrc uint32) (ClockMapping, bool) {
+	if s.rtcpSession == nil {
+		return ClockMapping{}, false
+	}
+	return s.rtcpSession.GetClockMapping(ssrc)
+}
+
+// ConvertRTPTimeToWallClock переводит RTP timestamp пакета удаленного
+// источника ssrc во время по часам отправителя (NTP) с частотой RTP clock
+// сессии.
+//
+// Возвращает ошибку, если RTCP отключен или от источника еще не получен
+// Sender Report.
+func (s *Session) ConvertRTPTimeToWallClock(ssrc uint32, ts uint32) (time.Time, error) {
+	mapping, ok := s.GetClockMapping(ssrc)
+	if !ok {
+		return time.Time{}, fmt.Errorf("нет Sender Report от источника SSRC=%d", ssrc)
+	}
+	clockRate := s.GetClockRate()
+	if clockRate == 0 {
+		return time.Time{}, fmt.Errorf("частота RTP clock не задана")
+	}
+	return mapping.WallClock(ts, clockRate), nil
+}
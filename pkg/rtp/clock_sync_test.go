@@ -0,0 +1,85 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+)
+
+// TestClockMappingFromSenderReport проверяет перевод RTP timestamp во время
+// по часам отправителя из полученного Sender Report
+func TestClockMappingFromSenderReport(t *testing.T) {
+	transportConfig := DefaultRTCPTransportConfig()
+	transportConfig.LocalAddr = "127.0.0.1:0"
+	transport, err := NewUDPRTCPTransport(transportConfig)
+	if err != nil {
+		t.Fatalf("Ошибка создания RTCP транспорта: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	rtcpSession, err := NewRTCPSession(RTCPSessionConfig{
+		SSRC:          0x12345678,
+		RTCPTransport: transport,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания RTCP сессии: %v", err)
+	}
+
+	const remoteSSRC = 0x87654321
+	if _, ok := rtcpSession.GetClockMapping(remoteSSRC); ok {
+		t.Fatal("Соответствие часов не должно существовать до SR")
+	}
+
+	// RTP timestamp близко к переполнению счетчика
+	senderTime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sr := NewSenderReport(remoteSSRC, NTPTimestamp(senderTime), 0xFFFFFF00, 100, 16000)
+	data, err := sr.Marshal()
+	if err != nil {
+		t.Fatalf("Ошибка сериализации SR: %v", err)
+	}
+	if err := rtcpSession.ProcessRTCPPacket(data, nil); err != nil {
+		t.Fatalf("Ошибка обработки SR: %v", err)
+	}
+
+	mapping, ok := rtcpSession.GetClockMapping(remoteSSRC)
+	if !ok {
+		t.Fatal("Соответствие часов не сохранено из SR")
+	}
+	if mapping.RTPTimestamp != 0xFFFFFF00 || mapping.NTPTime.Sub(senderTime).Abs() > time.Microsecond {
+		t.Errorf("Неверное соответствие часов: %+v", mapping)
+	}
+
+	tests := []struct {
+		name string
+		ts   uint32
+		want time.Duration
+	}{
+		{"момент SR", 0xFFFFFF00, 0},
+		{"после переполнения счетчика", 8000 - 0x100, time.Second},
+		{"пакет до SR", 0xFFFFFF00 - 160, -20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		got := mapping.WallClock(tt.ts, 8000).Sub(senderTime)
+		if (got - tt.want).Abs() > time.Microsecond {
+			t.Errorf("%s: смещение %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestSessionConvertRTPTimeToWallClock проверяет ошибку перевода до SR
+func TestSessionConvertRTPTimeToWallClock(t *testing.T) {
+	transport := NewMockTransport()
+	session, err := NewSession(SessionConfig{
+		PayloadType: PayloadTypePCMU,
+		MediaType:   MediaTypeAudio,
+		ClockRate:   8000,
+		Transport:   transport,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer func() { _ = session.Stop() }()
+
+	if _, err := session.ConvertRTPTimeToWallClock(1, 160); err == nil {
+		t.Error("Ожидалась ошибка без Sender Report")
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !small
+
 // comprehensive_test.go - Комплексные тесты для production readiness
 package rtp
 
@@ -0,0 +1,68 @@
+//go:build !small
+
+package rtp_test
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/arzzra/soft_phone/pkg/rtp"
+)
+
+// ExampleDTLSTransport_basic демонстрирует создание DTLS транспорта
+func ExampleDTLSTransport_basic() {
+	// Создаем DTLS транспорт для безопасной передачи RTP
+	config := rtp.DTLSTransportConfig{
+		TransportConfig: rtp.TransportConfig{
+			LocalAddr:  "127.0.0.1:5004",
+			BufferSize: 1500,
+		},
+		InsecureSkipVerify: true, // Только для демонстрации
+		HandshakeTimeout:   5 * time.Second,
+	}
+
+	transport, err := rtp.NewDTLSTransportServer(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer transport.Close()
+
+	fmt.Printf("DTLS транспорт создан: %v\n", transport.LocalAddr())
+	fmt.Printf("Активен: %t\n", transport.IsActive())
+	fmt.Printf("Handshake завершен: %t\n", transport.IsHandshakeComplete())
+
+	// Output:
+	// DTLS транспорт создан: 127.0.0.1:5004
+	// Активен: false
+	// Handshake завершен: false
+}
+
+// ExampleDTLSTransportConfig демонстрирует настройку DTLS конфигурации
+func ExampleDTLSTransportConfig() {
+	// Получаем конфигурацию DTLS по умолчанию
+	config := rtp.DefaultDTLSTransportConfig()
+
+	// Настраиваем для тестирования
+	config.TransportConfig.LocalAddr = "127.0.0.1:5004"
+	config.TransportConfig.BufferSize = 1500
+	config.InsecureSkipVerify = true // Только для демонстрации
+	config.HandshakeTimeout = 10 * time.Second
+
+	fmt.Printf("DTLS конфигурация:\n")
+	fmt.Printf("Локальный адрес: %s\n", config.TransportConfig.LocalAddr)
+	fmt.Printf("Размер буфера: %d\n", config.TransportConfig.BufferSize)
+	fmt.Printf("Таймаут handshake: %v\n", config.HandshakeTimeout)
+	fmt.Printf("MTU: %d\n", config.MTU)
+	fmt.Printf("Replay window: %d\n", config.ReplayProtectionWindow)
+	fmt.Printf("Connection ID: %t\n", config.EnableConnectionID)
+
+	// Output:
+	// DTLS конфигурация:
+	// Локальный адрес: 127.0.0.1:5004
+	// Размер буфера: 1500
+	// Таймаут handshake: 10s
+	// MTU: 1200
+	// Replay window: 64
+	// Connection ID: true
+}
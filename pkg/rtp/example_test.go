@@ -52,34 +52,6 @@ func ExampleUDPTransport_basic() {
 	// Активен: true
 }
 
-// ExampleDTLSTransport_basic демонстрирует создание DTLS транспорта
-func ExampleDTLSTransport_basic() {
-	// Создаем DTLS транспорт для безопасной передачи RTP
-	config := rtp.DTLSTransportConfig{
-		TransportConfig: rtp.TransportConfig{
-			LocalAddr:  "127.0.0.1:5004",
-			BufferSize: 1500,
-		},
-		InsecureSkipVerify: true, // Только для демонстрации
-		HandshakeTimeout:   5 * time.Second,
-	}
-
-	transport, err := rtp.NewDTLSTransportServer(config)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer transport.Close()
-
-	fmt.Printf("DTLS транспорт создан: %v\n", transport.LocalAddr())
-	fmt.Printf("Активен: %t\n", transport.IsActive())
-	fmt.Printf("Handshake завершен: %t\n", transport.IsHandshakeComplete())
-
-	// Output:
-	// DTLS транспорт создан: 127.0.0.1:5004
-	// Активен: false
-	// Handshake завершен: false
-}
-
 // ExampleExtendedTransportConfig демонстрирует расширенную конфигурацию транспорта
 func ExampleExtendedTransportConfig() {
 	// Создаем расширенную конфигурацию с QoS настройками
@@ -159,32 +131,3 @@ func ExampleTransportStatistics() {
 	// Скорость получения: 1.58 пакетов/сек
 	// Процент ошибок: 0.51%
 }
-
-// ExampleDTLSTransportConfig демонстрирует настройку DTLS конфигурации
-func ExampleDTLSTransportConfig() {
-	// Получаем конфигурацию DTLS по умолчанию
-	config := rtp.DefaultDTLSTransportConfig()
-
-	// Настраиваем для тестирования
-	config.TransportConfig.LocalAddr = "127.0.0.1:5004"
-	config.TransportConfig.BufferSize = 1500
-	config.InsecureSkipVerify = true // Только для демонстрации
-	config.HandshakeTimeout = 10 * time.Second
-
-	fmt.Printf("DTLS конфигурация:\n")
-	fmt.Printf("Локальный адрес: %s\n", config.TransportConfig.LocalAddr)
-	fmt.Printf("Размер буфера: %d\n", config.TransportConfig.BufferSize)
-	fmt.Printf("Таймаут handshake: %v\n", config.HandshakeTimeout)
-	fmt.Printf("MTU: %d\n", config.MTU)
-	fmt.Printf("Replay window: %d\n", config.ReplayProtectionWindow)
-	fmt.Printf("Connection ID: %t\n", config.EnableConnectionID)
-
-	// Output:
-	// DTLS конфигурация:
-	// Локальный адрес: 127.0.0.1:5004
-	// Размер буфера: 1500
-	// Таймаут handshake: 10s
-	// MTU: 1200
-	// Replay window: 64
-	// Connection ID: true
-}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
 	"github.com/arzzra/soft_phone/pkg/rtp"
 	rtppion "github.com/pion/rtp"
 )
@@ -127,14 +128,11 @@ func BasicSessionExample() error {
 		fmt.Printf("⚠️  Ошибка отправки SDES: %v\n", err)
 	}
 
-	// Генерируем и отправляем тестовое аудио (G.711 μ-law silence)
+	// Генерируем и отправляем тестовое аудио (G.711 μ-law тон 440 Гц)
 	fmt.Println("🎵 Начинаем передачу аудио...")
 
-	// G.711 μ-law silence (0xFF = тишина в μ-law)
-	audioFrame := make([]byte, 160) // 20ms при 8kHz = 160 samples
-	for i := range audioFrame {
-		audioFrame[i] = 0xFF // μ-law silence
-	}
+	// Каждый кадр 20ms при 8kHz = 160 samples, фаза тона сохраняется между кадрами
+	toneGenerator := testsignal.NewGenerator(testsignal.Sine(440, 0.3), testsignal.PCMU, 20*time.Millisecond)
 
 	// Отправляем аудио каждые 20ms (стандарт для телефонии)
 	ticker := time.NewTicker(20 * time.Millisecond)
@@ -144,7 +142,7 @@ func BasicSessionExample() error {
 	go func() {
 		packetCount := 0
 		for range ticker.C {
-			err := session.SendAudio(audioFrame, 20*time.Millisecond)
+			err := session.SendAudio(toneGenerator.NextFrame(), 20*time.Millisecond)
 			if err != nil {
 				fmt.Printf("❌ Ошибка отправки аудио: %v\n", err)
 				return
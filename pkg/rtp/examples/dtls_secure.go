@@ -1,3 +1,5 @@
+//go:build !small
+
 // Пример защищенной RTP передачи через DTLS
 // Демонстрирует настройку безопасного соединения для конфиденциальной связи
 package examples
@@ -0,0 +1,7 @@
+package rtp
+
+import "errors"
+
+// ErrExcludedFromBuild возвращается конструкторами подсистем, исключенных из
+// минимальной сборки с тегом small (например, DTLS транспорта)
+var ErrExcludedFromBuild = errors.New("подсистема исключена из сборки (тег small)")
@@ -0,0 +1,120 @@
+package rtp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxLatchEvents - сколько последних смен адреса источника хранит сессия
+const MaxLatchEvents = 16
+
+// LatchEvent - смена адреса, с которого приходят RTP пакеты. Первое событие
+// (From пустой) - привязка к первому источнику, последующие - переключение
+// NAT или SBC на другой адрес в ходе вызова
+type LatchEvent struct {
+	Time time.Time
+	From string
+	To   string
+}
+
+// MediaPathInfo - сведения о медиа пути сессии для диагностики
+// одностороннего звука: время первого и последнего пакета в каждом
+// направлении, фактический адрес источника и полученные RTCP пакеты
+type MediaPathInfo struct {
+	PacketsSent     uint64
+	PacketsReceived uint64
+	FirstSent       time.Time
+	LastSent        time.Time
+	FirstReceived   time.Time
+	LastReceived    time.Time
+
+	// RemoteSource - адрес источника последнего полученного RTP пакета
+	RemoteSource string
+	LatchEvents  []LatchEvent
+
+	RTCPReceived     uint64
+	LastRTCPReceived time.Time
+}
+
+// mediaPathTracker отслеживает время пакетов и адрес источника RTP
+type mediaPathTracker struct {
+	firstSent     atomic.Int64 // UnixNano, 0 - пакетов не было
+	lastSent      atomic.Int64
+	firstReceived atomic.Int64
+	lastReceived  atomic.Int64
+
+	mu      sync.Mutex
+	source  string
+	latches []LatchEvent
+}
+
+func (p *mediaPathTracker) sent(now time.Time) {
+	nanos := now.UnixNano()
+	p.firstSent.CompareAndSwap(0, nanos)
+	p.lastSent.Store(nanos)
+}
+
+func (p *mediaPathTracker) received(now time.Time, addr net.Addr) {
+	nanos := now.UnixNano()
+	p.firstReceived.CompareAndSwap(0, nanos)
+	p.lastReceived.Store(nanos)
+
+	if addr == nil {
+		return
+	}
+	source := addr.String()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if source == p.source {
+		return
+	}
+	p.latches = append(p.latches, LatchEvent{Time: now, From: p.source, To: source})
+	if len(p.latches) > MaxLatchEvents {
+		p.latches = p.latches[len(p.latches)-MaxLatchEvents:]
+	}
+	p.source = source
+}
+
+// fill заполняет время пакетов и сведения об источнике
+func (p *mediaPathTracker) fill(info *MediaPathInfo) {
+	info.FirstSent = unixNanoTime(p.firstSent.Load())
+	info.LastSent = unixNanoTime(p.lastSent.Load())
+	info.FirstReceived = unixNanoTime(p.firstReceived.Load())
+	info.LastReceived = unixNanoTime(p.lastReceived.Load())
+
+	p.mu.Lock()
+	info.RemoteSource = p.source
+	info.LatchEvents = append([]LatchEvent(nil), p.latches...)
+	p.mu.Unlock()
+}
+
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// MediaPath возвращает сведения о медиа пути RTP
+func (rs *RTPSession) MediaPath() MediaPathInfo {
+	info := MediaPathInfo{
+		PacketsSent:     rs.GetPacketsSent(),
+		PacketsReceived: rs.GetPacketsReceived(),
+	}
+	rs.path.fill(&info)
+	return info
+}
+
+// MediaPath возвращает сведения о медиа пути RTP и полученных RTCP пакетах
+func (s *Session) MediaPath() MediaPathInfo {
+	var info MediaPathInfo
+	if s.rtpSession != nil {
+		info = s.rtpSession.MediaPath()
+	}
+	info.RTCPReceived = s.rtcpReceived.Load()
+	info.LastRTCPReceived = unixNanoTime(s.lastRTCPReceived.Load())
+	return info
+}
@@ -0,0 +1,45 @@
+package rtp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestMediaPathTracker проверяет учет времени пакетов и смен адреса источника
+func TestMediaPathTracker(t *testing.T) {
+	var tracker mediaPathTracker
+	start := time.Now()
+	first := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 10), Port: 4000}
+	natted := &net.UDPAddr{IP: net.IPv4(198, 51, 100, 7), Port: 61000}
+
+	tracker.sent(start)
+	tracker.received(start.Add(time.Millisecond), first)
+	tracker.received(start.Add(2*time.Millisecond), first)
+	tracker.received(start.Add(3*time.Millisecond), natted)
+	tracker.sent(start.Add(4 * time.Millisecond))
+
+	var info MediaPathInfo
+	tracker.fill(&info)
+	if !info.FirstSent.Equal(start) || !info.LastSent.Equal(start.Add(4*time.Millisecond)) {
+		t.Errorf("Неверное время отправки: %v - %v", info.FirstSent, info.LastSent)
+	}
+	if !info.FirstReceived.Equal(start.Add(time.Millisecond)) {
+		t.Errorf("Неверное время первого входящего пакета: %v", info.FirstReceived)
+	}
+	if info.RemoteSource != natted.String() {
+		t.Errorf("Ожидался источник %s, получен %s", natted, info.RemoteSource)
+	}
+	if len(info.LatchEvents) != 2 || info.LatchEvents[0].From != "" || info.LatchEvents[1].From != first.String() {
+		t.Errorf("Неожиданные события latching: %+v", info.LatchEvents)
+	}
+
+	// Хранятся только последние MaxLatchEvents событий
+	for i := 0; i < MaxLatchEvents+5; i++ {
+		tracker.received(time.Now(), &net.UDPAddr{IP: net.IPv4(203, 0, 113, 1), Port: 1000 + i})
+	}
+	tracker.fill(&info)
+	if len(info.LatchEvents) != MaxLatchEvents {
+		t.Errorf("Ожидалось %d событий, получено %d", MaxLatchEvents, len(info.LatchEvents))
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !small
+
 // metrics.go - Расширенная система метрик для production monitoring
 //
 // Реализует комплексную систему сбора и экспорта метрик RTP стека:
@@ -16,6 +18,7 @@
 package rtp
 
 import (
+	"io"
 	"net/http"
 	"runtime"
 	"sort"
@@ -40,6 +43,7 @@ type MetricsCollector struct {
 	// Core metrics storage
 	sessions    map[string]*SessionMetrics // session_id -> metrics
 	globalStats *GlobalMetrics             // Агрегированные метрики
+	history     map[string]*metricsHistory // session_id -> история качества
 	mutex       sync.RWMutex
 
 	// Configuration
@@ -62,6 +66,9 @@ type MetricsCollector struct {
 	// Internal counters
 	totalSamples   uint64
 	droppedSamples uint64
+
+	// Дополнительные источники метрик Prometheus (AddPrometheusSource)
+	prometheusSources []PrometheusSource
 }
 
 // SessionMetrics содержит метрики для одной RTP сессии
@@ -199,6 +206,11 @@ type MetricsConfig struct {
 	// Resource monitoring
 	ResourceMonitoring  bool          `json:"resource_monitoring"`
 	MemoryCheckInterval time.Duration `json:"memory_check_interval"`
+
+	// Quality history: выборки раз в HistoryInterval за последние HistoryWindow.
+	// 0 - значения по умолчанию (5 минут, 1 секунда), HistoryWindow < 0 - отключено
+	HistoryWindow   time.Duration `json:"history_window"`
+	HistoryInterval time.Duration `json:"history_interval"`
 }
 
 // QualityThresholds пороговые значения для оценки качества
@@ -217,6 +229,13 @@ type MetricsExporter interface {
 	ExportCSV() ([]byte, error)
 }
 
+// PrometheusSource - источник метрик вне RTP стека (например, гистограммы
+// установления вызовов dialog.CallSetupMetrics), которые MetricsCollector
+// отдает вместе со своими в Prometheus формате
+type PrometheusSource interface {
+	WritePrometheus(w io.Writer) error
+}
+
 // MetricPoint точка данных для time-series БД
 type MetricPoint struct {
 	Name      string                 `json:"name"`
@@ -271,9 +290,18 @@ func NewMetricsCollector(config MetricsConfig) *MetricsCollector {
 		config.QualityThresholds.MinQualityScore = 70
 	}
 
+	// Значения по умолчанию для истории качества
+	if config.HistoryWindow == 0 {
+		config.HistoryWindow = DefaultMetricsHistoryWindow
+	}
+	if config.HistoryInterval <= 0 {
+		config.HistoryInterval = DefaultMetricsHistoryInterval
+	}
+
 	collector := &MetricsCollector{
 		sessions:        make(map[string]*SessionMetrics),
 		globalStats:     &GlobalMetrics{},
+		history:         make(map[string]*metricsHistory),
 		config:          config,
 		jitterHistogram: NewHistogram(config.JitterBuckets, 1000), // Keep 1000 samples
 		rttHistogram:    NewHistogram(config.RTTBuckets, 1000),
@@ -284,6 +312,9 @@ func NewMetricsCollector(config MetricsConfig) *MetricsCollector {
 
 	// Запускаем периодические задачи
 	go collector.periodicTasks()
+	if collector.historyEnabled() {
+		go collector.sampleHistory()
+	}
 
 	return collector
 }
@@ -1,3 +1,5 @@
+//go:build !small
+
 // metrics_collector.go - Реализация основных методов MetricsCollector
 package rtp
 
@@ -44,6 +46,9 @@ func (mc *MetricsCollector) RegisterSession(sessionID string, session *Session)
 	}
 
 	mc.sessions[sessionID] = metrics
+	if mc.historyEnabled() {
+		mc.history[sessionID] = newMetricsHistory(session, mc.historyCapacity())
+	}
 
 	// Обновляем глобальную статистику
 	mc.globalStats.mutex.Lock()
@@ -60,6 +65,7 @@ func (mc *MetricsCollector) UnregisterSession(sessionID string) {
 	mc.mutex.Lock()
 	defer mc.mutex.Unlock()
 
+	delete(mc.history, sessionID)
 	if _, exists := mc.sessions[sessionID]; exists {
 		delete(mc.sessions, sessionID)
 
@@ -468,6 +474,7 @@ func (mc *MetricsCollector) StartHTTPServer(addr string) error {
 	mux.HandleFunc("/metrics/prometheus", mc.handlePrometheusMetrics)
 	mux.HandleFunc("/metrics/json", mc.handleJSONMetrics)
 	mux.HandleFunc("/debug/sessions", mc.handleDebugSessions)
+	mux.HandleFunc("/debug/sessions/history", mc.handleSessionHistory)
 
 	mc.httpServer = &http.Server{
 		Addr:    addr,
@@ -486,6 +493,14 @@ func (mc *MetricsCollector) StopHTTPServer() error {
 	return nil
 }
 
+// AddPrometheusSource добавляет источник метрик, которые отдаются в
+// Prometheus формате после метрик RTP
+func (mc *MetricsCollector) AddPrometheusSource(source PrometheusSource) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.prometheusSources = append(mc.prometheusSources, source)
+}
+
 // handleMetrics обрабатывает запросы метрик (автоматический формат)
 func (mc *MetricsCollector) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	atomic.AddUint64(&mc.globalStats.MetricsRequests, 1)
@@ -593,6 +608,16 @@ func (mc *MetricsCollector) handlePrometheusMetrics(w http.ResponseWriter, r *ht
 	output.WriteString("# TYPE rtp_goroutines gauge\n")
 	output.WriteString(fmt.Sprintf("rtp_goroutines %d\n", global.GoRoutines))
 
+	mc.mutex.RLock()
+	sources := append([]PrometheusSource(nil), mc.prometheusSources...)
+	mc.mutex.RUnlock()
+	for _, source := range sources {
+		if err := source.WritePrometheus(&output); err != nil {
+			http.Error(w, "Ошибка экспорта метрик: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	_, _ = w.Write([]byte(output.String()))
 }
 
@@ -636,8 +661,17 @@ func (mc *MetricsCollector) cleanupOldSessions() {
 		lastActivity := session.LastActivity
 		session.mutex.RUnlock()
 
+		// Сессия с историей активна, пока через нее идет RTP, даже если
+		// приложение не вызывает UpdateSessionMetrics
+		if history, exists := mc.history[sessionID]; exists {
+			if active := history.lastActivity(); active.After(lastActivity) {
+				lastActivity = active
+			}
+		}
+
 		if lastActivity.Before(threshold) {
 			delete(mc.sessions, sessionID)
+			delete(mc.history, sessionID)
 			log.Printf("Удалена неактивная сессия %s", sessionID)
 		}
 	}
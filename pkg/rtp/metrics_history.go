@@ -0,0 +1,229 @@
+//go:build !small
+
+// metrics_history.go - История качества сессий в MetricsCollector
+//
+// Для каждой зарегистрированной сессии collector сам, без дополнительной
+// инструментации приложения, раз в HistoryInterval снимает показатели
+// качества и хранит их в кольцевом буфере на HistoryWindow. Так инженер
+// поддержки может посмотреть последние минуты проблемного вызова, который
+// уже идет, через GetSessionHistory или /debug/sessions/history.
+package rtp
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMetricsHistoryWindow - сколько истории хранится по умолчанию
+	DefaultMetricsHistoryWindow = 5 * time.Minute
+	// DefaultMetricsHistoryInterval - период снятия выборок по умолчанию
+	DefaultMetricsHistoryInterval = time.Second
+)
+
+// QualitySample - выборка качества сессии за один интервал. Счетчики пакетов
+// и байт - приращения за интервал, а не накопленные значения
+type QualitySample struct {
+	Timestamp time.Time `json:"timestamp"`
+
+	PacketsSent     uint64 `json:"packets_sent"`
+	PacketsReceived uint64 `json:"packets_received"`
+	BytesSent       uint64 `json:"bytes_sent"`
+	BytesReceived   uint64 `json:"bytes_received"`
+	PacketsLost     uint64 `json:"packets_lost"`
+
+	PacketLossRate float64 `json:"packet_loss_rate"` // Потери за интервал
+	Jitter         float64 `json:"jitter_ms"`
+	RTT            float64 `json:"rtt_ms"`
+
+	State string `json:"state"`
+}
+
+// metricsHistory - кольцевой буфер выборок одной сессии
+type metricsHistory struct {
+	session *Session
+
+	mu      sync.Mutex
+	samples []QualitySample
+	next    int  // Позиция следующей записи
+	full    bool // Буфер заполнен, самая старая выборка в next
+	last    SessionStatistics
+}
+
+func newMetricsHistory(session *Session, capacity int) *metricsHistory {
+	h := &metricsHistory{
+		session: session,
+		samples: make([]QualitySample, capacity),
+	}
+	if session != nil {
+		h.last = session.GetStatistics()
+	}
+	return h
+}
+
+// record снимает выборку с сессии. rtt - последнее значение из метрик
+// сессии, RTCP статистика Session его не содержит
+func (h *metricsHistory) record(now time.Time, rtt float64) {
+	if h.session == nil {
+		return
+	}
+	stats := h.session.GetStatistics()
+
+	sample := QualitySample{
+		Timestamp: now,
+		RTT:       rtt,
+		State:     h.session.GetState().String(),
+	}
+	if clockRate := h.session.GetClockRate(); clockRate > 0 {
+		sample.Jitter = stats.Jitter * 1000 / float64(clockRate)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sample.PacketsSent = counterDelta(stats.PacketsSent, h.last.PacketsSent)
+	sample.PacketsReceived = counterDelta(stats.PacketsReceived, h.last.PacketsReceived)
+	sample.BytesSent = counterDelta(stats.BytesSent, h.last.BytesSent)
+	sample.BytesReceived = counterDelta(stats.BytesReceived, h.last.BytesReceived)
+	sample.PacketsLost = counterDelta(uint64(stats.PacketsLost), uint64(h.last.PacketsLost))
+	if expected := sample.PacketsReceived + sample.PacketsLost; expected > 0 {
+		sample.PacketLossRate = float64(sample.PacketsLost) / float64(expected)
+	}
+	h.last = stats
+
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// since возвращает выборки не старше cutoff в хронологическом порядке
+func (h *metricsHistory) since(cutoff time.Time) []QualitySample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var ordered []QualitySample
+	if h.full {
+		ordered = append(ordered, h.samples[h.next:]...)
+	}
+	ordered = append(ordered, h.samples[:h.next]...)
+
+	result := make([]QualitySample, 0, len(ordered))
+	for _, sample := range ordered {
+		if !sample.Timestamp.Before(cutoff) {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+// lastActivity - время последнего RTP пакета сессии
+func (h *metricsHistory) lastActivity() time.Time {
+	if h.session == nil || h.session.rtpSession == nil {
+		return time.Time{}
+	}
+	return h.session.rtpSession.GetLastActivity()
+}
+
+// counterDelta - приращение счетчика; сброс счетчика дает текущее значение
+func counterDelta(current, previous uint64) uint64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// historyEnabled сообщает, ведется ли история качества
+func (mc *MetricsCollector) historyEnabled() bool {
+	return mc.config.HistoryWindow > 0
+}
+
+// historyCapacity - число выборок, покрывающее HistoryWindow
+func (mc *MetricsCollector) historyCapacity() int {
+	capacity := int(mc.config.HistoryWindow / mc.config.HistoryInterval)
+	if capacity < 1 {
+		capacity = 1
+	}
+	return capacity
+}
+
+// sampleHistory периодически снимает выборки со всех сессий
+func (mc *MetricsCollector) sampleHistory() {
+	ticker := time.NewTicker(mc.config.HistoryInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mc.recordHistory(now)
+	}
+}
+
+// recordHistory снимает по одной выборке со всех сессий с историей
+func (mc *MetricsCollector) recordHistory(now time.Time) {
+	mc.mutex.RLock()
+	defer mc.mutex.RUnlock()
+
+	for sessionID, history := range mc.history {
+		var rtt float64
+		if metrics, exists := mc.sessions[sessionID]; exists {
+			metrics.mutex.RLock()
+			rtt = metrics.RTT
+			metrics.mutex.RUnlock()
+		}
+		history.record(now, rtt)
+	}
+}
+
+// GetSessionHistory возвращает выборки качества сессии за последние window
+// в хронологическом порядке. window <= 0 или больше HistoryWindow означает
+// всю сохраненную историю. Второе значение false - сессия не найдена или
+// история отключена
+func (mc *MetricsCollector) GetSessionHistory(sessionID string, window time.Duration) ([]QualitySample, bool) {
+	mc.mutex.RLock()
+	history, exists := mc.history[sessionID]
+	mc.mutex.RUnlock()
+
+	if !exists {
+		return nil, false
+	}
+	if window <= 0 || window > mc.config.HistoryWindow {
+		window = mc.config.HistoryWindow
+	}
+	return history.since(time.Now().Add(-window)), true
+}
+
+// handleSessionHistory возвращает историю качества сессии:
+// /debug/sessions/history?session_id=<id>&window=2m
+func (mc *MetricsCollector) handleSessionHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	sessionID := query.Get("session_id")
+	if sessionID == "" {
+		http.Error(w, "Не указан session_id", http.StatusBadRequest)
+		return
+	}
+
+	var window time.Duration
+	if value := query.Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			http.Error(w, "Неверный window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	samples, exists := mc.GetSessionHistory(sessionID, window)
+	if !exists {
+		http.Error(w, "История сессии не найдена", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"session_id": sessionID,
+		"interval":   mc.config.HistoryInterval.String(),
+		"samples":    samples,
+	})
+}
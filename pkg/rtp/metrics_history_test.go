@@ -0,0 +1,99 @@
+//go:build !small
+
+package rtp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSessionHistory проверяет кольцевой буфер истории качества и REST endpoint
+func TestSessionHistory(t *testing.T) {
+	// Интервал в час: выборки снимаются только вызовами recordHistory из теста
+	collector := NewMetricsCollector(MetricsConfig{
+		HistoryWindow:   3 * time.Hour,
+		HistoryInterval: time.Hour,
+	})
+
+	transport := NewMockTransport()
+	transport.SetActive(true)
+	session, err := NewSession(SessionConfig{
+		PayloadType: PayloadTypePCMU,
+		MediaType:   MediaTypeAudio,
+		ClockRate:   8000,
+		Transport:   transport,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer func() { _ = session.Stop() }()
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+
+	sessionID := "history-session"
+	if err := collector.RegisterSession(sessionID, session); err != nil {
+		t.Fatalf("Ошибка регистрации сессии: %v", err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		for j := 0; j <= i; j++ {
+			if err := session.SendAudio(make([]byte, 160), 20*time.Millisecond); err != nil {
+				t.Fatalf("Ошибка отправки аудио: %v", err)
+			}
+		}
+		collector.recordHistory(now.Add(time.Duration(i-3) * time.Second))
+	}
+
+	// Емкость 3 выборки: самая старая вытеснена, счетчики - приращения
+	samples, exists := collector.GetSessionHistory(sessionID, 0)
+	if !exists {
+		t.Fatal("История сессии не найдена")
+	}
+	if len(samples) != 3 {
+		t.Fatalf("Ожидалось 3 выборки, получено %d", len(samples))
+	}
+	for i, sample := range samples {
+		if sample.PacketsSent != uint64(i+2) {
+			t.Errorf("Выборка %d: ожидалось %d отправленных пакетов, получено %d", i, i+2, sample.PacketsSent)
+		}
+	}
+
+	samples, _ = collector.GetSessionHistory(sessionID, 1500*time.Millisecond)
+	if len(samples) != 2 {
+		t.Errorf("За окно 1.5с ожидалось 2 выборки, получено %d", len(samples))
+	}
+
+	recorder := httptest.NewRecorder()
+	collector.handleSessionHistory(recorder, httptest.NewRequest(http.MethodGet,
+		"/debug/sessions/history?session_id="+sessionID+"&window=1h", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Неожиданный код ответа: %d", recorder.Code)
+	}
+	var response struct {
+		SessionID string          `json:"session_id"`
+		Samples   []QualitySample `json:"samples"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Ошибка разбора ответа: %v", err)
+	}
+	if response.SessionID != sessionID || len(response.Samples) != 3 {
+		t.Errorf("Неожиданный ответ: %s, %d выборок", response.SessionID, len(response.Samples))
+	}
+
+	recorder = httptest.NewRecorder()
+	collector.handleSessionHistory(recorder, httptest.NewRequest(http.MethodGet,
+		"/debug/sessions/history?session_id=unknown", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Для неизвестной сессии ожидался 404, получен %d", recorder.Code)
+	}
+
+	collector.UnregisterSession(sessionID)
+	if _, exists := collector.GetSessionHistory(sessionID, 0); exists {
+		t.Error("История найдена после удаления сессии")
+	}
+}
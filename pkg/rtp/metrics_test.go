@@ -1,8 +1,12 @@
+//go:build !small
+
 // metrics_test.go - Тесты для системы метрик
 package rtp
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -366,6 +370,36 @@ func TestHTTPEndpoints(t *testing.T) {
 	})
 }
 
+// prometheusSourceFunc - PrometheusSource из функции
+type prometheusSourceFunc func(w io.Writer) error
+
+func (f prometheusSourceFunc) WritePrometheus(w io.Writer) error { return f(w) }
+
+// TestPrometheusSource проверяет экспорт дополнительных источников метрик
+func TestPrometheusSource(t *testing.T) {
+	collector := NewMetricsCollector(MetricsConfig{})
+	collector.AddPrometheusSource(prometheusSourceFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, "sip_call_pdd_seconds_count 3\n")
+		return err
+	}))
+
+	w := httptest.NewRecorder()
+	collector.handlePrometheusMetrics(w, httptest.NewRequest("GET", "/metrics/prometheus", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "rtp_sessions_total") || !strings.HasSuffix(body, "sip_call_pdd_seconds_count 3\n") {
+		t.Errorf("Метрики источника не добавлены после метрик RTP:\n%s", body)
+	}
+
+	collector.AddPrometheusSource(prometheusSourceFunc(func(io.Writer) error {
+		return errors.New("source failed")
+	}))
+	w = httptest.NewRecorder()
+	collector.handlePrometheusMetrics(w, httptest.NewRequest("GET", "/metrics/prometheus", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Ошибка источника не возвращена: статус %d", w.Code)
+	}
+}
+
 // TestSampling тестирует функциональность sampling
 func TestSampling(t *testing.T) {
 	// Тестируем с sampling rate 0.5 (50%)
@@ -428,3 +462,37 @@ func abs(x float64) float64 {
 	}
 	return x
 }
+
+// BenchmarkMetricsCollection бенчмарк сбора метрик
+func BenchmarkMetricsCollection(b *testing.B) {
+	collector := NewMetricsCollector(MetricsConfig{
+		SamplingRate:        1.0,
+		MaxCardinality:      100,
+		HealthCheckInterval: time.Hour,
+	})
+
+	transport := NewMockTransport()
+	session, _ := NewSession(SessionConfig{
+		PayloadType: PayloadTypePCMU,
+		MediaType:   MediaTypeAudio,
+		ClockRate:   8000,
+		Transport:   transport,
+	})
+	defer session.Stop()
+
+	_ = collector.RegisterSession("bench-session", session)
+
+	update := SessionMetricsUpdate{
+		PacketsReceived: 1,
+		BytesReceived:   160,
+		Jitter:          5.0,
+		RTT:             25.0,
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		collector.UpdateSessionMetrics("bench-session", update)
+	}
+}
@@ -262,4 +262,70 @@ func TestConcurrentSessionOperations(t *testing.T) {
 
 	wg.Wait()
 	t.Logf("Тест concurrent session operations завершен успешно")
-}
\ No newline at end of file
+}
+// TestConcurrentStatisticsPolling проверяет, что статистика RTCP возвращается
+// копией: опрос и изменение результата во время приема пакетов не приводят
+// к гонкам и не влияют на внутреннее состояние сессии
+func TestConcurrentStatisticsPolling(t *testing.T) {
+	transportConfig := DefaultRTCPTransportConfig()
+	transportConfig.LocalAddr = "127.0.0.1:0"
+	transport, err := NewUDPRTCPTransport(transportConfig)
+	if err != nil {
+		t.Fatalf("Ошибка создания RTCP транспорта: %v", err)
+	}
+	defer func() { _ = transport.Close() }()
+
+	rtcpSession, err := NewRTCPSession(RTCPSessionConfig{
+		SSRC:          0x12345678,
+		RTCPTransport: transport,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания RTCP сессии: %v", err)
+	}
+
+	const remoteSSRC = 0x87654321
+	var wg sync.WaitGroup
+
+	// Прием пакетов
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 1000; i++ {
+			packet := &rtp.Packet{
+				Header: rtp.Header{
+					SSRC:           remoteSSRC,
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 160),
+				},
+				Payload: make([]byte, 160),
+			}
+			rtcpSession.UpdateStatistics(remoteSSRC, packet)
+		}
+	}()
+
+	// Опрос статистики с изменением полученных копий
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				for ssrc, stat := range rtcpSession.GetStatistics() {
+					stat.PacketsReceived = 0
+					stat.Jitter = 0
+					delete(rtcpSession.GetStatistics(), ssrc)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	stats := rtcpSession.GetStatistics()
+	stat, ok := stats[remoteSSRC]
+	if !ok {
+		t.Fatal("Статистика источника должна сохраниться")
+	}
+	if stat.PacketsReceived != 1000 {
+		t.Errorf("Изменение копий не должно влиять на сессию: ожидалось 1000 пакетов, получено %d", stat.PacketsReceived)
+	}
+}
@@ -0,0 +1,211 @@
+// Переписывание SSRC, payload type, sequence number и timestamp для
+// ретранслируемых потоков (B2BUA, медиа релей).
+//
+// При ретрансляции медиа между двумя плечами вызова входящий поток одного
+// плеча отправляется в другое от имени локального SSRC с согласованными на
+// исходящем плече payload types. Смена источника на входящем плече (перевод
+// вызова, re-INVITE на другую конечную точку) скрывается от удаленной
+// стороны: исходящий поток продолжает нумерацию без разрывов, поэтому
+// jitter buffer и RTCP статистика получателя не сбрасываются.
+package rtp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// RewriterConfig содержит параметры переписывания ретранслируемого потока
+type RewriterConfig struct {
+	// SSRC - SSRC исходящего потока. 0 - случайный (RFC 3550 Appendix A.6)
+	SSRC uint32
+
+	// PayloadTypes сопоставляет payload type входящего плеча с согласованным
+	// на исходящем плече, например динамический telephone-event 96 -> 101.
+	// Отсутствующие в таблице payload types передаются без изменений
+	PayloadTypes map[uint8]uint8
+
+	// ClockRate - частота RTP clock для продолжения timestamp при смене
+	// источника. 0 - 8000 Гц
+	ClockRate uint32
+
+	// InitialSequence и InitialTimestamp - начальные значения исходящего
+	// потока. Используются, если RandomizeBases не задан
+	InitialSequence  uint16
+	InitialTimestamp uint32
+	// RandomizeBases выбирает случайные начальные значения (RFC 3550 Section 5.1)
+	RandomizeBases bool
+}
+
+// RewriterStatistics содержит статистику переписывания
+type RewriterStatistics struct {
+	SSRC           uint32 // SSRC исходящего потока
+	PacketsIn      uint64 // Переписанные пакеты
+	PacketsDropped uint64 // Отброшенные дубликаты и пакеты старого источника
+	SourceChanges  uint64 // Смены SSRC входящего потока
+	InboundSSRC    uint32 // Текущий SSRC входящего потока
+}
+
+// StreamRewriter переписывает пакеты одного ретранслируемого потока.
+//
+// Sequence number и timestamp исходящего потока вычисляются через смещения
+// относительно входящего, поэтому порядок и интервалы пакетов сохраняются.
+// При смене входящего SSRC смещения пересчитываются так, чтобы sequence
+// number продолжился со следующего значения, а timestamp - с учетом
+// прошедшего времени. Первый пакет нового источника помечается битом
+// marker (начало talkspurt).
+//
+// Пример использования:
+//
+//	rewriter := rtp.NewStreamRewriter(rtp.RewriterConfig{
+//	    PayloadTypes:   map[uint8]uint8{96: 101},
+//	    RandomizeBases: true,
+//	})
+//	legA.RegisterIncomingHandler(func(packet *pionrtp.Packet, addr net.Addr) {
+//	    if out := rewriter.Rewrite(packet); out != nil {
+//	        _ = legB.SendPacket(out)
+//	    }
+//	})
+type StreamRewriter struct {
+	mu     sync.Mutex
+	config RewriterConfig
+	ssrc   uint32
+
+	started      bool
+	inboundSSRC  uint32
+	previousSSRC uint32 // Предыдущий входящий источник, его пакеты отбрасываются
+	seqOffset    uint16
+	tsOffset     uint32
+
+	lastInSeq  uint16    // Последний sequence number входящего потока
+	lastOutSeq uint16    // Последний отправленный sequence number
+	lastOutTS  uint32    // Последний отправленный timestamp
+	lastTime   time.Time // Время последнего пакета
+	markNext   bool      // Пометить следующий пакет битом marker
+
+	stats RewriterStatistics
+}
+
+// NewStreamRewriter создает переписывание потока
+func NewStreamRewriter(config RewriterConfig) *StreamRewriter {
+	if config.ClockRate == 0 {
+		config.ClockRate = 8000
+	}
+	if config.RandomizeBases {
+		config.InitialSequence = generateRandomUint16(nil)
+		config.InitialTimestamp = generateRandomUint32(nil)
+	}
+
+	ssrc := config.SSRC
+	for ssrc == 0 {
+		ssrc = generateRandomUint32(nil)
+	}
+
+	return &StreamRewriter{
+		config: config,
+		ssrc:   ssrc,
+		stats:  RewriterStatistics{SSRC: ssrc},
+	}
+}
+
+// SSRC возвращает SSRC исходящего потока
+func (r *StreamRewriter) SSRC() uint32 {
+	return r.ssrc
+}
+
+// SetPayloadTypes заменяет таблицу payload types, например после
+// повторного согласования SDP на одном из плеч
+func (r *StreamRewriter) SetPayloadTypes(mapping map[uint8]uint8) {
+	copied := make(map[uint8]uint8, len(mapping))
+	for in, out := range mapping {
+		copied[in] = out
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config.PayloadTypes = copied
+}
+
+// Rewrite возвращает копию пакета с переписанными SSRC, payload type,
+// sequence number и timestamp. Исходный пакет не изменяется. Возвращает nil
+// для повтора последнего пакета и опоздавших пакетов предыдущего источника,
+// которые нарушили бы нумерацию исходящего потока.
+func (r *StreamRewriter) Rewrite(packet *rtp.Packet) *rtp.Packet {
+	return r.rewriteAt(packet, time.Now())
+}
+
+// rewriteAt переписывает пакет, полученный в момент now
+func (r *StreamRewriter) rewriteAt(packet *rtp.Packet, now time.Time) *rtp.Packet {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case !r.started:
+		r.seqOffset = r.config.InitialSequence - packet.SequenceNumber
+		r.tsOffset = r.config.InitialTimestamp - packet.Timestamp
+		r.inboundSSRC = packet.SSRC
+		r.started = true
+	case r.stats.SourceChanges > 0 && packet.SSRC == r.previousSSRC:
+		// Опоздавший пакет прежнего источника нарушил бы нумерацию
+		r.stats.PacketsDropped++
+		return nil
+	case packet.SSRC != r.inboundSSRC:
+		r.switchSource(packet, now)
+	case packet.SequenceNumber == r.lastInSeq:
+		// Повтор последнего пакета. Переупорядоченные пакеты передаются
+		// с сохранением порядка номеров
+		r.stats.PacketsDropped++
+		return nil
+	}
+
+	out := packet.Clone()
+	out.SSRC = r.ssrc
+	out.SequenceNumber = packet.SequenceNumber + r.seqOffset
+	out.Timestamp = packet.Timestamp + r.tsOffset
+	if pt, ok := r.config.PayloadTypes[packet.PayloadType]; ok {
+		out.PayloadType = pt
+	}
+	if r.markNext {
+		out.Marker = true
+		r.markNext = false
+	}
+
+	if int16(out.SequenceNumber-r.lastOutSeq) > 0 || r.stats.PacketsIn == 0 {
+		r.lastInSeq = packet.SequenceNumber
+		r.lastOutSeq = out.SequenceNumber
+		r.lastOutTS = out.Timestamp
+		r.lastTime = now
+	}
+	r.stats.PacketsIn++
+	return out
+}
+
+// switchSource пересчитывает смещения для нового входящего источника.
+// Вызывается под mu.
+func (r *StreamRewriter) switchSource(packet *rtp.Packet, now time.Time) {
+	elapsed := now.Sub(r.lastTime)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	// Timestamp продолжается с учетом паузы между источниками, но не
+	// меньше чем на один отсчет
+	tsDelta := uint32(elapsed.Seconds() * float64(r.config.ClockRate))
+	tsDelta = max(tsDelta, 1)
+
+	r.seqOffset = r.lastOutSeq + 1 - packet.SequenceNumber
+	r.tsOffset = r.lastOutTS + tsDelta - packet.Timestamp
+	r.previousSSRC = r.inboundSSRC
+	r.inboundSSRC = packet.SSRC
+	r.markNext = true
+	r.stats.SourceChanges++
+}
+
+// GetStatistics возвращает статистику переписывания
+func (r *StreamRewriter) GetStatistics() RewriterStatistics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	stats.InboundSSRC = r.inboundSSRC
+	return stats
+}
@@ -0,0 +1,108 @@
+// rewriter_test.go - Тесты переписывания ретранслируемых потоков
+package rtp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+func relayedPacket(ssrc uint32, seq uint16, ts uint32, pt uint8) *rtp.Packet {
+	return &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    pt,
+			SequenceNumber: seq,
+			Timestamp:      ts,
+			SSRC:           ssrc,
+		},
+		Payload: []byte{0xFF, 0xFE},
+	}
+}
+
+// TestStreamRewriterMapping проверяет SSRC, payload type и начальные значения
+func TestStreamRewriterMapping(t *testing.T) {
+	rewriter := NewStreamRewriter(RewriterConfig{
+		SSRC:             0xCAFE,
+		PayloadTypes:     map[uint8]uint8{96: 101},
+		InitialSequence:  1000,
+		InitialTimestamp: 50000,
+	})
+
+	in := relayedPacket(0x1111, 65535, 4294967000, 0)
+	out := rewriter.Rewrite(in)
+	if out.SSRC != 0xCAFE || out.SequenceNumber != 1000 || out.Timestamp != 50000 || out.PayloadType != 0 {
+		t.Errorf("Некорректный первый пакет: %+v", out.Header)
+	}
+	if in.SSRC != 0x1111 || in.SequenceNumber != 65535 {
+		t.Error("Исходный пакет не должен изменяться")
+	}
+
+	// Переход через 0 сохраняет интервалы
+	out = rewriter.Rewrite(relayedPacket(0x1111, 0, 4294967160, 96))
+	if out.SequenceNumber != 1001 || out.Timestamp != 50160 || out.PayloadType != 101 {
+		t.Errorf("Некорректный второй пакет: %+v", out.Header)
+	}
+
+	// Повтор последнего пакета отбрасывается
+	if rewriter.Rewrite(relayedPacket(0x1111, 0, 4294967160, 96)) != nil {
+		t.Error("Дубликат должен отбрасываться")
+	}
+
+	rewriter.SetPayloadTypes(map[uint8]uint8{0: 8})
+	out = rewriter.Rewrite(relayedPacket(0x1111, 1, 24, 0))
+	if out.PayloadType != 8 {
+		t.Errorf("Ожидался payload type 8, получен %d", out.PayloadType)
+	}
+
+	stats := rewriter.GetStatistics()
+	if stats.PacketsIn != 3 || stats.PacketsDropped != 1 || stats.InboundSSRC != 0x1111 {
+		t.Errorf("Некорректная статистика: %+v", stats)
+	}
+}
+
+// TestStreamRewriterSourceChange проверяет непрерывность потока при смене источника
+func TestStreamRewriterSourceChange(t *testing.T) {
+	rewriter := NewStreamRewriter(RewriterConfig{RandomizeBases: true})
+	start := time.Now()
+
+	var last *rtp.Packet
+	for i := 0; i < 3; i++ {
+		last = rewriter.rewriteAt(relayedPacket(0x1111, uint16(100+i), uint32(8000+160*i), 0), start.Add(time.Duration(i)*20*time.Millisecond))
+	}
+	if last.Marker {
+		t.Error("Пакеты одного источника не помечаются битом marker")
+	}
+
+	// Новый источник через 100 мс после последнего пакета
+	switchTime := start.Add(140 * time.Millisecond)
+	out := rewriter.rewriteAt(relayedPacket(0x2222, 7, 123456, 0), switchTime)
+	if out.SSRC != last.SSRC {
+		t.Error("SSRC исходящего потока не должен меняться")
+	}
+	if out.SequenceNumber != last.SequenceNumber+1 {
+		t.Errorf("Ожидался sequence number %d, получен %d", last.SequenceNumber+1, out.SequenceNumber)
+	}
+	if out.Timestamp != last.Timestamp+800 {
+		t.Errorf("Ожидался timestamp %d, получен %d", last.Timestamp+800, out.Timestamp)
+	}
+	if !out.Marker {
+		t.Error("Первый пакет нового источника помечается битом marker")
+	}
+
+	next := rewriter.rewriteAt(relayedPacket(0x2222, 8, 123616, 0), switchTime.Add(20*time.Millisecond))
+	if next.Marker || next.SequenceNumber != out.SequenceNumber+1 || next.Timestamp != out.Timestamp+160 {
+		t.Errorf("Некорректный следующий пакет: %+v", next.Header)
+	}
+
+	// Опоздавший пакет прежнего источника отбрасывается
+	if rewriter.rewriteAt(relayedPacket(0x1111, 103, 8480, 0), switchTime.Add(30*time.Millisecond)) != nil {
+		t.Error("Пакет прежнего источника должен отбрасываться")
+	}
+
+	stats := rewriter.GetStatistics()
+	if stats.SourceChanges != 1 || stats.InboundSSRC != 0x2222 || stats.PacketsDropped != 1 {
+		t.Errorf("Некорректная статистика: %+v", stats)
+	}
+}
@@ -50,6 +50,7 @@ type RTCPSession struct {
 
 	// RTCP статистика по источникам
 	statistics      map[uint32]*RTCPStatistics // Статистика по SSRC
+	clockMappings   map[uint32]ClockMapping    // Соответствие RTP и NTP времени из SR по SSRC
 	statisticsMutex sync.RWMutex
 
 	// Обработчики RTCP событий
@@ -444,6 +445,7 @@ func (rs *RTCPSession) processSenderReport(sr *SenderReport) {
 	// Сохраняем информацию о последнем SR
 	stats.LastSRTimestamp = uint32(sr.NTPTimestamp >> 16) // Средние 32 бита NTP
 	stats.LastSRReceived = time.Now()
+	rs.updateClockMapping(sr, stats.LastSRReceived)
 	stats.PacketsSent = sr.SenderPackets
 	stats.OctetsSent = sr.SenderOctets
 	stats.LastActivity = time.Now()
@@ -543,7 +545,9 @@ func (rs *RTCPSession) hasSentPackets() bool {
 	return false
 }
 
-// GetStatistics возвращает RTCP статистику всех источников
+// GetStatistics возвращает RTCP статистику всех источников.
+// Карта и структуры статистики являются копиями и не изменяются
+// при последующем приеме пакетов, поэтому их можно использовать без синхронизации.
 func (rs *RTCPSession) GetStatistics() map[uint32]*RTCPStatistics {
 	rs.statisticsMutex.RLock()
 	defer rs.statisticsMutex.RUnlock()
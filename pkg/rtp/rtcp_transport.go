@@ -3,9 +3,21 @@ package rtp
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 )
 
+// ErrRemoteAddrUnsupported - транспорт не поддерживает смену удаленного
+// адреса без пересоздания
+var ErrRemoteAddrUnsupported = errors.New("транспорт не поддерживает смену удаленного адреса")
+
+// RemoteAddrSetter - транспорт, удаленный адрес которого меняется на
+// работающем сокете. Реализуется UDP транспортами RTP и RTCP
+type RemoteAddrSetter interface {
+	SetRemoteAddr(addr string) error
+}
+
 // RTCPTransport определяет интерфейс для транспортировки RTCP пакетов
 // Отдельный от RTP транспорта для соблюдения RFC 3550
 type RTCPTransport interface {
@@ -43,6 +55,9 @@ type RTCPTransportConfig struct {
 	LocalAddr  string // Локальный адрес для привязки
 	RemoteAddr string // Удаленный адрес для отправки (опционально)
 	BufferSize int    // Размер буфера для чтения
+
+	// SocketOptions - опции сокета RTCP, обычно те же, что у RTP (опционально)
+	SocketOptions *SocketOptions
 }
 
 // DefaultRTCPTransportConfig возвращает конфигурацию по умолчанию
@@ -76,6 +91,39 @@ func NewTransportPair(rtpTransport Transport, rtcpTransport RTCPTransport, muxMo
 	}
 }
 
+// SetRemoteAddr меняет удаленные адреса RTP и RTCP на работающих сокетах:
+// сессия продолжает отправку с тем же SSRC, sequence number и timestamp.
+// Оба адреса разбираются до изменения, при ошибке транспорты сохраняют
+// прежние адреса. rtcpAddr не используется без отдельного RTCP транспорта
+// (RTCPMuxDemux или RTCP отключен)
+func (tp *TransportPair) SetRemoteAddr(rtpAddr, rtcpAddr string) error {
+	rtpSetter, ok := tp.RTP.(RemoteAddrSetter)
+	if !ok {
+		return ErrRemoteAddrUnsupported
+	}
+	if _, err := net.ResolveUDPAddr("udp", rtpAddr); err != nil {
+		return fmt.Errorf("ошибка разрешения удаленного RTP адреса: %w", err)
+	}
+
+	var rtcpSetter RemoteAddrSetter
+	if tp.RTCP != nil && tp.MuxMode == RTCPMuxNone {
+		if rtcpSetter, ok = tp.RTCP.(RemoteAddrSetter); !ok {
+			return ErrRemoteAddrUnsupported
+		}
+		if _, err := net.ResolveUDPAddr("udp", rtcpAddr); err != nil {
+			return fmt.Errorf("ошибка разрешения удаленного RTCP адреса: %w", err)
+		}
+	}
+
+	if err := rtpSetter.SetRemoteAddr(rtpAddr); err != nil {
+		return err
+	}
+	if rtcpSetter != nil {
+		return rtcpSetter.SetRemoteAddr(rtcpAddr)
+	}
+	return nil
+}
+
 // Close закрывает оба транспорта
 func (tp *TransportPair) Close() error {
 	var rtpErr, rtcpErr error
@@ -5,6 +5,7 @@ package rtp
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -17,10 +18,10 @@ import (
 // Следует принципу единственной ответственности (SRP)
 type RTPSession struct {
 	// Основные параметры RTP
-	ssrc        uint32      // Synchronization Source ID
-	payloadType PayloadType // Тип payload
-	clockRate   uint32      // Частота тактирования
-	transport   Transport   // RTP транспорт
+	ssrc        uint32    // Synchronization Source ID
+	payloadType uint32    // Тип payload (atomic, меняется при смене кодека)
+	clockRate   uint32    // Частота тактирования (atomic)
+	transport   Transport // RTP транспорт
 
 	// RTP счетчики согласно RFC 3550
 	sequenceNumber uint32 // Sequence number (atomic)
@@ -33,8 +34,14 @@ type RTPSession struct {
 	bytesReceived   uint64 // Получено байт (atomic)
 	lastActivity    int64  // Последняя активность (atomic UnixNano)
 
+	// Следующий пакет SendAudio начинает talkspurt (atomic, 1 = да)
+	talkspurtStart uint32
+
+	// Время пакетов и адрес источника для диагностики медиа пути
+	path mediaPathTracker
+
 	// Обработчики RTP событий (защищены мьютексом)
-	handlerMutex     sync.RWMutex                 // Защита обработчиков
+	handlerMutex     sync.RWMutex                // Защита обработчиков
 	onPacketReceived func(*rtp.Packet, net.Addr) // Обработчик входящих пакетов
 	onPacketSent     func(*rtp.Packet)           // Обработчик отправленных пакетов
 
@@ -55,6 +62,8 @@ type RTPSessionConfig struct {
 	// Начальные значения (если 0, будут сгенерированы случайно)
 	InitialSequenceNumber uint32
 	InitialTimestamp      uint32
+	// RandomSource - источник для SSRC и начальных значений, nil - crypto/rand
+	RandomSource io.Reader
 
 	// Обработчики событий
 	OnPacketReceived func(*rtp.Packet, net.Addr)
@@ -75,7 +84,7 @@ func NewRTPSession(config RTPSessionConfig) (*RTPSession, error) {
 	ssrc := config.SSRC
 	if ssrc == 0 {
 		var err error
-		ssrc, err = generateSSRC()
+		ssrc, err = generateSSRC(config.RandomSource)
 		if err != nil {
 			return nil, fmt.Errorf("ошибка генерации SSRC: %w", err)
 		}
@@ -85,12 +94,15 @@ func NewRTPSession(config RTPSessionConfig) (*RTPSession, error) {
 
 	session := &RTPSession{
 		ssrc:        ssrc,
-		payloadType: config.PayloadType,
+		payloadType: uint32(config.PayloadType),
 		clockRate:   config.ClockRate,
 		transport:   config.Transport,
 		ctx:         ctx,
 		cancel:      cancel,
 
+		// Первый пакет потока начинает talkspurt
+		talkspurtStart: 1,
+
 		// Обработчики
 		onPacketReceived: config.OnPacketReceived,
 		onPacketSent:     config.OnPacketSent,
@@ -100,13 +112,13 @@ func NewRTPSession(config RTPSessionConfig) (*RTPSession, error) {
 	if config.InitialSequenceNumber != 0 {
 		session.sequenceNumber = config.InitialSequenceNumber
 	} else {
-		session.sequenceNumber = uint32(generateRandomUint16())
+		session.sequenceNumber = uint32(generateRandomUint16(config.RandomSource))
 	}
 
 	if config.InitialTimestamp != 0 {
 		session.timestamp = config.InitialTimestamp
 	} else {
-		session.timestamp = generateRandomUint32()
+		session.timestamp = generateRandomUint32(config.RandomSource)
 	}
 
 	return session, nil
@@ -142,16 +154,20 @@ func (rs *RTPSession) SendAudio(audioData []byte, duration time.Duration) error
 		return fmt.Errorf("RTP сессия не активна")
 	}
 
+	// Marker bit отмечает начало talkspurt (RFC 3551 Section 4.1): первый пакет
+	// потока и первый пакет после MarkTalkspurtStart
+	marker := atomic.SwapUint32(&rs.talkspurtStart, 0) == 1
+
 	// Создаем RTP пакет
 	packet := &rtp.Packet{
 		Header: rtp.Header{
 			Version:        2,
 			Padding:        false,
 			Extension:      false,
-			Marker:         false, // Для аудио обычно false
-			PayloadType:    uint8(rs.payloadType),
+			Marker:         marker,
+			PayloadType:    uint8(atomic.LoadUint32(&rs.payloadType)),
 			SequenceNumber: uint16(atomic.AddUint32(&rs.sequenceNumber, 1)),
-			Timestamp:      atomic.AddUint32(&rs.timestamp, uint32(duration.Seconds()*float64(rs.clockRate))),
+			Timestamp:      atomic.AddUint32(&rs.timestamp, uint32(duration.Seconds()*float64(atomic.LoadUint32(&rs.clockRate)))),
 			SSRC:           rs.ssrc,
 		},
 		Payload: audioData,
@@ -160,6 +176,13 @@ func (rs *RTPSession) SendAudio(audioData []byte, duration time.Duration) error
 	return rs.SendPacket(packet)
 }
 
+// MarkTalkspurtStart отмечает, что следующий пакет SendAudio начинает новый
+// talkspurt, например первый пакет после паузы подавления тишины (VAD).
+// Пакет отправляется с marker bit
+func (rs *RTPSession) MarkTalkspurtStart() {
+	atomic.StoreUint32(&rs.talkspurtStart, 1)
+}
+
 // SendPacket отправляет готовый RTP пакет
 func (rs *RTPSession) SendPacket(packet *rtp.Packet) error {
 	if atomic.LoadInt32(&rs.active) == 0 {
@@ -218,6 +241,7 @@ func (rs *RTPSession) receiveLoop() {
 func (rs *RTPSession) handleIncomingPacket(packet *rtp.Packet, addr net.Addr) {
 	// Обновляем статистику получения
 	rs.updateReceiveStats(packet)
+	rs.path.received(time.Now(), addr)
 
 	// Thread-safe вызов обработчика
 	rs.handlerMutex.RLock()
@@ -233,7 +257,9 @@ func (rs *RTPSession) handleIncomingPacket(packet *rtp.Packet, addr net.Addr) {
 func (rs *RTPSession) updateSendStats(packet *rtp.Packet) {
 	atomic.AddUint64(&rs.packetsSent, 1)
 	atomic.AddUint64(&rs.bytesSent, uint64(len(packet.Payload)))
-	atomic.StoreInt64(&rs.lastActivity, time.Now().UnixNano())
+	now := time.Now()
+	atomic.StoreInt64(&rs.lastActivity, now.UnixNano())
+	rs.path.sent(now)
 }
 
 // updateReceiveStats обновляет статистику получения
@@ -250,12 +276,20 @@ func (rs *RTPSession) GetSSRC() uint32 {
 
 // GetPayloadType возвращает тип payload
 func (rs *RTPSession) GetPayloadType() PayloadType {
-	return rs.payloadType
+	return PayloadType(atomic.LoadUint32(&rs.payloadType))
 }
 
 // GetClockRate возвращает частоту тактирования
 func (rs *RTPSession) GetClockRate() uint32 {
-	return rs.clockRate
+	return atomic.LoadUint32(&rs.clockRate)
+}
+
+// SetPayloadType меняет payload type и частоту тактирования исходящих пакетов,
+// например после выбора другого кодека из offer в SDP answer. Sequence number,
+// timestamp и SSRC потока сохраняются (RFC 3550 Section 5.1)
+func (rs *RTPSession) SetPayloadType(payloadType PayloadType, clockRate uint32) {
+	atomic.StoreUint32(&rs.payloadType, uint32(payloadType))
+	atomic.StoreUint32(&rs.clockRate, clockRate)
 }
 
 // GetSequenceNumber возвращает текущий sequence number
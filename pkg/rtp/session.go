@@ -19,13 +19,14 @@ package rtp
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/arzzra/soft_phone/pkg/random"
 	"github.com/pion/rtp"
 )
 
@@ -130,6 +131,10 @@ type Session struct {
 	onSourceAdded    func(uint32)                // Новый источник
 	onSourceRemoved  func(uint32)                // Источник удален
 	onRTCPReceived   func(RTCPPacket, net.Addr)  // Обработчик входящих RTCP пакетов
+
+	// Полученные RTCP пакеты для диагностики медиа пути
+	rtcpReceived     atomic.Uint64
+	lastRTCPReceived atomic.Int64
 }
 
 // SessionConfig конфигурация RTP сессии
@@ -141,6 +146,10 @@ type SessionConfig struct {
 	RTCPTransport RTCPTransport     // RTCP транспортный интерфейс (опциональный)
 	LocalSDesc    SourceDescription // Описание локального источника
 
+	// RandomSource - источник для SSRC и начальных sequence number и timestamp.
+	// nil - crypto/rand; тесты задают random.NewSeeded для воспроизводимости
+	RandomSource io.Reader
+
 	// Обработчики событий
 	OnPacketReceived func(*rtp.Packet, net.Addr)
 	OnSourceAdded    func(uint32)
@@ -173,7 +182,7 @@ func NewSession(config SessionConfig) (*Session, error) {
 	}
 
 	// Генерируем SSRC если не задан
-	ssrc, err := generateSSRC()
+	ssrc, err := generateSSRC(config.RandomSource)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка генерации SSRC: %w", err)
 	}
@@ -201,6 +210,7 @@ func NewSession(config SessionConfig) (*Session, error) {
 		PayloadType:      config.PayloadType,
 		ClockRate:        config.ClockRate,
 		Transport:        config.Transport,
+		RandomSource:     config.RandomSource,
 		OnPacketReceived: session.handleRTPPacketReceived,
 	}
 
@@ -306,6 +316,14 @@ func (s *Session) SendAudio(audioData []byte, duration time.Duration) error {
 	return s.rtpSession.SendAudio(audioData, duration)
 }
 
+// MarkTalkspurtStart отмечает, что следующий пакет SendAudio начинает новый
+// talkspurt и отправляется с marker bit (делегирует к RTPSession)
+func (s *Session) MarkTalkspurtStart() {
+	if s.rtpSession != nil {
+		s.rtpSession.MarkTalkspurtStart()
+	}
+}
+
 // SendPacket отправляет готовый RTP пакет (делегирует к RTPSession)
 func (s *Session) SendPacket(packet *rtp.Packet) error {
 	if s.GetState() != SessionStateActive {
@@ -493,7 +511,8 @@ func (s *Session) SendSourceDescription() error {
 	return s.rtcpSession.SendSourceDescription()
 }
 
-// GetRTCPStatistics возвращает RTCP статистику (делегирует к RTCP)
+// GetRTCPStatistics возвращает RTCP статистику (делегирует к RTCP).
+// Возвращает map[uint32]*RTCPStatistics с копиями статистики источников.
 func (s *Session) GetRTCPStatistics() interface{} {
 	if s.rtcpSession == nil {
 		return make(map[uint32]*RTCPStatistics)
@@ -526,6 +545,18 @@ func (s *Session) GetPayloadType() PayloadType {
 	return 0
 }
 
+// SetPayloadType меняет payload type и частоту тактирования исходящих RTP пакетов.
+//
+// Используется, когда SDP answer выбрал не первый кодек из offer: поток
+// продолжает использовать тот же SSRC, sequence number и timestamp.
+//
+// Делегирует операцию к внутреннему RTPSession компоненту.
+func (s *Session) SetPayloadType(payloadType PayloadType, clockRate uint32) {
+	if s.rtpSession != nil {
+		s.rtpSession.SetPayloadType(payloadType, clockRate)
+	}
+}
+
 // GetClockRate возвращает частоту тактирования RTP сессии в Герцах согласно RFC 3550
 //
 // Clock rate определяет частоту дискретизации для RTP временных меток и должен
@@ -656,6 +687,9 @@ func (s *Session) handleSourceRemoved(ssrc uint32, source *RemoteSource) {
 
 // handleRTCPReceived обрабатывает входящие RTCP пакеты от RTCPSession
 func (s *Session) handleRTCPReceived(packet RTCPPacket, addr net.Addr) {
+	s.rtcpReceived.Add(1)
+	s.lastRTCPReceived.Store(time.Now().UnixNano())
+
 	if s.onRTCPReceived != nil {
 		s.onRTCPReceived(packet, addr)
 	}
@@ -667,27 +701,21 @@ func (s *Session) isMultiplexedTransport(transport Transport) bool {
 	return ok
 }
 
-// generateSSRC генерирует случайный SSRC согласно RFC 3550 Appendix A.6
-func generateSSRC() (uint32, error) {
-	var ssrc uint32
-	err := binary.Read(rand.Reader, binary.BigEndian, &ssrc)
-	if err != nil {
-		return 0, err
-	}
-	return ssrc, nil
+// generateSSRC генерирует случайный SSRC согласно RFC 3550 Appendix A.6.
+// source - источник случайных данных, nil - crypto/rand
+func generateSSRC(source io.Reader) (uint32, error) {
+	return random.Uint32(source)
 }
 
 // generateRandomUint16 генерирует случайное 16-битное число
-func generateRandomUint16() uint16 {
-	var val uint16
-	_ = binary.Read(rand.Reader, binary.BigEndian, &val)
-	return val
+func generateRandomUint16(source io.Reader) uint16 {
+	val, _ := random.Uint32(source)
+	return uint16(val)
 }
 
 // generateRandomUint32 генерирует случайное 32-битное число
-func generateRandomUint32() uint32 {
-	var val uint32
-	_ = binary.Read(rand.Reader, binary.BigEndian, &val)
+func generateRandomUint32(source io.Reader) uint32 {
+	val, _ := random.Uint32(source)
 	return val
 }
 
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/arzzra/soft_phone/pkg/media/testsignal"
 	"github.com/pion/rtp"
 )
 
@@ -456,6 +457,56 @@ func TestRTPPacketSending(t *testing.T) {
 	}
 }
 
+// TestRTPMarkerBit тестирует установку marker bit на границах talkspurt
+// Проверяет:
+// - Marker на первом пакете потока
+// - Отсутствие marker на последующих пакетах, в том числе после задержки
+// - Marker на первом пакете после MarkTalkspurtStart (подавление тишины)
+func TestRTPMarkerBit(t *testing.T) {
+	transport := NewMockTransport()
+	transport.SetActive(true)
+
+	session, err := NewSession(SessionConfig{
+		PayloadType: PayloadTypePCMU,
+		MediaType:   MediaTypeAudio,
+		ClockRate:   8000,
+		Transport:   transport,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	defer session.Stop()
+
+	if err := session.Start(); err != nil {
+		t.Fatalf("Ошибка запуска сессии: %v", err)
+	}
+
+	audioData := generateTestAudioData(40)
+	duration := time.Millisecond * 5
+
+	// Задержка отправки без начала нового talkspurt не ставит marker,
+	// начало talkspurt отмечается явно
+	_ = session.SendAudio(audioData, duration)
+	_ = session.SendAudio(audioData, duration)
+	time.Sleep(duration * 4)
+	_ = session.SendAudio(audioData, duration)
+	session.MarkTalkspurtStart()
+	_ = session.SendAudio(audioData, duration)
+	_ = session.SendAudio(audioData, duration)
+
+	sentPackets := transport.GetSentPackets()
+	if len(sentPackets) != 5 {
+		t.Fatalf("Ожидалось 5 отправленных пакетов, получено %d", len(sentPackets))
+	}
+
+	expected := []bool{true, false, false, true, false}
+	for i, packet := range sentPackets {
+		if packet.Header.Marker != expected[i] {
+			t.Errorf("Пакет %d: marker=%t, ожидался %t", i, packet.Header.Marker, expected[i])
+		}
+	}
+}
+
 // === ТЕСТЫ ПРИЕМА RTP ПАКЕТОВ ===
 
 // TestRTPPacketReceiving тестирует прием RTP пакетов
@@ -835,12 +886,7 @@ func TestPayloadTypes(t *testing.T) {
 
 // generateTestAudioData генерирует тестовые аудио данные
 func generateTestAudioData(samples int) []byte {
-	data := make([]byte, samples)
-	for i := range data {
-		// Простая синусоида для μ-law
-		data[i] = byte(128 + 64*(i%32)/16)
-	}
-	return data
+	return testsignal.Encode(testsignal.PCMU, testsignal.Sine(440, 0.5), samples)
 }
 
 // === БЕНЧМАРКИ ===
@@ -886,3 +932,65 @@ func BenchmarkSessionOperations(b *testing.B) {
 		}
 	})
 }
+
+// linkedMockTransport передает отправленные пакеты в очередь приема другого MockTransport
+type linkedMockTransport struct {
+	*MockTransport
+	peer *MockTransport
+}
+
+func (lt *linkedMockTransport) Send(packet *rtp.Packet) error {
+	lt.peer.SimulateReceive(packet)
+	return nil
+}
+
+// BenchmarkSessionRoundTrip бенчмарк полного пути пакета между двумя сессиями:
+// SendAudio, прием транспортом, обработка источника и вызов OnPacketReceived
+func BenchmarkSessionRoundTrip(b *testing.B) {
+	receiverTransport := NewMockTransport()
+	senderTransport := &linkedMockTransport{MockTransport: NewMockTransport(), peer: receiverTransport}
+
+	received := make(chan struct{}, 1)
+	receiver, err := NewSession(SessionConfig{
+		PayloadType: PayloadTypePCMU,
+		MediaType:   MediaTypeAudio,
+		ClockRate:   8000,
+		Transport:   receiverTransport,
+		OnPacketReceived: func(*rtp.Packet, net.Addr) {
+			received <- struct{}{}
+		},
+	})
+	if err != nil {
+		b.Fatalf("Ошибка создания принимающей сессии: %v", err)
+	}
+	defer receiver.Stop()
+
+	sender, err := NewSession(SessionConfig{
+		PayloadType: PayloadTypePCMU,
+		MediaType:   MediaTypeAudio,
+		ClockRate:   8000,
+		Transport:   senderTransport,
+	})
+	if err != nil {
+		b.Fatalf("Ошибка создания отправляющей сессии: %v", err)
+	}
+	defer sender.Stop()
+
+	_ = receiver.Start()
+	_ = sender.Start()
+	audioData := generateTestAudioData(160)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := sender.SendAudio(audioData, time.Millisecond*20); err != nil {
+			b.Fatalf("Ошибка отправки аудио: %v", err)
+		}
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			b.Fatal("Пакет не был получен")
+		}
+	}
+}
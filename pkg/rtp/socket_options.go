@@ -0,0 +1,182 @@
+package rtp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"syscall"
+)
+
+// ErrSocketOptionUnsupported возвращается для опции сокета, которую текущая ОС не поддерживает
+var ErrSocketOptionUnsupported = errors.New("опция сокета не поддерживается платформой")
+
+// Имена опций в SocketOptionsReport
+const (
+	SocketOptionDSCP         = "dscp"
+	SocketOptionReusePort    = "reuseport"
+	SocketOptionBindToDevice = "bindtodevice"
+	SocketOptionRecvBuffer   = "rcvbuf"
+	SocketOptionSendBuffer   = "sndbuf"
+)
+
+// SocketOptions - платформо-независимые настройки UDP сокета транспорта.
+// Опции, которые ОС не поддерживает или отклонила, не применяются молча:
+// результат по каждой опции доступен в SocketOptionsReport, а в режиме Strict
+// транспорт не создается
+type SocketOptions struct {
+	DSCP           int    // DSCP маркировка 1-63 (0 - не изменять), см. DSCPExpeditedForwarding
+	ReusePort      bool   // SO_REUSEPORT (Linux, macOS)
+	BindToDevice   string // Имя сетевого интерфейса (Linux: SO_BINDTODEVICE, macOS: IP_BOUND_IF)
+	RecvBufferSize int    // Размер буфера приема, 0 - по умолчанию ОС
+	SendBufferSize int    // Размер буфера отправки, 0 - по умолчанию ОС
+
+	// Strict - ошибка создания транспорта, если хотя бы одна опция не применена
+	Strict bool
+}
+
+// Validate проверяет корректность настроек сокета
+func (o *SocketOptions) Validate() error {
+	if o.DSCP < 0 || o.DSCP > 63 {
+		return fmt.Errorf("DSCP должен быть в диапазоне 0-63")
+	}
+	if o.RecvBufferSize < 0 || o.SendBufferSize < 0 {
+		return fmt.Errorf("размер буфера сокета не может быть отрицательным")
+	}
+	return nil
+}
+
+// SocketCapabilities описывает, какие опции сокета поддерживает платформа
+type SocketCapabilities struct {
+	Platform     string // runtime.GOOS
+	DSCP         bool   // Маркировка DSCP через IP_TOS/IPV6_TCLASS
+	ReusePort    bool   // SO_REUSEPORT с балансировкой или разделением порта
+	BindToDevice bool   // Привязка к интерфейсу по имени
+	BufferSize   bool   // Проверка фактического размера буферов после установки
+}
+
+// PlatformSocketCapabilities возвращает возможности настройки сокетов текущей ОС
+func PlatformSocketCapabilities() SocketCapabilities {
+	caps := platformSocketCapabilities
+	caps.Platform = runtime.GOOS
+	return caps
+}
+
+// SocketOptionResult - результат применения одной опции сокета
+type SocketOptionResult struct {
+	Option    string // Имя опции (SocketOption*)
+	Requested int    // Запрошенное значение (для DSCP и буферов)
+	Effective int    // Фактическое значение, если ОС его сообщает
+	Err       error  // nil, если опция применена
+}
+
+// SocketOptionsReport - результаты применения SocketOptions к сокету
+type SocketOptionsReport struct {
+	Results []SocketOptionResult
+}
+
+// Failed возвращает опции, которые не удалось применить
+func (r SocketOptionsReport) Failed() []SocketOptionResult {
+	var failed []SocketOptionResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err объединяет ошибки неприменённых опций, nil если применены все
+func (r SocketOptionsReport) Err() error {
+	var errs []error
+	for _, result := range r.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %w", result.Option, result.Err))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *SocketOptionsReport) add(option string, requested, effective int, err error) {
+	r.Results = append(r.Results, SocketOptionResult{
+		Option:    option,
+		Requested: requested,
+		Effective: effective,
+		Err:       err,
+	})
+}
+
+// listenUDP создает UDP сокет и применяет к нему SocketOptions.
+// ReusePort и BindToDevice устанавливаются до bind, остальные опции - после
+func listenUDP(localAddr *net.UDPAddr, opts *SocketOptions) (*net.UDPConn, SocketOptionsReport, error) {
+	var report SocketOptionsReport
+	if opts == nil {
+		conn, err := net.ListenUDP("udp", localAddr)
+		return conn, report, err
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, report, err
+	}
+
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return c.Control(func(fd uintptr) {
+				if opts.ReusePort {
+					report.add(SocketOptionReusePort, 1, 0, setSockOptReusePort(int(fd)))
+				}
+				if opts.BindToDevice != "" {
+					report.add(SocketOptionBindToDevice, 0, 0, setSockOptBindToDevice(int(fd), opts.BindToDevice))
+				}
+			})
+		},
+	}
+
+	address := ""
+	if localAddr != nil {
+		address = localAddr.String()
+	}
+	packetConn, err := lc.ListenPacket(context.Background(), "udp", address)
+	if err != nil {
+		return nil, report, err
+	}
+	conn := packetConn.(*net.UDPConn)
+
+	if opts.RecvBufferSize > 0 {
+		err := conn.SetReadBuffer(opts.RecvBufferSize)
+		report.add(SocketOptionRecvBuffer, opts.RecvBufferSize, socketBufferSize(conn, syscall.SO_RCVBUF), err)
+	}
+	if opts.SendBufferSize > 0 {
+		err := conn.SetWriteBuffer(opts.SendBufferSize)
+		report.add(SocketOptionSendBuffer, opts.SendBufferSize, socketBufferSize(conn, syscall.SO_SNDBUF), err)
+	}
+	if opts.DSCP > 0 {
+		var dscpErr error
+		if rawConn, err := conn.SyscallConn(); err != nil {
+			dscpErr = err
+		} else if err := rawConn.Control(func(fd uintptr) { dscpErr = setSockOptDSCP(int(fd), opts.DSCP) }); err != nil {
+			dscpErr = err
+		}
+		report.add(SocketOptionDSCP, opts.DSCP, 0, dscpErr)
+	}
+
+	if opts.Strict {
+		if err := report.Err(); err != nil {
+			conn.Close()
+			return nil, report, fmt.Errorf("не удалось применить опции сокета: %w", err)
+		}
+	}
+	return conn, report, nil
+}
+
+// socketBufferSize возвращает фактический размер буфера сокета (0, если ОС не сообщает).
+// Linux, например, удваивает запрошенное значение и ограничивает его net.core.rmem_max
+func socketBufferSize(conn *net.UDPConn, option int) int {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	size := 0
+	_ = rawConn.Control(func(fd uintptr) {
+		size, _ = getSockOptInt(int(fd), syscall.SOL_SOCKET, option)
+	})
+	return size
+}
@@ -0,0 +1,73 @@
+package rtp
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+// TestSocketOptionsReport проверяет, что каждая опция сокета попадает в отчет
+// и неподдерживаемые платформой опции не теряются молча
+func TestSocketOptionsReport(t *testing.T) {
+	caps := PlatformSocketCapabilities()
+	if caps.Platform != runtime.GOOS {
+		t.Errorf("Ожидалась платформа %s, получена %s", runtime.GOOS, caps.Platform)
+	}
+
+	transport, err := NewUDPTransport(TransportConfig{
+		LocalAddr: "127.0.0.1:0",
+		SocketOptions: &SocketOptions{
+			DSCP:           DSCPExpeditedForwarding,
+			ReusePort:      true,
+			RecvBufferSize: 128 * 1024,
+			SendBufferSize: 64 * 1024,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Не удалось создать транспорт: %v", err)
+	}
+	defer transport.Close()
+
+	report := transport.SocketOptionsReport()
+	if len(report.Results) != 4 {
+		t.Fatalf("Ожидалось 4 результата, получено %d: %+v", len(report.Results), report.Results)
+	}
+
+	supported := map[string]bool{
+		SocketOptionDSCP:       caps.DSCP,
+		SocketOptionReusePort:  caps.ReusePort,
+		SocketOptionRecvBuffer: caps.BufferSize,
+		SocketOptionSendBuffer: caps.BufferSize,
+	}
+	for _, result := range report.Results {
+		if !supported[result.Option] && !errors.Is(result.Err, ErrSocketOptionUnsupported) {
+			t.Errorf("Опция %s не поддерживается платформой, ожидалась ErrSocketOptionUnsupported: %v", result.Option, result.Err)
+		}
+		if result.Option == SocketOptionRecvBuffer && caps.BufferSize && result.Err == nil && result.Effective == 0 {
+			t.Errorf("Ожидался фактический размер буфера приема")
+		}
+	}
+}
+
+// TestSocketOptionsStrict проверяет отказ создания транспорта в строгом режиме
+func TestSocketOptionsStrict(t *testing.T) {
+	opts := &SocketOptions{BindToDevice: "no-such-interface0", Strict: true}
+	if _, err := NewUDPTransport(TransportConfig{LocalAddr: "127.0.0.1:0", SocketOptions: opts}); err == nil {
+		t.Fatal("Ожидалась ошибка привязки к несуществующему интерфейсу")
+	}
+
+	// Без Strict транспорт создается, ошибка доступна в отчете
+	opts.Strict = false
+	transport, err := NewUDPTransport(TransportConfig{LocalAddr: "127.0.0.1:0", SocketOptions: opts})
+	if err != nil {
+		t.Fatalf("Не удалось создать транспорт: %v", err)
+	}
+	defer transport.Close()
+	if err := transport.SocketOptionsReport().Err(); err == nil {
+		t.Error("Ожидалась ошибка в отчете опций сокета")
+	}
+
+	if _, err := NewUDPTransport(TransportConfig{LocalAddr: "127.0.0.1:0", SocketOptions: &SocketOptions{DSCP: 64}}); err == nil {
+		t.Error("Ожидалась ошибка для DSCP вне диапазона 0-63")
+	}
+}
@@ -514,37 +514,3 @@ func BenchmarkRTPPacketProcessing(b *testing.B) {
 		transport.SimulateReceive(packet)
 	}
 }
-
-// BenchmarkMetricsCollection бенчмарк сбора метрик
-func BenchmarkMetricsCollection(b *testing.B) {
-	collector := NewMetricsCollector(MetricsConfig{
-		SamplingRate:        1.0,
-		MaxCardinality:     100,
-		HealthCheckInterval: time.Hour,
-	})
-
-	transport := NewMockTransport()
-	session, _ := NewSession(SessionConfig{
-		PayloadType: PayloadTypePCMU,
-		MediaType:   MediaTypeAudio,
-		ClockRate:   8000,
-		Transport:   transport,
-	})
-	defer session.Stop()
-
-	_ = collector.RegisterSession("bench-session", session)
-
-	update := SessionMetricsUpdate{
-		PacketsReceived: 1,
-		BytesReceived:   160,
-		Jitter:          5.0,
-		RTT:             25.0,
-	}
-
-	b.ResetTimer()
-	b.ReportAllocs()
-
-	for i := 0; i < b.N; i++ {
-		collector.UpdateSessionMetrics("bench-session", update)
-	}
-}
\ No newline at end of file
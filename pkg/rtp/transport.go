@@ -34,6 +34,10 @@ type TransportConfig struct {
 	LocalAddr  string // Локальный адрес для привязки
 	RemoteAddr string // Удаленный адрес для отправки (опционально)
 	BufferSize int    // Размер буфера для чтения
+
+	// SocketOptions - DSCP, SO_REUSEPORT, буферы и другие опции сокета (опционально).
+	// Поддержка зависит от ОС, см. PlatformSocketCapabilities
+	SocketOptions *SocketOptions
 }
 
 // DefaultTransportConfig возвращает конфигурацию по умолчанию
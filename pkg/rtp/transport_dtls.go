@@ -1,3 +1,5 @@
+//go:build !small
+
 package rtp
 
 import (
@@ -13,6 +15,9 @@ import (
 	"github.com/pion/rtp"
 )
 
+// DTLSAvailable сообщает, включен ли DTLS транспорт в сборку (false с тегом small)
+const DTLSAvailable = true
+
 // DTLSTransport реализует Transport интерфейс для DTLS
 // Обеспечивает шифрованную передачу RTP пакетов для софтфонов
 type DTLSTransport struct {
@@ -22,6 +27,8 @@ type DTLSTransport struct {
 	remoteAddr net.Addr
 	config     DTLSTransportConfig
 
+	socketReport SocketOptionsReport
+
 	active bool
 	mutex  sync.RWMutex
 }
@@ -77,28 +84,6 @@ func DefaultDTLSTransportConfig() DTLSTransportConfig {
 	}
 }
 
-// setSockOptForVoiceUDP настраивает UDP сокет для оптимальной работы с голосом
-func setSockOptForVoiceUDP(conn *net.UDPConn) error {
-	// Получаем raw connection
-	rawConn, err := conn.SyscallConn()
-	if err != nil {
-		return err
-	}
-
-	// Настраиваем приоритет и буферы для минимизации латентности
-	var sockErr error
-	err = rawConn.Control(func(fd uintptr) {
-		// Здесь можно добавить platform-specific настройки
-		// Например, SO_PRIORITY для Linux или Traffic Class для Windows
-		// Для простоты пока оставляем базовые настройки
-	})
-
-	if err != nil {
-		return err
-	}
-	return sockErr
-}
-
 // NewDTLSTransport создает новый DTLS транспорт для RTP
 func NewDTLSTransport(config DTLSTransportConfig) (*DTLSTransport, error) {
 	if config.BufferSize == 0 {
@@ -117,29 +102,28 @@ func NewDTLSTransport(config DTLSTransportConfig) (*DTLSTransport, error) {
 		return nil, fmt.Errorf("ошибка разрешения локального адреса: %w", err)
 	}
 
-	// Создаем UDP соединение
-	conn, err := net.ListenUDP("udp", localAddr)
+	// Создаем UDP соединение и настраиваем сокет для телефонии
+	conn, socketReport, err := listenUDP(localAddr, config.SocketOptions)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания UDP соединения: %w", err)
 	}
 
-	// Настраиваем сокет для телефонии
-	err = setSockOptForVoiceUDP(conn)
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("ошибка настройки сокета: %w", err)
-	}
-
 	transport := &DTLSTransport{
-		conn:      conn,
-		localAddr: conn.LocalAddr(),
-		config:    config,
-		active:    true,
+		conn:         conn,
+		localAddr:    conn.LocalAddr(),
+		config:       config,
+		socketReport: socketReport,
+		active:       true,
 	}
 
 	return transport, nil
 }
 
+// SocketOptionsReport возвращает результат применения SocketOptions к сокету
+func (t *DTLSTransport) SocketOptionsReport() SocketOptionsReport {
+	return t.socketReport
+}
+
 // NewDTLSTransportClient создает DTLS клиент
 func NewDTLSTransportClient(config DTLSTransportConfig) (*DTLSTransport, error) {
 	if config.RemoteAddr == "" {
@@ -410,7 +394,9 @@ func (t *DTLSTransport) GetConnectionState() dtls.State {
 	return dtls.State{}
 }
 
-// SetRemoteAddr устанавливает удаленный адрес (только для режима клиента)
+// SetRemoteAddr устанавливает удаленный адрес (только для режима клиента).
+// После рукопожатия DTLS соединение привязано к адресу, смена возвращает
+// ErrRemoteAddrUnsupported
 func (t *DTLSTransport) SetRemoteAddr(addr string) error {
 	remoteAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
@@ -419,6 +405,9 @@ func (t *DTLSTransport) SetRemoteAddr(addr string) error {
 
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
+	if t.dtlsConn != nil {
+		return ErrRemoteAddrUnsupported
+	}
 	t.remoteAddr = remoteAddr
 
 	return nil
@@ -0,0 +1,49 @@
+//go:build small
+
+package rtp
+
+import "time"
+
+// DTLSAvailable сообщает, включен ли DTLS транспорт в сборку (false с тегом small)
+const DTLSAvailable = false
+
+// DTLSTransport в минимальной сборке не создается: конструкторы возвращают
+// ErrExcludedFromBuild. Тип сохранен, чтобы код, выбирающий транспорт во
+// время выполнения, собирался без изменений.
+type DTLSTransport struct {
+	Transport
+}
+
+// DTLSTransportConfig конфигурация DTLS транспорта. В минимальной сборке
+// содержит только общие параметры: сертификаты и cipher suites pion/dtls
+// недоступны.
+type DTLSTransportConfig struct {
+	TransportConfig
+
+	HandshakeTimeout time.Duration
+	MTU              int
+}
+
+// DefaultDTLSTransportConfig возвращает конфигурацию DTLS по умолчанию
+func DefaultDTLSTransportConfig() DTLSTransportConfig {
+	return DTLSTransportConfig{
+		TransportConfig:  DefaultTransportConfig(),
+		HandshakeTimeout: DefaultHandshakeTimeout,
+		MTU:              1200,
+	}
+}
+
+// NewDTLSTransport возвращает ErrExcludedFromBuild
+func NewDTLSTransport(DTLSTransportConfig) (*DTLSTransport, error) {
+	return nil, ErrExcludedFromBuild
+}
+
+// NewDTLSTransportClient возвращает ErrExcludedFromBuild
+func NewDTLSTransportClient(DTLSTransportConfig) (*DTLSTransport, error) {
+	return nil, ErrExcludedFromBuild
+}
+
+// NewDTLSTransportServer возвращает ErrExcludedFromBuild
+func NewDTLSTransportServer(DTLSTransportConfig) (*DTLSTransport, error) {
+	return nil, ErrExcludedFromBuild
+}
@@ -17,6 +17,8 @@ type UDPRTCPTransport struct {
 	buffer     []byte
 	active     bool
 	mutex      sync.RWMutex
+
+	socketReport SocketOptionsReport
 }
 
 // NewUDPRTCPTransport создает новый UDP RTCP транспорт
@@ -37,16 +39,17 @@ func NewUDPRTCPTransport(config RTCPTransportConfig) (*UDPRTCPTransport, error)
 	}
 
 	// Создаем UDP соединение
-	conn, err := net.ListenUDP("udp", localAddr)
+	conn, socketReport, err := listenUDP(localAddr, config.SocketOptions)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания UDP соединения: %w", err)
 	}
 
 	transport := &UDPRTCPTransport{
-		conn:      conn,
-		localAddr: conn.LocalAddr().(*net.UDPAddr),
-		buffer:    make([]byte, config.BufferSize),
-		active:    true,
+		conn:         conn,
+		localAddr:    conn.LocalAddr().(*net.UDPAddr),
+		buffer:       make([]byte, config.BufferSize),
+		active:       true,
+		socketReport: socketReport,
 	}
 
 	// Устанавливаем удаленный адрес если указан
@@ -120,6 +123,11 @@ func (t *UDPRTCPTransport) ReceiveRTCP(ctx context.Context) ([]byte, net.Addr, e
 	return data, addr, nil
 }
 
+// SocketOptionsReport возвращает результат применения SocketOptions к сокету
+func (t *UDPRTCPTransport) SocketOptionsReport() SocketOptionsReport {
+	return t.socketReport
+}
+
 // LocalAddr возвращает локальный адрес RTCP транспорта
 func (t *UDPRTCPTransport) LocalAddr() net.Addr {
 	t.mutex.RLock()
@@ -2,6 +2,60 @@
 
 package rtp
 
-// Заглушка для macOS - пока нет специфичных настроек сокетов для данной платформы.
-// Функции для настройки QoS, приоритетов и других оптимизаций могут быть добавлены при необходимости.
+import (
+	"net"
+	"syscall"
 
+	"golang.org/x/sys/unix"
+)
+
+// platformSocketCapabilities - macOS поддерживает DSCP, SO_REUSEPORT (BSD семантика
+// без балансировки нагрузки) и привязку к интерфейсу через IP_BOUND_IF
+var platformSocketCapabilities = SocketCapabilities{
+	DSCP:         true,
+	ReusePort:    true,
+	BindToDevice: true,
+	BufferSize:   true,
+}
+
+// getSockOptInt читает целочисленную опцию сокета
+func getSockOptInt(fd, level, option int) (int, error) {
+	return syscall.GetsockoptInt(fd, level, option)
+}
+
+// setSockOptReusePort включает SO_REUSEPORT (macOS).
+// В отличие от Linux, ядро не распределяет пакеты между сокетами:
+// unicast датаграммы получает последний привязанный сокет
+func setSockOptReusePort(fd int) error {
+	return syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+}
+
+// setSockOptBindToDevice привязывает сокет к интерфейсу по индексу (macOS).
+// SO_BINDTODEVICE в macOS нет, аналог - IP_BOUND_IF и IPV6_BOUND_IF
+func setSockOptBindToDevice(fd int, device string) error {
+	iface, err := net.InterfaceByName(device)
+	if err != nil {
+		return err
+	}
+
+	// Как и для DSCP, сокет может быть IPv4 или dual-stack IPv6
+	errV4 := unix.SetsockoptInt(fd, unix.IPPROTO_IP, unix.IP_BOUND_IF, iface.Index)
+	errV6 := unix.SetsockoptInt(fd, unix.IPPROTO_IPV6, unix.IPV6_BOUND_IF, iface.Index)
+	if errV4 != nil && errV6 != nil {
+		return errV4
+	}
+	return nil
+}
+
+// setSockOptDSCP устанавливает DSCP маркировку для QoS (macOS реализация)
+func setSockOptDSCP(fd, dscp int) error {
+	// DSCP находится в старших 6 битах TOS поля
+	tos := dscp << 2
+
+	errV4 := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+	errV6 := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
+	if errV4 != nil && errV6 != nil {
+		return errV4
+	}
+	return nil
+}
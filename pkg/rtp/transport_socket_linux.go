@@ -8,6 +8,19 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// platformSocketCapabilities - Linux поддерживает все опции SocketOptions
+var platformSocketCapabilities = SocketCapabilities{
+	DSCP:         true,
+	ReusePort:    true,
+	BindToDevice: true,
+	BufferSize:   true,
+}
+
+// getSockOptInt читает целочисленную опцию сокета
+func getSockOptInt(fd, level, option int) (int, error) {
+	return syscall.GetsockoptInt(fd, level, option)
+}
+
 // setSockOptReusePort включает SO_REUSEPORT для множественных сокетов на одном порту (Linux)
 // В Linux SO_REUSEPORT позволяет нескольким процессам/потокам эффективно слушать один порт
 // с автоматическим распределением нагрузки на уровне ядра
@@ -51,15 +64,14 @@ func setSockOptDSCP(fd, dscp int) error {
 	// DSCP находится в старших 6 битах TOS поля
 	tos := dscp << 2
 
-	// Устанавливаем для IPv4 - Linux поддерживает IP_TOS
-	if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TOS, tos); err != nil {
-		// В некоторых Linux контейнерах могут быть ограничения
-		return nil // Не критично для работы
+	// Сокет может быть IPv4 или dual-stack IPv6, поэтому устанавливаем оба поля.
+	// Ошибка возвращается, только если ОС отклонила оба (например, в контейнере)
+	errV4 := syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+	errV6 := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
+	if errV4 != nil && errV6 != nil {
+		return errV4
 	}
 
-	// Устанавливаем для IPv6 - Linux поддерживает IPV6_TCLASS
-	syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
-
 	// Linux дополнительно поддерживает детальную настройку QoS через TC (Traffic Control)
 	// Для глубокой интеграции можно использовать netlink сокеты, но это выходит за рамки RTP слоя
 
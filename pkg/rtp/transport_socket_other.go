@@ -0,0 +1,32 @@
+//go:build !linux && !darwin && !windows
+
+package rtp
+
+import (
+	"syscall"
+)
+
+// platformSocketCapabilities - на прочих платформах настраиваются только буферы
+var platformSocketCapabilities = SocketCapabilities{
+	BufferSize: true,
+}
+
+// getSockOptInt читает целочисленную опцию сокета
+func getSockOptInt(fd, level, option int) (int, error) {
+	return syscall.GetsockoptInt(fd, level, option)
+}
+
+// setSockOptReusePort не реализован для текущей платформы
+func setSockOptReusePort(fd int) error {
+	return ErrSocketOptionUnsupported
+}
+
+// setSockOptBindToDevice не реализован для текущей платформы
+func setSockOptBindToDevice(fd int, device string) error {
+	return ErrSocketOptionUnsupported
+}
+
+// setSockOptDSCP не реализован для текущей платформы
+func setSockOptDSCP(fd, dscp int) error {
+	return ErrSocketOptionUnsupported
+}
@@ -3,27 +3,39 @@
 package rtp
 
 import (
+	"fmt"
 	"syscall"
 
 	"golang.org/x/sys/windows"
 )
 
-// setSockOptReusePort включает переиспользование адреса для Windows
-// Windows не поддерживает SO_REUSEPORT, используем SO_REUSEADDR
+// platformSocketCapabilities - Windows позволяет проверить только размеры буферов.
+// IP_TOS без групповой политики QoS игнорируется, SO_REUSEPORT и привязки
+// к интерфейсу по имени нет
+var platformSocketCapabilities = SocketCapabilities{
+	BufferSize: true,
+}
+
+// soExclusiveAddrUse - SO_EXCLUSIVEADDRUSE, определен в winsock2.h как ~SO_REUSEADDR
+const soExclusiveAddrUse = ^syscall.SO_REUSEADDR
+
+// getSockOptInt читает целочисленную опцию сокета
+func getSockOptInt(fd, level, option int) (int, error) {
+	return windows.GetsockoptInt(windows.Handle(fd), level, option)
+}
+
+// setSockOptReusePort не поддерживается в Windows.
+// SO_REUSEADDR в Windows позволяет другому процессу перехватить занятый порт,
+// поэтому не используется как замена SO_REUSEPORT
 func setSockOptReusePort(fd int) error {
-	// Windows поддерживает только SO_REUSEADDR (не SO_REUSEPORT)
-	// SO_REUSEADDR в Windows работает немного по-другому чем в Unix системах
-	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	return fmt.Errorf("%w: в Windows нет SO_REUSEPORT", ErrSocketOptionUnsupported)
 }
 
-// setSockOptBindToDevice заглушка для Windows (не поддерживается напрямую)
-// Windows требует привязки через IP адрес интерфейса, а не имя устройства
+// setSockOptBindToDevice не поддерживается в Windows.
+// Windows требует привязки через IP адрес интерфейса, а не имя устройства:
+// укажите адрес интерфейса в LocalAddr
 func setSockOptBindToDevice(fd int, device string) error {
-	// Windows не поддерживает SO_BINDTODEVICE
-	// Привязка к сетевому интерфейсу должна выполняться через:
-	// 1. Получение IP адреса интерфейса по имени
-	// 2. Bind к конкретному IP адресу при создании сокета
-	return nil // Игнорируем без ошибки
+	return fmt.Errorf("%w: привязка к интерфейсу %s по имени", ErrSocketOptionUnsupported, device)
 }
 
 // setSockOptVoiceOptimizations применяет Windows-специфичные оптимизации для голоса
@@ -51,31 +63,12 @@ func setSockOptVoiceOptimizations(fd int) error {
 	return nil
 }
 
-// setSockOptDSCP устанавливает DSCP маркировку для QoS (Windows реализация)
+// setSockOptDSCP не поддерживается в Windows.
+// IP_TOS принимается без ошибки, но игнорируется стеком без групповой политики QoS
+// (или ключа DisableUserTOSSetting), поэтому сообщаем об отсутствии поддержки.
+// Для маркировки используйте Windows QoS API (см. setWindowsQoSPolicy)
 func setSockOptDSCP(fd, dscp int) error {
-	handle := syscall.Handle(fd)
-
-	// DSCP находится в старших 6 битах TOS поля
-	tos := dscp << 2
-
-	// Windows поддерживает IP_TOS, но требует административных привилегий
-	// для некоторых значений TOS
-	if err := syscall.SetsockoptInt(handle, syscall.IPPROTO_IP, syscall.IP_TOS, tos); err != nil {
-		// Windows часто требует административных прав для QoS
-		// Игнорируем ошибку и продолжаем работу
-		return nil
-	}
-
-	// Для IPv6 пытаемся установить IPV6_TCLASS
-	syscall.SetsockoptInt(handle, syscall.IPPROTO_IPV6, windows.IPV6_TCLASS, tos)
-
-	// Windows дополнительно поддерживает QoS через Windows QoS API
-	// Для полной интеграции можно использовать:
-	// - QOSCreateHandle() / QOSAddSocketToFlow()
-	// - SetServiceType() для классификации трафика
-	// Но это требует отдельной реализации через WinAPI
-
-	return nil
+	return fmt.Errorf("%w: Windows игнорирует IP_TOS, настройте DSCP политикой QoS", ErrSocketOptionUnsupported)
 }
 
 // Дополнительные Windows-специфичные оптимизации
@@ -89,7 +82,7 @@ func setSockOptWindowsSpecific(fd int) error {
 
 	// SO_EXCLUSIVEADDRUSE для предотвращения address hijacking (Windows-специфично)
 	// Это предотвращает захват порта другими процессами
-	if err := syscall.SetsockoptInt(handle, syscall.SOL_SOCKET, windows.SO_EXCLUSIVEADDRUSE, 1); err != nil {
+	if err := syscall.SetsockoptInt(handle, syscall.SOL_SOCKET, soExclusiveAddrUse, 1); err != nil {
 		// Может конфликтовать с SO_REUSEADDR, игнорируем ошибку
 	}
 
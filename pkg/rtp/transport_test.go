@@ -208,234 +208,6 @@ func TestUDPTransportCommunication(t *testing.T) {
 	t.Log("✅ UDP транспорт успешно передал RTP пакет")
 }
 
-// === ТЕСТЫ DTLS ТРАНСПОРТА ===
-
-// TestDTLSTransportCreation тестирует создание DTLS транспорта
-// Проверяет безопасную передачу RTP через DTLS согласно RFC 5764
-func TestDTLSTransportCreation(t *testing.T) {
-	tests := []struct {
-		name        string
-		config      DTLSTransportConfig
-		expectError bool
-		description string
-	}{
-		{
-			name: "Стандартная DTLS конфигурация",
-			config: DTLSTransportConfig{
-				TransportConfig: TransportConfig{
-					LocalAddr:  "127.0.0.1:0",
-					BufferSize: 1500,
-				},
-				InsecureSkipVerify: true, // Только для тестов
-			},
-			expectError: false,
-			description: "Создание DTLS транспорта с базовыми настройками безопасности",
-		},
-		{
-			name: "DTLS сервер конфигурация",
-			config: DTLSTransportConfig{
-				TransportConfig: TransportConfig{
-					LocalAddr:  "127.0.0.1:0",
-					BufferSize: 2048,
-				},
-				InsecureSkipVerify: true,
-			},
-			expectError: false,
-			description: "Создание DTLS сервера для входящих соединений",
-		},
-		{
-			name: "DTLS с минимальными настройками",
-			config: DTLSTransportConfig{
-				TransportConfig: TransportConfig{
-					LocalAddr:  "127.0.0.1:0",
-					BufferSize: 1500,
-				},
-				InsecureSkipVerify: false, // Требует настоящих сертификатов
-			},
-			expectError: false, // Будет создан, но handshake может не пройти
-			description: "Создание DTLS транспорта с проверкой сертификатов",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Logf("Тест создания DTLS транспорта: %s", tt.description)
-
-			transport, err := NewDTLSTransport(tt.config)
-
-			if tt.expectError {
-				if err == nil {
-					t.Errorf("Ожидалась ошибка, но DTLS транспорт создан успешно")
-				}
-				return
-			}
-
-			if err != nil {
-				t.Fatalf("Неожиданная ошибка создания DTLS транспорта: %v", err)
-			}
-
-			defer transport.Close()
-
-			// Проверяем начальное состояние
-			if transport.IsActive() {
-				t.Error("DTLS транспорт не должен быть активен до handshake")
-			}
-
-			if transport.IsHandshakeComplete() {
-				t.Error("DTLS handshake не должен быть завершен при создании")
-			}
-
-			// Проверяем адреса
-			if transport.LocalAddr() == nil {
-				t.Error("LocalAddr не должен быть nil")
-			}
-
-			t.Logf("DTLS транспорт создан: %v",
-				transport.LocalAddr())
-		})
-	}
-}
-
-// TestDTLSTransportHandshake тестирует DTLS handshake между клиентом и сервером
-// Проверяет установление безопасного соединения
-func TestDTLSTransportHandshake(t *testing.T) {
-	// Конфигурация DTLS сервера
-	serverConfig := DTLSTransportConfig{
-		TransportConfig: TransportConfig{
-			LocalAddr:  "127.0.0.1:0",
-			BufferSize: 1500,
-		},
-		InsecureSkipVerify: true,
-		HandshakeTimeout:   time.Second * 5,
-	}
-
-	server, err := NewDTLSTransportServer(serverConfig)
-	if err != nil {
-		t.Fatalf("Ошибка создания DTLS сервера: %v", err)
-	}
-	defer server.Close()
-
-	serverAddr := server.LocalAddr().String()
-	t.Logf("DTLS сервер запущен на %s", serverAddr)
-
-	// Конфигурация DTLS клиента
-	clientConfig := DTLSTransportConfig{
-		TransportConfig: TransportConfig{
-			LocalAddr:  "127.0.0.1:0",
-			RemoteAddr: serverAddr,
-			BufferSize: 1500,
-		},
-		InsecureSkipVerify: true,
-		HandshakeTimeout:   time.Second * 5,
-	}
-
-	client, err := NewDTLSTransportClient(clientConfig)
-	if err != nil {
-		t.Fatalf("Ошибка создания DTLS клиента: %v", err)
-	}
-	defer client.Close()
-
-	t.Log("DTLS handshake автоматически запущен при создании клиента")
-
-	// Ждем завершения handshake
-	handshakeTimeout := time.After(time.Second * 10)
-	handshakeTicker := time.NewTicker(time.Millisecond * 100)
-	defer handshakeTicker.Stop()
-
-	for {
-		select {
-		case <-handshakeTimeout:
-			t.Fatal("Timeout ожидания DTLS handshake")
-		case <-handshakeTicker.C:
-			if client.IsHandshakeComplete() && server.IsHandshakeComplete() {
-				t.Log("✅ DTLS handshake завершен успешно")
-				goto handshakeComplete
-			}
-		}
-	}
-
-handshakeComplete:
-	// Проверяем состояние после handshake
-	if !client.IsActive() {
-		t.Error("DTLS клиент должен быть активен после handshake")
-	}
-
-	if !server.IsActive() {
-		t.Error("DTLS сервер должен быть активен после handshake")
-	}
-
-	// Получаем информацию о соединении
-	clientState := client.GetConnectionState()
-	if len(clientState.PeerCertificates) > 0 {
-		t.Logf("Получены сертификаты peer: %d", len(clientState.PeerCertificates))
-	}
-
-	t.Logf("DTLS соединение установлено: протокол %s", clientState.NegotiatedProtocol)
-}
-
-// === ТЕСТЫ СОВМЕСТИМОСТИ ТРАНСПОРТОВ ===
-
-// TestTransportCompatibility тестирует совместимость UDP и DTLS транспортов
-// Проверяет что оба транспорта реализуют одинаковый интерфейс
-func TestTransportCompatibility(t *testing.T) {
-	// Тестируем что оба транспорта реализуют Transport интерфейс
-	var _ Transport = (*UDPTransport)(nil)
-	var _ Transport = (*DTLSTransport)(nil)
-
-	// UDP транспорт
-	udpConfig := TransportConfig{
-		LocalAddr:  "127.0.0.1:0",
-		BufferSize: 1500,
-	}
-
-	udpTransport, err := NewUDPTransport(udpConfig)
-	if err != nil {
-		t.Fatalf("Ошибка создания UDP транспорта: %v", err)
-	}
-	defer udpTransport.Close()
-
-	// DTLS транспорт
-	dtlsConfig := DTLSTransportConfig{
-		TransportConfig: TransportConfig{
-			LocalAddr:  "127.0.0.1:0",
-			BufferSize: 1500,
-		},
-		InsecureSkipVerify: true,
-	}
-
-	dtlsTransport, err := NewDTLSTransportServer(dtlsConfig)
-	if err != nil {
-		t.Fatalf("Ошибка создания DTLS транспорта: %v", err)
-	}
-	defer dtlsTransport.Close()
-
-	// Проверяем базовую функциональность
-	transports := []struct {
-		name      string
-		transport Transport
-	}{
-		{"UDP", udpTransport},
-		{"DTLS", dtlsTransport},
-	}
-
-	for _, tt := range transports {
-		t.Run(tt.name, func(t *testing.T) {
-			// Проверяем LocalAddr
-			if tt.transport.LocalAddr() == nil {
-				t.Errorf("%s транспорт: LocalAddr не должен быть nil", tt.name)
-			}
-
-			// Проверяем активность
-			if !tt.transport.IsActive() {
-				t.Errorf("%s транспорт: должен быть активен", tt.name)
-			}
-
-			t.Logf("%s транспорт: LocalAddr=%v, Active=%t",
-				tt.name, tt.transport.LocalAddr(), tt.transport.IsActive())
-		})
-	}
-}
-
 // === ТЕСТЫ ПРОИЗВОДИТЕЛЬНОСТИ ТРАНСПОРТОВ ===
 
 // BenchmarkTransportOperations бенчмарк основных операций транспортов
@@ -473,43 +245,50 @@ func BenchmarkTransportOperations(b *testing.B) {
 			_ = transport.Send(packet)
 		}
 	})
+}
 
-	// DTLS транспорт бенчмарк
-	b.Run("DTLS_Send", func(b *testing.B) {
-		config := DTLSTransportConfig{
-			TransportConfig: TransportConfig{
-				LocalAddr:  "127.0.0.1:0",
-				BufferSize: 1500,
-			},
-			InsecureSkipVerify: true,
-		}
+// === ВСПОМОГАТЕЛЬНЫЕ ФУНКЦИИ ===
 
-		transport, err := NewDTLSTransportServer(config)
-		if err != nil {
-			b.Fatalf("Ошибка создания DTLS транспорта: %v", err)
-		}
-		defer transport.Close()
+// TestTransportPairSetRemoteAddr проверяет смену удаленных адресов пары
+// транспортов на работающих сокетах
+func TestTransportPairSetRemoteAddr(t *testing.T) {
+	rtpTransport, err := NewUDPTransport(TransportConfig{LocalAddr: "127.0.0.1:0", RemoteAddr: "127.0.0.1:40000"})
+	if err != nil {
+		t.Fatalf("Ошибка создания RTP транспорта: %v", err)
+	}
+	rtcpTransport, err := NewUDPRTCPTransport(RTCPTransportConfig{LocalAddr: "127.0.0.1:0", RemoteAddr: "127.0.0.1:40001"})
+	if err != nil {
+		t.Fatalf("Ошибка создания RTCP транспорта: %v", err)
+	}
+	pair := NewTransportPair(rtpTransport, rtcpTransport, RTCPMuxNone)
+	defer pair.Close()
 
-		packet := &rtp.Packet{
-			Header: rtp.Header{
-				Version:        2,
-				PayloadType:    0,
-				SequenceNumber: 1,
-				Timestamp:      160,
-				SSRC:           0x12345678,
-			},
-			Payload: make([]byte, 160),
-		}
+	if err := pair.SetRemoteAddr("127.0.0.1:41000", "127.0.0.1:41001"); err != nil {
+		t.Fatalf("Ошибка смены адреса: %v", err)
+	}
+	if rtpTransport.RemoteAddr().String() != "127.0.0.1:41000" || rtcpTransport.RemoteAddr().String() != "127.0.0.1:41001" {
+		t.Errorf("Адреса не обновлены: %v, %v", rtpTransport.RemoteAddr(), rtcpTransport.RemoteAddr())
+	}
 
-		b.ResetTimer()
+	// Ошибка в RTCP адресе не меняет и RTP адрес
+	if err := pair.SetRemoteAddr("127.0.0.1:42000", "invalid"); err == nil {
+		t.Fatal("Ожидалась ошибка для невалидного RTCP адреса")
+	}
+	if rtpTransport.RemoteAddr().String() != "127.0.0.1:41000" {
+		t.Errorf("RTP адрес изменен при ошибке: %v", rtpTransport.RemoteAddr())
+	}
 
-		for i := 0; i < b.N; i++ {
-			// DTLS может требовать установленного соединения
-			if transport.IsHandshakeComplete() {
-				_ = transport.Send(packet)
-			}
-		}
-	})
+	// Мультиплексированный транспорт обновляется через встроенный UDP
+	muxTransport, err := NewMultiplexedUDPTransport(TransportConfig{LocalAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("Ошибка создания мультиплексированного транспорта: %v", err)
+	}
+	muxPair := NewTransportPair(muxTransport, nil, RTCPMuxDemux)
+	defer muxPair.Close()
+	if err := muxPair.SetRemoteAddr("127.0.0.1:43000", ""); err != nil {
+		t.Fatalf("Ошибка смены адреса мультиплексированного транспорта: %v", err)
+	}
+	if muxTransport.RemoteAddr().String() != "127.0.0.1:43000" {
+		t.Errorf("Адрес не обновлен: %v", muxTransport.RemoteAddr())
+	}
 }
-
-// === ВСПОМОГАТЕЛЬНЫЕ ФУНКЦИИ ===
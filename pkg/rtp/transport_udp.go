@@ -31,6 +31,8 @@ type UDPTransport struct {
 	remoteAddr *net.UDPAddr
 	config     TransportConfig
 
+	socketReport SocketOptionsReport
+
 	active bool
 	mutex  sync.RWMutex
 }
@@ -47,23 +49,17 @@ func NewUDPTransport(config TransportConfig) (*UDPTransport, error) {
 		return nil, fmt.Errorf("ошибка разрешения локального адреса: %w", err)
 	}
 
-	// Создаем UDP соединение
-	conn, err := net.ListenUDP("udp", localAddr)
+	// Создаем UDP соединение и настраиваем сокет для телефонии
+	conn, socketReport, err := listenUDP(localAddr, config.SocketOptions)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания UDP соединения: %w", err)
 	}
 
-	// Настраиваем сокет для телефонии
-	err = setSockOptForVoice(conn)
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("ошибка настройки сокета: %w", err)
-	}
-
 	transport := &UDPTransport{
-		conn:   conn,
-		config: config,
-		active: true,
+		conn:         conn,
+		config:       config,
+		socketReport: socketReport,
+		active:       true,
 	}
 
 	// Парсим удаленный адрес если указан
@@ -242,26 +238,9 @@ func (t *UDPTransport) IsActive() bool {
 	return t.active
 }
 
-// setSockOptForVoice настраивает UDP сокет для оптимальной работы с голосом
-func setSockOptForVoice(conn *net.UDPConn) error {
-	// Получаем raw connection
-	rawConn, err := conn.SyscallConn()
-	if err != nil {
-		return err
-	}
-
-	// Настраиваем приоритет и буферы для минимизации латентности
-	var sockErr error
-	err = rawConn.Control(func(fd uintptr) {
-		// Здесь можно добавить platform-specific настройки
-		// Например, SO_PRIORITY для Linux или Traffic Class для Windows
-		// Для простоты пока оставляем базовые настройки
-	})
-
-	if err != nil {
-		return err
-	}
-	return sockErr
+// SocketOptionsReport возвращает результат применения SocketOptions к сокету
+func (t *UDPTransport) SocketOptionsReport() SocketOptionsReport {
+	return t.socketReport
 }
 
 // validatePacketSize проверяет размер пакета для защиты от DoS атак
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// fileFormat - содержимое файла FileStore
+type fileFormat struct {
+	Revision uint64      `json:"revision"`
+	Entries  []fileEntry `json:"entries"`
+}
+
+// fileEntry - запись в файле FileStore
+type fileEntry struct {
+	Key       string     `json:"key"`
+	Value     []byte     `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Revision  uint64     `json:"revision"`
+}
+
+// FileStore - MemoryStore с сохранением записей в JSON файл.
+//
+// Файл перезаписывается целиком после каждого изменения (через временный
+// файл и rename), поэтому FileStore подходит для небольших объемов
+// состояния одного экземпляра. При открытии записи с истекшим сроком
+// жизни отбрасываются, для остальных срок жизни продолжает отсчитываться.
+// Если сохранить файл не удалось, изменение не применяется и Put или
+// Delete возвращают ошибку.
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// NewFileStore открывает хранилище в файле path. Отсутствующий файл
+// создается при первом изменении.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		path:        path,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.persist = s.write
+	return s, nil
+}
+
+// Path возвращает путь к файлу хранилища
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// load читает записи из файла
+func (s *FileStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read store file: %w", err)
+	}
+
+	var content fileFormat
+	if err := json.Unmarshal(data, &content); err != nil {
+		return fmt.Errorf("failed to parse store file %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revision = content.Revision
+	now := time.Now()
+	for _, fe := range content.Entries {
+		item := &memoryItem{entry: Entry{Key: fe.Key, Value: fe.Value, Revision: fe.Revision}}
+		if fe.ExpiresAt != nil {
+			item.entry.ExpiresAt = *fe.ExpiresAt
+		}
+		if item.expired(now) {
+			continue
+		}
+		if fe.Revision > s.revision {
+			s.revision = fe.Revision
+		}
+		s.entries[fe.Key] = item
+		s.scheduleLocked(item)
+	}
+	return nil
+}
+
+// write сохраняет записи в файл. Вызывается MemoryStore под mu.
+func (s *FileStore) write(entries map[string]*memoryItem, revision uint64) error {
+	content := fileFormat{
+		Revision: revision,
+		Entries:  make([]fileEntry, 0, len(entries)),
+	}
+	for _, item := range entries {
+		fe := fileEntry{Key: item.entry.Key, Value: item.entry.Value, Revision: item.entry.Revision}
+		if !item.entry.ExpiresAt.IsZero() {
+			expiresAt := item.entry.ExpiresAt
+			fe.ExpiresAt = &expiresAt
+		}
+		content.Entries = append(content.Entries, fe)
+	}
+	sort.Slice(content.Entries, func(i, j int) bool { return content.Entries[i].Key < content.Entries[j].Key })
+
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write store file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace store file: %w", err)
+	}
+	return nil
+}
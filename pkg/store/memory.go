@@ -0,0 +1,273 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchBuffer - емкость канала событий одной подписки Watch
+const watchBuffer = 64
+
+// memoryItem - запись MemoryStore и таймер ее срока жизни
+type memoryItem struct {
+	entry Entry
+	timer *time.Timer
+}
+
+// memoryWatcher - подписка Watch
+type memoryWatcher struct {
+	prefix string
+	events chan Event
+}
+
+// MemoryStore хранит записи в памяти процесса. Срок жизни записей
+// отслеживается таймерами, поэтому события EventExpire доставляются без
+// обращений к хранилищу.
+//
+// События доставляются подписчикам Watch без блокировки: если подписчик не
+// успевает читать канал, события для него отбрасываются с предупреждением
+// в логе.
+type MemoryStore struct {
+	mu       sync.Mutex
+	entries  map[string]*memoryItem
+	watchers map[*memoryWatcher]struct{}
+	revision uint64
+	closed   bool
+
+	// persist сохраняет записи после каждого изменения (FileStore).
+	// Вызывается под mu.
+	persist func(entries map[string]*memoryItem, revision uint64) error
+}
+
+// NewMemoryStore создает пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:  make(map[string]*memoryItem),
+		watchers: make(map[*memoryWatcher]struct{}),
+	}
+}
+
+// Get возвращает запись по ключу
+func (m *MemoryStore) Get(ctx context.Context, key string) (Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return Entry{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return Entry{}, ErrClosed
+	}
+	item, ok := m.entries[key]
+	if !ok || item.expired(time.Now()) {
+		return Entry{}, ErrNotFound
+	}
+	return item.entry.clone(), nil
+}
+
+// Put создает или заменяет запись
+func (m *MemoryStore) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+
+	item := &memoryItem{entry: Entry{
+		Key:      key,
+		Value:    append([]byte(nil), value...),
+		Revision: m.revision + 1,
+	}}
+	if ttl > 0 {
+		item.entry.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	previous, existed := m.entries[key]
+	m.entries[key] = item
+	if err := m.persistLocked(m.revision + 1); err != nil {
+		if existed {
+			m.entries[key] = previous
+		} else {
+			delete(m.entries, key)
+		}
+		return err
+	}
+	m.revision++
+
+	if existed && previous.timer != nil {
+		previous.timer.Stop()
+	}
+	m.scheduleLocked(item)
+	m.notifyLocked(Event{Type: EventPut, Entry: item.entry})
+	return nil
+}
+
+// Delete удаляет запись
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	item, ok := m.entries[key]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(m.entries, key)
+	if err := m.persistLocked(m.revision); err != nil {
+		m.entries[key] = item
+		return err
+	}
+	if item.timer != nil {
+		item.timer.Stop()
+	}
+	m.notifyLocked(Event{Type: EventDelete, Entry: item.entry})
+	return nil
+}
+
+// List возвращает записи с префиксом prefix по порядку ключей
+func (m *MemoryStore) List(ctx context.Context, prefix string) ([]Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	now := time.Now()
+	var entries []Entry
+	for key, item := range m.entries {
+		if strings.HasPrefix(key, prefix) && !item.expired(now) {
+			entries = append(entries, item.entry.clone())
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries, nil
+}
+
+// Watch подписывается на изменения записей с префиксом prefix
+func (m *MemoryStore) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	w := &memoryWatcher{prefix: prefix, events: make(chan Event, watchBuffer)}
+	m.watchers[w] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.watchers[w]; ok {
+			delete(m.watchers, w)
+			close(w.events)
+		}
+	}()
+	return w.events, nil
+}
+
+// Close останавливает таймеры и закрывает каналы Watch. Повторный вызов безопасен
+func (m *MemoryStore) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	for _, item := range m.entries {
+		if item.timer != nil {
+			item.timer.Stop()
+		}
+	}
+	for w := range m.watchers {
+		close(w.events)
+	}
+	m.watchers = nil
+	return nil
+}
+
+// scheduleLocked запускает таймер срока жизни записи. Вызывается под mu.
+func (m *MemoryStore) scheduleLocked(item *memoryItem) {
+	if item.entry.ExpiresAt.IsZero() {
+		return
+	}
+	item.timer = time.AfterFunc(time.Until(item.entry.ExpiresAt), func() {
+		m.expire(item)
+	})
+}
+
+// expire удаляет запись по истечении срока жизни, если она не была заменена
+func (m *MemoryStore) expire(item *memoryItem) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := item.entry.Key
+	if m.closed || m.entries[key] != item {
+		return
+	}
+
+	delete(m.entries, key)
+	if err := m.persistLocked(m.revision); err != nil {
+		slog.Error("Ошибка сохранения хранилища после истечения записи",
+			slog.String("key", key),
+			slog.String("error", err.Error()))
+	}
+	m.notifyLocked(Event{Type: EventExpire, Entry: item.entry})
+}
+
+// persistLocked сохраняет записи, если хранилище персистентное. Вызывается под mu.
+func (m *MemoryStore) persistLocked(revision uint64) error {
+	if m.persist == nil {
+		return nil
+	}
+	return m.persist(m.entries, revision)
+}
+
+// notifyLocked доставляет событие подписчикам. Вызывается под mu.
+func (m *MemoryStore) notifyLocked(event Event) {
+	for w := range m.watchers {
+		if !strings.HasPrefix(event.Entry.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.events <- Event{Type: event.Type, Entry: event.Entry.clone()}:
+		default:
+			slog.Warn("Подписчик хранилища не успевает читать события",
+				slog.String("prefix", w.prefix),
+				slog.String("key", event.Entry.Key),
+				slog.String("event", event.Type.String()))
+		}
+	}
+}
+
+// expired проверяет истечение срока жизни записи на момент now
+func (i *memoryItem) expired(now time.Time) bool {
+	return !i.entry.ExpiresAt.IsZero() && !now.Before(i.entry.ExpiresAt)
+}
+
+// clone возвращает копию записи с собственным буфером значения
+func (e Entry) clone() Entry {
+	e.Value = append([]byte(nil), e.Value...)
+	return e
+}
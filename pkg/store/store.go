@@ -0,0 +1,99 @@
+// Package store определяет хранилище состояния функций стека: привязок
+// регистратора, подписок, слотов парковки. Хранилище позволяет сохранить
+// состояние между перезапусками или разделить его между экземплярами.
+//
+// В пакете есть две реализации: MemoryStore (в памяти процесса) и FileStore
+// (MemoryStore с сохранением в JSON файл). Кластерные бэкенды (etcd, Redis и
+// т.п.) реализуют интерфейс Store вне стека и подключаются так же:
+//
+//	st, err := store.NewFileStore("/var/lib/softphone/state.json")
+//	if err != nil {
+//	    return err
+//	}
+//	defer st.Close()
+//	lot := dialog.NewParkLot("701", "702")
+//	lot.SetStore(st)
+//
+// Ключи - строки с иерархией через "/" (например, "park/701"), по префиксу
+// ключа работают List и Watch. Значения - произвольные байты, обычно JSON.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound ошибка при обращении к отсутствующему или истекшему ключу
+	ErrNotFound = errors.New("key not found")
+	// ErrClosed ошибка при обращении к закрытому хранилищу
+	ErrClosed = errors.New("store is closed")
+)
+
+// Entry - запись хранилища
+type Entry struct {
+	Key       string
+	Value     []byte
+	ExpiresAt time.Time // Нулевое значение - запись без срока жизни
+	Revision  uint64    // Номер изменения, монотонно растет в пределах хранилища
+}
+
+// EventType - тип изменения записи
+type EventType int
+
+const (
+	// EventPut - запись создана или обновлена
+	EventPut EventType = iota
+	// EventDelete - запись удалена
+	EventDelete
+	// EventExpire - истек срок жизни записи
+	EventExpire
+)
+
+// String возвращает название типа события
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	default:
+		return "unknown"
+	}
+}
+
+// Event - изменение записи, доставляемое через Watch. Для EventDelete и
+// EventExpire Entry содержит последнее значение записи.
+type Event struct {
+	Type  EventType
+	Entry Entry
+}
+
+// Store - хранилище записей с ограниченным сроком жизни.
+//
+// Реализации должны быть безопасны для использования из нескольких горутин.
+type Store interface {
+	// Get возвращает запись по ключу или ErrNotFound
+	Get(ctx context.Context, key string) (Entry, error)
+
+	// Put создает или заменяет запись. ttl > 0 задает срок жизни записи,
+	// по истечении которого она удаляется с событием EventExpire;
+	// ttl <= 0 - запись хранится до Delete.
+	Put(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete удаляет запись. Удаление отсутствующего ключа возвращает ErrNotFound.
+	Delete(ctx context.Context, key string) error
+
+	// List возвращает записи с ключами, начинающимися с prefix, по порядку ключей
+	List(ctx context.Context, prefix string) ([]Entry, error)
+
+	// Watch подписывается на изменения записей с ключами, начинающимися с
+	// prefix. Канал закрывается при отмене ctx или закрытии хранилища.
+	Watch(ctx context.Context, prefix string) (<-chan Event, error)
+
+	// Close освобождает ресурсы хранилища и закрывает каналы Watch
+	Close() error
+}
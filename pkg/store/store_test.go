@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// receiveEvent ждет событие Watch
+func receiveEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Канал событий закрыт")
+		}
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("Таймаут ожидания события хранилища")
+	}
+	return Event{}
+}
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	events, err := s.Watch(ctx, "park/")
+	if err != nil {
+		t.Fatalf("Ошибка Watch: %v", err)
+	}
+
+	if _, err := s.Get(ctx, "park/701"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound для отсутствующего ключа, получено %v", err)
+	}
+
+	if err := s.Put(ctx, "park/701", []byte("call-1"), 0); err != nil {
+		t.Fatalf("Ошибка Put: %v", err)
+	}
+	if err := s.Put(ctx, "reg/alice", []byte("binding"), 0); err != nil {
+		t.Fatalf("Ошибка Put: %v", err)
+	}
+
+	entry, err := s.Get(ctx, "park/701")
+	if err != nil {
+		t.Fatalf("Ошибка Get: %v", err)
+	}
+	if string(entry.Value) != "call-1" || !entry.ExpiresAt.IsZero() {
+		t.Errorf("Неверная запись: %+v", entry)
+	}
+
+	event := receiveEvent(t, events)
+	if event.Type != EventPut || event.Entry.Key != "park/701" {
+		t.Errorf("Ожидалось put park/701, получено %s %s", event.Type, event.Entry.Key)
+	}
+
+	if err := s.Put(ctx, "park/702", []byte("call-2"), 50*time.Millisecond); err != nil {
+		t.Fatalf("Ошибка Put с TTL: %v", err)
+	}
+	if event := receiveEvent(t, events); event.Type != EventPut || event.Entry.Key != "park/702" {
+		t.Errorf("Ожидалось put park/702, получено %s %s", event.Type, event.Entry.Key)
+	}
+
+	list, err := s.List(ctx, "park/")
+	if err != nil {
+		t.Fatalf("Ошибка List: %v", err)
+	}
+	if len(list) != 2 || list[0].Key != "park/701" || list[1].Key != "park/702" {
+		t.Errorf("Неверный результат List: %+v", list)
+	}
+
+	event = receiveEvent(t, events)
+	if event.Type != EventExpire || event.Entry.Key != "park/702" || string(event.Entry.Value) != "call-2" {
+		t.Errorf("Ожидалось expire park/702, получено %s %+v", event.Type, event.Entry)
+	}
+	if _, err := s.Get(ctx, "park/702"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Истекшая запись доступна: %v", err)
+	}
+
+	if err := s.Delete(ctx, "park/701"); err != nil {
+		t.Fatalf("Ошибка Delete: %v", err)
+	}
+	if event := receiveEvent(t, events); event.Type != EventDelete || event.Entry.Key != "park/701" {
+		t.Errorf("Ожидалось delete park/701, получено %s %s", event.Type, event.Entry.Key)
+	}
+	if err := s.Delete(ctx, "park/701"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound при повторном Delete, получено %v", err)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Ошибка Close: %v", err)
+	}
+	if _, ok := <-events; ok {
+		t.Error("Канал Watch не закрыт после Close")
+	}
+	if err := s.Put(ctx, "park/701", nil, 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Ожидалась ErrClosed после Close, получено %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	testStore(t, mustFileStore(t, filepath.Join(t.TempDir(), "state.json")))
+}
+
+func TestMemoryStoreWatchCancel(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := s.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Ошибка Watch: %v", err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Получено событие после отмены подписки")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Канал Watch не закрыт после отмены контекста")
+	}
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	s := mustFileStore(t, path)
+	if err := s.Put(ctx, "sub/1", []byte("dialog"), time.Hour); err != nil {
+		t.Fatalf("Ошибка Put: %v", err)
+	}
+	if err := s.Put(ctx, "sub/2", []byte("short"), 30*time.Millisecond); err != nil {
+		t.Fatalf("Ошибка Put: %v", err)
+	}
+	if err := s.Put(ctx, "park/701", []byte{0, 1, 2}, 0); err != nil {
+		t.Fatalf("Ошибка Put: %v", err)
+	}
+	first, _ := s.Get(ctx, "sub/1")
+	_ = s.Close()
+
+	time.Sleep(50 * time.Millisecond)
+
+	reopened := mustFileStore(t, path)
+	defer reopened.Close()
+
+	entry, err := reopened.Get(ctx, "sub/1")
+	if err != nil {
+		t.Fatalf("Запись не восстановлена: %v", err)
+	}
+	if string(entry.Value) != "dialog" || !entry.ExpiresAt.Equal(first.ExpiresAt) || entry.Revision != first.Revision {
+		t.Errorf("Восстановлена неверная запись: %+v, ожидалось %+v", entry, first)
+	}
+	if entry, err := reopened.Get(ctx, "park/701"); err != nil || len(entry.Value) != 3 {
+		t.Errorf("Двоичное значение не восстановлено: %+v, %v", entry, err)
+	}
+	if _, err := reopened.Get(ctx, "sub/2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Истекшая запись восстановлена: %v", err)
+	}
+
+	// Номера изменений продолжаются после восстановления
+	if err := reopened.Put(ctx, "sub/3", nil, 0); err != nil {
+		t.Fatalf("Ошибка Put: %v", err)
+	}
+	if entry, _ := reopened.Get(ctx, "sub/3"); entry.Revision <= first.Revision {
+		t.Errorf("Номер изменения %d не больше восстановленного %d", entry.Revision, first.Revision)
+	}
+}
+
+func TestFileStoreWriteError(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	s := mustFileStore(t, path)
+	defer s.Close()
+	if err := s.Put(ctx, "park/701", []byte("call"), 0); err != nil {
+		t.Fatalf("Ошибка Put: %v", err)
+	}
+
+	// Каталог вместо временного файла делает запись невозможной
+	if err := os.Mkdir(path+".tmp", 0o700); err != nil {
+		t.Fatalf("Ошибка создания каталога: %v", err)
+	}
+	if err := s.Put(ctx, "park/702", []byte("call"), 0); err == nil {
+		t.Fatal("Ожидалась ошибка сохранения")
+	}
+	if _, err := s.Get(ctx, "park/702"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Несохраненная запись применена: %v", err)
+	}
+	if err := s.Delete(ctx, "park/701"); err == nil {
+		t.Fatal("Ожидалась ошибка сохранения при Delete")
+	}
+	if _, err := s.Get(ctx, "park/701"); err != nil {
+		t.Errorf("Запись удалена несмотря на ошибку сохранения: %v", err)
+	}
+}
+
+func TestFileStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("Ожидалась ошибка разбора файла")
+	}
+}
+
+func mustFileStore(t *testing.T, path string) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Ошибка открытия хранилища: %v", err)
+	}
+	return s
+}
@@ -1,3 +1,8 @@
+// Package rtp - экспериментальный набросок интерфейсов RTP сессии. Пакет не
+// используется и не развивается.
+//
+// Deprecated: используйте github.com/arzzra/soft_phone/pkg/rtp или
+// стабильный API github.com/arzzra/soft_phone/pkg/api/v1.
 package rtp
 
 import (